@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultAPTHookPath is where "debswarm apt enable" installs the dpkg hook.
+// It sorts after 90debswarm.conf so the proxy configuration reads first.
+const defaultAPTHookPath = "/etc/apt/apt.conf.d/91debswarm-hook"
+
+// aptNotifyTimeout bounds how long the hook may hold up apt when the daemon
+// is slow or unreachable. The daemon answers before scanning, so this is
+// only ever reached when something is wrong.
+const aptNotifyTimeout = 5 * time.Second
+
+func aptCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apt",
+		Short: "Manage APT integration",
+		Long: `Manage debswarm's integration with APT.
+
+Use 'debswarm apt enable' to install a dpkg hook that tells the daemon about
+packages APT downloaded without the proxy (a direct mirror, a proxy bypass
+for a down daemon, a manual apt-get download), so they are imported from
+/var/cache/apt/archives and shared with the swarm.`,
+	}
+
+	cmd.AddCommand(aptEnableCmd())
+	cmd.AddCommand(aptDisableCmd())
+	cmd.AddCommand(aptNotifyCmd())
+
+	return cmd
+}
+
+func aptEnableCmd() *cobra.Command {
+	var hookPath string
+
+	cmd := &cobra.Command{
+		Use:   "enable",
+		Short: "Install the APT hook that imports directly downloaded packages",
+		Long: `Install a DPkg::Post-Invoke hook into APT's configuration.
+
+After every dpkg run the hook calls 'debswarm apt notify', which asks the
+running daemon to hash the .deb files in APT's archives directory, import
+those the package index knows, and announce them to peers. Packages already
+in the cache are skipped. The hook never fails an apt operation: if the
+daemon is not running it does nothing.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exe, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to locate debswarm binary: %w", err)
+			}
+			if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+				exe = resolved
+			}
+
+			configPath := cfgFile
+			if configPath != "" {
+				if configPath, err = filepath.Abs(configPath); err != nil {
+					return fmt.Errorf("failed to resolve config path: %w", err)
+				}
+			}
+			if strings.ContainsRune(exe+configPath, '"') {
+				return fmt.Errorf("paths containing '\"' cannot be used in APT configuration")
+			}
+
+			if err := writeAPTConfig(hookPath, aptHookConfig(exe, configPath)); err != nil {
+				return err
+			}
+			fmt.Printf("Installed APT hook: %s\n", hookPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&hookPath, "path", defaultAPTHookPath, "APT configuration file to write")
+	return cmd
+}
+
+func aptDisableCmd() *cobra.Command {
+	var hookPath string
+
+	cmd := &cobra.Command{
+		Use:   "disable",
+		Short: "Remove the APT hook",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := os.Remove(hookPath); err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					fmt.Println("APT hook is not installed.")
+					return nil
+				}
+				return fmt.Errorf("failed to remove APT hook: %w", err)
+			}
+			fmt.Printf("Removed APT hook: %s\n", hookPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&hookPath, "path", defaultAPTHookPath, "APT configuration file to remove")
+	return cmd
+}
+
+func aptNotifyCmd() *cobra.Command {
+	var quiet bool
+
+	cmd := &cobra.Command{
+		Use:   "notify",
+		Short: "Ask the daemon to import packages from APT's archives",
+		Long: `Ask the running daemon to import new packages from APT's archives
+directory and announce them. This is what the APT hook runs; it returns as
+soon as the daemon has queued the import. Requires metrics to be enabled,
+as the request goes to the daemon's local API.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Metrics.Port == 0 {
+				return fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+			}
+
+			url := fmt.Sprintf("http://%s:%d/api/apt/import", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port)
+			client := &http.Client{Timeout: aptNotifyTimeout}
+			if err := notifyAPTImport(client, url); err != nil {
+				return err
+			}
+			if !quiet {
+				fmt.Println("Import of APT archives queued.")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Print nothing on success")
+	return cmd
+}
+
+// aptHookConfig renders the APT configuration snippet that runs
+// "debswarm apt notify" after dpkg. configPath is passed through so the hook
+// reaches the same daemon the user enabled it for.
+func aptHookConfig(exe, configPath string) string {
+	notify := shellQuote(exe)
+	if configPath != "" {
+		notify += " --config " + shellQuote(configPath)
+	}
+	notify += " apt notify --quiet"
+
+	var b strings.Builder
+	b.WriteString("// Installed by \"debswarm apt enable\"; remove with \"debswarm apt disable\".\n")
+	b.WriteString("//\n")
+	b.WriteString("// After each dpkg run, asks the debswarm daemon to import packages APT\n")
+	b.WriteString("// downloaded without the proxy, so they are shared with the swarm.\n")
+	b.WriteString("// Errors are ignored: a stopped daemon must never break apt.\n\n")
+	fmt.Fprintf(&b, "DPkg::Post-Invoke { %s; };\n",
+		aptQuote(fmt.Sprintf("if [ -x %s ]; then %s >/dev/null 2>&1 || true; fi", shellQuote(exe), notify)))
+	return b.String()
+}
+
+// writeAPTConfig writes an APT configuration file atomically.
+func writeAPTConfig(path, content string) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil { // #nosec G306 -- APT config must be world-readable
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to install %s: %w", path, err)
+	}
+	return nil
+}
+
+// notifyAPTImport posts the import request to the daemon's API.
+func notifyAPTImport(client *http.Client, url string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), aptNotifyTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("daemon not running or metrics disabled: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected status %d from daemon", resp.StatusCode)
+	}
+	return nil
+}
+
+// loopbackHost returns the host to reach a server bound to bind from this
+// machine. The import endpoint only accepts loopback clients.
+func loopbackHost(bind string) string {
+	switch bind {
+	case "", "0.0.0.0":
+		return "127.0.0.1"
+	case "::", "[::]":
+		return "[::1]"
+	}
+	return bind
+}
+
+// shellQuote quotes s for /bin/sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// aptQuote quotes s as an APT configuration string. APT strings cannot
+// contain double quotes; enable refuses paths that have one.
+func aptQuote(s string) string {
+	return `"` + s + `"`
+}
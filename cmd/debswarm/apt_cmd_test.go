@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPTHookConfig(t *testing.T) {
+	got := aptHookConfig("/usr/bin/debswarm", "/etc/debswarm/it's.toml")
+	want := `DPkg::Post-Invoke { "if [ -x '/usr/bin/debswarm' ]; then '/usr/bin/debswarm' --config '/etc/debswarm/it'\''s.toml' apt notify --quiet >/dev/null 2>&1 || true; fi"; };`
+	if !strings.Contains(got, want) {
+		t.Errorf("hook config missing %q:\n%s", want, got)
+	}
+	if strings.Contains(aptHookConfig("/usr/bin/debswarm", ""), "--config") {
+		t.Error("hook passes --config when none was given")
+	}
+}
+
+func TestWriteAPTConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "91debswarm-hook")
+	if err := writeAPTConfig(path, "content\n"); err != nil {
+		t.Fatalf("writeAPTConfig: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "content\n" {
+		t.Fatalf("hook file = %q, %v", data, err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("temporary file left behind")
+	}
+}
+
+func TestNotifyAPTImport(t *testing.T) {
+	var gotMethod, gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.Path
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	if err := notifyAPTImport(srv.Client(), srv.URL+"/api/apt/import"); err != nil {
+		t.Fatalf("notifyAPTImport: %v", err)
+	}
+	if gotMethod != http.MethodPost || gotPath != "/api/apt/import" {
+		t.Errorf("request = %s %s, want POST /api/apt/import", gotMethod, gotPath)
+	}
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	if err := notifyAPTImport(unavailable.Client(), unavailable.URL); err == nil {
+		t.Error("expected error for non-202 response")
+	}
+}
+
+func TestLoopbackHost(t *testing.T) {
+	for bind, want := range map[string]string{
+		"":          "127.0.0.1",
+		"0.0.0.0":   "127.0.0.1",
+		"::":        "[::1]",
+		"127.0.0.1": "127.0.0.1",
+	} {
+		if got := loopbackHost(bind); got != want {
+			t.Errorf("loopbackHost(%q) = %q, want %q", bind, got, want)
+		}
+	}
+}
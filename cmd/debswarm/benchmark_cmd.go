@@ -11,12 +11,12 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/debswarm/debswarm/internal/benchmark"
-	"github.com/debswarm/debswarm/internal/config"
+	"github.com/debswarm/debswarm/internal/units"
 )
 
 func benchmarkCmd() *cobra.Command {
 	var (
-		fileSize   string
+		fileSize   units.SizeFlag
 		peerCount  int
 		iterations int
 		workers    int
@@ -68,12 +68,9 @@ Examples:
 					}
 					return fmt.Errorf("scenario not found")
 				}
-			} else if fileSize != "" {
+			} else if fileSize > 0 {
 				// Custom scenario from flags
-				size, err := config.ParseSize(fileSize)
-				if err != nil {
-					return fmt.Errorf("invalid file-size: %w", err)
-				}
+				size := int64(fileSize)
 
 				peerConfigs := make([]benchmark.PeerConfig, peerCount)
 				for i := 0; i < peerCount; i++ {
@@ -111,7 +108,7 @@ Examples:
 		},
 	}
 
-	cmd.Flags().StringVar(&fileSize, "file-size", "", "File size to test (e.g., 100MB)")
+	cmd.Flags().Var(&fileSize, "file-size", "File size to test (e.g., 100MB)")
 	cmd.Flags().IntVar(&peerCount, "peers", 3, "Number of simulated peers")
 	cmd.Flags().IntVar(&iterations, "iterations", 3, "Number of iterations per test")
 	cmd.Flags().IntVar(&workers, "workers", 4, "Number of parallel chunk workers")
@@ -143,7 +140,7 @@ func benchmarkListCmd() *cobra.Command {
 
 func benchmarkStressCmd() *cobra.Command {
 	var (
-		fileSize    string
+		fileSize    units.SizeFlag
 		peerCount   int
 		concurrency int
 	)
@@ -172,10 +169,7 @@ Examples:
 				cancel()
 			}()
 
-			size, err := config.ParseSize(fileSize)
-			if err != nil {
-				return fmt.Errorf("invalid file-size: %w", err)
-			}
+			size := int64(fileSize)
 
 			// Build peer configs
 			peerConfigs := make([]benchmark.PeerConfig, peerCount)
@@ -243,7 +237,8 @@ Examples:
 		},
 	}
 
-	cmd.Flags().StringVar(&fileSize, "file-size", "10MB", "File size per download")
+	fileSize = units.SizeFlag(10 * units.MiB)
+	cmd.Flags().Var(&fileSize, "file-size", "File size per download")
 	cmd.Flags().IntVarP(&concurrency, "concurrency", "n", 10, "Number of concurrent downloads")
 	cmd.Flags().IntVar(&peerCount, "peers", 4, "Number of simulated peers")
 
@@ -252,7 +247,7 @@ Examples:
 
 func benchmarkConcurrencyCmd() *cobra.Command {
 	var (
-		fileSize  string
+		fileSize  units.SizeFlag
 		peerCount int
 		maxWorker int
 	)
@@ -280,10 +275,7 @@ Examples:
 				cancel()
 			}()
 
-			size, err := config.ParseSize(fileSize)
-			if err != nil {
-				return fmt.Errorf("invalid file-size: %w", err)
-			}
+			size := int64(fileSize)
 
 			fmt.Printf("Concurrency Benchmark\n")
 			fmt.Printf("══════════════════════════════════════\n")
@@ -328,7 +320,8 @@ Examples:
 		},
 	}
 
-	cmd.Flags().StringVar(&fileSize, "file-size", "100MB", "File size to test")
+	fileSize = units.SizeFlag(100 * units.MiB)
+	cmd.Flags().Var(&fileSize, "file-size", "File size to test")
 	cmd.Flags().IntVar(&peerCount, "peers", 4, "Number of simulated peers")
 	cmd.Flags().IntVar(&maxWorker, "max-workers", 8, "Maximum worker count to test")
 
@@ -340,7 +333,7 @@ func benchmarkProxyCmd() *cobra.Command {
 		proxyAddr   string
 		targetURL   string
 		concurrency int
-		duration    time.Duration
+		duration    units.DurationFlag
 	)
 
 	cmd := &cobra.Command{
@@ -380,7 +373,7 @@ Examples:
 			fmt.Printf("  Proxy:       %s\n", proxyAddr)
 			fmt.Printf("  Target URL:  %s\n", targetURL)
 			fmt.Printf("  Concurrency: %d\n", concurrency)
-			fmt.Printf("  Duration:    %v\n", duration)
+			fmt.Printf("  Duration:    %v\n", time.Duration(duration))
 			fmt.Printf("══════════════════════════════════════\n\n")
 			fmt.Printf("Running load test...\n")
 
@@ -388,7 +381,7 @@ Examples:
 				ProxyAddr:   proxyAddr,
 				TargetURL:   fullURL,
 				Concurrency: concurrency,
-				Duration:    duration,
+				Duration:    time.Duration(duration),
 			}
 
 			result, err := lt.Run(ctx)
@@ -404,7 +397,8 @@ Examples:
 	cmd.Flags().StringVar(&proxyAddr, "proxy", "127.0.0.1:9977", "Proxy address")
 	cmd.Flags().StringVar(&targetURL, "url", "", "Target URL to fetch through proxy (required)")
 	cmd.Flags().IntVarP(&concurrency, "concurrency", "n", 10, "Number of concurrent requests")
-	cmd.Flags().DurationVar(&duration, "duration", 10*time.Second, "Test duration")
+	duration = units.DurationFlag(10 * time.Second)
+	cmd.Flags().Var(&duration, "duration", "Test duration")
 
 	return cmd
 }
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/config"
+)
+
+func buildCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Use debswarm for debootstrap/mmdebstrap chroot builds",
+		Long: `Chroot builds go through the build listener ([build] port), a second proxy
+port with a build profile: cached Release and index files are reused for
+build.metadata_ttl without asking the mirror, and every package a build
+fetches is recorded in a named build set. With build.pin the packages are
+pinned, so the next run of the same build finds them in the cache.
+
+Name the build set in the mirror URL, or set build.default_key for
+requests made through the listener as an HTTP proxy:
+
+  debootstrap bookworm /srv/chroot http://127.0.0.1:9979/build/bookworm-minbase/deb.debian.org/debian
+  mmdebstrap bookworm /srv/chroot.tar http://127.0.0.1:9979/build/bookworm-ci/deb.debian.org/debian
+  http_proxy=http://127.0.0.1:9979 debootstrap bookworm /srv/chroot http://deb.debian.org/debian`,
+	}
+
+	cmd.AddCommand(buildEnvCmd())
+	cmd.AddCommand(buildListCmd())
+	cmd.AddCommand(buildShowCmd())
+	cmd.AddCommand(buildForgetCmd())
+
+	return cmd
+}
+
+func buildEnvCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "env [build-set]",
+		Short: "Print the mirror URL and proxy settings for a build",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Build.Port == 0 {
+				return fmt.Errorf("the build listener is disabled; set [build] port in the config")
+			}
+			key := cfg.Build.GetDefaultKey()
+			if len(args) == 1 {
+				key = args[0]
+			}
+			if !config.ValidBuildKey(key) {
+				return fmt.Errorf("invalid build set name %q; use up to 64 letters, digits, '.', '_' or '-'", key)
+			}
+			fmt.Print(buildEnv(cfg, key))
+			return nil
+		},
+	}
+}
+
+// buildEnv returns the shell settings that route a build through the build
+// listener into the named build set
+func buildEnv(cfg *config.Config, key string) string {
+	host := cfg.Network.ProxyBind
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	listener := "http://" + net.JoinHostPort(host, strconv.Itoa(cfg.Build.Port))
+	return fmt.Sprintf("DEBSWARM_BUILD_MIRROR=%s/build/%s/deb.debian.org/debian\n"+
+		"DEBSWARM_BUILD_PROXY=%s\n"+
+		"# debootstrap SUITE TARGET \"$DEBSWARM_BUILD_MIRROR\"\n"+
+		"# mmdebstrap SUITE TARGET \"$DEBSWARM_BUILD_MIRROR\"\n",
+		listener, key, listener)
+}
+
+func buildListCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List build sets",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := openBuildCache()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = c.Close() }()
+
+			sets, err := c.BuildSets()
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(sets)
+			}
+			if len(sets) == 0 {
+				fmt.Println("No build sets recorded")
+				return nil
+			}
+			fmt.Printf("%-32s %8s %8s %10s  %s\n", "BUILD SET", "PACKAGES", "CACHED", "SIZE", "LAST USED")
+			for _, s := range sets {
+				fmt.Printf("%-32s %8d %8d %10s  %s\n",
+					s.Key, s.Packages, s.Cached, formatBytes(s.Bytes), s.LastUsed.Format(time.DateTime))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output JSON")
+	return cmd
+}
+
+func buildShowCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "show <build-set>",
+		Short: "List the packages recorded for a build set",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := openBuildCache()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = c.Close() }()
+
+			pkgs, err := c.BuildSetPackages(args[0])
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(pkgs)
+			}
+			if len(pkgs) == 0 {
+				return fmt.Errorf("no build set named %q", args[0])
+			}
+			missing := 0
+			for _, p := range pkgs {
+				mark := " "
+				if !p.Cached {
+					mark = "!"
+					missing++
+				}
+				fmt.Printf(" %s %s  %10s  %s\n", mark, p.SHA256[:16], formatBytes(p.Size), p.URL)
+			}
+			fmt.Printf("\n%d packages, %d no longer cached\n", len(pkgs), missing)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output JSON")
+	return cmd
+}
+
+func buildForgetCmd() *cobra.Command {
+	var unpin bool
+
+	cmd := &cobra.Command{
+		Use:   "forget <build-set>",
+		Short: "Forget a build set",
+		Long: `Forget a build set. Its packages stay cached; with --unpin they are also
+unpinned, unless another build set still uses them.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := openBuildCache()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = c.Close() }()
+
+			pkgs, err := c.BuildSetPackages(args[0])
+			if err != nil {
+				return err
+			}
+			n, err := c.DeleteBuildSet(args[0])
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return fmt.Errorf("no build set named %q", args[0])
+			}
+
+			unpinned := 0
+			if unpin {
+				if unpinned, err = unpinUnusedBuildPackages(c, pkgs); err != nil {
+					return err
+				}
+			}
+			fmt.Printf("Forgot build set %s (%d packages, %d unpinned)\n", args[0], n, unpinned)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&unpin, "unpin", false, "Unpin packages no other build set uses")
+	return cmd
+}
+
+// unpinUnusedBuildPackages unpins the pinned packages among pkgs that no
+// remaining build set records
+func unpinUnusedBuildPackages(c *cache.Cache, pkgs []cache.BuildPackage) (int, error) {
+	sets, err := c.BuildSets()
+	if err != nil {
+		return 0, err
+	}
+	inUse := make(map[string]bool)
+	for _, s := range sets {
+		remaining, err := c.BuildSetPackages(s.Key)
+		if err != nil {
+			return 0, err
+		}
+		for _, p := range remaining {
+			inUse[p.SHA256] = true
+		}
+	}
+
+	unpinned := 0
+	for _, p := range pkgs {
+		if inUse[p.SHA256] || !c.IsPinned(p.SHA256) {
+			continue
+		}
+		if err := c.Unpin(p.SHA256); err != nil {
+			return unpinned, err
+		}
+		unpinned++
+	}
+	return unpinned, nil
+}
+
+func openBuildCache() (*cache.Cache, error) {
+	logger, _ := setupLogger()
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return cache.New(cfg.Cache.Path, cacheMaxSize(&cfg.Cache), logger)
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
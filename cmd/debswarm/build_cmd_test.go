@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/config"
+)
+
+func TestBuildEnv(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Build.Port = 9979
+	env := buildEnv(cfg, "bookworm-ci")
+	if !strings.Contains(env, "DEBSWARM_BUILD_MIRROR=http://127.0.0.1:9979/build/bookworm-ci/deb.debian.org/debian\n") ||
+		!strings.Contains(env, "DEBSWARM_BUILD_PROXY=http://127.0.0.1:9979\n") {
+		t.Errorf("env = %q", env)
+	}
+
+	cfg.Network.ProxyBind = "192.168.1.10"
+	if env := buildEnv(cfg, "x"); !strings.Contains(env, "http://192.168.1.10:9979/build/x/") {
+		t.Errorf("env with a LAN bind = %q", env)
+	}
+}
+
+func TestUnpinUnusedBuildPackages(t *testing.T) {
+	c, err := cache.New(t.TempDir(), 1<<30, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	put := func(name string) string {
+		data := []byte("contents of " + name)
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		if err := c.Put(bytes.NewReader(data), hash, name); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Pin(hash); err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	shared, own := put("libc6.deb"), put("bash.deb")
+	for _, r := range []struct{ key, hash string }{{"a", shared}, {"a", own}, {"b", shared}} {
+		if err := c.RecordBuildPackage(r.key, r.hash, "http://deb.debian.org/debian/pool/"+r.hash, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pkgs, _ := c.BuildSetPackages("a")
+	if _, err := c.DeleteBuildSet("a"); err != nil {
+		t.Fatal(err)
+	}
+	n, err := unpinUnusedBuildPackages(c, pkgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || c.IsPinned(own) || !c.IsPinned(shared) {
+		t.Errorf("unpinned %d; own pinned=%v shared pinned=%v", n, c.IsPinned(own), c.IsPinned(shared))
+	}
+}
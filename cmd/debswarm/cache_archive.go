@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/iothrottle"
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+// archiveBufferSize buffers archive streams, which usually run over a pipe
+const archiveBufferSize = 1 << 20
+
+func cacheExportCmd() *cobra.Command {
+	var stream bool
+	var output string
+	var matches []string
+	var since string
+
+	cmd := &cobra.Command{
+		Use:   "export (--stream | --output FILE) [--match PATTERN]... [--since 30d]",
+		Short: "Write cached packages and their metadata to an archive",
+		Long: `Write cached packages, with their cache metadata (package name, version,
+architecture, origin and pin), to a tar archive for another cache to import.
+
+With --stream the archive goes to standard output, so a new seed can be
+bootstrapped over ssh, or the archive kept in object storage:
+
+  debswarm cache export --stream | ssh seed2 debswarm cache import --stream
+  debswarm cache export --stream | zstd | aws s3 cp - s3://bucket/seed.tar.zst
+
+Packages are not hashed on the way out; the importing side verifies each one
+as it stores it. Progress is written to standard error.
+
+Examples:
+  debswarm cache export --output /srv/backup/cache.tar
+  debswarm cache export --stream --match 'linux-image*' --since 30d > recent.tar`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if stream == (output != "") {
+				return fmt.Errorf("exactly one of --stream or --output is required")
+			}
+			opts := seedExportOptions{matches: matches}
+			for _, m := range matches {
+				if _, err := path.Match(m, ""); err != nil {
+					return fmt.Errorf("invalid --match %q: %w", m, err)
+				}
+			}
+			if since != "" {
+				t, err := parseSince(since, time.Now())
+				if err != nil {
+					return err
+				}
+				opts.since = t
+			}
+
+			c, err := openCache()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = c.Close() }()
+
+			var w io.Writer = os.Stdout
+			var file *os.File
+			if output != "" {
+				// #nosec G304 -- the archive path is chosen by the operator
+				if file, err = os.Create(output); err != nil {
+					return err
+				}
+				defer file.Close()
+				w = file
+			}
+			bw := bufio.NewWriterSize(w, archiveBufferSize)
+
+			start := time.Now()
+			stats, err := c.Export(bw, func(pkg *cache.Package) bool { return exportSelected(pkg, &opts) })
+			if err != nil {
+				return fmt.Errorf("export failed: %w", err)
+			}
+			if err := bw.Flush(); err != nil {
+				return fmt.Errorf("export failed: %w", err)
+			}
+			if file != nil {
+				if err := file.Sync(); err != nil {
+					return err
+				}
+			}
+			fmt.Fprintf(os.Stderr, "Exported %d packages (%s) in %s\n",
+				stats.Packages, formatBytes(stats.Bytes), time.Since(start).Round(time.Second))
+			if stats.Skipped > 0 {
+				fmt.Fprintf(os.Stderr, "  %d packages were evicted during the export and left out\n", stats.Skipped)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&stream, "stream", false, "Write the archive to standard output")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write the archive to this file")
+	cmd.Flags().StringArrayVar(&matches, "match", nil, "Export packages whose name or file name matches this shell pattern (repeatable; default all)")
+	cmd.Flags().StringVar(&since, "since", "", "Only packages cached within this period (e.g. 30d, 72h, 2026-01-01)")
+	return cmd
+}
+
+func cacheImportCmd() *cobra.Command {
+	var stream bool
+	var verbose bool
+
+	cmd := &cobra.Command{
+		Use:   "import (--stream | FILE)",
+		Short: "Import packages from an archive written by 'cache export'",
+		Long: `Import packages and their cache metadata from an archive written by
+'debswarm cache export': from standard input with --stream, typically piped
+over ssh, or from a file.
+
+Each package is verified against its SHA256 while it is written, so the
+archive need not be trusted: a package that does not match is reported and
+skipped. Packages already cached are skipped too, so an interrupted import
+can simply be run again. A running daemon announces the imported packages
+with its next reannouncement pass.
+
+Examples:
+  ssh seed1 debswarm cache export --stream | debswarm cache import --stream
+  debswarm cache import /srv/backup/cache.tar`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if stream == (len(args) == 1) {
+				return fmt.Errorf("give either --stream or an archive file")
+			}
+			var r io.Reader = os.Stdin
+			if len(args) == 1 {
+				// #nosec G304 -- the archive path is chosen by the operator
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+
+			c, err := openCache()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = c.Close() }()
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			// Pace reading the archive, and so writing the packages
+			r = iothrottle.New(ioThrottleLimits(&cfg.Cache.IOThrottle)).Reader(cmd.Context(), r)
+
+			start := time.Now()
+			stats, err := c.Import(bufio.NewReaderSize(r, archiveBufferSize), func(row *cache.ArchivedPackage, err error) {
+				switch {
+				case err == nil:
+					if verbose {
+						fmt.Fprintf(os.Stderr, "  [OK]   %s (%s)\n", sanitize.Filename(row.Filename), formatBytes(row.Size))
+					}
+				case errors.Is(err, cache.ErrAlreadyCached):
+					if verbose {
+						fmt.Fprintf(os.Stderr, "  [SKIP] %s (already cached)\n", sanitize.Filename(row.Filename))
+					}
+				default:
+					fmt.Fprintf(os.Stderr, "  [FAIL] %s: %v\n", sanitize.Filename(row.Filename), err)
+				}
+			})
+			fmt.Fprintf(os.Stderr, "Imported %d packages (%s) in %s, %d already cached, %d failed\n",
+				stats.Packages, formatBytes(stats.Bytes), time.Since(start).Round(time.Second), stats.Skipped, stats.Failed)
+			if err != nil {
+				return fmt.Errorf("import stopped: %w", err)
+			}
+			if stats.Failed > 0 {
+				return fmt.Errorf("%d packages failed to import", stats.Failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&stream, "stream", false, "Read the archive from standard input")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "List every package")
+	return cmd
+}
+
+// openCache opens the configured cache
+func openCache() (*cache.Cache, error) {
+	logger, err := setupLogger()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	c, err := cache.New(cfg.Cache.Path, cacheMaxSize(&cfg.Cache), logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache: %w", err)
+	}
+	return c, nil
+}
@@ -1,16 +1,19 @@
 package main
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/hashutil"
+	"github.com/debswarm/debswarm/internal/iothrottle"
 )
 
 func cacheCmd() *cobra.Command {
@@ -27,12 +30,14 @@ func cacheCmd() *cobra.Command {
 	cmd.AddCommand(cacheRecentCmd())
 	cmd.AddCommand(cachePinCmd())
 	cmd.AddCommand(cacheUnpinCmd())
+	cmd.AddCommand(cacheExportCmd())
+	cmd.AddCommand(cacheImportCmd())
 
 	return cmd
 }
 
 func cacheListCmd() *cobra.Command {
-	var pinnedOnly bool
+	var pinnedOnly, verbose, long bool
 
 	cmd := &cobra.Command{
 		Use:   "list",
@@ -44,7 +49,7 @@ func cacheListCmd() *cobra.Command {
 				return err
 			}
 
-			maxSize := cfg.Cache.MaxSizeBytes()
+			maxSize := cacheMaxSize(&cfg.Cache)
 			c, err := cache.New(cfg.Cache.Path, maxSize, logger)
 			if err != nil {
 				return err
@@ -73,11 +78,23 @@ func cacheListCmd() *cobra.Command {
 				if pkg.Pinned {
 					pinMark = "*"
 				}
-				fmt.Printf(" %s %s  %10s  %s\n",
-					pinMark,
-					pkg.SHA256[:16],
-					formatBytes(pkg.Size),
-					pkg.Filename)
+				if verbose {
+					fmt.Printf(" %s %s  %10s  %-22s  %s\n",
+						pinMark,
+						pkg.SHA256[:16],
+						formatBytes(pkg.Size),
+						advertisedUntil(pkg, time.Now()),
+						pkg.Filename)
+				} else {
+					fmt.Printf(" %s %s  %10s  %s\n",
+						pinMark,
+						pkg.SHA256[:16],
+						formatBytes(pkg.Size),
+						pkg.Filename)
+				}
+				if long {
+					fmt.Printf("     %s\n", describeOrigin(pkg.Origin))
+				}
 			}
 
 			if !pinnedOnly && c.PinnedCount() > 0 {
@@ -90,9 +107,41 @@ func cacheListCmd() *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&pinnedOnly, "pinned", false, "Show only pinned packages")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Show until when each package is advertised in the DHT")
+	cmd.Flags().BoolVar(&long, "long", false, "Show the repository, suite, component and mirror each package came from")
 	return cmd
 }
 
+// describeOrigin formats where a package came from for cache list --long.
+func describeOrigin(o cache.Origin) string {
+	if o == (cache.Origin{}) {
+		return "origin unknown"
+	}
+	s := "from " + o.Repo
+	if o.Suite != "" {
+		s += " " + o.Suite
+		if o.Component != "" {
+			s += "/" + o.Component
+		}
+	}
+	if o.Mirror != "" {
+		s += " via " + o.Mirror
+	}
+	return s
+}
+
+// advertisedUntil describes when the provider record of a package expires
+func advertisedUntil(pkg *cache.Package, now time.Time) string {
+	switch {
+	case pkg.AdvertisedUntil.Unix() <= 0:
+		return "not advertised"
+	case !pkg.AdvertisedUntil.After(now):
+		return "expired " + pkg.AdvertisedUntil.Format("2006-01-02 15:04")
+	default:
+		return "until " + pkg.AdvertisedUntil.Format("2006-01-02 15:04")
+	}
+}
+
 func cacheClearCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "clear",
@@ -104,7 +153,7 @@ func cacheClearCmd() *cobra.Command {
 				return err
 			}
 
-			maxSize := cfg.Cache.MaxSizeBytes()
+			maxSize := cacheMaxSize(&cfg.Cache)
 			c, err := cache.New(cfg.Cache.Path, maxSize, logger)
 			if err != nil {
 				return err
@@ -142,7 +191,7 @@ func cacheStatsCmd() *cobra.Command {
 				return err
 			}
 
-			maxSize := cfg.Cache.MaxSizeBytes()
+			maxSize := cacheMaxSize(&cfg.Cache)
 			c, err := cache.New(cfg.Cache.Path, maxSize, logger)
 			if err != nil {
 				return err
@@ -163,7 +212,11 @@ func cacheStatsCmd() *cobra.Command {
 			fmt.Printf("Total Packages:    %d\n", stats.TotalPackages)
 			fmt.Printf("With Metadata:     %d\n", stats.UniquePackages)
 			fmt.Printf("Total Size:        %s\n", formatBytes(stats.TotalSize))
-			fmt.Printf("Max Size:          %s\n", cfg.Cache.MaxSize)
+			if limit := cfg.Cache.MaxSizeLimit(); limit.DiskRelative() {
+				fmt.Printf("Max Size:          %s (%s of the disk)\n", formatBytes(maxSize), limit)
+			} else {
+				fmt.Printf("Max Size:          %s\n", formatBytes(maxSize))
+			}
 			fmt.Printf("Usage:             %.1f%%\n", float64(stats.TotalSize)/float64(maxSize)*100)
 			fmt.Printf("Unannounced:       %d\n", len(unannounced))
 			fmt.Println()
@@ -208,10 +261,12 @@ func cacheStatsCmd() *cobra.Command {
 }
 
 func cacheVerifyCmd() *cobra.Command {
-	return &cobra.Command{
+	var jobs int
+
+	cmd := &cobra.Command{
 		Use:   "verify",
 		Short: "Verify integrity of all cached packages",
-		Long:  "Verify that all cached packages match their expected SHA256 hashes. Reports any corrupted or missing files.",
+		Long:  "Verify that all cached packages match their expected SHA256 hashes. Reports any corrupted or missing files. Packages are hashed in parallel, one per CPU by default.",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			logger, _ := setupLogger()
 			cfg, err := loadConfig()
@@ -219,7 +274,7 @@ func cacheVerifyCmd() *cobra.Command {
 				return err
 			}
 
-			maxSize := cfg.Cache.MaxSizeBytes()
+			maxSize := cacheMaxSize(&cfg.Cache)
 			c, err := cache.New(cfg.Cache.Path, maxSize, logger)
 			if err != nil {
 				return err
@@ -238,41 +293,50 @@ func cacheVerifyCmd() *cobra.Command {
 
 			fmt.Printf("Verifying %d cached packages...\n\n", len(packages))
 
-			var verified, corrupted, missing int
-			var corruptedList []string
-
+			checks := make([]hashutil.FileCheck, 0, len(packages))
+			filenames := make(map[string]string, len(packages))
 			for _, pkg := range packages {
 				// Build file path (same logic as cache.packagePath)
-				filePath := filepath.Join(cfg.Cache.Path, "packages", "sha256", pkg.SHA256[:2], pkg.SHA256)
+				checks = append(checks, hashutil.FileCheck{
+					Path:     filepath.Join(cfg.Cache.Path, "packages", "sha256", pkg.SHA256[:2], pkg.SHA256),
+					Expected: pkg.SHA256,
+				})
+				filenames[pkg.SHA256] = pkg.Filename
+			}
 
-				f, err := os.Open(filePath)
-				if err != nil {
-					if os.IsNotExist(err) {
-						fmt.Printf("  MISSING  %s  %s\n", pkg.SHA256[:16], pkg.Filename)
-						missing++
-						corruptedList = append(corruptedList, pkg.SHA256)
-						continue
+			var verified, corrupted, missing int
+			var corruptedList []string
+			var readErr error
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			// Re-hashing the whole cache is paced by [cache.io_throttle]
+			throttle := iothrottle.New(ioThrottleLimits(&cfg.Cache.IOThrottle))
+			pace := func(r io.Reader) io.Reader { return throttle.Reader(ctx, r) }
+			hashutil.VerifyFilesThrough(ctx, checks, jobs, pace, func(r hashutil.FileResult) {
+				hash, name := r.Expected, filenames[r.Expected]
+				switch {
+				case r.Err != nil && os.IsNotExist(r.Err):
+					fmt.Printf("  MISSING  %s  %s\n", hash[:16], name)
+					missing++
+					corruptedList = append(corruptedList, hash)
+				case r.Err != nil:
+					if readErr == nil && ctx.Err() == nil {
+						readErr = fmt.Errorf("failed to read %s: %w", hash[:16], r.Err)
+						cancel()
 					}
-					return fmt.Errorf("failed to open %s: %w", pkg.SHA256[:16], err)
-				}
-
-				hasher := sha256.New()
-				if _, err := io.Copy(hasher, f); err != nil {
-					_ = f.Close()
-					return fmt.Errorf("failed to read %s: %w", pkg.SHA256[:16], err)
-				}
-				_ = f.Close()
-
-				actualHash := hex.EncodeToString(hasher.Sum(nil))
-				if actualHash != pkg.SHA256 {
-					fmt.Printf("  CORRUPT  %s  %s\n", pkg.SHA256[:16], pkg.Filename)
-					fmt.Printf("           Expected: %s\n", pkg.SHA256)
-					fmt.Printf("           Got:      %s\n", actualHash)
+				case !r.OK():
+					fmt.Printf("  CORRUPT  %s  %s\n", hash[:16], name)
+					fmt.Printf("           Expected: %s\n", hash)
+					fmt.Printf("           Got:      %s\n", r.Actual)
 					corrupted++
-					corruptedList = append(corruptedList, pkg.SHA256)
-				} else {
+					corruptedList = append(corruptedList, hash)
+				default:
 					verified++
 				}
+			})
+			if readErr != nil {
+				return readErr
 			}
 
 			fmt.Println()
@@ -282,6 +346,7 @@ func cacheVerifyCmd() *cobra.Command {
 			fmt.Printf("  Missing:   %d\n", missing)
 
 			if len(corruptedList) > 0 {
+				sort.Strings(corruptedList)
 				fmt.Println()
 				fmt.Println("To remove corrupted/missing entries, run:")
 				for _, hash := range corruptedList {
@@ -294,6 +359,9 @@ func cacheVerifyCmd() *cobra.Command {
 			return nil
 		},
 	}
+
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", 0, "Packages to hash in parallel (default: one per CPU)")
+	return cmd
 }
 
 func cachePopularCmd() *cobra.Command {
@@ -310,7 +378,7 @@ func cachePopularCmd() *cobra.Command {
 				return err
 			}
 
-			maxSize := cfg.Cache.MaxSizeBytes()
+			maxSize := cacheMaxSize(&cfg.Cache)
 			c, err := cache.New(cfg.Cache.Path, maxSize, logger)
 			if err != nil {
 				return err
@@ -370,7 +438,7 @@ func cacheRecentCmd() *cobra.Command {
 				return err
 			}
 
-			maxSize := cfg.Cache.MaxSizeBytes()
+			maxSize := cacheMaxSize(&cfg.Cache)
 			c, err := cache.New(cfg.Cache.Path, maxSize, logger)
 			if err != nil {
 				return err
@@ -428,7 +496,7 @@ func cachePinCmd() *cobra.Command {
 				return err
 			}
 
-			maxSize := cfg.Cache.MaxSizeBytes()
+			maxSize := cacheMaxSize(&cfg.Cache)
 			c, err := cache.New(cfg.Cache.Path, maxSize, logger)
 			if err != nil {
 				return err
@@ -499,7 +567,7 @@ func cacheUnpinCmd() *cobra.Command {
 				return err
 			}
 
-			maxSize := cfg.Cache.MaxSizeBytes()
+			maxSize := cacheMaxSize(&cfg.Cache)
 			c, err := cache.New(cfg.Cache.Path, maxSize, logger)
 			if err != nil {
 				return err
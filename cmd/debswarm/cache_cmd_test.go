@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/cache"
+)
+
+func TestAdvertisedUntil(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		until time.Time
+		want  string
+	}{
+		{time.Unix(0, 0), "not advertised"},
+		{now.Add(-time.Hour), "expired 2026-03-01 11:00"},
+		{now.Add(6 * time.Hour), "until 2026-03-01 18:00"},
+	}
+	for _, tt := range tests {
+		got := advertisedUntil(&cache.Package{AdvertisedUntil: tt.until}, now)
+		if got != tt.want {
+			t.Errorf("advertisedUntil(%v) = %q, want %q", tt.until, got, tt.want)
+		}
+	}
+}
+
+func TestDescribeOrigin(t *testing.T) {
+	tests := []struct {
+		origin cache.Origin
+		want   string
+	}{
+		{cache.Origin{}, "origin unknown"},
+		{cache.Origin{Repo: "deb.debian.org/debian", Suite: "bookworm", Component: "main", Mirror: "http://deb.debian.org/debian/pool/main/v/vim/vim.deb"},
+			"from deb.debian.org/debian bookworm/main via http://deb.debian.org/debian/pool/main/v/vim/vim.deb"},
+		{cache.Origin{Repo: "example.com", Mirror: "http://example.com/x.deb"}, "from example.com via http://example.com/x.deb"},
+	}
+	for _, tt := range tests {
+		if got := describeOrigin(tt.origin); got != tt.want {
+			t.Errorf("describeOrigin(%+v) = %q, want %q", tt.origin, got, tt.want)
+		}
+	}
+}
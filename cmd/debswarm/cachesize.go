@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/config"
+	"github.com/debswarm/debswarm/internal/metrics"
+	"github.com/debswarm/debswarm/internal/units"
+)
+
+// cacheSizeInterval is how often a cache capacity relative to the disk is
+// recomputed, as VM disks get resized
+const cacheSizeInterval = 10 * time.Minute
+
+// Hysteresis of a cache capacity relative to the disk: changes smaller than
+// cacheSizeTolerance of the capacity are ignored, and the capacity shrinks
+// by at most cacheSizeShrinkStep of itself per interval, so a shrunk
+// filesystem is met with gradual eviction instead of a purge storm.
+const (
+	cacheSizeTolerance  = 0.01
+	cacheSizeShrinkStep = 0.10
+)
+
+// defaultCacheMaxSize is used when the size of the cache's filesystem
+// cannot be read
+const defaultCacheMaxSize = 10 * units.GiB
+
+// cacheMaxSize returns the cache capacity in bytes, resolving a percentage
+// of the disk against the filesystem holding the cache.
+func cacheMaxSize(c *config.CacheConfig) int64 {
+	limit := c.MaxSizeLimit()
+	if !limit.DiskRelative() {
+		return limit.Bytes
+	}
+	disk, err := cache.DiskCapacity(c.Path)
+	if err != nil || limit.Resolve(disk) <= 0 {
+		return defaultCacheMaxSize
+	}
+	return limit.Resolve(disk)
+}
+
+// cacheSizer applies the cache capacity, which may be a percentage of the
+// disk ("20%"). That is recomputed every cacheSizeInterval: a larger disk
+// raises the capacity at once, a smaller one lowers it step by step.
+type cacheSizer struct {
+	cache    *cache.Cache
+	capacity func() (int64, error) // size of the cache's filesystem
+	metrics  *metrics.Metrics
+	logger   *zap.Logger
+
+	mu      sync.Mutex
+	limit   units.Capacity
+	minFree int64
+}
+
+// newCacheSizer returns a sizer for pkgCache. A cache already larger than a
+// capacity relative to the disk, e.g. after the filesystem shrank while the
+// daemon was stopped, keeps its size and shrinks step by step too.
+func newCacheSizer(pkgCache *cache.Cache, cfg *config.CacheConfig, m *metrics.Metrics, logger *zap.Logger) *cacheSizer {
+	if cfg.MaxSizeLimit().DiskRelative() && pkgCache.Size() > pkgCache.MaxSize() {
+		pkgCache.SetLimits(pkgCache.Size(), cfg.MinFreeSpaceBytes())
+	}
+	path := cfg.Path
+	return &cacheSizer{
+		cache:    pkgCache,
+		capacity: func() (int64, error) { return cache.DiskCapacity(path) },
+		metrics:  m,
+		logger:   logger,
+		limit:    cfg.MaxSizeLimit(),
+		minFree:  cfg.MinFreeSpaceBytes(),
+	}
+}
+
+// set replaces the configured limits and applies them.
+func (s *cacheSizer) set(limit units.Capacity, minFree int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit, s.minFree = limit, minFree
+	s.apply(s.target(s.cache.MaxSize()))
+}
+
+// refresh recomputes a capacity relative to the disk.
+func (s *cacheSizer) refresh() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.limit.DiskRelative() {
+		return
+	}
+	current := s.cache.MaxSize()
+	next := s.target(current)
+	if next == current {
+		return
+	}
+	s.logger.Info("Cache filesystem resized, adjusting cache capacity",
+		zap.String("maxSize", s.limit.String()),
+		zap.String("from", formatBytes(current)),
+		zap.String("to", formatBytes(next)))
+	s.apply(next)
+}
+
+// target returns the capacity to apply next, moving from current towards
+// the configured limit with hysteresis. Caller must hold s.mu.
+func (s *cacheSizer) target(current int64) int64 {
+	if !s.limit.DiskRelative() {
+		return s.limit.Bytes
+	}
+	disk, err := s.capacity()
+	if err != nil || s.limit.Resolve(disk) <= 0 {
+		s.logger.Warn("Failed to read the size of the cache filesystem, keeping the cache capacity", zap.Error(err))
+		return current
+	}
+	return nextCacheSize(current, s.limit.Resolve(disk))
+}
+
+// nextCacheSize steps a capacity relative to the disk from current towards
+// want: small changes are ignored and shrinking is gradual.
+func nextCacheSize(current, want int64) int64 {
+	diff := want - current
+	if diff < 0 {
+		diff = -diff
+	}
+	if float64(diff) < float64(current)*cacheSizeTolerance {
+		return current
+	}
+	if want > current {
+		return want
+	}
+	return max(want, current-int64(float64(current)*cacheSizeShrinkStep))
+}
+
+// apply sets the cache capacity and evicts what no longer fits. Caller must
+// hold s.mu.
+func (s *cacheSizer) apply(maxSize int64) {
+	shrunk := maxSize < s.cache.MaxSize()
+	s.cache.SetLimits(maxSize, s.minFree)
+	if s.metrics != nil {
+		s.metrics.CacheMaxSize.Set(float64(maxSize))
+	}
+	if !shrunk {
+		return
+	}
+	evicted, err := s.cache.Trim()
+	if err != nil {
+		s.logger.Warn("Failed to evict packages down to the cache capacity", zap.Error(err))
+		return
+	}
+	if evicted > 0 && s.metrics != nil {
+		s.metrics.CacheSize.Set(float64(s.cache.Size()))
+		s.metrics.CacheCount.Set(float64(s.cache.Count()))
+	}
+}
+
+// run recomputes the capacity periodically until ctx is done.
+func (s *cacheSizer) run(ctx context.Context) {
+	ticker := time.NewTicker(cacheSizeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refresh()
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/metrics"
+	"github.com/debswarm/debswarm/internal/units"
+)
+
+func TestNextCacheSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		current, want int64
+		next          int64
+	}{
+		{"unchanged", 1000, 1000, 1000},
+		{"small change ignored", 1000, 995, 1000},
+		{"grows at once", 1000, 3000, 3000},
+		{"shrinks by a step", 1000, 500, 900},
+		{"last step", 1000, 950, 950},
+	}
+	for _, tt := range tests {
+		if got := nextCacheSize(tt.current, tt.want); got != tt.next {
+			t.Errorf("%s: nextCacheSize(%d, %d) = %d, want %d", tt.name, tt.current, tt.want, got, tt.next)
+		}
+	}
+}
+
+func TestCacheSizer(t *testing.T) {
+	c, err := cache.New(t.TempDir(), 1000, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	disk := int64(10000)
+	m := metrics.New()
+	s := &cacheSizer{
+		cache:    c,
+		capacity: func() (int64, error) { return disk, nil },
+		metrics:  m,
+		logger:   zap.NewNop(),
+	}
+	s.set(units.Capacity{DiskPercent: 20}, 0)
+	if got := c.MaxSize(); got != 2000 {
+		t.Fatalf("20%% of 10000 = %d, want 2000", got)
+	}
+
+	// The disk shrinks to half: the capacity follows 10% at a time
+	disk = 5000
+	for _, want := range []int64{1800, 1620, 1458, 1313, 1182, 1064, 1000, 1000} {
+		s.refresh()
+		if got := c.MaxSize(); got != want {
+			t.Fatalf("after shrinking: capacity %d, want %d", got, want)
+		}
+	}
+	if got := m.CacheMaxSize.Value(); got != 1000 {
+		t.Errorf("capacity gauge = %v, want 1000", got)
+	}
+
+	// Fixed capacities are left alone however the disk changes
+	s.set(units.Capacity{Bytes: 4000}, 0)
+	disk = 100000
+	s.refresh()
+	if got := c.MaxSize(); got != 4000 {
+		t.Errorf("fixed capacity changed to %d", got)
+	}
+}
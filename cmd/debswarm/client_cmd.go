@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/debswarm/debswarm/internal/lanproxy"
+	"github.com/debswarm/debswarm/internal/units"
+)
+
+// Files "debswarm client enable" installs. A machine running the daemon
+// has 90debswarm.conf instead.
+const (
+	defaultClientAPTConfPath = "/etc/apt/apt.conf.d/90debswarm-client"
+	defaultClientDetectPath  = "/usr/local/lib/debswarm/apt-client-detect"
+)
+
+// clientDialTimeout bounds the connection test of a discovered proxy.
+const clientDialTimeout = time.Second
+
+func clientCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "client",
+		Short: "Use a debswarm proxy found on the LAN, without running the daemon",
+		Long: `Configure APT on a machine that does not run debswarm to use a debswarm
+proxy serving the LAN, found over mDNS.
+
+A daemon whose proxy serves the LAN (network.proxy_bind not loopback)
+advertises it as an APT proxy (_apt_proxy._tcp, as squid-deb-proxy and
+apt-cacher-ng do). 'debswarm client enable' makes APT look for one before
+each download and fall back to the mirrors when there is none, so a laptop
+uses the office swarm whenever it is in the office.`,
+	}
+
+	cmd.AddCommand(clientEnableCmd())
+	cmd.AddCommand(clientDisableCmd())
+	cmd.AddCommand(clientDetectCmd())
+	cmd.AddCommand(clientListCmd())
+	return cmd
+}
+
+func clientEnableCmd() *cobra.Command {
+	var (
+		confPath   string
+		detectPath string
+		force      bool
+		timeout    units.DurationFlag
+	)
+
+	cmd := &cobra.Command{
+		Use:   "enable",
+		Short: "Configure APT to use a LAN proxy whenever one is found",
+		Long: `Install an APT Proxy-Auto-Detect script that runs 'debswarm client detect'.
+Before downloading, APT asks it for a proxy: it answers with a reachable
+proxy advertised on the LAN, debswarm ones first, or DIRECT, in which case
+APT goes to the mirrors as usual. Only http:// sources use the proxy.
+
+The search takes up to --timeout, and is usually much faster.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := os.Stat(defaultAPTProxyConfPath); err == nil && !force {
+				return fmt.Errorf("%s exists: this machine runs the debswarm proxy itself (use --force to override)", defaultAPTProxyConfPath)
+			}
+			exe, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to locate debswarm binary: %w", err)
+			}
+			if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+				exe = resolved
+			}
+			if strings.ContainsRune(detectPath, '"') {
+				return fmt.Errorf("paths containing '\"' cannot be used in APT configuration")
+			}
+
+			if err := os.MkdirAll(filepath.Dir(detectPath), 0o755); err != nil { // #nosec G301 -- APT must read it
+				return fmt.Errorf("failed to create %s: %w", filepath.Dir(detectPath), err)
+			}
+			if err := writeClientDetectScript(detectPath, clientDetectScript(exe, time.Duration(timeout))); err != nil {
+				return err
+			}
+			if err := writeAPTConfig(confPath, clientAPTConfig(detectPath)); err != nil {
+				return err
+			}
+			fmt.Printf("Installed %s and %s\n", confPath, detectPath)
+
+			proxy, err := lanproxy.Find(cmd.Context(), time.Duration(timeout), clientDialTimeout)
+			if err != nil {
+				fmt.Println("No LAN proxy found right now; APT goes to the mirrors until one is.")
+				return nil
+			}
+			fmt.Printf("Found %s at %s; APT will use it.\n", proxy.Instance, proxy.URL)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&confPath, "path", defaultClientAPTConfPath, "APT configuration file to write")
+	cmd.Flags().StringVar(&detectPath, "detect-script", defaultClientDetectPath, "Proxy detect script to write")
+	cmd.Flags().BoolVar(&force, "force", false, "Enable even though this machine runs the debswarm proxy")
+	timeout = units.DurationFlag(2 * time.Second)
+	cmd.Flags().Var(&timeout, "timeout", "How long APT waits for a proxy to answer")
+	return cmd
+}
+
+func clientDisableCmd() *cobra.Command {
+	var confPath, detectPath string
+
+	cmd := &cobra.Command{
+		Use:   "disable",
+		Short: "Stop APT from looking for a LAN proxy",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			removed := false
+			for _, path := range []string{confPath, detectPath} {
+				if err := os.Remove(path); err != nil {
+					if errors.Is(err, os.ErrNotExist) {
+						continue
+					}
+					return fmt.Errorf("failed to remove %s: %w", path, err)
+				}
+				fmt.Printf("Removed %s\n", path)
+				removed = true
+			}
+			if !removed {
+				fmt.Println("LAN proxy discovery is not enabled.")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&confPath, "path", defaultClientAPTConfPath, "APT configuration file to remove")
+	cmd.Flags().StringVar(&detectPath, "detect-script", defaultClientDetectPath, "Proxy detect script to remove")
+	return cmd
+}
+
+func clientDetectCmd() *cobra.Command {
+	timeout := units.DurationFlag(2 * time.Second)
+
+	cmd := &cobra.Command{
+		Use:   "detect [URI]",
+		Short: "Print a LAN proxy for APT, or DIRECT",
+		Long: `Print the URL of a reachable APT proxy advertised on the LAN, debswarm
+ones first, or DIRECT if there is none. This is what APT runs, through the
+script 'debswarm client enable' installs; the URI APT passes is ignored.
+Never fails, so APT always gets an answer.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			printClientDetect(cmd.Context(), cmd.OutOrStdout(), time.Duration(timeout))
+		},
+	}
+
+	cmd.Flags().Var(&timeout, "timeout", "How long to wait for a proxy to answer")
+	return cmd
+}
+
+// printClientDetect prints the answer APT expects from a proxy detect
+// program.
+func printClientDetect(ctx context.Context, w io.Writer, timeout time.Duration) {
+	proxy, err := lanproxy.Find(ctx, timeout, clientDialTimeout)
+	if err != nil {
+		fmt.Fprintln(w, "DIRECT")
+		return
+	}
+	fmt.Fprintln(w, proxy.URL)
+}
+
+func clientListCmd() *cobra.Command {
+	var (
+		timeout    units.DurationFlag
+		jsonOutput bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the APT proxies advertised on the LAN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			proxies, err := lanproxy.Discover(cmd.Context(), time.Duration(timeout))
+			if err != nil {
+				return fmt.Errorf("mDNS browse failed: %w", err)
+			}
+			if jsonOutput {
+				return printJSON(proxies)
+			}
+			printLANProxies(os.Stdout, proxies)
+			return nil
+		},
+	}
+
+	timeout = units.DurationFlag(3 * time.Second)
+	cmd.Flags().Var(&timeout, "timeout", "How long to listen for answers")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	return cmd
+}
+
+func printLANProxies(w io.Writer, proxies []lanproxy.Proxy) {
+	if len(proxies) == 0 {
+		fmt.Fprintln(w, "No APT proxies found on the LAN.")
+		return
+	}
+	for _, p := range proxies {
+		kind := "other"
+		if p.Debswarm {
+			kind = "debswarm"
+			if p.Version != "" {
+				kind += " " + p.Version
+			}
+		}
+		fmt.Fprintf(w, "%-28s  %-30s  %s\n", p.URL, p.Instance, kind)
+	}
+}
+
+// clientDetectScript renders the script APT runs to find a proxy. APT runs
+// a Proxy-Auto-Detect program with the URI as its only argument, so the
+// subcommand needs a wrapper; it answers DIRECT if debswarm has gone.
+func clientDetectScript(exe string, timeout time.Duration) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString("# Installed by \"debswarm client enable\"; remove with \"debswarm client disable\".\n")
+	b.WriteString("# APT runs this to find a proxy: a debswarm (or other APT) proxy\n")
+	b.WriteString("# advertised on the LAN, or DIRECT to go to the mirrors.\n")
+	fmt.Fprintf(&b, "%s client detect --timeout %s 2>/dev/null || echo DIRECT\n", shellQuote(exe), timeout)
+	return b.String()
+}
+
+// clientAPTConfig renders the APT snippet that asks the detect script for a
+// proxy.
+func clientAPTConfig(detectPath string) string {
+	var b strings.Builder
+	b.WriteString("// Installed by \"debswarm client enable\"; remove with \"debswarm client disable\".\n")
+	b.WriteString("//\n")
+	b.WriteString("// APT asks the detect script for a proxy before downloading. It answers\n")
+	b.WriteString("// with a proxy advertised on the LAN, or DIRECT when there is none, so\n")
+	b.WriteString("// this machine uses an office swarm there and the mirrors elsewhere.\n\n")
+	fmt.Fprintf(&b, "Acquire::http::Proxy-Auto-Detect %s;\n", aptQuote(detectPath))
+	return b.String()
+}
+
+// writeClientDetectScript writes an executable script atomically.
+func writeClientDetectScript(path, content string) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0o755); err != nil { // #nosec G306 -- APT runs it as _apt
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to install %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/lanproxy"
+)
+
+func TestClientDetectScript(t *testing.T) {
+	script := clientDetectScript("/usr/bin/deb'swarm", 2*time.Second)
+	if !strings.HasPrefix(script, "#!/bin/sh\n") {
+		t.Errorf("script should start with a shebang:\n%s", script)
+	}
+	if !strings.Contains(script, `'/usr/bin/deb'\''swarm' client detect --timeout 2s 2>/dev/null || echo DIRECT`) {
+		t.Errorf("script should run client detect, falling back to DIRECT:\n%s", script)
+	}
+
+	conf := clientAPTConfig("/usr/local/lib/debswarm/apt-client-detect")
+	if !strings.Contains(conf, `Acquire::http::Proxy-Auto-Detect "/usr/local/lib/debswarm/apt-client-detect";`) {
+		t.Errorf("APT config should use the detect script:\n%s", conf)
+	}
+	if strings.Contains(conf, "https::") {
+		t.Error("https sources should not go through the proxy")
+	}
+}
+
+func TestClientEnableDisable(t *testing.T) {
+	if _, err := os.Stat(defaultAPTProxyConfPath); err == nil {
+		t.Skip("this machine runs the debswarm proxy")
+	}
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "apt.conf.d", "90debswarm-client")
+	detect := filepath.Join(dir, "lib", "apt-client-detect")
+	if err := os.MkdirAll(filepath.Dir(conf), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := clientCmd()
+	cmd.SetArgs([]string{"enable", "--path", conf, "--detect-script", detect, "--timeout", "50ms"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("client enable: %v", err)
+	}
+	info, err := os.Stat(detect)
+	if err != nil || info.Mode().Perm()&0o111 == 0 {
+		t.Errorf("detect script not installed executable: %v", err)
+	}
+	if data, err := os.ReadFile(conf); err != nil || !strings.Contains(string(data), detect) {
+		t.Errorf("APT config = %q, %v", data, err)
+	}
+
+	cmd = clientCmd()
+	cmd.SetArgs([]string{"disable", "--path", conf, "--detect-script", detect})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("client disable: %v", err)
+	}
+	for _, path := range []string{conf, detect} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s not removed", path)
+		}
+	}
+}
+
+func TestPrintLANProxies(t *testing.T) {
+	var buf bytes.Buffer
+	printLANProxies(&buf, []lanproxy.Proxy{
+		{Instance: "debswarm on office-cache", URL: "http://192.168.1.10:9977", Debswarm: true, Version: "1.40.0"},
+		{Instance: "apt-cacher-ng proxy on nas", URL: "http://192.168.1.20:3142"},
+	})
+	out := buf.String()
+	for _, want := range []string{"http://192.168.1.10:9977", "debswarm 1.40.0", "apt-cacher-ng proxy on nas", "other"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contain %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	printLANProxies(&buf, nil)
+	if !strings.Contains(buf.String(), "No APT proxies") {
+		t.Errorf("empty list: %q", buf.String())
+	}
+}
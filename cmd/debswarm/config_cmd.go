@@ -1,10 +1,19 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"maps"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	toml "github.com/pelletier/go-toml/v2"
 	"github.com/spf13/cobra"
 
 	"github.com/debswarm/debswarm/internal/config"
@@ -19,6 +28,8 @@ func configCmd() *cobra.Command {
 	cmd.AddCommand(configShowCmd())
 	cmd.AddCommand(configInitCmd())
 	cmd.AddCommand(configWizardCmd())
+	cmd.AddCommand(configValidateCmd())
+	cmd.AddCommand(configDiffCmd())
 
 	return cmd
 }
@@ -41,6 +52,9 @@ func configShowCmd() *cobra.Command {
 
 			fmt.Printf("\n[network]\n")
 			fmt.Printf("  listen_port      = %d\n", cfg.Network.ListenPort)
+			if len(cfg.Network.Listen) > 0 {
+				fmt.Printf("  listen           = %d listeners\n", len(cfg.Network.Listen))
+			}
 			fmt.Printf("  proxy_port       = %d\n", cfg.Network.ProxyPort)
 			fmt.Printf("  max_connections  = %d\n", cfg.Network.MaxConnections)
 			fmt.Printf("  bootstrap_peers  = %d configured\n", len(cfg.Network.BootstrapPeers))
@@ -72,6 +86,26 @@ func configShowCmd() *cobra.Command {
 			if len(cfg.Privacy.PeerAllowlist) > 0 {
 				fmt.Printf("  peer_allowlist   = %d peers\n", len(cfg.Privacy.PeerAllowlist))
 			}
+			if cfg.Privacy.IdentitySigner != "" {
+				fmt.Printf("  identity_signer  = %s\n", cfg.Privacy.IdentitySigner)
+			}
+
+			// Literal credentials are shown as fingerprints only
+			mirrorCfg := cfg.Redacted().Mirror
+			fmt.Printf("\n[mirror]\n")
+			fmt.Printf("  user_agent       = %s\n", mirrorCfg.GetUserAgent())
+			for _, o := range mirrorCfg.Origins {
+				fmt.Printf("  origin           = %s\n", strings.Join(o.Hosts, ", "))
+				for _, name := range slices.Sorted(maps.Keys(o.Headers)) {
+					fmt.Printf("    %s: %s\n", name, o.Headers[name])
+				}
+				if o.Username != "" {
+					fmt.Printf("    username       = %s\n", o.Username)
+				}
+				if o.Password != "" {
+					fmt.Printf("    password       = %s\n", o.Password)
+				}
+			}
 
 			fmt.Printf("\n[metrics]\n")
 			fmt.Printf("  port             = %d\n", cfg.Metrics.Port)
@@ -100,10 +134,24 @@ func displayRate(rate string) string {
 }
 
 func configInitCmd() *cobra.Command {
-	return &cobra.Command{
+	var interactive bool
+
+	cmd := &cobra.Command{
 		Use:   "init",
 		Short: "Create default configuration file",
+		Long: `Create a configuration file with the default settings.
+
+With --interactive, guide a first-time setup instead: the wizard's questions
+plus the network environment (behind NAT? private swarm?), then optionally
+create the node identity and point APT at the proxy, and finish with a
+checklist to verify the installation. Run it with sudo to configure APT.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if interactive {
+				w := newWizard()
+				w.setup = defaultSetupPaths()
+				return w.run("")
+			}
+
 			cfg := config.DefaultConfig()
 
 			var cfgPath string
@@ -122,4 +170,214 @@ func configInitCmd() *cobra.Command {
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Guided first-time setup")
+	return cmd
+}
+
+func configValidateCmd() *cobra.Command {
+	var file string
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check a configuration file for errors",
+		Long: `Check a configuration file without starting the daemon.
+
+Errors are settings the daemon would refuse to start with. Warnings are
+keys it does not know (and ignores — usually a typo), settings that work
+against each other, and unsafe file permissions. Exits non-zero when there
+are errors.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := file
+			if path == "" {
+				var ok bool
+				if path, ok = existingConfigPath(); !ok {
+					return fmt.Errorf("no configuration file found (searched %v)", configPaths())
+				}
+			}
+
+			result, err := config.Check(path)
+			if err != nil {
+				return fmt.Errorf("failed to read config: %w", err)
+			}
+
+			if jsonOutput {
+				data, err := json.MarshalIndent(struct {
+					File  string `json:"file"`
+					Valid bool   `json:"valid"`
+					*config.CheckResult
+				}{path, result.Valid(), result}, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+			} else {
+				printCheckResult(path, result)
+			}
+
+			if !result.Valid() {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d error(s) in %s", len(result.Errors), path)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "Configuration file to check (default: the one the daemon would load)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output JSON")
+	return cmd
+}
+
+func printCheckResult(path string, result *config.CheckResult) {
+	fmt.Printf("Checking %s\n\n", path)
+	for _, issue := range result.Errors {
+		fmt.Printf("  ERROR    %s\n", formatIssue(issue))
+	}
+	for _, issue := range result.Warnings {
+		fmt.Printf("  WARNING  %s\n", formatIssue(issue))
+	}
+	if len(result.Errors)+len(result.Warnings) > 0 {
+		fmt.Println()
+	}
+	fmt.Printf("%d error(s), %d warning(s)\n", len(result.Errors), len(result.Warnings))
+}
+
+func formatIssue(issue config.Issue) string {
+	if issue.Field == "" {
+		return issue.Message
+	}
+	return issue.Field + ": " + issue.Message
+}
+
+func configDiffCmd() *cobra.Command {
+	var file string
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Show how the configuration on disk differs from the running daemon",
+		Long: `Compare the configuration the running daemon is using with the file on
+disk, and show which differences a SIGHUP would apply and which need a
+restart. Command-line flags and the CACHE_DIRECTORY environment variable
+given to the daemon also show up as differences. Settings received from a
+fleet leader are not included.
+
+Requires the daemon to be running with metrics enabled.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var disk *config.Config
+			var err error
+			path := file
+			if path != "" {
+				disk, err = config.Load(path)
+			} else {
+				path, _ = existingConfigPath()
+				disk, err = loadConfig()
+			}
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			if disk.Metrics.Port == 0 {
+				return fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+			}
+
+			url := fmt.Sprintf("http://%s:%d/api/config", loopbackHost(disk.Metrics.Bind), disk.Metrics.Port)
+			active, err := fetchActiveConfig(&http.Client{Timeout: 5 * time.Second}, url)
+			if err != nil {
+				return err
+			}
+
+			changes, err := config.Diff(active, disk.Redacted())
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				if changes == nil {
+					changes = []config.Change{}
+				}
+				data, err := json.MarshalIndent(changes, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+				return nil
+			}
+			printConfigDiff(path, changes)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "Configuration file to compare (default: the one the daemon would load)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output JSON")
+	return cmd
+}
+
+// fetchActiveConfig reads the running daemon's configuration from its API.
+func fetchActiveConfig(client *http.Client, url string) (*config.Config, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("daemon not running or metrics disabled: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from daemon", resp.StatusCode)
+	}
+
+	active := &config.Config{}
+	if err := toml.Unmarshal(body, active); err != nil {
+		return nil, fmt.Errorf("failed to parse daemon configuration: %w", err)
+	}
+	return active, nil
+}
+
+func printConfigDiff(path string, changes []config.Change) {
+	if path == "" {
+		path = "built-in defaults"
+	}
+	if len(changes) == 0 {
+		fmt.Printf("The running daemon matches %s.\n", path)
+		return
+	}
+
+	fmt.Printf("Differences between the running daemon and %s:\n\n", path)
+	reload, restart := 0, 0
+	for _, c := range changes {
+		when := "restart"
+		if c.Reloadable {
+			when = "SIGHUP"
+			reload++
+		} else {
+			restart++
+		}
+		fmt.Printf("  %-40s  %s -> %s  (%s)\n", c.Key, orUnset(c.Old), orUnset(c.New), when)
+	}
+	fmt.Println()
+	switch {
+	case restart == 0:
+		fmt.Println("All changes apply on reload: systemctl reload debswarm (or send SIGHUP).")
+	case reload == 0:
+		fmt.Println("All changes need a restart: systemctl restart debswarm.")
+	default:
+		fmt.Printf("%d change(s) apply on reload; %d need a restart.\n", reload, restart)
+	}
+}
+
+func orUnset(v string) string {
+	if v == "" {
+		return "(unset)"
+	}
+	return v
 }
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/debswarm/debswarm/internal/config"
+	"github.com/debswarm/debswarm/internal/p2p"
+)
+
+// System locations used by first-time setup ("config init --interactive").
+const (
+	defaultAPTProxyConfPath = "/etc/apt/apt.conf.d/90debswarm.conf"
+	defaultProxyDetectPath  = "/usr/lib/debswarm/apt-proxy-detect"
+	systemConfigPath        = "/etc/debswarm/config.toml"
+)
+
+// setupPaths are the files first-time setup writes outside the config file.
+// Tests point them at a temporary directory.
+type setupPaths struct {
+	aptConf  string // APT proxy configuration snippet
+	detector string // proxy auto-detect script shipped by the package
+	dataDir  string // identity key directory; "" resolves like the daemon does
+}
+
+func defaultSetupPaths() *setupPaths {
+	return &setupPaths{
+		aptConf:  defaultAPTProxyConfPath,
+		detector: defaultProxyDetectPath,
+	}
+}
+
+// promptNetworkEnvironment asks how this machine reaches the internet and
+// whether it joins a private swarm. The first answer sets force_reachability,
+// so a node known to be behind NAT reserves a relay slot at once instead of
+// waiting for AutoNAT; the second decides whether the privacy step offers a PSK.
+func (w *wizard) promptNetworkEnvironment(profileIdx int) {
+	w.printf("\n")
+	current := 2
+	switch w.cfg.Network.GetForceReachability() {
+	case config.ReachabilityPrivate:
+		current = 0
+	case config.ReachabilityPublic:
+		current = 1
+	}
+	idx := w.promptChoice("Step 4d: How does this machine reach the internet?", []string{
+		"Through a router or firewall (NAT) — a typical home or office machine",
+		"Directly, on a public IP address — a server or VPS",
+		"Not sure — detect it automatically",
+	}, current)
+	switch idx {
+	case 0:
+		w.cfg.Network.ForceReachability = config.ReachabilityPrivate
+	case 1:
+		w.cfg.Network.ForceReachability = config.ReachabilityPublic
+	default:
+		w.cfg.Network.ForceReachability = ""
+	}
+
+	havePSK := w.cfg.Privacy.PSK != "" || w.cfg.Privacy.PSKPath != ""
+	w.privateSwarm = w.promptYesNo(
+		"Step 4e: Join a private swarm (only nodes sharing a key)?",
+		profileIdx == 2 || havePSK,
+	)
+	if !w.privateSwarm && havePSK {
+		w.cfg.Privacy.PSK = ""
+		w.cfg.Privacy.PSKPath = ""
+		w.printf("  The configured PSK will be removed.\n")
+	}
+}
+
+// finishSetup runs the setup steps that act on the system rather than on the
+// config file, then prints what to check next. A step that fails is reported
+// and left on the checklist; the saved configuration is kept either way.
+func (w *wizard) finishSetup(savePath string) {
+	w.printf("\n")
+	peerID := w.setupIdentity()
+	aptDone := w.setupAPTProxy()
+	w.printChecklist(savePath, peerID, aptDone)
+}
+
+// setupIdentity shows the node's peer ID, creating the identity key first if
+// the user asks. Returns the peer ID, or "" when there is none yet.
+func (w *wizard) setupIdentity() string {
+	dataDir := w.setup.dataDir
+	if dataDir == "" {
+		dataDir = resolveDataDir(w.cfg)
+	}
+	keyPath := filepath.Join(dataDir, p2p.IdentityKeyFile)
+
+	if key, err := p2p.LoadIdentity(keyPath); err == nil {
+		peerID := p2p.IdentityFingerprint(key)
+		w.printf("Step 10: Node identity exists: %s\n", peerID)
+		return peerID
+	}
+	w.printf("  The daemon creates its identity on first start. Creating it now shows the\n")
+	w.printf("  peer ID other nodes need for their peer_allowlist.\n")
+	if !w.promptYesNo("Step 10: Create this node's identity now?", w.privateSwarm) {
+		return ""
+	}
+
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		w.printf("  Failed to create %s: %v\n", dataDir, err)
+		return ""
+	}
+	key, err := p2p.LoadOrCreateIdentity(dataDir)
+	if err != nil {
+		w.printf("  Failed to create identity: %v\n", err)
+		return ""
+	}
+	if err := matchOwner(keyPath, dataDir); err != nil {
+		w.printf("  Warning: could not hand %s to the daemon's user: %v\n", keyPath, err)
+	}
+	peerID := p2p.IdentityFingerprint(key)
+	w.printf("  Identity saved to: %s\n", keyPath)
+	w.printf("  Peer ID: %s\n", peerID)
+	return peerID
+}
+
+// setupAPTProxy offers to point APT at the proxy. Reports whether APT is
+// configured when it returns.
+func (w *wizard) setupAPTProxy() bool {
+	path := w.setup.aptConf
+	w.printf("\n")
+	if _, err := os.Stat(path); err == nil {
+		w.printf("Step 11: APT is already configured in %s; leaving it unchanged.\n", path)
+		return true
+	}
+	if !w.promptYesNo(fmt.Sprintf("Step 11: Configure APT to use the proxy (%s)?", path), os.Geteuid() == 0) {
+		return false
+	}
+
+	detector := w.setup.detector
+	if _, err := os.Stat(detector); err != nil {
+		detector = ""
+	}
+	if err := writeAPTConfig(path, aptProxyConfig(w.cfg.Network.ProxyPort, detector)); err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			w.printf("  Permission denied; re-run with sudo to configure APT.\n")
+		} else {
+			w.printf("  %v\n", err)
+		}
+		return false
+	}
+	w.printf("  Wrote %s\n", path)
+	return true
+}
+
+// aptProxyConfig renders the APT proxy snippet. When the packaged auto-detect
+// script is installed and the proxy is on its default port, APT asks the
+// script, which falls back to direct mirror access while the daemon is down.
+// The script only probes the default port, so any other port is set directly.
+func aptProxyConfig(port int, detector string) string {
+	var b strings.Builder
+	b.WriteString("// Written by \"debswarm config init --interactive\".\n")
+	if detector != "" && port == config.DefaultConfig().Network.ProxyPort {
+		b.WriteString("// APT asks the detect script for the proxy; it answers DIRECT while the\n")
+		b.WriteString("// daemon is not running, so apt keeps working without it.\n\n")
+		fmt.Fprintf(&b, "Acquire::http::Proxy-Auto-Detect %s;\n", aptQuote(detector))
+		fmt.Fprintf(&b, "Acquire::https::Proxy-Auto-Detect %s;\n", aptQuote(detector))
+		return b.String()
+	}
+	b.WriteString("// apt cannot download while the daemon is stopped; remove this file\n")
+	b.WriteString("// to go direct to the mirrors.\n\n")
+	fmt.Fprintf(&b, "Acquire::http::Proxy \"http://127.0.0.1:%d\";\n", port)
+	return b.String()
+}
+
+// printChecklist prints the steps that confirm the new setup works, including
+// the ones setup could not do itself.
+func (w *wizard) printChecklist(savePath, peerID string, aptDone bool) {
+	w.printf("\n")
+	w.printf("Verification checklist:\n")
+
+	cfgArg := ""
+	if savePath != systemConfigPath {
+		cfgArg = " --config " + savePath
+		w.printf("  [ ] The system service reads %s; copy this config there to use it\n", systemConfigPath)
+		w.printf("      with systemd, or run: debswarm daemon%s\n", cfgArg)
+	}
+	w.printf("  [ ] Check the config:           debswarm%s config validate\n", cfgArg)
+	w.printf("  [ ] Start the daemon:           sudo systemctl enable --now debswarm\n")
+	w.printf("  [ ] Confirm it is running:      debswarm%s status\n", cfgArg)
+	if aptDone {
+		w.printf("  [ ] Confirm APT uses the proxy: sudo apt-get update, then debswarm%s stats\n", cfgArg)
+		w.printf("      should count the requests\n")
+	} else {
+		w.printf("  [ ] Point APT at the proxy: add this line to %s\n", defaultAPTProxyConfPath)
+		w.printf("      Acquire::http::Proxy \"http://127.0.0.1:%d\";\n", w.cfg.Network.ProxyPort)
+	}
+	if w.cfg.Network.GetForceReachability() == config.ReachabilityPublic {
+		w.printf("  [ ] Allow TCP and UDP port %d through the firewall so peers can connect\n", w.cfg.Network.ListenPort)
+	}
+	if w.privateSwarm {
+		if w.cfg.Privacy.PSKPath != "" {
+			w.printf("  [ ] Copy %s to every node in the swarm; compare with: debswarm psk show\n", w.cfg.Privacy.PSKPath)
+		} else {
+			w.printf("  [ ] Copy the swarm key from an existing node and set privacy.psk_path\n")
+		}
+		w.printf("  [ ] Set network.bootstrap_peers to your own nodes; the public ones are not in the swarm\n")
+	}
+	if peerID != "" {
+		w.printf("  [ ] Add this peer ID to other nodes' privacy.peer_allowlist if they use one:\n")
+		w.printf("      %s\n", peerID)
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/debswarm/debswarm/internal/config"
 	"github.com/debswarm/debswarm/internal/p2p"
+	"github.com/debswarm/debswarm/internal/units"
 )
 
 // wizard drives the interactive configuration flow.
@@ -31,6 +32,15 @@ type wizard struct {
 	// offers to keep them. A found-but-unparseable config leaves this false: there
 	// are no current values to keep, so the flow is a normal create.
 	editing bool
+
+	// setup is set for first-time setup ("config init --interactive"), which
+	// also asks about the network, creates the identity, configures APT, and
+	// ends with a checklist. nil for the plain wizard.
+	setup *setupPaths
+
+	// privateSwarm records the answer to the private swarm question asked
+	// during first-time setup.
+	privateSwarm bool
 }
 
 // profile presets applied after the user picks a deployment mode.
@@ -50,7 +60,7 @@ type profile struct {
 var profiles = []profile{
 	{
 		name:         "Home user",
-		cacheSize:    "10GB",
+		cacheSize:    "10GiB",
 		uploadRate:   "0",
 		downloadRate: "0",
 		enableMDNS:   true,
@@ -60,8 +70,8 @@ var profiles = []profile{
 	},
 	{
 		name:         "Seeding server",
-		cacheSize:    "50GB",
-		uploadRate:   "50MB/s",
+		cacheSize:    "50GiB",
+		uploadRate:   "50MiB/s",
 		downloadRate: "0",
 		enableMDNS:   true,
 		announce:     true,
@@ -70,7 +80,7 @@ var profiles = []profile{
 	},
 	{
 		name:            "Private swarm",
-		cacheSize:       "10GB",
+		cacheSize:       "10GiB",
 		uploadRate:      "0",
 		downloadRate:    "0",
 		enableMDNS:      true,
@@ -90,30 +100,34 @@ func configWizardCmd() *cobra.Command {
 		Long: `Guides you through the most important configuration options,
 applies a deployment profile, validates inputs, and saves a ready-to-use config.toml.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			w := &wizard{
-				scanner: bufio.NewScanner(os.Stdin),
-				cfg:     config.DefaultConfig(),
-				out:     os.Stdout,
-			}
-
-			// Start from the existing config when there is one, so re-running the
-			// wizard edits the current settings instead of silently resetting them.
-			if path, ok := existingConfigPath(); ok {
-				w.existingPath = path // save back here either way
-				if loaded, err := config.Load(path); err == nil {
-					w.cfg = loaded
-					w.editing = true
-				} else {
-					w.printf("Warning: could not parse %s (%v).\nStarting from defaults; this file will be replaced.\n", path, err)
-				}
-			}
-			return w.run(outputPath)
+			return newWizard().run(outputPath)
 		},
 	}
 	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "output config file path")
 	return cmd
 }
 
+// newWizard returns a wizard reading from stdin. It starts from the existing
+// config when there is one, so re-running the wizard edits the current
+// settings instead of silently resetting them.
+func newWizard() *wizard {
+	w := &wizard{
+		scanner: bufio.NewScanner(os.Stdin),
+		cfg:     config.DefaultConfig(),
+		out:     os.Stdout,
+	}
+	if path, ok := existingConfigPath(); ok {
+		w.existingPath = path // save back here either way
+		if loaded, err := config.Load(path); err == nil {
+			w.cfg = loaded
+			w.editing = true
+		} else {
+			w.printf("Warning: could not parse %s (%v).\nStarting from defaults; this file will be replaced.\n", path, err)
+		}
+	}
+	return w
+}
+
 // run executes the full wizard flow.
 func (w *wizard) run(outputPath string) error {
 	w.printf("\n")
@@ -154,8 +168,11 @@ func (w *wizard) run(outputPath string) error {
 	// Step 3: Bandwidth limits
 	w.promptBandwidthLimits()
 
-	// Step 4: Ports
+	// Step 4: Ports, and for first-time setup the network environment
 	w.promptPorts()
+	if w.setup != nil {
+		w.promptNetworkEnvironment(profileIdx)
+	}
 
 	// Step 5: Repositories
 	w.promptRepositories()
@@ -203,6 +220,10 @@ func (w *wizard) run(outputPath string) error {
 	}
 
 	w.printf("\nConfiguration saved to: %s\n", savePath)
+	if w.setup != nil {
+		w.finishSetup(savePath)
+		return nil
+	}
 	w.printf("\nNext steps:\n")
 	w.printf("  debswarm daemon                    # start the daemon\n")
 	w.printf("  debswarm config show               # review configuration\n")
@@ -292,8 +313,8 @@ func (w *wizard) promptCacheSize() {
 			fmt.Sprintf("Step 2: Maximum cache size? [%s]", w.cfg.Cache.MaxSize),
 			w.cfg.Cache.MaxSize,
 		)
-		if _, err := config.ParseSize(val); err != nil {
-			w.printf("  Invalid size %q: %v. Try e.g. 10GB, 500MB\n", val, err)
+		if _, err := units.ParseCapacity(val); err != nil {
+			w.printf("  Invalid size %q: %v. Try e.g. 10GB, 500MB or 20%%\n", val, err)
 			continue
 		}
 		w.cfg.Cache.MaxSize = val
@@ -308,8 +329,8 @@ func (w *wizard) promptBandwidthLimits() {
 			fmt.Sprintf("Step 3a: Max upload rate? (e.g. 10MB/s, 0=unlimited) [%s]", displayRate(w.cfg.Transfer.MaxUploadRate)),
 			w.cfg.Transfer.MaxUploadRate,
 		)
-		if _, err := config.ParseRate(val); err != nil {
-			w.printf("  Invalid rate %q: %v. Try e.g. 10MB/s, 50MB/s, 0\n", val, err)
+		if _, err := units.ParseRate(val); err != nil {
+			w.printf("  Invalid rate %q: %v. Try e.g. 10MB/s, 50MB/s, 30%%link, 0\n", val, err)
 			continue
 		}
 		w.cfg.Transfer.MaxUploadRate = val
@@ -320,8 +341,8 @@ func (w *wizard) promptBandwidthLimits() {
 			fmt.Sprintf("Step 3b: Max download rate? (e.g. 10MB/s, 0=unlimited) [%s]", displayRate(w.cfg.Transfer.MaxDownloadRate)),
 			w.cfg.Transfer.MaxDownloadRate,
 		)
-		if _, err := config.ParseRate(val); err != nil {
-			w.printf("  Invalid rate %q: %v. Try e.g. 10MB/s, 50MB/s, 0\n", val, err)
+		if _, err := units.ParseRate(val); err != nil {
+			w.printf("  Invalid rate %q: %v. Try e.g. 10MB/s, 50MB/s, 30%%link, 0\n", val, err)
 			continue
 		}
 		w.cfg.Transfer.MaxDownloadRate = val
@@ -420,8 +441,9 @@ func (w *wizard) promptPrivacy(profileIdx int) {
 		w.cfg.Privacy.EnableMDNS,
 	)
 
-	// PSK generation for private swarm profile
-	if profileIdx == 2 {
+	// PSK generation for the private swarm profile, or when first-time setup
+	// was told this node joins one that has no key configured yet
+	if profileIdx == 2 || (w.privateSwarm && w.cfg.Privacy.PSKPath == "" && w.cfg.Privacy.PSK == "") {
 		if w.promptYesNo("Step 6b: Generate a new PSK now?", true) {
 			psk, err := p2p.GeneratePSK()
 			if err != nil {
@@ -501,6 +523,9 @@ func (w *wizard) printSummary() {
 	if w.cfg.Privacy.PSKPath != "" {
 		w.printf("  %-28s %s\n", "PSK path:", w.cfg.Privacy.PSKPath)
 	}
+	if w.cfg.Network.ForceReachability != "" {
+		w.printf("  %-28s %s\n", "Reachability:", w.cfg.Network.ForceReachability)
+	}
 	if w.cfg.Network.ConnectivityMode != "" {
 		w.printf("  %-28s %s\n", "Connectivity mode:", w.cfg.Network.ConnectivityMode)
 	}
@@ -111,8 +111,8 @@ func TestWizard_UnparseableExistingConfigIsReplaced(t *testing.T) {
 	if err != nil {
 		t.Fatalf("broken config was not replaced: %v", err)
 	}
-	if cfg.Cache.MaxSize != "10GB" {
-		t.Errorf("cache.max_size = %q, want %q", cfg.Cache.MaxSize, "10GB")
+	if cfg.Cache.MaxSize != "10GiB" {
+		t.Errorf("cache.max_size = %q, want %q", cfg.Cache.MaxSize, "10GiB")
 	}
 }
 
@@ -195,8 +195,8 @@ func TestWizard_EditExisting_ApplyProfileOverwrites(t *testing.T) {
 	}
 
 	// Applying a profile is opt-in, and it does overwrite.
-	if cfg.Cache.MaxSize != "10GB" {
-		t.Errorf("cache.max_size = %q, want %q from the home profile", cfg.Cache.MaxSize, "10GB")
+	if cfg.Cache.MaxSize != "10GiB" {
+		t.Errorf("cache.max_size = %q, want %q from the home profile", cfg.Cache.MaxSize, "10GiB")
 	}
 	if cfg.Transfer.MaxUploadRate != "0" {
 		t.Errorf("max_upload_rate = %q, want %q from the home profile", cfg.Transfer.MaxUploadRate, "0")
@@ -292,8 +292,8 @@ func TestWizard_EditExisting_StartFromScratch(t *testing.T) {
 	if cfg.Network.ProxyPort != 9977 {
 		t.Errorf("proxy_port = %d, want default 9977 — start-from-scratch must discard 8888", cfg.Network.ProxyPort)
 	}
-	if cfg.Cache.MaxSize != "10GB" {
-		t.Errorf("cache.max_size = %q, want default %q — must discard 99GB", cfg.Cache.MaxSize, "10GB")
+	if cfg.Cache.MaxSize != "10GiB" {
+		t.Errorf("cache.max_size = %q, want default %q — must discard 99GB", cfg.Cache.MaxSize, "10GiB")
 	}
 	if cfg.Transfer.MaxUploadRate != "0" {
 		t.Errorf("max_upload_rate = %q, want default %q — must discard 25MB/s", cfg.Transfer.MaxUploadRate, "0")
@@ -347,7 +347,7 @@ func TestWizard_HomeProfile(t *testing.T) {
 	// mdns=enter(Y), fleet=enter(N), log level=enter(1=info), confirm=y
 	w, f := newTestWizard(
 		"1", // profile: home
-		"",  // cache size: accept default 10GB
+		"",  // cache size: accept default 10GiB
 		"",  // upload rate: accept default
 		"",  // download rate: accept default
 		"",  // proxy port: accept default
@@ -372,8 +372,8 @@ func TestWizard_HomeProfile(t *testing.T) {
 		t.Fatalf("failed to load saved config: %v", err)
 	}
 
-	if cfg.Cache.MaxSize != "10GB" {
-		t.Errorf("cache.max_size = %q, want %q", cfg.Cache.MaxSize, "10GB")
+	if cfg.Cache.MaxSize != "10GiB" {
+		t.Errorf("cache.max_size = %q, want %q", cfg.Cache.MaxSize, "10GiB")
 	}
 	if cfg.Transfer.MaxUploadRate != "0" {
 		t.Errorf("transfer.max_upload_rate = %q, want %q", cfg.Transfer.MaxUploadRate, "0")
@@ -396,7 +396,7 @@ func TestWizard_ServerProfile(t *testing.T) {
 	w, f := newTestWizard(
 		"2",     // profile: server
 		"100GB", // custom cache size
-		"",      // upload rate: accept default 50MB/s
+		"",      // upload rate: accept default 50MiB/s
 		"",      // download rate: accept default
 		"",      // proxy port: accept default
 		"",      // p2p port: accept default
@@ -422,8 +422,8 @@ func TestWizard_ServerProfile(t *testing.T) {
 	if cfg.Cache.MaxSize != "100GB" {
 		t.Errorf("cache.max_size = %q, want %q", cfg.Cache.MaxSize, "100GB")
 	}
-	if cfg.Transfer.MaxUploadRate != "50MB/s" {
-		t.Errorf("transfer.max_upload_rate = %q, want %q", cfg.Transfer.MaxUploadRate, "50MB/s")
+	if cfg.Transfer.MaxUploadRate != "50MiB/s" {
+		t.Errorf("transfer.max_upload_rate = %q, want %q", cfg.Transfer.MaxUploadRate, "50MiB/s")
 	}
 	if !cfg.Fleet.Enabled {
 		t.Errorf("fleet.enabled = false, want true for server profile")
@@ -773,3 +773,150 @@ func TestParseHostList(t *testing.T) {
 		}
 	}
 }
+
+// newSetupWizard returns a wizard in first-time setup mode whose system files
+// live under a temporary directory.
+func newSetupWizard(t *testing.T, lines ...string) (*wizard, string, *os.File) {
+	t.Helper()
+	dir := t.TempDir()
+	w, f := newTestWizard(lines...)
+	w.setup = &setupPaths{
+		aptConf:  filepath.Join(dir, "90debswarm.conf"),
+		detector: filepath.Join(dir, "apt-proxy-detect"),
+		dataDir:  filepath.Join(dir, "data"),
+	}
+	return w, dir, f
+}
+
+func TestWizard_Setup_NATPrivateSwarm(t *testing.T) {
+	w, dir, f := newSetupWizard(t,
+		"1", // profile: home
+		"",  // cache size
+		"",  // upload rate
+		"",  // download rate
+		"",  // proxy port
+		"",  // p2p port
+		"",  // metrics port
+		"1", // network: behind NAT
+		"y", // private swarm
+		"",  // trust known repos
+		"",  // additional repo hosts
+		"",  // mdns
+		"n", // PSK generation: no (would write to $HOME)
+		"",  // fleet
+		"",  // log level
+		"y", // confirm save
+		"",  // create identity: accept default (Y for a private swarm)
+		"y", // configure APT
+	)
+	defer f.Close()
+	if err := os.WriteFile(w.setup.detector, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	outPath := filepath.Join(dir, "config.toml")
+	if err := w.run(outPath); err != nil {
+		t.Fatalf("wizard.run() failed: %v", err)
+	}
+
+	cfg, err := config.Load(outPath)
+	if err != nil {
+		t.Fatalf("failed to load saved config: %v", err)
+	}
+	if cfg.Network.ForceReachability != config.ReachabilityPrivate {
+		t.Errorf("network.force_reachability = %q, want %q", cfg.Network.ForceReachability, config.ReachabilityPrivate)
+	}
+	if _, err := os.Stat(filepath.Join(w.setup.dataDir, "identity.key")); err != nil {
+		t.Errorf("identity key not created: %v", err)
+	}
+	data, err := os.ReadFile(w.setup.aptConf)
+	if err != nil {
+		t.Fatalf("APT config not written: %v", err)
+	}
+	if !strings.Contains(string(data), `Acquire::http::Proxy-Auto-Detect "`+w.setup.detector+`";`) {
+		t.Errorf("APT config does not use the detect script:\n%s", data)
+	}
+}
+
+func TestWizard_Setup_PublicCustomPort(t *testing.T) {
+	w, dir, f := newSetupWizard(t,
+		"2",    // profile: server
+		"",     // cache size
+		"",     // upload rate
+		"",     // download rate
+		"8080", // proxy port
+		"",     // p2p port
+		"",     // metrics port
+		"2",    // network: public IP
+		"",     // private swarm: accept default (N)
+		"",     // trust known repos
+		"",     // additional repo hosts
+		"",     // mdns
+		"",     // fleet
+		"",     // log level
+		"y",    // confirm save
+		"",     // create identity: accept default (N)
+		"y",    // configure APT
+	)
+	defer f.Close()
+
+	outPath := filepath.Join(dir, "config.toml")
+	if err := w.run(outPath); err != nil {
+		t.Fatalf("wizard.run() failed: %v", err)
+	}
+
+	cfg, err := config.Load(outPath)
+	if err != nil {
+		t.Fatalf("failed to load saved config: %v", err)
+	}
+	if cfg.Network.ForceReachability != config.ReachabilityPublic {
+		t.Errorf("network.force_reachability = %q, want %q", cfg.Network.ForceReachability, config.ReachabilityPublic)
+	}
+	if _, err := os.Stat(filepath.Join(w.setup.dataDir, "identity.key")); !os.IsNotExist(err) {
+		t.Errorf("identity key created without being asked for")
+	}
+	// No detect script, and it only probes the default port anyway.
+	data, err := os.ReadFile(w.setup.aptConf)
+	if err != nil {
+		t.Fatalf("APT config not written: %v", err)
+	}
+	if !strings.Contains(string(data), `Acquire::http::Proxy "http://127.0.0.1:8080";`) {
+		t.Errorf("APT config does not point at port 8080:\n%s", data)
+	}
+}
+
+func TestWizard_Setup_KeepsExistingAPTConfig(t *testing.T) {
+	w, dir, f := newSetupWizard(t,
+		"1", // profile: home
+		"",  // cache size
+		"",  // upload rate
+		"",  // download rate
+		"",  // proxy port
+		"",  // p2p port
+		"",  // metrics port
+		"3", // network: not sure
+		"n", // private swarm
+		"",  // trust known repos
+		"",  // additional repo hosts
+		"",  // mdns
+		"",  // fleet
+		"",  // log level
+		"y", // confirm save
+		"n", // create identity
+	)
+	defer f.Close()
+	const existing = "// packaged\n"
+	if err := os.WriteFile(w.setup.aptConf, []byte(existing), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.run(filepath.Join(dir, "config.toml")); err != nil {
+		t.Fatalf("wizard.run() failed: %v", err)
+	}
+	if data, _ := os.ReadFile(w.setup.aptConf); string(data) != existing {
+		t.Errorf("existing APT config was rewritten: %q", data)
+	}
+	if w.cfg.Network.ForceReachability != "" {
+		t.Errorf("force_reachability = %q, want unset for automatic detection", w.cfg.Network.ForceReachability)
+	}
+}
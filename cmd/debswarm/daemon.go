@@ -2,39 +2,63 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/libp2p/go-libp2p/core/peer"
+	toml "github.com/pelletier/go-toml/v2"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
+	"github.com/debswarm/debswarm/internal/accesslog"
 	"github.com/debswarm/debswarm/internal/aptarchives"
 	"github.com/debswarm/debswarm/internal/aptlists"
+	"github.com/debswarm/debswarm/internal/artifact"
 	"github.com/debswarm/debswarm/internal/audit"
 	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/chaos"
 	"github.com/debswarm/debswarm/internal/config"
 	"github.com/debswarm/debswarm/internal/connectivity"
 	"github.com/debswarm/debswarm/internal/dashboard"
 	"github.com/debswarm/debswarm/internal/fleet"
+	"github.com/debswarm/debswarm/internal/fleethttp"
 	"github.com/debswarm/debswarm/internal/gpg"
+	"github.com/debswarm/debswarm/internal/hooks"
+	"github.com/debswarm/debswarm/internal/httpclient"
 	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/iothrottle"
+	"github.com/debswarm/debswarm/internal/lanproxy"
+	"github.com/debswarm/debswarm/internal/localmirror"
 	"github.com/debswarm/debswarm/internal/metrics"
 	"github.com/debswarm/debswarm/internal/mirror"
 	"github.com/debswarm/debswarm/internal/p2p"
 	"github.com/debswarm/debswarm/internal/peers"
+	"github.com/debswarm/debswarm/internal/prefetch"
 	"github.com/debswarm/debswarm/internal/proxy"
+	"github.com/debswarm/debswarm/internal/revocation"
+	"github.com/debswarm/debswarm/internal/scanner"
 	"github.com/debswarm/debswarm/internal/scheduler"
 	"github.com/debswarm/debswarm/internal/sdnotify"
+	"github.com/debswarm/debswarm/internal/security"
 	"github.com/debswarm/debswarm/internal/timeouts"
+	"github.com/debswarm/debswarm/internal/units"
+	"github.com/debswarm/debswarm/internal/updatecheck"
+	"github.com/debswarm/debswarm/internal/uplink"
 	"github.com/debswarm/debswarm/internal/verify"
 )
 
@@ -69,8 +93,8 @@ downloads packages from P2P peers when available, falling back to mirrors.`,
 	cmd.Flags().IntVar(&metricsPort, "metrics-port", 9978, "Metrics endpoint port (0 to disable)")
 	cmd.Flags().StringVar(&metricsBind, "metrics-bind", "127.0.0.1", "Metrics endpoint bind address (SECURITY: 0.0.0.0 exposes stats externally)")
 	cmd.Flags().BoolVar(&preferQUIC, "prefer-quic", true, "Prefer QUIC transport over TCP")
-	cmd.Flags().StringVar(&maxUploadRate, "max-upload-rate", "", "Max upload rate (e.g., 10MB/s, 0 = unlimited)")
-	cmd.Flags().StringVar(&maxDownloadRate, "max-download-rate", "", "Max download rate (e.g., 50MB/s, 0 = unlimited)")
+	cmd.Flags().Var(&maxUploadRate, "max-upload-rate", "Max upload rate (e.g., 10MB/s or 30%link, 0 = unlimited)")
+	cmd.Flags().Var(&maxDownloadRate, "max-download-rate", "Max download rate (e.g., 50MB/s or 80%link, 0 = unlimited)")
 
 	return cmd
 }
@@ -81,6 +105,8 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to setup logger: %w", err)
 	}
+	recentLogs := newLogRing(logRingSize)
+	logger = recentLogs.tee(logger)
 	defer func() { _ = logger.Sync() }()
 
 	logger.Info("Starting debswarm daemon",
@@ -124,6 +150,18 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid configuration: %w", validateErr)
 	}
 
+	// The configuration as loaded, before fleet overrides, for
+	// 'debswarm config diff' (GET /api/config). SIGHUP updates what it applies.
+	var activeCfg atomic.Pointer[config.Config]
+	activeCfg.Store(cfg)
+
+	// A follower starts with the settings last received from the fleet
+	// leader, so a restart does not revert to the local values.
+	if overlaid := withAppliedSharedSettings(cfg); overlaid != cfg {
+		cfg = overlaid
+		logger.Info("Using settings received from fleet leader")
+	}
+
 	// Determine data directory for persistent identity
 	// Priority: --data-dir flag > STATE_DIRECTORY env > /var/lib/debswarm > ~/.local/share/debswarm
 	p2pDataDir := os.Getenv("STATE_DIRECTORY")
@@ -170,9 +208,16 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 
 	// Initialize metrics
 	m := metrics.New()
-
-	// Initialize audit logger
-	var auditLogger audit.Logger = &audit.NoopLogger{}
+	m.Peers.Configure(metrics.PeerLabelConfig{
+		Mode:    cfg.Metrics.GetPerPeer(),
+		TopN:    cfg.Metrics.TopPeers,
+		Buckets: cfg.Metrics.PeerBuckets,
+	})
+
+	// Initialize audit logger. Significant events also go to the timeline
+	// shown on the dashboard's events page, audit log or not.
+	timeline := audit.NewTimeline(audit.DefaultTimelineSize)
+	var auditLogger audit.Logger = timeline
 	if cfg.Logging.Audit.Enabled {
 		auditWriter, auditErr := audit.NewJSONWriter(audit.JSONWriterConfig{
 			Path:       cfg.Logging.Audit.Path,
@@ -182,7 +227,11 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		if auditErr != nil {
 			return fmt.Errorf("failed to initialize audit logger: %w", auditErr)
 		}
-		auditLogger = auditWriter
+		// Earlier events from the audit log carry the timeline over restarts
+		if err := timeline.Load(cfg.Logging.Audit.Path); err != nil {
+			logger.Warn("Failed to load event history from the audit log", zap.Error(err))
+		}
+		auditLogger = audit.Tee(auditWriter, timeline)
 		defer func() { _ = auditWriter.Close() }()
 		logger.Info("Audit logging enabled",
 			zap.String("path", cfg.Logging.Audit.Path),
@@ -190,14 +239,74 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 			zap.Int("maxBackups", cfg.Logging.Audit.GetMaxBackups()))
 	}
 
+	var accessLog *accesslog.Logger
+	if access := cfg.Logging.Access; access.Enabled {
+		var err error
+		accessLog, err = accesslog.New(accesslog.Config{
+			Path:         access.Path,
+			MaxSizeMB:    access.GetMaxSizeMB(),
+			MaxBackups:   access.GetMaxBackups(),
+			SampleRate:   access.GetSampleRate(),
+			MaxPerSecond: access.GetMaxPerSecond(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to initialize access log: %w", err)
+		}
+		defer func() { _ = accessLog.Close() }()
+		logger.Info("Access logging enabled",
+			zap.String("path", access.Path),
+			zap.Float64("sampleRate", access.GetSampleRate()),
+			zap.Int("maxPerSecond", access.GetMaxPerSecond()))
+	}
+
 	// Initialize peer scorer
 	scorer := peers.NewScorer()
-
-	// Initialize timeout manager
+	ps := cfg.Transfer.PeerSelection
+	scorer.SetSelectionConfig(peers.SelectionConfig{
+		MaxPerSubnet:     ps.MaxPeersPerSubnet,
+		MaxPerNetgroup:   ps.MaxPeersPerNetgroup,
+		MinScore:         ps.GetMinScore(),
+		ExplorationRatio: ps.GetExplorationRatio(),
+		MinAddressGroups: ps.MinAddressGroups,
+		PreferTags:       ps.PreferTags,
+	})
+	cb := cfg.Transfer.CircuitBreaker
+	scorer.SetBreakerConfig(peers.BreakerConfig{
+		FailureThreshold: cb.GetFailureThreshold(),
+		Backoff:          cb.BackoffDuration(),
+		MaxBackoff:       cb.MaxBackoffDuration(),
+	})
+	sc := cfg.Transfer.Scoring
+	scorer.SetScoringConfig(peers.ScoringConfig{
+		Weights: peers.ScoreWeights{
+			Latency:     sc.Weight("latency"),
+			Throughput:  sc.Weight("throughput"),
+			Reliability: sc.Weight("reliability"),
+			Freshness:   sc.Weight("freshness"),
+			Proximity:   sc.Weight("proximity"),
+			Reciprocity: sc.Weight("reciprocity"),
+		},
+		DecayHalfLife: sc.DecayHalfLifeDuration(),
+	})
+	bans := cfg.Transfer.Bans
+	scorer.SetBanPolicy(peers.BanPolicy{
+		Duration:       bans.DurationValue(),
+		Multiplier:     bans.GetMultiplier(),
+		PermanentAfter: bans.GetPermanentAfter(),
+		StrikeExpiry:   bans.StrikeExpiryDuration(),
+	})
+
+	// Initialize timeout manager, starting from what the last run learned
 	tm := timeouts.NewManager(timeouts.DefaultConfig())
+	timeoutsPath := filepath.Join(p2pDataDir, "timeouts.json")
+	if restored, loadErr := tm.Load(timeoutsPath); loadErr != nil {
+		logger.Warn("Failed to load learned timeouts, starting from defaults", zap.Error(loadErr))
+	} else if restored {
+		logger.Debug("Restored learned timeouts", zap.String("path", timeoutsPath))
+	}
 
 	// Initialize cache
-	maxSize := cfg.Cache.MaxSizeBytes()
+	maxSize := cacheMaxSize(&cfg.Cache)
 	minFreeSpace := cfg.Cache.MinFreeSpaceBytes()
 	pkgCache, err := cache.NewWithMinFreeSpace(cfg.Cache.Path, maxSize, minFreeSpace, logger)
 	if err != nil {
@@ -205,9 +314,14 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 	}
 	defer func() { _ = pkgCache.Close() }()
 
+	// A capacity relative to the disk follows the filesystem as it is resized
+	sizer := newCacheSizer(pkgCache, &cfg.Cache, m, logger)
+	go sizer.run(ctx)
+
 	logger.Info("Initialized cache",
 		zap.String("path", cfg.Cache.Path),
-		zap.Int64("maxSize", maxSize),
+		zap.Int64("maxSize", pkgCache.MaxSize()),
+		zap.String("maxSizeSetting", cfg.Cache.MaxSizeLimit().String()),
 		zap.Int64("minFreeSpace", minFreeSpace),
 		zap.Int("currentCount", pkgCache.Count()),
 		zap.Int64("currentSize", pkgCache.Size()))
@@ -225,6 +339,41 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		logger.Info("Repository metadata caching disabled")
 	}
 
+	// Keep hot small files in RAM in front of the disk cache, if configured
+	if memoryTierSize := cfg.Cache.MemoryTierSizeBytes(); memoryTierSize > 0 {
+		maxObject := cfg.Cache.MemoryTierMaxObjectBytes()
+		pkgCache.SetMemoryTier(memoryTierSize, maxObject)
+		pkgCache.SetOnMemoryLookup(func(kind string, hit bool) {
+			if hit {
+				m.MemoryTierHits.WithLabel(kind).Inc()
+			} else {
+				m.MemoryTierMisses.WithLabel(kind).Inc()
+			}
+		})
+		logger.Info("In-memory cache tier enabled",
+			zap.Int64("budget", memoryTierSize),
+			zap.Int64("maxObject", maxObject))
+	}
+
+	// Restore the names and tags operators gave peers
+	loadPeerLabels(pkgCache, scorer, logger)
+
+	// Restore the bans of peers that served corrupt data, and keep them
+	// stored as they change
+	loadPeerBans(pkgCache, scorer, logger)
+	scorer.SetBanRecorder(func(b peers.Ban) {
+		if err := pkgCache.SetPeerBan(cache.PeerBan{
+			PeerID:     b.PeerID.String(),
+			Strikes:    b.Strikes,
+			Reason:     b.Reason,
+			LastStrike: b.LastStrike,
+			Until:      b.Until,
+			Permanent:  b.Permanent,
+		}); err != nil {
+			logger.Warn("Failed to store peer ban", zap.String("peer", b.PeerID.String()), zap.Error(err))
+		}
+	})
+
 	// Update cache metrics
 	m.CacheSize.Set(float64(pkgCache.Size()))
 	m.CacheCount.Set(float64(pkgCache.Count()))
@@ -245,14 +394,18 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 	}
 
 	// Import packages from APT's local cache into debswarm's cache
-	// This runs after the index is populated so we can verify packages
+	// This runs after the index is populated so we can verify packages.
+	// The importer is also driven by the APT hook (debswarm apt enable).
+	// Background imports are paced by [cache.io_throttle], adjustable on reload
+	ioThrottle := iothrottle.New(ioThrottleLimits(&cfg.Cache.IOThrottle))
+	aptImporter := aptarchives.New(pkgCache, idx, logger, &aptarchives.Config{
+		ArchivesPath: cfg.Index.APTArchivesPath,
+		Throttle:     ioThrottle,
+	})
 	if cfg.Index.GetImportAPTArchives() {
-		importer := aptarchives.New(pkgCache, idx, logger, &aptarchives.Config{
-			ArchivesPath: cfg.Index.APTArchivesPath,
-		})
 		// Run import in background to avoid blocking startup
 		go func() {
-			result, err := importer.Import(ctx)
+			result, err := aptImporter.Import(ctx)
 			if err != nil {
 				logger.Warn("Failed to import APT archives", zap.Error(err))
 			} else if result.Imported > 0 {
@@ -264,26 +417,32 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 	}
 
 	// Initialize mirror fetcher
-	fetcher := mirror.NewFetcher(nil, logger)
-
-	// Parse rate limits (CLI flags override config)
-	uploadRate := maxUploadRate
-	if uploadRate == "" {
-		uploadRate = cfg.Transfer.MaxUploadRate
+	fetcher, err := newMirrorFetcher(ctx, cfg, logger)
+	if err != nil {
+		return fmt.Errorf("failed to configure mirror requests: %w", err)
+	}
+	policy, err := mirrorPolicy(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid mirror policy: %w", err)
 	}
-	downloadRate := maxDownloadRate
-	if downloadRate == "" {
-		downloadRate = cfg.Transfer.MaxDownloadRate
+	fetcher.SetMirrorPolicy(policy)
+	if cfg.Network.GetConnectivityMode() == "offline" {
+		fetcher.SetOffline(true)
+		logger.Info("Offline mode: mirror fetches are disabled; serving from cache and peers only")
 	}
 
-	parsedUploadRate, err := config.ParseRate(uploadRate)
-	if err != nil {
-		return fmt.Errorf("invalid max-upload-rate: %w", err)
+	// Rate limits (CLI flags override config). Limits relative to the link
+	// start unlimited and are applied once mirror transfers have measured it.
+	uploadLimit := cfg.Transfer.UploadRateLimit()
+	if maxUploadRate.IsSet() {
+		uploadLimit = maxUploadRate.Rate
 	}
-	parsedDownloadRate, err := config.ParseRate(downloadRate)
-	if err != nil {
-		return fmt.Errorf("invalid max-download-rate: %w", err)
+	downloadLimit := cfg.Transfer.DownloadRateLimit()
+	if maxDownloadRate.IsSet() {
+		downloadLimit = maxDownloadRate.Rate
 	}
+	rates := &linkRates{measure: fetcher.LinkThroughput, logger: logger, upload: uploadLimit, download: downloadLimit}
+	parsedUploadRate, parsedDownloadRate := rates.resolved()
 
 	// Load PSK for private swarm if configured
 	var psk []byte
@@ -306,11 +465,28 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 			zap.String("fingerprint", p2p.PSKFingerprint(loadedPSK)))
 	}
 
+	// Fault injection for resilience testing; off unless [chaos] is set
+	chaosInjector := chaos.New(chaos.Config{
+		DropStreamPercent: cfg.Chaos.DropStreamPercent,
+		DHTDelay:          cfg.Chaos.DHTDelayDuration(),
+		CorruptOneIn:      cfg.Chaos.CorruptOneIn,
+	}, logger, m)
+	if chaosInjector != nil {
+		logger.Warn("CHAOS TESTING ENABLED: peer transfers and DHT lookups will fail on purpose. Do not run this in production.",
+			zap.Float64("dropStreamPercent", cfg.Chaos.DropStreamPercent),
+			zap.String("dhtDelay", cfg.Chaos.DHTDelay),
+			zap.Int("corruptOneIn", cfg.Chaos.CorruptOneIn))
+	}
+
 	// Initialize P2P node with QUIC preference
 	p2pCfg := &p2p.Config{
 		ListenPort:           cfg.Network.ListenPort,
-		BootstrapPeers:       cfg.Network.BootstrapPeers,
+		Listen:               listenConfigs(cfg.Network.Listen),
+		Version:              version,
+		Role:                 cfg.Network.GetRole(),
+		BootstrapPeers:       cfg.Network.BootstrapAddrs(),
 		EnableMDNS:           cfg.Privacy.EnableMDNS,
+		IdentitySigner:       cfg.Privacy.IdentitySigner,
 		DataDir:              p2pDataDir,
 		PreferQUIC:           preferQUIC,
 		MaxUploadRate:        parsedUploadRate,
@@ -324,6 +500,7 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		Timeouts:             tm,
 		Metrics:              m,
 		Audit:                auditLogger,
+		Chaos:                chaosInjector,
 		// NAT traversal configuration
 		EnableRelay:        cfg.Network.IsRelayEnabled(),
 		EnableHolePunching: cfg.Network.IsHolePunchingEnabled(),
@@ -338,6 +515,16 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		RelayDuration:        cfg.Network.RelayDuration(),
 		ForceReachability:    cfg.Network.GetForceReachability(),
 		RelayedTransferMax:   cfg.Network.RelayedTransferMaxBytes(),
+		// Bootstrap nodes behind dynamic DNS: re-resolve their names periodically
+		BootstrapResolveInterval: cfg.Network.BootstrapResolveIntervalDuration(),
+		// Stop waiting once enough bootstrap peers answer, and remember good
+		// peers to bootstrap from when the configured ones are down
+		BootstrapMinPeers: cfg.Network.GetBootstrapMinPeers(),
+		PersistPeers:      cfg.Network.IsPersistPeersEnabled(),
+		// DHT mode and per-hour operation budgets for constrained devices
+		DHTMode:       cfg.DHT.GetMode(),
+		ProvideBudget: cfg.DHT.ProvideBudget,
+		LookupBudget:  cfg.DHT.LookupBudget,
 		// Per-peer rate limiting configuration
 		PerPeerUploadRate:   cfg.Transfer.PerPeerUploadRateBytes(),
 		PerPeerDownloadRate: cfg.Transfer.PerPeerDownloadRateBytes(),
@@ -345,6 +532,56 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		AdaptiveEnabled:     cfg.Transfer.IsAdaptiveEnabled(),
 		AdaptiveMinRate:     cfg.Transfer.AdaptiveMinRateBytes(),
 		AdaptiveMaxBoost:    cfg.Transfer.AdaptiveMaxBoostFactor(),
+		RateLimitBurst:      cfg.Transfer.BurstSizeBytes(),
+		PerPeerBurst:        cfg.Transfer.PerPeerBurstSizeBytes(),
+		SmallObjectSize:     cfg.Transfer.SmallObjectSizeBytes(),
+		// Reciprocity-based upload policy
+		Sharing: p2p.SharingPolicy{
+			Reciprocal:        cfg.Transfer.Sharing.IsReciprocal(),
+			Grace:             cfg.Transfer.Sharing.GraceBytes(),
+			MinRatio:          cfg.Transfer.Sharing.GetMinRatio(),
+			LeecherUploadRate: cfg.Transfer.Sharing.LeecherUploadRateBytes(),
+			LeecherMaxUploads: cfg.Transfer.Sharing.GetLeecherMaxUploads(),
+		},
+		// Caps on WAN peer traffic; LAN peers are exempt
+		WANUploadRate:       cfg.Transfer.SplitHorizon.WANUploadRateBytes(),
+		WANDownloadRate:     cfg.Transfer.SplitHorizon.WANDownloadRateBytes(),
+		ProbeSize:           cfg.Transfer.Probe.SizeBytes(),
+		ProbeOnConnect:      cfg.Transfer.Probe.OnConnect,
+		ReplicationPartners: cfg.Replication.PartnerAddrs(),
+	}
+	if adm := cfg.Transfer.Admission; adm.Enabled {
+		p2pCfg.Admission = &p2p.AdmissionConfig{
+			MaxLoad:        adm.GetMaxLoad(),
+			MaxIOWait:      adm.GetMaxIOWaitPercent() / 100,
+			MaxNetworkRate: adm.MaxNetworkRateBytes(),
+			MinUploads:     adm.GetMinUploads(),
+			Interval:       adm.IntervalDuration(),
+		}
+	}
+	if pwr := cfg.Scheduler.Power; pwr.Enabled {
+		p2pCfg.Power = &p2p.PowerConfig{
+			OnBattery:    pwr.GetOnBattery(),
+			OnMetered:    pwr.GetOnMetered(),
+			ThrottleRate: pwr.ThrottleRateBytes(),
+			Interval:     pwr.IntervalDuration(),
+		}
+	}
+	if cmp := cfg.Transfer.Compression; cmp.Enabled {
+		// Level was validated with the config
+		_, level := zstd.EncoderLevelFromString(cmp.GetLevel())
+		p2pCfg.Compression = &p2p.CompressionConfig{
+			Level:   level,
+			MinSize: cmp.MinSizeBytes(),
+			LAN:     cmp.LAN,
+		}
+	}
+
+	if sp := cfg.Transfer.StreamPool; sp.IsEnabled() {
+		p2pCfg.StreamPool = &p2p.StreamPoolConfig{
+			MaxIdle:   sp.GetMaxIdle(),
+			KeepAlive: sp.KeepAliveDuration(),
+		}
 	}
 
 	p2pNode, err := p2p.New(ctx, p2pCfg, logger)
@@ -352,6 +589,15 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to initialize P2P node: %w", err)
 	}
 	defer func() { _ = p2pNode.Close() }()
+	rates.apply = p2pNode.UpdateRateLimits
+	go rates.run(ctx)
+
+	// Join additional swarms, each through its own node
+	swarms, err := startSwarms(ctx, cfg, *p2pCfg, logger)
+	if err != nil {
+		return err
+	}
+	defer closeSwarms(swarms)
 
 	// Wait for DHT bootstrap in background
 	go func() {
@@ -384,42 +630,82 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 	// Start connectivity monitor in background
 	go connectivityMonitor.Start(ctx)
 
+	// Recover quickly when the network changes under us: switching Wi-Fi,
+	// replugging a cable or resuming from suspend would otherwise leave dead
+	// connections and stale timeouts in place until they time out.
+	netWatcher := connectivity.NewWatcher(&connectivity.WatcherConfig{
+		OnChange: func(change connectivity.Change) {
+			reason := "addresses"
+			if change.Resumed {
+				reason = "resume"
+			}
+			m.NetworkChanges.WithLabel(reason).Inc()
+			go func() {
+				p2pNode.HandleNetworkChange(change.Resumed)
+				connectivityMonitor.CheckNow(ctx)
+			}()
+		},
+	}, logger)
+	go netWatcher.Start(ctx)
+
 	// Initialize scheduler if enabled
 	var sched *scheduler.Scheduler
 	if cfg.Scheduler.Enabled {
-		// Convert config windows to scheduler windows
-		var windows []scheduler.Window
-		for _, w := range cfg.Scheduler.Windows {
-			windows = append(windows, scheduler.Window{
-				Days:      w.Days,
-				StartTime: w.StartTime,
-				EndTime:   w.EndTime,
-			})
-		}
-
 		var err error
-		sched, err = scheduler.New(&scheduler.Config{
-			Enabled:           true,
-			Windows:           windows,
-			Timezone:          cfg.Scheduler.Timezone,
-			OutsideWindowRate: cfg.Scheduler.OutsideWindowRateBytes(),
-			InsideWindowRate:  cfg.Scheduler.InsideWindowRateBytes(),
-			UrgentFullSpeed:   cfg.Scheduler.IsUrgentFullSpeed(),
-		}, logger)
+		schedCfg := schedulerConfig(&cfg.Scheduler)
+		schedCfg.StatePath = filepath.Join(cfg.Cache.Path, schedulerStateFile)
+		sched, err = scheduler.New(schedCfg, logger)
 		if err != nil {
 			return fmt.Errorf("failed to initialize scheduler: %w", err)
 		}
 		if sched != nil {
+			// The window rate applies to package downloads from mirrors and
+			// peers alike
+			fetcher.SetThrottle(sched.ReaderContext)
+			p2pNode.SetDownloadThrottle(sched.ReaderContext)
 			logger.Info("Scheduler enabled",
-				zap.Int("windows", len(windows)),
+				zap.Int("windows", len(cfg.Scheduler.Windows)),
+				zap.Int("exceptions", len(cfg.Scheduler.Exceptions)),
 				zap.String("timezone", cfg.Scheduler.Timezone),
 				zap.Int64("outside_rate", cfg.Scheduler.OutsideWindowRateBytes()),
 				zap.Bool("in_window", sched.IsInWindow()))
 		}
 	}
 
+	// Shared uplink budget, on top of this daemon's own limits and the
+	// scheduler's rate
+	if upl := cfg.Transfer.Uplink; upl.IsEnabled() {
+		budget, err := uplink.New(uplink.Config{
+			Dir:      upl.Dir,
+			Member:   p2pNode.PeerID().String(),
+			Upload:   upl.UploadRateBytes(),
+			Download: upl.DownloadRateBytes(),
+			Interval: upl.IntervalDuration(),
+			Metrics:  m,
+		}, logger.Named("uplink"))
+		if err != nil {
+			return fmt.Errorf("failed to initialize shared uplink budget: %w", err)
+		}
+		budget.Start(ctx)
+		downloadThrottle := budget.ReaderContext
+		if sched != nil {
+			downloadThrottle = func(ctx context.Context, r io.Reader) io.Reader {
+				return budget.ReaderContext(ctx, sched.ReaderContext(ctx, r))
+			}
+		}
+		fetcher.SetThrottle(downloadThrottle)
+		p2pNode.SetDownloadThrottle(downloadThrottle)
+		p2pNode.SetUploadThrottle(budget.WriterContextSize)
+		logger.Info("Shared uplink budget enabled",
+			zap.String("dir", upl.Dir),
+			zap.Int64("upload_rate", upl.UploadRateBytes()),
+			zap.Int64("download_rate", upl.DownloadRateBytes()))
+	}
+
 	// Initialize fleet coordinator if enabled
 	var fleetCoord *fleet.Coordinator
+	var fleetHTTPSClient *fleethttp.Client
+	var fleetHTTPSTLS *tls.Config
 	if cfg.Fleet.Enabled {
 		fleetCoord = fleet.New(&fleet.Config{
 			ClaimTimeout:    cfg.Fleet.ClaimTimeoutDuration(),
@@ -439,6 +725,22 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 			zap.Duration("claimTimeout", cfg.Fleet.ClaimTimeoutDuration()),
 			zap.Duration("maxWaitTime", cfg.Fleet.MaxWaitTimeDuration()),
 			zap.Int("allowConcurrent", cfg.Fleet.AllowConcurrent))
+
+		// The HTTPS fallback: fetch from fleet peers over mutual TLS when a
+		// P2P transfer fails. Its server starts once the proxy can serve content.
+		if cfg.Fleet.HTTPS.IsEnabled() {
+			var clientTLS *tls.Config
+			fleetHTTPSTLS, clientTLS, err = fleethttp.LoadTLS(fleethttp.TLSFiles{
+				CertFile: cfg.Fleet.HTTPS.CertFile,
+				KeyFile:  cfg.Fleet.HTTPS.KeyFile,
+				CAFile:   cfg.Fleet.HTTPS.CAFile,
+			})
+			if err != nil {
+				return fmt.Errorf("fleet HTTPS fallback: %w", err)
+			}
+			fleetHTTPSClient = fleethttp.NewClient(clientTLS, cfg.Fleet.HTTPS.Port)
+			defer fleetHTTPSClient.Close()
+		}
 	}
 
 	// Initialize multi-source verifier
@@ -461,6 +763,11 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid network.proxy_allowed_cidrs: %w", err)
 	}
 
+	clients, err := clientProfiles(cfg.Clients)
+	if err != nil {
+		return fmt.Errorf("invalid clients configuration: %w", err)
+	}
+
 	// Load the trusted keyring and resolve the upstream signature-verification
 	// mode. Verification reads the signed Release from the metadata cache, so it
 	// needs cache_metadata; and enforce cannot function with no trusted keys.
@@ -493,6 +800,56 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Third-party repositories, each verified against its own keyring if set
+	repos := make([]proxy.Repo, 0, len(cfg.Repos))
+	for _, r := range cfg.Repos {
+		repo := proxy.Repo{Name: r.Name, Hosts: r.Hosts, Share: r.IsShared(), UploadPriority: r.UploadPriority}
+		if r.Keyring != "" {
+			if repo.Keyring, err = gpg.Load(logger, r.Keyring); err != nil {
+				return fmt.Errorf("failed to load keyring of repository %s: %w", r.Name, err)
+			}
+		}
+		repos = append(repos, repo)
+	}
+
+	// Load the revocation list signing keyring and the last accepted list, so
+	// enforcement is in force from the first request even if the list URL is
+	// unreachable at startup.
+	var revocations *revocation.Manager
+	if cfg.Revocation.Enabled() {
+		revKeyring, rerr := gpg.Load(logger, cfg.Revocation.KeyringPath)
+		if rerr != nil {
+			return fmt.Errorf("failed to load revocation keyring: %w", rerr)
+		}
+		if revKeyring.Empty() {
+			return fmt.Errorf("revocation.keyring_path %q contains no usable public keys", cfg.Revocation.KeyringPath)
+		}
+		revocations = revocation.NewManager(revKeyring, filepath.Join(p2pDataDir, "revocations.asc"), logger)
+		if lerr := revocations.Load(); lerr != nil {
+			logger.Warn("Ignoring persisted revocation list", zap.Error(lerr))
+		}
+	}
+
+	// Pipeline hooks compiled into this binary (see internal/hooks)
+	var pipelineHooks *hooks.Chain
+	if registered := hooks.Registered(); len(registered) > 0 {
+		names := make([]string, 0, len(registered))
+		for _, h := range registered {
+			names = append(names, h.Name())
+		}
+		pipelineHooks = hooks.NewChain(registered, logger.Named("hooks"))
+		logger.Info("Pipeline hooks registered", zap.Strings("hooks", names))
+	}
+
+	// Malware scanning before packages are cached (security.scan)
+	var pkgScanner scanner.Scanner
+	if sc := cfg.Security.Scan; sc.Enabled() {
+		pkgScanner = scanner.New(sc.Clamd, sc.Command, sc.TimeoutDuration())
+		logger.Info("Package scanning enabled",
+			zap.String("scanner", pkgScanner.Name()),
+			zap.Duration("timeout", sc.TimeoutDuration()))
+	}
+
 	// Initialize proxy server
 	proxyCfg := &proxy.Config{
 		Addr:                       net.JoinHostPort(cfg.Network.ProxyBind, strconv.Itoa(cfg.Network.ProxyPort)),
@@ -501,7 +858,6 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		DHTLookupLimit:             10,
 		MetricsPort:                cfg.Metrics.Port,
 		MetricsBind:                cfg.Metrics.Bind,
-		CacheMaxSize:               maxSize,
 		MaxConcurrentPeerDownloads: cfg.Transfer.MaxConcurrentPeerDownloads,
 		Metrics:                    m,
 		Timeouts:                   tm,
@@ -510,42 +866,259 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		Connectivity:               connectivityMonitor,
 		Scheduler:                  sched,
 		Fleet:                      fleetCoord,
+		FleetHTTPS:                 fleetHTTPSClient,
 		Verifier:                   verifier,
+		Revocations:                revocations,
+		Hooks:                      pipelineHooks,
+		Scanner:                    pkgScanner,
+		QuarantineDir:              cfg.Security.Scan.QuarantineDir,
 		RetryMaxAttempts:           cfg.Transfer.RetryMaxAttempts,
 		RetryInterval:              cfg.Transfer.RetryIntervalDuration(),
 		RetryMaxAge:                cfg.Transfer.RetryMaxAgeDuration(),
-		AllowedHosts:               cfg.Proxy.EffectiveAllowedHosts(),
+		MirrorPolicy:               policy,
 		HTTPSUpstreamHosts:         cfg.Proxy.EffectiveHTTPSUpstreamHosts(),
 		MetadataServeStale:         cfg.Cache.ServesStaleMetadata(),
+		ReconstructPdiffs:          cfg.Cache.ReconstructsPdiffs(),
 		VerifyMode:                 verifyMode,
 		Keyring:                    keyring,
 		VerifyExemptHosts:          cfg.Security.VerifyExemptHosts,
+		HashRequired:               cfg.Security.HashRequired,
+		HashRequiredExempt:         cfg.Security.HashRequiredExempt,
+		Repos:                      repos,
+		Clients:                    clients,
+		ClassPolicies:              classPolicies(cfg.Proxy.Classes),
+		PassthroughTTL:             cfg.Cache.PassthroughTTLDuration(),
+		PassthroughMaxTTL:          cfg.Cache.PassthroughMaxTTLDuration(),
+		ClassTTLs:                  classTTLs(cfg.Proxy.Classes),
+		StrictWhenFull:             cfg.Cache.StrictWhenFull,
+		ProviderTTL:                cfg.DHT.ProviderTTLDuration(),
+		HedgePercentile:            cfg.Transfer.GetHedgePercentile(),
+		RetryBudget:                float64(cfg.Transfer.GetRetryBudgetPercent()) / 100,
+		MaxChunksPerPeer:           cfg.Transfer.MaxChunksPerPeer,
+		ReadThrough:                cfg.Transfer.IsReadThroughEnabled(),
+		StreamMirror:               cfg.Transfer.IsStreamMirrorEnabled(),
+		Receipts:                   cfg.Transfer.IsReceiptsEnabled(),
+		Timeline:                   timeline,
+		AccessLog:                  accessLog,
+	}
+	if cfg.Build.Port != 0 {
+		proxyCfg.Build = &proxy.BuildProfile{
+			Addr:        net.JoinHostPort(cfg.Network.ProxyBind, strconv.Itoa(cfg.Build.Port)),
+			MetadataTTL: cfg.Build.MetadataTTLDuration(),
+			DefaultKey:  cfg.Build.GetDefaultKey(),
+			Pin:         cfg.Build.Pin,
+		}
+	}
+	if canary := cfg.Transfer.Canary; canary.Enabled() {
+		proxyCfg.Canary = &proxy.CanaryConfig{
+			SampleRate:    canary.SamplePercent / 100,
+			MaxConcurrent: canary.GetMaxConcurrent(),
+			MaxSize:       canary.MaxSizeBytes(),
+		}
+		logger.Info("Canary mode enabled: sampled P2P downloads are re-fetched from the mirror",
+			zap.Float64("samplePercent", canary.SamplePercent))
+	}
+
+	if shz := cfg.Transfer.SplitHorizon; shz.Enabled {
+		proxyCfg.SplitHorizon = &proxy.SplitHorizonConfig{
+			Deadline:   shz.DeadlineDuration(),
+			MinLANRate: shz.MinLANRateBytes(),
+		}
+		logger.Info("Split horizon enabled: LAN peers are tried before WAN peers and the mirror",
+			zap.Duration("deadline", shz.DeadlineDuration()))
+	}
+
+	for _, name := range cfg.Generic.EnabledAdapters() {
+		a, err := artifact.New(name)
+		if err != nil {
+			return err
+		}
+		proxyCfg.GenericAdapters = append(proxyCfg.GenericAdapters, a)
+	}
+	if len(proxyCfg.GenericAdapters) > 0 {
+		logger.Info("Generic mode enabled: /generic/<adapter>/ URLs cache and share other ecosystems' artifacts",
+			zap.Strings("adapters", cfg.Generic.EnabledAdapters()))
 	}
 
 	proxyServer := proxy.NewServer(proxyCfg, pkgCache, idx, p2pNode, fetcher, logger)
 	proxyServer.SetP2PNode(p2pNode)
+	for _, sw := range swarms {
+		proxyServer.AddSwarm(sw)
+	}
+	proxyServer.SetAPTImporter(aptImporter)
+	proxyServer.SetConfigSource(func() ([]byte, error) {
+		return toml.Marshal(activeCfg.Load().Redacted())
+	})
+	proxyServer.SetLogSource(recentLogs.Bytes)
+
+	// Fleet shared settings start once the proxy exists, as they may replace
+	// its mirror policy.
+	if cfg.Fleet.Enabled && cfg.Fleet.Shared.IsEnabled() {
+		shared, err := startFleetShared(ctx, cfg, p2pNode, sched, sizer, proxyServer, fetcher, logger)
+		if err != nil {
+			return err
+		}
+		defer shared.Close()
+	}
 
-	// Initialize dashboard
+	if fleetHTTPSTLS != nil {
+		fleetHTTPSServer := fleethttp.NewServer(fleethttp.ServerConfig{
+			Addr:          cfg.Fleet.HTTPS.Addr(),
+			TLS:           fleetHTTPSTLS,
+			Content:       proxyServer.PeerContent(),
+			MaxConcurrent: cfg.Transfer.MaxConcurrentUploads,
+			Accept:        p2pNode.AcceptsUploads,
+			Throttle:      p2pNode.ThrottleUpload,
+		}, logger)
+		if err := fleetHTTPSServer.Start(); err != nil {
+			return fmt.Errorf("fleet HTTPS fallback: %w", err)
+		}
+		defer func() { _ = fleetHTTPSServer.Close() }()
+		logger.Info("Fleet HTTPS fallback enabled", zap.String("addr", cfg.Fleet.HTTPS.Addr()))
+	}
+
+	// Revocation enforcement: purge what the persisted list already revokes,
+	// purge again whenever a newer list is accepted, and share it with the fleet.
+	if revocations != nil {
+		var revGossip *revocation.Gossip
+		if cfg.Revocation.IsGossipEnabled() {
+			revGossip = revocation.NewGossip(p2pNode.Host(), revocations, logger)
+			defer revGossip.Close()
+		}
+		revocations.SetOnUpdate(func(list *revocation.List) {
+			proxyServer.PurgeRevoked(list)
+			if revGossip != nil {
+				go revGossip.Push(ctx, mdnsPeerIDs(p2pNode))
+			}
+		})
+		if list := revocations.Current(); list != nil {
+			proxyServer.PurgeRevoked(list)
+		}
+		go runRevocationRefresh(ctx, revocations, revGossip, proxyServer, p2pNode, cfg.Revocation.URL,
+			cfg.Revocation.RefreshIntervalDuration(), logger)
+		logger.Info("Revocation list enforcement enabled",
+			zap.String("url", cfg.Revocation.URL),
+			zap.Bool("gossip", revGossip != nil),
+			zap.Duration("refreshInterval", cfg.Revocation.RefreshIntervalDuration()))
+	}
+
+	// Initialize dashboard (an empty rate shows as unlimited)
 	dashCfg := &dashboard.Config{
-		Version:         version,
-		PeerID:          p2pNode.PeerID().String(),
-		MaxUploadRate:   uploadRate,
-		MaxDownloadRate: downloadRate,
+		Version: version,
+		PeerID:  p2pNode.PeerID().String(),
+	}
+	if uploadLimit != (units.Rate{}) {
+		dashCfg.MaxUploadRate = uploadLimit.String()
+	}
+	if downloadLimit != (units.Rate{}) {
+		dashCfg.MaxDownloadRate = downloadLimit.String()
 	}
 	dash := dashboard.New(dashCfg, proxyServer.GetDashboardStats, proxyServer.GetPeerInfo)
+	dash.SetDownloadsProvider(proxyServer.GetDashboardDownloads)
+	dash.SetEventsProvider(proxyServer.GetDashboardEvents)
 	proxyServer.SetDashboard(dash)
 
+	// Report newer releases on the dashboard and in the log (opt-in)
+	if cfg.UpdateCheck.Enabled {
+		updates := updatecheck.New(cfg.UpdateCheck.URL, version, httpclient.WithTimeout(30*time.Second), logger)
+		proxyServer.SetUpdateChecker(updates)
+		go updates.Run(ctx, cfg.UpdateCheck.IntervalDuration())
+	}
+
+	// Serve existing mirror trees to peers in place
+	for _, root := range cfg.Sources.LocalMirror {
+		go proxyServer.ServeLocalMirror(ctx, localmirror.New(root, logger))
+	}
+
+	// Keep copies of partner seeds' caches
+	if repl := cfg.Replication; repl.Enabled() {
+		go proxyServer.Replicate(ctx, proxy.ReplicationConfig{
+			Repos:         repl.Repos,
+			Architectures: repl.Architectures,
+			MaxSize:       repl.MaxSizeBytes(),
+			Concurrency:   repl.GetConcurrency(),
+			CatchUp:       repl.CatchUpDuration(),
+		})
+		logger.Info("Cache replication enabled", zap.Int("partners", len(repl.Partners)))
+	}
+
+	// Keep package groups cached for offline provisioning
+	prefetchStore, err := prefetch.OpenStore(filepath.Join(cfg.Cache.Path, prefetchStateFile))
+	if err != nil {
+		return fmt.Errorf("failed to load prefetch groups: %w", err)
+	}
+	proxyServer.EnablePrefetch(proxy.PrefetchConfig{
+		Groups:        cfg.Prefetch.GetGroups(),
+		Store:         prefetchStore,
+		Architectures: cfg.Prefetch.GetArchitectures(),
+		Dependencies:  cfg.Prefetch.DependenciesEnabled(),
+		Pin:           cfg.Prefetch.PinEnabled(),
+		Concurrency:   cfg.Prefetch.GetConcurrency(),
+		Interval:      cfg.Prefetch.IntervalDuration(),
+	})
+	go proxyServer.Prefetch(ctx)
+
 	// Start periodic tasks
-	go runPeriodicTasks(ctx, proxyServer, pkgCache, p2pNode, m, logger, cfg.DHT.AnnounceIntervalDuration())
+	go runPeriodicTasks(ctx, proxyServer, pkgCache, p2pNode, m, logger, cfg.DHT.AnnounceIntervalDuration(), timeoutsPath)
+	if interval := cfg.Cache.DiskPressureIntervalDuration(); interval > 0 {
+		go runDiskPressureWatcher(ctx, pkgCache, m, auditLogger, interval, cfg.Cache.DiskPressureHeadroomBytes(), logger)
+	}
 
 	// Start proxy server in goroutine
-	errChan := make(chan error, 1)
+	errChan := make(chan error, 2)
 	go func() {
 		if err := proxyServer.Start(); err != nil {
 			errChan <- err
 		}
 	}()
 
+	// reload applies config file changes, on SIGHUP or from the control API
+	var reloadMu sync.Mutex
+	reload := func() error {
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
+		before := activeCfg.Load()
+		err := reloadConfig(logger, rates, ioThrottle, pkgCache, proxyServer, fetcher, &activeCfg)
+		if err != nil {
+			auditLogger.Log(audit.NewConfigReloadEvent(nil, err.Error()))
+			return err
+		}
+		var changed []string
+		if changes, diffErr := config.Diff(before.Redacted(), activeCfg.Load().Redacted()); diffErr == nil {
+			for _, c := range changes {
+				changed = append(changed, c.Key)
+			}
+		}
+		auditLogger.Log(audit.NewConfigReloadEvent(changed, ""))
+		return nil
+	}
+
+	// Serve the gRPC control API on its Unix socket (opt-in)
+	if cfg.Control.Socket != "" {
+		controlCfg := proxy.ControlConfig{
+			Socket:      cfg.Control.Socket,
+			Group:       cfg.Control.Group,
+			AdminGroups: cfg.Control.AdminGroups,
+			Reload:      reload,
+		}
+		go func() {
+			if err := proxyServer.ServeControl(ctx, controlCfg); err != nil {
+				errChan <- fmt.Errorf("control API: %w", err)
+			}
+		}()
+	}
+
+	// Announce a proxy serving the LAN, for "debswarm client enable"
+	if cfg.ProxyAdvertiseEnabled() {
+		adv, err := lanproxy.Advertise(cfg.Network.ProxyBind, cfg.Network.ProxyPort, version)
+		if err != nil {
+			logger.Warn("Failed to advertise the proxy over mDNS", zap.Error(err))
+		} else {
+			defer adv.Close()
+			logger.Info("Advertising the proxy over mDNS", zap.String("service", lanproxy.ServiceName))
+		}
+	}
+
 	logger.Info("debswarm daemon started",
 		zap.String("peerID", p2pNode.PeerID().String()),
 		zap.String("proxyAddr", proxyCfg.Addr),
@@ -564,7 +1137,7 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		case sig := <-sigChan:
 			if sig == syscall.SIGHUP {
 				logger.Info("Received SIGHUP, reloading configuration")
-				if err := reloadConfig(logger, p2pNode, pkgCache); err != nil {
+				if err := reload(); err != nil {
 					logger.Error("Config reload failed", zap.Error(err))
 				} else {
 					logger.Info("Configuration reloaded successfully")
@@ -594,6 +1167,9 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 	if err := proxyServer.Shutdown(shutdownCtx); err != nil {
 		logger.Warn("Proxy shutdown error", zap.Error(err))
 	}
+	if err := tm.Save(timeoutsPath); err != nil {
+		logger.Warn("Failed to save learned timeouts", zap.Error(err))
+	}
 
 	logger.Info("Shutdown complete")
 	return nil
@@ -605,7 +1181,9 @@ type providerFinderAdapter struct {
 }
 
 func (a *providerFinderAdapter) FindProviders(ctx context.Context, sha256Hash string, limit int) ([]peer.AddrInfo, error) {
-	return a.node.FindProviders(ctx, sha256Hash, limit)
+	// Verification is a background check and yields to lookups for APT
+	// requests when the lookup budget is tight.
+	return a.node.FindProviders(p2p.WithPriority(ctx, p2p.PriorityLow), sha256Hash, limit)
 }
 
 func (a *providerFinderAdapter) ID() peer.ID {
@@ -620,41 +1198,278 @@ func runPeriodicTasks(
 	m *metrics.Metrics,
 	logger *zap.Logger,
 	announceInterval time.Duration,
+	timeoutsPath string,
 ) {
-	announceTicker := time.NewTicker(announceInterval)
+	// Reannouncement follows provider record expiry: it runs when the
+	// earliest record is about to expire, and at least every announceInterval
+	announceTimer := time.NewTimer(proxyServer.NextReannounce(announceInterval))
 	metricsTicker := time.NewTicker(30 * time.Second)
 	cleanupTicker := time.NewTicker(time.Hour)
-	defer announceTicker.Stop()
+	cacheFullTicker := time.NewTicker(5 * time.Minute)
+	defer announceTimer.Stop()
 	defer metricsTicker.Stop()
 	defer cleanupTicker.Stop()
+	defer cacheFullTicker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 
-		case <-announceTicker.C:
-			logger.Info("Running periodic reannouncement")
+		case <-announceTimer.C:
+			logger.Debug("Running periodic reannouncement")
 			if err := proxyServer.ReannouncePackages(ctx); err != nil {
 				logger.Warn("Reannouncement failed", zap.Error(err))
 			}
+			next := proxyServer.NextReannounce(announceInterval)
+			logger.Debug("Next reannouncement scheduled", zap.Duration("in", next))
+			announceTimer.Reset(next)
 
 		case <-metricsTicker.C:
 			// Update metrics
 			m.CacheSize.Set(float64(pkgCache.Size()))
 			m.CacheCount.Set(float64(pkgCache.Count()))
 			m.MetadataCacheSize.Set(float64(pkgCache.MetadataSize()))
+			m.MemoryTierSize.Set(float64(pkgCache.MemoryTierStats().Size))
 			m.ConnectedPeers.Set(float64(p2pNode.ConnectedPeers()))
 			m.RoutingTableSize.Set(float64(p2pNode.RoutingTableSize()))
 
+		case <-cacheFullTicker.C:
+			// Keep a full cache visible in the logs for as long as it lasts
+			proxyServer.LogCacheFullSummary()
+
 		case <-cleanupTicker.C:
 			// Purge failed/abandoned download state rows and orphaned partial
 			// directories; downloads past the retry window only leak disk.
 			proxyServer.CleanupDownloadState(24 * time.Hour)
+
+			// Checkpoint learned timeouts so a crash does not lose them
+			if err := p2pNode.Timeouts().Save(timeoutsPath); err != nil {
+				logger.Warn("Failed to save learned timeouts", zap.Error(err))
+			}
+		}
+	}
+}
+
+// runDiskPressureWatcher checks free disk space every interval and evicts
+// packages when other activity on the filesystem has pushed it below
+// cache.min_free_space, instead of waiting for the next cache write to fail.
+func runDiskPressureWatcher(
+	ctx context.Context,
+	pkgCache *cache.Cache,
+	m *metrics.Metrics,
+	auditLogger audit.Logger,
+	interval time.Duration,
+	headroom int64,
+	logger *zap.Logger,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		relief, err := pkgCache.RelieveDiskPressure(headroom)
+		if err != nil {
+			logger.Warn("Disk pressure check failed", zap.Error(err))
+			continue
+		}
+		if relief == nil {
+			m.CacheDiskPressure.Set(0)
+			continue
+		}
+
+		m.DiskPressureEvictions.Add(int64(relief.Evicted))
+		m.CacheSize.Set(float64(pkgCache.Size()))
+		m.CacheCount.Set(float64(pkgCache.Count()))
+		if relief.Satisfied() {
+			m.CacheDiskPressure.Set(0)
+		} else {
+			m.CacheDiskPressure.Set(1)
+		}
+
+		fields := []zap.Field{
+			zap.Int("evicted", relief.Evicted),
+			zap.String("freed", formatBytes(relief.Freed)),
+			zap.String("freeBefore", formatBytes(relief.FreeBefore)),
+			zap.String("freeAfter", formatBytes(relief.FreeAfter)),
+			zap.String("cacheSize", formatBytes(relief.SizeAfter)),
+		}
+		if !relief.Satisfied() {
+			logger.Warn("Free disk space still below minimum after evicting unpinned packages", fields...)
+		} else {
+			logger.Info("Evicted packages to relieve disk pressure", fields...)
+		}
+		if relief.ShrunkBelowMax() {
+			auditLogger.Log(audit.NewCacheDiskPressureEvent(relief.Evicted, relief.Freed, relief.SizeAfter,
+				fmt.Sprintf("free disk space %s below minimum; cache shrunk below max size %s",
+					formatBytes(relief.FreeBefore), formatBytes(relief.MaxSize))))
 		}
 	}
 }
 
+// runRevocationRefresh re-fetches the revocation list from url (when set) and
+// re-pushes the list in force to fleet peers every interval. The periodic push
+// lets a peer that joined after the last update catch up; receivers drop a
+// list that is not newer than theirs. It also retries the cache purge, which
+// skips packages that were being read at the time.
+func runRevocationRefresh(
+	ctx context.Context,
+	revocations *revocation.Manager,
+	gossip *revocation.Gossip,
+	proxyServer *proxy.Server,
+	p2pNode *p2p.Node,
+	url string,
+	interval time.Duration,
+	logger *zap.Logger,
+) {
+	client := httpclient.WithTimeout(30 * time.Second)
+	refresh := func() {
+		if url != "" {
+			if _, err := revocations.Fetch(ctx, client, url); err != nil && !errors.Is(err, revocation.ErrStaleSerial) {
+				logger.Warn("Failed to refresh revocation list", zap.String("url", url), zap.Error(err))
+			}
+		}
+		if list := revocations.Current(); list != nil {
+			proxyServer.PurgeRevoked(list)
+		}
+		if gossip != nil {
+			gossip.Push(ctx, mdnsPeerIDs(p2pNode))
+		}
+	}
+
+	refresh()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
+
+// mdnsPeerIDs returns the IDs of connected fleet (mDNS) peers.
+func mdnsPeerIDs(node *p2p.Node) []peer.ID {
+	infos := node.GetMDNSPeers()
+	ids := make([]peer.ID, 0, len(infos))
+	for _, info := range infos {
+		ids = append(ids, info.ID)
+	}
+	return ids
+}
+
+// classPolicies converts [proxy.classes] overrides into proxy class policies.
+// loadPeerLabels hands the stored peer labels to the scorer. Labels that no
+// longer parse are skipped rather than failing startup.
+func loadPeerLabels(c *cache.Cache, scorer *peers.Scorer, logger *zap.Logger) {
+	labels, err := c.PeerLabels()
+	if err != nil {
+		logger.Warn("Failed to load peer labels", zap.Error(err))
+		return
+	}
+	for _, l := range labels {
+		id, err := peer.Decode(l.PeerID)
+		if err != nil {
+			logger.Warn("Skipping label for invalid peer ID", zap.String("peer", l.PeerID))
+			continue
+		}
+		scorer.SetLabel(id, peers.Label{Name: l.Name, Tags: l.Tags})
+	}
+	if len(labels) > 0 {
+		logger.Debug("Loaded peer labels", zap.Int("count", len(labels)))
+	}
+}
+
+// loadPeerBans hands the stored peer bans to the scorer, and deletes the
+// records whose strikes were forgotten while the daemon was down.
+func loadPeerBans(c *cache.Cache, scorer *peers.Scorer, logger *zap.Logger) {
+	stored, err := c.PeerBans()
+	if err != nil {
+		logger.Warn("Failed to load peer bans", zap.Error(err))
+		return
+	}
+	restored := 0
+	for _, b := range stored {
+		id, err := peer.Decode(b.PeerID)
+		if err == nil && scorer.RestoreBan(peers.Ban{
+			PeerID:     id,
+			Strikes:    b.Strikes,
+			Reason:     b.Reason,
+			LastStrike: b.LastStrike,
+			Until:      b.Until,
+			Permanent:  b.Permanent,
+		}) {
+			restored++
+			continue
+		}
+		if err := c.SetPeerBan(cache.PeerBan{PeerID: b.PeerID}); err != nil {
+			logger.Warn("Failed to delete expired peer ban", zap.String("peer", b.PeerID), zap.Error(err))
+		}
+	}
+	if restored > 0 {
+		logger.Info("Restored peer bans", zap.Int("count", restored))
+	}
+}
+
+// listenConfigs converts the [[network.listen]] entries
+func listenConfigs(listen []config.ListenConfig) []p2p.ListenConfig {
+	if len(listen) == 0 {
+		return nil
+	}
+	result := make([]p2p.ListenConfig, len(listen))
+	for i, l := range listen {
+		result[i] = p2p.ListenConfig{Transport: l.Transport, Interface: l.Interface, Address: l.Address, Port: l.Port}
+	}
+	return result
+}
+
+func classPolicies(classes map[string]config.ArtifactClassConfig) map[string]proxy.ClassPolicy {
+	if len(classes) == 0 {
+		return nil
+	}
+	policies := make(map[string]proxy.ClassPolicy, len(classes))
+	for name, c := range classes {
+		policies[name] = proxy.ClassPolicy{Cache: c.IsCached(), Share: c.IsShared(name)}
+	}
+	return policies
+}
+
+// clientProfiles converts the [[clients]] entries, which Validate has
+// already checked, for the proxy.
+func clientProfiles(clients []config.ClientConfig) ([]proxy.ClientProfile, error) {
+	profiles := make([]proxy.ClientProfile, 0, len(clients))
+	for _, c := range clients {
+		nets, err := c.ParsedCIDRs()
+		if err != nil {
+			return nil, fmt.Errorf("client %s: %w", c.Name, err)
+		}
+		profiles = append(profiles, proxy.ClientProfile{
+			Name:     c.Name,
+			Networks: nets,
+			MaxRate:  c.MaxRateBytes(),
+			Policy:   c.Policy,
+		})
+	}
+	return profiles, nil
+}
+
+func classTTLs(classes map[string]config.ArtifactClassConfig) map[string]time.Duration {
+	ttls := make(map[string]time.Duration)
+	for name, c := range classes {
+		if ttl, ok := c.TTLDuration(); ok {
+			ttls[name] = ttl
+		}
+	}
+	return ttls
+}
+
 // runWatchdog feeds the systemd watchdog for as long as the daemon's HTTP
 // loop is actually responding. A deadlocked-but-alive daemon (the class of
 // bug where a bad server timeout hung apt-get update while the process kept
@@ -756,9 +1571,21 @@ func checkDirectory(path, name string) error {
 	return nil
 }
 
+// mirrorPolicy builds the policy deciding which upstream URLs and CONNECT
+// targets the proxy may reach: the allowed and trusted hosts, the hosts of
+// configured repositories, and the allowed address ranges and ports.
+func mirrorPolicy(cfg *config.Config) (*security.MirrorPolicy, error) {
+	return security.NewMirrorPolicy(security.PolicyConfig{
+		AllowedHosts: slices.Concat(cfg.Proxy.EffectiveAllowedHosts(), cfg.RepoHosts()),
+		AllowedCIDRs: cfg.Proxy.AllowedCIDRs,
+		AllowedPorts: cfg.Proxy.AllowedPorts,
+		AllowPrivate: !cfg.Proxy.DeniesPrivate(),
+	})
+}
+
 // reloadConfig reloads configuration that can be changed at runtime.
 // Some settings (ports, cache path) require a full restart.
-func reloadConfig(logger *zap.Logger, p2pNode *p2p.Node, pkgCache *cache.Cache) error {
+func reloadConfig(logger *zap.Logger, rates *linkRates, ioThrottle *iothrottle.Throttle, pkgCache *cache.Cache, proxyServer *proxy.Server, fetcher *mirror.Fetcher, active *atomic.Pointer[config.Config]) error {
 	// Load new configuration
 	newCfg, warnings, err := loadConfigWithWarnings()
 	if err != nil {
@@ -776,9 +1603,37 @@ func reloadConfig(logger *zap.Logger, p2pNode *p2p.Node, pkgCache *cache.Cache)
 	}
 
 	// Apply new rate limits to the running P2P node
-	newUploadRate := newCfg.Transfer.MaxUploadRateBytes()
-	newDownloadRate := newCfg.Transfer.MaxDownloadRateBytes()
-	p2pNode.UpdateRateLimits(newUploadRate, newDownloadRate)
+	rates.set(newCfg.Transfer.UploadRateLimit(), newCfg.Transfer.DownloadRateLimit())
+	applied := *active.Load()
+	applied.Transfer.MaxUploadRate = newCfg.Transfer.MaxUploadRate
+	applied.Transfer.MaxDownloadRate = newCfg.Transfer.MaxDownloadRate
+
+	// Apply the new disk I/O limits to background cache work
+	ioThrottle.SetLimits(ioThrottleLimits(&newCfg.Cache.IOThrottle))
+	applied.Cache.IOThrottle = newCfg.Cache.IOThrottle
+
+	// Apply the new upstream mirror policy (allowed hosts, ranges and ports),
+	// keeping a mirror pool received from the fleet leader
+	policy, err := mirrorPolicy(withAppliedSharedSettings(newCfg))
+	if err != nil {
+		return fmt.Errorf("invalid mirror policy: %w", err)
+	}
+	proxyServer.SetMirrorPolicy(policy)
+	fetcher.SetMirrorPolicy(policy)
+	applied.Proxy.AllowedHosts = newCfg.Proxy.AllowedHosts
+	applied.Proxy.TrustKnownRepos = newCfg.Proxy.TrustKnownRepos
+	applied.Proxy.AllowedCIDRs = newCfg.Proxy.AllowedCIDRs
+	applied.Proxy.AllowedPorts = newCfg.Proxy.AllowedPorts
+	applied.Proxy.DenyPrivate = newCfg.Proxy.DenyPrivate
+
+	// Apply the new client rate limits and policies
+	clients, err := clientProfiles(newCfg.Clients)
+	if err != nil {
+		return fmt.Errorf("invalid clients configuration: %w", err)
+	}
+	proxyServer.SetClientProfiles(clients)
+	applied.Clients = newCfg.Clients
+	active.Store(&applied)
 
 	// Check database integrity during reload
 	if err := pkgCache.CheckIntegrity(); err != nil {
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func debugCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "debug",
+		Short: "Inspect daemon internals for troubleshooting",
+	}
+
+	cmd.AddCommand(debugStateCmd())
+	cmd.AddCommand(debugBundleCmd())
+
+	return cmd
+}
+
+func debugStateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "state",
+		Short: "Dump timeouts, peer scores, rate limiters and queues as JSON",
+		Long: `Print the daemon's internal state as JSON, for working out why downloads
+are slow: the adaptive timeout for each operation, the per-peer transfer
+profiles behind chunk deadlines, every peer's score with its components,
+rate limiter buckets, and the announcement queue depth.
+
+The format is meant for people and bug reports and may change between
+releases. Requires metrics to be enabled; the request goes to the daemon's
+local API and is only answered on loopback.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Metrics.Port == 0 {
+				return fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+			}
+			endpoint := fmt.Sprintf("http://%s:%d/stats/debug", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port)
+			return fetchDebugState(&http.Client{Timeout: 5 * time.Second}, endpoint, os.Stdout)
+		},
+	}
+}
+
+// fetchDebugState fetches the /stats/debug JSON and writes it indented to out.
+func fetchDebugState(client *http.Client, endpoint string, out io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("daemon not running or metrics disabled: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("daemon refused request: %s", apiErr.Error)
+		}
+		return fmt.Errorf("unexpected status %d from daemon", resp.StatusCode)
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	_, err = indented.WriteTo(out)
+	return err
+}
+
+func debugBundleCmd() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Save a support bundle for attaching to bug reports",
+		Long: `Save a gzipped tarball of the daemon's state for attaching to a bug
+report. It holds:
+
+  info.json           versions, platform, memory and goroutine counts
+  goroutines.txt      stack traces of every goroutine
+  config.toml         the active configuration, secrets redacted
+  logs.txt            the most recent log output (up to 1MB)
+  state.json          the same data as 'debswarm debug state'
+  stats.json          transfer statistics
+  cache.json          cache statistics
+  peers.json          scored peers and circuit breakers
+  connections.json    live peer connections
+  routing_table.json  the DHT routing table
+
+Package names appear in the logs. Review the bundle before sharing it
+publicly. Requires metrics to be enabled; the request goes to the daemon's
+local API and is only answered on loopback.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Metrics.Port == 0 {
+				return fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+			}
+			if output == "" {
+				output = fmt.Sprintf("debswarm-bundle-%s.tar.gz", time.Now().Format("20060102-150405"))
+			}
+			endpoint := fmt.Sprintf("http://%s:%d/debug/bundle", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port)
+			if err := fetchDebugBundle(&http.Client{Timeout: 30 * time.Second}, endpoint, output); err != nil {
+				return err
+			}
+			fmt.Printf("Support bundle written to %s\n", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write (default: debswarm-bundle-<time>.tar.gz)")
+	return cmd
+}
+
+// fetchDebugBundle downloads the /debug/bundle tarball into path.
+func fetchDebugBundle(client *http.Client, endpoint, path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("daemon not running or metrics disabled: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("daemon refused request: %s", apiErr.Error)
+		}
+		return fmt.Errorf("unexpected status %d from daemon", resp.StatusCode)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return fmt.Errorf("failed to save bundle: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchDebugState(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stats/debug" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"timeouts":[{"operation":"dht_lookup","current_ms":100}],"announce_queue":{"depth":3,"capacity":100}}`))
+	}))
+	defer srv.Close()
+
+	var out strings.Builder
+	if err := fetchDebugState(srv.Client(), srv.URL+"/stats/debug", &out); err != nil {
+		t.Fatalf("fetchDebugState: %v", err)
+	}
+	if !strings.Contains(out.String(), "\n  \"announce_queue\": {\n    \"depth\": 3,") {
+		t.Errorf("output not indented JSON:\n%s", out.String())
+	}
+
+	refused := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error":"this endpoint is restricted to localhost"}`))
+	}))
+	defer refused.Close()
+	err := fetchDebugState(refused.Client(), refused.URL, &out)
+	if err == nil || !strings.Contains(err.Error(), "restricted to localhost") {
+		t.Errorf("refused request error = %v", err)
+	}
+}
+
+func TestFetchDebugBundle(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		_, _ = w.Write([]byte("tarball"))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := fetchDebugBundle(srv.Client(), srv.URL+"/debug/bundle", path); err != nil {
+		t.Fatalf("fetchDebugBundle: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "tarball" {
+		t.Errorf("saved %q, %v", data, err)
+	}
+
+	refused := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error":"this endpoint is restricted to localhost"}`))
+	}))
+	defer refused.Close()
+	other := filepath.Join(t.TempDir(), "refused.tar.gz")
+	err := fetchDebugBundle(refused.Client(), refused.URL, other)
+	if err == nil || !strings.Contains(err.Error(), "restricted to localhost") {
+		t.Errorf("refused request error = %v", err)
+	}
+	if _, err := os.Stat(other); err == nil {
+		t.Error("file created for a refused request")
+	}
+}
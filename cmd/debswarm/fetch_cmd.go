@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/aptlists"
+	"github.com/debswarm/debswarm/internal/downloader"
+	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/mirror"
+	"github.com/debswarm/debswarm/internal/p2p"
+	"github.com/debswarm/debswarm/internal/peers"
+	"github.com/debswarm/debswarm/internal/units"
+)
+
+// fetchTarget is what debswarm fetch downloads: a package known by hash, and
+// optionally its mirror URL and size
+type fetchTarget struct {
+	URL    string // empty when fetched by hash, which rules out the mirror
+	SHA256 string
+	Size   int64 // 0 when unknown
+	Name   string
+}
+
+func fetchCmd() *cobra.Command {
+	var (
+		output   string
+		indexes  []string
+		noMirror bool
+		timeout  units.DurationFlag
+		maxPeers int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "fetch <package-url|sha256>",
+		Short: "Download one package without a running daemon",
+		Long: `Download a single package from the swarm, falling back to the mirror,
+without a running daemon. An ephemeral node is started with a throwaway
+identity on a random port, finds providers, downloads, verifies the SHA256
+and exits. Nothing is cached or announced.
+
+Given a URL, the expected SHA256 and size come from APT's package lists
+(index.apt_lists_path), which APT verified against the signed Release, or
+from Packages files passed with --index. A URL found in neither is refused
+rather than downloaded unverified. Given a SHA256, the package is fetched
+from peers only, since there is no mirror URL to fall back to.
+
+The output defaults to the file name from the URL (or <sha256>.deb) in the
+current directory; use -o - to write to stdout.
+
+Examples:
+  debswarm fetch http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-3_amd64.deb
+  debswarm fetch 3f4a...e91c -o hello.deb
+  debswarm fetch --index ./Packages --no-mirror http://mirror.lan/debian/pool/main/h/hello/hello_2.10-3_amd64.deb`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout))
+			defer cancel()
+			sigChan := make(chan os.Signal, 1)
+			signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+			defer signal.Stop(sigChan)
+			go func() {
+				select {
+				case <-sigChan:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			return runFetch(ctx, args[0], output, indexes, noMirror, maxPeers)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file (default: name from the URL; - for stdout)")
+	cmd.Flags().StringArrayVar(&indexes, "index", nil, "Packages file to look the URL up in (repeatable; default: APT's lists)")
+	cmd.Flags().BoolVar(&noMirror, "no-mirror", false, "Download from peers only")
+	timeout = units.DurationFlag(5 * time.Minute)
+	cmd.Flags().Var(&timeout, "timeout", "Give up after this long")
+	cmd.Flags().IntVar(&maxPeers, "max-peers", 10, "Maximum number of providers to download from")
+	return cmd
+}
+
+func runFetch(ctx context.Context, arg, output string, indexes []string, noMirror bool, maxPeers int) error {
+	logger, err := setupLogger()
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer func() { _ = logger.Sync() }()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	var idx *index.Index
+	if !isSHA256Hex(arg) {
+		idx = index.New("", logger)
+		if len(indexes) > 0 {
+			for _, p := range indexes {
+				if err := idx.LoadFromFile(p); err != nil {
+					return fmt.Errorf("failed to load packages index %s: %w", p, err)
+				}
+			}
+		} else {
+			lists := aptlists.New(idx, logger, &aptlists.Config{ListsPath: cfg.Index.APTListsPath})
+			if _, err := lists.Load(); err != nil {
+				return fmt.Errorf("failed to load APT lists: %w", err)
+			}
+		}
+	}
+	target, err := resolveFetchTarget(arg, idx)
+	if err != nil {
+		return err
+	}
+	if noMirror {
+		target.URL = ""
+	}
+	if output == "" {
+		output = target.Name
+	}
+
+	var psk []byte
+	if cfg.Privacy.PSKPath != "" {
+		if psk, err = p2p.LoadPSK(cfg.Privacy.PSKPath); err != nil {
+			return fmt.Errorf("failed to load PSK: %w", err)
+		}
+	} else if cfg.Privacy.PSK != "" {
+		if psk, err = p2p.ParsePSKFromHex(cfg.Privacy.PSK); err != nil {
+			return fmt.Errorf("failed to parse inline PSK: %w", err)
+		}
+	}
+
+	// No DataDir: the node gets a fresh identity and leaves nothing behind.
+	// Port 0 keeps it clear of a daemon running on the same host.
+	scorer := peers.NewScorer()
+	node, err := p2p.New(ctx, &p2p.Config{
+		ListenPort:         0,
+		Version:            version,
+		BootstrapPeers:     cfg.Network.BootstrapAddrs(),
+		BootstrapMinPeers:  cfg.Network.GetBootstrapMinPeers(),
+		EnableMDNS:         cfg.Privacy.EnableMDNS,
+		PreferQUIC:         true,
+		PSK:                psk,
+		PeerAllowlist:      cfg.Privacy.PeerAllowlist,
+		PeerBlocklist:      cfg.Privacy.PeerBlocklist,
+		Scorer:             scorer,
+		EnableRelay:        cfg.Network.IsRelayEnabled(),
+		EnableHolePunching: cfg.Network.IsHolePunchingEnabled(),
+		DHTMode:            cfg.DHT.GetMode(),
+	}, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize P2P node: %w", err)
+	}
+	defer func() { _ = node.Close() }()
+
+	fmt.Fprintln(os.Stderr, "Waiting for DHT bootstrap...")
+	node.WaitForBootstrap()
+
+	providers, err := node.FindProvidersRanked(ctx, target.SHA256, maxPeers)
+	if err != nil {
+		logger.Debug("Provider lookup failed", zap.Error(err))
+	}
+	fmt.Fprintf(os.Stderr, "Found %d provider(s) for %s\n", len(providers), target.SHA256[:16])
+
+	fetcher, err := newMirrorFetcher(ctx, cfg, logger)
+	if err != nil {
+		return fmt.Errorf("failed to configure mirror requests: %w", err)
+	}
+	result, err := downloadFetchTarget(ctx, node, scorer, fetcher, target, providers)
+	if err != nil {
+		return err
+	}
+	if result.FilePath != "" {
+		defer func() { _ = os.RemoveAll(filepath.Dir(result.FilePath)) }()
+	}
+	if err := writeFetchResult(result, output); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Fetched %s (%s) in %s: %s from peers, %s from the mirror, SHA256 verified\n",
+		displayOutput(output), formatBytes(result.Size), result.Duration.Round(time.Millisecond),
+		formatBytes(result.PeerBytes), formatBytes(result.MirrorBytes))
+	return nil
+}
+
+// resolveFetchTarget turns the command argument into a target. A SHA256 is
+// taken as is; a URL must be found in idx so its hash is a signed one.
+func resolveFetchTarget(arg string, idx *index.Index) (fetchTarget, error) {
+	if isSHA256Hex(arg) {
+		h := strings.ToLower(arg)
+		return fetchTarget{SHA256: h, Name: h + ".deb"}, nil
+	}
+	u, err := url.Parse(arg)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fetchTarget{}, fmt.Errorf("%q is neither a package URL nor a SHA256", arg)
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." || !strings.HasSuffix(name, "deb") {
+		return fetchTarget{}, fmt.Errorf("%s is not a package URL", arg)
+	}
+	pkg := idx.GetByURLPath(arg)
+	if pkg == nil {
+		return fetchTarget{}, fmt.Errorf("%s is not in any loaded package index; run apt-get update, pass --index, or fetch by SHA256", arg)
+	}
+	return fetchTarget{URL: arg, SHA256: pkg.SHA256, Size: pkg.Size, Name: name}, nil
+}
+
+func downloadFetchTarget(ctx context.Context, node *p2p.Node, scorer *peers.Scorer, fetcher *mirror.Fetcher, target fetchTarget, providers []peer.AddrInfo) (*downloader.DownloadResult, error) {
+	peerSources := make([]downloader.Source, 0, len(providers))
+	for _, p := range providers {
+		peerSources = append(peerSources, &downloader.PeerSource{
+			Info: p,
+			Downloader: func(ctx context.Context, info peer.AddrInfo, hash string, start, end int64) ([]byte, error) {
+				return node.DownloadRange(ctx, info, hash, start, end)
+			},
+		})
+	}
+
+	var mirrorSource downloader.Source
+	if target.URL != "" {
+		mirrorSource = &downloader.MirrorSource{
+			URL: target.URL,
+			Fetcher: func(ctx context.Context, url string, start, end int64) ([]byte, error) {
+				// The downloader's end is exclusive, HTTP ranges are inclusive
+				if end > 0 {
+					end--
+				}
+				return fetcher.FetchRange(ctx, url, start, end)
+			},
+		}
+	}
+	if len(peerSources) == 0 && mirrorSource == nil {
+		return nil, fmt.Errorf("no peer provides %s and there is no mirror URL to fall back to", target.SHA256)
+	}
+
+	d := downloader.New(&downloader.Config{Scorer: scorer})
+	result, err := d.Download(ctx, target.SHA256, target.Size, peerSources, mirrorSource)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	return result, nil
+}
+
+// writeFetchResult writes a verified download to output, or stdout for "-".
+// A file is written under a temporary name and renamed, so an interrupted
+// fetch never leaves a partial package behind.
+func writeFetchResult(result *downloader.DownloadResult, output string) error {
+	var src io.Reader
+	if result.FilePath != "" {
+		f, err := os.Open(result.FilePath)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		src = f
+	} else {
+		src = bytes.NewReader(result.Data)
+	}
+
+	if output == "-" {
+		_, err := io.Copy(os.Stdout, src)
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(output), ".debswarm-fetch-*")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), output); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	return nil
+}
+
+func displayOutput(output string) string {
+	if output == "-" {
+		return "stdout"
+	}
+	return output
+}
+
+// isSHA256Hex reports whether s is a hex-encoded SHA256
+func isSHA256Hex(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range strings.ToLower(s) {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
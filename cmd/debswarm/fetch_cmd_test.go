@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/downloader"
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+func TestResolveFetchTarget(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	idx := index.New("", zap.NewNop())
+	packages := "Package: hello\nVersion: 2.10-3\nArchitecture: amd64\n" +
+		"Filename: pool/main/h/hello/hello_2.10-3_amd64.deb\nSize: 1234\nSHA256: " + hash + "\n"
+	if err := idx.LoadFromData([]byte(packages), "http://deb.debian.org/debian/dists/bookworm/main/binary-amd64/Packages"); err != nil {
+		t.Fatal(err)
+	}
+
+	const pkgURL = "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-3_amd64.deb"
+	got, err := resolveFetchTarget(pkgURL, idx)
+	if err != nil {
+		t.Fatalf("resolve URL: %v", err)
+	}
+	if got.SHA256 != hash || got.Size != 1234 || got.URL != pkgURL || got.Name != "hello_2.10-3_amd64.deb" {
+		t.Errorf("URL target = %+v", got)
+	}
+
+	got, err = resolveFetchTarget(strings.ToUpper(hash), nil)
+	if err != nil {
+		t.Fatalf("resolve hash: %v", err)
+	}
+	if got.SHA256 != hash || got.URL != "" || got.Name != hash+".deb" {
+		t.Errorf("hash target = %+v", got)
+	}
+
+	for _, arg := range []string{
+		"http://deb.debian.org/debian/pool/main/o/other/other_1.0_amd64.deb", // not in the index
+		"http://deb.debian.org/debian/dists/bookworm/InRelease",              // not a package
+		"ftp://deb.debian.org/debian/pool/main/h/hello/hello_2.10-3_amd64.deb",
+		"hello",
+		hash[:63],
+	} {
+		if _, err := resolveFetchTarget(arg, idx); err == nil {
+			t.Errorf("resolveFetchTarget(%q) accepted", arg)
+		}
+	}
+}
+
+func TestWriteFetchResult(t *testing.T) {
+	dir := t.TempDir()
+
+	out := filepath.Join(dir, "from-memory.deb")
+	if err := writeFetchResult(&downloader.DownloadResult{Data: []byte("package")}, out); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "package" {
+		t.Errorf("output = %q", data)
+	}
+
+	src := filepath.Join(dir, "assembled")
+	if err := os.WriteFile(src, []byte("assembled package"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out = filepath.Join(dir, "from-file.deb")
+	if err := writeFetchResult(&downloader.DownloadResult{FilePath: src}, out); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "assembled package" {
+		t.Errorf("output = %q", data)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".debswarm-fetch-") {
+			t.Errorf("temporary file %s left behind", e.Name())
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/config"
+	"github.com/debswarm/debswarm/internal/fleet"
+	"github.com/debswarm/debswarm/internal/mirror"
+	"github.com/debswarm/debswarm/internal/p2p"
+	"github.com/debswarm/debswarm/internal/proxy"
+	"github.com/debswarm/debswarm/internal/scheduler"
+)
+
+// fleetSharedStateFile holds the last settings applied from the fleet leader,
+// so a follower restarts with them instead of its local values.
+const fleetSharedStateFile = "fleet-shared.json"
+
+// schedulerStateFile holds the exceptions and one-off windows added with
+// 'debswarm scheduler'.
+const schedulerStateFile = "scheduler.json"
+
+// prefetchStateFile holds the package groups added with 'debswarm prefetch',
+// and the packages pinned for groups.
+const prefetchStateFile = "prefetch.json"
+
+// schedulerConfig converts the [scheduler] section for the scheduler package.
+func schedulerConfig(sc *config.SchedulerConfig) *scheduler.Config {
+	windows := make([]scheduler.Window, 0, len(sc.Windows))
+	for _, w := range sc.Windows {
+		windows = append(windows, scheduler.Window{
+			Days:      w.Days,
+			StartTime: w.StartTime,
+			EndTime:   w.EndTime,
+		})
+	}
+	exceptions := make([]scheduler.Exception, 0, len(sc.Exceptions))
+	for _, e := range sc.Exceptions {
+		exceptions = append(exceptions, scheduler.Exception{
+			Name: e.Name,
+			From: e.From,
+			To:   e.To,
+		})
+	}
+	return &scheduler.Config{
+		Enabled:           sc.Enabled,
+		Exceptions:        exceptions,
+		Windows:           windows,
+		Timezone:          sc.Timezone,
+		OutsideWindowRate: sc.OutsideWindowRateBytes(),
+		InsideWindowRate:  sc.InsideWindowRateBytes(),
+		UrgentFullSpeed:   sc.IsUrgentFullSpeed(),
+	}
+}
+
+// overlaySharedSettings returns a copy of cfg with the leader's settings
+// applied on top. Sections the leader does not send keep their local values.
+func overlaySharedSettings(cfg *config.Config, s *fleet.SharedSettings) *config.Config {
+	out := *cfg
+	if sc := s.Scheduler; sc != nil {
+		out.Scheduler.Enabled = true
+		out.Scheduler.Windows = make([]config.ScheduleWindow, 0, len(sc.Windows))
+		for _, w := range sc.Windows {
+			out.Scheduler.Windows = append(out.Scheduler.Windows, config.ScheduleWindow{
+				Days:      w.Days,
+				StartTime: w.StartTime,
+				EndTime:   w.EndTime,
+			})
+		}
+		out.Scheduler.Timezone = sc.Timezone
+		out.Scheduler.OutsideWindowRate = sc.OutsideWindowRate
+		out.Scheduler.InsideWindowRate = sc.InsideWindowRate
+	}
+	if r := s.Retention; r != nil {
+		if r.MaxSize != "" {
+			out.Cache.MaxSize = r.MaxSize
+		}
+		if r.MinFreeSpace != "" {
+			out.Cache.MinFreeSpace = r.MinFreeSpace
+		}
+	}
+	if m := s.Mirrors; m != nil {
+		out.Proxy.AllowedHosts = m.AllowedHosts
+		out.Proxy.AllowedCIDRs = m.AllowedCIDRs
+	}
+	return &out
+}
+
+// loadAppliedSharedSettings reads the settings persisted by the last apply.
+// A missing or unreadable file yields nil: the node runs on its local config
+// until the leader sends settings again.
+func loadAppliedSharedSettings(cachePath string) *fleet.SharedSettings {
+	data, err := os.ReadFile(filepath.Join(cachePath, fleetSharedStateFile))
+	if err != nil {
+		return nil
+	}
+	var s fleet.SharedSettings
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil
+	}
+	return &s
+}
+
+// withAppliedSharedSettings returns cfg with the settings last received from
+// the fleet leader applied, or cfg itself when the node does not follow a
+// leader or has nothing valid to apply.
+func withAppliedSharedSettings(cfg *config.Config) *config.Config {
+	if !cfg.Fleet.Enabled || !cfg.Fleet.Shared.Accept {
+		return cfg
+	}
+	shared := loadAppliedSharedSettings(cfg.Cache.Path)
+	if shared == nil {
+		return cfg
+	}
+	overlaid := overlaySharedSettings(cfg, shared)
+	if overlaid.Validate() != nil {
+		return cfg
+	}
+	return overlaid
+}
+
+func saveAppliedSharedSettings(cachePath string, s *fleet.SharedSettings) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(cachePath, fleetSharedStateFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// startFleetShared joins fleet leader election and, when this node accepts
+// shared settings, applies the leader's to the running scheduler, cache and
+// mirror policy.
+func startFleetShared(
+	ctx context.Context,
+	cfg *config.Config,
+	p2pNode *p2p.Node,
+	sched *scheduler.Scheduler,
+	sizer *cacheSizer,
+	proxyServer *proxy.Server,
+	fetcher *mirror.Fetcher,
+	logger *zap.Logger,
+) (*fleet.Shared, error) {
+	sc := cfg.Fleet.Shared
+	sharedCfg := fleet.SharedConfig{
+		Accept:   sc.Accept,
+		Interval: sc.AnnounceIntervalDuration(),
+	}
+	if sc.SettingsPath != "" {
+		settings, err := fleet.LoadSharedSettings(sc.SettingsPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load fleet shared settings: %w", err)
+		}
+		if err := overlaySharedSettings(cfg, settings).Validate(); err != nil {
+			return nil, fmt.Errorf("invalid fleet shared settings in %s: %w", sc.SettingsPath, err)
+		}
+		sharedCfg.Settings = settings
+	}
+	for _, id := range sc.TrustedLeaders {
+		pid, err := peer.Decode(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fleet.shared.trusted_leaders entry %q: %w", id, err)
+		}
+		sharedCfg.TrustedLeaders = append(sharedCfg.TrustedLeaders, pid)
+	}
+
+	shared := fleet.NewShared(p2pNode.Host(), p2pNode, sharedCfg, logger)
+	shared.SetOnApply(func(from peer.ID, s *fleet.SharedSettings) {
+		applied := overlaySharedSettings(cfg, s)
+		if err := applied.Validate(); err != nil {
+			logger.Warn("Rejected invalid settings from fleet leader",
+				zap.String("leader", from.String()), zap.Error(err))
+			return
+		}
+		if s.Scheduler != nil {
+			if sched != nil {
+				sched.Update(schedulerConfig(&applied.Scheduler))
+			} else {
+				logger.Info("Fleet leader enables the scheduler; takes effect after restart")
+			}
+		}
+		if s.Retention != nil {
+			sizer.set(applied.Cache.MaxSizeLimit(), applied.Cache.MinFreeSpaceBytes())
+		}
+		if s.Mirrors != nil {
+			policy, err := mirrorPolicy(applied)
+			if err != nil {
+				logger.Warn("Rejected mirror pool from fleet leader",
+					zap.String("leader", from.String()), zap.Error(err))
+				return
+			}
+			proxyServer.SetMirrorPolicy(policy)
+			fetcher.SetMirrorPolicy(policy)
+		}
+		if err := saveAppliedSharedSettings(cfg.Cache.Path, s); err != nil {
+			logger.Warn("Failed to persist fleet shared settings", zap.Error(err))
+		}
+	})
+	go shared.Run(ctx)
+
+	logger.Info("Fleet leader election enabled",
+		zap.Bool("candidate", sharedCfg.Settings != nil),
+		zap.Bool("acceptSettings", sc.Accept),
+		zap.Int("trustedLeaders", len(sharedCfg.TrustedLeaders)))
+	return shared, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/config"
+	"github.com/debswarm/debswarm/internal/fleet"
+)
+
+// TestOverlaySharedSettings verifies that the leader's sections replace the
+// local ones without touching the original config or unsent sections.
+func TestOverlaySharedSettings(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Cache.MaxSize = "10GB"
+	cfg.Cache.MinFreeSpace = "1GB"
+	cfg.Proxy.AllowedHosts = []string{"local.example"}
+
+	shared := &fleet.SharedSettings{
+		Scheduler: &fleet.SharedScheduler{
+			Windows:           []fleet.SharedWindow{{Days: []string{"weekday"}, StartTime: "22:00", EndTime: "06:00"}},
+			Timezone:          "UTC",
+			OutsideWindowRate: "100KB/s",
+		},
+		Retention: &fleet.SharedRetention{MaxSize: "50GB"},
+		Mirrors: &fleet.SharedMirrors{
+			AllowedHosts: []string{"mirror.lab.example"},
+			AllowedCIDRs: []string{"10.0.0.0/8"},
+		},
+	}
+	got := overlaySharedSettings(cfg, shared)
+
+	if !got.Scheduler.Enabled || len(got.Scheduler.Windows) != 1 || got.Scheduler.Timezone != "UTC" {
+		t.Errorf("scheduler not overlaid: %+v", got.Scheduler)
+	}
+	if got.Cache.MaxSize != "50GB" || got.Cache.MinFreeSpace != "1GB" {
+		t.Errorf("cache = %q/%q, want 50GB/1GB", got.Cache.MaxSize, got.Cache.MinFreeSpace)
+	}
+	if !slices.Equal(got.Proxy.AllowedHosts, []string{"mirror.lab.example"}) ||
+		!slices.Equal(got.Proxy.AllowedCIDRs, []string{"10.0.0.0/8"}) {
+		t.Errorf("mirror pool = %v/%v, want the leader's", got.Proxy.AllowedHosts, got.Proxy.AllowedCIDRs)
+	}
+	if cfg.Scheduler.Enabled || cfg.Cache.MaxSize != "10GB" || cfg.Proxy.AllowedHosts[0] != "local.example" {
+		t.Error("overlay modified the original config")
+	}
+	if err := got.Validate(); err != nil {
+		t.Errorf("overlaid config invalid: %v", err)
+	}
+}
+
+func TestAppliedSharedSettingsRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	if loadAppliedSharedSettings(dir) != nil {
+		t.Fatal("expected nil before anything was saved")
+	}
+	want := &fleet.SharedSettings{Retention: &fleet.SharedRetention{MaxSize: "20GB"}}
+	if err := saveAppliedSharedSettings(dir, want); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got := loadAppliedSharedSettings(dir)
+	if got == nil || got.Digest() != want.Digest() {
+		t.Fatalf("loaded %+v, want %+v", got, want)
+	}
+}
+
+// TestWithAppliedSharedSettings verifies that a follower, at startup and on
+// reload, keeps the mirror pool it last received from the leader.
+func TestWithAppliedSharedSettings(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Cache.Path = t.TempDir()
+	cfg.Fleet.Enabled = true
+	shared := &fleet.SharedSettings{Mirrors: &fleet.SharedMirrors{AllowedHosts: []string{"mirror.lab.example"}}}
+	if err := saveAppliedSharedSettings(cfg.Cache.Path, shared); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	if got := withAppliedSharedSettings(cfg); got != cfg {
+		t.Error("settings applied on a node that does not accept them")
+	}
+	cfg.Fleet.Shared.Accept = true
+	got := withAppliedSharedSettings(cfg)
+	if !slices.Equal(got.Proxy.AllowedHosts, []string{"mirror.lab.example"}) {
+		t.Errorf("allowed hosts = %v, want the leader's", got.Proxy.AllowedHosts)
+	}
+}
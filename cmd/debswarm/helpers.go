@@ -9,6 +9,7 @@ import (
 	"go.uber.org/zap/zapcore"
 
 	"github.com/debswarm/debswarm/internal/config"
+	"github.com/debswarm/debswarm/internal/iothrottle"
 )
 
 // setupLogger creates a configured zap logger based on global flags.
@@ -78,6 +79,12 @@ func loadConfigWithWarnings() (*config.Config, []config.SecurityWarning, error)
 	return config.DefaultConfig(), nil, nil
 }
 
+// ioThrottleLimits returns the caps on background cache I/O set in
+// [cache.io_throttle]
+func ioThrottleLimits(c *config.IOThrottleConfig) iothrottle.Limits {
+	return iothrottle.Limits{BytesPerSec: c.MaxRateBytes(), IOPS: c.MaxIOPS}
+}
+
 // formatBytes formats a byte count as a human-readable string.
 func formatBytes(b int64) string {
 	const unit = 1024
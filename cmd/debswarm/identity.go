@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -19,11 +21,19 @@ func identityCmd() *cobra.Command {
 
 The identity key determines your peer ID in the P2P network. By default,
 debswarm generates a new ephemeral identity on each start. When a data
-directory is configured, the identity is persisted for stable peer IDs.`,
+directory is configured, the identity is persisted for stable peer IDs.
+
+The key can be backed up with "export" and restored on a replacement
+machine with "import", keeping the peer ID that allowlists refer to. Nodes
+whose peer ID must be bound to hardware can instead keep the key in a
+PKCS#11 token or TPM behind privacy.identity_signer; such a key cannot be
+exported.`,
 	}
 
 	cmd.AddCommand(identityShowCmd())
 	cmd.AddCommand(identityRegenerateCmd())
+	cmd.AddCommand(identityExportCmd())
+	cmd.AddCommand(identityImportCmd())
 
 	return cmd
 }
@@ -39,14 +49,26 @@ func identityShowCmd() *cobra.Command {
 				return err
 			}
 
+			fmt.Printf("Node Identity\n")
+			fmt.Printf("══════════════════════════════════════\n")
+
+			if cfg.Privacy.IdentitySigner != "" {
+				signer, err := p2p.NewExternalSigner(cfg.Privacy.IdentitySigner)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Peer ID:     %s\n", p2p.IdentityFingerprint(signer))
+				fmt.Printf("Signer:      %s\n", signer.Program())
+				fmt.Printf("Key Type:    %s (hardware-backed)\n", signer.Type())
+				fmt.Printf("\nThe private key stays on the device and cannot be exported.\n")
+				return nil
+			}
+
 			// Determine data directory using same logic as daemon
 			identityDir := resolveDataDir(cfg)
 
 			keyPath := filepath.Join(identityDir, p2p.IdentityKeyFile)
 
-			fmt.Printf("Node Identity\n")
-			fmt.Printf("══════════════════════════════════════\n")
-
 			// Check if identity file exists
 			if _, statErr := os.Stat(keyPath); os.IsNotExist(statErr) {
 				fmt.Printf("Status:      No persistent identity\n")
@@ -66,7 +88,7 @@ func identityShowCmd() *cobra.Command {
 
 			fmt.Printf("Peer ID:     %s\n", peerID)
 			fmt.Printf("Key File:    %s\n", keyPath)
-			fmt.Printf("Key Type:    Ed25519\n")
+			fmt.Printf("Key Type:    %s\n", privKey.Type())
 			fmt.Printf("\nThis peer ID is stable across daemon restarts.\n")
 			fmt.Printf("Share it with others to add to their peer allowlists.\n")
 
@@ -139,6 +161,165 @@ need to be updated.`,
 	return cmd
 }
 
+// passphraseEnv supplies the identity backup passphrase when no
+// --passphrase-file is given
+const passphraseEnv = "DEBSWARM_IDENTITY_PASSPHRASE"
+
+func identityExportCmd() *cobra.Command {
+	var output, passphraseFile string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write an encrypted backup of the identity key",
+		Long: `Write the identity key encrypted with a passphrase, so it can be restored
+with "debswarm identity import" and the node keeps its peer ID.
+
+The passphrase is read from --passphrase-file ("-" for stdin) or the
+DEBSWARM_IDENTITY_PASSPHRASE environment variable. The backup names the
+peer ID in clear; the key itself is sealed with AES-256-GCM under a
+PBKDF2-SHA256 derived key.`,
+		Example: `  debswarm identity export --passphrase-file /root/pass -o seed1-identity.pem`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Privacy.IdentitySigner != "" {
+				return fmt.Errorf("%w (privacy.identity_signer is set)", p2p.ErrKeyNotExportable)
+			}
+
+			keyPath := filepath.Join(resolveDataDir(cfg), p2p.IdentityKeyFile)
+			privKey, err := p2p.LoadIdentity(keyPath)
+			if err != nil {
+				return fmt.Errorf("failed to load identity: %w", err)
+			}
+			passphrase, err := readPassphrase(passphraseFile)
+			if err != nil {
+				return err
+			}
+			data, err := p2p.ExportIdentity(privKey, passphrase)
+			if err != nil {
+				return err
+			}
+
+			if output == "" || output == "-" {
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+			f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+			if err != nil {
+				return fmt.Errorf("failed to create backup: %w", err)
+			}
+			if _, err := f.Write(data); err != nil {
+				_ = f.Close()
+				return fmt.Errorf("failed to write backup: %w", err)
+			}
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("failed to write backup: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "Exported identity %s to %s\n", p2p.IdentityFingerprint(privKey), output)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Backup file to create (default stdout)")
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "File holding the passphrase, or - for stdin")
+
+	return cmd
+}
+
+func identityImportCmd() *cobra.Command {
+	var passphraseFile string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Restore the identity key from an encrypted backup",
+		Long: `Restore an identity key written by "debswarm identity export", replacing
+this node's peer ID with the one in the backup. Restart the daemon to use
+it.
+
+Do not run the same identity on two nodes at once: peers would see one
+peer ID flapping between two addresses.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to read backup: %w", err)
+			}
+			passphrase, err := readPassphrase(passphraseFile)
+			if err != nil {
+				return err
+			}
+			privKey, err := p2p.ImportIdentity(data, passphrase)
+			if err != nil {
+				return err
+			}
+
+			identityDir := resolveDataDir(cfg)
+			keyPath := filepath.Join(identityDir, p2p.IdentityKeyFile)
+			if current, loadErr := p2p.LoadIdentity(keyPath); loadErr == nil && !force {
+				if current.Equals(privKey) {
+					fmt.Printf("Identity %s is already in use\n", p2p.IdentityFingerprint(privKey))
+					return nil
+				}
+				fmt.Printf("Current Peer ID: %s\n\n", p2p.IdentityFingerprint(current))
+				return fmt.Errorf("identity file exists at %s\n\nUse --force to replace it (this will change your peer ID)", keyPath)
+			}
+
+			if mkdirErr := os.MkdirAll(identityDir, 0700); mkdirErr != nil {
+				return fmt.Errorf("failed to create identity directory: %w", mkdirErr)
+			}
+			if err := p2p.SaveIdentity(privKey, keyPath); err != nil {
+				return fmt.Errorf("failed to save identity: %w", err)
+			}
+
+			fmt.Printf("Identity Imported\n")
+			fmt.Printf("══════════════════════════════════════\n")
+			fmt.Printf("Peer ID:     %s\n", p2p.IdentityFingerprint(privKey))
+			fmt.Printf("Key File:    %s\n", keyPath)
+			if cfg.Privacy.IdentitySigner != "" {
+				fmt.Printf("\nNote: privacy.identity_signer is set, so the daemon will keep using\n")
+				fmt.Printf("the hardware-backed identity until it is removed.\n")
+			} else {
+				fmt.Printf("\nRestart the daemon to use this identity.\n")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "File holding the passphrase, or - for stdin")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace an existing identity")
+
+	return cmd
+}
+
+// readPassphrase reads the backup passphrase from path ("-" for stdin) or,
+// with no path, from the environment. Only the first line is used.
+func readPassphrase(path string) ([]byte, error) {
+	var data []byte
+	var err error
+	switch path {
+	case "":
+		data = []byte(os.Getenv(passphraseEnv))
+		if len(data) == 0 {
+			return nil, fmt.Errorf("no passphrase: use --passphrase-file or set %s", passphraseEnv)
+		}
+	case "-":
+		data, err = io.ReadAll(os.Stdin)
+	default:
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r")), nil
+}
+
 // resolveDataDir determines the data directory using the same logic as the daemon.
 // Priority: --data-dir flag > STATE_DIRECTORY env > /var/lib/debswarm > ~/.local/share/debswarm
 func resolveDataDir(cfg *config.Config) string {
@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// fileInode returns 0 on Windows, where os.FileInfo carries no file ID; the
+// import journal then relies on size and modification time alone.
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/units"
+)
+
+// linkRateInterval is how often rate limits relative to the link are
+// re-resolved against the measured link throughput
+const linkRateInterval = time.Minute
+
+// linkRates applies the global upload and download limits, which may be a
+// percentage of the link ("30%link"). Those are resolved against the
+// fastest mirror transfer seen so far and stay unlimited until one has been
+// measured.
+type linkRates struct {
+	apply   func(upload, download int64)
+	measure func() int64
+	logger  *zap.Logger
+
+	mu       sync.Mutex
+	upload   units.Rate
+	download units.Rate
+	link     int64
+}
+
+// set replaces the configured limits and applies them.
+func (l *linkRates) set(upload, download units.Rate) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.upload, l.download = upload, download
+	l.apply(upload.Resolve(l.link), download.Resolve(l.link))
+}
+
+// resolved returns the limits in bytes/sec for the current baseline.
+func (l *linkRates) resolved() (upload, download int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.upload.Resolve(l.link), l.download.Resolve(l.link)
+}
+
+// refresh re-resolves link-relative limits if the measured link throughput
+// changed.
+func (l *linkRates) refresh() {
+	link := l.measure()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if link == l.link || (!l.upload.LinkRelative() && !l.download.LinkRelative()) {
+		return
+	}
+	l.link = link
+	l.logger.Info("Link throughput measured, adjusting rate limits",
+		zap.String("link", units.FormatSize(link)+"/s"),
+		zap.String("upload", l.upload.String()),
+		zap.String("download", l.download.String()))
+	l.apply(l.upload.Resolve(link), l.download.Resolve(link))
+}
+
+// run refreshes the limits periodically until ctx is done.
+func (l *linkRates) run(ctx context.Context) {
+	ticker := time.NewTicker(linkRateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.refresh()
+		}
+	}
+}
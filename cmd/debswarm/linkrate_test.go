@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/units"
+)
+
+func TestLinkRates(t *testing.T) {
+	var link, up, down int64
+	l := &linkRates{
+		apply:   func(u, d int64) { up, down = u, d },
+		measure: func() int64 { return link },
+		logger:  zap.NewNop(),
+	}
+	l.set(units.Rate{LinkPercent: 50}, units.Rate{BytesPerSec: 1000})
+	if up != 0 || down != 1000 {
+		t.Fatalf("before measuring: up %d down %d, want unlimited and 1000", up, down)
+	}
+
+	link = 10000
+	l.refresh()
+	if up != 5000 || down != 1000 {
+		t.Errorf("after measuring: up %d down %d, want 5000 and 1000", up, down)
+	}
+	if u, d := l.resolved(); u != 5000 || d != 1000 {
+		t.Errorf("resolved = %d %d", u, d)
+	}
+
+	// Fixed limits are left alone however the link changes
+	l.set(units.Rate{BytesPerSec: 2000}, units.Rate{})
+	up = -1
+	link = 20000
+	l.refresh()
+	if up != -1 {
+		t.Errorf("fixed limits re-applied on a link change: %d", up)
+	}
+}
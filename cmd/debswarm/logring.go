@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// logRingSize is how much recent log output the daemon keeps for support
+// bundles
+const logRingSize = 1 << 20
+
+// logRing keeps the most recent log entries, up to a total size, for
+// 'debswarm debug bundle'. Whole entries are dropped from the front when it
+// is full.
+type logRing struct {
+	mu      sync.Mutex
+	max     int
+	size    int
+	entries [][]byte
+}
+
+func newLogRing(max int) *logRing {
+	return &logRing{max: max}
+}
+
+// Write stores one encoded entry; zap writes each entry in a single call.
+func (r *logRing) Write(p []byte) (int, error) {
+	entry := append([]byte(nil), p...)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+	r.size += len(entry)
+	for r.size > r.max && len(r.entries) > 1 {
+		r.size -= len(r.entries[0])
+		r.entries[0] = nil
+		r.entries = r.entries[1:]
+	}
+	return len(p), nil
+}
+
+// Sync implements zapcore.WriteSyncer
+func (r *logRing) Sync() error { return nil }
+
+// Bytes returns the retained entries, oldest first
+func (r *logRing) Bytes() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]byte, 0, r.size)
+	for _, e := range r.entries {
+		out = append(out, e...)
+	}
+	return out
+}
+
+// tee returns logger also writing to the ring, at the logger's level
+func (r *logRing) tee(logger *zap.Logger) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		enc := zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+		return zapcore.NewTee(core, zapcore.NewCore(enc, r, core))
+	}))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogRing(t *testing.T) {
+	r := newLogRing(64)
+	for _, line := range []string{"first entry\n", "second entry\n", "third entry\n", "fourth entry\n", "fifth entry\n", "sixth entry\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := string(r.Bytes())
+	if len(got) > 64 || strings.Contains(got, "first") || !strings.HasSuffix(got, "sixth entry\n") {
+		t.Errorf("ring = %q", got)
+	}
+
+	// An entry larger than the ring is kept rather than dropped
+	big := strings.Repeat("x", 100) + "\n"
+	_, _ = r.Write([]byte(big))
+	if string(r.Bytes()) != big {
+		t.Errorf("oversized entry not kept alone")
+	}
+}
+
+func TestLogRing_Tee(t *testing.T) {
+	r := newLogRing(logRingSize)
+	base := zap.New(zapcore.NewCore(zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()), zapcore.AddSync(io.Discard), zap.InfoLevel))
+	logger := r.tee(base)
+	logger.Info("Starting debswarm daemon", zap.Int("proxyPort", 9977))
+	logger.Debug("below the configured level")
+	got := string(r.Bytes())
+	if !strings.Contains(got, "Starting debswarm daemon") || !strings.Contains(got, "9977") || strings.Contains(got, "below") {
+		t.Errorf("ring = %q", got)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+
+	"github.com/debswarm/debswarm/internal/units"
 )
 
 var (
@@ -23,8 +25,8 @@ var (
 	metricsPort     int
 	metricsBind     string
 	preferQUIC      bool
-	maxUploadRate   string
-	maxDownloadRate string
+	maxUploadRate   units.RateFlag
+	maxDownloadRate units.RateFlag
 )
 
 func main() {
@@ -54,13 +56,25 @@ Features:
 	rootCmd.AddCommand(statusCmd())
 	rootCmd.AddCommand(cacheCmd())
 	rootCmd.AddCommand(peersCmd())
+	rootCmd.AddCommand(aptCmd())
+	rootCmd.AddCommand(p2pCmd())
+	rootCmd.AddCommand(debugCmd())
 	rootCmd.AddCommand(configCmd())
 	rootCmd.AddCommand(seedCmd())
+	rootCmd.AddCommand(fetchCmd())
+	rootCmd.AddCommand(observeCmd())
+	rootCmd.AddCommand(buildCmd())
 	rootCmd.AddCommand(pskCmd())
 	rootCmd.AddCommand(identityCmd())
 	rootCmd.AddCommand(benchmarkCmd())
 	rootCmd.AddCommand(rollbackCmd())
 	rootCmd.AddCommand(statsCmd())
+	rootCmd.AddCommand(schedulerCmd())
+	rootCmd.AddCommand(prefetchCmd())
+	rootCmd.AddCommand(offlineCmd())
+	rootCmd.AddCommand(simulateCmd())
+	rootCmd.AddCommand(clientCmd())
+	rootCmd.AddCommand(repoCmd())
 	rootCmd.AddCommand(versionCmd())
 
 	if err := rootCmd.Execute(); err != nil {
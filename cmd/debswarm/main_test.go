@@ -2,12 +2,15 @@ package main
 
 import (
 	"bytes"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 
+	toml "github.com/pelletier/go-toml/v2"
 	"github.com/spf13/cobra"
 
 	"github.com/debswarm/debswarm/internal/config"
@@ -116,6 +119,58 @@ func TestConfigShowCommand(t *testing.T) {
 	}
 }
 
+func TestConfigValidateCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfgPath := filepath.Join(tmpDir, "test-config.toml")
+
+	rootCmd := newRootCmd()
+	rootCmd.SetArgs([]string{"--config", cfgPath, "config", "init"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("config init failed: %v", err)
+	}
+
+	// A generated config must validate cleanly
+	rootCmd = newRootCmd()
+	rootCmd.SetArgs([]string{"--config", cfgPath, "config", "validate"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("config validate failed on a generated config: %v", err)
+	}
+
+	badPath := filepath.Join(tmpDir, "bad.toml")
+	if err := os.WriteFile(badPath, []byte("[cache]\nmax_size = \"huge\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	rootCmd = newRootCmd()
+	rootCmd.SetOut(new(bytes.Buffer))
+	rootCmd.SetErr(new(bytes.Buffer))
+	rootCmd.SetArgs([]string{"config", "validate", "--file", badPath})
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("config validate should fail on an invalid max_size")
+	}
+}
+
+func TestFetchActiveConfig(t *testing.T) {
+	want := config.DefaultConfig()
+	want.Cache.MaxSize = "42GB"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := toml.Marshal(want.Redacted())
+		if err != nil {
+			t.Errorf("marshal: %v", err)
+		}
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	got, err := fetchActiveConfig(srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatalf("fetchActiveConfig: %v", err)
+	}
+	changes, err := config.Diff(got, want)
+	if err != nil || len(changes) != 0 {
+		t.Errorf("round-tripped config differs: %+v, %v", changes, err)
+	}
+}
+
 func TestCacheCommand_Help(t *testing.T) {
 	rootCmd := newRootCmd()
 	buf := new(bytes.Buffer)
@@ -157,6 +212,12 @@ func TestSeedCommand_Help(t *testing.T) {
 	if !strings.Contains(output, "list") {
 		t.Error("seed help should list 'list' subcommand")
 	}
+	if !strings.Contains(output, "export") {
+		t.Error("seed help should list 'export' subcommand")
+	}
+	if !strings.Contains(output, "announce") {
+		t.Error("seed help should list 'announce' subcommand")
+	}
 }
 
 func TestPskCommand_Help(t *testing.T) {
@@ -197,6 +258,11 @@ func TestIdentityCommand_Help(t *testing.T) {
 	if !strings.Contains(output, "regenerate") {
 		t.Error("identity help should list 'regenerate' subcommand")
 	}
+	for _, sub := range []string{"export", "import"} {
+		if !strings.Contains(output, sub) {
+			t.Errorf("identity help should list '%s' subcommand", sub)
+		}
+	}
 }
 
 func TestPskGenerateCommand(t *testing.T) {
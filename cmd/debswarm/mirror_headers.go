@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/config"
+	"github.com/debswarm/debswarm/internal/mirror"
+	"github.com/debswarm/debswarm/internal/secret"
+)
+
+// newMirrorFetcher creates the mirror fetcher with the configured
+// User-Agent, per-host headers and repository rate limits. The secrets the
+// headers refer to are read now; a missing one is an error.
+func newMirrorFetcher(ctx context.Context, cfg *config.Config, logger *zap.Logger) (*mirror.Fetcher, error) {
+	fcfg := mirror.DefaultConfig()
+	fcfg.UserAgent = cfg.Mirror.GetUserAgent()
+	fetcher := mirror.NewFetcher(fcfg, logger)
+
+	resolver := secret.NewResolver()
+	for i, origin := range cfg.Mirror.Origins {
+		header, err := originHeader(ctx, resolver, origin)
+		if err != nil {
+			return nil, fmt.Errorf("mirror.origins[%d]: %w", i, err)
+		}
+		fetcher.SetHostHeader(origin.Hosts, header)
+	}
+	for _, repo := range cfg.Repos {
+		fetcher.SetHostRate(repo.Hosts, repo.MaxDownloadRateBytes())
+	}
+	return fetcher, nil
+}
+
+// originHeader expands an origin's header templates and credentials into
+// the headers its requests carry.
+func originHeader(ctx context.Context, resolver *secret.Resolver, origin config.MirrorOriginConfig) (http.Header, error) {
+	header := make(http.Header, len(origin.Headers)+1)
+	for name, template := range origin.Headers {
+		value, err := resolver.Expand(ctx, template)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", name, err)
+		}
+		header.Set(name, value)
+	}
+	if origin.Username != "" {
+		username, err := resolver.Expand(ctx, origin.Username)
+		if err != nil {
+			return nil, fmt.Errorf("username: %w", err)
+		}
+		password, err := resolver.Expand(ctx, origin.Password)
+		if err != nil {
+			return nil, fmt.Errorf("password: %w", err)
+		}
+		auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		header.Set("Authorization", "Basic "+auth)
+	}
+	return header, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/config"
+	"github.com/debswarm/debswarm/internal/secret"
+)
+
+func TestOriginHeader(t *testing.T) {
+	t.Setenv("DEBSWARM_TEST_MIRROR_TOKEN", "s3cret")
+	origin := config.MirrorOriginConfig{
+		Hosts:    []string{"mirror.corp.example"},
+		Headers:  map[string]string{"x-auth-token": "${env:DEBSWARM_TEST_MIRROR_TOKEN}", "X-Traffic-Class": "bulk"},
+		Username: "apt",
+		Password: "${env:DEBSWARM_TEST_MIRROR_TOKEN}",
+	}
+	header, err := originHeader(context.Background(), secret.NewResolver(), origin)
+	if err != nil {
+		t.Fatalf("originHeader: %v", err)
+	}
+	if header.Get("X-Auth-Token") != "s3cret" || header.Get("X-Traffic-Class") != "bulk" {
+		t.Errorf("headers = %v", header)
+	}
+	if header.Get("Authorization") != "Basic YXB0OnMzY3JldA==" {
+		t.Errorf("Authorization = %q, want basic auth for apt:s3cret", header.Get("Authorization"))
+	}
+
+	origin.Headers["X-Auth-Token"] = "${env:DEBSWARM_TEST_MISSING}"
+	if _, err := originHeader(context.Background(), secret.NewResolver(), origin); !errors.Is(err, secret.ErrNotFound) {
+		t.Errorf("missing secret: err = %v", err)
+	}
+}
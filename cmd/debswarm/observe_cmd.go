@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/aptlists"
+	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/observer"
+	"github.com/debswarm/debswarm/internal/p2p"
+	"github.com/debswarm/debswarm/internal/peers"
+	"github.com/debswarm/debswarm/internal/units"
+)
+
+func observeCmd() *cobra.Command {
+	var (
+		sample       int
+		packages     []string
+		indexes      []string
+		interval     units.DurationFlag
+		listen       string
+		once         bool
+		jsonOutput   bool
+		maxProviders int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "observe",
+		Short: "Measure swarm health without participating",
+		Long: `Join the swarm as an observer to measure its health before deploying
+debswarm actively. An ephemeral node is started with a throwaway identity on a
+random port, as a DHT client that announces, caches and serves nothing and
+relays for no one. Every --interval it reports:
+
+  - how many peers provide each of a sample of popular packages
+  - how many peers it is connected to, has in its routing table and found
+    by mDNS
+  - which debswarm versions the connected peers run
+
+The sample is the --sample packages most depended on in APT's package lists
+(index.apt_lists_path) or the Packages files passed with --index, or the
+packages named with --package. Provider counts are capped at --max-providers.
+
+The latest report is served as JSON at http://<listen>/report.
+
+Examples:
+  debswarm observe
+  debswarm observe --once --json --sample 100
+  debswarm observe --package libc6 --package openssl --listen 0.0.0.0:9979`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if sample < 1 && len(packages) == 0 {
+				return fmt.Errorf("--sample must be at least 1")
+			}
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+			ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer cancel()
+			return runObserve(ctx, observeOptions{
+				sample:       sample,
+				packages:     packages,
+				indexes:      indexes,
+				interval:     time.Duration(interval),
+				listen:       listen,
+				once:         once,
+				jsonOutput:   jsonOutput,
+				maxProviders: maxProviders,
+			})
+		},
+	}
+
+	cmd.Flags().IntVar(&sample, "sample", 50, "Number of popular packages to count providers of")
+	cmd.Flags().StringArrayVar(&packages, "package", nil, "Package to count providers of instead of popular ones (repeatable)")
+	cmd.Flags().StringArrayVar(&indexes, "index", nil, "Packages file to pick packages from (repeatable; default: APT's lists)")
+	interval = units.DurationFlag(10 * time.Minute)
+	cmd.Flags().Var(&interval, "interval", "Time between observation rounds")
+	cmd.Flags().StringVar(&listen, "listen", "127.0.0.1:9979", "Address to serve the report on (empty to disable)")
+	cmd.Flags().BoolVar(&once, "once", false, "Take one round, print it and exit")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print reports as JSON")
+	cmd.Flags().IntVar(&maxProviders, "max-providers", observer.DefaultMaxProviders, "Maximum providers counted per package")
+	return cmd
+}
+
+type observeOptions struct {
+	sample       int
+	packages     []string
+	indexes      []string
+	interval     time.Duration
+	listen       string
+	once         bool
+	jsonOutput   bool
+	maxProviders int
+}
+
+func runObserve(ctx context.Context, opts observeOptions) error {
+	logger, err := setupLogger()
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer func() { _ = logger.Sync() }()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	idx := index.New("", logger)
+	if len(opts.indexes) > 0 {
+		for _, p := range opts.indexes {
+			if err := idx.LoadFromFile(p); err != nil {
+				return fmt.Errorf("failed to load packages index %s: %w", p, err)
+			}
+		}
+	} else {
+		lists := aptlists.New(idx, logger, &aptlists.Config{ListsPath: cfg.Index.APTListsPath})
+		if _, err := lists.Load(); err != nil {
+			return fmt.Errorf("failed to load APT lists: %w", err)
+		}
+	}
+	var samples []observer.Sample
+	if len(opts.packages) > 0 {
+		var missing []string
+		samples, missing = observer.Named(idx.Packages(), opts.packages)
+		if len(missing) > 0 {
+			return fmt.Errorf("not in the package index: %s", strings.Join(missing, ", "))
+		}
+	} else {
+		samples = observer.Popular(idx.Packages(), opts.sample)
+	}
+	if len(samples) == 0 {
+		return fmt.Errorf("the package index is empty; pass Packages files with --index")
+	}
+
+	var psk []byte
+	if cfg.Privacy.PSKPath != "" {
+		if psk, err = p2p.LoadPSK(cfg.Privacy.PSKPath); err != nil {
+			return fmt.Errorf("failed to load PSK: %w", err)
+		}
+	} else if cfg.Privacy.PSK != "" {
+		if psk, err = p2p.ParsePSKFromHex(cfg.Privacy.PSK); err != nil {
+			return fmt.Errorf("failed to parse inline PSK: %w", err)
+		}
+	}
+
+	// No DataDir and port 0, as for fetch. As a DHT client with no content
+	// provider and the relay service off, the node stores no records,
+	// answers every block request as not available and relays for no one.
+	node, err := p2p.New(ctx, &p2p.Config{
+		ListenPort:         0,
+		Version:            version,
+		BootstrapPeers:     cfg.Network.BootstrapAddrs(),
+		BootstrapMinPeers:  cfg.Network.GetBootstrapMinPeers(),
+		EnableMDNS:         cfg.Privacy.EnableMDNS,
+		PreferQUIC:         true,
+		PSK:                psk,
+		PeerAllowlist:      cfg.Privacy.PeerAllowlist,
+		PeerBlocklist:      cfg.Privacy.PeerBlocklist,
+		Scorer:             peers.NewScorer(),
+		EnableRelay:        cfg.Network.IsRelayEnabled(),
+		EnableHolePunching: cfg.Network.IsHolePunchingEnabled(),
+		RelayService:       p2p.RelayServiceOff,
+		DHTMode:            "client",
+	}, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize P2P node: %w", err)
+	}
+	defer func() { _ = node.Close() }()
+
+	obs := observer.New(node, observer.Config{Samples: samples, MaxProviders: opts.maxProviders}, logger)
+
+	if opts.listen != "" && !opts.once {
+		ln, err := net.Listen("tcp", opts.listen)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", opts.listen, err)
+		}
+		srv := &http.Server{Handler: obs.Handler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("Report server failed", zap.Error(err))
+			}
+		}()
+		defer func() { _ = srv.Close() }()
+		fmt.Fprintf(os.Stderr, "Serving reports at http://%s/report\n", ln.Addr())
+	}
+
+	fmt.Fprintf(os.Stderr, "Observing %d package(s); waiting for DHT bootstrap...\n", len(samples))
+	node.WaitForBootstrap()
+
+	show := func(r *observer.Report) {
+		if opts.jsonOutput {
+			_ = printJSON(r)
+			return
+		}
+		printObserverReport(r)
+	}
+	if opts.once {
+		show(obs.Observe(ctx))
+		return nil
+	}
+	obs.Run(ctx, opts.interval, show)
+	return nil
+}
+
+func printObserverReport(r *observer.Report) {
+	fmt.Printf("Round %d at %s (took %s)\n", r.Round, r.Time, r.Duration)
+	fmt.Printf("  Peers:     %d connected, %d in routing table, %d by mDNS\n", r.ConnectedPeers, r.RoutingTable, r.MDNSPeers)
+	fmt.Printf("  Versions:  %s\n", formatVersionCounts(r.Versions))
+	fmt.Printf("  Packages:  %d of %d with providers, median %d provider(s)\n", r.Available, r.Sampled, r.MedianProviders)
+	for _, p := range r.Packages {
+		count := fmt.Sprintf("%d", p.Providers)
+		if p.Providers >= r.MaxProviders {
+			count += "+"
+		}
+		if p.Error != "" {
+			count = "error: " + p.Error
+		}
+		fmt.Printf("    %-32s %-8s %s\n", p.Package+":"+p.Architecture, count, p.Version)
+	}
+	fmt.Println()
+}
+
+// formatVersionCounts lists version counts, most common first
+func formatVersionCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "none"
+	}
+	versions := make([]string, 0, len(counts))
+	for v := range counts {
+		versions = append(versions, v)
+	}
+	slices.SortFunc(versions, func(a, b string) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(a, b)
+	})
+	parts := make([]string, len(versions))
+	for i, v := range versions {
+		parts[i] = fmt.Sprintf("%s (%d)", v, counts[v])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,13 @@
+package main
+
+import "testing"
+
+func TestFormatVersionCounts(t *testing.T) {
+	if got := formatVersionCounts(nil); got != "none" {
+		t.Errorf("nil = %q, want none", got)
+	}
+	got := formatVersionCounts(map[string]int{"1.39.0": 2, "unknown": 2, "1.40.0": 5})
+	if want := "1.40.0 (5), 1.39.0 (2), unknown (2)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/debswarm/debswarm/internal/prefetch"
+	"github.com/debswarm/debswarm/internal/proxy"
+)
+
+func offlineCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "offline",
+		Short: "Check what can be installed without the mirror",
+		Long: `Commands for offline mode (network.connectivity_mode = "offline"), in which
+the daemon never contacts a mirror and serves packages from its cache and
+peers only.
+
+Requires the daemon to be running with metrics enabled.`,
+	}
+
+	cmd.AddCommand(offlineCheckCmd())
+	return cmd
+}
+
+func offlineCheckCmd() *cobra.Command {
+	var (
+		archs      []string
+		noDeps     bool
+		jsonOutput bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "check PACKAGE...",
+		Short: "Report which packages could be installed fully offline",
+		Long: `Resolve each package against the daemon's package indices, with its
+dependencies, and report whether every resulting .deb is cached here or
+provided by a peer right now. Exits non-zero if any package could not be
+installed offline.
+
+Dependencies no indexed package satisfies, such as virtual packages, are
+listed but do not fail the check: they may already be installed on the
+target machine. Works whether or not the daemon is in offline mode.
+
+Examples:
+  debswarm offline check build-essential git
+  debswarm offline check --arch arm64 --no-deps linux-image-arm64`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := (prefetch.Group{Name: "check", Packages: args}).Validate(); err != nil {
+				return err
+			}
+			base, err := offlineAPIURL()
+			if err != nil {
+				return err
+			}
+			body, err := json.Marshal(proxy.OfflineCheckRequest{Packages: args, Architectures: archs, NoDependencies: noDeps})
+			if err != nil {
+				return err
+			}
+			var check proxy.OfflineCheck
+			// Provider lookups can take a while for a long dependency list
+			if err := peerLabelRequest(&http.Client{Timeout: 2 * time.Minute}, http.MethodPost, base+"/check", body, &check); err != nil {
+				return err
+			}
+			if jsonOutput {
+				if err := printJSON(check); err != nil {
+					return err
+				}
+			} else {
+				printOfflineCheck(os.Stdout, &check)
+			}
+			for _, p := range check.Packages {
+				if !p.Installable {
+					return fmt.Errorf("not every package can be installed offline")
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&archs, "arch", nil, "Architectures to resolve for (default: the host's)")
+	cmd.Flags().BoolVar(&noDeps, "no-deps", false, "Check the named packages only, not their dependencies")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	return cmd
+}
+
+// offlineAPIURL returns the local offline API URL
+func offlineAPIURL() (string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	if cfg.Metrics.Port == 0 {
+		return "", fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+	}
+	return fmt.Sprintf("http://%s:%d/api/offline", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port), nil
+}
+
+func printOfflineCheck(w io.Writer, check *proxy.OfflineCheck) {
+	if !check.Offline {
+		fmt.Fprintln(w, "Note: the daemon is not in offline mode; the mirror is still used for anything missing")
+	}
+	for _, p := range check.Packages {
+		verdict := "yes"
+		if !p.Installable {
+			verdict = "NO"
+		}
+		fmt.Fprintf(w, "%-30s  %-3s  %d/%d available (%d cached, %d from peers)\n",
+			p.Name, verdict, p.Cached+p.Peers, p.Total, p.Cached, p.Peers)
+		if len(p.Missing) > 0 {
+			fmt.Fprintf(w, "      not indexed: %s\n", strings.Join(p.Missing, " "))
+		}
+		if len(p.Unavailable) > 0 {
+			fmt.Fprintf(w, "      unavailable: %s\n", strings.Join(p.Unavailable, " "))
+		}
+		if len(p.Unresolved) > 0 {
+			fmt.Fprintf(w, "      unresolved:  %s\n", strings.Join(p.Unresolved, ", "))
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/proxy"
+)
+
+func TestOfflineCheck_Validates(t *testing.T) {
+	cmd := offlineCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"check", "Git"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "invalid package name") {
+		t.Errorf("err = %v, want an invalid package name", err)
+	}
+}
+
+func TestPrintOfflineCheck(t *testing.T) {
+	check := &proxy.OfflineCheck{
+		Offline: true,
+		Packages: []proxy.OfflinePackageCheck{
+			{Name: "make", Installable: true, Total: 1, Cached: 1},
+			{
+				Name: "git", Total: 3, Cached: 1, Peers: 1,
+				Unavailable: []string{"git-man_2.39_all.deb"}, Unresolved: []string{"perl | perl-base"},
+			},
+		},
+	}
+	var buf bytes.Buffer
+	printOfflineCheck(&buf, check)
+	out := buf.String()
+	for _, want := range []string{"make", "1/1 available", "NO", "2/3 available (1 cached, 1 from peers)", "unavailable: git-man_2.39_all.deb", "unresolved:  perl | perl-base"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "not in offline mode") {
+		t.Error("offline node reported as online")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package main
+
+// matchOwner is a no-op on Windows, where files inherit access from their
+// directory.
+func matchOwner(path, dir string) error {
+	return nil
+}
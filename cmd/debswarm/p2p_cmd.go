@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// p2pState matches the JSON from the /api/p2p endpoints.
+type p2pState struct {
+	Paused bool   `json:"paused"`
+	Since  string `json:"since"`
+	Reason string `json:"reason"`
+}
+
+func p2pCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "p2p",
+		Short: "Pause or resume P2P participation",
+		Long: `Pause or resume the daemon's participation in the swarm without restarting it.
+
+While paused the daemon serves no uploads (running ones are cut off), makes
+no announcements, and starts no P2P downloads; package requests are served
+from the cache or the mirror. Peer connections stay open so resuming is
+immediate. The pause is not persisted: a restarted daemon participates again.
+
+Requires metrics to be enabled, as the requests go to the daemon's local API.`,
+	}
+
+	cmd.AddCommand(p2pPauseCmd())
+	cmd.AddCommand(p2pResumeCmd())
+	cmd.AddCommand(p2pStatusCmd())
+
+	return cmd
+}
+
+func p2pPauseCmd() *cobra.Command {
+	var reason string
+
+	cmd := &cobra.Command{
+		Use:   "pause",
+		Short: "Stop uploads, announcements and P2P downloads",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			endpoint, err := p2pAPIURL("pause")
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				endpoint += "?reason=" + url.QueryEscape(reason)
+			}
+			state, err := p2pControl(&http.Client{Timeout: 5 * time.Second}, http.MethodPost, endpoint)
+			if err != nil {
+				return err
+			}
+			printP2PState(state)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&reason, "reason", "", "Reason recorded in the log and audit log")
+	return cmd
+}
+
+func p2pResumeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "resume",
+		Short: "Resume P2P participation",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			endpoint, err := p2pAPIURL("resume")
+			if err != nil {
+				return err
+			}
+			state, err := p2pControl(&http.Client{Timeout: 5 * time.Second}, http.MethodPost, endpoint)
+			if err != nil {
+				return err
+			}
+			printP2PState(state)
+			return nil
+		},
+	}
+}
+
+func p2pStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show whether P2P participation is paused",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			endpoint, err := p2pAPIURL("")
+			if err != nil {
+				return err
+			}
+			state, err := p2pControl(&http.Client{Timeout: 5 * time.Second}, http.MethodGet, endpoint)
+			if err != nil {
+				return err
+			}
+			printP2PState(state)
+			return nil
+		},
+	}
+}
+
+// p2pAPIURL returns the local API URL for action ("" for the state itself).
+func p2pAPIURL(action string) (string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	if cfg.Metrics.Port == 0 {
+		return "", fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+	}
+	endpoint := fmt.Sprintf("http://%s:%d/api/p2p", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port)
+	if action != "" {
+		endpoint += "/" + action
+	}
+	return endpoint, nil
+}
+
+// p2pControl sends a request to one of the /api/p2p endpoints and returns
+// the resulting state.
+func p2pControl(client *http.Client, method, endpoint string) (*p2pState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("daemon not running or metrics disabled: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return nil, fmt.Errorf("daemon refused request: %s", apiErr.Error)
+		}
+		return nil, fmt.Errorf("unexpected status %d from daemon", resp.StatusCode)
+	}
+
+	var state p2pState
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &state, nil
+}
+
+func printP2PState(state *p2pState) {
+	if !state.Paused {
+		fmt.Println("P2P participation: active")
+		return
+	}
+	fmt.Printf("P2P participation: paused since %s\n", state.Since)
+	if state.Reason != "" {
+		fmt.Printf("Reason: %s\n", state.Reason)
+	}
+	fmt.Println("Packages are served from the cache and the mirror only.")
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestP2PControl(t *testing.T) {
+	var gotMethod, gotPath, gotReason string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotReason = r.Method, r.URL.Path, r.URL.Query().Get("reason")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"paused":true,"since":"2026-03-01T11:00:00Z","reason":"metered link"}`))
+	}))
+	defer srv.Close()
+
+	state, err := p2pControl(srv.Client(), http.MethodPost, srv.URL+"/api/p2p/pause?reason=metered+link")
+	if err != nil {
+		t.Fatalf("p2pControl: %v", err)
+	}
+	if gotMethod != http.MethodPost || gotPath != "/api/p2p/pause" || gotReason != "metered link" {
+		t.Errorf("request = %s %s reason=%q", gotMethod, gotPath, gotReason)
+	}
+	if !state.Paused || state.Reason != "metered link" || state.Since == "" {
+		t.Errorf("state = %+v", state)
+	}
+
+	refused := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error":"P2P node is not running"}`))
+	}))
+	defer refused.Close()
+	if _, err := p2pControl(refused.Client(), http.MethodGet, refused.URL); err == nil {
+		t.Error("expected error for non-200 response")
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/debswarm/debswarm/internal/units"
+)
+
+// peerAccountingResponse matches one entry of the /api/peers/accounting JSON.
+type peerAccountingResponse struct {
+	PeerID     string `json:"peer_id"`
+	Uploaded   int64  `json:"uploaded"`
+	Downloaded int64  `json:"downloaded"`
+	FirstDay   string `json:"first_day"`
+	LastDay    string `json:"last_day"`
+	ActiveDays int    `json:"active_days"`
+}
+
+func peersAccountingCmd() *cobra.Command {
+	var (
+		since  string
+		output string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "accounting",
+		Short: "Show bytes exchanged with each peer over time",
+		Long: `Show how many bytes this node sent to and received from each peer,
+from the daemon's persistent transfer ledger (daily totals, kept for about
+13 months).
+
+RATIO is received/sent: what a peer gave back for each byte it took. Peers
+that take a lot and give nothing have a ratio near 0.
+
+--since accepts a number of days ("30d"), a duration ("72h"), or a date
+("2026-01-01"). Days are UTC. Requires the daemon to be running with
+metrics enabled.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, err := parseSince(since, time.Now())
+			if err != nil {
+				return err
+			}
+			switch output {
+			case "table", "csv", "json":
+			default:
+				return fmt.Errorf("invalid --output %q (want table, csv, or json)", output)
+			}
+
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Metrics.Port == 0 {
+				return fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+			}
+
+			endpoint := fmt.Sprintf("http://%s:%d/api/peers/accounting?since=%s",
+				loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port, url.QueryEscape(from.UTC().Format(time.DateOnly)))
+			client := &http.Client{Timeout: 10 * time.Second}
+			list, raw, err := fetchPeerAccounting(client, endpoint)
+			if err != nil {
+				return err
+			}
+
+			switch output {
+			case "json":
+				fmt.Println(string(raw))
+				return nil
+			case "csv":
+				return writePeerAccountingCSV(os.Stdout, list)
+			}
+			printPeerAccounting(list, from)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "30d", "Start of the period (e.g. 30d, 72h, 2026-01-01)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table, csv, or json")
+	return cmd
+}
+
+// parseSince turns a --since value into the start of the period. Whole days
+// are calendar days; other durations ("2w", "1d12h") are exact.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("invalid --since %q", s)
+		}
+		return now.AddDate(0, 0, -n), nil
+	}
+	if d, err := units.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (want e.g. 30d, 2w, 72h, or 2026-01-01)", s)
+}
+
+func fetchPeerAccounting(client *http.Client, endpoint string) ([]peerAccountingResponse, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("daemon not running or metrics disabled: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status %d from daemon", resp.StatusCode)
+	}
+
+	var list []peerAccountingResponse
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse accounting: %w", err)
+	}
+	return list, body, nil
+}
+
+// shareRatio is received/sent, or "" when nothing was sent to the peer.
+func shareRatio(p peerAccountingResponse) string {
+	if p.Uploaded == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(float64(p.Downloaded)/float64(p.Uploaded), 'f', 2, 64)
+}
+
+func writePeerAccountingCSV(w io.Writer, list []peerAccountingResponse) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"peer_id", "sent_bytes", "received_bytes", "ratio", "first_day", "last_day", "active_days"})
+	for _, p := range list {
+		_ = cw.Write([]string{
+			p.PeerID,
+			strconv.FormatInt(p.Uploaded, 10),
+			strconv.FormatInt(p.Downloaded, 10),
+			shareRatio(p),
+			p.FirstDay,
+			p.LastDay,
+			strconv.Itoa(p.ActiveDays),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func printPeerAccounting(list []peerAccountingResponse, since time.Time) {
+	var sent, received int64
+	for _, p := range list {
+		sent += p.Uploaded
+		received += p.Downloaded
+	}
+	fmt.Printf("Peer transfers since %s (UTC): %d peers, sent %s, received %s\n\n",
+		since.UTC().Format(time.DateOnly), len(list), formatBytes(sent), formatBytes(received))
+	if len(list) == 0 {
+		return
+	}
+
+	fmt.Printf(" %-16s  %10s  %10s  %6s  %4s  %s\n", "PEER", "SENT", "RECEIVED", "RATIO", "DAYS", "LAST SEEN")
+	for _, p := range list {
+		id := p.PeerID
+		if len(id) > 16 {
+			id = id[:6] + "..." + id[len(id)-7:]
+		}
+		ratio := shareRatio(p)
+		if ratio == "" {
+			ratio = "-"
+		}
+		fmt.Printf(" %-16s  %10s  %10s  %6s  %4d  %s\n",
+			id, formatBytes(p.Uploaded), formatBytes(p.Downloaded), ratio, p.ActiveDays, p.LastDay)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"30d", time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{"72h", time.Date(2026, 3, 28, 12, 0, 0, 0, time.UTC)},
+		{"2w", time.Date(2026, 3, 17, 12, 0, 0, 0, time.UTC)},
+		{"1d12h", time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC)},
+		{"2026-01-15", time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseSince(tt.in, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseSince(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "xd", "-5d", "yesterday"} {
+		if _, err := parseSince(bad, now); err == nil {
+			t.Errorf("parseSince(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestWritePeerAccountingCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := writePeerAccountingCSV(&buf, []peerAccountingResponse{
+		{PeerID: "12D3KooWA", Uploaded: 4000, Downloaded: 1000, FirstDay: "2026-03-01", LastDay: "2026-03-30", ActiveDays: 12},
+		{PeerID: "12D3KooWB", Downloaded: 500, FirstDay: "2026-03-02", LastDay: "2026-03-02", ActiveDays: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "peer_id,sent_bytes,received_bytes,ratio,first_day,last_day,active_days\n" +
+		"12D3KooWA,4000,1000,0.25,2026-03-01,2026-03-30,12\n" +
+		"12D3KooWB,0,500,,2026-03-02,2026-03-02,1\n"
+	if buf.String() != want {
+		t.Errorf("csv =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
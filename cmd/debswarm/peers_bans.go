@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/spf13/cobra"
+)
+
+// peerBanResponse matches one entry of the /api/peers/bans JSON.
+type peerBanResponse struct {
+	PeerID     string `json:"peer_id"`
+	Name       string `json:"name"`
+	Strikes    int    `json:"strikes"`
+	Reason     string `json:"reason"`
+	LastStrike string `json:"last_strike"`
+	Until      string `json:"until"`
+	Permanent  bool   `json:"permanent"`
+	Active     bool   `json:"active"`
+}
+
+func peersBansCmd() *cobra.Command {
+	var jsonOutput bool
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "bans",
+		Short: "List banned peers",
+		Long: `List the peers banned for serving data that failed verification.
+
+Each offense is a strike. The first bans the peer for transfer.bans.duration
+(24h by default), each further one multiplier times longer, and the
+permanent_after-th (the 5th by default) for good. Strikes are forgotten
+strike_expiry after the last one once its ban is over. Bans are stored in the
+cache database and survive restarts.
+
+With --all, peers whose ban is over but whose strikes are still counted are
+listed too. Use 'debswarm peers bans pardon' to lift a ban.
+
+Requires the daemon to be running with metrics enabled.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := peersAPIBase()
+			if err != nil {
+				return err
+			}
+			var bans []peerBanResponse
+			client := &http.Client{Timeout: 5 * time.Second}
+			if err := peerLabelRequest(client, http.MethodGet, base+"/bans", nil, &bans); err != nil {
+				return err
+			}
+			if !all {
+				active := bans[:0]
+				for _, b := range bans {
+					if b.Active {
+						active = append(active, b)
+					}
+				}
+				bans = active
+			}
+			if jsonOutput {
+				return printJSON(bans)
+			}
+			printPeerBans(bans, time.Now())
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output JSON")
+	cmd.Flags().BoolVar(&all, "all", false, "Also list peers whose ban is over but whose strikes are kept")
+	cmd.AddCommand(peersPardonCmd())
+	return cmd
+}
+
+func peersPardonCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "pardon <peer>",
+		Short: "Lift a peer's ban",
+		Long: `Lift a peer's ban, even a permanent one, and forget its strikes, so a
+later offense starts over at the first strike.
+
+The peer can be given by ID, by the start or end of the ID of a banned peer,
+or by its label name. Requires the daemon to be running with metrics enabled.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := peersAPIBase()
+			if err != nil {
+				return err
+			}
+			client := &http.Client{Timeout: 5 * time.Second}
+
+			id := args[0]
+			if _, err := peer.Decode(id); err != nil {
+				var bans []peerBanResponse
+				if err := peerLabelRequest(client, http.MethodGet, base+"/bans", nil, &bans); err != nil {
+					return err
+				}
+				list := make([]peerResponse, len(bans))
+				for i, b := range bans {
+					list[i] = peerResponse{ID: b.PeerID, Name: b.Name}
+				}
+				if id, err = resolvePeer(list, args[0]); err != nil {
+					return err
+				}
+			}
+
+			var res struct{}
+			if err := peerLabelRequest(client, http.MethodDelete, base+"/"+url.PathEscape(id)+"/ban", nil, &res); err != nil {
+				return err
+			}
+			fmt.Printf("Pardoned %s\n", id)
+			return nil
+		},
+	}
+}
+
+// peersAPIBase returns the URL of the daemon's peers API
+func peersAPIBase() (string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	if cfg.Metrics.Port == 0 {
+		return "", fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+	}
+	return fmt.Sprintf("http://%s:%d/api/peers", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port), nil
+}
+
+func printPeerBans(bans []peerBanResponse, now time.Time) {
+	if len(bans) == 0 {
+		fmt.Println("No banned peers")
+		return
+	}
+	fmt.Printf(" %-52s  %-16s  %7s  %-10s  %s\n", "PEER", "NAME", "STRIKES", "BANNED", "REASON")
+	for _, b := range bans {
+		fmt.Printf(" %-52s  %-16s  %7d  %-10s  %s\n", b.PeerID, shortPeerName("", b.Name), b.Strikes, banRemaining(b, now), b.Reason)
+	}
+}
+
+// banRemaining describes how long a ban still lasts
+func banRemaining(b peerBanResponse, now time.Time) string {
+	if b.Permanent {
+		return "permanent"
+	}
+	until, err := time.Parse(time.RFC3339, b.Until)
+	if err != nil || !until.After(now) {
+		return "over"
+	}
+	return formatAge(until.Sub(now))
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBanRemaining(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ban  peerBanResponse
+		want string
+	}{
+		{peerBanResponse{Permanent: true}, "permanent"},
+		{peerBanResponse{Until: "2025-03-05T14:30:00Z"}, "4d02h"},
+		{peerBanResponse{Until: "2025-03-01T11:00:00Z"}, "over"},
+		{peerBanResponse{Until: ""}, "over"},
+	}
+	for _, tt := range tests {
+		if got := banRemaining(tt.ban, now); got != tt.want {
+			t.Errorf("banRemaining(%+v) = %q, want %q", tt.ban, got, tt.want)
+		}
+	}
+}
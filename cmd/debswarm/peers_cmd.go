@@ -1,31 +1,312 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/debswarm/debswarm/internal/units"
 )
 
+// peerResponse matches one entry of the /api/peers JSON.
+type peerResponse struct {
+	ID                  string   `json:"id"`
+	Name                string   `json:"name"`
+	Tags                []string `json:"tags"`
+	Score               float64  `json:"score"`
+	Category            string   `json:"category"`
+	Version             string   `json:"version"`
+	MDNS                bool     `json:"mdns"`
+	Blacklisted         bool     `json:"blacklisted"`
+	Breaker             string   `json:"breaker"`
+	ConsecutiveFailures int      `json:"consecutive_failures"`
+	RetryAt             string   `json:"retry_at"`
+	LastSeen            string   `json:"last_seen"`
+}
+
+// connectedPeerResponse matches one entry of the /api/peers/connections JSON.
+type connectedPeerResponse struct {
+	ID             string  `json:"id"`
+	Name           string  `json:"name"`
+	Score          float64 `json:"score"`
+	Category       string  `json:"category"`
+	Version        string  `json:"version"`
+	ConnectedSince string  `json:"connected_since"`
+	Uploads        int     `json:"uploads"`
+	Downloads      int     `json:"downloads"`
+	Connections    []struct {
+		Addr      string `json:"addr"`
+		Transport string `json:"transport"`
+		Direction string `json:"direction"`
+		Opened    string `json:"opened"`
+	} `json:"connections"`
+}
+
 func peersCmd() *cobra.Command {
-	return &cobra.Command{
+	var jsonOutput bool
+	var all bool
+	var watch bool
+	interval := units.DurationFlag(2 * time.Second)
+
+	cmd := &cobra.Command{
 		Use:   "peers",
 		Short: "Show peer information",
-		Long:  "Show information about known peers and their scores",
+		Long: `Show the peers the running daemon is connected to: how long each has
+been connected, over which transport (quic, tcp, webtransport, websocket or
+relay) and in which direction, the transfers in progress with it (UP for
+uploads to it, DOWN for downloads from it) and its score. With --watch the
+list is refreshed until interrupted.
+
+With --all, list every peer the daemon has scored, connected or not, with
+its circuit breaker state instead. A peer whose breaker is "open" failed
+repeatedly and is skipped until its retry time; "half-open" means a single
+probe transfer is being attempted.
+
+Use 'debswarm peers accounting' for bytes exchanged with each peer over time,
+'debswarm peers label' to give peers names and tags (named peers are
+listed by name), and 'debswarm peers explain' to see why a peer has its score.
+Requires the daemon to be running with metrics enabled.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := loadConfig()
 			if err != nil {
 				return err
 			}
+			if cfg.Metrics.Port == 0 {
+				return fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+			}
 
-			if cfg.Metrics.Port > 0 {
-				fmt.Println("Peer information available via metrics endpoint:")
-				fmt.Printf("  curl http://%s:%d/stats\n", cfg.Metrics.Bind, cfg.Metrics.Port)
-				fmt.Printf("\nDashboard: http://%s:%d/dashboard\n", cfg.Metrics.Bind, cfg.Metrics.Port)
-			} else {
-				fmt.Println("Metrics endpoint is disabled (port = 0)")
+			base := fmt.Sprintf("http://%s:%d", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port)
+			client := &http.Client{Timeout: 5 * time.Second}
+			show := func(header string) error {
+				if all {
+					list, raw, err := fetchPeers(client, base+"/api/peers")
+					if err != nil {
+						return err
+					}
+					if jsonOutput {
+						fmt.Println(string(raw))
+						return nil
+					}
+					if header != "" {
+						fmt.Print("\033[2J\033[H")
+						fmt.Println(header)
+					}
+					printPeers(list, time.Now())
+					return nil
+				}
+				list, raw, err := fetchConnectedPeers(client, base+"/api/peers/connections")
+				if err != nil {
+					return err
+				}
+				if jsonOutput {
+					fmt.Println(string(raw))
+					return nil
+				}
+				if header != "" {
+					fmt.Print("\033[2J\033[H")
+					fmt.Println(header)
+				}
+				printConnectedPeers(list, time.Now())
+				return nil
 			}
-			fmt.Println("\nFor detailed peer scores, check the daemon logs with --log-level debug")
-			return nil
+			if !watch {
+				return show("")
+			}
+			return watchPeers(show, time.Duration(interval))
 		},
 	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output raw JSON")
+	cmd.Flags().BoolVar(&all, "all", false, "List every scored peer with its circuit breaker state, connected or not")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Continuously refresh the list")
+	cmd.Flags().Var(&interval, "interval", "Refresh interval (with --watch)")
+	cmd.AddCommand(peersAccountingCmd())
+	cmd.AddCommand(peersLabelCmd())
+	cmd.AddCommand(peersBansCmd())
+	cmd.AddCommand(peersReceiptsCmd())
+	cmd.AddCommand(peersExplainCmd())
+	cmd.AddCommand(peersProbeCmd())
+	return cmd
+}
+
+// watchPeers calls show every interval until interrupted
+func watchPeers(show func(header string) error, interval time.Duration) error {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		header := fmt.Sprintf("(updated %s, Ctrl+C to stop)\n", time.Now().Format("15:04:05"))
+		if err := show(header); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		select {
+		case <-sigChan:
+			fmt.Println()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func fetchConnectedPeers(client *http.Client, url string) ([]connectedPeerResponse, []byte, error) {
+	body, err := fetchAPI(client, url)
+	if err != nil {
+		return nil, nil, err
+	}
+	var list []connectedPeerResponse
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse connections: %w", err)
+	}
+	return list, body, nil
+}
+
+func fetchPeers(client *http.Client, url string) ([]peerResponse, []byte, error) {
+	body, err := fetchAPI(client, url)
+	if err != nil {
+		return nil, nil, err
+	}
+	var list []peerResponse
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse peers: %w", err)
+	}
+	return list, body, nil
+}
+
+// fetchAPI gets a daemon API endpoint and returns the response body
+func fetchAPI(client *http.Client, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("daemon not running or metrics disabled: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from daemon", resp.StatusCode)
+	}
+	return body, nil
+}
+
+func printPeers(list []peerResponse, now time.Time) {
+	fmt.Printf("Known Peers: %d\n\n", len(list))
+	if len(list) == 0 {
+		return
+	}
+
+	fmt.Printf(" %-16s  %5s  %-9s  %-16s  %-8s  %s\n", "PEER", "SCORE", "CATEGORY", "BREAKER", "FAILURES", "NOTES")
+	for _, p := range list {
+		id := shortPeerName(p.ID, p.Name)
+
+		breaker := p.Breaker
+		if p.RetryAt != "" {
+			if t, err := time.Parse(time.RFC3339, p.RetryAt); err == nil && t.After(now) {
+				breaker += fmt.Sprintf(" (%s)", t.Sub(now).Round(time.Second))
+			}
+		}
+
+		notes := ""
+		if p.MDNS {
+			notes += "lan "
+		}
+		if p.Blacklisted {
+			notes += "blacklisted "
+		}
+		if len(p.Tags) > 0 {
+			notes += "tags=" + strings.Join(p.Tags, ",") + " "
+		}
+		if p.Version != "" {
+			notes += "version=" + p.Version
+		}
+
+		fmt.Printf(" %-16s  %5.2f  %-9s  %-16s  %8d  %s\n",
+			id, p.Score, p.Category, breaker, p.ConsecutiveFailures, notes)
+	}
+}
+
+func printConnectedPeers(list []connectedPeerResponse, now time.Time) {
+	fmt.Printf("Connected Peers: %d\n\n", len(list))
+	if len(list) == 0 {
+		return
+	}
+
+	fmt.Printf(" %-16s  %-12s  %-8s  %8s  %2s  %4s  %5s  %s\n", "PEER", "TRANSPORT", "DIR", "AGE", "UP", "DOWN", "SCORE", "ADDRESS")
+	for _, p := range list {
+		transports, direction, addr := "", "", ""
+		seen := make(map[string]bool)
+		for _, c := range p.Connections {
+			if !seen[c.Transport] {
+				seen[c.Transport] = true
+				if transports != "" {
+					transports += ","
+				}
+				transports += c.Transport
+			}
+		}
+		if len(p.Connections) > 0 {
+			direction, addr = p.Connections[0].Direction, p.Connections[0].Addr
+			if n := len(p.Connections); n > 1 {
+				addr += fmt.Sprintf(" (+%d)", n-1)
+			}
+		}
+
+		age := "-"
+		if t, err := time.Parse(time.RFC3339, p.ConnectedSince); err == nil {
+			age = formatAge(now.Sub(t))
+		}
+
+		fmt.Printf(" %-16s  %-12s  %-8s  %8s  %2d  %4d  %5.2f  %s\n",
+			shortPeerName(p.ID, p.Name), transports, direction, age, p.Uploads, p.Downloads, p.Score, addr)
+	}
+}
+
+// shortPeerName fits a peer's name, or else its ID, into 16 columns
+func shortPeerName(id, name string) string {
+	if name != "" {
+		if r := []rune(name); len(r) > 16 {
+			return string(r[:15]) + "~"
+		}
+		return name
+	}
+	if len(id) > 16 {
+		return id[:6] + "..." + id[len(id)-7:]
+	}
+	return id
+}
+
+// formatAge formats a duration in its two largest units, e.g. "3h12m"
+func formatAge(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd%02dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
 }
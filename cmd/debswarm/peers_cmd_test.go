@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFormatAge(t *testing.T) {
+	tests := map[time.Duration]string{
+		42 * time.Second:                "42s",
+		5*time.Minute + 3*time.Second:   "5m03s",
+		3*time.Hour + 12*time.Minute:    "3h12m",
+		50*time.Hour + 20*time.Minute:   "2d02h",
+		999 * time.Millisecond:          "1s",
+		59*time.Minute + 59*time.Second: "59m59s",
+	}
+	for d, want := range tests {
+		if got := formatAge(d); got != want {
+			t.Errorf("formatAge(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestShortPeerName(t *testing.T) {
+	const id = "12D3KooWGzxzKZYveHXtpG6AsrUJBcWxHBFS2HsEoGTxrMLvKXtf"
+	if got := shortPeerName(id, ""); got != "12D3Ko...MLvKXtf" {
+		t.Errorf("ID = %q", got)
+	}
+	if got := shortPeerName(id, "rack3-seedbox"); got != "rack3-seedbox" {
+		t.Errorf("name = %q", got)
+	}
+	if got := shortPeerName(id, "a-very-long-peer-name"); got != "a-very-long-pee~" {
+		t.Errorf("long name = %q", got)
+	}
+}
+
+func TestFetchConnectedPeers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/peers/connections" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id":"12D3KooWAlpha","name":"seedbox","score":0.8,"connected_since":"2026-01-02T03:04:05Z",
+			"uploads":1,"downloads":2,"connections":[{"addr":"/ip4/10.0.0.2/tcp/4001","transport":"tcp","direction":"inbound","opened":"2026-01-02T03:04:05Z"}]}]`))
+	}))
+	defer srv.Close()
+
+	list, _, err := fetchConnectedPeers(srv.Client(), srv.URL+"/api/peers/connections")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "seedbox" || list[0].Score != 0.8 || list[0].Downloads != 2 ||
+		len(list[0].Connections) != 1 || list[0].Connections[0].Transport != "tcp" {
+		t.Errorf("list = %+v", list)
+	}
+	if _, _, err := fetchConnectedPeers(srv.Client(), srv.URL+"/missing"); err == nil {
+		t.Error("404 accepted")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// peerExplanationResponse matches the /api/peers/{id}/explain JSON.
+type peerExplanationResponse struct {
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	Score         float64 `json:"score"`
+	Category      string  `json:"category"`
+	Basis         string  `json:"basis"`
+	ScoreCachedAt string  `json:"score_cached_at"`
+	Samples       int64   `json:"samples"`
+	Measured      float64 `json:"measured"`
+	Decay         float64 `json:"decay"`
+	DecayHalfLife string  `json:"decay_half_life"`
+	LastTransfer  string  `json:"last_transfer"`
+	Components    []struct {
+		Name         string  `json:"name"`
+		Value        float64 `json:"value"`
+		Weight       float64 `json:"weight"`
+		Contribution float64 `json:"contribution"`
+	} `json:"components"`
+	Stats struct {
+		AvgLatencyMs        float64 `json:"avg_latency_ms"`
+		AvgThroughput       float64 `json:"avg_throughput"`
+		SuccessRate         float64 `json:"success_rate"`
+		Successes           int64   `json:"successes"`
+		Failures            int64   `json:"failures"`
+		BytesDownloaded     int64   `json:"bytes_downloaded"`
+		BytesUploaded       int64   `json:"bytes_uploaded"`
+		MDNS                bool    `json:"mdns"`
+		BlacklistReason     string  `json:"blacklist_reason"`
+		BlacklistUntil      string  `json:"blacklist_until"`
+		Breaker             string  `json:"breaker"`
+		ConsecutiveFailures int     `json:"consecutive_failures"`
+		LastSeen            string  `json:"last_seen"`
+		ProbedLatencyMs     float64 `json:"probed_latency_ms"`
+		ProbedThroughput    float64 `json:"probed_throughput"`
+		ProbedAt            string  `json:"probed_at"`
+	} `json:"stats"`
+}
+
+func peersExplainCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "explain <peer>",
+		Short: "Explain why a peer has its current score",
+		Long: `Break down a peer's score: each component's value, weight and
+contribution, how far the score has decayed toward neutral since the peer's
+last transfer, and the measurements behind it all.
+
+The peer can be given by ID, by the start or end of its ID, or by the name
+set with 'debswarm peers label'. Weights and decay are set in
+[transfer.scoring]. Requires the daemon to be running with metrics enabled.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Metrics.Port == 0 {
+				return fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+			}
+			base := fmt.Sprintf("http://%s:%d/api/peers", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port)
+			client := &http.Client{Timeout: 5 * time.Second}
+
+			list, _, err := fetchPeers(client, base)
+			if err != nil {
+				return err
+			}
+			id, err := resolvePeer(list, args[0])
+			if err != nil {
+				return err
+			}
+			var e peerExplanationResponse
+			if err := peerLabelRequest(client, http.MethodGet, base+"/"+url.PathEscape(id)+"/explain", nil, &e); err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(e)
+			}
+			printPeerExplanation(e)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output JSON")
+	return cmd
+}
+
+// resolvePeer finds the one known peer whose ID or name is arg, or whose ID
+// starts or ends with it
+func resolvePeer(list []peerResponse, arg string) (string, error) {
+	var matches []string
+	for _, p := range list {
+		switch {
+		case p.ID == arg || (p.Name != "" && p.Name == arg):
+			return p.ID, nil
+		case strings.HasPrefix(p.ID, arg) || strings.HasSuffix(p.ID, arg):
+			matches = append(matches, p.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no known peer matches %q", arg)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%q matches %d peers; give more of the ID", arg, len(matches))
+	}
+}
+
+func printPeerExplanation(e peerExplanationResponse) {
+	title := e.ID
+	if e.Name != "" {
+		title = fmt.Sprintf("%s (%s)", e.Name, e.ID)
+	}
+	fmt.Printf("Peer %s\n\n", title)
+	fmt.Printf("Score: %.3f (%s)\n", e.Score, e.Category)
+	if e.ScoreCachedAt != "" {
+		fmt.Printf("  cached at %s; selection uses this value until it is recomputed\n", e.ScoreCachedAt)
+	}
+
+	switch e.Basis {
+	case "few_samples":
+		fmt.Printf("  only %d transfers so far: the score stays neutral until there are enough to measure\n", e.Samples)
+	case "probed":
+		fmt.Printf("  only %d transfers so far: the score is neutral moved by the latency and throughput of a bandwidth probe\n", e.Samples)
+	case "blacklisted":
+		fmt.Printf("  blacklisted until %s (%s): the score is zero\n", e.Stats.BlacklistUntil, e.Stats.BlacklistReason)
+	}
+
+	fmt.Printf("\n %-12s  %6s  %6s  %12s\n", "COMPONENT", "VALUE", "WEIGHT", "CONTRIBUTION")
+	for _, c := range e.Components {
+		fmt.Printf(" %-12s  %6.3f  %6.3f  %12.3f\n", c.Name, c.Value, c.Weight, c.Contribution)
+	}
+	fmt.Printf(" %-12s  %6s  %6s  %12.3f\n", "measured", "", "", e.Measured)
+
+	fmt.Println()
+	if e.DecayHalfLife == "0s" {
+		fmt.Println("Decay: off")
+	} else {
+		since := "never"
+		if e.LastTransfer != "" {
+			since = e.LastTransfer
+		}
+		fmt.Printf("Decay: %.0f%% of the distance from neutral (0.5) left; last transfer %s, half-life %s\n",
+			e.Decay*100, since, e.DecayHalfLife)
+		if e.Basis == "measured" {
+			fmt.Printf("  0.5 + (%.3f - 0.5) x %.3f = %.3f\n", e.Measured, e.Decay, 0.5+(e.Measured-0.5)*e.Decay)
+		}
+	}
+
+	st := e.Stats
+	fmt.Println()
+	fmt.Printf("Transfers:    %d ok, %d failed (success rate %.0f%%)\n", st.Successes, st.Failures, st.SuccessRate*100)
+	fmt.Printf("Latency:      %.0f ms average\n", st.AvgLatencyMs)
+	fmt.Printf("Throughput:   %s/s average\n", formatBytes(int64(st.AvgThroughput)))
+	if st.ProbedAt != "" {
+		fmt.Printf("Probe:        %.0f ms, %s/s at %s\n", st.ProbedLatencyMs, formatBytes(int64(st.ProbedThroughput)), st.ProbedAt)
+	}
+	fmt.Printf("Exchanged:    %s received, %s sent\n", formatBytes(st.BytesDownloaded), formatBytes(st.BytesUploaded))
+	fmt.Printf("LAN (mDNS):   %v\n", st.MDNS)
+	fmt.Printf("Breaker:      %s (%d consecutive failures)\n", st.Breaker, st.ConsecutiveFailures)
+	fmt.Printf("Last seen:    %s\n", st.LastSeen)
+}
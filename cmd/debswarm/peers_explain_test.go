@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestResolvePeer(t *testing.T) {
+	list := []peerResponse{
+		{ID: "12D3KooWAlphaaaa1111"},
+		{ID: "12D3KooWBetaaaaa2222", Name: "rack3-seedbox"},
+		{ID: "12D3KooWBetaaaaa3333"},
+	}
+	tests := []struct {
+		arg, want string
+		wantErr   bool
+	}{
+		{"12D3KooWAlphaaaa1111", "12D3KooWAlphaaaa1111", false},
+		{"rack3-seedbox", "12D3KooWBetaaaaa2222", false},
+		{"12D3KooWAlpha", "12D3KooWAlphaaaa1111", false},
+		{"3333", "12D3KooWBetaaaaa3333", false},
+		{"12D3KooWBeta", "", true}, // ambiguous
+		{"nobody", "", true},
+	}
+	for _, tt := range tests {
+		got, err := resolvePeer(list, tt.arg)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("resolvePeer(%q) = %q, %v; want %q", tt.arg, got, err, tt.want)
+		}
+	}
+}
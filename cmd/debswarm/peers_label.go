@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// peerLabelResponse matches one entry of the /api/peers/labels JSON.
+type peerLabelResponse struct {
+	PeerID string   `json:"peer_id"`
+	Name   string   `json:"name"`
+	Tags   []string `json:"tags"`
+}
+
+func peersLabelCmd() *cobra.Command {
+	var name string
+	var tags []string
+	var clear bool
+
+	cmd := &cobra.Command{
+		Use:   "label [PEER-ID]",
+		Short: "Name and tag peers",
+		Long: `Give a peer a human-friendly name and tags, or list the labels assigned so far.
+
+Names are shown by 'debswarm peers', the dashboard and in audit events. Tags
+can be preferred in provider selection with transfer.peer_selection.prefer_tags,
+e.g. prefer_tags = ["seedbox"]. Labels are stored in the cache database and
+survive restarts; setting a label replaces the peer's previous one.
+
+Requires the daemon to be running with metrics enabled.
+
+Examples:
+  debswarm peers label
+  debswarm peers label 12D3KooW... --name rack3-seedbox --tag seedbox --tag rack3
+  debswarm peers label 12D3KooW... --clear`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Metrics.Port == 0 {
+				return fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+			}
+			base := fmt.Sprintf("http://%s:%d/api/peers", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port)
+			client := &http.Client{Timeout: 5 * time.Second}
+
+			if len(args) == 0 {
+				if name != "" || len(tags) > 0 || clear {
+					return fmt.Errorf("a peer ID is required with --name, --tag or --clear")
+				}
+				var labels []peerLabelResponse
+				if err := peerLabelRequest(client, http.MethodGet, base+"/labels", nil, &labels); err != nil {
+					return err
+				}
+				printPeerLabels(labels)
+				return nil
+			}
+
+			if clear && (name != "" || len(tags) > 0) {
+				return fmt.Errorf("--clear cannot be combined with --name or --tag")
+			}
+			if !clear && name == "" && len(tags) == 0 {
+				return fmt.Errorf("nothing to set: use --name, --tag or --clear")
+			}
+			body, err := json.Marshal(peerLabelResponse{Name: name, Tags: tags})
+			if err != nil {
+				return err
+			}
+			var label peerLabelResponse
+			endpoint := base + "/" + url.PathEscape(args[0]) + "/label"
+			if err := peerLabelRequest(client, http.MethodPut, endpoint, body, &label); err != nil {
+				return err
+			}
+			if clear {
+				fmt.Printf("Removed label of %s\n", label.PeerID)
+				return nil
+			}
+			printPeerLabels([]peerLabelResponse{label})
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "Name for the peer")
+	cmd.Flags().StringArrayVar(&tags, "tag", nil, "Tag for the peer (repeatable)")
+	cmd.Flags().BoolVar(&clear, "clear", false, "Remove the peer's name and tags")
+	return cmd
+}
+
+// peerLabelRequest sends a request to the peer label API and decodes the
+// JSON response into out, within the client's timeout (5s if it has none).
+func peerLabelRequest(client *http.Client, method, endpoint string, body []byte, out any) error {
+	timeout := client.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("daemon not running or metrics disabled: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("daemon refused request: %s", apiErr.Error)
+		}
+		return fmt.Errorf("unexpected status %d from daemon", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+func printPeerLabels(labels []peerLabelResponse) {
+	if len(labels) == 0 {
+		fmt.Println("No peer labels")
+		return
+	}
+	fmt.Printf(" %-52s  %-24s  %s\n", "PEER", "NAME", "TAGS")
+	for _, l := range labels {
+		fmt.Printf(" %-52s  %-24s  %s\n", l.PeerID, l.Name, strings.Join(l.Tags, ","))
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPeerLabelRequest(t *testing.T) {
+	var gotMethod, gotPath string
+	var gotBody peerLabelResponse
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"peer_id":"12D3KooWTest","name":"rack3-seedbox","tags":["seedbox"]}`))
+	}))
+	defer srv.Close()
+
+	body, _ := json.Marshal(peerLabelResponse{Name: "rack3-seedbox", Tags: []string{"seedbox"}})
+	var label peerLabelResponse
+	if err := peerLabelRequest(srv.Client(), http.MethodPut, srv.URL+"/api/peers/12D3KooWTest/label", body, &label); err != nil {
+		t.Fatalf("peerLabelRequest: %v", err)
+	}
+	if gotMethod != http.MethodPut || gotPath != "/api/peers/12D3KooWTest/label" || gotBody.Name != "rack3-seedbox" {
+		t.Errorf("request = %s %s %+v", gotMethod, gotPath, gotBody)
+	}
+	if label.Name != "rack3-seedbox" || len(label.Tags) != 1 {
+		t.Errorf("label = %+v", label)
+	}
+
+	refused := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid peer ID"}`))
+	}))
+	defer refused.Close()
+	err := peerLabelRequest(refused.Client(), http.MethodPut, refused.URL, body, &label)
+	if err == nil || err.Error() != "daemon refused request: invalid peer ID" {
+		t.Errorf("err = %v", err)
+	}
+}
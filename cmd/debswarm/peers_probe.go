@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/spf13/cobra"
+)
+
+// peerProbeResponse matches the /api/peers/{id}/probe JSON.
+type peerProbeResponse struct {
+	ID         string  `json:"id"`
+	Bytes      int64   `json:"bytes"`
+	LatencyMs  float64 `json:"latency_ms"`
+	DurationMs float64 `json:"duration_ms"`
+	Throughput float64 `json:"throughput"`
+	Score      float64 `json:"score"`
+}
+
+func peersProbeCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "probe <peer>",
+		Short: "Measure the bandwidth to a peer",
+		Long: `Run a bandwidth probe against a peer: a short timed transfer (256KB by
+default, see transfer.probe.size) that measures latency and throughput.
+
+The result is recorded with the peer scorer, so a peer that has served no
+packages yet is scheduled and rate-limited from the measurement rather than
+a neutral guess. Peers dialed by the daemon are probed automatically when
+transfer.probe.on_connect is set.
+
+The peer can be given by ID, by the start or end of the ID of a known peer,
+or by its label name. Requires the daemon to be running with metrics enabled.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Metrics.Port == 0 {
+				return fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+			}
+			base := fmt.Sprintf("http://%s:%d/api/peers", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port)
+
+			// A full peer ID may name a peer the daemon has not scored yet
+			id := args[0]
+			if _, err := peer.Decode(id); err != nil {
+				list, _, err := fetchPeers(&http.Client{Timeout: 5 * time.Second}, base)
+				if err != nil {
+					return err
+				}
+				if id, err = resolvePeer(list, args[0]); err != nil {
+					return err
+				}
+			}
+
+			var res peerProbeResponse
+			client := &http.Client{Timeout: 30 * time.Second}
+			if err := peerLabelRequest(client, http.MethodPost, base+"/"+url.PathEscape(id)+"/probe", nil, &res); err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(res)
+			}
+			fmt.Printf("Peer %s\n", res.ID)
+			fmt.Printf("Transferred:  %s in %.0f ms\n", formatBytes(res.Bytes), res.DurationMs)
+			fmt.Printf("Latency:      %.1f ms to first byte\n", res.LatencyMs)
+			fmt.Printf("Throughput:   %s/s\n", formatBytes(int64(res.Throughput)))
+			fmt.Printf("Score:        %.3f\n", res.Score)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output JSON")
+	return cmd
+}
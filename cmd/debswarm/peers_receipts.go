@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// receiptResponse matches one entry of the /api/peers/receipts JSON.
+type receiptResponse struct {
+	SHA256       string    `json:"sha256"`
+	Start        int64     `json:"start"`
+	End          int64     `json:"end"`
+	Digest       string    `json:"digest"`
+	Uploader     string    `json:"uploader"`
+	UploaderName string    `json:"uploader_name"`
+	Time         time.Time `json:"time"`
+	ReceivedAt   time.Time `json:"received_at"`
+	Valid        bool      `json:"valid"`
+	Content      string    `json:"content"`
+}
+
+func peersReceiptsCmd() *cobra.Command {
+	var (
+		hash       string
+		peerID     string
+		limit      int
+		jsonOutput bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "receipts",
+		Short: "Show the signed receipts of downloads from peers",
+		Long: `Show the receipts peers signed for the bytes they sent this node, most
+recent first. Each receipt names the package, the byte range, the SHA256 of
+the bytes sent and the uploader, and is signed with the uploader's identity
+key, so it cannot deny having sent them.
+
+Once a package is in the cache, each receipt for it is checked against the
+package's bytes: a receipt marked "corrupt" shows which peer supplied bad
+bytes. The --json output includes the public keys and signatures, so the
+receipts can be verified by others.
+
+Requires the daemon to be running with metrics enabled.
+
+Examples:
+  debswarm peers receipts
+  debswarm peers receipts --hash 3b1f...
+  debswarm peers receipts --peer 12D3KooW... --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if limit <= 0 {
+				return fmt.Errorf("--limit must be positive")
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Metrics.Port == 0 {
+				return fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+			}
+
+			q := url.Values{}
+			q.Set("limit", fmt.Sprint(limit))
+			if hash != "" {
+				q.Set("hash", hash)
+			}
+			if peerID != "" {
+				q.Set("peer", peerID)
+			}
+			endpoint := fmt.Sprintf("http://%s:%d/api/peers/receipts?%s", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port, q.Encode())
+			client := &http.Client{Timeout: 10 * time.Second}
+			list, raw, err := fetchReceipts(client, endpoint)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				fmt.Println(string(raw))
+				return nil
+			}
+			printReceipts(list)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&hash, "hash", "", "Only receipts for the package with this SHA256")
+	cmd.Flags().StringVar(&peerID, "peer", "", "Only receipts from this peer ID")
+	cmd.Flags().IntVar(&limit, "limit", 100, "Number of receipts to show")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output raw JSON")
+	return cmd
+}
+
+func fetchReceipts(client *http.Client, endpoint string) ([]receiptResponse, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("daemon not running or metrics disabled: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status %d from daemon", resp.StatusCode)
+	}
+
+	var list []receiptResponse
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse receipts: %w", err)
+	}
+	return list, body, nil
+}
+
+func printReceipts(list []receiptResponse) {
+	if len(list) == 0 {
+		fmt.Println("No receipts")
+		return
+	}
+
+	fmt.Printf(" %-16s  %-16s  %21s  %10s  %-9s  %s\n",
+		"PACKAGE", "UPLOADER", "RANGE", "SIZE", "CONTENT", "SENT")
+	for _, r := range list {
+		content := r.Content
+		switch {
+		case !r.Valid:
+			content = "INVALID"
+		case content == "":
+			content = "-"
+		}
+		fmt.Printf(" %-16s  %-16s  %21s  %10s  %-9s  %s\n",
+			r.SHA256[:min(16, len(r.SHA256))], shortPeerName(r.Uploader, r.UploaderName), fmt.Sprintf("%d-%d", r.Start, r.End),
+			formatBytes(r.End-r.Start), content, r.Time.Local().Format("2006-01-02 15:04"))
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/debswarm/debswarm/internal/prefetch"
+	"github.com/debswarm/debswarm/internal/proxy"
+)
+
+func prefetchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prefetch",
+		Short: "Keep package groups cached for offline provisioning",
+		Long: `Manage package groups ("tasks"): named sets of packages the daemon keeps
+resolved against the current package indices and cached, dependencies
+included, so new machines can be provisioned from the LAN swarm without
+the mirror. Cached group packages are announced to peers like any other
+and pinned against eviction.
+
+Groups can also be configured as [[prefetch.groups]]; those added here are
+kept in prefetch.json in the cache directory and survive restarts.
+
+Requires the daemon to be running with metrics enabled.`,
+	}
+
+	cmd.AddCommand(prefetchGroupCmd())
+	cmd.AddCommand(prefetchStatusCmd("status"))
+	cmd.AddCommand(prefetchRunCmd())
+	return cmd
+}
+
+func prefetchGroupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "group",
+		Short: "Add, remove and list package groups",
+	}
+	cmd.AddCommand(prefetchGroupAddCmd())
+	cmd.AddCommand(prefetchGroupRemoveCmd())
+	cmd.AddCommand(prefetchStatusCmd("list"))
+	return cmd
+}
+
+func prefetchGroupAddCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add NAME PACKAGE...",
+		Short: "Add a package group, or replace one added before",
+		Long: `Add a package group. Packages are binary package names, optionally
+qualified with an architecture ("libc6:i386"); unqualified names are
+resolved for each architecture in prefetch.architectures (default: the
+host's). The daemon fetches the group straight away.
+
+Examples:
+  debswarm prefetch group add base-dev build-essential git curl
+  debswarm prefetch group add desktop task-gnome-desktop firefox-esr`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			g := prefetch.Group{Name: args[0], Packages: args[1:]}
+			if err := g.Validate(); err != nil {
+				return err
+			}
+			base, err := prefetchAPIURL()
+			if err != nil {
+				return err
+			}
+			body, err := json.Marshal(g)
+			if err != nil {
+				return err
+			}
+			var st proxy.PrefetchStatus
+			if err := peerLabelRequest(&http.Client{Timeout: 5 * time.Second}, http.MethodPost, base+"/groups", body, &st); err != nil {
+				return err
+			}
+			fmt.Printf("Added group %s (%d packages); fetching in the background\n", g.Name, len(g.Packages))
+			return nil
+		},
+	}
+}
+
+func prefetchGroupRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove NAME",
+		Short: "Remove a package group added with this command",
+		Long: `Remove a package group. Its packages stay cached, but are unpinned on
+the next pass unless another group needs them.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := prefetchAPIURL()
+			if err != nil {
+				return err
+			}
+			var st proxy.PrefetchStatus
+			if err := peerLabelRequest(&http.Client{Timeout: 5 * time.Second}, http.MethodDelete, base+"/groups/"+url.PathEscape(args[0]), nil, &st); err != nil {
+				return err
+			}
+			fmt.Printf("Removed group %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+// prefetchStatusCmd shows the groups; it is both "prefetch status" and
+// "prefetch group list".
+func prefetchStatusCmd(use string) *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: "Show package groups and how much of each is cached",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := prefetchAPIURL()
+			if err != nil {
+				return err
+			}
+			var st proxy.PrefetchStatus
+			if err := peerLabelRequest(&http.Client{Timeout: 5 * time.Second}, http.MethodGet, base, nil, &st); err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(st)
+			}
+			printPrefetchStatus(os.Stdout, &st, time.Now())
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	return cmd
+}
+
+func prefetchRunCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "run",
+		Short: "Resolve and fetch all groups now",
+		Long: `Start a pass over all groups now, e.g. right after 'apt-get update' brought
+new indices, instead of waiting for prefetch.interval.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := prefetchAPIURL()
+			if err != nil {
+				return err
+			}
+			var st proxy.PrefetchStatus
+			if err := peerLabelRequest(&http.Client{Timeout: 5 * time.Second}, http.MethodPost, base+"/run", nil, &st); err != nil {
+				return err
+			}
+			fmt.Println("Prefetch pass requested; see 'debswarm prefetch status' for progress")
+			return nil
+		},
+	}
+}
+
+// prefetchAPIURL returns the local prefetch API URL
+func prefetchAPIURL() (string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	if cfg.Metrics.Port == 0 {
+		return "", fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+	}
+	return fmt.Sprintf("http://%s:%d/api/prefetch", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port), nil
+}
+
+func printPrefetchStatus(w io.Writer, st *proxy.PrefetchStatus, now time.Time) {
+	last := "never"
+	if !st.LastRun.IsZero() {
+		last = formatAge(now.Sub(st.LastRun)) + " ago"
+	}
+	if st.Running {
+		last += " (running now)"
+	}
+	fmt.Fprintf(w, "Architectures: %s\n", strings.Join(st.Architectures, ", "))
+	fmt.Fprintf(w, "Last pass:     %s\n", last)
+
+	fmt.Fprintf(w, "\nGroups: %d\n", len(st.Groups))
+	for _, g := range st.Groups {
+		fmt.Fprintf(w, "  %-20s  %-6s  %d/%d cached (%s)\n", g.Name, g.Source, g.Cached, g.Resolved, formatBytes(g.Bytes))
+		fmt.Fprintf(w, "      packages:    %s\n", strings.Join(g.Packages, " "))
+		if len(g.Missing) > 0 {
+			fmt.Fprintf(w, "      not indexed: %s\n", strings.Join(g.Missing, " "))
+		}
+		if len(g.Unresolved) > 0 {
+			fmt.Fprintf(w, "      unresolved:  %s\n", strings.Join(g.Unresolved, ", "))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/proxy"
+)
+
+func TestPrefetchCommand_Help(t *testing.T) {
+	cmd := prefetchCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"group", "--help"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("prefetch group --help failed: %v", err)
+	}
+	for _, sub := range []string{"add", "remove", "list"} {
+		if !strings.Contains(buf.String(), sub) {
+			t.Errorf("prefetch group help should list %q", sub)
+		}
+	}
+}
+
+func TestPrefetchGroupAdd_Validates(t *testing.T) {
+	cmd := prefetchCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"group", "add", "base dev", "git"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "invalid group name") {
+		t.Errorf("err = %v, want an invalid group name", err)
+	}
+}
+
+func TestPrintPrefetchStatus(t *testing.T) {
+	now := time.Now()
+	st := &proxy.PrefetchStatus{
+		Architectures: []string{"amd64"},
+		LastRun:       now.Add(-10 * time.Minute),
+		Groups: []proxy.PrefetchGroupStatus{{
+			Name: "base-dev", Source: "cli", Packages: []string{"build-essential", "git"},
+			Resolved: 40, Cached: 38, Bytes: 80 << 20,
+			Missing: []string{"git:amd64"}, Unresolved: []string{"perlapi-5.36.0"},
+		}},
+	}
+	var buf bytes.Buffer
+	printPrefetchStatus(&buf, st, now)
+	out := buf.String()
+	for _, want := range []string{"amd64", "10m", "base-dev", "38/40 cached", "not indexed: git:amd64", "unresolved:  perlapi-5.36.0"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/debswarm/debswarm/internal/config"
+	"github.com/debswarm/debswarm/internal/units"
+)
+
+func repoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "repo",
+		Short: "Manage third-party repositories",
+		Long: `Manage third-party repositories such as PPAs and vendor repositories.
+
+Each repository has its hosts allowed through the proxy, and its own
+settings for P2P sharing, Release signing keys, download rate and upload
+priority. Repositories added here are kept in the repos.d directory beside
+the config file; [[repos]] tables in the config file itself are listed but
+must be edited there. Restart the daemon to apply changes.`,
+	}
+	cmd.AddCommand(repoAddCmd())
+	cmd.AddCommand(repoListCmd())
+	cmd.AddCommand(repoRemoveCmd())
+	return cmd
+}
+
+func repoAddCmd() *cobra.Command {
+	var (
+		hosts          []string
+		keyring        string
+		noShare        bool
+		maxRate        units.RateFlag
+		uploadPriority bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add NAME --host HOST [--host HOST...]",
+		Short: "Add or replace a repository",
+		Example: `  debswarm repo add docker --host download.docker.com --keyring /etc/apt/keyrings/docker.asc
+  debswarm repo add vendor --host apt.vendor.example --no-share --max-download-rate 2MB/s`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, ok := existingConfigPath()
+			if !ok {
+				return fmt.Errorf("no config file found; create one with 'debswarm config init'")
+			}
+			repo := config.RepoConfig{
+				Name:           args[0],
+				Hosts:          hosts,
+				UploadPriority: uploadPriority,
+			}
+			if maxRate.IsSet() {
+				repo.MaxDownloadRate = maxRate.String()
+			}
+			if keyring != "" {
+				abs, err := filepath.Abs(keyring)
+				if err != nil {
+					return err
+				}
+				repo.Keyring = abs
+			}
+			if noShare {
+				share := false
+				repo.Share = &share
+			}
+			file, err := addRepo(path, repo)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Added repository %s: %s\n", repo.Name, file)
+			fmt.Println("Restart the daemon to apply: systemctl restart debswarm")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&hosts, "host", nil, "Repository hostname; repeat for several (required)")
+	cmd.Flags().StringVar(&keyring, "keyring", "", "Key file or directory its Release files are verified against")
+	cmd.Flags().BoolVar(&noShare, "no-share", false, "Keep its packages off the P2P network")
+	cmd.Flags().Var(&maxRate, "max-download-rate", "Limit downloads from its hosts (e.g. 2MB/s)")
+	cmd.Flags().BoolVar(&uploadPriority, "upload-priority", false, "Serve its packages to peers ahead of others")
+	_ = cmd.MarkFlagRequired("host")
+	return cmd
+}
+
+func repoListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List configured repositories",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			printRepos(cfg.Repos)
+			return nil
+		},
+	}
+}
+
+func repoRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove NAME",
+		Short: "Remove a repository added with 'debswarm repo add'",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, ok := existingConfigPath()
+			if !ok {
+				return fmt.Errorf("no config file found")
+			}
+			file, err := removeRepo(path, args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Removed repository %s: %s\n", args[0], file)
+			fmt.Println("Restart the daemon to apply: systemctl restart debswarm")
+			return nil
+		},
+	}
+}
+
+// addRepo validates repo against the config at path and writes it to the
+// config's repos.d, replacing a repository of the same name added earlier.
+func addRepo(path string, repo config.RepoConfig) (string, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return "", err
+	}
+	for i, r := range cfg.Repos {
+		if r.Name != repo.Name {
+			continue
+		}
+		if r.File == "" {
+			return "", fmt.Errorf("repository %s is configured in %s; edit it there", repo.Name, path)
+		}
+		cfg.Repos = slices.Delete(cfg.Repos, i, i+1)
+		break
+	}
+	cfg.Repos = append(cfg.Repos, repo)
+	if err := cfg.Validate(); err != nil {
+		return "", err
+	}
+	return config.SaveRepo(config.ReposDirFor(path), repo)
+}
+
+// removeRepo deletes the repos.d file of the repository name from the
+// config at path.
+func removeRepo(path, name string) (string, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range cfg.Repos {
+		if r.Name != name {
+			continue
+		}
+		if r.File == "" {
+			return "", fmt.Errorf("repository %s is configured in %s; edit it there", name, path)
+		}
+		return r.File, os.Remove(r.File)
+	}
+	return "", fmt.Errorf("no repository named %s", name)
+}
+
+func printRepos(repos []config.RepoConfig) {
+	if len(repos) == 0 {
+		fmt.Println("No repositories configured")
+		return
+	}
+
+	fmt.Printf(" %-16s  %-32s  %-5s  %-8s  %-10s  %s\n", "NAME", "HOSTS", "SHARE", "PRIORITY", "RATE", "KEYRING")
+	for _, r := range repos {
+		share, priority, keyring := "yes", "-", "apt"
+		if !r.IsShared() {
+			share = "no"
+		}
+		if r.UploadPriority {
+			priority = "upload"
+		}
+		if r.Keyring != "" {
+			keyring = r.Keyring
+		}
+		fmt.Printf(" %-16s  %-32s  %-5s  %-8s  %-10s  %s\n",
+			r.Name, strings.Join(r.Hosts, ","), share, priority, displayRate(r.MaxDownloadRate), keyring)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/config"
+)
+
+func TestAddRemoveRepo(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	contents := "[[repos]]\nname = \"pgdg\"\nhosts = [\"apt.postgresql.org\"]\n"
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	share := false
+	file, err := addRepo(path, config.RepoConfig{Name: "docker", Hosts: []string{"download.docker.com"}, Share: &share, MaxDownloadRate: "2MB/s"})
+	if err != nil {
+		t.Fatalf("addRepo: %v", err)
+	}
+	if file != filepath.Join(dir, "repos.d", "docker.toml") {
+		t.Errorf("file = %s", file)
+	}
+	// Adding again replaces it
+	if _, err := addRepo(path, config.RepoConfig{Name: "docker", Hosts: []string{"download.docker.com"}}); err != nil {
+		t.Fatalf("addRepo again: %v", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Repos) != 2 || cfg.Repos[1].Name != "docker" || !cfg.Repos[1].IsShared() || cfg.Repos[1].File != file {
+		t.Fatalf("repos = %+v", cfg.Repos)
+	}
+
+	if _, err := addRepo(path, config.RepoConfig{Name: "pgdg", Hosts: []string{"apt.postgresql.org"}}); err == nil {
+		t.Error("replacing a repository from the config file succeeded")
+	}
+	if _, err := addRepo(path, config.RepoConfig{Name: "bad", Hosts: []string{"https://example.com"}}); err == nil {
+		t.Error("invalid host accepted")
+	}
+
+	if _, err := removeRepo(path, "pgdg"); err == nil {
+		t.Error("removing a repository from the config file succeeded")
+	}
+	if _, err := removeRepo(path, "docker"); err != nil {
+		t.Fatalf("removeRepo: %v", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("repo file still exists: %v", err)
+	}
+	if _, err := removeRepo(path, "docker"); err == nil {
+		t.Error("removing a missing repository succeeded")
+	}
+}
@@ -54,7 +54,7 @@ Use --all to show all architectures.`,
 				return err
 			}
 
-			maxSize := cfg.Cache.MaxSizeBytes()
+			maxSize := cacheMaxSize(&cfg.Cache)
 			c, err := cache.New(cfg.Cache.Path, maxSize, logger)
 			if err != nil {
 				return err
@@ -164,7 +164,7 @@ Examples:
 				return err
 			}
 
-			maxSize := cfg.Cache.MaxSizeBytes()
+			maxSize := cacheMaxSize(&cfg.Cache)
 			c, err := cache.New(cfg.Cache.Path, maxSize, logger)
 			if err != nil {
 				return err
@@ -235,7 +235,7 @@ if you have existing cached packages.`,
 				return err
 			}
 
-			maxSize := cfg.Cache.MaxSizeBytes()
+			maxSize := cacheMaxSize(&cfg.Cache)
 			c, err := cache.New(cfg.Cache.Path, maxSize, logger)
 			if err != nil {
 				return err
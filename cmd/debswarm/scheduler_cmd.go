@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/debswarm/debswarm/internal/scheduler"
+	"github.com/debswarm/debswarm/internal/units"
+)
+
+func schedulerCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scheduler",
+		Short: "Show the sync schedule and add exceptions and one-off windows",
+		Long: `Inspect and adjust the running scheduler ([scheduler] in the configuration).
+
+Besides the recurring weekly windows, the schedule has date exceptions, on
+which no recurring window opens (holidays, change freezes), and one-off
+windows that open once regardless of the weekly pattern (maintenance runs).
+Exceptions can also be configured as [[scheduler.exceptions]]; those added
+here, and one-off windows, are kept in scheduler.json in the cache directory
+and survive restarts. Finished entries are dropped automatically.
+
+The window rate applies to package downloads from mirrors and peers alike.
+
+Requires the daemon to be running with metrics enabled.`,
+	}
+
+	cmd.AddCommand(schedulerShowCmd())
+	cmd.AddCommand(schedulerAddWindowCmd())
+	cmd.AddCommand(schedulerAddExceptionCmd())
+	cmd.AddCommand(schedulerRemoveCmd())
+	return cmd
+}
+
+func schedulerShowCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show windows, exceptions and the current rate",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := schedulerAPIURL()
+			if err != nil {
+				return err
+			}
+			var sch scheduler.Schedule
+			if err := peerLabelRequest(&http.Client{Timeout: 5 * time.Second}, http.MethodGet, base, nil, &sch); err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(sch)
+			}
+			printSchedule(&sch, time.Now())
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	return cmd
+}
+
+func schedulerAddWindowCmd() *cobra.Command {
+	var (
+		from, to, reason string
+		rate             units.RateFlag
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add-window",
+		Short: "Open a one-off sync window",
+		Long: `Open a sync window once, between two times given as "YYYY-MM-DD HH:MM"
+in the scheduler's timezone or as RFC 3339.
+
+--rate sets the rate while the window is open ("0" or "unlimited" for no
+limit); without it the inside-window rate applies.
+
+Examples:
+  debswarm scheduler add-window --from "2026-11-07 22:00" --to "2026-11-08 06:00" --rate 0
+  debswarm scheduler add-window --from 2026-11-07T22:00:00+01:00 --to 2026-11-08T02:00:00+01:00 --reason "fleet rebuild"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := struct {
+				From   string `json:"from"`
+				To     string `json:"to"`
+				Rate   *int64 `json:"rate,omitempty"`
+				Reason string `json:"reason,omitempty"`
+			}{From: from, To: to, Reason: reason}
+			if rate.IsSet() {
+				if rate.LinkRelative() {
+					return fmt.Errorf("invalid --rate %q: link percentages are not supported here", rate.String())
+				}
+				req.Rate = &rate.BytesPerSec
+			}
+
+			base, err := schedulerAPIURL()
+			if err != nil {
+				return err
+			}
+			body, err := json.Marshal(req)
+			if err != nil {
+				return err
+			}
+			var w scheduler.OneOffWindow
+			if err := peerLabelRequest(&http.Client{Timeout: 5 * time.Second}, http.MethodPost, base+"/windows", body, &w); err != nil {
+				return err
+			}
+			fmt.Printf("Added window %s: %s\n", w.ID, describeOneOffWindow(w))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "When the window opens")
+	cmd.Flags().StringVar(&to, "to", "", "When the window closes")
+	cmd.Flags().Var(&rate, "rate", "Rate while open, e.g. 10MB/s (0 = unlimited; default: inside_window_rate)")
+	cmd.Flags().StringVar(&reason, "reason", "", "Note shown by 'scheduler show'")
+	_ = cmd.MarkFlagRequired("from")
+	_ = cmd.MarkFlagRequired("to")
+	return cmd
+}
+
+func schedulerAddExceptionCmd() *cobra.Command {
+	var e scheduler.Exception
+
+	cmd := &cobra.Command{
+		Use:   "add-exception",
+		Short: "Keep the recurring windows shut on a range of dates",
+		Long: `Add a date exception: from the first to the last date (inclusive, in the
+scheduler's timezone) no recurring window opens and the outside-window rate
+applies. One-off windows still open.
+
+Examples:
+  debswarm scheduler add-exception --from 2026-12-25 --name Christmas
+  debswarm scheduler add-exception --from 2026-12-18 --to 2027-01-04 --name "year-end freeze"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := e.Validate(); err != nil {
+				return err
+			}
+			base, err := schedulerAPIURL()
+			if err != nil {
+				return err
+			}
+			body, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			var added scheduler.Exception
+			if err := peerLabelRequest(&http.Client{Timeout: 5 * time.Second}, http.MethodPost, base+"/exceptions", body, &added); err != nil {
+				return err
+			}
+			fmt.Printf("Added exception %s: %s\n", added.ID, describeException(added))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&e.From, "from", "", "First date, YYYY-MM-DD")
+	cmd.Flags().StringVar(&e.To, "to", "", "Last date, YYYY-MM-DD (default: --from)")
+	cmd.Flags().StringVar(&e.Name, "name", "", "Name, e.g. a holiday")
+	_ = cmd.MarkFlagRequired("from")
+	return cmd
+}
+
+func schedulerRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove ID",
+		Short: "Remove an exception or one-off window added with this command",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := schedulerAPIURL()
+			if err != nil {
+				return err
+			}
+			var sch scheduler.Schedule
+			if err := peerLabelRequest(&http.Client{Timeout: 5 * time.Second}, http.MethodDelete, base+"/"+url.PathEscape(args[0]), nil, &sch); err != nil {
+				return err
+			}
+			fmt.Printf("Removed %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+// schedulerAPIURL returns the local scheduler API URL
+func schedulerAPIURL() (string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	if cfg.Metrics.Port == 0 {
+		return "", fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+	}
+	return fmt.Sprintf("http://%s:%d/api/scheduler", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port), nil
+}
+
+func printSchedule(sch *scheduler.Schedule, now time.Time) {
+	st := sch.Status
+	state := "outside window"
+	switch {
+	case st.OneOffWindow != "":
+		state = "in one-off window " + st.OneOffWindow
+	case st.InWindow:
+		state = "in window"
+	case st.Exception != "":
+		state = "exception: " + st.Exception
+	}
+	fmt.Printf("Now:        %s, rate %s\n", state, formatRate(st.CurrentRate))
+	if !st.NextWindowOpen.IsZero() {
+		fmt.Printf("Next open:  %s (in %s)\n", st.NextWindowOpen.Format("Mon 2006-01-02 15:04"), formatAge(st.NextWindowOpen.Sub(now)))
+	}
+	fmt.Printf("Timezone:   %s\n", st.Timezone)
+	fmt.Printf("Rates:      inside %s, outside %s", formatRate(sch.InsideRate), formatRate(sch.OutsideRate))
+	if sch.UrgentBypass {
+		fmt.Print(", security updates unlimited")
+	}
+	fmt.Println()
+
+	fmt.Printf("\nWeekly windows: %d\n", len(sch.Windows))
+	for _, w := range sch.Windows {
+		fmt.Printf("  %-30s  %s-%s\n", strings.Join(w.Days, ","), w.StartTime, w.EndTime)
+	}
+
+	fmt.Printf("\nExceptions: %d\n", len(sch.Exceptions))
+	for _, e := range sch.Exceptions {
+		id := e.ID
+		if id == "" {
+			id = "config"
+		}
+		fmt.Printf("  %-6s  %s\n", id, describeException(e))
+	}
+
+	fmt.Printf("\nOne-off windows: %d\n", len(sch.OneOffWindows))
+	for _, w := range sch.OneOffWindows {
+		fmt.Printf("  %-6s  %s\n", w.ID, describeOneOffWindow(w))
+	}
+}
+
+func describeException(e scheduler.Exception) string {
+	var b strings.Builder
+	b.WriteString(e.From)
+	if e.To != "" && e.To != e.From {
+		b.WriteString(" to " + e.To)
+	}
+	if e.Name != "" {
+		fmt.Fprintf(&b, " (%s)", e.Name)
+	}
+	return b.String()
+}
+
+func describeOneOffWindow(w scheduler.OneOffWindow) string {
+	const layout = "2006-01-02 15:04 MST"
+	s := w.From.Local().Format(layout) + " to " + w.To.Local().Format(layout)
+	if w.Rate != nil {
+		s += ", rate " + formatRate(*w.Rate)
+	}
+	if w.Reason != "" {
+		s += " (" + w.Reason + ")"
+	}
+	return s
+}
+
+// formatRate formats a rate in bytes/sec, 0 being unlimited
+func formatRate(bytesPerSec int64) string {
+	if bytesPerSec <= 0 {
+		return "unlimited"
+	}
+	return formatBytes(bytesPerSec) + "/s"
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/scheduler"
+)
+
+func TestSchedulerCommand_Help(t *testing.T) {
+	cmd := schedulerCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"--help"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("scheduler --help failed: %v", err)
+	}
+	for _, sub := range []string{"show", "add-window", "add-exception", "remove"} {
+		if !strings.Contains(buf.String(), sub) {
+			t.Errorf("scheduler help should list %q", sub)
+		}
+	}
+}
+
+func TestDescribeException(t *testing.T) {
+	tests := map[scheduler.Exception]string{
+		{From: "2026-12-25"}: "2026-12-25",
+		{From: "2026-12-25", To: "2026-12-25", Name: "Xmas"}:   "2026-12-25 (Xmas)",
+		{From: "2026-12-18", To: "2027-01-04", Name: "freeze"}: "2026-12-18 to 2027-01-04 (freeze)",
+	}
+	for e, want := range tests {
+		if got := describeException(e); got != want {
+			t.Errorf("describeException(%+v) = %q, want %q", e, got, want)
+		}
+	}
+}
+
+func TestFormatRate(t *testing.T) {
+	if got := formatRate(0); got != "unlimited" {
+		t.Errorf("formatRate(0) = %q", got)
+	}
+	if got := formatRate(100 * 1024); !strings.HasSuffix(got, "/s") || got == "unlimited" {
+		t.Errorf("formatRate(100KB) = %q", got)
+	}
+}
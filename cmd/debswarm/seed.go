@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -18,7 +19,9 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/debswarm/debswarm/internal/cache"
-	"github.com/debswarm/debswarm/internal/p2p"
+	"github.com/debswarm/debswarm/internal/debpkg"
+	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/iothrottle"
 )
 
 // syncState tracks the last sync time for incremental syncs
@@ -37,6 +40,10 @@ func seedCmd() *cobra.Command {
 	var incremental bool
 	var watch bool
 	var showProgress bool
+	var packagesIndexes []string
+	var useJournal bool
+	var reportPath string
+	var announceWorkers int
 
 	cmd := &cobra.Command{
 		Use:   "seed",
@@ -52,6 +59,16 @@ making them discoverable via the DHT without waiting for APT requests.`,
 		Short: "Import .deb files into cache and announce to network",
 		Long: `Import .deb packages from local files or directories.
 
+Each file must be a structurally valid Debian package; its control file's
+Package, Version and Architecture are recorded in the cache. With
+--packages-index, a package the index lists under a different SHA256 is
+rejected, catching files altered after the mirror published them.
+
+Each file is read once: it is hashed, checked and copied into the cache in
+a single pass, with reads running ahead of hashing. An import journal in the
+cache directory records each file's size, modification time, inode and hash,
+so files unchanged since the last import are skipped without being read.
+
 Examples:
   debswarm seed import /var/cache/apt/archives/*.deb
   debswarm seed import --recursive /mirror/ubuntu/pool/
@@ -59,19 +76,24 @@ Examples:
   debswarm seed import --recursive --parallel 8 /mirror/pool/
   debswarm seed import --recursive --sync --incremental /mirror/pool/
   debswarm seed import --recursive --sync --dry-run /mirror/pool/
-  debswarm seed import --recursive --watch /mirror/pool/`,
+  debswarm seed import --recursive --watch /mirror/pool/
+  debswarm seed import --recursive --packages-index /mirror/dists/stable/main/binary-amd64/Packages.xz /mirror/pool/`,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			opts := &seedImportOptions{
-				recursive:    recursive,
-				announce:     announce,
-				syncMode:     syncMode,
-				cachePath:    cachePath,
-				parallel:     parallel,
-				dryRun:       dryRun,
-				incremental:  incremental,
-				watch:        watch,
-				showProgress: showProgress,
+				recursive:       recursive,
+				announce:        announce,
+				syncMode:        syncMode,
+				cachePath:       cachePath,
+				parallel:        parallel,
+				dryRun:          dryRun,
+				incremental:     incremental,
+				watch:           watch,
+				showProgress:    showProgress,
+				indexes:         packagesIndexes,
+				journal:         useJournal,
+				reportPath:      reportPath,
+				announceWorkers: announceWorkers,
 			}
 			return runSeedImport(args, opts)
 		},
@@ -79,18 +101,24 @@ Examples:
 
 	importCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Recursively scan directories")
 	importCmd.Flags().BoolVarP(&announce, "announce", "a", true, "Announce packages to DHT")
+	importCmd.Flags().IntVar(&announceWorkers, "announce-parallel", defaultAnnounceWorkers, "Number of concurrent DHT announcements")
 	importCmd.Flags().BoolVar(&syncMode, "sync", false, "Remove cached packages not in source (mirror sync mode)")
-	importCmd.Flags().IntVarP(&parallel, "parallel", "p", 1, "Number of parallel import workers (default 1)")
+	importCmd.Flags().IntVarP(&parallel, "parallel", "p", 0, "Number of parallel import workers (default: one per CPU, up to 32)")
 	importCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Preview changes without making them")
 	importCmd.Flags().BoolVar(&incremental, "incremental", false, "Only process files modified since last sync")
 	importCmd.Flags().BoolVarP(&watch, "watch", "w", false, "Watch for changes and import automatically")
 	importCmd.Flags().BoolVar(&showProgress, "progress", false, "Show progress bar instead of per-file output")
+	importCmd.Flags().BoolVar(&useJournal, "journal", true, "Skip files unchanged since the last import (import journal)")
+	importCmd.Flags().StringVar(&reportPath, "report", "", "Write the reconciliation report as JSON to this file")
+	importCmd.Flags().StringArrayVar(&packagesIndexes, "packages-index", nil, "Packages index file to cross-check hashes against (repeatable)")
 
 	// Add cache-path as persistent flag so it's available to all subcommands
 	cmd.PersistentFlags().StringVar(&cachePath, "cache-path", "", "Override cache path from config")
 
 	cmd.AddCommand(importCmd)
 	cmd.AddCommand(seedListCmd(&cachePath))
+	cmd.AddCommand(seedAnnounceCmd(&cachePath))
+	cmd.AddCommand(seedExportCmd(&cachePath))
 
 	return cmd
 }
@@ -105,6 +133,16 @@ type seedImportOptions struct {
 	incremental  bool
 	watch        bool
 	showProgress bool
+	indexes      []string // Packages files given with --packages-index
+	journal      bool
+	reportPath   string
+
+	announceWorkers int
+
+	// known holds the loaded --packages-index files, nil without any
+	known *index.Index
+	// throttle paces reading and copying the files ([cache.io_throttle])
+	throttle *iothrottle.Throttle
 }
 
 func seedListCmd(cachePath *string) *cobra.Command {
@@ -124,7 +162,7 @@ func seedListCmd(cachePath *string) *cobra.Command {
 				cacheDir = *cachePath
 			}
 
-			maxSize := cfg.Cache.MaxSizeBytes()
+			maxSize := cacheMaxSize(&cfg.Cache)
 			c, err := cache.New(cacheDir, maxSize, logger)
 			if err != nil {
 				return err
@@ -168,18 +206,30 @@ func runSeedImport(args []string, opts *seedImportOptions) error {
 		cacheDir = opts.cachePath
 	}
 
+	opts.throttle = iothrottle.New(ioThrottleLimits(&cfg.Cache.IOThrottle))
+
 	// Validate parallel count
 	if opts.parallel < 1 {
-		opts.parallel = 1
+		opts.parallel = runtime.NumCPU()
 	}
 	if opts.parallel > 32 {
 		opts.parallel = 32 // Cap at reasonable limit
 	}
 
+	if len(opts.indexes) > 0 {
+		opts.known = index.New("", logger)
+		for _, path := range opts.indexes {
+			if err := opts.known.LoadFromFile(path); err != nil {
+				return fmt.Errorf("failed to load packages index %s: %w", path, err)
+			}
+		}
+		fmt.Printf("Cross-checking against %d indexed packages\n", opts.known.Count())
+	}
+
 	// Initialize cache (unless dry-run)
 	var pkgCache *cache.Cache
 	if !opts.dryRun {
-		maxSize := cfg.Cache.MaxSizeBytes()
+		maxSize := cacheMaxSize(&cfg.Cache)
 		pkgCache, err = cache.New(cacheDir, maxSize, logger)
 		if err != nil {
 			return fmt.Errorf("failed to initialize cache: %w", err)
@@ -188,38 +238,14 @@ func runSeedImport(args []string, opts *seedImportOptions) error {
 	}
 
 	// Initialize P2P node if announcing (and not dry-run)
-	var p2pNode *p2p.Node
+	var announcer *seedAnnouncer
 	if opts.announce && !opts.dryRun {
-		ctx := context.Background()
-		p2pCfg := &p2p.Config{
-			ListenPort:         cfg.Network.ListenPort,
-			BootstrapPeers:     cfg.Network.BootstrapPeers,
-			EnableMDNS:         cfg.Privacy.EnableMDNS,
-			PreferQUIC:         true,
-			EnableRelay:        cfg.Network.IsRelayEnabled(),
-			EnableHolePunching: cfg.Network.IsHolePunchingEnabled(),
-			// Cross-NAT: a seeding node is often the publicly-reachable one, so it
-			// is exactly the node that should be relaying for NAT'd peers.
-			EnableAutoRelay:      cfg.Network.IsAutoRelayEnabled(),
-			RelayService:         cfg.Network.GetRelayService(),
-			RelayPeers:           cfg.Network.RelayPeers,
-			RelayMaxReservations: cfg.Network.RelayMaxReservations(),
-			RelayMaxCircuits:     cfg.Network.RelayMaxCircuits(),
-			RelayBufferSize:      cfg.Network.RelayBufferSizeBytes(),
-			RelayDuration:        cfg.Network.RelayDuration(),
-			ForceReachability:    cfg.Network.GetForceReachability(),
-			RelayedTransferMax:   cfg.Network.RelayedTransferMaxBytes(),
-		}
-
-		p2pNode, err = p2p.New(ctx, p2pCfg, logger)
+		p2pNode, err := newSeedNode(cfg, logger)
 		if err != nil {
-			return fmt.Errorf("failed to initialize P2P: %w", err)
+			return err
 		}
 		defer func() { _ = p2pNode.Close() }()
-
-		fmt.Println("Waiting for DHT bootstrap...")
-		p2pNode.WaitForBootstrap()
-		fmt.Printf("Connected to %d peers\n\n", p2pNode.ConnectedPeers())
+		announcer = newSeedAnnouncer(p2pNode, pkgCache, cfg, opts.announceWorkers, opts.showProgress)
 	}
 
 	if opts.dryRun {
@@ -229,14 +255,14 @@ func runSeedImport(args []string, opts *seedImportOptions) error {
 
 	// Watch mode: continuous monitoring
 	if opts.watch {
-		return runWatchMode(args, opts, pkgCache, p2pNode, cacheDir)
+		return runWatchMode(args, opts, pkgCache, announcer, cacheDir)
 	}
 
 	// Single import run
-	return runSingleImport(args, opts, pkgCache, p2pNode, cacheDir)
+	return runSingleImport(args, opts, pkgCache, announcer, cacheDir)
 }
 
-func runSingleImport(args []string, opts *seedImportOptions, pkgCache *cache.Cache, p2pNode *p2p.Node, cacheDir string) error {
+func runSingleImport(args []string, opts *seedImportOptions, pkgCache *cache.Cache, announcer *seedAnnouncer, cacheDir string) error {
 	// Load last sync time for incremental mode
 	var lastSync time.Time
 	var stateFile string
@@ -267,6 +293,19 @@ func runSingleImport(args []string, opts *seedImportOptions, pkgCache *cache.Cac
 		return fmt.Errorf("no .deb files found")
 	}
 
+	// The journal lets unchanged files skip hashing. An incremental run only
+	// lists recently modified files, so it cannot tell which files are gone.
+	var journal *importJournal
+	var journalRoots []string
+	if opts.journal {
+		journal = loadImportJournal(filepath.Join(cacheDir, ".seed-journal.json"))
+		if !opts.incremental {
+			journalRoots = args
+		}
+	}
+	report := &importReport{Started: time.Now()}
+	var reportMu sync.Mutex
+
 	fmt.Printf("Found %d .deb files to process\n", len(debFiles))
 	if opts.parallel > 1 {
 		fmt.Printf("Using %d parallel workers\n", opts.parallel)
@@ -280,17 +319,22 @@ func runSingleImport(args []string, opts *seedImportOptions, pkgCache *cache.Cac
 	var imported, skipped, failed int64
 	var totalBytes int64
 
+	// Newly imported packages, announced once the import is done
+	var toAnnounce []string
+
 	// Progress tracking
 	var processed int64
 	total := int64(len(debFiles))
 
 	// Results channel for collecting import results
 	type importResult struct {
-		path    string
-		hash    string
-		size    int64
-		err     error
-		skipped bool
+		path      string
+		hash      string
+		size      int64
+		err       error
+		skipped   bool
+		unchanged bool  // skipped via the journal without reading the file
+		hashed    int64 // bytes read and hashed
 	}
 	results := make(chan importResult, opts.parallel)
 
@@ -303,13 +347,29 @@ func runSingleImport(args []string, opts *seedImportOptions, pkgCache *cache.Cac
 		go func() {
 			defer wg.Done()
 			for path := range fileChan {
-				hash, size, err := processDebFile(pkgCache, path, opts.dryRun)
+				info, err := os.Stat(path)
+				if err != nil {
+					results <- importResult{path: path, err: err}
+					continue
+				}
+				if journal != nil {
+					if hash, ok := journal.unchanged(path, info); ok && (opts.dryRun || pkgCache.Has(hash)) {
+						results <- importResult{path: path, hash: hash, size: info.Size(), skipped: true, unchanged: true}
+						continue
+					}
+				}
+				hash, size, err := processDebFile(pkgCache, path, opts.dryRun, opts.known, opts.throttle)
+				skipped := err != nil && err.Error() == "already cached"
+				if journal != nil && (err == nil || skipped) {
+					journal.record(path, info, hash)
+				}
 				results <- importResult{
 					path:    path,
 					hash:    hash,
 					size:    size,
 					err:     err,
-					skipped: err != nil && err.Error() == "already cached",
+					skipped: skipped,
+					hashed:  info.Size(),
 				}
 			}
 		}()
@@ -321,7 +381,28 @@ func runSingleImport(args []string, opts *seedImportOptions, pkgCache *cache.Cac
 		for result := range results {
 			current := atomic.AddInt64(&processed, 1)
 
-			if result.skipped {
+			reportMu.Lock()
+			report.Scanned++
+			report.BytesHashed += result.hashed
+			switch {
+			case result.unchanged:
+				report.Unchanged++
+			case result.skipped:
+				report.AlreadyCached++
+			case result.err != nil:
+				report.Failed++
+				report.Failures = append(report.Failures, importFailure{Path: result.path, Error: result.err.Error()})
+			default:
+				report.Imported++
+				report.BytesImported += result.size
+			}
+			reportMu.Unlock()
+
+			if result.unchanged {
+				// Not printed per file: on a re-import that is nearly every file
+				atomic.AddInt64(&skipped, 1)
+				sourceHashes.Store(result.hash, struct{}{})
+			} else if result.skipped {
 				atomic.AddInt64(&skipped, 1)
 				sourceHashes.Store(result.hash, struct{}{})
 				if !opts.showProgress {
@@ -343,17 +424,7 @@ func runSingleImport(args []string, opts *seedImportOptions, pkgCache *cache.Cac
 						fmt.Printf("  [OK]   %s (%s, %s)\n", filepath.Base(result.path), formatBytes(result.size), result.hash[:12]+"...")
 					}
 				}
-
-				// Announce to DHT (not in dry-run)
-				if opts.announce && p2pNode != nil && !opts.dryRun {
-					ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-					if err := p2pNode.Provide(ctx, result.hash); err != nil {
-						if !opts.showProgress {
-							fmt.Printf("         Warning: failed to announce: %v\n", err)
-						}
-					}
-					cancel()
-				}
+				toAnnounce = append(toAnnounce, result.hash)
 			}
 
 			// Progress bar
@@ -391,6 +462,12 @@ func runSingleImport(args []string, opts *seedImportOptions, pkgCache *cache.Cac
 		fmt.Printf("Summary: %d imported (%s), %d skipped, %d failed\n", imported, formatBytes(totalBytes), skipped, failed)
 	}
 
+	if announcer != nil {
+		ctx, stop := interruptContext()
+		announcer.announceAndReport(ctx, appendUnannounced(pkgCache, toAnnounce, &sourceHashes))
+		stop()
+	}
+
 	// Sync mode: remove packages not in source
 	if opts.syncMode {
 		removed, wouldRemove := runSyncRemoval(pkgCache, &sourceHashes, opts.dryRun)
@@ -411,9 +488,51 @@ func runSingleImport(args []string, opts *seedImportOptions, pkgCache *cache.Cac
 		saveSyncState(stateFile, args[0])
 	}
 
+	if journal != nil {
+		report.Removed = journal.missing(journalRoots)
+		if !opts.dryRun {
+			if err := journal.save(journalRoots); err != nil {
+				fmt.Printf("Warning: failed to save import journal: %v\n", err)
+			}
+		}
+	}
+	elapsed := time.Since(report.Started)
+	report.Duration = elapsed.Round(time.Millisecond).String()
+	report.print(elapsed)
+	if opts.reportPath != "" {
+		if err := report.write(opts.reportPath); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+		fmt.Printf("Report written to %s\n", opts.reportPath)
+	}
+
 	return nil
 }
 
+// appendUnannounced adds to hashes the source packages that were already
+// cached but have no current provider record, such as those an interrupted
+// earlier import did not get to announce.
+func appendUnannounced(pkgCache *cache.Cache, hashes []string, sourceHashes *sync.Map) []string {
+	due, err := pkgCache.GetUnannounced()
+	if err != nil {
+		fmt.Printf("Warning: failed to list unannounced packages: %v\n", err)
+		return hashes
+	}
+	queued := make(map[string]struct{}, len(hashes))
+	for _, h := range hashes {
+		queued[h] = struct{}{}
+	}
+	for _, pkg := range due {
+		if _, ok := queued[pkg.SHA256]; ok {
+			continue
+		}
+		if _, ok := sourceHashes.Load(pkg.SHA256); ok {
+			hashes = append(hashes, pkg.SHA256)
+		}
+	}
+	return hashes
+}
+
 func runSyncRemoval(pkgCache *cache.Cache, sourceHashes *sync.Map, dryRun bool) (removed, wouldRemove int) {
 	if dryRun {
 		fmt.Println("\nSync mode: checking for packages that would be removed...")
@@ -445,12 +564,12 @@ func runSyncRemoval(pkgCache *cache.Cache, sourceHashes *sync.Map, dryRun bool)
 	return removed, wouldRemove
 }
 
-func runWatchMode(args []string, opts *seedImportOptions, pkgCache *cache.Cache, p2pNode *p2p.Node, cacheDir string) error {
+func runWatchMode(args []string, opts *seedImportOptions, pkgCache *cache.Cache, announcer *seedAnnouncer, cacheDir string) error {
 	fmt.Println("Watch mode: monitoring for changes (Ctrl+C to stop)")
 	fmt.Println()
 
 	// Do initial import
-	if err := runSingleImport(args, opts, pkgCache, p2pNode, cacheDir); err != nil {
+	if err := runSingleImport(args, opts, pkgCache, announcer, cacheDir); err != nil {
 		// Don't fail on initial import errors in watch mode
 		fmt.Printf("Initial import warning: %v\n", err)
 	}
@@ -490,8 +609,9 @@ func runWatchMode(args []string, opts *seedImportOptions, pkgCache *cache.Cache,
 		}
 
 		fmt.Printf("\n[%s] Processing %d changed files...\n", time.Now().Format("15:04:05"), len(files))
+		var toAnnounce []string
 		for _, path := range files {
-			hash, size, err := processDebFile(pkgCache, path, opts.dryRun)
+			hash, size, err := processDebFile(pkgCache, path, opts.dryRun, opts.known, opts.throttle)
 			if err != nil {
 				if err.Error() == "already cached" {
 					fmt.Printf("  [SKIP] %s\n", filepath.Base(path))
@@ -505,17 +625,12 @@ func runWatchMode(args []string, opts *seedImportOptions, pkgCache *cache.Cache,
 				fmt.Printf("  [WOULD IMPORT] %s (%s)\n", filepath.Base(path), formatBytes(size))
 			} else {
 				fmt.Printf("  [OK]   %s (%s)\n", filepath.Base(path), formatBytes(size))
-
-				// Announce to DHT
-				if opts.announce && p2pNode != nil {
-					ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-					if err := p2pNode.Provide(ctx, hash); err != nil {
-						fmt.Printf("         Warning: failed to announce: %v\n", err)
-					}
-					cancel()
-				}
+				toAnnounce = append(toAnnounce, hash)
 			}
 		}
+		if announcer != nil {
+			announcer.announceAndReport(context.Background(), toAnnounce)
+		}
 	}
 
 	// Watch for events
@@ -617,7 +732,12 @@ func collectDebFiles(path string, recursive bool, modifiedAfter time.Time) ([]st
 	return files, err
 }
 
-func processDebFile(c *cache.Cache, path string, dryRun bool) (string, int64, error) {
+// processDebFile validates a .deb and imports it into the cache. known, when
+// set, holds Packages indexes the package is cross-checked against. The file
+// is read once: hashed, checked and copied into a cache pending file in the
+// same pass, unless the cache probably has it already. Reads are paced by
+// throttle (nil = unlimited).
+func processDebFile(c *cache.Cache, path string, dryRun bool, known *index.Index, throttle *iothrottle.Throttle) (string, int64, error) {
 	// Open file
 	f, err := os.Open(path)
 	if err != nil {
@@ -631,13 +751,41 @@ func processDebFile(c *cache.Cache, path string, dryRun bool) (string, int64, er
 		return "", 0, err
 	}
 
-	// Calculate SHA256
+	// Copy while hashing, unless a package with this file's name, version,
+	// architecture and size is cached: then hash only, so re-importing a
+	// mirror without a journal does not rewrite it all.
 	hasher := sha256.New()
-	if _, err := io.Copy(hasher, f); err != nil {
+	var sink io.Writer = hasher
+	var pending *os.File
+	if !dryRun && !probablyCached(c, path, info.Size()) {
+		if pending, err = c.CreatePending(); err != nil {
+			return "", 0, err
+		}
+		defer func() {
+			if pending != nil {
+				_ = pending.Close()
+				_ = os.Remove(pending.Name())
+			}
+		}()
+		sink = io.MultiWriter(hasher, pending)
+	}
+
+	// Validate the package structure and calculate SHA256 in one pass
+	ctx := context.Background()
+	ra := newReadahead(throttle.Reader(ctx, f), readaheadSize, readaheadDepth)
+	defer ra.Close()
+	ctrl, err := debpkg.Inspect(io.TeeReader(ra, sink))
+	if err != nil {
 		return "", 0, err
 	}
 	hash := hex.EncodeToString(hasher.Sum(nil))
 
+	if known != nil {
+		if err := checkIndexedHash(known, ctrl, hash); err != nil {
+			return "", 0, err
+		}
+	}
+
 	// In dry-run mode, just return the hash/size
 	if dryRun {
 		return hash, info.Size(), nil
@@ -648,20 +796,128 @@ func processDebFile(c *cache.Cache, path string, dryRun bool) (string, int64, er
 		return hash, info.Size(), fmt.Errorf("already cached")
 	}
 
-	// Seek back to start
-	if _, err := f.Seek(0, io.SeekStart); err != nil {
-		return "", 0, err
-	}
-
-	// Store in cache
+	// Store in cache: move the copy in, or copy now if the guess was wrong
 	filename := filepath.Base(path)
-	if err := c.Put(f, hash, filename); err != nil {
+	if pending != nil {
+		if err := pending.Close(); err != nil {
+			return "", 0, err
+		}
+		if err := c.PutFile(pending.Name(), hash, filename, info.Size()); err != nil {
+			return "", 0, err
+		}
+		pending = nil
+	} else {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return "", 0, err
+		}
+		if err := c.Put(throttle.Reader(ctx, f), hash, filename); err != nil {
+			return "", 0, err
+		}
+	}
+	// The control file is authoritative; the file may have been renamed
+	if err := c.SetPackageMetadata(hash, ctrl.Package, ctrl.Version, ctrl.Architecture); err != nil {
 		return "", 0, err
 	}
 
 	return hash, info.Size(), nil
 }
 
+// probablyCached reports whether the cache holds a package with the name,
+// version and architecture of the file at path and the same size
+func probablyCached(c *cache.Cache, path string, size int64) bool {
+	name, version, arch, ok := cache.ParseDebFilename(filepath.Base(path))
+	if !ok {
+		return false
+	}
+	pkg, err := c.GetByNameVersionArch(name, version, arch)
+	return err == nil && pkg.Size == size
+}
+
+const (
+	readaheadSize  = 1 << 20 // bytes per read
+	readaheadDepth = 4       // chunks read ahead of the consumer
+)
+
+// readahead reads r in a goroutine, up to depth chunks ahead of its reader,
+// so disk reads of the next chunks overlap hashing of the current one.
+type readahead struct {
+	chunks chan readaheadChunk
+	stop   chan struct{}
+	once   sync.Once
+	cur    []byte
+	err    error
+}
+
+type readaheadChunk struct {
+	data []byte
+	err  error
+}
+
+func newReadahead(r io.Reader, chunkSize, depth int) *readahead {
+	ra := &readahead{
+		chunks: make(chan readaheadChunk, depth),
+		stop:   make(chan struct{}),
+	}
+	go func() {
+		defer close(ra.chunks)
+		for {
+			buf := make([]byte, chunkSize)
+			n, err := io.ReadFull(r, buf)
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			select {
+			case ra.chunks <- readaheadChunk{data: buf[:n], err: err}:
+			case <-ra.stop:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return ra
+}
+
+func (ra *readahead) Read(p []byte) (int, error) {
+	for len(ra.cur) == 0 {
+		if ra.err != nil {
+			return 0, ra.err
+		}
+		c, ok := <-ra.chunks
+		if !ok {
+			return 0, io.EOF
+		}
+		ra.cur, ra.err = c.data, c.err
+	}
+	n := copy(p, ra.cur)
+	ra.cur = ra.cur[n:]
+	return n, nil
+}
+
+// Close stops the read-ahead goroutine
+func (ra *readahead) Close() error {
+	ra.once.Do(func() { close(ra.stop) })
+	return nil
+}
+
+// checkIndexedHash rejects a package that the index lists under a different
+// SHA256, or whose hash the index assigns to a different package. Packages
+// the index does not list pass.
+func checkIndexedHash(known *index.Index, ctrl *debpkg.Control, hash string) error {
+	if pkg := known.GetBySHA256(hash); pkg != nil {
+		if pkg.Package != ctrl.Package || pkg.Version != ctrl.Version || pkg.Architecture != ctrl.Architecture {
+			return fmt.Errorf("index lists this hash as %s %s %s, control file says %s %s %s",
+				pkg.Package, pkg.Version, pkg.Architecture, ctrl.Package, ctrl.Version, ctrl.Architecture)
+		}
+		return nil
+	}
+	if pkg := known.GetByBasename(ctrl.Filename(), ""); pkg != nil {
+		return fmt.Errorf("SHA256 %s does not match the index (%s)", hash[:12]+"...", pkg.SHA256[:min(12, len(pkg.SHA256))]+"...")
+	}
+	return nil
+}
+
 func printProgress(current, total, imported, skipped, failed int64) {
 	width := 40
 	pct := float64(current) / float64(total)
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/config"
+	"github.com/debswarm/debswarm/internal/p2p"
+)
+
+const (
+	// defaultAnnounceWorkers is how many DHT announcements a seed run has
+	// in flight. Each is mostly waiting on the network, so far more than
+	// one per CPU pays off.
+	defaultAnnounceWorkers = 16
+
+	// seedAnnounceTimeout bounds a single announcement
+	seedAnnounceTimeout = 30 * time.Second
+)
+
+// seedAnnouncer announces seeded packages to the DHT through a bounded pool
+// of workers. Each success is recorded in the cache, so packages a run did
+// not get to stay unannounced there and "seed announce --resume" (or a
+// daemon sharing the cache) picks them up later.
+type seedAnnouncer struct {
+	provide  func(ctx context.Context, hash string) error
+	cache    *cache.Cache
+	workers  int
+	timeout  time.Duration
+	ttl      time.Duration // provider record lifetime, as the daemon uses
+	progress bool          // progress bar instead of per-failure lines
+	out      io.Writer
+}
+
+func newSeedAnnouncer(node *p2p.Node, c *cache.Cache, cfg *config.Config, workers int, progress bool) *seedAnnouncer {
+	if workers < 1 {
+		workers = defaultAnnounceWorkers
+	}
+	return &seedAnnouncer{
+		provide:  node.Provide,
+		cache:    c,
+		workers:  workers,
+		timeout:  seedAnnounceTimeout,
+		ttl:      cfg.DHT.ProviderTTLDuration(),
+		progress: progress,
+		out:      os.Stdout,
+	}
+}
+
+// run announces hashes until all have been tried or ctx is done, and
+// returns how many succeeded and failed. Hashes not tried because ctx ended
+// are in neither count.
+func (a *seedAnnouncer) run(ctx context.Context, hashes []string) (announced, failed int64) {
+	if len(hashes) == 0 {
+		return 0, 0
+	}
+	total := int64(len(hashes))
+	var done int64
+	var outMu sync.Mutex
+
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < min(a.workers, len(hashes)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for hash := range work {
+				if ctx.Err() != nil {
+					continue
+				}
+				pctx, cancel := context.WithTimeout(ctx, a.timeout)
+				err := a.provide(pctx, hash)
+				cancel()
+				if err == nil {
+					if markErr := a.cache.MarkAnnounced(hash, a.ttl); markErr != nil {
+						err = fmt.Errorf("announced, but failed to record it: %w", markErr)
+					}
+				}
+				if err == nil {
+					atomic.AddInt64(&announced, 1)
+				} else if ctx.Err() == nil {
+					atomic.AddInt64(&failed, 1)
+				}
+				current := atomic.AddInt64(&done, 1)
+
+				outMu.Lock()
+				if a.progress {
+					printAnnounceProgress(a.out, current, total, atomic.LoadInt64(&announced), atomic.LoadInt64(&failed))
+				} else if err != nil && ctx.Err() == nil {
+					_, _ = fmt.Fprintf(a.out, "  [FAIL] announce %s: %v\n", hash[:min(12, len(hash))]+"...", err)
+				}
+				outMu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, hash := range hashes {
+		select {
+		case work <- hash:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+	if a.progress {
+		_, _ = fmt.Fprintln(a.out)
+	}
+	return announced, failed
+}
+
+// announceAndReport runs the announcer and prints the outcome, with a hint
+// to resume when some packages were left unannounced.
+func (a *seedAnnouncer) announceAndReport(ctx context.Context, hashes []string) {
+	if len(hashes) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(a.out, "\nAnnouncing %d packages to the DHT (%d at a time)...\n", len(hashes), min(a.workers, len(hashes)))
+	start := time.Now()
+	announced, failed := a.run(ctx, hashes)
+	_, _ = fmt.Fprintf(a.out, "Announced %d of %d packages in %s", announced, len(hashes), time.Since(start).Round(time.Second))
+	if failed > 0 {
+		_, _ = fmt.Fprintf(a.out, ", %d failed", failed)
+	}
+	_, _ = fmt.Fprintln(a.out)
+	if left := int64(len(hashes)) - announced; left > 0 {
+		_, _ = fmt.Fprintf(a.out, "%d packages are not announced yet; run 'debswarm seed announce --resume' to finish\n", left)
+	}
+}
+
+func printAnnounceProgress(w io.Writer, current, total, announced, failed int64) {
+	width := 40
+	pct := float64(current) / float64(total)
+	filled := int(pct * float64(width))
+
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+	_, _ = fmt.Fprintf(w, "\r[%s] %3.0f%% (%d/%d) | announced %d, failed %d",
+		bar, pct*100, current, total, announced, failed)
+}
+
+// interruptContext returns a context cancelled by SIGINT or SIGTERM, so an
+// announcement run stops cleanly and leaves the rest for --resume.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// newSeedNode starts a P2P node for announcing seeded packages and waits
+// for the DHT to bootstrap
+func newSeedNode(cfg *config.Config, logger *zap.Logger) (*p2p.Node, error) {
+	p2pCfg := &p2p.Config{
+		ListenPort:         cfg.Network.ListenPort,
+		Listen:             listenConfigs(cfg.Network.Listen),
+		Version:            version,
+		BootstrapPeers:     cfg.Network.BootstrapAddrs(),
+		EnableMDNS:         cfg.Privacy.EnableMDNS,
+		PreferQUIC:         true,
+		EnableRelay:        cfg.Network.IsRelayEnabled(),
+		EnableHolePunching: cfg.Network.IsHolePunchingEnabled(),
+		// Cross-NAT: a seeding node is often the publicly-reachable one, so it
+		// is exactly the node that should be relaying for NAT'd peers.
+		EnableAutoRelay:      cfg.Network.IsAutoRelayEnabled(),
+		RelayService:         cfg.Network.GetRelayService(),
+		RelayPeers:           cfg.Network.RelayPeers,
+		RelayMaxReservations: cfg.Network.RelayMaxReservations(),
+		RelayMaxCircuits:     cfg.Network.RelayMaxCircuits(),
+		RelayBufferSize:      cfg.Network.RelayBufferSizeBytes(),
+		RelayDuration:        cfg.Network.RelayDuration(),
+		ForceReachability:    cfg.Network.GetForceReachability(),
+		RelayedTransferMax:   cfg.Network.RelayedTransferMaxBytes(),
+	}
+
+	node, err := p2p.New(context.Background(), p2pCfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize P2P: %w", err)
+	}
+
+	fmt.Println("Waiting for DHT bootstrap...")
+	node.WaitForBootstrap()
+	fmt.Printf("Connected to %d peers\n\n", node.ConnectedPeers())
+	return node, nil
+}
+
+func seedAnnounceCmd(cachePath *string) *cobra.Command {
+	var resume bool
+	var workers int
+	var showProgress bool
+
+	cmd := &cobra.Command{
+		Use:   "announce",
+		Short: "Announce cached packages to the DHT",
+		Long: `Announce cached packages to the DHT without importing anything.
+
+Successful announcements are recorded in the cache. With --resume only
+packages without a current provider record are announced: those an
+interrupted or failed "seed import" did not get to, and those whose record
+has expired.
+
+Examples:
+  debswarm seed announce --resume
+  debswarm seed announce --parallel 64 --progress`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger, err := setupLogger()
+			if err != nil {
+				return err
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			cacheDir := cfg.Cache.Path
+			if *cachePath != "" {
+				cacheDir = *cachePath
+			}
+			c, err := cache.New(cacheDir, cacheMaxSize(&cfg.Cache), logger)
+			if err != nil {
+				return fmt.Errorf("failed to initialize cache: %w", err)
+			}
+			defer func() { _ = c.Close() }()
+
+			var pkgs []*cache.Package
+			if resume {
+				pkgs, err = c.GetUnannounced()
+			} else {
+				pkgs, err = c.List()
+			}
+			if err != nil {
+				return err
+			}
+			if len(pkgs) == 0 {
+				fmt.Println("Nothing to announce.")
+				return nil
+			}
+			hashes := make([]string, len(pkgs))
+			for i, pkg := range pkgs {
+				hashes[i] = pkg.SHA256
+			}
+
+			node, err := newSeedNode(cfg, logger)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = node.Close() }()
+
+			ctx, stop := interruptContext()
+			defer stop()
+			newSeedAnnouncer(node, c, cfg, workers, showProgress).announceAndReport(ctx, hashes)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&resume, "resume", false, "Only announce packages without a current provider record")
+	cmd.Flags().IntVarP(&workers, "parallel", "p", defaultAnnounceWorkers, "Number of concurrent announcements")
+	cmd.Flags().BoolVar(&showProgress, "progress", false, "Show progress bar instead of per-failure output")
+	return cmd
+}
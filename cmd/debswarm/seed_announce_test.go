@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/cache"
+)
+
+// seedTestPackages caches n small packages and returns their hashes
+func seedTestPackages(t *testing.T, c *cache.Cache, n int) []string {
+	t.Helper()
+	hashes := make([]string, n)
+	for i := range hashes {
+		data := []byte(fmt.Sprintf("package %d", i))
+		sum := sha256.Sum256(data)
+		hashes[i] = hex.EncodeToString(sum[:])
+		if err := c.Put(bytes.NewReader(data), hashes[i], fmt.Sprintf("pkg%d_1.0_amd64.deb", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return hashes
+}
+
+func TestSeedAnnouncer(t *testing.T) {
+	c, err := cache.New(t.TempDir(), 1<<20, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	hashes := seedTestPackages(t, c, 20)
+
+	var inFlight, peak int64
+	var out bytes.Buffer
+	a := &seedAnnouncer{
+		provide: func(ctx context.Context, hash string) error {
+			n := atomic.AddInt64(&inFlight, 1)
+			defer atomic.AddInt64(&inFlight, -1)
+			for {
+				p := atomic.LoadInt64(&peak)
+				if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			if hash == hashes[3] {
+				return errors.New("no peers")
+			}
+			return nil
+		},
+		cache:   c,
+		workers: 4,
+		timeout: time.Second,
+		ttl:     time.Hour,
+		out:     &out,
+	}
+
+	announced, failed := a.run(context.Background(), hashes)
+	if announced != 19 || failed != 1 {
+		t.Errorf("announced %d, failed %d; want 19 and 1", announced, failed)
+	}
+	if peak < 2 || peak > 4 {
+		t.Errorf("peak concurrency %d, want 2-4", peak)
+	}
+	if !strings.Contains(out.String(), "no peers") {
+		t.Errorf("failure not reported: %q", out.String())
+	}
+
+	// Only the failed package is left for --resume
+	due, err := c.GetUnannounced()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].SHA256 != hashes[3] {
+		t.Errorf("unannounced = %d packages, want only the failed one", len(due))
+	}
+}
+
+func TestSeedAnnouncer_Interrupted(t *testing.T) {
+	c, err := cache.New(t.TempDir(), 1<<20, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	hashes := seedTestPackages(t, c, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var once sync.Once
+	var out bytes.Buffer
+	a := &seedAnnouncer{
+		provide: func(pctx context.Context, hash string) error {
+			if hash == hashes[2] {
+				once.Do(cancel)
+				<-pctx.Done()
+				return pctx.Err()
+			}
+			return nil
+		},
+		cache:   c,
+		workers: 1,
+		timeout: time.Second,
+		ttl:     time.Hour,
+		out:     &out,
+	}
+
+	a.announceAndReport(ctx, hashes)
+	if !strings.Contains(out.String(), "Announced 2 of 10") || !strings.Contains(out.String(), "seed announce --resume") {
+		t.Errorf("output = %q", out.String())
+	}
+	if strings.Contains(out.String(), "[FAIL]") {
+		t.Errorf("interruption reported as a failure: %q", out.String())
+	}
+	due, err := c.GetUnannounced()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 8 {
+		t.Errorf("%d packages left unannounced, want 8", len(due))
+	}
+}
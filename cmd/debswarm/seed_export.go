@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/hashutil"
+)
+
+// exportManifestName and exportSumsName are written at the top of the
+// destination directory
+const (
+	exportManifestName = "manifest.json"
+	exportSumsName     = "SHA256SUMS"
+)
+
+type seedExportOptions struct {
+	dest    string
+	matches []string  // shell patterns for the package name or file name
+	since   time.Time // only packages cached at or after this; zero for all
+	dryRun  bool
+}
+
+// exportManifest describes an export directory
+type exportManifest struct {
+	Created  time.Time         `json:"created"`
+	Packages []exportedPackage `json:"packages"`
+}
+
+type exportedPackage struct {
+	Path         string `json:"path"` // relative to the export directory
+	Package      string `json:"package,omitempty"`
+	Version      string `json:"version,omitempty"`
+	Architecture string `json:"architecture,omitempty"`
+	Size         int64  `json:"size"`
+	SHA256       string `json:"sha256"`
+}
+
+func seedExportCmd(cachePath *string) *cobra.Command {
+	var opts seedExportOptions
+	var matches []string
+	var since string
+
+	cmd := &cobra.Command{
+		Use:   "export --dest DIR [--match PATTERN]... [--since 30d]",
+		Short: "Copy cached packages out to a directory",
+		Long: `Copy selected packages out of the cache into a plain directory, for
+example a USB drive carried to an air-gapped site.
+
+Packages are laid out like a Debian pool, pool/<prefix>/<name>/<file>.deb,
+with a manifest.json and a SHA256SUMS file (check with sha256sum -c) at the
+top. Every package is verified against its hash while it is copied. Files
+already present with the right size are skipped, so an interrupted export
+can be re-run. On the other side, 'debswarm seed import --recursive DIR'
+imports the lot.
+
+Examples:
+  debswarm seed export --dest /media/usb --match 'linux-image*'
+  debswarm seed export --dest /media/usb --match 'nginx*' --match 'libssl*' --since 30d
+  debswarm seed export --dest /media/usb --since 7d --dry-run`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.dest == "" {
+				return fmt.Errorf("--dest is required")
+			}
+			for _, m := range matches {
+				if _, err := path.Match(m, ""); err != nil {
+					return fmt.Errorf("invalid --match %q: %w", m, err)
+				}
+			}
+			opts.matches = matches
+			if since != "" {
+				t, err := parseSince(since, time.Now())
+				if err != nil {
+					return err
+				}
+				opts.since = t
+			}
+
+			logger, err := setupLogger()
+			if err != nil {
+				return err
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			cacheDir := cfg.Cache.Path
+			if *cachePath != "" {
+				cacheDir = *cachePath
+			}
+			c, err := cache.New(cacheDir, cacheMaxSize(&cfg.Cache), logger)
+			if err != nil {
+				return fmt.Errorf("failed to open cache: %w", err)
+			}
+			defer func() { _ = c.Close() }()
+
+			if opts.dryRun {
+				fmt.Println("DRY-RUN MODE: No files will be written")
+				fmt.Println()
+			}
+			manifest, err := runSeedExport(c, &opts)
+			if err != nil {
+				return err
+			}
+			var total int64
+			for _, p := range manifest.Packages {
+				total += p.Size
+			}
+			verb := "Exported"
+			if opts.dryRun {
+				verb = "Would export"
+			}
+			fmt.Printf("\n%s %d packages (%s) to %s\n", verb, len(manifest.Packages), formatBytes(total), opts.dest)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.dest, "dest", "", "Destination directory (required)")
+	cmd.Flags().StringArrayVar(&matches, "match", nil, "Export packages whose name or file name matches this shell pattern (repeatable; default all)")
+	cmd.Flags().StringVar(&since, "since", "", "Only packages cached within this period (e.g. 30d, 72h, 2026-01-01)")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "List what would be exported without copying")
+	return cmd
+}
+
+// runSeedExport copies the selected packages to opts.dest and writes the
+// manifest and checksum files, which cover every selected package including
+// ones a previous run already copied.
+func runSeedExport(c *cache.Cache, opts *seedExportOptions) (*exportManifest, error) {
+	packages, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &exportManifest{Created: time.Now().UTC()}
+	for _, pkg := range packages {
+		if !exportSelected(pkg, opts) {
+			continue
+		}
+		entry := exportEntry(pkg)
+		dst := filepath.Join(opts.dest, filepath.FromSlash(entry.Path))
+
+		switch {
+		case opts.dryRun:
+			fmt.Printf("  [WOULD EXPORT] %s (%s)\n", entry.Path, formatBytes(entry.Size))
+		case sameSize(dst, entry.Size):
+			fmt.Printf("  [SKIP] %s (already exported)\n", entry.Path)
+		default:
+			if err := exportPackage(c, pkg.SHA256, dst); err != nil {
+				return nil, fmt.Errorf("%s: %w", entry.Path, err)
+			}
+			fmt.Printf("  [OK]   %s (%s)\n", entry.Path, formatBytes(entry.Size))
+		}
+		manifest.Packages = append(manifest.Packages, entry)
+	}
+	sort.Slice(manifest.Packages, func(i, j int) bool {
+		return manifest.Packages[i].Path < manifest.Packages[j].Path
+	})
+
+	if opts.dryRun || len(manifest.Packages) == 0 {
+		return manifest, nil
+	}
+	if err := writeExportManifest(opts.dest, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// exportSelected reports whether pkg passes the --match and --since filters
+func exportSelected(pkg *cache.Package, opts *seedExportOptions) bool {
+	if !opts.since.IsZero() && pkg.AddedAt.Before(opts.since) {
+		return false
+	}
+	if len(opts.matches) == 0 {
+		return true
+	}
+	name, _, _ := packageIdentity(pkg)
+	base := path.Base(pkg.Filename)
+	for _, m := range opts.matches {
+		if ok, _ := path.Match(m, name); ok && name != "" {
+			return true
+		}
+		if ok, _ := path.Match(m, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// packageIdentity returns the package's name, version and architecture,
+// from the cache's metadata or else its file name
+func packageIdentity(pkg *cache.Package) (name, version, arch string) {
+	if pkg.PackageName != "" {
+		return pkg.PackageName, pkg.PackageVersion, pkg.Architecture
+	}
+	name, version, arch, _ = cache.ParseDebFilename(path.Base(pkg.Filename))
+	return name, version, arch
+}
+
+// exportEntry places pkg in a pool layout, pool/<prefix>/<name>/<file>,
+// where prefix is the first letter of the name (four for lib* packages)
+func exportEntry(pkg *cache.Package) exportedPackage {
+	name, version, arch := packageIdentity(pkg)
+	base := path.Base(pkg.Filename)
+	if base == "." || base == ".." || base == "/" || base == "" || strings.Contains(base, `\`) {
+		base = pkg.SHA256 + ".deb"
+	}
+
+	dir := "pool/misc"
+	if name != "" && !strings.ContainsAny(name, `/\`) && name != "." && name != ".." {
+		prefix := name[:1]
+		if strings.HasPrefix(name, "lib") && len(name) > 3 {
+			prefix = name[:4]
+		}
+		dir = "pool/" + prefix + "/" + name
+	}
+	return exportedPackage{
+		Path:         dir + "/" + base,
+		Package:      name,
+		Version:      version,
+		Architecture: arch,
+		Size:         pkg.Size,
+		SHA256:       pkg.SHA256,
+	}
+}
+
+// exportPackage copies a cached package to dst through a temporary file,
+// verifying its hash on the way so a corrupt cache entry is not carried off
+func exportPackage(c *cache.Cache, hash, dst string) error {
+	src, _, err := c.Get(hash)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".export-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	hw := hashutil.NewHashingWriter(tmp)
+	if _, err := io.Copy(hw, src); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if got := hw.Sum(); got != hash {
+		return fmt.Errorf("cached file is corrupt: hash %s, want %s (run 'debswarm cache verify')", got[:12], hash[:12])
+	}
+	// #nosec G302 -- exported packages are meant to be read by other users
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func sameSize(path string, size int64) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Size() == size
+}
+
+// writeExportManifest writes manifest.json and SHA256SUMS
+func writeExportManifest(dest string, manifest *exportManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	// #nosec G306 -- the manifest is meant to be read alongside the packages
+	if err := os.WriteFile(filepath.Join(dest, exportManifestName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	var sums strings.Builder
+	for _, p := range manifest.Packages {
+		fmt.Fprintf(&sums, "%s  %s\n", p.SHA256, p.Path)
+	}
+	// #nosec G306 -- checksums are meant to be read alongside the packages
+	if err := os.WriteFile(filepath.Join(dest, exportSumsName), []byte(sums.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", exportSumsName, err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/cache"
+)
+
+func TestRunSeedExport(t *testing.T) {
+	c, err := cache.New(t.TempDir(), 1<<30, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	put := func(filename string) string {
+		data := []byte("contents of " + filename)
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		if err := c.Put(bytes.NewReader(data), hash, filename); err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	kernel := put("pool/main/l/linux/linux-image-6.1.0-18-amd64_6.1.76-1_amd64.deb")
+	put("libssl3_3.0.11-1_amd64.deb")
+	put("nginx_1.22.1-9_amd64.deb")
+
+	dest := t.TempDir()
+	opts := &seedExportOptions{dest: dest, matches: []string{"linux-image*", "libssl*"}}
+	manifest, err := runSeedExport(c, opts)
+	if err != nil {
+		t.Fatalf("runSeedExport: %v", err)
+	}
+	if len(manifest.Packages) != 2 {
+		t.Fatalf("exported %d packages, want 2: %+v", len(manifest.Packages), manifest.Packages)
+	}
+
+	kernelPath := filepath.Join(dest, "pool", "l", "linux-image-6.1.0-18-amd64", "linux-image-6.1.0-18-amd64_6.1.76-1_amd64.deb")
+	if _, err := os.Stat(kernelPath); err != nil {
+		t.Errorf("kernel package not exported to the pool layout: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "pool", "libs", "libssl3", "libssl3_3.0.11-1_amd64.deb")); err != nil {
+		t.Errorf("lib package not under its four-letter prefix: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "pool", "n")); !os.IsNotExist(err) {
+		t.Error("unmatched package exported")
+	}
+
+	sums, err := os.ReadFile(filepath.Join(dest, exportSumsName))
+	if err != nil || !strings.Contains(string(sums), kernel+"  pool/l/linux-image-6.1.0-18-amd64/") {
+		t.Errorf("SHA256SUMS = %q, %v", sums, err)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, exportManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var onDisk exportManifest
+	if err := json.Unmarshal(data, &onDisk); err != nil || len(onDisk.Packages) != 2 {
+		t.Errorf("manifest = %s, %v", data, err)
+	}
+
+	// --since excludes everything cached before it
+	opts = &seedExportOptions{dest: t.TempDir(), since: time.Now().Add(time.Hour)}
+	if manifest, err := runSeedExport(c, opts); err != nil || len(manifest.Packages) != 0 {
+		t.Errorf("--since in the future exported %d packages, err %v", len(manifest.Packages), err)
+	}
+}
+
+func TestExportEntry_UnsafeNames(t *testing.T) {
+	hash := strings.Repeat("a", 64)
+	for _, filename := range []string{"..", `..\..\evil.deb`, ""} {
+		e := exportEntry(&cache.Package{SHA256: hash, Filename: filename})
+		if strings.Contains(e.Path, "..") || !strings.HasPrefix(e.Path, "pool/misc/") {
+			t.Errorf("exportEntry(%q).Path = %q", filename, e.Path)
+		}
+	}
+}
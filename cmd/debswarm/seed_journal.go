@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// importJournal remembers the size, modification time, inode and hash of
+// every file seed import has processed, so a re-import of a large mirror
+// skips unchanged files instead of reading and hashing them again.
+type importJournal struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]journalEntry // keyed by absolute file path
+	seen    map[string]bool         // files present in this run
+}
+
+type journalEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"` // unix nanoseconds
+	Inode   uint64 `json:"inode,omitempty"`
+	SHA256  string `json:"sha256"`
+}
+
+// loadImportJournal reads the journal at path. A missing or unreadable
+// journal starts empty, which only costs a full re-hash.
+func loadImportJournal(path string) *importJournal {
+	j := &importJournal{
+		path:    path,
+		entries: make(map[string]journalEntry),
+		seen:    make(map[string]bool),
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- journal lives in the cache directory
+	if err != nil {
+		return j
+	}
+	var entries map[string]journalEntry
+	if err := json.Unmarshal(data, &entries); err == nil && entries != nil {
+		j.entries = entries
+	}
+	return j
+}
+
+func journalKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// unchanged returns the recorded hash of path if its size, modification time
+// and inode still match the journal
+func (j *importJournal) unchanged(path string, info os.FileInfo) (string, bool) {
+	key := journalKey(path)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.seen[key] = true
+	e, ok := j.entries[key]
+	if !ok || e.Size != info.Size() || e.ModTime != info.ModTime().UnixNano() || e.Inode != fileInode(info) {
+		return "", false
+	}
+	return e.SHA256, true
+}
+
+// record stores the hash of path as of info
+func (j *importJournal) record(path string, info os.FileInfo, hash string) {
+	key := journalKey(path)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.seen[key] = true
+	j.entries[key] = journalEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Inode:   fileInode(info),
+		SHA256:  hash,
+	}
+}
+
+// missing returns journaled files under roots that were not seen this run,
+// i.e. files removed from the source since the last import
+func (j *importJournal) missing(roots []string) []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var gone []string
+	for key := range j.entries {
+		if !j.seen[key] && underAnyRoot(key, roots) {
+			gone = append(gone, key)
+		}
+	}
+	sort.Strings(gone)
+	return gone
+}
+
+// save drops the missing files' entries and writes the journal atomically
+func (j *importJournal) save(roots []string) error {
+	for _, key := range j.missing(roots) {
+		delete(j.entries, key)
+	}
+	j.mu.Lock()
+	data, err := json.Marshal(j.entries)
+	j.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+func underAnyRoot(path string, roots []string) bool {
+	for _, root := range roots {
+		root = journalKey(root)
+		if path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// importReport reconciles one seed import run against its source
+type importReport struct {
+	Started       time.Time       `json:"started"`
+	Duration      string          `json:"duration"`
+	Scanned       int64           `json:"scanned"`
+	Unchanged     int64           `json:"unchanged"` // skipped via the journal without reading
+	Imported      int64           `json:"imported"`
+	AlreadyCached int64           `json:"already_cached"`
+	Failed        int64           `json:"failed"`
+	BytesHashed   int64           `json:"bytes_hashed"`
+	BytesImported int64           `json:"bytes_imported"`
+	Failures      []importFailure `json:"failures,omitempty"`
+	Removed       []string        `json:"removed_from_source,omitempty"` // journaled files no longer present
+}
+
+type importFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// print writes the reconciliation summary
+func (r *importReport) print(elapsed time.Duration) {
+	fmt.Printf("\nReconciliation:\n")
+	fmt.Printf("  Scanned:        %d files\n", r.Scanned)
+	fmt.Printf("  Unchanged:      %d (skipped via import journal)\n", r.Unchanged)
+	fmt.Printf("  Imported:       %d (%s)\n", r.Imported, formatBytes(r.BytesImported))
+	fmt.Printf("  Already cached: %d\n", r.AlreadyCached)
+	fmt.Printf("  Failed:         %d\n", r.Failed)
+	if secs := elapsed.Seconds(); secs > 0 && r.BytesHashed > 0 {
+		fmt.Printf("  Hashed:         %s in %s (%s/s)\n",
+			formatBytes(r.BytesHashed), elapsed.Round(time.Second), formatBytes(int64(float64(r.BytesHashed)/secs)))
+	}
+	if len(r.Removed) > 0 {
+		fmt.Printf("  Gone from source since last import: %d\n", len(r.Removed))
+	}
+	for _, f := range r.Failures {
+		fmt.Printf("    [FAIL] %s: %s\n", f.Path, f.Error)
+	}
+}
+
+// write saves the report as JSON
+func (r *importReport) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/debpkg"
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+// A file named .deb that is not a package (here an HTML error page saved by
+// a failed download) is rejected instead of being seeded.
+func TestProcessDebFile_RejectsInvalid(t *testing.T) {
+	c, err := cache.New(t.TempDir(), 1<<20, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	path := filepath.Join(t.TempDir(), "hello_2.10-3_amd64.deb")
+	if err := os.WriteFile(path, []byte("<html>404 Not Found</html>"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := processDebFile(c, path, false, nil, nil); !errors.Is(err, debpkg.ErrInvalid) {
+		t.Errorf("processDebFile = %v, want debpkg.ErrInvalid", err)
+	}
+	if c.Count() != 0 {
+		t.Error("invalid file was cached")
+	}
+}
+
+func TestCheckIndexedHash(t *testing.T) {
+	const goodHash = "1111111111111111111111111111111111111111111111111111111111111111"
+	const otherHash = "2222222222222222222222222222222222222222222222222222222222222222"
+	known := index.New("", zap.NewNop())
+	packages := "Package: hello\nVersion: 1:2.10-3\nArchitecture: amd64\nFilename: pool/main/h/hello/hello_2.10-3_amd64.deb\nSHA256: " + goodHash + "\n\n"
+	if err := known.LoadFromData([]byte(packages), "http://deb.debian.org/debian/dists/stable/main/binary-amd64/Packages"); err != nil {
+		t.Fatal(err)
+	}
+
+	hello := &debpkg.Control{Package: "hello", Version: "1:2.10-3", Architecture: "amd64"}
+	if err := checkIndexedHash(known, hello, goodHash); err != nil {
+		t.Errorf("matching package rejected: %v", err)
+	}
+	if err := checkIndexedHash(known, hello, otherHash); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("tampered package: err = %v", err)
+	}
+	impostor := &debpkg.Control{Package: "bash", Version: "5.2", Architecture: "amd64"}
+	if err := checkIndexedHash(known, impostor, goodHash); err == nil {
+		t.Error("hash listed for another package accepted")
+	}
+	unlisted := &debpkg.Control{Package: "local-tool", Version: "1.0", Architecture: "all"}
+	if err := checkIndexedHash(known, unlisted, otherHash); err != nil {
+		t.Errorf("unlisted package rejected: %v", err)
+	}
+}
+
+// buildTestDeb returns a minimal valid .deb for the given control fields
+func buildTestDeb(t *testing.T, pkg, version, arch string) []byte {
+	t.Helper()
+	var ctl bytes.Buffer
+	gz := gzip.NewWriter(&ctl)
+	tw := tar.NewWriter(gz)
+	control := fmt.Sprintf("Package: %s\nVersion: %s\nArchitecture: %s\n", pkg, version, arch)
+	if err := tw.WriteHeader(&tar.Header{Name: "./control", Mode: 0o644, Size: int64(len(control)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = tw.Write([]byte(control))
+	_ = tw.Close()
+	_ = gz.Close()
+
+	var deb bytes.Buffer
+	deb.WriteString("!<arch>\n")
+	for _, m := range []struct {
+		name string
+		data []byte
+	}{{"debian-binary", []byte("2.0\n")}, {"control.tar.gz", ctl.Bytes()}, {"data.tar.xz", bytes.Repeat([]byte(pkg), 4096)}} {
+		fmt.Fprintf(&deb, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", m.name, 0, 0, 0, "100644", len(m.data))
+		deb.Write(m.data)
+		if len(m.data)%2 == 1 {
+			deb.WriteByte('\n')
+		}
+	}
+	return deb.Bytes()
+}
+
+func TestProcessDebFile_Imports(t *testing.T) {
+	cacheDir := t.TempDir()
+	c, err := cache.New(cacheDir, 1<<30, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	// Renamed file: metadata must come from the control file
+	path := filepath.Join(t.TempDir(), "download.deb")
+	if err := os.WriteFile(path, buildTestDeb(t, "hello", "2.10-3", "amd64"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hash, _, err := processDebFile(c, path, false, nil, nil)
+	if err != nil {
+		t.Fatalf("processDebFile: %v", err)
+	}
+	if !c.Has(hash) {
+		t.Fatal("package not cached")
+	}
+	if pkg, err := c.GetByNameVersionArch("hello", "2.10-3", "amd64"); err != nil || pkg.SHA256 != hash {
+		t.Errorf("control metadata not recorded: %v", err)
+	}
+	if pending, _ := os.ReadDir(filepath.Join(cacheDir, "packages", "pending")); len(pending) != 0 {
+		t.Errorf("%d files left in the pending directory", len(pending))
+	}
+	if _, _, err := processDebFile(c, path, false, nil, nil); err == nil || err.Error() != "already cached" {
+		t.Errorf("second import: err = %v, want already cached", err)
+	}
+}
+
+func TestImportJournal(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "pool")
+	if err := os.MkdirAll(src, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	a := filepath.Join(src, "a_1_all.deb")
+	b := filepath.Join(src, "b_1_all.deb")
+	for _, p := range []string{a, b} {
+		if err := os.WriteFile(p, []byte(p), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stat := func(p string) os.FileInfo {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+
+	journalPath := filepath.Join(dir, "journal.json")
+	j := loadImportJournal(journalPath)
+	if _, ok := j.unchanged(a, stat(a)); ok {
+		t.Error("empty journal reported a file unchanged")
+	}
+	j.record(a, stat(a), "hash-a")
+	j.record(b, stat(b), "hash-b")
+	if err := j.save([]string{src}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Next run: a is unchanged and b was modified
+	j = loadImportJournal(journalPath)
+	if hash, ok := j.unchanged(a, stat(a)); !ok || hash != "hash-a" {
+		t.Errorf("unchanged(a) = %q, %v", hash, ok)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(b, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := j.unchanged(b, stat(b)); ok {
+		t.Error("modified file reported unchanged")
+	}
+
+	// A run that only sees a reports b gone from the source
+	j = loadImportJournal(journalPath)
+	j.unchanged(a, stat(a))
+	if gone := j.missing([]string{src}); len(gone) != 1 || gone[0] != journalKey(b) {
+		t.Errorf("missing = %v, want [%s]", gone, b)
+	}
+	if gone := j.missing([]string{filepath.Join(dir, "other")}); len(gone) != 0 {
+		t.Errorf("missing outside the roots = %v", gone)
+	}
+	if err := j.save([]string{src}); err != nil {
+		t.Fatal(err)
+	}
+	if j = loadImportJournal(journalPath); len(j.entries) != 1 {
+		t.Errorf("journal kept %d entries, want 1 after pruning", len(j.entries))
+	}
+}
+
+func TestReadahead(t *testing.T) {
+	data := make([]byte, 5*readaheadSize/2)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	ra := newReadahead(bytes.NewReader(data), readaheadSize, readaheadDepth)
+	defer ra.Close()
+	got, err := io.ReadAll(ra)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("read %d bytes (err %v), want the %d written", len(got), err, len(data))
+	}
+
+	// Closing early must not leave the reader goroutine blocked
+	early := newReadahead(bytes.NewReader(data), 1024, 1)
+	buf := make([]byte, 10)
+	if _, err := early.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	_ = early.Close()
+}
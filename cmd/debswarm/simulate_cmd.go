@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/debswarm/debswarm/internal/proxy"
+)
+
+// maxSimulateLine bounds one line of a log read by "debswarm simulate"
+const maxSimulateLine = 1 << 20
+
+func simulateCmd() *cobra.Command {
+	var (
+		list       bool
+		jsonOutput bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "simulate [FILE...]",
+		Short: "Report what the daemon would do with a list of requests, without doing it",
+		Long: `Read request URLs from files, or standard input, and report for each what the
+running daemon would do with it right now: serve it from the cache, look it
+up on peers, fetch it from the mirror, or refuse it. Nothing is downloaded or
+looked up, so the answer depends only on the daemon's configuration, cache
+and package indices, and the same state always gives the same answer.
+
+Each line's first http:// or https:// URL is used, so plain URL lists, the
+output of 'apt-get --print-uris' and proxy access logs all work; lines
+without a URL are skipped. Requests are simulated in order: a package an
+earlier request would have fetched counts as cached for later ones.
+
+Requires the daemon to be running with metrics enabled.
+
+Examples:
+  apt-get --print-uris -qq install build-essential | debswarm simulate
+  debswarm simulate --list /var/log/squid-deb-proxy/access.log`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			urls, skipped, err := readSimulateInput(args)
+			if err != nil {
+				return err
+			}
+			if len(urls) == 0 {
+				return fmt.Errorf("no request URLs found in the input")
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Metrics.Port == 0 {
+				return fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+			}
+			body, err := json.Marshal(proxy.SimulateRequest{URLs: urls})
+			if err != nil {
+				return err
+			}
+			apiURL := fmt.Sprintf("http://%s:%d/api/simulate", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port)
+			var sim proxy.Simulation
+			if err := peerLabelRequest(&http.Client{Timeout: 2 * time.Minute}, http.MethodPost, apiURL, body, &sim); err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(sim)
+			}
+			printSimulation(os.Stdout, &sim, skipped, list)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&list, "list", false, "List every request with its outcome")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	return cmd
+}
+
+// readSimulateInput reads the request URLs from files, or standard input
+// when there are none or for "-". It returns the URLs and how many lines
+// had none.
+func readSimulateInput(files []string) ([]string, int, error) {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var urls []string
+	skipped := 0
+	for _, name := range files {
+		r := io.Reader(os.Stdin)
+		var f *os.File
+		if name != "-" {
+			var err error
+			if f, err = os.Open(name); err != nil { // #nosec G304 -- user-specified log file
+				return nil, 0, err
+			}
+			r = f
+		}
+		found, n, err := scanRequestURLs(r)
+		if f != nil {
+			_ = f.Close()
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", name, err)
+		}
+		urls = append(urls, found...)
+		skipped += n
+	}
+	return urls, skipped, nil
+}
+
+// scanRequestURLs returns the request URL of each line of r, and how many
+// lines had none. Blank lines and comments are ignored.
+func scanRequestURLs(r io.Reader) ([]string, int, error) {
+	var urls []string
+	skipped := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxSimulateLine)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if u := requestURLFromLine(line); u != "" {
+			urls = append(urls, u)
+		} else {
+			skipped++
+		}
+	}
+	return urls, skipped, scanner.Err()
+}
+
+// requestURLFromLine returns the first http:// or https:// URL in a log
+// line, without the quotes or punctuation around it.
+func requestURLFromLine(line string) string {
+	start := -1
+	for _, scheme := range []string{"http://", "https://"} {
+		if i := strings.Index(line, scheme); i >= 0 && (start < 0 || i < start) {
+			start = i
+		}
+	}
+	if start < 0 {
+		return ""
+	}
+	rest := line[start:]
+	if end := strings.IndexAny(rest, " \t'\"<>,;[]{}|\\"); end >= 0 {
+		rest = rest[:end]
+	}
+	if strings.TrimPrefix(strings.TrimPrefix(rest, "http://"), "https://") == "" {
+		return ""
+	}
+	return rest
+}
+
+func printSimulation(w io.Writer, sim *proxy.Simulation, skipped int, list bool) {
+	total := len(sim.Requests)
+	fmt.Fprintf(w, "Simulated %d requests", total)
+	if skipped > 0 {
+		fmt.Fprintf(w, " (%d lines without a URL skipped)", skipped)
+	}
+	fmt.Fprintln(w)
+	switch {
+	case sim.Offline:
+		fmt.Fprintln(w, "The daemon is in offline mode: the mirror is never used")
+	case !sim.P2P:
+		fmt.Fprintln(w, "P2P is paused or not running: peers are not asked")
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "%-12s  %8s  %6s  %10s\n", "OUTCOME", "REQUESTS", "SHARE", "SIZE")
+	for _, t := range sim.Totals {
+		share := 0.0
+		if total > 0 {
+			share = float64(t.Requests) / float64(total) * 100
+		}
+		size := "-"
+		if t.Bytes > 0 {
+			size = formatBytes(t.Bytes)
+		}
+		fmt.Fprintf(w, "%-12s  %8d  %5.1f%%  %10s\n", t.Outcome, t.Requests, share, size)
+	}
+	fmt.Fprintln(w, "\nSizes cover the requests whose size is known: indexed packages and cached files.")
+
+	if !list {
+		return
+	}
+	fmt.Fprintln(w)
+	for _, req := range sim.Requests {
+		fmt.Fprintf(w, "%-10s  %s\n            %s\n", req.Outcome, req.URL, req.Reason)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/proxy"
+)
+
+func TestRequestURLFromLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"http://deb.debian.org/debian/pool/main/c/curl/curl_7.88_amd64.deb", "http://deb.debian.org/debian/pool/main/c/curl/curl_7.88_amd64.deb"},
+		// apt-get --print-uris
+		{"'http://deb.debian.org/debian/pool/main/m/make/make_4.3_amd64.deb' make_4.3_amd64.deb 396844 SHA256:abc", "http://deb.debian.org/debian/pool/main/m/make/make_4.3_amd64.deb"},
+		// squid access log
+		{"1697000000.123    45 10.0.0.5 TCP_MISS/200 396844 GET http://deb.debian.org/debian/dists/stable/InRelease - HIER_DIRECT/1.2.3.4 -", "http://deb.debian.org/debian/dists/stable/InRelease"},
+		// JSON log
+		{`{"level":"debug","url":"https://security.debian.org/pool/x.deb","class":"package"}`, "https://security.debian.org/pool/x.deb"},
+		{"Reading package lists...", ""},
+		{"see http:// for details", ""},
+	}
+	for _, tt := range tests {
+		if got := requestURLFromLine(tt.line); got != tt.want {
+			t.Errorf("requestURLFromLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestScanRequestURLs(t *testing.T) {
+	input := "# comment\n\nhttp://a.example/pool/a.deb\nno url here\n  http://b.example/pool/b.deb  \n"
+	urls, skipped, err := scanRequestURLs(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 2 || urls[1] != "http://b.example/pool/b.deb" || skipped != 1 {
+		t.Errorf("urls = %q, skipped = %d", urls, skipped)
+	}
+}
+
+func TestPrintSimulation(t *testing.T) {
+	sim := &proxy.Simulation{
+		P2P: true,
+		Totals: []proxy.SimulationTotal{
+			{Outcome: proxy.OutcomeCache, Requests: 3, Bytes: 3 << 20},
+			{Outcome: proxy.OutcomeP2P, Requests: 1, Bytes: 1 << 20},
+		},
+		Requests: []proxy.SimulatedRequest{
+			{URL: "http://deb.debian.org/debian/pool/a.deb", Outcome: proxy.OutcomeCache, Reason: "cached"},
+			{URL: "http://deb.debian.org/debian/pool/b.deb", Outcome: proxy.OutcomeCache, Reason: "cached"},
+			{URL: "http://deb.debian.org/debian/pool/c.deb", Outcome: proxy.OutcomeCache, Reason: "cached"},
+			{URL: "http://deb.debian.org/debian/pool/d.deb", Outcome: proxy.OutcomeP2P, Reason: "not cached: fetched from peers"},
+		},
+	}
+	var buf bytes.Buffer
+	printSimulation(&buf, sim, 2, true)
+	out := buf.String()
+	for _, want := range []string{"Simulated 4 requests (2 lines without a URL skipped)", "75.0%", "25.0%", "pool/d.deb", "fetched from peers"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "not running") {
+		t.Error("P2P reported as not running")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// clientStatResponse matches one entry of the /api/clients JSON.
+type clientStatResponse struct {
+	Address     string    `json:"address"`
+	Profile     string    `json:"profile"`
+	Requests    int64     `json:"requests"`
+	BytesServed int64     `json:"bytes_served"`
+	MaxRate     int64     `json:"max_rate"`
+	Policy      string    `json:"policy"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+func statsClientsCmd() *cobra.Command {
+	var (
+		top        int
+		jsonOutput bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "clients",
+		Short: "Show the proxy's traffic per client",
+		Long: `Show the requests and response bytes of each client of the proxy since
+the daemon started, busiest first, with the [[clients]] profile it belongs
+to, its rate limit and the source policy the profile forces.
+
+Clients are identified by IP address. The daemon keeps the 1024 clients
+seen most recently.
+
+Requires the daemon to be running with metrics enabled.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if top <= 0 {
+				return fmt.Errorf("--top must be positive")
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Metrics.Port == 0 {
+				return fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+			}
+
+			endpoint := fmt.Sprintf("http://%s:%d/api/clients?top=%d", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port, top)
+			client := &http.Client{Timeout: 10 * time.Second}
+			list, raw, err := fetchClientStats(client, endpoint)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				fmt.Println(string(raw))
+				return nil
+			}
+			printClientStats(list)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&top, "top", 50, "Number of clients to show")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output raw JSON")
+	return cmd
+}
+
+func fetchClientStats(client *http.Client, endpoint string) ([]clientStatResponse, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("daemon not running or metrics disabled: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status %d from daemon", resp.StatusCode)
+	}
+
+	var list []clientStatResponse
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse client stats: %w", err)
+	}
+	return list, body, nil
+}
+
+func printClientStats(list []clientStatResponse) {
+	if len(list) == 0 {
+		fmt.Println("No clients seen yet")
+		return
+	}
+
+	fmt.Printf(" %-39s  %-16s  %8s  %10s  %12s  %-11s  %s\n",
+		"ADDRESS", "PROFILE", "REQUESTS", "SERVED", "RATE LIMIT", "POLICY", "LAST SEEN")
+	for _, c := range list {
+		profile, policy := c.Profile, c.Policy
+		if profile == "" {
+			profile = "-"
+		}
+		if policy == "" {
+			policy = "-"
+		}
+		fmt.Printf(" %-39s  %-16s  %8d  %10s  %12s  %-11s  %s\n",
+			c.Address, profile, c.Requests, formatBytes(c.BytesServed), formatRate(c.MaxRate),
+			policy, c.LastSeen.Local().Format("2006-01-02 15:04"))
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchClientStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/clients" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"address":"10.1.2.3","profile":"lab","requests":12,"bytes_served":4096,"max_rate":1048576,"policy":"cache-only","last_seen":"2026-10-18T09:30:00Z"}]`))
+	}))
+	defer srv.Close()
+
+	list, _, err := fetchClientStats(srv.Client(), srv.URL+"/api/clients?top=5")
+	if err != nil {
+		t.Fatalf("fetchClientStats: %v", err)
+	}
+	if len(list) != 1 || list[0].Address != "10.1.2.3" || list[0].Profile != "lab" || list[0].BytesServed != 4096 || list[0].MaxRate != 1<<20 {
+		t.Errorf("list = %+v", list)
+	}
+
+	if _, _, err := fetchClientStats(srv.Client(), srv.URL+"/api/other"); err == nil {
+		t.Error("error status was not reported")
+	}
+}
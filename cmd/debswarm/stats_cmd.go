@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/debswarm/debswarm/internal/units"
 )
 
 // statsResponse matches the JSON from the /stats endpoint.
@@ -32,6 +34,8 @@ type statsResponse struct {
 		NextWindowOpen time.Time `json:"NextWindowOpen"`
 		Timezone       string    `json:"Timezone"`
 		WindowCount    int       `json:"WindowCount"`
+		OneOffWindow   string    `json:"OneOffWindow"`
+		Exception      string    `json:"Exception"`
 	} `json:"scheduler,omitempty"`
 	Fleet *struct {
 		InFlightCount int `json:"InFlightCount"`
@@ -42,7 +46,7 @@ type statsResponse struct {
 func statsCmd() *cobra.Command {
 	var (
 		watch      bool
-		interval   time.Duration
+		interval   units.DurationFlag
 		jsonOutput bool
 	)
 
@@ -71,14 +75,17 @@ machine-readable output.`,
 				return fetchAndPrint(client, url, jsonOutput)
 			}
 
-			return watchStats(client, url, interval, jsonOutput)
+			return watchStats(client, url, time.Duration(interval), jsonOutput)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Continuously refresh stats")
-	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "Refresh interval (with --watch)")
+	interval = units.DurationFlag(2 * time.Second)
+	cmd.Flags().Var(&interval, "interval", "Refresh interval (with --watch)")
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output raw JSON")
 
+	cmd.AddCommand(statsPackagesCmd())
+	cmd.AddCommand(statsClientsCmd())
 	return cmd
 }
 
@@ -198,10 +205,17 @@ func printStats(stats *statsResponse, subtitle string) {
 	}
 
 	if stats.Scheduler != nil {
-		if stats.Scheduler.InWindow {
-			fmt.Printf("Scheduler:  in window (rate: %s/s)\n",
-				formatBytes(stats.Scheduler.CurrentRate))
-		} else {
+		switch {
+		case stats.Scheduler.OneOffWindow != "":
+			fmt.Printf("Scheduler:  in one-off window %s (rate: %s)\n",
+				stats.Scheduler.OneOffWindow, formatRate(stats.Scheduler.CurrentRate))
+		case stats.Scheduler.InWindow:
+			fmt.Printf("Scheduler:  in window (rate: %s)\n",
+				formatRate(stats.Scheduler.CurrentRate))
+		case stats.Scheduler.Exception != "":
+			fmt.Printf("Scheduler:  exception %s (rate: %s)\n",
+				stats.Scheduler.Exception, formatRate(stats.Scheduler.CurrentRate))
+		default:
 			fmt.Printf("Scheduler:  outside window\n")
 		}
 	}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// packageStatResponse matches one entry of the /api/stats/packages JSON.
+type packageStatResponse struct {
+	SHA256        string    `json:"sha256"`
+	Filename      string    `json:"filename"`
+	Downloads     int64     `json:"downloads"`
+	Uploads       int64     `json:"uploads"`
+	BytesUploaded int64     `json:"bytes_uploaded"`
+	LastServed    time.Time `json:"last_served"`
+}
+
+func statsPackagesCmd() *cobra.Command {
+	var (
+		top        int
+		jsonOutput bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "packages",
+		Short: "Show the packages this node serves most",
+		Long: `Show which packages this node serves most, from the daemon's persistent
+per-package statistics: DOWNLOADS counts packages served to local APT
+clients (cache hits included), UPLOADS complete copies sent to peers.
+Counts outlive the packages' cache entries, so they can inform retention
+and prefetch policy.
+
+Requires the daemon to be running with metrics enabled.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if top <= 0 {
+				return fmt.Errorf("--top must be positive")
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Metrics.Port == 0 {
+				return fmt.Errorf("metrics are disabled in configuration (metrics.port = 0)")
+			}
+
+			endpoint := fmt.Sprintf("http://%s:%d/api/stats/packages?top=%d", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port, top)
+			client := &http.Client{Timeout: 10 * time.Second}
+			list, raw, err := fetchPackageStats(client, endpoint)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				fmt.Println(string(raw))
+				return nil
+			}
+			printPackageStats(list)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&top, "top", 50, "Number of packages to show")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output raw JSON")
+	return cmd
+}
+
+func fetchPackageStats(client *http.Client, endpoint string) ([]packageStatResponse, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("daemon not running or metrics disabled: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status %d from daemon", resp.StatusCode)
+	}
+
+	var list []packageStatResponse
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse package stats: %w", err)
+	}
+	return list, body, nil
+}
+
+func printPackageStats(list []packageStatResponse) {
+	if len(list) == 0 {
+		fmt.Println("No packages served yet")
+		return
+	}
+
+	fmt.Printf(" %-48s  %9s  %7s  %10s  %s\n", "PACKAGE", "DOWNLOADS", "UPLOADS", "UPLOADED", "LAST SERVED")
+	for _, p := range list {
+		name := path.Base(p.Filename)
+		if p.Filename == "" {
+			name = p.SHA256[:min(16, len(p.SHA256))] + "..."
+		}
+		if len(name) > 48 {
+			name = name[:45] + "..."
+		}
+		fmt.Printf(" %-48s  %9d  %7d  %10s  %s\n",
+			name, p.Downloads, p.Uploads, formatBytes(p.BytesUploaded), p.LastServed.Local().Format("2006-01-02 15:04"))
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchPackageStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/stats/packages" || r.URL.Query().Get("top") != "2" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"sha256":"abc","filename":"pool/main/c/curl/curl_8.0_amd64.deb","downloads":7,"uploads":3,"bytes_uploaded":900,"last_served":"2026-10-18T09:30:00Z"}]`))
+	}))
+	defer srv.Close()
+
+	list, _, err := fetchPackageStats(srv.Client(), srv.URL+"/api/stats/packages?top=2")
+	if err != nil {
+		t.Fatalf("fetchPackageStats: %v", err)
+	}
+	if len(list) != 1 || list[0].Filename != "pool/main/c/curl/curl_8.0_amd64.deb" || list[0].Downloads != 7 || list[0].Uploads != 3 {
+		t.Errorf("list = %+v", list)
+	}
+
+	if _, _, err := fetchPackageStats(srv.Client(), srv.URL+"/api/stats/packages?top=9"); err == nil {
+		t.Error("error status was not reported")
+	}
+}
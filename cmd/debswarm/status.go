@@ -2,8 +2,14 @@ package main
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/debswarm/debswarm/internal/config"
+	"github.com/debswarm/debswarm/internal/p2p"
 )
 
 func statusCmd() *cobra.Command {
@@ -36,7 +42,74 @@ func statusCmd() *cobra.Command {
 				fmt.Printf("\nMetrics:        disabled\n")
 			}
 
+			if cfg.Scheduler.Power.Enabled {
+				printPowerStatus(cfg)
+			}
+
 			return nil
 		},
 	}
 }
+
+// printPowerStatus prints the power policy configured and, when the daemon
+// can be asked, the power source and network it sees and the upload policy
+// in force.
+func printPowerStatus(cfg *config.Config) {
+	pwr := cfg.Scheduler.Power
+	fmt.Printf("\nPower Policy:   on battery %s, on metered %s (throttle %s)\n",
+		pwr.GetOnBattery(), pwr.GetOnMetered(), formatRate(pwr.ThrottleRateBytes()))
+	if cfg.Metrics.Port <= 0 {
+		fmt.Printf("Power State:    unknown (metrics disabled)\n")
+		return
+	}
+
+	var stats struct {
+		Power *p2p.PowerState `json:"power"`
+	}
+	endpoint := fmt.Sprintf("http://%s:%d/stats", loopbackHost(cfg.Metrics.Bind), cfg.Metrics.Port)
+	client := &http.Client{Timeout: 5 * time.Second}
+	if err := peerLabelRequest(client, http.MethodGet, endpoint, nil, &stats); err != nil {
+		fmt.Printf("Power State:    unknown (%v)\n", err)
+		return
+	}
+	if stats.Power == nil {
+		fmt.Printf("Power State:    unknown (restart the daemon to apply the power policy)\n")
+		return
+	}
+	source, network := describePower(*stats.Power)
+	fmt.Printf("Power State:    %s, %s\n", source, network)
+	fmt.Printf("Uploads:        %s\n", describeUploadPolicy(*stats.Power))
+}
+
+// describePower describes the power source and network in st.
+func describePower(st p2p.PowerState) (source, network string) {
+	switch {
+	case st.PowerError != "":
+		source = "AC power (assumed: " + st.PowerError + ")"
+	case st.OnBattery:
+		source = "on battery"
+	default:
+		source = "AC power"
+	}
+	switch {
+	case st.NetworkError != "":
+		network = "unmetered (assumed: " + st.NetworkError + ")"
+	case st.Metered:
+		network = "metered connection"
+	default:
+		network = "unmetered connection"
+	}
+	return source, network
+}
+
+// describeUploadPolicy describes the upload policy in st and why it holds.
+func describeUploadPolicy(st p2p.PowerState) string {
+	policy := st.Policy
+	if st.Policy == p2p.UploadsThrottled {
+		policy += " to " + formatRate(st.UploadRate)
+	}
+	if len(st.Reasons) > 0 {
+		policy += " (" + strings.Join(st.Reasons, ", ") + ")"
+	}
+	return policy
+}
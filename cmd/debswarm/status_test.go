@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/p2p"
+)
+
+func TestDescribePower(t *testing.T) {
+	source, network := describePower(p2p.PowerState{OnBattery: true, Metered: true})
+	if source != "on battery" || network != "metered connection" {
+		t.Errorf("describePower = %q, %q", source, network)
+	}
+	source, network = describePower(p2p.PowerState{NetworkError: "NetworkManager not available"})
+	if source != "AC power" || network != "unmetered (assumed: NetworkManager not available)" {
+		t.Errorf("describePower without NetworkManager = %q, %q", source, network)
+	}
+
+	st := p2p.PowerState{Policy: p2p.UploadsThrottled, Reasons: []string{"battery"}, UploadRate: 256 * 1024}
+	if got := describeUploadPolicy(st); got != "throttled to 256.0 KB/s (battery)" {
+		t.Errorf("describeUploadPolicy = %q", got)
+	}
+	if got := describeUploadPolicy(p2p.PowerState{Policy: p2p.UploadsNormal}); got != "normal" {
+		t.Errorf("describeUploadPolicy = %q", got)
+	}
+}
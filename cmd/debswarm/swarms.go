@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/config"
+	"github.com/debswarm/debswarm/internal/p2p"
+	"github.com/debswarm/debswarm/internal/proxy"
+)
+
+// startSwarms starts a P2P node for each [[swarms]] entry. Each node takes
+// the main node's settings, with its own port (for every listener), key, bootstrap and relay
+// peers, and its own identity under <data dir>/swarms/<name>. Peer scores and
+// learned timeouts are shared with the main node.
+func startSwarms(ctx context.Context, cfg *config.Config, base p2p.Config, logger *zap.Logger) ([]proxy.Swarm, error) {
+	var swarms []proxy.Swarm
+	for _, sc := range cfg.Swarms {
+		nodeCfg := base
+		nodeCfg.ListenPort = sc.ListenPort
+		// Same listeners as the main node, on the swarm's own port
+		nodeCfg.Listen = make([]p2p.ListenConfig, len(base.Listen))
+		for i, l := range base.Listen {
+			l.Port = 0
+			nodeCfg.Listen[i] = l
+		}
+		nodeCfg.DataDir = filepath.Join(base.DataDir, "swarms", sc.Name)
+		nodeCfg.PrivateKey = nil
+		nodeCfg.IdentitySigner = ""
+		nodeCfg.PSK = nil
+		nodeCfg.BootstrapPeers = (&config.NetworkConfig{BootstrapPeers: sc.BootstrapPeers}).BootstrapAddrs()
+		nodeCfg.RelayPeers = sc.RelayPeers
+		if sc.PSKPath != "" {
+			psk, err := p2p.LoadPSK(sc.PSKPath)
+			if err != nil {
+				closeSwarms(swarms)
+				return nil, fmt.Errorf("swarm %s: failed to load PSK: %w", sc.Name, err)
+			}
+			nodeCfg.PSK = psk
+		}
+
+		node, err := p2p.New(ctx, &nodeCfg, logger.With(zap.String("swarm", sc.Name)))
+		if err != nil {
+			closeSwarms(swarms)
+			return nil, fmt.Errorf("swarm %s: failed to initialize P2P node: %w", sc.Name, err)
+		}
+		logger.Info("Joined additional swarm",
+			zap.String("swarm", sc.Name),
+			zap.String("peerID", node.PeerID().String()),
+			zap.Int("port", sc.ListenPort),
+			zap.Strings("origins", sc.Origins))
+		swarms = append(swarms, proxy.Swarm{Name: sc.Name, Node: node, Origins: sc.Origins})
+	}
+	return swarms, nil
+}
+
+func closeSwarms(swarms []proxy.Swarm) {
+	for _, sw := range swarms {
+		_ = sw.Node.Close()
+	}
+}
@@ -0,0 +1,189 @@
+// Package accesslog writes one structured line per proxy request to an
+// access log: what was asked for, how it was classified, where it was
+// served from, how many bytes, how long it took and which peers supplied
+// it. Successful requests are sampled and all lines are rate capped, so
+// busy proxies can keep it on; failed requests are never sampled out.
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Entry is one proxy request
+type Entry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	TraceID   string    `json:"trace_id,omitempty"`
+	Client    string    `json:"client"`
+	Method    string    `json:"method"`
+	URL       string    `json:"url"`
+	// Class is the artifact class, e.g. "package" or "index"
+	Class string `json:"class,omitempty"`
+	// Source is where the response came from: "cache", "peer", "mirror"
+	// or "mixed"; empty when nothing was served
+	Source     string   `json:"source,omitempty"`
+	Status     int      `json:"status"`
+	Bytes      int64    `json:"bytes"`
+	DurationMS int64    `json:"duration_ms"`
+	Peers      []string `json:"peers,omitempty"`
+	// Suppressed is the number of entries dropped by the rate cap since
+	// the previous line
+	Suppressed int64 `json:"suppressed,omitempty"`
+}
+
+// Config configures a Logger
+type Config struct {
+	Path string
+	// MaxSizeMB is the size at which the file is rotated (default: 100)
+	MaxSizeMB int
+	// MaxBackups is the number of rotated files kept (default: 5)
+	MaxBackups int
+	// SampleRate is the fraction of successful requests logged, from 0 to
+	// 1. Requests answered with a 4xx or 5xx status are always logged.
+	SampleRate float64
+	// MaxPerSecond caps the lines written per second (0 = unlimited);
+	// entries over the cap are counted in the next line's Suppressed
+	MaxPerSecond int
+}
+
+// Logger writes entries to a rotated JSON Lines file
+type Logger struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+	sampleRate float64
+	limiter    *rate.Limiter // nil when unlimited
+	random     func() float64
+
+	mu         sync.Mutex
+	file       *os.File
+	written    int64
+	suppressed int64
+}
+
+// New opens the access log at cfg.Path, creating its directory
+func New(cfg Config) (*Logger, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("access log path is required")
+	}
+	if cfg.MaxSizeMB <= 0 {
+		cfg.MaxSizeMB = 100
+	}
+	if cfg.MaxBackups <= 0 {
+		cfg.MaxBackups = 5
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create access log directory: %w", err)
+	}
+
+	l := &Logger{
+		path:       cfg.Path,
+		maxBytes:   int64(cfg.MaxSizeMB) * 1024 * 1024,
+		maxBackups: cfg.MaxBackups,
+		sampleRate: cfg.SampleRate,
+		random:     rand.Float64,
+	}
+	if cfg.MaxPerSecond > 0 {
+		l.limiter = rate.NewLimiter(rate.Limit(cfg.MaxPerSecond), cfg.MaxPerSecond)
+	}
+	if err := l.openFile(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Logger) openFile() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open access log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to stat access log: %w", err)
+	}
+	l.file = f
+	l.written = info.Size()
+	return nil
+}
+
+// Sampled reports whether a request answered with status would be logged
+// by the sampling, before the rate cap
+func (l *Logger) Sampled(status int) bool {
+	return status >= 400 || l.sampleRate >= 1 || l.random() < l.sampleRate
+}
+
+// Log writes e unless sampling or the rate cap drops it
+func (l *Logger) Log(e Entry) {
+	if !l.Sampled(e.Status) {
+		return
+	}
+	line, err := json.Marshal(&e)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return
+	}
+	if l.limiter != nil && !l.limiter.Allow() {
+		l.suppressed++
+		return
+	}
+	if l.suppressed > 0 {
+		e.Suppressed = l.suppressed
+		if line, err = json.Marshal(&e); err != nil {
+			return
+		}
+		l.suppressed = 0
+	}
+
+	if l.written >= l.maxBytes {
+		_ = l.rotate() // keep writing to the current file if rotation fails
+	}
+	n, _ := l.file.Write(append(line, '\n'))
+	l.written += int64(n)
+}
+
+// rotate moves the log to .1, shifting older backups up and dropping the
+// oldest
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close access log for rotation: %w", err)
+	}
+	for i := l.maxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	renameErr := os.Rename(l.path, l.path+".1")
+	_ = os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxBackups+1))
+	if err := l.openFile(); err != nil {
+		l.file = nil
+		return err
+	}
+	if renameErr != nil && !os.IsNotExist(renameErr) {
+		return fmt.Errorf("failed to rotate access log: %w", renameErr)
+	}
+	return nil
+}
+
+// Close closes the log file
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
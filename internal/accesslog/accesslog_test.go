@@ -0,0 +1,138 @@
+package accesslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readEntries(t *testing.T, path string) []Entry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []Entry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("bad line %q: %v", sc.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "access.log")
+	l, err := New(Config{Path: path, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Log(Entry{
+		Time:       time.Now(),
+		Method:     "GET",
+		URL:        "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-3_amd64.deb",
+		Class:      "package",
+		Source:     "peer",
+		Status:     200,
+		Bytes:      53000,
+		DurationMS: 120,
+		Peers:      []string{"12D3KooWA", "12D3KooWB"},
+	})
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readEntries(t, path)
+	if len(entries) != 1 {
+		t.Fatalf("entries = %d, want 1", len(entries))
+	}
+	if e := entries[0]; e.Source != "peer" || e.Bytes != 53000 || len(e.Peers) != 2 || e.Suppressed != 0 {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	l, err := New(Config{Path: path, SampleRate: 0.25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	draws := []float64{0.1, 0.5, 0.9, 0.2}
+	l.random = func() float64 {
+		d := draws[0]
+		draws = draws[1:]
+		return d
+	}
+	for i := 0; i < 4; i++ {
+		l.Log(Entry{URL: "ok", Status: 200})
+	}
+	l.Log(Entry{URL: "missing", Status: 404})
+	l.Log(Entry{URL: "failed", Status: 502})
+	_ = l.Close()
+
+	var urls []string
+	for _, e := range readEntries(t, path) {
+		urls = append(urls, e.URL)
+	}
+	if got := strings.Join(urls, ","); got != "ok,ok,missing,failed" {
+		t.Errorf("logged %s, want the two sampled successes and both failures", got)
+	}
+}
+
+func TestRateCap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	l, err := New(Config{Path: path, SampleRate: 1, MaxPerSecond: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		l.Log(Entry{Status: 200})
+	}
+	time.Sleep(600 * time.Millisecond) // refill one token
+	l.Log(Entry{Status: 200})
+	_ = l.Close()
+
+	entries := readEntries(t, path)
+	if len(entries) != 3 {
+		t.Fatalf("entries = %d, want 3", len(entries))
+	}
+	if entries[2].Suppressed != 3 {
+		t.Errorf("suppressed = %d, want 3", entries[2].Suppressed)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	l, err := New(Config{Path: path, SampleRate: 1, MaxSizeMB: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.maxBytes = 100
+	for i := 0; i < 10; i++ {
+		l.Log(Entry{URL: strings.Repeat("x", 60), Status: 200})
+	}
+	_ = l.Close()
+
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s: %v", filepath.Base(p), err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("backups beyond max_backups should be removed")
+	}
+}
+
+func TestNewRequiresPath(t *testing.T) {
+	if _, err := New(Config{}); err == nil {
+		t.Error("New without a path should fail")
+	}
+}
@@ -8,12 +8,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/debswarm/debswarm/internal/cache"
 	"github.com/debswarm/debswarm/internal/hashutil"
 	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/iothrottle"
 )
 
 // DefaultAPTArchivesPath is the standard location for APT's package cache
@@ -25,12 +28,29 @@ type Importer struct {
 	cache        *cache.Cache
 	index        *index.Index
 	logger       *zap.Logger
+	throttle     *iothrottle.Throttle // nil = unlimited
+
+	// mu serializes imports (startup and APT hook notifications may overlap)
+	// and guards seen.
+	mu sync.Mutex
+	// seen records files already imported or found in the cache, so repeat
+	// scans skip them without re-hashing.
+	seen map[string]fileStamp
+}
+
+// fileStamp identifies one version of a file in the archives directory.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
 }
 
 // Config holds configuration for the APT archives importer
 type Config struct {
 	// Path to APT archives directory (default: /var/cache/apt/archives)
 	ArchivesPath string
+	// Throttle paces reading the archives, so a large import does not
+	// saturate the disk (nil = unlimited)
+	Throttle *iothrottle.Throttle
 }
 
 // ImportResult contains statistics from an import operation
@@ -40,6 +60,9 @@ type ImportResult struct {
 	Skipped    int // Already in cache
 	Unverified int // Not in index (hash unknown)
 	Errors     int // Failed to import
+
+	// Hashes lists the SHA256 of each package imported by this run.
+	Hashes []string
 }
 
 // New creates a new APT archives importer
@@ -48,12 +71,18 @@ func New(c *cache.Cache, idx *index.Index, logger *zap.Logger, cfg *Config) *Imp
 	if cfg != nil && cfg.ArchivesPath != "" {
 		path = cfg.ArchivesPath
 	}
+	var throttle *iothrottle.Throttle
+	if cfg != nil {
+		throttle = cfg.Throttle
+	}
 
 	return &Importer{
 		archivesPath: path,
 		cache:        c,
 		index:        idx,
 		logger:       logger.Named("aptarchives"),
+		throttle:     throttle,
+		seen:         make(map[string]fileStamp),
 	}
 }
 
@@ -61,7 +90,13 @@ func New(c *cache.Cache, idx *index.Index, logger *zap.Logger, cfg *Config) *Imp
 // It only imports packages that:
 // - Are not already in the cache
 // - Have a known hash in the index (for verification)
+//
+// Files handled by an earlier call and unchanged since are counted as skipped
+// without being hashed again, so calling Import after every APT run is cheap.
 func (i *Importer) Import(ctx context.Context) (*ImportResult, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
 	result := &ImportResult{}
 
 	// Check if directory exists
@@ -107,13 +142,25 @@ func (i *Importer) Import(ctx context.Context) (*ImportResult, error) {
 
 		result.Scanned++
 
+		var stamp fileStamp
+		if fi, err := entry.Info(); err == nil {
+			stamp = fileStamp{size: fi.Size(), modTime: fi.ModTime()}
+			if i.seen[name] == stamp {
+				result.Skipped++
+				continue
+			}
+		}
+
 		// Import the package
-		status := i.importPackage(filepath.Join(i.archivesPath, name))
+		status, hash := i.importPackage(ctx, filepath.Join(i.archivesPath, name))
 		switch status {
 		case statusImported:
 			result.Imported++
+			result.Hashes = append(result.Hashes, hash)
+			i.seen[name] = stamp
 		case statusSkipped:
 			result.Skipped++
+			i.seen[name] = stamp
 		case statusUnverified:
 			result.Unverified++
 		case statusError:
@@ -141,8 +188,9 @@ const (
 	statusError
 )
 
-// importPackage attempts to import a single .deb file
-func (i *Importer) importPackage(path string) importStatus {
+// importPackage attempts to import a single .deb file, returning its hash
+// when it got far enough to compute one.
+func (i *Importer) importPackage(ctx context.Context, path string) (importStatus, string) {
 	filename := filepath.Base(path)
 
 	// Get file info for size
@@ -151,16 +199,16 @@ func (i *Importer) importPackage(path string) importStatus {
 		i.logger.Debug("Failed to stat file",
 			zap.String("file", filename),
 			zap.Error(err))
-		return statusError
+		return statusError, ""
 	}
 
 	// Compute hash
-	hash, err := i.computeHash(path)
+	hash, err := i.computeHash(ctx, path)
 	if err != nil {
 		i.logger.Debug("Failed to compute hash",
 			zap.String("file", filename),
 			zap.Error(err))
-		return statusError
+		return statusError, ""
 	}
 
 	// Check if already in cache
@@ -168,7 +216,7 @@ func (i *Importer) importPackage(path string) importStatus {
 		i.logger.Debug("Package already in cache",
 			zap.String("file", filename),
 			zap.String("hash", hash[:16]+"..."))
-		return statusSkipped
+		return statusSkipped, hash
 	}
 
 	// Look up in index to verify this is a known package
@@ -180,7 +228,7 @@ func (i *Importer) importPackage(path string) importStatus {
 			i.logger.Debug("Package not in index or hash mismatch, skipping",
 				zap.String("file", filename),
 				zap.String("hash", hash[:16]+"..."))
-			return statusUnverified
+			return statusUnverified, hash
 		}
 	}
 
@@ -191,7 +239,7 @@ func (i *Importer) importPackage(path string) importStatus {
 		i.logger.Debug("Failed to open file",
 			zap.String("file", filename),
 			zap.Error(err))
-		return statusError
+		return statusError, hash
 	}
 	defer f.Close()
 
@@ -201,11 +249,11 @@ func (i *Importer) importPackage(path string) importStatus {
 		cacheFilename = pkg.Filename
 	}
 
-	if err := i.cache.Put(f, hash, cacheFilename); err != nil {
+	if err := i.cache.Put(i.throttle.Reader(ctx, f), hash, cacheFilename); err != nil {
 		i.logger.Debug("Failed to import package",
 			zap.String("file", filename),
 			zap.Error(err))
-		return statusError
+		return statusError, hash
 	}
 
 	i.logger.Debug("Imported package from APT archives",
@@ -213,11 +261,11 @@ func (i *Importer) importPackage(path string) importStatus {
 		zap.String("hash", hash[:16]+"..."),
 		zap.Int64("size", info.Size()))
 
-	return statusImported
+	return statusImported, hash
 }
 
 // computeHash computes the SHA256 hash of a file
-func (i *Importer) computeHash(path string) (string, error) {
+func (i *Importer) computeHash(ctx context.Context, path string) (string, error) {
 	// #nosec G304 -- path is constructed from configured directory + filename from os.ReadDir, not user input
 	f, err := os.Open(path)
 	if err != nil {
@@ -225,5 +273,5 @@ func (i *Importer) computeHash(path string) (string, error) {
 	}
 	defer f.Close()
 
-	return hashutil.HashReader(f)
+	return hashutil.HashReader(i.throttle.Reader(ctx, f))
 }
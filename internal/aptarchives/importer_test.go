@@ -5,11 +5,13 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/debswarm/debswarm/internal/cache"
 	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/iothrottle"
 )
 
 func testLogger() *zap.Logger {
@@ -225,7 +227,7 @@ func TestImport_WithKnownPackage(t *testing.T) {
 	// Create a Packages file that includes our test package
 	// First, we need to know the hash
 	importer := New(c, idx, testLogger(), &Config{ArchivesPath: archivesDir})
-	hash, err := importer.computeHash(debPath)
+	hash, err := importer.computeHash(context.Background(), debPath)
 	if err != nil {
 		t.Fatalf("Failed to compute hash: %v", err)
 	}
@@ -293,7 +295,7 @@ func TestImport_AlreadyInCache(t *testing.T) {
 	importer := New(c, idx, testLogger(), &Config{ArchivesPath: archivesDir})
 
 	// Compute hash and pre-cache the package
-	hash, err := importer.computeHash(debPath)
+	hash, err := importer.computeHash(context.Background(), debPath)
 	if err != nil {
 		t.Fatalf("Failed to compute hash: %v", err)
 	}
@@ -361,3 +363,111 @@ func TestImport_Cancellation(t *testing.T) {
 		t.Errorf("Expected context.Canceled error, got: %v", err)
 	}
 }
+
+func TestImport_RescanSkipsSeenFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	archivesDir := filepath.Join(tmpDir, "archives")
+	if err := os.MkdirAll(archivesDir, 0755); err != nil {
+		t.Fatalf("Failed to create archives dir: %v", err)
+	}
+	debPath := filepath.Join(archivesDir, "seen-pkg_1.0.0_amd64.deb")
+	if err := os.WriteFile(debPath, []byte("Package imported once, then seen."), 0644); err != nil {
+		t.Fatalf("Failed to create deb file: %v", err)
+	}
+
+	cacheDir := filepath.Join(tmpDir, "cache")
+	c, err := cache.New(cacheDir, 100*1024*1024, testLogger()) // 100MB
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	idx := index.New(cacheDir, testLogger())
+	importer := New(c, idx, testLogger(), &Config{ArchivesPath: archivesDir})
+	hash, err := importer.computeHash(context.Background(), debPath)
+	if err != nil {
+		t.Fatalf("Failed to compute hash: %v", err)
+	}
+	packagesPath := filepath.Join(tmpDir, "Packages")
+	packagesContent := "Package: seen-pkg\nVersion: 1.0.0\nArchitecture: amd64\n" +
+		"Filename: pool/main/s/seen-pkg/seen-pkg_1.0.0_amd64.deb\nSHA256: " + hash + "\n\n"
+	if err := os.WriteFile(packagesPath, []byte(packagesContent), 0644); err != nil {
+		t.Fatalf("Failed to write Packages file: %v", err)
+	}
+	if err := idx.LoadFromFile(packagesPath); err != nil {
+		t.Fatalf("Failed to load Packages file: %v", err)
+	}
+
+	result, err := importer.Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Imported != 1 || len(result.Hashes) != 1 || result.Hashes[0] != hash {
+		t.Fatalf("first import = %+v, want 1 imported with hash %s", result, hash)
+	}
+
+	// Change the content but keep size and mtime: a rescan that re-hashed
+	// would now report the file as unverified.
+	info, err := os.Stat(debPath)
+	if err != nil {
+		t.Fatalf("Failed to stat deb: %v", err)
+	}
+	if err := os.WriteFile(debPath, []byte("Package imported once, then SEEN."), 0644); err != nil {
+		t.Fatalf("Failed to rewrite deb: %v", err)
+	}
+	if err := os.Chtimes(debPath, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("Failed to reset mtime: %v", err)
+	}
+	result, err = importer.Import(context.Background())
+	if err != nil {
+		t.Fatalf("Rescan failed: %v", err)
+	}
+	if result.Scanned != 1 || result.Skipped != 1 || result.Unverified != 0 || len(result.Hashes) != 0 {
+		t.Errorf("rescan = %+v, want 1 scanned and skipped without hashing", result)
+	}
+}
+
+func TestImport_Throttled(t *testing.T) {
+	tmpDir := t.TempDir()
+	archivesDir := filepath.Join(tmpDir, "archives")
+	if err := os.MkdirAll(archivesDir, 0755); err != nil {
+		t.Fatalf("Failed to create archives dir: %v", err)
+	}
+	debPath := filepath.Join(archivesDir, "throttled_1.0_amd64.deb")
+	if err := os.WriteFile(debPath, []byte("throttled deb content"), 0644); err != nil {
+		t.Fatalf("Failed to create deb file: %v", err)
+	}
+
+	c, err := cache.New(filepath.Join(tmpDir, "cache"), 100*1024*1024, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+	idx := index.New("", testLogger())
+
+	// Two reads per second. Hashing here takes one, the import's hashing
+	// the other, so its copy into the cache waits half a second.
+	throttle := iothrottle.New(iothrottle.Limits{IOPS: 2})
+	importer := New(c, idx, testLogger(), &Config{ArchivesPath: archivesDir, Throttle: throttle})
+	hash, err := importer.computeHash(context.Background(), debPath)
+	if err != nil {
+		t.Fatalf("Failed to compute hash: %v", err)
+	}
+	packages := "Package: throttled\nVersion: 1.0\nArchitecture: amd64\n" +
+		"Filename: pool/main/t/throttled/throttled_1.0_amd64.deb\nSHA256: " + hash + "\n\n"
+	if err := idx.LoadFromData([]byte(packages), "Packages"); err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+
+	start := time.Now()
+	result, err := importer.Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Imported != 1 {
+		t.Errorf("Imported = %d, want 1", result.Imported)
+	}
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("throttled import took %v, want about 500ms", d)
+	}
+}
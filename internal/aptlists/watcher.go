@@ -119,6 +119,16 @@ func (w *Watcher) Start(ctx context.Context) error {
 	return nil
 }
 
+// Load parses every index in the APT lists directory once, without watching
+// for changes, and returns how many files it parsed. A missing directory
+// loads nothing.
+func (w *Watcher) Load() (int, error) {
+	if _, err := os.Stat(w.listsPath); os.IsNotExist(err) {
+		return 0, nil
+	}
+	return w.scanAll()
+}
+
 // Stop stops the watcher
 func (w *Watcher) Stop() {
 	if w.cancel != nil {
@@ -0,0 +1,113 @@
+package artifact
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+// Arch resolves Arch Linux packages from a repository's sync database,
+// <repo>.db, a tarball with a desc file per package giving its file name,
+// size and SHA256. pacman checks the package signatures itself.
+type Arch struct {
+	t *table
+}
+
+// NewArch returns an empty Arch adapter.
+func NewArch() *Arch {
+	return &Arch{t: newTable()}
+}
+
+func (a *Arch) Name() string        { return "arch" }
+func (a *Arch) ContentType() string { return "application/octet-stream" }
+
+func (a *Arch) Classify(rawURL string) Kind {
+	return classifyArch(rawURL)
+}
+
+func classifyArch(rawURL string) Kind {
+	name := baseName(rawURL)
+	switch {
+	case strings.HasSuffix(name, ".sig"):
+		return KindOther
+	case strings.Contains(name, ".pkg.tar"):
+		return KindArtifact
+	case strings.HasSuffix(name, ".db") || strings.HasSuffix(trimCompression(name), ".db.tar"):
+		return KindMetadata
+	default:
+		return KindOther
+	}
+}
+
+// Load parses a sync database. Packages sit in the same directory as it.
+func (a *Arch) Load(rawURL string, data []byte) error {
+	dir := dirURL(rawURL)
+	r, err := index.DecompressByMagic(data)
+	if err != nil {
+		return err
+	}
+	artifacts := make(map[string]Artifact)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read sync database: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg || !strings.HasSuffix(hdr.Name, "/desc") {
+			continue
+		}
+		pkg, ok, err := parseArchDesc(tr)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		if ok {
+			if u := joinURL(dir, pkg.Filename); u != "" {
+				artifacts[u] = pkg
+			}
+		}
+	}
+	a.t.replace(rawURL, artifacts)
+	return nil
+}
+
+// parseArchDesc reads the %FILENAME%, %CSIZE% and %SHA256SUM% fields of a
+// desc file. Each field is a %NAME% line followed by value lines up to a
+// blank line.
+func parseArchDesc(r io.Reader) (Artifact, bool, error) {
+	var a Artifact
+	var field string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+			field = ""
+		case strings.HasPrefix(line, "%") && strings.HasSuffix(line, "%"):
+			field = line
+		case field == "%FILENAME%":
+			a.Filename = line
+		case field == "%CSIZE%":
+			a.Size, _ = strconv.ParseInt(line, 10, 64)
+		case field == "%SHA256SUM%":
+			a.SHA256 = strings.ToLower(line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return Artifact{}, false, err
+	}
+	ok := validSHA256(a.SHA256) && a.Filename != "" && !strings.Contains(a.Filename, "/")
+	return a, ok, nil
+}
+
+func (a *Arch) Resolve(rawURL string) (Artifact, bool) {
+	return a.t.resolve(rawURL)
+}
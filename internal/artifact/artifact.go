@@ -0,0 +1,228 @@
+// Package artifact resolves the SHA256 of artifacts from packaging
+// ecosystems other than APT, for the proxy's generic content-addressed mode.
+//
+// Each ecosystem is an Adapter. The proxy hands it the repository metadata
+// clients fetch through it (RPM repodata, Arch sync databases, Nix narinfo
+// files, OCI image manifests); the adapter records the hash and size each
+// lists for its artifacts, so a later request for one can be verified,
+// cached and shared over the swarm exactly like a .deb.
+package artifact
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Kind is what a repository path is to an adapter.
+type Kind int
+
+const (
+	// KindOther is anything the adapter does not handle, passed through
+	KindOther Kind = iota
+	// KindMetadata lists artifacts and their hashes, and is handed to Load
+	KindMetadata
+	// KindArtifact is a file verified against the metadata
+	KindArtifact
+)
+
+// Artifact is an entry from repository metadata.
+type Artifact struct {
+	SHA256   string
+	Size     int64  // 0 when the metadata does not say
+	Filename string // path relative to the repository, recorded in the cache
+}
+
+// Adapter understands one ecosystem's repository layout.
+type Adapter interface {
+	// Name is the adapter's name in the config and in /generic/<name>/ URLs
+	Name() string
+	// ContentType is sent with artifacts served from the cache or swarm
+	ContentType() string
+	// Classify says what the file at a repository URL or path is
+	Classify(rawURL string) Kind
+	// Load records the artifacts listed in a metadata file fetched from
+	// rawURL, replacing what an earlier copy of the same file listed
+	Load(rawURL string, data []byte) error
+	// Resolve returns the artifact at rawURL, if loaded metadata lists it
+	Resolve(rawURL string) (Artifact, bool)
+}
+
+// Relayer is implemented by adapters whose repositories answer according to
+// request headers (content negotiation, bearer tokens). The proxy relays
+// their requests for anything but artifacts to the upstream uncached, with
+// the client's headers and the upstream's status, and forwards the request
+// headers when it fetches their artifacts.
+type Relayer interface {
+	// RelayHeaders are the request headers forwarded upstream and the
+	// response headers relayed back
+	RelayHeaders() (request, response []string)
+}
+
+// MaxEntries bounds the artifacts an adapter remembers. Past it, the
+// metadata files loaded longest ago are forgotten first.
+const MaxEntries = 1 << 20
+
+var constructors = map[string]func() Adapter{
+	"rpm":  func() Adapter { return NewRPM() },
+	"arch": func() Adapter { return NewArch() },
+	"nix":  func() Adapter { return NewNix() },
+	"oci":  func() Adapter { return NewOCI() },
+}
+
+// Names returns the names of the built-in adapters, sorted.
+func Names() []string {
+	names := make([]string, 0, len(constructors))
+	for name := range constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns a new built-in adapter by name.
+func New(name string) (Adapter, error) {
+	ctor, ok := constructors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown artifact adapter %q (want one of %s)", name, strings.Join(Names(), ", "))
+	}
+	return ctor(), nil
+}
+
+// IsArtifactURL reports whether any built-in adapter classifies rawURL as
+// an artifact. The proxy uses it to give such artifacts their own class.
+func IsArtifactURL(rawURL string) bool {
+	return classifyRPM(rawURL) == KindArtifact || classifyArch(rawURL) == KindArtifact ||
+		classifyNix(rawURL) == KindArtifact || classifyOCI(rawURL) == KindArtifact
+}
+
+// urlKey is the key artifacts are stored under: host and path, without
+// scheme, credentials or query, so http and https URLs of a repository
+// resolve alike.
+func urlKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Host) + path.Clean("/"+u.Path)
+}
+
+// baseName returns the last path segment of rawURL, lowercased and without
+// its query
+func baseName(rawURL string) string {
+	if i := strings.IndexByte(rawURL, '?'); i >= 0 {
+		rawURL = rawURL[:i]
+	}
+	return strings.ToLower(path.Base(rawURL))
+}
+
+// trimCompression removes a compression suffix from a file name
+func trimCompression(name string) string {
+	for _, ext := range []string{".gz", ".xz", ".bz2", ".zst", ".lz4"} {
+		if s, ok := strings.CutSuffix(name, ext); ok {
+			return s
+		}
+	}
+	return name
+}
+
+// table holds the artifacts adapters resolve, grouped by the metadata file
+// that listed them, so reloading a file replaces its entries.
+type table struct {
+	mu       sync.RWMutex
+	entries  map[string]Artifact // by urlKey
+	bySource map[string][]string // metadata urlKey -> artifact keys
+	order    []string            // metadata keys, oldest first
+	count    int
+	max      int
+}
+
+func newTable() *table {
+	return &table{entries: make(map[string]Artifact), bySource: make(map[string][]string), max: MaxEntries}
+}
+
+// replace records the artifacts listed by the metadata file at source,
+// keyed by artifact URL.
+func (t *table) replace(source string, artifacts map[string]Artifact) {
+	src := urlKey(source)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.bySource[src]; ok {
+		t.dropLocked(src)
+	}
+	keys := make([]string, 0, len(artifacts))
+	for rawURL, a := range artifacts {
+		if k := urlKey(rawURL); k != "" {
+			t.entries[k] = a
+			keys = append(keys, k)
+		}
+	}
+	t.bySource[src] = keys
+	t.order = append(t.order, src)
+	t.count += len(keys)
+	for t.count > t.max && len(t.order) > 1 {
+		t.dropLocked(t.order[0])
+	}
+}
+
+func (t *table) dropLocked(src string) {
+	for _, k := range t.bySource[src] {
+		delete(t.entries, k)
+	}
+	t.count -= len(t.bySource[src])
+	delete(t.bySource, src)
+	for i, s := range t.order {
+		if s == src {
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (t *table) resolve(rawURL string) (Artifact, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	a, ok := t.entries[urlKey(rawURL)]
+	return a, ok
+}
+
+// validSHA256 reports whether s is a lowercase hex SHA256
+func validSHA256(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// joinURL returns the URL of ref, a path relative to the directory URL dir
+// or an absolute http(s) URL. A relative path is joined as is, so file names
+// with a colon (Arch epochs) are not taken for a URL scheme.
+func joinURL(dir, ref string) string {
+	if u, err := url.Parse(ref); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return u.String()
+	}
+	joined, err := url.JoinPath(dir, ref)
+	if err != nil {
+		return ""
+	}
+	return joined
+}
+
+// dirURL returns the URL of the directory holding rawURL
+func dirURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	u.RawQuery = ""
+	u.Path = path.Dir(u.Path)
+	return u.String()
+}
@@ -0,0 +1,251 @@
+package artifact
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	hashA = "1111111111111111111111111111111111111111111111111111111111111111"
+	hashB = "2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+const samplePrimary = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="3">
+<package type="rpm">
+  <name>hello</name>
+  <arch>x86_64</arch>
+  <checksum type="sha256" pkgid="YES">` + hashA + `</checksum>
+  <size package="72541" installed="189405" archive="190128"/>
+  <location href="Packages/h/hello-2.12.1-2.fc39.x86_64.rpm"/>
+  <format><rpm:license>GPLv3+</rpm:license></format>
+</package>
+<package type="rpm">
+  <name>remote</name>
+  <checksum type="sha256" pkgid="YES">` + hashB + `</checksum>
+  <size package="100"/>
+  <location xml:base="https://other.example/pool/" href="remote-1.0.rpm"/>
+</package>
+<package type="rpm">
+  <name>oldsum</name>
+  <checksum type="sha1" pkgid="YES">aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa</checksum>
+  <location href="Packages/o/oldsum-1.0.rpm"/>
+</package>
+</metadata>
+`
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRPM(t *testing.T) {
+	a := NewRPM()
+	base := "https://dl.example.org/fedora/39/x86_64/os"
+	for rawURL, want := range map[string]Kind{
+		base + "/repodata/abc-primary.xml.zst":              KindMetadata,
+		base + "/repodata/repomd.xml":                       KindOther,
+		base + "/Packages/h/hello-2.12.1-2.fc39.x86_64.rpm": KindArtifact,
+	} {
+		if got := a.Classify(rawURL); got != want {
+			t.Errorf("Classify(%s) = %v, want %v", rawURL, got, want)
+		}
+	}
+
+	var compressed bytes.Buffer
+	zw, _ := zstd.NewWriter(&compressed)
+	_, _ = zw.Write([]byte(samplePrimary))
+	_ = zw.Close()
+	if err := a.Load(base+"/repodata/abc-primary.xml.zst", compressed.Bytes()); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	got, ok := a.Resolve("http://dl.example.org/fedora/39/x86_64/os/Packages/h/hello-2.12.1-2.fc39.x86_64.rpm")
+	if !ok || got.SHA256 != hashA || got.Size != 72541 || got.Filename != "Packages/h/hello-2.12.1-2.fc39.x86_64.rpm" {
+		t.Errorf("Resolve(hello) = %+v, %v", got, ok)
+	}
+	if got, ok := a.Resolve("https://other.example/pool/remote-1.0.rpm"); !ok || got.SHA256 != hashB {
+		t.Errorf("Resolve(xml:base package) = %+v, %v", got, ok)
+	}
+	if _, ok := a.Resolve(base + "/Packages/o/oldsum-1.0.rpm"); ok {
+		t.Error("a package without a SHA256 checksum resolved")
+	}
+
+	// A newer copy of the same metadata replaces the old entries
+	if err := a.Load(base+"/repodata/abc-primary.xml.zst", []byte(`<metadata></metadata>`)); err != nil {
+		t.Fatalf("Load(empty): %v", err)
+	}
+	if _, ok := a.Resolve(base + "/Packages/h/hello-2.12.1-2.fc39.x86_64.rpm"); ok {
+		t.Error("entry survived a reload of its metadata")
+	}
+}
+
+func TestArch(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, body := range map[string]string{
+		"hello-2.12.1-1/desc": "%FILENAME%\nhello-2.12.1-1-x86_64.pkg.tar.zst\n\n%NAME%\nhello\n\n%CSIZE%\n60512\n\n%SHA256SUM%\n" + hashA + "\n\n",
+		"epoch-1:2.0-1/desc":  "%FILENAME%\nepoch-1:2.0-1-any.pkg.tar.zst\n\n%SHA256SUM%\n" + hashB + "\n",
+		"nosum-1.0-1/desc":    "%FILENAME%\nnosum-1.0-1-any.pkg.tar.zst\n",
+	} {
+		_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg})
+		_, _ = tw.Write([]byte(body))
+	}
+	_ = tw.Close()
+
+	a := NewArch()
+	dir := "https://mirror.example/archlinux/extra/os/x86_64"
+	for rawURL, want := range map[string]Kind{
+		dir + "/extra.db":                              KindMetadata,
+		dir + "/extra.db.tar.gz":                       KindMetadata,
+		dir + "/hello-2.12.1-1-x86_64.pkg.tar.zst":     KindArtifact,
+		dir + "/hello-2.12.1-1-x86_64.pkg.tar.zst.sig": KindOther,
+		dir + "/extra.files":                           KindOther,
+	} {
+		if got := a.Classify(rawURL); got != want {
+			t.Errorf("Classify(%s) = %v, want %v", rawURL, got, want)
+		}
+	}
+
+	if err := a.Load(dir+"/extra.db", gzipBytes(t, buf.Bytes())); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got, ok := a.Resolve(dir + "/hello-2.12.1-1-x86_64.pkg.tar.zst"); !ok || got.SHA256 != hashA || got.Size != 60512 {
+		t.Errorf("Resolve(hello) = %+v, %v", got, ok)
+	}
+	// pacman may or may not escape the epoch colon
+	for _, u := range []string{dir + "/epoch-1:2.0-1-any.pkg.tar.zst", dir + "/epoch-1%3A2.0-1-any.pkg.tar.zst"} {
+		if got, ok := a.Resolve(u); !ok || got.SHA256 != hashB {
+			t.Errorf("Resolve(%s) = %+v, %v", u, got, ok)
+		}
+	}
+	if _, ok := a.Resolve(dir + "/nosum-1.0-1-any.pkg.tar.zst"); ok {
+		t.Error("a package without a SHA256 resolved")
+	}
+}
+
+func TestNix(t *testing.T) {
+	a := NewNix()
+	root := "https://cache.example.org"
+	if a.Classify(root+"/abc.narinfo") != KindMetadata || a.Classify(root+"/nar/abc.nar.xz") != KindArtifact || a.Classify(root+"/nix-cache-info") != KindOther {
+		t.Error("Classify gave the wrong kind")
+	}
+
+	// The SHA256 of empty input in Nix base32
+	narinfo := strings.Join([]string{
+		"StorePath: /nix/store/abc-hello-2.12.1",
+		"URL: nar/0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73.nar.xz",
+		"Compression: xz",
+		"FileHash: sha256:0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73",
+		"FileSize: 50088",
+		"NarHash: sha256:1w1fff338fvdw53sqgamddn1b2xgds473pv6y13gizdbqjv4i5p3",
+	}, "\n")
+	if err := a.Load(root+"/abc.narinfo", []byte(narinfo)); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	got, ok := a.Resolve(root + "/nar/0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73.nar.xz")
+	if !ok || got.SHA256 != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" || got.Size != 50088 {
+		t.Errorf("Resolve = %+v, %v", got, ok)
+	}
+
+	for _, bad := range []string{"URL: nar/x.nar\n", "URL: nar/x.nar\nFileHash: md5:abc\n", "URL: nar/x.nar\nFileHash: sha256:zzzz\n"} {
+		if err := a.Load(root+"/bad.narinfo", []byte(bad)); err == nil {
+			t.Errorf("Load(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestOCI(t *testing.T) {
+	a := NewOCI()
+	repo := "https://registry.example/v2/library/alpine"
+	for rawURL, want := range map[string]Kind{
+		repo + "/blobs/sha256:" + hashA:     KindArtifact,
+		repo + "/manifests/3.20":            KindMetadata,
+		repo + "/manifests/sha256:" + hashB: KindMetadata,
+		repo + "/blobs/uploads/":            KindOther,
+		repo + "/blobs/sha512:abc":          KindOther,
+		"https://registry.example/v2/":      KindOther,
+	} {
+		if got := a.Classify(rawURL); got != want {
+			t.Errorf("Classify(%s) = %v, want %v", rawURL, got, want)
+		}
+	}
+
+	// Blobs resolve from their URL alone
+	got, ok := a.Resolve(repo + "/blobs/sha256:" + hashA)
+	if !ok || got.SHA256 != hashA || got.Size != 0 || got.Filename != "library/alpine@sha256:"+hashA {
+		t.Errorf("Resolve before manifest = %+v, %v", got, ok)
+	}
+
+	manifest := `{"schemaVersion":2,"config":{"digest":"sha256:` + hashB + `","size":1472},` +
+		`"layers":[{"digest":"sha256:` + hashA + `","size":3623807}]}`
+	if err := a.Load(repo+"/manifests/3.20", []byte(manifest)); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got, ok := a.Resolve(repo + "/blobs/sha256:" + hashA); !ok || got.Size != 3623807 {
+		t.Errorf("Resolve(layer) = %+v, %v", got, ok)
+	}
+	if got, ok := a.Resolve(repo + "/blobs/sha256:" + hashB); !ok || got.Size != 1472 {
+		t.Errorf("Resolve(config) = %+v, %v", got, ok)
+	}
+	if err := a.Load(repo+"/manifests/3.20", []byte("not json")); err == nil {
+		t.Error("Load(invalid manifest) succeeded")
+	}
+}
+
+func TestParseNixHash(t *testing.T) {
+	const want = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	for _, in := range []string{
+		"sha256:0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73",
+		"sha256:" + want,
+		"sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+	} {
+		if got, err := parseNixHash(in); err != nil || got != want {
+			t.Errorf("parseNixHash(%s) = %s, %v", in, got, err)
+		}
+	}
+}
+
+func TestTableEviction(t *testing.T) {
+	tb := newTable()
+	tb.max = 2
+	tb.replace("https://h.example/first.narinfo", map[string]Artifact{"https://h.example/a1": {SHA256: hashA}, "https://h.example/a2": {SHA256: hashA}})
+	tb.replace("https://h.example/second.narinfo", map[string]Artifact{"https://h.example/b": {SHA256: hashB}})
+	if _, ok := tb.resolve("https://h.example/b"); !ok {
+		t.Error("newest entry missing")
+	}
+	if _, ok := tb.resolve("https://h.example/a1"); ok {
+		t.Error("oldest metadata's entries were not evicted")
+	}
+	if tb.count != 1 || len(tb.order) != 1 {
+		t.Errorf("count = %d, sources = %v", tb.count, tb.order)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range Names() {
+		a, err := New(name)
+		if err != nil || a.Name() != name {
+			t.Errorf("New(%s) = %v, %v", name, a, err)
+		}
+	}
+	if _, err := New("deb"); err == nil {
+		t.Error("New(deb) succeeded")
+	}
+	if !IsArtifactURL("http://x.example/Packages/h/hello.rpm") || IsArtifactURL("http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-3_amd64.deb") {
+		t.Error("IsArtifactURL gave the wrong answer")
+	}
+}
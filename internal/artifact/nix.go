@@ -0,0 +1,143 @@
+package artifact
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Nix resolves NAR archives in a Nix binary cache (cache.nixos.org or a
+// self-hosted one) from their .narinfo files, which give each compressed
+// NAR's URL, FileHash and FileSize. Nix checks the narinfo signature and the
+// NAR hash itself.
+type Nix struct {
+	t *table
+}
+
+// NewNix returns an empty Nix adapter.
+func NewNix() *Nix {
+	return &Nix{t: newTable()}
+}
+
+func (a *Nix) Name() string        { return "nix" }
+func (a *Nix) ContentType() string { return "application/x-nix-nar" }
+
+func (a *Nix) Classify(rawURL string) Kind {
+	return classifyNix(rawURL)
+}
+
+func classifyNix(rawURL string) Kind {
+	name := baseName(rawURL)
+	switch {
+	case strings.HasSuffix(name, ".narinfo"):
+		return KindMetadata
+	case strings.HasSuffix(trimCompression(name), ".nar"):
+		return KindArtifact
+	default:
+		return KindOther
+	}
+}
+
+// Load parses a narinfo file. Its URL field is relative to the cache root,
+// the directory holding the narinfo.
+func (a *Nix) Load(rawURL string, data []byte) error {
+	var narURL, fileHash string
+	var size int64
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		key, value, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "URL":
+			narURL = value
+		case "FileHash":
+			fileHash = value
+		case "FileSize":
+			size, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if narURL == "" || fileHash == "" {
+		return fmt.Errorf("narinfo has no URL or FileHash")
+	}
+	sum, err := parseNixHash(fileHash)
+	if err != nil {
+		return err
+	}
+	artifacts := make(map[string]Artifact, 1)
+	if u := joinURL(dirURL(rawURL), narURL); u != "" {
+		artifacts[u] = Artifact{SHA256: sum, Size: size, Filename: narURL}
+	}
+	a.t.replace(rawURL, artifacts)
+	return nil
+}
+
+func (a *Nix) Resolve(rawURL string) (Artifact, bool) {
+	return a.t.resolve(rawURL)
+}
+
+// parseNixHash returns a Nix SHA256 as lowercase hex. Nix writes hashes as
+// sha256:<base32>, sha256:<hex> or the SRI form sha256-<base64>.
+func parseNixHash(s string) (string, error) {
+	if b64, ok := strings.CutPrefix(s, "sha256-"); ok {
+		raw, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil || len(raw) != 32 {
+			return "", fmt.Errorf("invalid SRI hash %q", s)
+		}
+		return hex.EncodeToString(raw), nil
+	}
+	v, ok := strings.CutPrefix(s, "sha256:")
+	if !ok {
+		return "", fmt.Errorf("unsupported hash %q (want sha256)", s)
+	}
+	switch len(v) {
+	case 64:
+		v = strings.ToLower(v)
+		if !validSHA256(v) {
+			return "", fmt.Errorf("invalid hex hash %q", s)
+		}
+		return v, nil
+	case 52:
+		raw, err := decodeNixBase32(v, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid base32 hash %q: %w", s, err)
+		}
+		return hex.EncodeToString(raw), nil
+	default:
+		return "", fmt.Errorf("invalid hash %q", s)
+	}
+}
+
+// nixBase32Alphabet is Nix's base32 alphabet, which omits e, o, u and t
+const nixBase32Alphabet = "0123456789abcdfghijklmnpqrsvwxyz"
+
+// decodeNixBase32 decodes Nix's base32, which reads the string from its
+// last character to its first, least significant bits first.
+func decodeNixBase32(s string, size int) ([]byte, error) {
+	out := make([]byte, size)
+	for n := 0; n < len(s); n++ {
+		digit := strings.IndexByte(nixBase32Alphabet, s[len(s)-n-1])
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid character %q", s[len(s)-n-1])
+		}
+		b := n * 5
+		i, j := b/8, uint(b%8)
+		out[i] |= byte(digit << j)
+		carry := byte(digit >> (8 - j))
+		if i+1 < size {
+			out[i+1] |= carry
+		} else if carry != 0 {
+			return nil, fmt.Errorf("value does not fit in %d bytes", size)
+		}
+	}
+	return out, nil
+}
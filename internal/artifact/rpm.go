@@ -0,0 +1,112 @@
+package artifact
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+// RPM resolves RPM packages (Fedora, RHEL, openSUSE) from a repository's
+// primary metadata, repodata/*primary.xml.*, which lists each package's
+// location and SHA256. dnf checks repomd.xml's signature and the package
+// signatures itself.
+type RPM struct {
+	t *table
+}
+
+// NewRPM returns an empty RPM adapter.
+func NewRPM() *RPM {
+	return &RPM{t: newTable()}
+}
+
+func (a *RPM) Name() string        { return "rpm" }
+func (a *RPM) ContentType() string { return "application/x-rpm" }
+
+func (a *RPM) Classify(rawURL string) Kind {
+	return classifyRPM(rawURL)
+}
+
+func classifyRPM(rawURL string) Kind {
+	name := baseName(rawURL)
+	switch {
+	case strings.HasSuffix(name, ".rpm"):
+		return KindArtifact
+	case strings.Contains(strings.ToLower(rawURL), "/repodata/") && strings.HasSuffix(trimCompression(name), "primary.xml"):
+		return KindMetadata
+	default:
+		return KindOther
+	}
+}
+
+// rpmPackage is the part of a primary.xml <package> element the adapter
+// needs
+type rpmPackage struct {
+	Checksum struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"checksum"`
+	Size struct {
+		Package int64 `xml:"package,attr"`
+	} `xml:"size"`
+	Location struct {
+		Href string `xml:"href,attr"`
+		Base string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	} `xml:"location"`
+}
+
+// Load parses a primary.xml file. Packages are located relative to the
+// repository root, the directory above repodata/, unless they name an
+// xml:base of their own. Packages checksummed with anything but SHA256 are
+// skipped.
+func (a *RPM) Load(rawURL string, data []byte) error {
+	i := strings.LastIndex(strings.ToLower(rawURL), "/repodata/")
+	if i < 0 {
+		return fmt.Errorf("%s is not under repodata/", rawURL)
+	}
+	root := rawURL[:i]
+
+	r, err := index.DecompressByMagic(data)
+	if err != nil {
+		return err
+	}
+	artifacts := make(map[string]Artifact)
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse primary metadata: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "package" {
+			continue
+		}
+		var pkg rpmPackage
+		if err := dec.DecodeElement(&pkg, &start); err != nil {
+			return fmt.Errorf("failed to parse primary metadata: %w", err)
+		}
+		sum := strings.ToLower(strings.TrimSpace(pkg.Checksum.Value))
+		if pkg.Checksum.Type != "sha256" || !validSHA256(sum) || pkg.Location.Href == "" {
+			continue
+		}
+		dir := root
+		if pkg.Location.Base != "" {
+			dir = pkg.Location.Base
+		}
+		if u := joinURL(dir, pkg.Location.Href); u != "" {
+			artifacts[u] = Artifact{SHA256: sum, Size: pkg.Size.Package, Filename: pkg.Location.Href}
+		}
+	}
+	a.t.replace(rawURL, artifacts)
+	return nil
+}
+
+func (a *RPM) Resolve(rawURL string) (Artifact, bool) {
+	return a.t.resolve(rawURL)
+}
@@ -1,12 +1,15 @@
 package audit
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/debswarm/debswarm/internal/requestid"
 )
 
 func TestEventCreation(t *testing.T) {
@@ -117,6 +120,27 @@ func TestEventCreation(t *testing.T) {
 			t.Errorf("expected reason 'hash mismatch', got %s", event.Reason)
 		}
 	})
+
+	t.Run("NewCanaryMismatchEvent", func(t *testing.T) {
+		event := NewCanaryMismatchEvent(
+			"abcdef1234567890abcdef",
+			"canary.deb",
+			"0123456789abcdef0123",
+			4096,
+			120,
+			340,
+		)
+
+		if event.EventType != EventCanaryMismatch {
+			t.Errorf("expected EventCanaryMismatch, got %s", event.EventType)
+		}
+		if event.MirrorHash != "0123456789abcdef" {
+			t.Errorf("expected truncated mirror hash, got %s", event.MirrorHash)
+		}
+		if event.DurationMs != 120 || event.MirrorDurationMs != 340 {
+			t.Errorf("expected durations 120/340, got %d/%d", event.DurationMs, event.MirrorDurationMs)
+		}
+	})
 }
 
 func TestTruncateHash(t *testing.T) {
@@ -367,6 +391,23 @@ func TestEventJSONSerialization(t *testing.T) {
 	}
 }
 
+func TestEvent_WithRequest(t *testing.T) {
+	trace, _ := requestid.ParseTrace("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "")
+	ctx := requestid.WithTrace(requestid.WithRequestID(context.Background(), "0123456789abcdef01234567"), trace)
+	event := NewCacheHitEvent("hash", "test.deb", 1024).WithRequest(ctx)
+	if event.RequestID != "0123456789abcdef01234567" || event.TraceID != trace.TraceID {
+		t.Errorf("request ID %q, trace ID %q", event.RequestID, event.TraceID)
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`) {
+		t.Errorf("JSON should contain trace_id: %s", data)
+	}
+}
+
 func TestEvent_WithRequestID(t *testing.T) {
 	t.Run("adds request ID to event", func(t *testing.T) {
 		event := NewCacheHitEvent("abcdef1234567890", "test.deb", 1024)
@@ -494,6 +535,20 @@ func TestConnectTunnelEvents(t *testing.T) {
 		}
 	})
 
+	t.Run("NewMirrorBlockedEvent", func(t *testing.T) {
+		event := NewMirrorBlockedEvent("http://10.0.0.1/debian/dists/stable/Release", "10.0.0.1", "blocked_address")
+
+		if event.EventType != EventMirrorBlocked {
+			t.Errorf("EventType = %q, want %q", event.EventType, EventMirrorBlocked)
+		}
+		if event.URL != "http://10.0.0.1/debian/dists/stable/Release" || event.TargetHost != "10.0.0.1" {
+			t.Errorf("URL, TargetHost = %q, %q", event.URL, event.TargetHost)
+		}
+		if event.Reason != "blocked_address" {
+			t.Errorf("Reason = %q, want %q", event.Reason, "blocked_address")
+		}
+	})
+
 	t.Run("CONNECT events serialize to JSON", func(t *testing.T) {
 		events := []Event{
 			NewConnectTunnelStartEvent("example.com", "443"),
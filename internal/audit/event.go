@@ -2,7 +2,10 @@
 package audit
 
 import (
+	"context"
 	"time"
+
+	"github.com/debswarm/debswarm/internal/requestid"
 )
 
 // EventType represents the type of audit event
@@ -21,6 +24,8 @@ const (
 	EventCacheHit EventType = "cache_hit"
 	// EventPeerBlacklisted is logged when a peer is blacklisted
 	EventPeerBlacklisted EventType = "peer_blacklisted"
+	// EventPeerPardoned is logged when an operator lifts a peer's ban
+	EventPeerPardoned EventType = "peer_pardoned"
 	// EventMultiSourceVerified is logged when a package is verified by multiple providers
 	EventMultiSourceVerified EventType = "multi_source_verified"
 	// EventMultiSourceUnverified is logged when no other providers found for a package
@@ -31,6 +36,45 @@ const (
 	EventConnectTunnelEnd EventType = "connect_tunnel_end"
 	// EventConnectTunnelBlocked is logged when a CONNECT request is blocked
 	EventConnectTunnelBlocked EventType = "connect_tunnel_blocked"
+	// EventMirrorBlocked is logged when the mirror policy refuses an upstream URL
+	EventMirrorBlocked EventType = "mirror_blocked"
+	// EventRevokedContentBlocked is logged when a revoked hash is refused
+	EventRevokedContentBlocked EventType = "revoked_content_blocked"
+	// EventRevokedContentPurged is logged when a revoked hash is purged from cache
+	EventRevokedContentPurged EventType = "revoked_content_purged"
+	// EventCacheDiskPressure is logged when low free disk space forced the
+	// cache below its configured max size
+	EventCacheDiskPressure EventType = "cache_disk_pressure"
+	// EventSourcePolicy is logged when a client-chosen source policy decided
+	// where a package came from, or refused it
+	EventSourcePolicy EventType = "source_policy"
+	// EventP2PPaused is logged when P2P participation is paused
+	EventP2PPaused EventType = "p2p_paused"
+	// EventP2PResumed is logged when P2P participation resumes after a pause
+	EventP2PResumed EventType = "p2p_resumed"
+	// EventHookRejected is logged when a pipeline hook refuses to let a
+	// package be announced or served
+	EventHookRejected EventType = "hook_rejected"
+	// EventPackageQuarantined is logged when the malware scanner flags a
+	// package and it is moved to quarantine instead of the cache
+	EventPackageQuarantined EventType = "package_quarantined"
+	// EventScanFailed is logged when the malware scanner could not reach a
+	// verdict, so the package was not cached or announced
+	EventScanFailed EventType = "scan_failed"
+	// EventCanaryMismatch is logged when a package peers served differs
+	// from what the mirror serves for the same URL
+	EventCanaryMismatch EventType = "canary_mismatch"
+	// EventBootstrap is logged when the node has (re)joined the swarm: the
+	// DHT bootstrap at startup, or recovery after a network change
+	EventBootstrap EventType = "bootstrap"
+	// EventModeChange is logged when the node switches how it takes part in
+	// the swarm, such as its reachability or its upload policy
+	EventModeChange EventType = "mode_change"
+	// EventConfigReload is logged when the configuration is reloaded
+	EventConfigReload EventType = "config_reload"
+	// EventCacheEviction is logged when packages were evicted to make room
+	// for a new one
+	EventCacheEviction EventType = "cache_eviction"
 )
 
 // Event represents a single audit log entry
@@ -44,6 +88,9 @@ type Event struct {
 	// RequestID is the correlation ID for end-to-end request tracing
 	RequestID string `json:"request_id,omitempty"`
 
+	// TraceID is the W3C trace ID the client sent with the request, if any
+	TraceID string `json:"trace_id,omitempty"`
+
 	// PackageHash is the SHA256 hash of the package (truncated in logs)
 	PackageHash string `json:"package_hash,omitempty"`
 
@@ -59,6 +106,9 @@ type Event struct {
 	// PeerID is the libp2p peer ID (for uploads or peer-specific events)
 	PeerID string `json:"peer_id,omitempty"`
 
+	// PeerName is the name an operator gave the peer, if any
+	PeerName string `json:"peer_name,omitempty"`
+
 	// DurationMs is the operation duration in milliseconds
 	DurationMs int64 `json:"duration_ms,omitempty"`
 
@@ -90,6 +140,46 @@ type Event struct {
 	TargetPort string `json:"target_port,omitempty"`
 	// TunnelBytes is total bytes transferred through the tunnel
 	TunnelBytes int64 `json:"tunnel_bytes,omitempty"`
+
+	// URL is the upstream URL the mirror policy refused, credentials removed
+	URL string `json:"url,omitempty"`
+
+	// Disk pressure fields
+	// PackagesEvicted is the number of packages evicted
+	PackagesEvicted int `json:"packages_evicted,omitempty"`
+	// BytesFreed is the total size of the evicted packages
+	BytesFreed int64 `json:"bytes_freed,omitempty"`
+	// CacheSize is the cache size after eviction
+	CacheSize int64 `json:"cache_size,omitempty"`
+
+	// Policy is the source policy a client set for the request
+	Policy string `json:"policy,omitempty"`
+
+	// UploadsReset is the number of running uploads cut off by a P2P pause
+	UploadsReset int `json:"uploads_reset,omitempty"`
+
+	// Hook is the name of the pipeline hook that rejected a package, and
+	// Stage where it did ("pre_announce", "pre_serve")
+	Hook  string `json:"hook,omitempty"`
+	Stage string `json:"stage,omitempty"`
+
+	// MirrorHash is what the mirror served in a canary check, and
+	// MirrorDurationMs how long it took (DurationMs is the P2P download)
+	MirrorHash       string `json:"mirror_hash,omitempty"`
+	MirrorDurationMs int64  `json:"mirror_duration_ms,omitempty"`
+
+	// Mode is what a mode change switched ("reachability", "upload_policy")
+	// and State what it switched to
+	Mode  string `json:"mode,omitempty"`
+	State string `json:"state,omitempty"`
+
+	// Peers and RoutingTableSize are the connected peers and DHT routing
+	// table entries after a bootstrap
+	Peers            int `json:"peers,omitempty"`
+	RoutingTableSize int `json:"routing_table_size,omitempty"`
+
+	// Changes lists the settings a config reload changed
+	Changes []string `json:"changes,omitempty"`
 }
 
 // NewDownloadCompleteEvent creates an event for successful downloads
@@ -164,6 +254,15 @@ func NewPeerBlacklistedEvent(peerID, reason string) Event {
 	}
 }
 
+// NewPeerPardonedEvent creates an event for lifting a peer's ban
+func NewPeerPardonedEvent(peerID string) Event {
+	return Event{
+		Timestamp: time.Now(),
+		EventType: EventPeerPardoned,
+		PeerID:    truncatePeerID(peerID),
+	}
+}
+
 // truncateHash returns first 16 chars of hash for readability
 func truncateHash(hash string) string {
 	if len(hash) > 16 {
@@ -187,6 +286,21 @@ func (e Event) WithRequestID(id string) Event {
 	return e
 }
 
+// WithRequest returns a copy of the event with the request ID and trace ID
+// of the request ctx belongs to.
+func (e Event) WithRequest(ctx context.Context) Event {
+	e.RequestID = requestid.FromContext(ctx)
+	e.TraceID = requestid.TraceIDFromContext(ctx)
+	return e
+}
+
+// WithPeerName returns a copy of the event with the peer's operator-assigned
+// name set, so audit trails read "rack3-seedbox" rather than a bare peer ID.
+func (e Event) WithPeerName(name string) Event {
+	e.PeerName = name
+	return e
+}
+
 // NewConnectTunnelStartEvent creates an event for CONNECT tunnel establishment
 func NewConnectTunnelStartEvent(host, port string) Event {
 	return Event{
@@ -219,3 +333,189 @@ func NewConnectTunnelBlockedEvent(host, port, reason string) Event {
 		Reason:     reason,
 	}
 }
+
+// NewMirrorBlockedEvent creates an event for an upstream URL refused by the
+// mirror policy. rawURL should already be sanitized.
+func NewMirrorBlockedEvent(rawURL, host, reason string) Event {
+	return Event{
+		Timestamp:  time.Now(),
+		EventType:  EventMirrorBlocked,
+		URL:        rawURL,
+		TargetHost: host,
+		Reason:     reason,
+	}
+}
+
+// NewRevokedContentBlockedEvent creates an event for a refused revoked hash.
+// Source is "download" (APT client) or "upload" (peer request).
+func NewRevokedContentBlockedEvent(hash, source, reason string) Event {
+	return Event{
+		Timestamp:   time.Now(),
+		EventType:   EventRevokedContentBlocked,
+		PackageHash: truncateHash(hash),
+		Source:      source,
+		Reason:      reason,
+	}
+}
+
+// NewRevokedContentPurgedEvent creates an event for a revoked hash purged from cache
+func NewRevokedContentPurgedEvent(hash, reason string) Event {
+	return Event{
+		Timestamp:   time.Now(),
+		EventType:   EventRevokedContentPurged,
+		PackageHash: truncateHash(hash),
+		Reason:      reason,
+	}
+}
+
+// NewCacheDiskPressureEvent creates an event for packages evicted because
+// free disk space fell below the configured minimum
+func NewCacheDiskPressureEvent(evicted int, freed, cacheSize int64, reason string) Event {
+	return Event{
+		Timestamp:       time.Now(),
+		EventType:       EventCacheDiskPressure,
+		PackagesEvicted: evicted,
+		BytesFreed:      freed,
+		CacheSize:       cacheSize,
+		Reason:          reason,
+	}
+}
+
+// NewSourcePolicyEvent creates an event for a package request restricted by a
+// client-chosen source policy. source is where the package came from, or empty
+// with a reason when the policy left no source that could serve it.
+func NewSourcePolicyEvent(policy, hash, name, source, reason string) Event {
+	return Event{
+		Timestamp:   time.Now(),
+		EventType:   EventSourcePolicy,
+		Policy:      policy,
+		PackageHash: truncateHash(hash),
+		PackageName: name,
+		Source:      source,
+		Reason:      reason,
+	}
+}
+
+// NewP2PPausedEvent creates an event for P2P participation being paused
+func NewP2PPausedEvent(reason string, uploadsReset int) Event {
+	return Event{
+		Timestamp:    time.Now(),
+		EventType:    EventP2PPaused,
+		Reason:       reason,
+		UploadsReset: uploadsReset,
+	}
+}
+
+// NewP2PResumedEvent creates an event for P2P participation resuming after a
+// pause that lasted durationMs
+func NewP2PResumedEvent(durationMs int64) Event {
+	return Event{
+		Timestamp:  time.Now(),
+		EventType:  EventP2PResumed,
+		DurationMs: durationMs,
+	}
+}
+
+// NewHookRejectedEvent creates an event for a pipeline hook refusing a
+// package. peerID is set when the package was to be uploaded to a peer.
+func NewHookRejectedEvent(hook, stage, hash, name, peerID, reason string) Event {
+	return Event{
+		Timestamp:   time.Now(),
+		EventType:   EventHookRejected,
+		Hook:        hook,
+		Stage:       stage,
+		PackageHash: truncateHash(hash),
+		PackageName: name,
+		PeerID:      peerID,
+		Reason:      reason,
+	}
+}
+
+// NewPackageQuarantinedEvent creates an event for a package the malware
+// scanner flagged; signature is what the scanner found.
+func NewPackageQuarantinedEvent(hash, name string, size int64, signature string) Event {
+	return Event{
+		Timestamp:   time.Now(),
+		EventType:   EventPackageQuarantined,
+		PackageHash: truncateHash(hash),
+		PackageName: name,
+		PackageSize: size,
+		Reason:      signature,
+	}
+}
+
+// NewScanFailedEvent creates an event for a package the malware scanner
+// could not scan.
+func NewScanFailedEvent(hash, name, errMsg string) Event {
+	return Event{
+		Timestamp:   time.Now(),
+		EventType:   EventScanFailed,
+		PackageHash: truncateHash(hash),
+		PackageName: name,
+		Error:       errMsg,
+	}
+}
+
+// NewCanaryMismatchEvent creates an event for a canary check in which the
+// mirror served different content than peers did.
+func NewCanaryMismatchEvent(hash, name, mirrorHash string, size, durationMs, mirrorDurationMs int64) Event {
+	return Event{
+		Timestamp:        time.Now(),
+		EventType:        EventCanaryMismatch,
+		PackageHash:      truncateHash(hash),
+		PackageName:      name,
+		PackageSize:      size,
+		DurationMs:       durationMs,
+		MirrorHash:       truncateHash(mirrorHash),
+		MirrorDurationMs: mirrorDurationMs,
+	}
+}
+
+// NewBootstrapEvent creates an event for the node (re)joining the swarm.
+// trigger is what started it ("startup", "network_change", "resume").
+func NewBootstrapEvent(trigger string, peers, routingTableSize int, durationMs int64, errMsg string) Event {
+	return Event{
+		Timestamp:        time.Now(),
+		EventType:        EventBootstrap,
+		Reason:           trigger,
+		Peers:            peers,
+		RoutingTableSize: routingTableSize,
+		DurationMs:       durationMs,
+		Error:            errMsg,
+	}
+}
+
+// NewModeChangeEvent creates an event for the node switching mode to state.
+func NewModeChangeEvent(mode, state, reason string) Event {
+	return Event{
+		Timestamp: time.Now(),
+		EventType: EventModeChange,
+		Mode:      mode,
+		State:     state,
+		Reason:    reason,
+	}
+}
+
+// NewConfigReloadEvent creates an event for a configuration reload that
+// changed the given settings, or failed with errMsg.
+func NewConfigReloadEvent(changes []string, errMsg string) Event {
+	return Event{
+		Timestamp: time.Now(),
+		EventType: EventConfigReload,
+		Changes:   changes,
+		Error:     errMsg,
+	}
+}
+
+// NewCacheEvictionEvent creates an event for an eviction sweep that made
+// room in the cache.
+func NewCacheEvictionEvent(evicted int, freed, cacheSize int64, reason string) Event {
+	return Event{
+		Timestamp:       time.Now(),
+		EventType:       EventCacheEviction,
+		PackagesEvicted: evicted,
+		BytesFreed:      freed,
+		CacheSize:       cacheSize,
+		Reason:          reason,
+	}
+}
@@ -22,3 +22,30 @@ func (n *NoopLogger) Close() error {
 
 // Ensure NoopLogger implements Logger
 var _ Logger = (*NoopLogger)(nil)
+
+// multiLogger sends each event to several loggers
+type multiLogger []Logger
+
+// Tee returns a Logger that sends each event to all of loggers and closes
+// them all on Close.
+func Tee(loggers ...Logger) Logger {
+	return multiLogger(loggers)
+}
+
+// Log sends event to every logger
+func (m multiLogger) Log(event Event) {
+	for _, l := range m {
+		l.Log(event)
+	}
+}
+
+// Close closes every logger and returns the first error
+func (m multiLogger) Close() error {
+	var first error
+	for _, l := range m {
+		if err := l.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,185 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimelineSize is how many events a Timeline keeps by default
+const DefaultTimelineSize = 1000
+
+// Event categories shown on the timeline
+const (
+	CategoryNetwork  = "network"  // bootstraps, mode changes, pauses
+	CategorySecurity = "security" // verification failures, blacklisting, revocations
+	CategoryCache    = "cache"    // eviction sweeps, disk pressure
+	CategoryConfig   = "config"   // reloads
+)
+
+// categories maps the significant event types, the ones an operator looks
+// for after an incident, to their category. Per-package traffic (downloads,
+// cache hits, uploads, tunnels) is left to the audit log.
+var categories = map[EventType]string{
+	EventBootstrap:             CategoryNetwork,
+	EventModeChange:            CategoryNetwork,
+	EventP2PPaused:             CategoryNetwork,
+	EventP2PResumed:            CategoryNetwork,
+	EventVerificationFailed:    CategorySecurity,
+	EventPeerBlacklisted:       CategorySecurity,
+	EventPeerPardoned:          CategorySecurity,
+	EventRevokedContentBlocked: CategorySecurity,
+	EventRevokedContentPurged:  CategorySecurity,
+	EventHookRejected:          CategorySecurity,
+	EventPackageQuarantined:    CategorySecurity,
+	EventScanFailed:            CategorySecurity,
+	EventCanaryMismatch:        CategorySecurity,
+	EventCacheEviction:         CategoryCache,
+	EventCacheDiskPressure:     CategoryCache,
+	EventConfigReload:          CategoryConfig,
+}
+
+// Category returns the timeline category of an event type, "" if the
+// timeline does not keep it.
+func Category(t EventType) string {
+	return categories[t]
+}
+
+// Timeline keeps the most recent significant events in memory, newest
+// last, for the dashboard's events page. It is a Logger, so it can receive
+// every audit event and picks the ones it keeps.
+type Timeline struct {
+	mu     sync.RWMutex
+	events []Event // ring buffer of up to size events
+	next   int     // where the next event goes once the ring is full
+	size   int
+}
+
+// NewTimeline creates a Timeline keeping up to size events
+// (DefaultTimelineSize if size <= 0).
+func NewTimeline(size int) *Timeline {
+	if size <= 0 {
+		size = DefaultTimelineSize
+	}
+	return &Timeline{size: size}
+}
+
+// Log keeps event if it is significant
+func (t *Timeline) Log(event Event) {
+	if Category(event.EventType) == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.events) < t.size {
+		t.events = append(t.events, event)
+		return
+	}
+	t.events[t.next] = event
+	t.next = (t.next + 1) % t.size
+}
+
+// Close does nothing and returns nil
+func (t *Timeline) Close() error {
+	return nil
+}
+
+// Load fills the timeline from an audit log file written by JSONWriter, so
+// the history survives a restart. Lines that are not significant events
+// are skipped without being decoded; a missing file is not an error.
+func (t *Timeline) Load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	markers := make([][]byte, 0, len(categories))
+	for typ := range categories {
+		markers = append(markers, []byte(`"event_type":"`+string(typ)+`"`))
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		significant := false
+		for _, m := range markers {
+			if bytes.Contains(line, m) {
+				significant = true
+				break
+			}
+		}
+		if !significant {
+			continue
+		}
+		var event Event
+		if json.Unmarshal(line, &event) == nil {
+			t.Log(event)
+		}
+	}
+	return scanner.Err()
+}
+
+// TimelineFilter selects timeline events. Zero fields match everything.
+type TimelineFilter struct {
+	Category string    // CategoryNetwork, CategorySecurity, CategoryCache or CategoryConfig
+	Type     EventType // a single event type
+	Since    time.Time // events at or after
+	Query    string    // case-insensitive text in the package, peer, reason, error or mode
+	Limit    int       // most recent events returned
+}
+
+// Events returns the events matching f, newest first
+func (t *Timeline) Events(f TimelineFilter) []Event {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	query := strings.ToLower(f.Query)
+	out := make([]Event, 0)
+	for i := len(t.events) - 1; i >= 0; i-- {
+		if f.Limit > 0 && len(out) >= f.Limit {
+			break
+		}
+		e := t.events[(t.next+i)%len(t.events)]
+		if f.Category != "" && Category(e.EventType) != f.Category {
+			continue
+		}
+		if f.Type != "" && e.EventType != f.Type {
+			continue
+		}
+		if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+			continue
+		}
+		if query != "" && !e.matches(query) {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// matches reports whether one of e's descriptive fields contains query,
+// which is lower case
+func (e *Event) matches(query string) bool {
+	for _, field := range []string{e.PackageName, e.PackageHash, e.PeerID, e.PeerName, e.Reason, e.Error, e.Mode, e.State, e.Hook} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	for _, change := range e.Changes {
+		if strings.Contains(strings.ToLower(change), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ensure Timeline implements Logger
+var _ Logger = (*Timeline)(nil)
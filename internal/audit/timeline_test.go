@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTimeline(t *testing.T) {
+	tl := NewTimeline(3)
+
+	// Per-package traffic is left to the audit log
+	tl.Log(NewCacheHitEvent("hash", "pkg.deb", 100))
+	tl.Log(NewDownloadCompleteEvent("hash", "pkg.deb", 100, "peer", 10, 100, 0))
+	if got := tl.Events(TimelineFilter{}); len(got) != 0 {
+		t.Fatalf("traffic events kept: %+v", got)
+	}
+
+	old := NewBootstrapEvent("startup", 5, 20, 1500, "")
+	old.Timestamp = time.Now().Add(-2 * time.Hour)
+	tl.Log(old)
+	tl.Log(NewVerificationFailedEvent("abcdef0123456789", "openssl_3.0_amd64.deb", "peerA"))
+	tl.Log(NewModeChangeEvent("reachability", "public", "autonat"))
+	tl.Log(NewConfigReloadEvent([]string{"transfer.max_upload_rate"}, ""))
+
+	// The oldest event gave way; the rest come newest first
+	got := tl.Events(TimelineFilter{})
+	if len(got) != 3 || got[0].EventType != EventConfigReload || got[2].EventType != EventVerificationFailed {
+		t.Fatalf("events = %+v", got)
+	}
+
+	if got := tl.Events(TimelineFilter{Category: CategorySecurity}); len(got) != 1 || got[0].PackageName != "openssl_3.0_amd64.deb" {
+		t.Errorf("security events = %+v", got)
+	}
+	if got := tl.Events(TimelineFilter{Type: EventModeChange}); len(got) != 1 || got[0].State != "public" {
+		t.Errorf("mode changes = %+v", got)
+	}
+	if got := tl.Events(TimelineFilter{Query: "OpenSSL"}); len(got) != 1 {
+		t.Errorf("query by package = %+v", got)
+	}
+	if got := tl.Events(TimelineFilter{Query: "upload_rate"}); len(got) != 1 || got[0].EventType != EventConfigReload {
+		t.Errorf("query by changed setting = %+v", got)
+	}
+	if got := tl.Events(TimelineFilter{Limit: 2}); len(got) != 2 || got[0].EventType != EventConfigReload {
+		t.Errorf("limit 2 = %+v", got)
+	}
+	if got := tl.Events(TimelineFilter{Since: time.Now().Add(-time.Hour)}); len(got) != 3 {
+		t.Errorf("last hour = %d events, want 3", len(got))
+	}
+}
+
+func TestTimelineLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.json")
+	writer, err := NewJSONWriter(JSONWriterConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tee := Tee(writer, NewTimeline(0))
+	for i := range 5 {
+		tee.Log(NewCacheHitEvent(fmt.Sprintf("hash%d", i), "pkg.deb", 100))
+	}
+	tee.Log(NewPeerBlacklistedEvent("peerB", "sent corrupt data"))
+	tee.Log(NewCacheEvictionEvent(3, 900, 100, "capacity"))
+	if err := tee.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	tl := NewTimeline(0)
+	if err := tl.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	got := tl.Events(TimelineFilter{})
+	if len(got) != 2 || got[0].EventType != EventCacheEviction || got[0].PackagesEvicted != 3 || got[1].Reason != "sent corrupt data" {
+		t.Errorf("loaded events = %+v", got)
+	}
+
+	if err := NewTimeline(0).Load(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("Load of a missing file: %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+package cache
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+// The archive format streams cached packages between caches, for example to
+// bootstrap a new seed over ssh. It is a tar stream: a header entry, then for
+// each package its database row as JSON followed by its content.
+//
+//	debswarm-cache.json         ArchiveHeader
+//	packages/<sha256>.json      ArchivedPackage
+//	packages/<sha256>           package content
+//	...
+//
+// Rows carry what the receiving cache cannot rebuild cheaply (package name,
+// version and architecture, origin, pin), so importing does not re-parse
+// every package. Content is still verified against its hash as it is
+// written, in the same pass, so an archive need not be trusted.
+const (
+	ArchiveFormat     = 1
+	archiveHeaderName = "debswarm-cache.json"
+	archiveDir        = "packages/"
+
+	// maxArchiveRow bounds a JSON entry read from an archive
+	maxArchiveRow = 64 << 10
+)
+
+// ArchiveHeader opens an archive.
+type ArchiveHeader struct {
+	Format   int       `json:"format"`
+	Created  time.Time `json:"created"`
+	Packages int       `json:"packages"` // rows that follow, at the time of writing
+}
+
+// ArchivedPackage is a package's row in an archive.
+type ArchivedPackage struct {
+	SHA256       string    `json:"sha256"`
+	Size         int64     `json:"size"`
+	Filename     string    `json:"filename"`
+	Package      string    `json:"package,omitempty"`
+	Version      string    `json:"version,omitempty"`
+	Architecture string    `json:"architecture,omitempty"`
+	Pinned       bool      `json:"pinned,omitempty"`
+	AddedAt      time.Time `json:"added_at"`
+	Origin       *Origin   `json:"origin,omitempty"`
+}
+
+// ArchiveStats counts what an export or import did.
+type ArchiveStats struct {
+	Packages int   // written, or stored by an import
+	Bytes    int64 // content bytes written or stored
+	Skipped  int   // import: already cached; export: gone before it was read
+	Failed   int   // import: failed verification or could not be stored
+}
+
+// Export writes the packages for which include returns true (all when it is
+// nil) to w as an archive. Packages evicted while the export runs are
+// skipped.
+func (c *Cache) Export(w io.Writer, include func(*Package) bool) (ArchiveStats, error) {
+	var stats ArchiveStats
+	packages, err := c.List()
+	if err != nil {
+		return stats, err
+	}
+	selected := packages[:0]
+	for _, pkg := range packages {
+		if include == nil || include(pkg) {
+			selected = append(selected, pkg)
+		}
+	}
+
+	tw := tar.NewWriter(w)
+	now := time.Now().UTC()
+	if err := writeArchiveJSON(tw, archiveHeaderName, now, ArchiveHeader{Format: ArchiveFormat, Created: now, Packages: len(selected)}); err != nil {
+		return stats, err
+	}
+	for _, pkg := range selected {
+		ok, err := c.exportPackage(tw, pkg, now)
+		if err != nil {
+			return stats, fmt.Errorf("%s: %w", pkg.SHA256, err)
+		}
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+		stats.Packages++
+		stats.Bytes += pkg.Size
+	}
+	return stats, tw.Close()
+}
+
+// exportPackage writes one package's row and content. It reports false,
+// having written nothing, when the package is no longer cached.
+func (c *Cache) exportPackage(tw *tar.Writer, pkg *Package, now time.Time) (bool, error) {
+	src, _, err := c.Get(pkg.SHA256)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+
+	row := ArchivedPackage{
+		SHA256:       pkg.SHA256,
+		Size:         pkg.Size,
+		Filename:     pkg.Filename,
+		Package:      pkg.PackageName,
+		Version:      pkg.PackageVersion,
+		Architecture: pkg.Architecture,
+		Pinned:       pkg.Pinned,
+		AddedAt:      pkg.AddedAt.UTC(),
+	}
+	if pkg.Origin != (Origin{}) {
+		row.Origin = &pkg.Origin
+	}
+	if err := writeArchiveJSON(tw, archiveDir+pkg.SHA256+".json", now, row); err != nil {
+		return false, err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    archiveDir + pkg.SHA256,
+		Mode:    0644,
+		Size:    pkg.Size,
+		ModTime: now,
+	}); err != nil {
+		return false, err
+	}
+	if _, err := io.CopyN(tw, src, pkg.Size); err != nil {
+		return false, fmt.Errorf("failed to read cached file: %w", err)
+	}
+	return true, nil
+}
+
+func writeArchiveJSON(tw *tar.Writer, name string, modTime time.Time, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// Import stores the packages in an archive read from r. Packages already
+// cached are skipped; a package whose content does not match its hash, or
+// that the cache refuses (quarantine, no space), is counted as failed and
+// the import goes on. A malformed archive stops it. progress, if not nil, is
+// called after each package.
+func (c *Cache) Import(r io.Reader, progress func(row *ArchivedPackage, err error)) (ArchiveStats, error) {
+	var stats ArchiveStats
+	tr := tar.NewReader(r)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return stats, fmt.Errorf("not a debswarm cache archive: %w", err)
+	}
+	var header ArchiveHeader
+	if hdr.Name != archiveHeaderName || readArchiveJSON(tr, &header) != nil {
+		return stats, fmt.Errorf("not a debswarm cache archive")
+	}
+	if header.Format != ArchiveFormat {
+		return stats, fmt.Errorf("unsupported archive format %d (this version reads %d)", header.Format, ArchiveFormat)
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, fmt.Errorf("failed to read archive: %w", err)
+		}
+		var row ArchivedPackage
+		if name, ok := strings.CutSuffix(hdr.Name, ".json"); !ok || path.Dir(name)+"/" != archiveDir {
+			return stats, fmt.Errorf("unexpected archive entry %q", hdr.Name)
+		}
+		if err := readArchiveJSON(tr, &row); err != nil {
+			return stats, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		if !isHexHash(row.SHA256) || hdr.Name != archiveDir+row.SHA256+".json" {
+			return stats, fmt.Errorf("%s: row does not match its entry", hdr.Name)
+		}
+
+		hdr, err = tr.Next()
+		if err != nil {
+			return stats, fmt.Errorf("%s: missing content: %w", row.SHA256, err)
+		}
+		if hdr.Name != archiveDir+row.SHA256 || hdr.Size != row.Size {
+			return stats, fmt.Errorf("%s: unexpected archive entry %q", row.SHA256, hdr.Name)
+		}
+
+		err = c.importPackage(tr, &row)
+		switch {
+		case err == nil:
+			stats.Packages++
+			stats.Bytes += row.Size
+		case errors.Is(err, ErrAlreadyCached):
+			stats.Skipped++
+		default:
+			stats.Failed++
+			c.logger.Warn("Failed to import archived package",
+				zap.String("hash", row.SHA256),
+				zap.String("filename", sanitize.Filename(row.Filename)),
+				zap.Error(err))
+		}
+		if progress != nil {
+			progress(&row, err)
+		}
+	}
+}
+
+// ErrAlreadyCached is passed to the Import progress callback for packages
+// the cache already holds.
+var ErrAlreadyCached = errors.New("already cached")
+
+// importPackage stores one package from r and restores its row. The tar
+// reader skips whatever of the content is left unread.
+func (c *Cache) importPackage(r io.Reader, row *ArchivedPackage) error {
+	if c.Has(row.SHA256) {
+		return ErrAlreadyCached
+	}
+	filename := row.Filename
+	if filename == "" {
+		filename = row.SHA256
+	}
+	if err := c.Put(io.LimitReader(r, row.Size), row.SHA256, filename); err != nil {
+		return err
+	}
+	if row.Package != "" {
+		if err := c.SetPackageMetadata(row.SHA256, row.Package, row.Version, row.Architecture); err != nil {
+			return err
+		}
+	}
+	if row.Origin != nil {
+		if err := c.SetOrigin(row.SHA256, *row.Origin); err != nil {
+			return err
+		}
+	}
+	if row.Pinned {
+		return c.Pin(row.SHA256)
+	}
+	return nil
+}
+
+func readArchiveJSON(r io.Reader, v any) error {
+	data, err := io.ReadAll(io.LimitReader(r, maxArchiveRow+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxArchiveRow {
+		return fmt.Errorf("entry too large")
+	}
+	return json.Unmarshal(data, v)
+}
+
+// isHexHash reports whether s is a lowercase hex SHA256
+func isHexHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, ch := range s {
+		if (ch < '0' || ch > '9') && (ch < 'a' || ch > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	src, _ := testCache(t)
+	hello, world := []byte("hello package"), []byte("world package")
+	for _, p := range []struct {
+		data     []byte
+		filename string
+	}{{hello, "hello_1.0_amd64.deb"}, {world, "world_2.0_all.deb"}} {
+		if err := src.Put(bytes.NewReader(p.data), hashData(p.data), p.filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+	helloHash := hashData(hello)
+	if err := src.SetPackageMetadata(helloHash, "hello", "1.0", "amd64"); err != nil {
+		t.Fatal(err)
+	}
+	origin := Origin{Repo: "deb.debian.org/debian", Suite: "bookworm", Component: "main"}
+	if err := src.SetOrigin(helloHash, origin); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Pin(helloHash); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	stats, err := src.Export(&buf, func(p *Package) bool { return p.SHA256 == helloHash })
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if stats.Packages != 1 || stats.Bytes != int64(len(hello)) {
+		t.Errorf("export stats = %+v", stats)
+	}
+	archive := buf.Bytes()
+
+	dst, _ := testCache(t)
+	var seen []string
+	stats, err = dst.Import(bytes.NewReader(archive), func(row *ArchivedPackage, err error) {
+		seen = append(seen, row.Filename)
+	})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if stats.Packages != 1 || stats.Failed != 0 || len(seen) != 1 || seen[0] != "hello_1.0_amd64.deb" {
+		t.Errorf("import stats = %+v, seen %v", stats, seen)
+	}
+	pkg, err := dst.Info(helloHash)
+	if err != nil {
+		t.Fatalf("imported package missing: %v", err)
+	}
+	if pkg.PackageName != "hello" || pkg.PackageVersion != "1.0" || pkg.Architecture != "amd64" ||
+		!pkg.Pinned || pkg.Origin != origin {
+		t.Errorf("imported row = %+v", pkg)
+	}
+	if dst.Has(hashData(world)) {
+		t.Error("package outside the export filter was imported")
+	}
+
+	// Importing again skips what is cached
+	stats, err = dst.Import(bytes.NewReader(archive), nil)
+	if err != nil || stats.Skipped != 1 || stats.Packages != 0 {
+		t.Errorf("re-import: stats %+v, err %v", stats, err)
+	}
+}
+
+func TestArchiveImportRejectsBadContent(t *testing.T) {
+	src, _ := testCache(t)
+	data := []byte("genuine package")
+	if err := src.Put(bytes.NewReader(data), hashData(data), "pkg.deb"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := src.Export(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Same length, different bytes
+	tampered := bytes.Replace(buf.Bytes(), data, []byte("tampered packag"), 1)
+
+	dst, _ := testCache(t)
+	var importErr error
+	stats, err := dst.Import(bytes.NewReader(tampered), func(_ *ArchivedPackage, err error) { importErr = err })
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if stats.Failed != 1 || !errors.Is(importErr, ErrHashMismatch) || dst.Has(hashData(data)) {
+		t.Errorf("tampered import: stats %+v, err %v", stats, importErr)
+	}
+}
+
+func TestArchiveImportRejectsForeignTar(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	_ = tw.WriteHeader(&tar.Header{Name: "etc/passwd", Mode: 0644, Size: 4})
+	_, _ = io.WriteString(tw, "root")
+	_ = tw.Close()
+
+	c, _ := testCache(t)
+	if _, err := c.Import(&buf, nil); err == nil || !strings.Contains(err.Error(), "not a debswarm cache archive") {
+		t.Errorf("foreign tar: err = %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// BuildSet summarizes the packages recorded for one build key
+type BuildSet struct {
+	Key       string    `json:"key"`
+	Packages  int       `json:"packages"`
+	Bytes     int64     `json:"bytes"`
+	Cached    int       `json:"cached"` // how many of the packages are still in the cache
+	FirstUsed time.Time `json:"first_used"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+// BuildPackage is one package a build fetched
+type BuildPackage struct {
+	SHA256   string    `json:"sha256"`
+	URL      string    `json:"url"`
+	Size     int64     `json:"size"`
+	Cached   bool      `json:"cached"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// RecordBuildPackage adds a package to the build set key, or refreshes its
+// last use when the set already has it.
+func (c *Cache) RecordBuildPackage(key, sha256Hash, url string, size int64) error {
+	now := time.Now().Unix()
+	_, err := c.db.Exec(`
+		INSERT INTO build_packages (build_key, sha256, url, size, first_used, last_used)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(build_key, sha256) DO UPDATE SET
+			url = excluded.url,
+			last_used = excluded.last_used`,
+		key, sha256Hash, url, size, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to record build package: %w", err)
+	}
+	return nil
+}
+
+// BuildSets returns every build set, most recently used first
+func (c *Cache) BuildSets() ([]BuildSet, error) {
+	rows, err := c.db.Query(`
+		SELECT b.build_key, COUNT(*), COALESCE(SUM(b.size), 0), COUNT(p.sha256),
+			MIN(b.first_used), MAX(b.last_used)
+		FROM build_packages b LEFT JOIN packages p ON p.sha256 = b.sha256
+		GROUP BY b.build_key
+		ORDER BY MAX(b.last_used) DESC, b.build_key`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query build sets: %w", err)
+	}
+	defer rows.Close()
+
+	result := []BuildSet{}
+	for rows.Next() {
+		var s BuildSet
+		var first, last int64
+		if err := rows.Scan(&s.Key, &s.Packages, &s.Bytes, &s.Cached, &first, &last); err != nil {
+			return nil, fmt.Errorf("failed to read build set: %w", err)
+		}
+		s.FirstUsed, s.LastUsed = time.Unix(first, 0), time.Unix(last, 0)
+		result = append(result, s)
+	}
+	return result, rows.Err()
+}
+
+// BuildSetPackages returns the packages recorded for a build key, by URL
+func (c *Cache) BuildSetPackages(key string) ([]BuildPackage, error) {
+	rows, err := c.db.Query(`
+		SELECT b.sha256, b.url, b.size, p.sha256 IS NOT NULL, b.last_used
+		FROM build_packages b LEFT JOIN packages p ON p.sha256 = b.sha256
+		WHERE b.build_key = ?
+		ORDER BY b.url`, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query build set: %w", err)
+	}
+	defer rows.Close()
+
+	result := []BuildPackage{}
+	for rows.Next() {
+		var p BuildPackage
+		var last int64
+		if err := rows.Scan(&p.SHA256, &p.URL, &p.Size, &p.Cached, &last); err != nil {
+			return nil, fmt.Errorf("failed to read build package: %w", err)
+		}
+		p.LastUsed = time.Unix(last, 0)
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+// DeleteBuildSet forgets a build set and returns how many packages it had.
+// The packages themselves stay cached, and pinned if they were.
+func (c *Cache) DeleteBuildSet(key string) (int, error) {
+	result, err := c.db.Exec(`DELETE FROM build_packages WHERE build_key = ?`, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete build set: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check delete result: %w", err)
+	}
+	return int(n), nil
+}
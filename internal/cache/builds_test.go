@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestBuildSets(t *testing.T) {
+	c, err := New(t.TempDir(), 1<<20, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	data := []byte("cached package")
+	sum := sha256.Sum256(data)
+	cached := hex.EncodeToString(sum[:])
+	if err := c.Put(bytes.NewReader(data), cached, "a_1_amd64.deb"); err != nil {
+		t.Fatal(err)
+	}
+	gone := "ff" + cached[2:]
+
+	for _, r := range []struct {
+		key, hash, url string
+		size           int64
+	}{
+		{"bookworm", cached, "http://deb.debian.org/debian/pool/main/a/a/a_1_amd64.deb", int64(len(data))},
+		{"bookworm", gone, "http://deb.debian.org/debian/pool/main/b/b/b_1_amd64.deb", 100},
+		{"bookworm", cached, "http://deb.debian.org/debian/pool/main/a/a/a_1_amd64.deb", int64(len(data))},
+		{"trixie", cached, "http://deb.debian.org/debian/pool/main/a/a/a_1_amd64.deb", int64(len(data))},
+	} {
+		if err := c.RecordBuildPackage(r.key, r.hash, r.url, r.size); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sets, err := c.BuildSets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 2 {
+		t.Fatalf("sets = %+v", sets)
+	}
+	var bookworm BuildSet
+	for _, s := range sets {
+		if s.Key == "bookworm" {
+			bookworm = s
+		}
+	}
+	if bookworm.Packages != 2 || bookworm.Cached != 1 || bookworm.Bytes != int64(len(data))+100 {
+		t.Errorf("bookworm = %+v", bookworm)
+	}
+
+	pkgs, err := c.BuildSetPackages("bookworm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 2 || !pkgs[0].Cached || pkgs[1].Cached || pkgs[1].SHA256 != gone {
+		t.Errorf("packages = %+v", pkgs)
+	}
+
+	if n, err := c.DeleteBuildSet("bookworm"); err != nil || n != 2 {
+		t.Errorf("DeleteBuildSet = %d, %v", n, err)
+	}
+	if !c.Has(cached) {
+		t.Error("deleting a build set removed a cached package")
+	}
+	if sets, _ := c.BuildSets(); len(sets) != 1 || sets[0].Key != "trixie" {
+		t.Errorf("sets after delete = %+v", sets)
+	}
+}
@@ -2,6 +2,7 @@
 package cache
 
 import (
+	"bytes"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -15,6 +16,7 @@ import (
 	_ "modernc.org/sqlite"
 
 	"github.com/debswarm/debswarm/internal/hashutil"
+	"github.com/debswarm/debswarm/internal/migrate"
 	"github.com/debswarm/debswarm/internal/sanitize"
 )
 
@@ -27,19 +29,43 @@ var (
 
 // Package represents a cached package entry
 type Package struct {
-	SHA256         string
-	Size           int64
-	Filename       string
-	AddedAt        time.Time
-	LastAccessed   time.Time
-	AccessCount    int64
-	Announced      time.Time
-	PackageName    string
-	PackageVersion string
-	Architecture   string
-	Pinned         bool
+	SHA256       string
+	Size         int64
+	Filename     string
+	AddedAt      time.Time
+	LastAccessed time.Time
+	AccessCount  int64
+	Announced    time.Time
+	// AdvertisedUntil is when the provider record from the last successful
+	// announcement expires; the Unix epoch if never announced
+	AdvertisedUntil time.Time
+	PackageName     string
+	PackageVersion  string
+	Architecture    string
+	Pinned          bool
+	// Origin is where the package was first downloaded from; empty for
+	// packages cached before origins were recorded, or imported
+	Origin Origin
 }
 
+// Origin records where a cached package came from.
+type Origin struct {
+	Repo      string // repository base, e.g. "deb.debian.org/debian"
+	Suite     string // e.g. "bookworm-updates"
+	Component string // e.g. "main"
+	Mirror    string // URL the package was requested from
+}
+
+// accessFlushInterval is how often batched access records are persisted.
+// A crash loses at most this much access history, which only makes
+// eviction ranking slightly staler.
+const accessFlushInterval = 15 * time.Second
+
+// maxPendingAccess is how many distinct packages or metadata URLs may wait
+// for a flush before the flusher is woken early, bounding memory and the
+// history a crash can lose when many clients read at once.
+const maxPendingAccess = 4096
+
 // accessRecord accumulates access-time updates for one package between flushes.
 type accessRecord struct {
 	last  int64 // most recent access (unix seconds)
@@ -65,15 +91,43 @@ type Cache struct {
 	// them (and ensureSpace flushes before ranking eviction candidates).
 	pendingAccess   map[string]accessRecord
 	pendingAccessMu sync.Mutex
+	flushNow        chan struct{} // wakes the flusher before its next tick
 	flushStop       chan struct{}
 	flushDone       chan struct{}
 	closeOnce       sync.Once
 
+	// Per-peer transfer totals for the ledger (peer_transfers table),
+	// batched the same way and written by the same flusher.
+	pendingLedger   map[ledgerKey]ledgerRecord
+	pendingLedgerMu sync.Mutex
+
+	// Per-package serve counts (package_stats table), batched likewise.
+	pendingStats   map[string]packageStatRecord
+	pendingStatsMu sync.Mutex
+
+	// Metadata access times (indices table) by URL, batched likewise.
+	pendingMeta   map[string]accessRecord
+	pendingMetaMu sync.Mutex
+
 	// onEvict, when set, is called once per successfully evicted package so
 	// callers can count evictions (sustained eviction pressure means the
 	// cache is undersized). Called with the cache lock held — must not call
 	// back into the cache.
-	onEvict func()
+	onEvict func(reason string)
+
+	// onEvictionSweep, when set, is called once per ensureSpace run that
+	// evicted packages, with how many and the bytes freed. Called with the
+	// cache lock held, like onEvict.
+	onEvictionSweep func(evicted int, freed, sizeAfter int64)
+
+	// screen, when set, checks each verified package before it is
+	// committed (malware scanning); flagged files go to quarantineDir.
+	screen        Screen
+	quarantineDir string
+
+	// diskFree reports free space on the cache filesystem. It is
+	// getDiskFreeSpace outside tests.
+	diskFree func() (int64, error)
 
 	// Metadata (repository index) cache, held in the `indices` table and the
 	// `indices/` dir. metadataMaxSize == 0 disables it entirely (Get/Put become
@@ -83,6 +137,11 @@ type Cache struct {
 	metadataMaxSize int64
 	metadataSize    int64
 	onMetadataEvict func()
+
+	// memory holds hot small package and metadata bodies (see memoryTier);
+	// onMemoryLookup, when set, is told of each lookup it could serve.
+	memory         *memoryTier
+	onMemoryLookup func(kind string, hit bool)
 }
 
 // New creates a new cache instance
@@ -111,12 +170,12 @@ func NewWithMinFreeSpace(basePath string, maxSize int64, minFreeSpace int64, log
 		return nil, err
 	}
 
-	// Create tables
-	if err := createTables(db); err != nil {
+	// Create or migrate tables
+	if err := migrate.Apply(db, logger, Schema); err != nil {
 		if closeErr := db.Close(); closeErr != nil {
-			return nil, fmt.Errorf("failed to create tables: %w (also failed to close db: %v)", err, closeErr)
+			return nil, fmt.Errorf("failed to migrate database: %w (also failed to close db: %v)", err, closeErr)
 		}
-		return nil, fmt.Errorf("failed to create tables: %w", err)
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	c := &Cache{
@@ -126,10 +185,16 @@ func NewWithMinFreeSpace(basePath string, maxSize int64, minFreeSpace int64, log
 		db:            db,
 		logger:        logger,
 		activeReaders: make(map[string]int),
+		memory:        newMemoryTier(),
 		pendingAccess: make(map[string]accessRecord),
+		pendingMeta:   make(map[string]accessRecord),
+		pendingLedger: make(map[ledgerKey]ledgerRecord),
+		pendingStats:  make(map[string]packageStatRecord),
+		flushNow:      make(chan struct{}, 1),
 		flushStop:     make(chan struct{}),
 		flushDone:     make(chan struct{}),
 	}
+	c.diskFree = c.getDiskFreeSpace
 
 	// Calculate current size
 	if err := c.calculateSize(); err != nil {
@@ -272,8 +337,45 @@ func formatBytes(b int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
 }
 
-func createTables(db *sql.DB) error {
-	_, err := db.Exec(`
+// Schema is the cache's part of the state database: packages, indices,
+// transfer and package statistics, peer labels, build records, the
+// receipts of transfers from peers and peer bans.
+var Schema = migrate.Schema{
+	Name: "cache",
+	Migrations: []migrate.Migration{
+		{Version: 1, Description: "baseline", Up: baselineSchema},
+		{Version: 2, Description: "transfer receipts", Up: migrate.Exec(`
+			CREATE TABLE peer_receipts (
+				sha256 TEXT NOT NULL,
+				uploader TEXT NOT NULL,
+				start_offset INTEGER NOT NULL,
+				end_offset INTEGER NOT NULL,
+				received_at INTEGER NOT NULL,
+				receipt TEXT NOT NULL,
+				PRIMARY KEY (sha256, uploader, start_offset, end_offset)
+			);
+			CREATE INDEX idx_peer_receipts_uploader ON peer_receipts(uploader);
+			CREATE INDEX idx_peer_receipts_received_at ON peer_receipts(received_at);
+		`)},
+		{Version: 3, Description: "peer bans", Up: migrate.Exec(`
+			CREATE TABLE peer_bans (
+				peer_id TEXT PRIMARY KEY,
+				strikes INTEGER NOT NULL,
+				reason TEXT NOT NULL DEFAULT '',
+				last_strike INTEGER NOT NULL,
+				until INTEGER NOT NULL DEFAULT 0,
+				permanent INTEGER NOT NULL DEFAULT 0
+			);
+		`)},
+	},
+}
+
+// baselineSchema creates the tables as they were before the schema was
+// versioned, and brings databases from older versions up to them: columns
+// and indexes were once added ad hoc on every start, so an existing
+// database may lack any of them.
+func baselineSchema(tx *sql.Tx) error {
+	_, err := tx.Exec(`
 		CREATE TABLE IF NOT EXISTS packages (
 			sha256 TEXT PRIMARY KEY,
 			size INTEGER NOT NULL,
@@ -285,7 +387,12 @@ func createTables(db *sql.DB) error {
 			package_name TEXT DEFAULT '',
 			package_version TEXT DEFAULT '',
 			architecture TEXT DEFAULT '',
-			pinned INTEGER DEFAULT 0
+			pinned INTEGER DEFAULT 0,
+			scanned_at INTEGER DEFAULT 0,
+			origin_repo TEXT DEFAULT '',
+			origin_suite TEXT DEFAULT '',
+			origin_component TEXT DEFAULT '',
+			origin_mirror TEXT DEFAULT ''
 		);
 
 		CREATE TABLE IF NOT EXISTS indices (
@@ -298,82 +405,90 @@ func createTables(db *sql.DB) error {
 			content_type TEXT DEFAULT '',
 			last_accessed INTEGER NOT NULL DEFAULT 0,
 			access_count INTEGER NOT NULL DEFAULT 1,
-			last_validated INTEGER NOT NULL DEFAULT 0
+			last_validated INTEGER NOT NULL DEFAULT 0,
+			fresh_until INTEGER NOT NULL DEFAULT 0
 		);
 
-		CREATE TABLE IF NOT EXISTS downloads (
-			id TEXT PRIMARY KEY,
-			url TEXT NOT NULL,
-			expected_size INTEGER NOT NULL,
-			completed_size INTEGER DEFAULT 0,
-			chunk_size INTEGER NOT NULL,
-			total_chunks INTEGER NOT NULL,
-			status TEXT DEFAULT 'pending',
-			created_at INTEGER NOT NULL,
-			updated_at INTEGER NOT NULL,
-			error TEXT,
-			retry_count INTEGER DEFAULT 0
+		CREATE TABLE IF NOT EXISTS peer_transfers (
+			day TEXT NOT NULL,
+			peer_id TEXT NOT NULL,
+			uploaded INTEGER NOT NULL DEFAULT 0,
+			downloaded INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (day, peer_id)
 		);
 
-		CREATE TABLE IF NOT EXISTS download_chunks (
-			download_id TEXT NOT NULL,
-			chunk_index INTEGER NOT NULL,
-			start_offset INTEGER NOT NULL,
-			end_offset INTEGER NOT NULL,
-			status TEXT DEFAULT 'pending',
-			completed_at INTEGER,
-			PRIMARY KEY (download_id, chunk_index),
-			FOREIGN KEY (download_id) REFERENCES downloads(id) ON DELETE CASCADE
+		CREATE TABLE IF NOT EXISTS package_stats (
+			sha256 TEXT PRIMARY KEY,
+			filename TEXT NOT NULL DEFAULT '',
+			downloads INTEGER NOT NULL DEFAULT 0,
+			uploads INTEGER NOT NULL DEFAULT 0,
+			bytes_uploaded INTEGER NOT NULL DEFAULT 0,
+			last_served INTEGER NOT NULL DEFAULT 0
 		);
 
-		CREATE INDEX IF NOT EXISTS idx_packages_last_accessed
-		ON packages(last_accessed);
-
-		CREATE INDEX IF NOT EXISTS idx_packages_announced
-		ON packages(announced);
-
-		CREATE INDEX IF NOT EXISTS idx_packages_name
-		ON packages(package_name);
-
-		CREATE INDEX IF NOT EXISTS idx_downloads_status
-		ON downloads(status);
+		CREATE TABLE IF NOT EXISTS peer_labels (
+			peer_id TEXT PRIMARY KEY,
+			name TEXT NOT NULL DEFAULT '',
+			tags TEXT NOT NULL DEFAULT '',
+			updated_at INTEGER NOT NULL
+		);
 
-		CREATE INDEX IF NOT EXISTS idx_download_chunks_status
-		ON download_chunks(download_id, status);
+		CREATE TABLE IF NOT EXISTS build_packages (
+			build_key TEXT NOT NULL,
+			sha256 TEXT NOT NULL,
+			url TEXT NOT NULL,
+			size INTEGER NOT NULL DEFAULT 0,
+			first_used INTEGER NOT NULL,
+			last_used INTEGER NOT NULL,
+			PRIMARY KEY (build_key, sha256)
+		);
 	`)
 	if err != nil {
 		return err
 	}
 
-	// Migrations for existing databases
-	_, _ = db.Exec(`ALTER TABLE downloads ADD COLUMN retry_count INTEGER DEFAULT 0`)
 	// The indices table shipped earlier with only (url, etag, last_modified,
-	// fetched_at, path) and was never used; the metadata cache activates it, so
-	// upgraders need the added columns. ADD COLUMN is idempotent-by-intent here
-	// (it errors only when the column already exists, which the ignored result
-	// tolerates).
-	_, _ = db.Exec(`ALTER TABLE indices ADD COLUMN size INTEGER NOT NULL DEFAULT 0`)
-	_, _ = db.Exec(`ALTER TABLE indices ADD COLUMN content_type TEXT DEFAULT ''`)
-	_, _ = db.Exec(`ALTER TABLE indices ADD COLUMN last_accessed INTEGER NOT NULL DEFAULT 0`)
-	_, _ = db.Exec(`ALTER TABLE indices ADD COLUMN access_count INTEGER NOT NULL DEFAULT 1`)
-	_, _ = db.Exec(`ALTER TABLE indices ADD COLUMN last_validated INTEGER NOT NULL DEFAULT 0`)
-	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_indices_last_accessed ON indices(last_accessed)`)
-	_, _ = db.Exec(`ALTER TABLE packages ADD COLUMN package_name TEXT DEFAULT ''`)
-	_, _ = db.Exec(`ALTER TABLE packages ADD COLUMN package_version TEXT DEFAULT ''`)
-	_, _ = db.Exec(`ALTER TABLE packages ADD COLUMN architecture TEXT DEFAULT ''`)
-	_, _ = db.Exec(`ALTER TABLE packages ADD COLUMN pinned INTEGER DEFAULT 0`)
-	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_packages_name ON packages(package_name)`)
-	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_packages_pinned ON packages(pinned)`)
-	// Matches ensureSpace's eviction ORDER BY so candidate ranking is an index
-	// scan instead of a full-table sort on every over-budget Put. Created after
-	// the pinned migration above because the partial-index predicate needs the
-	// column to exist on databases from older versions.
-	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_packages_evict
-		ON packages((last_accessed + access_count * 86400)) WHERE pinned = 0`); err != nil {
-		return fmt.Errorf("failed to create eviction index: %w", err)
+	// fetched_at, path) and was never used; the metadata cache activated it
+	for _, col := range []struct{ table, name, def string }{
+		{"indices", "size", "INTEGER NOT NULL DEFAULT 0"},
+		{"indices", "content_type", "TEXT DEFAULT ''"},
+		{"indices", "last_accessed", "INTEGER NOT NULL DEFAULT 0"},
+		{"indices", "access_count", "INTEGER NOT NULL DEFAULT 1"},
+		{"indices", "last_validated", "INTEGER NOT NULL DEFAULT 0"},
+		{"indices", "fresh_until", "INTEGER NOT NULL DEFAULT 0"},
+		{"indices", "sha256", "TEXT NOT NULL DEFAULT ''"},
+		{"packages", "package_name", "TEXT DEFAULT ''"},
+		{"packages", "package_version", "TEXT DEFAULT ''"},
+		{"packages", "architecture", "TEXT DEFAULT ''"},
+		{"packages", "pinned", "INTEGER DEFAULT 0"},
+		{"packages", "scanned_at", "INTEGER DEFAULT 0"},
+		{"packages", "advertised_until", "INTEGER DEFAULT 0"},
+		{"packages", "origin_repo", "TEXT DEFAULT ''"},
+		{"packages", "origin_suite", "TEXT DEFAULT ''"},
+		{"packages", "origin_component", "TEXT DEFAULT ''"},
+		{"packages", "origin_mirror", "TEXT DEFAULT ''"},
+	} {
+		if err := migrate.AddColumn(tx, col.table, col.name, col.def); err != nil {
+			return fmt.Errorf("add %s.%s: %w", col.table, col.name, err)
+		}
 	}
 
-	return nil
+	_, err = tx.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_packages_last_accessed ON packages(last_accessed);
+		CREATE INDEX IF NOT EXISTS idx_packages_announced ON packages(announced);
+		CREATE INDEX IF NOT EXISTS idx_packages_name ON packages(package_name);
+		CREATE INDEX IF NOT EXISTS idx_packages_origin ON packages(origin_repo, origin_suite);
+		CREATE INDEX IF NOT EXISTS idx_packages_advertised_until ON packages(advertised_until);
+		CREATE INDEX IF NOT EXISTS idx_packages_pinned ON packages(pinned);
+		CREATE INDEX IF NOT EXISTS idx_indices_sha256 ON indices(sha256);
+		CREATE INDEX IF NOT EXISTS idx_indices_last_accessed ON indices(last_accessed);
+
+		-- Matches ensureSpace's eviction ORDER BY so candidate ranking is an
+		-- index scan instead of a full-table sort on every over-budget Put
+		CREATE INDEX IF NOT EXISTS idx_packages_evict
+		ON packages((last_accessed + access_count * 86400)) WHERE pinned = 0;
+	`)
+	return err
 }
 
 // Has checks if a package with the given hash exists in the cache
@@ -424,6 +539,17 @@ func (c *Cache) Get(sha256Hash string) (io.ReadCloser, *Package, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	// Hot small packages are served from the memory tier. Deleting a package
+	// drops it from the tier under the write lock, so a hit is never stale.
+	if data, ok := c.memory.get(sha256Hash); ok {
+		if pkg, err := c.getPackageInfo(sha256Hash); err == nil {
+			c.recordAccess(sha256Hash)
+			c.noteMemoryLookup(MemoryKindPackage, true)
+			return io.NopCloser(bytes.NewReader(data)), pkg, nil
+		}
+		c.memory.remove(sha256Hash)
+	}
+
 	path := c.packagePath(sha256Hash)
 
 	// Use a single critical section for file open and reader tracking
@@ -461,7 +587,21 @@ func (c *Cache) Get(sha256Hash string) (io.ReadCloser, *Package, error) {
 		return nil, nil, err
 	}
 
-	return &trackedReader{file: f, hash: sha256Hash, cache: c}, pkg, nil
+	tr := &trackedReader{file: f, hash: sha256Hash, cache: c}
+	if c.memory.admits(pkg.Size) {
+		// Read it in while still counted as a reader, so it cannot be
+		// deleted between the read and joining the tier
+		c.noteMemoryLookup(MemoryKindPackage, false)
+		rc, err := c.readIntoMemory(sha256Hash, tr, pkg.Size)
+		if closeErr := tr.Close(); closeErr != nil {
+			c.logger.Warn("Failed to close file after reading", zap.Error(closeErr))
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return rc, pkg, nil
+	}
+	return tr, pkg, nil
 }
 
 // recordAccess notes a cache hit for later batched persistence.
@@ -472,7 +612,19 @@ func (c *Cache) recordAccess(sha256Hash string) {
 	rec.last = now
 	rec.count++
 	c.pendingAccess[sha256Hash] = rec
+	full := len(c.pendingAccess) >= maxPendingAccess
 	c.pendingAccessMu.Unlock()
+	if full {
+		c.requestFlush()
+	}
+}
+
+// requestFlush wakes the flusher without waiting for it.
+func (c *Cache) requestFlush() {
+	select {
+	case c.flushNow <- struct{}{}:
+	default:
+	}
 }
 
 // flushAccess persists all pending access records in one transaction.
@@ -518,14 +670,21 @@ func (c *Cache) flushAccess() {
 // accessFlusher periodically persists batched access records until Close.
 func (c *Cache) accessFlusher() {
 	defer close(c.flushDone)
-	ticker := time.NewTicker(15 * time.Second)
+	ticker := time.NewTicker(accessFlushInterval)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-c.flushStop:
 			return
+		case <-c.flushNow:
+			c.flushAccess()
+			c.flushMetadataAccess()
 		case <-ticker.C:
 			c.flushAccess()
+			c.flushMetadataAccess()
+			c.flushLedger()
+			c.flushPackageStats()
+			c.pruneReceipts()
 		}
 	}
 }
@@ -573,7 +732,17 @@ func (c *Cache) Put(data io.Reader, expectedHash string, filename string) error
 		return fmt.Errorf("%w: expected %s, got %s", ErrHashMismatch, expectedHash, actualHash)
 	}
 
-	if commitErr := c.commitVerifiedFile(pendingPath, expectedHash, filename, size); commitErr != nil {
+	scannedAt, screenErr := c.screenFile(pendingPath, expectedHash, filename)
+	if screenErr != nil {
+		if !errors.Is(screenErr, ErrQuarantined) {
+			if removeErr := os.Remove(pendingPath); removeErr != nil {
+				c.logger.Warn("Failed to remove pending file during cleanup", zap.Error(removeErr))
+			}
+		}
+		return screenErr
+	}
+
+	if commitErr := c.commitVerifiedFile(pendingPath, expectedHash, filename, size, scannedAt); commitErr != nil {
 		if removeErr := os.Remove(pendingPath); removeErr != nil {
 			c.logger.Warn("Failed to remove pending file during cleanup", zap.Error(removeErr))
 		}
@@ -582,19 +751,36 @@ func (c *Cache) Put(data io.Reader, expectedHash string, filename string) error
 	return nil
 }
 
+// CreatePending creates a temporary file on the cache's filesystem for a
+// package the caller writes and hashes itself, then stores with PutFile. The
+// caller removes the file if it does not store it.
+func (c *Cache) CreatePending() (*os.File, error) {
+	f, err := os.CreateTemp(filepath.Join(c.basePath, "packages", "pending"), "import.*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	return f, nil
+}
+
 // PutFile stores a pre-verified file in the cache by moving it.
 // The file at filePath must already have been verified (correct hash).
 // This is more efficient than Put() for large files as it avoids copying.
-// On failure the source file is left in place so the caller can still use it.
+// On failure the source file is left in place so the caller can still use it,
+// except after ErrQuarantined, when it has been moved to quarantine.
 func (c *Cache) PutFile(filePath string, hash string, filename string, size int64) error {
-	return c.commitVerifiedFile(filePath, hash, filename, size)
+	scannedAt, err := c.screenFile(filePath, hash, filename)
+	if err != nil {
+		return err
+	}
+	return c.commitVerifiedFile(filePath, hash, filename, size, scannedAt)
 }
 
 // commitVerifiedFile moves an already-verified file into the cache and records
 // it, under the cache lock. Shared by Put and PutFile. On failure the source
 // file is left in place (callers rely on this to serve a package that could
-// not be cached, e.g. when the cache is full).
-func (c *Cache) commitVerifiedFile(filePath string, hash string, filename string, size int64) error {
+// not be cached, e.g. when the cache is full). scannedAt is when the screen
+// passed the file, or 0 if there is no screen.
+func (c *Cache) commitVerifiedFile(filePath string, hash string, filename string, size int64, scannedAt int64) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -634,8 +820,8 @@ func (c *Cache) commitVerifiedFile(filePath string, hash string, filename string
 	now := time.Now().Unix()
 	_, err := c.db.Exec(`
 		INSERT INTO packages
-		(sha256, size, filename, added_at, last_accessed, access_count, announced, package_name, package_version, architecture)
-		VALUES (?, ?, ?, ?, ?, 1, 0, ?, ?, ?)
+		(sha256, size, filename, added_at, last_accessed, access_count, announced, package_name, package_version, architecture, scanned_at)
+		VALUES (?, ?, ?, ?, ?, 1, 0, ?, ?, ?, ?)
 		ON CONFLICT(sha256) DO UPDATE SET
 			size = excluded.size,
 			filename = excluded.filename,
@@ -643,8 +829,9 @@ func (c *Cache) commitVerifiedFile(filePath string, hash string, filename string
 			access_count = access_count + 1,
 			package_name = CASE WHEN excluded.package_name != '' THEN excluded.package_name ELSE packages.package_name END,
 			package_version = CASE WHEN excluded.package_version != '' THEN excluded.package_version ELSE packages.package_version END,
-			architecture = CASE WHEN excluded.architecture != '' THEN excluded.architecture ELSE packages.architecture END`,
-		hash, size, filename, now, now, pkgName, pkgVersion, arch)
+			architecture = CASE WHEN excluded.architecture != '' THEN excluded.architecture ELSE packages.architecture END,
+			scanned_at = MAX(COALESCE(packages.scanned_at, 0), excluded.scanned_at)`,
+		hash, size, filename, now, now, pkgName, pkgVersion, arch, scannedAt)
 	if err != nil {
 		return fmt.Errorf("failed to record package: %w", err)
 	}
@@ -702,6 +889,7 @@ func (c *Cache) deleteUnlocked(sha256Hash string, sizeHint int64) error {
 	// (ensureSpace gates on currentSize, not real disk usage) and never reclaimed
 	// the orphaned file. Callers (eviction) log the error and try the next
 	// candidate, so a locked file is simply skipped.
+	c.memory.remove(sha256Hash)
 	path := c.packagePath(sha256Hash)
 	if removeErr := os.Remove(path); removeErr != nil && !os.IsNotExist(removeErr) {
 		return fmt.Errorf("failed to remove cache file for %s: %w", sha256Hash, removeErr)
@@ -724,9 +912,7 @@ func (c *Cache) List() ([]*Package, error) {
 	defer c.mu.RUnlock()
 
 	rows, err := c.db.Query(`
-		SELECT sha256, size, filename, added_at, last_accessed, access_count, announced,
-		       COALESCE(package_name, ''), COALESCE(package_version, ''), COALESCE(architecture, ''),
-		       COALESCE(pinned, 0)
+		SELECT ` + packageColumns + `
 		FROM packages
 		ORDER BY last_accessed DESC`)
 	if err != nil {
@@ -736,39 +922,33 @@ func (c *Cache) List() ([]*Package, error) {
 
 	var packages []*Package
 	for rows.Next() {
-		pkg := &Package{}
-		var addedAt, lastAccessed, announced int64
-		var pinned int
-		err := rows.Scan(
-			&pkg.SHA256, &pkg.Size, &pkg.Filename,
-			&addedAt, &lastAccessed, &pkg.AccessCount, &announced,
-			&pkg.PackageName, &pkg.PackageVersion, &pkg.Architecture,
-			&pinned)
+		pkg, err := scanPackage(rows)
 		if err != nil {
 			return nil, err
 		}
-		pkg.AddedAt = time.Unix(addedAt, 0)
-		pkg.LastAccessed = time.Unix(lastAccessed, 0)
-		pkg.Announced = time.Unix(announced, 0)
-		pkg.Pinned = pinned != 0
 		packages = append(packages, pkg)
 	}
 
 	return packages, rows.Err()
 }
 
-// GetUnannounced returns packages that need to be announced to the DHT
+// GetUnannounced returns packages with no provider record in the DHT: never
+// announced, or whose record has expired
 func (c *Cache) GetUnannounced() ([]*Package, error) {
+	return c.GetAnnounceDue(time.Now())
+}
+
+// GetAnnounceDue returns packages whose provider record expires before the
+// given time, including those never announced
+func (c *Cache) GetAnnounceDue(before time.Time) ([]*Package, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	threshold := time.Now().Add(-12 * time.Hour).Unix()
+	threshold := before.Unix()
 	rows, err := c.db.Query(`
-		SELECT sha256, size, filename, added_at, last_accessed, access_count, announced,
-		       COALESCE(package_name, ''), COALESCE(package_version, ''), COALESCE(architecture, ''),
-		       COALESCE(pinned, 0)
+		SELECT `+packageColumns+`
 		FROM packages
-		WHERE announced < ?`, threshold)
+		WHERE COALESCE(advertised_until, 0) < ?`, threshold)
 	if err != nil {
 		return nil, err
 	}
@@ -776,38 +956,45 @@ func (c *Cache) GetUnannounced() ([]*Package, error) {
 
 	var packages []*Package
 	for rows.Next() {
-		pkg := &Package{}
-		var addedAt, lastAccessed, announced int64
-		var pinned int
-		err := rows.Scan(
-			&pkg.SHA256, &pkg.Size, &pkg.Filename,
-			&addedAt, &lastAccessed, &pkg.AccessCount, &announced,
-			&pkg.PackageName, &pkg.PackageVersion, &pkg.Architecture,
-			&pinned)
+		pkg, err := scanPackage(rows)
 		if err != nil {
 			return nil, err
 		}
-		pkg.AddedAt = time.Unix(addedAt, 0)
-		pkg.LastAccessed = time.Unix(lastAccessed, 0)
-		pkg.Announced = time.Unix(announced, 0)
-		pkg.Pinned = pinned != 0
 		packages = append(packages, pkg)
 	}
 
 	return packages, rows.Err()
 }
 
-// MarkAnnounced updates the announced timestamp for a package
-func (c *Cache) MarkAnnounced(sha256Hash string) error {
+// MarkAnnounced records a successful announcement of a package whose
+// provider record is valid for ttl
+func (c *Cache) MarkAnnounced(sha256Hash string, ttl time.Duration) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	now := time.Now()
 	_, err := c.db.Exec(
-		"UPDATE packages SET announced = ? WHERE sha256 = ?",
-		time.Now().Unix(), sha256Hash)
+		"UPDATE packages SET announced = ?, advertised_until = ? WHERE sha256 = ?",
+		now.Unix(), now.Add(ttl).Unix(), sha256Hash)
 	return err
 }
 
+// NextAnnounceDue returns when the earliest provider record of a cached
+// package expires. ok is false when the cache is empty.
+func (c *Cache) NextAnnounceDue() (due time.Time, ok bool, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var until sql.NullInt64
+	if err := c.db.QueryRow(`SELECT MIN(COALESCE(advertised_until, 0)) FROM packages`).Scan(&until); err != nil {
+		return time.Time{}, false, err
+	}
+	if !until.Valid {
+		return time.Time{}, false, nil
+	}
+	return time.Unix(until.Int64, 0), true, nil
+}
+
 // Size returns the current cache size in bytes
 func (c *Cache) Size() int64 {
 	c.mu.RLock()
@@ -832,6 +1019,9 @@ func (c *Cache) Close() error {
 		close(c.flushStop)
 		<-c.flushDone
 		c.flushAccess()
+		c.flushMetadataAccess()
+		c.flushLedger()
+		c.flushPackageStats()
 	})
 	return c.db.Close()
 }
@@ -845,27 +1035,46 @@ func (c *Cache) packagePath(sha256Hash string) string {
 	return filepath.Join(c.basePath, "packages", "sha256", sha256Hash[:2], sha256Hash)
 }
 
+// Info returns the metadata of a cached package without opening it or
+// counting an access. Returns ErrNotFound if the package is not cached.
+func (c *Cache) Info(sha256Hash string) (*Package, error) {
+	pkg, err := c.getPackageInfo(sha256Hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return pkg, err
+}
+
 func (c *Cache) getPackageInfo(sha256Hash string) (*Package, error) {
-	pkg := &Package{}
-	var addedAt, lastAccessed, announced int64
-	var pinned int
+	return scanPackage(c.db.QueryRow(`
+		SELECT `+packageColumns+`
+		FROM packages WHERE sha256 = ?`, sha256Hash))
+}
 
-	err := c.db.QueryRow(`
-		SELECT sha256, size, filename, added_at, last_accessed, access_count, announced,
+// packageColumns are the packages columns scanPackage reads, in order
+const packageColumns = `sha256, size, filename, added_at, last_accessed, access_count, announced, COALESCE(advertised_until, 0),
 		       COALESCE(package_name, ''), COALESCE(package_version, ''), COALESCE(architecture, ''),
-		       COALESCE(pinned, 0)
-		FROM packages WHERE sha256 = ?`, sha256Hash).Scan(
+		       COALESCE(pinned, 0),
+		       COALESCE(origin_repo, ''), COALESCE(origin_suite, ''), COALESCE(origin_component, ''), COALESCE(origin_mirror, '')`
+
+// scanPackage reads a row selected with packageColumns.
+func scanPackage(row interface{ Scan(...any) error }) (*Package, error) {
+	pkg := &Package{}
+	var addedAt, lastAccessed, announced, advertisedUntil int64
+	var pinned int
+	err := row.Scan(
 		&pkg.SHA256, &pkg.Size, &pkg.Filename,
-		&addedAt, &lastAccessed, &pkg.AccessCount, &announced,
+		&addedAt, &lastAccessed, &pkg.AccessCount, &announced, &advertisedUntil,
 		&pkg.PackageName, &pkg.PackageVersion, &pkg.Architecture,
-		&pinned)
+		&pinned,
+		&pkg.Origin.Repo, &pkg.Origin.Suite, &pkg.Origin.Component, &pkg.Origin.Mirror)
 	if err != nil {
 		return nil, err
 	}
-
 	pkg.AddedAt = time.Unix(addedAt, 0)
 	pkg.LastAccessed = time.Unix(lastAccessed, 0)
 	pkg.Announced = time.Unix(announced, 0)
+	pkg.AdvertisedUntil = time.Unix(advertisedUntil, 0)
 	pkg.Pinned = pinned != 0
 	return pkg, nil
 }
@@ -897,7 +1106,7 @@ func (c *Cache) ensureSpace(needed int64) error {
 
 	// Check minimum free space constraint first
 	if c.minFreeSpace > 0 {
-		freeSpace, err := c.getDiskFreeSpace()
+		freeSpace, err := c.diskFree()
 		if err != nil {
 			c.logger.Warn("Failed to check disk free space", zap.Error(err))
 		} else if freeSpace-needed < c.minFreeSpace {
@@ -909,8 +1118,15 @@ func (c *Cache) ensureSpace(needed int64) error {
 		}
 	}
 
+	_, err := c.evictToFit(needed)
+	return err
+}
+
+// evictToFit evicts packages until needed more bytes fit within maxSize,
+// returning how many were evicted. Caller must hold c.mu.
+func (c *Cache) evictToFit(needed int64) (int, error) {
 	if c.currentSize+needed <= c.maxSize {
-		return nil
+		return 0, nil
 	}
 
 	// Get packages sorted by eviction score (oldest, least accessed first).
@@ -924,10 +1140,16 @@ func (c *Cache) ensureSpace(needed int64) error {
 		ORDER BY (last_accessed + access_count * 86400) ASC`,
 		time.Now().Add(-7*24*time.Hour).Unix()) // Don't evict recently accessed
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer rows.Close()
 
+	evicted, freed := 0, int64(0)
+	defer func() {
+		if evicted > 0 && c.onEvictionSweep != nil {
+			c.onEvictionSweep(evicted, freed, c.currentSize)
+		}
+	}()
 	for rows.Next() && c.currentSize+needed > c.maxSize {
 		var hash string
 		var size int64
@@ -942,20 +1164,24 @@ func (c *Cache) ensureSpace(needed int64) error {
 		if err := c.deleteUnlocked(hash, size); err != nil {
 			// Log but continue - file might be in use, try next candidate
 			c.logger.Warn("Failed to evict package", zap.Error(err))
-		} else if c.onEvict != nil {
-			c.onEvict()
+			continue
+		}
+		evicted++
+		freed += size
+		if c.onEvict != nil {
+			c.onEvict(EvictCapacity)
 		}
 	}
 	if err := rows.Err(); err != nil {
-		return fmt.Errorf("error iterating eviction candidates: %w", err)
+		return evicted, fmt.Errorf("error iterating eviction candidates: %w", err)
 	}
 
 	// Check if we freed enough space
 	if c.currentSize+needed > c.maxSize {
-		return ErrCacheFull
+		return evicted, ErrCacheFull
 	}
 
-	return nil
+	return evicted, nil
 }
 
 // GetDB returns the underlying database connection
@@ -964,6 +1190,13 @@ func (c *Cache) GetDB() *sql.DB {
 	return c.db
 }
 
+// Migrate brings the schemas of other subsystems that keep tables in the
+// cache's database, such as the downloader's resume state, to their latest
+// versions.
+func (c *Cache) Migrate(schemas ...migrate.Schema) error {
+	return migrate.Apply(c.db, c.logger, schemas...)
+}
+
 // PartialDir returns the directory for partial downloads
 func (c *Cache) PartialDir(hash string) string {
 	return filepath.Join(c.basePath, "packages", "partial", hash)
@@ -1026,15 +1259,44 @@ func (c *Cache) BasePath() string {
 
 // MaxSize returns the configured cache capacity in bytes.
 func (c *Cache) MaxSize() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.maxSize
 }
 
-// SetOnEvict registers a callback invoked once per evicted package. Must be
-// set before the cache is in use (not synchronized with concurrent stores).
-func (c *Cache) SetOnEvict(fn func()) {
+// SetLimits changes the cache capacity and minimum free disk space of a
+// running cache. A smaller capacity is enforced as packages are stored; the
+// cache is not shrunk eagerly.
+func (c *Cache) SetLimits(maxSize, minFreeSpace int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxSize = maxSize
+	c.minFreeSpace = minFreeSpace
+}
+
+// Eviction reasons passed to the SetOnEvict callback
+const (
+	// EvictCapacity: evicted to make room for a new package
+	EvictCapacity = "capacity"
+	// EvictDiskPressure: evicted because free disk space fell below the minimum
+	EvictDiskPressure = "disk_pressure"
+)
+
+// SetOnEvict registers a callback invoked once per evicted package with the
+// reason it was evicted. Must be set before the cache is in use (not
+// synchronized with concurrent stores).
+func (c *Cache) SetOnEvict(fn func(reason string)) {
 	c.onEvict = fn
 }
 
+// SetOnEvictionSweep registers a callback invoked once each time packages
+// were evicted to make room for a new one, with how many, the bytes freed
+// and the cache size after. Like SetOnEvict, it must be set before the
+// cache is in use.
+func (c *Cache) SetOnEvictionSweep(fn func(evicted int, freed, sizeAfter int64)) {
+	c.onEvictionSweep = fn
+}
+
 // ListByPackageName returns all cached versions of a package by name.
 // Results are sorted by last_accessed descending (most recently used first).
 func (c *Cache) ListByPackageName(name string) ([]*Package, error) {
@@ -1042,9 +1304,7 @@ func (c *Cache) ListByPackageName(name string) ([]*Package, error) {
 	defer c.mu.RUnlock()
 
 	rows, err := c.db.Query(`
-		SELECT sha256, size, filename, added_at, last_accessed, access_count, announced,
-		       COALESCE(package_name, ''), COALESCE(package_version, ''), COALESCE(architecture, ''),
-		       COALESCE(pinned, 0)
+		SELECT `+packageColumns+`
 		FROM packages
 		WHERE package_name = ?
 		ORDER BY last_accessed DESC`, name)
@@ -1055,21 +1315,10 @@ func (c *Cache) ListByPackageName(name string) ([]*Package, error) {
 
 	var packages []*Package
 	for rows.Next() {
-		pkg := &Package{}
-		var addedAt, lastAccessed, announced int64
-		var pinned int
-		err := rows.Scan(
-			&pkg.SHA256, &pkg.Size, &pkg.Filename,
-			&addedAt, &lastAccessed, &pkg.AccessCount, &announced,
-			&pkg.PackageName, &pkg.PackageVersion, &pkg.Architecture,
-			&pinned)
+		pkg, err := scanPackage(rows)
 		if err != nil {
 			return nil, err
 		}
-		pkg.AddedAt = time.Unix(addedAt, 0)
-		pkg.LastAccessed = time.Unix(lastAccessed, 0)
-		pkg.Announced = time.Unix(announced, 0)
-		pkg.Pinned = pinned != 0
 		packages = append(packages, pkg)
 	}
 
@@ -1082,32 +1331,53 @@ func (c *Cache) GetByNameVersionArch(name, version, arch string) (*Package, erro
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	pkg := &Package{}
-	var addedAt, lastAccessed, announced int64
-	var pinned int
-
-	err := c.db.QueryRow(`
-		SELECT sha256, size, filename, added_at, last_accessed, access_count, announced,
-		       COALESCE(package_name, ''), COALESCE(package_version, ''), COALESCE(architecture, ''),
-		       COALESCE(pinned, 0)
+	pkg, err := scanPackage(c.db.QueryRow(`
+		SELECT `+packageColumns+`
 		FROM packages
-		WHERE package_name = ? AND package_version = ? AND architecture = ?`, name, version, arch).Scan(
-		&pkg.SHA256, &pkg.Size, &pkg.Filename,
-		&addedAt, &lastAccessed, &pkg.AccessCount, &announced,
-		&pkg.PackageName, &pkg.PackageVersion, &pkg.Architecture,
-		&pinned)
+		WHERE package_name = ? AND package_version = ? AND architecture = ?`, name, version, arch))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return pkg, err
+}
+
+// SetPackageMetadata records a package's name, version and architecture as
+// read from its control file, replacing what was parsed from the filename.
+// Returns ErrNotFound if the package is not in the cache.
+func (c *Cache) SetPackageMetadata(sha256Hash, name, version, arch string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res, err := c.db.Exec(`
+		UPDATE packages
+		SET package_name = ?, package_version = ?, architecture = ?
+		WHERE sha256 = ?`, name, version, arch, sha256Hash)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, ErrNotFound
-		}
-		return nil, err
+		return fmt.Errorf("failed to update package metadata: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
 	}
+	return nil
+}
 
-	pkg.AddedAt = time.Unix(addedAt, 0)
-	pkg.LastAccessed = time.Unix(lastAccessed, 0)
-	pkg.Announced = time.Unix(announced, 0)
-	pkg.Pinned = pinned != 0
-	return pkg, nil
+// SetOrigin records where a cached package came from. Returns ErrNotFound if
+// the package is not in the cache.
+func (c *Cache) SetOrigin(sha256Hash string, o Origin) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res, err := c.db.Exec(`
+		UPDATE packages
+		SET origin_repo = ?, origin_suite = ?, origin_component = ?, origin_mirror = ?
+		WHERE sha256 = ?`, o.Repo, o.Suite, o.Component, o.Mirror, sha256Hash)
+	if err != nil {
+		return fmt.Errorf("failed to update package origin: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
 // CacheStats holds comprehensive cache statistics
@@ -1174,9 +1444,7 @@ func (c *Cache) PopularPackages(limit int) ([]*Package, error) {
 	}
 
 	rows, err := c.db.Query(`
-		SELECT sha256, size, filename, added_at, last_accessed, access_count, announced,
-		       COALESCE(package_name, ''), COALESCE(package_version, ''), COALESCE(architecture, ''),
-		       COALESCE(pinned, 0)
+		SELECT `+packageColumns+`
 		FROM packages
 		ORDER BY access_count DESC, last_accessed DESC
 		LIMIT ?`, limit)
@@ -1187,21 +1455,10 @@ func (c *Cache) PopularPackages(limit int) ([]*Package, error) {
 
 	var packages []*Package
 	for rows.Next() {
-		pkg := &Package{}
-		var addedAt, lastAccessed, announced int64
-		var pinned int
-		err := rows.Scan(
-			&pkg.SHA256, &pkg.Size, &pkg.Filename,
-			&addedAt, &lastAccessed, &pkg.AccessCount, &announced,
-			&pkg.PackageName, &pkg.PackageVersion, &pkg.Architecture,
-			&pinned)
+		pkg, err := scanPackage(rows)
 		if err != nil {
 			return nil, err
 		}
-		pkg.AddedAt = time.Unix(addedAt, 0)
-		pkg.LastAccessed = time.Unix(lastAccessed, 0)
-		pkg.Announced = time.Unix(announced, 0)
-		pkg.Pinned = pinned != 0
 		packages = append(packages, pkg)
 	}
 
@@ -1218,9 +1475,7 @@ func (c *Cache) RecentPackages(limit int) ([]*Package, error) {
 	}
 
 	rows, err := c.db.Query(`
-		SELECT sha256, size, filename, added_at, last_accessed, access_count, announced,
-		       COALESCE(package_name, ''), COALESCE(package_version, ''), COALESCE(architecture, ''),
-		       COALESCE(pinned, 0)
+		SELECT `+packageColumns+`
 		FROM packages
 		ORDER BY last_accessed DESC
 		LIMIT ?`, limit)
@@ -1231,21 +1486,10 @@ func (c *Cache) RecentPackages(limit int) ([]*Package, error) {
 
 	var packages []*Package
 	for rows.Next() {
-		pkg := &Package{}
-		var addedAt, lastAccessed, announced int64
-		var pinned int
-		err := rows.Scan(
-			&pkg.SHA256, &pkg.Size, &pkg.Filename,
-			&addedAt, &lastAccessed, &pkg.AccessCount, &announced,
-			&pkg.PackageName, &pkg.PackageVersion, &pkg.Architecture,
-			&pinned)
+		pkg, err := scanPackage(rows)
 		if err != nil {
 			return nil, err
 		}
-		pkg.AddedAt = time.Unix(addedAt, 0)
-		pkg.LastAccessed = time.Unix(lastAccessed, 0)
-		pkg.Announced = time.Unix(announced, 0)
-		pkg.Pinned = pinned != 0
 		packages = append(packages, pkg)
 	}
 
@@ -1372,9 +1616,7 @@ func (c *Cache) ListPinned() ([]*Package, error) {
 	defer c.mu.RUnlock()
 
 	rows, err := c.db.Query(`
-		SELECT sha256, size, filename, added_at, last_accessed, access_count, announced,
-		       COALESCE(package_name, ''), COALESCE(package_version, ''), COALESCE(architecture, ''),
-		       COALESCE(pinned, 0)
+		SELECT ` + packageColumns + `
 		FROM packages
 		WHERE pinned = 1
 		ORDER BY last_accessed DESC`)
@@ -1385,21 +1627,10 @@ func (c *Cache) ListPinned() ([]*Package, error) {
 
 	var packages []*Package
 	for rows.Next() {
-		pkg := &Package{}
-		var addedAt, lastAccessed, announced int64
-		var pinned int
-		err := rows.Scan(
-			&pkg.SHA256, &pkg.Size, &pkg.Filename,
-			&addedAt, &lastAccessed, &pkg.AccessCount, &announced,
-			&pkg.PackageName, &pkg.PackageVersion, &pkg.Architecture,
-			&pinned)
+		pkg, err := scanPackage(rows)
 		if err != nil {
 			return nil, err
 		}
-		pkg.AddedAt = time.Unix(addedAt, 0)
-		pkg.LastAccessed = time.Unix(lastAccessed, 0)
-		pkg.Announced = time.Unix(announced, 0)
-		pkg.Pinned = pinned != 0
 		packages = append(packages, pkg)
 	}
 
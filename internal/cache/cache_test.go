@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -61,6 +63,19 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestSetLimits(t *testing.T) {
+	c, _ := testCache(t)
+
+	c.SetLimits(16, 0)
+	if got := c.MaxSize(); got != 16 {
+		t.Fatalf("MaxSize() = %d, want 16", got)
+	}
+	data := []byte("larger than the new sixteen byte limit")
+	if err := c.Put(bytes.NewReader(data), hashData(data), "big.deb"); !errors.Is(err, ErrCacheFull) {
+		t.Fatalf("Put over the lowered limit: err = %v, want ErrCacheFull", err)
+	}
+}
+
 func TestPutAndGet(t *testing.T) {
 	c, _ := testCache(t)
 
@@ -344,7 +359,7 @@ func TestMarkAnnounced(t *testing.T) {
 	}
 
 	// Mark as announced
-	err = c.MarkAnnounced(hash)
+	err = c.MarkAnnounced(hash, 24*time.Hour)
 	if err != nil {
 		t.Fatalf("MarkAnnounced failed: %v", err)
 	}
@@ -359,6 +374,55 @@ func TestMarkAnnounced(t *testing.T) {
 	}
 }
 
+func TestAnnounceDue(t *testing.T) {
+	c, _ := testCache(t)
+
+	if _, ok, err := c.NextAnnounceDue(); err != nil || ok {
+		t.Fatalf("NextAnnounceDue on empty cache = ok %v, err %v; want false, nil", ok, err)
+	}
+
+	short := []byte("short-lived record")
+	long := []byte("long-lived record")
+	for _, data := range [][]byte{short, long} {
+		if err := c.Put(bytes.NewReader(data), hashData(data), "pkg.deb"); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := c.MarkAnnounced(hashData(short), time.Hour); err != nil {
+		t.Fatalf("MarkAnnounced failed: %v", err)
+	}
+	if err := c.MarkAnnounced(hashData(long), 24*time.Hour); err != nil {
+		t.Fatalf("MarkAnnounced failed: %v", err)
+	}
+
+	due, err := c.GetAnnounceDue(time.Now().Add(2 * time.Hour))
+	if err != nil {
+		t.Fatalf("GetAnnounceDue failed: %v", err)
+	}
+	if len(due) != 1 || due[0].SHA256 != hashData(short) {
+		t.Fatalf("due within 2h = %v, want only the short-lived record", due)
+	}
+	if until := time.Until(due[0].AdvertisedUntil); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("AdvertisedUntil is %v away, want about 1h", until)
+	}
+
+	next, ok, err := c.NextAnnounceDue()
+	if err != nil || !ok {
+		t.Fatalf("NextAnnounceDue = ok %v, err %v", ok, err)
+	}
+	if !next.Equal(due[0].AdvertisedUntil) {
+		t.Errorf("NextAnnounceDue = %v, want %v", next, due[0].AdvertisedUntil)
+	}
+
+	pkg, err := c.Info(hashData(long))
+	if err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+	if time.Until(pkg.AdvertisedUntil) < 23*time.Hour {
+		t.Errorf("AdvertisedUntil = %v, want about 24h away", pkg.AdvertisedUntil)
+	}
+}
+
 func TestEviction(t *testing.T) {
 	tmpDir := t.TempDir()
 	// Create cache with very small max size (1KB)
@@ -395,6 +459,46 @@ func TestEviction(t *testing.T) {
 	_ = c.Put(bytes.NewReader(data3), hash3, "pkg3.deb")
 }
 
+func TestEvictionSweepCallback(t *testing.T) {
+	c, err := New(t.TempDir(), 1024, testLogger())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+	type sweep struct {
+		evicted     int
+		freed, size int64
+	}
+	var sweeps []sweep
+	c.SetOnEvictionSweep(func(evicted int, freed, size int64) {
+		sweeps = append(sweeps, sweep{evicted, freed, size})
+	})
+
+	for i := range 3 {
+		data := make([]byte, 300)
+		copy(data, fmt.Sprintf("package%d", i))
+		if err := c.Put(bytes.NewReader(data), hashData(data), fmt.Sprintf("pkg%d.deb", i)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if len(sweeps) != 0 {
+		t.Fatalf("sweeps without eviction: %v", sweeps)
+	}
+	c.flushAccess()
+	if _, err := c.db.Exec("UPDATE packages SET last_accessed = ?", time.Now().Add(-30*24*time.Hour).Unix()); err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 600)
+	copy(data, "package3")
+	if err := c.Put(bytes.NewReader(data), hashData(data), "pkg3.deb"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if len(sweeps) != 1 || sweeps[0].evicted != 2 || sweeps[0].freed != 600 || sweeps[0].size != 300 {
+		t.Errorf("sweeps = %+v, want one of 2 packages, 600 bytes", sweeps)
+	}
+}
+
 func TestConcurrentAccess(t *testing.T) {
 	c, _ := testCache(t)
 
@@ -863,6 +967,66 @@ func TestListByPackageName(t *testing.T) {
 	}
 }
 
+func TestSetPackageMetadata(t *testing.T) {
+	c, _ := testCache(t)
+
+	// A file name that does not follow name_version_arch.deb
+	data := []byte("renamed package content")
+	hash := hashData(data)
+	if err := c.Put(bytes.NewReader(data), hash, "download.deb"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	if err := c.SetPackageMetadata(hash, "curl", "1:7.88.1-10", "amd64"); err != nil {
+		t.Fatalf("SetPackageMetadata failed: %v", err)
+	}
+	pkg, err := c.GetByNameVersionArch("curl", "1:7.88.1-10", "amd64")
+	if err != nil {
+		t.Fatalf("GetByNameVersionArch failed: %v", err)
+	}
+	if pkg.SHA256 != hash {
+		t.Errorf("Expected hash %q, got %q", hash, pkg.SHA256)
+	}
+
+	if err := c.SetPackageMetadata(hashData([]byte("missing")), "x", "1", "all"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSetOrigin(t *testing.T) {
+	c, _ := testCache(t)
+
+	data := []byte("origin package content")
+	hash := hashData(data)
+	if err := c.Put(bytes.NewReader(data), hash, "pool/main/c/curl/curl_7.88.1-10_amd64.deb"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if pkg, _ := c.Info(hash); pkg.Origin != (Origin{}) {
+		t.Errorf("new package has origin %+v", pkg.Origin)
+	}
+
+	origin := Origin{
+		Repo:      "deb.debian.org/debian",
+		Suite:     "bookworm-updates",
+		Component: "main",
+		Mirror:    "http://deb.debian.org/debian/pool/main/c/curl/curl_7.88.1-10_amd64.deb",
+	}
+	if err := c.SetOrigin(hash, origin); err != nil {
+		t.Fatalf("SetOrigin failed: %v", err)
+	}
+	if pkg, _ := c.Info(hash); pkg.Origin != origin {
+		t.Errorf("Info origin = %+v, want %+v", pkg.Origin, origin)
+	}
+	list, err := c.List()
+	if err != nil || len(list) != 1 || list[0].Origin != origin {
+		t.Errorf("List origin = %+v, %v", list, err)
+	}
+
+	if err := c.SetOrigin(hashData([]byte("missing")), origin); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
 func TestGetByNameVersionArch(t *testing.T) {
 	c, _ := testCache(t)
 
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// DiskCapacity returns the total size in bytes of the filesystem that holds,
+// or will hold, path: the nearest existing directory is measured when path
+// has not been created yet.
+func DiskCapacity(path string) (int64, error) {
+	for {
+		size, err := diskCapacity(path)
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			return size, err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return 0, err
+		}
+		path = parent
+	}
+}
+
+// Trim evicts packages until the cache fits its capacity again, as storing
+// a package would, after the capacity was lowered with SetLimits. Packages
+// accessed within the last week are kept, so the cache may stay above its
+// capacity until they age. It returns how many packages were evicted.
+func (c *Cache) Trim() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.currentSize <= c.maxSize {
+		return 0, nil
+	}
+	c.flushAccess()
+	evicted, err := c.evictToFit(0)
+	if errors.Is(err, ErrCacheFull) {
+		err = nil
+	}
+	return evicted, err
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskCapacity(t *testing.T) {
+	dir := t.TempDir()
+	size, err := DiskCapacity(dir)
+	if err != nil || size <= 0 {
+		t.Fatalf("DiskCapacity = %d, %v", size, err)
+	}
+	// A cache directory not created yet is on its parent's filesystem
+	if got, err := DiskCapacity(filepath.Join(dir, "not", "yet")); err != nil || got != size {
+		t.Errorf("DiskCapacity of a missing directory = %d, %v; want %d", got, err, size)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	c := pressureCache(t, 0)
+	old1 := putTestContent(t, c, bytes.Repeat([]byte("1"), 100), "old1.deb")
+	old2 := putTestContent(t, c, bytes.Repeat([]byte("2"), 100), "old2.deb")
+	recent := putTestContent(t, c, bytes.Repeat([]byte("r"), 100), "recent.deb")
+	monthAgo := time.Now().Add(-30 * 24 * time.Hour).Unix()
+	for _, hash := range []string{old1, old2} {
+		if _, err := c.db.Exec("UPDATE packages SET last_accessed = ? WHERE sha256 = ?", monthAgo, hash); err != nil {
+			t.Fatalf("age package: %v", err)
+		}
+	}
+
+	if n, err := c.Trim(); err != nil || n != 0 {
+		t.Fatalf("Trim within capacity = %d, %v; want 0, nil", n, err)
+	}
+
+	c.SetLimits(200, 0)
+	if n, err := c.Trim(); err != nil || n != 1 {
+		t.Fatalf("Trim to 200 = %d, %v; want 1 evicted", n, err)
+	}
+	if c.Size() != 200 {
+		t.Errorf("size = %d, want 200", c.Size())
+	}
+
+	// Recently accessed packages are kept even above capacity
+	c.SetLimits(50, 0)
+	if _, err := c.Trim(); err != nil {
+		t.Fatalf("Trim to 50: %v", err)
+	}
+	if !c.Has(recent) || c.Size() != 100 {
+		t.Errorf("size = %d, recent kept = %v; want only the recent package", c.Size(), c.Has(recent))
+	}
+}
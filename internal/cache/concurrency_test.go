@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"io"
 	"testing"
 	"time"
@@ -95,6 +96,65 @@ func TestStats_SeesUnflushedAccesses(t *testing.T) {
 	}
 }
 
+// TestMetadataAccessBatching verifies that metadata reads are recorded in
+// memory and reach the indices table on flush.
+func TestMetadataAccessBatching(t *testing.T) {
+	c := enabledCache(t, 1<<20)
+	url := "http://x/dists/stable/InRelease"
+	putMeta(t, c, url, "", "", "", []byte("release"))
+
+	count := func() int64 {
+		t.Helper()
+		var n int64
+		if err := c.db.QueryRow(`SELECT access_count FROM indices WHERE url = ?`, url).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	before := count()
+	for range 3 {
+		_, rc, err := c.GetMetadata(url)
+		if err != nil {
+			t.Fatalf("GetMetadata: %v", err)
+		}
+		_ = rc.Close()
+	}
+	if got := count(); got != before {
+		t.Errorf("access_count = %d before flush, want %d (reads must not write)", got, before)
+	}
+	c.flushMetadataAccess()
+	if got := count(); got != before+3 {
+		t.Errorf("access_count = %d after flush, want %d", got, before+3)
+	}
+}
+
+// TestAccessBatching_FlushesEarlyWhenFull verifies that a full batch wakes
+// the flusher instead of waiting for the next tick.
+func TestAccessBatching_FlushesEarlyWhenFull(t *testing.T) {
+	c, err := New(t.TempDir(), 1<<20, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	for i := range maxPendingAccess {
+		c.recordAccess(fmt.Sprintf("%064x", i))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.pendingAccessMu.Lock()
+		n := len(c.pendingAccess)
+		c.pendingAccessMu.Unlock()
+		if n < maxPendingAccess {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("full access batch was not flushed early")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 // slowReader trickles its payload so a Put stays in its copy phase long enough
 // for the test to probe concurrent reads.
 type slowReader struct {
@@ -1,8 +1,12 @@
 package cache
 
 import (
+	"database/sql"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/debswarm/debswarm/internal/migrate"
 )
 
 // TestOpenDatabase_WALEnabled verifies the SQLite connection actually runs with
@@ -47,3 +51,94 @@ func TestOpenDatabase_WALEnabled(t *testing.T) {
 		t.Errorf("busy_timeout = %d, want > 0", busy)
 	}
 }
+
+// TestLegacyDatabaseMigration opens a database from before the schema was
+// versioned, with the original packages and indices tables, and checks it
+// is brought up to the baseline without losing data.
+func TestLegacyDatabaseMigration(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "state.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE packages (
+			sha256 TEXT PRIMARY KEY,
+			size INTEGER NOT NULL,
+			filename TEXT NOT NULL,
+			added_at INTEGER NOT NULL,
+			last_accessed INTEGER NOT NULL,
+			access_count INTEGER DEFAULT 1,
+			announced INTEGER DEFAULT 0
+		);
+		CREATE TABLE indices (
+			url TEXT PRIMARY KEY,
+			etag TEXT,
+			last_modified TEXT,
+			fetched_at INTEGER NOT NULL,
+			path TEXT NOT NULL
+		);
+		INSERT INTO packages (sha256, size, filename, added_at, last_accessed)
+		VALUES ('abc', 3, 'old.deb', 1, 1);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	c, err := New(dir, 1<<20, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	if v, err := migrate.Version(c.GetDB(), Schema.Name); err != nil || v != Schema.Latest() {
+		t.Errorf("schema version = %d, %v; want %d", v, err, Schema.Latest())
+	}
+	var name string
+	var pinned int
+	if err := c.GetDB().QueryRow(`SELECT filename, pinned FROM packages WHERE sha256 = 'abc'`).Scan(&name, &pinned); err != nil || name != "old.deb" {
+		t.Errorf("legacy row = %q, %v", name, err)
+	}
+	if _, err := c.GetDB().Exec(`UPDATE indices SET sha256 = '', fresh_until = 0`); err != nil {
+		t.Errorf("indices columns not added: %v", err)
+	}
+	if backups, _ := filepath.Glob(dbPath + ".pre-migrate.*"); len(backups) != 1 {
+		t.Errorf("backups = %v, want 1", backups)
+	}
+}
+
+// TestSchemaUpgradeAddsPeerBans opens a database at version 2, from before
+// peer bans were stored, and checks the upgrade creates their table.
+func TestSchemaUpgradeAddsPeerBans(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite", filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2 := migrate.Schema{Name: Schema.Name, Migrations: Schema.Migrations[:2]}
+	if err := migrate.Apply(db, testLogger(), v2); err != nil {
+		t.Fatalf("Apply v2: %v", err)
+	}
+	// Released migrations must not create it, or a database already at
+	// version 2 would never get it
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'peer_bans'`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("peer_bans at version 2: %d, %v", n, err)
+	}
+	db.Close()
+
+	c, err := New(dir, 1<<20, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	if v, err := migrate.Version(c.GetDB(), Schema.Name); err != nil || v != Schema.Latest() {
+		t.Errorf("schema version = %d, %v; want %d", v, err, Schema.Latest())
+	}
+	if _, err := c.GetDB().Exec(`INSERT INTO peer_bans (peer_id, strikes, last_strike) VALUES ('p', 1, 1)`); err != nil {
+		t.Errorf("peer_bans not created: %v", err)
+	}
+}
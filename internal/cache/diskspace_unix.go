@@ -15,3 +15,13 @@ func (c *Cache) getDiskFreeSpace() (int64, error) {
 	// #nosec G115 -- overflow would require >9 exabytes free space, which is unrealistic
 	return int64(stat.Bavail) * int64(stat.Bsize), nil //nolint:unconvert
 }
+
+// diskCapacity returns the total size in bytes of the filesystem holding path
+func diskCapacity(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	// #nosec G115 -- overflow would require a filesystem over 9 exabytes
+	return int64(stat.Blocks) * int64(stat.Bsize), nil //nolint:unconvert
+}
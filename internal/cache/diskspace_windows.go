@@ -33,3 +33,20 @@ func (c *Cache) getDiskFreeSpace() (int64, error) {
 	}
 	return int64(freeBytesAvailable), nil
 }
+
+// diskCapacity returns the total size in bytes of the filesystem holding path
+func diskCapacity(path string) (int64, error) {
+	var freeBytesAvailable, totalBytes, totalFreeBytes uint64
+
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := windows.GetDiskFreeSpaceEx(pathPtr, &freeBytesAvailable, &totalBytes, &totalFreeBytes); err != nil {
+		return 0, err
+	}
+	if totalBytes > math.MaxInt64 {
+		return math.MaxInt64, nil
+	}
+	return int64(totalBytes), nil
+}
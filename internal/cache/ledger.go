@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ledgerRetention is how long daily per-peer transfer totals are kept.
+const ledgerRetention = 400 * 24 * time.Hour
+
+// ledgerKey identifies one peer's totals for one UTC day (YYYY-MM-DD).
+type ledgerKey struct {
+	day    string
+	peerID string
+}
+
+// ledgerRecord accumulates one peer's transfers for a day between flushes.
+type ledgerRecord struct {
+	uploaded   int64
+	downloaded int64
+}
+
+// PeerTransfer is the number of bytes exchanged with one peer over a period.
+type PeerTransfer struct {
+	PeerID     string `json:"peer_id"`
+	Uploaded   int64  `json:"uploaded"`   // bytes this node sent to the peer
+	Downloaded int64  `json:"downloaded"` // bytes this node received from the peer
+	FirstDay   string `json:"first_day"`  // first UTC day with traffic (YYYY-MM-DD)
+	LastDay    string `json:"last_day"`   // last UTC day with traffic
+	ActiveDays int    `json:"active_days"`
+}
+
+// RecordPeerTransfer adds bytes exchanged with a peer to today's ledger row.
+// Like access times, totals are batched in memory and written by the
+// background flusher, so this is cheap on the transfer path.
+func (c *Cache) RecordPeerTransfer(peerID string, uploaded, downloaded int64) {
+	if peerID == "" || (uploaded <= 0 && downloaded <= 0) {
+		return
+	}
+	key := ledgerKey{day: time.Now().UTC().Format(time.DateOnly), peerID: peerID}
+	c.pendingLedgerMu.Lock()
+	rec := c.pendingLedger[key]
+	rec.uploaded += max(uploaded, 0)
+	rec.downloaded += max(downloaded, 0)
+	c.pendingLedger[key] = rec
+	c.pendingLedgerMu.Unlock()
+}
+
+// flushLedger persists pending transfer totals in one transaction and drops
+// rows older than ledgerRetention.
+func (c *Cache) flushLedger() {
+	c.pendingLedgerMu.Lock()
+	if len(c.pendingLedger) == 0 {
+		c.pendingLedgerMu.Unlock()
+		return
+	}
+	pending := c.pendingLedger
+	c.pendingLedger = make(map[ledgerKey]ledgerRecord)
+	c.pendingLedgerMu.Unlock()
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		c.logger.Warn("Failed to begin transfer ledger flush", zap.Error(err))
+		return
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO peer_transfers (day, peer_id, uploaded, downloaded)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(day, peer_id) DO UPDATE SET
+			uploaded = uploaded + excluded.uploaded,
+			downloaded = downloaded + excluded.downloaded`)
+	if err != nil {
+		c.logger.Warn("Failed to prepare transfer ledger flush", zap.Error(err))
+		_ = tx.Rollback()
+		return
+	}
+	defer func() {
+		if closeErr := stmt.Close(); closeErr != nil {
+			c.logger.Warn("Failed to close transfer ledger statement", zap.Error(closeErr))
+		}
+	}()
+	for key, rec := range pending {
+		if _, err := stmt.Exec(key.day, key.peerID, rec.uploaded, rec.downloaded); err != nil {
+			c.logger.Warn("Failed to flush transfer ledger row", zap.Error(err))
+		}
+	}
+	cutoff := time.Now().Add(-ledgerRetention).UTC().Format(time.DateOnly)
+	if _, err := tx.Exec(`DELETE FROM peer_transfers WHERE day < ?`, cutoff); err != nil {
+		c.logger.Warn("Failed to prune transfer ledger", zap.Error(err))
+	}
+	if err := tx.Commit(); err != nil {
+		c.logger.Warn("Failed to commit transfer ledger flush", zap.Error(err))
+	}
+}
+
+// PeerAccounting returns per-peer byte totals from the UTC day containing
+// since onwards, largest uploaders first. Transfers not yet flushed are
+// included.
+func (c *Cache) PeerAccounting(since time.Time) ([]PeerTransfer, error) {
+	c.flushLedger()
+
+	rows, err := c.db.Query(`
+		SELECT peer_id, SUM(uploaded), SUM(downloaded), MIN(day), MAX(day), COUNT(*)
+		FROM peer_transfers
+		WHERE day >= ?
+		GROUP BY peer_id
+		ORDER BY SUM(uploaded) DESC, SUM(downloaded) DESC, peer_id`,
+		since.UTC().Format(time.DateOnly))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transfer ledger: %w", err)
+	}
+	defer rows.Close()
+
+	result := []PeerTransfer{}
+	for rows.Next() {
+		var t PeerTransfer
+		if err := rows.Scan(&t.PeerID, &t.Uploaded, &t.Downloaded, &t.FirstDay, &t.LastDay, &t.ActiveDays); err != nil {
+			return nil, fmt.Errorf("failed to read transfer ledger: %w", err)
+		}
+		result = append(result, t)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeerAccounting(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 1<<20, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	c.RecordPeerTransfer("peerA", 1000, 0)
+	c.RecordPeerTransfer("peerA", 500, 200)
+	c.RecordPeerTransfer("peerB", 0, 3000)
+	c.RecordPeerTransfer("peerC", 0, 0) // nothing exchanged: no row
+	c.flushLedger()
+
+	// An old day outside the query window, and one beyond retention.
+	old := time.Now().AddDate(0, 0, -60).UTC().Format(time.DateOnly)
+	expired := time.Now().Add(-ledgerRetention - 48*time.Hour).UTC().Format(time.DateOnly)
+	for _, day := range []string{old, expired} {
+		if _, err := c.db.Exec(`INSERT INTO peer_transfers (day, peer_id, uploaded, downloaded) VALUES (?, 'peerA', 7, 0)`, day); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Unflushed transfers are included.
+	c.RecordPeerTransfer("peerB", 0, 1000)
+
+	got, err := c.PeerAccounting(time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("PeerAccounting: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d peers, want 2: %+v", len(got), got)
+	}
+	if got[0].PeerID != "peerA" || got[0].Uploaded != 1500 || got[0].Downloaded != 200 || got[0].ActiveDays != 1 {
+		t.Errorf("peerA = %+v", got[0])
+	}
+	if got[1].PeerID != "peerB" || got[1].Uploaded != 0 || got[1].Downloaded != 4000 {
+		t.Errorf("peerB = %+v", got[1])
+	}
+
+	// The flush above pruned the row beyond retention; the 60-day-old one stays.
+	all, err := c.PeerAccounting(time.Time{})
+	if err != nil {
+		t.Fatalf("PeerAccounting: %v", err)
+	}
+	if all[0].Uploaded != 1507 || all[0].ActiveDays != 2 || all[0].FirstDay != old {
+		t.Errorf("all-time peerA = %+v", all[0])
+	}
+
+	// Totals survive a restart.
+	c.RecordPeerTransfer("peerB", 0, 1)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	c, err = New(dir, 1<<20, testLogger())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer c.Close()
+	got, err = c.PeerAccounting(time.Now().AddDate(0, 0, -1))
+	if err != nil || len(got) != 2 || got[1].Downloaded != 4001 {
+		t.Errorf("after reopen = %+v, %v", got, err)
+	}
+}
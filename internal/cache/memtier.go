@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"sync"
+)
+
+// Memory tier kinds, passed to the lookup observer
+const (
+	MemoryKindPackage  = "package"
+	MemoryKindMetadata = "metadata"
+)
+
+// memoryTier is an LRU of small, hot file bodies held in front of the disk
+// cache, so an InRelease or a small .deb reinstalled by every CI job is not
+// read from disk each time. Entries are keyed by package hash or by
+// "meta:"+URL and removed whenever the disk copy is deleted or replaced.
+type memoryTier struct {
+	mu        sync.Mutex
+	budget    int64 // total bytes held; 0 disables the tier
+	maxObject int64 // larger bodies are never held
+	size      int64
+	order     *list.List // front = most recently used
+	entries   map[string]*list.Element
+	evictions int64
+}
+
+type memoryEntry struct {
+	key  string
+	data []byte
+}
+
+// MemoryTierStats describes the in-memory tier
+type MemoryTierStats struct {
+	Budget    int64
+	Size      int64
+	Entries   int
+	Evictions int64
+}
+
+func newMemoryTier() *memoryTier {
+	return &memoryTier{
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func metadataMemoryKey(url string) string {
+	return "meta:" + url
+}
+
+// configure sets the budget and object size limit, dropping what no longer fits
+func (m *memoryTier) configure(budget, maxObject int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.budget = max(budget, 0)
+	m.maxObject = min(max(maxObject, 0), m.budget)
+	for e := m.order.Back(); e != nil; {
+		prev := e.Prev()
+		if ent := e.Value.(*memoryEntry); int64(len(ent.data)) > m.maxObject {
+			m.removeElement(e)
+		}
+		e = prev
+	}
+	m.evictLocked()
+}
+
+// admits reports whether a body of size bytes may be held
+func (m *memoryTier) admits(size int64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.budget > 0 && size > 0 && size <= m.maxObject
+}
+
+// get returns the body held for key, marking it most recently used. The
+// slice must not be modified.
+func (m *memoryTier) get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	m.order.MoveToFront(e)
+	return e.Value.(*memoryEntry).data, true
+}
+
+// put holds data under key, evicting least recently used entries to fit
+func (m *memoryTier) put(key string, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	size := int64(len(data))
+	if m.budget <= 0 || size == 0 || size > m.maxObject {
+		return
+	}
+	if e, ok := m.entries[key]; ok {
+		m.removeElement(e)
+	}
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, data: data})
+	m.size += size
+	m.evictLocked()
+}
+
+// remove drops key; a no-op when it is not held
+func (m *memoryTier) remove(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.entries[key]; ok {
+		m.removeElement(e)
+	}
+}
+
+func (m *memoryTier) stats() MemoryTierStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return MemoryTierStats{Budget: m.budget, Size: m.size, Entries: len(m.entries), Evictions: m.evictions}
+}
+
+func (m *memoryTier) evictLocked() {
+	for m.size > m.budget {
+		e := m.order.Back()
+		if e == nil {
+			return
+		}
+		m.removeElement(e)
+		m.evictions++
+	}
+}
+
+func (m *memoryTier) removeElement(e *list.Element) {
+	ent := e.Value.(*memoryEntry)
+	m.order.Remove(e)
+	delete(m.entries, ent.key)
+	m.size -= int64(len(ent.data))
+}
+
+// SetMemoryTier sizes the in-memory tier for hot small files: up to budget
+// bytes in total, of bodies no larger than maxObject. A budget of 0 (the
+// default) disables it.
+func (c *Cache) SetMemoryTier(budget, maxObject int64) {
+	c.memory.configure(budget, maxObject)
+}
+
+// SetOnMemoryLookup sets a function called on every read that the memory
+// tier could serve, with the kind (MemoryKindPackage or MemoryKindMetadata)
+// and whether it did, so callers can count hits and misses.
+func (c *Cache) SetOnMemoryLookup(fn func(kind string, hit bool)) {
+	c.onMemoryLookup = fn
+}
+
+// MemoryTierStats returns the in-memory tier's budget and usage
+func (c *Cache) MemoryTierStats() MemoryTierStats {
+	return c.memory.stats()
+}
+
+func (c *Cache) noteMemoryLookup(kind string, hit bool) {
+	if c.onMemoryLookup != nil {
+		c.onMemoryLookup(kind, hit)
+	}
+}
+
+// readIntoMemory reads a whole small file body, holds it under key and
+// returns a reader over it. A body whose size differs from the recorded one
+// is returned but not held.
+func (c *Cache) readIntoMemory(key string, r io.Reader, expected int64) (io.ReadCloser, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) == expected {
+		c.memory.put(key, data)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
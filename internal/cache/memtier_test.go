@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestMemoryTier_LRUEviction(t *testing.T) {
+	m := newMemoryTier()
+	m.configure(10, 4)
+
+	m.put("a", []byte("aaaa"))
+	m.put("b", []byte("bbbb"))
+	m.get("a") // a is now most recently used
+	m.put("c", []byte("cccc"))
+
+	if _, ok := m.get("b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := m.get(k); !ok {
+			t.Errorf("entry %q evicted", k)
+		}
+	}
+	m.put("big", []byte("too large"))
+	if _, ok := m.get("big"); ok {
+		t.Error("object above max size was held")
+	}
+	if st := m.stats(); st.Size != 8 || st.Entries != 2 || st.Evictions != 1 {
+		t.Errorf("stats = %+v", st)
+	}
+
+	// Shrinking the budget drops what no longer fits
+	m.configure(0, 0)
+	if st := m.stats(); st.Size != 0 || st.Entries != 0 {
+		t.Errorf("after disabling: %+v", st)
+	}
+}
+
+type lookup struct {
+	kind string
+	hit  bool
+}
+
+func memoryCache(t *testing.T) (*Cache, *[]lookup) {
+	t.Helper()
+	c, _ := testCache(t)
+	c.SetMemoryTier(1<<20, 64<<10)
+	var lookups []lookup
+	c.SetOnMemoryLookup(func(kind string, hit bool) {
+		lookups = append(lookups, lookup{kind, hit})
+	})
+	return c, &lookups
+}
+
+func readPackage(t *testing.T, c *Cache, hash string) []byte {
+	t.Helper()
+	rc, _, err := c.Get(hash)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return b
+}
+
+func TestMemoryTier_PackageHitAndDelete(t *testing.T) {
+	c, lookups := memoryCache(t)
+	content := []byte("small hot package")
+	hash := putTestContent(t, c, content, "hot.deb")
+
+	readPackage(t, c, hash) // miss, loads the tier
+	// The second read must come from memory, even with the file gone
+	if err := os.Remove(c.packagePath(hash)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readPackage(t, c, hash); !bytes.Equal(got, content) {
+		t.Errorf("memory hit = %q", got)
+	}
+	want := []lookup{{MemoryKindPackage, false}, {MemoryKindPackage, true}}
+	if len(*lookups) != 2 || (*lookups)[0] != want[0] || (*lookups)[1] != want[1] {
+		t.Errorf("lookups = %v, want %v", *lookups, want)
+	}
+
+	if err := c.Delete(hash); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if st := c.MemoryTierStats(); st.Entries != 0 {
+		t.Errorf("deleted package still held: %+v", st)
+	}
+	if _, _, err := c.Get(hash); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryTier_LargePackageBypasses(t *testing.T) {
+	c, lookups := memoryCache(t)
+	hash := putTestContent(t, c, bytes.Repeat([]byte("x"), 128<<10), "big.deb")
+
+	readPackage(t, c, hash)
+	if st := c.MemoryTierStats(); st.Entries != 0 {
+		t.Errorf("large package held: %+v", st)
+	}
+	if len(*lookups) != 0 {
+		t.Errorf("lookups = %v, want none for files the tier cannot hold", *lookups)
+	}
+}
+
+func TestMemoryTier_MetadataReplaced(t *testing.T) {
+	c, lookups := memoryCache(t)
+	c.SetMetadataMaxSize(1 << 20)
+	const url = "http://deb.debian.org/debian/dists/stable/InRelease"
+
+	putMeta(t, c, url, `"v1"`, "", "text/plain", []byte("release v1"))
+	getMetaBody(t, c, url)
+	if got, _ := getMetaBody(t, c, url); string(got) != "release v1" {
+		t.Errorf("body = %q", got)
+	}
+	if len(*lookups) != 2 || !(*lookups)[1].hit || (*lookups)[1].kind != MemoryKindMetadata {
+		t.Errorf("lookups = %v", *lookups)
+	}
+
+	// An update must never be shadowed by the old body in memory
+	putMeta(t, c, url, `"v2"`, "", "text/plain", []byte("release v2, longer"))
+	if got, entry := getMetaBody(t, c, url); string(got) != "release v2, longer" || entry.ETag != `"v2"` {
+		t.Errorf("after replace body = %q etag = %s", got, entry.ETag)
+	}
+
+	c.dropMetadataRow(url)
+	if st := c.MemoryTierStats(); st.Entries != 0 {
+		t.Errorf("deleted metadata still held: %+v", st)
+	}
+}
@@ -1,12 +1,14 @@
 package cache
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -28,6 +30,17 @@ type MetadataEntry struct {
 	ContentType   string
 	FetchedAt     time.Time
 	LastValidated time.Time
+	// FreshUntil is when the copy must next be revalidated; zero means it
+	// is revalidated on every use
+	FreshUntil time.Time
+	// SHA256 is the hash of the body, or "" for copies stored before it
+	// was recorded
+	SHA256 string
+}
+
+// IsFresh reports whether the copy may be served without revalidation
+func (e *MetadataEntry) IsFresh() bool {
+	return !e.FreshUntil.IsZero() && time.Now().Before(e.FreshUntil)
 }
 
 // SetMetadataMaxSize sets the disk budget (bytes) for the metadata cache. A
@@ -108,12 +121,26 @@ func byHashSHA256(rawURL string) (string, bool) {
 }
 
 // IsImmutableMetadataURL reports whether a metadata URL is content-addressed
-// (an APT by-hash/SHA256 URL) and therefore never needs upstream revalidation
-// once cached. Callers use this to serve directly from cache without a
-// conditional GET.
+// (an APT by-hash/SHA256 URL) or an index diff patch, and therefore never needs
+// upstream revalidation once cached. Callers use this to serve directly from
+// cache without a conditional GET.
 func IsImmutableMetadataURL(rawURL string) bool {
-	_, ok := byHashSHA256(rawURL)
-	return ok
+	if _, ok := byHashSHA256(rawURL); ok {
+		return true
+	}
+	return isPdiffPatchURL(rawURL)
+}
+
+// isPdiffPatchURL reports whether a URL is a patch under an index's .diff/
+// directory (e.g. Packages.diff/T-2026-10-01-0213.15-F-2026-09-30-2012.55.gz).
+// Patches are named by the times they span and never rewritten; APT checks
+// each against the hashes in the .diff/Index, which itself is revalidated.
+func isPdiffPatchURL(rawURL string) bool {
+	if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+		rawURL = rawURL[:i]
+	}
+	dir, name := path.Split(rawURL)
+	return strings.HasSuffix(dir, ".diff/") && name != "" && name != "Index"
 }
 
 // MetadataValidators returns the stored ETag and Last-Modified for a cached URL
@@ -182,41 +209,140 @@ func (c *Cache) GetMetadata(url string) (*MetadataEntry, io.ReadCloser, error) {
 	}
 
 	entry := &MetadataEntry{URL: url}
-	var fetchedAt, lastValidated int64
+	var fetchedAt, lastValidated, freshUntil int64
 	err := c.db.QueryRowContext(context.Background(), `
 		SELECT COALESCE(etag,''), COALESCE(last_modified,''), size,
-		       COALESCE(content_type,''), fetched_at, last_validated
+		       COALESCE(content_type,''), fetched_at, last_validated, fresh_until, sha256
 		FROM indices WHERE url = ?`, url).Scan(
 		&entry.ETag, &entry.LastModified, &entry.Size,
-		&entry.ContentType, &fetchedAt, &lastValidated)
+		&entry.ContentType, &fetchedAt, &lastValidated, &freshUntil, &entry.SHA256)
 	if err != nil {
 		c.mu.RUnlock()
 		return nil, nil, ErrNotFound
 	}
 
+	entry.FetchedAt = time.Unix(fetchedAt, 0)
+	entry.LastValidated = time.Unix(lastValidated, 0)
+	if freshUntil > 0 {
+		entry.FreshUntil = time.Unix(freshUntil, 0)
+	}
+
+	// A hot small body may be held in memory; Commit and deletion drop it
+	// under the write lock, so it always matches the row read above.
+	key := metadataMemoryKey(url)
+	if data, ok := c.memory.get(key); ok && int64(len(data)) == entry.Size {
+		c.mu.RUnlock()
+		c.noteMemoryLookup(MemoryKindMetadata, true)
+		c.touchMetadata(url)
+		return entry, io.NopCloser(bytes.NewReader(data)), nil
+	}
+
 	path := c.metadataPath(url)
 	f, openErr := os.Open(path) //nolint:gosec // path derived from sha256(url), not user input
-	c.mu.RUnlock()
-
 	if openErr != nil {
+		c.mu.RUnlock()
 		// Row without a file (interrupted write, manual deletion, or corruption
 		// recovery). Treat as a miss and drop the row so the next fetch re-stores.
 		c.dropMetadataRow(url)
 		return nil, nil, ErrNotFound
 	}
 
-	entry.FetchedAt = time.Unix(fetchedAt, 0)
-	entry.LastValidated = time.Unix(lastValidated, 0)
+	var body io.ReadCloser = f
+	if c.memory.admits(entry.Size) {
+		// Read it in under the read lock so a concurrent Commit cannot
+		// replace the file between the read and joining the tier
+		c.noteMemoryLookup(MemoryKindMetadata, false)
+		rc, err := c.readIntoMemory(key, f, entry.Size)
+		_ = f.Close()
+		if err != nil {
+			c.mu.RUnlock()
+			return nil, nil, err
+		}
+		body = rc
+	}
+	c.mu.RUnlock()
+
 	c.touchMetadata(url)
-	return entry, f, nil
+	return entry, body, nil
+}
+
+// GetMetadataByHash returns the cached metadata file whose body hashes to
+// sha256Hash, so an index can be served to peers by the hash its Release
+// lists. It returns ErrNotFound like GetMetadata.
+func (c *Cache) GetMetadataByHash(sha256Hash string) (*MetadataEntry, io.ReadCloser, error) {
+	c.mu.RLock()
+	if c.metadataMaxSize <= 0 {
+		c.mu.RUnlock()
+		return nil, nil, ErrNotFound
+	}
+	var url string
+	err := c.db.QueryRowContext(context.Background(),
+		"SELECT url FROM indices WHERE sha256 = ? LIMIT 1", strings.ToLower(sha256Hash)).Scan(&url)
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, nil, ErrNotFound
+	}
+	return c.GetMetadata(url)
 }
 
-// touchMetadata records an access for LRU ranking. Best-effort; a failed update
-// only means slightly staler eviction ordering.
+// touchMetadata records an access for LRU ranking. Like package accesses it
+// is batched in memory and persisted by the background flusher, so a burst
+// of index reads does not queue a write per hit behind the database lock.
 func (c *Cache) touchMetadata(url string) {
-	_, _ = c.db.ExecContext(context.Background(),
-		"UPDATE indices SET last_accessed = ?, access_count = access_count + 1 WHERE url = ?",
-		time.Now().Unix(), url)
+	now := time.Now().Unix()
+	c.pendingMetaMu.Lock()
+	rec := c.pendingMeta[url]
+	rec.last = now
+	rec.count++
+	c.pendingMeta[url] = rec
+	full := len(c.pendingMeta) >= maxPendingAccess
+	c.pendingMetaMu.Unlock()
+	if full {
+		c.requestFlush()
+	}
+}
+
+// flushMetadataAccess persists pending metadata access records in one
+// transaction. Best-effort; a failed update only means slightly staler
+// eviction ordering. It touches only the database, so it is safe to call
+// with c.mu held.
+func (c *Cache) flushMetadataAccess() {
+	c.pendingMetaMu.Lock()
+	if len(c.pendingMeta) == 0 {
+		c.pendingMetaMu.Unlock()
+		return
+	}
+	pending := c.pendingMeta
+	c.pendingMeta = make(map[string]accessRecord)
+	c.pendingMetaMu.Unlock()
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		c.logger.Warn("Failed to begin metadata access-time flush", zap.Error(err))
+		return
+	}
+	stmt, err := tx.Prepare(`
+		UPDATE indices
+		SET last_accessed = MAX(last_accessed, ?), access_count = access_count + ?
+		WHERE url = ?`)
+	if err != nil {
+		c.logger.Warn("Failed to prepare metadata access-time flush", zap.Error(err))
+		_ = tx.Rollback()
+		return
+	}
+	defer func() {
+		if closeErr := stmt.Close(); closeErr != nil {
+			c.logger.Warn("Failed to close metadata access-time statement", zap.Error(closeErr))
+		}
+	}()
+	for url, rec := range pending {
+		if _, err := stmt.Exec(rec.last, rec.count, url); err != nil {
+			c.logger.Warn("Failed to flush metadata access time", zap.Error(err))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.logger.Warn("Failed to commit metadata access-time flush", zap.Error(err))
+	}
 }
 
 // RevalidateMetadata refreshes the stored validators and last_validated time for
@@ -240,10 +366,43 @@ func (c *Cache) RevalidateMetadata(url, etag, lastModified string) {
 		etag, etag, lastModified, lastModified, now, now, url)
 }
 
+// SetMetadataFreshUntil sets when the cached copy of url must next be
+// revalidated; a zero time revalidates it on every use. It is a no-op if the
+// URL is not cached.
+func (c *Cache) SetMetadataFreshUntil(url string, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, _ = c.db.ExecContext(context.Background(),
+		"UPDATE indices SET fresh_until = ? WHERE url = ?", unixOrZero(until), url)
+}
+
+// ExpireMetadataUnder makes every cached copy whose URL starts with prefix
+// due for revalidation, and returns how many were fresh.
+func (c *Cache) ExpireMetadataUnder(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, err := c.db.ExecContext(context.Background(),
+		"UPDATE indices SET fresh_until = 0 WHERE fresh_until > 0 AND substr(url, 1, ?) = ?",
+		len(prefix), prefix)
+	if err != nil {
+		return 0
+	}
+	n, _ := result.RowsAffected()
+	return int(n)
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
 // dropMetadataRow removes just the DB row (used on self-healing misses).
 func (c *Cache) dropMetadataRow(url string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.memory.remove(metadataMemoryKey(url))
 	var size int64
 	if err := c.db.QueryRowContext(context.Background(), "SELECT size FROM indices WHERE url = ?", url).Scan(&size); err == nil {
 		if _, err := c.db.ExecContext(context.Background(), "DELETE FROM indices WHERE url = ?", url); err == nil {
@@ -258,13 +417,15 @@ func (c *Cache) dropMetadataRow(url string) {
 // MetadataWriter streams a metadata body to a pending file while the caller also
 // writes it to the client (via io.MultiWriter), so large Contents/Packages files
 // are never buffered in memory. Commit verifies (for by-hash URLs), evicts to
-// fit the budget, and atomically installs the entry; Abort discards it.
+// fit the budget, and atomically installs the entry with the body's SHA256;
+// Abort discards it.
 type MetadataWriter struct {
 	cache        *Cache
 	url          string
 	etag         string
 	lastModified string
 	contentType  string
+	freshUntil   time.Time
 	expectedHash string // from a by-hash URL; "" otherwise
 	tmp          *os.File
 	tmpPath      string
@@ -291,16 +452,21 @@ func (c *Cache) NewMetadataWriter(url, etag, lastModified, contentType string) (
 		contentType:  contentType,
 		tmp:          f,
 		tmpPath:      f.Name(),
-		dst:          f,
+		hw:           hashutil.NewHashingWriter(f),
 	}
+	mw.dst = mw.hw
 	if h, ok := byHashSHA256(url); ok {
 		mw.expectedHash = h
-		mw.hw = hashutil.NewHashingWriter(f)
-		mw.dst = mw.hw
 	}
 	return mw, nil
 }
 
+// SetFreshUntil sets when the stored copy must next be revalidated (see
+// MetadataEntry.FreshUntil). Without it the copy is revalidated on every use.
+func (mw *MetadataWriter) SetFreshUntil(until time.Time) {
+	mw.freshUntil = until
+}
+
 // Write appends body bytes to the pending file.
 func (mw *MetadataWriter) Write(p []byte) (int, error) {
 	n, err := mw.dst.Write(p)
@@ -335,16 +501,18 @@ func (mw *MetadataWriter) Commit() error {
 		return fmt.Errorf("failed to close metadata temp file: %w", err)
 	}
 
-	if mw.expectedHash != "" {
-		if got := mw.hw.Sum(); got != mw.expectedHash {
-			_ = os.Remove(mw.tmpPath)
-			return fmt.Errorf("%w: by-hash metadata %s != %s", ErrHashMismatch, got, mw.expectedHash)
-		}
+	sum := mw.hw.Sum()
+	if mw.expectedHash != "" && sum != mw.expectedHash {
+		_ = os.Remove(mw.tmpPath)
+		return fmt.Errorf("%w: by-hash metadata %s != %s", ErrHashMismatch, sum, mw.expectedHash)
 	}
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	// Whatever happens below, a body held in memory is no longer current
+	c.memory.remove(metadataMemoryKey(mw.url))
+
 	if c.metadataMaxSize <= 0 {
 		_ = os.Remove(mw.tmpPath)
 		return nil // disabled between construction and commit; nothing to store
@@ -385,14 +553,15 @@ func (mw *MetadataWriter) Commit() error {
 
 	now := time.Now().Unix()
 	_, err := c.db.ExecContext(context.Background(), `
-		INSERT INTO indices (url, etag, last_modified, fetched_at, path, size, content_type, last_accessed, access_count, last_validated)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1, ?)
+		INSERT INTO indices (url, etag, last_modified, fetched_at, path, size, content_type, last_accessed, access_count, last_validated, fresh_until, sha256)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?)
 		ON CONFLICT(url) DO UPDATE SET
 			etag = excluded.etag, last_modified = excluded.last_modified,
 			fetched_at = excluded.fetched_at, path = excluded.path, size = excluded.size,
 			content_type = excluded.content_type, last_accessed = excluded.last_accessed,
-			access_count = indices.access_count + 1, last_validated = excluded.last_validated`,
-		mw.url, mw.etag, mw.lastModified, now, finalPath, mw.size, mw.contentType, now, now)
+			access_count = indices.access_count + 1, last_validated = excluded.last_validated,
+			fresh_until = excluded.fresh_until, sha256 = excluded.sha256`,
+		mw.url, mw.etag, mw.lastModified, now, finalPath, mw.size, mw.contentType, now, now, unixOrZero(mw.freshUntil), sum)
 	if err != nil {
 		// The row failed but the file is installed; remove it to avoid an orphan.
 		_ = os.Remove(finalPath)
@@ -422,6 +591,8 @@ func (c *Cache) ensureMetadataSpace(needed int64) error {
 	if c.metadataSize+needed <= c.metadataMaxSize {
 		return nil
 	}
+	// Rank on current access history, not what the last flush saw
+	c.flushMetadataAccess()
 
 	type victim struct {
 		url  string
@@ -472,6 +643,7 @@ func (c *Cache) ensureMetadataSpace(needed int64) error {
 // deleteMetadataUnlocked removes a metadata file then its row (file first, so a
 // failed removal never leaves a row promising a missing file). Must hold c.mu.
 func (c *Cache) deleteMetadataUnlocked(url string, size int64) error {
+	c.memory.remove(metadataMemoryKey(url))
 	path := c.metadataPath(url)
 	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
 		return err
@@ -6,6 +6,9 @@ import (
 	"io"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/hashutil"
 )
 
 // putMeta stores a body the way the proxy does: streaming through a MultiWriter
@@ -143,6 +146,30 @@ func TestMetadata_PutGetRoundTrip(t *testing.T) {
 	}
 }
 
+func TestMetadata_GetByHash(t *testing.T) {
+	c := enabledCache(t, 10*1024*1024)
+	url := "http://deb.debian.org/debian/dists/bookworm/main/binary-amd64/Packages.xz"
+	body := bytes.Repeat([]byte("xz-data\n"), 100)
+	putMeta(t, c, url, "", "", "", body)
+
+	hash := hashutil.HashBytes(body)
+	entry, rc, err := c.GetMetadataByHash(hash)
+	if err != nil {
+		t.Fatalf("GetMetadataByHash: %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if entry.URL != url || entry.SHA256 != hash || !bytes.Equal(got, body) {
+		t.Fatalf("GetMetadataByHash = %s (%s), want %s", entry.URL, entry.SHA256, url)
+	}
+
+	// A new body replaces the hash
+	putMeta(t, c, url, "", "", "", []byte("newer"))
+	if _, _, err := c.GetMetadataByHash(hash); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("old hash still found: %v", err)
+	}
+}
+
 func TestMetadata_ReplaceUpdatesSizeAccounting(t *testing.T) {
 	c := enabledCache(t, 10*1024*1024)
 	url := "http://x/dists/stable/InRelease"
@@ -185,6 +212,59 @@ func TestMetadata_RevalidatePreservesValidators(t *testing.T) {
 	}
 }
 
+func TestMetadata_Freshness(t *testing.T) {
+	c := enabledCache(t, 1<<20)
+	const dist = "http://deb.debian.org/debian/dists/bookworm/"
+	icons := dist + "main/dep11/icons-64x64.tar.gz"
+	other := "http://deb.debian.org/debian/dists/trixie/main/dep11/icons-64x64.tar.gz"
+
+	mw, err := c.NewMetadataWriter(icons, `"v1"`, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mw.SetFreshUntil(time.Now().Add(time.Hour))
+	if _, err := mw.Write([]byte("icons")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, entry := getMetaBody(t, c, icons); !entry.IsFresh() {
+		t.Errorf("entry not fresh: %+v", entry)
+	}
+
+	// A plain store revalidates on every use
+	putMeta(t, c, other, "", "", "", []byte("icons"))
+	if _, entry := getMetaBody(t, c, other); entry.IsFresh() || !entry.FreshUntil.IsZero() {
+		t.Errorf("entry stored without a TTL is fresh: %+v", entry)
+	}
+	c.SetMetadataFreshUntil(other, time.Now().Add(time.Hour))
+
+	if n := c.ExpireMetadataUnder(dist); n != 1 {
+		t.Errorf("ExpireMetadataUnder = %d, want 1", n)
+	}
+	if _, entry := getMetaBody(t, c, icons); entry.IsFresh() {
+		t.Error("entry under the expired prefix is still fresh")
+	}
+	if _, entry := getMetaBody(t, c, other); !entry.IsFresh() {
+		t.Error("entry outside the expired prefix was expired")
+	}
+}
+
+func TestIsImmutableMetadataURL_Pdiff(t *testing.T) {
+	const dir = "http://deb.debian.org/debian/dists/trixie/main/binary-amd64/Packages.diff/"
+	for url, want := range map[string]bool{
+		dir + "T-2026-10-01-0213.15-F-2026-09-30-2012.55.gz": true,
+		dir + "Index": false,
+		"http://deb.debian.org/debian/dists/trixie/main/binary-amd64/Packages.xz": false,
+		"http://deb.debian.org/debian/pool/main/g/gcc/gcc_4.0.diff.gz":            false,
+	} {
+		if got := IsImmutableMetadataURL(url); got != want {
+			t.Errorf("IsImmutableMetadataURL(%s) = %v, want %v", url, got, want)
+		}
+	}
+}
+
 func TestMetadata_ByHashVerification(t *testing.T) {
 	c := enabledCache(t, 1024*1024)
 	body := []byte("immutable index bytes")
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// PeerBan is a peer's record of strikes for serving corrupt data and the
+// ban the last one earned
+type PeerBan struct {
+	PeerID     string    `json:"peer_id"`
+	Strikes    int       `json:"strikes"`
+	Reason     string    `json:"reason,omitempty"`
+	LastStrike time.Time `json:"last_strike"`
+	Until      time.Time `json:"until,omitzero"` // zero if permanent
+	Permanent  bool      `json:"permanent"`
+}
+
+// SetPeerBan stores a peer's ban record, replacing any previous one. A
+// record without strikes deletes it.
+func (c *Cache) SetPeerBan(b PeerBan) error {
+	if b.PeerID == "" {
+		return fmt.Errorf("peer ID is required")
+	}
+	if b.Strikes <= 0 {
+		if _, err := c.db.Exec(`DELETE FROM peer_bans WHERE peer_id = ?`, b.PeerID); err != nil {
+			return fmt.Errorf("failed to delete peer ban: %w", err)
+		}
+		return nil
+	}
+	var until int64
+	if !b.Until.IsZero() {
+		until = b.Until.Unix()
+	}
+	_, err := c.db.Exec(`
+		INSERT INTO peer_bans (peer_id, strikes, reason, last_strike, until, permanent)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(peer_id) DO UPDATE SET
+			strikes = excluded.strikes,
+			reason = excluded.reason,
+			last_strike = excluded.last_strike,
+			until = excluded.until,
+			permanent = excluded.permanent`,
+		b.PeerID, b.Strikes, b.Reason, b.LastStrike.Unix(), until, b.Permanent)
+	if err != nil {
+		return fmt.Errorf("failed to store peer ban: %w", err)
+	}
+	return nil
+}
+
+// PeerBans returns all stored ban records ordered by peer ID
+func (c *Cache) PeerBans() ([]PeerBan, error) {
+	rows, err := c.db.Query(`SELECT peer_id, strikes, reason, last_strike, until, permanent FROM peer_bans ORDER BY peer_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query peer bans: %w", err)
+	}
+	defer rows.Close()
+
+	result := []PeerBan{}
+	for rows.Next() {
+		var b PeerBan
+		var lastStrike, until int64
+		if err := rows.Scan(&b.PeerID, &b.Strikes, &b.Reason, &lastStrike, &until, &b.Permanent); err != nil {
+			return nil, fmt.Errorf("failed to read peer ban: %w", err)
+		}
+		b.LastStrike = time.Unix(lastStrike, 0)
+		if until != 0 {
+			b.Until = time.Unix(until, 0)
+		}
+		result = append(result, b)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeerBans(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 1<<20, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	struck := time.Unix(1_700_000_000, 0)
+	if err := c.SetPeerBan(PeerBan{PeerID: "peerB", Strikes: 1, Reason: "hash mismatch", LastStrike: struck, Until: struck.Add(24 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPeerBan(PeerBan{PeerID: "peerA", Strikes: 4, Reason: "hash mismatch", LastStrike: struck, Until: struck.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPeerBan(PeerBan{PeerID: "peerA", Strikes: 5, Reason: "fleet hash mismatch", LastStrike: struck, Permanent: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPeerBan(PeerBan{PeerID: "peerC", Strikes: 1, LastStrike: struck}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPeerBan(PeerBan{PeerID: "peerC"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bans survive a restart
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	c, err = New(dir, 1<<20, testLogger())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	got, err := c.PeerBans()
+	if err != nil {
+		t.Fatalf("PeerBans: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d bans, want 2: %+v", len(got), got)
+	}
+	if a := got[0]; a.PeerID != "peerA" || a.Strikes != 5 || !a.Permanent || !a.Until.IsZero() || a.Reason != "fleet hash mismatch" {
+		t.Errorf("peerA = %+v", a)
+	}
+	if b := got[1]; b.PeerID != "peerB" || b.Strikes != 1 || b.Permanent || !b.Until.Equal(struck.Add(24*time.Hour)) || !b.LastStrike.Equal(struck) {
+		t.Errorf("peerB = %+v", b)
+	}
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PeerLabel is the name and tags an operator assigned to a peer
+type PeerLabel struct {
+	PeerID string   `json:"peer_id"`
+	Name   string   `json:"name,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// SetPeerLabel stores a peer's label, replacing any previous one. A label
+// with neither name nor tags deletes it.
+func (c *Cache) SetPeerLabel(l PeerLabel) error {
+	if l.PeerID == "" {
+		return fmt.Errorf("peer ID is required")
+	}
+	if l.Name == "" && len(l.Tags) == 0 {
+		if _, err := c.db.Exec(`DELETE FROM peer_labels WHERE peer_id = ?`, l.PeerID); err != nil {
+			return fmt.Errorf("failed to delete peer label: %w", err)
+		}
+		return nil
+	}
+	_, err := c.db.Exec(`
+		INSERT INTO peer_labels (peer_id, name, tags, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(peer_id) DO UPDATE SET
+			name = excluded.name,
+			tags = excluded.tags,
+			updated_at = excluded.updated_at`,
+		l.PeerID, l.Name, strings.Join(l.Tags, ","), time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to store peer label: %w", err)
+	}
+	return nil
+}
+
+// PeerLabels returns all stored peer labels ordered by peer ID
+func (c *Cache) PeerLabels() ([]PeerLabel, error) {
+	rows, err := c.db.Query(`SELECT peer_id, name, tags FROM peer_labels ORDER BY peer_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query peer labels: %w", err)
+	}
+	defer rows.Close()
+
+	result := []PeerLabel{}
+	for rows.Next() {
+		var l PeerLabel
+		var tags string
+		if err := rows.Scan(&l.PeerID, &l.Name, &tags); err != nil {
+			return nil, fmt.Errorf("failed to read peer label: %w", err)
+		}
+		if tags != "" {
+			l.Tags = strings.Split(tags, ",")
+		}
+		result = append(result, l)
+	}
+	return result, rows.Err()
+}
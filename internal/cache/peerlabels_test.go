@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPeerLabels(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 1<<20, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := c.SetPeerLabel(PeerLabel{PeerID: "peerB", Name: "laptop-anna"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPeerLabel(PeerLabel{PeerID: "peerA", Name: "old", Tags: []string{"x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPeerLabel(PeerLabel{PeerID: "peerA", Name: "rack3-seedbox", Tags: []string{"rack3", "seedbox"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPeerLabel(PeerLabel{PeerID: "peerC", Tags: []string{"gone"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPeerLabel(PeerLabel{PeerID: "peerC"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Labels survive a restart
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	c, err = New(dir, 1<<20, testLogger())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	got, err := c.PeerLabels()
+	if err != nil {
+		t.Fatalf("PeerLabels: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d labels, want 2: %+v", len(got), got)
+	}
+	if got[0].PeerID != "peerA" || got[0].Name != "rack3-seedbox" || !slices.Equal(got[0].Tags, []string{"rack3", "seedbox"}) {
+		t.Errorf("peerA = %+v", got[0])
+	}
+	if got[1].PeerID != "peerB" || got[1].Name != "laptop-anna" || got[1].Tags != nil {
+		t.Errorf("peerB = %+v", got[1])
+	}
+}
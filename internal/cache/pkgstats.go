@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// packageStatsRetention is how long a package's serve counts are kept after
+// it was last served. The counts outlive the package's cache entry, so
+// popularity survives eviction and can inform retention and prefetch.
+const packageStatsRetention = 400 * 24 * time.Hour
+
+// packageStatRecord accumulates one package's serves between flushes.
+type packageStatRecord struct {
+	filename      string
+	downloads     int64
+	uploads       int64
+	bytesUploaded int64
+	lastServed    int64
+}
+
+// PackageStat is how often one package was served.
+type PackageStat struct {
+	SHA256        string    `json:"sha256"`
+	Filename      string    `json:"filename"`
+	Downloads     int64     `json:"downloads"`      // times served to local APT clients
+	Uploads       int64     `json:"uploads"`        // complete copies sent to peers
+	BytesUploaded int64     `json:"bytes_uploaded"` // bytes sent to peers, partial transfers included
+	LastServed    time.Time `json:"last_served"`
+}
+
+// RecordPackageDownload counts a package served to a local APT client.
+// Like the transfer ledger, counts are batched in memory and written by the
+// background flusher.
+func (c *Cache) RecordPackageDownload(sha256Hash, filename string) {
+	c.recordPackageStat(sha256Hash, func(rec *packageStatRecord) {
+		rec.downloads++
+		if filename != "" {
+			rec.filename = filename
+		}
+	})
+}
+
+// RecordPackageUpload counts bytes of a package sent to a peer. complete
+// marks the transfer that delivered the package's last byte, so a package
+// fetched in chunks from several peers counts as one upload swarm-wide.
+func (c *Cache) RecordPackageUpload(sha256Hash string, bytes int64, complete bool) {
+	c.recordPackageStat(sha256Hash, func(rec *packageStatRecord) {
+		rec.bytesUploaded += max(bytes, 0)
+		if complete {
+			rec.uploads++
+		}
+	})
+}
+
+func (c *Cache) recordPackageStat(sha256Hash string, update func(*packageStatRecord)) {
+	if sha256Hash == "" {
+		return
+	}
+	c.pendingStatsMu.Lock()
+	rec := c.pendingStats[sha256Hash]
+	update(&rec)
+	rec.lastServed = time.Now().Unix()
+	c.pendingStats[sha256Hash] = rec
+	c.pendingStatsMu.Unlock()
+}
+
+// flushPackageStats persists pending serve counts in one transaction and
+// drops packages not served within packageStatsRetention. A package recorded
+// without a name takes it from its cache entry.
+func (c *Cache) flushPackageStats() {
+	c.pendingStatsMu.Lock()
+	if len(c.pendingStats) == 0 {
+		c.pendingStatsMu.Unlock()
+		return
+	}
+	pending := c.pendingStats
+	c.pendingStats = make(map[string]packageStatRecord)
+	c.pendingStatsMu.Unlock()
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		c.logger.Warn("Failed to begin package stats flush", zap.Error(err))
+		return
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO package_stats (sha256, filename, downloads, uploads, bytes_uploaded, last_served)
+		VALUES (?, COALESCE(NULLIF(?, ''), (SELECT filename FROM packages WHERE sha256 = ?), ''), ?, ?, ?, ?)
+		ON CONFLICT(sha256) DO UPDATE SET
+			filename = CASE WHEN excluded.filename != '' THEN excluded.filename ELSE filename END,
+			downloads = downloads + excluded.downloads,
+			uploads = uploads + excluded.uploads,
+			bytes_uploaded = bytes_uploaded + excluded.bytes_uploaded,
+			last_served = MAX(last_served, excluded.last_served)`)
+	if err != nil {
+		c.logger.Warn("Failed to prepare package stats flush", zap.Error(err))
+		_ = tx.Rollback()
+		return
+	}
+	defer func() {
+		if closeErr := stmt.Close(); closeErr != nil {
+			c.logger.Warn("Failed to close package stats statement", zap.Error(closeErr))
+		}
+	}()
+	for hash, rec := range pending {
+		if _, err := stmt.Exec(hash, rec.filename, hash, rec.downloads, rec.uploads, rec.bytesUploaded, rec.lastServed); err != nil {
+			c.logger.Warn("Failed to flush package stats row", zap.Error(err))
+		}
+	}
+	cutoff := time.Now().Add(-packageStatsRetention).Unix()
+	if _, err := tx.Exec(`DELETE FROM package_stats WHERE last_served < ?`, cutoff); err != nil {
+		c.logger.Warn("Failed to prune package stats", zap.Error(err))
+	}
+	if err := tx.Commit(); err != nil {
+		c.logger.Warn("Failed to commit package stats flush", zap.Error(err))
+	}
+}
+
+// TopPackages returns the limit most served packages, by downloads plus
+// uploads. Serves not yet flushed are included.
+func (c *Cache) TopPackages(limit int) ([]PackageStat, error) {
+	c.flushPackageStats()
+
+	rows, err := c.db.Query(`
+		SELECT sha256, filename, downloads, uploads, bytes_uploaded, last_served
+		FROM package_stats
+		ORDER BY downloads + uploads DESC, last_served DESC, sha256
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query package stats: %w", err)
+	}
+	defer rows.Close()
+
+	result := []PackageStat{}
+	for rows.Next() {
+		var st PackageStat
+		var lastServed int64
+		if err := rows.Scan(&st.SHA256, &st.Filename, &st.Downloads, &st.Uploads, &st.BytesUploaded, &lastServed); err != nil {
+			return nil, fmt.Errorf("failed to read package stats: %w", err)
+		}
+		st.LastServed = time.Unix(lastServed, 0)
+		result = append(result, st)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/hashutil"
+)
+
+func TestTopPackages(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 1<<20, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	cached := []byte("cached package")
+	cachedHash := hashutil.HashBytes(cached)
+	if err := c.Put(bytes.NewReader(cached), cachedHash, "pool/main/c/cached/cached_1.0_amd64.deb"); err != nil {
+		t.Fatal(err)
+	}
+
+	c.RecordPackageDownload("aaaa", "pool/main/h/hello/hello_1.0_amd64.deb")
+	c.RecordPackageUpload("aaaa", 100, false)
+	c.RecordPackageUpload("aaaa", 50, true)
+	c.flushPackageStats()
+	c.RecordPackageDownload("aaaa", "")
+	// Only uploaded: the name comes from the cache entry
+	c.RecordPackageUpload(cachedHash, 14, true)
+
+	// A package last served beyond retention is pruned at the next flush
+	expired := time.Now().Add(-packageStatsRetention - time.Hour).Unix()
+	if _, err := c.db.Exec(`INSERT INTO package_stats (sha256, downloads, last_served) VALUES ('old', 99, ?)`, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.TopPackages(10)
+	if err != nil {
+		t.Fatalf("TopPackages: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d packages, want 2: %+v", len(got), got)
+	}
+	if got[0].SHA256 != "aaaa" || got[0].Filename != "pool/main/h/hello/hello_1.0_amd64.deb" ||
+		got[0].Downloads != 2 || got[0].Uploads != 1 || got[0].BytesUploaded != 150 {
+		t.Errorf("top package = %+v", got[0])
+	}
+	if time.Since(got[0].LastServed) > time.Minute {
+		t.Errorf("LastServed = %v", got[0].LastServed)
+	}
+	if got[1].SHA256 != cachedHash || got[1].Filename != "pool/main/c/cached/cached_1.0_amd64.deb" || got[1].Uploads != 1 {
+		t.Errorf("second package = %+v", got[1])
+	}
+
+	if top, err := c.TopPackages(1); err != nil || len(top) != 1 {
+		t.Errorf("TopPackages(1) = %d packages, %v", len(top), err)
+	}
+
+	// Counts survive a restart and the package's eviction
+	c.RecordPackageDownload(cachedHash, "")
+	if err := c.Delete(cachedHash); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	c, err = New(dir, 1<<20, testLogger())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer c.Close()
+	got, err = c.TopPackages(10)
+	if err != nil {
+		t.Fatalf("TopPackages after restart: %v", err)
+	}
+	if len(got) != 2 || got[1].SHA256 != cachedHash || got[1].Filename == "" || got[1].Downloads != 1 || got[1].Uploads != 1 {
+		t.Errorf("after restart = %+v", got)
+	}
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DiskPressureRelief describes one round of eviction forced by low free disk
+// space.
+type DiskPressureRelief struct {
+	FreeBefore int64 // free disk space when pressure was detected
+	FreeAfter  int64 // free disk space once eviction stopped
+	Target     int64 // free disk space eviction aimed for
+	Evicted    int   // packages evicted
+	Freed      int64 // bytes of packages evicted
+	SizeAfter  int64 // cache size once eviction stopped
+	MaxSize    int64 // configured cache capacity
+}
+
+// Satisfied reports whether eviction restored the target free space.
+func (r *DiskPressureRelief) Satisfied() bool {
+	return r.FreeAfter >= r.Target
+}
+
+// ShrunkBelowMax reports whether the cache had to give up capacity it was
+// configured to keep: it now holds less than max_size because other data on
+// the filesystem took the space.
+func (r *DiskPressureRelief) ShrunkBelowMax() bool {
+	return r.Evicted > 0 && r.SizeAfter < r.MaxSize
+}
+
+// RelieveDiskPressure evicts packages when free disk space has fallen below
+// the minimum free space, for instance because logs or another program filled
+// the filesystem since the last Put. Packages go in eviction-policy order
+// (least recently and frequently used first, never pinned ones), except that
+// packages accessed within the last week — which Put's eviction protects — are
+// taken last rather than not at all: keeping the system's disk usable comes
+// first. Eviction stops once free space reaches the minimum plus headroom,
+// so the watcher does not evict again at the next small write.
+//
+// It returns nil when there is no pressure (or no minimum is configured).
+func (c *Cache) RelieveDiskPressure(headroom int64) (*DiskPressureRelief, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.minFreeSpace <= 0 {
+		return nil, nil
+	}
+	free, err := c.diskFree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check disk free space: %w", err)
+	}
+	if free >= c.minFreeSpace {
+		return nil, nil
+	}
+
+	c.flushAccess()
+
+	relief := &DiskPressureRelief{
+		FreeBefore: free,
+		Target:     c.minFreeSpace + headroom,
+		MaxSize:    c.maxSize,
+	}
+	rows, err := c.db.Query(`
+		SELECT sha256, size
+		FROM packages
+		WHERE pinned = 0
+		ORDER BY (last_accessed >= ?) ASC, (last_accessed + access_count * 86400) ASC`,
+		time.Now().Add(-7*24*time.Hour).Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Deleting a file frees its size on the filesystem, so expected free
+	// space is tracked locally instead of calling statfs per package.
+	for rows.Next() && free+relief.Freed < relief.Target {
+		var hash string
+		var size int64
+		if err := rows.Scan(&hash, &size); err != nil {
+			continue
+		}
+		if err := c.deleteUnlocked(hash, size); err != nil {
+			c.logger.Warn("Failed to evict package under disk pressure", zap.Error(err))
+			continue
+		}
+		relief.Evicted++
+		relief.Freed += size
+		if c.onEvict != nil {
+			c.onEvict(EvictDiskPressure)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating eviction candidates: %w", err)
+	}
+
+	relief.FreeAfter = free + relief.Freed
+	if now, err := c.diskFree(); err == nil {
+		relief.FreeAfter = now
+	}
+	relief.SizeAfter = c.currentSize
+	return relief, nil
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// pressureCache returns a cache enforcing minFree bytes of free disk space.
+func pressureCache(t *testing.T, minFree int64) *Cache {
+	t.Helper()
+	c, err := NewWithMinFreeSpace(t.TempDir(), 1<<20, minFree, testLogger())
+	if err != nil {
+		t.Fatalf("NewWithMinFreeSpace: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+// fakeDiskFree makes the cache's filesystem report base bytes free plus
+// whatever is evicted from now on.
+func fakeDiskFree(c *Cache, base int64) {
+	start := c.currentSize
+	c.diskFree = func() (int64, error) { return base + start - c.currentSize, nil }
+}
+
+func TestRelieveDiskPressure_NoPressure(t *testing.T) {
+	c := pressureCache(t, 1000)
+	putTestContent(t, c, bytes.Repeat([]byte("a"), 100), "a.deb")
+	fakeDiskFree(c, 5000)
+
+	relief, err := c.RelieveDiskPressure(0)
+	if err != nil || relief != nil {
+		t.Fatalf("RelieveDiskPressure = %+v, %v; want nil, nil", relief, err)
+	}
+	if c.Count() != 1 {
+		t.Error("package evicted without disk pressure")
+	}
+}
+
+func TestRelieveDiskPressure_EvictsInPolicyOrder(t *testing.T) {
+	c := pressureCache(t, 1000)
+	recent := putTestContent(t, c, bytes.Repeat([]byte("r"), 100), "recent.deb")
+	old := putTestContent(t, c, bytes.Repeat([]byte("o"), 100), "old.deb")
+	pinned := putTestContent(t, c, bytes.Repeat([]byte("p"), 100), "pinned.deb")
+	if err := c.Pin(pinned); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	monthAgo := time.Now().Add(-30 * 24 * time.Hour).Unix()
+	if _, err := c.db.Exec("UPDATE packages SET last_accessed = ? WHERE sha256 = ?", monthAgo, old); err != nil {
+		t.Fatalf("age package: %v", err)
+	}
+
+	// 900 free against a 1000 minimum: one 100-byte package restores it.
+	fakeDiskFree(c, 900)
+	relief, err := c.RelieveDiskPressure(0)
+	if err != nil {
+		t.Fatalf("RelieveDiskPressure: %v", err)
+	}
+	if relief == nil || relief.Evicted != 1 || relief.Freed != 100 || !relief.Satisfied() {
+		t.Fatalf("relief = %+v, want one 100-byte eviction that satisfies the target", relief)
+	}
+	if c.Has(old) || !c.Has(recent) {
+		t.Error("expected the least recently used package to go first")
+	}
+	if !relief.ShrunkBelowMax() {
+		t.Error("ShrunkBelowMax = false after evicting from a cache below max_size")
+	}
+
+	// Headroom pushes the target past what unpinned packages can free:
+	// recently used packages go too, pinned ones never do.
+	fakeDiskFree(c, 900)
+	relief, err = c.RelieveDiskPressure(500)
+	if err != nil {
+		t.Fatalf("RelieveDiskPressure: %v", err)
+	}
+	if relief.Evicted != 1 || relief.Satisfied() {
+		t.Errorf("relief = %+v, want one eviction short of the target", relief)
+	}
+	if c.Has(recent) || !c.Has(pinned) {
+		t.Error("expected the recent package evicted and the pinned one kept")
+	}
+}
+
+func TestRelieveDiskPressure_EvictReason(t *testing.T) {
+	c := pressureCache(t, 1000)
+	var reasons []string
+	c.SetOnEvict(func(reason string) { reasons = append(reasons, reason) })
+	putTestContent(t, c, bytes.Repeat([]byte("a"), 100), "a.deb")
+
+	fakeDiskFree(c, 900)
+	if _, err := c.RelieveDiskPressure(0); err != nil {
+		t.Fatalf("RelieveDiskPressure: %v", err)
+	}
+	if len(reasons) != 1 || reasons[0] != EvictDiskPressure {
+		t.Errorf("eviction reasons = %v, want [%s]", reasons, EvictDiskPressure)
+	}
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// receiptRetention is how long receipts of transfers from peers are kept.
+const receiptRetention = 90 * 24 * time.Hour
+
+// PeerReceipt is a stored receipt: an uploader's signed statement of the
+// bytes of a package it sent this node. The cache keeps the receipt as it
+// was received; the p2p package signs and verifies it.
+type PeerReceipt struct {
+	SHA256     string          `json:"sha256"`
+	Uploader   string          `json:"uploader"`
+	Start      int64           `json:"start"`
+	End        int64           `json:"end"`
+	ReceivedAt time.Time       `json:"received_at"`
+	Receipt    json.RawMessage `json:"receipt"`
+}
+
+// ReceiptFilter selects stored receipts. Empty fields match everything.
+type ReceiptFilter struct {
+	SHA256   string
+	Uploader string
+	Limit    int // 0 = no limit
+}
+
+// StoreReceipt keeps a receipt, replacing one for the same bytes from the
+// same uploader.
+func (c *Cache) StoreReceipt(r PeerReceipt) error {
+	if r.SHA256 == "" || r.Uploader == "" || len(r.Receipt) == 0 {
+		return fmt.Errorf("receipt needs a hash, an uploader and its content")
+	}
+	_, err := c.db.Exec(`
+		INSERT INTO peer_receipts (sha256, uploader, start_offset, end_offset, received_at, receipt)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(sha256, uploader, start_offset, end_offset) DO UPDATE SET
+			received_at = excluded.received_at,
+			receipt = excluded.receipt`,
+		r.SHA256, r.Uploader, r.Start, r.End, r.ReceivedAt.Unix(), string(r.Receipt))
+	if err != nil {
+		return fmt.Errorf("failed to store receipt: %w", err)
+	}
+	return nil
+}
+
+// Receipts returns the stored receipts matching f, most recent first.
+func (c *Cache) Receipts(f ReceiptFilter) ([]PeerReceipt, error) {
+	var where []string
+	var args []any
+	if f.SHA256 != "" {
+		where = append(where, "sha256 = ?")
+		args = append(args, f.SHA256)
+	}
+	if f.Uploader != "" {
+		where = append(where, "uploader = ?")
+		args = append(args, f.Uploader)
+	}
+	query := `SELECT sha256, uploader, start_offset, end_offset, received_at, receipt FROM peer_receipts`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY received_at DESC, sha256, start_offset"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query receipts: %w", err)
+	}
+	defer rows.Close()
+
+	result := []PeerReceipt{}
+	for rows.Next() {
+		var r PeerReceipt
+		var receivedAt int64
+		var receipt string
+		if err := rows.Scan(&r.SHA256, &r.Uploader, &r.Start, &r.End, &receivedAt, &receipt); err != nil {
+			return nil, fmt.Errorf("failed to read receipt: %w", err)
+		}
+		r.ReceivedAt = time.Unix(receivedAt, 0)
+		r.Receipt = json.RawMessage(receipt)
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+// pruneReceipts drops receipts older than receiptRetention.
+func (c *Cache) pruneReceipts() {
+	cutoff := time.Now().Add(-receiptRetention).Unix()
+	if _, err := c.db.Exec(`DELETE FROM peer_receipts WHERE received_at < ?`, cutoff); err != nil {
+		c.logger.Warn("Failed to prune receipts", zap.Error(err))
+	}
+}
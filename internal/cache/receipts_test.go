@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestReceipts(t *testing.T) {
+	c, err := New(t.TempDir(), 1<<20, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	now := time.Now()
+	store := func(hash, uploader string, start int64, at time.Time, body string) {
+		t.Helper()
+		err := c.StoreReceipt(PeerReceipt{
+			SHA256: hash, Uploader: uploader, Start: start, End: start + 10,
+			ReceivedAt: at, Receipt: json.RawMessage(body),
+		})
+		if err != nil {
+			t.Fatalf("StoreReceipt: %v", err)
+		}
+	}
+	store("h1", "peerA", 0, now.Add(-time.Hour), `{"n":1}`)
+	store("h1", "peerB", 10, now, `{"n":2}`)
+	store("h2", "peerA", 0, now.Add(-time.Minute), `{"n":3}`)
+	// The same bytes again replace the first receipt
+	store("h1", "peerA", 0, now.Add(-2*time.Minute), `{"n":4}`)
+	store("h3", "peerC", 0, now.Add(-receiptRetention-time.Hour), `{"n":5}`)
+
+	if err := c.StoreReceipt(PeerReceipt{SHA256: "h1"}); err == nil {
+		t.Error("receipt without uploader or content was stored")
+	}
+
+	all, err := c.Receipts(ReceiptFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, r := range all {
+		order = append(order, string(r.Receipt))
+	}
+	if len(order) != 4 || order[0] != `{"n":2}` || order[1] != `{"n":3}` || order[2] != `{"n":4}` {
+		t.Errorf("receipts = %v, want most recent first", order)
+	}
+
+	got, err := c.Receipts(ReceiptFilter{SHA256: "h1", Uploader: "peerA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || string(got[0].Receipt) != `{"n":4}` || got[0].End != 10 {
+		t.Errorf("filtered receipts = %+v", got)
+	}
+	if got, _ := c.Receipts(ReceiptFilter{Limit: 1}); len(got) != 1 {
+		t.Errorf("limit 1 returned %d receipts", len(got))
+	}
+
+	c.pruneReceipts()
+	if got, _ := c.Receipts(ReceiptFilter{SHA256: "h3"}); len(got) != 0 {
+		t.Errorf("receipt past retention kept: %+v", got)
+	}
+}
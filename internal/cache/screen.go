@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrQuarantined is returned when the screen flagged a package: it was moved
+// to the quarantine directory instead of the cache and must not be served.
+var ErrQuarantined = errors.New("package quarantined")
+
+// Screen inspects a verified package file before it enters the cache. An
+// error wrapping ErrQuarantined quarantines the file; any other error means
+// no verdict, and the package is not cached.
+type Screen func(path, hash, filename string) error
+
+// SetScreen makes every Put and PutFile pass packages through fn after hash
+// verification. Flagged packages are moved to quarantineDir (default
+// <cache>/quarantine). Must be called before the cache is used.
+func (c *Cache) SetScreen(fn Screen, quarantineDir string) {
+	if quarantineDir == "" {
+		quarantineDir = filepath.Join(c.basePath, "quarantine")
+	}
+	c.screen = fn
+	c.quarantineDir = quarantineDir
+}
+
+// screenFile runs the screen over a file about to be committed. It returns
+// the time the package was scanned, or 0 when no screen is set. A flagged
+// file is moved to quarantine.
+func (c *Cache) screenFile(path, hash, filename string) (int64, error) {
+	if c.screen == nil {
+		return 0, nil
+	}
+	err := c.screen(path, hash, filename)
+	if err == nil {
+		return time.Now().Unix(), nil
+	}
+	if errors.Is(err, ErrQuarantined) {
+		if qerr := c.quarantine(path, hash, filename, true); qerr != nil {
+			c.logger.Warn("Failed to quarantine package, deleting it", zap.Error(qerr))
+			_ = os.Remove(path)
+		}
+	}
+	return 0, err
+}
+
+// quarantinePath is where a flagged package is kept: the hash first, so
+// Quarantined can find it, then the original name for whoever inspects it.
+func (c *Cache) quarantinePath(hash, filename string) string {
+	base := filepath.Base(filename)
+	if base == "." || base == ".." || base == string(filepath.Separator) {
+		base = "package"
+	}
+	return filepath.Join(c.quarantineDir, hash+"_"+base)
+}
+
+// quarantine moves (or, for a file still in the cache, copies) path into the
+// quarantine directory.
+func (c *Cache) quarantine(path, hash, filename string, move bool) error {
+	if err := os.MkdirAll(c.quarantineDir, 0700); err != nil {
+		return fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	dst := c.quarantinePath(hash, filename)
+	if move {
+		if err := os.Rename(path, dst); err == nil {
+			return nil
+		}
+	}
+	src, err := os.Open(path) // #nosec G304 -- path is a cache-managed file
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600) // #nosec G304 -- under quarantineDir
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if move {
+		return os.Remove(path)
+	}
+	return nil
+}
+
+// Quarantined reports whether a package with this hash is in quarantine.
+// Deleting it from the quarantine directory lets it be fetched again.
+func (c *Cache) Quarantined(hash string) bool {
+	if c.screen == nil || strings.ContainsAny(hash, `*?[\/`) {
+		return false
+	}
+	matches, _ := filepath.Glob(filepath.Join(c.quarantineDir, hash+"_*"))
+	return len(matches) > 0
+}
+
+// Scanned reports whether a cached package has passed the screen. Packages
+// cached before a screen was set have not.
+func (c *Cache) Scanned(hash string) bool {
+	var scannedAt int64
+	err := c.db.QueryRow("SELECT COALESCE(scanned_at, 0) FROM packages WHERE sha256 = ?", hash).Scan(&scannedAt)
+	return err == nil && scannedAt > 0
+}
+
+// Rescreen runs the screen over a package that is already cached, for
+// content stored before scanning was enabled. A clean package is marked
+// scanned; a flagged one is copied to quarantine and removed from the cache.
+func (c *Cache) Rescreen(hash string) error {
+	if c.screen == nil {
+		return nil
+	}
+	pkg, err := c.Info(hash)
+	if err != nil {
+		return err
+	}
+	path := c.packagePath(hash)
+	err = c.screen(path, hash, pkg.Filename)
+	if err == nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		_, err = c.db.Exec("UPDATE packages SET scanned_at = ? WHERE sha256 = ?", time.Now().Unix(), hash)
+		return err
+	}
+	if !errors.Is(err, ErrQuarantined) {
+		return err
+	}
+	if qerr := c.quarantine(path, hash, pkg.Filename, false); qerr != nil {
+		c.logger.Warn("Failed to copy package to quarantine", zap.Error(qerr))
+	}
+	if derr := c.Delete(hash); derr != nil {
+		return fmt.Errorf("%w (not yet removed from cache: %v)", err, derr)
+	}
+	return err
+}
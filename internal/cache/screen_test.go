@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func sha(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestScreen(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 1<<20, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	// Cached before scanning was enabled.
+	old := []byte("cached before scanning")
+	if err := c.Put(bytes.NewReader(old), sha(old), "pool/main/o/old/old_1.0_all.deb"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Scanned(sha(old)) {
+		t.Error("package cached without a screen reported as scanned")
+	}
+
+	errScanner := errors.New("clamd unreachable")
+	verdicts := map[string]error{}
+	c.SetScreen(func(path, hash, filename string) error {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("screen got a missing file: %v", err)
+		}
+		return verdicts[hash]
+	}, "")
+
+	clean := []byte("clean package")
+	if err := c.Put(bytes.NewReader(clean), sha(clean), "pool/main/c/clean/clean_1.0_all.deb"); err != nil {
+		t.Fatalf("Put clean: %v", err)
+	}
+	if !c.Scanned(sha(clean)) {
+		t.Error("clean package not marked scanned")
+	}
+
+	bad := []byte("infected package")
+	verdicts[sha(bad)] = fmt.Errorf("%w: Eicar-Test-Signature", ErrQuarantined)
+	if err := c.Put(bytes.NewReader(bad), sha(bad), "pool/main/b/bad/bad_1.0_all.deb"); !errors.Is(err, ErrQuarantined) {
+		t.Fatalf("Put infected = %v, want ErrQuarantined", err)
+	}
+	if c.Has(sha(bad)) {
+		t.Error("infected package was cached")
+	}
+	if !c.Quarantined(sha(bad)) {
+		t.Error("infected package not quarantined")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "quarantine", sha(bad)+"_bad_1.0_all.deb")); err != nil {
+		t.Errorf("quarantine file: %v", err)
+	}
+
+	unscanned := []byte("scanner down")
+	verdicts[sha(unscanned)] = errScanner
+	if err := c.Put(bytes.NewReader(unscanned), sha(unscanned), "u.deb"); !errors.Is(err, errScanner) {
+		t.Fatalf("Put unscanned = %v", err)
+	}
+	if c.Has(sha(unscanned)) || c.Quarantined(sha(unscanned)) {
+		t.Error("unscanned package should be neither cached nor quarantined")
+	}
+	if pending, _ := os.ReadDir(filepath.Join(dir, "packages", "pending")); len(pending) != 0 {
+		t.Errorf("%d pending files left behind", len(pending))
+	}
+
+	// PutFile leaves an unscanned file for the caller to serve.
+	src := filepath.Join(t.TempDir(), "assembled")
+	if err := os.WriteFile(src, unscanned, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutFile(src, sha(unscanned), "u.deb", int64(len(unscanned))); !errors.Is(err, errScanner) {
+		t.Fatalf("PutFile unscanned = %v", err)
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Errorf("PutFile removed the source after a scan error: %v", err)
+	}
+
+	// Rescreen catches up on content from before scanning.
+	if err := c.Rescreen(sha(old)); err != nil || !c.Scanned(sha(old)) {
+		t.Errorf("Rescreen clean: %v, scanned %v", err, c.Scanned(sha(old)))
+	}
+	verdicts[sha(clean)] = fmt.Errorf("%w: new signature", ErrQuarantined)
+	if err := c.Rescreen(sha(clean)); !errors.Is(err, ErrQuarantined) {
+		t.Fatalf("Rescreen infected = %v", err)
+	}
+	if c.Has(sha(clean)) || !c.Quarantined(sha(clean)) {
+		t.Error("rescreened infected package should move from the cache to quarantine")
+	}
+}
@@ -0,0 +1,116 @@
+// Package chaos injects controlled failures into the daemon so operators can
+// check that verification, retries and mirror fallback work in their
+// environment before trusting the swarm. It is off unless configured, and
+// every injected fault is logged.
+package chaos
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/metrics"
+)
+
+// Fault names, used as the metrics label
+const (
+	FaultDropStream = "drop_stream"
+	FaultDHTDelay   = "dht_delay"
+	FaultCorrupt    = "corrupt"
+)
+
+// Config selects the faults to inject. The zero value injects none.
+type Config struct {
+	// DropStreamPercent is the share (0-100) of peer transfer streams reset
+	// right after they are opened.
+	DropStreamPercent float64
+	// DHTDelay is added to every provider lookup.
+	DHTDelay time.Duration
+	// CorruptOneIn corrupts every Nth peer download before it is verified.
+	// 0 disables corruption.
+	CorruptOneIn int
+}
+
+// Enabled reports whether any fault is configured
+func (c Config) Enabled() bool {
+	return c.DropStreamPercent > 0 || c.DHTDelay > 0 || c.CorruptOneIn > 0
+}
+
+// Injector decides when to inject faults. A nil *Injector injects nothing,
+// so callers need no checks of their own.
+type Injector struct {
+	cfg       Config
+	logger    *zap.Logger
+	metrics   *metrics.Metrics
+	downloads atomic.Int64
+}
+
+// New returns an injector for cfg, or nil when cfg enables no fault
+func New(cfg Config, logger *zap.Logger, m *metrics.Metrics) *Injector {
+	if !cfg.Enabled() {
+		return nil
+	}
+	return &Injector{cfg: cfg, logger: logger.Named("chaos"), metrics: m}
+}
+
+// Config returns the injector's settings
+func (i *Injector) Config() Config {
+	if i == nil {
+		return Config{}
+	}
+	return i.cfg
+}
+
+// DropStream reports whether the caller should reset the peer stream it
+// just opened
+func (i *Injector) DropStream(peerID string) bool {
+	if i == nil || i.cfg.DropStreamPercent <= 0 {
+		return false
+	}
+	if rand.Float64()*100 >= i.cfg.DropStreamPercent { // #nosec G404 -- fault injection, not security
+		return false
+	}
+	i.note(FaultDropStream, zap.String("peer", peerID))
+	return true
+}
+
+// DelayDHT sleeps for the configured DHT delay, returning early with the
+// context's error if it is canceled first
+func (i *Injector) DelayDHT(ctx context.Context) error {
+	if i == nil || i.cfg.DHTDelay <= 0 {
+		return nil
+	}
+	i.note(FaultDHTDelay, zap.Duration("delay", i.cfg.DHTDelay))
+	t := time.NewTimer(i.cfg.DHTDelay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Corrupt flips a byte of data if this download is one to corrupt, and
+// reports whether it did
+func (i *Injector) Corrupt(data []byte, peerID string) bool {
+	if i == nil || i.cfg.CorruptOneIn <= 0 || len(data) == 0 {
+		return false
+	}
+	if i.downloads.Add(1)%int64(i.cfg.CorruptOneIn) != 0 {
+		return false
+	}
+	data[len(data)/2] ^= 0xff
+	i.note(FaultCorrupt, zap.String("peer", peerID), zap.Int("bytes", len(data)))
+	return true
+}
+
+func (i *Injector) note(fault string, fields ...zap.Field) {
+	i.logger.Warn("CHAOS: injected fault", append([]zap.Field{zap.String("fault", fault)}, fields...)...)
+	if i.metrics != nil {
+		i.metrics.ChaosFaults.WithLabel(fault).Inc()
+	}
+}
@@ -0,0 +1,73 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/metrics"
+)
+
+func TestNilInjector(t *testing.T) {
+	var i *Injector
+	if New(Config{}, zap.NewNop(), nil) != nil {
+		t.Error("New with no faults should return nil")
+	}
+	if i.DropStream("p") || i.Corrupt([]byte("data"), "p") {
+		t.Error("nil injector injected a fault")
+	}
+	if err := i.DelayDHT(context.Background()); err != nil {
+		t.Errorf("DelayDHT = %v", err)
+	}
+}
+
+func TestCorruptOneIn(t *testing.T) {
+	m := metrics.New()
+	i := New(Config{CorruptOneIn: 3}, zap.NewNop(), m)
+	var corrupted int
+	for n := 0; n < 9; n++ {
+		data := []byte("package bytes")
+		if i.Corrupt(data, "p") {
+			corrupted++
+			if string(data) == "package bytes" {
+				t.Error("Corrupt reported true but left the data intact")
+			}
+		}
+	}
+	if corrupted != 3 {
+		t.Errorf("corrupted %d of 9 downloads, want 3", corrupted)
+	}
+	if got := m.ChaosFaults.WithLabel(FaultCorrupt).Value(); got != 3 {
+		t.Errorf("corrupt counter = %d, want 3", got)
+	}
+}
+
+func TestDropStream(t *testing.T) {
+	always := New(Config{DropStreamPercent: 100}, zap.NewNop(), nil)
+	never := New(Config{DropStreamPercent: 0, CorruptOneIn: 1}, zap.NewNop(), nil)
+	for n := 0; n < 20; n++ {
+		if !always.DropStream("p") {
+			t.Fatal("100% drop kept a stream")
+		}
+		if never.DropStream("p") {
+			t.Fatal("0% drop dropped a stream")
+		}
+	}
+}
+
+func TestDelayDHT(t *testing.T) {
+	i := New(Config{DHTDelay: time.Hour}, zap.NewNop(), nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := i.DelayDHT(ctx); err != context.DeadlineExceeded {
+		t.Errorf("DelayDHT = %v, want the context's error", err)
+	}
+
+	short := New(Config{DHTDelay: 5 * time.Millisecond}, zap.NewNop(), nil)
+	start := time.Now()
+	if err := short.DelayDHT(context.Background()); err != nil || time.Since(start) < 5*time.Millisecond {
+		t.Errorf("DelayDHT returned %v after %v", err, time.Since(start))
+	}
+}
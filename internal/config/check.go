@@ -0,0 +1,158 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// Issue is one problem found by Check.
+type Issue struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// CheckResult lists what Check found. Errors stop the daemon from starting;
+// warnings describe settings that are accepted but probably not what was
+// meant.
+type CheckResult struct {
+	Errors   []Issue `json:"errors"`
+	Warnings []Issue `json:"warnings"`
+}
+
+// Valid reports whether the configuration has no errors.
+func (r *CheckResult) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// Check loads the configuration file at path the way the daemon does and
+// reports every problem with it: TOML syntax and type errors, validation
+// errors, keys the daemon does not know (ignored on load, usually a typo),
+// settings that conflict with each other, and unsafe file permissions. The
+// error return is for a file that cannot be read at all.
+func Check(path string) (*CheckResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CheckResult{Errors: []Issue{}, Warnings: []Issue{}}
+
+	dec := toml.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var strictErr *toml.StrictMissingError
+	var decodeErr *toml.DecodeError
+	if err := dec.Decode(DefaultConfig()); err != nil {
+		switch {
+		case errors.As(err, &strictErr):
+			for _, e := range strictErr.Errors {
+				row, _ := e.Position()
+				result.Warnings = append(result.Warnings, Issue{
+					Field:   keyString(e.Key()),
+					Message: fmt.Sprintf("unknown key on line %d is ignored", row),
+				})
+			}
+		case errors.As(err, &decodeErr):
+			row, col := decodeErr.Position()
+			result.Errors = append(result.Errors, Issue{
+				Field:   keyString(decodeErr.Key()),
+				Message: fmt.Sprintf("line %d, column %d: %s", row, col, decodeErr.Error()),
+			})
+			return result, nil
+		default:
+			result.Errors = append(result.Errors, Issue{Message: err.Error()})
+			return result, nil
+		}
+	}
+
+	cfg, warnings, err := LoadWithWarnings(path)
+	if err != nil {
+		result.Errors = append(result.Errors, Issue{Message: err.Error()})
+		return result, nil
+	}
+
+	var verrs ValidationErrors
+	if err := cfg.Validate(); errors.As(err, &verrs) {
+		for _, v := range verrs {
+			result.Errors = append(result.Errors, Issue(v))
+		}
+	} else if err != nil {
+		result.Errors = append(result.Errors, Issue{Message: err.Error()})
+	}
+
+	result.Warnings = append(result.Warnings, cfg.Conflicts()...)
+	for _, w := range warnings {
+		result.Warnings = append(result.Warnings, Issue{Field: w.File, Message: w.Message})
+	}
+	return result, nil
+}
+
+// Conflicts reports combinations of individually valid settings that work
+// against each other.
+func (c *Config) Conflicts() []Issue {
+	var issues []Issue
+
+	if len(c.Privacy.PeerAllowlist) > 0 && c.Privacy.PSK == "" && c.Privacy.PSKPath == "" {
+		allowed := make(map[string]bool, len(c.Privacy.PeerAllowlist))
+		var allowedNets []*net.IPNet
+		for _, entry := range c.Privacy.PeerAllowlist {
+			if n, ok := peerRuleNetwork(entry); ok {
+				allowedNets = append(allowedNets, n)
+			} else {
+				allowed[entry] = true
+			}
+		}
+		refused := 0
+		for _, addr := range c.Network.BootstrapAddrs() {
+			ma, err := multiaddr.NewMultiaddr(addr)
+			if err != nil {
+				continue
+			}
+			id, err := peer.IDFromP2PAddr(ma)
+			if err != nil || allowed[id.String()] {
+				continue
+			}
+			if ip, err := manet.ToIP(ma); err == nil && slices.ContainsFunc(allowedNets, func(n *net.IPNet) bool { return n.Contains(ip) }) {
+				continue
+			}
+			refused++
+		}
+		if refused > 0 {
+			issues = append(issues, Issue{
+				Field: "privacy.peer_allowlist",
+				Message: fmt.Sprintf("%d bootstrap peer(s) are not on the allowlist and will be refused, so the public DHT is unreachable; "+
+					"for a private swarm set privacy.psk_path and bootstrap from your own nodes", refused),
+			})
+		}
+	}
+
+	if c.Fleet.Enabled && !c.Privacy.EnableMDNS {
+		issues = append(issues, Issue{
+			Field:   "fleet.enabled",
+			Message: "fleet coordination finds peers through mDNS, which privacy.enable_mdns turns off",
+		})
+	}
+
+	if c.Fleet.Shared.IsEnabled() && !c.Fleet.Enabled {
+		issues = append(issues, Issue{
+			Field:   "fleet.shared",
+			Message: "shared settings have no effect while fleet.enabled is false",
+		})
+	}
+
+	return issues
+}
+
+// keyString renders a TOML key path as dotted text.
+func keyString(k toml.Key) string {
+	return strings.Join(k, ".")
+}
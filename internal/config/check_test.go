@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeCheckConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func hasIssue(issues []Issue, field string) bool {
+	for _, i := range issues {
+		if i.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheck_Valid(t *testing.T) {
+	path := writeCheckConfig(t, "[cache]\nmax_size = \"20GB\"\n")
+	result, err := Check(path)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if !result.Valid() || len(result.Warnings) != 0 {
+		t.Errorf("result = %+v, want no errors or warnings", result)
+	}
+}
+
+func TestCheck_UnknownKeysAndValidationErrors(t *testing.T) {
+	path := writeCheckConfig(t, `
+[cache]
+max_sise = "20GB"
+min_free_space = "plenty"
+`)
+	result, err := Check(path)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if result.Valid() || !hasIssue(result.Errors, "cache.min_free_space") {
+		t.Errorf("errors = %+v, want cache.min_free_space", result.Errors)
+	}
+	if !hasIssue(result.Warnings, "cache.max_sise") {
+		t.Errorf("warnings = %+v, want unknown key cache.max_sise", result.Warnings)
+	}
+}
+
+func TestCheck_TypeError(t *testing.T) {
+	path := writeCheckConfig(t, "[network]\nlisten_port = \"4001\"\n")
+	result, err := Check(path)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if result.Valid() {
+		t.Error("expected an error for a string port")
+	}
+}
+
+func TestCheck_MissingFile(t *testing.T) {
+	if _, err := Check(filepath.Join(t.TempDir(), "nope.toml")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestConflicts_AllowlistWithPublicBootstrap(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Privacy.PeerAllowlist = []string{"12D3KooWDpJ7As7BWAwRMfu1VU2WCqNjvq387JEYKDBj4kx6nXTN"}
+	if !hasIssue(cfg.Conflicts(), "privacy.peer_allowlist") {
+		t.Error("allowlist with public bootstrap peers not reported")
+	}
+
+	cfg.Privacy.PSKPath = "/etc/debswarm/swarm.key"
+	if hasIssue(cfg.Conflicts(), "privacy.peer_allowlist") {
+		t.Error("allowlist reported although a PSK makes the swarm private")
+	}
+}
+
+func TestConflicts_AllowlistNetworkCoversBootstrap(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Network.BootstrapPeers = []string{"/ip4/10.20.0.5/tcp/4001/p2p/12D3KooWDpJ7As7BWAwRMfu1VU2WCqNjvq387JEYKDBj4kx6nXTN"}
+	cfg.Privacy.PeerAllowlist = []string{"10.20.0.0/16"}
+	if hasIssue(cfg.Conflicts(), "privacy.peer_allowlist") {
+		t.Error("bootstrap peer in an allowed network reported as refused")
+	}
+
+	cfg.Privacy.PeerAllowlist = []string{"10.30.0.0/16"}
+	if !hasIssue(cfg.Conflicts(), "privacy.peer_allowlist") {
+		t.Error("bootstrap peer outside the allowed networks not reported")
+	}
+}
+
+func TestConflicts_FleetWithoutMDNS(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Privacy.EnableMDNS = false
+	if !hasIssue(cfg.Conflicts(), "fleet.enabled") {
+		t.Error("fleet without mDNS not reported")
+	}
+	if issues := DefaultConfig().Conflicts(); len(issues) != 0 {
+		t.Errorf("default config has conflicts: %+v", issues)
+	}
+}
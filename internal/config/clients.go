@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+// ClientConfig sets the rate limit and source policy of the proxy clients
+// in some networks, for a daemon serving a LAN (network.proxy_bind). A
+// client belongs to the first [[clients]] entry with a CIDR containing its
+// address; clients in none have no limit and choose their own policy.
+type ClientConfig struct {
+	Name  string   `toml:"name"`  // Identifies the clients in stats and the dashboard
+	CIDRs []string `toml:"cidrs"` // Client networks, in CIDR notation
+
+	// MaxRate limits the responses to each client in the networks (e.g.
+	// "5MB/s"). Each client has its own limit. Empty or "0" is unlimited.
+	MaxRate string `toml:"max_rate,omitempty"`
+
+	// Policy is the source policy of every request from the clients:
+	// "auto", "mirror-only", "p2p-only" or "cache-only". It overrides the
+	// policy a request asks for. Empty leaves the choice to the client.
+	Policy string `toml:"policy,omitempty"`
+}
+
+// ParsedCIDRs parses CIDRs into networks. Validate reports every malformed
+// entry; this returns an error on the first one.
+func (c *ClientConfig) ParsedCIDRs() ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(c.CIDRs))
+	for _, cidr := range c.CIDRs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets, nil
+}
+
+// MaxRateBytes returns the per-client rate limit in bytes per second, 0 for
+// unlimited.
+func (c *ClientConfig) MaxRateBytes() int64 {
+	rate, err := ParseRate(c.MaxRate)
+	if err != nil {
+		return 0
+	}
+	return rate
+}
+
+// validateClients checks the [[clients]] entries
+func (c *Config) validateClients() ValidationErrors {
+	var errs ValidationErrors
+	names := make(map[string]bool)
+
+	for i, cl := range c.Clients {
+		field := fmt.Sprintf("clients[%d]", i)
+		if !swarmNamePattern.MatchString(cl.Name) {
+			errs = append(errs, ValidationError{
+				Field:   field + ".name",
+				Message: fmt.Sprintf("invalid name %q (lowercase letters, digits, '-' and '_')", cl.Name),
+			})
+		} else if names[cl.Name] {
+			errs = append(errs, ValidationError{
+				Field:   field + ".name",
+				Message: fmt.Sprintf("duplicate client name %q", cl.Name),
+			})
+		}
+		names[cl.Name] = true
+
+		if len(cl.CIDRs) == 0 {
+			errs = append(errs, ValidationError{
+				Field:   field + ".cidrs",
+				Message: "at least one CIDR is required",
+			})
+		}
+		for j, cidr := range cl.CIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				errs = append(errs, ValidationError{
+					Field:   fmt.Sprintf("%s.cidrs[%d]", field, j),
+					Message: fmt.Sprintf("invalid CIDR %q", cidr),
+				})
+			}
+		}
+
+		if _, err := ParseRate(cl.MaxRate); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   field + ".max_rate",
+				Message: err.Error(),
+			})
+		}
+		switch cl.Policy {
+		case "", "auto", "mirror-only", "p2p-only", "cache-only":
+		default:
+			errs = append(errs, ValidationError{
+				Field:   field + ".policy",
+				Message: fmt.Sprintf("invalid policy %q; must be auto, mirror-only, p2p-only, or cache-only", cl.Policy),
+			})
+		}
+	}
+	return errs
+}
@@ -2,11 +2,17 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +20,9 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/pelletier/go-toml/v2"
+
+	"github.com/debswarm/debswarm/internal/p2p"
+	"github.com/debswarm/debswarm/internal/units"
 )
 
 // Config holds all configuration for apt-p2p
@@ -30,6 +39,53 @@ type Config struct {
 	Fleet     FleetConfig     `toml:"fleet"`
 	Index     IndexConfig     `toml:"index"`
 	Security  SecurityConfig  `toml:"security"`
+
+	Revocation RevocationConfig `toml:"revocation"`
+
+	// UpdateCheck periodically compares the running version to the latest
+	// release. Off by default.
+	UpdateCheck UpdateCheckConfig `toml:"update_check"`
+
+	// Sources are package stores served to peers in place, besides the cache.
+	Sources SourcesConfig `toml:"sources"`
+
+	// Replication fetches what partner seeds cache, keeping copies of
+	// their caches. Off by default.
+	Replication ReplicationConfig `toml:"replication"`
+
+	// Build configures the build listener used by debootstrap and
+	// mmdebstrap chroot builds.
+	Build BuildConfig `toml:"build"`
+
+	// Control serves the gRPC control API on a Unix socket. Off by default.
+	Control ControlConfig `toml:"control"`
+
+	// Generic caches and shares RPM, Arch, Nix and OCI artifacts through
+	// /generic/<adapter>/ URLs. Off by default.
+	Generic GenericConfig `toml:"generic"`
+
+	// Swarms are additional private swarms this node joins alongside the
+	// one configured by [network] and [privacy].
+	Swarms []SwarmConfig `toml:"swarms"`
+
+	// Repos configure third-party repositories, from [[repos]] and the
+	// files in repos.d beside the config file.
+	Repos []RepoConfig `toml:"repos"`
+
+	// Mirror sets the User-Agent and the per-host headers and credentials
+	// of mirror requests.
+	Mirror MirrorConfig `toml:"mirror,omitempty"`
+
+	// Prefetch keeps package groups cached for offline provisioning.
+	Prefetch PrefetchConfig `toml:"prefetch,omitempty"`
+
+	// Clients set the rate limit and source policy of proxy clients by
+	// network, from [[clients]].
+	Clients []ClientConfig `toml:"clients"`
+
+	// Chaos injects failures for resilience testing. It is undocumented in
+	// the sample config on purpose and must stay unset in production.
+	Chaos ChaosConfig `toml:"chaos,omitempty"`
 }
 
 // ProxyConfig holds proxy-related settings
@@ -39,6 +95,21 @@ type ProxyConfig struct {
 	// Built-in allowed hosts (Debian, Ubuntu, and Linux Mint domains) are always permitted.
 	AllowedHosts []string `toml:"allowed_hosts"`
 
+	// AllowedCIDRs lists upstream address ranges (CIDR notation) the proxy may
+	// fetch from even when private or loopback, e.g. an internal mirror. A URL
+	// with an IP address in one needs no allowed_hosts entry, and a hostname
+	// resolving into one passes the CONNECT DNS-rebinding check.
+	AllowedCIDRs []string `toml:"allowed_cidrs"`
+
+	// AllowedPorts lists ports HTTPS CONNECT tunnels may use besides 80 and
+	// 443. Plain HTTP mirror URLs may already use any port.
+	AllowedPorts []int `toml:"allowed_ports"`
+
+	// DenyPrivate refuses upstream private and loopback addresses outside
+	// AllowedCIDRs. Defaults to true; link-local addresses and cloud metadata
+	// hosts are refused either way.
+	DenyPrivate *bool `toml:"deny_private"`
+
 	// TrustKnownRepos controls whether the curated DefaultTrustedRepos set (common
 	// third-party repositories such as Docker, Launchpad PPAs, and PostgreSQL) is
 	// merged into the effective allowed-hosts list. Defaults to true when unset;
@@ -52,6 +123,70 @@ type ProxyConfig struct {
 	// When TrustKnownRepos is enabled, the curated DefaultHTTPSUpstreamHosts set
 	// (known HTTPS-only repos such as pkgs.k8s.io) is merged in automatically.
 	HTTPSUpstreamHosts []string `toml:"https_upstream_hosts"`
+
+	// Classes overrides how each class of repository artifact is handled,
+	// keyed by class name (see ArtifactClasses).
+	Classes map[string]ArtifactClassConfig `toml:"classes"`
+}
+
+// ArtifactClasses are the artifact class names accepted in [proxy.classes].
+// They match the proxy's classifier.
+var ArtifactClasses = []string{
+	"package", "source", "index", "release", "translation",
+	"contents", "commands", "dep11", "pdiff", "installer", "generic", "unknown",
+}
+
+// ArtifactClassConfig overrides the handling of one artifact class
+type ArtifactClassConfig struct {
+	Cache *bool  `toml:"cache"` // keep a copy (default: true)
+	Share *bool  `toml:"share"` // exchange with peers (default: true for package and source; index may opt in)
+	TTL   string `toml:"ttl"`   // serve a cached copy this long without revalidation (passthrough classes; default: cache.passthrough_ttl)
+}
+
+// TTLDuration returns the class's TTL and whether one is set
+func (a ArtifactClassConfig) TTLDuration() (time.Duration, bool) {
+	if a.TTL == "" {
+		return 0, false
+	}
+	d, err := units.ParseDuration(a.TTL)
+	if err != nil || d < 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+// PassthroughClass reports whether a class is served as a passthrough
+// object, the classes a TTL applies to. Packages are verified by hash, and
+// indexes, Release files and pdiff indexes always revalidate, since they
+// change together when the archive is updated.
+func PassthroughClass(class string) bool {
+	switch class {
+	case "package", "source", "index", "release", "pdiff":
+		return false
+	}
+	return true
+}
+
+// IsCached reports whether the class is cached. Defaults to true.
+func (a ArtifactClassConfig) IsCached() bool {
+	return a.Cache == nil || *a.Cache
+}
+
+// IsShared reports whether a class is exchanged with peers. Defaults to true
+// for packages, source artifacts and generic-mode artifacts, the classes
+// their index verifies.
+func (a ArtifactClassConfig) IsShared(class string) bool {
+	if a.Share != nil {
+		return *a.Share
+	}
+	return (class == "package" || class == "source" || class == "generic") && a.IsCached()
+}
+
+// shareableClass reports whether a class may be shared: packages, source
+// artifacts and generic-mode artifacts, which their index verifies, and
+// indexes, which the signed Release verifies
+func shareableClass(class string) bool {
+	return class == "package" || class == "source" || class == "generic" || class == "index"
 }
 
 // DefaultTrustedRepos is a curated set of well-known public APT repositories that
@@ -98,6 +233,12 @@ func (p *ProxyConfig) TrustsKnownRepos() bool {
 	return p.TrustKnownRepos == nil || *p.TrustKnownRepos
 }
 
+// DeniesPrivate reports whether upstream private addresses are refused.
+// It defaults to true when the option is unset.
+func (p *ProxyConfig) DeniesPrivate() bool {
+	return p.DenyPrivate == nil || *p.DenyPrivate
+}
+
 // EffectiveAllowedHosts returns the full set of additional hosts the proxy should
 // permit: the user-configured AllowedHosts, plus DefaultTrustedRepos when
 // TrustKnownRepos is enabled. The result is de-duplicated (case-insensitively),
@@ -160,6 +301,11 @@ type NetworkConfig struct {
 	ListenPort int `toml:"listen_port"`
 	ProxyPort  int `toml:"proxy_port"`
 
+	// Listen replaces listening with both transports on every address by
+	// the listeners given, e.g. QUIC on one interface only. A transport
+	// without a listener is not listened on.
+	Listen []ListenConfig `toml:"listen"`
+
 	// ProxyBind is the HTTP proxy listen address (default "127.0.0.1", loopback
 	// only). Setting a non-loopback address (a LAN interface IP or "0.0.0.0")
 	// enables LAN server mode and REQUIRES ProxyAllowedCIDRs (fail-closed).
@@ -167,12 +313,29 @@ type NetworkConfig struct {
 	// ProxyAllowedCIDRs lists the client networks (CIDR notation) permitted to
 	// use this cache when ProxyBind is non-loopback. Loopback is always allowed.
 	ProxyAllowedCIDRs []string `toml:"proxy_allowed_cidrs"`
-
-	MaxConnections int      `toml:"max_connections"`
+	// ProxyAdvertise announces the proxy over mDNS as an APT proxy, for
+	// "debswarm client enable" on other machines (default: in LAN server
+	// mode, unless privacy.enable_mdns is off)
+	ProxyAdvertise *bool `toml:"proxy_advertise,omitempty"`
+
+	MaxConnections int `toml:"max_connections"`
+	// BootstrapPeers are multiaddrs (including /dns, /dns4, /dns6 and
+	// /dnsaddr forms) or "host:port#peerid" shorthand; see ExpandBootstrapPeer.
 	BootstrapPeers []string `toml:"bootstrap_peers"`
+	// BootstrapResolveInterval is how often DNS bootstrap addresses are
+	// re-resolved so peers behind dynamic DNS stay reachable (default "10m",
+	// "0s" disables).
+	BootstrapResolveInterval string `toml:"bootstrap_resolve_interval"`
+	// BootstrapMinPeers is how many bootstrap peers must be connected before
+	// the node stops waiting on the rest (default 4, 0 waits for all).
+	BootstrapMinPeers *int `toml:"bootstrap_min_peers"`
+	// PersistPeers remembers the peers connected to in the data directory
+	// and bootstraps from them on the next start too, so the node comes up
+	// when the configured bootstrap peers are down (default: true).
+	PersistPeers *bool `toml:"persist_peers"`
 
 	// Connectivity detection settings
-	ConnectivityMode          string `toml:"connectivity_mode"`           // "auto", "lan_only", "online_only"
+	ConnectivityMode          string `toml:"connectivity_mode"`           // "auto", "lan_only", "online_only", "offline"
 	ConnectivityCheckInterval string `toml:"connectivity_check_interval"` // How often to check connectivity
 	ConnectivityCheckURL      string `toml:"connectivity_check_url"`      // URL to check for internet access
 
@@ -192,6 +355,10 @@ type NetworkConfig struct {
 	// reachable, "on" always runs it, "off" never does.
 	RelayService string `toml:"relay_service"`
 
+	// Role is advertised to LAN peers over mDNS: "full" (default) for an
+	// ordinary node, "seed" for a dedicated seeder that exists to serve its cache.
+	Role string `toml:"role"`
+
 	// RelayPeers are static relays to reserve on, as full multiaddrs including
 	// /p2p/<peer-id>. Required for a private (PSK) swarm, which has no public DHT
 	// to discover relays through. Merged with any relays discovered from the swarm.
@@ -223,6 +390,18 @@ type NetworkConfig struct {
 	RelayedTransferMax int64 `toml:"relayed_transfer_max_bytes"`
 }
 
+// ListenConfig is a [[network.listen]] entry: a P2P transport listening on
+// all addresses, the addresses of an interface, or one address.
+type ListenConfig struct {
+	Transport string `toml:"transport"` // "quic" or "tcp"
+	Interface string `toml:"interface"` // e.g. "enp0s31f6"; its addresses at startup
+	Address   string `toml:"address"`   // an IP address, "0.0.0.0" or "::" for all of a family
+	Port      int    `toml:"port"`      // default network.listen_port
+}
+
+// ListenTransports are the transports of [[network.listen]]
+var ListenTransports = []string{"quic", "tcp"}
+
 // Reachability override modes.
 const (
 	ReachabilityAuto    = "auto"
@@ -255,6 +434,12 @@ const (
 	RelayServiceOff  = "off"
 )
 
+// Node roles advertised over mDNS.
+const (
+	RoleFull = "full"
+	RoleSeed = "seed"
+)
+
 // Relay limit defaults. These mirror circuit-relay v2's own defaults, which are
 // sized for hole-punch coordination rather than bulk transfer.
 const (
@@ -281,6 +466,14 @@ func (c *NetworkConfig) GetRelayService() string {
 	return strings.ToLower(strings.TrimSpace(c.RelayService))
 }
 
+// GetRole returns the node role, defaulting to "full".
+func (c *NetworkConfig) GetRole() string {
+	if c.Role == "" {
+		return RoleFull
+	}
+	return strings.ToLower(strings.TrimSpace(c.Role))
+}
+
 // RelayMaxReservations returns the reservation cap, defaulting when unset.
 func (c *NetworkConfig) RelayMaxReservations() int {
 	if c.RelayLimits.MaxReservations <= 0 {
@@ -316,7 +509,7 @@ func (c *NetworkConfig) RelayDuration() time.Duration {
 	if c.RelayLimits.Duration == "" {
 		return DefaultRelayDuration
 	}
-	d, err := time.ParseDuration(c.RelayLimits.Duration)
+	d, err := units.ParseDuration(c.RelayLimits.Duration)
 	if err != nil || d <= 0 {
 		return DefaultRelayDuration
 	}
@@ -341,6 +534,104 @@ func (c *NetworkConfig) GetConnectivityMode() string {
 	return c.ConnectivityMode
 }
 
+// ExpandBootstrapPeer converts one bootstrap_peers entry into multiaddrs.
+// Multiaddrs are returned unchanged; DNS components in them are resolved when
+// connecting. The shorthand "host:port#peerid" — host being an IPv4 address,
+// a bracketed IPv6 address or a DNS name — expands to a TCP and a QUIC address.
+func ExpandBootstrapPeer(entry string) ([]string, error) {
+	if strings.HasPrefix(entry, "/") {
+		if _, err := multiaddr.NewMultiaddr(entry); err != nil {
+			return nil, err
+		}
+		return []string{entry}, nil
+	}
+
+	hostPort, id, ok := strings.Cut(entry, "#")
+	if !ok {
+		return nil, fmt.Errorf("expected a multiaddr or host:port#peerid")
+	}
+	if _, err := peer.Decode(id); err != nil {
+		return nil, fmt.Errorf("invalid peer ID %q: %w", id, err)
+	}
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return nil, fmt.Errorf("invalid port %q", port)
+	}
+
+	var prefix string
+	switch ip := net.ParseIP(host); {
+	case ip == nil:
+		if host == "" {
+			return nil, fmt.Errorf("missing host")
+		}
+		prefix = "/dns/" + host
+	case ip.To4() != nil:
+		prefix = "/ip4/" + ip.String()
+	default:
+		prefix = "/ip6/" + ip.String()
+	}
+	addrs := []string{
+		fmt.Sprintf("%s/tcp/%s/p2p/%s", prefix, port, id),
+		fmt.Sprintf("%s/udp/%s/quic-v1/p2p/%s", prefix, port, id),
+	}
+	for _, a := range addrs {
+		if _, err := multiaddr.NewMultiaddr(a); err != nil {
+			return nil, err
+		}
+	}
+	return addrs, nil
+}
+
+// BootstrapAddrs returns BootstrapPeers expanded to multiaddrs, skipping
+// entries Validate would reject.
+func (c *NetworkConfig) BootstrapAddrs() []string {
+	var out []string
+	for _, entry := range c.BootstrapPeers {
+		if entry == "" {
+			continue
+		}
+		if addrs, err := ExpandBootstrapPeer(entry); err == nil {
+			out = append(out, addrs...)
+		}
+	}
+	return out
+}
+
+// BootstrapResolveIntervalDuration returns how often bootstrap DNS names are
+// re-resolved. Returns 10 minutes default if not configured or invalid, and 0
+// (disabled) for "0s".
+func (c *NetworkConfig) BootstrapResolveIntervalDuration() time.Duration {
+	if c.BootstrapResolveInterval == "" {
+		return 10 * time.Minute
+	}
+	d, err := units.ParseDuration(c.BootstrapResolveInterval)
+	if err != nil || d < 0 {
+		return 10 * time.Minute
+	}
+	return d
+}
+
+// GetBootstrapMinPeers returns how many bootstrap peers to wait for,
+// defaulting to 4 when unset. 0 waits for all of them.
+func (c *NetworkConfig) GetBootstrapMinPeers() int {
+	if c.BootstrapMinPeers == nil || *c.BootstrapMinPeers < 0 {
+		return 4
+	}
+	return *c.BootstrapMinPeers
+}
+
+// IsPersistPeersEnabled reports whether connected peers are remembered to
+// bootstrap from. Defaults to true when unset.
+func (c *NetworkConfig) IsPersistPeersEnabled() bool {
+	if c.PersistPeers == nil {
+		return true
+	}
+	return *c.PersistPeers
+}
+
 // ParsedAllowedCIDRs parses ProxyAllowedCIDRs into *net.IPNet values, skipping
 // empty entries. Validate reports every malformed entry; this returns an error on
 // the first one for callers that parse after validation has passed. The result is
@@ -371,6 +662,18 @@ func isLoopbackBindAddr(bind string) bool {
 	return ip != nil && ip.IsLoopback()
 }
 
+// ProxyAdvertiseEnabled reports whether the proxy is announced over mDNS.
+// Only a proxy serving the LAN is.
+func (c *Config) ProxyAdvertiseEnabled() bool {
+	if isLoopbackBindAddr(c.Network.ProxyBind) {
+		return false
+	}
+	if c.Network.ProxyAdvertise != nil {
+		return *c.Network.ProxyAdvertise
+	}
+	return c.Privacy.EnableMDNS
+}
+
 // countNonEmptyStrings returns the number of non-empty entries in s.
 func countNonEmptyStrings(s []string) int {
 	n := 0
@@ -388,7 +691,7 @@ func (c *NetworkConfig) GetConnectivityCheckInterval() time.Duration {
 	if c.ConnectivityCheckInterval == "" {
 		return 30 * time.Second
 	}
-	d, err := time.ParseDuration(c.ConnectivityCheckInterval)
+	d, err := units.ParseDuration(c.ConnectivityCheckInterval)
 	if err != nil {
 		return 30 * time.Second
 	}
@@ -431,6 +734,9 @@ func (c *NetworkConfig) IsHolePunchingEnabled() bool {
 
 // CacheConfig holds cache-related settings
 type CacheConfig struct {
+	// MaxSize is the package cache capacity: a size, or a percentage of the
+	// filesystem holding Path ("20%"), recomputed as the filesystem is
+	// resized. Default: 10GiB.
 	MaxSize      string `toml:"max_size"`
 	Path         string `toml:"path"`
 	MinFreeSpace string `toml:"min_free_space"`
@@ -448,6 +754,65 @@ type CacheConfig struct {
 	// so apt-get update keeps working offline. APT still verifies the signature
 	// and Valid-Until of whatever is served. Default: true.
 	ServeStaleMetadata *bool `toml:"serve_stale_metadata"`
+	// ReconstructPdiffs rebuilds a Packages or Sources index from its pdiffs
+	// (Packages.diff/) and an older cached copy when a client updates through
+	// them, so the index debswarm verifies packages against stays current and
+	// LAN clients can fetch the rebuilt file. Default: true.
+	ReconstructPdiffs *bool `toml:"reconstruct_pdiffs"`
+	// DiskPressureInterval is how often free disk space is checked against
+	// MinFreeSpace between cache writes; when other activity has eaten into
+	// it, packages are evicted until free space is back above MinFreeSpace
+	// plus DiskPressureHeadroom. Default: 1m. "0s" disables the watcher.
+	DiskPressureInterval string `toml:"disk_pressure_interval"`
+	// DiskPressureHeadroom is the extra free space eviction restores beyond
+	// MinFreeSpace, so one eviction round is not followed by another at the
+	// next write. Default: 256MB.
+	DiskPressureHeadroom string `toml:"disk_pressure_headroom"`
+	// StrictWhenFull answers 507 Insufficient Storage for a package the cache
+	// has no room for, instead of serving it from the mirror without caching
+	// or sharing it, so operators notice a full cache. Default: false.
+	StrictWhenFull bool `toml:"strict_when_full"`
+	// MemoryTierSize is a RAM budget for keeping hot small files (InRelease,
+	// Packages diffs, small .debs) in memory in front of the disk cache, so
+	// repeated reads by many clients skip the disk. Default: 0 (disabled).
+	MemoryTierSize string `toml:"memory_tier_size"`
+	// MemoryTierMaxObject is the largest file held in the memory tier.
+	// Default: 1MB.
+	MemoryTierMaxObject string `toml:"memory_tier_max_object"`
+	// PassthroughTTL is how long cached passthrough objects (Translation,
+	// Contents, DEP-11, installer files and anything else that is not a
+	// package, index or Release file) are served without asking the mirror,
+	// when the mirror sends no Cache-Control or Expires. Classes can override
+	// it with [proxy.classes.<class>] ttl. Default: 0 (always revalidate).
+	PassthroughTTL string `toml:"passthrough_ttl"`
+	// PassthroughMaxTTL caps the lifetime the mirror's Cache-Control or
+	// Expires can give a passthrough object. "0s" ignores those headers.
+	// Default: 1h.
+	PassthroughMaxTTL string `toml:"passthrough_max_ttl"`
+	// IOThrottle caps the disk I/O of background cache work, so it cannot
+	// saturate the disk the proxy serves from.
+	IOThrottle IOThrottleConfig `toml:"io_throttle"`
+}
+
+// IOThrottleConfig caps the disk I/O of background cache operations:
+// importing APT's archives, "cache verify", "cache import" and "seed
+// import". Changes apply on reload. Both caps default to unlimited.
+type IOThrottleConfig struct {
+	MaxRate string `toml:"max_rate"` // bytes per second read or written, e.g. "20MB/s"
+	MaxIOPS int    `toml:"max_iops"` // reads and writes per second, 0 = unlimited
+}
+
+// MaxRateBytes returns the byte rate cap in bytes/sec, 0 when unlimited or
+// invalid
+func (c *IOThrottleConfig) MaxRateBytes() int64 {
+	if c.MaxRate == "" {
+		return 0
+	}
+	rate, err := ParseRate(c.MaxRate)
+	if err != nil || rate < 0 {
+		return 0
+	}
+	return rate
 }
 
 // IndexConfig holds package index settings
@@ -522,6 +887,57 @@ type SecurityConfig struct {
 	// repo whose signing key cannot be provisioned. Ignored in off/warn (which
 	// serve regardless).
 	VerifyExemptHosts []string `toml:"verify_exempt_hosts"`
+
+	// HashRequired refuses packages without a SHA256 from a loaded index
+	// instead of streaming them from the mirror unverified. It requires
+	// verify_upstream_signatures = "enforce", so every such index is signed.
+	HashRequired bool `toml:"hash_required"`
+
+	// HashRequiredExempt lists repositories still passed through without an
+	// index entry: a host ("download.example.com") or a host and path prefix
+	// ("ppa.launchpadcontent.net/deadsnakes").
+	HashRequiredExempt []string `toml:"hash_required_exempt"`
+
+	// Scan configures malware scanning of packages before they are cached.
+	Scan ScanConfig `toml:"scan"`
+}
+
+// ScanConfig configures an external scanner that checks every package after
+// hash verification and before it is cached and announced. Set Clamd or
+// Command; with neither, scanning is off.
+type ScanConfig struct {
+	// Clamd is the clamd socket: a Unix socket path (/run/clamav/clamd.ctl)
+	// or host:port for TCP.
+	Clamd string `toml:"clamd"`
+
+	// Command is a scanner program and its arguments. The package is written
+	// to its stdin; exit status 0 means clean, 1 infected (the first line of
+	// output names the signature), anything else a scan error.
+	Command []string `toml:"command"`
+
+	// Timeout bounds one scan (default 2m).
+	Timeout string `toml:"timeout"`
+
+	// QuarantineDir receives packages the scanner flags (default
+	// <cache.path>/quarantine).
+	QuarantineDir string `toml:"quarantine_dir"`
+}
+
+// Enabled reports whether a scanner is configured.
+func (c *ScanConfig) Enabled() bool {
+	return c.Clamd != "" || len(c.Command) > 0
+}
+
+// TimeoutDuration returns the per-package scan timeout (default 2m).
+func (c *ScanConfig) TimeoutDuration() time.Duration {
+	if c.Timeout == "" {
+		return 2 * time.Minute
+	}
+	d, err := units.ParseDuration(c.Timeout)
+	if err != nil || d <= 0 {
+		return 2 * time.Minute
+	}
+	return d
 }
 
 // GetVerifyMode returns the normalized verification mode, defaulting to "auto"
@@ -546,6 +962,93 @@ func (c *SecurityConfig) VerificationEnabled() bool {
 	return c.GetVerifyMode() != VerifyOff
 }
 
+// RevocationConfig holds settings for the signed revocation list of known-bad
+// content hashes. A revoked hash is purged from the cache, refused to peers,
+// and refused to APT clients. The list is only ever trusted when it verifies
+// against KeyringPath, whether it arrives from URL or from a fleet peer.
+type RevocationConfig struct {
+	// URL is where the clearsigned list is fetched from. Empty disables
+	// fetching; the node can still accept lists gossiped by fleet peers.
+	URL string `toml:"url"`
+
+	// KeyringPath is the file or directory of public keys allowed to sign the
+	// list. Required to enable revocation: APT's keyrings are deliberately not
+	// trusted here, since a repository key should not be able to purge caches.
+	KeyringPath string `toml:"keyring_path"`
+
+	// RefreshInterval is how often URL is re-fetched (default: 1h).
+	RefreshInterval string `toml:"refresh_interval"`
+
+	// Gossip pushes the list in force to fleet (mDNS) peers and accepts lists
+	// they push (default: true).
+	Gossip *bool `toml:"gossip"`
+}
+
+// Enabled reports whether revocation enforcement is configured.
+func (c *RevocationConfig) Enabled() bool {
+	return c.KeyringPath != ""
+}
+
+// RefreshIntervalDuration returns the list refresh interval.
+// Returns 1 hour default if not configured.
+func (c *RevocationConfig) RefreshIntervalDuration() time.Duration {
+	if c.RefreshInterval == "" {
+		return time.Hour
+	}
+	d, err := units.ParseDuration(c.RefreshInterval)
+	if err != nil || d <= 0 {
+		return time.Hour
+	}
+	return d
+}
+
+// IsGossipEnabled returns whether fleet gossip of the list is enabled.
+// Returns true if not explicitly set.
+func (c *RevocationConfig) IsGossipEnabled() bool {
+	if c.Gossip == nil {
+		return true
+	}
+	return *c.Gossip
+}
+
+// UpdateCheckConfig holds settings for the release update check. The check
+// only reports a newer release on the dashboard and in the log; it never
+// downloads or installs anything.
+type UpdateCheckConfig struct {
+	// Enabled turns on the periodic check (default: false).
+	Enabled bool `toml:"enabled"`
+
+	// URL returns the latest release: a GitHub release document, a JSON
+	// object with a "version" field, or the bare version as plain text
+	// (default: the debswarm GitHub releases API).
+	URL string `toml:"url"`
+
+	// Interval is how often URL is checked (default: 24h).
+	Interval string `toml:"interval"`
+}
+
+// IntervalDuration returns the update check interval.
+// Returns 24 hours default if not configured.
+func (c *UpdateCheckConfig) IntervalDuration() time.Duration {
+	if c.Interval == "" {
+		return 24 * time.Hour
+	}
+	d, err := units.ParseDuration(c.Interval)
+	if err != nil || d <= 0 {
+		return 24 * time.Hour
+	}
+	return d
+}
+
+// SourcesConfig holds package stores served to peers without importing
+// them into the cache.
+type SourcesConfig struct {
+	// LocalMirror lists the roots of existing Debian mirror trees (holding
+	// dists/ and pool/). Their packages are announced and uploaded straight
+	// from the tree, hashed by the mirror's Packages indexes.
+	LocalMirror []string `toml:"local_mirror"`
+}
+
 // TransferConfig holds transfer-related settings
 type TransferConfig struct {
 	MaxUploadRate              string `toml:"max_upload_rate"`
@@ -566,157 +1069,1215 @@ type TransferConfig struct {
 	AdaptiveRateLimiting *bool   `toml:"adaptive_rate_limiting"` // nil = auto (enabled if per-peer active)
 	AdaptiveMinRate      string  `toml:"adaptive_min_rate"`      // Minimum rate floor: "100KB/s"
 	AdaptiveMaxBoost     float64 `toml:"adaptive_max_boost"`     // Max multiplier: 1.5
+
+	// Token bucket sizes: how much moves at full speed after an idle spell
+	// before the rate applies. Empty = one second's worth of the rate,
+	// between 64KB and 4MB.
+	BurstSize        string `toml:"burst_size"`          // global limiters, e.g. "8MB"
+	PerPeerBurstSize string `toml:"per_peer_burst_size"` // per-peer limiters
+	// Transfers up to this size are never held up by a rate limiter; they
+	// still count toward the rate. Default "0" (off).
+	SmallObjectSize string `toml:"small_object_size"`
+
+	// Chunked downloads: a chunk still outstanding after this percentile
+	// (0-100) of recent chunk download times is requested from a second
+	// source as well, and the first copy wins. nil = 95, 0 = off.
+	HedgePercentile *float64 `toml:"hedge_percentile"`
+	// Extra chunk requests (retries and hedges) one download may make, as a
+	// percentage of its chunk count. Default 50.
+	RetryBudgetPercent int `toml:"retry_budget_percent"`
+	// Chunk requests outstanding to one peer across all downloads; further
+	// chunks go to the next best peer or the mirror. 0 = the per-download
+	// limit, max_concurrent_peer_downloads.
+	MaxChunksPerPeer int `toml:"max_chunks_per_peer"`
+	// Read-through: a package with an interrupted download on disk is
+	// served from the completed prefix at once while the rest is fetched
+	// from the mirror with a range request. nil = true.
+	ReadThrough *bool `toml:"read_through"`
+	// Stream a package fetched from the mirror to APT while it is hashed,
+	// withholding the last byte until it verifies. nil = true.
+	StreamMirror *bool `toml:"stream_mirror"`
+	// Ask each peer a package is downloaded from for a signed receipt of
+	// the bytes it sent, kept as evidence of who supplied a corrupt
+	// package. nil = true.
+	Receipts *bool `toml:"receipts"`
+
+	// Provider selection diversity and anti-eclipse settings
+	PeerSelection PeerSelectionConfig `toml:"peer_selection"`
+
+	// Per-peer circuit breaker for failing peers
+	CircuitBreaker CircuitBreakerConfig `toml:"circuit_breaker"`
+
+	// Peer score component weights and decay
+	Scoring ScoringConfig `toml:"scoring"`
+
+	// Upload sharing policy (reciprocity towards requesting peers)
+	Sharing SharingConfig `toml:"sharing"`
+
+	// Canary checks of peer-served packages against the mirror
+	Canary CanaryConfig `toml:"canary"`
+
+	// LAN-first downloads and WAN peer rate limits
+	SplitHorizon SplitHorizonConfig `toml:"split_horizon"`
+
+	// zstd compression of compressible transfers (indexes, not packages)
+	Compression CompressionConfig `toml:"compression"`
+
+	// Bandwidth probes of peers
+	Probe ProbeConfig `toml:"probe"`
+
+	// Fewer uploads while the host is busy with other work
+	Admission AdmissionConfig `toml:"admission"`
+
+	// Bandwidth budget shared with the other daemons on the same uplink
+	Uplink UplinkConfig `toml:"uplink"`
+
+	// Reuse of transfer streams to peers across chunks and downloads
+	StreamPool StreamPoolConfig `toml:"stream_pool"`
+
+	// Escalating bans of peers that serve corrupt data
+	Bans BanConfig `toml:"bans"`
 }
 
-// DHTConfig holds DHT-related settings
-type DHTConfig struct {
-	ProviderTTL      string `toml:"provider_ttl"`
-	AnnounceInterval string `toml:"announce_interval"`
+// BanConfig escalates the ban of a peer each time it serves data that fails
+// verification: the first strike bans it for duration, each further one
+// multiplier times longer, and the permanent_after-th for good. Strikes are
+// forgotten strike_expiry after the last one, once its ban is over.
+type BanConfig struct {
+	Duration       string  `toml:"duration"`        // ban of the first strike, default "24h"
+	Multiplier     float64 `toml:"multiplier"`      // growth per further strike, default 4
+	PermanentAfter *int    `toml:"permanent_after"` // strikes to a permanent ban, default 5, 0 = never
+	StrikeExpiry   string  `toml:"strike_expiry"`   // how long strikes are kept, default "720h"
 }
 
-// ProviderTTLDuration returns the parsed provider TTL duration.
-// Returns 24h default if parsing fails or value is empty.
-func (c *DHTConfig) ProviderTTLDuration() time.Duration {
-	if c.ProviderTTL == "" {
+// DurationValue returns the ban of a first strike.
+// Returns 24h if not configured.
+func (c *BanConfig) DurationValue() time.Duration {
+	if c.Duration == "" {
 		return 24 * time.Hour
 	}
-	d, err := time.ParseDuration(c.ProviderTTL)
-	if err != nil {
+	d, err := units.ParseDuration(c.Duration)
+	if err != nil || d <= 0 {
 		return 24 * time.Hour
 	}
 	return d
 }
 
-// AnnounceIntervalDuration returns the parsed announce interval duration.
-// Returns 12h default if parsing fails or value is empty.
-func (c *DHTConfig) AnnounceIntervalDuration() time.Duration {
-	if c.AnnounceInterval == "" {
-		return 12 * time.Hour
+// GetMultiplier returns how much longer each further strike bans a peer.
+// Returns 4 if not configured.
+func (c *BanConfig) GetMultiplier() float64 {
+	if c.Multiplier < 1 {
+		return 4
 	}
-	d, err := time.ParseDuration(c.AnnounceInterval)
-	if err != nil {
-		return 12 * time.Hour
-	}
-	return d
+	return c.Multiplier
 }
 
-// PrivacyConfig holds privacy-related settings
-type PrivacyConfig struct {
-	EnableMDNS       bool     `toml:"enable_mdns"`
-	AnnouncePackages bool     `toml:"announce_packages"`
-	PSKPath          string   `toml:"psk_path"`       // Path to PSK file for private swarm
-	PSK              string   `toml:"psk"`            // Inline PSK (hex), mutually exclusive with path
-	PeerAllowlist    []string `toml:"peer_allowlist"` // List of allowed peer IDs
-	PeerBlocklist    []string `toml:"peer_blocklist"` // List of blocked peer IDs
+// GetPermanentAfter returns the strikes that ban a peer for good, 0 for
+// never. Returns 5 if not configured.
+func (c *BanConfig) GetPermanentAfter() int {
+	if c.PermanentAfter == nil || *c.PermanentAfter < 0 {
+		return 5
+	}
+	return *c.PermanentAfter
 }
 
-// MetricsConfig holds metrics/monitoring settings
-type MetricsConfig struct {
-	Port int    `toml:"port"` // Metrics endpoint port (0 to disable)
-	Bind string `toml:"bind"` // Metrics endpoint bind address
+// StrikeExpiryDuration returns how long strikes are kept after the last one.
+// Returns 720h (30 days) if not configured.
+func (c *BanConfig) StrikeExpiryDuration() time.Duration {
+	if c.StrikeExpiry == "" {
+		return 720 * time.Hour
+	}
+	d, err := units.ParseDuration(c.StrikeExpiry)
+	if err != nil || d <= 0 {
+		return 720 * time.Hour
+	}
+	return d
 }
 
-// LoggingConfig holds logging-related settings
-type LoggingConfig struct {
-	Level string      `toml:"level"`
-	File  string      `toml:"file"`
-	Audit AuditConfig `toml:"audit"`
+// StreamPoolConfig keeps transfer streams to each peer open between
+// downloads, so successive chunks reuse a stream instead of opening one
+// each. Only peers with the revised transfer protocol keep streams open.
+type StreamPoolConfig struct {
+	Enabled   *bool  `toml:"enabled"`   // default true
+	MaxIdle   int    `toml:"max_idle"`  // idle streams kept per peer, default 4
+	KeepAlive string `toml:"keepalive"` // how long an idle stream is kept, default "30s", at most "60s"
 }
 
-// AuditConfig holds audit logging settings
-type AuditConfig struct {
-	Enabled    bool   `toml:"enabled"`     // Enable audit logging (default: false)
-	Path       string `toml:"path"`        // Path for JSON audit log file
-	MaxSizeMB  int    `toml:"max_size_mb"` // Max file size before rotation (default: 100)
-	MaxBackups int    `toml:"max_backups"` // Number of backup files to keep (default: 5)
+// IsEnabled reports whether transfer streams are reused. Enabled by default.
+func (c *StreamPoolConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
 }
 
-// GetMaxSizeMB returns the max size with a default of 100MB
-func (c *AuditConfig) GetMaxSizeMB() int {
-	if c.MaxSizeMB <= 0 {
-		return 100
+// GetMaxIdle returns the idle streams kept per peer.
+// Returns p2p.DefaultStreamMaxIdle (4) if not configured.
+func (c *StreamPoolConfig) GetMaxIdle() int {
+	if c.MaxIdle <= 0 {
+		return p2p.DefaultStreamMaxIdle
 	}
-	return c.MaxSizeMB
+	return c.MaxIdle
 }
 
-// GetMaxBackups returns the max backups with a default of 5
-func (c *AuditConfig) GetMaxBackups() int {
-	if c.MaxBackups <= 0 {
-		return 5
+// KeepAliveDuration returns how long an idle stream is kept.
+// Returns p2p.DefaultStreamKeepAlive (30s) if not configured.
+func (c *StreamPoolConfig) KeepAliveDuration() time.Duration {
+	if c.KeepAlive == "" {
+		return p2p.DefaultStreamKeepAlive
 	}
-	return c.MaxBackups
+	d, err := units.ParseDuration(c.KeepAlive)
+	if err != nil || d <= 0 {
+		return p2p.DefaultStreamKeepAlive
+	}
+	return min(d, p2p.MaxStreamKeepAlive)
 }
 
-// SchedulerConfig holds scheduled sync window settings
-type SchedulerConfig struct {
-	Enabled           bool             `toml:"enabled"`                  // Enable scheduler (default: false)
-	Windows           []ScheduleWindow `toml:"windows"`                  // List of sync windows
-	Timezone          string           `toml:"timezone"`                 // IANA timezone (e.g., "America/New_York")
-	OutsideWindowRate string           `toml:"outside_window_rate"`      // Rate limit outside windows (e.g., "100KB/s")
-	InsideWindowRate  string           `toml:"inside_window_rate"`       // Rate limit inside windows (e.g., "unlimited")
-	UrgentFullSpeed   *bool            `toml:"urgent_always_full_speed"` // Security updates always get full speed
+// UplinkConfig caps the traffic of several daemons sharing one uplink, such
+// as VMs on a host, together. Each daemon reports its throughput in dir, a
+// directory they all mount (e.g. over virtiofs or NFS), and limits itself to
+// its fair share of the budget: daemons that are busy split what the idle
+// ones leave. These caps apply on top of max_upload_rate and
+// max_download_rate, to mirror and peer traffic alike.
+type UplinkConfig struct {
+	Dir          string `toml:"dir"`           // shared directory; default "" (off)
+	UploadRate   string `toml:"upload_rate"`   // all daemons' uploads together, e.g. "20MB/s"
+	DownloadRate string `toml:"download_rate"` // all daemons' downloads together, e.g. "50MB/s"
+	Interval     string `toml:"interval"`      // how often shares are recomputed, default "1s"
 }
 
-// ScheduleWindow represents a time window for sync operations
-type ScheduleWindow struct {
-	Days      []string `toml:"days"`       // "monday", "tuesday", etc. or "weekday", "weekend"
-	StartTime string   `toml:"start_time"` // "09:00" (24h format)
-	EndTime   string   `toml:"end_time"`   // "17:00"
+// IsEnabled reports whether the daemon shares a budget with others.
+func (c *UplinkConfig) IsEnabled() bool {
+	return c.Dir != "" && (c.UploadRateBytes() > 0 || c.DownloadRateBytes() > 0)
 }
 
-// OutsideWindowRateBytes returns the rate limit in bytes/sec for outside windows.
-// Returns 100KB/s default if not configured.
-func (c *SchedulerConfig) OutsideWindowRateBytes() int64 {
-	if c.OutsideWindowRate == "" {
-		return 100 * 1024 // 100KB/s default
+// UploadRateBytes returns the shared upload budget in bytes/sec (0 = none).
+func (c *UplinkConfig) UploadRateBytes() int64 {
+	if c.UploadRate == "" {
+		return 0
 	}
-	rate, err := ParseRate(c.OutsideWindowRate)
+	rate, err := ParseRate(c.UploadRate)
 	if err != nil {
-		return 100 * 1024
+		return 0
 	}
 	return rate
 }
 
-// InsideWindowRateBytes returns the rate limit in bytes/sec for inside windows.
-// Returns 0 (unlimited) if not configured or set to "unlimited".
-func (c *SchedulerConfig) InsideWindowRateBytes() int64 {
-	if c.InsideWindowRate == "" || c.InsideWindowRate == "unlimited" {
-		return 0 // unlimited
+// DownloadRateBytes returns the shared download budget in bytes/sec
+// (0 = none).
+func (c *UplinkConfig) DownloadRateBytes() int64 {
+	if c.DownloadRate == "" {
+		return 0
 	}
-	rate, err := ParseRate(c.InsideWindowRate)
+	rate, err := ParseRate(c.DownloadRate)
 	if err != nil {
 		return 0
 	}
 	return rate
 }
 
-// IsUrgentFullSpeed returns whether security updates should always get full speed.
-// Returns true by default.
-func (c *SchedulerConfig) IsUrgentFullSpeed() bool {
-	if c.UrgentFullSpeed == nil {
-		return true // default
+// IntervalDuration returns how often shares are recomputed.
+// Returns 1 second default if not configured.
+func (c *UplinkConfig) IntervalDuration() time.Duration {
+	if c.Interval == "" {
+		return time.Second
 	}
-	return *c.UrgentFullSpeed
+	d, err := units.ParseDuration(c.Interval)
+	if err != nil || d <= 0 {
+		return time.Second
+	}
+	return d
 }
 
-// FleetConfig holds fleet coordination settings
-type FleetConfig struct {
-	Enabled         bool   `toml:"enabled"`          // Enable fleet coordination (default: false)
-	ClaimTimeout    string `toml:"claim_timeout"`    // How long to wait for peer to claim WAN download
-	MaxWaitTime     string `toml:"max_wait_time"`    // Max wait for peer to finish WAN download
-	AllowConcurrent int    `toml:"allow_concurrent"` // Number of concurrent WAN fetchers allowed
-	RefreshInterval string `toml:"refresh_interval"` // Progress broadcast interval
+// SplitHorizonConfig separates LAN peers (mDNS-discovered, or on a private
+// address) from WAN peers. When enabled, LAN providers of a package get the
+// first attempt on their own; WAN peers and the mirror are only used when
+// the LAN cannot deliver it within deadline, extended for large packages to
+// the time they take at min_lan_rate. The WAN rates cap transfers with WAN
+// peers on top of max_upload_rate and max_download_rate, whether or not
+// LAN-first downloads are enabled.
+type SplitHorizonConfig struct {
+	Enabled         bool   `toml:"enabled"`           // LAN-first downloads, default false
+	Deadline        string `toml:"deadline"`          // default "10s"
+	MinLANRate      string `toml:"min_lan_rate"`      // default "0" (deadline only)
+	WANUploadRate   string `toml:"wan_upload_rate"`   // default "0" (no extra cap)
+	WANDownloadRate string `toml:"wan_download_rate"` // default "0" (no extra cap)
 }
 
-// ClaimTimeoutDuration returns the claim timeout duration.
-// Returns 5 seconds default if not configured.
-func (c *FleetConfig) ClaimTimeoutDuration() time.Duration {
-	if c.ClaimTimeout == "" {
-		return 5 * time.Second
+// DeadlineDuration returns how long LAN peers get on their own.
+// Returns 10 seconds default if not configured.
+func (c *SplitHorizonConfig) DeadlineDuration() time.Duration {
+	if c.Deadline == "" {
+		return 10 * time.Second
 	}
-	d, err := time.ParseDuration(c.ClaimTimeout)
-	if err != nil {
-		return 5 * time.Second
+	d, err := units.ParseDuration(c.Deadline)
+	if err != nil || d <= 0 {
+		return 10 * time.Second
+	}
+	return d
+}
+
+// MinLANRateBytes returns the rate, in bytes/sec, large packages are given
+// LAN time for (0 = deadline only).
+func (c *SplitHorizonConfig) MinLANRateBytes() int64 {
+	if c.MinLANRate == "" {
+		return 0
+	}
+	rate, err := ParseRate(c.MinLANRate)
+	if err != nil {
+		return 0
+	}
+	return rate
+}
+
+// WANUploadRateBytes returns the cap on uploads to WAN peers in bytes/sec
+// (0 = none).
+func (c *SplitHorizonConfig) WANUploadRateBytes() int64 {
+	if c.WANUploadRate == "" {
+		return 0
+	}
+	rate, err := ParseRate(c.WANUploadRate)
+	if err != nil {
+		return 0
+	}
+	return rate
+}
+
+// WANDownloadRateBytes returns the cap on downloads from WAN peers in
+// bytes/sec (0 = none).
+func (c *SplitHorizonConfig) WANDownloadRateBytes() int64 {
+	if c.WANDownloadRate == "" {
+		return 0
+	}
+	rate, err := ParseRate(c.WANDownloadRate)
+	if err != nil {
+		return 0
+	}
+	return rate
+}
+
+// CompressionConfig compresses transfers with peers that support it. Only
+// content that is not compressed already is compressed, which in practice
+// means index files and other metadata rather than .deb packages. Level and
+// min_size trade CPU for bandwidth; LAN transfers, where bandwidth is rarely
+// scarce, are only compressed with lan = true.
+type CompressionConfig struct {
+	Enabled bool   `toml:"enabled"`  // default false
+	Level   string `toml:"level"`    // "fastest", "default", "better" or "best"; default "fastest"
+	MinSize string `toml:"min_size"` // smallest transfer compressed, default "4KB"
+	LAN     bool   `toml:"lan"`      // also compress with LAN peers, default false
+}
+
+// compressionLevels are the accepted transfer compression levels.
+var compressionLevels = []string{"fastest", "default", "better", "best"}
+
+// GetLevel returns the compression level.
+// Returns "fastest" if not configured.
+func (c *CompressionConfig) GetLevel() string {
+	if c.Level == "" {
+		return "fastest"
+	}
+	return c.Level
+}
+
+// MinSizeBytes returns the smallest transfer that is compressed.
+// Returns 4KB default if not configured.
+func (c *CompressionConfig) MinSizeBytes() int64 {
+	if c.MinSize == "" {
+		return 4 * 1024
+	}
+	size, err := ParseSize(c.MinSize)
+	if err != nil {
+		return 4 * 1024
+	}
+	return size
+}
+
+// ProbeConfig controls bandwidth probes: short timed transfers that give the
+// peer scorer a measurement of a peer before it has served any packages.
+// Probes can always be run with 'debswarm peers probe'.
+type ProbeConfig struct {
+	OnConnect bool   `toml:"on_connect"` // probe each peer dialed that has no measurements, default false
+	Size      string `toml:"size"`       // bytes requested per probe, default "256KB", at most "1MB"
+}
+
+// maxProbeSize mirrors the most a peer sends in one probe
+const maxProbeSize = 1024 * 1024
+
+// SizeBytes returns the probe transfer size.
+// Returns 256KB default if not configured.
+func (c *ProbeConfig) SizeBytes() int64 {
+	if c.Size == "" {
+		return 256 * 1024
+	}
+	size, err := ParseSize(c.Size)
+	if err != nil || size <= 0 {
+		return 256 * 1024
+	}
+	return size
+}
+
+// AdmissionConfig lowers the number of concurrent uploads accepted while the
+// host is busy: its load average, I/O wait or network traffic is above a
+// threshold. Meant for a workstation that seeds on the side, where uploads
+// should give way to whatever its user is doing. Security update uploads
+// keep their reserved slots. Linux only; elsewhere it has no effect.
+type AdmissionConfig struct {
+	Enabled          bool     `toml:"enabled"`            // default false
+	MaxLoad          *float64 `toml:"max_load"`           // 1-minute load average per CPU, nil = 1.0, 0 = not checked
+	MaxIOWaitPercent *float64 `toml:"max_iowait_percent"` // share of CPU time waiting on I/O, nil = 20, 0 = not checked
+	MaxNetworkRate   string   `toml:"max_network_rate"`   // traffic besides our uploads, e.g. "50MB/s"; default "" (not checked)
+	MinUploads       int      `toml:"min_uploads"`        // uploads accepted however busy, default 1
+	Interval         string   `toml:"interval"`           // how often the host is sampled, default "10s"
+}
+
+// GetMaxLoad returns the load average per CPU above which the host is busy
+// (0 = not checked). Returns 1.0 default if not configured.
+func (c *AdmissionConfig) GetMaxLoad() float64 {
+	if c.MaxLoad == nil {
+		return 1.0
+	}
+	return *c.MaxLoad
+}
+
+// GetMaxIOWaitPercent returns the I/O wait percentage above which the host
+// is busy (0 = not checked). Returns 20 default if not configured.
+func (c *AdmissionConfig) GetMaxIOWaitPercent() float64 {
+	if c.MaxIOWaitPercent == nil {
+		return 20
+	}
+	return *c.MaxIOWaitPercent
+}
+
+// MaxNetworkRateBytes returns the network traffic in bytes/sec above which
+// the host is busy (0 = not checked).
+func (c *AdmissionConfig) MaxNetworkRateBytes() int64 {
+	if c.MaxNetworkRate == "" {
+		return 0
+	}
+	rate, err := ParseRate(c.MaxNetworkRate)
+	if err != nil {
+		return 0
+	}
+	return rate
+}
+
+// GetMinUploads returns the uploads accepted however busy the host is.
+// Returns 1 default if not configured.
+func (c *AdmissionConfig) GetMinUploads() int {
+	if c.MinUploads <= 0 {
+		return 1
+	}
+	return c.MinUploads
+}
+
+// IntervalDuration returns how often the host is sampled.
+// Returns 10 seconds default if not configured or invalid.
+func (c *AdmissionConfig) IntervalDuration() time.Duration {
+	if c.Interval == "" {
+		return 10 * time.Second
+	}
+	d, err := units.ParseDuration(c.Interval)
+	if err != nil || d <= 0 {
+		return 10 * time.Second
+	}
+	return d
+}
+
+// CanaryConfig makes the daemon fetch a sample of the packages peers served
+// from the mirror as well, in the background, and compare the two: a
+// mismatch means the index or the verification path is wrong, and the
+// timings show whether P2P is actually faster than the mirror. Intended for
+// validating a rollout; it costs mirror bandwidth.
+type CanaryConfig struct {
+	SamplePercent float64 `toml:"sample_percent"` // share (0-100) of peer-served packages checked, default 0 (off)
+	MaxConcurrent int     `toml:"max_concurrent"` // default 1
+	MaxSize       string  `toml:"max_size"`       // larger packages are not checked, default "100MB", "0" = no limit
+}
+
+// Enabled reports whether any packages are checked.
+func (c *CanaryConfig) Enabled() bool {
+	return c.SamplePercent > 0
+}
+
+// GetMaxConcurrent returns how many canary fetches may run at once.
+// Returns 1 default if not configured.
+func (c *CanaryConfig) GetMaxConcurrent() int {
+	if c.MaxConcurrent <= 0 {
+		return 1
+	}
+	return c.MaxConcurrent
+}
+
+// MaxSizeBytes returns the size above which packages are not checked
+// (0 = no limit). Returns 100MB default if not configured or invalid.
+func (c *CanaryConfig) MaxSizeBytes() int64 {
+	if c.MaxSize == "" {
+		return 100 * 1024 * 1024
+	}
+	size, err := ParseSize(c.MaxSize)
+	if err != nil {
+		return 100 * 1024 * 1024
+	}
+	return size
+}
+
+// Sharing policies.
+const (
+	SharingAltruistic = "altruistic" // serve every peer alike (default)
+	SharingReciprocal = "reciprocal" // throttle peers that take but do not give back
+)
+
+// SharingConfig controls how uploads treat peers by reciprocity. Under the
+// reciprocal policy a peer that has taken more than grace from this node and
+// served back less than min_ratio of it is a leecher: it gets at most
+// leecher_max_uploads concurrent uploads at leecher_upload_rate. LAN (mDNS)
+// peers are never throttled.
+type SharingConfig struct {
+	Policy            string   `toml:"policy"`              // "altruistic" (default) or "reciprocal"
+	Grace             string   `toml:"grace"`               // default "1GiB"
+	MinRatio          *float64 `toml:"min_ratio"`           // default 0.1
+	LeecherUploadRate string   `toml:"leecher_upload_rate"` // default "256KB/s", "0" = no cap
+	LeecherMaxUploads *int     `toml:"leecher_max_uploads"` // default 1, 0 = refuse uploads
+}
+
+// IsReciprocal reports whether uploads depend on the peer's reciprocity.
+func (c *SharingConfig) IsReciprocal() bool {
+	return c.Policy == SharingReciprocal
+}
+
+// GraceBytes returns how much a peer may take before reciprocity counts.
+// Returns 1GB default if not configured or invalid.
+func (c *SharingConfig) GraceBytes() int64 {
+	if c.Grace == "" {
+		return 1 << 30
+	}
+	size, err := ParseSize(c.Grace)
+	if err != nil {
+		return 1 << 30
+	}
+	return size
+}
+
+// GetMinRatio returns the received/sent ratio below which a peer is a leecher.
+// Returns 0.1 default if not configured.
+func (c *SharingConfig) GetMinRatio() float64 {
+	if c.MinRatio == nil {
+		return 0.1
+	}
+	return *c.MinRatio
+}
+
+// LeecherUploadRateBytes returns the per-peer upload rate for leechers in
+// bytes/sec (0 = no cap). Returns 256KB/s default if not configured or invalid.
+func (c *SharingConfig) LeecherUploadRateBytes() int64 {
+	if c.LeecherUploadRate == "" {
+		return 256 * 1024
+	}
+	rate, err := ParseRate(c.LeecherUploadRate)
+	if err != nil {
+		return 256 * 1024
+	}
+	return rate
+}
+
+// GetLeecherMaxUploads returns the concurrent uploads a leecher may have.
+// Returns 1 default if not configured.
+func (c *SharingConfig) GetLeecherMaxUploads() int {
+	if c.LeecherMaxUploads == nil {
+		return 1
+	}
+	return *c.LeecherMaxUploads
+}
+
+// CircuitBreakerConfig controls the per-peer circuit breaker: after
+// failure_threshold consecutive failures a peer is skipped for backoff, then
+// probed once; each failed probe doubles the backoff up to max_backoff.
+type CircuitBreakerConfig struct {
+	FailureThreshold *int   `toml:"failure_threshold"` // default 5, 0 = disabled
+	Backoff          string `toml:"backoff"`           // default "30s"
+	MaxBackoff       string `toml:"max_backoff"`       // default "10m"
+}
+
+// GetFailureThreshold returns the consecutive failures that open a breaker.
+// Returns 5 default if not configured.
+func (c *CircuitBreakerConfig) GetFailureThreshold() int {
+	if c.FailureThreshold == nil {
+		return 5
+	}
+	return *c.FailureThreshold
+}
+
+// BackoffDuration returns the initial open duration.
+// Returns 30 seconds default if parsing fails or value is empty.
+func (c *CircuitBreakerConfig) BackoffDuration() time.Duration {
+	if c.Backoff == "" {
+		return 30 * time.Second
+	}
+	d, err := units.ParseDuration(c.Backoff)
+	if err != nil {
+		return 30 * time.Second
+	}
+	return d
+}
+
+// MaxBackoffDuration returns the cap on the doubling backoff.
+// Returns 10 minutes default if parsing fails or value is empty.
+func (c *CircuitBreakerConfig) MaxBackoffDuration() time.Duration {
+	if c.MaxBackoff == "" {
+		return 10 * time.Minute
+	}
+	d, err := units.ParseDuration(c.MaxBackoff)
+	if err != nil {
+		return 10 * time.Minute
+	}
+	return d
+}
+
+// DefaultScoreWeights are the relative weights of the peer score components
+// ([transfer.scoring.weights]). Reciprocity, served bytes against taken
+// bytes, does not count by default.
+var DefaultScoreWeights = map[string]float64{
+	"latency":     0.25,
+	"throughput":  0.25,
+	"reliability": 0.20,
+	"freshness":   0.15,
+	"proximity":   0.15,
+	"reciprocity": 0,
+}
+
+// ScoringConfig tunes peer scores. Weights are relative and scaled to sum to
+// 1; components not listed keep their default weight. A measured score
+// decays toward neutral (0.5) while a peer goes without transfers, halfway
+// after decay_half_life.
+type ScoringConfig struct {
+	DecayHalfLife string             `toml:"decay_half_life"` // default "6h", "0s" = no decay
+	Weights       map[string]float64 `toml:"weights"`
+}
+
+// DecayHalfLifeDuration returns the score decay half-life.
+// Returns 6 hours default if parsing fails or value is empty.
+func (c *ScoringConfig) DecayHalfLifeDuration() time.Duration {
+	if c.DecayHalfLife == "" {
+		return 6 * time.Hour
+	}
+	d, err := units.ParseDuration(c.DecayHalfLife)
+	if err != nil || d < 0 {
+		return 6 * time.Hour
+	}
+	return d
+}
+
+// Weight returns the configured weight of a score component, or its default.
+func (c *ScoringConfig) Weight(component string) float64 {
+	if w, ok := c.Weights[component]; ok {
+		return w
+	}
+	return DefaultScoreWeights[component]
+}
+
+// PeerSelectionConfig exposes the knobs of provider selection. A subnet is an
+// IPv4 /24 (IPv6 /48); a netgroup is an IPv4 /16 (IPv6 /32), used as a rough
+// stand-in for an ASN. LAN (private-address or mDNS) peers are never capped.
+type PeerSelectionConfig struct {
+	MaxPeersPerSubnet   int      `toml:"max_peers_per_subnet"`   // 0 = unlimited (default)
+	MaxPeersPerNetgroup int      `toml:"max_peers_per_netgroup"` // 0 = unlimited (default)
+	MinScore            *float64 `toml:"min_score"`              // default 0.1
+	ExplorationRatio    *float64 `toml:"exploration_ratio"`      // default 0.3
+	// MinAddressGroups is the number of distinct netgroups the selected
+	// providers must span before a download may rely on peers alone; below
+	// it, the mirror is used instead. 0 disables the check (default).
+	MinAddressGroups int `toml:"min_address_groups"`
+	// PreferTags ranks peers labeled with any of these tags ('debswarm peers
+	// label') ahead of all others, e.g. ["seedbox"].
+	PreferTags []string `toml:"prefer_tags"`
+}
+
+// peerTagPattern matches a peer label tag
+var peerTagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,31}$`)
+
+// GetMinScore returns the minimum peer score for selection.
+// Returns 0.1 default if not configured.
+func (c *PeerSelectionConfig) GetMinScore() float64 {
+	if c.MinScore == nil {
+		return 0.1
+	}
+	return *c.MinScore
+}
+
+// GetExplorationRatio returns the share of slots given to exploratory peers.
+// Returns 0.3 default if not configured.
+func (c *PeerSelectionConfig) GetExplorationRatio() float64 {
+	if c.ExplorationRatio == nil {
+		return 0.3
+	}
+	return *c.ExplorationRatio
+}
+
+// DHTConfig holds DHT-related settings
+type DHTConfig struct {
+	ProviderTTL      string `toml:"provider_ttl"`
+	AnnounceInterval string `toml:"announce_interval"`
+
+	// Mode is "auto" (default: serve DHT records once publicly reachable),
+	// "server" or "client". Client mode never answers other nodes' queries,
+	// which keeps CPU and memory low on small devices.
+	Mode string `toml:"mode"`
+	// ProvideBudget and LookupBudget cap provide and provider-lookup
+	// operations per hour (0 = unlimited, the default). Over budget,
+	// operations queue with lookups for APT requests first and background
+	// re-announcement last.
+	ProvideBudget int `toml:"provide_budget"`
+	LookupBudget  int `toml:"lookup_budget"`
+}
+
+// ChaosConfig injects controlled failures so operators can check that hash
+// verification, retries and mirror fallback work before trusting the swarm.
+// All faults are off by default and logged at warning level when injected.
+type ChaosConfig struct {
+	DropStreamPercent float64 `toml:"drop_stream_percent,omitempty"` // share (0-100) of peer streams reset after opening
+	DHTDelay          string  `toml:"dht_delay,omitempty"`           // added to every provider lookup, e.g. "2s"
+	CorruptOneIn      int     `toml:"corrupt_one_in,omitempty"`      // corrupt every Nth peer download before verification
+}
+
+// Enabled reports whether any fault is configured
+func (c *ChaosConfig) Enabled() bool {
+	return c.DropStreamPercent > 0 || c.DHTDelayDuration() > 0 || c.CorruptOneIn > 0
+}
+
+// DHTDelayDuration returns the parsed DHT delay, 0 if unset or invalid.
+func (c *ChaosConfig) DHTDelayDuration() time.Duration {
+	if c.DHTDelay == "" {
+		return 0
+	}
+	d, err := units.ParseDuration(c.DHTDelay)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// DHT modes
+const (
+	DHTModeAuto   = "auto"
+	DHTModeServer = "server"
+	DHTModeClient = "client"
+)
+
+// GetMode returns the DHT mode with a default of "auto".
+func (c *DHTConfig) GetMode() string {
+	if c.Mode == "" {
+		return DHTModeAuto
+	}
+	return c.Mode
+}
+
+// ProviderTTLDuration returns the parsed provider TTL duration.
+// Returns 24h default if parsing fails or value is empty.
+func (c *DHTConfig) ProviderTTLDuration() time.Duration {
+	if c.ProviderTTL == "" {
+		return 24 * time.Hour
+	}
+	d, err := units.ParseDuration(c.ProviderTTL)
+	if err != nil {
+		return 24 * time.Hour
+	}
+	return d
+}
+
+// AnnounceIntervalDuration returns the parsed announce interval duration.
+// Returns 12h default if parsing fails or value is empty.
+func (c *DHTConfig) AnnounceIntervalDuration() time.Duration {
+	if c.AnnounceInterval == "" {
+		return 12 * time.Hour
+	}
+	d, err := units.ParseDuration(c.AnnounceInterval)
+	if err != nil {
+		return 12 * time.Hour
+	}
+	return d
+}
+
+// peerRuleNetwork parses a peer allowlist or blocklist entry naming
+// addresses: a CIDR, or a single IP taken as a /32 or /128
+func peerRuleNetwork(entry string) (*net.IPNet, bool) {
+	if _, n, err := net.ParseCIDR(entry); err == nil {
+		return n, true
+	}
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return nil, false
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, true
+}
+
+// PrivacyConfig holds privacy-related settings
+type PrivacyConfig struct {
+	EnableMDNS       bool     `toml:"enable_mdns"`
+	AnnouncePackages bool     `toml:"announce_packages"`
+	PSKPath          string   `toml:"psk_path"`        // Path to PSK file for private swarm
+	PSK              string   `toml:"psk"`             // Inline PSK (hex), mutually exclusive with path
+	PeerAllowlist    []string `toml:"peer_allowlist"`  // Allowed peer IDs, CIDRs and IP addresses
+	PeerBlocklist    []string `toml:"peer_blocklist"`  // Blocked peer IDs, CIDRs and IP addresses
+	IdentitySigner   string   `toml:"identity_signer"` // Helper program holding a hardware-backed identity key
+}
+
+// SwarmConfig describes an additional swarm the daemon joins with a second
+// P2P node. Packages from its origins are looked up, announced and served
+// only in that swarm; everything else stays in the main swarm.
+type SwarmConfig struct {
+	Name           string   `toml:"name"`            // Identifies the swarm in logs and its data directory
+	PSKPath        string   `toml:"psk_path"`        // PSK file; empty joins the public swarm
+	ListenPort     int      `toml:"listen_port"`     // Must differ from network.listen_port
+	BootstrapPeers []string `toml:"bootstrap_peers"` // Same forms as network.bootstrap_peers
+	RelayPeers     []string `toml:"relay_peers"`     // Same form as network.relay_peers
+
+	// Origins are the repositories whose packages belong to this swarm, as
+	// "host" or "host/path" without a scheme (e.g. "apt.corp.example/debian").
+	Origins []string `toml:"origins"`
+}
+
+var swarmNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// MetricsConfig holds metrics/monitoring settings
+type MetricsConfig struct {
+	Port int    `toml:"port"` // Metrics endpoint port (0 to disable)
+	Bind string `toml:"bind"` // Metrics endpoint bind address
+
+	// Per-peer transfer series: "top" labels the busiest peers by ID and
+	// the rest as "other", "hash" hashes peers into buckets, "off" exports
+	// none. Default "top".
+	PerPeer     string `toml:"per_peer"`
+	TopPeers    int    `toml:"top_peers"`    // peers labeled in top mode (default 10)
+	PeerBuckets int    `toml:"peer_buckets"` // buckets in hash mode (default 16)
+}
+
+// Limits on the per-peer label values, so a typo cannot bring back the
+// unbounded series the modes exist to prevent.
+const (
+	maxTopPeers    = 100
+	maxPeerBuckets = 256
+)
+
+// GetPerPeer returns the per-peer metrics mode, "top" by default.
+func (c *MetricsConfig) GetPerPeer() string {
+	if c.PerPeer == "" {
+		return "top"
+	}
+	return c.PerPeer
+}
+
+// ControlConfig holds gRPC control API settings
+type ControlConfig struct {
+	Socket      string   `toml:"socket"`       // Unix socket path (empty to disable)
+	Group       string   `toml:"group"`        // Group owning the socket; members may call read-only methods
+	AdminGroups []string `toml:"admin_groups"` // Groups whose members may also change state
+}
+
+// GenericConfig holds generic content-addressed mode settings
+type GenericConfig struct {
+	Enabled  bool     `toml:"enabled"`  // Serve /generic/<adapter>/ URLs
+	Adapters []string `toml:"adapters"` // Adapters to enable (default: all, see GenericAdapters)
+}
+
+// GenericAdapters are the adapter names accepted in generic.adapters
+var GenericAdapters = []string{"arch", "nix", "oci", "rpm"}
+
+// EnabledAdapters returns the adapters to enable, all of them by default,
+// or nil when generic mode is off.
+func (g GenericConfig) EnabledAdapters() []string {
+	if !g.Enabled {
+		return nil
+	}
+	if len(g.Adapters) == 0 {
+		return GenericAdapters
+	}
+	return g.Adapters
+}
+
+// LoggingConfig holds logging-related settings
+type LoggingConfig struct {
+	Level  string          `toml:"level"`
+	File   string          `toml:"file"`
+	Audit  AuditConfig     `toml:"audit"`
+	Access AccessLogConfig `toml:"access"`
+}
+
+// AuditConfig holds audit logging settings
+type AuditConfig struct {
+	Enabled    bool   `toml:"enabled"`     // Enable audit logging (default: false)
+	Path       string `toml:"path"`        // Path for JSON audit log file
+	MaxSizeMB  int    `toml:"max_size_mb"` // Max file size before rotation (default: 100)
+	MaxBackups int    `toml:"max_backups"` // Number of backup files to keep (default: 5)
+}
+
+// GetMaxSizeMB returns the max size with a default of 100MB
+func (c *AuditConfig) GetMaxSizeMB() int {
+	if c.MaxSizeMB <= 0 {
+		return 100
+	}
+	return c.MaxSizeMB
+}
+
+// GetMaxBackups returns the max backups with a default of 5
+func (c *AuditConfig) GetMaxBackups() int {
+	if c.MaxBackups <= 0 {
+		return 5
+	}
+	return c.MaxBackups
+}
+
+// AccessLogConfig holds per-request access log settings. Successful
+// requests are sampled and all lines rate capped, so the log can stay on
+// for busy proxies; failed requests are never sampled out.
+type AccessLogConfig struct {
+	Enabled      bool     `toml:"enabled"`        // Enable the access log (default: false)
+	Path         string   `toml:"path"`           // Path for the JSON Lines access log
+	SampleRate   *float64 `toml:"sample_rate"`    // Fraction of successful requests logged, default 1
+	MaxPerSecond *int     `toml:"max_per_second"` // Lines written per second at most, default 100, 0 = unlimited
+	MaxSizeMB    int      `toml:"max_size_mb"`    // Max file size before rotation (default: 100)
+	MaxBackups   int      `toml:"max_backups"`    // Number of backup files to keep (default: 5)
+}
+
+// GetSampleRate returns the fraction of successful requests logged (default 1)
+func (c *AccessLogConfig) GetSampleRate() float64 {
+	if c.SampleRate == nil {
+		return 1
+	}
+	return *c.SampleRate
+}
+
+// GetMaxPerSecond returns the cap on lines written per second (0 =
+// unlimited). Returns 100 default if not configured.
+func (c *AccessLogConfig) GetMaxPerSecond() int {
+	if c.MaxPerSecond == nil {
+		return 100
+	}
+	return *c.MaxPerSecond
+}
+
+// GetMaxSizeMB returns the max size with a default of 100MB
+func (c *AccessLogConfig) GetMaxSizeMB() int {
+	if c.MaxSizeMB <= 0 {
+		return 100
+	}
+	return c.MaxSizeMB
+}
+
+// GetMaxBackups returns the max backups with a default of 5
+func (c *AccessLogConfig) GetMaxBackups() int {
+	if c.MaxBackups <= 0 {
+		return 5
+	}
+	return c.MaxBackups
+}
+
+// SchedulerConfig holds scheduled sync window settings
+type SchedulerConfig struct {
+	Enabled           bool             `toml:"enabled"`                  // Enable scheduler (default: false)
+	Windows           []ScheduleWindow `toml:"windows"`                  // List of sync windows
+	Timezone          string           `toml:"timezone"`                 // IANA timezone (e.g., "America/New_York")
+	OutsideWindowRate string           `toml:"outside_window_rate"`      // Rate limit outside windows (e.g., "100KB/s")
+	InsideWindowRate  string           `toml:"inside_window_rate"`       // Rate limit inside windows (e.g., "unlimited")
+	UrgentFullSpeed   *bool            `toml:"urgent_always_full_speed"` // Security updates always get full speed
+
+	// Exceptions keep the recurring windows shut on the given dates, such
+	// as holidays and change freezes
+	Exceptions []ScheduleException `toml:"exceptions"`
+
+	// Power throttles or stops uploads on battery or a metered connection,
+	// whether or not the windows above are enabled
+	Power PowerConfig `toml:"power"`
+}
+
+// ScheduleException is a range of dates on which no recurring window opens
+type ScheduleException struct {
+	Name string `toml:"name"` // e.g. "Christmas freeze"
+	From string `toml:"from"` // first date, "2026-12-24"
+	To   string `toml:"to"`   // last date, inclusive (default: from)
+}
+
+// ScheduleWindow represents a time window for sync operations
+type ScheduleWindow struct {
+	Days      []string `toml:"days"`       // "monday", "tuesday", etc. or "weekday", "weekend"
+	StartTime string   `toml:"start_time"` // "09:00" (24h format)
+	EndTime   string   `toml:"end_time"`   // "17:00"
+}
+
+// OutsideWindowRateBytes returns the rate limit in bytes/sec for outside windows.
+// Returns 100KB/s default if not configured.
+func (c *SchedulerConfig) OutsideWindowRateBytes() int64 {
+	if c.OutsideWindowRate == "" {
+		return 100 * 1024 // 100KB/s default
+	}
+	rate, err := ParseRate(c.OutsideWindowRate)
+	if err != nil {
+		return 100 * 1024
+	}
+	return rate
+}
+
+// InsideWindowRateBytes returns the rate limit in bytes/sec for inside windows.
+// Returns 0 (unlimited) if not configured or set to "unlimited".
+func (c *SchedulerConfig) InsideWindowRateBytes() int64 {
+	if c.InsideWindowRate == "" || c.InsideWindowRate == "unlimited" {
+		return 0 // unlimited
+	}
+	rate, err := ParseRate(c.InsideWindowRate)
+	if err != nil {
+		return 0
+	}
+	return rate
+}
+
+// IsUrgentFullSpeed returns whether security updates should always get full speed.
+// Returns true by default.
+func (c *SchedulerConfig) IsUrgentFullSpeed() bool {
+	if c.UrgentFullSpeed == nil {
+		return true // default
+	}
+	return *c.UrgentFullSpeed
+}
+
+// PowerConfig adjusts uploads on a laptop: while it runs on battery, or
+// NetworkManager reports its connection as metered, uploads are throttled or
+// refused, and restored once it is back on AC and an unmetered network.
+// Linux only; a power source or network state that cannot be read counts as
+// AC, or as unmetered.
+type PowerConfig struct {
+	Enabled      bool   `toml:"enabled"`       // default false
+	OnBattery    string `toml:"on_battery"`    // "throttle", "disable" or "ignore", default "throttle"
+	OnMetered    string `toml:"on_metered"`    // "throttle", "disable" or "ignore", default "disable"
+	ThrottleRate string `toml:"throttle_rate"` // all uploads together while throttled, default "256KB/s"
+	Interval     string `toml:"interval"`      // how often power and network are checked, default "30s"
+}
+
+// Power policy actions.
+const (
+	PowerThrottle = "throttle"
+	PowerDisable  = "disable"
+	PowerIgnore   = "ignore"
+)
+
+func validPowerAction(action string) bool {
+	return action == PowerThrottle || action == PowerDisable || action == PowerIgnore
+}
+
+// GetOnBattery returns what happens to uploads on battery power.
+// Returns "throttle" default if not configured.
+func (c *PowerConfig) GetOnBattery() string {
+	if c.OnBattery == "" {
+		return PowerThrottle
+	}
+	return c.OnBattery
+}
+
+// GetOnMetered returns what happens to uploads on a metered connection.
+// Returns "disable" default if not configured.
+func (c *PowerConfig) GetOnMetered() string {
+	if c.OnMetered == "" {
+		return PowerDisable
+	}
+	return c.OnMetered
+}
+
+// ThrottleRateBytes returns the upload rate in bytes/sec while throttled.
+// Returns 256KB/s default if not configured or invalid.
+func (c *PowerConfig) ThrottleRateBytes() int64 {
+	if c.ThrottleRate == "" {
+		return 256 * 1024
+	}
+	rate, err := ParseRate(c.ThrottleRate)
+	if err != nil || rate <= 0 {
+		return 256 * 1024
+	}
+	return rate
+}
+
+// IntervalDuration returns how often power and network are checked.
+// Returns 30 seconds default if not configured or invalid.
+func (c *PowerConfig) IntervalDuration() time.Duration {
+	if c.Interval == "" {
+		return 30 * time.Second
+	}
+	d, err := units.ParseDuration(c.Interval)
+	if err != nil || d <= 0 {
+		return 30 * time.Second
+	}
+	return d
+}
+
+// BuildConfig configures the build listener: a second proxy port, on
+// network.proxy_bind, for debootstrap and mmdebstrap chroot builds. Requests
+// on it use the build profile, and every package they fetch is recorded in a
+// named build set so CI can find (and keep) what a build needs.
+type BuildConfig struct {
+	// Port is the build listener's port (0 = disabled, the default)
+	Port int `toml:"port"`
+	// MetadataTTL is how long cached Release and index files are served to
+	// builds without asking the mirror (default "15m"). A build asks for the
+	// same files several times; revalidating each is slow and, mid-build,
+	// risks a mirror update changing the index under it.
+	MetadataTTL string `toml:"metadata_ttl"`
+	// DefaultKey names the build set for requests that do not give one in
+	// the URL (default "default")
+	DefaultKey string `toml:"default_key"`
+	// Pin pins the packages a build fetches so eviction keeps them for the
+	// next run (default: false)
+	Pin bool `toml:"pin"`
+}
+
+// buildKeyPattern matches build set names
+var buildKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidBuildKey reports whether key can name a build set
+func ValidBuildKey(key string) bool {
+	return buildKeyPattern.MatchString(key)
+}
+
+// MetadataTTLDuration returns how long builds use cached metadata without
+// revalidation. Returns 15 minutes if not configured.
+func (c *BuildConfig) MetadataTTLDuration() time.Duration {
+	if c.MetadataTTL == "" {
+		return 15 * time.Minute
+	}
+	d, err := units.ParseDuration(c.MetadataTTL)
+	if err != nil || d < 0 {
+		return 15 * time.Minute
+	}
+	return d
+}
+
+// GetDefaultKey returns the build set used when a request names none
+func (c *BuildConfig) GetDefaultKey() string {
+	if c.DefaultKey == "" {
+		return "default"
+	}
+	return c.DefaultKey
+}
+
+// FleetConfig holds fleet coordination settings
+type FleetConfig struct {
+	Enabled         bool   `toml:"enabled"`          // Enable fleet coordination (default: false)
+	ClaimTimeout    string `toml:"claim_timeout"`    // How long to wait for peer to claim WAN download
+	MaxWaitTime     string `toml:"max_wait_time"`    // Max wait for peer to finish WAN download
+	AllowConcurrent int    `toml:"allow_concurrent"` // Number of concurrent WAN fetchers allowed
+	RefreshInterval string `toml:"refresh_interval"` // Progress broadcast interval
+
+	Shared FleetSharedConfig `toml:"shared"`
+
+	// HTTPS is a fallback transport between fleet nodes: when a P2P transfer
+	// from a fleet peer fails, the content is fetched from the peer over
+	// HTTPS with mutual TLS. Off unless port is set.
+	HTTPS FleetHTTPSConfig `toml:"https"`
+}
+
+// FleetHTTPSConfig configures the fleet's HTTPS fallback. Every fleet node
+// uses the same port, and a certificate issued by the fleet's own CA.
+type FleetHTTPSConfig struct {
+	Port     int    `toml:"port"`      // Port the content server listens on and peers are reached at (0 = disabled)
+	Bind     string `toml:"bind"`      // Address the server listens on (default: all interfaces)
+	CertFile string `toml:"cert_file"` // This node's certificate (PEM), used as server and client
+	KeyFile  string `toml:"key_file"`  // Its private key (PEM)
+	CAFile   string `toml:"ca_file"`   // The fleet CA certificate (PEM)
+}
+
+// IsEnabled reports whether the HTTPS fallback is configured
+func (c *FleetHTTPSConfig) IsEnabled() bool {
+	return c.Port > 0
+}
+
+// Addr returns the address the content server listens on
+func (c *FleetHTTPSConfig) Addr() string {
+	return net.JoinHostPort(c.Bind, strconv.Itoa(c.Port))
+}
+
+// validateFleetHTTPS checks the [fleet.https] section
+func (c *Config) validateFleetHTTPS() ValidationErrors {
+	h := &c.Fleet.HTTPS
+	if h.Port == 0 {
+		return nil
+	}
+	var errs ValidationErrors
+	switch {
+	case h.Port < 1 || h.Port > 65535:
+		errs = append(errs, ValidationError{
+			Field:   "fleet.https.port",
+			Message: fmt.Sprintf("must be between 1 and 65535, got %d", h.Port),
+		})
+	case h.Port == c.Network.ListenPort || h.Port == c.Network.ProxyPort || h.Port == c.Metrics.Port || h.Port == c.Build.Port:
+		errs = append(errs, ValidationError{
+			Field:   "fleet.https.port",
+			Message: fmt.Sprintf("port %d is already used by another listener", h.Port),
+		})
+	}
+	if h.Bind != "" && net.ParseIP(h.Bind) == nil {
+		errs = append(errs, ValidationError{
+			Field:   "fleet.https.bind",
+			Message: fmt.Sprintf("invalid IP address %q", h.Bind),
+		})
+	}
+	for key, path := range map[string]string{"cert_file": h.CertFile, "key_file": h.KeyFile, "ca_file": h.CAFile} {
+		if path == "" {
+			errs = append(errs, ValidationError{
+				Field:   "fleet.https." + key,
+				Message: "required when fleet.https.port is set",
+			})
+		} else if _, err := os.Stat(path); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "fleet.https." + key,
+				Message: fmt.Sprintf("%q is not accessible: %v", path, err),
+			})
+		}
+	}
+	return errs
+}
+
+// FleetSharedConfig controls fleet leader election and the shared settings
+// (scheduler windows, cache retention) the leader distributes to followers.
+type FleetSharedConfig struct {
+	// SettingsPath is a TOML file of settings to distribute. Setting it makes
+	// this node a leadership candidate; the candidate with the lowest peer ID
+	// leads.
+	SettingsPath string `toml:"settings_path"`
+	// Accept applies the leader's settings on this node (default: false).
+	Accept bool `toml:"accept"`
+	// TrustedLeaders lists the peer IDs allowed to lead. Empty trusts any
+	// fleet peer, which is only appropriate on a private swarm.
+	TrustedLeaders []string `toml:"trusted_leaders"`
+	// AnnounceInterval is how often candidates announce themselves (default "30s").
+	AnnounceInterval string `toml:"announce_interval"`
+}
+
+// AnnounceIntervalDuration returns the candidate announce interval.
+// Returns 30 seconds default if not configured.
+func (c *FleetSharedConfig) AnnounceIntervalDuration() time.Duration {
+	if c.AnnounceInterval == "" {
+		return 30 * time.Second
+	}
+	d, err := units.ParseDuration(c.AnnounceInterval)
+	if err != nil || d <= 0 {
+		return 30 * time.Second
+	}
+	return d
+}
+
+// IsEnabled reports whether this node takes part in leader election, either
+// as a candidate or as a follower applying the leader's settings.
+func (c *FleetSharedConfig) IsEnabled() bool {
+	return c.SettingsPath != "" || c.Accept
+}
+
+// ClaimTimeoutDuration returns the claim timeout duration.
+// Returns 5 seconds default if not configured.
+func (c *FleetConfig) ClaimTimeoutDuration() time.Duration {
+	if c.ClaimTimeout == "" {
+		return 5 * time.Second
+	}
+	d, err := units.ParseDuration(c.ClaimTimeout)
+	if err != nil {
+		return 5 * time.Second
 	}
 	return d
 }
@@ -727,7 +2288,7 @@ func (c *FleetConfig) MaxWaitTimeDuration() time.Duration {
 	if c.MaxWaitTime == "" {
 		return 5 * time.Minute
 	}
-	d, err := time.ParseDuration(c.MaxWaitTime)
+	d, err := units.ParseDuration(c.MaxWaitTime)
 	if err != nil {
 		return 5 * time.Minute
 	}
@@ -740,7 +2301,7 @@ func (c *FleetConfig) RefreshIntervalDuration() time.Duration {
 	if c.RefreshInterval == "" {
 		return 1 * time.Second
 	}
-	d, err := time.ParseDuration(c.RefreshInterval)
+	d, err := units.ParseDuration(c.RefreshInterval)
 	if err != nil {
 		return 1 * time.Second
 	}
@@ -756,16 +2317,91 @@ func (c *FleetConfig) GetAllowConcurrent() int {
 	return c.AllowConcurrent
 }
 
-// MaxSizeBytes returns the parsed max size in bytes.
-// Returns 10GB default if parsing fails or value is 0.
-func (c *CacheConfig) MaxSizeBytes() int64 {
+// ReplicationConfig makes this seed fetch whatever its partner seeds cache.
+// Each partner must list this node too: a node only answers subscriptions
+// from its own partners.
+type ReplicationConfig struct {
+	// Partners are the partner seeds, as full multiaddrs including
+	// /p2p/<peer-id>
+	Partners []string `toml:"partners"`
+	// Repos and Architectures limit what is replicated (empty = all);
+	// MaxSize skips larger packages ("0" or unset = no limit)
+	Repos         []string `toml:"repos"`
+	Architectures []string `toml:"architectures"`
+	MaxSize       string   `toml:"max_size"`
+	Concurrency   int      `toml:"concurrency"` // packages fetched at once, default 2
+	// CatchUp is how far back a partner's new content is requested on
+	// startup, to pick up what was cached while this node was down
+	// (default "24h", "0" = none)
+	CatchUp string `toml:"catch_up"`
+}
+
+// Enabled reports whether any partners are configured.
+func (c *ReplicationConfig) Enabled() bool {
+	return len(c.Partners) > 0
+}
+
+// PartnerAddrs returns the parsed partner addresses, skipping invalid ones
+// (which Validate reports).
+func (c *ReplicationConfig) PartnerAddrs() []peer.AddrInfo {
+	var out []peer.AddrInfo
+	for _, addr := range c.Partners {
+		if info, err := peer.AddrInfoFromString(addr); err == nil {
+			out = append(out, *info)
+		}
+	}
+	return out
+}
+
+// MaxSizeBytes returns the size above which packages are not replicated
+// (0 = no limit).
+func (c *ReplicationConfig) MaxSizeBytes() int64 {
 	size, err := ParseSize(c.MaxSize)
-	if err != nil || size == 0 {
-		return 10 * 1024 * 1024 * 1024 // 10GB default
+	if err != nil {
+		return 0
 	}
 	return size
 }
 
+// GetConcurrency returns how many packages are fetched at once.
+// Returns 2 default if not configured.
+func (c *ReplicationConfig) GetConcurrency() int {
+	if c.Concurrency <= 0 {
+		return 2
+	}
+	return c.Concurrency
+}
+
+// CatchUpDuration returns how far back partners' new content is requested
+// on startup. Returns 24 hours default if not configured or invalid.
+func (c *ReplicationConfig) CatchUpDuration() time.Duration {
+	if c.CatchUp == "" {
+		return 24 * time.Hour
+	}
+	d, err := units.ParseDuration(c.CatchUp)
+	if err != nil || d < 0 {
+		return 24 * time.Hour
+	}
+	return d
+}
+
+// MaxSizeLimit returns the parsed max size, which may be a percentage of
+// the filesystem. Returns the 10GiB default if parsing fails or value is 0.
+func (c *CacheConfig) MaxSizeLimit() units.Capacity {
+	limit, err := units.ParseCapacity(c.MaxSize)
+	if err != nil || (limit.Bytes == 0 && !limit.DiskRelative()) {
+		return units.Capacity{Bytes: 10 * 1024 * 1024 * 1024} // 10GiB default
+	}
+	return limit
+}
+
+// MaxSizeBytes returns the parsed max size in bytes, resolving a percentage
+// against disk, the size of the filesystem holding the cache.
+// Returns 10GiB default if parsing fails or value is 0.
+func (c *CacheConfig) MaxSizeBytes(disk int64) int64 {
+	return c.MaxSizeLimit().Resolve(disk)
+}
+
 // MinFreeSpaceBytes returns the parsed min free space in bytes.
 // Returns 0 if parsing fails (no minimum requirement).
 func (c *CacheConfig) MinFreeSpaceBytes() int64 {
@@ -776,6 +2412,32 @@ func (c *CacheConfig) MinFreeSpaceBytes() int64 {
 	return size
 }
 
+// DiskPressureIntervalDuration returns how often the disk-pressure watcher
+// runs (default 1m); 0 disables it.
+func (c *CacheConfig) DiskPressureIntervalDuration() time.Duration {
+	if c.DiskPressureInterval == "" {
+		return time.Minute
+	}
+	d, err := units.ParseDuration(c.DiskPressureInterval)
+	if err != nil || d < 0 {
+		return time.Minute
+	}
+	return d
+}
+
+// DiskPressureHeadroomBytes returns the parsed disk-pressure headroom in
+// bytes (default 256MB).
+func (c *CacheConfig) DiskPressureHeadroomBytes() int64 {
+	if c.DiskPressureHeadroom == "" {
+		return 256 * 1024 * 1024
+	}
+	size, err := ParseSize(c.DiskPressureHeadroom)
+	if err != nil {
+		return 256 * 1024 * 1024
+	}
+	return size
+}
+
 // MetadataCachingEnabled reports whether repository-metadata caching is on.
 // Default: true.
 func (c *CacheConfig) MetadataCachingEnabled() bool {
@@ -794,6 +2456,15 @@ func (c *CacheConfig) ServesStaleMetadata() bool {
 	return *c.ServeStaleMetadata
 }
 
+// ReconstructsPdiffs reports whether indexes are rebuilt from pdiffs.
+// Default: true.
+func (c *CacheConfig) ReconstructsPdiffs() bool {
+	if c.ReconstructPdiffs == nil {
+		return true
+	}
+	return *c.ReconstructPdiffs
+}
+
 // MetadataMaxSizeBytes returns the metadata cache disk budget in bytes, or 0
 // when metadata caching is disabled. Defaults to 1GB.
 func (c *CacheConfig) MetadataMaxSizeBytes() int64 {
@@ -807,33 +2478,84 @@ func (c *CacheConfig) MetadataMaxSizeBytes() int64 {
 	return size
 }
 
-// MaxUploadRateBytes returns the parsed max upload rate in bytes/sec.
-// Returns 0 (unlimited) if parsing fails (should not happen after Validate).
-func (c *TransferConfig) MaxUploadRateBytes() int64 {
-	rate, err := ParseRate(c.MaxUploadRate)
+// MemoryTierSizeBytes returns the in-memory tier budget in bytes, or 0 when
+// the tier is disabled (the default).
+func (c *CacheConfig) MemoryTierSizeBytes() int64 {
+	size, err := ParseSize(c.MemoryTierSize)
 	if err != nil {
-		return 0 // unlimited
+		return 0
 	}
-	return rate
+	return size
 }
 
-// MaxDownloadRateBytes returns the parsed max download rate in bytes/sec.
-// Returns 0 (unlimited) if parsing fails (should not happen after Validate).
-func (c *TransferConfig) MaxDownloadRateBytes() int64 {
-	rate, err := ParseRate(c.MaxDownloadRate)
-	if err != nil {
-		return 0 // unlimited
+// MemoryTierMaxObjectBytes returns the largest file size the in-memory tier
+// holds. Defaults to 1MB.
+func (c *CacheConfig) MemoryTierMaxObjectBytes() int64 {
+	size, err := ParseSize(c.MemoryTierMaxObject)
+	if err != nil || size == 0 {
+		return 1 * 1024 * 1024 // 1MB default
+	}
+	return size
+}
+
+// PassthroughTTLDuration returns the default lifetime of cached passthrough
+// objects. Returns 0 (always revalidate) if not configured.
+func (c *CacheConfig) PassthroughTTLDuration() time.Duration {
+	d, err := units.ParseDuration(c.PassthroughTTL)
+	if err != nil || d < 0 {
+		return 0
 	}
+	return d
+}
+
+// PassthroughMaxTTLDuration returns the cap on mirror-provided lifetimes of
+// passthrough objects. Returns 1 hour if not configured.
+func (c *CacheConfig) PassthroughMaxTTLDuration() time.Duration {
+	if c.PassthroughMaxTTL == "" {
+		return time.Hour
+	}
+	d, err := units.ParseDuration(c.PassthroughMaxTTL)
+	if err != nil || d < 0 {
+		return time.Hour
+	}
+	return d
+}
+
+// UploadRateLimit returns the parsed max upload rate, which may be relative
+// to the link. Returns the zero (unlimited) Rate if parsing fails (should not
+// happen after Validate).
+func (c *TransferConfig) UploadRateLimit() units.Rate {
+	rate, _ := units.ParseRate(c.MaxUploadRate)
 	return rate
 }
 
+// DownloadRateLimit returns the parsed max download rate, which may be
+// relative to the link. Returns the zero (unlimited) Rate if parsing fails
+// (should not happen after Validate).
+func (c *TransferConfig) DownloadRateLimit() units.Rate {
+	rate, _ := units.ParseRate(c.MaxDownloadRate)
+	return rate
+}
+
+// MaxUploadRateBytes returns the parsed max upload rate in bytes/sec.
+// Returns 0 (unlimited) if parsing fails or the rate is relative to the link.
+func (c *TransferConfig) MaxUploadRateBytes() int64 {
+	return c.UploadRateLimit().BytesPerSec
+}
+
+// MaxDownloadRateBytes returns the parsed max download rate in bytes/sec.
+// Returns 0 (unlimited) if parsing fails or the rate is relative to the link.
+func (c *TransferConfig) MaxDownloadRateBytes() int64 {
+	return c.DownloadRateLimit().BytesPerSec
+}
+
 // RetryIntervalDuration returns the parsed retry interval duration.
 // Returns 5 minutes default if parsing fails or value is empty.
 func (c *TransferConfig) RetryIntervalDuration() time.Duration {
 	if c.RetryInterval == "" {
 		return 5 * time.Minute
 	}
-	d, err := time.ParseDuration(c.RetryInterval)
+	d, err := units.ParseDuration(c.RetryInterval)
 	if err != nil {
 		return 5 * time.Minute
 	}
@@ -846,7 +2568,7 @@ func (c *TransferConfig) RetryMaxAgeDuration() time.Duration {
 	if c.RetryMaxAge == "" {
 		return 1 * time.Hour
 	}
-	d, err := time.ParseDuration(c.RetryMaxAge)
+	d, err := units.ParseDuration(c.RetryMaxAge)
 	if err != nil {
 		return 1 * time.Hour
 	}
@@ -900,6 +2622,72 @@ func (c *TransferConfig) IsAdaptiveEnabled() bool {
 	return c.IsPerPeerEnabled()
 }
 
+// BurstSizeBytes returns the global limiters' bucket size in bytes, 0 for
+// the default.
+func (c *TransferConfig) BurstSizeBytes() int64 {
+	size, err := ParseSize(c.BurstSize)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// PerPeerBurstSizeBytes returns the per-peer limiters' bucket size in
+// bytes, 0 for the default.
+func (c *TransferConfig) PerPeerBurstSizeBytes() int64 {
+	size, err := ParseSize(c.PerPeerBurstSize)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// SmallObjectSizeBytes returns the transfer size up to which rate limiters
+// do not hold transfers up. Returns 0 (off) if not configured or invalid.
+func (c *TransferConfig) SmallObjectSizeBytes() int64 {
+	size, err := ParseSize(c.SmallObjectSize)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// GetHedgePercentile returns the chunk latency percentile after which a
+// chunk is hedged (0 = off). Returns 95 default if not configured.
+func (c *TransferConfig) GetHedgePercentile() float64 {
+	if c.HedgePercentile == nil {
+		return 95
+	}
+	return *c.HedgePercentile
+}
+
+// GetRetryBudgetPercent returns the retry budget of a download as a
+// percentage of its chunk count. Returns 50 default if not configured.
+func (c *TransferConfig) GetRetryBudgetPercent() int {
+	if c.RetryBudgetPercent <= 0 {
+		return 50
+	}
+	return c.RetryBudgetPercent
+}
+
+// IsReadThroughEnabled reports whether interrupted downloads are served
+// from their completed prefix. Enabled by default.
+func (c *TransferConfig) IsReadThroughEnabled() bool {
+	return c.ReadThrough == nil || *c.ReadThrough
+}
+
+// IsStreamMirrorEnabled reports whether mirror downloads are streamed to
+// the client before verification completes. Enabled by default.
+func (c *TransferConfig) IsStreamMirrorEnabled() bool {
+	return c.StreamMirror == nil || *c.StreamMirror
+}
+
+// IsReceiptsEnabled reports whether signed receipts are kept for downloads
+// from peers. Enabled by default.
+func (c *TransferConfig) IsReceiptsEnabled() bool {
+	return c.Receipts == nil || *c.Receipts
+}
+
 // AdaptiveMinRateBytes returns the minimum adaptive rate in bytes/sec.
 // Returns 100KB/s default if not configured.
 func (c *TransferConfig) AdaptiveMinRateBytes() int64 {
@@ -964,9 +2752,9 @@ func DefaultConfig() *Config {
 			},
 		},
 		Cache: CacheConfig{
-			MaxSize:      "10GB",
+			MaxSize:      "10GiB",
 			Path:         cachePath,
-			MinFreeSpace: "1GB",
+			MinFreeSpace: "1GiB",
 		},
 		Transfer: TransferConfig{
 			MaxUploadRate:              "0", // unlimited
@@ -981,9 +2769,9 @@ func DefaultConfig() *Config {
 			PerPeerDownloadRate: "auto", // global_limit / expected_peers
 			ExpectedPeers:       10,     // For auto-calculation
 			// Adaptive rate limiting (enabled by default when per-peer is active)
-			AdaptiveRateLimiting: nil,       // Auto: enabled if per-peer active
-			AdaptiveMinRate:      "100KB/s", // Minimum rate floor
-			AdaptiveMaxBoost:     1.5,       // Max 1.5x base rate
+			AdaptiveRateLimiting: nil,        // Auto: enabled if per-peer active
+			AdaptiveMinRate:      "100KiB/s", // Minimum rate floor
+			AdaptiveMaxBoost:     1.5,        // Max 1.5x base rate
 		},
 		DHT: DHTConfig{
 			ProviderTTL:      "24h",
@@ -1025,14 +2813,16 @@ func Load(path string) (*Config, error) {
 	cfg := DefaultConfig()
 
 	data, err := os.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return cfg, nil // Use defaults if no config file
-		}
+	if err != nil && !os.IsNotExist(err) {
 		return nil, err
 	}
-
-	if err := toml.Unmarshal(data, cfg); err != nil {
+	// Use defaults if no config file
+	if err == nil {
+		if err := toml.Unmarshal(data, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadReposDir(path); err != nil {
 		return nil, err
 	}
 
@@ -1052,7 +2842,16 @@ func (c *Config) Save(path string) error {
 		return err
 	}
 
-	data, err := toml.Marshal(c)
+	// Repositories from repos.d stay in their own files
+	saved := *c
+	saved.Repos = nil
+	for _, r := range c.Repos {
+		if r.File == "" {
+			saved.Repos = append(saved.Repos, r)
+		}
+	}
+
+	data, err := toml.Marshal(&saved)
 	if err != nil {
 		return err
 	}
@@ -1061,92 +2860,159 @@ func (c *Config) Save(path string) error {
 }
 
 // ParseSize parses a size string like "10GB" into bytes.
-// Returns an error for empty strings, non-numeric input, or unrecognized units.
+// See units.ParseSize for the accepted notation.
 func ParseSize(s string) (int64, error) {
-	s = strings.TrimSpace(s)
-	if s == "" || s == "0" {
-		return 0, nil
-	}
-
-	var size int64
-	var unit string
-
-	n := parseWithUnit(s, &size, &unit)
-	if n == 0 {
-		return 0, fmt.Errorf("invalid size: no numeric value in %q", s)
-	}
-
-	unit = strings.ToUpper(strings.TrimSpace(unit))
-	var multiplier int64
-	switch unit {
-	case "", "B":
-		multiplier = 1
-	case "KB", "K":
-		multiplier = 1024
-	case "MB", "M":
-		multiplier = 1024 * 1024
-	case "GB", "G":
-		multiplier = 1024 * 1024 * 1024
-	case "TB", "T":
-		multiplier = 1024 * 1024 * 1024 * 1024
-	default:
-		return 0, fmt.Errorf("invalid size unit %q in %q", unit, s)
-	}
-
-	result := size * multiplier
-	// Check for overflow: if size is positive but result is negative or
-	// dividing back doesn't yield the original value, overflow occurred
-	if size > 0 && (result < 0 || result/multiplier != size) {
-		return 0, fmt.Errorf("size value overflows int64 in %q", s)
-	}
-	return result, nil
-}
-
-func parseWithUnit(s string, size *int64, unit *string) int {
-	// Find the end of the numeric prefix
-	var n int
-	for i, c := range s {
-		if c >= '0' && c <= '9' {
-			n = i + 1
-		} else {
-			break
-		}
-	}
-	if n > 0 {
-		// Use strconv.ParseInt for overflow-safe parsing
-		v, err := strconv.ParseInt(s[:n], 10, 64)
-		if err != nil {
-			// Overflow or other parse error — return 0 digits consumed
-			return 0
-		}
-		*size = v
-	}
-	*unit = s[n:]
-	return n
+	return units.ParseSize(s)
 }
 
 // ParseRate parses a rate string like "10MB/s" or "100KB" into bytes per second
-// Returns 0 for unlimited (empty string, "0", or "unlimited")
+// Returns 0 for unlimited (empty string, "0", or "unlimited"). Rates relative
+// to the link ("30%link") are only valid for the global limits, which are
+// read with UploadRateLimit and DownloadRateLimit.
 func ParseRate(s string) (int64, error) {
-	if s == "" || s == "0" || s == "unlimited" {
-		return 0, nil
+	rate, err := units.ParseRate(s)
+	if err != nil {
+		return 0, err
 	}
-
-	// Remove "/s" suffix if present
-	rateStr := s
-	if len(s) > 2 && s[len(s)-2:] == "/s" {
-		rateStr = s[:len(s)-2]
+	if rate.LinkRelative() {
+		return 0, errLinkRate
 	}
-
-	return ParseSize(rateStr)
+	return rate.BytesPerSec, nil
 }
 
+var errLinkRate = errors.New("percentage-of-link rates are only supported for transfer.max_upload_rate and transfer.max_download_rate")
+
 // SecurityWarning represents a security concern with the configuration
 type SecurityWarning struct {
 	Message string
 	File    string
 }
 
+// validateListen checks the [[network.listen]] entries
+func (c *Config) validateListen() ValidationErrors {
+	var errs ValidationErrors
+	seen := make(map[ListenConfig]bool)
+	for i, l := range c.Network.Listen {
+		field := fmt.Sprintf("network.listen[%d]", i)
+		if !slices.Contains(ListenTransports, l.Transport) {
+			errs = append(errs, ValidationError{
+				Field:   field + ".transport",
+				Message: fmt.Sprintf("must be one of %s, got %q", strings.Join(ListenTransports, ", "), l.Transport),
+			})
+		}
+		if l.Interface != "" && l.Address != "" {
+			errs = append(errs, ValidationError{
+				Field:   field,
+				Message: "set interface or address, not both",
+			})
+		}
+		if l.Address != "" && net.ParseIP(l.Address) == nil {
+			errs = append(errs, ValidationError{
+				Field:   field + ".address",
+				Message: fmt.Sprintf("must be an IP address, got %q", l.Address),
+			})
+		}
+		if l.Port < 0 || l.Port > 65535 {
+			errs = append(errs, ValidationError{
+				Field:   field + ".port",
+				Message: fmt.Sprintf("must be between 1 and 65535, got %d", l.Port),
+			})
+		}
+		if l.Port == 0 {
+			l.Port = c.Network.ListenPort
+		}
+		if seen[l] {
+			errs = append(errs, ValidationError{
+				Field:   field,
+				Message: "duplicate listener",
+			})
+		}
+		seen[l] = true
+	}
+	return errs
+}
+
+// validateSwarms checks the [[swarms]] entries
+func (c *Config) validateSwarms() ValidationErrors {
+	var errs ValidationErrors
+	mainPrivate := c.Privacy.PSKPath != "" || c.Privacy.PSK != ""
+	names := make(map[string]bool)
+	ports := map[int]bool{c.Network.ListenPort: true}
+	public := !mainPrivate
+
+	for i, sw := range c.Swarms {
+		field := fmt.Sprintf("swarms[%d]", i)
+		if !swarmNamePattern.MatchString(sw.Name) {
+			errs = append(errs, ValidationError{
+				Field:   field + ".name",
+				Message: fmt.Sprintf("invalid name %q (lowercase letters, digits, '-' and '_')", sw.Name),
+			})
+		} else if names[sw.Name] {
+			errs = append(errs, ValidationError{
+				Field:   field + ".name",
+				Message: fmt.Sprintf("duplicate swarm name %q", sw.Name),
+			})
+		}
+		names[sw.Name] = true
+
+		if sw.ListenPort < 1 || sw.ListenPort > 65535 {
+			errs = append(errs, ValidationError{
+				Field:   field + ".listen_port",
+				Message: fmt.Sprintf("must be between 1 and 65535, got %d", sw.ListenPort),
+			})
+		} else if ports[sw.ListenPort] {
+			errs = append(errs, ValidationError{
+				Field:   field + ".listen_port",
+				Message: fmt.Sprintf("port %d is already used by another swarm", sw.ListenPort),
+			})
+		}
+		ports[sw.ListenPort] = true
+
+		if sw.PSKPath == "" {
+			if public {
+				errs = append(errs, ValidationError{
+					Field:   field + ".psk_path",
+					Message: "required: the node is already a member of the public swarm",
+				})
+			}
+			public = true
+		}
+
+		if len(sw.Origins) == 0 {
+			errs = append(errs, ValidationError{
+				Field:   field + ".origins",
+				Message: "at least one origin is required",
+			})
+		}
+		for j, origin := range sw.Origins {
+			if origin == "" || strings.Contains(origin, "://") {
+				errs = append(errs, ValidationError{
+					Field:   fmt.Sprintf("%s.origins[%d]", field, j),
+					Message: fmt.Sprintf("invalid origin %q (use \"host\" or \"host/path\" without a scheme)", origin),
+				})
+			}
+		}
+
+		for j, addr := range sw.BootstrapPeers {
+			if _, err := ExpandBootstrapPeer(addr); addr != "" && err != nil {
+				errs = append(errs, ValidationError{
+					Field:   fmt.Sprintf("%s.bootstrap_peers[%d]", field, j),
+					Message: fmt.Sprintf("invalid bootstrap peer %q: %v", addr, err),
+				})
+			}
+		}
+		for j, addr := range sw.RelayPeers {
+			if _, err := peer.AddrInfoFromString(addr); addr != "" && err != nil {
+				errs = append(errs, ValidationError{
+					Field:   fmt.Sprintf("%s.relay_peers[%d]", field, j),
+					Message: fmt.Sprintf("invalid relay multiaddr %q: %v", addr, err),
+				})
+			}
+		}
+	}
+	return errs
+}
+
 // LoadWithWarnings reads configuration and returns security warnings
 // This should be used when security-sensitive options might be present
 func LoadWithWarnings(path string) (*Config, []SecurityWarning, error) {
@@ -1157,8 +3023,8 @@ func LoadWithWarnings(path string) (*Config, []SecurityWarning, error) {
 
 	var warnings []SecurityWarning
 
-	// Check file permissions if inline PSK is configured
-	if cfg.Privacy.PSK != "" {
+	// Check file permissions if an inline PSK or mirror credential is configured
+	if cfg.Privacy.PSK != "" || cfg.Mirror.hasLiteralSecrets() {
 		warn := checkFilePermissions(path)
 		if warn != nil {
 			warnings = append(warnings, *warn)
@@ -1197,7 +3063,7 @@ func checkFilePermissions(path string) *SecurityWarning {
 
 	if mode&0004 != 0 { // world readable
 		return &SecurityWarning{
-			Message: fmt.Sprintf("config file is world-readable (mode %04o); consider 'chmod 600 %s' for files with inline PSK", mode, path),
+			Message: fmt.Sprintf("config file is world-readable (mode %04o); consider 'chmod 600 %s' for files with inline secrets", mode, path),
 			File:    path,
 		}
 	}
@@ -1242,14 +3108,24 @@ func (c *Config) Validate() error {
 		if addr == "" {
 			continue
 		}
-		_, err := multiaddr.NewMultiaddr(addr)
-		if err != nil {
+		if _, err := ExpandBootstrapPeer(addr); err != nil {
 			errs = append(errs, ValidationError{
 				Field:   fmt.Sprintf("network.bootstrap_peers[%d]", i),
-				Message: fmt.Sprintf("invalid multiaddr %q: %v", addr, err),
+				Message: fmt.Sprintf("invalid bootstrap peer %q: %v", addr, err),
+			})
+		}
+	}
+	if c.Network.BootstrapResolveInterval != "" {
+		if d, err := units.ParseDuration(c.Network.BootstrapResolveInterval); err != nil || d < 0 {
+			errs = append(errs, ValidationError{
+				Field:   "network.bootstrap_resolve_interval",
+				Message: fmt.Sprintf("invalid duration %q", c.Network.BootstrapResolveInterval),
 			})
 		}
 	}
+	if c.Network.BootstrapMinPeers != nil && *c.Network.BootstrapMinPeers < 0 {
+		errs = append(errs, ValidationError{Field: "network.bootstrap_min_peers", Message: "must be >= 0"})
+	}
 
 	// Validate relay peers. These must carry a /p2p/<peer-id> component: a relay
 	// address without a peer ID cannot be reserved on.
@@ -1277,6 +3153,16 @@ func (c *Config) Validate() error {
 		})
 	}
 
+	// Validate node role
+	switch c.Network.GetRole() {
+	case RoleFull, RoleSeed:
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "network.role",
+			Message: fmt.Sprintf("invalid value %q (must be %q or %q)", c.Network.Role, RoleFull, RoleSeed),
+		})
+	}
+
 	// Validate reachability override
 	switch c.Network.GetForceReachability() {
 	case ReachabilityAuto, ReachabilityPublic, ReachabilityPrivate:
@@ -1298,7 +3184,7 @@ func (c *Config) Validate() error {
 		}
 	}
 	if s := c.Network.RelayLimits.Duration; s != "" {
-		if _, err := time.ParseDuration(s); err != nil {
+		if _, err := units.ParseDuration(s); err != nil {
 			errs = append(errs, ValidationError{
 				Field:   "network.relay_limits.duration",
 				Message: fmt.Sprintf("invalid duration %q: %v", s, err),
@@ -1320,6 +3206,66 @@ func (c *Config) Validate() error {
 		})
 	}
 
+	errs = append(errs, c.validateListen()...)
+
+	// Validate artifact class overrides
+	classNames := make([]string, 0, len(c.Proxy.Classes))
+	for name := range c.Proxy.Classes {
+		classNames = append(classNames, name)
+	}
+	sort.Strings(classNames)
+	for _, name := range classNames {
+		class := c.Proxy.Classes[name]
+		field := "proxy.classes." + name
+		switch {
+		case !slices.Contains(ArtifactClasses, name):
+			errs = append(errs, ValidationError{
+				Field:   field,
+				Message: fmt.Sprintf("unknown artifact class (must be one of %s)", strings.Join(ArtifactClasses, ", ")),
+			})
+		case class.Share != nil && *class.Share && !shareableClass(name):
+			errs = append(errs, ValidationError{
+				Field:   field + ".share",
+				Message: "only package, source and index artifacts can be shared with peers",
+			})
+		case class.Share != nil && *class.Share && !class.IsCached():
+			errs = append(errs, ValidationError{
+				Field:   field + ".share",
+				Message: "shared artifacts must be cached",
+			})
+		case class.TTL != "" && !PassthroughClass(name):
+			errs = append(errs, ValidationError{
+				Field:   field + ".ttl",
+				Message: "packages, indexes, Release files and pdiffs always revalidate; ttl applies to the other classes",
+			})
+		case class.TTL != "":
+			if d, err := units.ParseDuration(class.TTL); err != nil || d < 0 {
+				errs = append(errs, ValidationError{
+					Field:   field + ".ttl",
+					Message: fmt.Sprintf("invalid duration %q", class.TTL),
+				})
+			}
+		}
+	}
+
+	// Validate the upstream mirror policy
+	for i, cidr := range c.Proxy.AllowedCIDRs {
+		if _, _, err := net.ParseCIDR(strings.TrimSpace(cidr)); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("proxy.allowed_cidrs[%d]", i),
+				Message: fmt.Sprintf("invalid CIDR %q: %v", cidr, err),
+			})
+		}
+	}
+	for i, port := range c.Proxy.AllowedPorts {
+		if port < 1 || port > 65535 {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("proxy.allowed_ports[%d]", i),
+				Message: fmt.Sprintf("invalid port %d (must be 1-65535)", port),
+			})
+		}
+	}
+
 	// Validate proxy bind address + client allowlist (LAN server mode).
 	if c.Network.ProxyBind != "" && c.Network.ProxyBind != "localhost" && net.ParseIP(c.Network.ProxyBind) == nil {
 		errs = append(errs, ValidationError{
@@ -1348,9 +3294,16 @@ func (c *Config) Validate() error {
 		})
 	}
 
+	if c.Network.ProxyAdvertise != nil && *c.Network.ProxyAdvertise && isLoopbackBindAddr(c.Network.ProxyBind) {
+		errs = append(errs, ValidationError{
+			Field:   "network.proxy_advertise",
+			Message: "requires a non-loopback proxy_bind: a loopback proxy cannot serve other machines",
+		})
+	}
+
 	// Validate cache settings
 	if c.Cache.MaxSize != "" {
-		if _, err := ParseSize(c.Cache.MaxSize); err != nil {
+		if _, err := units.ParseCapacity(c.Cache.MaxSize); err != nil {
 			errs = append(errs, ValidationError{
 				Field:   "cache.max_size",
 				Message: fmt.Sprintf("invalid size %q: %v", c.Cache.MaxSize, err),
@@ -1365,74 +3318,264 @@ func (c *Config) Validate() error {
 			})
 		}
 	}
+	if c.Cache.DiskPressureInterval != "" {
+		if d, err := units.ParseDuration(c.Cache.DiskPressureInterval); err != nil || d < 0 {
+			errs = append(errs, ValidationError{
+				Field:   "cache.disk_pressure_interval",
+				Message: fmt.Sprintf("invalid duration %q (use 0s to disable)", c.Cache.DiskPressureInterval),
+			})
+		}
+	}
+	if c.Cache.DiskPressureHeadroom != "" {
+		if _, err := ParseSize(c.Cache.DiskPressureHeadroom); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "cache.disk_pressure_headroom",
+				Message: fmt.Sprintf("invalid size %q: %v", c.Cache.DiskPressureHeadroom, err),
+			})
+		}
+	}
+
+	if c.Cache.MemoryTierSize != "" {
+		if _, err := ParseSize(c.Cache.MemoryTierSize); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "cache.memory_tier_size",
+				Message: fmt.Sprintf("invalid size %q: %v", c.Cache.MemoryTierSize, err),
+			})
+		}
+	}
+	if c.Cache.MemoryTierMaxObject != "" {
+		if _, err := ParseSize(c.Cache.MemoryTierMaxObject); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "cache.memory_tier_max_object",
+				Message: fmt.Sprintf("invalid size %q: %v", c.Cache.MemoryTierMaxObject, err),
+			})
+		}
+	}
+	if c.Cache.IOThrottle.MaxRate != "" {
+		if rate, err := ParseRate(c.Cache.IOThrottle.MaxRate); err != nil {
+			errs = append(errs, ValidationError{Field: "cache.io_throttle.max_rate", Message: err.Error()})
+		} else if rate < 0 {
+			errs = append(errs, ValidationError{Field: "cache.io_throttle.max_rate", Message: "must be >= 0"})
+		}
+	}
+	if c.Cache.IOThrottle.MaxIOPS < 0 {
+		errs = append(errs, ValidationError{Field: "cache.io_throttle.max_iops", Message: "must be >= 0"})
+	}
+	for field, value := range map[string]string{
+		"cache.passthrough_ttl":     c.Cache.PassthroughTTL,
+		"cache.passthrough_max_ttl": c.Cache.PassthroughMaxTTL,
+	} {
+		if value == "" {
+			continue
+		}
+		if d, err := units.ParseDuration(value); err != nil || d < 0 {
+			errs = append(errs, ValidationError{
+				Field:   field,
+				Message: fmt.Sprintf("invalid duration %q", value),
+			})
+		}
+	}
+
+	// Validate rate limits
+	if c.Transfer.MaxUploadRate != "" {
+		if _, err := units.ParseRate(c.Transfer.MaxUploadRate); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "transfer.max_upload_rate",
+				Message: fmt.Sprintf("invalid rate %q: %v", c.Transfer.MaxUploadRate, err),
+			})
+		}
+	}
+	if c.Transfer.MaxDownloadRate != "" {
+		if _, err := units.ParseRate(c.Transfer.MaxDownloadRate); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "transfer.max_download_rate",
+				Message: fmt.Sprintf("invalid rate %q: %v", c.Transfer.MaxDownloadRate, err),
+			})
+		}
+	}
+
+	// Validate per-peer rate limits
+	if c.Transfer.PerPeerUploadRate != "" && c.Transfer.PerPeerUploadRate != "auto" && c.Transfer.PerPeerUploadRate != "0" {
+		if _, err := ParseRate(c.Transfer.PerPeerUploadRate); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "transfer.per_peer_upload_rate",
+				Message: fmt.Sprintf("invalid rate %q: must be 'auto', '0', or a rate like '5MB/s'", c.Transfer.PerPeerUploadRate),
+			})
+		}
+	}
+	if c.Transfer.PerPeerDownloadRate != "" && c.Transfer.PerPeerDownloadRate != "auto" && c.Transfer.PerPeerDownloadRate != "0" {
+		if _, err := ParseRate(c.Transfer.PerPeerDownloadRate); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "transfer.per_peer_download_rate",
+				Message: fmt.Sprintf("invalid rate %q: must be 'auto', '0', or a rate like '5MB/s'", c.Transfer.PerPeerDownloadRate),
+			})
+		}
+	}
+
+	// Validate adaptive min rate
+	if c.Transfer.AdaptiveMinRate != "" {
+		if _, err := ParseRate(c.Transfer.AdaptiveMinRate); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "transfer.adaptive_min_rate",
+				Message: fmt.Sprintf("invalid rate %q: %v", c.Transfer.AdaptiveMinRate, err),
+			})
+		}
+	}
+
+	// Validate adaptive max boost
+	if c.Transfer.AdaptiveMaxBoost < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "transfer.adaptive_max_boost",
+			Message: fmt.Sprintf("must be non-negative, got %v", c.Transfer.AdaptiveMaxBoost),
+		})
+	}
+
+	// Validate token bucket settings
+	for _, f := range []struct{ field, value string }{
+		{"transfer.burst_size", c.Transfer.BurstSize},
+		{"transfer.per_peer_burst_size", c.Transfer.PerPeerBurstSize},
+		{"transfer.small_object_size", c.Transfer.SmallObjectSize},
+	} {
+		if f.value == "" {
+			continue
+		}
+		if _, err := ParseSize(f.value); err != nil {
+			errs = append(errs, ValidationError{Field: f.field, Message: fmt.Sprintf("invalid size %q: %v", f.value, err)})
+		}
+	}
+	if burst := c.Transfer.BurstSizeBytes(); burst > math.MaxInt32 {
+		errs = append(errs, ValidationError{Field: "transfer.burst_size", Message: "must be less than 2GB"})
+	}
+	if burst := c.Transfer.PerPeerBurstSizeBytes(); burst > math.MaxInt32 {
+		errs = append(errs, ValidationError{Field: "transfer.per_peer_burst_size", Message: "must be less than 2GB"})
+	}
+	if p := c.Transfer.GetHedgePercentile(); p < 0 || p >= 100 {
+		errs = append(errs, ValidationError{Field: "transfer.hedge_percentile", Message: fmt.Sprintf("must be at least 0 and below 100, got %v", p)})
+	}
+	if c.Transfer.RetryBudgetPercent < 0 {
+		errs = append(errs, ValidationError{Field: "transfer.retry_budget_percent", Message: "must be >= 0"})
+	}
+	if c.Transfer.MaxChunksPerPeer < 0 {
+		errs = append(errs, ValidationError{Field: "transfer.max_chunks_per_peer", Message: "must be >= 0"})
+	}
+
+	// Validate PSK configuration (mutually exclusive)
+	if c.Privacy.PSKPath != "" && c.Privacy.PSK != "" {
+		errs = append(errs, ValidationError{
+			Field:   "privacy.psk/psk_path",
+			Message: "psk and psk_path are mutually exclusive; use only one",
+		})
+	}
+	for _, rules := range []struct {
+		name    string
+		entries []string
+	}{{"peer_allowlist", c.Privacy.PeerAllowlist}, {"peer_blocklist", c.Privacy.PeerBlocklist}} {
+		for i, entry := range rules.entries {
+			if _, err := peer.Decode(entry); err == nil {
+				continue
+			}
+			if _, ok := peerRuleNetwork(entry); !ok {
+				errs = append(errs, ValidationError{
+					Field:   fmt.Sprintf("privacy.%s[%d]", rules.name, i),
+					Message: fmt.Sprintf("%q is not a peer ID, CIDR or IP address", entry),
+				})
+			}
+		}
+	}
+	if c.Privacy.IdentitySigner != "" && !filepath.IsAbs(c.Privacy.IdentitySigner) {
+		errs = append(errs, ValidationError{
+			Field:   "privacy.identity_signer",
+			Message: fmt.Sprintf("must be an absolute path, got %q", c.Privacy.IdentitySigner),
+		})
+	}
 
-	// Validate rate limits
-	if c.Transfer.MaxUploadRate != "" {
-		if _, err := ParseRate(c.Transfer.MaxUploadRate); err != nil {
+	// Validate the build listener
+	if c.Build.Port != 0 {
+		switch {
+		case c.Build.Port < 1 || c.Build.Port > 65535:
 			errs = append(errs, ValidationError{
-				Field:   "transfer.max_upload_rate",
-				Message: fmt.Sprintf("invalid rate %q: %v", c.Transfer.MaxUploadRate, err),
+				Field:   "build.port",
+				Message: fmt.Sprintf("must be between 1 and 65535, got %d", c.Build.Port),
 			})
-		}
-	}
-	if c.Transfer.MaxDownloadRate != "" {
-		if _, err := ParseRate(c.Transfer.MaxDownloadRate); err != nil {
+		case c.Build.Port == c.Network.ProxyPort || c.Build.Port == c.Metrics.Port:
 			errs = append(errs, ValidationError{
-				Field:   "transfer.max_download_rate",
-				Message: fmt.Sprintf("invalid rate %q: %v", c.Transfer.MaxDownloadRate, err),
+				Field:   "build.port",
+				Message: fmt.Sprintf("port %d is already used by the proxy or metrics listener", c.Build.Port),
 			})
 		}
 	}
-
-	// Validate per-peer rate limits
-	if c.Transfer.PerPeerUploadRate != "" && c.Transfer.PerPeerUploadRate != "auto" && c.Transfer.PerPeerUploadRate != "0" {
-		if _, err := ParseRate(c.Transfer.PerPeerUploadRate); err != nil {
+	if c.Build.MetadataTTL != "" {
+		if d, err := units.ParseDuration(c.Build.MetadataTTL); err != nil || d < 0 {
 			errs = append(errs, ValidationError{
-				Field:   "transfer.per_peer_upload_rate",
-				Message: fmt.Sprintf("invalid rate %q: must be 'auto', '0', or a rate like '5MB/s'", c.Transfer.PerPeerUploadRate),
+				Field:   "build.metadata_ttl",
+				Message: fmt.Sprintf("invalid duration %q", c.Build.MetadataTTL),
 			})
 		}
 	}
-	if c.Transfer.PerPeerDownloadRate != "" && c.Transfer.PerPeerDownloadRate != "auto" && c.Transfer.PerPeerDownloadRate != "0" {
-		if _, err := ParseRate(c.Transfer.PerPeerDownloadRate); err != nil {
+	if c.Build.DefaultKey != "" && !ValidBuildKey(c.Build.DefaultKey) {
+		errs = append(errs, ValidationError{
+			Field:   "build.default_key",
+			Message: fmt.Sprintf("invalid build set name %q; use up to 64 letters, digits, '.', '_' or '-'", c.Build.DefaultKey),
+		})
+	}
+
+	errs = append(errs, c.validateSwarms()...)
+	errs = append(errs, c.validateRepos()...)
+	errs = append(errs, c.validateClients()...)
+	errs = append(errs, c.validateMirror()...)
+	errs = append(errs, c.validatePrefetch()...)
+
+	// Validate control socket
+	if c.Control.Socket != "" && !filepath.IsAbs(c.Control.Socket) {
+		errs = append(errs, ValidationError{
+			Field:   "control.socket",
+			Message: fmt.Sprintf("must be an absolute path, got %q", c.Control.Socket),
+		})
+	}
+	for i, g := range c.Control.AdminGroups {
+		if strings.TrimSpace(g) == "" {
 			errs = append(errs, ValidationError{
-				Field:   "transfer.per_peer_download_rate",
-				Message: fmt.Sprintf("invalid rate %q: must be 'auto', '0', or a rate like '5MB/s'", c.Transfer.PerPeerDownloadRate),
+				Field:   fmt.Sprintf("control.admin_groups[%d]", i),
+				Message: "group name must not be empty",
 			})
 		}
 	}
 
-	// Validate adaptive min rate
-	if c.Transfer.AdaptiveMinRate != "" {
-		if _, err := ParseRate(c.Transfer.AdaptiveMinRate); err != nil {
+	for i, name := range c.Generic.Adapters {
+		if !slices.Contains(GenericAdapters, name) {
 			errs = append(errs, ValidationError{
-				Field:   "transfer.adaptive_min_rate",
-				Message: fmt.Sprintf("invalid rate %q: %v", c.Transfer.AdaptiveMinRate, err),
+				Field:   fmt.Sprintf("generic.adapters[%d]", i),
+				Message: fmt.Sprintf("unknown adapter %q (must be one of %s)", name, strings.Join(GenericAdapters, ", ")),
 			})
 		}
 	}
 
-	// Validate adaptive max boost
-	if c.Transfer.AdaptiveMaxBoost < 0 {
+	// Validate metrics port
+	if c.Metrics.Port < 0 || c.Metrics.Port > 65535 {
 		errs = append(errs, ValidationError{
-			Field:   "transfer.adaptive_max_boost",
-			Message: fmt.Sprintf("must be non-negative, got %v", c.Transfer.AdaptiveMaxBoost),
+			Field:   "metrics.port",
+			Message: fmt.Sprintf("must be between 0 and 65535, got %d", c.Metrics.Port),
 		})
 	}
 
-	// Validate PSK configuration (mutually exclusive)
-	if c.Privacy.PSKPath != "" && c.Privacy.PSK != "" {
+	switch c.Metrics.GetPerPeer() {
+	case "off", "top", "hash":
+	default:
 		errs = append(errs, ValidationError{
-			Field:   "privacy.psk/psk_path",
-			Message: "psk and psk_path are mutually exclusive; use only one",
+			Field:   "metrics.per_peer",
+			Message: fmt.Sprintf("invalid mode %q; must be off, top, or hash", c.Metrics.PerPeer),
 		})
 	}
-
-	// Validate metrics port
-	if c.Metrics.Port < 0 || c.Metrics.Port > 65535 {
+	if c.Metrics.TopPeers < 0 || c.Metrics.TopPeers > maxTopPeers {
 		errs = append(errs, ValidationError{
-			Field:   "metrics.port",
-			Message: fmt.Sprintf("must be between 0 and 65535, got %d", c.Metrics.Port),
+			Field:   "metrics.top_peers",
+			Message: fmt.Sprintf("must be between 0 and %d, got %d", maxTopPeers, c.Metrics.TopPeers),
+		})
+	}
+	if c.Metrics.PeerBuckets < 0 || c.Metrics.PeerBuckets > maxPeerBuckets {
+		errs = append(errs, ValidationError{
+			Field:   "metrics.peer_buckets",
+			Message: fmt.Sprintf("must be between 0 and %d, got %d", maxPeerBuckets, c.Metrics.PeerBuckets),
 		})
 	}
 
@@ -1446,17 +3589,17 @@ func (c *Config) Validate() error {
 	}
 
 	// Validate connectivity mode
-	validModes := map[string]bool{"auto": true, "lan_only": true, "online_only": true, "": true}
+	validModes := map[string]bool{"auto": true, "lan_only": true, "online_only": true, "offline": true, "": true}
 	if !validModes[c.Network.ConnectivityMode] {
 		errs = append(errs, ValidationError{
 			Field:   "network.connectivity_mode",
-			Message: fmt.Sprintf("invalid mode %q; must be auto, lan_only, or online_only", c.Network.ConnectivityMode),
+			Message: fmt.Sprintf("invalid mode %q; must be auto, lan_only, online_only, or offline", c.Network.ConnectivityMode),
 		})
 	}
 
 	// Validate connectivity check interval
 	if c.Network.ConnectivityCheckInterval != "" {
-		if _, err := time.ParseDuration(c.Network.ConnectivityCheckInterval); err != nil {
+		if _, err := units.ParseDuration(c.Network.ConnectivityCheckInterval); err != nil {
 			errs = append(errs, ValidationError{
 				Field:   "network.connectivity_check_interval",
 				Message: fmt.Sprintf("invalid duration %q: %v", c.Network.ConnectivityCheckInterval, err),
@@ -1484,6 +3627,39 @@ func (c *Config) Validate() error {
 		})
 	}
 
+	// Validate access log config
+	access := c.Logging.Access
+	if access.Enabled && access.Path == "" {
+		errs = append(errs, ValidationError{
+			Field:   "logging.access.path",
+			Message: "access log path is required when the access log is enabled",
+		})
+	}
+	if rate := access.GetSampleRate(); rate < 0 || rate > 1 {
+		errs = append(errs, ValidationError{
+			Field:   "logging.access.sample_rate",
+			Message: fmt.Sprintf("must be between 0 and 1, got %g", rate),
+		})
+	}
+	if access.GetMaxPerSecond() < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "logging.access.max_per_second",
+			Message: fmt.Sprintf("must be non-negative, got %d", access.GetMaxPerSecond()),
+		})
+	}
+	if access.MaxSizeMB < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "logging.access.max_size_mb",
+			Message: fmt.Sprintf("must be non-negative, got %d", access.MaxSizeMB),
+		})
+	}
+	if access.MaxBackups < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "logging.access.max_backups",
+			Message: fmt.Sprintf("must be non-negative, got %d", access.MaxBackups),
+		})
+	}
+
 	// Validate scheduler config
 	if c.Scheduler.Enabled {
 		if c.Scheduler.Timezone != "" {
@@ -1510,12 +3686,79 @@ func (c *Config) Validate() error {
 				})
 			}
 		}
+		for i, e := range c.Scheduler.Exceptions {
+			field := fmt.Sprintf("scheduler.exceptions[%d]", i)
+			from, err := time.Parse("2006-01-02", e.From)
+			if err != nil {
+				errs = append(errs, ValidationError{
+					Field:   field + ".from",
+					Message: fmt.Sprintf("invalid date %q, expected YYYY-MM-DD", e.From),
+				})
+				continue
+			}
+			if e.To == "" {
+				continue
+			}
+			if to, err := time.Parse("2006-01-02", e.To); err != nil {
+				errs = append(errs, ValidationError{
+					Field:   field + ".to",
+					Message: fmt.Sprintf("invalid date %q, expected YYYY-MM-DD", e.To),
+				})
+			} else if to.Before(from) {
+				errs = append(errs, ValidationError{
+					Field:   field + ".to",
+					Message: fmt.Sprintf("%s is before from date %s", e.To, e.From),
+				})
+			}
+		}
+	}
+
+	pwr := c.Scheduler.Power
+	if pwr.OnBattery != "" && !validPowerAction(pwr.OnBattery) {
+		errs = append(errs, ValidationError{Field: "scheduler.power.on_battery", Message: fmt.Sprintf("must be throttle, disable or ignore, got %q", pwr.OnBattery)})
+	}
+	if pwr.OnMetered != "" && !validPowerAction(pwr.OnMetered) {
+		errs = append(errs, ValidationError{Field: "scheduler.power.on_metered", Message: fmt.Sprintf("must be throttle, disable or ignore, got %q", pwr.OnMetered)})
+	}
+	if pwr.ThrottleRate != "" {
+		if rate, err := ParseRate(pwr.ThrottleRate); err != nil {
+			errs = append(errs, ValidationError{Field: "scheduler.power.throttle_rate", Message: err.Error()})
+		} else if rate <= 0 {
+			errs = append(errs, ValidationError{Field: "scheduler.power.throttle_rate", Message: "must be greater than 0"})
+		}
+	}
+	if pwr.Interval != "" {
+		if d, err := units.ParseDuration(pwr.Interval); err != nil || d <= 0 {
+			errs = append(errs, ValidationError{Field: "scheduler.power.interval", Message: fmt.Sprintf("invalid duration %q", pwr.Interval)})
+		}
+	}
+
+	// Validate DHT mode and budgets
+	switch c.DHT.GetMode() {
+	case DHTModeAuto, DHTModeServer, DHTModeClient:
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "dht.mode",
+			Message: fmt.Sprintf("must be %q, %q or %q, got %q", DHTModeAuto, DHTModeServer, DHTModeClient, c.DHT.Mode),
+		})
+	}
+	if c.DHT.ProvideBudget < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "dht.provide_budget",
+			Message: "must be 0 (unlimited) or positive",
+		})
+	}
+	if c.DHT.LookupBudget < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "dht.lookup_budget",
+			Message: "must be 0 (unlimited) or positive",
+		})
 	}
 
 	// Validate fleet config
 	if c.Fleet.Enabled {
 		if c.Fleet.ClaimTimeout != "" {
-			if _, err := time.ParseDuration(c.Fleet.ClaimTimeout); err != nil {
+			if _, err := units.ParseDuration(c.Fleet.ClaimTimeout); err != nil {
 				errs = append(errs, ValidationError{
 					Field:   "fleet.claim_timeout",
 					Message: fmt.Sprintf("invalid duration %q: %v", c.Fleet.ClaimTimeout, err),
@@ -1523,7 +3766,7 @@ func (c *Config) Validate() error {
 			}
 		}
 		if c.Fleet.MaxWaitTime != "" {
-			if _, err := time.ParseDuration(c.Fleet.MaxWaitTime); err != nil {
+			if _, err := units.ParseDuration(c.Fleet.MaxWaitTime); err != nil {
 				errs = append(errs, ValidationError{
 					Field:   "fleet.max_wait_time",
 					Message: fmt.Sprintf("invalid duration %q: %v", c.Fleet.MaxWaitTime, err),
@@ -1531,13 +3774,30 @@ func (c *Config) Validate() error {
 			}
 		}
 		if c.Fleet.RefreshInterval != "" {
-			if _, err := time.ParseDuration(c.Fleet.RefreshInterval); err != nil {
+			if _, err := units.ParseDuration(c.Fleet.RefreshInterval); err != nil {
 				errs = append(errs, ValidationError{
 					Field:   "fleet.refresh_interval",
 					Message: fmt.Sprintf("invalid duration %q: %v", c.Fleet.RefreshInterval, err),
 				})
 			}
 		}
+		if c.Fleet.Shared.AnnounceInterval != "" {
+			if d, err := units.ParseDuration(c.Fleet.Shared.AnnounceInterval); err != nil || d <= 0 {
+				errs = append(errs, ValidationError{
+					Field:   "fleet.shared.announce_interval",
+					Message: fmt.Sprintf("invalid duration %q: must be positive", c.Fleet.Shared.AnnounceInterval),
+				})
+			}
+		}
+		for i, id := range c.Fleet.Shared.TrustedLeaders {
+			if _, err := peer.Decode(id); err != nil {
+				errs = append(errs, ValidationError{
+					Field:   fmt.Sprintf("fleet.shared.trusted_leaders[%d]", i),
+					Message: fmt.Sprintf("invalid peer ID %q: %v", id, err),
+				})
+			}
+		}
+		errs = append(errs, c.validateFleetHTTPS()...)
 	}
 
 	// Validate upstream signature-verification settings.
@@ -1559,6 +3819,360 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// A hash from an unverified index proves nothing: in the other modes an
+	// index that cannot be verified is still loaded, so a forged Packages
+	// file could make any hash known.
+	if c.Security.HashRequired && c.Security.GetVerifyMode() != VerifyEnforce {
+		errs = append(errs, ValidationError{
+			Field:   "security.hash_required",
+			Message: fmt.Sprintf("requires verify_upstream_signatures = \"enforce\", got %q", c.Security.GetVerifyMode()),
+		})
+	}
+	for _, repo := range c.Security.HashRequiredExempt {
+		if repo = strings.TrimSpace(repo); repo == "" || strings.ContainsAny(repo, " \t?#") {
+			errs = append(errs, ValidationError{
+				Field:   "security.hash_required_exempt",
+				Message: fmt.Sprintf("%q is not a host or host/path prefix", repo),
+			})
+		}
+	}
+
+	if c.Security.Scan.Clamd != "" && len(c.Security.Scan.Command) > 0 {
+		errs = append(errs, ValidationError{
+			Field:   "security.scan",
+			Message: "set either clamd or command, not both",
+		})
+	}
+	if c.Security.Scan.Timeout != "" {
+		if d, err := units.ParseDuration(c.Security.Scan.Timeout); err != nil || d <= 0 {
+			errs = append(errs, ValidationError{
+				Field:   "security.scan.timeout",
+				Message: fmt.Sprintf("invalid duration %q", c.Security.Scan.Timeout),
+			})
+		}
+	}
+
+	// Validate peer selection settings.
+	ps := c.Transfer.PeerSelection
+	if ps.MaxPeersPerSubnet < 0 {
+		errs = append(errs, ValidationError{Field: "transfer.peer_selection.max_peers_per_subnet", Message: "must be >= 0"})
+	}
+	if ps.MaxPeersPerNetgroup < 0 {
+		errs = append(errs, ValidationError{Field: "transfer.peer_selection.max_peers_per_netgroup", Message: "must be >= 0"})
+	}
+	if ps.MinAddressGroups < 0 {
+		errs = append(errs, ValidationError{Field: "transfer.peer_selection.min_address_groups", Message: "must be >= 0"})
+	}
+	if v := ps.GetMinScore(); v < 0 || v > 1 {
+		errs = append(errs, ValidationError{Field: "transfer.peer_selection.min_score", Message: fmt.Sprintf("must be between 0 and 1, got %v", v)})
+	}
+	if v := ps.GetExplorationRatio(); v < 0 || v > 1 {
+		errs = append(errs, ValidationError{Field: "transfer.peer_selection.exploration_ratio", Message: fmt.Sprintf("must be between 0 and 1, got %v", v)})
+	}
+	for _, tag := range ps.PreferTags {
+		if !peerTagPattern.MatchString(tag) {
+			errs = append(errs, ValidationError{Field: "transfer.peer_selection.prefer_tags", Message: fmt.Sprintf("invalid tag %q: use up to 32 of a-z, 0-9, '.', '_' and '-'", tag)})
+		}
+	}
+
+	// Validate scoring settings.
+	sc := c.Transfer.Scoring
+	if sc.DecayHalfLife != "" {
+		if d, err := units.ParseDuration(sc.DecayHalfLife); err != nil || d < 0 {
+			errs = append(errs, ValidationError{Field: "transfer.scoring.decay_half_life", Message: fmt.Sprintf("invalid duration %q", sc.DecayHalfLife)})
+		}
+	}
+	weightSum := 0.0
+	for name := range DefaultScoreWeights {
+		weightSum += sc.Weight(name)
+	}
+	for name, w := range sc.Weights {
+		field := "transfer.scoring.weights." + name
+		switch _, known := DefaultScoreWeights[name]; {
+		case !known:
+			errs = append(errs, ValidationError{Field: field, Message: "unknown score component; use latency, throughput, reliability, freshness, proximity or reciprocity"})
+		case w < 0:
+			errs = append(errs, ValidationError{Field: field, Message: fmt.Sprintf("must be >= 0, got %v", w)})
+		}
+	}
+	if weightSum <= 0 {
+		errs = append(errs, ValidationError{Field: "transfer.scoring.weights", Message: "at least one weight must be positive"})
+	}
+
+	// Validate canary settings.
+	canary := c.Transfer.Canary
+	if v := canary.SamplePercent; v < 0 || v > 100 {
+		errs = append(errs, ValidationError{Field: "transfer.canary.sample_percent", Message: fmt.Sprintf("must be between 0 and 100, got %v", v)})
+	}
+	if canary.MaxConcurrent < 0 {
+		errs = append(errs, ValidationError{Field: "transfer.canary.max_concurrent", Message: "must be >= 0"})
+	}
+	if canary.MaxSize != "" {
+		if _, err := ParseSize(canary.MaxSize); err != nil {
+			errs = append(errs, ValidationError{Field: "transfer.canary.max_size", Message: fmt.Sprintf("invalid size %q", canary.MaxSize)})
+		}
+	}
+
+	// Validate replication settings. Replication is for seeds: a partner
+	// keeps a protected connection and fetches everything this node caches.
+	repl := c.Replication
+	for i, addr := range repl.Partners {
+		if _, err := peer.AddrInfoFromString(addr); err != nil {
+			errs = append(errs, ValidationError{
+				Field: fmt.Sprintf("replication.partners[%d]", i),
+				Message: fmt.Sprintf("invalid partner multiaddr %q: %v "+
+					"(must be a full address including /p2p/<peer-id>)", addr, err),
+			})
+		}
+	}
+	if repl.Enabled() && c.Network.GetRole() != RoleSeed {
+		errs = append(errs, ValidationError{Field: "replication.partners", Message: fmt.Sprintf("replication requires network.role = %q", RoleSeed)})
+	}
+	if repl.MaxSize != "" {
+		if _, err := ParseSize(repl.MaxSize); err != nil {
+			errs = append(errs, ValidationError{Field: "replication.max_size", Message: fmt.Sprintf("invalid size %q", repl.MaxSize)})
+		}
+	}
+	if repl.Concurrency < 0 {
+		errs = append(errs, ValidationError{Field: "replication.concurrency", Message: "must be >= 0"})
+	}
+	if repl.CatchUp != "" {
+		if d, err := units.ParseDuration(repl.CatchUp); err != nil || d < 0 {
+			errs = append(errs, ValidationError{Field: "replication.catch_up", Message: fmt.Sprintf("invalid duration %q", repl.CatchUp)})
+		}
+	}
+
+	// Validate chaos settings.
+	if v := c.Chaos.DropStreamPercent; v < 0 || v > 100 {
+		errs = append(errs, ValidationError{Field: "chaos.drop_stream_percent", Message: fmt.Sprintf("must be between 0 and 100, got %v", v)})
+	}
+	if c.Chaos.DHTDelay != "" {
+		if d, err := units.ParseDuration(c.Chaos.DHTDelay); err != nil || d < 0 {
+			errs = append(errs, ValidationError{Field: "chaos.dht_delay", Message: fmt.Sprintf("invalid duration %q", c.Chaos.DHTDelay)})
+		}
+	}
+	if c.Chaos.CorruptOneIn < 0 {
+		errs = append(errs, ValidationError{Field: "chaos.corrupt_one_in", Message: "must be >= 0"})
+	}
+
+	// Validate circuit breaker settings.
+	cb := c.Transfer.CircuitBreaker
+	if cb.GetFailureThreshold() < 0 {
+		errs = append(errs, ValidationError{Field: "transfer.circuit_breaker.failure_threshold", Message: "must be >= 0"})
+	}
+	for _, f := range []struct{ field, value string }{
+		{"transfer.circuit_breaker.backoff", cb.Backoff},
+		{"transfer.circuit_breaker.max_backoff", cb.MaxBackoff},
+	} {
+		if f.value == "" {
+			continue
+		}
+		if d, err := units.ParseDuration(f.value); err != nil || d <= 0 {
+			errs = append(errs, ValidationError{Field: f.field, Message: fmt.Sprintf("invalid duration %q", f.value)})
+		}
+	}
+	if cb.BackoffDuration() > cb.MaxBackoffDuration() {
+		errs = append(errs, ValidationError{Field: "transfer.circuit_breaker.max_backoff", Message: "must be >= backoff"})
+	}
+
+	// Validate sharing policy settings.
+	sh := c.Transfer.Sharing
+	switch sh.Policy {
+	case "", SharingAltruistic, SharingReciprocal:
+	default:
+		errs = append(errs, ValidationError{Field: "transfer.sharing.policy", Message: fmt.Sprintf("must be %q or %q, got %q", SharingAltruistic, SharingReciprocal, sh.Policy)})
+	}
+	if sh.Grace != "" {
+		if _, err := ParseSize(sh.Grace); err != nil {
+			errs = append(errs, ValidationError{Field: "transfer.sharing.grace", Message: err.Error()})
+		}
+	}
+	if v := sh.GetMinRatio(); v < 0 {
+		errs = append(errs, ValidationError{Field: "transfer.sharing.min_ratio", Message: fmt.Sprintf("must be >= 0, got %v", v)})
+	}
+	if sh.LeecherUploadRate != "" {
+		if _, err := ParseRate(sh.LeecherUploadRate); err != nil {
+			errs = append(errs, ValidationError{Field: "transfer.sharing.leecher_upload_rate", Message: err.Error()})
+		}
+	}
+	if v := sh.GetLeecherMaxUploads(); v < 0 {
+		errs = append(errs, ValidationError{Field: "transfer.sharing.leecher_max_uploads", Message: "must be >= 0"})
+	}
+
+	// Validate split-horizon settings.
+	shz := c.Transfer.SplitHorizon
+	if shz.Deadline != "" {
+		if d, err := units.ParseDuration(shz.Deadline); err != nil || d <= 0 {
+			errs = append(errs, ValidationError{Field: "transfer.split_horizon.deadline", Message: fmt.Sprintf("invalid duration %q", shz.Deadline)})
+		}
+	}
+	for _, f := range []struct{ field, value string }{
+		{"transfer.split_horizon.min_lan_rate", shz.MinLANRate},
+		{"transfer.split_horizon.wan_upload_rate", shz.WANUploadRate},
+		{"transfer.split_horizon.wan_download_rate", shz.WANDownloadRate},
+	} {
+		if f.value == "" {
+			continue
+		}
+		if _, err := ParseRate(f.value); err != nil {
+			errs = append(errs, ValidationError{Field: f.field, Message: err.Error()})
+		}
+	}
+
+	// Validate transfer compression settings.
+	if lvl := c.Transfer.Compression.Level; lvl != "" && !slices.Contains(compressionLevels, lvl) {
+		errs = append(errs, ValidationError{
+			Field:   "transfer.compression.level",
+			Message: fmt.Sprintf("must be one of %s, got %q", strings.Join(compressionLevels, ", "), lvl),
+		})
+	}
+	if v := c.Transfer.Compression.MinSize; v != "" {
+		if _, err := ParseSize(v); err != nil {
+			errs = append(errs, ValidationError{Field: "transfer.compression.min_size", Message: err.Error()})
+		}
+	}
+
+	adm := c.Transfer.Admission
+	if v := adm.GetMaxLoad(); v < 0 {
+		errs = append(errs, ValidationError{Field: "transfer.admission.max_load", Message: "must be >= 0"})
+	}
+	if v := adm.GetMaxIOWaitPercent(); v < 0 || v > 100 {
+		errs = append(errs, ValidationError{Field: "transfer.admission.max_iowait_percent", Message: fmt.Sprintf("must be between 0 and 100, got %v", v)})
+	}
+	if adm.MaxNetworkRate != "" {
+		if _, err := ParseRate(adm.MaxNetworkRate); err != nil {
+			errs = append(errs, ValidationError{Field: "transfer.admission.max_network_rate", Message: err.Error()})
+		}
+	}
+	if adm.MinUploads < 0 {
+		errs = append(errs, ValidationError{Field: "transfer.admission.min_uploads", Message: "must be >= 0"})
+	}
+	if adm.Interval != "" {
+		if d, err := units.ParseDuration(adm.Interval); err != nil || d <= 0 {
+			errs = append(errs, ValidationError{Field: "transfer.admission.interval", Message: fmt.Sprintf("invalid duration %q", adm.Interval)})
+		}
+	}
+
+	upl := c.Transfer.Uplink
+	for _, f := range []struct{ field, value string }{
+		{"transfer.uplink.upload_rate", upl.UploadRate},
+		{"transfer.uplink.download_rate", upl.DownloadRate},
+	} {
+		if f.value == "" {
+			continue
+		}
+		if _, err := ParseRate(f.value); err != nil {
+			errs = append(errs, ValidationError{Field: f.field, Message: err.Error()})
+		}
+	}
+	if upl.Interval != "" {
+		if d, err := units.ParseDuration(upl.Interval); err != nil || d <= 0 {
+			errs = append(errs, ValidationError{Field: "transfer.uplink.interval", Message: fmt.Sprintf("invalid duration %q", upl.Interval)})
+		}
+	}
+	if upl.Dir != "" {
+		if !filepath.IsAbs(upl.Dir) {
+			errs = append(errs, ValidationError{Field: "transfer.uplink.dir", Message: fmt.Sprintf("must be an absolute path, got %q", upl.Dir)})
+		}
+		if upl.UploadRateBytes() <= 0 && upl.DownloadRateBytes() <= 0 {
+			errs = append(errs, ValidationError{Field: "transfer.uplink.dir", Message: "requires upload_rate or download_rate"})
+		}
+	}
+
+	sp := c.Transfer.StreamPool
+	if sp.MaxIdle < 0 {
+		errs = append(errs, ValidationError{Field: "transfer.stream_pool.max_idle", Message: "must be >= 0"})
+	}
+	if sp.KeepAlive != "" {
+		if d, err := units.ParseDuration(sp.KeepAlive); err != nil || d <= 0 || d > p2p.MaxStreamKeepAlive {
+			errs = append(errs, ValidationError{Field: "transfer.stream_pool.keepalive", Message: fmt.Sprintf("must be a positive duration of at most %s, got %q", p2p.MaxStreamKeepAlive, sp.KeepAlive)})
+		}
+	}
+
+	bans := c.Transfer.Bans
+	if bans.Duration != "" {
+		if d, err := units.ParseDuration(bans.Duration); err != nil || d <= 0 {
+			errs = append(errs, ValidationError{Field: "transfer.bans.duration", Message: fmt.Sprintf("must be a positive duration, got %q", bans.Duration)})
+		}
+	}
+	if bans.Multiplier != 0 && bans.Multiplier < 1 {
+		errs = append(errs, ValidationError{Field: "transfer.bans.multiplier", Message: fmt.Sprintf("must be >= 1, got %g", bans.Multiplier)})
+	}
+	if bans.PermanentAfter != nil && *bans.PermanentAfter < 0 {
+		errs = append(errs, ValidationError{Field: "transfer.bans.permanent_after", Message: "must be >= 0"})
+	}
+	if bans.StrikeExpiry != "" {
+		if d, err := units.ParseDuration(bans.StrikeExpiry); err != nil || d <= 0 {
+			errs = append(errs, ValidationError{Field: "transfer.bans.strike_expiry", Message: fmt.Sprintf("must be a positive duration, got %q", bans.StrikeExpiry)})
+		}
+	}
+
+	if v := c.Transfer.Probe.Size; v != "" {
+		if size, err := ParseSize(v); err != nil {
+			errs = append(errs, ValidationError{Field: "transfer.probe.size", Message: err.Error()})
+		} else if size <= 0 || size > maxProbeSize {
+			errs = append(errs, ValidationError{Field: "transfer.probe.size", Message: fmt.Sprintf("must be between 1 byte and 1MB, got %s", v)})
+		}
+	}
+
+	// Validate revocation list settings. A URL without a signing keyring would
+	// be unverifiable, so it is rejected rather than silently ignored.
+	if c.Revocation.URL != "" {
+		if c.Revocation.KeyringPath == "" {
+			errs = append(errs, ValidationError{
+				Field:   "revocation.keyring_path",
+				Message: "required when revocation.url is set",
+			})
+		}
+		if u, err := url.Parse(c.Revocation.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, ValidationError{
+				Field:   "revocation.url",
+				Message: fmt.Sprintf("must be an http(s) URL, got %q", c.Revocation.URL),
+			})
+		}
+	}
+	if c.Revocation.KeyringPath != "" {
+		if _, err := os.Stat(c.Revocation.KeyringPath); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "revocation.keyring_path",
+				Message: fmt.Sprintf("keyring path %q is not accessible: %v", c.Revocation.KeyringPath, err),
+			})
+		}
+	}
+	if c.Revocation.RefreshInterval != "" {
+		if d, err := units.ParseDuration(c.Revocation.RefreshInterval); err != nil || d <= 0 {
+			errs = append(errs, ValidationError{
+				Field:   "revocation.refresh_interval",
+				Message: fmt.Sprintf("invalid duration %q", c.Revocation.RefreshInterval),
+			})
+		}
+	}
+	if c.UpdateCheck.URL != "" {
+		if u, err := url.Parse(c.UpdateCheck.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, ValidationError{
+				Field:   "update_check.url",
+				Message: fmt.Sprintf("must be an http(s) URL, got %q", c.UpdateCheck.URL),
+			})
+		}
+	}
+	if c.UpdateCheck.Interval != "" {
+		if d, err := units.ParseDuration(c.UpdateCheck.Interval); err != nil || d <= 0 {
+			errs = append(errs, ValidationError{
+				Field:   "update_check.interval",
+				Message: fmt.Sprintf("invalid duration %q", c.UpdateCheck.Interval),
+			})
+		}
+	}
+
+	for _, root := range c.Sources.LocalMirror {
+		if info, err := os.Stat(filepath.Join(root, "dists")); err != nil || !info.IsDir() {
+			errs = append(errs, ValidationError{
+				Field:   "sources.local_mirror",
+				Message: fmt.Sprintf("%q is not a mirror tree: no dists/ directory", root),
+			})
+		}
+	}
+
 	if len(errs) > 0 {
 		return errs
 	}
@@ -33,8 +33,8 @@ func TestDefaultConfig(t *testing.T) {
 	}
 
 	// Check cache defaults
-	if cfg.Cache.MaxSize != "10GB" {
-		t.Errorf("Cache.MaxSize = %s, want 10GB", cfg.Cache.MaxSize)
+	if cfg.Cache.MaxSize != "10GiB" {
+		t.Errorf("Cache.MaxSize = %s, want 10GiB", cfg.Cache.MaxSize)
 	}
 
 	// Check transfer defaults
@@ -164,16 +164,20 @@ func TestParseSize(t *testing.T) {
 	}{
 		{"0", 0},
 		{"100", 100},
-		{"1KB", 1024},
+		{"1KB", 1000},
+		{"1KiB", 1024},
 		{"1K", 1024},
-		{"10KB", 10 * 1024},
-		{"1MB", 1024 * 1024},
+		{"10KB", 10 * 1000},
+		{"1MB", 1000 * 1000},
+		{"1MiB", 1024 * 1024},
 		{"1M", 1024 * 1024},
-		{"100MB", 100 * 1024 * 1024},
-		{"1GB", 1024 * 1024 * 1024},
+		{"100MB", 100 * 1000 * 1000},
+		{"1GB", 1000 * 1000 * 1000},
+		{"1GiB", 1024 * 1024 * 1024},
 		{"1G", 1024 * 1024 * 1024},
-		{"10GB", 10 * 1024 * 1024 * 1024},
-		{"1TB", 1024 * 1024 * 1024 * 1024},
+		{"10GB", 10 * 1000 * 1000 * 1000},
+		{"1TB", 1000 * 1000 * 1000 * 1000},
+		{"1TiB", 1024 * 1024 * 1024 * 1024},
 		{"1T", 1024 * 1024 * 1024 * 1024},
 	}
 
@@ -198,11 +202,12 @@ func TestParseRate(t *testing.T) {
 		{"", 0},          // unlimited
 		{"0", 0},         // unlimited
 		{"unlimited", 0}, // unlimited
-		{"1MB/s", 1024 * 1024},
-		{"10MB/s", 10 * 1024 * 1024},
-		{"100KB/s", 100 * 1024},
-		{"1GB/s", 1024 * 1024 * 1024},
-		{"50MB", 50 * 1024 * 1024}, // without /s
+		{"1MB/s", 1000 * 1000},
+		{"1MiB/s", 1024 * 1024},
+		{"10MiB/s", 10 * 1024 * 1024},
+		{"100KiB/s", 100 * 1024},
+		{"1GiB/s", 1024 * 1024 * 1024},
+		{"50MiB", 50 * 1024 * 1024}, // without /s
 	}
 
 	for _, tc := range tests {
@@ -328,8 +333,8 @@ listen_port = 7001
 	if cfg.Network.ProxyPort != 9977 {
 		t.Errorf("ProxyPort = %d, want 9977 (default)", cfg.Network.ProxyPort)
 	}
-	if cfg.Cache.MaxSize != "10GB" {
-		t.Errorf("Cache.MaxSize = %s, want 10GB (default)", cfg.Cache.MaxSize)
+	if cfg.Cache.MaxSize != "10GiB" {
+		t.Errorf("Cache.MaxSize = %s, want 10GiB (default)", cfg.Cache.MaxSize)
 	}
 }
 
@@ -344,6 +349,35 @@ func TestParseSize_EdgeCases(t *testing.T) {
 	}
 }
 
+func TestUnitNotation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Cache.MaxSize = "1.5GiB"
+	cfg.Transfer.MaxUploadRate = "30%link"
+	cfg.Transfer.MaxDownloadRate = "8MiB/s"
+	cfg.Transfer.RetryMaxAge = "2d"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if got := cfg.Cache.MaxSizeBytes(0); got != 3*1024*1024*1024/2 {
+		t.Errorf("MaxSizeBytes = %d", got)
+	}
+	if got := cfg.Transfer.UploadRateLimit(); got.LinkPercent != 30 || cfg.Transfer.MaxUploadRateBytes() != 0 {
+		t.Errorf("UploadRateLimit = %+v", got)
+	}
+	if got := cfg.Transfer.MaxDownloadRateBytes(); got != 8*1024*1024 {
+		t.Errorf("MaxDownloadRateBytes = %d", got)
+	}
+	if got := cfg.Transfer.RetryMaxAgeDuration(); got != 48*time.Hour {
+		t.Errorf("RetryMaxAgeDuration = %v", got)
+	}
+
+	// Only the global limits can follow the link
+	cfg.Transfer.PerPeerUploadRate = "10%link"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "transfer.per_peer_upload_rate") {
+		t.Errorf("per-peer link rate accepted: %v", err)
+	}
+}
+
 func TestValidate_ValidConfig(t *testing.T) {
 	cfg := DefaultConfig()
 	if err := cfg.Validate(); err != nil {
@@ -371,6 +405,125 @@ func TestValidate_InvalidBootstrapPeers(t *testing.T) {
 	}
 }
 
+func TestExpandBootstrapPeer(t *testing.T) {
+	const id = "12D3KooWDpJ7As7BWAwRMfu1VU2WCqNjvq387JEYKDBj4kx6nXTN"
+	tests := []struct {
+		entry   string
+		want    []string
+		wantErr bool
+	}{
+		{"/dnsaddr/bootstrap.example.org", []string{"/dnsaddr/bootstrap.example.org"}, false},
+		{"/dns4/boot.example.org/tcp/4001/p2p/" + id, []string{"/dns4/boot.example.org/tcp/4001/p2p/" + id}, false},
+		{"192.0.2.7:4001#" + id, []string{
+			"/ip4/192.0.2.7/tcp/4001/p2p/" + id,
+			"/ip4/192.0.2.7/udp/4001/quic-v1/p2p/" + id,
+		}, false},
+		{"[2001:db8::1]:4001#" + id, []string{
+			"/ip6/2001:db8::1/tcp/4001/p2p/" + id,
+			"/ip6/2001:db8::1/udp/4001/quic-v1/p2p/" + id,
+		}, false},
+		{"boot.example.org:4001#" + id, []string{
+			"/dns/boot.example.org/tcp/4001/p2p/" + id,
+			"/dns/boot.example.org/udp/4001/quic-v1/p2p/" + id,
+		}, false},
+		{"boot.example.org:4001", nil, true},           // no peer ID
+		{"boot.example.org:4001#not-an-id", nil, true}, // bad peer ID
+		{"boot.example.org#" + id, nil, true},          // no port
+		{"boot.example.org:99999#" + id, nil, true},    // port out of range
+		{":4001#" + id, nil, true},                     // no host
+		{"/ip4/invalid", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			got, err := ExpandBootstrapPeer(tt.entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandBootstrapPeer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("ExpandBootstrapPeer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetworkConfig_BootstrapResolveIntervalDuration(t *testing.T) {
+	tests := []struct {
+		interval string
+		expected time.Duration
+	}{
+		{"", 10 * time.Minute},
+		{"bogus", 10 * time.Minute},
+		{"0s", 0},
+		{"1h", time.Hour},
+	}
+	for _, tt := range tests {
+		cfg := &NetworkConfig{BootstrapResolveInterval: tt.interval}
+		if got := cfg.BootstrapResolveIntervalDuration(); got != tt.expected {
+			t.Errorf("BootstrapResolveIntervalDuration(%q) = %v, want %v", tt.interval, got, tt.expected)
+		}
+	}
+}
+
+func TestNetworkConfig_Bootstrap(t *testing.T) {
+	cfg := DefaultConfig()
+	if got := cfg.Network.GetBootstrapMinPeers(); got != 4 {
+		t.Errorf("default bootstrap_min_peers = %d, want 4", got)
+	}
+	if !cfg.Network.IsPersistPeersEnabled() {
+		t.Error("persist_peers should default to true")
+	}
+
+	zero, off := 0, false
+	cfg.Network.BootstrapMinPeers = &zero
+	cfg.Network.PersistPeers = &off
+	if got := cfg.Network.GetBootstrapMinPeers(); got != 0 {
+		t.Errorf("bootstrap_min_peers = %d, want 0 (wait for all)", got)
+	}
+	if cfg.Network.IsPersistPeersEnabled() {
+		t.Error("persist_peers = false ignored")
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid bootstrap settings rejected: %v", err)
+	}
+
+	negative := -1
+	cfg.Network.BootstrapMinPeers = &negative
+	if err := cfg.Validate(); err == nil || !contains(err.Error(), "network.bootstrap_min_peers") {
+		t.Errorf("negative bootstrap_min_peers should error mentioning the field, got: %v", err)
+	}
+}
+
+func TestIOThrottleConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if got := cfg.Cache.IOThrottle.MaxRateBytes(); got != 0 || cfg.Cache.IOThrottle.MaxIOPS != 0 {
+		t.Errorf("default io_throttle = %d B/s, %d IOPS, want unlimited", got, cfg.Cache.IOThrottle.MaxIOPS)
+	}
+
+	cfg.Cache.IOThrottle = IOThrottleConfig{MaxRate: "20MiB/s", MaxIOPS: 200}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid io_throttle rejected: %v", err)
+	}
+	if got := cfg.Cache.IOThrottle.MaxRateBytes(); got != 20*1024*1024 {
+		t.Errorf("MaxRateBytes = %d, want 20MB", got)
+	}
+
+	for field, io := range map[string]IOThrottleConfig{
+		"cache.io_throttle.max_rate": {MaxRate: "fast"},
+		"cache.io_throttle.max_iops": {MaxIOPS: -1},
+	} {
+		cfg := DefaultConfig()
+		cfg.Cache.IOThrottle = io
+		if err := cfg.Validate(); err == nil || !contains(err.Error(), field) {
+			t.Errorf("%+v: want an error mentioning %s, got %v", io, field, err)
+		}
+	}
+	cfg.Cache.IOThrottle = IOThrottleConfig{MaxRate: "10%"}
+	if err := cfg.Validate(); err == nil || !contains(err.Error(), "cache.io_throttle.max_rate") {
+		t.Errorf("link-relative rate should be rejected, got %v", err)
+	}
+}
+
 func TestValidate_InvalidPort(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.Network.ListenPort = 0
@@ -427,6 +580,37 @@ func TestValidate_ProxyBindFailClosed(t *testing.T) {
 	}
 }
 
+func TestProxyAdvertiseEnabled(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		bind      string
+		advertise *bool
+		mdns      bool
+		want      bool
+	}{
+		{"127.0.0.1", nil, true, false},
+		{"0.0.0.0", nil, true, true},
+		{"0.0.0.0", nil, false, false},
+		{"192.168.1.10", &yes, false, true},
+		{"0.0.0.0", &no, true, false},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Network.ProxyBind = tt.bind
+		cfg.Network.ProxyAdvertise = tt.advertise
+		cfg.Privacy.EnableMDNS = tt.mdns
+		if got := cfg.ProxyAdvertiseEnabled(); got != tt.want {
+			t.Errorf("bind %s, advertise %v, mdns %v: got %v", tt.bind, tt.advertise, tt.mdns, got)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.Network.ProxyAdvertise = &yes
+	if err := cfg.Validate(); err == nil || !contains(err.Error(), "network.proxy_advertise") {
+		t.Errorf("advertising a loopback proxy: err = %v", err)
+	}
+}
+
 func TestNetworkConfig_ParsedAllowedCIDRs(t *testing.T) {
 	n := &NetworkConfig{ProxyAllowedCIDRs: []string{"10.0.0.0/8", "", "fd00::/8"}}
 	nets, err := n.ParsedAllowedCIDRs()
@@ -492,6 +676,21 @@ func TestValidate_VerifyUpstreamSignatures(t *testing.T) {
 	}
 }
 
+func TestValidate_ConnectivityMode(t *testing.T) {
+	for _, mode := range []string{"", "auto", "lan_only", "online_only", "offline"} {
+		cfg := DefaultConfig()
+		cfg.Network.ConnectivityMode = mode
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("mode %q should validate, got: %v", mode, err)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.Network.ConnectivityMode = "airplane"
+	if err := cfg.Validate(); err == nil || !contains(err.Error(), "network.connectivity_mode") {
+		t.Fatalf("invalid mode should error mentioning the field, got: %v", err)
+	}
+}
+
 func TestValidate_KeyringPath(t *testing.T) {
 	cfg := DefaultConfig()
 	if err := cfg.Validate(); err != nil {
@@ -524,6 +723,36 @@ func TestValidate_MutuallyExclusivePSK(t *testing.T) {
 	}
 }
 
+func TestValidate_IdentitySigner(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Privacy.IdentitySigner = "/usr/lib/debswarm/tpm-signer"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("absolute signer path rejected: %v", err)
+	}
+
+	cfg.Privacy.IdentitySigner = "tpm-signer"
+	err := cfg.Validate()
+	if err == nil || !contains(err.Error(), "privacy.identity_signer") {
+		t.Errorf("relative signer path should error mentioning the field, got: %v", err)
+	}
+}
+
+func TestValidate_PeerRules(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Privacy.PeerAllowlist = []string{"12D3KooWDpJ7As7BWAwRMfu1VU2WCqNjvq387JEYKDBj4kx6nXTN", "10.20.0.0/16", "fd00::/8", "203.0.113.7"}
+	cfg.Privacy.PeerBlocklist = []string{"10.20.99.0/24", "2001:db8::1"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("peer IDs, CIDRs and IPs rejected: %v", err)
+	}
+
+	cfg.Privacy.PeerAllowlist = append(cfg.Privacy.PeerAllowlist, "10.20.0.0/33")
+	cfg.Privacy.PeerBlocklist = append(cfg.Privacy.PeerBlocklist, "not-a-peer")
+	err := cfg.Validate()
+	if err == nil || !contains(err.Error(), "privacy.peer_allowlist[4]") || !contains(err.Error(), "privacy.peer_blocklist[2]") {
+		t.Errorf("invalid entries should error mentioning their index, got: %v", err)
+	}
+}
+
 func TestValidate_InvalidLogLevel(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.Logging.Level = "invalid-level"
@@ -578,18 +807,20 @@ func TestCacheConfig_MaxSizeBytes(t *testing.T) {
 		maxSize  string
 		expected int64
 	}{
-		{"10GB", "10GB", 10 * 1024 * 1024 * 1024},
-		{"1GB", "1GB", 1024 * 1024 * 1024},
-		{"500MB", "500MB", 500 * 1024 * 1024},
+		{"10GiB", "10GiB", 10 * 1024 * 1024 * 1024},
+		{"1GiB", "1GiB", 1024 * 1024 * 1024},
+		{"500MiB", "500MiB", 500 * 1024 * 1024},
 		{"invalid falls back to 10GB", "invalid", 10 * 1024 * 1024 * 1024},
 		{"empty falls back to 10GB", "", 10 * 1024 * 1024 * 1024},
 		{"zero falls back to 10GB", "0", 10 * 1024 * 1024 * 1024},
+		{"percentage of a 200GB disk", "20%", 40 * 1024 * 1024 * 1024},
+		{"invalid percentage falls back to 10GB", "0%", 10 * 1024 * 1024 * 1024},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := &CacheConfig{MaxSize: tt.maxSize}
-			got := cfg.MaxSizeBytes()
+			got := cfg.MaxSizeBytes(200 * 1024 * 1024 * 1024)
 			if got != tt.expected {
 				t.Errorf("MaxSizeBytes() = %d, want %d", got, tt.expected)
 			}
@@ -597,6 +828,80 @@ func TestCacheConfig_MaxSizeBytes(t *testing.T) {
 	}
 }
 
+func TestCacheConfig_MemoryTier(t *testing.T) {
+	c := &CacheConfig{}
+	if c.MemoryTierSizeBytes() != 0 {
+		t.Error("memory tier should default to disabled")
+	}
+	if c.MemoryTierMaxObjectBytes() != 1024*1024 {
+		t.Errorf("MemoryTierMaxObjectBytes() = %d, want 1MB", c.MemoryTierMaxObjectBytes())
+	}
+	c = &CacheConfig{MemoryTierSize: "256MiB", MemoryTierMaxObject: "64KiB"}
+	if c.MemoryTierSizeBytes() != 256*1024*1024 || c.MemoryTierMaxObjectBytes() != 64*1024 {
+		t.Errorf("got %d, %d", c.MemoryTierSizeBytes(), c.MemoryTierMaxObjectBytes())
+	}
+
+	cfg := DefaultConfig()
+	cfg.Cache.MemoryTierSize = "lots"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "cache.memory_tier_size") {
+		t.Errorf("Validate() = %v, want a memory_tier_size error", err)
+	}
+}
+
+func TestCacheConfig_PassthroughTTL(t *testing.T) {
+	c := &CacheConfig{}
+	if c.PassthroughTTLDuration() != 0 || c.PassthroughMaxTTLDuration() != time.Hour {
+		t.Errorf("defaults = %v, %v; want 0, 1h", c.PassthroughTTLDuration(), c.PassthroughMaxTTLDuration())
+	}
+	c = &CacheConfig{PassthroughTTL: "10m", PassthroughMaxTTL: "0s"}
+	if c.PassthroughTTLDuration() != 10*time.Minute || c.PassthroughMaxTTLDuration() != 0 {
+		t.Errorf("got %v, %v", c.PassthroughTTLDuration(), c.PassthroughMaxTTLDuration())
+	}
+
+	cfg := DefaultConfig()
+	cfg.Cache.PassthroughTTL = "-1m"
+	cfg.Cache.PassthroughMaxTTL = "forever"
+	err := cfg.Validate()
+	for _, field := range []string{"cache.passthrough_ttl", "cache.passthrough_max_ttl"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Validate() = %v, want a %s error", err, field)
+		}
+	}
+}
+
+func TestBuildConfig(t *testing.T) {
+	c := &BuildConfig{}
+	if c.MetadataTTLDuration() != 15*time.Minute || c.GetDefaultKey() != "default" {
+		t.Errorf("defaults = %v, %q", c.MetadataTTLDuration(), c.GetDefaultKey())
+	}
+	c = &BuildConfig{MetadataTTL: "1h", DefaultKey: "ci"}
+	if c.MetadataTTLDuration() != time.Hour || c.GetDefaultKey() != "ci" {
+		t.Errorf("got %v, %q", c.MetadataTTLDuration(), c.GetDefaultKey())
+	}
+
+	for _, tt := range []struct {
+		field string
+		set   func(*BuildConfig)
+	}{
+		{"build.port", func(b *BuildConfig) { b.Port = 70000 }},
+		{"build.port", func(b *BuildConfig) { b.Port = 9977 }},
+		{"build.metadata_ttl", func(b *BuildConfig) { b.MetadataTTL = "soon" }},
+		{"build.default_key", func(b *BuildConfig) { b.DefaultKey = "../etc" }},
+	} {
+		cfg := DefaultConfig()
+		tt.set(&cfg.Build)
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.field) {
+			t.Errorf("Validate() = %v, want a %s error", err, tt.field)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.Build.Port = 9979
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
 func TestCacheConfig_MetadataMaxSizeBytes(t *testing.T) {
 	yes, no := true, false
 	tests := []struct {
@@ -605,10 +910,10 @@ func TestCacheConfig_MetadataMaxSizeBytes(t *testing.T) {
 		expected int64
 	}{
 		{"default (nil) enabled, 1GB default", CacheConfig{}, 1024 * 1024 * 1024},
-		{"explicit enabled with size", CacheConfig{CacheMetadata: &yes, MetadataMaxSize: "256MB"}, 256 * 1024 * 1024},
+		{"explicit enabled with size", CacheConfig{CacheMetadata: &yes, MetadataMaxSize: "256MiB"}, 256 * 1024 * 1024},
 		{"enabled, empty size falls back to 1GB", CacheConfig{CacheMetadata: &yes}, 1024 * 1024 * 1024},
 		{"enabled, invalid size falls back to 1GB", CacheConfig{CacheMetadata: &yes, MetadataMaxSize: "nope"}, 1024 * 1024 * 1024},
-		{"disabled returns 0 regardless of size", CacheConfig{CacheMetadata: &no, MetadataMaxSize: "256MB"}, 0},
+		{"disabled returns 0 regardless of size", CacheConfig{CacheMetadata: &no, MetadataMaxSize: "256MiB"}, 0},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -645,8 +950,8 @@ func TestCacheConfig_MinFreeSpaceBytes(t *testing.T) {
 		minFreeSpace string
 		expected     int64
 	}{
-		{"1GB", "1GB", 1024 * 1024 * 1024},
-		{"500MB", "500MB", 500 * 1024 * 1024},
+		{"1GiB", "1GiB", 1024 * 1024 * 1024},
+		{"500MiB", "500MiB", 500 * 1024 * 1024},
 		{"zero means no minimum", "0", 0},
 		{"invalid parses as 0 (no min)", "invalid", 0},
 		{"empty parses as 0 (no min)", "", 0},
@@ -669,8 +974,8 @@ func TestTransferConfig_MaxUploadRateBytes(t *testing.T) {
 		rate     string
 		expected int64
 	}{
-		{"10MB/s", "10MB/s", 10 * 1024 * 1024},
-		{"1MB/s", "1MB/s", 1024 * 1024},
+		{"10MiB/s", "10MiB/s", 10 * 1024 * 1024},
+		{"1MiB/s", "1MiB/s", 1024 * 1024},
 		{"0 (unlimited)", "0", 0},
 		{"invalid falls back to 0", "invalid", 0},
 		{"empty falls back to 0", "", 0},
@@ -693,8 +998,8 @@ func TestTransferConfig_MaxDownloadRateBytes(t *testing.T) {
 		rate     string
 		expected int64
 	}{
-		{"10MB/s", "10MB/s", 10 * 1024 * 1024},
-		{"1MB/s", "1MB/s", 1024 * 1024},
+		{"10MiB/s", "10MiB/s", 10 * 1024 * 1024},
+		{"1MiB/s", "1MiB/s", 1024 * 1024},
 		{"0 (unlimited)", "0", 0},
 		{"invalid falls back to 0", "invalid", 0},
 		{"empty falls back to 0", "", 0},
@@ -723,6 +1028,7 @@ func TestNetworkConfig_GetConnectivityMode(t *testing.T) {
 		{"auto", "auto", "auto"},
 		{"lan_only", "lan_only", "lan_only"},
 		{"online_only", "online_only", "online_only"},
+		{"offline", "offline", "offline"},
 	}
 
 	for _, tt := range tests {
@@ -985,7 +1291,7 @@ func TestTransferConfig_PerPeerRateBytes(t *testing.T) {
 	}{
 		{"empty is auto (0)", "", 0},
 		{"auto is 0", "auto", 0},
-		{"5MB/s", "5MB/s", 5 * 1024 * 1024},
+		{"5MiB/s", "5MiB/s", 5 * 1024 * 1024},
 		{"invalid is 0", "invalid", 0},
 	}
 
@@ -1019,7 +1325,7 @@ func TestTransferConfig_IsPerPeerEnabled(t *testing.T) {
 		{"both 0 is disabled", "0", "0", false},
 		{"only upload 0 is enabled", "0", "auto", true},
 		{"only download 0 is enabled", "auto", "0", true},
-		{"explicit rate is enabled", "5MB/s", "5MB/s", true},
+		{"explicit rate is enabled", "5MiB/s", "5MiB/s", true},
 	}
 
 	for _, tt := range tests {
@@ -1076,8 +1382,8 @@ func TestTransferConfig_AdaptiveMinRateBytes(t *testing.T) {
 		expected int64
 	}{
 		{"empty defaults to 100KB", "", 100 * 1024},
-		{"50KB/s", "50KB/s", 50 * 1024},
-		{"200KB/s", "200KB/s", 200 * 1024},
+		{"50KiB/s", "50KiB/s", 50 * 1024},
+		{"200KiB/s", "200KiB/s", 200 * 1024},
 	}
 
 	for _, tt := range tests {
@@ -1169,8 +1475,8 @@ func TestSchedulerConfig_OutsideWindowRateBytes(t *testing.T) {
 		expected int64
 	}{
 		{"empty defaults to 100KB", "", 100 * 1024},
-		{"50KB/s", "50KB/s", 50 * 1024},
-		{"200KB/s", "200KB/s", 200 * 1024},
+		{"50KiB/s", "50KiB/s", 50 * 1024},
+		{"200KiB/s", "200KiB/s", 200 * 1024},
 	}
 
 	for _, tt := range tests {
@@ -1192,7 +1498,7 @@ func TestSchedulerConfig_InsideWindowRateBytes(t *testing.T) {
 	}{
 		{"empty is unlimited (0)", "", 0},
 		{"unlimited is 0", "unlimited", 0},
-		{"10MB/s", "10MB/s", 10 * 1024 * 1024},
+		{"10MiB/s", "10MiB/s", 10 * 1024 * 1024},
 		{"invalid is unlimited (0)", "invalid", 0},
 	}
 
@@ -1232,6 +1538,31 @@ func TestSchedulerConfig_IsUrgentFullSpeed(t *testing.T) {
 	}
 }
 
+func TestValidate_SchedulerExceptions(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Scheduler.Enabled = true
+	cfg.Scheduler.Exceptions = []ScheduleException{
+		{Name: "Christmas", From: "2026-12-25"},
+		{Name: "freeze", From: "2026-12-18", To: "2027-01-04"},
+		{From: "25/12/2026"},
+		{From: "2026-12-25", To: "2026-12-24"},
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"scheduler.exceptions[2].from", "scheduler.exceptions[3].to"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q does not mention %s", err, field)
+		}
+	}
+	for _, field := range []string{"exceptions[0]", "exceptions[1]"} {
+		if strings.Contains(err.Error(), field) {
+			t.Errorf("valid exception rejected: %v", err)
+		}
+	}
+}
+
 // FleetConfig getter tests
 
 func TestFleetConfig_ClaimTimeoutDuration(t *testing.T) {
@@ -1322,6 +1653,181 @@ func TestFleetConfig_GetAllowConcurrent(t *testing.T) {
 	}
 }
 
+func TestFleetSharedConfig_AnnounceIntervalDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval string
+		expected time.Duration
+	}{
+		{"empty defaults to 30s", "", 30 * time.Second},
+		{"invalid defaults to 30s", "soon", 30 * time.Second},
+		{"negative defaults to 30s", "-1m", 30 * time.Second},
+		{"custom value", "2m", 2 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &FleetSharedConfig{AnnounceInterval: tt.interval}
+			if got := cfg.AnnounceIntervalDuration(); got != tt.expected {
+				t.Errorf("AnnounceIntervalDuration() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidate_FleetShared(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Fleet.Shared = FleetSharedConfig{
+		Accept:           true,
+		TrustedLeaders:   []string{"12D3KooWDpJ7As7BWAwRMfu1VU2WCqNjvq387JEYKDBj4kx6nXTN", "not-a-peer"},
+		AnnounceInterval: "0s",
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"fleet.shared.announce_interval", "fleet.shared.trusted_leaders[1]"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q does not mention %s", err, field)
+		}
+	}
+	if strings.Contains(err.Error(), "trusted_leaders[0]") {
+		t.Errorf("valid peer ID rejected: %v", err)
+	}
+}
+
+func TestValidate_FleetHTTPS(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"node.pem", "node.key", "ca.pem"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := DefaultConfig()
+	if cfg.Fleet.HTTPS.IsEnabled() {
+		t.Fatal("HTTPS fallback should be off by default")
+	}
+	cfg.Fleet.HTTPS = FleetHTTPSConfig{
+		Port:     9980,
+		CertFile: filepath.Join(dir, "node.pem"),
+		KeyFile:  filepath.Join(dir, "node.key"),
+		CAFile:   filepath.Join(dir, "ca.pem"),
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid fleet.https rejected: %v", err)
+	}
+	if addr := cfg.Fleet.HTTPS.Addr(); addr != ":9980" {
+		t.Errorf("Addr() = %q", addr)
+	}
+
+	cfg.Fleet.HTTPS = FleetHTTPSConfig{Port: cfg.Network.ProxyPort, Bind: "lan", CAFile: filepath.Join(dir, "missing.pem")}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"fleet.https.port", "fleet.https.bind", "fleet.https.cert_file", "fleet.https.key_file", "fleet.https.ca_file"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q does not mention %s", err, field)
+		}
+	}
+}
+
+func TestValidate_ArtifactClasses(t *testing.T) {
+	yes, no := true, false
+	cfg := DefaultConfig()
+	cfg.Proxy.Classes = map[string]ArtifactClassConfig{
+		"dep11":   {Cache: &no},
+		"index":   {Share: &yes},
+		"package": {Share: &no},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid class overrides rejected: %v", err)
+	}
+	if !cfg.Proxy.Classes["package"].IsCached() || cfg.Proxy.Classes["package"].IsShared("package") {
+		t.Error("package override: want cached, not shared")
+	}
+	if cfg.Proxy.Classes["dep11"].IsCached() || cfg.Proxy.Classes["dep11"].IsShared("dep11") {
+		t.Error("dep11 override: want neither cached nor shared")
+	}
+	if !(ArtifactClassConfig{}).IsShared("source") || (ArtifactClassConfig{Cache: &no}).IsShared("source") {
+		t.Error("source is shared by default, unless not cached")
+	}
+	if !cfg.Proxy.Classes["index"].IsShared("index") || (ArtifactClassConfig{}).IsShared("index") {
+		t.Error("index is shared only when opted in")
+	}
+
+	cfg.Proxy.Classes = map[string]ArtifactClassConfig{
+		"debs":    {},
+		"release": {Share: &yes},
+		"package": {Cache: &no, Share: &yes},
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"proxy.classes.debs", "proxy.classes.release.share", "proxy.classes.package.share"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q does not mention %s", err, field)
+		}
+	}
+
+	cfg.Proxy.Classes = map[string]ArtifactClassConfig{"translation": {TTL: "30m"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid class TTL rejected: %v", err)
+	}
+	if ttl, ok := cfg.Proxy.Classes["translation"].TTLDuration(); !ok || ttl != 30*time.Minute {
+		t.Errorf("TTLDuration() = %v, %v; want 30m", ttl, ok)
+	}
+	cfg.Proxy.Classes = map[string]ArtifactClassConfig{
+		"release":  {TTL: "5m"},
+		"contents": {TTL: "soon"},
+	}
+	err = cfg.Validate()
+	for _, field := range []string{"proxy.classes.release.ttl", "proxy.classes.contents.ttl"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Validate() = %v, want a %s error", err, field)
+		}
+	}
+}
+
+func TestValidate_Swarms(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Swarms = []SwarmConfig{
+		{Name: "corp", PSKPath: "/etc/debswarm/corp.key", ListenPort: 4002, Origins: []string{"apt.corp.example"}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid swarm rejected: %v", err)
+	}
+
+	cfg.Swarms = append(cfg.Swarms,
+		SwarmConfig{Name: "corp", PSKPath: "/etc/debswarm/b.key", ListenPort: 4002, Origins: []string{"https://b.example"}},
+		SwarmConfig{Name: "Bad Name", ListenPort: cfg.Network.ListenPort},
+	)
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{
+		"swarms[1].name", "swarms[1].listen_port", "swarms[1].origins[0]",
+		"swarms[2].name", "swarms[2].listen_port", "swarms[2].psk_path", "swarms[2].origins",
+	} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q does not mention %s", err, field)
+		}
+	}
+	if strings.Contains(err.Error(), "swarms[0]") {
+		t.Errorf("valid swarm rejected: %v", err)
+	}
+
+	// A private main swarm may join the public swarm as an additional one
+	cfg = DefaultConfig()
+	cfg.Privacy.PSKPath = "/etc/debswarm/swarm.key"
+	cfg.Swarms = []SwarmConfig{{Name: "public", ListenPort: 4002, Origins: []string{"deb.debian.org"}}}
+	if err := cfg.Validate(); err != nil && strings.Contains(err.Error(), "swarms[0]") {
+		t.Errorf("public additional swarm rejected: %v", err)
+	}
+}
+
 // ValidationError tests
 
 func TestValidationError_Error(t *testing.T) {
@@ -1684,6 +2190,22 @@ func TestNetworkConfig_GetRelayService(t *testing.T) {
 	}
 }
 
+func TestNetworkConfig_GetRole(t *testing.T) {
+	for in, want := range map[string]string{"": RoleFull, "full": RoleFull, " Seed ": RoleSeed} {
+		c := NetworkConfig{Role: in}
+		if got := c.GetRole(); got != want {
+			t.Errorf("GetRole(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.Network.Role = "mirror"
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "network.role") {
+		t.Errorf("Validate() with role %q = %v, want network.role error", cfg.Network.Role, err)
+	}
+}
+
 func TestNetworkConfig_RelayLimits(t *testing.T) {
 	t.Run("defaults when unset", func(t *testing.T) {
 		c := NetworkConfig{}
@@ -1705,7 +2227,7 @@ func TestNetworkConfig_RelayLimits(t *testing.T) {
 		c := NetworkConfig{RelayLimits: RelayLimitsConfig{
 			MaxReservations: 8,
 			MaxCircuits:     2,
-			BufferSize:      "64KB",
+			BufferSize:      "64KiB",
 			Duration:        "30s",
 		}}
 		if got := c.RelayMaxReservations(); got != 8 {
@@ -1809,3 +2331,914 @@ func TestValidate_RelayConfig(t *testing.T) {
 		}
 	})
 }
+
+func TestRevocationConfig_Defaults(t *testing.T) {
+	var c RevocationConfig
+	if c.Enabled() {
+		t.Error("revocation should be disabled without a keyring")
+	}
+	if c.RefreshIntervalDuration() != time.Hour {
+		t.Errorf("RefreshIntervalDuration = %v, want 1h", c.RefreshIntervalDuration())
+	}
+	if !c.IsGossipEnabled() {
+		t.Error("gossip should default to enabled")
+	}
+	off := false
+	c = RevocationConfig{KeyringPath: "/x", RefreshInterval: "15m", Gossip: &off}
+	if !c.Enabled() || c.RefreshIntervalDuration() != 15*time.Minute || c.IsGossipEnabled() {
+		t.Errorf("explicit settings not honored: %+v", c)
+	}
+}
+
+func TestValidate_Revocation(t *testing.T) {
+	keyring := filepath.Join(t.TempDir(), "revocation.gpg")
+	if err := os.WriteFile(keyring, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		rc      RevocationConfig
+		wantErr string
+	}{
+		{"disabled", RevocationConfig{}, ""},
+		{"valid", RevocationConfig{URL: "https://example.com/revocations.asc", KeyringPath: keyring}, ""},
+		{"gossip only", RevocationConfig{KeyringPath: keyring}, ""},
+		{"url without keyring", RevocationConfig{URL: "https://example.com/r.asc"}, "revocation.keyring_path"},
+		{"non-http url", RevocationConfig{URL: "ftp://example.com/r.asc", KeyringPath: keyring}, "revocation.url"},
+		{"missing keyring", RevocationConfig{KeyringPath: "/nonexistent/revocation.gpg"}, "revocation.keyring_path"},
+		{"bad interval", RevocationConfig{KeyringPath: keyring, RefreshInterval: "soon"}, "revocation.refresh_interval"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Revocation = tt.rc
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want mention of %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateCheckConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		uc      UpdateCheckConfig
+		wantErr string
+	}{
+		{"disabled", UpdateCheckConfig{}, ""},
+		{"valid", UpdateCheckConfig{Enabled: true, URL: "https://updates.example.com/debswarm/latest", Interval: "12h"}, ""},
+		{"non-http url", UpdateCheckConfig{Enabled: true, URL: "file:///etc/debswarm/latest"}, "update_check.url"},
+		{"bad interval", UpdateCheckConfig{Enabled: true, Interval: "daily"}, "update_check.interval"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.UpdateCheck = tt.uc
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want mention of %s", err, tt.wantErr)
+			}
+		})
+	}
+
+	if d := (&UpdateCheckConfig{}).IntervalDuration(); d != 24*time.Hour {
+		t.Errorf("default interval = %v, want 24h", d)
+	}
+}
+
+func TestSourcesConfig_Validate(t *testing.T) {
+	mirror := t.TempDir()
+	if err := os.Mkdir(filepath.Join(mirror, "dists"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Sources.LocalMirror = []string{mirror}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.Sources.LocalMirror = []string{mirror, t.TempDir()}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "sources.local_mirror") {
+		t.Fatalf("error = %v, want mention of sources.local_mirror", err)
+	}
+}
+
+func TestPeerSelectionConfig_Defaults(t *testing.T) {
+	var c PeerSelectionConfig
+	if c.GetMinScore() != 0.1 {
+		t.Errorf("GetMinScore = %v, want 0.1", c.GetMinScore())
+	}
+	if c.GetExplorationRatio() != 0.3 {
+		t.Errorf("GetExplorationRatio = %v, want 0.3", c.GetExplorationRatio())
+	}
+	zero := 0.0
+	c.ExplorationRatio = &zero
+	if c.GetExplorationRatio() != 0 {
+		t.Error("explicit 0 exploration ratio should be honored")
+	}
+}
+
+func TestValidate_PeerSelection(t *testing.T) {
+	bad := 1.5
+	cfg := DefaultConfig()
+	cfg.Transfer.PeerSelection = PeerSelectionConfig{MaxPeersPerSubnet: -1, ExplorationRatio: &bad, PreferTags: []string{"seedbox", "Seed Box"}}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	if strings.Contains(err.Error(), `"seedbox"`) {
+		t.Errorf("valid tag rejected: %v", err)
+	}
+	for _, field := range []string{"max_peers_per_subnet", "exploration_ratio", "prefer_tags"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q should mention %s", err, field)
+		}
+	}
+}
+
+func TestCircuitBreakerConfig_Defaults(t *testing.T) {
+	var cb CircuitBreakerConfig
+	if got := cb.GetFailureThreshold(); got != 5 {
+		t.Errorf("GetFailureThreshold() = %d, want 5", got)
+	}
+	if got := cb.BackoffDuration(); got != 30*time.Second {
+		t.Errorf("BackoffDuration() = %v, want 30s", got)
+	}
+	if got := cb.MaxBackoffDuration(); got != 10*time.Minute {
+		t.Errorf("MaxBackoffDuration() = %v, want 10m", got)
+	}
+	zero := 0
+	cb.FailureThreshold = &zero
+	if got := cb.GetFailureThreshold(); got != 0 {
+		t.Errorf("explicit 0 threshold = %d, want 0 (disabled)", got)
+	}
+}
+
+func TestValidate_CircuitBreaker(t *testing.T) {
+	neg := -1
+	cfg := DefaultConfig()
+	cfg.Transfer.CircuitBreaker = CircuitBreakerConfig{FailureThreshold: &neg, Backoff: "soon"}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"failure_threshold", "circuit_breaker.backoff"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q should mention %s", err, field)
+		}
+	}
+
+	cfg = DefaultConfig()
+	cfg.Transfer.CircuitBreaker = CircuitBreakerConfig{Backoff: "5m", MaxBackoff: "1m"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "max_backoff") {
+		t.Errorf("expected max_backoff < backoff to be rejected, got %v", err)
+	}
+}
+
+func TestScoringConfig(t *testing.T) {
+	var sc ScoringConfig
+	if got := sc.DecayHalfLifeDuration(); got != 6*time.Hour {
+		t.Errorf("DecayHalfLifeDuration() = %v, want 6h", got)
+	}
+	if sc.Weight("latency") != 0.25 || sc.Weight("reciprocity") != 0 {
+		t.Errorf("default weights: latency %v, reciprocity %v", sc.Weight("latency"), sc.Weight("reciprocity"))
+	}
+	sc = ScoringConfig{DecayHalfLife: "0s", Weights: map[string]float64{"reciprocity": 0.3}}
+	if sc.DecayHalfLifeDuration() != 0 || sc.Weight("reciprocity") != 0.3 || sc.Weight("proximity") != 0.15 {
+		t.Errorf("got %v, %v, %v", sc.DecayHalfLifeDuration(), sc.Weight("reciprocity"), sc.Weight("proximity"))
+	}
+
+	cfg := DefaultConfig()
+	cfg.Transfer.Scoring = ScoringConfig{
+		DecayHalfLife: "a while",
+		Weights:       map[string]float64{"speed": 1, "latency": -1},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{"transfer.scoring.decay_half_life", "transfer.scoring.weights.speed", "transfer.scoring.weights.latency"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Validate() = %v, want a %s error", err, field)
+		}
+	}
+
+	cfg.Transfer.Scoring = ScoringConfig{Weights: map[string]float64{
+		"latency": 0, "throughput": 0, "reliability": 0, "freshness": 0, "proximity": 0,
+	}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "transfer.scoring.weights") {
+		t.Errorf("Validate() = %v, want an all-zero weights error", err)
+	}
+}
+
+func TestTransferBurstConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if tc := cfg.Transfer; tc.BurstSizeBytes() != 0 || tc.PerPeerBurstSizeBytes() != 0 || tc.SmallObjectSizeBytes() != 0 {
+		t.Errorf("defaults = %d %d %d", tc.BurstSizeBytes(), tc.PerPeerBurstSizeBytes(), tc.SmallObjectSizeBytes())
+	}
+
+	cfg.Transfer.BurstSize = "8MiB"
+	cfg.Transfer.PerPeerBurstSize = "1MiB"
+	cfg.Transfer.SmallObjectSize = "256KiB"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if tc := cfg.Transfer; tc.BurstSizeBytes() != 8<<20 || tc.PerPeerBurstSizeBytes() != 1<<20 || tc.SmallObjectSizeBytes() != 256<<10 {
+		t.Errorf("parsed = %d %d %d", tc.BurstSizeBytes(), tc.PerPeerBurstSizeBytes(), tc.SmallObjectSizeBytes())
+	}
+
+	cfg.Transfer.BurstSize = "4GiB"
+	cfg.Transfer.PerPeerBurstSize = "lots"
+	cfg.Transfer.SmallObjectSize = "tiny"
+	err := cfg.Validate()
+	for _, field := range []string{"transfer.burst_size", "transfer.per_peer_burst_size", "transfer.small_object_size"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Validate() = %v, want a %s error", err, field)
+		}
+	}
+}
+
+func TestCanaryConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	canary := cfg.Transfer.Canary
+	if canary.Enabled() || canary.GetMaxConcurrent() != 1 || canary.MaxSizeBytes() != 100<<20 {
+		t.Errorf("defaults = %v %d %d", canary.Enabled(), canary.GetMaxConcurrent(), canary.MaxSizeBytes())
+	}
+
+	cfg.Transfer.Canary = CanaryConfig{SamplePercent: 5, MaxConcurrent: 3, MaxSize: "0"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if canary := cfg.Transfer.Canary; !canary.Enabled() || canary.GetMaxConcurrent() != 3 || canary.MaxSizeBytes() != 0 {
+		t.Errorf("parsed = %+v", canary)
+	}
+
+	cfg.Transfer.Canary = CanaryConfig{SamplePercent: 150, MaxConcurrent: -1, MaxSize: "huge"}
+	err := cfg.Validate()
+	for _, field := range []string{"transfer.canary.sample_percent", "transfer.canary.max_concurrent", "transfer.canary.max_size"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Validate() = %v, want a %s error", err, field)
+		}
+	}
+}
+
+func TestHedgeConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if tr := cfg.Transfer; tr.GetHedgePercentile() != 95 || tr.GetRetryBudgetPercent() != 50 || !tr.IsReadThroughEnabled() {
+		t.Errorf("defaults = %v %d %v", tr.GetHedgePercentile(), tr.GetRetryBudgetPercent(), tr.IsReadThroughEnabled())
+	}
+	no := false
+	cfg.Transfer.ReadThrough = &no
+	if cfg.Transfer.IsReadThroughEnabled() {
+		t.Error("read_through = false not honored")
+	}
+	if !cfg.Transfer.IsStreamMirrorEnabled() {
+		t.Error("stream_mirror should default to true")
+	}
+	cfg.Transfer.StreamMirror = &no
+	if cfg.Transfer.IsStreamMirrorEnabled() {
+		t.Error("stream_mirror = false not honored")
+	}
+
+	off := 0.0
+	cfg.Transfer.HedgePercentile = &off
+	cfg.Transfer.RetryBudgetPercent = 200
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if tr := cfg.Transfer; tr.GetHedgePercentile() != 0 || tr.GetRetryBudgetPercent() != 200 {
+		t.Errorf("parsed = %v %d", tr.GetHedgePercentile(), tr.GetRetryBudgetPercent())
+	}
+
+	all := 100.0
+	cfg.Transfer.HedgePercentile = &all
+	cfg.Transfer.RetryBudgetPercent = -1
+	cfg.Transfer.MaxChunksPerPeer = -1
+	err := cfg.Validate()
+	for _, field := range []string{"transfer.hedge_percentile", "transfer.retry_budget_percent", "transfer.max_chunks_per_peer"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Validate() = %v, want a %s error", err, field)
+		}
+	}
+}
+
+func TestAdmissionConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	adm := cfg.Transfer.Admission
+	if adm.Enabled || adm.GetMaxLoad() != 1 || adm.GetMaxIOWaitPercent() != 20 || adm.MaxNetworkRateBytes() != 0 ||
+		adm.GetMinUploads() != 1 || adm.IntervalDuration() != 10*time.Second {
+		t.Errorf("defaults = %v %v %v %d %d %v", adm.Enabled, adm.GetMaxLoad(), adm.GetMaxIOWaitPercent(),
+			adm.MaxNetworkRateBytes(), adm.GetMinUploads(), adm.IntervalDuration())
+	}
+
+	off := 0.0
+	cfg.Transfer.Admission = AdmissionConfig{
+		Enabled:        true,
+		MaxLoad:        &off,
+		MaxNetworkRate: "10MiB/s",
+		MinUploads:     3,
+		Interval:       "1m",
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	adm = cfg.Transfer.Admission
+	if adm.GetMaxLoad() != 0 || adm.MaxNetworkRateBytes() != 10*1024*1024 || adm.GetMinUploads() != 3 || adm.IntervalDuration() != time.Minute {
+		t.Errorf("parsed = %v %d %d %v", adm.GetMaxLoad(), adm.MaxNetworkRateBytes(), adm.GetMinUploads(), adm.IntervalDuration())
+	}
+
+	neg, over := -1.0, 150.0
+	cfg.Transfer.Admission = AdmissionConfig{
+		MaxLoad:          &neg,
+		MaxIOWaitPercent: &over,
+		MaxNetworkRate:   "fast",
+		MinUploads:       -1,
+		Interval:         "0s",
+	}
+	err := cfg.Validate()
+	for _, field := range []string{"max_load", "max_iowait_percent", "max_network_rate", "min_uploads", "interval"} {
+		if err == nil || !strings.Contains(err.Error(), "transfer.admission."+field) {
+			t.Errorf("Validate() = %v, want a transfer.admission.%s error", err, field)
+		}
+	}
+}
+
+func TestPowerConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	pwr := cfg.Scheduler.Power
+	if pwr.Enabled || pwr.GetOnBattery() != PowerThrottle || pwr.GetOnMetered() != PowerDisable ||
+		pwr.ThrottleRateBytes() != 256*1024 || pwr.IntervalDuration() != 30*time.Second {
+		t.Errorf("defaults = %+v", pwr)
+	}
+
+	cfg.Scheduler.Power = PowerConfig{Enabled: true, OnBattery: "disable", OnMetered: "ignore", ThrottleRate: "1MiB/s", Interval: "1m"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if pwr := cfg.Scheduler.Power; pwr.ThrottleRateBytes() != 1<<20 || pwr.IntervalDuration() != time.Minute {
+		t.Errorf("parsed = %d %v", pwr.ThrottleRateBytes(), pwr.IntervalDuration())
+	}
+
+	cfg.Scheduler.Power = PowerConfig{OnBattery: "sleep", OnMetered: "off", ThrottleRate: "unlimited", Interval: "0s"}
+	err := cfg.Validate()
+	for _, field := range []string{"on_battery", "on_metered", "throttle_rate", "interval"} {
+		if err == nil || !strings.Contains(err.Error(), "scheduler.power."+field) {
+			t.Errorf("Validate() = %v, want a scheduler.power.%s error", err, field)
+		}
+	}
+}
+
+func TestSharingConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	sh := cfg.Transfer.Sharing
+	if sh.IsReciprocal() || sh.GraceBytes() != 1<<30 || sh.GetMinRatio() != 0.1 ||
+		sh.LeecherUploadRateBytes() != 256*1024 || sh.GetLeecherMaxUploads() != 1 {
+		t.Errorf("defaults = %v %d %v %d %d", sh.IsReciprocal(), sh.GraceBytes(), sh.GetMinRatio(),
+			sh.LeecherUploadRateBytes(), sh.GetLeecherMaxUploads())
+	}
+
+	zero := 0
+	cfg.Transfer.Sharing = SharingConfig{Policy: SharingReciprocal, Grace: "100MiB", LeecherUploadRate: "0", LeecherMaxUploads: &zero}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if sh := cfg.Transfer.Sharing; !sh.IsReciprocal() || sh.GraceBytes() != 100<<20 || sh.LeecherUploadRateBytes() != 0 || sh.GetLeecherMaxUploads() != 0 {
+		t.Errorf("parsed = %+v", sh)
+	}
+
+	neg := -0.5
+	cfg.Transfer.Sharing = SharingConfig{Policy: "tit-for-tat", Grace: "lots", MinRatio: &neg, LeecherUploadRate: "fast"}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"sharing.policy", "sharing.grace", "sharing.min_ratio", "sharing.leecher_upload_rate"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q should mention %s", err, field)
+		}
+	}
+}
+
+func TestSplitHorizonConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	shz := cfg.Transfer.SplitHorizon
+	if shz.Enabled || shz.DeadlineDuration() != 10*time.Second || shz.MinLANRateBytes() != 0 ||
+		shz.WANUploadRateBytes() != 0 || shz.WANDownloadRateBytes() != 0 {
+		t.Errorf("defaults = %+v", shz)
+	}
+
+	cfg.Transfer.SplitHorizon = SplitHorizonConfig{Enabled: true, Deadline: "3s", MinLANRate: "10MiB/s", WANUploadRate: "1MiB/s", WANDownloadRate: "5MiB/s"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if shz := cfg.Transfer.SplitHorizon; shz.DeadlineDuration() != 3*time.Second || shz.MinLANRateBytes() != 10<<20 ||
+		shz.WANUploadRateBytes() != 1<<20 || shz.WANDownloadRateBytes() != 5<<20 {
+		t.Errorf("parsed = %+v", shz)
+	}
+
+	cfg.Transfer.SplitHorizon = SplitHorizonConfig{Deadline: "never", MinLANRate: "fast", WANUploadRate: "50%", WANDownloadRate: "slow"}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"split_horizon.deadline", "split_horizon.min_lan_rate", "split_horizon.wan_upload_rate", "split_horizon.wan_download_rate"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q should mention %s", err, field)
+		}
+	}
+}
+
+func TestUplinkConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	upl := cfg.Transfer.Uplink
+	if upl.IsEnabled() || upl.IntervalDuration() != time.Second {
+		t.Errorf("defaults = %+v", upl)
+	}
+
+	cfg.Transfer.Uplink = UplinkConfig{Dir: "/run/debswarm-uplink", DownloadRate: "50MiB/s", Interval: "2s"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if upl := cfg.Transfer.Uplink; !upl.IsEnabled() || upl.UploadRateBytes() != 0 ||
+		upl.DownloadRateBytes() != 50<<20 || upl.IntervalDuration() != 2*time.Second {
+		t.Errorf("parsed = %+v", upl)
+	}
+
+	cfg.Transfer.Uplink = UplinkConfig{Dir: "uplink", UploadRate: "fast", Interval: "0s"}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"uplink.upload_rate", "uplink.interval", "uplink.dir"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q should mention %s", err, field)
+		}
+	}
+}
+
+func TestTransferCompressionConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cmp := cfg.Transfer.Compression
+	if cmp.Enabled || cmp.GetLevel() != "fastest" || cmp.MinSizeBytes() != 4*1024 || cmp.LAN {
+		t.Errorf("defaults = %+v", cmp)
+	}
+
+	cfg.Transfer.Compression = CompressionConfig{Enabled: true, Level: "better", MinSize: "64KiB"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if cmp := cfg.Transfer.Compression; cmp.GetLevel() != "better" || cmp.MinSizeBytes() != 64*1024 {
+		t.Errorf("parsed = %+v", cmp)
+	}
+
+	cfg.Transfer.Compression = CompressionConfig{Enabled: true, Level: "max", MinSize: "big"}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"transfer.compression.level", "transfer.compression.min_size"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q should mention %s", err, field)
+		}
+	}
+}
+
+func TestTransferProbeConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if p := cfg.Transfer.Probe; p.OnConnect || p.SizeBytes() != 256*1024 {
+		t.Errorf("defaults = %+v", p)
+	}
+
+	cfg.Transfer.Probe = ProbeConfig{OnConnect: true, Size: "512KiB"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if p := cfg.Transfer.Probe; p.SizeBytes() != 512*1024 {
+		t.Errorf("parsed = %+v", p)
+	}
+
+	for _, size := range []string{"big", "2MiB"} {
+		cfg.Transfer.Probe.Size = size
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "transfer.probe.size") {
+			t.Errorf("size %q: error = %v", size, err)
+		}
+	}
+}
+
+func TestTransferStreamPoolConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if p := cfg.Transfer.StreamPool; !p.IsEnabled() || p.GetMaxIdle() != 4 || p.KeepAliveDuration() != 30*time.Second {
+		t.Errorf("defaults = %+v", p)
+	}
+
+	disabled := false
+	cfg.Transfer.StreamPool = StreamPoolConfig{Enabled: &disabled, MaxIdle: 2, KeepAlive: "45s"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if p := cfg.Transfer.StreamPool; p.IsEnabled() || p.GetMaxIdle() != 2 || p.KeepAliveDuration() != 45*time.Second {
+		t.Errorf("parsed = %+v", p)
+	}
+
+	for _, keepAlive := range []string{"soon", "0s", "2m"} {
+		cfg.Transfer.StreamPool.KeepAlive = keepAlive
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "transfer.stream_pool.keepalive") {
+			t.Errorf("keepalive %q: error = %v", keepAlive, err)
+		}
+	}
+	cfg.Transfer.StreamPool = StreamPoolConfig{MaxIdle: -1}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "transfer.stream_pool.max_idle") {
+		t.Errorf("max_idle -1: error = %v", err)
+	}
+}
+
+func TestValidate_Listen(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Network.Listen = []ListenConfig{
+		{Transport: "quic", Interface: "enp0s31f6"},
+		{Transport: "tcp", Address: "2001:db8::1", Port: 4002},
+		{Transport: "quic", Address: "0.0.0.0", Port: 4003},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	invalid := map[string]ListenConfig{
+		"network.listen[1].transport": {Transport: "sctp"},
+		"network.listen[1].address":   {Transport: "tcp", Address: "eth0"},
+		"network.listen[1].port":      {Transport: "tcp", Port: 70000},
+		"set interface or address":    {Transport: "tcp", Interface: "eth0", Address: "10.0.0.1"},
+		"duplicate listener":          {Transport: "quic", Interface: "enp0s31f6", Port: 4001},
+	}
+	for want, l := range invalid {
+		cfg.Network.Listen = []ListenConfig{{Transport: "quic", Interface: "enp0s31f6"}, l}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%+v: error = %v, want %q", l, err, want)
+		}
+	}
+}
+
+func TestTransferBanConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	b := cfg.Transfer.Bans
+	if b.DurationValue() != 24*time.Hour || b.GetMultiplier() != 4 || b.GetPermanentAfter() != 5 || b.StrikeExpiryDuration() != 720*time.Hour {
+		t.Errorf("defaults = %+v", b)
+	}
+
+	never := 0
+	cfg.Transfer.Bans = BanConfig{Duration: "1h", Multiplier: 2, PermanentAfter: &never, StrikeExpiry: "7d"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	b = cfg.Transfer.Bans
+	if b.DurationValue() != time.Hour || b.GetMultiplier() != 2 || b.GetPermanentAfter() != 0 || b.StrikeExpiryDuration() != 7*24*time.Hour {
+		t.Errorf("parsed = %+v", b)
+	}
+
+	negative := -1
+	invalid := map[string]BanConfig{
+		"transfer.bans.duration":        {Duration: "0s"},
+		"transfer.bans.multiplier":      {Multiplier: 0.5},
+		"transfer.bans.permanent_after": {PermanentAfter: &negative},
+		"transfer.bans.strike_expiry":   {StrikeExpiry: "never"},
+	}
+	for field, bans := range invalid {
+		cfg.Transfer.Bans = bans
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("%s: error = %v", field, err)
+		}
+	}
+}
+
+func TestAccessLogConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	a := cfg.Logging.Access
+	if a.Enabled || a.GetSampleRate() != 1 || a.GetMaxPerSecond() != 100 || a.GetMaxSizeMB() != 100 || a.GetMaxBackups() != 5 {
+		t.Errorf("defaults = %+v", a)
+	}
+
+	rate, unlimited := 0.01, 0
+	cfg.Logging.Access = AccessLogConfig{Enabled: true, Path: "/var/log/debswarm/access.log", SampleRate: &rate, MaxPerSecond: &unlimited}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if a = cfg.Logging.Access; a.GetSampleRate() != 0.01 || a.GetMaxPerSecond() != 0 {
+		t.Errorf("parsed = %+v", a)
+	}
+
+	tooHigh, negative := 1.5, -1
+	invalid := map[string]AccessLogConfig{
+		"logging.access.path":           {Enabled: true},
+		"logging.access.sample_rate":    {SampleRate: &tooHigh},
+		"logging.access.max_per_second": {MaxPerSecond: &negative},
+		"logging.access.max_size_mb":    {MaxSizeMB: -1},
+		"logging.access.max_backups":    {MaxBackups: -1},
+	}
+	for field, access := range invalid {
+		cfg.Logging.Access = access
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("%s: error = %v", field, err)
+		}
+	}
+}
+
+func TestValidate_HashRequiredExempt(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Security.HashRequired = true
+	cfg.Security.HashRequiredExempt = []string{"download.docker.com", "https://ppa.launchpadcontent.net/deadsnakes/"}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "security.hash_required:") {
+		t.Errorf("Validate() error = %v, want hash_required to require enforce", err)
+	}
+
+	cfg.Security.VerifyUpstreamSignatures = VerifyEnforce
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	cfg.Security.HashRequiredExempt = []string{"", "example.com/a b"}
+	err = cfg.Validate()
+	if err == nil || strings.Count(err.Error(), "security.hash_required_exempt") != 2 {
+		t.Errorf("Validate() error = %v, want two hash_required_exempt errors", err)
+	}
+}
+
+func TestValidate_MirrorPolicy(t *testing.T) {
+	cfg := DefaultConfig()
+	if !cfg.Proxy.DeniesPrivate() {
+		t.Error("DeniesPrivate() = false by default, want true")
+	}
+	cfg.Proxy.AllowedCIDRs = []string{"10.20.0.0/16", "fd12::/16"}
+	cfg.Proxy.AllowedPorts = []int{8443}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	cfg.Proxy.AllowedCIDRs = []string{"10.20.0.1"}
+	cfg.Proxy.AllowedPorts = []int{0}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"proxy.allowed_cidrs[0]", "proxy.allowed_ports[0]"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q should mention %s", err, field)
+		}
+	}
+}
+
+func TestValidate_Control(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Control = ControlConfig{Socket: "/run/debswarm/control.sock", AdminGroups: []string{"sudo"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	cfg.Control = ControlConfig{Socket: "control.sock", AdminGroups: []string{" "}}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"control.socket", "control.admin_groups[0]"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q should mention %s", err, field)
+		}
+	}
+}
+
+func TestGenericConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if got := cfg.Generic.EnabledAdapters(); got != nil {
+		t.Errorf("EnabledAdapters() = %v with generic mode off", got)
+	}
+	cfg.Generic.Enabled = true
+	if got := cfg.Generic.EnabledAdapters(); !slices.Equal(got, GenericAdapters) {
+		t.Errorf("EnabledAdapters() = %v, want all", got)
+	}
+
+	cfg.Generic.Adapters = []string{"rpm", "deb"}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "generic.adapters[1]") {
+		t.Errorf("Validate() = %v, want an error for generic.adapters[1]", err)
+	}
+}
+
+func TestScanConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Security.Scan.Enabled() || cfg.Security.Scan.TimeoutDuration() != 2*time.Minute {
+		t.Errorf("defaults: enabled %v, timeout %v", cfg.Security.Scan.Enabled(), cfg.Security.Scan.TimeoutDuration())
+	}
+
+	cfg.Security.Scan = ScanConfig{Clamd: "/run/clamav/clamd.ctl", Timeout: "30s"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if !cfg.Security.Scan.Enabled() || cfg.Security.Scan.TimeoutDuration() != 30*time.Second {
+		t.Errorf("parsed = %+v", cfg.Security.Scan)
+	}
+
+	cfg.Security.Scan = ScanConfig{Clamd: "127.0.0.1:3310", Command: []string{"clamdscan", "-"}, Timeout: "soon"}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"security.scan:", "security.scan.timeout"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q should mention %s", err, field)
+		}
+	}
+}
+
+func TestValidate_DHTModeAndBudgets(t *testing.T) {
+	cfg := DefaultConfig()
+	if got := cfg.DHT.GetMode(); got != DHTModeAuto {
+		t.Errorf("GetMode() = %q, want %q", got, DHTModeAuto)
+	}
+	cfg.DHT.Mode = DHTModeClient
+	cfg.DHT.ProvideBudget = 60
+	cfg.DHT.LookupBudget = 600
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	cfg.DHT.Mode = "lite"
+	cfg.DHT.ProvideBudget = -1
+	cfg.DHT.LookupBudget = -1
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors for DHT mode and budgets")
+	}
+	for _, field := range []string{"dht.mode", "dht.provide_budget", "dht.lookup_budget"} {
+		if !contains(err.Error(), field) {
+			t.Errorf("Error should mention %s, got: %s", field, err.Error())
+		}
+	}
+}
+
+func TestCacheConfig_DiskPressure(t *testing.T) {
+	tests := []struct {
+		interval string
+		expected time.Duration
+	}{
+		{"", time.Minute},
+		{"bogus", time.Minute},
+		{"0s", 0},
+		{"30s", 30 * time.Second},
+	}
+	for _, tt := range tests {
+		cfg := &CacheConfig{DiskPressureInterval: tt.interval}
+		if got := cfg.DiskPressureIntervalDuration(); got != tt.expected {
+			t.Errorf("DiskPressureIntervalDuration(%q) = %v, want %v", tt.interval, got, tt.expected)
+		}
+	}
+
+	if got := (&CacheConfig{}).DiskPressureHeadroomBytes(); got != 256*1024*1024 {
+		t.Errorf("default headroom = %d, want 256MB", got)
+	}
+	if got := (&CacheConfig{DiskPressureHeadroom: "1GiB"}).DiskPressureHeadroomBytes(); got != 1024*1024*1024 {
+		t.Errorf("headroom = %d, want 1GB", got)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Cache.DiskPressureInterval = "-1m"
+	cfg.Cache.DiskPressureHeadroom = "lots"
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors for disk pressure settings")
+	}
+	for _, field := range []string{"cache.disk_pressure_interval", "cache.disk_pressure_headroom"} {
+		if !contains(err.Error(), field) {
+			t.Errorf("Error should mention %s, got: %s", field, err.Error())
+		}
+	}
+}
+
+func TestCacheConfig_MaxSizePercent(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Cache.MaxSize = "20%"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if limit := cfg.Cache.MaxSizeLimit(); limit.DiskPercent != 20 {
+		t.Errorf("MaxSizeLimit() = %+v, want 20%% of the disk", limit)
+	}
+
+	for _, bad := range []string{"0%", "150%"} {
+		cfg.Cache.MaxSize = bad
+		if err := cfg.Validate(); err == nil || !contains(err.Error(), "cache.max_size") {
+			t.Errorf("max_size %q: Validate() = %v, want a cache.max_size error", bad, err)
+		}
+	}
+}
+
+func TestChaosConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Chaos.Enabled() {
+		t.Error("chaos enabled by default")
+	}
+	cfg.Chaos = ChaosConfig{DropStreamPercent: 150, DHTDelay: "soon", CorruptOneIn: -1}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"drop_stream_percent", "dht_delay", "corrupt_one_in"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q should mention %s", err, field)
+		}
+	}
+
+	cfg.Chaos = ChaosConfig{DHTDelay: "2s"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if !cfg.Chaos.Enabled() || cfg.Chaos.DHTDelayDuration() != 2*time.Second {
+		t.Errorf("chaos = %+v", cfg.Chaos)
+	}
+}
+
+func TestReplicationConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if r := cfg.Replication; r.Enabled() || r.GetConcurrency() != 2 || r.CatchUpDuration() != 24*time.Hour || r.MaxSizeBytes() != 0 {
+		t.Errorf("defaults = %+v", r)
+	}
+
+	partner := "/ip4/192.0.2.10/tcp/4001/p2p/12D3KooWGcoaGN51tdR3AriPCidsVUY4qSBgKxJjLDkCHSTLS31s"
+	cfg.Replication = ReplicationConfig{Partners: []string{partner}, MaxSize: "500MiB", CatchUp: "0"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "network.role") {
+		t.Errorf("replication on a full node: error = %v", err)
+	}
+	cfg.Network.Role = RoleSeed
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	r := cfg.Replication
+	if addrs := r.PartnerAddrs(); len(addrs) != 1 || len(addrs[0].Addrs) != 1 {
+		t.Errorf("partner addrs = %v", addrs)
+	}
+	if r.MaxSizeBytes() != 500*1024*1024 || r.CatchUpDuration() != 0 {
+		t.Errorf("parsed = %+v", r)
+	}
+
+	cfg.Replication.Partners = []string{"/ip4/192.0.2.10/tcp/4001"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "replication.partners[0]") {
+		t.Errorf("partner without peer ID: error = %v", err)
+	}
+}
+
+func TestMetricsPerPeerConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if got := cfg.Metrics.GetPerPeer(); got != "top" {
+		t.Errorf("default per_peer = %q, want top", got)
+	}
+	cfg.Metrics.PerPeer = "hash"
+	cfg.Metrics.PeerBuckets = 32
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	cfg.Metrics = MetricsConfig{Port: 9978, PerPeer: "all", TopPeers: 1000, PeerBuckets: -1}
+	err := cfg.Validate()
+	for _, field := range []string{"per_peer", "top_peers", "peer_buckets"} {
+		if err == nil || !strings.Contains(err.Error(), "metrics."+field) {
+			t.Errorf("Validate() = %v, want a metrics.%s error", err, field)
+		}
+	}
+}
+
+func TestClientsConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Clients = []ClientConfig{{Name: "lab", CIDRs: []string{"10.1.0.0/16", "fd00::/64"}, MaxRate: "5MiB/s", Policy: "cache-only"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	nets, err := cfg.Clients[0].ParsedCIDRs()
+	if err != nil || len(nets) != 2 {
+		t.Errorf("ParsedCIDRs() = %v, %v", nets, err)
+	}
+	if got := cfg.Clients[0].MaxRateBytes(); got != 5<<20 {
+		t.Errorf("MaxRateBytes() = %d", got)
+	}
+
+	cfg.Clients = []ClientConfig{
+		{Name: "lab", CIDRs: []string{"10.1.0.0/16"}},
+		{Name: "lab", CIDRs: []string{"10.2.0.0"}, MaxRate: "fast", Policy: "peers"},
+	}
+	err = cfg.Validate()
+	for _, field := range []string{"clients[1].name", "clients[1].cidrs[0]", "clients[1].max_rate", "clients[1].policy"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Validate() = %v, want a %s error", err, field)
+		}
+	}
+}
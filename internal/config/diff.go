@@ -0,0 +1,121 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// reloadableKeys are the settings a running daemon applies on SIGHUP. Any
+// other change takes effect only after a restart.
+var reloadableKeys = map[string]bool{
+	"transfer.max_upload_rate":   true,
+	"transfer.max_download_rate": true,
+	"proxy.allowed_hosts":        true,
+	"proxy.trust_known_repos":    true,
+	"proxy.allowed_cidrs":        true,
+	"proxy.allowed_ports":        true,
+	"proxy.deny_private":         true,
+	"clients":                    true,
+	"cache.io_throttle.max_rate": true,
+	"cache.io_throttle.max_iops": true,
+}
+
+// Change is one setting that differs between two configurations. Old or New
+// is empty when the key is set on one side only.
+type Change struct {
+	Key        string `json:"key"`
+	Old        string `json:"old"`
+	New        string `json:"new"`
+	Reloadable bool   `json:"reloadable"` // applied by SIGHUP; otherwise needs a restart
+}
+
+// Diff lists the settings that differ between old and new, sorted by key.
+// Keys are dotted TOML paths ("cache.max_size"); tables that are not arrays
+// are compared key by key, everything else by value.
+func Diff(old, new *Config) ([]Change, error) {
+	a, err := flatten(old)
+	if err != nil {
+		return nil, err
+	}
+	b, err := flatten(new)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for key, av := range a {
+		if bv, ok := b[key]; !ok || bv != av {
+			changes = append(changes, Change{Key: key, Old: av, New: b[key], Reloadable: reloadableKeys[key]})
+		}
+	}
+	for key, bv := range b {
+		if _, ok := a[key]; !ok {
+			changes = append(changes, Change{Key: key, New: bv, Reloadable: reloadableKeys[key]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
+
+// Redacted returns a copy of c with secrets replaced by a short fingerprint,
+// so it can be shown or sent over the local API while two redacted copies
+// still compare unequal when the secret differs.
+func (c *Config) Redacted() *Config {
+	cp := *c
+	if cp.Privacy.PSK != "" {
+		cp.Privacy.PSK = fingerprint(cp.Privacy.PSK)
+	}
+	cp.Mirror = c.Mirror.redacted()
+	return &cp
+}
+
+// fingerprint replaces a secret by a short hash of it
+func fingerprint(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return "redacted:" + hex.EncodeToString(sum[:4])
+}
+
+// flatten renders cfg as dotted keys mapped to display values.
+func flatten(cfg *Config) (map[string]string, error) {
+	data, err := toml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	var tree map[string]any
+	if err := toml.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	out := make(map[string]string)
+	flattenInto(out, "", tree)
+	return out, nil
+}
+
+func flattenInto(out map[string]string, prefix string, tree map[string]any) {
+	for k, v := range tree {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if sub, ok := v.(map[string]any); ok {
+			flattenInto(out, key, sub)
+			continue
+		}
+		out[key] = displayValue(v)
+	}
+}
+
+// displayValue formats a decoded TOML value the way it would be written.
+func displayValue(v any) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	if data, err := json.Marshal(v); err == nil {
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
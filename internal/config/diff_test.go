@@ -0,0 +1,57 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	active := DefaultConfig()
+	disk := DefaultConfig()
+	disk.Transfer.MaxUploadRate = "10MB/s"
+	disk.Network.ListenPort = 4002
+	disk.Scheduler.Windows = []ScheduleWindow{{Days: []string{"weekday"}, StartTime: "22:00", EndTime: "06:00"}}
+
+	changes, err := Diff(active, disk)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	got := make(map[string]Change)
+	for _, c := range changes {
+		got[c.Key] = c
+	}
+	if len(got) != 3 {
+		t.Fatalf("changes = %+v, want 3", changes)
+	}
+	if c := got["transfer.max_upload_rate"]; c.Old != `"0"` || c.New != `"10MB/s"` || !c.Reloadable {
+		t.Errorf("max_upload_rate change = %+v", c)
+	}
+	if c := got["network.listen_port"]; c.Old != "4001" || c.New != "4002" || c.Reloadable {
+		t.Errorf("listen_port change = %+v", c)
+	}
+	if c := got["scheduler.windows"]; c.Old != "[]" || !strings.Contains(c.New, "weekday") {
+		t.Errorf("scheduler.windows change = %+v", c)
+	}
+
+	if changes, _ := Diff(active, DefaultConfig()); len(changes) != 0 {
+		t.Errorf("identical configs differ: %+v", changes)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Privacy.PSK = "0123456789abcdef"
+	r := cfg.Redacted()
+	if strings.Contains(r.Privacy.PSK, "0123456789abcdef") || !strings.HasPrefix(r.Privacy.PSK, "redacted:") {
+		t.Errorf("PSK not redacted: %q", r.Privacy.PSK)
+	}
+	if cfg.Privacy.PSK != "0123456789abcdef" {
+		t.Error("Redacted modified the original")
+	}
+
+	other := DefaultConfig()
+	other.Privacy.PSK = "fedcba9876543210"
+	if changes, _ := Diff(r, other.Redacted()); len(changes) != 1 || changes[0].Key != "privacy.psk" {
+		t.Errorf("changed PSK not detected through redaction: %+v", changes)
+	}
+}
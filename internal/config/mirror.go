@@ -0,0 +1,183 @@
+package config
+
+import (
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	"github.com/debswarm/debswarm/internal/secret"
+)
+
+// DefaultUserAgent is the User-Agent of mirror requests unless
+// [mirror] user_agent sets another.
+const DefaultUserAgent = "debswarm/1.0"
+
+// MirrorConfig configures requests to HTTP mirrors.
+type MirrorConfig struct {
+	// UserAgent is sent with every mirror request (default "debswarm/1.0").
+	UserAgent string `toml:"user_agent,omitempty"`
+
+	// Origins add headers and credentials to requests for particular
+	// hosts, from [[mirror.origins]].
+	Origins []MirrorOriginConfig `toml:"origins,omitempty"`
+}
+
+// MirrorOriginConfig adds headers to the requests for a set of hosts, as a
+// corporate mirror frontend may require for authentication or traffic
+// classification. Header values, Username and Password are templates that
+// may refer to secrets kept out of the config file: ${env:NAME},
+// ${file:PATH} or ${keyring:NAME}. The headers are not carried over when a
+// redirect leaves the hosts.
+type MirrorOriginConfig struct {
+	Hosts   []string          `toml:"hosts"`             // Hostnames; subdomains match too
+	Headers map[string]string `toml:"headers,omitempty"` // Header name to value template
+
+	// Username and Password send HTTP basic authentication.
+	Username string `toml:"username,omitempty"`
+	Password string `toml:"password,omitempty"`
+}
+
+// GetUserAgent returns the User-Agent of mirror requests
+func (c *MirrorConfig) GetUserAgent() string {
+	if c.UserAgent == "" {
+		return DefaultUserAgent
+	}
+	return c.UserAgent
+}
+
+// headerNamePattern matches an HTTP header name (an RFC 9110 token)
+var headerNamePattern = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+// reservedHeaders are set by the fetcher or the HTTP client and may not be
+// configured
+var reservedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Range":             true,
+}
+
+// validateMirror checks the [mirror] section. Secrets are not read here;
+// the daemon reads them when it starts.
+func (c *Config) validateMirror() ValidationErrors {
+	var errs ValidationErrors
+	if strings.ContainsAny(c.Mirror.UserAgent, "\r\n") {
+		errs = append(errs, ValidationError{
+			Field:   "mirror.user_agent",
+			Message: "must be a single line",
+		})
+	}
+	for i, o := range c.Mirror.Origins {
+		field := fmt.Sprintf("mirror.origins[%d]", i)
+		if len(o.Hosts) == 0 {
+			errs = append(errs, ValidationError{
+				Field:   field + ".hosts",
+				Message: "at least one host is required",
+			})
+		}
+		for j, host := range o.Hosts {
+			if host == "" || strings.ContainsAny(host, "/: ") {
+				errs = append(errs, ValidationError{
+					Field:   fmt.Sprintf("%s.hosts[%d]", field, j),
+					Message: fmt.Sprintf("invalid host %q (use a hostname without scheme, port or path)", host),
+				})
+			}
+		}
+		for name, value := range o.Headers {
+			hfield := fmt.Sprintf("%s.headers.%s", field, name)
+			switch {
+			case !headerNamePattern.MatchString(name):
+				errs = append(errs, ValidationError{Field: hfield, Message: "invalid header name"})
+			case reservedHeaders[textproto.CanonicalMIMEHeaderKey(name)]:
+				errs = append(errs, ValidationError{Field: hfield, Message: "header is set by debswarm and cannot be configured"})
+			case strings.ContainsAny(value, "\r\n"):
+				errs = append(errs, ValidationError{Field: hfield, Message: "value must be a single line"})
+			default:
+				if err := secret.Check(value); err != nil {
+					errs = append(errs, ValidationError{Field: hfield, Message: err.Error()})
+				}
+			}
+		}
+		if o.Password != "" && o.Username == "" {
+			errs = append(errs, ValidationError{
+				Field:   field + ".username",
+				Message: "a username is required with a password",
+			})
+		}
+		for key, value := range map[string]string{"username": o.Username, "password": o.Password} {
+			if err := secret.Check(value); err != nil {
+				errs = append(errs, ValidationError{Field: field + "." + key, Message: err.Error()})
+			}
+		}
+	}
+	return errs
+}
+
+// sensitiveHeader reports whether a header's value is likely a credential
+func sensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	if name == "cookie" {
+		return true
+	}
+	for _, s := range []string{"auth", "token", "key", "secret", "pass", "session"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactTemplate fingerprints a value that holds a literal secret. A value
+// that refers to a secret kept elsewhere is left as is: it only names it.
+func redactTemplate(value string) string {
+	if value == "" {
+		return value
+	}
+	if refs, err := secret.Refs(value); err == nil && len(refs) > 0 {
+		return value
+	}
+	return fingerprint(value)
+}
+
+// hasLiteralSecrets reports whether credentials are written into the config
+// file rather than referred to
+func (c *MirrorConfig) hasLiteralSecrets() bool {
+	for _, o := range c.Origins {
+		if redactTemplate(o.Password) != o.Password {
+			return true
+		}
+		for name, value := range o.Headers {
+			if sensitiveHeader(name) && redactTemplate(value) != value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// redacted returns a copy of c with literal credentials fingerprinted
+func (c *MirrorConfig) redacted() MirrorConfig {
+	cp := *c
+	if len(c.Origins) == 0 {
+		return cp
+	}
+	origins := make([]MirrorOriginConfig, len(c.Origins))
+	for i, o := range c.Origins {
+		if o.Headers != nil {
+			headers := make(map[string]string, len(o.Headers))
+			for name, value := range o.Headers {
+				if sensitiveHeader(name) {
+					value = redactTemplate(value)
+				}
+				headers[name] = value
+			}
+			o.Headers = headers
+		}
+		o.Password = redactTemplate(o.Password)
+		origins[i] = o
+	}
+	cp.Origins = origins
+	return cp
+}
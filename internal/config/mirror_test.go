@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMirror_LoadValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	contents := `[mirror]
+user_agent = "corp-apt/2.0"
+
+[[mirror.origins]]
+hosts = ["mirror.corp.example"]
+username = "apt"
+password = "${file:/run/credentials/debswarm.service/mirror}"
+[mirror.origins.headers]
+X-Traffic-Class = "bulk"
+X-Auth-Token = "Bearer ${env:MIRROR_TOKEN}"
+`
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if cfg.Mirror.GetUserAgent() != "corp-apt/2.0" || len(cfg.Mirror.Origins) != 1 || cfg.Mirror.Origins[0].Headers["X-Traffic-Class"] != "bulk" {
+		t.Errorf("mirror = %+v", cfg.Mirror)
+	}
+	if ua := DefaultConfig().Mirror.GetUserAgent(); ua != DefaultUserAgent {
+		t.Errorf("default User-Agent = %q", ua)
+	}
+
+	cfg.Mirror.UserAgent = "two\nlines"
+	cfg.Mirror.Origins = append(cfg.Mirror.Origins,
+		MirrorOriginConfig{Hosts: []string{"https://mirror.example"}, Password: "x"},
+		MirrorOriginConfig{Headers: map[string]string{"Host": "other", "Bad Name": "x", "X-Key": "${vault:key}"}})
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{"mirror.user_agent", "mirror.origins[1].hosts[0]", "mirror.origins[1].username",
+		"mirror.origins[2].hosts", "headers.Host", "headers.Bad Name", "headers.X-Key"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should mention %s", err, want)
+		}
+	}
+}
+
+func TestMirror_Redacted(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Mirror.Origins = []MirrorOriginConfig{{
+		Hosts:    []string{"mirror.corp.example"},
+		Username: "apt",
+		Password: "hunter2",
+		Headers: map[string]string{
+			"Authorization":   "Bearer ${env:MIRROR_TOKEN}",
+			"X-Api-Key":       "literal-key",
+			"X-Traffic-Class": "bulk",
+		},
+	}}
+	r := cfg.Redacted().Mirror.Origins[0]
+	if !strings.HasPrefix(r.Password, "redacted:") || !strings.HasPrefix(r.Headers["X-Api-Key"], "redacted:") {
+		t.Errorf("literal secrets not redacted: %+v", r)
+	}
+	// References name where a secret lives and other headers are not secret
+	if r.Headers["Authorization"] != "Bearer ${env:MIRROR_TOKEN}" || r.Headers["X-Traffic-Class"] != "bulk" || r.Username != "apt" {
+		t.Errorf("redacted too much: %+v", r)
+	}
+	if o := cfg.Mirror.Origins[0]; o.Password != "hunter2" || o.Headers["X-Api-Key"] != "literal-key" {
+		t.Error("Redacted modified the original")
+	}
+	if !cfg.Mirror.hasLiteralSecrets() {
+		t.Error("literal password not detected")
+	}
+	cfg.Mirror.Origins[0].Password = "${keyring:corp-mirror}"
+	delete(cfg.Mirror.Origins[0].Headers, "X-Api-Key")
+	if cfg.Mirror.hasLiteralSecrets() {
+		t.Error("references reported as literal secrets")
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/prefetch"
+	"github.com/debswarm/debswarm/internal/units"
+)
+
+// PrefetchConfig keeps package groups ("tasks") cached, so machines can be
+// provisioned from the swarm without the mirror. Groups may also be added
+// at runtime with "debswarm prefetch group add".
+type PrefetchConfig struct {
+	// Interval is how often groups are resolved against the index and
+	// fetched (default "6h", "0" = only on demand)
+	Interval string `toml:"interval,omitempty"`
+	// Architectures groups are resolved for (default: the host's)
+	Architectures []string `toml:"architectures,omitempty"`
+	// Dependencies includes each package's Depends and Pre-Depends,
+	// recursively (default true)
+	Dependencies *bool `toml:"dependencies,omitempty"`
+	// Pin protects group packages from eviction (default true)
+	Pin         *bool `toml:"pin,omitempty"`
+	Concurrency int   `toml:"concurrency,omitempty"` // packages fetched at once, default 2
+
+	Groups []PrefetchGroupConfig `toml:"groups,omitempty"`
+}
+
+// PrefetchGroupConfig is a package group, from [[prefetch.groups]].
+type PrefetchGroupConfig struct {
+	Name     string   `toml:"name"`
+	Packages []string `toml:"packages"`
+}
+
+// IntervalDuration returns how often groups are refreshed (0 = only on
+// demand). Returns 6 hours default if not configured or invalid.
+func (c *PrefetchConfig) IntervalDuration() time.Duration {
+	if c.Interval == "" {
+		return 6 * time.Hour
+	}
+	d, err := units.ParseDuration(c.Interval)
+	if err != nil || d < 0 {
+		return 6 * time.Hour
+	}
+	return d
+}
+
+// GetArchitectures returns the architectures groups are resolved for.
+func (c *PrefetchConfig) GetArchitectures() []string {
+	if len(c.Architectures) == 0 {
+		return []string{prefetch.HostArchitecture()}
+	}
+	return c.Architectures
+}
+
+// DependenciesEnabled reports whether dependencies are resolved.
+func (c *PrefetchConfig) DependenciesEnabled() bool {
+	return c.Dependencies == nil || *c.Dependencies
+}
+
+// PinEnabled reports whether group packages are pinned.
+func (c *PrefetchConfig) PinEnabled() bool {
+	return c.Pin == nil || *c.Pin
+}
+
+// GetConcurrency returns how many packages are fetched at once.
+// Returns 2 default if not configured.
+func (c *PrefetchConfig) GetConcurrency() int {
+	if c.Concurrency <= 0 {
+		return 2
+	}
+	return c.Concurrency
+}
+
+// GetGroups returns the configured groups.
+func (c *PrefetchConfig) GetGroups() []prefetch.Group {
+	groups := make([]prefetch.Group, 0, len(c.Groups))
+	for _, g := range c.Groups {
+		groups = append(groups, prefetch.Group{Name: g.Name, Packages: g.Packages})
+	}
+	return groups
+}
+
+// validatePrefetch checks the [prefetch] section.
+func (c *Config) validatePrefetch() ValidationErrors {
+	var errs ValidationErrors
+	p := c.Prefetch
+	if p.Interval != "" {
+		if d, err := units.ParseDuration(p.Interval); err != nil || d < 0 {
+			errs = append(errs, ValidationError{Field: "prefetch.interval", Message: fmt.Sprintf("invalid duration %q", p.Interval)})
+		}
+	}
+	for i, arch := range p.Architectures {
+		if arch == "" || arch == "all" {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("prefetch.architectures[%d]", i),
+				Message: fmt.Sprintf("invalid architecture %q", arch),
+			})
+		}
+	}
+	if p.Concurrency < 0 {
+		errs = append(errs, ValidationError{Field: "prefetch.concurrency", Message: "must be >= 0"})
+	}
+	seen := make(map[string]bool)
+	for i, g := range p.GetGroups() {
+		field := fmt.Sprintf("prefetch.groups[%d]", i)
+		if err := g.Validate(); err != nil {
+			errs = append(errs, ValidationError{Field: field, Message: err.Error()})
+		}
+		if seen[g.Name] {
+			errs = append(errs, ValidationError{Field: field + ".name", Message: fmt.Sprintf("duplicate group %q", g.Name)})
+		}
+		seen[g.Name] = true
+	}
+	return errs
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/prefetch"
+)
+
+func TestPrefetch_LoadValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	contents := `[prefetch]
+interval = "1h"
+architectures = ["amd64", "arm64"]
+dependencies = false
+
+[[prefetch.groups]]
+name = "base-dev"
+packages = ["build-essential", "git", "libc6-dev:i386"]
+`
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	p := cfg.Prefetch
+	if p.IntervalDuration() != time.Hour || p.DependenciesEnabled() || !p.PinEnabled() || p.GetConcurrency() != 2 {
+		t.Errorf("prefetch = %+v", p)
+	}
+	groups := p.GetGroups()
+	if len(groups) != 1 || groups[0].Name != "base-dev" || len(groups[0].Packages) != 3 {
+		t.Errorf("groups = %+v", groups)
+	}
+
+	def := DefaultConfig().Prefetch
+	if def.IntervalDuration() != 6*time.Hour || !def.DependenciesEnabled() || !slices.Equal(def.GetArchitectures(), []string{prefetch.HostArchitecture()}) {
+		t.Errorf("default prefetch = %+v", def)
+	}
+
+	cfg.Prefetch.Interval = "often"
+	cfg.Prefetch.Architectures = []string{"all"}
+	cfg.Prefetch.Concurrency = -1
+	cfg.Prefetch.Groups = append(cfg.Prefetch.Groups,
+		PrefetchGroupConfig{Name: "base-dev", Packages: []string{"make"}},
+		PrefetchGroupConfig{Name: "empty"})
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{"prefetch.interval", "prefetch.architectures[0]", "prefetch.concurrency",
+		"prefetch.groups[1].name", "prefetch.groups[2]"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should mention %s", err, want)
+		}
+	}
+}
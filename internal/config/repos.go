@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// ReposDir is the directory, beside the config file, holding one
+// <name>.toml file per repository added with 'debswarm repo add'.
+const ReposDir = "repos.d"
+
+// RepoConfig configures a third-party repository (a PPA, or a vendor
+// repository such as Docker's or PostgreSQL's). Its hosts are allowed through
+// the proxy, and its settings apply to every package and index fetched from
+// them or their subdomains.
+type RepoConfig struct {
+	Name  string   `toml:"name"`  // Identifies the repository in logs and 'debswarm repo'
+	Hosts []string `toml:"hosts"` // Repository hostnames, without scheme or path
+
+	// Share fetches the repository's packages from peers and serves them to
+	// peers. Defaults to true; false keeps them between this node and the
+	// mirror, e.g. for a licensed vendor repository.
+	Share *bool `toml:"share,omitempty"`
+
+	// Keyring is a key file or directory its Release files are verified
+	// against, in place of the APT keyrings: like APT's signed-by, keys for
+	// one repository cannot vouch for another.
+	Keyring string `toml:"keyring,omitempty"`
+
+	// MaxDownloadRate limits downloads from the repository's hosts, shared
+	// by all transfers from them (e.g. "2MB/s"). Empty or "0" is unlimited.
+	MaxDownloadRate string `toml:"max_download_rate,omitempty"`
+
+	// UploadPriority serves its packages to peers ahead of others, as for
+	// security updates.
+	UploadPriority bool `toml:"upload_priority,omitempty"`
+
+	// File is the repos.d file the repository was read from; empty when it
+	// is configured in the main config file.
+	File string `toml:"-"`
+}
+
+// IsShared reports whether the repository's packages are shared over P2P.
+// Returns true if not configured.
+func (r *RepoConfig) IsShared() bool {
+	return r.Share == nil || *r.Share
+}
+
+// MaxDownloadRateBytes returns the download rate limit in bytes per second,
+// 0 for unlimited.
+func (r *RepoConfig) MaxDownloadRateBytes() int64 {
+	rate, err := ParseRate(r.MaxDownloadRate)
+	if err != nil {
+		return 0
+	}
+	return rate
+}
+
+// RepoHosts returns the hosts of every configured repository.
+func (c *Config) RepoHosts() []string {
+	var hosts []string
+	for _, r := range c.Repos {
+		hosts = append(hosts, r.Hosts...)
+	}
+	return hosts
+}
+
+// ReposDirFor returns the repos.d directory for the config file at path.
+func ReposDirFor(path string) string {
+	return filepath.Join(filepath.Dir(path), ReposDir)
+}
+
+// loadReposDir appends the repositories in the repos.d directory beside the
+// config file at path, in file name order. A file without a name takes it
+// from the file name. A missing directory is not an error.
+func (c *Config) loadReposDir(path string) error {
+	files, err := filepath.Glob(filepath.Join(ReposDirFor(path), "*.toml"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var repo RepoConfig
+		if err := toml.Unmarshal(data, &repo); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if repo.Name == "" {
+			repo.Name = strings.TrimSuffix(filepath.Base(file), ".toml")
+		}
+		repo.File = file
+		c.Repos = append(c.Repos, repo)
+	}
+	return nil
+}
+
+// SaveRepo writes repo to its own file in dir, replacing any earlier file of
+// the same name, and returns the file's path.
+func SaveRepo(dir string, repo RepoConfig) (string, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", err
+	}
+	data, err := toml.Marshal(repo)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, repo.Name+".toml")
+	return path, os.WriteFile(path, data, 0600)
+}
+
+// validateRepos checks the [[repos]] entries, including those from repos.d
+func (c *Config) validateRepos() ValidationErrors {
+	var errs ValidationErrors
+	names := make(map[string]bool)
+
+	for i, r := range c.Repos {
+		field := fmt.Sprintf("repos[%d]", i)
+		if r.File != "" {
+			field = r.File
+		}
+		if !swarmNamePattern.MatchString(r.Name) {
+			errs = append(errs, ValidationError{
+				Field:   field + ".name",
+				Message: fmt.Sprintf("invalid name %q (lowercase letters, digits, '-' and '_')", r.Name),
+			})
+		} else if names[r.Name] {
+			errs = append(errs, ValidationError{
+				Field:   field + ".name",
+				Message: fmt.Sprintf("duplicate repository name %q", r.Name),
+			})
+		}
+		names[r.Name] = true
+
+		if len(r.Hosts) == 0 {
+			errs = append(errs, ValidationError{
+				Field:   field + ".hosts",
+				Message: "at least one host is required",
+			})
+		}
+		for j, host := range r.Hosts {
+			if host == "" || strings.ContainsAny(host, "/: ") {
+				errs = append(errs, ValidationError{
+					Field:   fmt.Sprintf("%s.hosts[%d]", field, j),
+					Message: fmt.Sprintf("invalid host %q (use a hostname without scheme, port or path)", host),
+				})
+			}
+		}
+
+		if r.Keyring != "" {
+			if _, err := os.Stat(r.Keyring); err != nil {
+				errs = append(errs, ValidationError{
+					Field:   field + ".keyring",
+					Message: fmt.Sprintf("keyring path %q is not accessible: %v", r.Keyring, err),
+				})
+			}
+		}
+		if _, err := ParseRate(r.MaxDownloadRate); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   field + ".max_download_rate",
+				Message: err.Error(),
+			})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRepos_LoadSaveValidate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	contents := "[[repos]]\nname = \"pgdg\"\nhosts = [\"apt.postgresql.org\"]\nupload_priority = true\n"
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(ReposDirFor(path), 0750); err != nil {
+		t.Fatal(err)
+	}
+	// A file without a name is named after the file
+	dropIn := "hosts = [\"download.docker.com\"]\nshare = false\nmax_download_rate = \"2MB/s\"\n"
+	if err := os.WriteFile(filepath.Join(ReposDirFor(path), "docker.toml"), []byte(dropIn), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if len(cfg.Repos) != 2 {
+		t.Fatalf("repos = %+v", cfg.Repos)
+	}
+	pgdg, docker := cfg.Repos[0], cfg.Repos[1]
+	if pgdg.File != "" || !pgdg.IsShared() || !pgdg.UploadPriority || pgdg.MaxDownloadRateBytes() != 0 {
+		t.Errorf("pgdg = %+v", pgdg)
+	}
+	if docker.Name != "docker" || docker.File == "" || docker.IsShared() || docker.MaxDownloadRateBytes() != 2_000_000 {
+		t.Errorf("docker = %+v", docker)
+	}
+	if hosts := cfg.RepoHosts(); len(hosts) != 2 || hosts[1] != "download.docker.com" {
+		t.Errorf("RepoHosts() = %v", hosts)
+	}
+
+	// Save keeps repos.d repositories out of the config file
+	if err := cfg.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if cfg, err = Load(path); err != nil || len(cfg.Repos) != 2 {
+		t.Fatalf("reloaded repos = %+v, %v", cfg.Repos, err)
+	}
+
+	cfg.Repos = append(cfg.Repos,
+		RepoConfig{Name: "docker", Hosts: []string{"https://download.docker.com"}},
+		RepoConfig{Name: "Bad Name", Keyring: filepath.Join(dir, "missing.gpg"), MaxDownloadRate: "fast"})
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{"duplicate repository name", "repos[2].hosts[0]", "repos[3].name", "repos[3].hosts", "repos[3].keyring", "repos[3].max_download_rate"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should mention %s", err, want)
+		}
+	}
+}
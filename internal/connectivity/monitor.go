@@ -40,7 +40,8 @@ func (m Mode) String() string {
 
 // Config holds connectivity monitor configuration
 type Config struct {
-	// Mode is the configured connectivity mode ("auto", "lan_only", "online_only")
+	// Mode is the configured connectivity mode ("auto", "lan_only",
+	// "online_only", "offline")
 	Mode string
 
 	// CheckInterval is how often to check connectivity when in auto mode
@@ -116,6 +117,10 @@ func NewMonitor(cfg *Config, logger *zap.Logger) *Monitor {
 		m.mode.Store(int32(ModeLANOnly))
 	case "online_only":
 		m.mode.Store(int32(ModeOnline))
+	case "offline":
+		// Mirrors are off limits but peers are not: the node works as if
+		// only the local network were reachable
+		m.mode.Store(int32(ModeLANOnly))
 	default: // "auto" or unset
 		m.mode.Store(int32(ModeOnline)) // Assume online until proven otherwise
 	}
@@ -128,6 +133,12 @@ func (m *Monitor) GetMode() Mode {
 	return Mode(m.mode.Load())
 }
 
+// MirrorsDisabled reports whether the configured mode is "offline", in
+// which no mirror may be contacted at all.
+func (m *Monitor) MirrorsDisabled() bool {
+	return m.configMode == "offline"
+}
+
 // Start starts the connectivity monitor
 // It runs periodic checks in the background when in auto mode
 func (m *Monitor) Start(ctx context.Context) {
@@ -160,6 +171,15 @@ func (m *Monitor) Start(ctx context.Context) {
 	}
 }
 
+// CheckNow re-checks connectivity at once instead of at the next interval,
+// as after a network change. It does nothing in a static mode.
+func (m *Monitor) CheckNow(ctx context.Context) {
+	if m.configMode != "auto" && m.configMode != "" {
+		return
+	}
+	m.checkAndUpdate(ctx)
+}
+
 // checkAndUpdate performs a connectivity check and updates the mode
 func (m *Monitor) checkAndUpdate(ctx context.Context) {
 	newMode := m.checkConnectivity(ctx)
@@ -60,6 +60,19 @@ func TestNewMonitorStaticModes(t *testing.T) {
 	if m.GetMode() != ModeOnline {
 		t.Errorf("expected ModeOnline for online_only config, got %v", m.GetMode())
 	}
+	if m.MirrorsDisabled() {
+		t.Error("online_only should not disable mirrors")
+	}
+
+	// Test offline mode: peers only, and static
+	m = NewMonitor(&Config{Mode: "offline"}, logger)
+	if m.GetMode() != ModeLANOnly || !m.MirrorsDisabled() {
+		t.Errorf("expected ModeLANOnly with mirrors disabled for offline config, got %v", m.GetMode())
+	}
+	m.CheckNow(context.Background())
+	if m.GetMode() != ModeLANOnly {
+		t.Errorf("offline mode should not be re-checked, got %v", m.GetMode())
+	}
 }
 
 func TestCheckConnectivityOnline(t *testing.T) {
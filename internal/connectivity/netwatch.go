@@ -0,0 +1,176 @@
+package connectivity
+
+import (
+	"context"
+	"net"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Change describes a network change seen by a Watcher
+type Change struct {
+	// Resumed is set when the system was suspended; Asleep is roughly for
+	// how long
+	Resumed bool
+	Asleep  time.Duration
+
+	// Added and Removed list the host addresses that appeared and
+	// disappeared
+	Added   []string
+	Removed []string
+}
+
+// WatcherConfig holds network change watcher configuration
+type WatcherConfig struct {
+	// PollInterval is how often addresses and the clock are checked.
+	// Route change notifications, where the platform has them, trigger a
+	// check at once.
+	PollInterval time.Duration
+
+	// Settle is how long to wait after a notification for further ones
+	// before checking, so one Wi-Fi switch is one change
+	Settle time.Duration
+
+	// OnChange is called, from the watcher's goroutine, for each change
+	OnChange func(Change)
+}
+
+// Watcher detects changes of the host's network: addresses appearing or
+// disappearing, as when switching Wi-Fi networks or replugging a cable, and
+// resumes from suspend. A resume is detected as the wall clock jumping
+// ahead of the monotonic clock, which stops while the system sleeps.
+type Watcher struct {
+	pollInterval time.Duration
+	settle       time.Duration
+	onChange     func(Change)
+	logger       *zap.Logger
+
+	// addrs lists the host's addresses; events, if not nil, signals
+	// platform route and address change notifications. Replaced in tests.
+	addrs  func() ([]string, error)
+	events func(ctx context.Context) <-chan struct{}
+}
+
+// NewWatcher creates a network change watcher
+func NewWatcher(cfg *WatcherConfig, logger *zap.Logger) *Watcher {
+	w := &Watcher{
+		pollInterval: cfg.PollInterval,
+		settle:       cfg.Settle,
+		onChange:     cfg.OnChange,
+		logger:       logger,
+		addrs:        hostAddrs,
+		events:       routeEvents(logger),
+	}
+	if w.pollInterval <= 0 {
+		w.pollInterval = 5 * time.Second
+	}
+	if w.settle <= 0 {
+		w.settle = time.Second
+	}
+	return w
+}
+
+// Start watches until ctx is done
+func (w *Watcher) Start(ctx context.Context) {
+	var events <-chan struct{}
+	if w.events != nil {
+		events = w.events(ctx)
+	}
+	last, _ := w.addrs()
+	lastTick := time.Now()
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-events:
+			// Let a burst of notifications settle
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(w.settle):
+			}
+		}
+
+		now := time.Now()
+		var change Change
+		wall, mono := now.Round(0).Sub(lastTick.Round(0)), now.Sub(lastTick)
+		if asleep := suspendedFor(wall, mono, w.pollInterval); asleep > 0 {
+			change.Resumed, change.Asleep = true, asleep
+		}
+		lastTick = now
+
+		current, err := w.addrs()
+		if err != nil {
+			w.logger.Debug("Failed to list network addresses", zap.Error(err))
+			current = last
+		}
+		change.Added, change.Removed = diffAddrs(last, current)
+		last = current
+
+		if !change.Resumed && len(change.Added) == 0 && len(change.Removed) == 0 {
+			continue
+		}
+		w.logger.Info("Network changed",
+			zap.Bool("resumed", change.Resumed),
+			zap.Duration("asleep", change.Asleep),
+			zap.Strings("added", change.Added),
+			zap.Strings("removed", change.Removed))
+		if w.onChange != nil {
+			w.onChange(change)
+		}
+	}
+}
+
+// suspendedFor returns how long the system slept, or 0, given the wall
+// clock and monotonic time elapsed between two checks. The wall clock keeps
+// running during suspend but the monotonic one does not, so their difference
+// is the time asleep. Jumps smaller than the poll interval, such as NTP
+// adjustments, are ignored.
+func suspendedFor(wall, mono, interval time.Duration) time.Duration {
+	if gap := wall - mono; gap > max(interval, 5*time.Second) {
+		return gap
+	}
+	return 0
+}
+
+// diffAddrs returns the addresses in current but not last, and in last but
+// not current. Both are sorted.
+func diffAddrs(last, current []string) (added, removed []string) {
+	for _, a := range current {
+		if !slices.Contains(last, a) {
+			added = append(added, a)
+		}
+	}
+	for _, a := range last {
+		if !slices.Contains(current, a) {
+			removed = append(removed, a)
+		}
+	}
+	return added, removed
+}
+
+// hostAddrs lists the host's global and private unicast addresses, sorted.
+// Loopback and link-local addresses are left out: they do not change when
+// the network does.
+func hostAddrs() ([]string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		result = append(result, ipnet.IP.String())
+	}
+	slices.Sort(result)
+	return result, nil
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package connectivity
+
+import (
+	"context"
+	"syscall"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// routeEvents subscribes to rtnetlink link, address and route changes, so
+// a network change is noticed within the settle time instead of at the next
+// poll.
+func routeEvents(logger *zap.Logger) func(ctx context.Context) <-chan struct{} {
+	return func(ctx context.Context) <-chan struct{} {
+		fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+		if err != nil {
+			logger.Debug("Route change notifications unavailable, polling only", zap.Error(err))
+			return nil
+		}
+		groups := uint32(unix.RTMGRP_LINK | unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV6_IFADDR |
+			unix.RTMGRP_IPV4_ROUTE | unix.RTMGRP_IPV6_ROUTE)
+		if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups}); err != nil {
+			_ = syscall.Close(fd)
+			logger.Debug("Route change notifications unavailable, polling only", zap.Error(err))
+			return nil
+		}
+		// A receive timeout lets the reader notice ctx being done
+		tv := syscall.Timeval{Sec: 1}
+		_ = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv)
+
+		events := make(chan struct{}, 1)
+		go func() {
+			defer func() { _ = syscall.Close(fd) }()
+			buf := make([]byte, 1<<16)
+			for ctx.Err() == nil {
+				n, _, err := syscall.Recvfrom(fd, buf, 0)
+				if err != nil || n == 0 {
+					continue
+				}
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+			}
+		}()
+		return events
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package connectivity
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// routeEvents is nil where route change notifications are not implemented;
+// the watcher then relies on polling.
+func routeEvents(*zap.Logger) func(ctx context.Context) <-chan struct{} {
+	return nil
+}
@@ -0,0 +1,92 @@
+package connectivity
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestSuspendedFor(t *testing.T) {
+	tests := []struct {
+		name       string
+		wall, mono time.Duration
+		want       time.Duration
+	}{
+		{"awake", 5 * time.Second, 5 * time.Second, 0},
+		{"suspended", 10*time.Minute + 5*time.Second, 5 * time.Second, 10 * time.Minute},
+		{"ntp step", 7 * time.Second, 5 * time.Second, 0},
+		{"clock set back", time.Second, 5 * time.Second, 0},
+	}
+	for _, tt := range tests {
+		if got := suspendedFor(tt.wall, tt.mono, 5*time.Second); got != tt.want {
+			t.Errorf("%s: suspendedFor = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiffAddrs(t *testing.T) {
+	added, removed := diffAddrs(
+		[]string{"10.0.0.5", "2001:db8::5"},
+		[]string{"192.168.1.20", "2001:db8::5"})
+	if !slices.Equal(added, []string{"192.168.1.20"}) || !slices.Equal(removed, []string{"10.0.0.5"}) {
+		t.Errorf("added %v, removed %v", added, removed)
+	}
+	if added, removed := diffAddrs([]string{"10.0.0.5"}, []string{"10.0.0.5"}); added != nil || removed != nil {
+		t.Errorf("unchanged: added %v, removed %v", added, removed)
+	}
+}
+
+func TestWatcher_AddressChange(t *testing.T) {
+	var mu sync.Mutex
+	addrs := []string{"10.0.0.5"}
+	changes := make(chan Change, 4)
+
+	w := NewWatcher(&WatcherConfig{
+		PollInterval: time.Hour,
+		Settle:       10 * time.Millisecond,
+		OnChange:     func(c Change) { changes <- c },
+	}, zap.NewNop())
+	w.addrs = func() ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(addrs), nil
+	}
+	events := make(chan struct{}, 1)
+	w.events = func(context.Context) <-chan struct{} { return events }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Start(ctx)
+
+	// A notification without an address change is not a change
+	events <- struct{}{}
+	select {
+	case c := <-changes:
+		t.Fatalf("unexpected change %+v", c)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	mu.Lock()
+	addrs = []string{"192.168.1.20"}
+	mu.Unlock()
+	events <- struct{}{}
+	select {
+	case c := <-changes:
+		if c.Resumed || !slices.Equal(c.Added, []string{"192.168.1.20"}) || !slices.Equal(c.Removed, []string{"10.0.0.5"}) {
+			t.Errorf("change = %+v", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported")
+	}
+}
+
+func TestNewWatcherDefaults(t *testing.T) {
+	w := NewWatcher(&WatcherConfig{}, zap.NewNop())
+	if w.pollInterval != 5*time.Second || w.settle != time.Second {
+		t.Errorf("pollInterval %v, settle %v", w.pollInterval, w.settle)
+	}
+}
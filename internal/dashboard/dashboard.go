@@ -40,6 +40,13 @@ type Stats struct {
 	CacheMaxSize      string  `json:"cache_max_size"`
 	CacheUsagePercent float64 `json:"cache_usage_percent"`
 
+	// Cache-full backpressure: set while the cache refuses new packages, which
+	// are then served uncached and not shared with peers
+	CacheDegraded       bool   `json:"cache_degraded"`
+	CacheDegradedSince  string `json:"cache_degraded_since,omitempty"`
+	CacheFullRefusals   int64  `json:"cache_full_refusals"`
+	CacheStrictWhenFull bool   `json:"cache_strict_when_full"`
+
 	// Network stats
 	ConnectedPeers   int `json:"connected_peers"`
 	RoutingTableSize int `json:"routing_table_size"`
@@ -52,12 +59,32 @@ type Stats struct {
 
 	// Recent activity
 	RecentDownloads []RecentDownload `json:"recent_downloads"`
+	RecentPackages  []CachedPackage  `json:"recent_packages"`
+
+	// Most served packages, from the persistent per-package statistics
+	TopPackages []PackageStat `json:"top_packages"`
+
+	// Busiest proxy clients since the daemon started
+	TopClients []ClientStat `json:"top_clients"`
 
 	// Peers
 	Peers []PeerInfo `json:"peers"`
 
 	// Errors
 	VerificationFailures int64 `json:"verification_failures"`
+
+	// Versions: the latest release, when update checks are enabled, and the
+	// versions connected peers run, most common first
+	LatestVersion   string         `json:"latest_version,omitempty"`
+	UpdateAvailable bool           `json:"update_available"`
+	PeerVersions    []VersionShare `json:"peer_versions"`
+}
+
+// VersionShare is how many connected peers run one debswarm version
+type VersionShare struct {
+	Version string  `json:"version"` // "unknown" for peers that have not said
+	Peers   int     `json:"peers"`
+	Percent float64 `json:"percent"`
 }
 
 // RecentDownload represents a recent download entry
@@ -69,18 +96,52 @@ type RecentDownload struct {
 	Duration string `json:"duration"`
 }
 
+// CachedPackage is a recently used cache entry and where it came from
+type CachedPackage struct {
+	Filename   string `json:"filename"`
+	Size       string `json:"size"`
+	LastAccess string `json:"last_access"`
+	Repo       string `json:"repo,omitempty"`
+	Suite      string `json:"suite,omitempty"`
+	Component  string `json:"component,omitempty"`
+	Mirror     string `json:"mirror,omitempty"`
+}
+
+// PackageStat is how often one package was served
+type PackageStat struct {
+	Filename   string `json:"filename"`
+	Downloads  int64  `json:"downloads"` // times served to local APT clients
+	Uploads    int64  `json:"uploads"`   // complete copies sent to peers
+	Uploaded   string `json:"uploaded"`  // bytes sent to peers
+	LastServed string `json:"last_served"`
+}
+
+// ClientStat is one proxy client's traffic
+type ClientStat struct {
+	Address   string `json:"address"`
+	Profile   string `json:"profile,omitempty"` // the [[clients]] entry it belongs to
+	Requests  int64  `json:"requests"`
+	Served    string `json:"served"` // response bytes sent to it
+	RateLimit string `json:"rate_limit"`
+	Policy    string `json:"policy,omitempty"` // source policy forced by its profile
+	LastSeen  string `json:"last_seen"`
+}
+
 // PeerInfo contains information about a connected peer
 type PeerInfo struct {
-	ID          string  `json:"id"`
-	ShortID     string  `json:"short_id"`
-	Score       float64 `json:"score"`
-	Category    string  `json:"category"`
-	Latency     string  `json:"latency"`
-	Throughput  string  `json:"throughput"`
-	Downloaded  string  `json:"downloaded"`
-	Uploaded    string  `json:"uploaded"`
-	LastSeen    string  `json:"last_seen"`
-	Blacklisted bool    `json:"blacklisted"`
+	ID          string   `json:"id"`
+	ShortID     string   `json:"short_id"`
+	Name        string   `json:"name,omitempty"` // operator-assigned, see 'debswarm peers label'
+	Tags        []string `json:"tags,omitempty"`
+	Score       float64  `json:"score"`
+	Category    string   `json:"category"`
+	Latency     string   `json:"latency"`
+	Throughput  string   `json:"throughput"`
+	Downloaded  string   `json:"downloaded"`
+	Uploaded    string   `json:"uploaded"`
+	LastSeen    string   `json:"last_seen"`
+	Blacklisted bool     `json:"blacklisted"`
+	Version     string   `json:"version,omitempty"` // from the hello handshake; empty if unknown
 }
 
 // StatsProvider is a function that returns current stats
@@ -94,6 +155,8 @@ type Dashboard struct {
 	template      *template.Template
 	getStats      StatsProvider
 	getPeers      PeersProvider
+	getDownloads  DownloadsProvider
+	getEvents     EventsProvider
 	startTime     time.Time
 	version       string
 	peerID        string
@@ -128,8 +191,11 @@ func New(cfg *Config, statsProvider StatsProvider, peersProvider PeersProvider)
 		maxRecent:     50,
 	}
 
-	// Parse embedded template
+	// Parse embedded templates
 	d.template = template.Must(template.New("dashboard").Parse(dashboardHTML))
+	template.Must(d.template.New("style").Parse(dashboardCSS))
+	template.Must(d.template.New("downloads").Parse(downloadsHTML))
+	template.Must(d.template.New("events").Parse(eventsHTML))
 
 	return d
 }
@@ -160,7 +226,9 @@ func (d *Dashboard) RecordDownload(filename string, size int64, source string, d
 // Embedding *Stats preserves all existing {{.Field}} references.
 type templateData struct {
 	*Stats
-	Nonce string
+	Nonce        string
+	DownloadsURL string
+	EventsURL    string
 }
 
 // generateNonce creates a cryptographically random base64-encoded nonce for CSP.
@@ -179,6 +247,10 @@ func (d *Dashboard) Handler() http.Handler {
 	mux.HandleFunc("/", d.handleDashboard)
 	mux.HandleFunc("/api/stats", d.handleAPIStats)
 	mux.HandleFunc("/api/peers", d.handleAPIPeers)
+	mux.HandleFunc("/downloads", d.handleDownloads)
+	mux.HandleFunc("/api/downloads", d.handleAPIDownloads)
+	mux.HandleFunc("/events", d.handleEvents)
+	mux.HandleFunc("/api/events", d.handleAPIEvents)
 	return securityHeadersMiddleware(mux)
 }
 
@@ -241,17 +313,27 @@ func (d *Dashboard) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	nonce := generateNonce()
 
 	// Override middleware CSP to allow our nonced inline script
-	w.Header().Set("Content-Security-Policy",
-		fmt.Sprintf("default-src 'self'; style-src 'unsafe-inline'; script-src 'nonce-%s'; connect-src 'self'; img-src 'self' data:; frame-ancestors 'none'", nonce))
+	w.Header().Set("Content-Security-Policy", pageCSP(nonce))
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	data := &templateData{Stats: stats, Nonce: nonce}
-	if err := d.template.Execute(w, data); err != nil {
+	// Relative, so the link works wherever the dashboard is mounted
+	downloadsURL, eventsURL := "downloads", "events"
+	if r.URL.Path == "/dashboard" {
+		downloadsURL, eventsURL = "dashboard/downloads", "dashboard/events"
+	}
+	data := &templateData{Stats: stats, Nonce: nonce, DownloadsURL: downloadsURL, EventsURL: eventsURL}
+	if err := d.template.ExecuteTemplate(w, "dashboard", data); err != nil {
 		// SECURITY: Don't expose internal error details to clients
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
 }
 
+// pageCSP is the Content-Security-Policy of a dashboard page whose inline
+// script carries nonce
+func pageCSP(nonce string) string {
+	return fmt.Sprintf("default-src 'self'; style-src 'unsafe-inline'; script-src 'nonce-%s'; connect-src 'self'; img-src 'self' data:; frame-ancestors 'none'", nonce)
+}
+
 func (d *Dashboard) handleAPIStats(w http.ResponseWriter, r *http.Request) {
 	stats := d.getStats()
 	if stats == nil {
@@ -333,15 +415,8 @@ func sanitizeForCSS(s string) string {
 	return string(result)
 }
 
-// Embedded HTML template
-const dashboardHTML = `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <noscript><meta http-equiv="refresh" content="5"><style>.chart-grid{display:none}</style></noscript>
-    <title>Debswarm Dashboard</title>
-    <style>
+// dashboardCSS is the stylesheet shared by the dashboard pages
+const dashboardCSS = `
         * { box-sizing: border-box; margin: 0; padding: 0; }
         body {
             font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, Helvetica, Arial, sans-serif;
@@ -362,6 +437,14 @@ const dashboardHTML = `<!DOCTYPE html>
         h1 { font-size: 24px; font-weight: 600; color: #f0f6fc; }
         .version { color: #8b949e; font-size: 14px; }
         .peer-id { font-family: monospace; font-size: 12px; color: #8b949e; }
+        .banner {
+            background: #3d1d1f;
+            border: 1px solid #f85149;
+            border-radius: 6px;
+            color: #f0f6fc;
+            padding: 12px 16px;
+            margin-bottom: 24px;
+        }
         .grid {
             display: grid;
             grid-template-columns: repeat(auto-fit, minmax(300px, 1fr));
@@ -400,6 +483,7 @@ const dashboardHTML = `<!DOCTYPE html>
         .score-fair { color: #d29922; }
         .score-poor { color: #f85149; }
         .blacklisted { color: #f85149; text-decoration: line-through; }
+        .tag { font-size: 11px; padding: 1px 6px; border-radius: 10px; background: #21262d; color: #8b949e; }
         .progress-bar {
             height: 8px;
             background: #21262d;
@@ -465,11 +549,47 @@ const dashboardHTML = `<!DOCTYPE html>
             margin-right: 4px;
             vertical-align: middle;
         }
+        nav a { color: #58a6ff; text-decoration: none; margin-left: 12px; font-size: 14px; }
+        .download { padding: 12px 0; border-bottom: 1px solid #21262d; }
+        .download:last-child { border-bottom: none; }
+        .download-head { display: flex; justify-content: space-between; gap: 16px; }
+        .download-meta { color: #8b949e; font-size: 12px; font-family: monospace; }
+        .download canvas { width: 100%; height: 48px; display: block; margin-top: 8px; }
+        .chunk-map { display: flex; flex-wrap: wrap; gap: 2px; margin-top: 8px; }
+        .chunk { width: 10px; height: 14px; border-radius: 2px; background: #21262d; }
+        .chunk-peer { background: #3fb950; }
+        .chunk-mirror { background: #d29922; }
+        .chunk-resumed { background: #58a6ff; }
+        .chunk-failed { background: #f85149; }
+        .download-note { color: #d29922; font-size: 12px; }
+        .download-error { color: #f85149; font-size: 12px; }
+        .event-filter { display: flex; flex-wrap: wrap; gap: 8px; margin-bottom: 12px; }
+        .event-filter select, .event-filter input, .event-filter button {
+            background: #0d1117;
+            border: 1px solid #30363d;
+            border-radius: 6px;
+            color: #c9d1d9;
+            padding: 4px 8px;
+            font-size: 13px;
+        }
+        .event-filter input { flex: 1; min-width: 200px; }
+        .event-warning td:last-child { color: #d29922; }
+        .event-error td:last-child { color: #f85149; }
         @media (max-width: 768px) {
             .grid { grid-template-columns: 1fr; }
             .chart-grid { grid-template-columns: 1fr; }
         }
-    </style>
+`
+
+// Embedded HTML template
+const dashboardHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <noscript><meta http-equiv="refresh" content="5"><style>.chart-grid{display:none}</style></noscript>
+    <title>Debswarm Dashboard</title>
+    <style>{{template "style"}}    </style>
 </head>
 <body>
     <div class="container">
@@ -478,9 +598,25 @@ const dashboardHTML = `<!DOCTYPE html>
                 <h1>debswarm</h1>
                 <div class="peer-id">{{.PeerID}}</div>
             </div>
-            <div class="version">v{{.Version}} | Uptime: <span id="stat-uptime">{{.Uptime}}</span></div>
+            <div class="version">v{{.Version}} | Uptime: <span id="stat-uptime">{{.Uptime}}</span>
+                <nav><a href="{{.DownloadsURL}}">Downloads</a><a href="{{.EventsURL}}">Events</a></nav>
+            </div>
         </header>
 
+        {{if .CacheDegraded}}
+        <div class="banner" id="cache-full-banner">
+            <strong>Cache full</strong> since {{.CacheDegradedSince}}: {{.CacheFullRefusals}} package(s) could not be cached.
+            {{if .CacheStrictWhenFull}}Clients receive 507 Insufficient Storage for them.{{else}}They are served from the mirror but not shared with peers.{{end}}
+            Free disk space or raise cache.max_size.
+        </div>
+        {{end}}
+
+        {{if .UpdateAvailable}}
+        <div class="banner" id="update-banner">
+            <strong>Update available:</strong> debswarm v{{.LatestVersion}} has been released; this node runs v{{.Version}}.
+        </div>
+        {{end}}
+
         <div class="grid">
             <div class="card">
                 <h2>Overview</h2>
@@ -560,6 +696,36 @@ const dashboardHTML = `<!DOCTYPE html>
                     <span class="stat-value">{{if .MaxDownloadRate}}{{.MaxDownloadRate}}{{else}}Unlimited{{end}}</span>
                 </div>
             </div>
+
+            <div class="card">
+                <h2>Versions</h2>
+                <div class="stat-row">
+                    <span class="stat-label">This Node</span>
+                    <span class="stat-value{{if .UpdateAvailable}} warning{{end}}">v{{.Version}}</span>
+                </div>
+                {{if .LatestVersion}}
+                <div class="stat-row">
+                    <span class="stat-label">Latest Release</span>
+                    <span class="stat-value">v{{.LatestVersion}}</span>
+                </div>
+                {{end}}
+                {{with .PeerVersions}}{{with index . 0}}{{if ne .Version "unknown"}}
+                <div class="stat-row">
+                    <span class="stat-value highlight" id="stat-version-summary">{{printf "%.0f" .Percent}}% of connected peers run v{{.Version}}</span>
+                </div>
+                {{end}}{{end}}
+                {{range .}}
+                <div class="stat-row">
+                    <span class="stat-label">{{if eq .Version "unknown"}}Unknown{{else}}v{{.Version}}{{end}}</span>
+                    <span class="stat-value">{{.Peers}} ({{printf "%.0f" .Percent}}%)</span>
+                </div>
+                {{end}}{{else}}
+                <div class="stat-row">
+                    <span class="stat-label">Peers</span>
+                    <span class="stat-value">none connected</span>
+                </div>
+                {{end}}
+            </div>
         </div>
 
         <div class="chart-grid">
@@ -624,6 +790,102 @@ const dashboardHTML = `<!DOCTYPE html>
             {{end}}
         </div>
 
+        <div class="card">
+            <h2>Recent Packages</h2>
+            {{if .RecentPackages}}
+            <table>
+                <thead>
+                    <tr>
+                        <th>Last Used</th>
+                        <th>Package</th>
+                        <th>Size</th>
+                        <th>Repository</th>
+                        <th>Suite</th>
+                        <th>Component</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .RecentPackages}}
+                    <tr>
+                        <td>{{.LastAccess}}</td>
+                        <td title="{{.Mirror}}">{{.Filename}}</td>
+                        <td>{{.Size}}</td>
+                        <td>{{if .Repo}}{{.Repo}}{{else}}unknown{{end}}</td>
+                        <td>{{.Suite}}</td>
+                        <td>{{.Component}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+            {{else}}
+            <div class="empty-state">No cached packages</div>
+            {{end}}
+        </div>
+
+        <div class="card">
+            <h2>Most Served Packages</h2>
+            {{if .TopPackages}}
+            <table>
+                <thead>
+                    <tr>
+                        <th>Package</th>
+                        <th>Downloads</th>
+                        <th>Uploads</th>
+                        <th>Uploaded</th>
+                        <th>Last Served</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .TopPackages}}
+                    <tr>
+                        <td>{{.Filename}}</td>
+                        <td>{{.Downloads}}</td>
+                        <td>{{.Uploads}}</td>
+                        <td>{{.Uploaded}}</td>
+                        <td>{{.LastServed}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+            {{else}}
+            <div class="empty-state">No packages served yet</div>
+            {{end}}
+        </div>
+
+        <div class="card">
+            <h2>Clients</h2>
+            {{if .TopClients}}
+            <table>
+                <thead>
+                    <tr>
+                        <th>Address</th>
+                        <th>Profile</th>
+                        <th>Requests</th>
+                        <th>Served</th>
+                        <th>Rate Limit</th>
+                        <th>Policy</th>
+                        <th>Last Seen</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .TopClients}}
+                    <tr>
+                        <td>{{.Address}}</td>
+                        <td>{{if .Profile}}{{.Profile}}{{else}}-{{end}}</td>
+                        <td>{{.Requests}}</td>
+                        <td>{{.Served}}</td>
+                        <td>{{.RateLimit}}</td>
+                        <td>{{if .Policy}}{{.Policy}}{{else}}-{{end}}</td>
+                        <td>{{.LastSeen}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+            {{else}}
+            <div class="empty-state">No clients yet</div>
+            {{end}}
+        </div>
+
         <div class="card">
             <h2>Connected Peers</h2>
             {{if .Peers}}
@@ -642,7 +904,7 @@ const dashboardHTML = `<!DOCTYPE html>
                 <tbody>
                     {{range .Peers}}
                     <tr{{if .Blacklisted}} class="blacklisted"{{end}}>
-                        <td title="{{.ID}}">{{.ShortID}}</td>
+                        <td title="{{.ID}}{{if .Version}} (debswarm {{.Version}}){{end}}">{{if .Name}}{{.Name}} <span class="peer-id">{{.ShortID}}</span>{{else}}{{.ShortID}}{{end}}{{range .Tags}} <span class="tag">{{.}}</span>{{end}}</td>
                         <td class="score-{{.Category}}">{{printf "%.1f" .Score}}</td>
                         <td>{{.Latency}}</td>
                         <td>{{.Throughput}}</td>
@@ -125,6 +125,121 @@ func TestHandler_Dashboard(t *testing.T) {
 	}
 }
 
+func TestHandler_CacheFullBanner(t *testing.T) {
+	stats := &Stats{}
+	d := New(&Config{Version: "1.0.0"}, func() *Stats { return stats }, func() []PeerInfo { return nil })
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		d.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w.Body.String()
+	}
+	if strings.Contains(get(), "cache-full-banner") {
+		t.Error("banner shown while the cache is healthy")
+	}
+
+	stats.CacheDegraded = true
+	stats.CacheDegradedSince = "2024-06-01 10:00:00"
+	stats.CacheFullRefusals = 7
+	body := get()
+	if !strings.Contains(body, "cache-full-banner") || !strings.Contains(body, "7 package(s)") {
+		t.Error("banner missing while the cache is full")
+	}
+	if !strings.Contains(body, "not shared with peers") {
+		t.Error("banner should say packages are not shared")
+	}
+
+	stats.CacheStrictWhenFull = true
+	if !strings.Contains(get(), "507 Insufficient Storage") {
+		t.Error("strict-mode banner should mention 507")
+	}
+}
+
+func TestHandler_Versions(t *testing.T) {
+	stats := &Stats{}
+	d := New(&Config{Version: "1.2.0"}, func() *Stats { return stats }, nil)
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		d.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w.Body.String()
+	}
+	if body := get(); strings.Contains(body, "update-banner") || !strings.Contains(body, "none connected") {
+		t.Error("versions card should show no update and no peers")
+	}
+
+	stats.LatestVersion = "1.3.0"
+	stats.UpdateAvailable = true
+	stats.PeerVersions = []VersionShare{
+		{Version: "1.3.0", Peers: 8, Percent: 80},
+		{Version: "unknown", Peers: 2, Percent: 20},
+	}
+	body := get()
+	for _, want := range []string{"update-banner", "v1.3.0 has been released", "80% of connected peers run v1.3.0", "Unknown"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard missing %q", want)
+		}
+	}
+}
+
+func TestHandler_PeerNames(t *testing.T) {
+	peers := []PeerInfo{
+		{ID: "12D3KooWSeedbox", ShortID: "12D3Ko...eedbox", Name: "rack3-seedbox", Tags: []string{"seedbox"}, Category: "good"},
+		{ID: "12D3KooWUnnamed", ShortID: "12D3Ko...nnamed", Category: "fair"},
+	}
+	d := New(&Config{Version: "1.0.0"}, func() *Stats { return &Stats{} }, func() []PeerInfo { return peers })
+
+	w := httptest.NewRecorder()
+	d.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, "rack3-seedbox") || !strings.Contains(body, `<span class="tag">seedbox</span>`) {
+		t.Error("peer name and tag not shown")
+	}
+	if !strings.Contains(body, "12D3Ko...nnamed") {
+		t.Error("unnamed peer should show its short ID")
+	}
+}
+
+func TestHandler_RecentPackages(t *testing.T) {
+	stats := &Stats{RecentPackages: []CachedPackage{
+		{Filename: "pool/main/v/vim/vim_9.0_amd64.deb", Size: "1.2 MB", Repo: "deb.debian.org/debian", Suite: "bookworm-updates", Component: "main", Mirror: "http://deb.debian.org/debian/pool/main/v/vim/vim_9.0_amd64.deb"},
+		{Filename: "pool/main/c/curl/curl_8.0_amd64.deb", Size: "300 KB"},
+	}}
+	d := New(&Config{Version: "1.0.0"}, func() *Stats { return stats }, nil)
+
+	w := httptest.NewRecorder()
+	d.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	body := w.Body.String()
+	for _, want := range []string{"vim_9.0_amd64.deb", "bookworm-updates", "deb.debian.org/debian", `title="http://deb.debian.org/debian/pool/main/v/vim/vim_9.0_amd64.deb"`, "unknown"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard missing %q", want)
+		}
+	}
+}
+
+func TestHandler_TopPackages(t *testing.T) {
+	stats := &Stats{TopPackages: []PackageStat{
+		{Filename: "openssl_3.0.11_amd64.deb", Downloads: 42, Uploads: 317, Uploaded: "1.1 GB", LastServed: "2026-10-18 09:30"},
+	}}
+	d := New(&Config{Version: "1.0.0"}, func() *Stats { return stats }, nil)
+
+	w := httptest.NewRecorder()
+	d.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	body := w.Body.String()
+	for _, want := range []string{"Most Served Packages", "openssl_3.0.11_amd64.deb", "<td>317</td>", "1.1 GB"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard missing %q", want)
+		}
+	}
+
+	stats.TopPackages = nil
+	w = httptest.NewRecorder()
+	d.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(w.Body.String(), "No packages served yet") {
+		t.Error("dashboard missing empty state for top packages")
+	}
+}
+
 func TestHandler_APIStats(t *testing.T) {
 	cfg := &Config{Version: "1.0.0", PeerID: "testpeer"}
 	statsProvider := func() *Stats {
@@ -0,0 +1,239 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Download is a package download in progress or recently finished, as
+// shown on the downloads page
+type Download struct {
+	ID         int64           `json:"id"`
+	Filename   string          `json:"filename"`
+	Path       string          `json:"path"`
+	Size       string          `json:"size"`
+	Percent    float64         `json:"percent"`
+	Started    string          `json:"started"`
+	Duration   string          `json:"duration"`
+	Active     bool            `json:"active"`
+	Source     string          `json:"source"` // peer, mirror or mixed
+	Error      string          `json:"error,omitempty"`
+	Retries    int             `json:"retries"` // extra chunk requests, retries and hedges
+	Chunks     []DownloadChunk `json:"chunks"`
+	Throughput []int64         `json:"throughput"` // bytes received in each second since the start
+	Notes      []string        `json:"notes,omitempty"`
+}
+
+// DownloadChunk is one cell of a download's chunk map
+type DownloadChunk struct {
+	Source   string `json:"source,omitempty"` // peer, mirror, resumed or failed; empty while pending
+	From     string `json:"from,omitempty"`   // peer name or short ID, or mirror host
+	Attempts int    `json:"attempts,omitempty"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// DownloadsProvider is a function that returns the active and recent downloads
+type DownloadsProvider func() []Download
+
+// SetDownloadsProvider sets where the downloads page gets its downloads
+func (d *Dashboard) SetDownloadsProvider(p DownloadsProvider) {
+	d.getDownloads = p
+}
+
+// downloadsData is the data of the downloads page
+type downloadsData struct {
+	Downloads []Download
+	PeerID    string
+	Version   string
+	Uptime    string
+	Nonce     string
+}
+
+// downloads returns the downloads to show, sources made safe for CSS classes
+func (d *Dashboard) downloads() []Download {
+	var list []Download
+	if d.getDownloads != nil {
+		list = d.getDownloads()
+	}
+	if list == nil {
+		return []Download{}
+	}
+	for i := range list {
+		if list[i].Source != "" {
+			list[i].Source = sanitizeForCSS(list[i].Source)
+		}
+		for j := range list[i].Chunks {
+			if c := &list[i].Chunks[j]; c.Source != "" {
+				c.Source = sanitizeForCSS(c.Source)
+			}
+		}
+	}
+	return list
+}
+
+func (d *Dashboard) handleDownloads(w http.ResponseWriter, r *http.Request) {
+	nonce := generateNonce()
+	w.Header().Set("Content-Security-Policy", pageCSP(nonce))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	data := &downloadsData{
+		Downloads: d.downloads(),
+		PeerID:    d.peerID,
+		Version:   d.version,
+		Uptime:    formatDuration(time.Since(d.startTime)),
+		Nonce:     nonce,
+	}
+	if err := d.template.ExecuteTemplate(w, "downloads", data); err != nil {
+		// SECURITY: Don't expose internal error details to clients
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (d *Dashboard) handleAPIDownloads(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(d.downloads()); err != nil {
+		http.Error(w, "Failed to encode downloads", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Embedded HTML template of the downloads page
+const downloadsHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <noscript><meta http-equiv="refresh" content="5"></noscript>
+    <title>Debswarm Downloads</title>
+    <style>{{template "style"}}    </style>
+</head>
+<body>
+    <div class="container">
+        <header>
+            <div>
+                <h1>debswarm downloads</h1>
+                <div class="peer-id">{{.PeerID}}</div>
+            </div>
+            <div class="version">v{{.Version}} | Uptime: {{.Uptime}}
+                <nav><a href="./">Dashboard</a><a href="events">Events</a></nav>
+            </div>
+        </header>
+
+        <div class="card">
+            <h2>Active and Recent Downloads</h2>
+            <div class="chart-legend">
+                <span><span class="legend-color chunk-peer"></span>Peer</span>
+                <span><span class="legend-color chunk-mirror"></span>Mirror</span>
+                <span><span class="legend-color chunk-resumed"></span>Resumed</span>
+                <span><span class="legend-color chunk-failed"></span>Failed</span>
+                <span><span class="legend-color chunk"></span>Pending</span>
+            </div>
+            <div id="downloads">
+            {{range .Downloads}}
+                <div class="download">
+                    <div class="download-head">
+                        <strong title="{{.Path}}">{{.Filename}}</strong>
+                        <span class="source-{{.Source}}">{{if .Active}}{{printf "%.0f" .Percent}}%{{else if .Error}}failed{{else}}{{.Source}}{{end}}</span>
+                    </div>
+                    <div class="download-meta">{{.Size}} · started {{.Started}} · {{.Duration}} · {{.Retries}} retries</div>
+                    {{if .Error}}<div class="download-error">{{.Error}}</div>{{end}}
+                    {{range .Notes}}<div class="download-note">{{.}}</div>{{end}}
+                    <div class="chunk-map">{{range $i, $c := .Chunks}}<span class="chunk{{if $c.Source}} chunk-{{$c.Source}}{{end}}" title="chunk {{$i}}: {{if $c.Source}}{{$c.Source}}{{if $c.From}} {{$c.From}}{{end}}, {{$c.Attempts}} attempt(s), {{$c.Duration}}{{else}}pending{{end}}"></span>{{end}}</div>
+                </div>
+            {{else}}
+                <div class="empty-state">No downloads yet</div>
+            {{end}}
+            </div>
+        </div>
+    </div>
+    <script nonce="{{.Nonce}}">
+    (function(){
+        var INTERVAL=2000;
+        var url=location.pathname.replace(/\/downloads\/?$/,'')+'/api/downloads';
+
+        function formatBps(b){
+            if(b<1024)return b.toFixed(0)+' B/s';
+            if(b<1048576)return(b/1024).toFixed(1)+' KB/s';
+            if(b<1073741824)return(b/1048576).toFixed(1)+' MB/s';
+            return(b/1073741824).toFixed(1)+' GB/s';
+        }
+        function el(tag,cls,text){
+            var e=document.createElement(tag);
+            if(cls)e.className=cls;
+            if(text!=null)e.textContent=text;
+            return e;
+        }
+        function chunkTitle(c,i){
+            if(!c.source)return 'chunk '+i+': pending';
+            return 'chunk '+i+': '+c.source+(c.from?' '+c.from:'')+', '+(c.attempts||0)+' attempt(s), '+(c.duration||'');
+        }
+
+        // Bytes per second over the download, one bar per second
+        function drawThroughput(canvas,data){
+            var dpr=window.devicePixelRatio||1;
+            var rect=canvas.getBoundingClientRect();
+            canvas.width=rect.width*dpr;
+            canvas.height=rect.height*dpr;
+            var ctx=canvas.getContext('2d');
+            ctx.scale(dpr,dpr);
+            var W=rect.width,H=rect.height;
+            ctx.fillStyle='#0d1117';
+            ctx.fillRect(0,0,W,H);
+            if(!data||!data.length)return;
+            var maxVal=0;
+            for(var i=0;i<data.length;i++)if(data[i]>maxVal)maxVal=data[i];
+            if(maxVal===0)return;
+            var bw=W/Math.max(data.length,30);
+            ctx.fillStyle='#58a6ff';
+            for(var i=0;i<data.length;i++){
+                var h=(data[i]/maxVal)*(H-14);
+                ctx.fillRect(i*bw,H-h,Math.max(bw-1,1),h);
+            }
+            ctx.fillStyle='#8b949e';
+            ctx.font='10px monospace';
+            ctx.textAlign='left';
+            ctx.fillText('peak '+formatBps(maxVal),4,10);
+        }
+
+        function render(list){
+            var root=document.getElementById('downloads');
+            root.textContent='';
+            if(!list.length){
+                root.appendChild(el('div','empty-state','No downloads yet'));
+                return;
+            }
+            list.forEach(function(d){
+                var item=el('div','download');
+                var head=el('div','download-head');
+                var name=el('strong',null,d.filename);
+                name.title=d.path;
+                head.appendChild(name);
+                head.appendChild(el('span','source-'+d.source,d.active?d.percent.toFixed(0)+'%':(d.error?'failed':d.source)));
+                item.appendChild(head);
+                item.appendChild(el('div','download-meta',d.size+' · started '+d.started+' · '+d.duration+' · '+d.retries+' retries'));
+                if(d.error)item.appendChild(el('div','download-error',d.error));
+                (d.notes||[]).forEach(function(n){item.appendChild(el('div','download-note',n));});
+                var map=el('div','chunk-map');
+                d.chunks.forEach(function(c,i){
+                    var cell=el('span','chunk'+(c.source?' chunk-'+c.source:''));
+                    cell.title=chunkTitle(c,i);
+                    map.appendChild(cell);
+                });
+                item.appendChild(map);
+                var canvas=el('canvas');
+                item.appendChild(canvas);
+                root.appendChild(item);
+                drawThroughput(canvas,d.throughput);
+            });
+        }
+
+        function poll(){
+            fetch(url).then(function(r){return r.json();}).then(render).catch(function(){});
+        }
+
+        poll();
+        setInterval(poll,INTERVAL);
+    })();
+    </script>
+</body>
+</html>`
@@ -0,0 +1,102 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testDownloads() []Download {
+	return []Download{{
+		ID:       1,
+		Filename: "linux-image_6.1_amd64.deb",
+		Path:     "pool/main/l/linux/linux-image_6.1_amd64.deb",
+		Size:     "68.0 MB",
+		Percent:  50,
+		Active:   true,
+		Source:   "mixed",
+		Retries:  2,
+		Chunks: []DownloadChunk{
+			{Source: "peer", From: "rack3-seedbox", Attempts: 1, Duration: "1.2s"},
+			{Source: "mirror", From: "deb.debian.org", Attempts: 3, Duration: "4.0s"},
+			{Source: "<script>"},
+			{},
+		},
+		Notes: []string{"provider set lacks address diversity"},
+	}}
+}
+
+func TestHandler_Downloads(t *testing.T) {
+	d := New(&Config{Version: "1.0.0"}, func() *Stats { return &Stats{} }, nil)
+	d.SetDownloadsProvider(testDownloads)
+
+	w := httptest.NewRecorder()
+	d.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/downloads", nil))
+	if w.Code != 200 {
+		t.Fatalf("status = %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"linux-image_6.1_amd64.deb",
+		`class="chunk chunk-peer" title="chunk 0: peer rack3-seedbox, 1 attempt(s), 1.2s"`,
+		`class="chunk chunk-mirror"`,
+		`class="chunk chunk-script"`,
+		`class="chunk" title="chunk 3: pending"`,
+		"provider set lacks address diversity",
+		"2 retries",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("downloads page missing %q", want)
+		}
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "script-src 'nonce-") {
+		t.Errorf("CSP = %q", csp)
+	}
+
+	// Without a provider the page is empty, not broken
+	d.SetDownloadsProvider(nil)
+	w = httptest.NewRecorder()
+	d.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/downloads", nil))
+	if !strings.Contains(w.Body.String(), "No downloads yet") {
+		t.Error("empty downloads page should say so")
+	}
+}
+
+func TestHandler_APIDownloads(t *testing.T) {
+	d := New(&Config{Version: "1.0.0"}, func() *Stats { return &Stats{} }, nil)
+
+	w := httptest.NewRecorder()
+	d.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/api/downloads", nil))
+	if got := strings.TrimSpace(w.Body.String()); got != "[]" {
+		t.Errorf("without provider = %s, want []", got)
+	}
+
+	d.SetDownloadsProvider(testDownloads)
+	w = httptest.NewRecorder()
+	d.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/api/downloads", nil))
+	var got []Download
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || len(got[0].Chunks) != 4 || got[0].Chunks[2].Source != "script" || got[0].Chunks[3].Source != "" {
+		t.Errorf("downloads = %+v", got)
+	}
+}
+
+func TestHandler_DownloadsLink(t *testing.T) {
+	d := New(&Config{Version: "1.0.0"}, func() *Stats { return &Stats{} }, nil)
+	for path, want := range map[string]string{
+		"/":          `href="downloads"`,
+		"/dashboard": `href="dashboard/downloads"`,
+	} {
+		w := httptest.NewRecorder()
+		d.Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s: missing %s", path, want)
+		}
+		if events := strings.Replace(want, "downloads", "events", 1); !strings.Contains(w.Body.String(), events) {
+			t.Errorf("%s: missing %s", path, events)
+		}
+	}
+}
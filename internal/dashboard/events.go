@@ -0,0 +1,233 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Event is one entry of the events timeline: a significant daemon event
+// such as a bootstrap, a mode change, a verification failure or a config
+// reload
+type Event struct {
+	Time     string `json:"time"`
+	Type     string `json:"type"`
+	Category string `json:"category"` // network, security, cache or config
+	Severity string `json:"severity"` // info, warning or error
+	Summary  string `json:"summary"`
+}
+
+// EventFilter selects the events shown. Zero fields match everything.
+type EventFilter struct {
+	Category string
+	Since    time.Time
+	Query    string // text in the event's package, peer, reason or error
+	Limit    int
+}
+
+// EventsProvider is a function that returns the events matching a filter,
+// newest first
+type EventsProvider func(EventFilter) []Event
+
+// SetEventsProvider sets where the events page gets its events
+func (d *Dashboard) SetEventsProvider(p EventsProvider) {
+	d.getEvents = p
+}
+
+// Event filter bounds
+const (
+	defaultEventLimit = 200
+	maxEventLimit     = 1000
+)
+
+// eventCategories are the categories offered by the events page filter
+var eventCategories = []string{"network", "security", "cache", "config"}
+
+// eventPeriods are the time ranges offered by the events page filter
+var eventPeriods = []eventPeriod{
+	{"1h", "Last hour"},
+	{"24h", "Last 24 hours"},
+	{"168h", "Last 7 days"},
+	{"", "All"},
+}
+
+// eventPeriod is a time range of the events page filter: a duration back
+// from now, "" for all
+type eventPeriod struct {
+	Value string
+	Label string
+}
+
+// eventsData is the data of the events page
+type eventsData struct {
+	Events     []Event
+	Categories []string
+	Periods    []eventPeriod
+	Category   string
+	Since      string
+	Query      string
+	PeerID     string
+	Version    string
+	Uptime     string
+	Nonce      string
+}
+
+// eventFilter reads the filter from the query string: category, since (a
+// duration back from now), q and limit. Invalid values are ignored.
+func eventFilter(r *http.Request) EventFilter {
+	q := r.URL.Query()
+	f := EventFilter{Category: q.Get("category"), Query: q.Get("q"), Limit: defaultEventLimit}
+	if d, err := time.ParseDuration(q.Get("since")); err == nil && d > 0 {
+		f.Since = time.Now().Add(-d)
+	}
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+		f.Limit = min(n, maxEventLimit)
+	}
+	return f
+}
+
+// events returns the events to show, categories and severities made safe
+// for CSS classes
+func (d *Dashboard) events(f EventFilter) []Event {
+	var list []Event
+	if d.getEvents != nil {
+		list = d.getEvents(f)
+	}
+	if list == nil {
+		return []Event{}
+	}
+	for i := range list {
+		list[i].Category = sanitizeForCSS(list[i].Category)
+		list[i].Severity = sanitizeForCSS(list[i].Severity)
+	}
+	return list
+}
+
+func (d *Dashboard) handleEvents(w http.ResponseWriter, r *http.Request) {
+	nonce := generateNonce()
+	w.Header().Set("Content-Security-Policy", pageCSP(nonce))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	q := r.URL.Query()
+	data := &eventsData{
+		Events:     d.events(eventFilter(r)),
+		Categories: eventCategories,
+		Periods:    eventPeriods,
+		Category:   q.Get("category"),
+		Since:      q.Get("since"),
+		Query:      q.Get("q"),
+		PeerID:     d.peerID,
+		Version:    d.version,
+		Uptime:     formatDuration(time.Since(d.startTime)),
+		Nonce:      nonce,
+	}
+	if err := d.template.ExecuteTemplate(w, "events", data); err != nil {
+		// SECURITY: Don't expose internal error details to clients
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (d *Dashboard) handleAPIEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(d.events(eventFilter(r))); err != nil {
+		http.Error(w, "Failed to encode events", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Embedded HTML template of the events page. Without JavaScript the filter
+// form reloads the page; with it, the list is refreshed in place.
+const eventsHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Debswarm Events</title>
+    <style>{{template "style"}}    </style>
+</head>
+<body>
+    <div class="container">
+        <header>
+            <div>
+                <h1>debswarm events</h1>
+                <div class="peer-id">{{.PeerID}}</div>
+            </div>
+            <div class="version">v{{.Version}} | Uptime: {{.Uptime}}
+                <nav><a href="./">Dashboard</a><a href="downloads">Downloads</a></nav>
+            </div>
+        </header>
+
+        <div class="card">
+            <h2>Timeline</h2>
+            <form id="event-filter" class="event-filter" method="get">
+                <select name="category">
+                    <option value="">All categories</option>
+                    {{range .Categories}}<option value="{{.}}"{{if eq . $.Category}} selected{{end}}>{{.}}</option>{{end}}
+                </select>
+                <select name="since">
+                    {{range .Periods}}<option value="{{.Value}}"{{if eq .Value $.Since}} selected{{end}}>{{.Label}}</option>{{end}}
+                </select>
+                <input type="search" name="q" value="{{.Query}}" placeholder="Package, peer or reason">
+                <button type="submit">Filter</button>
+            </form>
+            <table>
+                <thead><tr><th>Time</th><th>Category</th><th>Event</th><th>Details</th></tr></thead>
+                <tbody id="events">
+                {{range .Events}}
+                    <tr class="event-{{.Severity}}"><td>{{.Time}}</td><td><span class="tag">{{.Category}}</span></td><td>{{.Type}}</td><td>{{.Summary}}</td></tr>
+                {{else}}
+                    <tr><td colspan="4" class="empty-state">No events</td></tr>
+                {{end}}
+                </tbody>
+            </table>
+        </div>
+    </div>
+    <script nonce="{{.Nonce}}">
+    (function(){
+        var INTERVAL=5000;
+        var base=location.pathname.replace(/\/events\/?$/,'')+'/api/events';
+        var form=document.getElementById('event-filter');
+
+        function el(tag,cls,text){
+            var e=document.createElement(tag);
+            if(cls)e.className=cls;
+            if(text!=null)e.textContent=text;
+            return e;
+        }
+        function render(list){
+            var body=document.getElementById('events');
+            body.textContent='';
+            if(!list.length){
+                var td=el('td','empty-state','No events');
+                td.colSpan=4;
+                var tr=el('tr');
+                tr.appendChild(td);
+                body.appendChild(tr);
+                return;
+            }
+            list.forEach(function(e){
+                var tr=el('tr','event-'+e.severity);
+                tr.appendChild(el('td',null,e.time));
+                var cat=el('td');
+                cat.appendChild(el('span','tag',e.category));
+                tr.appendChild(cat);
+                tr.appendChild(el('td',null,e.type));
+                tr.appendChild(el('td',null,e.summary));
+                body.appendChild(tr);
+            });
+        }
+        function poll(){
+            var query=new URLSearchParams(new FormData(form)).toString();
+            fetch(base+'?'+query).then(function(r){return r.json();}).then(render).catch(function(){});
+        }
+
+        form.addEventListener('submit',function(ev){
+            ev.preventDefault();
+            history.replaceState(null,'','?'+new URLSearchParams(new FormData(form)).toString());
+            poll();
+        });
+        setInterval(poll,INTERVAL);
+    })();
+    </script>
+</body>
+</html>`
@@ -0,0 +1,75 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_Events(t *testing.T) {
+	d := New(&Config{Version: "1.0.0"}, func() *Stats { return &Stats{} }, nil)
+	var filter EventFilter
+	d.SetEventsProvider(func(f EventFilter) []Event {
+		filter = f
+		return []Event{
+			{Time: "2026-10-18 09:00:00", Type: "verification_failed", Category: "security", Severity: "error",
+				Summary: "Hash mismatch for <b>openssl</b>"},
+			{Time: "2026-10-18 08:00:00", Type: "bootstrap", Category: "network", Severity: "info",
+				Summary: "Bootstrap (startup) done"},
+		}
+	})
+
+	w := httptest.NewRecorder()
+	d.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/events?category=security&since=24h&q=openssl", nil))
+	if w.Code != 200 {
+		t.Fatalf("status = %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`<tr class="event-error">`,
+		"Hash mismatch for &lt;b&gt;openssl&lt;/b&gt;",
+		`<option value="security" selected>`,
+		`<option value="24h" selected>`,
+		`value="openssl"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("events page missing %q", want)
+		}
+	}
+	if filter.Category != "security" || filter.Query != "openssl" || filter.Limit != defaultEventLimit ||
+		time.Since(filter.Since) < 23*time.Hour || time.Since(filter.Since) > 25*time.Hour {
+		t.Errorf("filter = %+v", filter)
+	}
+
+	// Without a provider the page is empty, not broken
+	d.SetEventsProvider(nil)
+	w = httptest.NewRecorder()
+	d.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+	if !strings.Contains(w.Body.String(), "No events") {
+		t.Error("empty events page should say so")
+	}
+}
+
+func TestHandler_APIEvents(t *testing.T) {
+	d := New(&Config{Version: "1.0.0"}, func() *Stats { return &Stats{} }, nil)
+	var filter EventFilter
+	d.SetEventsProvider(func(f EventFilter) []Event {
+		filter = f
+		return []Event{{Type: "mode_change", Category: "net work", Severity: "<info>"}}
+	})
+
+	w := httptest.NewRecorder()
+	d.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/api/events?limit=5000&since=bogus", nil))
+	var got []Event
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Category != "network" || got[0].Severity != "info" {
+		t.Errorf("events = %+v", got)
+	}
+	if filter.Limit != maxEventLimit || !filter.Since.IsZero() {
+		t.Errorf("filter = %+v", filter)
+	}
+}
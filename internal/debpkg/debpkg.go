@@ -0,0 +1,234 @@
+// Package debpkg checks that a file is a structurally valid Debian binary
+// package and reads the identifying fields of its control file. It also
+// compares package versions.
+package debpkg
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	arMagic      = "!<arch>\n"
+	arHeaderSize = 60
+
+	// maxControlBytes caps the control member and the control file inside
+	// it; real ones are a few KB, so anything larger is not a package.
+	maxControlBytes = 16 << 20
+)
+
+// ErrInvalid is wrapped by every error that means the data is not a valid
+// Debian binary package, as opposed to an error reading it.
+var ErrInvalid = errors.New("not a valid Debian package")
+
+// Control holds the fields that identify a package
+type Control struct {
+	Package      string
+	Version      string
+	Architecture string
+}
+
+// Filename returns the pool file name for the package,
+// name_version_arch.deb with any epoch dropped from the version.
+func (c *Control) Filename() string {
+	version := c.Version
+	if i := strings.IndexByte(version, ':'); i >= 0 {
+		version = version[i+1:]
+	}
+	return c.Package + "_" + version + "_" + c.Architecture + ".deb"
+}
+
+// Inspect reads a .deb from r and returns its control fields. It checks the
+// ar structure, the debian-binary version, and that control and data members
+// are present, and reads r to the end so a caller can hash it on the way.
+func Inspect(r io.Reader) (*Control, error) {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(arMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != arMagic {
+		return nil, invalid("missing ar archive header")
+	}
+
+	var ctrl *Control
+	var members int
+	var haveData bool
+	for {
+		name, size, err := readMember(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		member := &io.LimitedReader{R: br, N: size}
+
+		switch {
+		case members == 0:
+			if name != "debian-binary" {
+				return nil, invalid("first member is %q, want debian-binary", name)
+			}
+			data, err := io.ReadAll(io.LimitReader(member, 64))
+			if err != nil {
+				return nil, err
+			}
+			if !strings.HasPrefix(string(data), "2.") {
+				return nil, invalid("unsupported format version %q", strings.TrimSpace(string(data)))
+			}
+		case strings.HasPrefix(name, "control.tar"):
+			if ctrl != nil {
+				return nil, invalid("duplicate control member")
+			}
+			if size > maxControlBytes {
+				return nil, invalid("control member is %d bytes", size)
+			}
+			if ctrl, err = readControlTar(member, name); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(name, "data.tar"):
+			if ctrl == nil {
+				return nil, invalid("data member before control member")
+			}
+			haveData = true
+		}
+
+		// Skip what the member's reader left, then the odd-size padding byte
+		if _, err := io.Copy(io.Discard, member); err != nil {
+			return nil, err
+		}
+		if member.N > 0 {
+			return nil, invalid("truncated member %q", name)
+		}
+		if size%2 == 1 {
+			if _, err := br.Discard(1); err != nil {
+				return nil, invalid("truncated archive")
+			}
+		}
+		members++
+	}
+
+	if ctrl == nil {
+		return nil, invalid("no control member")
+	}
+	if !haveData {
+		return nil, invalid("no data member")
+	}
+	return ctrl, nil
+}
+
+// readMember reads an ar member header and returns the member's name and size
+func readMember(br *bufio.Reader) (string, int64, error) {
+	var hdr [arHeaderSize]byte
+	n, err := io.ReadFull(br, hdr[:])
+	if n == 0 && err == io.EOF {
+		return "", 0, io.EOF
+	}
+	if err != nil {
+		return "", 0, invalid("truncated member header")
+	}
+	if hdr[58] != '`' || hdr[59] != '\n' {
+		return "", 0, invalid("bad member header")
+	}
+	name := strings.TrimSuffix(strings.TrimRight(string(hdr[0:16]), " "), "/")
+	size, err := strconv.ParseInt(strings.TrimRight(string(hdr[48:58]), " "), 10, 64)
+	if err != nil || size < 0 {
+		return "", 0, invalid("bad size for member %q", name)
+	}
+	return name, size, nil
+}
+
+// readControlTar finds ./control in the control tarball and parses it
+func readControlTar(r io.Reader, name string) (*Control, error) {
+	dr, err := decompress(r, name)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := dr.(io.Closer); ok {
+		defer func() { _ = c.Close() }()
+	}
+	tr := tar.NewReader(dr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, invalid("control member has no control file")
+		}
+		if err != nil {
+			return nil, invalid("reading %s: %v", name, err)
+		}
+		if path.Clean(strings.TrimPrefix(hdr.Name, "./")) != "control" || hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxControlBytes))
+		if err != nil {
+			return nil, invalid("reading control file: %v", err)
+		}
+		return parseControl(data)
+	}
+}
+
+func decompress(r io.Reader, name string) (io.Reader, error) {
+	switch path.Ext(name) {
+	case ".tar":
+		return r, nil
+	case ".gz":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, invalid("%s: %v", name, err)
+		}
+		return gz, nil
+	case ".xz":
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, invalid("%s: %v", name, err)
+		}
+		return xr, nil
+	case ".zst":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, invalid("%s: %v", name, err)
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return nil, invalid("unsupported control compression %q", name)
+}
+
+// parseControl reads Package, Version and Architecture from a control file
+func parseControl(data []byte) (*Control, error) {
+	ctrl := &Control{}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 || line[0] == ' ' || line[0] == '\t' {
+			continue // continuation of a multi-line field
+		}
+		field, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			continue
+		}
+		v := strings.TrimSpace(string(value))
+		switch string(field) {
+		case "Package":
+			ctrl.Package = v
+		case "Version":
+			ctrl.Version = v
+		case "Architecture":
+			ctrl.Architecture = v
+		}
+	}
+	if ctrl.Package == "" || ctrl.Version == "" || ctrl.Architecture == "" {
+		return nil, invalid("control file lacks Package, Version or Architecture")
+	}
+	return ctrl, nil
+}
+
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...))
+}
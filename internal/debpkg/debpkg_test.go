@@ -0,0 +1,106 @@
+package debpkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type arMember struct {
+	name string
+	data []byte
+}
+
+func buildAr(members ...arMember) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(arMagic)
+	for _, m := range members {
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", m.name, 0, 0, 0, "100644", len(m.data))
+		buf.Write(m.data)
+		if len(m.data)%2 == 1 {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+func buildControlTarGz(t *testing.T, control string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, body := range map[string]string{"./md5sums": "", "./control": control} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+const helloControl = "Package: hello\nVersion: 1:2.10-3\nArchitecture: amd64\nDescription: example\n multi-line description\n"
+
+func TestInspect(t *testing.T) {
+	deb := buildAr(
+		arMember{"debian-binary", []byte("2.0\n")},
+		arMember{"control.tar.gz", buildControlTarGz(t, helloControl)},
+		arMember{"data.tar.xz", []byte("odd")},
+	)
+	ctrl, err := Inspect(bytes.NewReader(deb))
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if ctrl.Package != "hello" || ctrl.Version != "1:2.10-3" || ctrl.Architecture != "amd64" {
+		t.Errorf("control = %+v", ctrl)
+	}
+	if got := ctrl.Filename(); got != "hello_2.10-3_amd64.deb" {
+		t.Errorf("Filename() = %q", got)
+	}
+}
+
+func TestInspect_Invalid(t *testing.T) {
+	control := buildControlTarGz(t, helloControl)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not an archive", []byte("<html>404 Not Found</html>")},
+		{"empty", nil},
+		{"wrong first member", buildAr(arMember{"control.tar.gz", control}, arMember{"data.tar.xz", []byte("x")})},
+		{"format 3", buildAr(arMember{"debian-binary", []byte("3.0\n")}, arMember{"control.tar.gz", control}, arMember{"data.tar.xz", []byte("x")})},
+		{"no data", buildAr(arMember{"debian-binary", []byte("2.0\n")}, arMember{"control.tar.gz", control})},
+		{"no control", buildAr(arMember{"debian-binary", []byte("2.0\n")}, arMember{"data.tar.xz", []byte("x")})},
+		{"missing fields", buildAr(
+			arMember{"debian-binary", []byte("2.0\n")},
+			arMember{"control.tar.gz", buildControlTarGz(t, "Package: hello\n")},
+			arMember{"data.tar.xz", []byte("x")})},
+		{"corrupt control", buildAr(
+			arMember{"debian-binary", []byte("2.0\n")},
+			arMember{"control.tar.gz", []byte("not gzip")},
+			arMember{"data.tar.xz", []byte("x")})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Inspect(bytes.NewReader(tt.data)); !errors.Is(err, ErrInvalid) {
+				t.Errorf("Inspect = %v, want ErrInvalid", err)
+			}
+		})
+	}
+
+	// A package cut off inside its data member is invalid too
+	deb := buildAr(arMember{"debian-binary", []byte("2.0\n")}, arMember{"control.tar.gz", control}, arMember{"data.tar.xz", bytes.Repeat([]byte("x"), 100)})
+	if _, err := Inspect(bytes.NewReader(deb[:len(deb)-50])); !errors.Is(err, ErrInvalid) {
+		t.Errorf("truncated package: Inspect = %v, want ErrInvalid", err)
+	}
+}
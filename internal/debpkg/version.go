@@ -0,0 +1,96 @@
+package debpkg
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersions compares two Debian package versions
+// ([epoch:]upstream[-revision]) as dpkg does, returning -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	aEpoch, aUpstream, aRevision := splitVersion(a)
+	bEpoch, bUpstream, bRevision := splitVersion(b)
+	if aEpoch != bEpoch {
+		if aEpoch < bEpoch {
+			return -1
+		}
+		return 1
+	}
+	if c := compareFragment(aUpstream, bUpstream); c != 0 {
+		return c
+	}
+	return compareFragment(aRevision, bRevision)
+}
+
+// splitVersion splits a version into its epoch, upstream version and
+// Debian revision. A missing or invalid epoch is 0.
+func splitVersion(v string) (epoch int, upstream, revision string) {
+	if i := strings.IndexByte(v, ':'); i >= 0 {
+		epoch, _ = strconv.Atoi(v[:i])
+		v = v[i+1:]
+	}
+	if i := strings.LastIndexByte(v, '-'); i >= 0 {
+		return epoch, v[:i], v[i+1:]
+	}
+	return epoch, v, ""
+}
+
+// compareFragment compares an upstream version or revision: alternating
+// runs of non-digits, compared by charOrder, and digits, compared as
+// numbers.
+func compareFragment(a, b string) int {
+	for a != "" || b != "" {
+		var aText, bText string
+		aText, a = cutRun(a, false)
+		bText, b = cutRun(b, false)
+		for i := 0; i < len(aText) || i < len(bText); i++ {
+			if c := charOrder(aText, i) - charOrder(bText, i); c != 0 {
+				if c < 0 {
+					return -1
+				}
+				return 1
+			}
+		}
+
+		var aNum, bNum string
+		aNum, a = cutRun(a, true)
+		bNum, b = cutRun(b, true)
+		aNum, bNum = strings.TrimLeft(aNum, "0"), strings.TrimLeft(bNum, "0")
+		if len(aNum) != len(bNum) {
+			if len(aNum) < len(bNum) {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(aNum, bNum); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// cutRun splits the leading run of digits, or of non-digits, off s.
+func cutRun(s string, digits bool) (run, rest string) {
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9') == digits {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// charOrder returns the sort weight of s[i] in dpkg's ordering: '~' before
+// the end of the run, the end before letters, letters before other
+// characters.
+func charOrder(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	switch c := s[i]; {
+	case c == '~':
+		return -1
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return int(c)
+	default:
+		return int(c) + 256
+	}
+}
@@ -0,0 +1,76 @@
+package downloader
+
+import (
+	"maps"
+	"sync"
+
+	"github.com/debswarm/debswarm/internal/metrics"
+)
+
+// peerLoad counts the chunk requests outstanding to each peer across every
+// download of a Downloader. Each download ranks its sources by itself, so
+// without a shared count concurrent downloads all pick the same best peer:
+// their chunks queue behind each other in its upload slots while the next
+// peers, and the mirror, sit idle. A peer with max requests outstanding is
+// saturated, and chunk selection passes over it to the best source that is
+// not (see sourceTracker.selectBest). Mirrors are not counted.
+//
+// A request is counted from when it starts, not when its source is picked,
+// so workers choosing at the same moment may overshoot max by a few.
+type peerLoad struct {
+	max     int
+	spilled *metrics.Counter // nil when metrics are off
+
+	mu       sync.Mutex
+	inflight map[string]int
+}
+
+func newPeerLoad(max int, m *metrics.Metrics) *peerLoad {
+	l := &peerLoad{max: max, inflight: make(map[string]int)}
+	if m != nil {
+		l.spilled = m.ChunkSpills
+	}
+	return l
+}
+
+// acquire counts a chunk request to s until the returned function is called.
+func (l *peerLoad) acquire(s Source) func() {
+	if l == nil || s.Type() != SourceTypePeer {
+		return func() {}
+	}
+	id := s.ID()
+	l.mu.Lock()
+	l.inflight[id]++
+	l.mu.Unlock()
+	return func() {
+		l.mu.Lock()
+		if l.inflight[id]--; l.inflight[id] <= 0 {
+			delete(l.inflight, id)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// saturated reports whether s is a peer with max chunk requests outstanding.
+func (l *peerLoad) saturated(s Source) bool {
+	if l == nil || l.max <= 0 || s.Type() != SourceTypePeer {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight[s.ID()] >= l.max
+}
+
+// noteSpill counts a chunk sent past a saturated peer.
+func (l *peerLoad) noteSpill() {
+	if l != nil && l.spilled != nil {
+		l.spilled.Inc()
+	}
+}
+
+// snapshot returns the requests outstanding per peer.
+func (l *peerLoad) snapshot() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return maps.Clone(l.inflight)
+}
@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/metrics"
+)
+
+func TestPeerLoadSpill(t *testing.T) {
+	m := metrics.New()
+	load := newPeerLoad(2, m)
+	st := &sourceTracker{stats: make(map[string]*sourceStats), load: load}
+
+	best := &mockSource{id: "best", sourceType: SourceTypePeer}
+	second := &mockSource{id: "second", sourceType: SourceTypePeer}
+	mirror := &mockSource{id: "mirror", sourceType: SourceTypeMirror}
+	st.recordSuccess("best", 1<<20, 10*time.Millisecond)
+	st.recordSuccess("second", 1<<20, 100*time.Millisecond)
+	sources := []Source{second, mirror, best}
+
+	// Other downloads hold one chunk from the best peer: still below the cap
+	releaseA := load.acquire(best)
+	if got := st.selectBest(sources); got != best {
+		t.Fatalf("selected %s below the cap, want best", got.ID())
+	}
+
+	// At the cap the next best source takes the chunk
+	releaseB := load.acquire(best)
+	if got := st.selectBest(sources); got != second {
+		t.Errorf("selected %s with best saturated, want second", got.ID())
+	}
+	if m.ChunkSpills.Value() != 1 {
+		t.Errorf("spills = %d, want 1", m.ChunkSpills.Value())
+	}
+
+	// With every peer saturated the mirror is used; it is never counted
+	releaseC := load.acquire(second)
+	releaseD := load.acquire(second)
+	if got := st.selectBest(sources); got != mirror {
+		t.Errorf("selected %s with all peers saturated, want mirror", got.ID())
+	}
+	load.acquire(mirror)
+	if got := load.snapshot(); len(got) != 2 || got["best"] != 2 || got["second"] != 2 {
+		t.Errorf("load = %v", got)
+	}
+
+	// Without a mirror, the best peer regardless
+	if got := st.selectBest([]Source{second, best}); got != best {
+		t.Errorf("selected %s with no unsaturated source, want best", got.ID())
+	}
+
+	releaseA()
+	if got := st.selectBest(sources); got != best {
+		t.Errorf("selected %s after release, want best", got.ID())
+	}
+	releaseB()
+	releaseC()
+	releaseD()
+	if got := load.snapshot(); len(got) != 0 {
+		t.Errorf("load after release = %v", got)
+	}
+}
+
+func TestPeerLoadShared(t *testing.T) {
+	d := New(&Config{MaxConcurrent: 4, MaxChunksPerPeer: 1})
+	if d.load.max != 1 {
+		t.Errorf("max = %d, want 1", d.load.max)
+	}
+	if d := New(&Config{MaxConcurrent: 4}); d.load.max != 4 {
+		t.Errorf("default max = %d, want MaxConcurrent", d.load.max)
+	}
+
+	// A nil load, as in a bare sourceTracker, never saturates
+	var none *peerLoad
+	src := &mockSource{id: "p", sourceType: SourceTypePeer}
+	none.acquire(src)()
+	if none.saturated(src) {
+		t.Error("nil load saturated")
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/debswarm/debswarm/internal/hashutil"
 	"github.com/debswarm/debswarm/internal/metrics"
 	"github.com/debswarm/debswarm/internal/peers"
+	"github.com/debswarm/debswarm/internal/timeouts"
 )
 
 // Configuration constants
@@ -33,7 +34,8 @@ const (
 	// Maximum retries per chunk
 	MaxChunkRetries = 3
 
-	// Timeout for individual chunk download
+	// Timeout for individual chunk download, used for mirrors and for peers
+	// when no timeout manager is configured
 	ChunkTimeout = 30 * time.Second
 
 	// Time to wait before starting mirror fallback
@@ -133,6 +135,16 @@ type Downloader struct {
 	stateManager   *StateManager
 	cache          PartialCache
 	minChunkedSize int64
+	timeouts       *timeouts.Manager
+
+	hedgePercentile float64
+	retryBudget     float64
+
+	// load counts chunk requests outstanding per peer across downloads
+	load *peerLoad
+
+	// gate holds background downloads back while interactive ones run
+	gate *classGate
 }
 
 // Config holds downloader configuration
@@ -143,7 +155,21 @@ type Config struct {
 	Metrics        *metrics.Metrics
 	StateManager   *StateManager
 	Cache          PartialCache
-	MinChunkedSize int64 // Minimum file size for chunked downloads (default: MinChunkedSize constant)
+	MinChunkedSize int64             // Minimum file size for chunked downloads (default: MinChunkedSize constant)
+	Timeouts       *timeouts.Manager // Per-peer transfer profiles for chunk deadlines (optional)
+
+	// HedgePercentile requests a chunk from a second source once it has been
+	// outstanding longer than this percentile (0-100) of recent chunk times
+	// learned by Timeouts; the first copy wins. 0 disables hedging.
+	HedgePercentile float64
+	// RetryBudget caps the extra chunk requests (retries and hedges) of one
+	// download at this fraction of its chunk count, but at least
+	// MaxChunkRetries (0 = DefaultRetryBudget)
+	RetryBudget float64
+	// MaxChunksPerPeer caps the chunk requests outstanding to one peer
+	// across all downloads; chunks beyond it go to the next best source
+	// (0 = MaxConcurrent, so a download on its own is never held back)
+	MaxChunksPerPeer int
 }
 
 // New creates a new Downloader
@@ -158,6 +184,7 @@ func New(cfg *Config) *Downloader {
 		minChunkedSize: minChunked,
 	}
 
+	maxPerPeer := 0
 	if cfg != nil {
 		if cfg.ChunkSize > 0 {
 			d.chunkSize = cfg.ChunkSize
@@ -172,7 +199,16 @@ func New(cfg *Config) *Downloader {
 		d.metrics = cfg.Metrics
 		d.stateManager = cfg.StateManager
 		d.cache = cfg.Cache
+		d.timeouts = cfg.Timeouts
+		d.hedgePercentile = cfg.HedgePercentile
+		d.retryBudget = cfg.RetryBudget
+		maxPerPeer = cfg.MaxChunksPerPeer
 	}
+	if maxPerPeer <= 0 {
+		maxPerPeer = d.maxConc
+	}
+	d.load = newPeerLoad(maxPerPeer, d.metrics)
+	d.gate = newClassGate(d.metrics)
 
 	return d
 }
@@ -182,6 +218,46 @@ func (d *Downloader) SetStateManager(sm *StateManager) {
 	d.stateManager = sm
 }
 
+// ResumablePrefix returns the assembly file of an interrupted chunked
+// download of hash and how many bytes at its start are already complete,
+// counting completed chunks from the first until the first gap. n is 0 when
+// there is nothing usable on disk: no saved state, a different chunk grid or
+// size, or an assembly file that is missing or the wrong size.
+func (d *Downloader) ResumablePrefix(hash string, size int64) (path string, n int64) {
+	if d.stateManager == nil || d.cache == nil {
+		return "", 0
+	}
+	state, err := d.stateManager.GetDownload(hash)
+	if err != nil || state == nil || state.ChunkSize != d.chunkSize || state.ExpectedSize != size {
+		return "", 0
+	}
+	path = filepath.Join(d.cache.PartialDir(hash), "assembled")
+	if info, err := os.Stat(path); err != nil || info.Size() != size {
+		return "", 0
+	}
+	completed := make(map[int]bool, len(state.Chunks))
+	for _, cs := range state.Chunks {
+		if cs.Status == "completed" {
+			completed[cs.Index] = true
+		}
+	}
+	for i := 0; completed[i] && n < size; i++ {
+		n = min(n+d.chunkSize, size)
+	}
+	return path, n
+}
+
+// DiscardPartial forgets the saved state and on-disk chunks of an
+// interrupted download, once the file has been fetched another way.
+func (d *Downloader) DiscardPartial(hash string) {
+	if d.stateManager != nil {
+		_ = d.stateManager.DeleteDownload(hash)
+	}
+	if d.cache != nil {
+		_ = d.cache.CleanPartialDir(hash)
+	}
+}
+
 // GetStateManager returns the state manager
 func (d *Downloader) GetStateManager() *StateManager {
 	return d.stateManager
@@ -201,7 +277,9 @@ type DownloadResult struct {
 	ChunksFromP2P int
 }
 
-// Download downloads a file using the best available strategy
+// Download downloads a file using the best available strategy. A download
+// whose context carries a background priority.Class waits while
+// interactive downloads run.
 func (d *Downloader) Download(
 	ctx context.Context,
 	expectedHash string,
@@ -209,6 +287,10 @@ func (d *Downloader) Download(
 	peerSources []Source,
 	mirrorSource Source,
 ) (*DownloadResult, error) {
+	defer d.gate.begin(ctx)()
+	if err := d.gate.wait(ctx); err != nil {
+		return nil, err
+	}
 	start := time.Now()
 
 	if d.metrics != nil {
@@ -238,6 +320,8 @@ func (d *Downloader) downloadChunked(
 ) (*DownloadResult, error) {
 	// Calculate chunks
 	numChunks := int((expectedSize + d.chunkSize - 1) / d.chunkSize)
+	progress := progressFrom(ctx)
+	progress.layout(expectedSize, d.chunkSize)
 
 	// Check for existing download state (resume support)
 	var existingState *DownloadState
@@ -315,6 +399,9 @@ func (d *Downloader) downloadChunked(
 		}
 	}
 	chunksRecovered := len(completedFromDisk)
+	for i := range completedFromDisk {
+		progress.chunkResumed(i)
+	}
 
 	f, err := os.OpenFile(assemblyFile, os.O_CREATE|os.O_RDWR, 0600)
 	if err != nil {
@@ -328,6 +415,21 @@ func (d *Downloader) downloadChunked(
 		return nil, fmt.Errorf("failed to allocate assembly file: %w", err)
 	}
 
+	// The file is hashed as it is assembled rather than read back in full at
+	// the end. Out-of-order chunks are held for hashing up to one chunk per
+	// worker.
+	hasher := newChunkHasher(f, expectedSize, d.chunkSize, int64(d.maxConc)*d.chunkSize)
+	for i := 0; i < numChunks; i++ {
+		if !completedFromDisk[i] {
+			continue
+		}
+		if err := hasher.addFromDisk(i); err != nil {
+			f.Close()
+			cleanupTempDir()
+			return nil, err
+		}
+	}
+
 	// Build list of chunks still to download
 	chunks := make([]*Chunk, 0, numChunks)
 	for i := 0; i < numChunks; i++ {
@@ -368,7 +470,9 @@ func (d *Downloader) downloadChunked(
 
 		// Track source performance for adaptive assignment
 		sourceStats := &sourceTracker{
-			stats: make(map[string]*sourceStats),
+			stats:  make(map[string]*sourceStats),
+			scorer: d.scorer,
+			load:   d.load,
 		}
 
 		// All sources (peers + mirror)
@@ -407,11 +511,12 @@ func (d *Downloader) downloadChunked(
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
+		budget := newRetryBudget(numChunks, d.retryBudget)
 		for i := 0; i < workerCount; i++ {
 			wg.Add(1)
 			go func(workerID int) {
 				defer wg.Done()
-				d.chunkWorker(ctx, workerID, pendingChunks, results, allSources, sourceStats, expectedHash)
+				d.chunkWorker(ctx, workerID, pendingChunks, results, allSources, sourceStats, budget, expectedHash)
 			}(i)
 		}
 
@@ -426,6 +531,7 @@ func (d *Downloader) downloadChunked(
 		var firstError error
 
 		for chunk := range results {
+			progress.chunkDone(chunk)
 			if chunk.Error != nil {
 				if firstError == nil {
 					firstError = fmt.Errorf("chunk %d failed: %w", chunk.Index, chunk.Error)
@@ -442,6 +548,14 @@ func (d *Downloader) downloadChunked(
 				continue
 			}
 
+			if err := hasher.add(chunk.Index, chunk.Data); err != nil {
+				if firstError == nil {
+					firstError = err
+					cancel()
+				}
+				continue
+			}
+
 			chunkLen := int64(len(chunk.Data))
 			if chunk.Source.Type() == SourceTypePeer {
 				peerBytes += chunkLen
@@ -449,7 +563,7 @@ func (d *Downloader) downloadChunked(
 			} else {
 				mirrorBytes += chunkLen
 			}
-			chunk.Data = nil // Release chunk memory; it is on disk now
+			chunk.Data = nil // On disk now; the hasher keeps it if it must wait
 			received[chunk.Index] = true
 
 			// The chunk is in the assembly file — the state row is what makes
@@ -481,19 +595,13 @@ func (d *Downloader) downloadChunked(
 		}
 	}
 
-	// Verify hash by streaming read (no memory allocation for full file)
-	if _, err := f.Seek(0, 0); err != nil {
-		f.Close()
-		cleanupTempDir()
-		return nil, fmt.Errorf("failed to seek for hash verification: %w", err)
-	}
-	actualHashHex, err := hashutil.HashReader(f)
+	// Every chunk has been hashed as it came in
+	actualHashHex, err := hasher.sum()
+	f.Close()
 	if err != nil {
-		f.Close()
 		cleanupTempDir()
 		return nil, fmt.Errorf("failed to compute hash: %w", err)
 	}
-	f.Close()
 
 	if actualHashHex != expectedHash {
 		if d.metrics != nil {
@@ -501,7 +609,9 @@ func (d *Downloader) downloadChunked(
 		}
 		if resumeEnabled {
 			_ = d.stateManager.FailDownload(expectedHash, "hash mismatch")
-			_ = d.cache.CleanPartialDir(expectedHash)
+			if d.cache.CleanPartialDir(expectedHash) == nil && d.metrics != nil {
+				d.metrics.PartialDirsRemoved.WithLabel("corrupt").Inc()
+			}
 		}
 		if tempAssemblyDir != "" {
 			_ = os.RemoveAll(tempAssemblyDir)
@@ -553,6 +663,7 @@ func (d *Downloader) chunkWorker(
 	results chan<- *Chunk,
 	sources []Source,
 	tracker *sourceTracker,
+	budget *retryBudget,
 	hash string,
 ) {
 	for chunk := range pending {
@@ -563,6 +674,11 @@ func (d *Downloader) chunkWorker(
 			return
 		default:
 		}
+		if err := d.gate.wait(ctx); err != nil {
+			chunk.Error = err
+			results <- chunk
+			return
+		}
 
 		// Select best source for this chunk
 		source := tracker.selectBest(sources)
@@ -574,25 +690,19 @@ func (d *Downloader) chunkWorker(
 		var allErrors []string
 
 		for attempt := 0; attempt < MaxChunkRetries; attempt++ {
+			if attempt > 0 && !d.spendRetry(budget) {
+				allErrors = append(allErrors, "retry budget exhausted")
+				break
+			}
 			chunk.Attempts++
 
-			chunkCtx, cancel := context.WithTimeout(ctx, ChunkTimeout)
-			start := time.Now()
-
-			data, lastErr = source.Download(chunkCtx, hash, chunk.Start, chunk.End)
-			duration = time.Since(start)
-			cancel()
-
-			if lastErr == nil && int64(len(data)) == chunk.End-chunk.Start {
+			data, source, duration, lastErr = d.fetchChunk(ctx, chunk, source, sources, tracker, budget, hash)
+			if lastErr == nil {
 				break
 			}
 
 			// Record error for context
-			if lastErr != nil {
-				allErrors = append(allErrors, fmt.Sprintf("attempt %d (%s): %v", attempt+1, source.ID(), lastErr))
-			} else {
-				allErrors = append(allErrors, fmt.Sprintf("attempt %d (%s): incomplete data", attempt+1, source.ID()))
-			}
+			allErrors = append(allErrors, fmt.Sprintf("attempt %d (%s): %v", attempt+1, source.ID(), lastErr))
 
 			// Try a different source on failure
 			tracker.recordFailure(source.ID())
@@ -601,8 +711,6 @@ func (d *Downloader) chunkWorker(
 
 		if lastErr != nil {
 			chunk.Error = fmt.Errorf("all retries failed: %w (history: %v)", lastErr, allErrors)
-		} else if int64(len(data)) != chunk.End-chunk.Start {
-			chunk.Error = fmt.Errorf("incomplete chunk: got %d, expected %d (history: %v)", len(data), chunk.End-chunk.Start, allErrors)
 		} else {
 			chunk.Data = data
 			chunk.Source = source
@@ -618,6 +726,24 @@ func (d *Downloader) chunkWorker(
 	}
 }
 
+// chunkTimeout returns the deadline for one attempt at a chunk of size
+// bytes: the peer's transfer profile when a timeout manager is set, else
+// ChunkTimeout.
+func (d *Downloader) chunkTimeout(source Source, size int64) time.Duration {
+	if d.timeouts == nil || source.Type() != SourceTypePeer {
+		return ChunkTimeout
+	}
+	return d.timeouts.PeerTransferTimeout(source.ID(), size)
+}
+
+// recordChunkTimeout lengthens the next chunk deadline for a peer that
+// missed this one.
+func (d *Downloader) recordChunkTimeout(source Source) {
+	if d.timeouts != nil && source.Type() == SourceTypePeer {
+		d.timeouts.RecordPeerTimeout(source.ID())
+	}
+}
+
 // downloadRacing downloads from multiple sources simultaneously, using the first to complete
 func (d *Downloader) downloadRacing(
 	ctx context.Context,
@@ -693,7 +819,7 @@ func (d *Downloader) downloadRacing(
 				// Blacklist peer if hash mismatch
 				if res.source.Type() == SourceTypePeer && d.scorer != nil {
 					if ps, ok := res.source.(*PeerSource); ok {
-						d.scorer.Blacklist(ps.Info.ID, "hash mismatch", 24*time.Hour)
+						d.scorer.Strike(ps.Info.ID, "hash mismatch")
 						if d.metrics != nil {
 							d.metrics.PeersBlacklisted.Inc()
 						}
@@ -708,6 +834,7 @@ func (d *Downloader) downloadRacing(
 
 			// Success! Cancel other downloads
 			cancel()
+			progressFrom(ctx).fetched(res.source, int64(len(res.data)), time.Since(startTime))
 
 			sourceType := res.source.Type()
 			var peerBytes, mirrorBytes int64
@@ -739,6 +866,14 @@ func (d *Downloader) downloadRacing(
 type sourceTracker struct {
 	mu    sync.RWMutex
 	stats map[string]*sourceStats
+
+	// scorer, if set, supplies a prior for peers not yet used in this
+	// download (see prior)
+	scorer *peers.Scorer
+
+	// load, if set, makes selection pass over peers saturated by this and
+	// other downloads
+	load *peerLoad
 }
 
 type sourceStats struct {
@@ -749,6 +884,25 @@ type sourceStats struct {
 	lastFailure  time.Time
 }
 
+// prior scores a source not yet used in this download: neutral, with a
+// slight preference for peers, moved up or down by the throughput the scorer
+// knows for a peer from a bandwidth probe or earlier downloads.
+func (st *sourceTracker) prior(s Source) float64 {
+	if s.Type() != SourceTypePeer {
+		return 0.5
+	}
+	ps, ok := s.(*PeerSource)
+	if !ok || st.scorer == nil {
+		return 0.55
+	}
+	stats := st.scorer.GetStats(ps.Info.ID)
+	if stats == nil || stats.AvgThroughput <= 0 {
+		return 0.55
+	}
+	throughputScore := stats.AvgThroughput / (stats.AvgThroughput + ReferenceThroughput)
+	return 0.55 + 0.4*(throughputScore-0.5)
+}
+
 func (st *sourceTracker) selectBest(sources []Source) Source {
 	st.mu.RLock()
 	defer st.mu.RUnlock()
@@ -765,11 +919,7 @@ func (st *sourceTracker) selectBest(sources []Source) Source {
 		var score float64
 
 		if !ok {
-			// Unknown source - give neutral score, slight preference for peers
-			score = 0.5
-			if s.Type() == SourceTypePeer {
-				score = 0.55
-			}
+			score = st.prior(s)
 		} else {
 			total := stats.successCount + stats.failureCount
 			if total == 0 {
@@ -800,9 +950,34 @@ func (st *sourceTracker) selectBest(sources []Source) Source {
 		return scoredSources[i].score > scoredSources[j].score
 	})
 
+	// The best source that is not saturated; if every peer is and there
+	// is no mirror, the best one regardless
+	for i, c := range scoredSources {
+		if !st.load.saturated(c.source) {
+			if i > 0 {
+				st.load.noteSpill()
+			}
+			return c.source
+		}
+	}
 	return scoredSources[0].source
 }
 
+// selectBestExcept returns the best source other than the one with the given
+// ID, or nil if there is none
+func (st *sourceTracker) selectBestExcept(sources []Source, id string) Source {
+	others := make([]Source, 0, len(sources))
+	for _, s := range sources {
+		if s.ID() != id {
+			others = append(others, s)
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+	return st.selectBest(others)
+}
+
 func (st *sourceTracker) recordSuccess(id string, bytes int64, duration time.Duration) {
 	st.mu.Lock()
 	defer st.mu.Unlock()
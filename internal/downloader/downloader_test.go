@@ -16,6 +16,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 
 	"github.com/debswarm/debswarm/internal/peers"
+	"github.com/debswarm/debswarm/internal/timeouts"
 )
 
 // mockSource is a test source implementation
@@ -376,6 +377,25 @@ func TestSourceTracker(t *testing.T) {
 	}
 }
 
+func TestSourceTrackerProbedPrior(t *testing.T) {
+	scorer := peers.NewScorer()
+	st := &sourceTracker{stats: make(map[string]*sourceStats), scorer: scorer}
+
+	slow := &PeerSource{Info: peer.AddrInfo{ID: peer.ID("slow")}}
+	fast := &PeerSource{Info: peer.AddrInfo{ID: peer.ID("fast")}}
+	unknown := &PeerSource{Info: peer.AddrInfo{ID: peer.ID("unknown")}}
+	scorer.RecordProbe(slow.Info.ID, 200, 100*1024)
+	scorer.RecordProbe(fast.Info.ID, 5, 100*1024*1024)
+
+	// Nothing recorded in this download yet: the probes decide
+	if selected := st.selectBest([]Source{slow, unknown, fast}); selected != fast {
+		t.Errorf("Expected the fast probed peer, got %s", selected.ID())
+	}
+	if selected := st.selectBest([]Source{slow, unknown}); selected != unknown {
+		t.Errorf("Expected the unprobed peer over the slow one, got %s", selected.ID())
+	}
+}
+
 func TestSourceTrackerRecentFailurePenalty(t *testing.T) {
 	st := &sourceTracker{
 		stats: make(map[string]*sourceStats),
@@ -663,6 +683,62 @@ func TestDownloadChunked_ResumeFromPartial(t *testing.T) {
 	}
 }
 
+func TestResumablePrefix(t *testing.T) {
+	chunkSize := int64(1024)
+	data := testData(int(chunkSize)*4 - 100)
+	hash := hashBytes(data)
+	size := int64(len(data))
+
+	db := setupTestDB(t)
+	defer db.Close()
+	cache := &mockPartialCache{baseDir: t.TempDir()}
+	stateManager := NewStateManager(db)
+	d := New(&Config{ChunkSize: chunkSize, StateManager: stateManager, Cache: cache})
+
+	if _, n := d.ResumablePrefix(hash, size); n != 0 {
+		t.Errorf("prefix without state = %d, want 0", n)
+	}
+
+	if err := cache.EnsurePartialDir(hash); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(cache.PartialDir(hash), "assembled")
+	if err := os.WriteFile(path, make([]byte, size), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateManager.CreateDownload(hash, "", size, chunkSize); err != nil {
+		t.Fatal(err)
+	}
+	// Chunk 3 completed but chunk 2 did not: only the first two count
+	for _, i := range []int{0, 1, 3} {
+		if err := stateManager.UpdateChunk(hash, i, "completed"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, n := d.ResumablePrefix(hash, size); got != path || n != 2*chunkSize {
+		t.Errorf("prefix = %s %d, want %s %d", got, n, path, 2*chunkSize)
+	}
+	if _, n := d.ResumablePrefix(hash, size+1); n != 0 {
+		t.Errorf("prefix for another size = %d, want 0", n)
+	}
+
+	// The last chunk is short: a complete file is exactly size bytes
+	if err := stateManager.UpdateChunk(hash, 2, "completed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, n := d.ResumablePrefix(hash, size); n != size {
+		t.Errorf("complete prefix = %d, want %d", n, size)
+	}
+
+	d.DiscardPartial(hash)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("assembly file not removed: %v", err)
+	}
+	if _, n := d.ResumablePrefix(hash, size); n != 0 {
+		t.Errorf("prefix after discard = %d, want 0", n)
+	}
+}
+
 // TestDownloadChunked_LegacyChunkFilesRedownloaded covers upgrading mid-download:
 // older versions persisted chunk_N files instead of writing into the assembly
 // file, so their state rows say "completed" but no assembly file exists. Those
@@ -736,3 +812,51 @@ func TestDownloadChunked_LegacyChunkFilesRedownloaded(t *testing.T) {
 		}
 	}
 }
+
+func TestChunkTimeout_PeerProfiles(t *testing.T) {
+	peerSrc := &mockSource{id: "peer1", sourceType: SourceTypePeer}
+	mirrorSrc := &mockSource{id: "mirror", sourceType: SourceTypeMirror}
+
+	d := New(&Config{})
+	if got := d.chunkTimeout(peerSrc, DefaultChunkSize); got != ChunkTimeout {
+		t.Errorf("without timeouts manager: %v, want %v", got, ChunkTimeout)
+	}
+
+	tm := timeouts.NewManager(nil)
+	tm.SetPeerProfile("peer1", timeouts.ProfileLAN)
+	d = New(&Config{Timeouts: tm})
+	if got, want := d.chunkTimeout(peerSrc, DefaultChunkSize), tm.PeerTransferTimeout("peer1", DefaultChunkSize); got != want {
+		t.Errorf("LAN peer chunk timeout = %v, want %v", got, want)
+	}
+	if got := d.chunkTimeout(mirrorSrc, DefaultChunkSize); got != ChunkTimeout {
+		t.Errorf("mirror chunk timeout = %v, want %v", got, ChunkTimeout)
+	}
+}
+
+func TestDownloadChunked_SlowLANPeerTimesOut(t *testing.T) {
+	data := testData(128 * 1024)
+	hash := hashBytes(data)
+
+	// Well inside the WAN default but past what a LAN peer is allowed
+	slowPeer := &mockSource{id: "peer1", sourceType: SourceTypePeer, data: data, rangeSupport: true, delay: 3 * time.Second}
+	mirrorSrc := &mockSource{id: "mirror", sourceType: SourceTypeMirror, data: data, rangeSupport: true}
+
+	tm := timeouts.NewManager(nil)
+	tm.SetPeerProfile("peer1", timeouts.ProfileLAN)
+	before := tm.PeerTransferTimeout("peer1", 64*1024)
+
+	d := New(&Config{ChunkSize: 64 * 1024, MinChunkedSize: 1, Timeouts: tm})
+	result, err := d.Download(context.Background(), hash, int64(len(data)), []Source{slowPeer}, mirrorSrc)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if result.Source != SourceTypeMirror {
+		t.Errorf("Source = %s, want mirror after the LAN peer timed out", result.Source)
+	}
+	if stats := tm.GetPeerStats("peer1"); stats == nil || stats.TimeoutCount == 0 {
+		t.Errorf("peer timeout not recorded: %+v", stats)
+	}
+	if after := tm.PeerTransferTimeout("peer1", 64*1024); after <= before {
+		t.Errorf("deadline after timeout = %v, want > %v", after, before)
+	}
+}
@@ -0,0 +1,147 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRetryBudget is the share of a download's chunk count it may spend
+// on extra chunk requests (retries and hedges) when none is configured
+const DefaultRetryBudget = 0.5
+
+// retryBudget bounds the extra chunk requests of one download, so a download
+// whose sources keep failing gives up (and the caller falls back to the
+// mirror) instead of retrying every chunk to the limit, and hedging cannot
+// double its traffic.
+type retryBudget struct {
+	left atomic.Int64
+}
+
+func newRetryBudget(numChunks int, fraction float64) *retryBudget {
+	if fraction <= 0 {
+		fraction = DefaultRetryBudget
+	}
+	b := &retryBudget{}
+	b.left.Store(max(int64(MaxChunkRetries), int64(float64(numChunks)*fraction+0.5)))
+	return b
+}
+
+// take spends one extra request, reporting false once the budget is gone
+func (b *retryBudget) take() bool {
+	return b.left.Add(-1) >= 0
+}
+
+// spendRetry takes one extra request from budget, counting a refusal
+func (d *Downloader) spendRetry(budget *retryBudget) bool {
+	if budget.take() {
+		return true
+	}
+	if d.metrics != nil {
+		d.metrics.RetryBudgetExhausted.Inc()
+	}
+	return false
+}
+
+// hedgeDelay returns how long a chunk may be outstanding before it is also
+// requested from another source, or 0 when hedging is off or not enough
+// chunk times have been learned yet
+func (d *Downloader) hedgeDelay() time.Duration {
+	if d.hedgePercentile <= 0 || d.timeouts == nil {
+		return 0
+	}
+	return d.timeouts.ChunkLatencyPercentile(d.hedgePercentile)
+}
+
+type chunkAttempt struct {
+	data     []byte
+	source   Source
+	duration time.Duration
+	err      error
+}
+
+// fetchChunk downloads one chunk from source. A chunk still outstanding after
+// hedgeDelay is requested from the next best source as well, if the retry
+// budget allows: the first complete copy wins and the other request is
+// canceled, so one stuck peer does not hold up the whole download. It returns
+// the source that delivered, or source and its error.
+func (d *Downloader) fetchChunk(
+	ctx context.Context,
+	chunk *Chunk,
+	source Source,
+	sources []Source,
+	tracker *sourceTracker,
+	budget *retryBudget,
+	hash string,
+) ([]byte, Source, time.Duration, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	size := chunk.End - chunk.Start
+	results := make(chan chunkAttempt, 2)
+	start := func(s Source) {
+		go func() {
+			defer d.load.acquire(s)()
+			reqCtx, reqCancel := context.WithTimeout(ctx, d.chunkTimeout(s, size))
+			defer reqCancel()
+			begin := time.Now()
+			data, err := s.Download(reqCtx, hash, chunk.Start, chunk.End)
+			if errors.Is(reqCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+				d.recordChunkTimeout(s)
+			}
+			if err == nil && int64(len(data)) != size {
+				err = fmt.Errorf("incomplete chunk: got %d, expected %d", len(data), size)
+			}
+			results <- chunkAttempt{data: data, source: s, duration: time.Since(begin), err: err}
+		}()
+	}
+
+	start(source)
+	pending := 1
+	var hedge <-chan time.Time
+	if delay := d.hedgeDelay(); delay > 0 && len(sources) > 1 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+
+	hedged := false
+	var primary chunkAttempt
+	for pending > 0 {
+		select {
+		case <-hedge:
+			hedge = nil
+			alt := tracker.selectBestExcept(sources, source.ID())
+			if alt == nil || !d.spendRetry(budget) {
+				continue
+			}
+			chunk.Attempts++
+			hedged = true
+			pending++
+			start(alt)
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if hedged && d.metrics != nil {
+					result := "lost"
+					if r.source != source {
+						result = "won"
+					}
+					d.metrics.ChunkHedges.WithLabel(result).Inc()
+				}
+				if d.timeouts != nil && size == d.chunkSize {
+					d.timeouts.RecordChunkLatency(r.duration)
+				}
+				return r.data, r.source, r.duration, nil
+			}
+			if r.source == source {
+				primary = r
+			} else {
+				tracker.recordFailure(r.source.ID())
+			}
+		}
+	}
+	return nil, source, primary.duration, primary.err
+}
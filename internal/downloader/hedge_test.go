@@ -0,0 +1,117 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/metrics"
+	"github.com/debswarm/debswarm/internal/timeouts"
+)
+
+// flakySource fails every other request
+type flakySource struct {
+	mockSource
+	calls int32
+}
+
+func (f *flakySource) Download(ctx context.Context, hash string, start, end int64) ([]byte, error) {
+	if atomic.AddInt32(&f.calls, 1)%2 == 1 {
+		return nil, errors.New("connection reset")
+	}
+	return f.mockSource.Download(ctx, hash, start, end)
+}
+
+func TestRetryBudget(t *testing.T) {
+	b := newRetryBudget(10, 0.5)
+	for i := 0; i < 5; i++ {
+		if !b.take() {
+			t.Fatalf("take %d refused within a budget of 5", i)
+		}
+	}
+	if b.take() {
+		t.Error("take allowed past the budget")
+	}
+
+	// Small downloads still get MaxChunkRetries
+	b = newRetryBudget(1, 0)
+	for i := 0; i < MaxChunkRetries; i++ {
+		if !b.take() {
+			t.Fatalf("take %d refused, want at least %d", i, MaxChunkRetries)
+		}
+	}
+}
+
+func TestFetchChunk_Hedge(t *testing.T) {
+	data := testData(64 * 1024)
+	stuck := &mockSource{id: "peer1", sourceType: SourceTypePeer, data: data, rangeSupport: true, delay: 10 * time.Second}
+	fast := &mockSource{id: "peer2", sourceType: SourceTypePeer, data: data, rangeSupport: true}
+	sources := []Source{stuck, fast}
+
+	tm := timeouts.NewManager(nil)
+	m := metrics.New()
+	d := New(&Config{ChunkSize: 64 * 1024, Timeouts: tm, Metrics: m, HedgePercentile: 95})
+	if d.hedgeDelay() != 0 {
+		t.Fatal("hedging before any chunk latency was learned")
+	}
+	for i := 0; i < timeouts.MinChunkLatencySamples; i++ {
+		tm.RecordChunkLatency(20 * time.Millisecond)
+	}
+
+	chunk := &Chunk{Start: 0, End: int64(len(data))}
+	tracker := &sourceTracker{stats: make(map[string]*sourceStats)}
+	start := time.Now()
+	got, source, _, err := d.fetchChunk(context.Background(), chunk, stuck, sources, tracker, newRetryBudget(1, 0), "hash")
+	if err != nil {
+		t.Fatalf("fetchChunk: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("hedged chunk took %v", elapsed)
+	}
+	if source != fast || len(got) != len(data) {
+		t.Errorf("chunk came from %s (%d bytes), want the hedge to peer2", source.ID(), len(got))
+	}
+	if chunk.Attempts != 1 {
+		t.Errorf("Attempts = %d, want the hedge counted", chunk.Attempts)
+	}
+	if won := m.ChunkHedges.WithLabel("won").Value(); won != 1 {
+		t.Errorf("hedges won = %d, want 1", won)
+	}
+
+	// No budget left: no hedge, the stuck source runs to its deadline
+	budget := newRetryBudget(1, 0)
+	for budget.take() {
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, _, _, err := d.fetchChunk(ctx, chunk, stuck, sources, tracker, budget, "hash"); err == nil {
+		t.Error("fetchChunk succeeded without budget for a hedge")
+	}
+	if m.RetryBudgetExhausted.Value() == 0 {
+		t.Error("refused hedge not counted")
+	}
+}
+
+func TestDownloadChunked_RetryBudgetExhausted(t *testing.T) {
+	data := testData(16 * 64 * 1024)
+	hash := hashBytes(data)
+	flaky := &flakySource{mockSource: mockSource{id: "peer1", sourceType: SourceTypePeer, data: data, rangeSupport: true}}
+
+	m := metrics.New()
+	d := New(&Config{ChunkSize: 64 * 1024, MaxConcurrent: 1, MinChunkedSize: 1, Metrics: m, RetryBudget: 0.1})
+	_, err := d.Download(context.Background(), hash, int64(len(data)), []Source{flaky}, nil)
+	if err == nil || !strings.Contains(err.Error(), "retry budget exhausted") {
+		t.Fatalf("Download error = %v, want the retry budget exhausted", err)
+	}
+	// 16 chunks at 10% is below the floor of MaxChunkRetries: one request
+	// per chunk plus three retries at most
+	if calls := atomic.LoadInt32(&flaky.calls); calls > 16+MaxChunkRetries {
+		t.Errorf("requests = %d, want at most %d", calls, 16+MaxChunkRetries)
+	}
+	if m.RetryBudgetExhausted.Value() == 0 {
+		t.Error("budget refusals not counted")
+	}
+}
@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"context"
+	"sync"
+
+	"github.com/debswarm/debswarm/internal/metrics"
+	"github.com/debswarm/debswarm/internal/priority"
+)
+
+// classGate holds background downloads (see priority.Class) back while an
+// interactive one runs, so a client running apt install never shares the
+// node's bandwidth or its peers' upload slots with prefetch traffic. A
+// background download waits before it starts and before each chunk; chunks
+// already in flight finish. It resumes once no interactive download is
+// running, or once it is promoted because a client waits on it.
+type classGate struct {
+	waits *metrics.CounterVec // nil when metrics are off
+
+	mu          sync.Mutex
+	interactive int
+	idle        chan struct{} // closed while no interactive download runs
+}
+
+func newClassGate(m *metrics.Metrics) *classGate {
+	g := &classGate{idle: make(chan struct{})}
+	close(g.idle)
+	if m != nil {
+		g.waits = m.BackgroundDownloadWaits
+	}
+	return g
+}
+
+// begin counts the download of ctx, if interactive, until the returned
+// function is called.
+func (g *classGate) begin(ctx context.Context) func() {
+	if priority.Of(ctx).Background() {
+		return func() {}
+	}
+	g.mu.Lock()
+	if g.interactive++; g.interactive == 1 {
+		g.idle = make(chan struct{})
+	}
+	g.mu.Unlock()
+	return func() {
+		g.mu.Lock()
+		if g.interactive--; g.interactive == 0 {
+			close(g.idle)
+		}
+		g.mu.Unlock()
+	}
+}
+
+// wait blocks a background download while an interactive one runs.
+func (g *classGate) wait(ctx context.Context) error {
+	level := priority.FromContext(ctx)
+	class := level.Class()
+	if !class.Background() {
+		return nil
+	}
+	g.mu.Lock()
+	idle := g.idle
+	g.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	default:
+	}
+	if g.waits != nil {
+		g.waits.WithLabel(class.String()).Inc()
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-level.Promoted():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,86 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/metrics"
+	"github.com/debswarm/debswarm/internal/priority"
+)
+
+// waitAsync runs gate.wait for ctx and returns its result channel.
+func waitAsync(g *classGate, ctx context.Context) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- g.wait(ctx) }()
+	return done
+}
+
+func expectBlocked(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		t.Fatalf("background download not held back: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func expectReleased(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("wait = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("background download still held back")
+	}
+}
+
+func TestClassGate(t *testing.T) {
+	m := metrics.New()
+	g := newClassGate(m)
+	ctx := context.Background()
+	prefetch := priority.WithLevel(ctx, priority.NewLevel(priority.Prefetch))
+
+	// Nothing interactive running: background goes ahead
+	expectReleased(t, waitAsync(g, prefetch))
+
+	// Interactive downloads hold background ones back until the last ends
+	end1, end2 := g.begin(ctx), g.begin(ctx)
+	if err := g.wait(ctx); err != nil {
+		t.Fatalf("interactive download waited: %v", err)
+	}
+	done := waitAsync(g, prefetch)
+	expectBlocked(t, done)
+	end1()
+	expectBlocked(t, done)
+	end2()
+	expectReleased(t, done)
+
+	// Background downloads do not hold each other back
+	defer g.begin(prefetch)()
+	expectReleased(t, waitAsync(g, prefetch))
+
+	// A promoted download goes ahead
+	defer g.begin(ctx)()
+	level := priority.NewLevel(priority.Replication)
+	done = waitAsync(g, priority.WithLevel(ctx, level))
+	expectBlocked(t, done)
+	level.Promote()
+	expectReleased(t, done)
+
+	// A canceled one gives up
+	canceled, cancel := context.WithCancel(prefetch)
+	done = waitAsync(g, canceled)
+	expectBlocked(t, done)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled wait = %v, want context.Canceled", err)
+	}
+
+	if got := m.BackgroundDownloadWaits.Values(); got["prefetch"] != 2 || got["replication"] != 1 {
+		t.Errorf("waits = %v", got)
+	}
+}
@@ -0,0 +1,369 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// SourceResumed marks a chunk taken from an interrupted earlier attempt
+	SourceResumed = "resumed"
+	// SourceFailed marks a chunk whose every attempt failed
+	SourceFailed = "failed"
+
+	// maxProgressSamples bounds the throughput history of one download to an
+	// hour; later bytes are counted in the last second.
+	maxProgressSamples = 3600
+	// maxProgressNotes bounds the notes kept for one download
+	maxProgressNotes = 20
+)
+
+// ChunkProgress is where one chunk of a download came from.
+type ChunkProgress struct {
+	Source   string        // SourceTypePeer, SourceTypeMirror, SourceResumed or SourceFailed; empty while pending
+	ID       string        // peer ID or mirror URL
+	Attempts int           // requests made for it, retries and hedges included
+	Duration time.Duration // of the request that delivered it
+}
+
+// DownloadInfo is a snapshot of a tracked download.
+type DownloadInfo struct {
+	ID         int64
+	Hash       string
+	Name       string
+	Size       int64
+	Received   int64
+	Started    time.Time
+	Finished   time.Time // zero while active
+	Source     string    // peer, mirror or mixed: what delivered it, or so far
+	Error      string
+	Retries    int             // extra chunk requests, retries and hedges
+	ChunkSize  int64           // 0 until the download is split into chunks
+	Chunks     []ChunkProgress // chunk map, in file order
+	Throughput []int64         // bytes received in each second since Started
+	Notes      []string        // e.g. why a strategy was abandoned
+}
+
+// Progress follows one package download: where each chunk came from, when
+// bytes arrived, and how many extra requests it took. All methods are safe
+// on a nil *Progress, so downloads that are not tracked need no checks.
+type Progress struct {
+	tracker *Tracker
+
+	mu   sync.Mutex
+	info DownloadInfo
+}
+
+// Tracker keeps the package downloads in progress and the most recently
+// finished ones, for the dashboard.
+type Tracker struct {
+	mu        sync.Mutex
+	lastID    int64
+	active    []*Progress // oldest first
+	recent    []*Progress // newest first
+	maxRecent int
+}
+
+// NewTracker returns a Tracker keeping up to maxRecent finished downloads.
+func NewTracker(maxRecent int) *Tracker {
+	return &Tracker{maxRecent: maxRecent}
+}
+
+// Start begins tracking a download of size bytes (0 if unknown). A nil
+// Tracker returns a nil Progress.
+func (t *Tracker) Start(hash, name string, size int64) *Progress {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastID++
+	p := &Progress{tracker: t, info: DownloadInfo{
+		ID:      t.lastID,
+		Hash:    hash,
+		Name:    name,
+		Size:    size,
+		Started: time.Now(),
+	}}
+	t.active = append(t.active, p)
+	return p
+}
+
+// Downloads returns the active downloads, oldest first, followed by the
+// finished ones, newest first.
+func (t *Tracker) Downloads() []DownloadInfo {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	all := make([]*Progress, 0, len(t.active)+len(t.recent))
+	all = append(all, t.active...)
+	all = append(all, t.recent...)
+	t.mu.Unlock()
+
+	out := make([]DownloadInfo, len(all))
+	for i, p := range all {
+		out[i] = p.Info()
+	}
+	return out
+}
+
+// finish moves p from the active to the recent downloads.
+func (t *Tracker) finish(p *Progress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, a := range t.active {
+		if a == p {
+			t.active = append(t.active[:i], t.active[i+1:]...)
+			break
+		}
+	}
+	t.recent = append([]*Progress{p}, t.recent...)
+	if len(t.recent) > t.maxRecent {
+		t.recent = t.recent[:t.maxRecent]
+	}
+}
+
+// Info returns a snapshot of the download.
+func (p *Progress) Info() DownloadInfo {
+	if p == nil {
+		return DownloadInfo{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	info := p.info
+	info.Chunks = append([]ChunkProgress(nil), p.info.Chunks...)
+	info.Throughput = append([]int64(nil), p.info.Throughput...)
+	info.Notes = append([]string(nil), p.info.Notes...)
+	if info.Finished.IsZero() {
+		info.Source = chunkSources(info.Chunks)
+	}
+	return info
+}
+
+// Note records why the download changed course, e.g. a fallback to the
+// mirror.
+func (p *Progress) Note(msg string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.info.Notes) < maxProgressNotes {
+		p.info.Notes = append(p.info.Notes, msg)
+	}
+}
+
+// Reader returns r, recording the bytes read from it as arriving from
+// source (SourceTypePeer or SourceTypeMirror) id, in file order from the
+// start.
+func (p *Progress) Reader(r io.Reader, source, id string) io.Reader {
+	if p == nil {
+		return r
+	}
+	p.mu.Lock()
+	if p.info.ChunkSize == 0 && p.info.Size > 0 {
+		p.layoutLocked(p.info.Size, DefaultChunkSize)
+	}
+	p.mu.Unlock()
+	return &progressReader{r: r, p: p, source: source, id: id, chunk: -1}
+}
+
+// Finish ends tracking. A download that recorded no bytes, fetched by a
+// path that is not followed chunk by chunk, is shown as received whole
+// from source.
+func (p *Progress) Finish(source string, err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	if !p.info.Finished.IsZero() {
+		p.mu.Unlock()
+		return
+	}
+	p.info.Finished = time.Now()
+	if err != nil {
+		p.info.Error = err.Error()
+		p.info.Source = chunkSources(p.info.Chunks)
+	} else {
+		if p.info.Received == 0 && p.info.Size > 0 {
+			p.info.Chunks = []ChunkProgress{{Source: source, Attempts: 1, Duration: p.info.Finished.Sub(p.info.Started)}}
+			p.info.ChunkSize = p.info.Size
+			p.addLocked(p.info.Size, p.info.Started, p.info.Finished)
+		}
+		p.info.Source = source
+	}
+	p.mu.Unlock()
+	p.tracker.finish(p)
+}
+
+// layout splits the download into its chunk grid.
+func (p *Progress) layout(size, chunkSize int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.layoutLocked(size, chunkSize)
+}
+
+func (p *Progress) layoutLocked(size, chunkSize int64) {
+	n := int((size + chunkSize - 1) / chunkSize)
+	if p.info.ChunkSize == chunkSize && len(p.info.Chunks) == n {
+		return
+	}
+	p.info.Size = size
+	p.info.ChunkSize = chunkSize
+	p.info.Chunks = make([]ChunkProgress, n)
+}
+
+// chunkResumed records a chunk taken from an interrupted earlier attempt.
+func (p *Progress) chunkResumed(index int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if index < len(p.info.Chunks) {
+		p.info.Chunks[index] = ChunkProgress{Source: SourceResumed}
+	}
+}
+
+// chunkDone records a chunk that arrived, or failed for good.
+func (p *Progress) chunkDone(c *Chunk) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c.Attempts > 1 {
+		p.info.Retries += c.Attempts - 1
+	}
+	cp := ChunkProgress{Source: SourceFailed, Attempts: c.Attempts}
+	switch {
+	case errors.Is(c.Error, context.Canceled):
+		return // abandoned when another chunk failed; still pending
+	case c.Error == nil:
+		cp = ChunkProgress{Source: c.Source.Type(), ID: c.Source.ID(), Attempts: c.Attempts, Duration: c.Duration}
+		now := time.Now()
+		p.addLocked(int64(len(c.Data)), now.Add(-c.Duration), now)
+	}
+	if c.Index < len(p.info.Chunks) {
+		p.info.Chunks[c.Index] = cp
+	}
+}
+
+// fetched records a download received in one piece.
+func (p *Progress) fetched(source Source, n int64, d time.Duration) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.info.ChunkSize = n
+	p.info.Chunks = []ChunkProgress{{Source: source.Type(), ID: source.ID(), Attempts: 1, Duration: d}}
+	now := time.Now()
+	p.addLocked(n, now.Add(-d), now)
+}
+
+// addLocked counts n bytes received between from and to, spread evenly
+// over the seconds in between.
+func (p *Progress) addLocked(n int64, from, to time.Time) {
+	p.info.Received += n
+	first := p.second(from)
+	last := p.second(to)
+	if len(p.info.Throughput) <= last {
+		p.info.Throughput = append(p.info.Throughput, make([]int64, last+1-len(p.info.Throughput))...)
+	}
+	spread := int64(last - first + 1)
+	for s := first; s <= last; s++ {
+		p.info.Throughput[s] += n / spread
+	}
+	p.info.Throughput[last] += n % spread
+}
+
+// second returns the throughput sample t falls in.
+func (p *Progress) second(t time.Time) int {
+	s := int(t.Sub(p.info.Started) / time.Second)
+	return min(max(s, 0), maxProgressSamples-1)
+}
+
+// chunkSources summarizes the sources of the chunks received so far.
+func chunkSources(chunks []ChunkProgress) string {
+	var peer, mirror bool
+	for _, c := range chunks {
+		switch c.Source {
+		case SourceTypePeer:
+			peer = true
+		case SourceTypeMirror:
+			mirror = true
+		}
+	}
+	switch {
+	case peer && mirror:
+		return SourceTypeMixed
+	case peer:
+		return SourceTypePeer
+	case mirror:
+		return SourceTypeMirror
+	}
+	return ""
+}
+
+// progressReader records the bytes read through it, marking each chunk of
+// the grid with the source as the stream passes it.
+type progressReader struct {
+	r      io.Reader
+	p      *Progress
+	source string
+	id     string
+	off    int64
+	last   time.Time
+
+	chunk        int64 // chunk being read, and when the stream entered it
+	chunkEntered time.Time
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	if pr.last.IsZero() {
+		pr.last = time.Now()
+	}
+	n, err := pr.r.Read(b)
+	if n > 0 {
+		now := time.Now()
+		p := pr.p
+		p.mu.Lock()
+		p.addLocked(int64(n), pr.last, now)
+		if cs := p.info.ChunkSize; cs > 0 {
+			for i := pr.off / cs; i <= (pr.off+int64(n)-1)/cs && int(i) < len(p.info.Chunks); i++ {
+				if i != pr.chunk {
+					pr.chunk, pr.chunkEntered = i, pr.last
+				}
+				p.info.Chunks[i] = ChunkProgress{Source: pr.source, ID: pr.id, Attempts: 1, Duration: now.Sub(pr.chunkEntered)}
+			}
+		}
+		p.mu.Unlock()
+		pr.off += int64(n)
+		pr.last = now
+	}
+	return n, err
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context whose downloads report to p.
+func WithProgress(ctx context.Context, p *Progress) context.Context {
+	if p == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey{}, p)
+}
+
+// progressFrom returns the Progress of ctx, or nil.
+func progressFrom(ctx context.Context) *Progress {
+	p, _ := ctx.Value(progressKey{}).(*Progress)
+	return p
+}
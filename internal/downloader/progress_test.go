@@ -0,0 +1,128 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestProgressChunkedDownload(t *testing.T) {
+	data := testData(3*1024 + 100)
+	hash := hashBytes(data)
+	peer := &mockSource{id: "peer1", sourceType: SourceTypePeer, data: data, rangeSupport: true}
+
+	d := New(&Config{ChunkSize: 1024, MinChunkedSize: 1024})
+	tracker := NewTracker(10)
+	p := tracker.Start(hash, "pool/main/h/hello/hello_1.0_amd64.deb", int64(len(data)))
+
+	result, err := d.Download(WithProgress(context.Background(), p), hash, int64(len(data)), []Source{peer}, nil)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	info := p.Info()
+	if len(info.Chunks) != 4 || info.ChunkSize != 1024 {
+		t.Fatalf("chunk map = %d chunks of %d, want 4 of 1024", len(info.Chunks), info.ChunkSize)
+	}
+	for i, c := range info.Chunks {
+		if c.Source != SourceTypePeer || c.ID != "peer1" || c.Attempts != 1 {
+			t.Errorf("chunk %d = %+v", i, c)
+		}
+	}
+	if info.Received != int64(len(data)) || info.Source != SourceTypePeer || !info.Finished.IsZero() {
+		t.Errorf("active download = %+v", info)
+	}
+	var sum int64
+	for _, n := range info.Throughput {
+		sum += n
+	}
+	if sum != int64(len(data)) {
+		t.Errorf("throughput adds up to %d, want %d", sum, len(data))
+	}
+
+	p.Finish(result.Source, nil)
+	all := tracker.Downloads()
+	if len(all) != 1 || all[0].Finished.IsZero() || all[0].Source != SourceTypePeer {
+		t.Errorf("finished downloads = %+v", all)
+	}
+}
+
+func TestProgressRacingDownload(t *testing.T) {
+	data := testData(500)
+	hash := hashBytes(data)
+	mirror := &mockSource{id: "http://mirror/x.deb", sourceType: SourceTypeMirror, data: data}
+
+	p := NewTracker(10).Start(hash, "x.deb", 0)
+	if _, err := New(nil).Download(WithProgress(context.Background(), p), hash, 0, nil, mirror); err != nil {
+		t.Fatal(err)
+	}
+	info := p.Info()
+	if len(info.Chunks) != 1 || info.Chunks[0].Source != SourceTypeMirror || info.Received != 500 {
+		t.Errorf("racing download = %+v", info)
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+	data := testData(DefaultChunkSize + 10)
+	p := NewTracker(10).Start("h", "x.deb", int64(len(data)))
+
+	// A failed attempt at the second chunk, then the mirror streams it all
+	p.layout(int64(len(data)), DefaultChunkSize)
+	p.chunkDone(&Chunk{Index: 1, Attempts: 3, Error: errors.New("timeout")})
+	if _, err := io.Copy(io.Discard, p.Reader(bytes.NewReader(data), SourceTypeMirror, "http://mirror")); err != nil {
+		t.Fatal(err)
+	}
+	p.Note("parallel download failed")
+	p.Finish(SourceTypeMirror, nil)
+
+	info := p.Info()
+	if len(info.Chunks) != 2 {
+		t.Fatalf("chunks = %+v", info.Chunks)
+	}
+	for i, c := range info.Chunks {
+		if c.Source != SourceTypeMirror {
+			t.Errorf("chunk %d from %q, want mirror", i, c.Source)
+		}
+	}
+	if info.Retries != 2 || info.Received != int64(len(data)) || len(info.Notes) != 1 {
+		t.Errorf("download = retries %d, received %d, notes %v", info.Retries, info.Received, info.Notes)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(2)
+	a := tracker.Start("a", "a.deb", 10)
+	b := tracker.Start("b", "b.deb", 10)
+	c := tracker.Start("c", "c.deb", 10)
+
+	// Fetched by a path not followed chunk by chunk
+	a.Finish(SourceTypePeer, nil)
+	a.Finish(SourceTypeMirror, nil) // only the first counts
+	b.Finish("", errors.New("mirror fetch failed"))
+
+	all := tracker.Downloads()
+	if len(all) != 3 || all[0].Name != "c.deb" || all[1].Name != "b.deb" || all[2].Name != "a.deb" {
+		t.Fatalf("downloads = %+v", all)
+	}
+	if all[1].Error == "" || all[2].Source != SourceTypePeer || all[2].Received != 10 || len(all[2].Chunks) != 1 {
+		t.Errorf("finished = %+v, %+v", all[1], all[2])
+	}
+
+	// Only the two most recent finished downloads are kept
+	c.Finish(SourceTypeMirror, nil)
+	tracker.Start("d", "d.deb", 10).Finish(SourceTypeMirror, nil)
+	if all := tracker.Downloads(); len(all) != 2 || all[0].Name != "d.deb" || all[1].Name != "c.deb" {
+		t.Errorf("downloads = %+v", all)
+	}
+
+	// Untracked downloads
+	var nilTracker *Tracker
+	p := nilTracker.Start("x", "x.deb", 1)
+	p.Note("ignored")
+	p.Finish(SourceTypePeer, nil)
+	if WithProgress(context.Background(), p) != context.Background() || nilTracker.Downloads() != nil {
+		t.Error("nil tracker tracked a download")
+	}
+}
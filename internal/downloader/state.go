@@ -5,8 +5,61 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
+
+	"github.com/debswarm/debswarm/internal/migrate"
 )
 
+// StateSchema holds the resume state of downloads. Its tables live in the
+// cache's database; apply it with cache.Cache.Migrate before use.
+var StateSchema = migrate.Schema{
+	Name: "downloads",
+	Migrations: []migrate.Migration{
+		{Version: 1, Description: "baseline", Up: baselineStateSchema},
+	},
+}
+
+// baselineStateSchema creates the tables as they were before the schema was
+// versioned; the cache created them then, and added retry_count ad hoc.
+func baselineStateSchema(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS downloads (
+			id TEXT PRIMARY KEY,
+			url TEXT NOT NULL,
+			expected_size INTEGER NOT NULL,
+			completed_size INTEGER DEFAULT 0,
+			chunk_size INTEGER NOT NULL,
+			total_chunks INTEGER NOT NULL,
+			status TEXT DEFAULT 'pending',
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL,
+			error TEXT,
+			retry_count INTEGER DEFAULT 0
+		);
+
+		CREATE TABLE IF NOT EXISTS download_chunks (
+			download_id TEXT NOT NULL,
+			chunk_index INTEGER NOT NULL,
+			start_offset INTEGER NOT NULL,
+			end_offset INTEGER NOT NULL,
+			status TEXT DEFAULT 'pending',
+			completed_at INTEGER,
+			PRIMARY KEY (download_id, chunk_index),
+			FOREIGN KEY (download_id) REFERENCES downloads(id) ON DELETE CASCADE
+		);
+	`)
+	if err != nil {
+		return err
+	}
+	if err := migrate.AddColumn(tx, "downloads", "retry_count", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_downloads_status ON downloads(status);
+		CREATE INDEX IF NOT EXISTS idx_download_chunks_status ON download_chunks(download_id, status);
+	`)
+	return err
+}
+
 // DownloadState represents a resumable download
 type DownloadState struct {
 	ID            string
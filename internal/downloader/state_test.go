@@ -6,7 +6,10 @@ import (
 	"testing"
 	"time"
 
+	"go.uber.org/zap"
 	_ "modernc.org/sqlite"
+
+	"github.com/debswarm/debswarm/internal/migrate"
 )
 
 func setupTestDB(t *testing.T) *sql.DB {
@@ -18,38 +21,8 @@ func setupTestDB(t *testing.T) *sql.DB {
 		t.Fatalf("Failed to open test database: %v", err)
 	}
 
-	// Create required tables - execute separately to avoid multi-statement issues
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS downloads (
-			id TEXT PRIMARY KEY,
-			url TEXT NOT NULL,
-			expected_size INTEGER NOT NULL,
-			completed_size INTEGER DEFAULT 0,
-			chunk_size INTEGER NOT NULL,
-			total_chunks INTEGER NOT NULL,
-			status TEXT NOT NULL,
-			created_at INTEGER NOT NULL,
-			updated_at INTEGER NOT NULL,
-			error TEXT,
-			retry_count INTEGER DEFAULT 0
-		)`)
-	if err != nil {
-		t.Fatalf("Failed to create downloads table: %v", err)
-	}
-
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS download_chunks (
-			download_id TEXT NOT NULL,
-			chunk_index INTEGER NOT NULL,
-			start_offset INTEGER NOT NULL,
-			end_offset INTEGER NOT NULL,
-			status TEXT NOT NULL,
-			completed_at INTEGER,
-			PRIMARY KEY (download_id, chunk_index),
-			FOREIGN KEY (download_id) REFERENCES downloads(id) ON DELETE CASCADE
-		)`)
-	if err != nil {
-		t.Fatalf("Failed to create download_chunks table: %v", err)
+	if err := migrate.Apply(db, zap.NewNop(), StateSchema); err != nil {
+		t.Fatalf("Failed to create download state tables: %v", err)
 	}
 
 	t.Cleanup(func() { db.Close() })
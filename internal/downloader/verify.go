@@ -0,0 +1,95 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// chunkHasher hashes a chunked download while it is assembled. Chunks
+// complete out of order, but SHA256 must see the file in order, so each
+// chunk is hashed as soon as every chunk before it is in: in the common case
+// straight from the buffer that was just written, so the assembled file is
+// never read back. Out-of-order chunks are held in memory up to maxHeld
+// bytes; beyond that their data is dropped and read back from the assembly
+// file (usually still in the page cache) when their turn comes. Chunks
+// recovered from an interrupted download are always read back.
+type chunkHasher struct {
+	h         hash.Hash
+	file      io.ReaderAt
+	chunkSize int64
+	size      int64
+	numChunks int
+
+	next      int            // first chunk not yet hashed
+	available []bool         // chunk is in the assembly file
+	held      map[int][]byte // out-of-order chunk data kept for hashing
+	heldBytes int64
+	maxHeld   int64
+}
+
+func newChunkHasher(file io.ReaderAt, size, chunkSize int64, maxHeld int64) *chunkHasher {
+	numChunks := int((size + chunkSize - 1) / chunkSize)
+	return &chunkHasher{
+		h:         sha256.New(),
+		file:      file,
+		chunkSize: chunkSize,
+		size:      size,
+		numChunks: numChunks,
+		available: make([]bool, numChunks),
+		held:      make(map[int][]byte),
+		maxHeld:   maxHeld,
+	}
+}
+
+// addFromDisk marks a chunk already in the assembly file, such as one
+// recovered on resume
+func (c *chunkHasher) addFromDisk(index int) error {
+	c.available[index] = true
+	return c.advance()
+}
+
+// add hashes a chunk just written to the assembly file, or holds on to it
+// until the chunks before it are in. The caller must not modify data after.
+func (c *chunkHasher) add(index int, data []byte) error {
+	c.available[index] = true
+	if index == c.next {
+		c.h.Write(data)
+		c.next++
+		return c.advance()
+	}
+	if c.heldBytes+int64(len(data)) <= c.maxHeld {
+		c.held[index] = data
+		c.heldBytes += int64(len(data))
+	}
+	return nil
+}
+
+// advance hashes every chunk that is now next in line
+func (c *chunkHasher) advance() error {
+	for c.next < c.numChunks && c.available[c.next] {
+		if data, ok := c.held[c.next]; ok {
+			c.h.Write(data)
+			delete(c.held, c.next)
+			c.heldBytes -= int64(len(data))
+		} else {
+			start := int64(c.next) * c.chunkSize
+			end := min(start+c.chunkSize, c.size)
+			if _, err := io.Copy(c.h, io.NewSectionReader(c.file, start, end-start)); err != nil {
+				return fmt.Errorf("failed to read chunk %d for hashing: %w", c.next, err)
+			}
+		}
+		c.next++
+	}
+	return nil
+}
+
+// sum returns the hex-encoded SHA256 of the file once every chunk is in
+func (c *chunkHasher) sum() (string, error) {
+	if c.next != c.numChunks {
+		return "", fmt.Errorf("chunk %d missing", c.next)
+	}
+	return hex.EncodeToString(c.h.Sum(nil)), nil
+}
@@ -0,0 +1,67 @@
+package downloader
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/hashutil"
+)
+
+func TestChunkHasher(t *testing.T) {
+	const chunkSize = 1000
+	data := make([]byte, 10*chunkSize+123)
+	_, _ = rand.Read(data)
+	want := hashutil.HashBytes(data)
+	chunk := func(i int) []byte {
+		return data[i*chunkSize : min((i+1)*chunkSize, len(data))]
+	}
+
+	tests := []struct {
+		name     string
+		order    []int
+		fromDisk []int
+		maxHeld  int64
+	}{
+		{"in order", []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, nil, 0},
+		{"reversed, held in memory", []int{10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0}, nil, 100 * chunkSize},
+		{"reversed, read back", []int{10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0}, nil, 2 * chunkSize},
+		{"resumed", []int{3, 1, 10, 7, 9, 5, 8}, []int{0, 2, 4, 6}, chunkSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The assembly file: only chunks that have "arrived" are readable
+			file := make([]byte, len(data))
+			h := newChunkHasher(bytes.NewReader(file), int64(len(data)), chunkSize, tt.maxHeld)
+			for _, i := range tt.fromDisk {
+				copy(file[i*chunkSize:], chunk(i))
+			}
+			for _, i := range tt.fromDisk {
+				if err := h.addFromDisk(i); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for n, i := range tt.order {
+				if n == len(tt.order)-1 {
+					if _, err := h.sum(); err == nil {
+						t.Error("sum() before the last chunk should fail")
+					}
+				}
+				copy(file[i*chunkSize:], chunk(i))
+				if err := h.add(i, chunk(i)); err != nil {
+					t.Fatal(err)
+				}
+				if h.heldBytes > tt.maxHeld {
+					t.Fatalf("holding %d bytes, limit %d", h.heldBytes, tt.maxHeld)
+				}
+			}
+			got, err := h.sum()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("sum() = %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package fleet
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/pelletier/go-toml/v2"
+	"go.uber.org/zap"
+)
+
+// SharedProtocolID is the libp2p protocol carrying leader announcements. Each
+// announcement is one JSON object, terminated by the sender closing its write
+// side.
+const SharedProtocolID = "/debswarm/fleet-shared/1.0.0"
+
+const (
+	// maxAnnouncementSize bounds an announcement read from a peer.
+	maxAnnouncementSize = 64 * 1024
+
+	sharedTimeout = 10 * time.Second
+
+	// candidateExpiry is how many announce intervals a candidate stays
+	// electable without being heard from.
+	candidateExpiry = 3
+)
+
+// SharedSettings are the settings a fleet leader distributes to followers.
+// Every section is optional; a follower only overrides what is present.
+type SharedSettings struct {
+	Scheduler *SharedScheduler `json:"scheduler,omitempty" toml:"scheduler"`
+	Retention *SharedRetention `json:"retention,omitempty" toml:"retention"`
+	Mirrors   *SharedMirrors   `json:"mirrors,omitempty" toml:"mirrors"`
+}
+
+// SharedScheduler mirrors the [scheduler] config section.
+type SharedScheduler struct {
+	Windows           []SharedWindow `json:"windows" toml:"windows"`
+	Timezone          string         `json:"timezone,omitempty" toml:"timezone"`
+	OutsideWindowRate string         `json:"outside_window_rate,omitempty" toml:"outside_window_rate"`
+	InsideWindowRate  string         `json:"inside_window_rate,omitempty" toml:"inside_window_rate"`
+}
+
+// SharedWindow is one sync window, as in [[scheduler.windows]].
+type SharedWindow struct {
+	Days      []string `json:"days" toml:"days"`
+	StartTime string   `json:"start_time" toml:"start_time"`
+	EndTime   string   `json:"end_time" toml:"end_time"`
+}
+
+// SharedRetention mirrors the cache size limits of the [cache] config section.
+type SharedRetention struct {
+	MaxSize      string `json:"max_size,omitempty" toml:"max_size"`
+	MinFreeSpace string `json:"min_free_space,omitempty" toml:"min_free_space"`
+}
+
+// SharedMirrors is the mirror pool: the upstream hosts and address ranges of
+// the [proxy] config section the fleet may fetch from.
+type SharedMirrors struct {
+	AllowedHosts []string `json:"allowed_hosts,omitempty" toml:"allowed_hosts"`
+	AllowedCIDRs []string `json:"allowed_cidrs,omitempty" toml:"allowed_cidrs"`
+}
+
+// LoadSharedSettings reads the TOML file a leader candidate distributes.
+func LoadSharedSettings(path string) (*SharedSettings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s SharedSettings
+	if err := toml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Digest identifies the settings' content, so followers apply a given set
+// only once.
+func (s *SharedSettings) Digest() string {
+	data, _ := json.Marshal(s)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// announcement is what a leader candidate sends to its fleet peers.
+type announcement struct {
+	Settings *SharedSettings `json:"settings"`
+}
+
+// SharedConfig configures leader election and settings distribution.
+type SharedConfig struct {
+	// Settings makes this node a leadership candidate distributing them.
+	// nil means the node never leads.
+	Settings *SharedSettings
+	// Accept applies settings received from the leader. Without it the node
+	// still takes part in the election but keeps its own configuration.
+	Accept bool
+	// TrustedLeaders restricts which peers may be elected. Empty trusts any
+	// fleet peer.
+	TrustedLeaders []peer.ID
+	// Interval is how often a candidate announces itself.
+	Interval time.Duration
+}
+
+// Shared elects a fleet leader and distributes its settings.
+//
+// The election needs no extra round trips: every candidate announces itself
+// (with its settings) to the LAN peers each interval, and each node treats the
+// candidate with the lowest peer ID among those heard from recently as the
+// leader. Nodes hearing the same announcements agree, and when the leader goes
+// quiet the next-lowest candidate takes over after a few missed intervals.
+// Followers that opted in apply the leader's settings whenever they change.
+type Shared struct {
+	host   host.Host
+	peers  PeerProvider
+	cfg    SharedConfig
+	logger *zap.Logger
+
+	mu         sync.Mutex
+	candidates map[peer.ID]*candidate
+	leader     peer.ID
+	applied    string // digest of the settings last applied
+	onApply    func(from peer.ID, s *SharedSettings)
+}
+
+type candidate struct {
+	settings *SharedSettings
+	seen     time.Time
+}
+
+// NewShared registers the announcement stream handler on h.
+func NewShared(h host.Host, peers PeerProvider, cfg SharedConfig, logger *zap.Logger) *Shared {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	s := &Shared{
+		host:       h,
+		peers:      peers,
+		cfg:        cfg,
+		logger:     logger,
+		candidates: make(map[peer.ID]*candidate),
+	}
+	h.SetStreamHandler(protocol.ID(SharedProtocolID), s.handleStream)
+	return s
+}
+
+// Close removes the stream handler.
+func (s *Shared) Close() {
+	s.host.RemoveStreamHandler(protocol.ID(SharedProtocolID))
+}
+
+// SetOnApply registers the callback that applies the leader's settings. It
+// runs without locks held, once per distinct set of settings.
+func (s *Shared) SetOnApply(fn func(from peer.ID, settings *SharedSettings)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onApply = fn
+}
+
+// Leader returns the current leader, or "" if no candidate is known.
+func (s *Shared) Leader() peer.ID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.electLocked(time.Now())
+}
+
+// IsLeader reports whether this node is the current leader.
+func (s *Shared) IsLeader() bool {
+	return s.Leader() == s.host.ID()
+}
+
+// Run announces this node every interval until ctx is done. Nodes that are
+// not candidates only re-evaluate the election, so a leader that went quiet
+// is replaced on time.
+func (s *Shared) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		s.Announce(ctx)
+		s.reelect()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Announce sends this candidate's settings to every LAN peer and returns how
+// many accepted the stream. Non-candidates send nothing.
+func (s *Shared) Announce(ctx context.Context) int {
+	if s.cfg.Settings == nil {
+		return 0
+	}
+	data, err := json.Marshal(announcement{Settings: s.cfg.Settings})
+	if err != nil {
+		return 0
+	}
+	sent := 0
+	for _, info := range s.peers.GetMDNSPeers() {
+		if info.ID == s.host.ID() {
+			continue
+		}
+		if err := s.sendTo(ctx, info.ID, data); err != nil {
+			s.logger.Debug("Failed to announce fleet candidacy",
+				zap.String("peer", info.ID.String()), zap.Error(err))
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+func (s *Shared) sendTo(ctx context.Context, p peer.ID, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, sharedTimeout)
+	defer cancel()
+	st, err := s.host.NewStream(ctx, p, protocol.ID(SharedProtocolID))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = st.Close() }()
+	_ = st.SetWriteDeadline(time.Now().Add(sharedTimeout))
+	if _, err := st.Write(data); err != nil {
+		_ = st.Reset()
+		return err
+	}
+	return st.CloseWrite()
+}
+
+func (s *Shared) handleStream(st network.Stream) {
+	defer func() { _ = st.Close() }()
+	from := st.Conn().RemotePeer()
+	_ = st.SetReadDeadline(time.Now().Add(sharedTimeout))
+
+	data, err := io.ReadAll(io.LimitReader(st, maxAnnouncementSize+1))
+	if err != nil || len(data) > maxAnnouncementSize {
+		_ = st.Reset()
+		return
+	}
+	var a announcement
+	if err := json.Unmarshal(data, &a); err != nil || a.Settings == nil {
+		s.logger.Debug("Invalid fleet announcement",
+			zap.String("peer", from.String()), zap.Error(err))
+		return
+	}
+	if !s.trusted(from) {
+		s.logger.Debug("Ignoring fleet announcement from untrusted peer",
+			zap.String("peer", from.String()))
+		return
+	}
+
+	s.mu.Lock()
+	s.candidates[from] = &candidate{settings: a.Settings, seen: time.Now()}
+	s.mu.Unlock()
+	s.reelect()
+}
+
+// reelect recomputes the leader and applies its settings if they are new.
+func (s *Shared) reelect() {
+	s.mu.Lock()
+	leader := s.electLocked(time.Now())
+	var settings *SharedSettings
+	if c := s.candidates[leader]; c != nil {
+		settings = c.settings
+	}
+	apply := s.onApply
+	if !s.cfg.Accept || settings == nil || leader == s.host.ID() || apply == nil {
+		s.mu.Unlock()
+		return
+	}
+	digest := settings.Digest()
+	if digest == s.applied {
+		s.mu.Unlock()
+		return
+	}
+	s.applied = digest
+	s.mu.Unlock()
+
+	s.logger.Info("Applying settings from fleet leader",
+		zap.String("leader", leader.String()),
+		zap.String("digest", digest[:16]))
+	apply(leader, settings)
+}
+
+// electLocked drops expired candidates and returns the lowest live candidate
+// ID, counting this node when it is a candidate. Caller holds s.mu.
+func (s *Shared) electLocked(now time.Time) peer.ID {
+	expiry := candidateExpiry * s.cfg.Interval
+	var leader peer.ID
+	if s.cfg.Settings != nil && s.trusted(s.host.ID()) {
+		leader = s.host.ID()
+	}
+	for id, c := range s.candidates {
+		if now.Sub(c.seen) > expiry {
+			delete(s.candidates, id)
+			continue
+		}
+		if leader == "" || id < leader {
+			leader = id
+		}
+	}
+	if leader != s.leader {
+		s.logger.Info("Fleet leader changed",
+			zap.String("leader", leader.String()),
+			zap.Bool("self", leader != "" && leader == s.host.ID()))
+		s.leader = leader
+	}
+	return leader
+}
+
+func (s *Shared) trusted(id peer.ID) bool {
+	if len(s.cfg.TrustedLeaders) == 0 {
+		return true
+	}
+	for _, t := range s.cfg.TrustedLeaders {
+		if t == id {
+			return true
+		}
+	}
+	return false
+}
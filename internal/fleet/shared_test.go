@@ -0,0 +1,228 @@
+package fleet
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+// sharedTestNet returns n connected mocknet hosts and a peer provider for each
+// that lists the other hosts as LAN peers.
+func sharedTestNet(t *testing.T, n int) ([]host.Host, []*mockPeerProvider) {
+	t.Helper()
+	mn := mocknet.New()
+	t.Cleanup(func() { _ = mn.Close() })
+
+	hosts := make([]host.Host, n)
+	for i := range hosts {
+		h, err := mn.GenPeer()
+		if err != nil {
+			t.Fatalf("GenPeer: %v", err)
+		}
+		hosts[i] = h
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("LinkAll: %v", err)
+	}
+	if err := mn.ConnectAllButSelf(); err != nil {
+		t.Fatalf("ConnectAllButSelf: %v", err)
+	}
+
+	providers := make([]*mockPeerProvider, n)
+	for i := range hosts {
+		providers[i] = &mockPeerProvider{}
+		for j, other := range hosts {
+			if i != j {
+				providers[i].peers = append(providers[i].peers, peer.AddrInfo{ID: other.ID()})
+			}
+		}
+	}
+	return hosts, providers
+}
+
+// appliedRecorder collects the settings a follower applies.
+type appliedRecorder struct {
+	mu   sync.Mutex
+	from []peer.ID
+	got  []*SharedSettings
+}
+
+func (r *appliedRecorder) apply(from peer.ID, s *SharedSettings) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.from = append(r.from, from)
+	r.got = append(r.got, s)
+}
+
+func (r *appliedRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.got)
+}
+
+func settingsWithTimezone(tz string) *SharedSettings {
+	return &SharedSettings{Scheduler: &SharedScheduler{
+		Windows:  []SharedWindow{{Days: []string{"weekday"}, StartTime: "22:00", EndTime: "06:00"}},
+		Timezone: tz,
+	}}
+}
+
+func TestShared_LowestCandidateLeadsAndFollowerApplies(t *testing.T) {
+	hosts, providers := sharedTestNet(t, 3)
+	a, b, follower := hosts[0], hosts[1], hosts[2]
+
+	sa := NewShared(a, providers[0], SharedConfig{Settings: settingsWithTimezone("UTC")}, nil)
+	defer sa.Close()
+	sb := NewShared(b, providers[1], SharedConfig{Settings: settingsWithTimezone("Europe/Berlin")}, nil)
+	defer sb.Close()
+	sf := NewShared(follower, providers[2], SharedConfig{Accept: true}, nil)
+	defer sf.Close()
+	rec := &appliedRecorder{}
+	sf.SetOnApply(rec.apply)
+
+	if sa.Announce(context.Background()) != 2 || sb.Announce(context.Background()) != 2 {
+		t.Fatal("candidates should reach both other peers")
+	}
+
+	want := a.ID()
+	wantTZ := "UTC"
+	if b.ID() < a.ID() {
+		want, wantTZ = b.ID(), "Europe/Berlin"
+	}
+	waitFor(t, func() bool { return sf.Leader() == want && rec.count() > 0 })
+
+	if got := sa.Leader(); got != want {
+		t.Errorf("candidate A elected %s, want %s", got, want)
+	}
+	if got := sb.Leader(); got != want {
+		t.Errorf("candidate B elected %s, want %s", got, want)
+	}
+	if sa.IsLeader() == sb.IsLeader() {
+		t.Error("exactly one candidate should consider itself leader")
+	}
+
+	rec.mu.Lock()
+	last := rec.got[len(rec.got)-1]
+	from := rec.from[len(rec.from)-1]
+	rec.mu.Unlock()
+	if from != want || last.Scheduler.Timezone != wantTZ {
+		t.Fatalf("applied settings from %s (tz %q), want leader %s (tz %q)",
+			from, last.Scheduler.Timezone, want, wantTZ)
+	}
+
+	// Re-announcing unchanged settings must not re-apply them.
+	n := rec.count()
+	sa.Announce(context.Background())
+	sb.Announce(context.Background())
+	time.Sleep(100 * time.Millisecond)
+	if rec.count() != n {
+		t.Errorf("unchanged settings applied again: %d applications, want %d", rec.count(), n)
+	}
+}
+
+func TestShared_TrustedLeadersAndOptIn(t *testing.T) {
+	hosts, providers := sharedTestNet(t, 3)
+	leader, trusting, declining := hosts[0], hosts[1], hosts[2]
+
+	sl := NewShared(leader, providers[0], SharedConfig{Settings: settingsWithTimezone("UTC")}, nil)
+	defer sl.Close()
+
+	// Trusts only some other peer, so it must never elect the announcing node.
+	strict := NewShared(trusting, providers[1], SharedConfig{Accept: true, TrustedLeaders: []peer.ID{declining.ID()}}, nil)
+	defer strict.Close()
+	strictRec := &appliedRecorder{}
+	strict.SetOnApply(strictRec.apply)
+
+	// Has not opted in: learns the leader but keeps its own settings.
+	optOut := NewShared(declining, providers[2], SharedConfig{}, nil)
+	defer optOut.Close()
+	optOutRec := &appliedRecorder{}
+	optOut.SetOnApply(optOutRec.apply)
+
+	sl.Announce(context.Background())
+	waitFor(t, func() bool { return optOut.Leader() == leader.ID() })
+	time.Sleep(100 * time.Millisecond)
+
+	if got := strict.Leader(); got != "" {
+		t.Errorf("untrusted candidate elected: %s", got)
+	}
+	if strictRec.count() != 0 {
+		t.Error("settings from an untrusted peer were applied")
+	}
+	if optOutRec.count() != 0 {
+		t.Error("settings applied on a node that did not opt in")
+	}
+}
+
+func TestShared_LeaderExpires(t *testing.T) {
+	hosts, providers := sharedTestNet(t, 2)
+	sl := NewShared(hosts[0], providers[0], SharedConfig{Settings: settingsWithTimezone("UTC"), Interval: 20 * time.Millisecond}, nil)
+	defer sl.Close()
+	sf := NewShared(hosts[1], providers[1], SharedConfig{Accept: true, Interval: 20 * time.Millisecond}, nil)
+	defer sf.Close()
+
+	sl.Announce(context.Background())
+	waitFor(t, func() bool { return sf.Leader() == hosts[0].ID() })
+
+	// Three missed intervals later the leader is gone.
+	waitFor(t, func() bool { return sf.Leader() == "" })
+}
+
+func TestLoadSharedSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.toml")
+	data := `
+[scheduler]
+timezone = "UTC"
+outside_window_rate = "100KB/s"
+
+[[scheduler.windows]]
+days = ["weekend"]
+start_time = "00:00"
+end_time = "23:59"
+
+[retention]
+max_size = "50GB"
+
+[mirrors]
+allowed_hosts = ["mirror.lab.example"]
+allowed_cidrs = ["10.0.0.0/8"]
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSharedSettings(path)
+	if err != nil {
+		t.Fatalf("LoadSharedSettings: %v", err)
+	}
+	if s.Scheduler == nil || len(s.Scheduler.Windows) != 1 || s.Scheduler.Windows[0].Days[0] != "weekend" {
+		t.Fatalf("scheduler = %+v", s.Scheduler)
+	}
+	if s.Retention == nil || s.Retention.MaxSize != "50GB" {
+		t.Fatalf("retention = %+v", s.Retention)
+	}
+	if s.Mirrors == nil || len(s.Mirrors.AllowedHosts) != 1 || len(s.Mirrors.AllowedCIDRs) != 1 {
+		t.Fatalf("mirrors = %+v", s.Mirrors)
+	}
+	if s.Digest() == settingsWithTimezone("UTC").Digest() {
+		t.Error("different settings share a digest")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition not met within 5s")
+}
@@ -0,0 +1,108 @@
+package fleethttp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/multiformats/go-multiaddr"
+)
+
+// MaxContentSize bounds a response, as for P2P transfers.
+const MaxContentSize = 500 * 1024 * 1024
+
+// Client fetches content from fleet peers' servers.
+type Client struct {
+	port   int
+	client *http.Client
+}
+
+// NewClient creates a Client reaching peers' servers on port, which every
+// fleet node uses.
+func NewClient(tlsCfg *tls.Config, port int) *Client {
+	return &Client{
+		port: port,
+		client: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig:       tlsCfg,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: 30 * time.Second,
+				IdleConnTimeout:       90 * time.Second,
+				MaxIdleConnsPerHost:   2,
+			},
+		},
+	}
+}
+
+// FetchRange fetches bytes [start, end) of the content with sha256Hash
+// from a peer at one of addrs, trying its LAN addresses in turn. end <= 0
+// means to the end of the content, as for p2p.Node.DownloadRange. The data
+// is not verified here.
+func (c *Client) FetchRange(ctx context.Context, addrs []multiaddr.Multiaddr, sha256Hash string, start, end int64) ([]byte, error) {
+	hostports := LANAddrs(addrs, c.port)
+	if len(hostports) == 0 {
+		return nil, errors.New("fleet peer has no LAN address")
+	}
+	var errs []error
+	for _, hostport := range hostports {
+		data, err := c.fetch(ctx, hostport, sha256Hash, start, end)
+		if err == nil {
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", hostport, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (c *Client) fetch(ctx context.Context, hostport, sha256Hash string, start, end int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+hostport+ContentPath+sha256Hash, nil)
+	if err != nil {
+		return nil, err
+	}
+	partial := start > 0 || end > 0
+	switch {
+	case end > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	case start > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	want := http.StatusOK
+	if partial {
+		want = http.StatusPartialContent
+	}
+	if resp.StatusCode != want {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if resp.ContentLength > MaxContentSize {
+		return nil, fmt.Errorf("content too large: %d bytes", resp.ContentLength)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxContentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxContentSize {
+		return nil, errors.New("content too large")
+	}
+	if resp.ContentLength >= 0 && int64(len(data)) != resp.ContentLength {
+		return nil, fmt.Errorf("short response: %d of %d bytes", len(data), resp.ContentLength)
+	}
+	return data, nil
+}
+
+// Close releases idle connections.
+func (c *Client) Close() {
+	c.client.CloseIdleConnections()
+}
@@ -0,0 +1,237 @@
+package fleethttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/multiformats/go-multiaddr"
+	"go.uber.org/zap"
+)
+
+const testHash = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+// testCA issues certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fleet CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	ca := &testCA{cert: cert, key: key, dir: t.TempDir()}
+	writePEM(t, filepath.Join(ca.dir, "ca.pem"), "CERTIFICATE", der)
+	return ca
+}
+
+// issue writes a node certificate and key and returns the TLS files
+func (ca *testCA) issue(t *testing.T, name string) TLSFiles {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := TLSFiles{
+		CertFile: filepath.Join(ca.dir, name+".pem"),
+		KeyFile:  filepath.Join(ca.dir, name+".key"),
+		CAFile:   filepath.Join(ca.dir, "ca.pem"),
+	}
+	writePEM(t, files.CertFile, "CERTIFICATE", der)
+	writePEM(t, files.KeyFile, "EC PRIVATE KEY", keyDER)
+	return files
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// opener serves one piece of content, without seeking
+type opener []byte
+
+func (o opener) Open(hash string) (io.ReadCloser, int64, error) {
+	if hash != testHash {
+		return nil, 0, errors.New("not found")
+	}
+	return io.NopCloser(bytes.NewReader(o)), int64(len(o)), nil
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestServerClient(t *testing.T) {
+	ca := newTestCA(t)
+	serverTLS, _, err := LoadTLS(ca.issue(t, "seed"))
+	if err != nil {
+		t.Fatalf("LoadTLS: %v", err)
+	}
+	_, clientTLS, err := LoadTLS(ca.issue(t, "laptop"))
+	if err != nil {
+		t.Fatalf("LoadTLS: %v", err)
+	}
+
+	content := []byte("the quick brown fox jumps over the lazy dog")
+	var refuse atomic.Bool
+	port := freePort(t)
+	srv := NewServer(ServerConfig{
+		Addr:    "127.0.0.1:" + strconv.Itoa(port),
+		TLS:     serverTLS,
+		Content: opener(content),
+		Accept:  func() bool { return !refuse.Load() },
+	}, zap.NewNop())
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer func() { _ = srv.Close() }()
+
+	addrs := []multiaddr.Multiaddr{
+		multiaddr.StringCast("/ip4/8.8.8.8/tcp/4001"), // public, skipped
+		multiaddr.StringCast("/ip4/127.0.0.1/tcp/4001"),
+	}
+	client := NewClient(clientTLS, port)
+	defer client.Close()
+	ctx := context.Background()
+
+	data, err := client.FetchRange(ctx, addrs, testHash, 0, -1)
+	if err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("whole content = %q, %v", data, err)
+	}
+	data, err = client.FetchRange(ctx, addrs, testHash, 4, 9)
+	if err != nil || string(data) != "quick" {
+		t.Errorf("range [4, 9) = %q, %v", data, err)
+	}
+	data, err = client.FetchRange(ctx, addrs, testHash, 40, 0)
+	if err != nil || string(data) != "dog" {
+		t.Errorf("range [40, end) = %q, %v", data, err)
+	}
+	if _, err := client.FetchRange(ctx, addrs, testHash[:63]+"0", 0, -1); err == nil {
+		t.Error("missing content fetched")
+	}
+
+	refuse.Store(true)
+	if _, err := client.FetchRange(ctx, addrs, testHash, 0, -1); err == nil {
+		t.Error("fetched while the server refuses uploads")
+	}
+	refuse.Store(false)
+
+	// Certificates from another CA are refused, both ways
+	strangerServerTLS, strangerTLS, err := LoadTLS(newTestCA(t).issue(t, "stranger"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger := NewClient(strangerTLS, port)
+	defer stranger.Close()
+	if _, err := stranger.FetchRange(ctx, addrs, testHash, 0, -1); err == nil {
+		t.Error("client with a foreign certificate was served")
+	}
+
+	strangerPort := freePort(t)
+	strangerSrv := NewServer(ServerConfig{
+		Addr:    "127.0.0.1:" + strconv.Itoa(strangerPort),
+		TLS:     strangerServerTLS,
+		Content: opener(content),
+	}, zap.NewNop())
+	if err := strangerSrv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer func() { _ = strangerSrv.Close() }()
+	if _, err := NewClient(clientTLS, strangerPort).FetchRange(ctx, addrs, testHash, 0, -1); err == nil {
+		t.Error("fetched from a server with a foreign certificate")
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header       string
+		start, end   int64
+		partial, bad bool
+	}{
+		{"", 0, 100, false, false},
+		{"bytes=10-19", 10, 20, true, false},
+		{"bytes=90-", 90, 100, true, false},
+		{"bytes=90-200", 90, 100, true, false},
+		{"bytes=100-", 0, 0, false, true},
+		{"bytes=20-10", 0, 0, false, true},
+		{"bytes=0-1,5-6", 0, 0, false, true},
+		{"items=0-1", 0, 0, false, true},
+	}
+	for _, tt := range tests {
+		start, end, partial, err := parseRange(tt.header, 100)
+		if (err != nil) != tt.bad || start != tt.start || end != tt.end || partial != tt.partial {
+			t.Errorf("parseRange(%q) = %d, %d, %v, %v", tt.header, start, end, partial, err)
+		}
+	}
+}
+
+func TestLANAddrs(t *testing.T) {
+	addrs := []multiaddr.Multiaddr{
+		multiaddr.StringCast("/ip4/192.168.1.20/tcp/4001"),
+		multiaddr.StringCast("/ip4/192.168.1.20/udp/4001/quic-v1"),
+		multiaddr.StringCast("/ip6/fd00::20/tcp/4001"),
+		multiaddr.StringCast("/ip4/203.0.113.7/tcp/4001"),
+		multiaddr.StringCast("/ip4/10.0.0.1/tcp/4001/p2p/12D3KooWJWoaqZhDaoEFshF7Rh1bpY9ohihFhzcW6d69Lr2NASuq/p2p-circuit"),
+	}
+	got := LANAddrs(addrs, 9980)
+	if len(got) != 2 || got[0] != "192.168.1.20:9980" || got[1] != "[fd00::20]:9980" {
+		t.Errorf("LANAddrs = %v", got)
+	}
+}
@@ -0,0 +1,188 @@
+package fleethttp
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Opener opens content by SHA256 hash, returning it and its size. A
+// p2p.ContentProvider is one.
+type Opener interface {
+	Open(sha256Hash string) (io.ReadCloser, int64, error)
+}
+
+// ServerConfig configures a Server.
+type ServerConfig struct {
+	Addr    string      // host:port to listen on
+	TLS     *tls.Config // from LoadTLS
+	Content Opener
+
+	// MaxConcurrent bounds the requests served at once; more are refused
+	// with 503 (0 = 4).
+	MaxConcurrent int
+
+	// Accept, if set, is asked before each upload; false refuses it with
+	// 503, e.g. while P2P is paused.
+	Accept func() bool
+
+	// Throttle, if set, wraps the response writer of a size-byte upload,
+	// so the node's upload rate limits apply.
+	Throttle func(ctx context.Context, w io.Writer, size int64) io.Writer
+}
+
+// Server serves content to fleet peers that present a certificate issued
+// by the fleet CA.
+type Server struct {
+	cfg    ServerConfig
+	logger *zap.Logger
+	slots  chan struct{}
+	server *http.Server
+}
+
+// NewServer creates a Server; Start begins serving.
+func NewServer(cfg ServerConfig, logger *zap.Logger) *Server {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 4
+	}
+	s := &Server{
+		cfg:    cfg,
+		logger: logger,
+		slots:  make(chan struct{}, cfg.MaxConcurrent),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+ContentPath+"{hash}", s.handleContent)
+	s.server = &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           mux,
+		TLSConfig:         cfg.TLS,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		MaxHeaderBytes:    16 << 10,
+	}
+	return s
+}
+
+// Start listens on the configured address and serves in the background.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := s.server.ServeTLS(ln, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Fleet HTTPS server stopped", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Close stops the server, ending uploads in progress.
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+func (s *Server) handleContent(w http.ResponseWriter, r *http.Request) {
+	hash := strings.ToLower(r.PathValue("hash"))
+	if len(hash) != 64 {
+		http.Error(w, "invalid hash", http.StatusBadRequest)
+		return
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		http.Error(w, "invalid hash", http.StatusBadRequest)
+		return
+	}
+	if s.cfg.Accept != nil && !s.cfg.Accept() {
+		http.Error(w, "not serving uploads", http.StatusServiceUnavailable)
+		return
+	}
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	default:
+		http.Error(w, "too many uploads", http.StatusServiceUnavailable)
+		return
+	}
+
+	reader, size, err := s.cfg.Content.Open(hash)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	defer func() { _ = reader.Close() }()
+
+	start, end, partial, err := parseRange(r.Header.Get("Range"), size)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	if start > 0 {
+		if seeker, ok := reader.(io.Seeker); ok {
+			_, err = seeker.Seek(start, io.SeekStart)
+		} else {
+			_, err = io.CopyN(io.Discard, reader, start)
+		}
+		if err != nil {
+			http.Error(w, "read failed", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	length := end - start
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	w.Header().Set("Accept-Ranges", "bytes")
+	if partial {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, size))
+		w.WriteHeader(http.StatusPartialContent)
+	}
+	var out io.Writer = w
+	if s.cfg.Throttle != nil {
+		out = s.cfg.Throttle(r.Context(), w, length)
+	}
+	if _, err := io.Copy(out, io.LimitReader(reader, length)); err != nil {
+		s.logger.Debug("Fleet HTTPS upload ended early",
+			zap.String("hash", hash[:16]+"..."), zap.String("client", r.RemoteAddr), zap.Error(err))
+	}
+}
+
+// parseRange parses a single "bytes=start-end" or "bytes=start-" range
+// against size, returning the half-open range [start, end) and whether a
+// range was asked for. No header is the whole content.
+func parseRange(header string, size int64) (start, end int64, partial bool, err error) {
+	if header == "" {
+		return 0, size, false, nil
+	}
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false, errors.New("unsupported range")
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, false, errors.New("invalid range")
+	}
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false, errors.New("range not satisfiable")
+	}
+	end = size
+	if last != "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < start {
+			return 0, 0, false, errors.New("invalid range")
+		}
+		end = min(n+1, size)
+	}
+	return start, end, true, nil
+}
@@ -0,0 +1,101 @@
+package hashutil
+
+import (
+	"context"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// FileCheck is a file to verify against an expected hex-encoded SHA256.
+type FileCheck struct {
+	Path     string
+	Expected string
+}
+
+// FileResult is the outcome of one FileCheck. Err is set when the file could
+// not be read (os.IsNotExist tells a missing file apart); otherwise Actual
+// holds its hash.
+type FileResult struct {
+	FileCheck
+	Actual string
+	Err    error
+}
+
+// OK reports whether the file was read and matched its expected hash.
+func (r FileResult) OK() bool {
+	return r.Err == nil && r.Actual == r.Expected
+}
+
+// VerifyFiles hashes files with a bounded pool of workers, one per CPU when
+// workers is 0 or less, so verifying many objects is not limited to one
+// core. report is called once per check, from one goroutine at a time, in
+// completion order. Checks not started when ctx is canceled are reported
+// with ctx's error.
+func VerifyFiles(ctx context.Context, checks []FileCheck, workers int, report func(FileResult)) {
+	VerifyFilesThrough(ctx, checks, workers, nil, report)
+}
+
+// VerifyFilesThrough is VerifyFiles reading each file through wrap, e.g. to
+// pace the reads; a nil wrap reads the files directly.
+func VerifyFilesThrough(ctx context.Context, checks []FileCheck, workers int, wrap func(io.Reader) io.Reader, report func(FileResult)) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	workers = min(workers, len(checks))
+
+	jobs := make(chan FileCheck)
+	results := make(chan FileResult)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for check := range jobs {
+				results <- verifyFile(ctx, check, wrap)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, check := range checks {
+			select {
+			case jobs <- check:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	reported := 0
+	for r := range results {
+		report(r)
+		reported++
+	}
+	// Checks never handed to a worker
+	for _, check := range checks[reported:] {
+		report(FileResult{FileCheck: check, Err: ctx.Err()})
+	}
+}
+
+func verifyFile(ctx context.Context, check FileCheck, wrap func(io.Reader) io.Reader) FileResult {
+	if err := ctx.Err(); err != nil {
+		return FileResult{FileCheck: check, Err: err}
+	}
+	f, err := os.Open(check.Path)
+	if err != nil {
+		return FileResult{FileCheck: check, Err: err}
+	}
+	defer func() { _ = f.Close() }()
+	var r io.Reader = f
+	if wrap != nil {
+		r = wrap(f)
+	}
+	actual, err := HashReader(r)
+	return FileResult{FileCheck: check, Actual: actual, Err: err}
+}
@@ -0,0 +1,104 @@
+package hashutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestVerifyFiles(t *testing.T) {
+	dir := t.TempDir()
+	var checks []FileCheck
+	for i := 0; i < 20; i++ {
+		data := []byte(fmt.Sprintf("package %d", i))
+		path := filepath.Join(dir, fmt.Sprintf("f%d", i))
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		expected := HashBytes(data)
+		if i == 7 {
+			expected = HashBytes([]byte("something else"))
+		}
+		checks = append(checks, FileCheck{Path: path, Expected: expected})
+	}
+	checks = append(checks, FileCheck{Path: filepath.Join(dir, "missing"), Expected: HashBytes(nil)})
+
+	for _, workers := range []int{0, 1, 4, 100} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			results := make(map[string]FileResult)
+			VerifyFiles(context.Background(), checks, workers, func(r FileResult) {
+				results[r.Path] = r
+			})
+			if len(results) != len(checks) {
+				t.Fatalf("got %d results, want %d", len(results), len(checks))
+			}
+			for i, check := range checks {
+				r := results[check.Path]
+				switch i {
+				case 7:
+					if r.OK() || r.Err != nil {
+						t.Errorf("mismatched file: OK=%v err=%v", r.OK(), r.Err)
+					}
+				case 20:
+					if !os.IsNotExist(r.Err) {
+						t.Errorf("missing file: err=%v", r.Err)
+					}
+				default:
+					if !r.OK() {
+						t.Errorf("%s: not OK (actual %s, err %v)", check.Path, r.Actual, r.Err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyFiles_Canceled(t *testing.T) {
+	dir := t.TempDir()
+	checks := make([]FileCheck, 10)
+	for i := range checks {
+		checks[i] = FileCheck{Path: filepath.Join(dir, "x"), Expected: HashBytes(nil)}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n := 0
+	VerifyFiles(ctx, checks, 2, func(r FileResult) {
+		n++
+		if r.Err == nil {
+			t.Error("canceled check reported no error")
+		}
+	})
+	if n != len(checks) {
+		t.Errorf("reported %d checks, want %d", n, len(checks))
+	}
+
+	VerifyFiles(context.Background(), nil, 0, func(FileResult) { t.Error("no checks to report") })
+}
+
+func TestVerifyFilesThrough(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte("wrapped")
+	path := filepath.Join(dir, "f")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	checks := []FileCheck{{Path: path, Expected: HashBytes(data)}, {Path: path, Expected: HashBytes(data)}}
+
+	var wrapped atomic.Int32
+	wrap := func(r io.Reader) io.Reader {
+		wrapped.Add(1)
+		return r
+	}
+	VerifyFilesThrough(context.Background(), checks, 2, wrap, func(r FileResult) {
+		if !r.OK() {
+			t.Errorf("not OK: %v", r.Err)
+		}
+	})
+	if wrapped.Load() != 2 {
+		t.Errorf("wrapped %d reads, want 2", wrapped.Load())
+	}
+}
@@ -0,0 +1,235 @@
+// Package hooks provides extension points in the package pipeline, so
+// integrators can add behavior such as virus or license scanning and
+// notifications without changing proxy or downloader code.
+//
+// A hook is any value with a Name that implements one or more of
+// ContentVerifiedHook, PreAnnounceHook and PreServeHook. Hooks are compiled
+// in: add a file to cmd/debswarm (usually behind a build tag) whose init
+// function calls Register, and the daemon picks the hook up at startup.
+//
+//	//go:build clamav
+//
+//	package main
+//
+//	import "github.com/debswarm/debswarm/internal/hooks"
+//
+//	func init() { hooks.Register(&clamScanner{socket: "/run/clamav/clamd.ctl"}) }
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Stages at which a hook can reject a package.
+const (
+	StagePreAnnounce = "pre_announce"
+	StagePreServe    = "pre_serve"
+)
+
+// Package describes a package whose content has been verified against the
+// SHA256 from the signed repository index.
+type Package struct {
+	SHA256   string
+	Filename string // repository path, e.g. pool/main/c/curl/curl_8.5.0-1_amd64.deb
+	Size     int64
+	Source   string // where it came from: "mirror", "peer", "fleet", "cache", ...
+
+	// Open returns the verified content. Each call starts a new reader;
+	// the caller closes it.
+	Open func() (io.ReadCloser, error)
+}
+
+// Target is who a package is about to be served to.
+type Target struct {
+	Peer bool   // true for a P2P upload, false for a proxy client
+	ID   string // peer ID, or the client's remote address
+}
+
+// Hook is the part every hook implements.
+type Hook interface {
+	// Name identifies the hook in logs, metrics and the audit log.
+	Name() string
+}
+
+// ContentVerifiedHook is told about each package newly downloaded and
+// verified. It runs on the request path, so slow work (a notification, a
+// scan whose verdict PreServe consults later) belongs in a goroutine.
+type ContentVerifiedHook interface {
+	Hook
+	ContentVerified(ctx context.Context, pkg *Package)
+}
+
+// PreAnnounceHook decides whether a cached package may be announced to the
+// swarm. Returning an error keeps it unannounced; it is asked again at the
+// next reannounce.
+type PreAnnounceHook interface {
+	Hook
+	PreAnnounce(ctx context.Context, pkg *Package) error
+}
+
+// PreServeHook decides whether a package may be served to a proxy client
+// or uploaded to a peer. Returning an error refuses that one request.
+type PreServeHook interface {
+	Hook
+	PreServe(ctx context.Context, pkg *Package, to Target) error
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []Hook
+)
+
+// Register adds a hook to those the daemon runs. It is meant to be called
+// from init functions, and panics if h implements no hook interface or its
+// name is empty or already registered.
+func Register(h Hook) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if h.Name() == "" {
+		panic("hooks: Register called with an unnamed hook")
+	}
+	if !implementsAny(h) {
+		panic(fmt.Sprintf("hooks: %s implements no hook interface", h.Name()))
+	}
+	for _, r := range registry {
+		if r.Name() == h.Name() {
+			panic(fmt.Sprintf("hooks: %s registered twice", h.Name()))
+		}
+	}
+	registry = append(registry, h)
+}
+
+// Registered returns the registered hooks in registration order.
+func Registered() []Hook {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return append([]Hook(nil), registry...)
+}
+
+func implementsAny(h Hook) bool {
+	_, v := h.(ContentVerifiedHook)
+	_, a := h.(PreAnnounceHook)
+	_, s := h.(PreServeHook)
+	return v || a || s
+}
+
+// RejectedError reports that a hook refused a package.
+type RejectedError struct {
+	Hook  string
+	Stage string
+	Err   error
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("%s hook %s rejected package: %v", e.Stage, e.Hook, e.Err)
+}
+
+func (e *RejectedError) Unwrap() error { return e.Err }
+
+// Chain runs a set of hooks in order. A nil *Chain runs nothing.
+type Chain struct {
+	verified []ContentVerifiedHook
+	announce []PreAnnounceHook
+	serve    []PreServeHook
+	logger   *zap.Logger
+}
+
+// NewChain sorts hooks by the interfaces they implement.
+func NewChain(hs []Hook, logger *zap.Logger) *Chain {
+	c := &Chain{logger: logger}
+	for _, h := range hs {
+		if v, ok := h.(ContentVerifiedHook); ok {
+			c.verified = append(c.verified, v)
+		}
+		if a, ok := h.(PreAnnounceHook); ok {
+			c.announce = append(c.announce, a)
+		}
+		if s, ok := h.(PreServeHook); ok {
+			c.serve = append(c.serve, s)
+		}
+	}
+	return c
+}
+
+// Empty reports whether the chain has no hooks at all.
+func (c *Chain) Empty() bool {
+	return c == nil || len(c.verified)+len(c.announce)+len(c.serve) == 0
+}
+
+// HasPreServe reports whether any hook gates serving. The proxy then waits
+// for verified content instead of streaming a download as it arrives.
+func (c *Chain) HasPreServe() bool {
+	return c != nil && len(c.serve) > 0
+}
+
+// HasPreAnnounce reports whether any hook gates announcements.
+func (c *Chain) HasPreAnnounce() bool {
+	return c != nil && len(c.announce) > 0
+}
+
+// ContentVerified tells every ContentVerifiedHook about pkg. A hook that
+// panics is logged and skipped.
+func (c *Chain) ContentVerified(ctx context.Context, pkg *Package) {
+	if c == nil {
+		return
+	}
+	for _, h := range c.verified {
+		err := guard(func() error {
+			h.ContentVerified(ctx, pkg)
+			return nil
+		})
+		if err != nil {
+			c.logger.Error("Content-verified hook failed",
+				zap.String("hook", h.Name()), zap.Error(err))
+		}
+	}
+}
+
+// PreAnnounce asks each PreAnnounceHook in turn and returns a
+// *RejectedError from the first that refuses. A hook that panics refuses.
+func (c *Chain) PreAnnounce(ctx context.Context, pkg *Package) error {
+	if c == nil {
+		return nil
+	}
+	for _, h := range c.announce {
+		if err := guard(func() error { return h.PreAnnounce(ctx, pkg) }); err != nil {
+			return &RejectedError{Hook: h.Name(), Stage: StagePreAnnounce, Err: err}
+		}
+	}
+	return nil
+}
+
+// PreServe asks each PreServeHook in turn and returns a *RejectedError from
+// the first that refuses. A hook that panics refuses.
+func (c *Chain) PreServe(ctx context.Context, pkg *Package, to Target) error {
+	if c == nil {
+		return nil
+	}
+	for _, h := range c.serve {
+		if err := guard(func() error { return h.PreServe(ctx, pkg, to) }); err != nil {
+			return &RejectedError{Hook: h.Name(), Stage: StagePreServe, Err: err}
+		}
+	}
+	return nil
+}
+
+// errPanicked wraps a recovered hook panic.
+var errPanicked = errors.New("hook panicked")
+
+// guard runs fn, turning a panic into an error so one faulty hook cannot
+// take down the daemon. Gating hooks fail closed.
+func guard(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errPanicked, r)
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,152 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+type testHook struct {
+	name     string
+	verified []string
+	announce error
+	serve    error
+	panics   bool
+}
+
+func (h *testHook) Name() string { return h.name }
+
+func (h *testHook) ContentVerified(_ context.Context, pkg *Package) {
+	if h.panics {
+		panic("boom")
+	}
+	h.verified = append(h.verified, pkg.SHA256)
+}
+
+func (h *testHook) PreAnnounce(_ context.Context, _ *Package) error {
+	if h.panics {
+		panic("boom")
+	}
+	return h.announce
+}
+
+func (h *testHook) PreServe(_ context.Context, _ *Package, _ Target) error {
+	if h.panics {
+		panic("boom")
+	}
+	return h.serve
+}
+
+type verifyOnly struct{ name string }
+
+func (h verifyOnly) Name() string                              { return h.name }
+func (h verifyOnly) ContentVerified(context.Context, *Package) {}
+
+type nameOnly struct{}
+
+func (nameOnly) Name() string { return "name-only" }
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+	pkg := &Package{SHA256: "abc", Filename: "pool/main/t/test/test_1.0_all.deb"}
+
+	ok := &testHook{name: "ok"}
+	c := NewChain([]Hook{ok, verifyOnly{name: "v"}}, zap.NewNop())
+	if c.Empty() || !c.HasPreServe() || !c.HasPreAnnounce() {
+		t.Fatal("chain should have verified, announce and serve hooks")
+	}
+	c.ContentVerified(ctx, pkg)
+	if len(ok.verified) != 1 || ok.verified[0] != "abc" {
+		t.Errorf("verified = %v, want [abc]", ok.verified)
+	}
+	if err := c.PreAnnounce(ctx, pkg); err != nil {
+		t.Errorf("PreAnnounce: %v", err)
+	}
+	if err := c.PreServe(ctx, pkg, Target{}); err != nil {
+		t.Errorf("PreServe: %v", err)
+	}
+
+	errInfected := errors.New("infected")
+	bad := &testHook{name: "scanner", announce: errInfected, serve: errInfected}
+	c = NewChain([]Hook{ok, bad}, zap.NewNop())
+	for _, tc := range []struct {
+		stage string
+		err   error
+	}{
+		{StagePreAnnounce, c.PreAnnounce(ctx, pkg)},
+		{StagePreServe, c.PreServe(ctx, pkg, Target{Peer: true, ID: "peer"})},
+	} {
+		var rej *RejectedError
+		if !errors.As(tc.err, &rej) {
+			t.Fatalf("%s: got %v, want *RejectedError", tc.stage, tc.err)
+		}
+		if rej.Hook != "scanner" || rej.Stage != tc.stage || !errors.Is(tc.err, errInfected) {
+			t.Errorf("%s: got %+v", tc.stage, rej)
+		}
+	}
+}
+
+func TestChain_PanicsFailClosed(t *testing.T) {
+	ctx := context.Background()
+	pkg := &Package{SHA256: "abc"}
+	c := NewChain([]Hook{&testHook{name: "faulty", panics: true}}, zap.NewNop())
+
+	c.ContentVerified(ctx, pkg) // logged, not propagated
+	if err := c.PreAnnounce(ctx, pkg); !errors.Is(err, errPanicked) {
+		t.Errorf("PreAnnounce = %v, want a panic rejection", err)
+	}
+	if err := c.PreServe(ctx, pkg, Target{}); !errors.Is(err, errPanicked) {
+		t.Errorf("PreServe = %v, want a panic rejection", err)
+	}
+}
+
+func TestChain_Nil(t *testing.T) {
+	var c *Chain
+	ctx := context.Background()
+	if !c.Empty() || c.HasPreServe() || c.HasPreAnnounce() {
+		t.Error("nil chain should be empty")
+	}
+	c.ContentVerified(ctx, &Package{})
+	if c.PreAnnounce(ctx, &Package{}) != nil || c.PreServe(ctx, &Package{}, Target{}) != nil {
+		t.Error("nil chain should allow everything")
+	}
+	if !NewChain(nil, zap.NewNop()).Empty() {
+		t.Error("chain without hooks should be empty")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	registryMu.Lock()
+	saved := registry
+	registry = nil
+	registryMu.Unlock()
+	defer func() {
+		registryMu.Lock()
+		registry = saved
+		registryMu.Unlock()
+	}()
+
+	Register(verifyOnly{name: "first"})
+	Register(&testHook{name: "second"})
+	got := Registered()
+	if len(got) != 2 || got[0].Name() != "first" || got[1].Name() != "second" {
+		t.Fatalf("Registered() = %v", got)
+	}
+
+	for name, h := range map[string]Hook{
+		"duplicate":     verifyOnly{name: "first"},
+		"unnamed":       verifyOnly{},
+		"no interfaces": nameOnly{},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: Register did not panic", name)
+				}
+			}()
+			Register(h)
+		}()
+	}
+}
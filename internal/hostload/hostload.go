@@ -0,0 +1,160 @@
+// Package hostload samples how busy the host is: load average, time spent
+// waiting on I/O, and network throughput, read from Linux's /proc. Where
+// /proc is missing, Sample returns an error.
+package hostload
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sample is one reading of the host's load. The rates and the iowait share
+// cover the time since the previous sample; the first sample has none.
+type Sample struct {
+	Time       time.Time
+	Load1      float64 // 1-minute load average
+	LoadPerCPU float64 // Load1 divided by the CPUs available
+	IOWait     float64 // share of CPU time spent waiting on I/O, 0-1
+	RxRate     float64 // bytes/sec received on all but loopback interfaces
+	TxRate     float64 // bytes/sec sent on all but loopback interfaces
+}
+
+// cpuTimes are the aggregate counters from the "cpu" line of /proc/stat.
+type cpuTimes struct {
+	iowait, total uint64
+}
+
+// netBytes are the byte counters summed over interfaces from /proc/net/dev.
+type netBytes struct {
+	rx, tx uint64
+}
+
+// Sampler reads Samples. It keeps the counters of the previous sample to
+// turn them into rates, so one Sampler should be used throughout.
+type Sampler struct {
+	procDir string
+	cpus    int
+
+	mu   sync.Mutex
+	last time.Time
+	cpu  cpuTimes
+	net  netBytes
+}
+
+// NewSampler returns a Sampler reading the host's /proc.
+func NewSampler() *Sampler {
+	return &Sampler{procDir: "/proc", cpus: runtime.NumCPU()}
+}
+
+// Sample reads the current load. It fails only if the load average cannot
+// be read; missing iowait or network counters read as zero.
+func (s *Sampler) Sample() (Sample, error) {
+	now := time.Now()
+	data, err := os.ReadFile(filepath.Join(s.procDir, "loadavg"))
+	if err != nil {
+		return Sample{}, err
+	}
+	load1, err := parseLoadavg(data)
+	if err != nil {
+		return Sample{}, err
+	}
+	out := Sample{Time: now, Load1: load1, LoadPerCPU: load1 / float64(max(s.cpus, 1))}
+
+	var cpu cpuTimes
+	if data, err := os.ReadFile(filepath.Join(s.procDir, "stat")); err == nil {
+		cpu, _ = parseStat(data)
+	}
+	var nb netBytes
+	if data, err := os.ReadFile(filepath.Join(s.procDir, "net", "dev")); err == nil {
+		nb = parseNetDev(data)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.last.IsZero() {
+		if cpu.total > s.cpu.total && cpu.iowait >= s.cpu.iowait {
+			out.IOWait = float64(cpu.iowait-s.cpu.iowait) / float64(cpu.total-s.cpu.total)
+		}
+		if secs := now.Sub(s.last).Seconds(); secs > 0 {
+			// Counters reset when an interface goes away; skip that interval
+			if nb.rx >= s.net.rx {
+				out.RxRate = float64(nb.rx-s.net.rx) / secs
+			}
+			if nb.tx >= s.net.tx {
+				out.TxRate = float64(nb.tx-s.net.tx) / secs
+			}
+		}
+	}
+	s.last, s.cpu, s.net = now, cpu, nb
+	return out, nil
+}
+
+// parseLoadavg returns the 1-minute load average from /proc/loadavg.
+func parseLoadavg(data []byte) (float64, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, errors.New("empty loadavg")
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse loadavg: %w", err)
+	}
+	return load, nil
+}
+
+// parseStat returns the aggregate CPU counters from /proc/stat: user, nice,
+// system, idle, iowait, irq, softirq and steal. Guest time is already
+// counted in user and nice.
+func parseStat(data []byte) (cpuTimes, error) {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	fields := strings.Fields(string(line))
+	if len(fields) < 6 || fields[0] != "cpu" {
+		return cpuTimes{}, errors.New("no cpu line in stat")
+	}
+	var t cpuTimes
+	for i, f := range fields[1:min(len(fields), 9)] {
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return cpuTimes{}, fmt.Errorf("parse stat: %w", err)
+		}
+		t.total += v
+		if i == 4 {
+			t.iowait = v
+		}
+	}
+	return t, nil
+}
+
+// parseNetDev sums the received and sent bytes of every interface but
+// loopback in /proc/net/dev. Malformed lines are skipped.
+func parseNetDev(data []byte) netBytes {
+	var nb netBytes
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		name, counters, ok := strings.Cut(sc.Text(), ":")
+		if !ok || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+		rx, err1 := strconv.ParseUint(fields[0], 10, 64)
+		tx, err2 := strconv.ParseUint(fields[8], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		nb.rx += rx
+		nb.tx += tx
+	}
+	return nb
+}
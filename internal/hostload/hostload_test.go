@@ -0,0 +1,80 @@
+package hostload
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const netDevHeader = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+`
+
+func writeProc(t *testing.T, dir, loadavg, stat, netDev string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, "net"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"loadavg": loadavg, "stat": stat, "net/dev": netDevHeader + netDev} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSampler(t *testing.T) {
+	dir := t.TempDir()
+	s := &Sampler{procDir: dir, cpus: 4}
+
+	writeProc(t, dir, "2.00 1.50 1.00 3/400 1234\n",
+		"cpu  100 0 100 700 100 0 0 0 0 0\ncpu0 1 2 3 4 5 6 7 8 9 10\n",
+		"    lo: 5000 10 0 0 0 0 0 0 5000 10 0 0 0 0 0 0\n"+
+			"  eth0: 1000 10 0 0 0 0 0 0 2000 10 0 0 0 0 0 0\n")
+	first, err := s.Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Load1 != 2 || first.LoadPerCPU != 0.5 {
+		t.Errorf("load = %v, per CPU %v", first.Load1, first.LoadPerCPU)
+	}
+	if first.IOWait != 0 || first.RxRate != 0 || first.TxRate != 0 {
+		t.Errorf("first sample has rates: %+v", first)
+	}
+
+	// 1000 more CPU ticks, 250 of them iowait; eth0 moved 1MB each way
+	s.last = s.last.Add(-time.Second)
+	writeProc(t, dir, "2.00 1.50 1.00 3/400 1234\n",
+		"cpu  300 0 200 1150 350 0 0 0 0 0\n",
+		"    lo: 9000 10 0 0 0 0 0 0 9000 10 0 0 0 0 0 0\n"+
+			"  eth0: 1049576 10 0 0 0 0 0 0 1050576 10 0 0 0 0 0 0\n")
+	second, err := s.Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(second.IOWait-0.25) > 1e-9 {
+		t.Errorf("iowait = %v, want 0.25", second.IOWait)
+	}
+	// Loopback is ignored; the interval is a little over a second
+	if second.RxRate < 0.9*(1<<20) || second.RxRate > 1<<20 || second.TxRate < 0.9*(1<<20) || second.TxRate > 1<<20 {
+		t.Errorf("rates = %v rx, %v tx, want about 1MB/s", second.RxRate, second.TxRate)
+	}
+
+	// Counters that went backwards give no rate
+	writeProc(t, dir, "0.10 0 0 1/1 1\n", "cpu  1 0 1 1 1 0 0 0\n", "  eth0: 1 0 0 0 0 0 0 0 1 0 0 0 0 0 0 0\n")
+	third, err := s.Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.IOWait != 0 || third.RxRate != 0 || third.TxRate != 0 {
+		t.Errorf("sample after reset = %+v", third)
+	}
+}
+
+func TestSamplerUnavailable(t *testing.T) {
+	s := &Sampler{procDir: t.TempDir(), cpus: 1}
+	if _, err := s.Sample(); err == nil {
+		t.Error("Sample without /proc succeeded")
+	}
+}
@@ -51,7 +51,10 @@ type PackageInfo struct {
 	Filename     string
 	Size         int64
 	SHA256       string
+	Depends      string // Pre-Depends and Depends relations, for prefetch groups
 	Repo         string // Repository base URL this package belongs to
+	Suite        string // Suite of the index file listing it, e.g. "bookworm"; empty if unknown
+	Component    string // Component of that index file, e.g. "main"; empty if unknown
 }
 
 // Index manages package index files from multiple repositories
@@ -270,6 +273,7 @@ func (idx *Index) parseForRepo(reader io.Reader, repo, fileKey string) error {
 	if idx.byRepo[repo] == nil {
 		idx.byRepo[repo] = make(map[string]*PackageInfo)
 	}
+	suite, component := suiteComponent(fileKey)
 
 	var generation []*PackageInfo
 
@@ -287,7 +291,7 @@ func (idx *Index) parseForRepo(reader io.Reader, repo, fileKey string) error {
 		// Empty line marks end of package entry
 		if line == "" {
 			if pkg != nil && pkg.SHA256 != "" {
-				pkg.Repo = repo
+				pkg.Repo, pkg.Suite, pkg.Component = repo, suite, component
 				idx.packages[pkg.SHA256] = pkg
 				generation = append(generation, pkg)
 				if pkg.Filename != "" {
@@ -339,12 +343,17 @@ func (idx *Index) parseForRepo(reader io.Reader, repo, fileKey string) error {
 			}
 		case "SHA256":
 			pkg.SHA256 = value
+		case "Pre-Depends", "Depends":
+			if pkg.Depends != "" {
+				value = pkg.Depends + ", " + value
+			}
+			pkg.Depends = value
 		}
 	}
 
 	// Handle last package
 	if pkg != nil && pkg.SHA256 != "" {
-		pkg.Repo = repo
+		pkg.Repo, pkg.Suite, pkg.Component = repo, suite, component
 		idx.packages[pkg.SHA256] = pkg
 		generation = append(generation, pkg)
 		if pkg.Filename != "" {
@@ -498,6 +507,18 @@ func (idx *Index) Count() int {
 	return len(idx.packages)
 }
 
+// Packages returns every indexed package (unique by SHA256), in no
+// particular order.
+func (idx *Index) Packages() []*PackageInfo {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	pkgs := make([]*PackageInfo, 0, len(idx.packages))
+	for _, pkg := range idx.packages {
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs
+}
+
 // RepoCount returns the number of indexed repositories
 func (idx *Index) RepoCount() int {
 	idx.mu.RLock()
@@ -620,6 +641,28 @@ func (idx *Index) clearIndexFileLocked(fileKey string) {
 	delete(idx.byIndexFile, fileKey)
 }
 
+// suiteComponent returns the suite and component of an index file from its
+// URL, ".../dists/bookworm/main/binary-amd64/Packages", or its APT lists
+// name, "deb.debian.org_debian_dists_bookworm_main_binary-amd64_Packages".
+// Both are empty for flat repositories.
+func suiteComponent(fileKey string) (suite, component string) {
+	var parts []string
+	if i := strings.LastIndex(fileKey, "/dists/"); i >= 0 {
+		parts = strings.Split(fileKey[i+len("/dists/"):], "/")
+	} else if i := strings.LastIndex(fileKey, "_dists_"); i >= 0 {
+		parts = strings.Split(fileKey[i+len("_dists_"):], "_")
+	}
+	for i, p := range parts {
+		if strings.HasPrefix(p, "binary-") || p == "source" {
+			if i < 2 {
+				break
+			}
+			return strings.Join(parts[:i-1], "/"), parts[i-1]
+		}
+	}
+	return "", ""
+}
+
 // ExtractRepoFromURL extracts the repository base URL from a full URL
 // e.g., "http://deb.debian.org/debian/dists/bookworm/main/binary-amd64/Packages.gz"
 //
@@ -391,6 +391,34 @@ func TestExtractRepoFromURL(t *testing.T) {
 	}
 }
 
+func TestSuiteComponent(t *testing.T) {
+	tests := []struct {
+		key, suite, component string
+	}{
+		{"http://deb.debian.org/debian/dists/bookworm-updates/main/binary-amd64/Packages", "bookworm-updates", "main"},
+		{"http://deb.debian.org/debian/dists/bookworm/contrib/source/Sources", "bookworm", "contrib"},
+		{"http://security.debian.org/dists/stretch/updates/main/binary-amd64", "stretch/updates", "main"},
+		{"/var/lib/apt/lists/archive.ubuntu.com_ubuntu_dists_noble_universe_binary-amd64_Packages", "noble", "universe"},
+		{"http://example.com/repo/Packages", "", ""},
+		{"http://example.com/dists/binary-amd64/Packages", "", ""},
+	}
+	for _, tt := range tests {
+		suite, component := suiteComponent(tt.key)
+		if suite != tt.suite || component != tt.component {
+			t.Errorf("suiteComponent(%q) = %q, %q; want %q, %q", tt.key, suite, component, tt.suite, tt.component)
+		}
+	}
+
+	idx := New("/tmp/test", testLogger())
+	if err := idx.LoadFromData([]byte(samplePackagesContent), "http://deb.debian.org/debian/dists/bookworm/main/binary-amd64/Packages"); err != nil {
+		t.Fatal(err)
+	}
+	pkg := idx.GetByURLPath("http://deb.debian.org/debian/pool/main/v/vim/vim_9.0.1378-2_amd64.deb")
+	if pkg == nil || pkg.Suite != "bookworm" || pkg.Component != "main" {
+		t.Errorf("vim = %+v, want suite bookworm, component main", pkg)
+	}
+}
+
 func TestExtractPathFromURL(t *testing.T) {
 	tests := []struct {
 		url      string
@@ -822,3 +850,23 @@ func TestGetByRepoAndPath_EmptyRepo(t *testing.T) {
 		t.Error("Expected nil for empty repo")
 	}
 }
+
+func TestLoadFromData_Depends(t *testing.T) {
+	idx := New("/tmp/test", testLogger())
+	data := "Package: git\n" +
+		"Version: 1:2.39.2-1.1\n" +
+		"Architecture: amd64\n" +
+		"Pre-Depends: libc6 (>= 2.34)\n" +
+		"Depends: libcurl3-gnutls (>= 7.56.1), perl, git-man (>> 1:2.39.2)\n" +
+		"Filename: pool/main/g/git/git_2.39.2-1.1_amd64.deb\n" +
+		"Size: 100\n" +
+		"SHA256: a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2\n\n"
+	if err := idx.LoadFromData([]byte(data), "http://deb.debian.org/debian/dists/bookworm/main/binary-amd64/Packages"); err != nil {
+		t.Fatal(err)
+	}
+	pkg := idx.GetBySHA256("a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2")
+	want := "libc6 (>= 2.34), libcurl3-gnutls (>= 7.56.1), perl, git-man (>> 1:2.39.2)"
+	if pkg == nil || pkg.Depends != want {
+		t.Errorf("Depends = %+v, want %q", pkg, want)
+	}
+}
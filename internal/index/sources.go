@@ -17,12 +17,13 @@ import (
 	"go.uber.org/zap"
 )
 
-// decompressByMagic wraps data with the decompressor matching its leading magic
+// DecompressByMagic wraps data with the decompressor matching its leading magic
 // bytes (for by-hash URLs that lack a file extension), applying the
-// decompression-bomb size limit. Mirrors the detection in LoadFromData. Like
-// that path it has no bz2 case — bz2 is only detected by extension
-// (decompressByName), which is fine because by-hash Sources blobs are gz/xz.
-func decompressByMagic(data []byte) (io.Reader, error) {
+// decompression-bomb size limit; data with no known magic is returned as is.
+// Mirrors the detection in LoadFromData. Like that path it has no bz2 case —
+// bz2 is only detected by extension (decompressByName), which is fine because
+// by-hash index blobs are gz/xz.
+func DecompressByMagic(data []byte) (io.Reader, error) {
 	switch {
 	case len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b:
 		gz, err := gzip.NewReader(bytes.NewReader(data))
@@ -56,7 +57,7 @@ func decompressByMagic(data []byte) (io.Reader, error) {
 // .debs. url derives the repo base and the index-file key. Compression handling
 // matches LoadFromData (gzip/xz/zstd/lz4 by magic bytes).
 func (idx *Index) LoadSourcesFromData(data []byte, url string) error {
-	reader, err := decompressByMagic(data)
+	reader, err := DecompressByMagic(data)
 	if err != nil {
 		return err
 	}
@@ -116,6 +117,7 @@ func (idx *Index) parseSourcesForRepo(reader io.Reader, repo, fileKey string) er
 	if idx.byRepo[repo] == nil {
 		idx.byRepo[repo] = make(map[string]*PackageInfo)
 	}
+	suite, component := suiteComponent(fileKey)
 
 	var generation []*PackageInfo
 	count := 0
@@ -142,11 +144,13 @@ func (idx *Index) parseSourcesForRepo(reader io.Reader, repo, fileKey string) er
 				}
 				filename := directory + "/" + fe.name
 				pkg := &PackageInfo{
-					Package:  srcName,
-					Filename: filename,
-					Size:     fe.size,
-					SHA256:   fe.sha256,
-					Repo:     repo,
+					Package:   srcName,
+					Filename:  filename,
+					Size:      fe.size,
+					SHA256:    fe.sha256,
+					Repo:      repo,
+					Suite:     suite,
+					Component: component,
 				}
 				idx.packages[fe.sha256] = pkg
 				generation = append(generation, pkg)
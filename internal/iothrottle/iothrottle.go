@@ -0,0 +1,132 @@
+// Package iothrottle caps the disk I/O of background cache work, such as
+// imports and re-hashing the cache, so it cannot saturate the disk the
+// proxy serves clients from. A Throttle limits bytes per second and
+// operations per second, and its limits can be changed while readers use
+// it.
+package iothrottle
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	minByteBurst = 64 * 1024
+	maxByteBurst = 4 * 1024 * 1024
+)
+
+// Limits are the caps of a Throttle; 0 means unlimited
+type Limits struct {
+	BytesPerSec int64
+	IOPS        int
+}
+
+// Throttle paces I/O to its Limits. A nil Throttle does not limit.
+type Throttle struct {
+	bytes *rate.Limiter
+	ops   *rate.Limiter
+
+	mu     sync.Mutex
+	limits Limits
+}
+
+// New returns a Throttle with the given limits, starting with a full burst
+func New(l Limits) *Throttle {
+	bytesLimit, bytesBurst := l.bytesRate()
+	opsLimit, opsBurst := l.opsRate()
+	return &Throttle{
+		bytes:  rate.NewLimiter(bytesLimit, bytesBurst),
+		ops:    rate.NewLimiter(opsLimit, opsBurst),
+		limits: l,
+	}
+}
+
+// bytesRate returns the byte limit and a burst of one second's worth,
+// between 64KB and 4MB
+func (l Limits) bytesRate() (rate.Limit, int) {
+	if l.BytesPerSec <= 0 {
+		return rate.Inf, 0
+	}
+	return rate.Limit(l.BytesPerSec), int(min(max(l.BytesPerSec, minByteBurst), maxByteBurst))
+}
+
+// opsRate returns the operation limit and a burst of one second's worth
+func (l Limits) opsRate() (rate.Limit, int) {
+	if l.IOPS <= 0 {
+		return rate.Inf, 0
+	}
+	return rate.Limit(l.IOPS), l.IOPS
+}
+
+// SetLimits changes the limits, also for the readers already handed out
+func (t *Throttle) SetLimits(l Limits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.limits = l
+	bytesLimit, bytesBurst := l.bytesRate()
+	t.bytes.SetLimit(bytesLimit)
+	t.bytes.SetBurst(bytesBurst)
+	opsLimit, opsBurst := l.opsRate()
+	t.ops.SetLimit(opsLimit)
+	t.ops.SetBurst(opsBurst)
+}
+
+// Limits returns the current limits
+func (t *Throttle) Limits() Limits {
+	if t == nil {
+		return Limits{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limits
+}
+
+// Wait blocks until one operation of n bytes is allowed, or ctx is done
+func (t *Throttle) Wait(ctx context.Context, n int) error {
+	if t == nil {
+		return nil
+	}
+	if err := t.ops.Wait(ctx); err != nil {
+		return err
+	}
+	// WaitN fails for more than the burst, so wait in burst-sized steps
+	for n > 0 {
+		step := n
+		if b := t.bytes.Burst(); b > 0 && step > b {
+			step = b
+		}
+		if err := t.bytes.WaitN(ctx, step); err != nil {
+			return err
+		}
+		n -= step
+	}
+	return nil
+}
+
+// Reader returns r with each read paced by the throttle
+func (t *Throttle) Reader(ctx context.Context, r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &reader{r: r, t: t, ctx: ctx}
+}
+
+type reader struct {
+	r   io.Reader
+	t   *Throttle
+	ctx context.Context
+}
+
+// Read reads, then waits for what was read, so a read's size is known
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.t.Wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
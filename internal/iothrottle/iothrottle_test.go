@@ -0,0 +1,86 @@
+package iothrottle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestUnlimited(t *testing.T) {
+	th := New(Limits{})
+	start := time.Now()
+	n, err := io.Copy(io.Discard, th.Reader(context.Background(), bytes.NewReader(make([]byte, 64<<20))))
+	if err != nil || n != 64<<20 {
+		t.Fatalf("copied %d, %v", n, err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("unlimited copy took %v", d)
+	}
+
+	var nilThrottle *Throttle
+	r := bytes.NewReader(nil)
+	if nilThrottle.Reader(context.Background(), r) != r || nilThrottle.Limits() != (Limits{}) {
+		t.Error("a nil throttle should not limit")
+	}
+}
+
+func TestBytesPerSec(t *testing.T) {
+	// The burst is one second's worth, so 128KB pass at once and the next
+	// 32KB take a quarter second at 128KB/s
+	th := New(Limits{BytesPerSec: 128 * 1024})
+	start := time.Now()
+	n, err := io.Copy(io.Discard, th.Reader(context.Background(), bytes.NewReader(make([]byte, 160*1024))))
+	if err != nil || n != 160*1024 {
+		t.Fatalf("copied %d, %v", n, err)
+	}
+	if d := time.Since(start); d < 200*time.Millisecond || d > 2*time.Second {
+		t.Errorf("160KB at 128KB/s took %v, want about 250ms", d)
+	}
+}
+
+func TestIOPS(t *testing.T) {
+	th := New(Limits{IOPS: 10})
+	start := time.Now()
+	for i := 0; i < 15; i++ {
+		if err := th.Wait(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 10 pass at once, the other 5 take half a second at 10/s
+	if d := time.Since(start); d < 400*time.Millisecond || d > 2*time.Second {
+		t.Errorf("15 operations at 10 IOPS took %v, want about 500ms", d)
+	}
+}
+
+func TestSetLimitsAtRuntime(t *testing.T) {
+	th := New(Limits{BytesPerSec: 1024})
+	r := th.Reader(context.Background(), bytes.NewReader(make([]byte, 1<<20)))
+
+	// Lifting the limit applies to the reader already handed out
+	th.SetLimits(Limits{})
+	start := time.Now()
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("copy after lifting the limit took %v", d)
+	}
+
+	th.SetLimits(Limits{BytesPerSec: 2048, IOPS: 5})
+	if got := th.Limits(); got != (Limits{BytesPerSec: 2048, IOPS: 5}) {
+		t.Errorf("Limits = %+v", got)
+	}
+}
+
+func TestWaitCanceled(t *testing.T) {
+	th := New(Limits{BytesPerSec: 1024})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := io.Copy(io.Discard, th.Reader(ctx, bytes.NewReader(make([]byte, 1<<20))))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
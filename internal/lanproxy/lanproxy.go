@@ -0,0 +1,262 @@
+// Package lanproxy advertises a debswarm proxy that serves the LAN over mDNS
+// (DNS-SD), and finds such proxies from client machines, so roaming
+// machines can use an office swarm whenever they are on its network.
+package lanproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libp2p/zeroconf/v2"
+)
+
+// ServiceName is the DNS-SD service APT proxies advertise. It is the one
+// squid-deb-proxy and apt-cacher-ng use, so their client helpers (e.g.
+// squid-deb-proxy-client, auto-apt-proxy) find debswarm too, and ours finds
+// them.
+const ServiceName = "_apt_proxy._tcp"
+
+// TXT record keys
+const (
+	txtProduct = "product="
+	txtVersion = "version="
+)
+
+// product marks entries advertised by debswarm
+const product = "debswarm"
+
+// Advertisement is a running mDNS advertisement of the proxy.
+type Advertisement struct {
+	server *zeroconf.Server
+}
+
+// Advertise announces the proxy listening on bind:port. An unspecified bind
+// address ("0.0.0.0" or "::") is announced with every multicast interface's
+// addresses.
+func Advertise(bind string, port int, version string) (*Advertisement, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine host name: %w", err)
+	}
+	host, _, _ = strings.Cut(host, ".")
+	instance := "debswarm on " + host
+	txt := []string{txtProduct + product}
+	if version != "" {
+		txt = append(txt, txtVersion+version)
+	}
+
+	var server *zeroconf.Server
+	if ip := net.ParseIP(bind); ip != nil && !ip.IsUnspecified() {
+		server, err = zeroconf.RegisterProxy(instance, ServiceName, "local.", port, host, []string{ip.String()}, txt, nil)
+	} else {
+		server, err = zeroconf.Register(instance, ServiceName, "local.", port, txt, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Advertisement{server: server}, nil
+}
+
+// Close withdraws the advertisement.
+func (a *Advertisement) Close() {
+	a.server.Shutdown()
+}
+
+// Proxy is an APT proxy found on the LAN.
+type Proxy struct {
+	// Instance is the advertised service name, e.g. "debswarm on office-cache"
+	Instance string `json:"instance"`
+	// URL is the proxy URL to give APT
+	URL string `json:"url"`
+	// Debswarm reports whether the proxy is a debswarm node
+	Debswarm bool   `json:"debswarm"`
+	Version  string `json:"version,omitempty"`
+}
+
+// Discover browses the LAN for APT proxies for timeout. Debswarm proxies
+// come first, then others (apt-cacher-ng, squid-deb-proxy), each in the
+// order they answered.
+func Discover(ctx context.Context, timeout time.Duration) ([]Proxy, error) {
+	var proxies []Proxy
+	err := browse(ctx, timeout, func(p Proxy) bool {
+		proxies = append(proxies, p)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortProxies(proxies)
+	return proxies, nil
+}
+
+// Find returns a reachable APT proxy on the LAN, browsing for at most
+// timeout. The first reachable debswarm proxy is returned as soon as it
+// answers; other proxies only once the time is up and no debswarm one was
+// found.
+func Find(ctx context.Context, timeout, dialTimeout time.Duration) (Proxy, error) {
+	var found *Proxy
+	var others []Proxy
+	err := browse(ctx, timeout, func(p Proxy) bool {
+		if !p.Debswarm {
+			others = append(others, p)
+			return false
+		}
+		if _, err := Reachable(ctx, []Proxy{p}, dialTimeout); err == nil {
+			found = &p
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return Proxy{}, err
+	}
+	if found != nil {
+		return *found, nil
+	}
+	return Reachable(ctx, others, dialTimeout)
+}
+
+// browse hands each proxy answering within timeout to found, once, until
+// found returns true.
+func browse(ctx context.Context, timeout time.Duration, found func(Proxy) bool) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	entries := make(chan *zeroconf.ServiceEntry, 32)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- zeroconf.Browse(ctx, ServiceName, "local.", entries)
+	}()
+
+	seen := make(map[string]bool)
+	for {
+		select {
+		case entry, ok := <-entries:
+			if !ok {
+				entries = nil // closed; wait for Browse to return
+				continue
+			}
+			p, ok := proxyFromEntry(entry)
+			if !ok || seen[p.URL] {
+				continue
+			}
+			seen[p.URL] = true
+			if found(p) {
+				// Browse closes entries once it stops, and may be blocked
+				// sending one until then
+				cancel()
+				for range entries {
+				}
+				<-errCh
+				return nil
+			}
+		case err := <-errCh:
+			if err != nil && ctx.Err() == nil {
+				return err
+			}
+			// Entries queued before Browse returned are dropped: the
+			// time was up
+			return nil
+		}
+	}
+}
+
+// sortProxies puts debswarm proxies first, keeping the order otherwise.
+func sortProxies(proxies []Proxy) {
+	slices.SortStableFunc(proxies, func(a, b Proxy) int {
+		switch {
+		case a.Debswarm == b.Debswarm:
+			return 0
+		case a.Debswarm:
+			return -1
+		default:
+			return 1
+		}
+	})
+}
+
+// proxyFromEntry returns the proxy an mDNS entry advertises. IPv4 addresses
+// are preferred; link-local IPv6 ones are skipped, as a URL cannot carry
+// their zone for APT.
+func proxyFromEntry(entry *zeroconf.ServiceEntry) (Proxy, bool) {
+	if entry.Port <= 0 || entry.Port > 65535 {
+		return Proxy{}, false
+	}
+	var ip net.IP
+	for _, addr := range append(slices.Clone(entry.AddrIPv4), entry.AddrIPv6...) {
+		if !addr.IsLinkLocalUnicast() && !addr.IsLoopback() && !addr.IsUnspecified() {
+			ip = addr
+			break
+		}
+	}
+	if ip == nil {
+		return Proxy{}, false
+	}
+	p := Proxy{
+		Instance: unescapeInstance(entry.Instance),
+		URL:      "http://" + net.JoinHostPort(ip.String(), strconv.Itoa(entry.Port)),
+	}
+	for _, rec := range entry.Text {
+		switch {
+		case rec == txtProduct+product:
+			p.Debswarm = true
+		case strings.HasPrefix(rec, txtVersion):
+			p.Version = strings.TrimPrefix(rec, txtVersion)
+		}
+	}
+	return p, true
+}
+
+// unescapeInstance undoes the DNS escaping of an instance name, as in
+// `debswarm\ on\ office`.
+func unescapeInstance(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == '\\' && i+1 < len(name) {
+			if i+3 < len(name) && isDigit(name[i+1]) && isDigit(name[i+2]) && isDigit(name[i+3]) {
+				if n, err := strconv.Atoi(name[i+1 : i+4]); err == nil && n < 256 {
+					b.WriteByte(byte(n))
+					i += 3
+					continue
+				}
+			}
+			i++
+			c = name[i]
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// ErrNoProxy is returned by Reachable when no proxy accepts connections.
+var ErrNoProxy = errors.New("no reachable APT proxy")
+
+// Reachable returns the first of proxies that accepts a TCP connection
+// within timeout, as APT would connect to it. An advertisement can outlive
+// its proxy, or announce an address the client cannot route to.
+func Reachable(ctx context.Context, proxies []Proxy, timeout time.Duration) (Proxy, error) {
+	var d net.Dialer
+	for _, p := range proxies {
+		host := strings.TrimPrefix(p.URL, "http://")
+		dialCtx, cancel := context.WithTimeout(ctx, timeout)
+		conn, err := d.DialContext(dialCtx, "tcp", host)
+		cancel()
+		if err == nil {
+			_ = conn.Close()
+			return p, nil
+		}
+	}
+	return Proxy{}, ErrNoProxy
+}
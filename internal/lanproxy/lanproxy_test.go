@@ -0,0 +1,78 @@
+package lanproxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/libp2p/zeroconf/v2"
+)
+
+func TestProxyFromEntry(t *testing.T) {
+	entry := &zeroconf.ServiceEntry{
+		ServiceRecord: zeroconf.ServiceRecord{Instance: `debswarm\ on\ office-cache`},
+		Port:          9977,
+		Text:          []string{"product=debswarm", "version=1.40.0"},
+		AddrIPv4:      []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("192.168.1.10")},
+		AddrIPv6:      []net.IP{net.ParseIP("fe80::1"), net.ParseIP("2001:db8::10")},
+	}
+	p, ok := proxyFromEntry(entry)
+	if !ok || p.URL != "http://192.168.1.10:9977" || !p.Debswarm || p.Version != "1.40.0" || p.Instance != "debswarm on office-cache" {
+		t.Errorf("proxy = %+v, %v", p, ok)
+	}
+
+	// IPv6 only, and not debswarm (e.g. apt-cacher-ng)
+	entry.AddrIPv4 = nil
+	entry.Text = nil
+	p, ok = proxyFromEntry(entry)
+	if !ok || p.URL != "http://[2001:db8::10]:9977" || p.Debswarm {
+		t.Errorf("IPv6 proxy = %+v, %v", p, ok)
+	}
+
+	entry.AddrIPv6 = []net.IP{net.ParseIP("fe80::1")}
+	if p, ok := proxyFromEntry(entry); ok {
+		t.Errorf("link-local only entry gave %+v", p)
+	}
+}
+
+func TestUnescapeInstance(t *testing.T) {
+	for in, want := range map[string]string{
+		`debswarm\ on\ vm`: "debswarm on vm",
+		`a\.b\\c`:          `a.b\c`,
+		`caf\195\169`:      "café",
+		"plain":            "plain",
+		`trailing\`:        `trailing\`,
+	} {
+		if got := unescapeInstance(in); got != want {
+			t.Errorf("unescapeInstance(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestReachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	_ = closed.Close()
+
+	proxies := []Proxy{
+		{Instance: "gone", URL: "http://" + closedAddr},
+		{Instance: "up", URL: "http://" + ln.Addr().String()},
+	}
+	p, err := Reachable(context.Background(), proxies, time.Second)
+	if err != nil || p.Instance != "up" {
+		t.Errorf("Reachable = %+v, %v", p, err)
+	}
+	if _, err := Reachable(context.Background(), proxies[:1], time.Second); !errors.Is(err, ErrNoProxy) {
+		t.Errorf("err = %v, want ErrNoProxy", err)
+	}
+}
@@ -0,0 +1,198 @@
+// Package localmirror serves packages to peers straight from an existing
+// Debian mirror tree. The mirror's own Packages indexes supply each file's
+// hash, so nothing is re-hashed or copied into the cache: a mirror operator
+// seeds the swarm without storing every package twice.
+package localmirror
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+// ErrNotFound is returned by Open for a hash the mirror does not hold, or
+// whose file no longer matches its index entry.
+var ErrNotFound = errors.New("package not in local mirror")
+
+// compressionPreference orders the variants of one Packages index, cheapest
+// to parse first; only one variant per index is read.
+var compressionPreference = []string{"", ".xz", ".zst", ".gz", ".lz4", ".bz2"}
+
+// Mirror is an on-disk Debian mirror tree indexed by SHA256.
+type Mirror struct {
+	root   string
+	logger *zap.Logger
+
+	mu    sync.RWMutex
+	idx   *index.Index
+	stamp string // index files and their mtimes at the last Load
+}
+
+// New returns a Mirror rooted at root, the directory holding dists/ and
+// pool/. It is empty until Load is called.
+func New(root string, logger *zap.Logger) *Mirror {
+	return &Mirror{
+		root:   filepath.Clean(root),
+		logger: logger,
+		idx:    index.New("", logger),
+	}
+}
+
+// Root returns the mirror's root directory.
+func (m *Mirror) Root() string {
+	return m.root
+}
+
+// Load (re)reads the mirror's Packages indexes. Indexes are only parsed
+// again when one was added, removed or modified since the last Load, as
+// after a mirror sync; it reports whether they were.
+func (m *Mirror) Load() (bool, error) {
+	files, err := m.indexFiles()
+	if err != nil {
+		return false, err
+	}
+	stamp, err := stampOf(files)
+	if err != nil {
+		return false, err
+	}
+	m.mu.RLock()
+	unchanged := stamp == m.stamp
+	m.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	idx := index.New("", m.logger)
+	for _, path := range files {
+		if err := idx.LoadFromFileWithRepo(path, m.root); err != nil {
+			m.logger.Warn("Failed to parse local mirror index",
+				zap.String("path", path), zap.Error(err))
+		}
+	}
+
+	m.mu.Lock()
+	m.idx, m.stamp = idx, stamp
+	m.mu.Unlock()
+	m.logger.Info("Indexed local mirror",
+		zap.String("root", m.root),
+		zap.Int("indexes", len(files)),
+		zap.Int("packages", idx.Count()))
+	return true, nil
+}
+
+// indexFiles returns one file per Packages index under dists/, preferring
+// the uncompressed variant when several exist.
+func (m *Mirror) indexFiles() ([]string, error) {
+	dists := filepath.Join(m.root, "dists")
+	variants := make(map[string]map[string]string) // dir → ext → path
+	err := filepath.WalkDir(dists, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// by-hash holds older copies of the same indexes under their digests
+			if d.Name() == "by-hash" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext, ok := strings.CutPrefix(d.Name(), "Packages")
+		if !ok || !isCompressionExt(ext) {
+			return nil
+		}
+		dir := filepath.Dir(path)
+		if variants[dir] == nil {
+			variants[dir] = make(map[string]string)
+		}
+		variants[dir][ext] = path
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dists, err)
+	}
+
+	files := make([]string, 0, len(variants))
+	for _, byExt := range variants {
+		for _, ext := range compressionPreference {
+			if path, ok := byExt[ext]; ok {
+				files = append(files, path)
+				break
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func isCompressionExt(ext string) bool {
+	for _, e := range compressionPreference {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// stampOf identifies a set of index files by name, size and mtime.
+func stampOf(files []string) (string, error) {
+	var b strings.Builder
+	for _, path := range files {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", path, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// Lookup returns the index entry for a hash, or nil.
+func (m *Mirror) Lookup(sha256Hash string) *index.PackageInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.idx.GetBySHA256(sha256Hash)
+}
+
+// Packages returns every package the mirror's indexes list.
+func (m *Mirror) Packages() []*index.PackageInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.idx.Packages()
+}
+
+// Count returns the number of packages the mirror's indexes list.
+func (m *Mirror) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.idx.Count()
+}
+
+// Open opens the mirror file for a hash. The file is not re-hashed: a file
+// whose size no longer matches its index entry, as mid-sync, is treated as
+// missing, and peers verify what they receive in any case.
+func (m *Mirror) Open(sha256Hash string) (io.ReadCloser, int64, error) {
+	pkg := m.Lookup(sha256Hash)
+	if pkg == nil || !filepath.IsLocal(filepath.FromSlash(pkg.Filename)) {
+		return nil, 0, ErrNotFound
+	}
+	f, err := os.Open(filepath.Join(m.root, filepath.FromSlash(pkg.Filename)))
+	if err != nil {
+		return nil, 0, ErrNotFound
+	}
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() || (pkg.Size > 0 && fi.Size() != pkg.Size) {
+		_ = f.Close()
+		return nil, 0, ErrNotFound
+	}
+	return f, fi.Size(), nil
+}
@@ -0,0 +1,120 @@
+package localmirror
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/hashutil"
+)
+
+// writeFile writes data under root, creating parent directories.
+func writeFile(t *testing.T, root, rel string, data []byte) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func stanza(name, filename string, data []byte) string {
+	return fmt.Sprintf("Package: %s\nVersion: 1.0\nArchitecture: amd64\nFilename: %s\nSize: %d\nSHA256: %s\n\n",
+		name, filename, len(data), hashutil.HashBytes(data))
+}
+
+func gz(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func read(t *testing.T, m *Mirror, hash string) ([]byte, error) {
+	t.Helper()
+	rc, size, err := m.Open(hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err == nil && int64(len(data)) != size {
+		t.Errorf("Open size = %d, read %d bytes", size, len(data))
+	}
+	return data, err
+}
+
+func TestMirror(t *testing.T) {
+	root := t.TempDir()
+	hello := []byte("hello package contents")
+	world := []byte("world package contents")
+	escape := []byte("outside the mirror")
+	writeFile(t, root, "pool/main/h/hello/hello_1.0_amd64.deb", hello)
+	writeFile(t, root, "pool/main/w/world/world_1.0_amd64.deb", world)
+	writeFile(t, filepath.Dir(root), "secret.deb", escape)
+
+	// Only one variant of an index is read; the by-hash copy is not.
+	packages := stanza("hello", "pool/main/h/hello/hello_1.0_amd64.deb", hello) +
+		stanza("escape", "../secret.deb", escape)
+	writeFile(t, root, "dists/bookworm/main/binary-amd64/Packages", []byte(packages))
+	writeFile(t, root, "dists/bookworm/main/binary-amd64/Packages.gz", gz(t, packages))
+	writeFile(t, root, "dists/bookworm/main/binary-amd64/by-hash/SHA256/abc", []byte(stanza("world", "pool/main/w/world/world_1.0_amd64.deb", world)))
+
+	m := New(root, zap.NewNop())
+	changed, err := m.Load()
+	if err != nil || !changed {
+		t.Fatalf("Load = %v, %v", changed, err)
+	}
+	if m.Count() != 2 {
+		t.Errorf("Count = %d, want 2", m.Count())
+	}
+
+	if got, err := read(t, m, hashutil.HashBytes(hello)); err != nil || !bytes.Equal(got, hello) {
+		t.Errorf("hello = %q, %v", got, err)
+	}
+	if _, err := read(t, m, hashutil.HashBytes(world)); err != ErrNotFound {
+		t.Errorf("package only in by-hash: err = %v, want ErrNotFound", err)
+	}
+	if _, err := read(t, m, hashutil.HashBytes(escape)); err != ErrNotFound {
+		t.Errorf("Filename outside the mirror: err = %v, want ErrNotFound", err)
+	}
+
+	// Unchanged indexes are not parsed again
+	if changed, err := m.Load(); err != nil || changed {
+		t.Errorf("second Load = %v, %v; want unchanged", changed, err)
+	}
+
+	// A sync adds an index and rewrites a pool file mid-way
+	writeFile(t, root, "dists/bookworm/contrib/binary-amd64/Packages.gz",
+		gz(t, stanza("world", "pool/main/w/world/world_1.0_amd64.deb", world)))
+	writeFile(t, root, "pool/main/h/hello/hello_1.0_amd64.deb", hello[:5])
+	if changed, err := m.Load(); err != nil || !changed {
+		t.Fatalf("Load after sync = %v, %v", changed, err)
+	}
+	if got, err := read(t, m, hashutil.HashBytes(world)); err != nil || !bytes.Equal(got, world) {
+		t.Errorf("world after sync = %q, %v", got, err)
+	}
+	if _, err := read(t, m, hashutil.HashBytes(hello)); err != ErrNotFound {
+		t.Errorf("file not matching its index size: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMirror_NoDists(t *testing.T) {
+	if _, err := New(t.TempDir(), zap.NewNop()).Load(); err == nil {
+		t.Error("Load of a directory without dists/ succeeded")
+	}
+}
@@ -24,6 +24,60 @@ type Metrics struct {
 	// means the cache is undersized for the workload.
 	CacheEvictions *Counter
 
+	// DiskPressureEvictions counts packages evicted because free disk space
+	// fell below cache.min_free_space between writes; CacheDiskPressure is 1
+	// while the watcher cannot restore it.
+	DiskPressureEvictions *Counter
+	CacheDiskPressure     *Gauge
+
+	// CacheEvictionsByReason breaks evictions down by reason ("capacity",
+	// "disk_pressure", "metadata"); unlike CacheEvictions it includes
+	// metadata cache evictions.
+	CacheEvictionsByReason *CounterVec
+
+	// PartialDirsRemoved counts partial-download directories removed, by
+	// reason ("stale" = swept after the retry window, "corrupt" = dropped
+	// after the assembled file failed verification).
+	PartialDirsRemoved *CounterVec
+
+	// DHTBudgetRejected counts DHT operations (by operation) refused or
+	// abandoned because dht.provide_budget / dht.lookup_budget was spent.
+	DHTBudgetRejected *CounterVec
+	// DHTBudgetQueued is how many DHT operations (by operation) are waiting
+	// for budget.
+	DHTBudgetQueued *GaugeVec
+
+	// Announcement queue for freshly cached packages: how many are waiting,
+	// how many were dropped because the queue was full (they wait for the
+	// next reannounce pass instead), and announcement outcomes by result
+	// ("ok", "failed", "refused" by a hook or policy) for both the queue and
+	// reannounce passes.
+	AnnounceQueueDepth   *Gauge
+	AnnounceQueueDropped *Counter
+	Announcements        *CounterVec
+
+	// CoalescedRequests counts requests that shared the work of an identical
+	// one already running instead of doing it again, by kind ("package",
+	// "retry", "release", "pdiff").
+	CoalescedRequests *CounterVec
+
+	// NetworkChanges counts recoveries after a network change, by reason
+	// ("resume" from suspend, "addresses" changed).
+	NetworkChanges *CounterVec
+
+	// InflightStreams counts package requests served by streaming an
+	// in-flight download rather than waiting for it to complete.
+	InflightStreams *Counter
+
+	// ReadThroughDownloads counts packages completed from the prefix of an
+	// interrupted download plus a range request to the mirror.
+	ReadThroughDownloads *Counter
+
+	// LowDiversityProviders counts downloads where the provider set spanned
+	// fewer address groups than peer_selection.min_address_groups, so the
+	// mirror was used instead (a possible eclipse attempt).
+	LowDiversityProviders *Counter
+
 	// PeersBlacklisted counts peers blacklisted for serving corrupt data —
 	// the primary security-operational signal.
 	PeersBlacklisted *Counter
@@ -43,6 +97,125 @@ type Metrics struct {
 	// MetadataCacheStaleServed counts metadata files served from cache without a
 	// successful upstream revalidation (mirror unreachable / offline).
 	MetadataCacheStaleServed *Counter
+	// PassthroughFreshHits counts passthrough objects served from cache
+	// within their TTL, without asking the mirror.
+	PassthroughFreshHits *Counter
+
+	// PdiffReconstructions counts attempts to rebuild an index from its
+	// pdiffs, labeled by result (rebuilt, current, no_base, failed).
+	PdiffReconstructions *CounterVec
+	// MetadataP2P counts index files shared over P2P, labeled by result:
+	// current (cached copy still listed by the Release), downloaded (from a
+	// peer), no_providers, failed, and uploaded (served to a peer).
+	MetadataP2P *CounterVec
+
+	// BuildPackages counts packages served on the build listener
+	BuildPackages *Counter
+
+	// In-memory tier lookups, labeled by kind (package, metadata). Only reads
+	// of files small enough for the tier are counted.
+	MemoryTierHits   *CounterVec
+	MemoryTierMisses *CounterVec
+
+	// Revocation list enforcement. RevokedBlocked is labeled by direction
+	// (download = refused to an APT client, upload = refused to a peer);
+	// RevokedPurged counts cached packages deleted because they were revoked.
+	RevokedBlocked *CounterVec
+	RevokedPurged  *Counter
+
+	// Client-chosen source policies, labeled by policy. SourcePolicyRefused
+	// counts package requests the policy left without an allowed source.
+	SourcePolicyRequests *CounterVec
+	SourcePolicyRefused  *CounterVec
+
+	// P2PPaused is 1 while P2P participation is paused by the kill switch
+	P2PPaused *Gauge
+
+	// Uploads to peers below the sharing ratio, labeled by result
+	// ("throttled" = served at the leecher rate, "refused" = no slot)
+	SharingLeecherUploads *CounterVec
+
+	// Uploads of security updates served with upload priority
+	PriorityUploads *Counter
+
+	// Concurrent uploads accepted while admission control watches host load
+	UploadAdmissionLimit *Gauge
+
+	// Upload policy set by the power source and network, 1 for the policy
+	// in force (normal|throttled|disabled)
+	UploadPowerPolicy *GaugeVec
+
+	// Split-horizon LAN-first attempts, labeled by result ("lan" = served by
+	// LAN peers within budget, "fallback" = WAN peers and the mirror joined)
+	SplitHorizonDownloads *CounterVec
+
+	// Fetches from fleet peers over HTTPS after the P2P transfer failed,
+	// labeled by result (success|failure)
+	FleetHTTPSFallbacks *CounterVec
+
+	// Background downloads held back while an interactive download ran,
+	// and background uploads refused to keep slots free for interactive
+	// requests, labeled by class (replication|prefetch)
+	BackgroundDownloadWaits  *CounterVec
+	BackgroundUploadsRefused *CounterVec
+
+	// Bytes of compressed uploads, labeled "raw" (content) and "wire"
+	// (sent after compression)
+	TransferCompression *CounterVec
+
+	// Packages refused by a pipeline hook, labeled by stage
+	// ("pre_announce", "pre_serve")
+	HookRejections *CounterVec
+
+	// Malware scans before caching, labeled by result
+	// ("clean", "infected", "error")
+	PackageScans *CounterVec
+
+	// Failed client requests, labeled by the X-Debswarm-Error code
+	RequestErrors *CounterVec
+
+	// Canary checks of peer-served packages against the mirror, labeled by
+	// result ("match", "mismatch", "error"), and how long the same package
+	// took over P2P and from the mirror
+	CanaryChecks         *CounterVec
+	CanaryP2PDuration    *Histogram
+	CanaryMirrorDuration *Histogram
+
+	// Cache replication from partner seeds, labeled by result ("replicated",
+	// "cached", "filtered", "failed", "dropped"); how long after the partner
+	// cached a package it was stored here; replication fetches waiting; and
+	// partners this node is subscribed to
+	Replications          *CounterVec
+	ReplicationLag        *Histogram
+	ReplicationQueueDepth *Gauge
+	ReplicationPartners   *Gauge
+
+	// Packages fetched for prefetch groups, labeled by result ("fetched",
+	// "failed")
+	PrefetchPackages *CounterVec
+
+	// Daemons sharing the uplink budget, this one included, and this
+	// daemon's share of it in bytes/sec, labeled by direction ("upload",
+	// "download")
+	UplinkMembers *Gauge
+	UplinkShare   *GaugeVec
+
+	// Transfer streams to peers reused from the pool or opened, labeled by
+	// result ("reused", "opened"), the idle streams pooled, and streams
+	// found checked out long after any transfer could have ended
+	PeerStreams       *CounterVec
+	PeerStreamsIdle   *Gauge
+	PeerStreamsLeaked *Counter
+
+	// Hedged chunk requests, labeled by result ("won" = the duplicate
+	// request delivered first, "lost" = the original did), and chunk
+	// requests refused because the download's retry budget was spent
+	ChunkHedges          *CounterVec
+	RetryBudgetExhausted *Counter
+
+	// Chunks sent to a lower-ranked source because the best peer already
+	// had its share of chunk requests from all downloads outstanding
+	ChunkSpills *Counter
 
 	// Resume metrics
 	DownloadsResumed *Counter
@@ -55,13 +228,28 @@ type Metrics struct {
 	PeersJoined *Counter
 	PeersLeft   *Counter
 
+	// MDNSPeersFiltered counts LAN nodes skipped because they advertised a
+	// different swarm fingerprint
+	MDNSPeersFiltered *Counter
+
+	// Cache-full backpressure: package writes refused for lack of space, and
+	// whether the cache is currently refusing them (1) or not (0)
+	CacheFullRefusals *Counter
+	CacheDegraded     *Gauge
+
+	// Failures injected by the [chaos] testing settings, labeled by fault
+	// ("drop_stream", "dht_delay", "corrupt")
+	ChaosFaults *CounterVec
+
 	// Gauges
 	ConnectedPeers    *Gauge
+	PeersCircuitOpen  *Gauge // peers skipped because their circuit breaker is open
 	RoutingTableSize  *Gauge
 	CacheSize         *Gauge
 	CacheMaxSize      *Gauge // configured capacity, so dashboards can compute fill percentage
 	CacheCount        *Gauge
 	MetadataCacheSize *Gauge // current repository-metadata cache size in bytes
+	MemoryTierSize    *Gauge // bytes held by the in-memory tier
 	ActiveDownloads   *Gauge
 	ActiveUploads     *Gauge
 
@@ -98,7 +286,8 @@ type Metrics struct {
 
 	// Histograms
 	// PeerLatency is deliberately unlabeled: labeling by peer ID made the
-	// series set grow without bound on a public-DHT node.
+	// series set grow without bound on a public-DHT node. Per-peer
+	// breakdowns with bounded labels are in Peers.
 	PeerLatency       *Histogram
 	ChunkDownloadTime *Histogram
 	DHTLookupDuration *Histogram
@@ -133,6 +322,12 @@ type Metrics struct {
 	// a relay is carrying. See docs/design/relay-data-fallback.md.
 	BytesFromRelay       *Counter    // Bytes fetched over a relay (a subset of peer bytes)
 	RelayedTransferTotal *CounterVec // Relayed-transfer attempts, by result (ok|too_large)
+
+	// Signed receipts asked of uploaders after downloads, by result (ok|missing)
+	TransferReceipts *CounterVec
+
+	// Per-peer transfer series, off unless configured (see PeerMetrics)
+	Peers *PeerMetrics
 }
 
 // Counter is a simple counter metric
@@ -198,6 +393,13 @@ func (cv *CounterVec) Values() map[string]int64 {
 	return result
 }
 
+// Delete removes the counter with the given label.
+func (cv *CounterVec) Delete(label string) {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	delete(cv.counters, label)
+}
+
 // Gauge is a metric that can go up and down.
 type Gauge struct {
 	value float64
@@ -350,11 +552,33 @@ func (hv *HistogramVec) WithLabel(label string) *Histogram {
 	return h
 }
 
+// Delete removes the histogram with the given label.
+func (hv *HistogramVec) Delete(label string) {
+	hv.mu.Lock()
+	defer hv.mu.Unlock()
+	delete(hv.histograms, label)
+}
+
+// snapshot returns the histograms by label.
+func (hv *HistogramVec) snapshot() map[string]*Histogram {
+	hv.mu.RLock()
+	defer hv.mu.RUnlock()
+	result := make(map[string]*Histogram, len(hv.histograms))
+	for k, h := range hv.histograms {
+		result[k] = h
+	}
+	return result
+}
+
 // Default buckets for different metric types
 var (
 	DurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
 	SizeBuckets     = []float64{1024, 10240, 102400, 1048576, 10485760, 104857600, 1073741824}
 	LatencyBuckets  = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+	// ReplicationLagBuckets span seconds to a day, as a partner that was
+	// offline catches up on what it missed
+	ReplicationLagBuckets = []float64{1, 5, 15, 30, 60, 300, 900, 3600, 21600, 86400}
 )
 
 // New creates a new Metrics instance
@@ -364,17 +588,84 @@ func New() *Metrics {
 		BytesDownloaded:        NewCounterVec(),
 		BytesUploaded:          &Counter{},
 		DHTQueries:             NewCounterVec(),
+		DHTBudgetRejected:      NewCounterVec(),
 		CacheHits:              &Counter{},
 		CacheMisses:            &Counter{},
 		VerificationFailures:   &Counter{},
 		CacheEvictions:         &Counter{},
 		PeersBlacklisted:       &Counter{},
+		LowDiversityProviders:  &Counter{},
+		InflightStreams:        &Counter{},
+		ReadThroughDownloads:   &Counter{},
 		PackagesServedUncached: &Counter{},
 
+		DiskPressureEvictions:  &Counter{},
+		CacheEvictionsByReason: NewCounterVec(),
+		PartialDirsRemoved:     NewCounterVec(),
+		DHTBudgetQueued:        NewGaugeVec(),
+		AnnounceQueueDepth:     &Gauge{},
+		AnnounceQueueDropped:   &Counter{},
+		Announcements:          NewCounterVec(),
+		CoalescedRequests:      NewCounterVec(),
+		NetworkChanges:         NewCounterVec(),
+		CacheDiskPressure:      &Gauge{},
+
 		MetadataCacheHits:        &Counter{},
 		MetadataCacheMisses:      &Counter{},
 		MetadataCacheBytesSaved:  &Counter{},
 		MetadataCacheStaleServed: &Counter{},
+		PassthroughFreshHits:     &Counter{},
+
+		PdiffReconstructions: NewCounterVec(),
+		MetadataP2P:          NewCounterVec(),
+		BuildPackages:        &Counter{},
+
+		MemoryTierHits:   NewCounterVec(),
+		MemoryTierMisses: NewCounterVec(),
+
+		RevokedBlocked: NewCounterVec(),
+		RevokedPurged:  &Counter{},
+
+		SourcePolicyRequests: NewCounterVec(),
+		SourcePolicyRefused:  NewCounterVec(),
+
+		P2PPaused: &Gauge{},
+
+		SharingLeecherUploads: NewCounterVec(),
+		PriorityUploads:       &Counter{},
+		UploadAdmissionLimit:  &Gauge{},
+		UploadPowerPolicy:     NewGaugeVec(),
+		SplitHorizonDownloads: NewCounterVec(),
+		FleetHTTPSFallbacks:   NewCounterVec(),
+		TransferCompression:   NewCounterVec(),
+		HookRejections:        NewCounterVec(),
+		PackageScans:          NewCounterVec(),
+		RequestErrors:         NewCounterVec(),
+
+		BackgroundDownloadWaits:  NewCounterVec(),
+		BackgroundUploadsRefused: NewCounterVec(),
+
+		CanaryChecks:         NewCounterVec(),
+		CanaryP2PDuration:    NewHistogram(DurationBuckets),
+		CanaryMirrorDuration: NewHistogram(DurationBuckets),
+
+		Replications:          NewCounterVec(),
+		ReplicationLag:        NewHistogram(ReplicationLagBuckets),
+		ReplicationQueueDepth: &Gauge{},
+		ReplicationPartners:   &Gauge{},
+
+		PrefetchPackages: NewCounterVec(),
+
+		UplinkMembers: &Gauge{},
+		UplinkShare:   NewGaugeVec(),
+
+		PeerStreams:       NewCounterVec(),
+		PeerStreamsIdle:   &Gauge{},
+		PeerStreamsLeaked: &Counter{},
+
+		ChunkHedges:          NewCounterVec(),
+		RetryBudgetExhausted: &Counter{},
+		ChunkSpills:          &Counter{},
 
 		// Resume metrics
 		DownloadsResumed: &Counter{},
@@ -387,12 +678,20 @@ func New() *Metrics {
 		PeersJoined: &Counter{},
 		PeersLeft:   &Counter{},
 
+		MDNSPeersFiltered: &Counter{},
+
+		CacheFullRefusals: &Counter{},
+		CacheDegraded:     &Gauge{},
+		ChaosFaults:       NewCounterVec(),
+
 		ConnectedPeers:    &Gauge{},
+		PeersCircuitOpen:  &Gauge{},
 		CacheMaxSize:      &Gauge{},
 		RoutingTableSize:  &Gauge{},
 		CacheSize:         &Gauge{},
 		CacheCount:        &Gauge{},
 		MetadataCacheSize: &Gauge{},
+		MemoryTierSize:    &Gauge{},
 		ActiveDownloads:   &Gauge{},
 		ActiveUploads:     &Gauge{},
 
@@ -447,6 +746,10 @@ func New() *Metrics {
 
 		BytesFromRelay:       &Counter{},
 		RelayedTransferTotal: NewCounterVec(),
+
+		TransferReceipts: NewCounterVec(),
+
+		Peers: newPeerMetrics(),
 	}
 }
 
@@ -461,13 +764,141 @@ func (m *Metrics) Handler() http.Handler {
 		writeCounter(w, "debswarm_cache_evictions_total", m.CacheEvictions.Value())
 		writeCounter(w, "debswarm_verification_failures_total", m.VerificationFailures.Value())
 		writeCounter(w, "debswarm_peers_blacklisted_total", m.PeersBlacklisted.Value())
+		writeCounter(w, "debswarm_low_diversity_providers_total", m.LowDiversityProviders.Value())
+		writeCounter(w, "debswarm_inflight_streams_total", m.InflightStreams.Value())
+		writeCounter(w, "debswarm_read_through_downloads_total", m.ReadThroughDownloads.Value())
 		writeCounter(w, "debswarm_packages_served_uncached_total", m.PackagesServedUncached.Value())
 
+		// Disk pressure
+		writeCounter(w, "debswarm_cache_disk_pressure_evictions_total", m.DiskPressureEvictions.Value())
+		writeGauge(w, "debswarm_cache_disk_pressure", m.CacheDiskPressure.Value())
+		for label, value := range m.CacheEvictionsByReason.Values() {
+			writeCounterWithLabel(w, "debswarm_cache_evictions_by_reason_total", "reason", label, value)
+		}
+		for label, value := range m.PartialDirsRemoved.Values() {
+			writeCounterWithLabel(w, "debswarm_partial_dirs_removed_total", "reason", label, value)
+		}
+
+		// Announcements and request coalescing
+		writeGauge(w, "debswarm_announce_queue_depth", m.AnnounceQueueDepth.Value())
+		writeCounter(w, "debswarm_announce_queue_dropped_total", m.AnnounceQueueDropped.Value())
+		for label, value := range m.Announcements.Values() {
+			writeCounterWithLabel(w, "debswarm_announcements_total", "result", label, value)
+		}
+		for label, value := range m.CoalescedRequests.Values() {
+			writeCounterWithLabel(w, "debswarm_coalesced_requests_total", "kind", label, value)
+		}
+		for label, value := range m.NetworkChanges.Values() {
+			writeCounterWithLabel(w, "debswarm_network_changes_total", "reason", label, value)
+		}
+
 		// Metadata (repository index) cache
 		writeCounter(w, "debswarm_metadata_cache_hits_total", m.MetadataCacheHits.Value())
 		writeCounter(w, "debswarm_metadata_cache_misses_total", m.MetadataCacheMisses.Value())
 		writeCounter(w, "debswarm_metadata_cache_bytes_saved_total", m.MetadataCacheBytesSaved.Value())
 		writeCounter(w, "debswarm_metadata_cache_stale_served_total", m.MetadataCacheStaleServed.Value())
+		writeCounter(w, "debswarm_passthrough_fresh_hits_total", m.PassthroughFreshHits.Value())
+
+		for label, value := range m.PdiffReconstructions.Values() {
+			writeCounterWithLabel(w, "debswarm_pdiff_reconstructions_total", "result", label, value)
+		}
+		for label, value := range m.MetadataP2P.Values() {
+			writeCounterWithLabel(w, "debswarm_metadata_p2p_total", "result", label, value)
+		}
+		writeCounter(w, "debswarm_build_packages_total", m.BuildPackages.Value())
+
+		// In-memory tier
+		for label, value := range m.MemoryTierHits.Values() {
+			writeCounterWithLabel(w, "debswarm_memory_tier_hits_total", "kind", label, value)
+		}
+		for label, value := range m.MemoryTierMisses.Values() {
+			writeCounterWithLabel(w, "debswarm_memory_tier_misses_total", "kind", label, value)
+		}
+
+		// Revocation list enforcement
+		for label, value := range m.RevokedBlocked.Values() {
+			writeCounterWithLabel(w, "debswarm_revoked_blocked_total", "direction", label, value)
+		}
+		writeCounter(w, "debswarm_revoked_purged_total", m.RevokedPurged.Value())
+
+		// Source policies
+		for label, value := range m.SourcePolicyRequests.Values() {
+			writeCounterWithLabel(w, "debswarm_source_policy_requests_total", "policy", label, value)
+		}
+		for label, value := range m.SourcePolicyRefused.Values() {
+			writeCounterWithLabel(w, "debswarm_source_policy_refused_total", "policy", label, value)
+		}
+		writeGauge(w, "debswarm_p2p_paused", m.P2PPaused.Value())
+		for label, value := range m.SharingLeecherUploads.Values() {
+			writeCounterWithLabel(w, "debswarm_sharing_leecher_uploads_total", "result", label, value)
+		}
+		writeCounter(w, "debswarm_priority_uploads_total", m.PriorityUploads.Value())
+		writeGauge(w, "debswarm_upload_admission_limit", m.UploadAdmissionLimit.Value())
+		for label, value := range m.UploadPowerPolicy.Values() {
+			writeGaugeWithLabel(w, "debswarm_upload_power_policy", "policy", label, value)
+		}
+		for label, value := range m.SplitHorizonDownloads.Values() {
+			writeCounterWithLabel(w, "debswarm_split_horizon_downloads_total", "result", label, value)
+		}
+		for label, value := range m.FleetHTTPSFallbacks.Values() {
+			writeCounterWithLabel(w, "debswarm_fleet_https_fallbacks_total", "result", label, value)
+		}
+		for label, value := range m.BackgroundDownloadWaits.Values() {
+			writeCounterWithLabel(w, "debswarm_background_download_waits_total", "class", label, value)
+		}
+		for label, value := range m.BackgroundUploadsRefused.Values() {
+			writeCounterWithLabel(w, "debswarm_background_uploads_refused_total", "class", label, value)
+		}
+		for label, value := range m.TransferCompression.Values() {
+			writeCounterWithLabel(w, "debswarm_transfer_compression_bytes_total", "stage", label, value)
+		}
+		for label, value := range m.HookRejections.Values() {
+			writeCounterWithLabel(w, "debswarm_hook_rejections_total", "stage", label, value)
+		}
+		for label, value := range m.PackageScans.Values() {
+			writeCounterWithLabel(w, "debswarm_package_scans_total", "result", label, value)
+		}
+		for label, value := range m.RequestErrors.Values() {
+			writeCounterWithLabel(w, "debswarm_request_errors_total", "code", label, value)
+		}
+		for label, value := range m.CanaryChecks.Values() {
+			writeCounterWithLabel(w, "debswarm_canary_checks_total", "result", label, value)
+		}
+		writeHistogram(w, "debswarm_canary_p2p_seconds", m.CanaryP2PDuration)
+		writeHistogram(w, "debswarm_canary_mirror_seconds", m.CanaryMirrorDuration)
+
+		// Cache replication
+		for label, value := range m.Replications.Values() {
+			writeCounterWithLabel(w, "debswarm_replication_packages_total", "result", label, value)
+		}
+		writeHistogram(w, "debswarm_replication_lag_seconds", m.ReplicationLag)
+		writeGauge(w, "debswarm_replication_queue_depth", m.ReplicationQueueDepth.Value())
+		writeGauge(w, "debswarm_replication_partners_connected", m.ReplicationPartners.Value())
+
+		// Prefetch groups
+		for label, value := range m.PrefetchPackages.Values() {
+			writeCounterWithLabel(w, "debswarm_prefetch_packages_total", "result", label, value)
+		}
+
+		// Shared uplink budget
+		writeGauge(w, "debswarm_uplink_members", m.UplinkMembers.Value())
+		for label, value := range m.UplinkShare.Values() {
+			writeGaugeWithLabel(w, "debswarm_uplink_share_bytes_per_second", "direction", label, value)
+		}
+
+		// Transfer stream pool
+		for label, value := range m.PeerStreams.Values() {
+			writeCounterWithLabel(w, "debswarm_peer_streams_total", "result", label, value)
+		}
+		writeGauge(w, "debswarm_peer_streams_idle", m.PeerStreamsIdle.Value())
+		writeCounter(w, "debswarm_peer_streams_leaked_total", m.PeerStreamsLeaked.Value())
+
+		// Hedging and retry budget
+		for label, value := range m.ChunkHedges.Values() {
+			writeCounterWithLabel(w, "debswarm_chunk_hedges_total", "result", label, value)
+		}
+		writeCounter(w, "debswarm_retry_budget_exhausted_total", m.RetryBudgetExhausted.Value())
+		writeCounter(w, "debswarm_chunk_spills_total", m.ChunkSpills.Value())
 
 		// Resume metrics
 		writeCounter(w, "debswarm_downloads_resumed_total", m.DownloadsResumed.Value())
@@ -476,6 +907,11 @@ func (m *Metrics) Handler() http.Handler {
 		// Peer churn
 		writeCounter(w, "debswarm_peers_joined_total", m.PeersJoined.Value())
 		writeCounter(w, "debswarm_peers_left_total", m.PeersLeft.Value())
+		writeCounter(w, "debswarm_mdns_peers_filtered_total", m.MDNSPeersFiltered.Value())
+		writeCounter(w, "debswarm_cache_full_refusals_total", m.CacheFullRefusals.Value())
+		for label, value := range m.ChaosFaults.Values() {
+			writeCounterWithLabel(w, "debswarm_chaos_faults_injected_total", "fault", label, value)
+		}
 
 		for label, value := range m.DownloadsTotal.Values() {
 			writeCounterWithLabel(w, "debswarm_downloads_total", "source", label, value)
@@ -487,6 +923,12 @@ func (m *Metrics) Handler() http.Handler {
 		for label, value := range m.DHTQueries.Values() {
 			writeCounterWithLabel(w, "debswarm_dht_queries_total", "operation", label, value)
 		}
+		for label, value := range m.DHTBudgetRejected.Values() {
+			writeCounterWithLabel(w, "debswarm_dht_budget_rejected_total", "operation", label, value)
+		}
+		for label, value := range m.DHTBudgetQueued.Values() {
+			writeGaugeWithLabel(w, "debswarm_dht_budget_queued", "operation", label, value)
+		}
 		// Error breakdown
 		for label, value := range m.Errors.Values() {
 			writeCounterWithLabel(w, "debswarm_errors_total", "type", label, value)
@@ -494,11 +936,14 @@ func (m *Metrics) Handler() http.Handler {
 
 		// Gauges
 		writeGauge(w, "debswarm_connected_peers", m.ConnectedPeers.Value())
+		writeGauge(w, "debswarm_peers_circuit_open", m.PeersCircuitOpen.Value())
 		writeGauge(w, "debswarm_routing_table_size", m.RoutingTableSize.Value())
 		writeGauge(w, "debswarm_cache_size_bytes", m.CacheSize.Value())
 		writeGauge(w, "debswarm_cache_max_size_bytes", m.CacheMaxSize.Value())
 		writeGauge(w, "debswarm_cache_count", m.CacheCount.Value())
 		writeGauge(w, "debswarm_metadata_cache_size_bytes", m.MetadataCacheSize.Value())
+		writeGauge(w, "debswarm_memory_tier_bytes", m.MemoryTierSize.Value())
+		writeGauge(w, "debswarm_cache_degraded", m.CacheDegraded.Value())
 		writeGauge(w, "debswarm_active_downloads", m.ActiveDownloads.Value())
 		writeGauge(w, "debswarm_active_uploads", m.ActiveUploads.Value())
 
@@ -543,6 +988,7 @@ func (m *Metrics) Handler() http.Handler {
 
 		// Histograms
 		writeHistogram(w, "debswarm_peer_latency_milliseconds", m.PeerLatency)
+		m.Peers.write(w)
 		writeHistogram(w, "debswarm_chunk_download_seconds", m.ChunkDownloadTime)
 		writeHistogram(w, "debswarm_dht_lookup_seconds", m.DHTLookupDuration)
 
@@ -575,6 +1021,9 @@ func (m *Metrics) Handler() http.Handler {
 		for label, value := range m.RelayedTransferTotal.Values() {
 			writeCounterWithLabel(w, "debswarm_relayed_transfer_total", "result", label, value)
 		}
+		for label, value := range m.TransferReceipts.Values() {
+			writeCounterWithLabel(w, "debswarm_transfer_receipts_total", "result", label, value)
+		}
 	})
 }
 
@@ -611,6 +1060,21 @@ func writeHistogram(w http.ResponseWriter, name string, h *Histogram) {
 	_, _ = w.Write([]byte(name + "_count " + itoa(count) + "\n"))
 }
 
+func writeHistogramWithLabel(w http.ResponseWriter, name, labelName, labelValue string, h *Histogram) {
+	count, sum, buckets := h.Stats()
+	label := labelName + "=\"" + labelValue + "\""
+
+	cumulative := int64(0)
+	for i, b := range h.buckets {
+		cumulative += buckets[i]
+		_, _ = w.Write([]byte(name + "_bucket{" + label + ",le=\"" + ftoa(b) + "\"} " + itoa(cumulative) + "\n"))
+	}
+	cumulative += buckets[len(buckets)-1]
+	_, _ = w.Write([]byte(name + "_bucket{" + label + ",le=\"+Inf\"} " + itoa(cumulative) + "\n"))
+	_, _ = w.Write([]byte(name + "_sum{" + label + "} " + ftoa(sum) + "\n"))
+	_, _ = w.Write([]byte(name + "_count{" + label + "} " + itoa(count) + "\n"))
+}
+
 func itoa(i int64) string {
 	if i == 0 {
 		return "0"
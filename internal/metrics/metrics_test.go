@@ -281,6 +281,10 @@ func TestMetrics_Handler(t *testing.T) {
 	m.DownloadsTotal.WithLabel("p2p").Add(50)
 	m.BytesDownloaded.WithLabel("mirror").Add(1000000)
 	m.DHTLookupDuration.Observe(0.5)
+	m.AnnounceQueueDepth.Set(3)
+	m.CoalescedRequests.WithLabel("package").Inc()
+	m.CacheEvictionsByReason.WithLabel("disk_pressure").Inc()
+	m.DHTBudgetQueued.WithLabel("provide").Set(2)
 
 	// Create request and response recorder
 	req := httptest.NewRequest("GET", "/metrics", nil)
@@ -311,6 +315,10 @@ func TestMetrics_Handler(t *testing.T) {
 		"debswarm_downloads_total{source=\"p2p\"}",
 		"debswarm_bytes_downloaded_total{source=\"mirror\"}",
 		"debswarm_dht_lookup_seconds",
+		"debswarm_announce_queue_depth 3",
+		"debswarm_coalesced_requests_total{kind=\"package\"} 1",
+		"debswarm_cache_evictions_by_reason_total{reason=\"disk_pressure\"} 1",
+		"debswarm_dht_budget_queued{operation=\"provide\"} 2",
 	}
 
 	for _, check := range checks {
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"cmp"
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Per-peer label modes. Labeling series by peer ID directly gives a
+// public-DHT node a new series for every peer it ever talks to, so per-peer
+// metrics always map peers onto a bounded set of label values.
+const (
+	PeerLabelsOff  = "off"  // no per-peer series
+	PeerLabelsTop  = "top"  // the busiest peers by ID, the rest as "other"
+	PeerLabelsHash = "hash" // peers hashed into a fixed number of buckets
+)
+
+// PeerLabelOther is the label value the peers outside the top share.
+const PeerLabelOther = "other"
+
+// Default per-peer label settings.
+const (
+	DefaultTopPeers    = 10
+	DefaultPeerBuckets = 16
+)
+
+// peerRerankInterval is how often the top peers are chosen again. Between
+// reranks a peer keeps its label, so its series stays continuous.
+const peerRerankInterval = time.Minute
+
+// PeerLabelConfig bounds the label values of the per-peer series.
+type PeerLabelConfig struct {
+	Mode    string // PeerLabelsOff, PeerLabelsTop or PeerLabelsHash
+	TopN    int    // peers with their own label in top mode (default 10)
+	Buckets int    // label values in hash mode (default 16)
+}
+
+// PeerMetrics holds the per-peer transfer series. Each label value stands
+// for one peer in top mode, or for every peer hashing to the same bucket in
+// hash mode.
+//
+// In top mode the peers that moved the most bytes in the last interval get
+// their own label; bytes to and from the others are counted under "other".
+// A peer that drops out of the top loses its series, and one that rejoins
+// later starts a new one from zero, which Prometheus treats as a counter
+// reset. "other" only ever grows.
+type PeerMetrics struct {
+	mu     sync.Mutex
+	cfg    PeerLabelConfig
+	top    map[string]bool  // peers with their own label
+	recent map[string]int64 // bytes per peer since the last rerank
+	ranked time.Time
+	now    func() time.Time
+
+	uploaded   *CounterVec
+	downloaded *CounterVec
+	latency    *HistogramVec
+}
+
+func newPeerMetrics() *PeerMetrics {
+	return &PeerMetrics{
+		cfg:        PeerLabelConfig{Mode: PeerLabelsOff},
+		top:        make(map[string]bool),
+		recent:     make(map[string]int64),
+		now:        time.Now,
+		uploaded:   NewCounterVec(),
+		downloaded: NewCounterVec(),
+		latency:    NewHistogramVec(LatencyBuckets),
+	}
+}
+
+// Configure sets how peers are labeled, dropping the series recorded so far
+// when the mode changes.
+func (p *PeerMetrics) Configure(cfg PeerLabelConfig) {
+	if cfg.Mode == "" {
+		cfg.Mode = PeerLabelsOff
+	}
+	if cfg.TopN <= 0 {
+		cfg.TopN = DefaultTopPeers
+	}
+	if cfg.Buckets <= 0 {
+		cfg.Buckets = DefaultPeerBuckets
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cfg == p.cfg {
+		return
+	}
+	p.cfg = cfg
+	p.top = make(map[string]bool)
+	p.recent = make(map[string]int64)
+	p.ranked = p.now()
+	p.uploaded = NewCounterVec()
+	p.downloaded = NewCounterVec()
+	p.latency = NewHistogramVec(LatencyBuckets)
+}
+
+// Upload counts bytes sent to a peer.
+func (p *PeerMetrics) Upload(peerID string, bytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if label, ok := p.label(peerID, bytes); ok {
+		p.uploaded.WithLabel(label).Add(bytes)
+	}
+}
+
+// Download counts bytes received from a peer and the time the transfer
+// took.
+func (p *PeerMetrics) Download(peerID string, bytes int64, latencyMs float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if label, ok := p.label(peerID, bytes); ok {
+		p.downloaded.WithLabel(label).Add(bytes)
+		p.latency.WithLabel(label).Observe(latencyMs)
+	}
+}
+
+// label returns the label value for peerID, noting its activity for the
+// next rerank. Callers hold p.mu.
+func (p *PeerMetrics) label(peerID string, bytes int64) (string, bool) {
+	switch p.cfg.Mode {
+	case PeerLabelsHash:
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(peerID))
+		return "bucket-" + strconv.Itoa(int(h.Sum32()%uint32(p.cfg.Buckets))), true
+	case PeerLabelsTop:
+		p.recent[peerID] += bytes
+		if p.now().Sub(p.ranked) >= peerRerankInterval {
+			p.rerank()
+		}
+		// Until the first rerank, the first peers seen take the free labels
+		if !p.top[peerID] && len(p.top) < p.cfg.TopN {
+			p.top[peerID] = true
+		}
+		if p.top[peerID] {
+			return peerID, true
+		}
+		return PeerLabelOther, true
+	default:
+		return "", false
+	}
+}
+
+// rerank gives the peers that moved the most bytes since the last rerank
+// their own labels. Peers that were on top keep theirs while there is room,
+// so a quiet interval does not drop every series. Callers hold p.mu.
+func (p *PeerMetrics) rerank() {
+	active := make([]string, 0, len(p.recent))
+	for id := range p.recent {
+		active = append(active, id)
+	}
+	slices.SortFunc(active, func(a, b string) int {
+		return cmp.Or(cmp.Compare(p.recent[b], p.recent[a]), cmp.Compare(a, b))
+	})
+
+	top := make(map[string]bool, p.cfg.TopN)
+	for _, id := range active[:min(len(active), p.cfg.TopN)] {
+		top[id] = true
+	}
+	for id := range p.top {
+		if len(top) >= p.cfg.TopN {
+			break
+		}
+		top[id] = true
+	}
+	for id := range p.top {
+		if !top[id] {
+			p.uploaded.Delete(id)
+			p.downloaded.Delete(id)
+			p.latency.Delete(id)
+		}
+	}
+	p.top = top
+	p.recent = make(map[string]int64)
+	p.ranked = p.now()
+}
+
+// write exports the per-peer series.
+func (p *PeerMetrics) write(w http.ResponseWriter) {
+	p.mu.Lock()
+	if p.cfg.Mode == PeerLabelsTop && p.now().Sub(p.ranked) >= peerRerankInterval {
+		p.rerank()
+	}
+	uploaded, downloaded, latency := p.uploaded.Values(), p.downloaded.Values(), p.latency
+	p.mu.Unlock()
+
+	for label, value := range uploaded {
+		writeCounterWithLabel(w, "debswarm_peer_bytes_uploaded_total", "peer", label, value)
+	}
+	for label, value := range downloaded {
+		writeCounterWithLabel(w, "debswarm_peer_bytes_downloaded_total", "peer", label, value)
+	}
+	for label, h := range latency.snapshot() {
+		writeHistogramWithLabel(w, "debswarm_peer_transfer_latency_milliseconds", "peer", label, h)
+	}
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(m *Metrics) string {
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	return w.Body.String()
+}
+
+func TestPeerMetricsOff(t *testing.T) {
+	m := New()
+	m.Peers.Upload("peer-a", 100)
+	m.Peers.Download("peer-a", 100, 5)
+	if body := scrape(m); strings.Contains(body, "debswarm_peer_bytes") {
+		t.Error("per-peer series exported while off")
+	}
+}
+
+func TestPeerMetricsTop(t *testing.T) {
+	m := New()
+	now := time.Now()
+	m.Peers.now = func() time.Time { return now }
+	m.Peers.Configure(PeerLabelConfig{Mode: PeerLabelsTop, TopN: 2})
+
+	m.Peers.Upload("peer-a", 10)
+	m.Peers.Upload("peer-b", 20)
+	m.Peers.Upload("peer-c", 30) // no free label left
+	m.Peers.Download("peer-a", 40, 12)
+	body := scrape(m)
+	for _, want := range []string{
+		`debswarm_peer_bytes_uploaded_total{peer="peer-a"} 10`,
+		`debswarm_peer_bytes_uploaded_total{peer="peer-b"} 20`,
+		`debswarm_peer_bytes_uploaded_total{peer="other"} 30`,
+		`debswarm_peer_bytes_downloaded_total{peer="peer-a"} 40`,
+		`debswarm_peer_transfer_latency_milliseconds_bucket{peer="peer-a",le="25"} 1`,
+		`debswarm_peer_transfer_latency_milliseconds_count{peer="peer-a"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+
+	// The busiest peers of the last interval (c with 1030 bytes, a with 51)
+	// take the labels; b drops out and loses its series, and "other" only
+	// grows
+	m.Peers.Upload("peer-c", 1000)
+	m.Peers.Upload("peer-b", 5)
+	now = now.Add(peerRerankInterval)
+	m.Peers.Upload("peer-a", 1)
+	m.Peers.Upload("peer-c", 7)
+	body = scrape(m)
+	for _, want := range []string{
+		`debswarm_peer_bytes_uploaded_total{peer="peer-c"} 7`,
+		`debswarm_peer_bytes_uploaded_total{peer="peer-a"} 11`,
+		`debswarm_peer_bytes_uploaded_total{peer="other"} 1030`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Contains(body, `peer="peer-b"`) {
+		t.Error("peer that left the top still has series")
+	}
+}
+
+func TestPeerMetricsHash(t *testing.T) {
+	m := New()
+	m.Peers.Configure(PeerLabelConfig{Mode: PeerLabelsHash, Buckets: 4})
+	for i := range 100 {
+		m.Peers.Upload(fmt.Sprintf("peer-%d", i), 1)
+	}
+	values := m.Peers.uploaded.Values()
+	if len(values) > 4 {
+		t.Errorf("%d labels, want at most 4", len(values))
+	}
+	var total int64
+	for label, v := range values {
+		if !strings.HasPrefix(label, "bucket-") {
+			t.Errorf("label %q", label)
+		}
+		total += v
+	}
+	if total != 100 {
+		t.Errorf("total = %d, want 100", total)
+	}
+}
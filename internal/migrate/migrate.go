@@ -0,0 +1,244 @@
+// Package migrate versions the SQLite schema of debswarm's state database.
+//
+// Each subsystem that keeps tables in the database owns a Schema: a name
+// and an ordered list of migrations. The version each schema has reached is
+// recorded in the schema_version table, so a migration runs exactly once,
+// in its own transaction, and a failed one leaves the database at the
+// version before it. Before any migration runs on a database that already
+// holds data, a copy of it is written next to it.
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// keepBackups is how many pre-migration copies of a database are kept.
+const keepBackups = 3
+
+// backupSuffix marks pre-migration copies: <db>.pre-migrate.<unix time>.
+const backupSuffix = ".pre-migrate."
+
+// Migration is one step of a schema. Up runs in a transaction.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *sql.Tx) error
+}
+
+// Schema is the set of tables one subsystem keeps. Its migrations must be
+// numbered from 1 without gaps; released migrations must never change, and
+// a schema change is always a new migration at the end.
+type Schema struct {
+	Name       string
+	Migrations []Migration
+}
+
+// Latest returns the version the schema's last migration brings it to.
+func (s *Schema) Latest() int {
+	return len(s.Migrations)
+}
+
+func (s *Schema) validate() error {
+	if s.Name == "" {
+		return errors.New("schema has no name")
+	}
+	for i, m := range s.Migrations {
+		if m.Version != i+1 {
+			return fmt.Errorf("schema %s: migration %d has version %d, want %d", s.Name, i, m.Version, i+1)
+		}
+		if m.Up == nil {
+			return fmt.Errorf("schema %s: migration %d has no Up", s.Name, m.Version)
+		}
+	}
+	return nil
+}
+
+// Exec returns an Up function running the given statements.
+func Exec(statements string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(statements)
+		return err
+	}
+}
+
+// AddColumn adds a column to a table unless it is already there: databases
+// created before the schema was versioned may have any subset of the
+// columns added ad hoc over time.
+func AddColumn(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%q)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if strings.EqualFold(name, column) {
+			return rows.Close()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_ = rows.Close()
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %q ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// Apply brings each schema in db to its latest version. A schema recorded
+// at a version newer than this build knows, from a newer debswarm, is left
+// as it is with a warning: migrations only add to a schema, so older code
+// keeps working on it.
+func Apply(db *sql.DB, logger *zap.Logger, schemas ...Schema) error {
+	for i := range schemas {
+		if err := schemas[i].validate(); err != nil {
+			return err
+		}
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		name TEXT PRIMARY KEY,
+		version INTEGER NOT NULL,
+		applied_at INTEGER NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_version table: %w", err)
+	}
+
+	versions := make([]int, len(schemas))
+	pending := false
+	for i := range schemas {
+		s := &schemas[i]
+		v, err := Version(db, s.Name)
+		if err != nil {
+			return err
+		}
+		versions[i] = v
+		switch {
+		case v > s.Latest():
+			logger.Warn("Database schema is newer than this version of debswarm",
+				zap.String("schema", s.Name), zap.Int("version", v), zap.Int("supported", s.Latest()))
+		case v < s.Latest():
+			pending = true
+		}
+	}
+	if !pending {
+		return nil
+	}
+
+	if err := backup(db, logger); err != nil {
+		return fmt.Errorf("back up database before migrating: %w", err)
+	}
+	for i := range schemas {
+		s := &schemas[i]
+		for _, m := range s.Migrations[min(versions[i], s.Latest()):] {
+			if err := run(db, s.Name, m); err != nil {
+				return fmt.Errorf("migrate %s to version %d (%s): %w", s.Name, m.Version, m.Description, err)
+			}
+			logger.Info("Migrated database schema",
+				zap.String("schema", s.Name), zap.Int("version", m.Version), zap.String("migration", m.Description))
+		}
+	}
+	return nil
+}
+
+// Version returns the version a schema is recorded at, 0 if none.
+func Version(db *sql.DB, name string) (int, error) {
+	var v int
+	err := db.QueryRow(`SELECT version FROM schema_version WHERE name = ?`, name).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read schema version of %s: %w", name, err)
+	}
+	return v, nil
+}
+
+func run(db *sql.DB, name string, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := m.Up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO schema_version (name, version, applied_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET version = excluded.version, applied_at = excluded.applied_at`,
+		name, m.Version, time.Now().Unix()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// backup copies a database that holds any tables besides schema_version,
+// and removes all but the newest keepBackups copies. A new or in-memory
+// database is not copied.
+func backup(db *sql.DB, logger *zap.Logger) error {
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name NOT IN ('schema_version') AND name NOT LIKE 'sqlite_%'`).Scan(&tables); err != nil {
+		return err
+	}
+	path, err := mainFile(db)
+	if err != nil || path == "" || tables == 0 {
+		return err
+	}
+
+	dst := fmt.Sprintf("%s%s%d", path, backupSuffix, time.Now().Unix())
+	// VACUUM INTO refuses to overwrite; two migrations in one second
+	// share the first copy
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	if _, err := db.Exec(`VACUUM INTO ?`, dst); err != nil {
+		return err
+	}
+	logger.Info("Backed up database before migrating its schema", zap.String("backup", dst))
+
+	old, _ := filepath.Glob(path + backupSuffix + "*")
+	slices.Sort(old) // the same number of digits until 2286
+	for _, f := range old[:max(len(old)-keepBackups, 0)] {
+		if err := os.Remove(f); err != nil {
+			logger.Debug("Failed to remove old database backup", zap.String("file", f), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// mainFile returns the file of db's main database, "" if it is in memory.
+func mainFile(db *sql.DB) (string, error) {
+	rows, err := db.Query(`PRAGMA database_list`)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			seq        int
+			name, file string
+		)
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return "", err
+		}
+		if name == "main" {
+			return file, nil
+		}
+	}
+	return "", rows.Err()
+}
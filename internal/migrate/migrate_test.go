@@ -0,0 +1,203 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "state.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, path
+}
+
+func backups(t *testing.T, path string) []string {
+	t.Helper()
+	files, err := filepath.Glob(path + backupSuffix + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+var widgets = Schema{Name: "widgets", Migrations: []Migration{
+	{Version: 1, Description: "create widgets", Up: Exec(`CREATE TABLE widgets (id INTEGER PRIMARY KEY)`)},
+}}
+
+func withMigration(s Schema, m Migration) Schema {
+	s.Migrations = append(append([]Migration(nil), s.Migrations...), m)
+	return s
+}
+
+func TestApply(t *testing.T) {
+	db, path := openTestDB(t)
+	logger := zap.NewNop()
+
+	// A new database is created without a backup
+	if err := Apply(db, logger, widgets); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if v, err := Version(db, "widgets"); err != nil || v != 1 {
+		t.Fatalf("Version = %d, %v; want 1", v, err)
+	}
+	if b := backups(t, path); len(b) != 0 {
+		t.Errorf("new database backed up: %v", b)
+	}
+	if _, err := db.Exec(`INSERT INTO widgets (id) VALUES (1)`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Up to date: nothing runs
+	if err := Apply(db, logger, widgets); err != nil {
+		t.Fatalf("Apply when current: %v", err)
+	}
+
+	// A new migration runs once, after a backup of the data
+	v2 := withMigration(widgets, Migration{Version: 2, Description: "add name", Up: func(tx *sql.Tx) error {
+		return AddColumn(tx, "widgets", "name", "TEXT DEFAULT ''")
+	}})
+	if err := Apply(db, logger, v2); err != nil {
+		t.Fatalf("Apply v2: %v", err)
+	}
+	if err := Apply(db, logger, v2); err != nil {
+		t.Fatalf("Apply v2 again: %v", err)
+	}
+	if v, _ := Version(db, "widgets"); v != 2 {
+		t.Errorf("Version = %d, want 2", v)
+	}
+	if _, err := db.Exec(`UPDATE widgets SET name = 'a'`); err != nil {
+		t.Errorf("column not added: %v", err)
+	}
+	b := backups(t, path)
+	if len(b) != 1 {
+		t.Fatalf("backups = %v, want 1", b)
+	}
+	backup, err := sql.Open("sqlite", b[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+	var n int
+	if err := backup.QueryRow(`SELECT COUNT(*) FROM widgets`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("backup holds %d widgets, %v", n, err)
+	}
+	if v, _ := Version(backup, "widgets"); v != 1 {
+		t.Errorf("backup at version %d, want 1", v)
+	}
+
+	// A failed migration is rolled back and leaves the version alone
+	v3 := withMigration(v2, Migration{Version: 3, Description: "broken", Up: func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM widgets`); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}})
+	if err := Apply(db, logger, v3); err == nil || !strings.Contains(err.Error(), "version 3 (broken)") {
+		t.Errorf("Apply broken = %v", err)
+	}
+	if v, _ := Version(db, "widgets"); v != 2 {
+		t.Errorf("Version after failure = %d, want 2", v)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM widgets`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("failed migration not rolled back: %d widgets, %v", n, err)
+	}
+
+	// A database from a newer build is used as it is
+	if err := Apply(db, logger, widgets); err != nil {
+		t.Errorf("Apply older schema: %v", err)
+	}
+	if v, _ := Version(db, "widgets"); v != 2 {
+		t.Errorf("Version after older Apply = %d, want 2", v)
+	}
+}
+
+func TestApplySchemasIndependently(t *testing.T) {
+	db, _ := openTestDB(t)
+	gadgets := Schema{Name: "gadgets", Migrations: []Migration{
+		{Version: 1, Description: "create gadgets", Up: Exec(`CREATE TABLE gadgets (id INTEGER PRIMARY KEY)`)},
+		{Version: 2, Description: "index gadgets", Up: Exec(`CREATE INDEX idx_gadgets ON gadgets(id)`)},
+	}}
+	if err := Apply(db, zap.NewNop(), widgets); err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(db, zap.NewNop(), gadgets); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := Version(db, "widgets"); v != 1 {
+		t.Errorf("widgets at %d, want 1", v)
+	}
+	if v, _ := Version(db, "gadgets"); v != 2 {
+		t.Errorf("gadgets at %d, want 2", v)
+	}
+}
+
+func TestApplyInvalidSchema(t *testing.T) {
+	db, _ := openTestDB(t)
+	for _, s := range []Schema{
+		{Migrations: widgets.Migrations},
+		{Name: "gap", Migrations: []Migration{{Version: 2, Up: Exec(`SELECT 1`)}}},
+		{Name: "nil", Migrations: []Migration{{Version: 1}}},
+	} {
+		if err := Apply(db, zap.NewNop(), s); err == nil {
+			t.Errorf("Apply(%+v) succeeded", s)
+		}
+	}
+}
+
+func TestBackupPruning(t *testing.T) {
+	db, path := openTestDB(t)
+	if err := Apply(db, zap.NewNop(), widgets); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 5 {
+		if err := os.WriteFile(fmt.Sprintf("%s%s%d", path, backupSuffix, 1000000000+i), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Apply(db, zap.NewNop(), withMigration(widgets, Migration{Version: 2, Description: "noop", Up: Exec(`SELECT 1`)})); err != nil {
+		t.Fatal(err)
+	}
+	b := backups(t, path)
+	if len(b) != keepBackups {
+		t.Fatalf("backups = %v, want %d", b, keepBackups)
+	}
+	// The two newest old copies and the new one
+	if !strings.HasSuffix(b[0], "1000000003") {
+		t.Errorf("oldest kept backup = %s", b[0])
+	}
+}
+
+func TestAddColumn(t *testing.T) {
+	db, _ := openTestDB(t)
+	if _, err := db.Exec(`CREATE TABLE t (a INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := AddColumn(tx, "t", "b", "TEXT DEFAULT ''"); err != nil {
+			t.Fatalf("AddColumn: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO t (a, b) VALUES (1, 'x')`); err != nil {
+		t.Error(err)
+	}
+}
@@ -3,15 +3,20 @@ package mirror
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/debswarm/debswarm/internal/httpclient"
+	"github.com/debswarm/debswarm/internal/ratelimit"
+	"github.com/debswarm/debswarm/internal/requestid"
 	"github.com/debswarm/debswarm/internal/retry"
 	"github.com/debswarm/debswarm/internal/security"
 )
@@ -36,8 +41,46 @@ type Fetcher struct {
 	maxRetries      int
 	maxResponseSize int64
 	stallWindow     time.Duration
+
+	// Fastest transfer of at least LinkSampleSize seen, in bytes/sec: the
+	// measured link capacity that "%link" rate limits are relative to
+	peakBps float64
+
+	// throttle, if set, wraps response bodies, as for the scheduler's rate
+	throttle func(ctx context.Context, r io.Reader) io.Reader
+
+	// hostLimits rate-limit downloads from particular hosts
+	hostLimits []hostLimit
+
+	// hostHeaders are added to the requests for particular hosts
+	hostHeaders []hostHeader
+
+	// policy decides which addresses redirects may lead to
+	policy atomic.Pointer[security.MirrorPolicy]
+
+	// offline refuses every request (connectivity_mode = "offline")
+	offline atomic.Bool
+}
+
+// ErrOffline is returned for every request while the fetcher is offline.
+var ErrOffline = errors.New("mirror fetches are disabled (offline mode)")
+
+// hostLimit is a rate limit shared by all downloads from a set of hosts
+type hostLimit struct {
+	hosts   []string
+	limiter *ratelimit.Limiter
 }
 
+// hostHeader is a set of headers added to the requests for a set of hosts
+type hostHeader struct {
+	hosts  []string
+	header http.Header
+}
+
+// LinkSampleSize is the smallest transfer that counts toward the measured
+// link throughput; smaller ones are dominated by latency.
+const LinkSampleSize = 1024 * 1024
+
 // Config holds mirror fetcher configuration
 type Config struct {
 	// Timeout bounds LACK OF PROGRESS, not the whole transfer: it is the
@@ -73,13 +116,6 @@ func NewFetcher(cfg *Config, logger *zap.Logger) *Fetcher {
 		cfg = DefaultConfig()
 	}
 
-	client := httpclient.New(&httpclient.Config{
-		Timeout:               -1, // no whole-request deadline; stalls are bounded per-read below
-		ResponseHeaderTimeout: cfg.Timeout,
-		MaxIdleConnsPerHost:   cfg.MaxIdleConn,
-		CheckRedirect:         checkRedirectSafety,
-	})
-
 	maxResponseSize := cfg.MaxResponseSize
 	if maxResponseSize <= 0 {
 		maxResponseSize = DefaultMaxResponseSize
@@ -90,8 +126,7 @@ func NewFetcher(cfg *Config, logger *zap.Logger) *Fetcher {
 		stallWindow = 60 * time.Second
 	}
 
-	return &Fetcher{
-		client:          client,
+	f := &Fetcher{
 		stats:           make(map[string]*Stats),
 		logger:          logger,
 		userAgent:       cfg.UserAgent,
@@ -99,6 +134,119 @@ func NewFetcher(cfg *Config, logger *zap.Logger) *Fetcher {
 		maxResponseSize: maxResponseSize,
 		stallWindow:     stallWindow,
 	}
+	f.policy.Store(security.DefaultMirrorPolicy)
+	f.client = httpclient.New(&httpclient.Config{
+		Timeout:               -1, // no whole-request deadline; stalls are bounded per-read below
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConn,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if err := checkRedirectSafety(f.policy.Load(), req, via); err != nil {
+				return err
+			}
+			f.redirectHostHeaders(req, via[0])
+			return nil
+		},
+	})
+	return f
+}
+
+// SetMirrorPolicy replaces the policy redirects are checked against, so an
+// internal range the proxy may reach can also be redirected to. Safe to call
+// while the fetcher is in use.
+func (f *Fetcher) SetMirrorPolicy(policy *security.MirrorPolicy) {
+	f.policy.Store(policy)
+}
+
+// SetOffline disables (or re-enables) every mirror request: while offline,
+// requests fail with ErrOffline without touching the network.
+func (f *Fetcher) SetOffline(offline bool) {
+	f.offline.Store(offline)
+}
+
+// Offline reports whether mirror requests are disabled.
+func (f *Fetcher) Offline() bool {
+	return f.offline.Load()
+}
+
+// SetThrottle makes every response body read through fn, which may limit
+// its rate. Call before the fetcher is used.
+func (f *Fetcher) SetThrottle(fn func(ctx context.Context, r io.Reader) io.Reader) {
+	f.throttle = fn
+}
+
+// SetHostRate limits downloads from hosts and their subdomains to
+// bytesPerSecond in total. Call before the fetcher is used.
+func (f *Fetcher) SetHostRate(hosts []string, bytesPerSecond int64) {
+	if bytesPerSecond <= 0 || len(hosts) == 0 {
+		return
+	}
+	f.hostLimits = append(f.hostLimits, hostLimit{hosts: hosts, limiter: ratelimit.New(bytesPerSecond)})
+}
+
+// hostLimiter returns the rate limiter for downloads from host, or nil.
+func (f *Fetcher) hostLimiter(host string) *ratelimit.Limiter {
+	for _, hl := range f.hostLimits {
+		if matchHost(host, hl.hosts) {
+			return hl.limiter
+		}
+	}
+	return nil
+}
+
+// SetHostHeader adds header to every request for hosts and their
+// subdomains, replacing any header of the same name, as for the credentials
+// a mirror frontend requires. The headers are removed when a redirect
+// leaves the hosts. Call before the fetcher is used.
+func (f *Fetcher) SetHostHeader(hosts []string, header http.Header) {
+	if len(header) == 0 || len(hosts) == 0 {
+		return
+	}
+	f.hostHeaders = append(f.hostHeaders, hostHeader{hosts: hosts, header: header})
+}
+
+// hostHeader returns the headers configured for host, or nil.
+func (f *Fetcher) hostHeader(host string) http.Header {
+	for _, hh := range f.hostHeaders {
+		if matchHost(host, hh.hosts) {
+			return hh.header
+		}
+	}
+	return nil
+}
+
+// addHostHeader adds the headers configured for the request's host.
+func (f *Fetcher) addHostHeader(req *http.Request) {
+	for name, values := range f.hostHeader(req.URL.Hostname()) {
+		req.Header[name] = values
+	}
+}
+
+// redirectHostHeaders swaps the headers configured for the host of the
+// original request for those of the host a redirect leads to. Go's HTTP
+// client copies the original request's headers to each hop, and would
+// otherwise send one host's credentials to another.
+func (f *Fetcher) redirectHostHeaders(req, orig *http.Request) {
+	for name := range f.hostHeader(orig.URL.Hostname()) {
+		req.Header.Del(name)
+	}
+	f.addHostHeader(req)
+}
+
+// matchHost reports whether host is one of hosts or a subdomain of one.
+func matchHost(host string, hosts []string) bool {
+	host = strings.ToLower(host)
+	for _, h := range hosts {
+		if h = strings.ToLower(h); host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// throttledBody is a response body read through a throttle
+type throttledBody struct {
+	io.Reader
+	io.Closer
 }
 
 // stallReader aborts a transfer that stops making progress: every successful
@@ -143,29 +291,110 @@ func (sr *stallReader) Close() error {
 // by callers that retry) instead of hanging or — with the old whole-request
 // timeout — killing healthy long transfers.
 func (f *Fetcher) doStallGuarded(req *http.Request) (*http.Response, error) {
+	if f.offline.Load() {
+		return nil, retry.NonRetryable(ErrOffline)
+	}
+	for name, values := range headerFrom(req.Context()) {
+		req.Header[name] = values
+	}
+	f.addHostHeader(req)
+	trace, traced := f.setTrace(req)
 	guardCtx, cancel := context.WithCancel(req.Context())
+	start := time.Now()
 	resp, err := f.client.Do(req.WithContext(guardCtx))
+	if traced {
+		f.logTraced(req, trace, resp, err, time.Since(start))
+	}
 	if err != nil {
 		cancel()
 		return nil, err
 	}
 	resp.Body = newStallReader(resp.Body, f.stallWindow, cancel)
+	if f.throttle != nil {
+		resp.Body = throttledBody{f.throttle(req.Context(), resp.Body), resp.Body}
+	}
+	if limiter := f.hostLimiter(req.URL.Hostname()); limiter != nil {
+		resp.Body = throttledBody{limiter.ReaderContext(req.Context(), resp.Body), resp.Body}
+	}
+	return resp, nil
+}
+
+// setTrace continues the trace of the request's context, if it has one,
+// with a span for the mirror request.
+func (f *Fetcher) setTrace(req *http.Request) (requestid.Trace, bool) {
+	trace, ok := requestid.TraceFromContext(req.Context())
+	if !ok {
+		return trace, false
+	}
+	trace = trace.Child()
+	trace.SetHeader(req.Header)
+	return trace, true
+}
+
+// logTraced logs a traced mirror request with its latency (to the response
+// headers), so the trace can be followed to the mirror.
+func (f *Fetcher) logTraced(req *http.Request, trace requestid.Trace, resp *http.Response, err error, latency time.Duration) {
+	fields := []zap.Field{
+		zap.String("traceID", trace.TraceID),
+		zap.String("spanID", trace.SpanID),
+		zap.String("method", req.Method),
+		zap.String("host", req.URL.Host),
+		zap.Duration("latency", latency),
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	} else {
+		fields = append(fields, zap.Int("status", resp.StatusCode))
+	}
+	f.logger.Debug("Mirror request", fields...)
+}
+
+type headerKey struct{}
+
+// WithHeader returns a context whose mirror requests carry header, as for
+// credentials a client sent with its request. Go's HTTP client drops
+// Authorization when a redirect leaves the host.
+func WithHeader(ctx context.Context, header http.Header) context.Context {
+	return context.WithValue(ctx, headerKey{}, header)
+}
+
+func headerFrom(ctx context.Context) http.Header {
+	h, _ := ctx.Value(headerKey{}).(http.Header)
+	return h
+}
+
+// Do issues a GET or HEAD request and returns the response whatever its
+// status, for callers that relay it to a client. The body is stall-guarded
+// like Stream's, and the caller must close it.
+func (f *Fetcher) Do(ctx context.Context, method, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+
+	resp, err := f.doStallGuarded(req)
+	if err != nil {
+		f.recordError(url)
+		return nil, err
+	}
 	return resp, nil
 }
 
 // checkRedirectSafety validates each redirect hop before it is followed.
 // The initial URL is validated against the mirror allowlist by the proxy, but
 // a malicious or compromised mirror could redirect to an internal address
-// (SSRF). Loopback, private, link-local and metadata targets are refused;
-// public cross-host redirects (e.g. PPA -> CDN) remain allowed.
-func checkRedirectSafety(req *http.Request, via []*http.Request) error {
+// (SSRF). Addresses the policy blocks (by default loopback, private,
+// link-local and metadata targets) are refused; public cross-host redirects
+// (e.g. PPA -> CDN) remain allowed.
+func checkRedirectSafety(policy *security.MirrorPolicy, req *http.Request, via []*http.Request) error {
 	if len(via) >= 10 {
 		return fmt.Errorf("stopped after 10 redirects")
 	}
 	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
 		return fmt.Errorf("redirect to disallowed scheme %q refused", req.URL.Scheme)
 	}
-	if security.IsBlockedHost(req.URL.String()) {
+	if policy.BlocksHost(req.URL.Hostname()) {
 		return fmt.Errorf("redirect to blocked host %q refused", req.URL.Hostname())
 	}
 	return nil
@@ -195,7 +424,7 @@ func (f *Fetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
 			if closeErr := resp.Body.Close(); closeErr != nil {
 				f.logger.Debug("Failed to close response body", zap.Error(closeErr))
 			}
-			httpErr := fmt.Errorf("http %d: %s", resp.StatusCode, resp.Status)
+			httpErr := &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 			f.recordError(url)
 			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
 				// Don't retry client errors
@@ -259,7 +488,7 @@ func (f *Fetcher) FetchToWriter(ctx context.Context, url string, w io.Writer) (i
 			f.logger.Debug("Failed to close response body", zap.Error(closeErr))
 		}
 		f.recordError(url)
-		return 0, fmt.Errorf("http %d: %s", resp.StatusCode, resp.Status)
+		return 0, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
 	// Limit response size to prevent disk exhaustion
@@ -302,10 +531,71 @@ func (f *Fetcher) Stream(ctx context.Context, url string) (io.ReadCloser, int64,
 			f.logger.Debug("Failed to close response body", zap.Error(closeErr))
 		}
 		f.recordError(url)
-		return nil, 0, fmt.Errorf("http %d: %s", resp.StatusCode, resp.Status)
+		return nil, 0, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
-	return resp.Body, resp.ContentLength, nil
+	return &measuredBody{ReadCloser: resp.Body, f: f, url: url, start: time.Now()}, resp.ContentLength, nil
+}
+
+// measuredBody records a streamed response in the mirror's statistics once
+// it has been read to the end.
+type measuredBody struct {
+	io.ReadCloser
+	f     *Fetcher
+	url   string
+	start time.Time
+	n     int64
+	done  bool
+}
+
+func (b *measuredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF && !b.done {
+		b.done = true
+		b.f.recordSuccess(b.url, b.n, time.Since(b.start))
+	}
+	return n, err
+}
+
+// StreamRange returns a reader for the content of url from byte start to the
+// end. It fails unless the mirror answers with the requested range, so the
+// caller can fall back to a full download.
+func (f *Fetcher) StreamRange(ctx context.Context, url string, start int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+
+	resp, err := f.doStallGuarded(req)
+	if err != nil {
+		f.recordError(url)
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusPartialContent ||
+		!strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", start)) {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			f.logger.Debug("Failed to close response body", zap.Error(closeErr))
+		}
+		if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+			f.recordError(url)
+		}
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return &measuredBody{ReadCloser: resp.Body, f: f, url: url, start: time.Now()}, nil
+}
+
+// StatusError reports that the mirror answered with an unexpected HTTP
+// status, so callers can tell a missing file from an unreachable mirror.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http %d: %s", e.StatusCode, e.Status)
 }
 
 // ConditionalResult carries the outcome of a conditional GET. On upstream 304
@@ -317,6 +607,11 @@ type ConditionalResult struct {
 	NotModified  bool
 	LastModified string
 	ETag         string
+	ContentType  string
+	// Freshness headers, for callers that decide how long to reuse the body
+	CacheControl string
+	Expires      string
+	Date         string
 }
 
 // StreamConditional performs a GET forwarding the given revalidation values
@@ -346,6 +641,10 @@ func (f *Fetcher) StreamConditional(ctx context.Context, url, ifModifiedSince, i
 		Size:         resp.ContentLength,
 		LastModified: resp.Header.Get("Last-Modified"),
 		ETag:         resp.Header.Get("ETag"),
+		ContentType:  resp.Header.Get("Content-Type"),
+		CacheControl: resp.Header.Get("Cache-Control"),
+		Expires:      resp.Header.Get("Expires"),
+		Date:         resp.Header.Get("Date"),
 	}
 
 	switch resp.StatusCode {
@@ -363,7 +662,7 @@ func (f *Fetcher) StreamConditional(ctx context.Context, url, ifModifiedSince, i
 			f.logger.Debug("Failed to close response body", zap.Error(closeErr))
 		}
 		f.recordError(url)
-		return nil, fmt.Errorf("http %d: %s", resp.StatusCode, resp.Status)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 }
 
@@ -380,8 +679,18 @@ func (f *Fetcher) Head(ctx context.Context, url string) (*http.Response, error)
 		return nil, err
 	}
 	req.Header.Set("User-Agent", f.userAgent)
+	f.addHostHeader(req)
 
-	return f.client.Do(req)
+	if f.offline.Load() {
+		return nil, ErrOffline
+	}
+	trace, traced := f.setTrace(req)
+	start := time.Now()
+	resp, err := f.client.Do(req)
+	if traced {
+		f.logTraced(req, trace, resp, err, time.Since(start))
+	}
+	return resp, err
 }
 
 // FetchRange downloads a specific byte range from a URL using HTTP Range headers.
@@ -424,7 +733,7 @@ func (f *Fetcher) FetchRange(ctx context.Context, url string, rangeStart, rangeE
 			if closeErr := resp.Body.Close(); closeErr != nil {
 				f.logger.Debug("Failed to close response body", zap.Error(closeErr))
 			}
-			httpErr := fmt.Errorf("http %d: %s", resp.StatusCode, resp.Status)
+			httpErr := &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 			f.recordError(url)
 			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
 				return nil, retry.NonRetryable(httpErr)
@@ -529,10 +838,25 @@ func (f *Fetcher) recordSuccess(url string, bytes int64, duration time.Duration)
 	if duration > 0 {
 		throughputBps := float64(bytes) / duration.Seconds()
 		stats.AvgThroughputBps = stats.AvgThroughputBps*(n-1)/n + throughputBps/n
+		if bytes >= LinkSampleSize && throughputBps > f.peakBps {
+			f.peakBps = throughputBps
+		}
 	}
 }
 
+// LinkThroughput returns the fastest mirror transfer of at least
+// LinkSampleSize seen so far in bytes/sec, or 0 before there has been one.
+func (f *Fetcher) LinkThroughput() int64 {
+	f.statsMu.RLock()
+	defer f.statsMu.RUnlock()
+	return int64(f.peakBps)
+}
+
 func (f *Fetcher) recordError(url string) {
+	if f.offline.Load() {
+		// The mirror was never asked
+		return
+	}
 	host := extractHost(url)
 
 	f.statsMu.Lock()
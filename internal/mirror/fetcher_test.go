@@ -3,6 +3,7 @@ package mirror
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -12,6 +13,9 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/requestid"
+	"github.com/debswarm/debswarm/internal/security"
 )
 
 func testLogger() *zap.Logger {
@@ -65,7 +69,7 @@ func TestCheckRedirectSafety(t *testing.T) {
 			for i := range via {
 				via[i] = makeReq("http://deb.debian.org/debian/dists/stable/Release")
 			}
-			err := checkRedirectSafety(makeReq(tc.url), via)
+			err := checkRedirectSafety(security.DefaultMirrorPolicy, makeReq(tc.url), via)
 			if (err != nil) != tc.wantErr {
 				t.Errorf("checkRedirectSafety(%q) error = %v, wantErr %v", tc.url, err, tc.wantErr)
 			}
@@ -145,6 +149,42 @@ func TestFetchSuccess(t *testing.T) {
 	}
 }
 
+func TestFetchThrottle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("package data"))
+	}))
+	defer server.Close()
+
+	type key struct{}
+	var throttled atomic.Int32
+	f := NewFetcher(nil, testLogger())
+	f.SetThrottle(func(ctx context.Context, r io.Reader) io.Reader {
+		if ctx.Value(key{}) == "scheduled" {
+			throttled.Add(1)
+		}
+		return r
+	})
+
+	ctx := context.WithValue(context.Background(), key{}, "scheduled")
+	data, err := f.Fetch(ctx, server.URL+"/test.deb")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if string(data) != "package data" {
+		t.Errorf("got %q", data)
+	}
+	rc, _, err := f.Stream(ctx, server.URL+"/test.deb")
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	_, _ = io.Copy(io.Discard, rc)
+	_ = rc.Close()
+
+	if got := throttled.Load(); got != 2 {
+		t.Errorf("throttle saw %d bodies with the request context, want 2", got)
+	}
+}
+
 func TestFetchUserAgent(t *testing.T) {
 	var receivedUA string
 
@@ -170,6 +210,110 @@ func TestFetchUserAgent(t *testing.T) {
 	}
 }
 
+func TestDoWithHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="https://auth.example/token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	f := NewFetcher(&Config{MaxRetries: 1}, testLogger())
+	resp, err := f.Do(context.Background(), http.MethodGet, server.URL)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Errorf("status %d, WWW-Authenticate %q; want the 401 returned as is", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+
+	ctx := WithHeader(context.Background(), http.Header{"Authorization": {"Bearer token"}})
+	body, _, err := f.Stream(ctx, server.URL)
+	if err != nil {
+		t.Fatalf("Stream with header failed: %v", err)
+	}
+	_ = body.Close()
+}
+
+func TestTracePropagation(t *testing.T) {
+	var got atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.Store(r.Header.Get("traceparent") + " " + r.Header.Get("tracestate"))
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	f := NewFetcher(&Config{MaxRetries: 1}, testLogger())
+	if _, err := f.Fetch(context.Background(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if got.Load() != " " {
+		t.Errorf("untraced request carried %q", got.Load())
+	}
+
+	trace, _ := requestid.ParseTrace("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "vendor=abc")
+	if _, err := f.Fetch(requestid.WithTrace(context.Background(), trace), server.URL); err != nil {
+		t.Fatal(err)
+	}
+	header := got.Load().(string)
+	if !strings.HasPrefix(header, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || strings.Contains(header, trace.SpanID) ||
+		!strings.HasSuffix(header, "-01 vendor=abc") {
+		t.Errorf("traced request carried %q, want the trace with a new span", header)
+	}
+}
+
+func TestHostHeader(t *testing.T) {
+	var cdnToken atomic.Value
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cdnToken.Store(r.Header.Get("X-Mirror-Token"))
+		_, _ = w.Write([]byte("from cdn"))
+	}))
+	defer cdn.Close()
+	cdnURL := strings.Replace(cdn.URL, "127.0.0.1", "localhost", 1)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Mirror-Token") != "s3cret" || r.Header.Get("X-Traffic-Class") != "apt" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, cdnURL+"/pool/pkg.deb", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("from origin"))
+	}))
+	defer origin.Close()
+
+	f := NewFetcher(&Config{MaxRetries: 1}, testLogger())
+	policy, err := security.NewMirrorPolicy(security.PolicyConfig{AllowPrivate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.SetMirrorPolicy(policy)
+	f.SetHostHeader([]string{"127.0.0.1"}, http.Header{
+		"X-Mirror-Token":  {"s3cret"},
+		"X-Traffic-Class": {"apt"},
+	})
+
+	data, err := f.Fetch(context.Background(), origin.URL+"/pool/pkg.deb")
+	if err != nil || string(data) != "from origin" {
+		t.Fatalf("Fetch = %q, %v; want the origin to accept its headers", data, err)
+	}
+
+	// The token stays with the origin's hosts
+	data, err = f.Fetch(context.Background(), origin.URL+"/redirect")
+	if err != nil || string(data) != "from cdn" {
+		t.Fatalf("Fetch through redirect = %q, %v", data, err)
+	}
+	if got := cdnToken.Load(); got != "" {
+		t.Errorf("redirect to another host carried X-Mirror-Token %q", got)
+	}
+}
+
 func TestFetch404(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -188,6 +332,42 @@ func TestFetch404(t *testing.T) {
 	}
 }
 
+func TestFetcherOffline(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	f := NewFetcher(&Config{MaxRetries: 3}, testLogger())
+	f.SetOffline(true)
+	ctx := context.Background()
+	if _, err := f.Fetch(ctx, server.URL); !errors.Is(err, ErrOffline) {
+		t.Errorf("Fetch = %v, want ErrOffline", err)
+	}
+	if _, _, err := f.Stream(ctx, server.URL); !errors.Is(err, ErrOffline) {
+		t.Errorf("Stream = %v, want ErrOffline", err)
+	}
+	if _, err := f.Head(ctx, server.URL); !errors.Is(err, ErrOffline) {
+		t.Errorf("Head = %v, want ErrOffline", err)
+	}
+	if _, err := f.FetchRange(ctx, server.URL, 0, 3); !errors.Is(err, ErrOffline) {
+		t.Errorf("FetchRange = %v, want ErrOffline", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("%d requests reached the mirror while offline", n)
+	}
+	if len(f.GetStats()) != 0 {
+		t.Error("refused requests counted as mirror errors")
+	}
+
+	f.SetOffline(false)
+	if _, err := f.Fetch(ctx, server.URL); err != nil {
+		t.Errorf("Fetch after going back online: %v", err)
+	}
+}
+
 func TestFetch500WithRetry(t *testing.T) {
 	var attempts int32
 
@@ -367,6 +547,66 @@ func TestStream404(t *testing.T) {
 	}
 }
 
+func TestStreamRange(t *testing.T) {
+	body := []byte("0123456789abcdef")
+	ranges := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ranges {
+			_, _ = w.Write(body)
+			return
+		}
+		http.ServeContent(w, r, "pkg.deb", time.Time{}, bytes.NewReader(body))
+	}))
+	defer server.Close()
+
+	f := NewFetcher(nil, testLogger())
+	rc, err := f.StreamRange(context.Background(), server.URL, 10)
+	if err != nil {
+		t.Fatalf("StreamRange failed: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if !bytes.Equal(data, body[10:]) {
+		t.Errorf("got %q, want %q", data, body[10:])
+	}
+
+	// A mirror ignoring Range must not be mistaken for the remainder
+	ranges = false
+	if _, err := f.StreamRange(context.Background(), server.URL, 10); err == nil {
+		t.Error("expected error when the mirror ignores Range")
+	}
+}
+
+func TestLinkThroughput(t *testing.T) {
+	big := make([]byte, LinkSampleSize)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/small" {
+			_, _ = w.Write([]byte("small"))
+			return
+		}
+		_, _ = w.Write(big)
+	}))
+	defer server.Close()
+
+	f := NewFetcher(nil, testLogger())
+	if _, err := f.Fetch(context.Background(), server.URL+"/small"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.LinkThroughput(); got != 0 {
+		t.Errorf("small transfer measured the link: %d", got)
+	}
+
+	rc, _, err := f.Stream(context.Background(), server.URL+"/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(io.Discard, rc)
+	_ = rc.Close()
+	if got := f.LinkThroughput(); got <= 0 {
+		t.Errorf("LinkThroughput = %d after a streamed transfer", got)
+	}
+}
+
 func TestHead(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodHead {
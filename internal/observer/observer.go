@@ -0,0 +1,305 @@
+// Package observer measures the health of a swarm without taking part in
+// it: a node that joins the DHT and mDNS, announces and serves nothing, and
+// periodically counts the providers of a sample of popular packages, the
+// peers it sees and the debswarm versions they run. Operators use it to
+// judge a swarm before deploying debswarm actively.
+package observer
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/debpkg"
+	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/prefetch"
+)
+
+// Defaults
+const (
+	DefaultMaxProviders  = 20
+	DefaultLookupTimeout = 30 * time.Second
+	lookupConcurrency    = 8
+)
+
+// Node is the part of a P2P node the observer reads
+type Node interface {
+	FindProviders(ctx context.Context, sha256Hash string, limit int) ([]peer.AddrInfo, error)
+	ConnectedPeers() int
+	RoutingTableSize() int
+	GetMDNSPeers() []peer.AddrInfo
+	PeerVersions() map[string]int
+}
+
+// Sample is a package whose providers are counted
+type Sample struct {
+	Package      string `json:"package"`
+	Version      string `json:"version"`
+	Architecture string `json:"architecture"`
+	SHA256       string `json:"sha256"`
+	// Dependents is the number of indexed packages depending on it
+	Dependents int `json:"dependents"`
+}
+
+// Config configures an Observer
+type Config struct {
+	Samples []Sample
+	// MaxProviders caps the providers counted per package; 0 = DefaultMaxProviders
+	MaxProviders int
+	// LookupTimeout bounds each provider lookup; 0 = DefaultLookupTimeout
+	LookupTimeout time.Duration
+}
+
+// PackageReport is the providers of one sampled package
+type PackageReport struct {
+	Sample
+	Providers int    `json:"providers"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report is the result of one observation round
+type Report struct {
+	Time           string         `json:"time"`
+	Round          int            `json:"round"`
+	Duration       string         `json:"duration"`
+	ConnectedPeers int            `json:"connected_peers"`
+	RoutingTable   int            `json:"routing_table"`
+	MDNSPeers      int            `json:"mdns_peers"`
+	Versions       map[string]int `json:"versions"`
+	// Sampled is the number of packages looked up, Available those with at
+	// least one provider
+	Sampled         int             `json:"sampled"`
+	Available       int             `json:"available"`
+	MedianProviders int             `json:"median_providers"`
+	MaxProviders    int             `json:"max_providers"`
+	Packages        []PackageReport `json:"packages"`
+}
+
+// Observer takes observation rounds and keeps the latest report
+type Observer struct {
+	node   Node
+	cfg    Config
+	logger *zap.Logger
+
+	mu     sync.RWMutex
+	rounds int
+	last   *Report
+}
+
+// New creates an observer of node
+func New(node Node, cfg Config, logger *zap.Logger) *Observer {
+	if cfg.MaxProviders <= 0 {
+		cfg.MaxProviders = DefaultMaxProviders
+	}
+	if cfg.LookupTimeout <= 0 {
+		cfg.LookupTimeout = DefaultLookupTimeout
+	}
+	return &Observer{node: node, cfg: cfg, logger: logger}
+}
+
+// Observe takes one round: it looks up the providers of every sample and
+// reads the node's view of the swarm. The report is kept for Last.
+func (o *Observer) Observe(ctx context.Context) *Report {
+	start := time.Now()
+	packages := make([]PackageReport, len(o.cfg.Samples))
+	sem := make(chan struct{}, lookupConcurrency)
+	var wg sync.WaitGroup
+	for i, s := range o.cfg.Samples {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			packages[i] = o.lookup(ctx, s)
+		}()
+	}
+	wg.Wait()
+
+	report := &Report{
+		Time:           start.UTC().Format(time.RFC3339),
+		Duration:       time.Since(start).Round(time.Millisecond).String(),
+		ConnectedPeers: o.node.ConnectedPeers(),
+		RoutingTable:   o.node.RoutingTableSize(),
+		MDNSPeers:      len(o.node.GetMDNSPeers()),
+		Versions:       o.node.PeerVersions(),
+		Sampled:        len(packages),
+		MaxProviders:   o.cfg.MaxProviders,
+		Packages:       packages,
+	}
+	counts := make([]int, 0, len(packages))
+	for _, p := range packages {
+		if p.Providers > 0 {
+			report.Available++
+		}
+		counts = append(counts, p.Providers)
+	}
+	if len(counts) > 0 {
+		slices.Sort(counts)
+		report.MedianProviders = counts[len(counts)/2]
+	}
+
+	o.mu.Lock()
+	o.rounds++
+	report.Round = o.rounds
+	o.last = report
+	o.mu.Unlock()
+
+	o.logger.Info("Observation round complete",
+		zap.Int("round", report.Round),
+		zap.Int("connectedPeers", report.ConnectedPeers),
+		zap.Int("available", report.Available),
+		zap.Int("sampled", report.Sampled))
+	return report
+}
+
+// lookup counts the providers of one sample
+func (o *Observer) lookup(ctx context.Context, s Sample) PackageReport {
+	ctx, cancel := context.WithTimeout(ctx, o.cfg.LookupTimeout)
+	defer cancel()
+	providers, err := o.node.FindProviders(ctx, s.SHA256, o.cfg.MaxProviders)
+	r := PackageReport{Sample: s, Providers: min(len(providers), o.cfg.MaxProviders)}
+	if err != nil && len(providers) == 0 {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// Run takes a round every interval until ctx is done, passing each report
+// to onReport if it is set
+func (o *Observer) Run(ctx context.Context, interval time.Duration, onReport func(*Report)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		report := o.Observe(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if onReport != nil {
+			onReport(report)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Last returns the latest report, or nil before the first round
+func (o *Observer) Last() *Report {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.last
+}
+
+// Handler serves the latest report as JSON at GET /report
+func (o *Observer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /report", func(w http.ResponseWriter, r *http.Request) {
+		report := o.Last()
+		if report == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "first observation round in progress"})
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v) //nolint:errcheck
+}
+
+// Popular picks the n packages most depended on by the other indexed
+// packages, as the ones a swarm is most likely to have. Each package name
+// and architecture is sampled once, at its newest indexed version.
+func Popular(pkgs []*index.PackageInfo, n int) []Sample {
+	latest := newest(pkgs)
+	dependents := make(map[string]int)
+	for _, pkg := range latest {
+		seen := make(map[string]bool)
+		for _, alternatives := range prefetch.ParseRelations(pkg.Depends) {
+			for _, name := range alternatives {
+				if !seen[name] && name != pkg.Package {
+					seen[name] = true
+					dependents[name]++
+				}
+			}
+		}
+	}
+
+	samples := make([]Sample, 0, len(latest))
+	for _, pkg := range latest {
+		samples = append(samples, sampleOf(pkg, dependents[pkg.Package]))
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		a, b := samples[i], samples[j]
+		if a.Dependents != b.Dependents {
+			return a.Dependents > b.Dependents
+		}
+		return cmp.Or(cmp.Compare(a.Package, b.Package), cmp.Compare(a.Architecture, b.Architecture)) < 0
+	})
+	if len(samples) > n {
+		samples = samples[:n]
+	}
+	return samples
+}
+
+// Named picks the packages called names, each at the newest indexed
+// version for every architecture it is indexed for. Names not indexed at
+// all are returned as missing.
+func Named(pkgs []*index.PackageInfo, names []string) (samples []Sample, missing []string) {
+	byName := make(map[string][]*index.PackageInfo)
+	for _, pkg := range newest(pkgs) {
+		byName[pkg.Package] = append(byName[pkg.Package], pkg)
+	}
+	for _, name := range names {
+		found := byName[name]
+		if len(found) == 0 {
+			missing = append(missing, name)
+			continue
+		}
+		slices.SortFunc(found, func(a, b *index.PackageInfo) int { return cmp.Compare(a.Architecture, b.Architecture) })
+		for _, pkg := range found {
+			samples = append(samples, sampleOf(pkg, 0))
+		}
+	}
+	return samples, missing
+}
+
+// newest returns the newest version of each package name and architecture
+func newest(pkgs []*index.PackageInfo) []*index.PackageInfo {
+	type key struct{ name, arch string }
+	latest := make(map[key]*index.PackageInfo)
+	for _, pkg := range pkgs {
+		k := key{pkg.Package, pkg.Architecture}
+		if cur := latest[k]; cur == nil || debpkg.CompareVersions(pkg.Version, cur.Version) > 0 {
+			latest[k] = pkg
+		}
+	}
+	result := make([]*index.PackageInfo, 0, len(latest))
+	for _, pkg := range latest {
+		result = append(result, pkg)
+	}
+	return result
+}
+
+func sampleOf(pkg *index.PackageInfo, dependents int) Sample {
+	return Sample{
+		Package:      pkg.Package,
+		Version:      pkg.Version,
+		Architecture: pkg.Architecture,
+		SHA256:       pkg.SHA256,
+		Dependents:   dependents,
+	}
+}
@@ -0,0 +1,148 @@
+package observer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+type fakeNode struct {
+	providers map[string]int
+}
+
+func (f *fakeNode) FindProviders(ctx context.Context, hash string, limit int) ([]peer.AddrInfo, error) {
+	n, ok := f.providers[hash]
+	if !ok {
+		return nil, errors.New("lookup failed")
+	}
+	return make([]peer.AddrInfo, n), nil
+}
+
+func (f *fakeNode) ConnectedPeers() int           { return 7 }
+func (f *fakeNode) RoutingTableSize() int         { return 12 }
+func (f *fakeNode) GetMDNSPeers() []peer.AddrInfo { return make([]peer.AddrInfo, 2) }
+func (f *fakeNode) PeerVersions() map[string]int {
+	return map[string]int{"1.40.0": 5, "unknown": 2}
+}
+
+func TestObserve(t *testing.T) {
+	node := &fakeNode{providers: map[string]int{"a": 3, "b": 0, "c": 50}}
+	o := New(node, Config{Samples: []Sample{{SHA256: "a"}, {SHA256: "b"}, {SHA256: "c"}, {SHA256: "d"}}}, zap.NewNop())
+
+	if o.Last() != nil {
+		t.Fatal("Last before the first round should be nil")
+	}
+	r := o.Observe(context.Background())
+	if r.Round != 1 || r.ConnectedPeers != 7 || r.RoutingTable != 12 || r.MDNSPeers != 2 || r.Versions["1.40.0"] != 5 {
+		t.Errorf("report = %+v", r)
+	}
+	if r.Sampled != 4 || r.Available != 2 {
+		t.Errorf("sampled %d, available %d; want 4, 2", r.Sampled, r.Available)
+	}
+	if got := r.Packages[2].Providers; got != DefaultMaxProviders {
+		t.Errorf("providers of c = %d, want capped at %d", got, DefaultMaxProviders)
+	}
+	if r.Packages[3].Error == "" {
+		t.Error("failed lookup should report its error")
+	}
+	if r.MedianProviders != 3 {
+		t.Errorf("median = %d, want 3", r.MedianProviders)
+	}
+	if o.Observe(context.Background()).Round != 2 || o.Last().Round != 2 {
+		t.Error("second round should replace the first")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	o := New(&fakeNode{providers: map[string]int{"a": 1}}, Config{Samples: []Sample{{Package: "a", SHA256: "a"}}}, zap.NewNop())
+	h := o.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("before the first round: status %d, want 503", rec.Code)
+	}
+
+	o.Observe(context.Background())
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report", nil))
+	var r Report
+	if err := json.NewDecoder(rec.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || len(r.Packages) != 1 || r.Packages[0].Package != "a" || r.Packages[0].Providers != 1 {
+		t.Errorf("status %d, report %+v", rec.Code, r)
+	}
+}
+
+func TestRunStopsWithContext(t *testing.T) {
+	o := New(&fakeNode{}, Config{}, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	reports := 0
+	done := make(chan struct{})
+	go func() {
+		o.Run(ctx, time.Millisecond, func(*Report) {
+			if reports++; reports == 3 {
+				cancel()
+			}
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	if reports != 3 {
+		t.Errorf("reports = %d, want 3", reports)
+	}
+}
+
+func TestPopular(t *testing.T) {
+	pkgs := []*index.PackageInfo{
+		{Package: "libc6", Version: "2.36-9", Architecture: "amd64", SHA256: "libc-old"},
+		{Package: "libc6", Version: "2.36-9+deb12u4", Architecture: "amd64", SHA256: "libc-new"},
+		{Package: "zlib1g", Version: "1:1.2.13", Architecture: "amd64", SHA256: "zlib", Depends: "libc6 (>= 2.14)"},
+		{Package: "curl", Version: "7.88", Architecture: "amd64", SHA256: "curl", Depends: "libc6 (>= 2.34), zlib1g | libz"},
+		{Package: "wget", Version: "1.21", Architecture: "amd64", SHA256: "wget", Depends: "libc6, libc6:any, zlib1g"},
+		{Package: "hello", Version: "2.10", Architecture: "amd64", SHA256: "hello", Depends: "libc6"},
+	}
+
+	got := Popular(pkgs, 2)
+	if len(got) != 2 {
+		t.Fatalf("Popular = %v, want 2 samples", got)
+	}
+	if got[0].Package != "libc6" || got[0].SHA256 != "libc-new" || got[0].Dependents != 4 {
+		t.Errorf("first = %+v, want newest libc6 with 4 dependents", got[0])
+	}
+	if got[1].Package != "zlib1g" || got[1].Dependents != 2 {
+		t.Errorf("second = %+v, want zlib1g with 2 dependents", got[1])
+	}
+	if all := Popular(pkgs, 100); len(all) != 5 || all[2].Package != "curl" {
+		t.Errorf("Popular(100) = %v, want 5 samples, ties by name", all)
+	}
+}
+
+func TestNamed(t *testing.T) {
+	pkgs := []*index.PackageInfo{
+		{Package: "hello", Version: "2.10", Architecture: "arm64", SHA256: "h-arm"},
+		{Package: "hello", Version: "2.10", Architecture: "amd64", SHA256: "h-amd"},
+		{Package: "hello", Version: "2.9", Architecture: "amd64", SHA256: "h-old"},
+	}
+	samples, missing := Named(pkgs, []string{"hello", "nope"})
+	if len(samples) != 2 || samples[0].SHA256 != "h-amd" || samples[1].SHA256 != "h-arm" {
+		t.Errorf("samples = %+v", samples)
+	}
+	if len(missing) != 1 || missing[0] != "nope" {
+		t.Errorf("missing = %v, want [nope]", missing)
+	}
+}
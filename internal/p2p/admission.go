@@ -0,0 +1,216 @@
+package p2p
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/hostload"
+)
+
+// AdmissionConfig lowers the number of uploads this node accepts while the
+// host is busy with other work, for a workstation that seeds on the side.
+// Each threshold left at zero is not checked.
+type AdmissionConfig struct {
+	MaxLoad        float64       // 1-minute load average per CPU
+	MaxIOWait      float64       // share of CPU time waiting on I/O, 0-1
+	MaxNetworkRate int64         // bytes/sec on the network interfaces, not counting our uploads
+	MinUploads     int           // uploads still accepted however busy the host is (default 1)
+	Interval       time.Duration // how often the host is sampled (default 10s)
+}
+
+// Default admission settings.
+const (
+	DefaultAdmissionInterval   = 10 * time.Second
+	DefaultAdmissionMinUploads = 1
+
+	// admissionCalm is the fraction of each threshold the host must be
+	// back under before the limit is raised again, so that a reading
+	// hovering at a threshold does not flap the limit.
+	admissionCalm = 0.8
+)
+
+// AdmissionState is the latest host sample and the upload limit set from it.
+type AdmissionState struct {
+	Busy          bool      `json:"busy"`
+	Reasons       []string  `json:"reasons,omitempty"` // thresholds exceeded: "load", "iowait", "network"
+	UploadLimit   int       `json:"upload_limit"`
+	MaxUploads    int       `json:"max_uploads"`
+	LoadPerCPU    float64   `json:"load_per_cpu"`
+	IOWaitPercent float64   `json:"iowait_percent"`
+	NetworkRate   float64   `json:"network_bytes_per_sec"` // not counting our uploads
+	SampledAt     time.Time `json:"sampled_at,omitempty"`
+	Error         string    `json:"error,omitempty"` // why the host could not be sampled
+}
+
+// admission holds the upload limit set by host load. The limit is halved
+// each interval the host is busy, down to MinUploads, and raised by a
+// quarter of the maximum each interval it is calm: backing off quickly
+// gives the machine back to its user, and recovering gradually avoids
+// swinging between the two.
+type admission struct {
+	cfg  AdmissionConfig
+	read func() (hostload.Sample, error)
+
+	limit    atomic.Int64
+	uploaded atomic.Int64 // bytes uploaded, to leave out of the network rate
+
+	mu           sync.Mutex
+	state        AdmissionState
+	lastUploaded int64
+}
+
+// startAdmission starts sampling the host when admission control is
+// configured.
+func (n *Node) startAdmission(cfg *AdmissionConfig) {
+	if cfg == nil {
+		return
+	}
+	a := &admission{cfg: *cfg, read: hostload.NewSampler().Sample}
+	if a.cfg.Interval <= 0 {
+		a.cfg.Interval = DefaultAdmissionInterval
+	}
+	if a.cfg.MinUploads <= 0 {
+		a.cfg.MinUploads = DefaultAdmissionMinUploads
+	}
+	a.cfg.MinUploads = min(a.cfg.MinUploads, n.maxConcurrentUploads)
+	a.limit.Store(int64(n.maxConcurrentUploads))
+	a.state = AdmissionState{UploadLimit: n.maxConcurrentUploads, MaxUploads: n.maxConcurrentUploads}
+	n.admission = a
+	n.setAdmissionGauge(n.maxConcurrentUploads)
+
+	go func() {
+		ticker := time.NewTicker(a.cfg.Interval)
+		defer ticker.Stop()
+		a.sample(n) // sets the baseline for the first rates
+		for {
+			select {
+			case <-n.ctx.Done():
+				return
+			case <-ticker.C:
+				a.sample(n)
+			}
+		}
+	}()
+}
+
+// sample reads the host's load and adjusts the upload limit.
+func (a *admission) sample(n *Node) {
+	s, err := a.read()
+	uploaded := a.uploaded.Load()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		// Without readings the node accepts uploads as if admission were off
+		if a.state.Error == "" {
+			n.logger.Warn("Cannot sample host load, upload admission control is inactive", zap.Error(err))
+		}
+		a.state = AdmissionState{UploadLimit: n.maxConcurrentUploads, MaxUploads: n.maxConcurrentUploads, Error: err.Error()}
+		a.setLimit(n, n.maxConcurrentUploads)
+		return
+	}
+
+	var ours float64
+	if !a.state.SampledAt.IsZero() {
+		if secs := s.Time.Sub(a.state.SampledAt).Seconds(); secs > 0 {
+			ours = float64(uploaded-a.lastUploaded) / secs
+		}
+	}
+	a.lastUploaded = uploaded
+	// Uploads are counted when they end, so a long one can make the rest
+	// of the traffic look smaller than it was for an interval
+	netRate := max(s.RxRate+s.TxRate-ours, 0)
+
+	over, calm := a.assess(s, netRate)
+	prev := int(a.limit.Load())
+	limit := prev
+	switch {
+	case len(over) > 0:
+		limit = max(prev/2, a.cfg.MinUploads)
+	case calm:
+		limit = min(prev+max(n.maxConcurrentUploads/4, 1), n.maxConcurrentUploads)
+	}
+
+	if len(over) > 0 && prev == n.maxConcurrentUploads {
+		n.logger.Info("Host is busy, accepting fewer uploads",
+			zap.Strings("reasons", over), zap.Int("uploadLimit", limit))
+	} else if prev < n.maxConcurrentUploads && limit == n.maxConcurrentUploads {
+		n.logger.Info("Host is no longer busy, upload limit restored", zap.Int("uploadLimit", limit))
+	}
+	a.state = AdmissionState{
+		Busy:          len(over) > 0,
+		Reasons:       over,
+		UploadLimit:   limit,
+		MaxUploads:    n.maxConcurrentUploads,
+		LoadPerCPU:    s.LoadPerCPU,
+		IOWaitPercent: s.IOWait * 100,
+		NetworkRate:   netRate,
+		SampledAt:     s.Time,
+	}
+	a.setLimit(n, limit)
+}
+
+// assess returns the thresholds s exceeds, and whether every reading is
+// comfortably below its threshold.
+func (a *admission) assess(s hostload.Sample, netRate float64) (over []string, calm bool) {
+	calm = true
+	check := func(name string, value, threshold float64) {
+		if threshold <= 0 {
+			return
+		}
+		if value > threshold {
+			over = append(over, name)
+		}
+		if value > threshold*admissionCalm {
+			calm = false
+		}
+	}
+	check("load", s.LoadPerCPU, a.cfg.MaxLoad)
+	check("iowait", s.IOWait, a.cfg.MaxIOWait)
+	check("network", netRate, float64(a.cfg.MaxNetworkRate))
+	return over, calm
+}
+
+func (a *admission) setLimit(n *Node, limit int) {
+	a.limit.Store(int64(limit))
+	n.setAdmissionGauge(limit)
+}
+
+func (n *Node) setAdmissionGauge(limit int) {
+	if n.metrics != nil {
+		n.metrics.UploadAdmissionLimit.Set(float64(limit))
+	}
+}
+
+// noteUpload counts bytes sent to peers, which the network threshold leaves
+// out: the uploads it limits are not the work it protects.
+func (a *admission) noteUpload(bytes int64) {
+	if a != nil {
+		a.uploaded.Add(bytes)
+	}
+}
+
+// uploadLimit returns how many uploads are accepted now.
+func (n *Node) uploadLimit() int {
+	if n.uploadsDisabled() {
+		return 0
+	}
+	if n.admission == nil {
+		return n.maxConcurrentUploads
+	}
+	return int(n.admission.limit.Load())
+}
+
+// AdmissionState returns the state of upload admission control, and false
+// if it is not enabled.
+func (n *Node) AdmissionState() (AdmissionState, bool) {
+	if n.admission == nil {
+		return AdmissionState{}, false
+	}
+	n.admission.mu.Lock()
+	defer n.admission.mu.Unlock()
+	return n.admission.state, true
+}
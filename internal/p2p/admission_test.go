@@ -0,0 +1,114 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/debswarm/debswarm/internal/hostload"
+	"github.com/debswarm/debswarm/internal/metrics"
+	"github.com/debswarm/debswarm/internal/priority"
+)
+
+func TestAdmission(t *testing.T) {
+	m := metrics.New()
+	node := &Node{
+		maxConcurrentUploads: 8,
+		uploadsPerPeer:       make(map[peer.ID]int),
+		logger:               newTestLogger(),
+		metrics:              m,
+	}
+	next := hostload.Sample{Time: time.Now()}
+	var readErr error
+	a := &admission{
+		cfg:  AdmissionConfig{MaxLoad: 1, MaxIOWait: 0.2, MaxNetworkRate: 1000, MinUploads: 1},
+		read: func() (hostload.Sample, error) { return next, readErr },
+	}
+	a.limit.Store(8)
+	node.admission = a
+	sample := func(s hostload.Sample) int {
+		t.Helper()
+		next.Time = next.Time.Add(time.Second)
+		s.Time = next.Time
+		next = s
+		a.sample(node)
+		return node.uploadLimit()
+	}
+
+	// Busy: halved each interval, down to the minimum
+	for _, want := range []int{4, 2, 1, 1} {
+		if got := sample(hostload.Sample{LoadPerCPU: 1.5}); got != want {
+			t.Fatalf("limit while busy = %d, want %d", got, want)
+		}
+	}
+	st, ok := node.AdmissionState()
+	if !ok || !st.Busy || !slices.Equal(st.Reasons, []string{"load"}) || st.UploadLimit != 1 || st.MaxUploads != 8 {
+		t.Errorf("state = %+v", st)
+	}
+	if m.UploadAdmissionLimit.Value() != 1 {
+		t.Errorf("gauge = %v, want 1", m.UploadAdmissionLimit.Value())
+	}
+
+	// One upload is accepted; the slots reserved for security updates remain
+	if !node.tryAcceptUpload("a", false, priority.Interactive) || node.tryAcceptUpload("b", false, priority.Interactive) {
+		t.Error("busy host should accept exactly one regular upload")
+	}
+	if !node.tryAcceptUpload("c", true, priority.Interactive) {
+		t.Error("busy host refused a priority upload")
+	}
+	if free := node.localCapabilities().FreeUploadSlots; free != 0 {
+		t.Errorf("hello advertises %d free slots while busy", free)
+	}
+
+	// Just under a threshold holds the limit; well under raises it
+	if got := sample(hostload.Sample{LoadPerCPU: 0.9}); got != 1 {
+		t.Errorf("limit near the threshold = %d, want 1", got)
+	}
+	for _, want := range []int{3, 5, 7, 8, 8} {
+		if got := sample(hostload.Sample{LoadPerCPU: 0.5, IOWait: 0.1}); got != want {
+			t.Fatalf("limit while calm = %d, want %d", got, want)
+		}
+	}
+
+	// Our own uploads do not count as network traffic
+	a.noteUpload(5000)
+	if got := sample(hostload.Sample{RxRate: 500, TxRate: 5000}); got != 8 {
+		t.Errorf("limit with our uploads = %d, want 8", got)
+	}
+	if st, _ := node.AdmissionState(); st.Busy || st.NetworkRate != 500 {
+		t.Errorf("state = %+v, want 500 B/s of other traffic", st)
+	}
+	if got := sample(hostload.Sample{RxRate: 1500, IOWait: 0.5}); got != 4 {
+		t.Errorf("limit with other traffic = %d, want 4", got)
+	}
+	if st, _ := node.AdmissionState(); !slices.Equal(st.Reasons, []string{"iowait", "network"}) {
+		t.Errorf("reasons = %v", st.Reasons)
+	}
+
+	// A host that cannot be sampled is not limited
+	readErr = errors.New("no /proc")
+	if got := sample(hostload.Sample{}); got != 8 {
+		t.Errorf("limit without samples = %d, want 8", got)
+	}
+	if st, _ := node.AdmissionState(); st.Error == "" {
+		t.Error("sampling error not reported")
+	}
+}
+
+func TestAdmissionDisabled(t *testing.T) {
+	node := &Node{maxConcurrentUploads: 3, uploadsPerPeer: make(map[peer.ID]int)}
+	node.startAdmission(nil)
+	if _, ok := node.AdmissionState(); ok {
+		t.Error("admission state reported without admission control")
+	}
+	for i := range 3 {
+		if !node.tryAcceptUpload(peer.ID(fmt.Sprintf("peer-%d", i)), false, priority.Interactive) {
+			t.Fatalf("Should accept upload %d", i)
+		}
+	}
+	node.admission.noteUpload(1) // nil-safe
+}
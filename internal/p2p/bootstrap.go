@@ -0,0 +1,184 @@
+package p2p
+
+import (
+	"context"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/sanitize"
+	"github.com/debswarm/debswarm/internal/timeouts"
+)
+
+// maxDNSAddrDepth bounds nested /dnsaddr lookups (a TXT record pointing at
+// another /dnsaddr name), as go-ipfs does.
+const maxDNSAddrDepth = 4
+
+// resolveBootstrapPeers resolves bootstrap multiaddrs into peers with IP
+// addresses. DNS components (/dns, /dns4, /dns6, /dnsaddr) are looked up now
+// rather than left to the dialer, so a bare /dnsaddr name — whose TXT records
+// carry the peer IDs — works, and re-resolving picks up changed records.
+// Entries that fail to parse or resolve are logged and skipped.
+func resolveBootstrapPeers(ctx context.Context, resolver *madns.Resolver, addrs []string, logger *zap.Logger) []peer.AddrInfo {
+	var resolved []multiaddr.Multiaddr
+	for _, addr := range addrs {
+		ma, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			logger.Warn("Invalid bootstrap address", zap.String("addr", sanitize.String(addr)), zap.Error(err))
+			continue
+		}
+		out, err := resolveAddr(ctx, resolver, ma, maxDNSAddrDepth)
+		if err != nil {
+			logger.Warn("Failed to resolve bootstrap address",
+				zap.String("addr", sanitize.String(addr)), zap.Error(err))
+			continue
+		}
+		resolved = append(resolved, out...)
+	}
+
+	var withID []multiaddr.Multiaddr
+	for _, ma := range resolved {
+		if _, err := peer.IDFromP2PAddr(ma); err != nil {
+			logger.Warn("Bootstrap address has no peer ID", zap.String("addr", ma.String()))
+			continue
+		}
+		withID = append(withID, ma)
+	}
+	infos, err := peer.AddrInfosFromP2pAddrs(withID...)
+	if err != nil {
+		logger.Warn("Failed to parse bootstrap peers", zap.Error(err))
+		return nil
+	}
+	return infos
+}
+
+// resolveAddr resolves every DNS component of ma, following /dnsaddr records
+// up to depth levels.
+func resolveAddr(ctx context.Context, resolver *madns.Resolver, ma multiaddr.Multiaddr, depth int) ([]multiaddr.Multiaddr, error) {
+	if !madns.Matches(ma) {
+		return []multiaddr.Multiaddr{ma}, nil
+	}
+	if depth == 0 {
+		return nil, nil
+	}
+	step, err := resolver.Resolve(ctx, ma)
+	if err != nil {
+		return nil, err
+	}
+	var out []multiaddr.Multiaddr
+	for _, next := range step {
+		more, err := resolveAddr(ctx, resolver, next, depth-1)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, more...)
+	}
+	return out, nil
+}
+
+// hasDNSBootstrap reports whether any bootstrap address needs DNS, and so is
+// worth re-resolving.
+func hasDNSBootstrap(addrs []string) bool {
+	for _, addr := range addrs {
+		if ma, err := multiaddr.NewMultiaddr(addr); err == nil && madns.Matches(ma) {
+			return true
+		}
+	}
+	return false
+}
+
+// connectBootstrapPeers dials the peers in parallel and waits until need of
+// them are connected, counting those connected already, or until all dials
+// are done when need is 0 or cannot be reached. Dials still running when it
+// returns carry on in the background. Returns the peers connected.
+func (n *Node) connectBootstrapPeers(ctx context.Context, infos []peer.AddrInfo, need int) int {
+	connected := 0
+	results := make(chan bool, len(infos))
+	dialing := 0
+	for _, info := range infos {
+		if n.host.Network().Connectedness(info.ID) == network.Connected {
+			connected++
+			continue
+		}
+		dialing++
+		go func(pi peer.AddrInfo) {
+			timeout := n.timeouts.Get(timeouts.OpPeerConnect)
+			timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			if connectErr := n.host.Connect(timeoutCtx, pi); connectErr != nil {
+				n.logger.Debug("Failed to connect to bootstrap peer",
+					zap.String("peer", pi.ID.String()),
+					zap.Error(connectErr))
+				n.timeouts.RecordFailure(timeouts.OpPeerConnect)
+				results <- false
+			} else {
+				n.logger.Debug("Connected to bootstrap peer",
+					zap.String("peer", pi.ID.String()))
+				n.timeouts.RecordSuccess(timeouts.OpPeerConnect, time.Since(start))
+				results <- true
+			}
+		}(info)
+	}
+	for ; dialing > 0 && (need <= 0 || connected < need); dialing-- {
+		if <-results {
+			connected++
+		}
+	}
+	return connected
+}
+
+// refreshBootstrapPeers re-resolves the bootstrap addresses every interval.
+// Addresses that disappeared from DNS are dropped from the peerstore, new ones
+// are added, and bootstrap peers we are no longer connected to are redialed,
+// so a bootstrap node behind dynamic DNS stays reachable without a restart.
+func (n *Node) refreshBootstrapPeers(ctx context.Context, addrs []string, known []peer.AddrInfo, interval time.Duration) {
+	last := make(map[peer.ID][]multiaddr.Multiaddr, len(known))
+	for _, info := range known {
+		last[info.ID] = info.Addrs
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		infos := resolveBootstrapPeers(ctx, n.resolver, addrs, n.logger)
+		if len(infos) == 0 {
+			continue // resolution failed; keep what we had
+		}
+		for _, info := range infos {
+			if stale := missingAddrs(last[info.ID], info.Addrs); len(stale) > 0 {
+				n.logger.Info("Bootstrap peer address changed",
+					zap.String("peer", info.ID.String()),
+					zap.Int("removed", len(stale)),
+					zap.Int("current", len(info.Addrs)))
+				n.host.Peerstore().SetAddrs(info.ID, stale, 0)
+			}
+			n.host.Peerstore().AddAddrs(info.ID, info.Addrs, peerstore.AddressTTL)
+			last[info.ID] = info.Addrs
+		}
+		n.connectBootstrapPeers(ctx, infos, 0)
+	}
+}
+
+// missingAddrs returns the addresses of old that are not in cur.
+func missingAddrs(old, cur []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+	var out []multiaddr.Multiaddr
+	for _, a := range old {
+		if !multiaddr.Contains(cur, a) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
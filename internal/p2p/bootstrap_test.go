@@ -0,0 +1,108 @@
+package p2p
+
+import (
+	"context"
+	"net"
+	"sort"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	"go.uber.org/zap"
+)
+
+const (
+	testBootID1 = "12D3KooWDpJ7As7BWAwRMfu1VU2WCqNjvq387JEYKDBj4kx6nXTN"
+	testBootID2 = "QmNnooDu7bfjPFoTZYxMNLWUQJyrVwtbZg5gBMjTezGAJN"
+)
+
+func mockResolver(t *testing.T, mock *madns.MockResolver) *madns.Resolver {
+	t.Helper()
+	r, err := madns.NewResolver(madns.WithDefaultResolver(mock))
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	return r
+}
+
+func addrStrings(addrs []multiaddr.Multiaddr) []string {
+	out := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		out = append(out, a.String())
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestResolveBootstrapPeers(t *testing.T) {
+	mock := &madns.MockResolver{
+		IP: map[string][]net.IPAddr{
+			"boot.example.org": {{IP: net.ParseIP("192.0.2.10")}, {IP: net.ParseIP("2001:db8::10")}},
+		},
+		TXT: map[string][]string{
+			// A bare /dnsaddr name: the TXT records carry the peer IDs, and one
+			// of them points at another DNS name.
+			"_dnsaddr.swarm.example.org": {
+				"dnsaddr=/ip4/198.51.100.1/tcp/4001/p2p/" + testBootID2,
+				"dnsaddr=/dns4/boot.example.org/udp/4001/quic-v1/p2p/" + testBootID2,
+			},
+		},
+	}
+	addrs := []string{
+		"/dns/boot.example.org/tcp/4001/p2p/" + testBootID1,
+		"/dnsaddr/swarm.example.org",
+		"/ip4/203.0.113.5/tcp/4001", // no peer ID: skipped
+		"not-a-multiaddr",           // skipped
+	}
+
+	infos := resolveBootstrapPeers(context.Background(), mockResolver(t, mock), addrs, zap.NewNop())
+	got := make(map[peer.ID][]string)
+	for _, info := range infos {
+		got[info.ID] = addrStrings(info.Addrs)
+	}
+	if len(got) != 2 {
+		t.Fatalf("resolved %d peers, want 2: %v", len(got), got)
+	}
+
+	id1, _ := peer.Decode(testBootID1)
+	want1 := []string{"/ip4/192.0.2.10/tcp/4001", "/ip6/2001:db8::10/tcp/4001"}
+	if s := got[id1]; len(s) != 2 || s[0] != want1[0] || s[1] != want1[1] {
+		t.Errorf("peer 1 addrs = %v, want %v", s, want1)
+	}
+	id2, _ := peer.Decode(testBootID2)
+	want2 := []string{"/ip4/192.0.2.10/udp/4001/quic-v1", "/ip4/198.51.100.1/tcp/4001"}
+	if s := got[id2]; len(s) != 2 || s[0] != want2[0] || s[1] != want2[1] {
+		t.Errorf("peer 2 addrs = %v, want %v", s, want2)
+	}
+}
+
+func TestResolveAddr_DepthLimit(t *testing.T) {
+	// A /dnsaddr record pointing at itself must not recurse forever.
+	mock := &madns.MockResolver{TXT: map[string][]string{
+		"_dnsaddr.loop.example.org": {"dnsaddr=/dnsaddr/loop.example.org"},
+	}}
+	ma := multiaddr.StringCast("/dnsaddr/loop.example.org")
+	out, err := resolveAddr(context.Background(), mockResolver(t, mock), ma, maxDNSAddrDepth)
+	if err != nil || len(out) != 0 {
+		t.Fatalf("resolveAddr = %v, %v; want nothing", out, err)
+	}
+}
+
+func TestHasDNSBootstrap(t *testing.T) {
+	if hasDNSBootstrap([]string{"/ip4/192.0.2.1/tcp/4001/p2p/" + testBootID1}) {
+		t.Error("IP-only bootstrap list reported as needing DNS")
+	}
+	if !hasDNSBootstrap([]string{"/ip4/192.0.2.1/tcp/4001", "/dns6/boot.example.org/tcp/4001"}) {
+		t.Error("DNS bootstrap address not detected")
+	}
+}
+
+func TestMissingAddrs(t *testing.T) {
+	a := multiaddr.StringCast("/ip4/192.0.2.1/tcp/4001")
+	b := multiaddr.StringCast("/ip4/192.0.2.2/tcp/4001")
+	got := missingAddrs([]multiaddr.Multiaddr{a, b}, []multiaddr.Multiaddr{b})
+	if len(got) != 1 || !got[0].Equal(a) {
+		t.Errorf("missingAddrs = %v, want [%s]", got, a)
+	}
+}
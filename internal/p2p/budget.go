@@ -0,0 +1,225 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+
+	"github.com/debswarm/debswarm/internal/metrics"
+)
+
+// ErrBudgetExhausted is returned when a DHT operation was refused because
+// its hourly budget is spent and the wait queue is full of operations with
+// the same or higher priority.
+var ErrBudgetExhausted = errors.New("DHT operation budget exhausted")
+
+// Priority orders DHT operations waiting for budget. Higher runs first.
+type Priority int
+
+const (
+	// PriorityLow is for background work: periodic re-announcement,
+	// multi-source verification.
+	PriorityLow Priority = iota
+	// PriorityNormal is the default: announcing a freshly downloaded package.
+	PriorityNormal
+	// PriorityHigh is for work a client is waiting on: provider lookups for
+	// an APT request.
+	PriorityHigh
+
+	numPriorities = 3
+)
+
+// dhtMode maps the configured DHT mode to the kad-dht option.
+func dhtMode(mode string) dht.ModeOpt {
+	switch mode {
+	case "server":
+		return dht.ModeServer
+	case "client":
+		return dht.ModeClient
+	default:
+		return dht.ModeAutoServer
+	}
+}
+
+type priorityKey struct{}
+
+// WithPriority tags ctx so DHT operations made with it queue at p when their
+// budget is exhausted.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityFrom(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok && p >= PriorityLow && p < numPriorities {
+		return p
+	}
+	return PriorityNormal
+}
+
+// maxBudgetQueue bounds how many operations may wait for budget at once.
+const maxBudgetQueue = 256
+
+// opBudget limits one kind of DHT operation to perHour operations. Tokens
+// refill continuously; up to a tenth of the hourly budget may be spent in a
+// burst. Operations arriving with no token wait in per-priority FIFO queues
+// and are admitted highest priority first as tokens refill. When the queue
+// is full a new operation displaces the newest waiter of lower priority, or
+// is refused if there is none.
+type opBudget struct {
+	perHour int // 0 = unlimited
+
+	mu       sync.Mutex
+	tokens   float64
+	burst    float64
+	last     time.Time
+	waiters  [numPriorities][]*budgetWaiter
+	queued   int
+	maxQueue int
+	timer    *time.Timer
+	now      func() time.Time
+
+	// queuedGauge, when set, tracks the number of waiting operations
+	queuedGauge *metrics.Gauge
+}
+
+type budgetWaiter struct {
+	ch chan error // receives nil when admitted, ErrBudgetExhausted when displaced
+}
+
+func newOpBudget(perHour int) *opBudget {
+	b := &opBudget{perHour: perHour, maxQueue: maxBudgetQueue, now: time.Now}
+	if perHour > 0 {
+		b.burst = max(1, float64(perHour)/10)
+		b.tokens = b.burst
+		b.last = b.now()
+	}
+	return b
+}
+
+// acquire takes one operation's worth of budget, waiting in the queue for
+// priority p if necessary.
+func (b *opBudget) acquire(ctx context.Context, p Priority) error {
+	if b == nil || b.perHour <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	b.refillLocked()
+	if b.tokens >= 1 && !b.waitingAtOrAboveLocked(p) {
+		b.tokens--
+		b.mu.Unlock()
+		return nil
+	}
+	if b.queued >= b.maxQueue && !b.displaceLocked(p) {
+		b.mu.Unlock()
+		return ErrBudgetExhausted
+	}
+	w := &budgetWaiter{ch: make(chan error, 1)}
+	b.waiters[p] = append(b.waiters[p], w)
+	b.queued++
+	b.scheduleLocked()
+	b.publishLocked()
+	b.mu.Unlock()
+
+	select {
+	case err := <-w.ch:
+		return err
+	case <-ctx.Done():
+		b.mu.Lock()
+		removed := b.removeLocked(p, w)
+		b.publishLocked()
+		b.mu.Unlock()
+		if !removed {
+			// Admitted or displaced concurrently; a token granted to us is
+			// spent either way, as the operation is abandoned.
+			<-w.ch
+		}
+		return ctx.Err()
+	}
+}
+
+func (b *opBudget) refillLocked() {
+	now := b.now()
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	b.tokens = min(b.burst, b.tokens+elapsed*float64(b.perHour)/3600)
+}
+
+func (b *opBudget) waitingAtOrAboveLocked(p Priority) bool {
+	for q := p; q < numPriorities; q++ {
+		if len(b.waiters[q]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// displaceLocked refuses the newest waiter of the lowest priority below p to
+// make room. It reports false when every waiter is at p or above.
+func (b *opBudget) displaceLocked(p Priority) bool {
+	for q := PriorityLow; q < p; q++ {
+		if n := len(b.waiters[q]); n > 0 {
+			w := b.waiters[q][n-1]
+			b.waiters[q] = b.waiters[q][:n-1]
+			b.queued--
+			w.ch <- ErrBudgetExhausted
+			return true
+		}
+	}
+	return false
+}
+
+func (b *opBudget) removeLocked(p Priority, w *budgetWaiter) bool {
+	for i, x := range b.waiters[p] {
+		if x == w {
+			b.waiters[p] = append(b.waiters[p][:i], b.waiters[p][i+1:]...)
+			b.queued--
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleLocked arms the timer to admit waiters when the next token is due.
+func (b *opBudget) scheduleLocked() {
+	if b.timer != nil || b.queued == 0 {
+		return
+	}
+	wait := time.Duration((1 - b.tokens) * 3600 / float64(b.perHour) * float64(time.Second))
+	if wait < 0 {
+		wait = 0
+	}
+	b.timer = time.AfterFunc(wait, b.admit)
+}
+
+// admit hands refilled tokens to waiters, highest priority first.
+func (b *opBudget) admit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.timer = nil
+	b.refillLocked()
+	for b.tokens >= 1 && b.queued > 0 {
+		for q := numPriorities - 1; q >= 0; q-- {
+			if len(b.waiters[q]) == 0 {
+				continue
+			}
+			w := b.waiters[q][0]
+			b.waiters[q] = b.waiters[q][1:]
+			b.queued--
+			b.tokens--
+			w.ch <- nil
+			break
+		}
+	}
+	b.scheduleLocked()
+	b.publishLocked()
+}
+
+func (b *opBudget) publishLocked() {
+	if b.queuedGauge != nil {
+		b.queuedGauge.Set(float64(b.queued))
+	}
+}
@@ -0,0 +1,162 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/metrics"
+)
+
+// drainedBudget returns a budget of perHour with no tokens left.
+func drainedBudget(perHour int) *opBudget {
+	b := newOpBudget(perHour)
+	b.tokens = 0
+	return b
+}
+
+func waitQueued(t *testing.T, b *opBudget, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		b.mu.Lock()
+		queued := b.queued
+		b.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("queue never reached %d waiters", n)
+}
+
+func TestOpBudget_Unlimited(t *testing.T) {
+	var nilBudget *opBudget
+	if err := nilBudget.acquire(context.Background(), PriorityLow); err != nil {
+		t.Fatalf("nil budget: %v", err)
+	}
+	b := newOpBudget(0)
+	for range 1000 {
+		if err := b.acquire(context.Background(), PriorityLow); err != nil {
+			t.Fatalf("unlimited budget: %v", err)
+		}
+	}
+}
+
+func TestOpBudget_BurstThenWait(t *testing.T) {
+	b := newOpBudget(100) // burst of 10
+	for i := range 10 {
+		if err := b.acquire(context.Background(), PriorityNormal); err != nil {
+			t.Fatalf("acquire %d within burst: %v", i, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.acquire(ctx, PriorityNormal); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire past burst: err = %v, want deadline exceeded", err)
+	}
+	b.mu.Lock()
+	queued := b.queued
+	b.mu.Unlock()
+	if queued != 0 {
+		t.Errorf("cancelled waiter left in queue (%d queued)", queued)
+	}
+}
+
+func TestOpBudget_HighPriorityAdmittedFirst(t *testing.T) {
+	b := drainedBudget(36000) // one token every 100ms
+
+	var mu sync.Mutex
+	var order []Priority
+	var wg sync.WaitGroup
+	start := func(p Priority) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.acquire(context.Background(), p); err != nil {
+				t.Errorf("acquire(%d): %v", p, err)
+				return
+			}
+			mu.Lock()
+			order = append(order, p)
+			mu.Unlock()
+		}()
+	}
+	start(PriorityLow)
+	waitQueued(t, b, 1)
+	start(PriorityNormal)
+	waitQueued(t, b, 2)
+	start(PriorityHigh)
+	waitQueued(t, b, 3)
+	wg.Wait()
+
+	want := []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("admission order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestOpBudget_FullQueueDisplacesLowerPriority(t *testing.T) {
+	b := drainedBudget(1) // effectively never refills during the test
+	b.maxQueue = 1
+
+	lowErr := make(chan error, 1)
+	go func() { lowErr <- b.acquire(context.Background(), PriorityLow) }()
+	waitQueued(t, b, 1)
+
+	// A second low-priority operation finds the queue full and is refused.
+	if err := b.acquire(context.Background(), PriorityLow); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("low into full queue: err = %v, want ErrBudgetExhausted", err)
+	}
+
+	// A high-priority one displaces the queued low-priority operation.
+	ctx, cancel := context.WithCancel(context.Background())
+	highErr := make(chan error, 1)
+	go func() { highErr <- b.acquire(ctx, PriorityHigh) }()
+	select {
+	case err := <-lowErr:
+		if !errors.Is(err, ErrBudgetExhausted) {
+			t.Fatalf("displaced waiter: err = %v, want ErrBudgetExhausted", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("low-priority waiter was not displaced")
+	}
+	cancel()
+	if err := <-highErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("high waiter: err = %v, want context.Canceled", err)
+	}
+}
+
+func TestOpBudget_QueuedGauge(t *testing.T) {
+	b := drainedBudget(1)
+	b.queuedGauge = &metrics.Gauge{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.acquire(ctx, PriorityNormal) }()
+	waitQueued(t, b, 1)
+	if got := b.queuedGauge.Value(); got != 1 {
+		t.Errorf("queued gauge = %v with one waiter, want 1", got)
+	}
+	cancel()
+	<-done
+	if got := b.queuedGauge.Value(); got != 0 {
+		t.Errorf("queued gauge = %v after the waiter gave up, want 0", got)
+	}
+}
+
+func TestPriorityFrom(t *testing.T) {
+	if p := priorityFrom(context.Background()); p != PriorityNormal {
+		t.Errorf("default priority = %d, want PriorityNormal", p)
+	}
+	if p := priorityFrom(WithPriority(context.Background(), PriorityHigh)); p != PriorityHigh {
+		t.Errorf("priority = %d, want PriorityHigh", p)
+	}
+	if p := priorityFrom(WithPriority(context.Background(), Priority(42))); p != PriorityNormal {
+		t.Errorf("out-of-range priority = %d, want PriorityNormal", p)
+	}
+}
@@ -0,0 +1,145 @@
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+// Packages are compressed already, but index files, Contents and DEP-11
+// metadata shared between peers shrink several-fold. A node with compression
+// enabled serves ProtocolTransferCompressed alongside the plain protocols and
+// prefers it when fetching, so two such nodes negotiate it through libp2p's
+// protocol selection while older peers keep using the plain protocols.
+//
+// The request is a range frame (see encodeRangeRequest). The response is the
+// 8-byte uncompressed size, one encoding byte, then the content: raw, or as a
+// zstd stream that decodes to exactly that size. The server picks the
+// encoding per transfer by sniffing the content, so compressed data is not
+// compressed again.
+const ProtocolTransferCompressed = "/debswarm/transfer-zstd/1.0.0"
+
+// Response encodings of ProtocolTransferCompressed.
+const (
+	encodingIdentity byte = 0
+	encodingZstd     byte = 1
+)
+
+// maxZstdWindow caps the zstd window both ways. Senders never use a larger
+// one, and receivers refuse a frame declaring one, so a peer cannot make
+// each chunk stream allocate an arbitrary window.
+const maxZstdWindow = 8 << 20
+
+// CompressionConfig controls compression of transfers. A nil config on the
+// node disables it.
+type CompressionConfig struct {
+	// Level trades CPU for bandwidth
+	Level zstd.EncoderLevel
+	// MinSize is the smallest transfer worth compressing, in bytes
+	MinSize int64
+	// LAN also compresses transfers with LAN peers, where bandwidth is
+	// rarely the bottleneck
+	LAN bool
+}
+
+// compressedMagics are the leading bytes of formats that do not compress
+// further: gzip, xz, zstd, bzip2, lz4, and the ar archive a .deb is.
+var compressedMagics = [][]byte{
+	{0x1f, 0x8b},
+	{0xfd, '7', 'z', 'X', 'Z', 0x00},
+	{0x28, 0xb5, 0x2f, 0xfd},
+	{'B', 'Z', 'h'},
+	{0x04, 0x22, 0x4d, 0x18},
+	[]byte("!<arch>\n"),
+}
+
+// sniffCompressible reports whether content starting with head is worth
+// compressing.
+func sniffCompressible(head []byte) bool {
+	for _, magic := range compressedMagics {
+		if bytes.HasPrefix(head, magic) {
+			return false
+		}
+	}
+	return true
+}
+
+// chooseEncoding picks the encoding for a transfer of size bytes over conn,
+// peeking at the content through br.
+func (n *Node) chooseEncoding(br *bufio.Reader, size int64, conn network.Conn) byte {
+	c := n.compression
+	if c == nil || size < c.MinSize || (!c.LAN && n.isLANConn(conn)) {
+		return encodingIdentity
+	}
+	head, _ := br.Peek(8)
+	if !sniffCompressible(head) {
+		return encodingIdentity
+	}
+	return encodingZstd
+}
+
+// writeEncoded writes size bytes from r to w in the given encoding,
+// returning the content bytes written.
+func (n *Node) writeEncoded(w io.Writer, r io.Reader, size int64, encoding byte) (int64, error) {
+	if _, err := w.Write([]byte{encoding}); err != nil {
+		return 0, err
+	}
+	if encoding != encodingZstd {
+		return io.CopyN(w, r, size)
+	}
+
+	wire := &countingWriter{w: w}
+	enc, err := zstd.NewWriter(wire, zstd.WithEncoderLevel(n.compression.Level), zstd.WithEncoderConcurrency(1),
+		zstd.WithWindowSize(maxZstdWindow))
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.CopyN(enc, r, size)
+	if closeErr := enc.Close(); err == nil {
+		err = closeErr
+	}
+	if n.metrics != nil {
+		n.metrics.TransferCompression.WithLabel("raw").Add(written)
+		n.metrics.TransferCompression.WithLabel("wire").Add(wire.n)
+	}
+	return written, err
+}
+
+// decodedReader returns a reader of the size bytes of content that follow
+// the encoding byte on r, and a function releasing it. The decoder's window
+// and memory are bounded, as the stream comes from an untrusted peer.
+func decodedReader(r io.Reader, size int64) (io.Reader, func(), error) {
+	var encoding [1]byte
+	if _, err := io.ReadFull(r, encoding[:]); err != nil {
+		return nil, nil, fmt.Errorf("failed to read encoding: %w", err)
+	}
+	switch encoding[0] {
+	case encodingIdentity:
+		return r, func() {}, nil
+	case encodingZstd:
+		dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxWindow(maxZstdWindow), zstd.WithDecoderMaxMemory(uint64(max(size, 1))))
+		if err != nil {
+			return nil, nil, err
+		}
+		return dec, dec.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown transfer encoding %d", encoding[0])
+	}
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
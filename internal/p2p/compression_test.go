@@ -0,0 +1,164 @@
+package p2p
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestSniffCompressible(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write([]byte("Package: hello\n"))
+	_ = w.Close()
+
+	tests := []struct {
+		name string
+		head []byte
+		want bool
+	}{
+		{"Packages text", []byte("Package: hello\nVersion: 1.0\n"), true},
+		{"empty", nil, true},
+		{"gzip", gz.Bytes(), false},
+		{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00, 0x00}, false},
+		{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd, 0x04}, false},
+		{"deb", []byte("!<arch>\ndebian-binary   "), false},
+	}
+	for _, tt := range tests {
+		if got := sniffCompressible(tt.head); got != tt.want {
+			t.Errorf("%s: sniffCompressible = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompressedTransfer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	logger := newTestLogger()
+	// Test peers are on loopback, so LAN transfers must be compressed too
+	compression := &CompressionConfig{Level: zstd.SpeedDefault, MinSize: 1024, LAN: true}
+
+	serverCfg := newTestConfig(t)
+	serverCfg.Compression = compression
+	server, err := New(ctx, serverCfg, logger)
+	if err != nil {
+		t.Fatalf("New server failed: %v", err)
+	}
+	defer server.Close()
+
+	text := []byte(strings.Repeat("Package: hello\nVersion: 2.10-3\nArchitecture: amd64\n\n", 500))
+	textHash := "1111111111111111111111111111111111111111111111111111111111111111"
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, _ = gw.Write(text)
+	_ = gw.Close()
+	gzHash := "2222222222222222222222222222222222222222222222222222222222222222"
+	server.SetContentProvider(ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+		switch hash {
+		case textHash:
+			return io.NopCloser(bytes.NewReader(text)), int64(len(text)), nil
+		case gzHash:
+			return io.NopCloser(bytes.NewReader(gz.Bytes())), int64(gz.Len()), nil
+		}
+		return nil, 0, ErrContentNotFound
+	}))
+	serverInfo := peer.AddrInfo{ID: server.PeerID(), Addrs: server.Addrs()}
+	compressed := server.metrics.TransferCompression
+
+	clientCfg := newTestConfig(t)
+	clientCfg.Compression = compression
+	client, err := New(ctx, clientCfg, logger)
+	if err != nil {
+		t.Fatalf("New client failed: %v", err)
+	}
+	defer client.Close()
+
+	data, err := client.Download(ctx, serverInfo, textHash)
+	if err != nil || !bytes.Equal(data, text) {
+		t.Fatalf("Download text = %d bytes, %v", len(data), err)
+	}
+	raw, wire := compressed.WithLabel("raw").Value(), compressed.WithLabel("wire").Value()
+	if raw != int64(len(text)) || wire == 0 || wire >= raw/4 {
+		t.Errorf("compressed %d raw bytes to %d on the wire", raw, wire)
+	}
+
+	data, err = client.DownloadRange(ctx, serverInfo, textHash, 100, 3000)
+	if err != nil || !bytes.Equal(data, text[100:3000]) {
+		t.Fatalf("DownloadRange text = %d bytes, %v", len(data), err)
+	}
+
+	// Already compressed content, and transfers below MinSize, go as is
+	before := compressed.WithLabel("raw").Value()
+	data, err = client.Download(ctx, serverInfo, gzHash)
+	if err != nil || !bytes.Equal(data, gz.Bytes()) {
+		t.Fatalf("Download gzip = %d bytes, %v", len(data), err)
+	}
+	data, err = client.DownloadRange(ctx, serverInfo, textHash, 0, 100)
+	if err != nil || !bytes.Equal(data, text[:100]) {
+		t.Fatalf("small DownloadRange = %d bytes, %v", len(data), err)
+	}
+	if got := compressed.WithLabel("raw").Value(); got != before {
+		t.Errorf("compressed %d more raw bytes, want none", got-before)
+	}
+
+	// A peer without compression still gets plain transfers
+	plain, err := New(ctx, newTestConfig(t), logger)
+	if err != nil {
+		t.Fatalf("New plain failed: %v", err)
+	}
+	defer plain.Close()
+	data, err = plain.Download(ctx, serverInfo, textHash)
+	if err != nil || !bytes.Equal(data, text) {
+		t.Fatalf("plain Download = %d bytes, %v", len(data), err)
+	}
+	if got := compressed.WithLabel("raw").Value(); got != before {
+		t.Error("transfer to a peer without compression was compressed")
+	}
+}
+
+func TestDecodedReader_Limits(t *testing.T) {
+	encode := func(content []byte) []byte {
+		var buf bytes.Buffer
+		buf.WriteByte(encodingZstd)
+		enc, err := zstd.NewWriter(&buf, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := enc.Write(content); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	decode := func(wire []byte, size int64) ([]byte, error) {
+		r, release, err := decodedReader(bytes.NewReader(wire), size)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return io.ReadAll(r)
+	}
+
+	content := bytes.Repeat([]byte("debswarm "), 1024)
+	if got, err := decode(encode(content), int64(len(content))); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("decode within limits: %d bytes, %v", len(got), err)
+	}
+	// A frame header declaring a 32MB window (exponent 15), then an empty
+	// last block
+	bigWindow := []byte{encodingZstd, 0x28, 0xb5, 0x2f, 0xfd, 0x00, 15 << 3, 0x01, 0x00, 0x00}
+	if _, err := decode(bigWindow, 1<<30); err == nil {
+		t.Error("frame declaring a window above the cap was decoded")
+	}
+	if _, err := decode(encode(content), 1024); err == nil {
+		t.Error("stream decoding to more than the announced size was decoded")
+	}
+}
@@ -0,0 +1,146 @@
+// Package p2p - Live connection and routing table listing
+package p2p
+
+import (
+	"sort"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Connection describes one open connection to a peer
+type Connection struct {
+	Remote    multiaddr.Multiaddr
+	Transport string // "quic", "tcp", "webtransport", "websocket" or "relay"
+	Direction string // "inbound" or "outbound"
+	Opened    time.Time
+}
+
+// PeerConnections describes a connected peer: its open connections, oldest
+// first, and the transfers in progress with it
+type PeerConnections struct {
+	ID          peer.ID
+	Connections []Connection
+	Uploads     int // transfers we are serving to the peer
+	Downloads   int // transfers we are fetching from the peer
+}
+
+// Since returns when the oldest open connection to the peer was opened
+func (p *PeerConnections) Since() time.Time {
+	if len(p.Connections) == 0 {
+		return time.Time{}
+	}
+	return p.Connections[0].Opened
+}
+
+// Connections lists the connected peers, longest connected first
+func (n *Node) Connections() []PeerConnections {
+	var result []PeerConnections
+	for _, id := range n.host.Network().Peers() {
+		pc := PeerConnections{ID: id}
+		for _, c := range n.host.Network().ConnsToPeer(id) {
+			st := c.Stat()
+			pc.Connections = append(pc.Connections, Connection{
+				Remote:    c.RemoteMultiaddr(),
+				Transport: transportName(c.RemoteMultiaddr()),
+				Direction: directionName(st.Direction),
+				Opened:    st.Opened,
+			})
+		}
+		if len(pc.Connections) == 0 {
+			continue
+		}
+		sort.Slice(pc.Connections, func(i, j int) bool {
+			return pc.Connections[i].Opened.Before(pc.Connections[j].Opened)
+		})
+		pc.Uploads, pc.Downloads = n.activeTransfers(id)
+		result = append(result, pc)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Since().Before(result[j].Since()) })
+	return result
+}
+
+// RoutingPeer is an entry of the DHT routing table
+type RoutingPeer struct {
+	ID            peer.ID
+	AddedAt       time.Time
+	LastUsefulAt  time.Time // last time the peer answered a query usefully
+	LastQueriedAt time.Time // last successful outbound query to the peer
+}
+
+// RoutingTable lists the DHT routing table
+func (n *Node) RoutingTable() []RoutingPeer {
+	infos := n.dht.RoutingTable().GetPeerInfos()
+	result := make([]RoutingPeer, 0, len(infos))
+	for _, pi := range infos {
+		result = append(result, RoutingPeer{
+			ID:            pi.Id,
+			AddedAt:       pi.AddedAt,
+			LastUsefulAt:  pi.LastUsefulAt,
+			LastQueriedAt: pi.LastSuccessfulOutboundQueryAt,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].AddedAt.Before(result[j].AddedAt) })
+	return result
+}
+
+// activeTransfers returns the uploads to and downloads from a peer in progress
+func (n *Node) activeTransfers(id peer.ID) (uploads, downloads int) {
+	n.uploadsMu.Lock()
+	defer n.uploadsMu.Unlock()
+	return n.uploadsPerPeer[id], n.downloadsPerPeer[id]
+}
+
+// trackDownload adjusts the count of downloads in progress from a peer
+func (n *Node) trackDownload(id peer.ID, delta int) {
+	n.uploadsMu.Lock()
+	defer n.uploadsMu.Unlock()
+	if n.downloadsPerPeer == nil {
+		n.downloadsPerPeer = make(map[peer.ID]int)
+	}
+	n.downloadsPerPeer[id] += delta
+	if n.downloadsPerPeer[id] <= 0 {
+		delete(n.downloadsPerPeer, id)
+	}
+}
+
+// transportName names the transport of a connection from its remote
+// address. A relayed connection is "relay" whatever carries the circuit.
+func transportName(addr multiaddr.Multiaddr) string {
+	if addr == nil {
+		return "unknown"
+	}
+	name := "unknown"
+	for _, p := range addr {
+		switch p.Protocol().Code {
+		case multiaddr.P_CIRCUIT:
+			return "relay"
+		case multiaddr.P_WEBTRANSPORT:
+			name = "webtransport"
+		case multiaddr.P_WS, multiaddr.P_WSS:
+			name = "websocket"
+		case multiaddr.P_QUIC_V1, multiaddr.P_QUIC:
+			if name == "unknown" {
+				name = "quic"
+			}
+		case multiaddr.P_TCP:
+			if name == "unknown" {
+				name = "tcp"
+			}
+		}
+	}
+	return name
+}
+
+func directionName(d network.Direction) string {
+	switch d {
+	case network.DirInbound:
+		return "inbound"
+	case network.DirOutbound:
+		return "outbound"
+	default:
+		return "unknown"
+	}
+}
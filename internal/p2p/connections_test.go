@@ -0,0 +1,76 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestTransportName(t *testing.T) {
+	tests := map[string]string{
+		"/ip4/10.0.0.1/tcp/4001":                                            "tcp",
+		"/ip4/10.0.0.1/udp/4001/quic-v1":                                    "quic",
+		"/ip4/10.0.0.1/udp/4001/quic-v1/webtransport":                       "webtransport",
+		"/ip4/10.0.0.1/tcp/443/ws":                                          "websocket",
+		"/ip4/10.0.0.1/udp/4001/quic-v1/p2p/" + testPeerID + "/p2p-circuit": "relay",
+	}
+	for addr, want := range tests {
+		if got := transportName(multiaddr.StringCast(addr)); got != want {
+			t.Errorf("transportName(%s) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+// testPeerID is a syntactically valid peer ID for building addresses
+const testPeerID = "12D3KooWGzxzKZYveHXtpG6AsrUJBcWxHBFS2HsEoGTxrMLvKXtf"
+
+func TestNode_Connections(t *testing.T) {
+	ctx := context.Background()
+	node1, err := New(ctx, newTestConfig(t), newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node1.Close()
+	node2, err := New(ctx, newTestConfig(t), newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node2.Close()
+
+	if got := node1.Connections(); len(got) != 0 {
+		t.Fatalf("%d connections before connecting", len(got))
+	}
+	before := time.Now().Add(-time.Second)
+	if err := node2.host.Connect(ctx, peer.AddrInfo{ID: node1.PeerID(), Addrs: node1.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+
+	conns := node2.Connections()
+	if len(conns) != 1 || conns[0].ID != node1.PeerID() {
+		t.Fatalf("connections = %+v, want node1", conns)
+	}
+	c := conns[0].Connections[0]
+	if c.Direction != "outbound" || c.Transport == "unknown" || c.Opened.Before(before) {
+		t.Errorf("connection = %+v", c)
+	}
+
+	node2.trackDownload(node1.PeerID(), 1)
+	if downloads := node2.Connections()[0].Downloads; downloads != 1 {
+		t.Errorf("downloads = %d, want 1", downloads)
+	}
+	node2.trackDownload(node1.PeerID(), -1)
+	if len(node2.downloadsPerPeer) != 0 {
+		t.Error("finished download still tracked")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(node1.Connections()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if conns := node1.Connections(); len(conns) != 1 || conns[0].Connections[0].Direction != "inbound" {
+		t.Errorf("node1 connections = %+v, want one inbound", conns)
+	}
+}
@@ -0,0 +1,52 @@
+package p2p
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrContentNotFound is returned by a ContentChain none of whose providers
+// has the requested content.
+var ErrContentNotFound = errors.New("content not found")
+
+// ContentProvider is a store of content served to peers, addressed by its
+// SHA256 hash: the package cache, a cold tier, or an existing mirror tree
+// read in place.
+type ContentProvider interface {
+	// Open returns the content with the given hash and its size, or an
+	// error if the provider does not have it.
+	Open(sha256Hash string) (io.ReadCloser, int64, error)
+}
+
+// ContentGetter adapts a function to a ContentProvider.
+type ContentGetter func(sha256Hash string) (io.ReadCloser, int64, error)
+
+// Open calls f.
+func (f ContentGetter) Open(sha256Hash string) (io.ReadCloser, int64, error) {
+	return f(sha256Hash)
+}
+
+// ContentChain is a ContentProvider that consults its providers in order
+// (e.g. memory tier, disk cache, cold tier, local mirror directory) and
+// serves the content from the first that has it.
+type ContentChain []ContentProvider
+
+// Open returns the content from the first provider that has it. If none
+// has, it returns the first provider's error, or ErrContentNotFound for an
+// empty chain.
+func (c ContentChain) Open(sha256Hash string) (io.ReadCloser, int64, error) {
+	var firstErr error
+	for _, p := range c {
+		r, size, err := p.Open(sha256Hash)
+		if err == nil {
+			return r, size, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = ErrContentNotFound
+	}
+	return nil, 0, firstErr
+}
@@ -0,0 +1,45 @@
+package p2p
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestContentChain(t *testing.T) {
+	errDown := errors.New("store offline")
+	store := func(hash, body string, missing error) ContentProvider {
+		return ContentGetter(func(h string) (io.ReadCloser, int64, error) {
+			if h != hash {
+				return nil, 0, missing
+			}
+			return io.NopCloser(strings.NewReader(body)), int64(len(body)), nil
+		})
+	}
+	chain := ContentChain{
+		store("a", "memory", errDown),
+		store("b", "disk", ErrContentNotFound),
+		store("a", "shadowed", ErrContentNotFound),
+	}
+
+	for hash, want := range map[string]string{"a": "memory", "b": "disk"} {
+		rc, size, err := chain.Open(hash)
+		if err != nil {
+			t.Fatalf("Open(%q): %v", hash, err)
+		}
+		got, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		if string(got) != want || size != int64(len(want)) {
+			t.Errorf("Open(%q) = %q (%d bytes), want %q", hash, got, size, want)
+		}
+	}
+
+	// The first store's error is reported when none has the content
+	if _, _, err := chain.Open("c"); !errors.Is(err, errDown) {
+		t.Errorf("Open(missing) error = %v, want the first store's", err)
+	}
+	if _, _, err := (ContentChain{}).Open("a"); !errors.Is(err, ErrContentNotFound) {
+		t.Errorf("empty chain error = %v", err)
+	}
+}
@@ -62,12 +62,12 @@ func TestNode_Download_CancelResetsStream(t *testing.T) {
 	gate := make(chan struct{})
 	t.Cleanup(func() { close(gate) }) // unblock the server-side copy at test end
 
-	node1.SetContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+	node1.SetContentProvider(ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
 		if hash == testHash {
 			return &stallingReader{prefix: make([]byte, 1024), gate: gate}, advertisedSize, nil
 		}
 		return nil, 0, io.EOF
-	})
+	}))
 
 	node1Info := peer.AddrInfo{
 		ID:    node1.PeerID(),
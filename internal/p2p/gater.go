@@ -2,21 +2,36 @@
 package p2p
 
 import (
+	"net"
 	"sync"
 
 	"github.com/libp2p/go-libp2p/core/control"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
 
 	"github.com/debswarm/debswarm/internal/security"
 )
 
 // AllowlistGater implements connmgr.ConnectionGater to restrict connections
-// to a specific set of peer IDs and block specific peers
+// to a specific set of peer IDs and networks and block specific ones.
+//
+// Rules are evaluated in this order, the first that applies deciding:
+//  1. a blocked peer ID is refused
+//  2. an address in a blocked network is refused
+//  3. an address in an allowed network is accepted, even a private one
+//  4. a private or reserved address is refused when dialing or accepting
+//  5. with allow rules, a peer ID on the allowlist is accepted and any
+//     other peer refused; without them, every peer is accepted
+//
+// Relayed connections have no address of the peer's own, so only peer ID
+// rules apply to them.
 type AllowlistGater struct {
 	allowlist        map[peer.ID]struct{}
 	blocklist        map[peer.ID]struct{}
+	allowNets        []*net.IPNet
+	blockNets        []*net.IPNet
 	mu               sync.RWMutex
 	allowlistEnabled bool
 }
@@ -56,6 +71,52 @@ func NewGater(allowlist []peer.ID, blocklist []peer.ID) *AllowlistGater {
 	return g
 }
 
+// PeerRules are the peer IDs and networks of an allowlist or blocklist
+type PeerRules struct {
+	IDs      []peer.ID
+	Networks []*net.IPNet
+}
+
+// Empty reports whether there are no rules
+func (r PeerRules) Empty() bool {
+	return len(r.IDs) == 0 && len(r.Networks) == 0
+}
+
+// ParsePeerRules parses allowlist or blocklist entries: peer IDs, networks
+// in CIDR form ("10.20.0.0/16") and single IP addresses. Entries that are
+// none of these are returned as invalid.
+func ParsePeerRules(entries []string) (rules PeerRules, invalid []string) {
+	for _, e := range entries {
+		if id, err := peer.Decode(e); err == nil {
+			rules.IDs = append(rules.IDs, id)
+			continue
+		}
+		if _, n, err := net.ParseCIDR(e); err == nil {
+			rules.Networks = append(rules.Networks, n)
+			continue
+		}
+		if ip := net.ParseIP(e); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			rules.Networks = append(rules.Networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		invalid = append(invalid, e)
+	}
+	return rules, invalid
+}
+
+// NewRulesGater creates a connection gater from allow and block rules
+func NewRulesGater(allow, block PeerRules) *AllowlistGater {
+	g := NewGater(allow.IDs, block.IDs)
+	g.allowNets = append(g.allowNets, allow.Networks...)
+	g.blockNets = append(g.blockNets, block.Networks...)
+	g.allowlistEnabled = !allow.Empty()
+	return g
+}
+
 // Enabled returns whether allowlist gating is active
 func (g *AllowlistGater) Enabled() bool {
 	return g.allowlistEnabled
@@ -113,15 +174,87 @@ func (g *AllowlistGater) ListBlockedPeers() []peer.ID {
 	return peers
 }
 
+// AllowNetwork adds a network to the allowlist
+func (g *AllowlistGater) AllowNetwork(n *net.IPNet) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.allowNets = append(g.allowNets, n)
+	g.allowlistEnabled = true
+}
+
+// BlockNetwork adds a network to the blocklist
+func (g *AllowlistGater) BlockNetwork(n *net.IPNet) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.blockNets = append(g.blockNets, n)
+}
+
+// verdict is the outcome of the address rules for a connection
+type verdict int
+
+const (
+	verdictNone    verdict = iota // no address rule applies
+	verdictAllowed                // in an allowed network
+	verdictBlocked                // in a blocked network
+)
+
+// addrVerdict applies the network rules to a remote address. Relayed
+// addresses name the relay, not the peer, so no rule applies to them.
+func (g *AllowlistGater) addrVerdict(addr multiaddr.Multiaddr) verdict {
+	if addr == nil || len(g.allowNets)+len(g.blockNets) == 0 {
+		return verdictNone
+	}
+	if _, err := addr.ValueForProtocol(multiaddr.P_CIRCUIT); err == nil {
+		return verdictNone
+	}
+	ip, err := manet.ToIP(addr)
+	if err != nil {
+		return verdictNone
+	}
+	for _, n := range g.blockNets {
+		if n.Contains(ip) {
+			return verdictBlocked
+		}
+	}
+	for _, n := range g.allowNets {
+		if n.Contains(ip) {
+			return verdictAllowed
+		}
+	}
+	return verdictNone
+}
+
+// AllowsAddr reports whether an allowed network contains addr and no
+// blocked one does. A nil gater allows no address.
+func (g *AllowlistGater) AllowsAddr(addr multiaddr.Multiaddr) bool {
+	if g == nil {
+		return false
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.addrVerdict(addr) == verdictAllowed
+}
+
 // isAllowed checks if a peer is allowed (not blocked and passes allowlist if enabled)
 func (g *AllowlistGater) isAllowed(p peer.ID) bool {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
+	return g.allowedLocked(p, verdictNone)
+}
 
+// allowedLocked applies the peer ID rules, given the verdict of the
+// address rules
+func (g *AllowlistGater) allowedLocked(p peer.ID, v verdict) bool {
 	// Check blocklist first - always deny blocked peers
 	if _, blocked := g.blocklist[p]; blocked {
 		return false
 	}
+	switch v {
+	case verdictBlocked:
+		return false
+	case verdictAllowed:
+		return true
+	}
 
 	// If allowlist is enabled, peer must be in it
 	if g.allowlistEnabled {
@@ -132,40 +265,86 @@ func (g *AllowlistGater) isAllowed(p peer.ID) bool {
 	return true
 }
 
+// isAllowedAt checks a peer connecting from or dialed at addr. Private
+// addresses are refused unless an allowed network contains them, if
+// checkPrivate is set.
+func (g *AllowlistGater) isAllowedAt(p peer.ID, addr multiaddr.Multiaddr, checkPrivate bool) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	v := g.addrVerdict(addr)
+	if checkPrivate && v == verdictNone && security.IsBlockedMultiaddr(addr) {
+		return false
+	}
+	return g.allowedLocked(p, v)
+}
+
 // InterceptPeerDial is called when we're about to dial a peer
 func (g *AllowlistGater) InterceptPeerDial(p peer.ID) bool {
-	return g.isAllowed(p)
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if _, blocked := g.blocklist[p]; blocked {
+		return false
+	}
+	// An allowed network may admit the peer at one of its addresses,
+	// checked in InterceptAddrDial
+	if len(g.allowNets) > 0 {
+		return true
+	}
+	return g.allowedLocked(p, verdictNone)
 }
 
 // InterceptAddrDial is called when we're about to dial a specific address
 func (g *AllowlistGater) InterceptAddrDial(id peer.ID, addr multiaddr.Multiaddr) bool {
 	// Block dialing to private/reserved IPs (defense against eclipse attacks)
-	if security.IsBlockedMultiaddr(addr) {
-		return false
-	}
-	return g.isAllowed(id)
+	return g.isAllowedAt(id, addr, true)
 }
 
 // InterceptAccept is called when we're about to accept an inbound connection
 func (g *AllowlistGater) InterceptAccept(addrs network.ConnMultiaddrs) bool {
-	// Block connections from private/reserved IPs early (defense-in-depth)
-	// This saves resources by rejecting before security handshake
-	if addrs != nil && security.IsBlockedMultiaddr(addrs.RemoteMultiaddr()) {
+	if addrs == nil {
+		return true
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	addr := addrs.RemoteMultiaddr()
+	switch g.addrVerdict(addr) {
+	case verdictBlocked:
 		return false
+	case verdictAllowed:
+		return true
 	}
+	// Block connections from private/reserved IPs early (defense-in-depth)
+	// This saves resources by rejecting before security handshake.
 	// Can't check peer ID here, allow and check in InterceptSecured
-	return true
+	return !security.IsBlockedMultiaddr(addr)
 }
 
 // InterceptSecured is called after the security handshake completes
 func (g *AllowlistGater) InterceptSecured(dir network.Direction, id peer.ID, addrs network.ConnMultiaddrs) bool {
-	return g.isAllowed(id)
+	var addr multiaddr.Multiaddr
+	if addrs != nil {
+		addr = addrs.RemoteMultiaddr()
+	}
+	return g.isAllowedAt(id, addr, false)
 }
 
 // InterceptUpgraded is called after the connection is fully upgraded
 func (g *AllowlistGater) InterceptUpgraded(conn network.Conn) (bool, control.DisconnectReason) {
-	if g.isAllowed(conn.RemotePeer()) {
+	var allowed bool
+	if g.hasNetworks() {
+		allowed = g.isAllowedAt(conn.RemotePeer(), conn.RemoteMultiaddr(), false)
+	} else {
+		allowed = g.isAllowed(conn.RemotePeer())
+	}
+	if allowed {
 		return true, 0
 	}
 	return false, control.DisconnectReason(0)
 }
+
+// hasNetworks reports whether any network rule is set
+func (g *AllowlistGater) hasNetworks() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.allowNets)+len(g.blockNets) > 0
+}
@@ -1,6 +1,7 @@
 package p2p
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/libp2p/go-libp2p/core/network"
@@ -393,3 +394,124 @@ func TestAllowlistGater_InterceptAddrDial_CombinesIPAndPeerCheck(t *testing.T) {
 		t.Error("Should block: blocked peer + private IP")
 	}
 }
+
+func TestParsePeerRules(t *testing.T) {
+	const id = "12D3KooWDpJ7As7BWAwRMfu1VU2WCqNjvq387JEYKDBj4kx6nXTN"
+	rules, invalid := ParsePeerRules([]string{id, "10.20.0.0/16", "2001:db8::/32", "203.0.113.7", "not-a-peer"})
+	if len(rules.IDs) != 1 || rules.IDs[0].String() != id {
+		t.Errorf("IDs = %v", rules.IDs)
+	}
+	var nets []string
+	for _, n := range rules.Networks {
+		nets = append(nets, n.String())
+	}
+	if want := []string{"10.20.0.0/16", "2001:db8::/32", "203.0.113.7/32"}; !slices.Equal(nets, want) {
+		t.Errorf("Networks = %v, want %v", nets, want)
+	}
+	if len(invalid) != 1 || invalid[0] != "not-a-peer" {
+		t.Errorf("invalid = %v", invalid)
+	}
+	if rules.Empty() || !(PeerRules{}).Empty() {
+		t.Error("Empty is wrong")
+	}
+}
+
+func TestRulesGater_Order(t *testing.T) {
+	allowedPeer := peer.ID("12D3KooWAllowedPeer")
+	blockedPeer := peer.ID("12D3KooWBlockedPeer")
+	otherPeer := peer.ID("12D3KooWOtherPeer")
+
+	allow, _ := ParsePeerRules([]string{"10.20.0.0/16"})
+	allow.IDs = []peer.ID{allowedPeer}
+	block, _ := ParsePeerRules([]string{"10.20.99.0/24", "198.51.100.0/24"})
+	block.IDs = []peer.ID{blockedPeer}
+	gater := NewRulesGater(allow, block)
+	if !gater.Enabled() {
+		t.Fatal("network allow rules should enable the allowlist")
+	}
+
+	tests := []struct {
+		name string
+		peer peer.ID
+		addr string
+		want bool
+	}{
+		{"blocked peer in an allowed network", blockedPeer, "/ip4/10.20.1.1/tcp/4001", false},
+		{"blocked network inside an allowed one", otherPeer, "/ip4/10.20.99.5/tcp/4001", false},
+		{"allowed peer in a blocked network", allowedPeer, "/ip4/198.51.100.9/tcp/4001", false},
+		{"any peer in an allowed private network", otherPeer, "/ip4/10.20.1.1/udp/4001/quic-v1", true},
+		{"allowed peer at a public address", allowedPeer, "/ip4/8.8.8.8/tcp/4001", true},
+		{"other peer at a public address", otherPeer, "/ip4/8.8.8.8/tcp/4001", false},
+		{"allowed peer at another private address", allowedPeer, "/ip4/192.168.1.1/tcp/4001", false},
+		{"other peer through a relay in an allowed network", otherPeer, "/ip4/10.20.1.1/tcp/4001/p2p/12D3KooWDpJ7As7BWAwRMfu1VU2WCqNjvq387JEYKDBj4kx6nXTN/p2p-circuit", false},
+	}
+	for _, tt := range tests {
+		addr := mustMultiaddr(t, tt.addr)
+		if got := gater.InterceptAddrDial(tt.peer, addr); got != tt.want {
+			t.Errorf("%s: InterceptAddrDial = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Secured connections are checked the same way, without the private
+	// address check that dialing and accepting apply
+	if !gater.InterceptSecured(network.DirInbound, otherPeer, &mockConnMultiaddrs{remote: mustMultiaddr(t, "/ip4/10.20.1.1/tcp/4001")}) {
+		t.Error("InterceptSecured should allow any peer in an allowed network")
+	}
+	if gater.InterceptSecured(network.DirInbound, otherPeer, &mockConnMultiaddrs{remote: mustMultiaddr(t, "/ip4/8.8.8.8/tcp/4001")}) {
+		t.Error("InterceptSecured should refuse other peers outside allowed networks")
+	}
+	if gater.InterceptSecured(network.DirInbound, allowedPeer, &mockConnMultiaddrs{remote: mustMultiaddr(t, "/ip4/198.51.100.9/tcp/4001")}) {
+		t.Error("InterceptSecured should refuse a blocked network")
+	}
+}
+
+func TestRulesGater_Accept(t *testing.T) {
+	allow, _ := ParsePeerRules([]string{"10.20.0.0/16"})
+	block, _ := ParsePeerRules([]string{"198.51.100.0/24"})
+	gater := NewRulesGater(allow, block)
+
+	accept := func(addr string) bool {
+		return gater.InterceptAccept(&mockConnMultiaddrs{remote: mustMultiaddr(t, addr)})
+	}
+	if !accept("/ip4/10.20.3.4/tcp/4001") {
+		t.Error("an allowed private network should be accepted")
+	}
+	if accept("/ip4/10.30.3.4/tcp/4001") {
+		t.Error("other private addresses should still be refused")
+	}
+	if accept("/ip4/198.51.100.9/tcp/4001") {
+		t.Error("a blocked network should be refused before the handshake")
+	}
+	if !accept("/ip4/8.8.8.8/tcp/4001") {
+		t.Error("public addresses wait for the peer ID check")
+	}
+}
+
+func TestRulesGater_PeerDial(t *testing.T) {
+	blockedPeer := peer.ID("12D3KooWBlockedPeer")
+	otherPeer := peer.ID("12D3KooWOtherPeer")
+
+	// With only peer IDs allowed, other peers are refused before any address
+	idsOnly := NewRulesGater(PeerRules{IDs: []peer.ID{"12D3KooWAllowedPeer"}}, PeerRules{IDs: []peer.ID{blockedPeer}})
+	if idsOnly.InterceptPeerDial(otherPeer) || idsOnly.InterceptPeerDial(blockedPeer) {
+		t.Error("peer dial should refuse peers not on an ID-only allowlist")
+	}
+
+	// An allowed network may admit the peer at one of its addresses
+	allow, _ := ParsePeerRules([]string{"10.20.0.0/16"})
+	withNets := NewRulesGater(allow, PeerRules{IDs: []peer.ID{blockedPeer}})
+	if !withNets.InterceptPeerDial(otherPeer) {
+		t.Error("peer dial should defer to the address check with allowed networks")
+	}
+	if withNets.InterceptPeerDial(blockedPeer) {
+		t.Error("blocked peers are refused whatever the networks")
+	}
+
+	if !withNets.AllowsAddr(mustMultiaddr(t, "/ip4/10.20.0.9/tcp/4001")) || withNets.AllowsAddr(mustMultiaddr(t, "/ip4/10.21.0.9/tcp/4001")) {
+		t.Error("AllowsAddr should match allowed networks only")
+	}
+	var none *AllowlistGater
+	if none.AllowsAddr(mustMultiaddr(t, "/ip4/10.20.0.9/tcp/4001")) {
+		t.Error("a nil gater allows no address")
+	}
+}
@@ -0,0 +1,256 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"go.uber.org/zap"
+)
+
+// ProtocolHello is the capability handshake. The dialing side opens a stream
+// and writes its Capabilities as one JSON object; the listener replies with its
+// own and closes. JSON (unlike the fixed binary transfer frames) lets fields be
+// added without a protocol bump: unknown fields are ignored on both ends.
+const ProtocolHello = "/debswarm/hello/1.0.0"
+
+const (
+	// maxHelloSize bounds a hello message read from a peer.
+	maxHelloSize = 4096
+
+	helloTimeout = 5 * time.Second
+
+	// helloFreshness is how long a peer's reported free upload slots are
+	// trusted. Slots change with every transfer, so older reports are
+	// refreshed before they steer provider selection.
+	helloFreshness = 30 * time.Second
+)
+
+// Capabilities is what a peer reports about itself in the hello handshake.
+type Capabilities struct {
+	// Version is the debswarm version string (e.g. "1.9.0" or "dev").
+	Version string `json:"version"`
+	// Protocols lists the transfer and probe protocol IDs the peer serves.
+	Protocols []string `json:"protocols"`
+	// FreeUploadSlots is how many more concurrent uploads the peer accepts.
+	FreeUploadSlots int `json:"free_upload_slots"`
+	// MaxUploadRate is the peer's global upload cap in bytes/s (0 = none).
+	MaxUploadRate int64 `json:"max_upload_rate"`
+}
+
+// PeerCapabilities is a peer's last hello and when it was received.
+type PeerCapabilities struct {
+	Capabilities
+	Received time.Time
+}
+
+// transferVersion returns the highest major version of the transfer protocol
+// the peer advertises, or 0 when unknown (no hello, e.g. an older debswarm).
+func (c *PeerCapabilities) transferVersion() int {
+	if c == nil {
+		return 0
+	}
+	best := 0
+	for _, p := range c.Protocols {
+		if !strings.HasPrefix(p, "/debswarm/transfer") {
+			continue
+		}
+		idx := strings.LastIndex(p, "/")
+		major, _, _ := strings.Cut(p[idx+1:], ".")
+		if v, err := strconv.Atoi(major); err == nil && v > best {
+			best = v
+		}
+	}
+	return best
+}
+
+// helloState holds the capabilities learned from peers.
+type helloState struct {
+	mu    sync.RWMutex
+	peers map[peer.ID]*PeerCapabilities
+}
+
+// localCapabilities describes this node for the hello handshake.
+func (n *Node) localCapabilities() Capabilities {
+	n.uploadsMu.Lock()
+	free := n.uploadLimit() - n.activeUploads
+	n.uploadsMu.Unlock()
+	if free < 0 {
+		free = 0
+	}
+	protocols := []string{ProtocolTransfer, ProtocolTransferRange, ProtocolTransferV2}
+	if n.compression != nil {
+		protocols = append(protocols, ProtocolTransferCompressed)
+	}
+	protocols = append(protocols, ProtocolProbe)
+	return Capabilities{
+		Version:         n.version,
+		Protocols:       protocols,
+		FreeUploadSlots: free,
+		MaxUploadRate:   n.uploadLimiter.Rate(),
+	}
+}
+
+// startHello registers the hello handler and greets every peer we dial,
+// probing its bandwidth afterwards when probe_on_connect is set.
+func (n *Node) startHello() {
+	n.host.SetStreamHandler(protocol.ID(ProtocolHello), n.handleHelloStream)
+	n.host.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(_ network.Network, c network.Conn) {
+			// Only the dialer greets; the listener learns the dialer's
+			// capabilities from the request, so one stream serves both.
+			if c.Stat().Direction != network.DirOutbound {
+				return
+			}
+			go func(pid peer.ID) {
+				caps, err := n.Hello(n.ctx, pid)
+				if err != nil {
+					n.logger.Debug("Hello handshake failed",
+						zap.String("peer", pid.String()), zap.Error(err))
+					return
+				}
+				n.probeNewPeer(pid, caps)
+			}(c.RemotePeer())
+		},
+		DisconnectedF: func(_ network.Network, c network.Conn) {
+			if n.host.Network().Connectedness(c.RemotePeer()) != network.Connected {
+				n.hello.mu.Lock()
+				delete(n.hello.peers, c.RemotePeer())
+				n.hello.mu.Unlock()
+			}
+		},
+	})
+}
+
+func (n *Node) handleHelloStream(s network.Stream) {
+	defer s.Close()
+	_ = s.SetDeadline(time.Now().Add(helloTimeout))
+
+	var theirs Capabilities
+	if err := json.NewDecoder(io.LimitReader(s, maxHelloSize)).Decode(&theirs); err != nil {
+		_ = s.Reset()
+		return
+	}
+	n.recordCapabilities(s.Conn().RemotePeer(), theirs)
+	_ = json.NewEncoder(s).Encode(n.localCapabilities())
+}
+
+// Hello exchanges capabilities with a connected peer and returns theirs.
+// A peer running a debswarm version without the handshake returns an error
+// and is simply treated as having unknown capabilities.
+func (n *Node) Hello(ctx context.Context, pid peer.ID) (*PeerCapabilities, error) {
+	ctx, cancel := context.WithTimeout(ctx, helloTimeout)
+	defer cancel()
+	// The handshake is a few hundred bytes, fine over a relayed connection.
+	ctx = network.WithAllowLimitedConn(ctx, "debswarm-hello")
+
+	s, err := n.host.NewStream(ctx, pid, protocol.ID(ProtocolHello))
+	if err != nil {
+		return nil, fmt.Errorf("open hello stream: %w", err)
+	}
+	defer s.Close()
+	_ = s.SetDeadline(time.Now().Add(helloTimeout))
+
+	if err := json.NewEncoder(s).Encode(n.localCapabilities()); err != nil {
+		_ = s.Reset()
+		return nil, fmt.Errorf("send hello: %w", err)
+	}
+	if err := s.CloseWrite(); err != nil {
+		_ = s.Reset()
+		return nil, fmt.Errorf("send hello: %w", err)
+	}
+	var theirs Capabilities
+	if err := json.NewDecoder(io.LimitReader(s, maxHelloSize)).Decode(&theirs); err != nil {
+		_ = s.Reset()
+		return nil, fmt.Errorf("read hello: %w", err)
+	}
+	return n.recordCapabilities(pid, theirs), nil
+}
+
+func (n *Node) recordCapabilities(pid peer.ID, caps Capabilities) *PeerCapabilities {
+	pc := &PeerCapabilities{Capabilities: caps, Received: time.Now()}
+	n.hello.mu.Lock()
+	if n.hello.peers == nil {
+		n.hello.peers = make(map[peer.ID]*PeerCapabilities)
+	}
+	n.hello.peers[pid] = pc
+	n.hello.mu.Unlock()
+	return pc
+}
+
+// PeerCapabilities returns the last capabilities a peer reported, or nil if
+// it has not completed a hello handshake.
+func (n *Node) PeerCapabilities(pid peer.ID) *PeerCapabilities {
+	n.hello.mu.RLock()
+	defer n.hello.mu.RUnlock()
+	return n.hello.peers[pid]
+}
+
+// PeerVersions counts the connected peers by the debswarm version they
+// reported in the hello handshake. Peers that have not completed one are
+// counted under "unknown".
+func (n *Node) PeerVersions() map[string]int {
+	connected := n.host.Network().Peers()
+	counts := make(map[string]int)
+	n.hello.mu.RLock()
+	defer n.hello.mu.RUnlock()
+	for _, pid := range connected {
+		v := "unknown"
+		if pc := n.hello.peers[pid]; pc != nil && pc.Version != "" {
+			v = pc.Version
+		}
+		counts[v]++
+	}
+	return counts
+}
+
+// refreshCapabilities re-runs the handshake with connected providers whose
+// report is older than helloFreshness, in parallel and bounded by one
+// helloTimeout, so free upload slots are current when selection uses them.
+func (n *Node) refreshCapabilities(ctx context.Context, providers []peer.AddrInfo) {
+	var wg sync.WaitGroup
+	for _, p := range providers {
+		if n.host.Network().Connectedness(p.ID) != network.Connected {
+			continue
+		}
+		if pc := n.PeerCapabilities(p.ID); pc != nil && time.Since(pc.Received) < helloFreshness {
+			continue
+		}
+		wg.Add(1)
+		go func(pid peer.ID) {
+			defer wg.Done()
+			_, _ = n.Hello(ctx, pid)
+		}(p.ID)
+	}
+	wg.Wait()
+}
+
+// applyCapabilities drops providers that freshly reported no free upload
+// slots — they would refuse the transfer anyway — and moves providers that
+// speak a newer transfer protocol ahead of older ones, keeping the ranked
+// order otherwise.
+func (n *Node) applyCapabilities(providers []peer.AddrInfo) []peer.AddrInfo {
+	out := make([]peer.AddrInfo, 0, len(providers))
+	versions := make(map[peer.ID]int, len(providers))
+	for _, p := range providers {
+		pc := n.PeerCapabilities(p.ID)
+		if pc != nil && pc.FreeUploadSlots <= 0 && time.Since(pc.Received) < helloFreshness {
+			continue
+		}
+		versions[p.ID] = pc.transferVersion()
+		out = append(out, p)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return versions[out[i].ID] > versions[out[j].ID]
+	})
+	return out
+}
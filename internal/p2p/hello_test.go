@@ -0,0 +1,104 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestHello_ExchangedOnConnect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	logger := newTestLogger()
+
+	cfg1 := newTestConfig(t)
+	cfg1.Version = "1.2.3"
+	cfg1.MaxUploadRate = 1 << 20
+	node1, err := New(ctx, cfg1, logger)
+	if err != nil {
+		t.Fatalf("New node1 failed: %v", err)
+	}
+	defer node1.Close()
+
+	cfg2 := newTestConfig(t)
+	cfg2.Version = "4.5.6"
+	cfg2.MaxConcurrentUploads = 7
+	node2, err := New(ctx, cfg2, logger)
+	if err != nil {
+		t.Fatalf("New node2 failed: %v", err)
+	}
+	defer node2.Close()
+
+	if err := node2.host.Connect(ctx, peer.AddrInfo{ID: node1.PeerID(), Addrs: node1.Addrs()}); err != nil {
+		t.Fatalf("Failed to connect nodes: %v", err)
+	}
+
+	// node2 dialed, so it greets; both sides learn the other's capabilities.
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if node1.PeerCapabilities(node2.PeerID()) != nil && node2.PeerCapabilities(node1.PeerID()) != nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	got1 := node2.PeerCapabilities(node1.PeerID())
+	if got1 == nil {
+		t.Fatal("node2 did not learn node1's capabilities")
+	}
+	if got1.Version != "1.2.3" || got1.MaxUploadRate != 1<<20 {
+		t.Errorf("node1 caps = %+v", got1.Capabilities)
+	}
+	if got1.transferVersion() != 2 {
+		t.Errorf("transferVersion = %d, want 2", got1.transferVersion())
+	}
+
+	got2 := node1.PeerCapabilities(node2.PeerID())
+	if got2 == nil {
+		t.Fatal("node1 did not learn node2's capabilities")
+	}
+	if got2.Version != "4.5.6" || got2.FreeUploadSlots != 7 {
+		t.Errorf("node2 caps = %+v", got2.Capabilities)
+	}
+
+	if v := node1.PeerVersions(); len(v) != 1 || v["4.5.6"] != 1 {
+		t.Errorf("node1 PeerVersions = %v", v)
+	}
+}
+
+func TestApplyCapabilities(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	node, err := New(ctx, newTestConfig(t), newTestLogger())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer node.Close()
+
+	unknown := peer.ID("unknown-peer")
+	busy := peer.ID("busy-peer")
+	staleBusy := peer.ID("stale-busy-peer")
+	v2 := peer.ID("v2-peer")
+	v1 := peer.ID("v1-peer")
+
+	node.recordCapabilities(busy, Capabilities{Protocols: []string{ProtocolTransfer}, FreeUploadSlots: 0})
+	node.recordCapabilities(staleBusy, Capabilities{Protocols: []string{ProtocolTransfer}, FreeUploadSlots: 0})
+	node.hello.peers[staleBusy].Received = time.Now().Add(-2 * helloFreshness)
+	node.recordCapabilities(v2, Capabilities{Protocols: []string{ProtocolTransfer, "/debswarm/transfer/2.0.0"}, FreeUploadSlots: 3})
+	node.recordCapabilities(v1, Capabilities{Protocols: []string{ProtocolTransfer}, FreeUploadSlots: 3})
+
+	in := []peer.AddrInfo{{ID: unknown}, {ID: busy}, {ID: v1}, {ID: staleBusy}, {ID: v2}}
+	out := node.applyCapabilities(in)
+
+	want := []peer.ID{v2, v1, staleBusy, unknown}
+	if len(out) != len(want) {
+		t.Fatalf("got %d providers, want %d: %v", len(out), len(want), out)
+	}
+	for i, id := range want {
+		if out[i].ID != id {
+			t.Errorf("position %d = %s, want %s", i, out[i].ID, id)
+		}
+	}
+}
@@ -2,11 +2,18 @@
 package p2p
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -112,6 +119,115 @@ func SaveIdentity(privKey crypto.PrivKey, path string) error {
 	return nil
 }
 
+// Encrypted identity exports are PEM blocks holding the marshaled key
+// sealed with AES-256-GCM under a key derived from a passphrase.
+const (
+	exportPEMType       = "DEBSWARM ENCRYPTED IDENTITY"
+	exportKDF           = "pbkdf2-sha256"
+	exportIterations    = 600000
+	exportMaxIterations = 10 * exportIterations // cap on an imported backup's work factor
+	exportMinPassLen    = 8
+	exportKeyLen        = 32
+	exportSaltLen       = 16
+	exportPeerIDField   = "Peer-Id"
+)
+
+// ErrBadPassphrase is returned when an export cannot be decrypted with the
+// given passphrase.
+var ErrBadPassphrase = errors.New("wrong passphrase or corrupted export")
+
+// ExportIdentity returns privKey encrypted with passphrase, as a PEM block
+// that also names the peer ID in clear so a backup can be identified without
+// the passphrase.
+func ExportIdentity(privKey crypto.PrivKey, passphrase []byte) ([]byte, error) {
+	if len(passphrase) < exportMinPassLen {
+		return nil, fmt.Errorf("passphrase must be at least %d characters", exportMinPassLen)
+	}
+	keyBytes, err := crypto.MarshalPrivateKey(privKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal identity key: %w", err)
+	}
+	salt := make([]byte, exportSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := exportCipher(passphrase, salt, exportIterations)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	peerID := IdentityFingerprint(privKey)
+	block := &pem.Block{
+		Type: exportPEMType,
+		Headers: map[string]string{
+			"KDF":             exportKDF,
+			"Iterations":      strconv.Itoa(exportIterations),
+			"Salt":            hex.EncodeToString(salt),
+			"Nonce":           hex.EncodeToString(nonce),
+			exportPeerIDField: peerID,
+		},
+		// The peer ID is authenticated, so an edited header is detected
+		Bytes: aead.Seal(nil, nonce, keyBytes, []byte(peerID)),
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// ImportIdentity decrypts an export made by ExportIdentity.
+func ImportIdentity(data, passphrase []byte) (crypto.PrivKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != exportPEMType {
+		return nil, fmt.Errorf("not a debswarm identity export")
+	}
+	if block.Headers["KDF"] != exportKDF {
+		return nil, fmt.Errorf("unsupported key derivation %q", block.Headers["KDF"])
+	}
+	iterations, err := strconv.Atoi(block.Headers["Iterations"])
+	if err != nil || iterations < 1 {
+		return nil, fmt.Errorf("invalid identity export: bad iteration count")
+	}
+	if iterations > exportMaxIterations {
+		return nil, fmt.Errorf("invalid identity export: iteration count %d exceeds the maximum of %d", iterations, exportMaxIterations)
+	}
+	salt, err1 := hex.DecodeString(block.Headers["Salt"])
+	nonce, err2 := hex.DecodeString(block.Headers["Nonce"])
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("invalid identity export: bad salt or nonce")
+	}
+	aead, err := exportCipher(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid identity export: bad nonce")
+	}
+	peerID := block.Headers[exportPeerIDField]
+	keyBytes, err := aead.Open(nil, nonce, block.Bytes, []byte(peerID))
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	privKey, err := crypto.UnmarshalPrivateKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity export: bad key data: %w", err)
+	}
+	return privKey, nil
+}
+
+// exportCipher derives the AES-256-GCM cipher for an export.
+func exportCipher(passphrase, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, string(passphrase), salt, iterations, exportKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 // IdentityFingerprint returns a safe-to-log fingerprint of the identity
 func IdentityFingerprint(privKey crypto.PrivKey) string {
 	pubKey := privKey.GetPublic()
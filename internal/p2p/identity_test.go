@@ -1,8 +1,11 @@
 package p2p
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/libp2p/go-libp2p/core/crypto"
@@ -236,3 +239,52 @@ func TestTrimWhitespace(t *testing.T) {
 		}
 	}
 }
+
+func TestExportImportIdentity(t *testing.T) {
+	key, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	passphrase := []byte("correct horse battery")
+
+	data, err := ExportIdentity(key, passphrase)
+	if err != nil {
+		t.Fatalf("ExportIdentity failed: %v", err)
+	}
+	if !strings.Contains(string(data), IdentityFingerprint(key)) {
+		t.Error("export does not name the peer ID")
+	}
+
+	imported, err := ImportIdentity(data, passphrase)
+	if err != nil {
+		t.Fatalf("ImportIdentity failed: %v", err)
+	}
+	if !imported.Equals(key) {
+		t.Error("imported key differs from the exported one")
+	}
+
+	if _, err := ImportIdentity(data, []byte("wrong passphrase")); !errors.Is(err, ErrBadPassphrase) {
+		t.Errorf("wrong passphrase: err = %v, want ErrBadPassphrase", err)
+	}
+
+	// The clear-text peer ID is bound to the ciphertext
+	other, _ := GenerateIdentity()
+	forged := strings.Replace(string(data), IdentityFingerprint(key), IdentityFingerprint(other), 1)
+	if _, err := ImportIdentity([]byte(forged), passphrase); !errors.Is(err, ErrBadPassphrase) {
+		t.Errorf("edited peer ID: err = %v, want ErrBadPassphrase", err)
+	}
+
+	if _, err := ExportIdentity(key, []byte("short")); err == nil {
+		t.Error("short passphrase accepted")
+	}
+	if _, err := ImportIdentity([]byte("not a backup"), passphrase); err == nil {
+		t.Error("garbage imported")
+	}
+
+	// An inflated iteration count is refused before any key derivation
+	inflated := strings.Replace(string(data), "Iterations: "+strconv.Itoa(exportIterations),
+		"Iterations: "+strconv.Itoa(exportMaxIterations+1), 1)
+	if _, err := ImportIdentity([]byte(inflated), passphrase); err == nil || !strings.Contains(err.Error(), "iteration count") {
+		t.Errorf("inflated iteration count: err = %v", err)
+	}
+}
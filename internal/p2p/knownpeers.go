@@ -0,0 +1,233 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"go.uber.org/zap"
+)
+
+const (
+	// knownPeersFile holds the peers remembered between runs, in DataDir
+	knownPeersFile = "peers.json"
+	// maxKnownPeers caps the peers remembered, most recently seen first
+	maxKnownPeers = 64
+	// maxKnownPeerAddrs caps the addresses remembered per peer
+	maxKnownPeerAddrs = 8
+	// knownPeerMaxAge is how long a peer not seen since is remembered
+	knownPeerMaxAge = 14 * 24 * time.Hour
+	// knownPeersSaveInterval is how often connected peers are saved, so a
+	// crash loses little
+	knownPeersSaveInterval = 10 * time.Minute
+)
+
+// knownPeer is a peer the node was connected to, with the addresses it had
+type knownPeer struct {
+	ID       string    `json:"id"`
+	Addrs    []string  `json:"addrs"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// loadKnownPeers reads the peers saved at path. A missing file is not an
+// error, and peers not seen for knownPeerMaxAge are dropped.
+func loadKnownPeers(path string, now time.Time) ([]knownPeer, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is in the node's data directory
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var peers []knownPeer
+	if err := json.Unmarshal(data, &peers); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return mergeKnownPeers(nil, peers, now), nil
+}
+
+// saveKnownPeers writes peers to path atomically (temp file + rename)
+func saveKnownPeers(path string, peers []knownPeer) error {
+	data, err := json.MarshalIndent(peers, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".peers-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// mergeKnownPeers combines saved peers with the ones seen now, keeping the
+// newest entry per peer, dropping those not seen for knownPeerMaxAge and
+// keeping the maxKnownPeers most recently seen.
+func mergeKnownPeers(saved, seen []knownPeer, now time.Time) []knownPeer {
+	byID := make(map[string]knownPeer, len(saved)+len(seen))
+	for _, p := range slices.Concat(saved, seen) {
+		if len(p.Addrs) == 0 || now.Sub(p.LastSeen) > knownPeerMaxAge {
+			continue
+		}
+		if old, ok := byID[p.ID]; !ok || p.LastSeen.After(old.LastSeen) {
+			byID[p.ID] = p
+		}
+	}
+	out := make([]knownPeer, 0, len(byID))
+	for _, p := range byID {
+		out = append(out, p)
+	}
+	slices.SortFunc(out, func(a, b knownPeer) int {
+		if c := b.LastSeen.Compare(a.LastSeen); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	if len(out) > maxKnownPeers {
+		out = out[:maxKnownPeers]
+	}
+	return out
+}
+
+// knownPeerInfos turns saved peers into dialable peers, skipping entries
+// that no longer parse
+func knownPeerInfos(peers []knownPeer) []peer.AddrInfo {
+	infos := make([]peer.AddrInfo, 0, len(peers))
+	for _, p := range peers {
+		id, err := peer.Decode(p.ID)
+		if err != nil {
+			continue
+		}
+		info := peer.AddrInfo{ID: id}
+		for _, a := range p.Addrs {
+			if ma, err := multiaddr.NewMultiaddr(a); err == nil {
+				info.Addrs = append(info.Addrs, ma)
+			}
+		}
+		if len(info.Addrs) > 0 {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// connectedKnownPeers returns the peers connected now with the addresses
+// they are reachable at. Relayed addresses are left out: the relay may be
+// gone by the next start, and the DHT finds it again anyway.
+func (n *Node) connectedKnownPeers(now time.Time) []knownPeer {
+	var out []knownPeer
+	for _, id := range n.host.Network().Peers() {
+		var addrs []string
+		for _, a := range n.host.Peerstore().Addrs(id) {
+			if isCircuitAddr(a) || len(addrs) == maxKnownPeerAddrs {
+				continue
+			}
+			addrs = append(addrs, a.String())
+		}
+		if len(addrs) > 0 {
+			out = append(out, knownPeer{ID: id.String(), Addrs: addrs, LastSeen: now})
+		}
+	}
+	return out
+}
+
+// knownPeersPath returns where the node remembers peers, or "" when it
+// doesn't
+func (n *Node) knownPeersPath() string {
+	if !n.persistPeers || n.dataDir == "" {
+		return ""
+	}
+	return filepath.Join(n.dataDir, knownPeersFile)
+}
+
+// loadKnownPeerInfos returns the peers remembered from previous runs, to
+// bootstrap from along with the configured peers
+func (n *Node) loadKnownPeerInfos() []peer.AddrInfo {
+	path := n.knownPeersPath()
+	if path == "" {
+		return nil
+	}
+	peers, err := loadKnownPeers(path, time.Now())
+	if err != nil {
+		n.logger.Warn("Failed to load known peers", zap.Error(err))
+		return nil
+	}
+	n.knownPeersMu.Lock()
+	n.knownPeers = peers
+	n.knownPeersMu.Unlock()
+	return knownPeerInfos(peers)
+}
+
+// saveConnectedPeers adds the peers connected now to the remembered ones
+// and writes them out
+func (n *Node) saveConnectedPeers() {
+	path := n.knownPeersPath()
+	if path == "" {
+		return
+	}
+	n.knownPeersMu.Lock()
+	defer n.knownPeersMu.Unlock()
+	now := time.Now()
+	n.knownPeers = mergeKnownPeers(n.knownPeers, n.connectedKnownPeers(now), now)
+	if err := saveKnownPeers(path, n.knownPeers); err != nil {
+		n.logger.Warn("Failed to save known peers", zap.Error(err))
+	}
+}
+
+// persistKnownPeers saves the connected peers every knownPeersSaveInterval
+// until ctx is done; Close saves them a last time
+func (n *Node) persistKnownPeers(ctx context.Context) {
+	ticker := time.NewTicker(knownPeersSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.saveConnectedPeers()
+		}
+	}
+}
+
+// mergeBootstrapInfos combines configured and remembered bootstrap peers,
+// configured ones first, merging the addresses of peers in both
+func mergeBootstrapInfos(configured, known []peer.AddrInfo) []peer.AddrInfo {
+	out := slices.Clone(configured)
+	for _, k := range known {
+		i := slices.IndexFunc(out, func(c peer.AddrInfo) bool { return c.ID == k.ID })
+		if i < 0 {
+			out = append(out, k)
+			continue
+		}
+		out[i].Addrs = slices.Clone(out[i].Addrs)
+		for _, a := range k.Addrs {
+			if !multiaddr.Contains(out[i].Addrs, a) {
+				out[i].Addrs = append(out[i].Addrs, a)
+			}
+		}
+	}
+	return out
+}
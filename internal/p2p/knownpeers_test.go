@@ -0,0 +1,179 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestMergeKnownPeers(t *testing.T) {
+	now := time.Now()
+	saved := []knownPeer{
+		{ID: "a", Addrs: []string{"/ip4/192.0.2.1/tcp/4001"}, LastSeen: now.Add(-time.Hour)},
+		{ID: "b", Addrs: []string{"/ip4/192.0.2.2/tcp/4001"}, LastSeen: now.Add(-2 * time.Hour)},
+		{ID: "old", Addrs: []string{"/ip4/192.0.2.3/tcp/4001"}, LastSeen: now.Add(-knownPeerMaxAge - time.Hour)},
+		{ID: "noaddrs", LastSeen: now},
+	}
+	seen := []knownPeer{
+		{ID: "b", Addrs: []string{"/ip4/192.0.2.20/tcp/4001"}, LastSeen: now},
+	}
+	got := mergeKnownPeers(saved, seen, now)
+	if len(got) != 2 {
+		t.Fatalf("merged %d peers, want 2: %+v", len(got), got)
+	}
+	if got[0].ID != "b" || got[0].Addrs[0] != "/ip4/192.0.2.20/tcp/4001" {
+		t.Errorf("first = %+v, want b at its new address", got[0])
+	}
+	if got[1].ID != "a" {
+		t.Errorf("second = %+v, want a", got[1])
+	}
+
+	var many []knownPeer
+	for i := 0; i < maxKnownPeers+10; i++ {
+		many = append(many, knownPeer{ID: fmt.Sprintf("p%03d", i), Addrs: []string{"/ip4/192.0.2.1/tcp/4001"},
+			LastSeen: now.Add(-time.Duration(i) * time.Minute)})
+	}
+	got = mergeKnownPeers(nil, many, now)
+	if len(got) != maxKnownPeers || got[0].ID != "p000" || got[len(got)-1].ID != fmt.Sprintf("p%03d", maxKnownPeers-1) {
+		t.Errorf("cap kept %d peers, %s to %s", len(got), got[0].ID, got[len(got)-1].ID)
+	}
+}
+
+func TestKnownPeers_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node", knownPeersFile)
+	if peers, err := loadKnownPeers(path, time.Now()); err != nil || peers != nil {
+		t.Fatalf("missing file: %v, %v", peers, err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	peers := []knownPeer{{ID: testBootID1, Addrs: []string{"/ip4/192.0.2.1/tcp/4001", "/ip4/192.0.2.1/udp/4001/quic-v1"}, LastSeen: now}}
+	if err := saveKnownPeers(path, peers); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadKnownPeers(path, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 1 || loaded[0].ID != testBootID1 || len(loaded[0].Addrs) != 2 || !loaded[0].LastSeen.Equal(now) {
+		t.Errorf("loaded = %+v", loaded)
+	}
+
+	infos := knownPeerInfos(append(loaded, knownPeer{ID: "bad", Addrs: []string{"/ip4/192.0.2.9/tcp/4001"}}))
+	if len(infos) != 1 || infos[0].ID.String() != testBootID1 || len(infos[0].Addrs) != 2 {
+		t.Errorf("infos = %v", infos)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadKnownPeers(path, now); err == nil {
+		t.Error("corrupt file should fail to load")
+	}
+}
+
+func TestMergeBootstrapInfos(t *testing.T) {
+	id1, _ := peer.Decode(testBootID1)
+	id2, _ := peer.Decode(testBootID2)
+	a := multiaddr.StringCast("/ip4/192.0.2.1/tcp/4001")
+	b := multiaddr.StringCast("/ip4/192.0.2.2/tcp/4001")
+	configured := []peer.AddrInfo{{ID: id1, Addrs: []multiaddr.Multiaddr{a}}}
+	known := []peer.AddrInfo{{ID: id1, Addrs: []multiaddr.Multiaddr{a, b}}, {ID: id2, Addrs: []multiaddr.Multiaddr{b}}}
+
+	got := mergeBootstrapInfos(configured, known)
+	if len(got) != 2 || got[0].ID != id1 || len(got[0].Addrs) != 2 || got[1].ID != id2 {
+		t.Errorf("merged = %v", got)
+	}
+	if len(configured[0].Addrs) != 1 {
+		t.Error("configured peers were modified")
+	}
+}
+
+func TestNode_BootstrapFromKnownPeers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	logger := newTestLogger()
+
+	seedCfg := newTestConfig(t)
+	seed, err := New(ctx, seedCfg, logger)
+	if err != nil {
+		t.Fatalf("New seed: %v", err)
+	}
+	defer seed.Close()
+	seedAddr := fmt.Sprintf("%s/p2p/%s", seed.Addrs()[0], seed.PeerID())
+
+	// First run: bootstrap from the seed and remember it on close
+	cfg := newTestConfig(t)
+	cfg.PersistPeers = true
+	cfg.BootstrapPeers = []string{seedAddr}
+	node, err := New(ctx, cfg, logger)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	node.WaitForBootstrap()
+	if node.ConnectedPeers() == 0 {
+		t.Fatal("not connected to the seed")
+	}
+	_ = node.Close()
+	if _, err := os.Stat(filepath.Join(cfg.DataDir, knownPeersFile)); err != nil {
+		t.Fatalf("known peers not saved: %v", err)
+	}
+
+	// Second run: the configured bootstrap peer is gone, the seed is not
+	cfg.BootstrapPeers = []string{"/ip4/127.0.0.1/tcp/1/p2p/" + testBootID1}
+	cfg.BootstrapMinPeers = 1
+	node, err = New(ctx, cfg, logger)
+	if err != nil {
+		t.Fatalf("New again: %v", err)
+	}
+	defer node.Close()
+	node.WaitForBootstrap()
+	connected := false
+	for _, p := range node.host.Network().Peers() {
+		if p == seed.PeerID() {
+			connected = true
+		}
+	}
+	if !connected {
+		t.Error("did not bootstrap from the remembered seed")
+	}
+}
+
+func TestConnectBootstrapPeers_EarlyExit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	logger := newTestLogger()
+
+	var infos []peer.AddrInfo
+	for i := 0; i < 2; i++ {
+		n, err := New(ctx, newTestConfig(t), logger)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		defer n.Close()
+		infos = append(infos, peer.AddrInfo{ID: n.PeerID(), Addrs: n.Addrs()})
+	}
+
+	node, err := New(ctx, newTestConfig(t), logger)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer node.Close()
+
+	if got := node.connectBootstrapPeers(ctx, infos[:1], 1); got != 1 {
+		t.Errorf("connected = %d, want 1", got)
+	}
+	// The peer connected already counts towards the target, so the call
+	// returns without waiting for the second dial
+	if got := node.connectBootstrapPeers(ctx, infos, 1); got != 1 {
+		t.Errorf("connected = %d, want 1 (early exit)", got)
+	}
+	if got := node.connectBootstrapPeers(ctx, infos, 0); got != 2 {
+		t.Errorf("connected = %d, want 2 when waiting for all", got)
+	}
+}
@@ -0,0 +1,127 @@
+package p2p
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// Listen transports
+const (
+	TransportQUIC = "quic"
+	TransportTCP  = "tcp"
+)
+
+// ListenConfig is one listener of a node: a transport on all addresses, on
+// the addresses of a network interface, or on a single address. Listeners
+// replace the default of both transports on every address, so a transport
+// without one is not listened on at all.
+type ListenConfig struct {
+	Transport string // TransportQUIC or TransportTCP
+	Interface string // listen on this interface's addresses, e.g. "enp0s31f6"
+	Address   string // listen on this IP address; "0.0.0.0" or "::" for all of a family
+	Port      int    // 0 = Config.ListenPort
+}
+
+// interfaceAddrsByName lists the addresses of a network interface; replaced
+// in tests
+var interfaceAddrsByName = func(name string) ([]net.Addr, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return iface.Addrs()
+}
+
+// listenMultiaddrs returns the addresses the node listens on. Without
+// listeners both transports listen on every IPv4 and IPv6 address, the
+// preferred one first. Interfaces are resolved to the addresses they have now; link-local
+// IPv6 addresses are skipped, as they cannot be listened on without a zone.
+func listenMultiaddrs(cfg *Config) ([]multiaddr.Multiaddr, error) {
+	listeners := cfg.Listen
+	if len(listeners) == 0 {
+		for _, transport := range []string{TransportQUIC, TransportTCP} {
+			listeners = append(listeners,
+				ListenConfig{Transport: transport, Address: "0.0.0.0"},
+				ListenConfig{Transport: transport, Address: "::"})
+		}
+	}
+
+	// QUIC first for preference, unless TCP is preferred
+	first := TransportQUIC
+	if !cfg.PreferQUIC {
+		first = TransportTCP
+	}
+	var preferred, rest []multiaddr.Multiaddr
+	for _, l := range listeners {
+		addrs, err := l.multiaddrs(cfg.ListenPort)
+		if err != nil {
+			return nil, err
+		}
+		if l.Transport == first {
+			preferred = append(preferred, addrs...)
+		} else {
+			rest = append(rest, addrs...)
+		}
+	}
+	return append(preferred, rest...), nil
+}
+
+// multiaddrs returns the addresses of a listener
+func (l ListenConfig) multiaddrs(defaultPort int) ([]multiaddr.Multiaddr, error) {
+	port := l.Port
+	if port == 0 {
+		port = defaultPort
+	}
+
+	var ips []net.IP
+	switch {
+	case l.Interface != "":
+		ifaceAddrs, err := interfaceAddrsByName(l.Interface)
+		if err != nil {
+			return nil, fmt.Errorf("listen on %s interface %s: %w", l.Transport, l.Interface, err)
+		}
+		for _, a := range ifaceAddrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok || (ipNet.IP.To4() == nil && ipNet.IP.IsLinkLocalUnicast()) {
+				continue
+			}
+			ips = append(ips, ipNet.IP)
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("listen on %s interface %s: interface has no usable address", l.Transport, l.Interface)
+		}
+	case l.Address != "":
+		ip := net.ParseIP(l.Address)
+		if ip == nil {
+			return nil, fmt.Errorf("listen on %s: invalid address %q", l.Transport, l.Address)
+		}
+		ips = append(ips, ip)
+	default:
+		ips = append(ips, net.IPv4zero, net.IPv6unspecified)
+	}
+
+	addrs := make([]multiaddr.Multiaddr, 0, len(ips))
+	for _, ip := range ips {
+		var addr net.Addr
+		switch l.Transport {
+		case TransportQUIC:
+			addr = &net.UDPAddr{IP: ip, Port: port}
+		case TransportTCP:
+			addr = &net.TCPAddr{IP: ip, Port: port}
+		default:
+			return nil, fmt.Errorf("unknown listen transport %q", l.Transport)
+		}
+		ma, err := manet.FromNetAddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("listen on %s %s: %w", l.Transport, ip, err)
+		}
+		if l.Transport == TransportQUIC {
+			ma = ma.Encapsulate(multiaddr.StringCast("/quic-v1"))
+		}
+		addrs = append(addrs, ma)
+	}
+	return addrs, nil
+}
@@ -0,0 +1,86 @@
+package p2p
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/multiformats/go-multiaddr"
+)
+
+func multiaddrStrings(addrs []multiaddr.Multiaddr) []string {
+	result := make([]string, len(addrs))
+	for i, a := range addrs {
+		result[i] = a.String()
+	}
+	return result
+}
+
+func TestListenMultiaddrs_Default(t *testing.T) {
+	addrs, err := listenMultiaddrs(&Config{ListenPort: 4001, PreferQUIC: true})
+	if err != nil {
+		t.Fatalf("listenMultiaddrs: %v", err)
+	}
+	want := []string{
+		"/ip4/0.0.0.0/udp/4001/quic-v1",
+		"/ip6/::/udp/4001/quic-v1",
+		"/ip4/0.0.0.0/tcp/4001",
+		"/ip6/::/tcp/4001",
+	}
+	if got := multiaddrStrings(addrs); !reflect.DeepEqual(got, want) {
+		t.Errorf("addrs = %v, want %v", got, want)
+	}
+
+	addrs, _ = listenMultiaddrs(&Config{ListenPort: 4001})
+	if got := multiaddrStrings(addrs); got[0] != "/ip4/0.0.0.0/tcp/4001" {
+		t.Errorf("without QUIC preference addrs = %v, want TCP first", got)
+	}
+}
+
+func TestListenMultiaddrs_Listeners(t *testing.T) {
+	orig := interfaceAddrsByName
+	defer func() { interfaceAddrsByName = orig }()
+	interfaceAddrsByName = func(name string) ([]net.Addr, error) {
+		if name != "enp0s31f6" {
+			return nil, errors.New("no such network interface")
+		}
+		return []net.Addr{
+			&net.IPNet{IP: net.ParseIP("192.168.1.10"), Mask: net.CIDRMask(24, 32)},
+			&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)},
+			&net.IPNet{IP: net.ParseIP("2001:db8::10"), Mask: net.CIDRMask(64, 128)},
+		}, nil
+	}
+
+	cfg := &Config{
+		ListenPort: 4001,
+		PreferQUIC: true,
+		Listen: []ListenConfig{
+			{Transport: TransportTCP, Address: "2001:db8::20", Port: 4002},
+			{Transport: TransportQUIC, Interface: "enp0s31f6"},
+		},
+	}
+	addrs, err := listenMultiaddrs(cfg)
+	if err != nil {
+		t.Fatalf("listenMultiaddrs: %v", err)
+	}
+	want := []string{
+		"/ip4/192.168.1.10/udp/4001/quic-v1",
+		"/ip6/2001:db8::10/udp/4001/quic-v1",
+		"/ip6/2001:db8::20/tcp/4002",
+	}
+	if got := multiaddrStrings(addrs); !reflect.DeepEqual(got, want) {
+		t.Errorf("addrs = %v, want %v", got, want)
+	}
+
+	invalid := []ListenConfig{
+		{Transport: TransportQUIC, Interface: "wlan9"},
+		{Transport: TransportTCP, Address: "not-an-ip"},
+		{Transport: "sctp"},
+	}
+	for _, l := range invalid {
+		if _, err := listenMultiaddrs(&Config{ListenPort: 4001, Listen: []ListenConfig{l}}); err == nil {
+			t.Errorf("listener %+v: no error", l)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package p2p
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/zeroconf/v2"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"go.uber.org/zap"
+)
+
+// MDNSServiceName is the DNS-SD service debswarm nodes advertise on the LAN
+const MDNSServiceName = "_debswarm._tcp"
+
+// mDNS TXT record keys. dnsaddr carries one listen address per record, as
+// libp2p's own mDNS service does, so older nodes still parse our entries.
+const (
+	txtDNSAddr = "dnsaddr="
+	txtSwarm   = "swarm="
+	txtVersion = "version="
+	txtRole    = "role="
+)
+
+// SwarmPublic is the swarm fingerprint advertised by nodes without a PSK.
+// Entries from nodes too old to advertise a fingerprint are treated as public.
+const SwarmPublic = "public"
+
+var errNoMDNSAddrs = errors.New("no IP listen addresses to advertise over mDNS")
+
+// mdnsPeer is what a discovered node advertised about itself
+type mdnsPeer struct {
+	Info    peer.AddrInfo
+	Swarm   string
+	Version string
+	Role    string
+}
+
+// mdnsService advertises this node over mDNS with its swarm fingerprint,
+// version and role, and hands discovered nodes to the Node. It replaces
+// libp2p's mdns service, which cannot carry extra TXT records.
+type mdnsService struct {
+	host  host.Host
+	txt   []string // metadata records; dnsaddr records are added at Start
+	found func(mdnsPeer)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	server *zeroconf.Server
+}
+
+func newMDNSService(h host.Host, swarm, version, role string, found func(mdnsPeer)) *mdnsService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &mdnsService{
+		host:   h,
+		txt:    mdnsMetadataTXT(swarm, version, role),
+		found:  found,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start registers the service and begins browsing for other nodes
+func (s *mdnsService) Start() error {
+	ifaceAddrs, err := s.host.Network().InterfaceListenAddresses()
+	if err != nil {
+		return err
+	}
+	addrs, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{ID: s.host.ID(), Addrs: ifaceAddrs})
+	if err != nil {
+		return err
+	}
+
+	txt := append([]string(nil), s.txt...)
+	var ips []string
+	for _, addr := range addrs {
+		if !mdnsSuitable(addr) {
+			continue
+		}
+		txt = append(txt, txtDNSAddr+addr.String())
+		if ip, err := manet.ToIP(addr); err == nil && len(ips) < 2 {
+			ips = append(ips, ip.String())
+		}
+	}
+	if len(ips) == 0 {
+		return errNoMDNSAddrs
+	}
+
+	// The instance name only needs to be unique on the link; the port is
+	// required by DNS-SD but unused, since peers dial the dnsaddr records.
+	instance := randomInstanceName()
+	server, err := zeroconf.RegisterProxy(instance, MDNSServiceName, "local", 4001, instance, ips, txt, nil)
+	if err != nil {
+		return err
+	}
+	s.server = server
+
+	entries := make(chan *zeroconf.ServiceEntry, 1000)
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		for entry := range entries {
+			p, ok := parseMDNSEntry(entry.Text)
+			if !ok || p.Info.ID == s.host.ID() {
+				continue
+			}
+			go s.found(p)
+		}
+	}()
+	go func() {
+		defer s.wg.Done()
+		_ = zeroconf.Browse(s.ctx, MDNSServiceName, "local", entries)
+	}()
+	return nil
+}
+
+// Close stops advertising and browsing
+func (s *mdnsService) Close() error {
+	s.cancel()
+	if s.server != nil {
+		s.server.Shutdown()
+	}
+	s.wg.Wait()
+	return nil
+}
+
+// mdnsMetadataTXT returns the TXT records describing this node
+func mdnsMetadataTXT(swarm, version, role string) []string {
+	txt := []string{txtSwarm + swarm}
+	if version != "" {
+		txt = append(txt, txtVersion+version)
+	}
+	if role != "" {
+		txt = append(txt, txtRole+role)
+	}
+	return txt
+}
+
+// parseMDNSEntry extracts a node's addresses and metadata from its TXT
+// records. Unknown records are ignored. Returns false if the entry carries no
+// usable address for exactly one peer.
+func parseMDNSEntry(txt []string) (mdnsPeer, bool) {
+	p := mdnsPeer{Swarm: SwarmPublic}
+	var addrs []multiaddr.Multiaddr
+	for _, rec := range txt {
+		switch {
+		case strings.HasPrefix(rec, txtDNSAddr):
+			addr, err := multiaddr.NewMultiaddr(strings.TrimPrefix(rec, txtDNSAddr))
+			if err == nil {
+				addrs = append(addrs, addr)
+			}
+		case strings.HasPrefix(rec, txtSwarm):
+			p.Swarm = strings.TrimPrefix(rec, txtSwarm)
+		case strings.HasPrefix(rec, txtVersion):
+			p.Version = strings.TrimPrefix(rec, txtVersion)
+		case strings.HasPrefix(rec, txtRole):
+			p.Role = strings.TrimPrefix(rec, txtRole)
+		}
+	}
+	infos, err := peer.AddrInfosFromP2pAddrs(addrs...)
+	if err != nil || len(infos) != 1 {
+		return mdnsPeer{}, false
+	}
+	p.Info = infos[0]
+	return p, true
+}
+
+// mdnsSuitable reports whether an address is worth advertising on the LAN:
+// a direct IP address, not a relay or browser transport.
+func mdnsSuitable(addr multiaddr.Multiaddr) bool {
+	first, _ := multiaddr.SplitFirst(addr)
+	if first == nil {
+		return false
+	}
+	if code := first.Protocol().Code; code != multiaddr.P_IP4 && code != multiaddr.P_IP6 {
+		return false
+	}
+	suitable := true
+	multiaddr.ForEach(addr, func(c multiaddr.Component) bool {
+		switch c.Protocol().Code {
+		case multiaddr.P_CIRCUIT, multiaddr.P_WEBTRANSPORT, multiaddr.P_WEBRTC,
+			multiaddr.P_WEBRTC_DIRECT, multiaddr.P_WS, multiaddr.P_WSS:
+			suitable = false
+		}
+		return suitable
+	})
+	return suitable
+}
+
+func randomInstanceName() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// swarmFingerprint identifies the swarm a node belongs to in mDNS records
+func swarmFingerprint(psk []byte) string {
+	if len(psk) == 0 {
+		return SwarmPublic
+	}
+	return PSKFingerprint(psk)
+}
+
+// handleMDNSPeer connects to a discovered node if it belongs to our swarm.
+// Nodes from another swarm (a different PSK, or public vs private) would
+// only fail the connection handshake, so they are skipped before dialing.
+func (n *Node) handleMDNSPeer(p mdnsPeer) {
+	if p.Swarm != n.swarm {
+		n.logger.Debug("Ignoring mDNS peer from another swarm",
+			zap.String("peerID", p.Info.ID.String()),
+			zap.String("swarm", p.Swarm))
+		if n.metrics != nil {
+			n.metrics.MDNSPeersFiltered.Inc()
+		}
+		return
+	}
+	n.logger.Debug("mDNS peer metadata",
+		zap.String("peerID", p.Info.ID.String()),
+		zap.String("version", p.Version),
+		zap.String("role", p.Role))
+	n.HandlePeerFound(p.Info)
+}
@@ -0,0 +1,90 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/metrics"
+)
+
+func TestParseMDNSEntry(t *testing.T) {
+	const id = "12D3KooWGC6TvWhfapngX6wvJHMYvKpDMXPb3ZnCZ6dMoaMtimQ5"
+	addr := txtDNSAddr + "/ip4/192.168.1.20/tcp/4001/p2p/" + id
+
+	txt := append(mdnsMetadataTXT("0123456789abcdef", "1.2.3", "seed"), addr, "other=ignored")
+	p, ok := parseMDNSEntry(txt)
+	if !ok {
+		t.Fatal("parseMDNSEntry rejected a valid entry")
+	}
+	if p.Info.ID.String() != id || len(p.Info.Addrs) != 1 {
+		t.Errorf("Info = %v, want %s with one address", p.Info, id)
+	}
+	if p.Swarm != "0123456789abcdef" || p.Version != "1.2.3" || p.Role != "seed" {
+		t.Errorf("metadata = %q %q %q", p.Swarm, p.Version, p.Role)
+	}
+
+	// An entry from a node that predates swarm metadata is public
+	p, ok = parseMDNSEntry([]string{addr})
+	if !ok || p.Swarm != SwarmPublic || p.Version != "" || p.Role != "" {
+		t.Errorf("legacy entry = %+v, %v", p, ok)
+	}
+
+	if _, ok := parseMDNSEntry(mdnsMetadataTXT(SwarmPublic, "1.2.3", "full")); ok {
+		t.Error("entry without addresses should be rejected")
+	}
+	if _, ok := parseMDNSEntry([]string{txtDNSAddr + "not-a-multiaddr"}); ok {
+		t.Error("entry with an unparseable address should be rejected")
+	}
+}
+
+func TestSwarmFingerprint(t *testing.T) {
+	if got := swarmFingerprint(nil); got != SwarmPublic {
+		t.Errorf("swarmFingerprint(nil) = %q, want %q", got, SwarmPublic)
+	}
+	psk, err := GeneratePSK()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := swarmFingerprint(psk); got != PSKFingerprint(psk) {
+		t.Errorf("swarmFingerprint(psk) = %q, want %q", got, PSKFingerprint(psk))
+	}
+}
+
+func TestHandleMDNSPeer_FiltersOtherSwarms(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	logger := newTestLogger()
+
+	cfg1 := newTestConfig(t)
+	cfg1.Metrics = metrics.New()
+	node1, err := New(ctx, cfg1, logger)
+	if err != nil {
+		t.Fatalf("New node1 failed: %v", err)
+	}
+	defer node1.Close()
+
+	node2, err := New(ctx, newTestConfig(t), logger)
+	if err != nil {
+		t.Fatalf("New node2 failed: %v", err)
+	}
+	defer node2.Close()
+
+	found := mdnsPeer{Swarm: "0123456789abcdef"}
+	found.Info.ID = node2.PeerID()
+	found.Info.Addrs = node2.Addrs()
+
+	node1.handleMDNSPeer(found)
+	if node1.ConnectedPeers() != 0 {
+		t.Error("node connected to a peer from another swarm")
+	}
+	if got := cfg1.Metrics.MDNSPeersFiltered.Value(); got != 1 {
+		t.Errorf("MDNSPeersFiltered = %d, want 1", got)
+	}
+
+	found.Swarm = SwarmPublic
+	node1.handleMDNSPeer(found)
+	if node1.ConnectedPeers() == 0 {
+		t.Error("node did not connect to a peer from its own swarm")
+	}
+}
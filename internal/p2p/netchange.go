@@ -0,0 +1,142 @@
+// Package p2p - Recovery after network changes and resume from suspend
+package p2p
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/audit"
+)
+
+const (
+	// netChangeRecoveryTimeout bounds one recovery run
+	netChangeRecoveryTimeout = time.Minute
+
+	// resumePingTimeout is how long a peer has to answer a ping after a
+	// resume before its connections are presumed dead
+	resumePingTimeout = 5 * time.Second
+)
+
+// interfaceAddrs lists the host's addresses; replaced in tests
+var interfaceAddrs = net.InterfaceAddrs
+
+// HandleNetworkChange recovers after the host's addresses changed or it
+// resumed from suspend. Connections bound to an address the host no longer
+// has are closed, and after a resume so are those to peers that no longer
+// answer a ping. Adaptive timeouts learned on the old network are reset.
+// Then the node reconnects to its bootstrap peers and static relays and
+// refreshes the DHT routing table; identify and AutoNAT on the new
+// connections relearn the external address.
+//
+// It blocks until recovery is done. A call while a recovery is running
+// returns at once.
+func (n *Node) HandleNetworkChange(resumed bool) {
+	if n.paused.Load() || !n.recovering.CompareAndSwap(false, true) {
+		return
+	}
+	defer n.recovering.Store(false)
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(n.ctx, netChangeRecoveryTimeout)
+	defer cancel()
+
+	closed := n.closeStaleConns(ctx, resumed)
+	n.timeouts.Reset()
+
+	infos := resolveBootstrapPeers(ctx, n.resolver, n.bootstrapAddrs, n.logger)
+	n.connectBootstrapPeers(ctx, append(infos, n.staticRelays...), 0)
+	select {
+	case err := <-n.dht.RefreshRoutingTable():
+		if err != nil {
+			n.logger.Debug("Routing table refresh after network change failed", zap.Error(err))
+		}
+	case <-ctx.Done():
+	}
+
+	n.logger.Info("Recovered from network change",
+		zap.Bool("resumed", resumed),
+		zap.Int("closedConnections", closed),
+		zap.Int("connectedPeers", len(n.host.Network().Peers())),
+		zap.Int("routingTableSize", n.dht.RoutingTable().Size()),
+		zap.Duration("took", time.Since(start)))
+	trigger := "network_change"
+	if resumed {
+		trigger = "resume"
+	}
+	n.audit.Log(audit.NewBootstrapEvent(trigger, len(n.host.Network().Peers()),
+		n.dht.RoutingTable().Size(), time.Since(start).Milliseconds(), ""))
+	if n.metrics != nil {
+		n.metrics.RoutingTableSize.Set(float64(n.dht.RoutingTable().Size()))
+		n.metrics.ConnectedPeers.Set(float64(len(n.host.Network().Peers())))
+	}
+}
+
+// closeStaleConns closes connections that cannot work any more and
+// returns how many it closed.
+func (n *Node) closeStaleConns(ctx context.Context, resumed bool) int {
+	current := make(map[string]bool)
+	if addrs, err := interfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok {
+				current[ipnet.IP.String()] = true
+			}
+		}
+	}
+
+	var stale []network.Conn
+	live := make(map[peer.ID]bool)
+	for _, c := range n.host.Network().Conns() {
+		if len(current) > 0 && !localAddrAssigned(c, current) {
+			stale = append(stale, c)
+			continue
+		}
+		live[c.RemotePeer()] = true
+	}
+
+	// After a suspend, connections on addresses we still have may be
+	// dead all the same: NAT mappings and the peers' state expired.
+	if resumed {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for id := range live {
+			wg.Add(1)
+			go func(id peer.ID) {
+				defer wg.Done()
+				pctx, cancel := context.WithTimeout(ctx, resumePingTimeout)
+				defer cancel()
+				if res := <-n.pingService.Ping(pctx, id); res.Error != nil {
+					mu.Lock()
+					stale = append(stale, n.host.Network().ConnsToPeer(id)...)
+					mu.Unlock()
+				}
+			}(id)
+		}
+		wg.Wait()
+	}
+
+	for _, c := range stale {
+		n.logger.Debug("Closing stale connection",
+			zap.String("peer", c.RemotePeer().String()),
+			zap.Stringer("local", c.LocalMultiaddr()))
+		_ = c.Close()
+	}
+	return len(stale)
+}
+
+// localAddrAssigned reports whether the local end of c is an address the
+// host still has. Wildcard and loopback addresses always count as assigned,
+// as do connections whose local address is not an IP.
+func localAddrAssigned(c network.Conn, current map[string]bool) bool {
+	ip, err := manet.ToIP(c.LocalMultiaddr())
+	if err != nil || ip.IsUnspecified() || ip.IsLoopback() {
+		return true
+	}
+	return current[ip.String()]
+}
@@ -0,0 +1,107 @@
+package p2p
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/debswarm/debswarm/internal/timeouts"
+)
+
+// fakeConn is a network.Conn with only a local address
+type fakeConn struct {
+	network.Conn
+	local multiaddr.Multiaddr
+}
+
+func (c fakeConn) LocalMultiaddr() multiaddr.Multiaddr { return c.local }
+
+func TestLocalAddrAssigned(t *testing.T) {
+	current := map[string]bool{"192.168.1.20": true, "2001:db8::20": true}
+	tests := map[string]bool{
+		"/ip4/192.168.1.20/tcp/4001":         true,
+		"/ip6/2001:db8::20/udp/4001/quic-v1": true,
+		"/ip4/10.0.0.5/tcp/4001":             false, // address of the old network
+		"/ip4/0.0.0.0/tcp/4001":              true,
+		"/ip4/127.0.0.1/tcp/4001":            true,
+		"/dns4/example.com/tcp/4001":         true,
+	}
+	for addr, want := range tests {
+		c := fakeConn{local: multiaddr.StringCast(addr)}
+		if got := localAddrAssigned(c, current); got != want {
+			t.Errorf("localAddrAssigned(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestHandleNetworkChange(t *testing.T) {
+	orig := interfaceAddrs
+	defer func() { interfaceAddrs = orig }()
+	// The host moved to a network the test connection is not on; loopback
+	// connections must survive that.
+	interfaceAddrs = func() ([]net.Addr, error) {
+		return []net.Addr{&net.IPNet{IP: net.ParseIP("192.0.2.1"), Mask: net.CIDRMask(24, 32)}}, nil
+	}
+
+	ctx := context.Background()
+	node1, err := New(ctx, newTestConfig(t), newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node1.Close()
+	node2, err := New(ctx, newTestConfig(t), newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node2.Close()
+
+	var loopback []multiaddr.Multiaddr
+	for _, a := range node1.Addrs() {
+		if strings.HasPrefix(a.String(), "/ip4/127.0.0.1/") {
+			loopback = append(loopback, a)
+		}
+	}
+	if len(loopback) == 0 {
+		t.Skip("node has no loopback address")
+	}
+	if err := node2.host.Connect(ctx, peer.AddrInfo{ID: node1.PeerID(), Addrs: loopback}); err != nil {
+		t.Fatal(err)
+	}
+
+	node2.timeouts.RecordTimeout(timeouts.OpPeerConnect)
+	if node2.timeouts.Get(timeouts.OpPeerConnect) == timeouts.DefaultConfig().PeerConnect {
+		t.Fatal("timeout did not grow")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		node2.HandleNetworkChange(true)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(netChangeRecoveryTimeout):
+		t.Fatal("recovery did not finish")
+	}
+
+	if node2.host.Network().Connectedness(node1.PeerID()) != network.Connected {
+		t.Error("live loopback connection was closed")
+	}
+	if got := node2.timeouts.Get(timeouts.OpPeerConnect); got != timeouts.DefaultConfig().PeerConnect {
+		t.Errorf("peer connect timeout = %v after recovery, want base", got)
+	}
+
+	// Paused nodes stay out of the network
+	node2.paused.Store(true)
+	node2.timeouts.RecordTimeout(timeouts.OpPeerConnect)
+	node2.HandleNetworkChange(false)
+	if node2.timeouts.Get(timeouts.OpPeerConnect) == timeouts.DefaultConfig().PeerConnect {
+		t.Error("paused node recovered")
+	}
+}
@@ -5,12 +5,14 @@ import (
 	"bufio"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/libp2p/go-libp2p"
@@ -21,7 +23,6 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/pnet"
 	"github.com/libp2p/go-libp2p/core/protocol"
-	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
 	drouting "github.com/libp2p/go-libp2p/p2p/discovery/routing"
 	"github.com/libp2p/go-libp2p/p2p/host/autorelay"
 	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
@@ -29,13 +30,16 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
 	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 	"github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	manet "github.com/multiformats/go-multiaddr/net"
 	"go.uber.org/zap"
 
 	"github.com/debswarm/debswarm/internal/audit"
+	"github.com/debswarm/debswarm/internal/chaos"
 	"github.com/debswarm/debswarm/internal/metrics"
 	"github.com/debswarm/debswarm/internal/peers"
+	"github.com/debswarm/debswarm/internal/priority"
 	"github.com/debswarm/debswarm/internal/ratelimit"
-	"github.com/debswarm/debswarm/internal/sanitize"
 	"github.com/debswarm/debswarm/internal/security"
 	"github.com/debswarm/debswarm/internal/timeouts"
 )
@@ -67,17 +71,56 @@ type Node struct {
 	logger           *zap.Logger
 	ctx              context.Context
 	cancel           context.CancelFunc
-	getContent       ContentGetter
+	content          ContentProvider
+	contentNamer     ContentNamer
+	recordTransfer   TransferRecorder
+	recordUpload     UploadRecorder
+	recordReceipt    ReceiptRecorder
+	uploadGate       UploadGate
+	uploadPriority   UploadPriority
+	throttle         DownloadThrottle
+	uploadThrottle   UploadThrottle
 	scorer           *peers.Scorer
 	timeouts         *timeouts.Manager
 	metrics          *metrics.Metrics
 	audit            audit.Logger
-	mdnsService      mdns.Service
+	chaos            *chaos.Injector // nil unless [chaos] faults are configured
+	mdnsService      *mdnsService
+	swarm            string // swarm fingerprint advertised and matched over mDNS
 	bootstrapDone    chan struct{}
 
+	// Bootstrap DNS names are resolved through resolver, and re-resolved
+	// every bootstrapResolveInterval (0 = never).
+	resolver                 *madns.Resolver
+	bootstrapResolveInterval time.Duration
+	// Bootstrap is done once bootstrapMinPeers dials succeeded (0 = all)
+	bootstrapMinPeers int
+
+	// Peers remembered in dataDir to bootstrap from on the next start, when
+	// persistPeers is set (see knownpeers.go)
+	dataDir      string
+	persistPeers bool
+	knownPeersMu sync.Mutex
+	knownPeers   []knownPeer
+
+	// Peers reconnected to after a network change (see HandleNetworkChange)
+	bootstrapAddrs []string
+	staticRelays   []peer.AddrInfo
+	recovering     atomic.Bool
+
+	// Hourly budgets for DHT operations (nil = unlimited)
+	provideBudget *opBudget
+	lookupBudget  *opBudget
+
 	// Rate limiting (global)
 	uploadLimiter   *ratelimit.Limiter
 	downloadLimiter *ratelimit.Limiter
+	// Additional limits on transfers with WAN peers (see isLANConn)
+	wanUploadLimiter   *ratelimit.Limiter
+	wanDownloadLimiter *ratelimit.Limiter
+
+	// Transfer compression (nil if disabled)
+	compression *CompressionConfig
 
 	// Per-peer rate limiting (optional, nil if disabled)
 	peerUploadLimiter   *ratelimit.PeerLimiterManager
@@ -87,11 +130,24 @@ type Node struct {
 	uploadsMu            sync.Mutex
 	activeUploads        int
 	uploadsPerPeer       map[peer.ID]int
+	downloadsPerPeer     map[peer.ID]int             // in progress, listed by Connections
+	uploadStreams        map[network.Stream]struct{} // running uploads, reset by Pause
 	maxConcurrentUploads int
+	sharing              SharingPolicy
+	admission            *admission   // lowers the upload limit while the host is busy (nil = off)
+	power                *powerPolicy // throttles or stops uploads on battery or metered networks (nil = off)
+
+	// Kill switch (see Pause). paused is read on every transfer; pauseMu
+	// guards pauseState and serializes Pause/Resume.
+	paused     atomic.Bool
+	pauseMu    sync.Mutex
+	pauseState PauseState
 
 	// Private swarm mode (when peer allowlist is active)
 	// Skips DHT announcements to prevent information leakage
 	privateSwarm bool
+	// gater applies the allowlist and blocklist; nil without them
+	gater *AllowlistGater
 
 	// Whether a pre-shared key isolates this swarm: every connected peer is
 	// then a trusted swarm member (see GetMDNSPeers)
@@ -109,17 +165,59 @@ type Node struct {
 	// connection, bounded to this many bytes, when the peer has no direct path
 	// (e.g. both peers symmetric-NAT'd). 0 = never carry package bytes over a relay.
 	relayedTransferMax int64
+
+	// Capability handshake: our version string and what peers reported.
+	version string
+	hello   helloState
+
+	// Bandwidth probes (see Probe): the size we request, whether new peers
+	// are probed after the handshake, and the probes we answer at once.
+	probeSize      int64
+	probeOnConnect bool
+	probeSem       chan struct{}
+
+	// Replication partners and their subscriptions (see replicate.go)
+	replication replicationState
+
+	// Completed uploads whose receipts may still be asked for (see receipt.go)
+	uploads *recentUploads
+
+	// Idle transfer streams to peers, reused by downloads (nil = none)
+	streams *streamPool
 }
 
-// ContentGetter is a function that retrieves content by hash
-type ContentGetter func(sha256Hash string) (io.ReadCloser, int64, error)
+// TransferRecorder is called with the bytes sent to (uploaded) or received
+// from (downloaded) a peer after each transfer, for the transfer ledger.
+type TransferRecorder func(peerID peer.ID, uploaded, downloaded int64)
+
+// UploadRecorder is called with the bytes of a package sent to a peer after
+// each upload; complete is set when the upload reached the package's end.
+type UploadRecorder func(sha256Hash string, uploaded int64, complete bool)
+
+// UploadGate is asked before content is uploaded to a peer; an error refuses
+// the upload as if the content were not available.
+type UploadGate func(sha256Hash string, peerID peer.ID) error
+
+// UploadPriority reports whether content is uploaded ahead of other
+// transfers, as security updates are.
+type UploadPriority func(sha256Hash string) bool
+
+// DownloadThrottle wraps the reader of each download from a peer, after the
+// node's own rate limits, and may limit it further.
+type DownloadThrottle func(ctx context.Context, r io.Reader) io.Reader
+
+// UploadThrottle wraps the writer of each size-byte upload (-1 if unknown),
+// after the node's own rate limits, and may limit it further.
+type UploadThrottle func(ctx context.Context, w io.Writer, size int64) io.Writer
 
 // Config holds P2P node configuration
 type Config struct {
 	ListenPort           int
-	BootstrapPeers       []string
+	Listen               []ListenConfig // Listeners replacing all transports on all addresses (nil = default)
+	BootstrapPeers       []string       // multiaddrs; DNS components are resolved at connect time
 	EnableMDNS           bool
 	PrivateKey           crypto.PrivKey
+	IdentitySigner       string   // Helper program holding a hardware-backed identity key
 	DataDir              string   // Directory for persistent data (identity key, etc.)
 	PreferQUIC           bool     // Prefer QUIC over TCP
 	MaxUploadRate        int64    // bytes per second, 0 = unlimited
@@ -127,12 +225,15 @@ type Config struct {
 	MaxConnections       int      // Maximum number of connections (0 = default 100)
 	MaxConcurrentUploads int      // Maximum concurrent uploads (0 = default 20)
 	PSK                  []byte   // Pre-shared key for private swarm
-	PeerAllowlist        []string // Allowed peer IDs (empty = all allowed)
-	PeerBlocklist        []string // Blocked peer IDs
+	PeerAllowlist        []string // Allowed peer IDs, networks ("10.20.0.0/16") and addresses (empty = all allowed)
+	PeerBlocklist        []string // Blocked peer IDs, networks and addresses
 	Scorer               *peers.Scorer
 	Timeouts             *timeouts.Manager
 	Metrics              *metrics.Metrics
-	Audit                audit.Logger // Audit logger for structured event logging
+	Audit                audit.Logger    // Audit logger for structured event logging
+	Chaos                *chaos.Injector // Fault injection for resilience testing (nil = none)
+	Version              string          // debswarm version reported in the hello handshake
+	Role                 string          // "full" or "seed", advertised over mDNS
 
 	// NAT traversal configuration
 	EnableRelay        bool // Use circuit relays to reach NAT'd peers (default: true)
@@ -153,6 +254,27 @@ type Config struct {
 	// to discover relays through.
 	RelayPeers []string
 
+	// BootstrapResolveInterval is how often DNS bootstrap addresses are
+	// re-resolved. 0 disables re-resolution.
+	BootstrapResolveInterval time.Duration
+	// BootstrapMinPeers ends the wait for bootstrap dials once this many
+	// succeeded; the other dials carry on in the background. 0 waits for
+	// all of them.
+	BootstrapMinPeers int
+	// PersistPeers remembers the peers connected to in DataDir and dials
+	// them along with BootstrapPeers on the next start, so the node comes
+	// up even when the configured bootstrap peers are down.
+	PersistPeers bool
+
+	// DHTMode is "server", "client" or "auto" (default): serve DHT records
+	// only once AutoNAT finds us publicly reachable. Client mode keeps a
+	// constrained device from answering other nodes' queries.
+	DHTMode string
+	// ProvideBudget and LookupBudget cap DHT provide and provider-lookup
+	// operations per hour (0 = unlimited); see opBudget.
+	ProvideBudget int
+	LookupBudget  int
+
 	// Bounds on what we are willing to carry when acting as a relay. Zero values
 	// fall back to circuit-relay v2's own defaults.
 	RelayMaxReservations int
@@ -177,6 +299,48 @@ type Config struct {
 	AdaptiveEnabled     bool    // Enable adaptive rate adjustment based on peer scores
 	AdaptiveMinRate     int64   // Minimum rate floor for adaptive (bytes/sec)
 	AdaptiveMaxBoost    float64 // Maximum boost factor for high-performing peers
+
+	// Bandwidth probes: ProbeSize is the transfer requested (0 = 256KB, at
+	// most 1MB); ProbeOnConnect probes each peer dialed that the scorer has
+	// no measurements for.
+	ProbeSize      int64
+	ProbeOnConnect bool
+
+	// ReplicationPartners are the seeds allowed to subscribe to this node's
+	// new-content events (see ProtocolReplicate). Their connections are
+	// protected from trimming.
+	ReplicationPartners []peer.AddrInfo
+
+	// Token bucket sizes in bytes (0 = one second's worth of the rate,
+	// between 64KB and 4MB) and the transfer size up to which packages are
+	// sent and received without waiting on a limiter (0 = none)
+	RateLimitBurst  int64
+	PerPeerBurst    int64
+	SmallObjectSize int64
+
+	// Sharing adjusts uploads by the requesting peer's reciprocity.
+	Sharing SharingPolicy
+
+	// Admission, when set, accepts fewer uploads while the host is busy.
+	Admission *AdmissionConfig
+
+	// Power, when set, throttles or stops uploads on battery power or a
+	// metered connection.
+	Power *PowerConfig
+
+	// Caps on transfers with WAN peers, in bytes per second (0 = none),
+	// on top of the limits above. LAN peers (mDNS, or on a private
+	// address) are not affected.
+	WANUploadRate   int64
+	WANDownloadRate int64
+
+	// Compression, when set, compresses compressible transfers with peers
+	// that support it (see ProtocolTransferCompressed).
+	Compression *CompressionConfig
+
+	// StreamPool, when set, keeps transfer streams to peers open for reuse
+	// by later downloads.
+	StreamPool *StreamPoolConfig
 }
 
 // New creates a new P2P node with QUIC preference
@@ -190,6 +354,16 @@ func New(ctx context.Context, cfg *Config, logger *zap.Logger) (*Node, error) {
 	if cfg.PrivateKey != nil {
 		// Use explicitly provided key
 		privKey = cfg.PrivateKey
+	} else if cfg.IdentitySigner != "" {
+		// Hardware-backed key; the private key never leaves the device
+		privKey, err = NewExternalSigner(cfg.IdentitySigner)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to load identity: %w", err)
+		}
+		logger.Info("Using hardware-backed identity",
+			zap.String("peerID", IdentityFingerprint(privKey)),
+			zap.String("signer", cfg.IdentitySigner))
 	} else if cfg.DataDir != "" {
 		// Load from persistent storage or create new
 		privKey, err = LoadOrCreateIdentity(cfg.DataDir)
@@ -211,46 +385,10 @@ func New(ctx context.Context, cfg *Config, logger *zap.Logger) (*Node, error) {
 	}
 
 	// Create listen addresses - QUIC first for preference
-	var listenAddrs []multiaddr.Multiaddr
-
-	if cfg.PreferQUIC {
-		// QUIC addresses first (preferred)
-		quicAddrs := []string{
-			fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic-v1", cfg.ListenPort),
-			fmt.Sprintf("/ip6/::/udp/%d/quic-v1", cfg.ListenPort),
-		}
-		for _, addr := range quicAddrs {
-			ma, maErr := multiaddr.NewMultiaddr(addr)
-			if maErr == nil {
-				listenAddrs = append(listenAddrs, ma)
-			}
-		}
-	}
-
-	// TCP addresses (fallback or primary if QUIC disabled)
-	tcpAddrs := []string{
-		fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", cfg.ListenPort),
-		fmt.Sprintf("/ip6/::/tcp/%d", cfg.ListenPort),
-	}
-	for _, addr := range tcpAddrs {
-		ma, maErr := multiaddr.NewMultiaddr(addr)
-		if maErr == nil {
-			listenAddrs = append(listenAddrs, ma)
-		}
-	}
-
-	if !cfg.PreferQUIC {
-		// Add QUIC as fallback
-		quicAddrs := []string{
-			fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic-v1", cfg.ListenPort),
-			fmt.Sprintf("/ip6/::/udp/%d/quic-v1", cfg.ListenPort),
-		}
-		for _, addr := range quicAddrs {
-			ma, maErr := multiaddr.NewMultiaddr(addr)
-			if maErr == nil {
-				listenAddrs = append(listenAddrs, ma)
-			}
-		}
+	listenAddrs, err := listenMultiaddrs(cfg)
+	if err != nil {
+		cancel()
+		return nil, err
 	}
 
 	// Set up connection manager with limits
@@ -286,6 +424,9 @@ func New(ctx context.Context, cfg *Config, logger *zap.Logger) (*Node, error) {
 		libp2p.EnableNATService(),
 		libp2p.NATPortMap(),
 	}
+	if _, ok := privKey.(*ExternalSigner); ok {
+		opts = append(opts, signerQUICReuse())
+	}
 
 	// Optional: circuit-relay client transport. This lets us *dial* a /p2p-circuit
 	// address and be reached through a relay we hold a reservation with. On its
@@ -360,38 +501,27 @@ func New(ctx context.Context, cfg *Config, logger *zap.Logger) (*Node, error) {
 	// Add peer allowlist/blocklist if configured
 	// Also track if we're in private swarm mode to skip DHT announcements
 	var privateSwarmMode bool
+	var gater *AllowlistGater
 	if len(cfg.PeerAllowlist) > 0 || len(cfg.PeerBlocklist) > 0 {
-		// Parse allowlist
-		allowedPeerIDs := make([]peer.ID, 0, len(cfg.PeerAllowlist))
-		for _, pidStr := range cfg.PeerAllowlist {
-			pid, decodeErr := peer.Decode(pidStr)
-			if decodeErr != nil {
-				logger.Warn("Invalid peer ID in allowlist", zap.String("peer", pidStr), zap.Error(decodeErr))
-				continue
-			}
-			allowedPeerIDs = append(allowedPeerIDs, pid)
+		// Entries are peer IDs, networks or single addresses
+		allow, invalid := ParsePeerRules(cfg.PeerAllowlist)
+		for _, e := range invalid {
+			logger.Warn("Invalid entry in allowlist", zap.String("entry", e))
 		}
-
-		// Parse blocklist
-		blockedPeerIDs := make([]peer.ID, 0, len(cfg.PeerBlocklist))
-		for _, pidStr := range cfg.PeerBlocklist {
-			pid, decodeErr := peer.Decode(pidStr)
-			if decodeErr != nil {
-				logger.Warn("Invalid peer ID in blocklist", zap.String("peer", pidStr), zap.Error(decodeErr))
-				continue
-			}
-			blockedPeerIDs = append(blockedPeerIDs, pid)
+		block, invalid := ParsePeerRules(cfg.PeerBlocklist)
+		for _, e := range invalid {
+			logger.Warn("Invalid entry in blocklist", zap.String("entry", e))
 		}
 
-		if len(allowedPeerIDs) > 0 || len(blockedPeerIDs) > 0 {
-			gater := NewGater(allowedPeerIDs, blockedPeerIDs)
+		if !allow.Empty() || !block.Empty() {
+			gater = NewRulesGater(allow, block)
 			opts = append(opts, libp2p.ConnectionGater(gater))
-			if len(allowedPeerIDs) > 0 {
+			if !allow.Empty() {
 				privateSwarmMode = true // Enable private swarm mode to skip DHT announcements
-				logger.Info("Peer allowlist enabled", zap.Int("count", len(allowedPeerIDs)))
+				logger.Info("Peer allowlist enabled", zap.Int("peers", len(allow.IDs)), zap.Int("networks", len(allow.Networks)))
 			}
-			if len(blockedPeerIDs) > 0 {
-				logger.Info("Peer blocklist enabled", zap.Int("count", len(blockedPeerIDs)))
+			if !block.Empty() {
+				logger.Info("Peer blocklist enabled", zap.Int("peers", len(block.IDs)), zap.Int("networks", len(block.Networks)))
 			}
 		}
 	}
@@ -410,7 +540,7 @@ func New(ctx context.Context, cfg *Config, logger *zap.Logger) (*Node, error) {
 
 	// Create DHT
 	kadDHT, err := dht.New(ctx, h,
-		dht.Mode(dht.ModeAutoServer),
+		dht.Mode(dhtMode(cfg.DHTMode)),
 		dht.ProtocolPrefix("/debswarm"),
 	)
 	if err != nil {
@@ -438,28 +568,48 @@ func New(ctx context.Context, cfg *Config, logger *zap.Logger) (*Node, error) {
 		auditLogger = &audit.NoopLogger{}
 	}
 
+	limiterOpts := ratelimit.Options{Burst: cfg.RateLimitBurst, SmallObjectSize: cfg.SmallObjectSize}
 	node := &Node{
-		host:                 h,
-		dht:                  kadDHT,
-		routingDiscovery:     drouting.NewRoutingDiscovery(kadDHT), // Reuse for all lookups
-		pingService:          ping.NewPingService(h),               // Keepalive pings
-		logger:               logger,
-		ctx:                  ctx,
-		cancel:               cancel,
-		scorer:               scorer,
-		timeouts:             tm,
-		metrics:              cfg.Metrics,
-		audit:                auditLogger,
-		bootstrapDone:        make(chan struct{}),
-		uploadsPerPeer:       make(map[peer.ID]int),
-		maxConcurrentUploads: cfg.MaxConcurrentUploads,
-		uploadLimiter:        ratelimit.New(cfg.MaxUploadRate),
-		downloadLimiter:      ratelimit.New(cfg.MaxDownloadRate),
-		privateSwarm:         privateSwarmMode,
-		pskEnabled:           len(cfg.PSK) > 0,
-		relayServiceMode:     relayServiceMode(cfg.RelayService),
-		relayResources:       relayResourcesFrom(cfg),
-		relayedTransferMax:   cfg.RelayedTransferMax,
+		host:                     h,
+		dht:                      kadDHT,
+		routingDiscovery:         drouting.NewRoutingDiscovery(kadDHT), // Reuse for all lookups
+		pingService:              ping.NewPingService(h),               // Keepalive pings
+		logger:                   logger,
+		ctx:                      ctx,
+		cancel:                   cancel,
+		scorer:                   scorer,
+		timeouts:                 tm,
+		metrics:                  cfg.Metrics,
+		audit:                    auditLogger,
+		chaos:                    cfg.Chaos,
+		bootstrapDone:            make(chan struct{}),
+		resolver:                 madns.DefaultResolver,
+		bootstrapResolveInterval: cfg.BootstrapResolveInterval,
+		bootstrapMinPeers:        cfg.BootstrapMinPeers,
+		dataDir:                  cfg.DataDir,
+		persistPeers:             cfg.PersistPeers,
+		bootstrapAddrs:           cfg.BootstrapPeers,
+		staticRelays:             staticRelays,
+		provideBudget:            newOpBudget(cfg.ProvideBudget),
+		lookupBudget:             newOpBudget(cfg.LookupBudget),
+		uploadsPerPeer:           make(map[peer.ID]int),
+		maxConcurrentUploads:     cfg.MaxConcurrentUploads,
+		sharing:                  cfg.Sharing,
+		uploadLimiter:            ratelimit.NewWithOptions(cfg.MaxUploadRate, limiterOpts),
+		downloadLimiter:          ratelimit.NewWithOptions(cfg.MaxDownloadRate, limiterOpts),
+		wanUploadLimiter:         ratelimit.NewWithOptions(cfg.WANUploadRate, limiterOpts),
+		wanDownloadLimiter:       ratelimit.NewWithOptions(cfg.WANDownloadRate, limiterOpts),
+		privateSwarm:             privateSwarmMode,
+		gater:                    gater,
+		pskEnabled:               len(cfg.PSK) > 0,
+		relayServiceMode:         relayServiceMode(cfg.RelayService),
+		relayResources:           relayResourcesFrom(cfg),
+		relayedTransferMax:       cfg.RelayedTransferMax,
+		version:                  cfg.Version,
+		compression:              cfg.Compression,
+		swarm:                    swarmFingerprint(cfg.PSK),
+		probeSize:                cfg.ProbeSize,
+		probeOnConnect:           cfg.ProbeOnConnect,
 	}
 
 	// AutoRelay's peer source was handed to libp2p before this Node existed;
@@ -470,6 +620,15 @@ func New(ctx context.Context, cfg *Config, logger *zap.Logger) (*Node, error) {
 	if node.maxConcurrentUploads <= 0 {
 		node.maxConcurrentUploads = MaxConcurrentUploads
 	}
+	node.startAdmission(cfg.Admission)
+	node.startPower(cfg.Power)
+	if cfg.StreamPool != nil {
+		node.streams = newStreamPool(*cfg.StreamPool, cfg.Metrics, logger.Named("streams"))
+		go node.streams.run(node.ctx)
+	}
+	if node.probeSize <= 0 || node.probeSize > MaxProbeSize {
+		node.probeSize = DefaultProbeSize
+	}
 
 	if cfg.MaxUploadRate > 0 {
 		logger.Info("Upload rate limiting enabled", zap.Int64("bytesPerSecond", cfg.MaxUploadRate))
@@ -477,6 +636,30 @@ func New(ctx context.Context, cfg *Config, logger *zap.Logger) (*Node, error) {
 	if cfg.MaxDownloadRate > 0 {
 		logger.Info("Download rate limiting enabled", zap.Int64("bytesPerSecond", cfg.MaxDownloadRate))
 	}
+	if cfg.WANUploadRate > 0 || cfg.WANDownloadRate > 0 {
+		logger.Info("WAN peer rate limiting enabled",
+			zap.Int64("uploadBytesPerSecond", cfg.WANUploadRate),
+			zap.Int64("downloadBytesPerSecond", cfg.WANDownloadRate))
+	}
+
+	if cfg.Metrics != nil {
+		node.provideBudget.queuedGauge = cfg.Metrics.DHTBudgetQueued.WithLabel("provide")
+		node.lookupBudget.queuedGauge = cfg.Metrics.DHTBudgetQueued.WithLabel("lookup")
+	}
+
+	if cfg.ProvideBudget > 0 || cfg.LookupBudget > 0 {
+		logger.Info("DHT operation budgets enabled",
+			zap.Int("providesPerHour", cfg.ProvideBudget),
+			zap.Int("lookupsPerHour", cfg.LookupBudget))
+	}
+
+	if cfg.Sharing.Reciprocal {
+		logger.Info("Reciprocal sharing policy enabled",
+			zap.Int64("graceBytes", cfg.Sharing.Grace),
+			zap.Float64("minRatio", cfg.Sharing.MinRatio),
+			zap.Int64("leecherUploadRate", cfg.Sharing.LeecherUploadRate),
+			zap.Int("leecherMaxUploads", cfg.Sharing.LeecherMaxUploads))
+	}
 
 	// Initialize per-peer rate limiters if configured
 	// Per-peer limiting is enabled by default (ExpectedPeers > 0 or explicit rates)
@@ -492,6 +675,8 @@ func New(ctx context.Context, cfg *Config, logger *zap.Logger) (*Node, error) {
 			LatencyThresholdMs:     ratelimit.DefaultLatencyThreshold,
 			IdleTimeout:            ratelimit.DefaultIdleTimeout,
 			AdaptiveRecalcInterval: ratelimit.DefaultAdaptiveRecalc,
+			Burst:                  cfg.PerPeerBurst,
+			SmallObjectSize:        cfg.SmallObjectSize,
 			Logger:                 logger.Named("peer-upload-limiter"),
 		}
 		node.peerUploadLimiter = ratelimit.NewPeerLimiterManager(uploadPeerCfg, node.uploadLimiter, scorer)
@@ -507,6 +692,8 @@ func New(ctx context.Context, cfg *Config, logger *zap.Logger) (*Node, error) {
 			LatencyThresholdMs:     ratelimit.DefaultLatencyThreshold,
 			IdleTimeout:            ratelimit.DefaultIdleTimeout,
 			AdaptiveRecalcInterval: ratelimit.DefaultAdaptiveRecalc,
+			Burst:                  cfg.PerPeerBurst,
+			SmallObjectSize:        cfg.SmallObjectSize,
 			Logger:                 logger.Named("peer-download-limiter"),
 		}
 		node.peerDownloadLimiter = ratelimit.NewPeerLimiterManager(downloadPeerCfg, node.downloadLimiter, scorer)
@@ -519,19 +706,27 @@ func New(ctx context.Context, cfg *Config, logger *zap.Logger) (*Node, error) {
 	// Set up transfer protocol handlers
 	h.SetStreamHandler(protocol.ID(ProtocolTransfer), node.handleTransferStream)
 	h.SetStreamHandler(protocol.ID(ProtocolTransferRange), node.handleRangeTransferStream)
+	h.SetStreamHandler(protocol.ID(ProtocolTransferV2), node.handleTransferV2Stream)
+	if node.compression != nil {
+		h.SetStreamHandler(protocol.ID(ProtocolTransferCompressed), node.handleCompressedTransferStream)
+	}
+	node.startHello()
+	node.startProbe()
+	node.startReceipts()
+	node.startReplication(cfg.ReplicationPartners)
 
 	// Start mDNS discovery if enabled
 	if cfg.EnableMDNS {
-		mdnsServiceName := "_debswarm._tcp"
-		mdnsService := mdns.NewMdnsService(h, mdnsServiceName, node)
+		mdnsService := newMDNSService(h, node.swarm, cfg.Version, cfg.Role, node.handleMDNSPeer)
 		if err := mdnsService.Start(); err != nil {
 			logger.Warn("Failed to start mDNS discovery",
-				zap.String("service", mdnsServiceName),
+				zap.String("service", MDNSServiceName),
 				zap.Error(err))
 		} else {
 			node.mdnsService = mdnsService
 			logger.Info("Started mDNS discovery for local peer discovery",
-				zap.String("service", mdnsServiceName),
+				zap.String("service", MDNSServiceName),
+				zap.String("swarm", node.swarm),
 				zap.Strings("listenAddrs", multiaddrsToStrings(h.Addrs())))
 		}
 	} else {
@@ -540,6 +735,9 @@ func New(ctx context.Context, cfg *Config, logger *zap.Logger) (*Node, error) {
 
 	// Bootstrap DHT
 	go node.bootstrap(ctx, cfg.BootstrapPeers)
+	if node.knownPeersPath() != "" {
+		go node.persistKnownPeers(ctx)
+	}
 
 	// Start periodic tasks
 	go node.periodicTasks()
@@ -568,62 +766,97 @@ func New(ctx context.Context, cfg *Config, logger *zap.Logger) (*Node, error) {
 	return node, nil
 }
 
-// SetContentGetter sets the function used to get content for serving to peers
-func (n *Node) SetContentGetter(getter ContentGetter) {
-	n.getContent = getter
+// SetContentProvider sets where content served to peers comes from
+func (n *Node) SetContentProvider(p ContentProvider) {
+	n.content = p
+}
+
+// SetTransferRecorder sets the function that accounts per-peer transfer bytes
+func (n *Node) SetTransferRecorder(recorder TransferRecorder) {
+	n.recordTransfer = recorder
+}
+
+// SetUploadRecorder sets the function that accounts per-package uploads
+func (n *Node) SetUploadRecorder(recorder UploadRecorder) {
+	n.recordUpload = recorder
+}
+
+// SetUploadGate sets the function that may refuse individual uploads
+func (n *Node) SetUploadGate(gate UploadGate) {
+	n.uploadGate = gate
+}
+
+// SetUploadPriority sets the function that picks uploads to prioritize
+func (n *Node) SetUploadPriority(priority UploadPriority) {
+	n.uploadPriority = priority
+}
+
+// SetDownloadThrottle sets the function that may limit downloads from peers
+func (n *Node) SetDownloadThrottle(throttle DownloadThrottle) {
+	n.throttle = throttle
+}
+
+// SetUploadThrottle sets the function that may limit uploads, to peers and
+// over other transports alike
+func (n *Node) SetUploadThrottle(throttle UploadThrottle) {
+	n.uploadThrottle = throttle
+}
+
+// AcceptsUploads reports whether the node serves content at all: it is not
+// paused and the power policy does not refuse uploads. Uploads made over
+// another transport, such as the fleet's HTTPS fallback, ask it first.
+func (n *Node) AcceptsUploads() bool {
+	return !n.paused.Load() && !n.uploadsDisabled()
+}
+
+// ThrottleUpload wraps w, the destination of a size-byte upload made over
+// another transport, in the node's upload rate limit, power throttle and
+// upload throttle.
+func (n *Node) ThrottleUpload(ctx context.Context, w io.Writer, size int64) io.Writer {
+	if n.uploadLimiter.Enabled() {
+		w = n.uploadLimiter.WriterContextSize(ctx, w, size)
+	}
+	if throttle := n.powerThrottle(); throttle != nil {
+		w = throttle.WriterContextSize(ctx, w, size)
+	}
+	if n.uploadThrottle != nil {
+		w = n.uploadThrottle(ctx, w, size)
+	}
+	return w
 }
 
 // bootstrap connects to bootstrap peers and initializes the DHT
 func (n *Node) bootstrap(ctx context.Context, bootstrapPeers []string) {
 	defer close(n.bootstrapDone)
 
-	n.logger.Info("Starting DHT bootstrap", zap.Int("bootstrapPeers", len(bootstrapPeers)))
+	start := time.Now()
+	known := n.loadKnownPeerInfos()
+	n.logger.Info("Starting DHT bootstrap",
+		zap.Int("bootstrapPeers", len(bootstrapPeers)),
+		zap.Int("knownPeers", len(known)))
 
-	// Connect to bootstrap peers
-	var wg sync.WaitGroup
-	for _, addr := range bootstrapPeers {
-		ma, err := multiaddr.NewMultiaddr(addr)
-		if err != nil {
-			n.logger.Warn("Invalid bootstrap address", zap.String("addr", sanitize.String(addr)), zap.Error(err))
-			continue
-		}
-
-		peerInfo, err := peer.AddrInfoFromP2pAddr(ma)
-		if err != nil {
-			n.logger.Warn("Failed to parse bootstrap peer", zap.Error(err))
-			continue
-		}
-
-		wg.Add(1)
-		go func(connectCtx context.Context, pi *peer.AddrInfo) {
-			defer wg.Done()
-			timeout := n.timeouts.Get(timeouts.OpPeerConnect)
-			timeoutCtx, cancel := context.WithTimeout(connectCtx, timeout)
-			defer cancel()
-
-			start := time.Now()
-			if connectErr := n.host.Connect(timeoutCtx, *pi); connectErr != nil {
-				n.logger.Debug("Failed to connect to bootstrap peer",
-					zap.String("peer", pi.ID.String()),
-					zap.Error(connectErr))
-				n.timeouts.RecordFailure(timeouts.OpPeerConnect)
-			} else {
-				n.logger.Debug("Connected to bootstrap peer",
-					zap.String("peer", pi.ID.String()))
-				n.timeouts.RecordSuccess(timeouts.OpPeerConnect, time.Since(start))
-			}
-		}(ctx, peerInfo)
+	// Connect to bootstrap peers, and to the peers remembered from the last
+	// run in case the configured ones are down
+	infos := resolveBootstrapPeers(ctx, n.resolver, bootstrapPeers, n.logger)
+	connected := n.connectBootstrapPeers(ctx, mergeBootstrapInfos(infos, known), n.bootstrapMinPeers)
+	n.logger.Debug("Bootstrap dials done", zap.Int("connected", connected),
+		zap.Duration("elapsed", time.Since(start)))
+	if n.bootstrapResolveInterval > 0 && hasDNSBootstrap(bootstrapPeers) {
+		go n.refreshBootstrapPeers(ctx, bootstrapPeers, infos, n.bootstrapResolveInterval)
 	}
-	wg.Wait()
 
 	// Bootstrap the DHT
 	if bootstrapErr := n.dht.Bootstrap(ctx); bootstrapErr != nil {
 		n.logger.Error("DHT bootstrap failed", zap.Error(bootstrapErr))
+		n.audit.Log(audit.NewBootstrapEvent("startup", len(n.host.Network().Peers()), 0,
+			time.Since(start).Milliseconds(), bootstrapErr.Error()))
 		return
 	}
 
 	n.logger.Info("DHT bootstrap complete",
 		zap.Int("routingTableSize", n.dht.RoutingTable().Size()))
+	n.audit.Log(audit.NewBootstrapEvent("startup", len(n.host.Network().Peers()),
+		n.dht.RoutingTable().Size(), time.Since(start).Milliseconds(), ""))
 
 	// Update metrics
 	if n.metrics != nil {
@@ -720,12 +953,23 @@ func (n *Node) Provide(ctx context.Context, sha256Hash string) error {
 			zap.String("hash", sha256Hash[:16]+"..."))
 		return nil
 	}
+	if n.paused.Load() {
+		return ErrPaused
+	}
+
+	if err := n.provideBudget.acquire(ctx, priorityFrom(ctx)); err != nil {
+		if n.metrics != nil {
+			n.metrics.DHTBudgetRejected.WithLabel("provide").Inc()
+		}
+		return fmt.Errorf("failed to provide: %w", err)
+	}
 
 	key := NamespacePackage + sha256Hash
 
 	var timer *metrics.Timer
 	if n.metrics != nil {
 		timer = metrics.NewTimer(n.metrics.DHTLookupDuration)
+		n.metrics.DHTQueries.WithLabel("provide").Inc()
 	} else {
 		timer = metrics.NewTimer(nil)
 	}
@@ -747,6 +991,19 @@ func (n *Node) Provide(ctx context.Context, sha256Hash string) error {
 
 // FindProviders searches the DHT for peers that have a package
 func (n *Node) FindProviders(ctx context.Context, sha256Hash string, limit int) ([]peer.AddrInfo, error) {
+	if n.paused.Load() {
+		return nil, ErrPaused
+	}
+	if err := n.lookupBudget.acquire(ctx, priorityFrom(ctx)); err != nil {
+		if n.metrics != nil {
+			n.metrics.DHTBudgetRejected.WithLabel("lookup").Inc()
+		}
+		return nil, fmt.Errorf("failed to find providers: %w", err)
+	}
+	if err := n.chaos.DelayDHT(ctx); err != nil {
+		return nil, fmt.Errorf("failed to find providers: %w", err)
+	}
+
 	key := NamespacePackage + sha256Hash
 
 	var timer *metrics.Timer
@@ -786,10 +1043,16 @@ func (n *Node) FindProviders(ctx context.Context, sha256Hash string, limit int)
 	duration := timer.ObserveDuration()
 	n.timeouts.RecordSuccess(timeouts.OpDHTLookup, duration)
 
-	// Filter out providers with blocked/private IP addresses (defense against eclipse attacks)
+	// Filter out providers with blocked/private IP addresses (defense against eclipse attacks),
+	// except those in networks the allowlist admits
 	filtered := make([]peer.AddrInfo, 0, len(providers))
 	for _, p := range providers {
-		allowedAddrs := security.FilterBlockedAddrs(p.Addrs)
+		allowedAddrs := make([]multiaddr.Multiaddr, 0, len(p.Addrs))
+		for _, addr := range p.Addrs {
+			if !security.IsBlockedMultiaddr(addr) || n.gater.AllowsAddr(addr) {
+				allowedAddrs = append(allowedAddrs, addr)
+			}
+		}
 		if len(allowedAddrs) > 0 {
 			filtered = append(filtered, peer.AddrInfo{
 				ID:    p.ID,
@@ -811,8 +1074,11 @@ func (n *Node) FindProvidersRanked(ctx context.Context, sha256Hash string, limit
 		return nil, err
 	}
 
-	// Use scorer to select best peers, with some diversity
-	return n.scorer.SelectDiverse(providers, limit), nil
+	// Use scorer to select best peers, with some diversity, then let the
+	// peers' own capability reports skip busy ones
+	selected := n.scorer.SelectDiverse(providers, limit)
+	n.refreshCapabilities(ctx, selected)
+	return n.applyCapabilities(selected), nil
 }
 
 // Download attempts to download a package from a peer
@@ -821,15 +1087,25 @@ func (n *Node) Download(ctx context.Context, peerInfo peer.AddrInfo, sha256Hash
 }
 
 // rangeRequestLen is the fixed wire size of a range-transfer request:
-// hash(64) + start(8, big-endian) + end(8, big-endian) + newline terminator.
+// hash(64) + start(8, big-endian) + end(8, big-endian) + terminator.
 const rangeRequestLen = 64 + 16 + 1
 
+// The terminator of a range-transfer request carries the priority.Class of
+// the transfer. It was always a newline, which servers never checked, so an
+// older server ignores the class and an older client's requests are
+// interactive.
+const (
+	terminatorInteractive = '\n'
+	terminatorReplication = 'r'
+	terminatorPrefetch    = 'p'
+)
+
 // encodeRangeRequest builds the fixed-size range-transfer request frame. start
 // must be non-negative; end < 0 (to-EOF) is encoded as 0, which the server reads
 // as "to end of file". The frame is a fixed binary layout and must be decoded by
 // length (see decodeRangeRequest), never scanned for the trailing newline — the
 // big-endian offsets can legitimately contain the newline byte (0x0A).
-func encodeRangeRequest(sha256Hash string, start, end int64) []byte {
+func encodeRangeRequest(sha256Hash string, start, end int64, class priority.Class) []byte {
 	req := make([]byte, rangeRequestLen)
 	copy(req, sha256Hash)
 	if start < 0 {
@@ -840,7 +1116,14 @@ func encodeRangeRequest(sha256Hash string, start, end int64) []byte {
 		end = 0
 	}
 	binary.BigEndian.PutUint64(req[72:80], uint64(end)) // #nosec G115 -- end >= 0 above
-	req[rangeRequestLen-1] = '\n'
+	switch class {
+	case priority.Replication:
+		req[rangeRequestLen-1] = terminatorReplication
+	case priority.Prefetch:
+		req[rangeRequestLen-1] = terminatorPrefetch
+	default:
+		req[rangeRequestLen-1] = terminatorInteractive
+	}
 	return req
 }
 
@@ -848,25 +1131,57 @@ func encodeRangeRequest(sha256Hash string, start, end int64) []byte {
 // encodeRangeRequest. It reads exactly rangeRequestLen bytes rather than scanning
 // for a newline, so offsets containing 0x0A are handled correctly. The returned
 // end is 0 for a to-EOF request (the caller treats end<=0 as "to end of file").
-func decodeRangeRequest(r io.Reader) (sha256Hash string, start, end int64, err error) {
+// An unknown terminator is interactive.
+func decodeRangeRequest(r io.Reader) (sha256Hash string, start, end int64, class priority.Class, err error) {
 	buf := make([]byte, rangeRequestLen)
 	if _, err = io.ReadFull(r, buf); err != nil {
-		return "", 0, 0, err
+		return "", 0, 0, priority.Interactive, err
 	}
 	startU64 := binary.BigEndian.Uint64(buf[64:72])
 	endU64 := binary.BigEndian.Uint64(buf[72:80])
 	// Validate values fit in int64 to prevent overflow.
 	if startU64 > math.MaxInt64 || endU64 > math.MaxInt64 {
-		return "", 0, 0, fmt.Errorf("range values overflow int64: start=%d end=%d", startU64, endU64)
+		return "", 0, 0, priority.Interactive, fmt.Errorf("range values overflow int64: start=%d end=%d", startU64, endU64)
 	}
-	return string(buf[:64]), int64(startU64), int64(endU64), nil
+	switch buf[rangeRequestLen-1] {
+	case terminatorReplication:
+		class = priority.Replication
+	case terminatorPrefetch:
+		class = priority.Prefetch
+	}
+	return string(buf[:64]), int64(startU64), int64(endU64), class, nil
+}
+
+// transferProfile classifies the connection a transfer rides for its
+// deadline: a direct connection to a private or loopback address is LAN,
+// anything else, relays included, is WAN.
+func transferProfile(remote multiaddr.Multiaddr, relayed bool) timeouts.Profile {
+	if relayed || remote == nil {
+		return timeouts.ProfileWAN
+	}
+	ip, err := manet.ToIP(remote)
+	if err != nil || !(ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast()) {
+		return timeouts.ProfileWAN
+	}
+	return timeouts.ProfileLAN
 }
 
 // DownloadRange downloads a range of bytes from a peer
 // If end is -1, downloads from start to end of file
 func (n *Node) DownloadRange(ctx context.Context, peerInfo peer.AddrInfo, sha256Hash string, start, end int64) ([]byte, error) {
+	if n.paused.Load() {
+		return nil, ErrPaused
+	}
 	startTime := time.Now()
 
+	// A peer whose circuit breaker is open is skipped outright, so an outage
+	// does not cost a connect timeout per attempt.
+	if !n.scorer.AllowAttempt(peerInfo.ID) {
+		return nil, peers.ErrCircuitOpen
+	}
+	n.trackDownload(peerInfo.ID, 1)
+	defer n.trackDownload(peerInfo.ID, -1)
+
 	// Connect to peer if not already connected. A relayed (Limited) connection
 	// counts as connected here; whether we may actually transfer over it is decided
 	// below, once we know if a direct path exists.
@@ -905,19 +1220,34 @@ func (n *Node) DownloadRange(ctx context.Context, peerInfo peer.AddrInfo, sha256
 		streamCtx = network.WithAllowLimitedConn(ctx, "debswarm-transfer")
 	}
 
-	// Choose protocol based on whether we need a range
+	// Choose protocol based on whether we need a range. Only the range
+	// frame carries a priority class, so background transfers use it for
+	// whole files too.
+	class := priority.Of(ctx)
 	proto := ProtocolTransfer
-	if start > 0 || end > 0 {
+	if start > 0 || end > 0 || class.Background() {
 		proto = ProtocolTransferRange
 	}
 
-	// Open stream
-	stream, err := n.host.NewStream(streamCtx, peerInfo.ID, protocol.ID(proto))
+	// Open stream, preferring compressed transfers when both ends support
+	// them, then the protocol that describes the content. An idle session
+	// to the peer is reused if the pool has one.
+	protos := []protocol.ID{ProtocolTransferV2, protocol.ID(proto)}
+	if n.compression != nil {
+		protos = append([]protocol.ID{ProtocolTransferCompressed}, protos...)
+	}
+	stream, reused, err := n.openTransferStream(streamCtx, peerInfo.ID, protos)
 	if err != nil {
 		n.scorer.RecordFailure(peerInfo.ID, "stream failed")
 		return nil, fmt.Errorf("failed to open stream: %w", err)
 	}
-	defer stream.Close()
+	// A session whose response was read in full goes back to the pool,
+	// unless ctx was canceled and the stream may have been reset
+	reusable := false
+	defer func() { n.releaseTransferStream(stream, reusable && ctx.Err() == nil) }()
+	compressed := stream.Protocol() == ProtocolTransferCompressed
+	withInfo := stream.Protocol() == ProtocolTransferV2
+	n.timeouts.SetPeerProfile(peerInfo.ID.String(), transferProfile(stream.Conn().RemoteMultiaddr(), relayed))
 
 	// Reset the stream if ctx is canceled mid-transfer — e.g. this source lost
 	// a download race. The blocking reads below don't observe ctx (only the
@@ -926,8 +1256,13 @@ func (n *Node) DownloadRange(ctx context.Context, peerInfo peer.AddrInfo, sha256
 	// peer's limited upload slots until its deadline. Reset unblocks the local
 	// read immediately and signals the remote to stop sending.
 	transferDone := make(chan struct{})
-	defer close(transferDone)
+	watchDone := make(chan struct{})
+	defer func() {
+		close(transferDone)
+		<-watchDone
+	}()
 	go func() {
+		defer close(watchDone)
 		select {
 		case <-ctx.Done():
 			_ = stream.Reset()
@@ -945,17 +1280,28 @@ func (n *Node) DownloadRange(ctx context.Context, peerInfo peer.AddrInfo, sha256
 		n.scorer.RecordFailure(peerInfo.ID, reason)
 		return err
 	}
+	// A reused session failing before the response began was most likely
+	// closed by the peer while idle, which is not the peer's fault either
+	requestFailure := func(reason string, err error) error {
+		if reused && ctx.Err() == nil {
+			return fmt.Errorf("reused stream: %w", err)
+		}
+		return transferFailure(reason, err)
+	}
+
+	if n.chaos.DropStream(peerInfo.ID.String()) {
+		_ = stream.Reset()
+		return nil, transferFailure("chaos: stream dropped", fmt.Errorf("stream dropped by chaos testing"))
+	}
 
 	// Set initial stream deadline for the request/response header phase.
 	// This prevents io.ReadFull from blocking forever on unresponsive peers.
 	// Will be extended after reading the actual transfer size.
-	if deadlineErr := stream.SetDeadline(time.Now().Add(30 * time.Second)); deadlineErr != nil {
-		n.logger.Debug("Failed to set client stream deadline", zap.Error(deadlineErr))
-	}
+	n.setTransferDeadline(stream, 30*time.Second)
 
 	// Send request
 	var request []byte
-	if proto == ProtocolTransferRange {
+	if proto == ProtocolTransferRange || compressed || withInfo {
 		// Validate range values to prevent integer overflow
 		if start < 0 {
 			return nil, fmt.Errorf("invalid range: start=%d (negative start not allowed)", start)
@@ -963,20 +1309,20 @@ func (n *Node) DownloadRange(ctx context.Context, peerInfo peer.AddrInfo, sha256
 		if end < -1 {
 			return nil, fmt.Errorf("invalid range: end=%d (must be >= -1)", end)
 		}
-		request = encodeRangeRequest(sha256Hash, start, end)
+		request = encodeRangeRequest(sha256Hash, start, end, class)
 	} else {
 		// Simple request: hash + newline
 		request = []byte(sha256Hash + "\n")
 	}
 
 	if _, err := stream.Write(request); err != nil {
-		return nil, transferFailure("write failed", fmt.Errorf("failed to send request: %w", err))
+		return nil, requestFailure("write failed", fmt.Errorf("failed to send request: %w", err))
 	}
 
 	// Read response size (8 bytes)
 	sizeBuf := make([]byte, 8)
 	if _, err := io.ReadFull(stream, sizeBuf); err != nil {
-		return nil, transferFailure("read size failed", fmt.Errorf("failed to read size: %w", err))
+		return nil, requestFailure("read size failed", fmt.Errorf("failed to read size: %w", err))
 	}
 
 	sizeU64 := binary.BigEndian.Uint64(sizeBuf)
@@ -993,6 +1339,26 @@ func (n *Node) DownloadRange(ctx context.Context, peerInfo peer.AddrInfo, sha256
 		return nil, fmt.Errorf("content too large: %d bytes", size)
 	}
 
+	// The content header tells what the peer is about to send, so a range
+	// answered differently than asked, or a file of the wrong size, is
+	// refused before any content is read
+	if withInfo {
+		info, err := readContentInfo(stream)
+		if err != nil {
+			return nil, transferFailure("read header failed", err)
+		}
+		if err := info.validate(start, end, size); err != nil {
+			_ = stream.Reset()
+			return nil, transferFailure("bad content header", err)
+		}
+		if check := contentCheckFrom(ctx); check != nil {
+			if err := check(info); err != nil {
+				_ = stream.Reset()
+				return nil, transferFailure("content rejected", err)
+			}
+		}
+	}
+
 	// A relayed transfer is bounded: refuse anything larger than the configured cap
 	// so a relay only ever carries small packages. The relay's own per-circuit Data
 	// budget is an independent hard ceiling; this is the client-side bound. Reset the
@@ -1005,11 +1371,12 @@ func (n *Node) DownloadRange(ctx context.Context, peerInfo peer.AddrInfo, sha256
 		return nil, fmt.Errorf("relayed transfer too large: %d bytes exceeds cap %d", size, n.relayedTransferMax)
 	}
 
-	// Extend stream deadline based on actual transfer size
-	transferDeadline := n.timeouts.GetForSize(timeouts.OpPeerTransfer, size)
-	if deadlineErr := stream.SetDeadline(time.Now().Add(transferDeadline)); deadlineErr != nil {
-		n.logger.Debug("Failed to extend stream deadline", zap.Error(deadlineErr))
-	}
+	// Extend stream deadline based on actual transfer size, allowing at least
+	// as long as this peer's profile does so a slow WAN peer is not cut off
+	// before the downloader's own chunk deadline
+	transferDeadline := max(n.timeouts.GetForSize(timeouts.OpPeerTransfer, size),
+		n.timeouts.PeerTransferTimeout(peerInfo.ID.String(), size))
+	n.setTransferDeadline(stream, transferDeadline)
 
 	// Read content with rate limiting (per-peer if available, else global).
 	// Cap initial allocation to prevent OOM from peer-controlled size values.
@@ -1020,10 +1387,25 @@ func (n *Node) DownloadRange(ctx context.Context, peerInfo peer.AddrInfo, sha256
 	var reader io.Reader = stream
 	if n.peerDownloadLimiter != nil && n.peerDownloadLimiter.Enabled() {
 		// Use per-peer limiter (includes global limiting via composed reader)
-		reader = n.peerDownloadLimiter.ReaderContext(ctx, peerInfo.ID, stream)
+		reader = n.peerDownloadLimiter.ReaderContextSize(ctx, peerInfo.ID, stream, size)
 	} else if n.downloadLimiter.Enabled() {
 		// Fall back to global limiter only
-		reader = n.downloadLimiter.ReaderContext(ctx, stream)
+		reader = n.downloadLimiter.ReaderContextSize(ctx, stream, size)
+	}
+	if n.wanDownloadLimiter.Enabled() && !n.isLANConn(stream.Conn()) {
+		reader = n.wanDownloadLimiter.ReaderContextSize(ctx, reader, size)
+	}
+	if n.throttle != nil {
+		reader = n.throttle(ctx, reader)
+	}
+	// Limits apply to the bytes on the wire, before decompression
+	if compressed {
+		decoded, release, err := decodedReader(reader, size)
+		if err != nil {
+			return nil, transferFailure("read data failed", err)
+		}
+		defer release()
+		reader = decoded
 	}
 	if size <= maxInitialAlloc {
 		// Small transfer: single allocation already sized correctly
@@ -1043,6 +1425,10 @@ func (n *Node) DownloadRange(ctx context.Context, peerInfo peer.AddrInfo, sha256
 		}
 		data = append(data, tail...)
 	}
+	reusable = true
+	n.collectReceipt(peerInfo.ID, sha256Hash, start, data)
+	// Chaos testing corrupts here so the caller's hash check has to catch it
+	n.chaos.Corrupt(data, peerInfo.ID.String())
 
 	// Record success
 	duration := time.Since(startTime)
@@ -1050,12 +1436,17 @@ func (n *Node) DownloadRange(ctx context.Context, peerInfo peer.AddrInfo, sha256
 	throughput := float64(size) / duration.Seconds()
 
 	n.scorer.RecordSuccess(peerInfo.ID, size, latencyMs, throughput)
+	if n.recordTransfer != nil {
+		n.recordTransfer(peerInfo.ID, 0, size)
+	}
 	n.timeouts.RecordSuccess(timeouts.OpPeerTransfer, duration)
+	n.timeouts.RecordPeerTransfer(peerInfo.ID.String(), size, duration)
 
 	if n.metrics != nil {
 		n.metrics.BytesDownloaded.WithLabel("peer").Add(size)
 		n.metrics.DownloadsTotal.WithLabel("peer").Inc()
 		n.metrics.PeerLatency.Observe(latencyMs)
+		n.metrics.Peers.Download(peerInfo.ID.String(), size, latencyMs)
 		if relayed {
 			// Relayed bytes are a subset of peer bytes, tracked separately so an
 			// operator can see what a relay is actually carrying.
@@ -1069,63 +1460,83 @@ func (n *Node) DownloadRange(ctx context.Context, peerInfo peer.AddrInfo, sha256
 
 // handleTransferStream handles incoming transfer requests (full file)
 func (n *Node) handleTransferStream(stream network.Stream) {
-	n.handleTransferRequest(stream, false)
+	n.handleTransferRequest(stream, false, false)
 }
 
 // handleRangeTransferStream handles incoming range transfer requests
 func (n *Node) handleRangeTransferStream(stream network.Stream) {
-	n.handleTransferRequest(stream, true)
+	n.handleTransferRequest(stream, true, false)
+}
+
+// handleCompressedTransferStream handles incoming compressed transfer requests
+func (n *Node) handleCompressedTransferStream(stream network.Stream) {
+	n.handleTransferRequest(stream, true, true)
+}
+
+// handleTransferV2Stream handles incoming transfer requests answered with a
+// content header. The stream is a session: once a response is sent in full
+// it waits up to transferSessionIdle for the peer's next request.
+func (n *Node) handleTransferV2Stream(stream network.Stream) {
+	defer stream.Close()
+
+	// Range frames are read by length, so one buffered reader serves the
+	// whole session without losing bytes between requests
+	bufReader := bufio.NewReaderSize(stream, rangeRequestLen)
+	for n.serveTransfer(stream, bufReader, true, false, true) {
+		if err := stream.SetDeadline(time.Now().Add(transferSessionIdle)); err != nil {
+			return
+		}
+		if _, err := bufReader.Peek(1); err != nil {
+			return
+		}
+	}
 }
 
-func (n *Node) handleTransferRequest(stream network.Stream, rangeSupport bool) {
+// maxRequestSize bounds what is read of a version 1 request, so a peer
+// sending unbounded data without a newline cannot exhaust memory. Max
+// legitimate request: 64 (hash) + 16 (range) + 1 (newline) = 81 bytes.
+const maxRequestSize = 256
+
+// handleTransferRequest serves the one request of a version 1 stream.
+func (n *Node) handleTransferRequest(stream network.Stream, rangeSupport, compressed bool) {
 	defer stream.Close()
+	n.serveTransfer(stream, bufio.NewReader(io.LimitReader(stream, maxRequestSize)), rangeSupport, compressed, false)
+}
 
+// serveTransfer reads a request from bufReader and answers it on stream. It
+// reports whether the response was sent in full, leaving the stream ready
+// for another request.
+func (n *Node) serveTransfer(stream network.Stream, bufReader *bufio.Reader, rangeSupport, compressed, withInfo bool) bool {
 	// Set stream deadline to prevent slowloris attacks
 	// If this fails, subsequent I/O operations may hang indefinitely, so bail out
 	if err := stream.SetDeadline(time.Now().Add(2 * time.Minute)); err != nil {
 		n.logger.Warn("Failed to set stream deadline, rejecting request", zap.Error(err))
-		return
+		return false
 	}
 
 	peerID := stream.Conn().RemotePeer()
 
-	// Check upload limits and atomically reserve a slot
-	if !n.tryAcceptUpload(peerID) {
-		_ = n.writeSize(stream, 0)
-		return
-	}
-	defer n.trackUploadEnd(peerID)
-
-	if n.metrics != nil {
-		n.metrics.ActiveUploads.Inc()
-		defer n.metrics.ActiveUploads.Dec()
-	}
-
-	// Read request using buffered reader with a size limit to prevent
-	// memory exhaustion from malicious peers sending unbounded data without a newline.
-	// Max legitimate request: 64 (hash) + 16 (range) + 1 (newline) = 81 bytes.
-	const maxRequestSize = 256
-	bufReader := bufio.NewReader(io.LimitReader(stream, maxRequestSize))
 	var sha256Hash string
 	var start, end int64 = 0, -1
+	var class priority.Class
 
 	if rangeSupport {
 		// Range request is a fixed-size binary frame; it must be read by length,
 		// not scanned for a newline, because the binary offsets can contain the
 		// newline byte (0x0A) and truncate a newline-delimited read.
 		var derr error
-		sha256Hash, start, end, derr = decodeRangeRequest(bufReader)
+		sha256Hash, start, end, class, derr = decodeRangeRequest(bufReader)
 		if derr != nil {
 			if derr != io.EOF {
 				n.logger.Debug("Failed to decode range request", zap.Error(derr))
 			}
-			return
+			return false
 		}
 	} else {
 		// Simple request: hash + newline
 		line, err := bufReader.ReadBytes('\n')
 		if err != nil {
-			return
+			return false
 		}
 		// Remove newline
 		if len(line) > 0 && line[len(line)-1] == '\n' {
@@ -1137,27 +1548,61 @@ func (n *Node) handleTransferRequest(stream network.Stream, rangeSupport bool) {
 	if len(sha256Hash) != 64 {
 		n.logger.Debug("Invalid hash length", zap.Int("length", len(sha256Hash)))
 		_ = n.writeSize(stream, 0)
-		return
+		return false
 	}
 
 	// Validate hex
 	if _, err := hex.DecodeString(sha256Hash); err != nil {
 		n.logger.Debug("Invalid hash format", zap.Error(err))
 		_ = n.writeSize(stream, 0)
-		return
+		return false
+	}
+
+	// Check upload limits and atomically reserve a slot. The request is read
+	// first so that security updates can take the slots reserved for them,
+	// and background transfers leave room for interactive ones.
+	urgent := n.uploadPriority != nil && n.uploadPriority(sha256Hash)
+	leecher := n.isLeecher(peerID)
+	if !n.tryAcceptUpload(peerID, urgent, class) {
+		if leecher && n.metrics != nil {
+			n.metrics.SharingLeecherUploads.WithLabel("refused").Inc()
+		}
+		_ = n.writeSize(stream, 0)
+		return false
+	}
+	defer n.trackUploadEnd(peerID)
+
+	// A paused node serves nothing; a size of 0 is the usual "not available".
+	if !n.trackUploadStream(stream) {
+		_ = n.writeSize(stream, 0)
+		return false
+	}
+	defer n.untrackUploadStream(stream)
+
+	if n.metrics != nil {
+		n.metrics.ActiveUploads.Inc()
+		defer n.metrics.ActiveUploads.Dec()
 	}
 
 	// Get content
-	if n.getContent == nil {
+	if n.content == nil {
 		_ = n.writeSize(stream, 0)
-		return
+		return false
 	}
 
-	reader, totalSize, err := n.getContent(sha256Hash)
+	if n.uploadGate != nil {
+		if err := n.uploadGate(sha256Hash, peerID); err != nil {
+			n.logger.Debug("Upload refused by gate", zap.String("hash", sha256Hash[:16]+"..."), zap.Error(err))
+			_ = n.writeSize(stream, 0)
+			return false
+		}
+	}
+
+	reader, totalSize, err := n.content.Open(sha256Hash)
 	if err != nil {
 		n.logger.Debug("Content not found", zap.String("hash", sha256Hash[:16]+"..."))
 		_ = n.writeSize(stream, 0)
-		return
+		return false
 	}
 	defer reader.Close()
 
@@ -1176,7 +1621,7 @@ func (n *Node) handleTransferRequest(stream network.Stream, rangeSupport bool) {
 			zap.Int64("end", end),
 			zap.String("hash", sha256Hash[:16]+"..."))
 		_ = n.writeSize(stream, 0)
-		return
+		return false
 	}
 	if start >= totalSize {
 		n.logger.Debug("Invalid range: start >= totalSize",
@@ -1184,7 +1629,7 @@ func (n *Node) handleTransferRequest(stream network.Stream, rangeSupport bool) {
 			zap.Int64("totalSize", totalSize),
 			zap.String("hash", sha256Hash[:16]+"..."))
 		_ = n.writeSize(stream, 0)
-		return
+		return false
 	}
 
 	responseSize := end - start
@@ -1194,20 +1639,27 @@ func (n *Node) handleTransferRequest(stream network.Stream, rangeSupport bool) {
 		if seeker, ok := reader.(io.Seeker); ok {
 			if _, seekErr := seeker.Seek(start, io.SeekStart); seekErr != nil {
 				_ = n.writeSize(stream, 0)
-				return
+				return false
 			}
 		} else {
 			// Can't seek, read and discard
 			if _, discardErr := io.CopyN(io.Discard, reader, start); discardErr != nil {
 				_ = n.writeSize(stream, 0)
-				return
+				return false
 			}
 		}
 	}
 
 	// Send size — if this fails, the peer will read misaligned data, so abort
 	if err := n.writeSize(stream, responseSize); err != nil {
-		return
+		return false
+	}
+	if withInfo {
+		info := ContentInfo{TotalSize: totalSize, Start: start, End: end, Filename: n.contentName(sha256Hash)}
+		if err := writeContentInfo(stream, info); err != nil {
+			n.logger.Debug("Failed to write content header", zap.Error(err))
+			return false
+		}
 	}
 
 	// Send content (limited to response size) with rate limiting (per-peer if available, else global)
@@ -1215,17 +1667,57 @@ func (n *Node) handleTransferRequest(stream network.Stream, rangeSupport bool) {
 	var writer io.Writer = stream
 	if n.peerUploadLimiter != nil && n.peerUploadLimiter.Enabled() {
 		// Use per-peer limiter (includes global limiting via composed writer)
-		writer = n.peerUploadLimiter.WriterContext(n.ctx, peerID, stream)
+		writer = n.peerUploadLimiter.WriterContextSize(n.ctx, peerID, stream, responseSize)
 	} else if n.uploadLimiter.Enabled() {
 		// Fall back to global limiter only
-		writer = n.uploadLimiter.WriterContext(n.ctx, stream)
+		writer = n.uploadLimiter.WriterContextSize(n.ctx, stream, responseSize)
+	}
+	if n.wanUploadLimiter.Enabled() && !n.isLANConn(stream.Conn()) {
+		writer = n.wanUploadLimiter.WriterContextSize(n.ctx, writer, responseSize)
+	}
+	if throttle := n.powerThrottle(); throttle != nil {
+		writer = throttle.WriterContextSize(n.ctx, writer, responseSize)
+	}
+	if n.uploadThrottle != nil {
+		writer = n.uploadThrottle(n.ctx, writer, responseSize)
+	}
+	// Security updates reach every peer at full speed, leechers included;
+	// the node's own rate limits still apply.
+	if leecher && !urgent {
+		if n.sharing.LeecherUploadRate > 0 {
+			writer = ratelimit.New(n.sharing.LeecherUploadRate).WriterContext(n.ctx, writer)
+		}
+		if n.metrics != nil {
+			n.metrics.SharingLeecherUploads.WithLabel("throttled").Inc()
+		}
+		n.logger.Debug("Throttling upload to peer below the sharing ratio",
+			zap.String("peer", peerID.String()))
+	}
+	// The bytes sent are hashed for the receipt the peer may ask for
+	digest := sha256.New()
+	src := io.TeeReader(io.LimitReader(reader, responseSize), digest)
+	var written int64
+	if compressed {
+		br := bufio.NewReader(src)
+		written, err = n.writeEncoded(writer, br, responseSize, n.chooseEncoding(br, responseSize, stream.Conn()))
+	} else {
+		written, err = io.CopyN(writer, src, responseSize)
 	}
-	written, err := io.CopyN(writer, reader, responseSize)
+	// Bytes sent before a failure still count towards the peer's share.
+	if n.recordTransfer != nil {
+		n.recordTransfer(peerID, written, 0)
+	}
+	if n.recordUpload != nil {
+		n.recordUpload(sha256Hash, written, err == nil && end == totalSize)
+	}
+	n.admission.noteUpload(written)
 	if err != nil {
 		n.logger.Debug("Failed to send content", zap.Error(err))
-		return
+		return false
 	}
 
+	n.uploads.add(uploadKey{peer: peerID, sha256: sha256Hash, start: start, end: end}, hex.EncodeToString(digest.Sum(nil)))
+
 	n.logger.Debug("Sent content to peer",
 		zap.String("peer", peerID.String()),
 		zap.String("hash", sha256Hash[:16]+"..."),
@@ -1237,10 +1729,15 @@ func (n *Node) handleTransferRequest(stream network.Stream, rangeSupport bool) {
 	n.scorer.RecordUpload(peerID, written)
 	if n.metrics != nil {
 		n.metrics.BytesUploaded.Add(written)
+		n.metrics.Peers.Upload(peerID.String(), written)
+		if urgent {
+			n.metrics.PriorityUploads.Inc()
+		}
 	}
 
 	// Audit log upload complete
-	n.audit.Log(audit.NewUploadCompleteEvent(sha256Hash, written, peerID.String(), 0))
+	n.audit.Log(audit.NewUploadCompleteEvent(sha256Hash, written, peerID.String(), 0).WithPeerName(n.scorer.Name(peerID)))
+	return true
 }
 
 func (n *Node) writeSize(stream network.Stream, size int64) error {
@@ -1259,15 +1756,36 @@ func (n *Node) writeSize(stream network.Stream, size int64) error {
 
 // tryAcceptUpload atomically checks upload limits and reserves a slot.
 // Returns true if the upload was accepted, false if limits are exceeded.
-func (n *Node) tryAcceptUpload(peerID peer.ID) bool {
+// An urgent upload may also take one of the slots reserved beyond the
+// limits (see priorityUploadSlots), and one more per peer, so security
+// updates are not queued behind bulk transfers or refused to leechers.
+// The slots reserved stay available while admission control lowers the
+// limit, but not while the power policy disables uploads. A background
+// upload of class is held to backgroundUploadLimit, whether urgent or not.
+func (n *Node) tryAcceptUpload(peerID peer.ID, urgent bool, class priority.Class) bool {
+	if n.uploadsDisabled() {
+		return false
+	}
+	limit, perPeer := n.uploadLimit(), n.maxUploadsFor(peerID)
+	switch {
+	case class.Background():
+		limit = backgroundUploadLimit(limit, class)
+	case urgent:
+		limit += n.priorityUploadSlots()
+		perPeer++
+	}
+
 	n.uploadsMu.Lock()
 	defer n.uploadsMu.Unlock()
 
-	if n.activeUploads >= n.maxConcurrentUploads {
+	if n.activeUploads >= limit {
+		if class.Background() && n.activeUploads < n.uploadLimit() && n.metrics != nil {
+			n.metrics.BackgroundUploadsRefused.WithLabel(class.String()).Inc()
+		}
 		return false
 	}
 
-	if n.uploadsPerPeer[peerID] >= MaxUploadsPerPeer {
+	if n.uploadsPerPeer[peerID] >= perPeer {
 		return false
 	}
 
@@ -1276,6 +1794,25 @@ func (n *Node) tryAcceptUpload(peerID peer.ID) bool {
 	return true
 }
 
+// backgroundUploadLimit returns how many uploads may be running for a
+// background upload of class to be accepted, out of limit. A quarter of the
+// limit, at least one slot, is kept for interactive requests, so a client
+// running apt install on a peer is never refused for prefetch traffic;
+// prefetch may take half of the rest.
+func backgroundUploadLimit(limit int, class priority.Class) int {
+	background := limit - max(1, limit/4)
+	if class == priority.Prefetch {
+		background = (background + 1) / 2
+	}
+	return max(0, background)
+}
+
+// priorityUploadSlots returns how many uploads beyond maxConcurrentUploads
+// are reserved for priority content: a quarter of the limit, at least 2.
+func (n *Node) priorityUploadSlots() int {
+	return max(2, n.maxConcurrentUploads/4)
+}
+
 func (n *Node) trackUploadEnd(peerID peer.ID) {
 	n.uploadsMu.Lock()
 	defer n.uploadsMu.Unlock()
@@ -1296,7 +1833,7 @@ func (n *Node) UpdateRateLimits(uploadBytesPerSec, downloadBytesPerSec int64) {
 		zap.Int64("downloadRate", downloadBytesPerSec))
 }
 
-// HandlePeerFound implements mdns.Notifee
+// HandlePeerFound connects to a peer discovered on the LAN
 func (n *Node) HandlePeerFound(pi peer.AddrInfo) {
 	if pi.ID == n.host.ID() {
 		return
@@ -1342,6 +1879,41 @@ func (n *Node) RoutingTableSize() int        { return n.dht.RoutingTable().Size(
 func (n *Node) Scorer() *peers.Scorer        { return n.scorer }
 func (n *Node) Timeouts() *timeouts.Manager  { return n.timeouts }
 
+// RateLimitState is a snapshot of the node's transfer rate limiters.
+// Per-peer states are nil when per-peer limiting is off.
+type RateLimitState struct {
+	Upload       ratelimit.State
+	Download     ratelimit.State
+	WANUpload    ratelimit.State
+	WANDownload  ratelimit.State
+	PeerUpload   []ratelimit.PeerState
+	PeerDownload []ratelimit.PeerState
+}
+
+// isLANConn reports whether conn is to a LAN peer: one discovered via mDNS,
+// or connected over a private, loopback or link-local address. WAN rate
+// limits do not apply to LAN peers.
+func (n *Node) isLANConn(conn network.Conn) bool {
+	return n.scorer.IsLANPeer(peer.AddrInfo{ID: conn.RemotePeer(), Addrs: []multiaddr.Multiaddr{conn.RemoteMultiaddr()}})
+}
+
+// RateLimitState returns the current state of the rate limiters
+func (n *Node) RateLimitState() RateLimitState {
+	state := RateLimitState{
+		Upload:      n.uploadLimiter.State(),
+		Download:    n.downloadLimiter.State(),
+		WANUpload:   n.wanUploadLimiter.State(),
+		WANDownload: n.wanDownloadLimiter.State(),
+	}
+	if n.peerUploadLimiter != nil && n.peerUploadLimiter.Enabled() {
+		state.PeerUpload = n.peerUploadLimiter.Snapshot()
+	}
+	if n.peerDownloadLimiter != nil && n.peerDownloadLimiter.Enabled() {
+		state.PeerDownload = n.peerDownloadLimiter.Snapshot()
+	}
+	return state
+}
+
 // GetPeerStats returns statistics for all known peers
 func (n *Node) GetPeerStats() []*peers.PeerScore {
 	return n.scorer.GetAllStats()
@@ -1407,5 +1979,8 @@ func (n *Node) Close() error {
 		n.logger.Warn("Failed to close DHT", zap.Error(err))
 	}
 
+	// Remember who we were connected to for the next start
+	n.saveConnectedPeers()
+
 	return n.host.Close()
 }
@@ -2,6 +2,7 @@ package p2p
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/debswarm/debswarm/internal/metrics"
 	"github.com/debswarm/debswarm/internal/peers"
+	"github.com/debswarm/debswarm/internal/priority"
 	"github.com/debswarm/debswarm/internal/timeouts"
 )
 
@@ -243,7 +245,7 @@ func TestNode_Close(t *testing.T) {
 	node.Close()
 }
 
-func TestNode_SetContentGetter(t *testing.T) {
+func TestNode_SetContentProvider(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -256,19 +258,19 @@ func TestNode_SetContentGetter(t *testing.T) {
 	}
 	defer node.Close()
 
-	// Set a content getter
+	// Set a content provider
 	called := false
-	getter := func(hash string) (io.ReadCloser, int64, error) {
+	getter := ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
 		called = true
 		return nil, 0, nil
-	}
+	})
 
-	node.SetContentGetter(getter)
+	node.SetContentProvider(getter)
 
-	// Content getter should be set (we can't easily verify it's called
+	// Content provider should be set (we can't easily verify it's called
 	// without a full protocol exchange, but at least we verify no panic)
 	if called {
-		t.Error("Content getter should not be called on set")
+		t.Error("Content provider should not be called on set")
 	}
 }
 
@@ -539,7 +541,7 @@ func TestNode_TwoNodes_Connect(t *testing.T) {
 	}
 }
 
-func TestNode_Download_NoContentGetter(t *testing.T) {
+func TestNode_Download_NoContentProvider(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -569,11 +571,11 @@ func TestNode_Download_NoContentGetter(t *testing.T) {
 		t.Fatalf("Failed to connect: %v", err)
 	}
 
-	// Try to download - should fail because no content getter set
+	// Try to download - should fail because no content provider set
 	testHash := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
 	_, err = node2.Download(ctx, node1Info, testHash)
 	if err == nil {
-		t.Error("Download should fail when content getter is not set")
+		t.Error("Download should fail when content provider is not set")
 	}
 }
 
@@ -602,12 +604,12 @@ func TestNode_Download_Success(t *testing.T) {
 	testContent := []byte("test content for download")
 	testHash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
 
-	node1.SetContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+	node1.SetContentProvider(ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
 		if hash == testHash {
 			return io.NopCloser(strings.NewReader(string(testContent))), int64(len(testContent)), nil
 		}
 		return nil, 0, io.EOF
-	})
+	}))
 
 	// Connect nodes
 	node1Info := peer.AddrInfo{
@@ -654,12 +656,12 @@ func TestNode_DownloadRange_Success(t *testing.T) {
 	testContent := []byte("0123456789ABCDEF") // 16 bytes
 	testHash := "a1b2c3d4e5f67890123456789012345678901234567890123456789012abcdef"
 
-	node1.SetContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+	node1.SetContentProvider(ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
 		if hash == testHash {
 			return io.NopCloser(strings.NewReader(string(testContent))), int64(len(testContent)), nil
 		}
 		return nil, 0, io.EOF
-	})
+	}))
 
 	// Connect nodes
 	node1Info := peer.AddrInfo{
@@ -839,7 +841,7 @@ func TestNode_UploadTracking(t *testing.T) {
 	testPeerID := node.PeerID() // Use own ID for testing
 
 	// Initially should be able to accept uploads (tryAcceptUpload atomically checks and reserves)
-	if !node.tryAcceptUpload(testPeerID) {
+	if !node.tryAcceptUpload(testPeerID, false, priority.Interactive) {
 		t.Error("Should be able to accept upload initially")
 	}
 
@@ -847,7 +849,7 @@ func TestNode_UploadTracking(t *testing.T) {
 	node.trackUploadEnd(testPeerID)
 
 	// Should still be able to accept
-	if !node.tryAcceptUpload(testPeerID) {
+	if !node.tryAcceptUpload(testPeerID, false, priority.Interactive) {
 		t.Error("Should be able to accept upload after end")
 	}
 	node.trackUploadEnd(testPeerID)
@@ -870,13 +872,13 @@ func TestNode_UploadLimit(t *testing.T) {
 
 	// Fill up MaxUploadsPerPeer slots for this peer using tryAcceptUpload
 	for i := 0; i < MaxUploadsPerPeer; i++ {
-		if !node.tryAcceptUpload(testPeerID) {
+		if !node.tryAcceptUpload(testPeerID, false, priority.Interactive) {
 			t.Fatalf("Should accept upload %d", i)
 		}
 	}
 
 	// Should not accept more from this peer
-	if node.tryAcceptUpload(testPeerID) {
+	if node.tryAcceptUpload(testPeerID, false, priority.Interactive) {
 		t.Error("Should not accept upload when per-peer limit reached")
 	}
 
@@ -884,12 +886,91 @@ func TestNode_UploadLimit(t *testing.T) {
 	node.trackUploadEnd(testPeerID)
 
 	// Should accept again
-	if !node.tryAcceptUpload(testPeerID) {
+	if !node.tryAcceptUpload(testPeerID, false, priority.Interactive) {
 		t.Error("Should accept upload after one ends")
 	}
 	node.trackUploadEnd(testPeerID)
 }
 
+func TestNode_PriorityUploadSlots(t *testing.T) {
+	node := &Node{maxConcurrentUploads: 8, uploadsPerPeer: make(map[peer.ID]int)}
+
+	// Fill every regular slot
+	for i := 0; i < 8; i++ {
+		if !node.tryAcceptUpload(peer.ID(fmt.Sprintf("peer-%d", i)), false, priority.Interactive) {
+			t.Fatalf("Should accept upload %d", i)
+		}
+	}
+	if node.tryAcceptUpload("bulk", false, priority.Interactive) {
+		t.Error("Should not accept a regular upload when all slots are taken")
+	}
+
+	// Priority uploads take the reserved slots, and only those
+	if slots := node.priorityUploadSlots(); slots != 2 {
+		t.Fatalf("priorityUploadSlots() = %d, want 2", slots)
+	}
+	for i := 0; i < 2; i++ {
+		if !node.tryAcceptUpload("security", true, priority.Interactive) {
+			t.Fatalf("Should accept priority upload %d", i)
+		}
+	}
+	if node.tryAcceptUpload("security", true, priority.Interactive) {
+		t.Error("Should not accept a priority upload beyond the reserved slots")
+	}
+
+	// One extra per peer
+	node = &Node{maxConcurrentUploads: 100, uploadsPerPeer: make(map[peer.ID]int)}
+	for i := 0; i < MaxUploadsPerPeer; i++ {
+		node.tryAcceptUpload("busy", false, priority.Interactive)
+	}
+	if !node.tryAcceptUpload("busy", true, priority.Interactive) {
+		t.Error("Should accept a priority upload past the per-peer limit")
+	}
+	if node.tryAcceptUpload("busy", true, priority.Interactive) {
+		t.Error("Should accept only one priority upload past the per-peer limit")
+	}
+}
+
+func TestNode_BackgroundUploadSlots(t *testing.T) {
+	m := metrics.New()
+	node := &Node{maxConcurrentUploads: 8, uploadsPerPeer: make(map[peer.ID]int), metrics: m}
+
+	// Prefetch fills half of what interactive requests leave: (8-2+1)/2
+	for i := 0; i < 3; i++ {
+		if !node.tryAcceptUpload(peer.ID(fmt.Sprintf("prefetch-%d", i)), false, priority.Prefetch) {
+			t.Fatalf("Should accept prefetch upload %d", i)
+		}
+	}
+	if node.tryAcceptUpload("prefetch", false, priority.Prefetch) {
+		t.Error("Should not accept a prefetch upload beyond its share")
+	}
+
+	// Replication fills up to the interactive reserve
+	for i := 0; i < 3; i++ {
+		if !node.tryAcceptUpload(peer.ID(fmt.Sprintf("replica-%d", i)), false, priority.Replication) {
+			t.Fatalf("Should accept replication upload %d", i)
+		}
+	}
+	if node.tryAcceptUpload("replica", true, priority.Replication) {
+		t.Error("Should not accept a replication upload into the interactive reserve, urgent or not")
+	}
+
+	// The reserve stays for interactive requests
+	for i := 0; i < 2; i++ {
+		if !node.tryAcceptUpload(peer.ID(fmt.Sprintf("apt-%d", i)), false, priority.Interactive) {
+			t.Fatalf("Should accept interactive upload %d", i)
+		}
+	}
+	if got := m.BackgroundUploadsRefused.Values(); got["prefetch"] != 1 || got["replication"] != 1 {
+		t.Errorf("refusals = %v, want one per class", got)
+	}
+
+	// A node down to one slot keeps it for interactive requests
+	if got := backgroundUploadLimit(1, priority.Replication); got != 0 {
+		t.Errorf("backgroundUploadLimit(1) = %d, want 0", got)
+	}
+}
+
 func TestNew_IPv6Addresses(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -1056,12 +1137,12 @@ func TestNode_Download_IPv6(t *testing.T) {
 	testContent := []byte("IPv6 test content for download")
 	testHash := "a1b2c3d4e5f6789012345678901234567890123456789012345678901234abcd"
 
-	node1.SetContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+	node1.SetContentProvider(ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
 		if hash == testHash {
 			return io.NopCloser(strings.NewReader(string(testContent))), int64(len(testContent)), nil
 		}
 		return nil, 0, io.EOF
-	})
+	}))
 
 	// Connect using IPv6 only
 	node1Info := peer.AddrInfo{
@@ -1084,3 +1165,27 @@ func TestNode_Download_IPv6(t *testing.T) {
 
 	t.Log("Successfully downloaded content over IPv6")
 }
+
+func TestTransferProfile(t *testing.T) {
+	tests := []struct {
+		addr    string
+		relayed bool
+		want    timeouts.Profile
+	}{
+		{"/ip4/192.168.1.20/tcp/4001", false, timeouts.ProfileLAN},
+		{"/ip4/127.0.0.1/udp/4001/quic-v1", false, timeouts.ProfileLAN},
+		{"/ip6/fe80::1/tcp/4001", false, timeouts.ProfileLAN},
+		{"/ip4/192.168.1.20/tcp/4001", true, timeouts.ProfileWAN},
+		{"/ip4/203.0.113.5/tcp/4001", false, timeouts.ProfileWAN},
+		{"/dns4/example.com/tcp/4001", false, timeouts.ProfileWAN},
+	}
+	for _, tt := range tests {
+		ma := multiaddr.StringCast(tt.addr)
+		if got := transferProfile(ma, tt.relayed); got != tt.want {
+			t.Errorf("transferProfile(%s, relayed=%v) = %s, want %s", tt.addr, tt.relayed, got, tt.want)
+		}
+	}
+	if got := transferProfile(nil, false); got != timeouts.ProfileWAN {
+		t.Errorf("nil address = %s, want wan", got)
+	}
+}
@@ -0,0 +1,97 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestNode_PauseRefusesSwarmOperations(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	node, err := New(ctx, newTestConfig(t), newTestLogger())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer node.Close()
+
+	if !node.Pause("incident 42") {
+		t.Fatal("Pause on a running node returned false")
+	}
+	if node.Pause("again") {
+		t.Error("second Pause returned true")
+	}
+	st := node.PauseState()
+	if !st.Paused || st.Reason != "incident 42" || st.Since.IsZero() {
+		t.Errorf("PauseState = %+v", st)
+	}
+	if got := node.metrics.P2PPaused.Value(); got != 1 {
+		t.Errorf("p2p_paused gauge = %v, want 1", got)
+	}
+
+	hash := strings.Repeat("a", 64)
+	if err := node.Provide(ctx, hash); !errors.Is(err, ErrPaused) {
+		t.Errorf("Provide: err = %v, want ErrPaused", err)
+	}
+	if _, err := node.FindProviders(ctx, hash, 5); !errors.Is(err, ErrPaused) {
+		t.Errorf("FindProviders: err = %v, want ErrPaused", err)
+	}
+	if _, err := node.Download(ctx, peer.AddrInfo{ID: node.PeerID()}, hash); !errors.Is(err, ErrPaused) {
+		t.Errorf("Download: err = %v, want ErrPaused", err)
+	}
+
+	if !node.Resume() {
+		t.Fatal("Resume on a paused node returned false")
+	}
+	if node.Paused() || node.PauseState().Paused {
+		t.Error("node still paused after Resume")
+	}
+	if got := node.metrics.P2PPaused.Value(); got != 0 {
+		t.Errorf("p2p_paused gauge = %v, want 0", got)
+	}
+}
+
+func TestNode_PausedNodeServesNoUploads(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	logger := newTestLogger()
+
+	provider, err := New(ctx, newTestConfig(t), logger)
+	if err != nil {
+		t.Fatalf("New provider failed: %v", err)
+	}
+	defer provider.Close()
+	client, err := New(ctx, newTestConfig(t), logger)
+	if err != nil {
+		t.Fatalf("New client failed: %v", err)
+	}
+	defer client.Close()
+
+	content := "package bytes"
+	hash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	provider.SetContentProvider(ContentGetter(func(h string) (io.ReadCloser, int64, error) {
+		return io.NopCloser(strings.NewReader(content)), int64(len(content)), nil
+	}))
+
+	info := peer.AddrInfo{ID: provider.PeerID(), Addrs: provider.Addrs()}
+	if err := client.host.Connect(ctx, info); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	provider.Pause("")
+	if _, err := client.Download(ctx, info, hash); err == nil {
+		t.Fatal("download from a paused node succeeded")
+	}
+
+	provider.Resume()
+	data, err := client.Download(ctx, info, hash)
+	if err != nil || string(data) != content {
+		t.Fatalf("download after Resume: %q, %v", data, err)
+	}
+}
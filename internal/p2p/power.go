@@ -0,0 +1,211 @@
+package p2p
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/audit"
+	"github.com/debswarm/debswarm/internal/power"
+	"github.com/debswarm/debswarm/internal/ratelimit"
+)
+
+// PowerConfig throttles or stops uploads while a laptop runs on battery or
+// its connection is metered, and restores them once it is back on AC and
+// an unmetered network.
+type PowerConfig struct {
+	OnBattery    string        // PowerIgnore, PowerThrottle or PowerDisable
+	OnMetered    string        // PowerIgnore, PowerThrottle or PowerDisable
+	ThrottleRate int64         // bytes/sec shared by all uploads while throttled
+	Interval     time.Duration // how often power and network are sampled (default 30s)
+}
+
+// Actions a PowerConfig takes on battery or on a metered connection.
+const (
+	PowerIgnore   = "ignore"
+	PowerThrottle = "throttle"
+	PowerDisable  = "disable"
+)
+
+// Upload policies set by the power state.
+const (
+	UploadsNormal    = "normal"
+	UploadsThrottled = "throttled"
+	UploadsDisabled  = "disabled"
+)
+
+// DefaultPowerInterval is how often power and network are sampled.
+const DefaultPowerInterval = 30 * time.Second
+
+// PowerState is the latest power and network sample and the upload policy
+// set from it.
+type PowerState struct {
+	OnBattery    bool      `json:"on_battery"`
+	Metered      bool      `json:"metered"`
+	Policy       string    `json:"policy"`                // UploadsNormal, UploadsThrottled or UploadsDisabled
+	Reasons      []string  `json:"reasons,omitempty"`     // conditions that set the policy: "battery", "metered"
+	UploadRate   int64     `json:"upload_rate,omitempty"` // bytes/sec while throttled
+	SampledAt    time.Time `json:"sampled_at,omitempty"`
+	PowerError   string    `json:"power_error,omitempty"`   // why the power source could not be read
+	NetworkError string    `json:"network_error,omitempty"` // why the metered state could not be read
+}
+
+// powerPolicy holds the upload policy set by the power source and network.
+// Uploads already running when it changes are left to finish.
+type powerPolicy struct {
+	cfg      PowerConfig
+	read     func(ctx context.Context) power.Sample
+	throttle *ratelimit.Limiter
+
+	policy atomic.Value // string
+
+	mu    sync.Mutex
+	state PowerState
+}
+
+// startPower starts sampling the power source and network when the power
+// policy is configured.
+func (n *Node) startPower(cfg *PowerConfig) {
+	if cfg == nil {
+		return
+	}
+	p := &powerPolicy{cfg: *cfg, read: power.NewDetector().Sample}
+	if p.cfg.Interval <= 0 {
+		p.cfg.Interval = DefaultPowerInterval
+	}
+	p.throttle = ratelimit.New(p.cfg.ThrottleRate)
+	p.policy.Store(UploadsNormal)
+	p.state = PowerState{Policy: UploadsNormal}
+	n.power = p
+	n.setPowerGauge(UploadsNormal)
+
+	go func() {
+		ticker := time.NewTicker(p.cfg.Interval)
+		defer ticker.Stop()
+		p.sample(n)
+		for {
+			select {
+			case <-n.ctx.Done():
+				return
+			case <-ticker.C:
+				p.sample(n)
+			}
+		}
+	}()
+}
+
+// sample reads the power source and network and sets the upload policy.
+// A source that cannot be read counts as AC, or as unmetered.
+func (p *powerPolicy) sample(n *Node) {
+	s := p.read(n.ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s.PowerErr != nil && p.state.PowerError == "" {
+		n.logger.Debug("Cannot read power supplies, assuming AC power", zap.Error(s.PowerErr))
+	}
+	if s.NetErr != nil && p.state.NetworkError == "" {
+		n.logger.Debug("Cannot read metered state, assuming unmetered", zap.Error(s.NetErr))
+	}
+
+	policy, reasons := p.decide(s)
+	prev, _ := p.policy.Load().(string)
+	if policy != prev {
+		n.audit.Log(audit.NewModeChangeEvent("upload_policy", policy, strings.Join(reasons, ",")))
+		if policy == UploadsNormal {
+			n.logger.Info("Back on AC power and an unmetered connection, uploads restored")
+		} else {
+			n.logger.Info("Adjusting uploads to power and network",
+				zap.String("policy", policy), zap.Strings("reasons", reasons))
+		}
+	}
+
+	p.state = PowerState{
+		OnBattery: s.OnBattery,
+		Metered:   s.Metered,
+		Policy:    policy,
+		Reasons:   reasons,
+		SampledAt: s.Time,
+	}
+	if policy == UploadsThrottled {
+		p.state.UploadRate = p.cfg.ThrottleRate
+	}
+	if s.PowerErr != nil {
+		p.state.PowerError = s.PowerErr.Error()
+	}
+	if s.NetErr != nil {
+		p.state.NetworkError = s.NetErr.Error()
+	}
+	p.policy.Store(policy)
+	n.setPowerGauge(policy)
+}
+
+// decide returns the upload policy for s, the strictest action of the
+// conditions that hold, and those conditions.
+func (p *powerPolicy) decide(s power.Sample) (string, []string) {
+	policy := UploadsNormal
+	var reasons []string
+	apply := func(reason, action string) {
+		switch action {
+		case PowerDisable:
+			policy = UploadsDisabled
+		case PowerThrottle:
+			// Without a rate there is nothing to throttle to
+			if policy == UploadsNormal && p.throttle.Enabled() {
+				policy = UploadsThrottled
+			}
+		default:
+			return
+		}
+		reasons = append(reasons, reason)
+	}
+	if s.OnBattery {
+		apply("battery", p.cfg.OnBattery)
+	}
+	if s.Metered {
+		apply("metered", p.cfg.OnMetered)
+	}
+	return policy, reasons
+}
+
+func (n *Node) setPowerGauge(policy string) {
+	if n.metrics == nil {
+		return
+	}
+	for _, p := range []string{UploadsNormal, UploadsThrottled, UploadsDisabled} {
+		v := 0.0
+		if p == policy {
+			v = 1
+		}
+		n.metrics.UploadPowerPolicy.WithLabel(p).Set(v)
+	}
+}
+
+// uploadsDisabled reports whether the power policy refuses uploads.
+func (n *Node) uploadsDisabled() bool {
+	return n.power != nil && n.power.policy.Load() == UploadsDisabled
+}
+
+// powerThrottle returns the limiter uploads share while the power policy
+// throttles them, nil otherwise.
+func (n *Node) powerThrottle() *ratelimit.Limiter {
+	if n.power == nil || n.power.policy.Load() != UploadsThrottled {
+		return nil
+	}
+	return n.power.throttle
+}
+
+// PowerState returns the state of the power policy, and false if it is not
+// enabled.
+func (n *Node) PowerState() (PowerState, bool) {
+	if n.power == nil {
+		return PowerState{}, false
+	}
+	n.power.mu.Lock()
+	defer n.power.mu.Unlock()
+	return n.power.state, true
+}
@@ -0,0 +1,105 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/debswarm/debswarm/internal/audit"
+	"github.com/debswarm/debswarm/internal/metrics"
+	"github.com/debswarm/debswarm/internal/power"
+	"github.com/debswarm/debswarm/internal/priority"
+	"github.com/debswarm/debswarm/internal/ratelimit"
+)
+
+func TestPowerPolicy(t *testing.T) {
+	m := metrics.New()
+	timeline := audit.NewTimeline(0)
+	node := &Node{
+		maxConcurrentUploads: 4,
+		uploadsPerPeer:       make(map[peer.ID]int),
+		logger:               newTestLogger(),
+		metrics:              m,
+		audit:                timeline,
+	}
+	var next power.Sample
+	p := &powerPolicy{
+		cfg:      PowerConfig{OnBattery: PowerThrottle, OnMetered: PowerDisable, ThrottleRate: 1024},
+		read:     func(context.Context) power.Sample { return next },
+		throttle: ratelimit.New(1024),
+	}
+	p.policy.Store(UploadsNormal)
+	node.power = p
+	sample := func(s power.Sample) PowerState {
+		t.Helper()
+		s.Time = time.Now()
+		next = s
+		p.sample(node)
+		st, ok := node.PowerState()
+		if !ok {
+			t.Fatal("power state not reported")
+		}
+		return st
+	}
+
+	// On battery: uploads accepted, but throttled
+	st := sample(power.Sample{OnBattery: true})
+	if st.Policy != UploadsThrottled || !slices.Equal(st.Reasons, []string{"battery"}) || st.UploadRate != 1024 {
+		t.Errorf("on battery: state = %+v", st)
+	}
+	if node.powerThrottle() == nil || node.uploadLimit() != 4 {
+		t.Error("on battery: uploads not throttled")
+	}
+	if m.UploadPowerPolicy.Values()[UploadsThrottled] != 1 || m.UploadPowerPolicy.Values()[UploadsNormal] != 0 {
+		t.Errorf("gauge = %v", m.UploadPowerPolicy.Values())
+	}
+
+	// Metered as well: the stricter action wins, security updates included
+	st = sample(power.Sample{OnBattery: true, Metered: true})
+	if st.Policy != UploadsDisabled || !slices.Equal(st.Reasons, []string{"battery", "metered"}) {
+		t.Errorf("on battery and metered: state = %+v", st)
+	}
+	if node.tryAcceptUpload("a", false, priority.Interactive) || node.tryAcceptUpload("b", true, priority.Interactive) {
+		t.Error("upload accepted while uploads are disabled")
+	}
+	if free := node.localCapabilities().FreeUploadSlots; free != 0 {
+		t.Errorf("hello advertises %d free slots while uploads are disabled", free)
+	}
+
+	// Back on AC and unmetered, or readings unavailable: uploads restored
+	st = sample(power.Sample{PowerErr: errors.New("no sysfs"), NetErr: power.ErrNoNetworkManager})
+	if st.Policy != UploadsNormal || st.PowerError == "" || st.NetworkError == "" {
+		t.Errorf("without readings: state = %+v", st)
+	}
+	if node.powerThrottle() != nil || !node.tryAcceptUpload("a", false, priority.Interactive) {
+		t.Error("uploads not restored")
+	}
+
+	// Each change of policy is on the event timeline
+	changes := timeline.Events(audit.TimelineFilter{Type: audit.EventModeChange})
+	if len(changes) != 3 || changes[0].State != UploadsNormal || changes[1].State != UploadsDisabled ||
+		changes[1].Reason != "battery,metered" || changes[2].State != UploadsThrottled {
+		t.Errorf("mode changes = %+v", changes)
+	}
+
+	// Ignored conditions change nothing
+	p.cfg.OnMetered = PowerIgnore
+	if st := sample(power.Sample{Metered: true}); st.Policy != UploadsNormal || len(st.Reasons) != 0 {
+		t.Errorf("metered ignored: state = %+v", st)
+	}
+}
+
+func TestPowerPolicyDisabled(t *testing.T) {
+	node := &Node{maxConcurrentUploads: 2, uploadsPerPeer: make(map[peer.ID]int)}
+	node.startPower(nil)
+	if _, ok := node.PowerState(); ok {
+		t.Error("power state reported without a power policy")
+	}
+	if node.uploadsDisabled() || node.powerThrottle() != nil || node.uploadLimit() != 2 {
+		t.Error("uploads limited without a power policy")
+	}
+}
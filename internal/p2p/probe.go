@@ -0,0 +1,204 @@
+package p2p
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"go.uber.org/zap"
+)
+
+// ProtocolProbe measures the bandwidth to a peer. The requester writes the
+// number of bytes it wants as a 4-byte big-endian integer and closes its
+// side; the peer replies with that many zero bytes, through the same rate
+// limits as an upload, and closes. Latency is the time to the first byte and
+// throughput what follows it, so a few hundred KB give a usable estimate
+// without the cost of a package transfer.
+const ProtocolProbe = "/debswarm/probe/1.0.0"
+
+const (
+	// DefaultProbeSize is the probe transfer size used when none is configured.
+	DefaultProbeSize = 256 * 1024
+	// MaxProbeSize bounds the bytes a peer may ask us to send in one probe.
+	MaxProbeSize = 1024 * 1024
+
+	probeTimeout = 15 * time.Second
+
+	// maxConcurrentProbes bounds the probes this node answers at once; more
+	// are refused, and the requester simply has no measurement.
+	maxConcurrentProbes = 2
+)
+
+// ErrProbeRefused is returned when a peer resets a probe: it is paused,
+// already answering other probes, or was asked for too many bytes.
+var ErrProbeRefused = errors.New("probe refused by peer")
+
+// ProbeResult is the outcome of a bandwidth probe.
+type ProbeResult struct {
+	Bytes      int64
+	Latency    time.Duration // until the first byte arrived
+	Duration   time.Duration // whole transfer, request included
+	Throughput float64       // bytes per second after the first byte
+}
+
+// startProbe registers the probe handler.
+func (n *Node) startProbe() {
+	n.probeSem = make(chan struct{}, maxConcurrentProbes)
+	n.host.SetStreamHandler(protocol.ID(ProtocolProbe), n.handleProbeStream)
+}
+
+func (n *Node) handleProbeStream(s network.Stream) {
+	defer s.Close()
+	_ = s.SetDeadline(time.Now().Add(probeTimeout))
+
+	// A paused node sends nothing to the swarm, probes included, nor does
+	// one whose power policy disables uploads
+	if n.paused.Load() || n.uploadsDisabled() {
+		_ = s.Reset()
+		return
+	}
+	select {
+	case n.probeSem <- struct{}{}:
+		defer func() { <-n.probeSem }()
+	default:
+		_ = s.Reset()
+		return
+	}
+
+	var buf [4]byte
+	if _, err := io.ReadFull(s, buf[:]); err != nil {
+		_ = s.Reset()
+		return
+	}
+	size := int64(binary.BigEndian.Uint32(buf[:]))
+	if size == 0 || size > MaxProbeSize {
+		_ = s.Reset()
+		return
+	}
+
+	// Probe bytes are not package data and stay out of the transfer ledger
+	peerID := s.Conn().RemotePeer()
+	var writer io.Writer = s
+	if n.peerUploadLimiter != nil && n.peerUploadLimiter.Enabled() {
+		writer = n.peerUploadLimiter.WriterContext(n.ctx, peerID, s)
+	} else if n.uploadLimiter.Enabled() {
+		writer = n.uploadLimiter.WriterContext(n.ctx, s)
+	}
+	if n.wanUploadLimiter.Enabled() && !n.isLANConn(s.Conn()) {
+		writer = n.wanUploadLimiter.WriterContext(n.ctx, writer)
+	}
+	if throttle := n.powerThrottle(); throttle != nil {
+		writer = throttle.WriterContext(n.ctx, writer)
+	}
+	if n.uploadThrottle != nil {
+		writer = n.uploadThrottle(n.ctx, writer, size)
+	}
+	if _, err := io.CopyN(writer, zeroReader{}, size); err != nil {
+		_ = s.Reset()
+		return
+	}
+}
+
+// Probe measures the bandwidth to a peer with a transfer of the configured
+// probe size and records the result with the peer scorer, so the first real
+// transfers are scheduled and rate-limited from a measurement rather than a
+// neutral guess. Probes are not carried over relayed connections, whose
+// limits would make the measurement meaningless.
+func (n *Node) Probe(ctx context.Context, pid peer.ID) (*ProbeResult, error) {
+	if n.paused.Load() {
+		return nil, ErrPaused
+	}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	s, err := n.host.NewStream(ctx, pid, protocol.ID(ProtocolProbe))
+	if err != nil {
+		return nil, fmt.Errorf("open probe stream: %w", err)
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.SetDeadline(deadline)
+	}
+
+	var req [4]byte
+	binary.BigEndian.PutUint32(req[:], uint32(n.probeSize))
+	if _, err := s.Write(req[:]); err != nil {
+		_ = s.Reset()
+		return nil, fmt.Errorf("send probe request: %w", err)
+	}
+	if err := s.CloseWrite(); err != nil {
+		_ = s.Reset()
+		return nil, fmt.Errorf("send probe request: %w", err)
+	}
+
+	var first [1]byte
+	if _, err := io.ReadFull(s, first[:]); err != nil {
+		_ = s.Reset()
+		if errors.Is(err, network.ErrReset) || errors.Is(err, io.EOF) {
+			return nil, ErrProbeRefused
+		}
+		return nil, fmt.Errorf("read probe: %w", err)
+	}
+	latency := time.Since(start)
+	rest, err := io.Copy(io.Discard, io.LimitReader(s, n.probeSize))
+	if err != nil {
+		_ = s.Reset()
+		return nil, fmt.Errorf("read probe: %w", err)
+	}
+	if rest+1 != n.probeSize {
+		return nil, fmt.Errorf("probe truncated at %d of %d bytes", rest+1, n.probeSize)
+	}
+	duration := time.Since(start)
+
+	res := &ProbeResult{Bytes: n.probeSize, Latency: latency, Duration: duration}
+	if transfer := duration - latency; transfer > 0 {
+		res.Throughput = float64(rest) / transfer.Seconds()
+	} else {
+		res.Throughput = float64(n.probeSize) / duration.Seconds()
+	}
+
+	n.scorer.RecordProbe(pid, float64(latency.Milliseconds()), res.Throughput)
+	n.timeouts.RecordPeerTransfer(pid.String(), res.Bytes, duration)
+	n.logger.Debug("Probed peer bandwidth",
+		zap.String("peer", pid.String()),
+		zap.Duration("latency", latency),
+		zap.Float64("throughput", res.Throughput))
+	return res, nil
+}
+
+// probeNewPeer probes a peer that just completed a hello handshake when it
+// serves the probe protocol and the scorer has measured nothing about it.
+func (n *Node) probeNewPeer(pid peer.ID, caps *PeerCapabilities) {
+	if !n.probeOnConnect || caps == nil || !slices.Contains(caps.Protocols, ProtocolProbe) {
+		return
+	}
+	if ps := n.scorer.GetStats(pid); ps != nil && (ps.TotalRequests > 0 || !ps.ProbedAt.IsZero()) {
+		return
+	}
+	// Over a relay the probe would not open; skip it rather than fail
+	for _, c := range n.host.Network().ConnsToPeer(pid) {
+		if !c.Stat().Limited {
+			if _, err := n.Probe(n.ctx, pid); err != nil {
+				n.logger.Debug("Bandwidth probe failed",
+					zap.String("peer", pid.String()), zap.Error(err))
+			}
+			return
+		}
+	}
+}
+
+// zeroReader reads zero bytes forever
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,89 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestProbe(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	logger := newTestLogger()
+
+	node1, err := New(ctx, newTestConfig(t), logger)
+	if err != nil {
+		t.Fatalf("New node1 failed: %v", err)
+	}
+	defer node1.Close()
+
+	cfg2 := newTestConfig(t)
+	cfg2.ProbeSize = 64 * 1024
+	node2, err := New(ctx, cfg2, logger)
+	if err != nil {
+		t.Fatalf("New node2 failed: %v", err)
+	}
+	defer node2.Close()
+
+	if err := node2.host.Connect(ctx, peer.AddrInfo{ID: node1.PeerID(), Addrs: node1.Addrs()}); err != nil {
+		t.Fatalf("Failed to connect nodes: %v", err)
+	}
+
+	res, err := node2.Probe(ctx, node1.PeerID())
+	if err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+	if res.Bytes != 64*1024 || res.Throughput <= 0 || res.Latency <= 0 || res.Duration < res.Latency {
+		t.Errorf("result = %+v", res)
+	}
+
+	ps := node2.scorer.GetStats(node1.PeerID())
+	if ps == nil || ps.ProbedAt.IsZero() || ps.AvgThroughput != res.Throughput {
+		t.Fatalf("probe not recorded: %+v", ps)
+	}
+	if ps.TotalRequests != 0 {
+		t.Errorf("probe counted as a request: %d", ps.TotalRequests)
+	}
+
+	// A paused node answers no probes
+	node1.Pause("test")
+	if _, err := node2.Probe(ctx, node1.PeerID()); !errors.Is(err, ErrProbeRefused) {
+		t.Errorf("probe of paused peer: err = %v, want ErrProbeRefused", err)
+	}
+}
+
+func TestProbe_OnConnect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	logger := newTestLogger()
+
+	node1, err := New(ctx, newTestConfig(t), logger)
+	if err != nil {
+		t.Fatalf("New node1 failed: %v", err)
+	}
+	defer node1.Close()
+
+	cfg2 := newTestConfig(t)
+	cfg2.ProbeOnConnect = true
+	node2, err := New(ctx, cfg2, logger)
+	if err != nil {
+		t.Fatalf("New node2 failed: %v", err)
+	}
+	defer node2.Close()
+
+	if err := node2.host.Connect(ctx, peer.AddrInfo{ID: node1.PeerID(), Addrs: node1.Addrs()}); err != nil {
+		t.Fatalf("Failed to connect nodes: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if ps := node2.scorer.GetStats(node1.PeerID()); ps != nil && !ps.ProbedAt.IsZero() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("new peer was not probed after the handshake")
+}
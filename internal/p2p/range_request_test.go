@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"strings"
 	"testing"
+
+	"github.com/debswarm/debswarm/internal/priority"
 )
 
 // Range requests are a fixed binary frame, not newline-delimited: the big-endian
@@ -29,12 +31,12 @@ func TestRangeRequest_RoundTrip(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			frame := encodeRangeRequest(hash, c.start, c.end)
+			frame := encodeRangeRequest(hash, c.start, c.end, priority.Interactive)
 			if len(frame) != rangeRequestLen {
 				t.Fatalf("frame length = %d, want %d", len(frame), rangeRequestLen)
 			}
 
-			gotHash, gotStart, gotEnd, err := decodeRangeRequest(bytes.NewReader(frame))
+			gotHash, gotStart, gotEnd, _, err := decodeRangeRequest(bytes.NewReader(frame))
 			if err != nil {
 				t.Fatalf("decodeRangeRequest: %v", err)
 			}
@@ -55,14 +57,14 @@ func TestRangeRequest_RoundTrip(t *testing.T) {
 // for a 0x0A offset really does contain a newline byte before the terminator, so
 // a newline-delimited read would have stopped early.
 func TestRangeRequest_OffsetContainsNewlineByte(t *testing.T) {
-	frame := encodeRangeRequest(strings.Repeat("a", 64), 40*4*1024*1024, 0)
+	frame := encodeRangeRequest(strings.Repeat("a", 64), 40*4*1024*1024, 0, priority.Interactive)
 	// Everything before the trailing terminator is the hash + binary offsets.
 	body := frame[:rangeRequestLen-1]
 	if !bytes.ContainsRune(body, '\n') {
 		t.Fatal("expected the encoded offset to contain a 0x0A byte for this test to be meaningful")
 	}
 	// Decode must still recover the full offset despite the embedded newline.
-	_, start, _, err := decodeRangeRequest(bytes.NewReader(frame))
+	_, start, _, _, err := decodeRangeRequest(bytes.NewReader(frame))
 	if err != nil {
 		t.Fatalf("decode: %v", err)
 	}
@@ -71,10 +73,27 @@ func TestRangeRequest_OffsetContainsNewlineByte(t *testing.T) {
 	}
 }
 
+// The terminator carries the transfer's class; a plain newline, as older
+// clients send, is interactive.
+func TestRangeRequest_Class(t *testing.T) {
+	hash := strings.Repeat("a", 64)
+	for _, class := range []priority.Class{priority.Interactive, priority.Replication, priority.Prefetch} {
+		_, _, _, got, err := decodeRangeRequest(bytes.NewReader(encodeRangeRequest(hash, 0, 0, class)))
+		if err != nil || got != class {
+			t.Errorf("class %v decoded as %v, %v", class, got, err)
+		}
+	}
+	frame := encodeRangeRequest(hash, 0, 0, priority.Prefetch)
+	frame[rangeRequestLen-1] = '?'
+	if _, _, _, got, _ := decodeRangeRequest(bytes.NewReader(frame)); got != priority.Interactive {
+		t.Errorf("unknown terminator decoded as %v, want interactive", got)
+	}
+}
+
 // A short/truncated frame must be a clean error, not a partial parse.
 func TestRangeRequest_TruncatedFrame(t *testing.T) {
-	frame := encodeRangeRequest(strings.Repeat("a", 64), 1024, 2048)
-	if _, _, _, err := decodeRangeRequest(bytes.NewReader(frame[:40])); err == nil {
+	frame := encodeRangeRequest(strings.Repeat("a", 64), 1024, 2048, priority.Interactive)
+	if _, _, _, _, err := decodeRangeRequest(bytes.NewReader(frame[:40])); err == nil {
 		t.Error("expected error decoding a truncated frame, got nil")
 	}
 }
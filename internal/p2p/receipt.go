@@ -0,0 +1,329 @@
+package p2p
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/priority"
+)
+
+// ProtocolReceipt asks a peer for a signed receipt of a transfer it just
+// made to us. The requester writes a range-transfer request frame (see
+// encodeRangeRequest) naming the bytes it received and closes its side; the
+// peer replies with the Receipt as JSON, signed with its identity key, or
+// resets the stream if it sent no such bytes to the requester recently.
+const ProtocolReceipt = "/debswarm/receipt/1.0.0"
+
+const (
+	// receiptWindow is how long after an upload its receipt can be asked for
+	receiptWindow = 10 * time.Minute
+	// maxRecentUploads bounds the uploads remembered for receipts; the
+	// oldest is dropped to make room
+	maxRecentUploads = 4096
+	// maxReceiptSize bounds a receipt read from a peer
+	maxReceiptSize = 4096
+
+	receiptTimeout = 15 * time.Second
+
+	// receiptDomain prefixes the signed bytes, so a receipt signature can
+	// never be mistaken for a signature over anything else
+	receiptDomain = "debswarm-receipt-v1\n"
+)
+
+var (
+	// ErrReceiptRefused is returned when a peer resets a receipt request:
+	// it does not remember sending the bytes, or does not issue receipts.
+	ErrReceiptRefused = errors.New("receipt refused by peer")
+	// ErrInvalidReceipt is returned for a receipt whose signature does not
+	// verify, or that describes another transfer than the one asked about.
+	ErrInvalidReceipt = errors.New("invalid receipt")
+)
+
+// Receipt is an uploader's signed statement that it sent the bytes
+// [Start, End) of the package with hash SHA256 to Downloader, and that those
+// bytes hash to Digest. Kept by the downloader, it proves who supplied a
+// corrupt package: the uploader cannot deny a receipt that verifies against
+// its own peer ID.
+type Receipt struct {
+	SHA256     string    `json:"sha256"`
+	Start      int64     `json:"start"`
+	End        int64     `json:"end"`
+	Digest     string    `json:"digest"` // SHA256 of the bytes sent, hex
+	Uploader   string    `json:"uploader"`
+	Downloader string    `json:"downloader"`
+	Time       time.Time `json:"time"`
+	PublicKey  []byte    `json:"public_key"` // the uploader's, marshalled
+	Signature  []byte    `json:"signature"`
+}
+
+// ReceiptRecorder is called with each verified receipt for a download.
+type ReceiptRecorder func(r *Receipt)
+
+// Size returns the number of bytes the receipt covers.
+func (r *Receipt) Size() int64 {
+	return r.End - r.Start
+}
+
+// UploaderID returns the peer ID of the uploader.
+func (r *Receipt) UploaderID() (peer.ID, error) {
+	return peer.Decode(r.Uploader)
+}
+
+// signedBytes returns what the signature covers: every field but the key
+// and the signature, one per line after the domain prefix.
+func (r *Receipt) signedBytes() []byte {
+	var b strings.Builder
+	b.WriteString(receiptDomain)
+	for _, field := range []string{
+		r.SHA256,
+		strconv.FormatInt(r.Start, 10),
+		strconv.FormatInt(r.End, 10),
+		r.Digest,
+		r.Uploader,
+		r.Downloader,
+		strconv.FormatInt(r.Time.Unix(), 10),
+	} {
+		b.WriteString(field)
+		b.WriteByte('\n')
+	}
+	return []byte(b.String())
+}
+
+// Verify checks that the receipt is signed by the key of its uploader.
+func (r *Receipt) Verify() error {
+	pub, err := crypto.UnmarshalPublicKey(r.PublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: public key: %v", ErrInvalidReceipt, err)
+	}
+	uploader, err := r.UploaderID()
+	if err != nil {
+		return fmt.Errorf("%w: uploader: %v", ErrInvalidReceipt, err)
+	}
+	if !uploader.MatchesPublicKey(pub) {
+		return fmt.Errorf("%w: public key is not the uploader's", ErrInvalidReceipt)
+	}
+	ok, err := pub.Verify(r.signedBytes(), r.Signature)
+	if err != nil || !ok {
+		return fmt.Errorf("%w: bad signature", ErrInvalidReceipt)
+	}
+	return nil
+}
+
+// Sign completes the receipt with the uploader's public key and its
+// signature; key must be the uploader's identity key.
+func (r *Receipt) Sign(key crypto.PrivKey) error {
+	pub, err := crypto.MarshalPublicKey(key.GetPublic())
+	if err != nil {
+		return err
+	}
+	r.PublicKey = pub
+	sig, err := key.Sign(r.signedBytes())
+	if err != nil {
+		return err
+	}
+	r.Signature = sig
+	return nil
+}
+
+// uploadKey identifies one completed upload for receipts.
+type uploadKey struct {
+	peer       peer.ID
+	sha256     string
+	start, end int64
+}
+
+// recentUpload is what was sent in one completed upload.
+type recentUpload struct {
+	digest string
+	at     time.Time
+}
+
+// recentUploads remembers the uploads of the last receiptWindow so their
+// receipts can be issued on request.
+type recentUploads struct {
+	mu      sync.Mutex
+	uploads map[uploadKey]recentUpload
+	now     func() time.Time
+}
+
+func newRecentUploads() *recentUploads {
+	return &recentUploads{uploads: make(map[uploadKey]recentUpload), now: time.Now}
+}
+
+// add records a completed upload.
+func (u *recentUploads) add(key uploadKey, digest string) {
+	now := u.now()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.uploads) >= maxRecentUploads {
+		var oldest uploadKey
+		var oldestAt time.Time
+		for k, up := range u.uploads {
+			if now.Sub(up.at) > receiptWindow {
+				delete(u.uploads, k)
+				continue
+			}
+			if oldestAt.IsZero() || up.at.Before(oldestAt) {
+				oldest, oldestAt = k, up.at
+			}
+		}
+		if len(u.uploads) >= maxRecentUploads {
+			delete(u.uploads, oldest)
+		}
+	}
+	u.uploads[key] = recentUpload{digest: digest, at: now}
+}
+
+// get returns a recent upload, if it is within receiptWindow.
+func (u *recentUploads) get(key uploadKey) (recentUpload, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	up, ok := u.uploads[key]
+	if !ok || u.now().Sub(up.at) > receiptWindow {
+		return recentUpload{}, false
+	}
+	return up, true
+}
+
+// startReceipts registers the receipt handler.
+func (n *Node) startReceipts() {
+	n.uploads = newRecentUploads()
+	n.host.SetStreamHandler(protocol.ID(ProtocolReceipt), n.handleReceiptStream)
+}
+
+// SetReceiptRecorder sets the function that keeps receipts for downloads.
+// Without one, no receipts are asked for.
+func (n *Node) SetReceiptRecorder(recorder ReceiptRecorder) {
+	n.recordReceipt = recorder
+}
+
+func (n *Node) handleReceiptStream(s network.Stream) {
+	defer s.Close()
+	_ = s.SetDeadline(time.Now().Add(receiptTimeout))
+
+	sha256Hash, start, end, _, err := decodeRangeRequest(io.LimitReader(s, rangeRequestLen))
+	if err != nil {
+		_ = s.Reset()
+		return
+	}
+	downloader := s.Conn().RemotePeer()
+	up, ok := n.uploads.get(uploadKey{peer: downloader, sha256: sha256Hash, start: start, end: end})
+	if !ok {
+		_ = s.Reset()
+		return
+	}
+
+	r := &Receipt{
+		SHA256:     sha256Hash,
+		Start:      start,
+		End:        end,
+		Digest:     up.digest,
+		Uploader:   n.host.ID().String(),
+		Downloader: downloader.String(),
+		Time:       up.at.UTC().Truncate(time.Second),
+	}
+	if err := r.Sign(n.host.Peerstore().PrivKey(n.host.ID())); err != nil {
+		n.logger.Debug("Failed to sign receipt", zap.Error(err))
+		_ = s.Reset()
+		return
+	}
+	if err := json.NewEncoder(s).Encode(r); err != nil {
+		_ = s.Reset()
+	}
+}
+
+// RequestReceipt asks a peer for its receipt of the bytes [start, end) of
+// a package it sent us, whose SHA256 is digest. The receipt is returned
+// only if it is signed by the peer and describes exactly those bytes.
+func (n *Node) RequestReceipt(ctx context.Context, pid peer.ID, sha256Hash string, start, end int64, digest string) (*Receipt, error) {
+	ctx, cancel := context.WithTimeout(ctx, receiptTimeout)
+	defer cancel()
+
+	s, err := n.host.NewStream(network.WithAllowLimitedConn(ctx, "debswarm-receipt"), pid, protocol.ID(ProtocolReceipt))
+	if err != nil {
+		return nil, fmt.Errorf("open receipt stream: %w", err)
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.SetDeadline(deadline)
+	}
+
+	if _, err := s.Write(encodeRangeRequest(sha256Hash, start, end, priority.Interactive)); err != nil {
+		_ = s.Reset()
+		return nil, fmt.Errorf("send receipt request: %w", err)
+	}
+	if err := s.CloseWrite(); err != nil {
+		_ = s.Reset()
+		return nil, fmt.Errorf("send receipt request: %w", err)
+	}
+
+	var r Receipt
+	if err := json.NewDecoder(io.LimitReader(s, maxReceiptSize)).Decode(&r); err != nil {
+		_ = s.Reset()
+		if errors.Is(err, network.ErrReset) || errors.Is(err, io.EOF) {
+			return nil, ErrReceiptRefused
+		}
+		return nil, fmt.Errorf("read receipt: %w", err)
+	}
+
+	if err := r.Verify(); err != nil {
+		return nil, err
+	}
+	if r.Uploader != pid.String() || r.Downloader != n.host.ID().String() ||
+		r.SHA256 != sha256Hash || r.Start != start || r.End != end {
+		return nil, fmt.Errorf("%w: describes another transfer", ErrInvalidReceipt)
+	}
+	if r.Digest != digest {
+		return nil, fmt.Errorf("%w: digest %s does not match the bytes received", ErrInvalidReceipt, r.Digest)
+	}
+	return &r, nil
+}
+
+// collectReceipt asks for and records the receipt of a download, in the
+// background so the transfer is not held up by it.
+func (n *Node) collectReceipt(pid peer.ID, sha256Hash string, start int64, data []byte) {
+	recorder := n.recordReceipt
+	if recorder == nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	end := start + int64(len(data))
+	go func() {
+		r, err := n.RequestReceipt(n.ctx, pid, sha256Hash, start, end, digest)
+		if err != nil {
+			if errors.Is(err, ErrInvalidReceipt) {
+				n.logger.Warn("Peer sent an invalid transfer receipt",
+					zap.String("peer", pid.String()),
+					zap.String("hash", sha256Hash[:16]+"..."),
+					zap.Error(err))
+			} else {
+				n.logger.Debug("No transfer receipt from peer",
+					zap.String("peer", pid.String()), zap.Error(err))
+			}
+			if n.metrics != nil {
+				n.metrics.TransferReceipts.WithLabel("missing").Inc()
+			}
+			return
+		}
+		if n.metrics != nil {
+			n.metrics.TransferReceipts.WithLabel("ok").Inc()
+		}
+		recorder(r)
+	}()
+}
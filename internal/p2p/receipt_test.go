@@ -0,0 +1,103 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestReceipts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	logger := newTestLogger()
+	uploader, err := New(ctx, newTestConfig(t), logger)
+	if err != nil {
+		t.Fatalf("New uploader: %v", err)
+	}
+	defer uploader.Close()
+	downloader, err := New(ctx, newTestConfig(t), logger)
+	if err != nil {
+		t.Fatalf("New downloader: %v", err)
+	}
+	defer downloader.Close()
+
+	content := "0123456789ABCDEF"
+	hash := strings.Repeat("ab", 32)
+	uploader.SetContentProvider(ContentGetter(func(h string) (io.ReadCloser, int64, error) {
+		if h != hash {
+			return nil, 0, io.EOF
+		}
+		return io.NopCloser(strings.NewReader(content)), int64(len(content)), nil
+	}))
+	receipts := make(chan *Receipt, 1)
+	downloader.SetReceiptRecorder(func(r *Receipt) { receipts <- r })
+
+	info := peer.AddrInfo{ID: uploader.PeerID(), Addrs: uploader.Addrs()}
+	if _, err := downloader.DownloadRange(ctx, info, hash, 3, 9); err != nil {
+		t.Fatalf("DownloadRange: %v", err)
+	}
+
+	var r *Receipt
+	select {
+	case r = <-receipts:
+	case <-ctx.Done():
+		t.Fatal("no receipt recorded")
+	}
+	if r.SHA256 != hash || r.Start != 3 || r.End != 9 ||
+		r.Uploader != uploader.PeerID().String() || r.Downloader != downloader.PeerID().String() {
+		t.Errorf("receipt = %+v", r)
+	}
+	if err := r.Verify(); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	// Any change to what was signed breaks the signature
+	forged := *r
+	forged.Digest = strings.Repeat("00", 32)
+	if err := forged.Verify(); !errors.Is(err, ErrInvalidReceipt) {
+		t.Errorf("forged digest: Verify = %v", err)
+	}
+	forged = *r
+	forged.Uploader = downloader.PeerID().String()
+	if err := forged.Verify(); !errors.Is(err, ErrInvalidReceipt) {
+		t.Errorf("forged uploader: Verify = %v", err)
+	}
+
+	// No receipt for bytes that were not sent, nor for other digests
+	if _, err := downloader.RequestReceipt(ctx, uploader.PeerID(), hash, 0, 9, r.Digest); !errors.Is(err, ErrReceiptRefused) {
+		t.Errorf("receipt for bytes not sent: %v", err)
+	}
+	if _, err := downloader.RequestReceipt(ctx, uploader.PeerID(), hash, 3, 9, strings.Repeat("00", 32)); !errors.Is(err, ErrInvalidReceipt) {
+		t.Errorf("receipt for other bytes: %v", err)
+	}
+}
+
+func TestRecentUploadsBounded(t *testing.T) {
+	u := newRecentUploads()
+	now := time.Now()
+	u.now = func() time.Time { return now }
+
+	first := uploadKey{sha256: "first"}
+	u.add(first, "d")
+	for i := range maxRecentUploads {
+		now = now.Add(time.Millisecond)
+		u.add(uploadKey{sha256: "x", start: int64(i)}, "d")
+	}
+	if len(u.uploads) != maxRecentUploads {
+		t.Fatalf("%d uploads remembered, want %d", len(u.uploads), maxRecentUploads)
+	}
+	if _, ok := u.get(first); ok {
+		t.Error("oldest upload was kept")
+	}
+
+	now = now.Add(receiptWindow + time.Second)
+	if _, ok := u.get(uploadKey{sha256: "x", start: 1}); ok {
+		t.Error("upload past the receipt window was returned")
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/multiformats/go-multiaddr"
 	"go.uber.org/zap"
 
+	"github.com/debswarm/debswarm/internal/audit"
 	"github.com/debswarm/debswarm/internal/metrics"
 )
 
@@ -303,6 +304,8 @@ func (n *Node) watchReachability() {
 			}
 
 			n.recordReachability(evt.Reachability)
+			n.audit.Log(audit.NewModeChangeEvent("reachability",
+				strings.ToLower(evt.Reachability.String()), "autonat"))
 
 			if n.relayServiceMode != RelayServiceAuto {
 				continue // "on" started at construction; "off" never runs
@@ -135,7 +135,7 @@ func TestDownloadRange_DirectUnaffectedByRelayCap(t *testing.T) {
 	for i := range payload {
 		payload[i] = byte(i)
 	}
-	server.SetContentGetter(bytesContentGetter(testHash, payload))
+	server.SetContentProvider(bytesContentGetter(testHash, payload))
 
 	// Client with the relayed-transfer cap enabled. The connection below is direct,
 	// so the cap must not apply.
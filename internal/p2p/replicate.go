@@ -0,0 +1,307 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"go.uber.org/zap"
+)
+
+// ProtocolReplicate carries new-content events between replication
+// partners, seeds that keep copies of each other's caches. The subscriber
+// writes one JSON line, a ReplicationRequest, and the partner answers with
+// ReplicationEvent lines: first the packages it cached since the requested
+// time, then each package as it is cached, for as long as the stream stays
+// open. Empty lines are heartbeats. Only peers listed as replication
+// partners may subscribe. The packages themselves are fetched over the
+// transfer protocol like any other download.
+const ProtocolReplicate = "/debswarm/replicate/1.0.0"
+
+const (
+	// replicationHeartbeat is how often an idle subscription is written
+	// to; a subscriber that hears nothing for three intervals reconnects.
+	replicationHeartbeat = 30 * time.Second
+
+	// replicationBuffer is the events queued per subscriber. A subscriber
+	// that falls further behind is disconnected, and catches up from the
+	// backlog when it resubscribes, instead of holding up the publisher.
+	replicationBuffer = 256
+
+	maxReplicationLine = 16 << 10
+)
+
+// ErrReplicationRefused is returned when a peer resets a replication
+// subscription before acknowledging it: this node is not one of its
+// replication partners, or it is paused.
+var ErrReplicationRefused = errors.New("replication subscription refused by peer")
+
+// ReplicationRequest opens a replication subscription.
+type ReplicationRequest struct {
+	// Since asks for the packages cached at or after this time before the
+	// live events (zero = live events only).
+	Since time.Time `json:"since"`
+}
+
+// ReplicationEvent announces a package a replication partner has cached.
+type ReplicationEvent struct {
+	SHA256       string    `json:"sha256"`
+	Size         int64     `json:"size"`
+	Filename     string    `json:"filename"`
+	Package      string    `json:"package,omitempty"`
+	Version      string    `json:"version,omitempty"`
+	Architecture string    `json:"architecture,omitempty"`
+	Repo         string    `json:"repo,omitempty"`
+	Suite        string    `json:"suite,omitempty"`
+	Component    string    `json:"component,omitempty"`
+	CachedAt     time.Time `json:"cached_at"`
+}
+
+// ReplicationBacklog returns the events for the packages cached at or after
+// since, oldest first.
+type ReplicationBacklog func(since time.Time) []ReplicationEvent
+
+// replicationState is the publishing side of replication: the partners
+// allowed to subscribe and their live subscriptions.
+type replicationState struct {
+	partners map[peer.ID]peer.AddrInfo
+
+	mu      sync.Mutex
+	subs    map[*replicationSub]struct{}
+	backlog ReplicationBacklog
+}
+
+type replicationSub struct {
+	events chan ReplicationEvent
+	// overflow is closed when the subscriber fell behind
+	overflow     chan struct{}
+	overflowOnce sync.Once
+}
+
+// startReplication registers the replication handler when partners are
+// configured.
+func (n *Node) startReplication(partners []peer.AddrInfo) {
+	if len(partners) == 0 {
+		return
+	}
+	n.replication.partners = make(map[peer.ID]peer.AddrInfo, len(partners))
+	n.replication.subs = make(map[*replicationSub]struct{})
+	for _, p := range partners {
+		n.replication.partners[p.ID] = p
+		n.host.Peerstore().AddAddrs(p.ID, p.Addrs, peerstore.PermanentAddrTTL)
+		// A partner connection carries a long-lived subscription; keep the
+		// connection manager from trimming it
+		n.host.ConnManager().Protect(p.ID, "replication")
+	}
+	n.host.SetStreamHandler(protocol.ID(ProtocolReplicate), n.handleReplicateStream)
+}
+
+// ReplicationPartners returns the configured replication partners.
+func (n *Node) ReplicationPartners() []peer.AddrInfo {
+	out := make([]peer.AddrInfo, 0, len(n.replication.partners))
+	for _, p := range n.replication.partners {
+		out = append(out, p)
+	}
+	return out
+}
+
+// SetReplicationBacklog sets where subscribers' catch-up events come from.
+func (n *Node) SetReplicationBacklog(fn ReplicationBacklog) {
+	n.replication.mu.Lock()
+	n.replication.backlog = fn
+	n.replication.mu.Unlock()
+}
+
+// HasReplicationSubscribers reports whether any partner is subscribed.
+func (n *Node) HasReplicationSubscribers() bool {
+	n.replication.mu.Lock()
+	defer n.replication.mu.Unlock()
+	return len(n.replication.subs) > 0
+}
+
+// PublishReplication sends ev to every subscribed partner. It never blocks:
+// a partner too far behind is disconnected and catches up when it
+// resubscribes.
+func (n *Node) PublishReplication(ev ReplicationEvent) {
+	n.replication.mu.Lock()
+	defer n.replication.mu.Unlock()
+	for sub := range n.replication.subs {
+		select {
+		case sub.events <- ev:
+		default:
+			sub.overflowOnce.Do(func() { close(sub.overflow) })
+		}
+	}
+}
+
+func (n *Node) handleReplicateStream(s network.Stream) {
+	defer s.Close()
+	pid := s.Conn().RemotePeer()
+	if _, ok := n.replication.partners[pid]; !ok || n.paused.Load() {
+		_ = s.Reset()
+		return
+	}
+
+	_ = s.SetReadDeadline(time.Now().Add(replicationHeartbeat))
+	var req ReplicationRequest
+	line, err := bufio.NewReaderSize(s, maxReplicationLine).ReadSlice('\n')
+	if err != nil || json.Unmarshal(line, &req) != nil {
+		_ = s.Reset()
+		return
+	}
+	_ = s.SetReadDeadline(time.Time{})
+
+	// Subscribe before reading the backlog, so nothing cached in between
+	// is missed; a package sent twice is simply skipped by the subscriber
+	sub := &replicationSub{
+		events:   make(chan ReplicationEvent, replicationBuffer),
+		overflow: make(chan struct{}),
+	}
+	n.replication.mu.Lock()
+	n.replication.subs[sub] = struct{}{}
+	backlog := n.replication.backlog
+	n.replication.mu.Unlock()
+	defer func() {
+		n.replication.mu.Lock()
+		delete(n.replication.subs, sub)
+		n.replication.mu.Unlock()
+	}()
+
+	n.logger.Info("Replication partner subscribed",
+		zap.String("peer", pid.String()), zap.Time("since", req.Since))
+
+	w := bufio.NewWriter(s)
+	send := func(ev *ReplicationEvent) error {
+		_ = s.SetWriteDeadline(time.Now().Add(replicationHeartbeat))
+		if ev != nil {
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	// A first heartbeat acknowledges the subscription
+	if err := send(nil); err != nil {
+		_ = s.Reset()
+		return
+	}
+	if backlog != nil && !req.Since.IsZero() {
+		for _, ev := range backlog(req.Since) {
+			if err := send(&ev); err != nil {
+				_ = s.Reset()
+				return
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(replicationHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-sub.overflow:
+			n.logger.Warn("Replication partner fell behind, disconnecting it to catch up",
+				zap.String("peer", pid.String()))
+			_ = s.Reset()
+			return
+		case ev := <-sub.events:
+			err = send(&ev)
+		case <-heartbeat.C:
+			if n.paused.Load() {
+				_ = s.Reset()
+				return
+			}
+			err = send(nil)
+		}
+		if err != nil {
+			_ = s.Reset()
+			return
+		}
+	}
+}
+
+// SubscribeReplication subscribes to a replication partner's new-content
+// events, starting with the packages it cached since the given time. It
+// calls subscribed, if not nil, once the partner accepts, then handle for
+// each event until ctx is canceled or the subscription breaks. It always
+// returns a non-nil error.
+func (n *Node) SubscribeReplication(ctx context.Context, partner peer.AddrInfo, since time.Time, subscribed func(), handle func(ReplicationEvent)) error {
+	if n.paused.Load() {
+		return ErrPaused
+	}
+	if len(partner.Addrs) > 0 {
+		connectCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := n.host.Connect(connectCtx, partner)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("connect: %w", err)
+		}
+	}
+	s, err := n.host.NewStream(ctx, partner.ID, protocol.ID(ProtocolReplicate))
+	if err != nil {
+		return fmt.Errorf("open replication stream: %w", err)
+	}
+	defer s.Close()
+	stop := context.AfterFunc(ctx, func() { _ = s.Reset() })
+	defer stop()
+
+	req, err := json.Marshal(ReplicationRequest{Since: since})
+	if err != nil {
+		return err
+	}
+	_ = s.SetWriteDeadline(time.Now().Add(replicationHeartbeat))
+	if _, err := s.Write(append(req, '\n')); err != nil {
+		_ = s.Reset()
+		return fmt.Errorf("send replication request: %w", err)
+	}
+
+	r := bufio.NewReaderSize(s, maxReplicationLine)
+	for received := false; ; {
+		_ = s.SetReadDeadline(time.Now().Add(3 * replicationHeartbeat))
+		line, err := r.ReadSlice('\n')
+		if err != nil {
+			_ = s.Reset()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// A partner that does not list us resets before acknowledging
+			if !received && errors.Is(err, network.ErrReset) {
+				return ErrReplicationRefused
+			}
+			return fmt.Errorf("replication stream: %w", err)
+		}
+		if !received {
+			received = true
+			if subscribed != nil {
+				subscribed()
+			}
+		}
+		if len(line) == 1 {
+			continue // heartbeat
+		}
+		var ev ReplicationEvent
+		if err := json.Unmarshal(line, &ev); err != nil {
+			_ = s.Reset()
+			return fmt.Errorf("malformed replication event: %w", err)
+		}
+		handle(ev)
+	}
+}
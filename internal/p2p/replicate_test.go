@@ -0,0 +1,97 @@
+package p2p
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestReplication(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	logger := newTestLogger()
+
+	// The subscriber replicates from some other seed, not the publisher
+	_, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subCfg := newTestConfig(t)
+	subCfg.ReplicationPartners = []peer.AddrInfo{{ID: other}}
+	subscriber, err := New(ctx, subCfg, logger)
+	if err != nil {
+		t.Fatalf("New subscriber failed: %v", err)
+	}
+	defer subscriber.Close()
+
+	cfg := newTestConfig(t)
+	cfg.ReplicationPartners = []peer.AddrInfo{{ID: subscriber.PeerID()}}
+	publisher, err := New(ctx, cfg, logger)
+	if err != nil {
+		t.Fatalf("New publisher failed: %v", err)
+	}
+	defer publisher.Close()
+
+	cachedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	var backlogSince time.Time
+	publisher.SetReplicationBacklog(func(since time.Time) []ReplicationEvent {
+		backlogSince = since
+		return []ReplicationEvent{{SHA256: "old", Size: 1, CachedAt: cachedAt}}
+	})
+
+	partner := peer.AddrInfo{ID: publisher.PeerID(), Addrs: publisher.Addrs()}
+	since := time.Now().Add(-24 * time.Hour).UTC().Truncate(time.Second)
+	events := make(chan ReplicationEvent, 4)
+	subscribed := make(chan struct{})
+	subCtx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- subscriber.SubscribeReplication(subCtx, partner, since,
+			func() { close(subscribed) },
+			func(ev ReplicationEvent) { events <- ev })
+	}()
+
+	select {
+	case <-subscribed:
+	case <-ctx.Done():
+		t.Fatal("subscription was not acknowledged")
+	}
+	ev := <-events
+	if ev.SHA256 != "old" || !ev.CachedAt.Equal(cachedAt) || !backlogSince.Equal(since) {
+		t.Errorf("backlog event = %+v, since %v", ev, backlogSince)
+	}
+	if !publisher.HasReplicationSubscribers() {
+		t.Fatal("publisher has no subscribers")
+	}
+
+	publisher.PublishReplication(ReplicationEvent{SHA256: "new", Size: 2, Architecture: "amd64"})
+	select {
+	case ev := <-events:
+		if ev.SHA256 != "new" || ev.Architecture != "amd64" {
+			t.Errorf("live event = %+v", ev)
+		}
+	case <-ctx.Done():
+		t.Fatal("live event not received")
+	}
+
+	stop()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("SubscribeReplication after cancel: %v", err)
+	}
+
+	// The publisher is not one of the subscriber's partners
+	back := peer.AddrInfo{ID: subscriber.PeerID(), Addrs: subscriber.Addrs()}
+	err = publisher.SubscribeReplication(ctx, back, time.Time{}, nil, func(ReplicationEvent) {})
+	if !errors.Is(err, ErrReplicationRefused) {
+		t.Errorf("subscription to a non-partner: err = %v, want ErrReplicationRefused", err)
+	}
+}
@@ -0,0 +1,43 @@
+package p2p
+
+import (
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// SharingPolicy decides how uploads treat a peer by its reciprocity: bytes
+// it has served this node against bytes it has taken. The zero value serves
+// every peer alike.
+//
+// Totals come from the peer scorer, so they cover the daemon's lifetime; a
+// restarted daemon gives every peer a fresh grace allowance. Peers only
+// reciprocate with packages we ask for, so MinRatio is a floor against pure
+// leechers, not a demand for parity.
+type SharingPolicy struct {
+	Reciprocal        bool    // enable the policy; false serves everyone alike
+	Grace             int64   // bytes a peer may take before reciprocity counts
+	MinRatio          float64 // served/taken below this makes a peer a leecher
+	LeecherUploadRate int64   // per-peer upload cap for leechers, bytes/sec (0 = none)
+	LeecherMaxUploads int     // concurrent uploads for a leecher (0 = refuse)
+}
+
+// isLeecher reports whether the sharing policy throttles uploads to peerID.
+// LAN (mDNS) peers are exempt: a local fleet shares through whichever node
+// fetched first, and throttling it only sends traffic to the mirror.
+func (n *Node) isLeecher(peerID peer.ID) bool {
+	if !n.sharing.Reciprocal {
+		return false
+	}
+	st := n.scorer.GetStats(peerID)
+	if st == nil || st.IsMDNSPeer || st.BytesUploaded <= n.sharing.Grace {
+		return false
+	}
+	return float64(st.BytesDownloaded) < n.sharing.MinRatio*float64(st.BytesUploaded)
+}
+
+// maxUploadsFor returns how many concurrent uploads peerID may have.
+func (n *Node) maxUploadsFor(peerID peer.ID) int {
+	if n.isLeecher(peerID) {
+		return min(n.sharing.LeecherMaxUploads, MaxUploadsPerPeer)
+	}
+	return MaxUploadsPerPeer
+}
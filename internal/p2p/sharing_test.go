@@ -0,0 +1,72 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/debswarm/debswarm/internal/priority"
+)
+
+func TestNode_ReciprocalSharing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cfg := newTestConfig(t)
+	cfg.Sharing = SharingPolicy{Reciprocal: true, Grace: 1000, MinRatio: 0.1, LeecherMaxUploads: 1}
+	node, err := New(ctx, cfg, newTestLogger())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer node.Close()
+
+	leecher := peer.ID("leecher")
+	scorer := node.Scorer()
+
+	// Within the grace allowance a peer is served like any other.
+	scorer.RecordUpload(leecher, 900)
+	if node.isLeecher(leecher) || node.maxUploadsFor(leecher) != MaxUploadsPerPeer {
+		t.Fatal("peer throttled within the grace allowance")
+	}
+
+	// Past it, a peer that served nothing back gets one upload at a time.
+	scorer.RecordUpload(leecher, 1100)
+	if !node.isLeecher(leecher) {
+		t.Fatal("peer that only takes is not a leecher")
+	}
+	if !node.tryAcceptUpload(leecher, false, priority.Interactive) {
+		t.Fatal("leecher refused its one upload")
+	}
+	if node.tryAcceptUpload(leecher, false, priority.Interactive) {
+		t.Error("leecher accepted a second concurrent upload")
+	}
+	// Security updates still reach it.
+	if !node.tryAcceptUpload(leecher, true, priority.Interactive) {
+		t.Error("leecher refused a priority upload")
+	}
+	node.trackUploadEnd(leecher)
+	node.trackUploadEnd(leecher)
+
+	// Serving back at least MinRatio restores normal treatment.
+	scorer.RecordSuccess(leecher, 200, 10, 1<<20)
+	if node.isLeecher(leecher) {
+		t.Error("peer above the minimum ratio is still a leecher")
+	}
+
+	// LAN peers are never throttled.
+	lan := peer.ID("lan")
+	scorer.RecordUpload(lan, 1<<20)
+	scorer.MarkAsMDNSPeer(lan)
+	if node.isLeecher(lan) {
+		t.Error("mDNS peer throttled")
+	}
+
+	// The default policy serves everyone alike.
+	node.sharing.Reciprocal = false
+	scorer.RecordUpload(leecher, 1<<30)
+	if node.isLeecher(leecher) {
+		t.Error("altruistic policy throttled a peer")
+	}
+}
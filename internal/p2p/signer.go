@@ -0,0 +1,222 @@
+// Package p2p - Hardware-backed identity via an external signer
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	pb "github.com/libp2p/go-libp2p/core/crypto/pb"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
+	"github.com/quic-go/quic-go"
+	"go.uber.org/fx"
+)
+
+// signerTimeout bounds each call to the external signer. Hardware tokens
+// can be slow, but a handshake must not hang on a wedged helper.
+const signerTimeout = 10 * time.Second
+
+// ErrKeyNotExportable is returned when the private key bytes of a
+// hardware-backed identity are requested.
+var ErrKeyNotExportable = errors.New("identity key is held by an external signer and cannot be exported")
+
+// ExternalSigner is an identity key held outside the process, typically in
+// a PKCS#11 token or TPM, and used through a helper program:
+//
+//	<program> public-key   prints the PKIX public key, PEM or DER
+//	<program> sign         signs stdin and prints the signature
+//
+// Signatures use the libp2p conventions for the key type: Ed25519 over the
+// data, ECDSA over its SHA-256 (ASN.1 DER or raw r||s), and RSA PKCS#1 v1.5
+// with SHA-256. The private key never enters debswarm's memory, so the peer
+// ID stays bound to the hardware.
+type ExternalSigner struct {
+	program string
+	pub     crypto.PubKey
+}
+
+var _ crypto.PrivKey = (*ExternalSigner)(nil)
+
+// NewExternalSigner queries program for its public key.
+func NewExternalSigner(program string) (*ExternalSigner, error) {
+	out, err := runSigner(program, "public-key", nil)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := parseSignerPublicKey(out)
+	if err != nil {
+		return nil, fmt.Errorf("identity signer %s: %w", program, err)
+	}
+	return &ExternalSigner{program: program, pub: pub}, nil
+}
+
+// Program returns the path of the helper program.
+func (s *ExternalSigner) Program() string {
+	return s.program
+}
+
+// Sign asks the helper to sign data and checks the signature before
+// returning it, so a misbehaving helper fails here rather than in a peer's
+// handshake.
+func (s *ExternalSigner) Sign(data []byte) ([]byte, error) {
+	sig, err := runSigner(s.program, "sign", data)
+	if err != nil {
+		return nil, err
+	}
+	if s.pub.Type() == pb.KeyType_ECDSA {
+		sig = ecdsaSignatureDER(s.pub, sig)
+	}
+	ok, err := s.pub.Verify(data, sig)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("identity signer %s returned an invalid signature", s.program)
+	}
+	return sig, nil
+}
+
+// GetPublic returns the public key.
+func (s *ExternalSigner) GetPublic() crypto.PubKey {
+	return s.pub
+}
+
+// Type returns the key type.
+func (s *ExternalSigner) Type() pb.KeyType {
+	return s.pub.Type()
+}
+
+// Raw always fails: the private key is not available.
+func (s *ExternalSigner) Raw() ([]byte, error) {
+	return nil, ErrKeyNotExportable
+}
+
+// Equals reports whether o is an external signer for the same key.
+func (s *ExternalSigner) Equals(o crypto.Key) bool {
+	other, ok := o.(*ExternalSigner)
+	return ok && s.pub.Equals(other.pub)
+}
+
+// runSigner runs the helper with one argument, feeding it stdin.
+func runSigner(program, arg string, stdin []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), signerTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, program, arg)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("identity signer %s %s: %w: %s", program, arg, err, msg)
+		}
+		return nil, fmt.Errorf("identity signer %s %s: %w", program, arg, err)
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("identity signer %s %s: no output", program, arg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// parseSignerPublicKey converts a PEM or DER PKIX public key.
+func parseSignerPublicKey(data []byte) (crypto.PubKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	std, err := x509.ParsePKIXPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	switch k := std.(type) {
+	case ed25519.PublicKey:
+		return crypto.UnmarshalEd25519PublicKey(k)
+	case *ecdsa.PublicKey:
+		return crypto.ECDSAPublicKeyFromPubKey(*k)
+	case *rsa.PublicKey:
+		return crypto.UnmarshalRsaPublicKey(data)
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", std)
+	}
+}
+
+// ecdsaSignatureDER converts a raw r||s signature, as PKCS#11 tokens
+// produce, to the ASN.1 form libp2p verifies. Anything else is returned
+// unchanged.
+func ecdsaSignatureDER(pub crypto.PubKey, sig []byte) []byte {
+	std, err := crypto.PubKeyToStdKey(pub)
+	if err != nil {
+		return sig
+	}
+	k, ok := std.(*ecdsa.PublicKey)
+	if !ok {
+		return sig
+	}
+	size := (k.Curve.Params().BitSize + 7) / 8
+	if len(sig) != 2*size {
+		return sig
+	}
+	if rest, err := asn1.Unmarshal(sig, new(crypto.ECDSASig)); err == nil && len(rest) == 0 {
+		return sig
+	}
+	der, err := asn1.Marshal(crypto.ECDSASig{
+		R: new(big.Int).SetBytes(sig[:size]),
+		S: new(big.Int).SetBytes(sig[size:]),
+	})
+	if err != nil {
+		return sig
+	}
+	return der
+}
+
+// signerQUICReuse replaces libp2p's QUIC connection manager for a
+// signer-backed identity. The default one derives its stateless reset and
+// token keys from the private key bytes, which a signer cannot provide, so
+// these are random per process instead: stateless resets and address
+// validation tokens from before a restart are not recognised, as with a
+// fresh key. Otherwise it is set up like the default.
+func signerQUICReuse() libp2p.Option {
+	return libp2p.QUICReuse(func(rcmgr network.ResourceManager, lifecycle fx.Lifecycle) (*quicreuse.ConnManager, error) {
+		var resetKey quic.StatelessResetKey
+		var tokenKey quic.TokenGeneratorKey
+		if _, err := rand.Read(resetKey[:]); err != nil {
+			return nil, err
+		}
+		if _, err := rand.Read(tokenKey[:]); err != nil {
+			return nil, err
+		}
+		cm, err := quicreuse.NewConnManager(resetKey, tokenKey,
+			quicreuse.ConnContext(func(ctx context.Context, clientInfo *quic.ClientInfo) (context.Context, error) {
+				addr, err := quicreuse.ToQuicMultiaddr(clientInfo.RemoteAddr, quic.Version1)
+				if err != nil {
+					addr = nil
+				}
+				scope, err := rcmgr.OpenConnection(network.DirInbound, false, addr)
+				if err != nil {
+					return ctx, err
+				}
+				ctx = network.WithConnManagementScope(ctx, scope)
+				context.AfterFunc(ctx, scope.Done)
+				return ctx, nil
+			}),
+			quicreuse.VerifySourceAddress(rcmgr.VerifySourceAddress),
+		)
+		if err != nil {
+			return nil, err
+		}
+		lifecycle.Append(fx.StopHook(cm.Close))
+		return cm, nil
+	})
+}
@@ -0,0 +1,178 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// TestSignerHelperProcess is the fake hardware token run by writeSigner. It
+// holds the key from DEBSWARM_TEST_SIGNER_KEY and does nothing in a normal
+// test run.
+func TestSignerHelperProcess(t *testing.T) {
+	if os.Getenv("DEBSWARM_TEST_SIGNER") != "1" {
+		return
+	}
+	keyBytes, _ := hex.DecodeString(os.Getenv("DEBSWARM_TEST_SIGNER_KEY"))
+	key, err := crypto.UnmarshalPrivateKey(keyBytes)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	switch os.Args[len(os.Args)-1] {
+	case "public-key":
+		std, _ := crypto.PubKeyToStdKey(key.GetPublic())
+		der, _ := x509.MarshalPKIXPublicKey(std)
+		_ = pem.Encode(os.Stdout, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	case "sign":
+		data, _ := io.ReadAll(os.Stdin)
+		sig, _ := key.Sign(data)
+		switch os.Getenv("DEBSWARM_TEST_SIGNER_MODE") {
+		case "raw":
+			// r||s as PKCS#11 tokens return it
+			var es crypto.ECDSASig
+			_, _ = asn1.Unmarshal(sig, &es)
+			std, _ := crypto.PubKeyToStdKey(key.GetPublic())
+			n := (std.(*ecdsa.PublicKey).Curve.Params().BitSize + 7) / 8
+			sig = append(es.R.FillBytes(make([]byte, n)), es.S.FillBytes(make([]byte, n))...)
+		case "bad":
+			sig[0] ^= 0xff
+		}
+		_, _ = os.Stdout.Write(sig)
+	default:
+		fmt.Fprintln(os.Stderr, "unknown command")
+		os.Exit(2)
+	}
+	os.Exit(0)
+}
+
+// writeSigner writes a helper program that runs TestSignerHelperProcess
+// holding key.
+func writeSigner(t *testing.T, key crypto.PrivKey, mode string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("signer helper is a shell script")
+	}
+	keyBytes, err := crypto.MarshalPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := fmt.Sprintf("#!/bin/sh\nDEBSWARM_TEST_SIGNER=1 DEBSWARM_TEST_SIGNER_KEY=%s DEBSWARM_TEST_SIGNER_MODE=%s exec %q -test.run='^TestSignerHelperProcess$' -- \"$1\"\n",
+		hex.EncodeToString(keyBytes), mode, os.Args[0])
+	path := filepath.Join(t.TempDir(), "signer")
+	if err := os.WriteFile(path, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExternalSigner(t *testing.T) {
+	ecKey, _, err := crypto.GenerateECDSAKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		key  crypto.PrivKey
+		mode string
+	}{
+		{"ed25519", edKey, ""},
+		{"ecdsa-der", ecKey, ""},
+		{"ecdsa-raw", ecKey, "raw"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			signer, err := NewExternalSigner(writeSigner(t, tc.key, tc.mode))
+			if err != nil {
+				t.Fatalf("NewExternalSigner failed: %v", err)
+			}
+			if IdentityFingerprint(signer) != IdentityFingerprint(tc.key) {
+				t.Error("signer has a different peer ID from its key")
+			}
+			sig, err := signer.Sign([]byte("handshake"))
+			if err != nil {
+				t.Fatalf("Sign failed: %v", err)
+			}
+			if ok, _ := tc.key.GetPublic().Verify([]byte("handshake"), sig); !ok {
+				t.Error("signature does not verify")
+			}
+			if _, err := crypto.MarshalPrivateKey(signer); !errors.Is(err, ErrKeyNotExportable) {
+				t.Errorf("marshal: err = %v, want ErrKeyNotExportable", err)
+			}
+		})
+	}
+
+	bad, err := NewExternalSigner(writeSigner(t, edKey, "bad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bad.Sign([]byte("handshake")); err == nil {
+		t.Error("invalid signature accepted")
+	}
+	if _, err := NewExternalSigner(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing signer accepted")
+	}
+}
+
+func TestNew_IdentitySigner(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	logger := newTestLogger()
+
+	key, _, err := crypto.GenerateECDSAKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg1 := newTestConfig(t)
+	cfg1.IdentitySigner = writeSigner(t, key, "raw")
+	node1, err := New(ctx, cfg1, logger)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer node1.Close()
+	if node1.PeerID().String() != IdentityFingerprint(key) {
+		t.Errorf("peer ID = %s, want the signer's", node1.PeerID())
+	}
+	if _, err := os.Stat(filepath.Join(cfg1.DataDir, IdentityKeyFile)); err == nil {
+		t.Error("identity key file written despite the signer")
+	}
+
+	// The handshake is signed by the helper, over TCP and QUIC
+	for _, transport := range []string{"/tcp/", "/quic-v1"} {
+		var addrs []multiaddr.Multiaddr
+		for _, a := range node1.Addrs() {
+			if strings.Contains(a.String(), transport) {
+				addrs = append(addrs, a)
+			}
+		}
+		node2, err := New(ctx, newTestConfig(t), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := node2.host.Connect(ctx, peer.AddrInfo{ID: node1.PeerID(), Addrs: addrs}); err != nil {
+			t.Errorf("Failed to connect to signer-backed node over %s: %v", transport, err)
+		}
+		node2.Close()
+	}
+}
@@ -0,0 +1,283 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/metrics"
+)
+
+// transferSessionIdle is how long a node keeps a ProtocolTransferV2 stream
+// open waiting for the peer's next request
+const transferSessionIdle = 90 * time.Second
+
+// MaxStreamKeepAlive bounds how long an idle stream is pooled. It stays
+// below transferSessionIdle so the peer does not close a session just as it
+// is reused.
+const MaxStreamKeepAlive = 60 * time.Second
+
+// Defaults of StreamPoolConfig
+const (
+	DefaultStreamMaxIdle   = 4
+	DefaultStreamKeepAlive = 30 * time.Second
+)
+
+// streamLeakGrace is how long a stream may stay checked out past its
+// deadline before it is reported as leaked: by then every read and write on
+// it has failed, so only a missing release keeps it out of the pool
+const streamLeakGrace = time.Minute
+
+// StreamPoolConfig keeps transfer streams to peers open between downloads,
+// so successive chunks reuse a stream instead of opening one each. Only
+// ProtocolTransferV2 streams are pooled. A nil config on the node disables
+// pooling.
+type StreamPoolConfig struct {
+	// MaxIdle is the most idle streams kept per peer (0 = 4)
+	MaxIdle int
+	// KeepAlive is how long an idle stream is kept (0 = 30s, at most
+	// MaxStreamKeepAlive)
+	KeepAlive time.Duration
+}
+
+// idleStream is a pooled stream and when it was returned
+type idleStream struct {
+	stream network.Stream
+	since  time.Time
+}
+
+// streamPool holds the idle transfer streams of each peer, and the deadline
+// of each stream checked out of it, to find those never returned.
+type streamPool struct {
+	maxIdle   int
+	keepAlive time.Duration
+	metrics   *metrics.Metrics
+	logger    *zap.Logger
+
+	mu     sync.Mutex
+	idle   map[peer.ID][]idleStream
+	nIdle  int
+	busy   map[network.Stream]time.Time
+	closed bool
+}
+
+func newStreamPool(cfg StreamPoolConfig, m *metrics.Metrics, logger *zap.Logger) *streamPool {
+	p := &streamPool{
+		maxIdle:   cfg.MaxIdle,
+		keepAlive: cfg.KeepAlive,
+		metrics:   m,
+		logger:    logger,
+		idle:      make(map[peer.ID][]idleStream),
+		busy:      make(map[network.Stream]time.Time),
+	}
+	if p.maxIdle <= 0 {
+		p.maxIdle = DefaultStreamMaxIdle
+	}
+	if p.keepAlive <= 0 {
+		p.keepAlive = DefaultStreamKeepAlive
+	}
+	p.keepAlive = min(p.keepAlive, MaxStreamKeepAlive)
+	return p
+}
+
+// get checks out the most recently used idle stream to id that has not
+// expired, or returns nil.
+func (p *streamPool) get(id peer.ID, now time.Time) network.Stream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	streams := p.idle[id]
+	for len(streams) > 0 {
+		last := streams[len(streams)-1]
+		streams = streams[:len(streams)-1]
+		p.nIdle--
+		if now.Sub(last.since) >= p.keepAlive || last.stream.Conn().IsClosed() {
+			_ = last.stream.Close()
+			continue
+		}
+		p.setIdle(id, streams)
+		p.busy[last.stream] = now.Add(transferSessionIdle)
+		p.count("reused")
+		return last.stream
+	}
+	p.setIdle(id, nil)
+	return nil
+}
+
+// opened checks out a stream just opened, until deadline.
+func (p *streamPool) opened(s network.Stream, deadline time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.busy[s] = deadline
+	p.count("opened")
+}
+
+// extend moves the deadline of a checked-out stream, as its transfer
+// deadline is.
+func (p *streamPool) extend(s network.Stream, deadline time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.busy[s]; ok {
+		p.busy[s] = deadline
+	}
+}
+
+// put returns a checked-out stream that is ready for another request. It
+// is closed instead if the peer already has MaxIdle idle streams.
+func (p *streamPool) put(s network.Stream, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.busy, s)
+	id := s.Conn().RemotePeer()
+	if p.closed || len(p.idle[id]) >= p.maxIdle {
+		_ = s.Close()
+		return
+	}
+	p.idle[id] = append(p.idle[id], idleStream{stream: s, since: now})
+	p.nIdle++
+	p.updateIdle()
+}
+
+// drop forgets a checked-out stream that the caller closes.
+func (p *streamPool) drop(s network.Stream) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.busy, s)
+}
+
+// sweep closes idle streams past their keep-alive, and resets streams still
+// checked out streamLeakGrace after their deadline.
+func (p *streamPool) sweep(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, streams := range p.idle {
+		kept := streams[:0]
+		for _, is := range streams {
+			if now.Sub(is.since) >= p.keepAlive {
+				_ = is.stream.Close()
+				p.nIdle--
+				continue
+			}
+			kept = append(kept, is)
+		}
+		p.setIdle(id, kept)
+	}
+	for s, deadline := range p.busy {
+		if now.Sub(deadline) < streamLeakGrace {
+			continue
+		}
+		delete(p.busy, s)
+		_ = s.Reset()
+		if p.metrics != nil {
+			p.metrics.PeerStreamsLeaked.Inc()
+		}
+		p.logger.Warn("Transfer stream was never returned to the pool, resetting it",
+			zap.String("peer", s.Conn().RemotePeer().String()),
+			zap.Duration("pastDeadline", now.Sub(deadline)))
+	}
+	p.updateIdle()
+}
+
+// run sweeps the pool until ctx is done, then closes it.
+func (p *streamPool) run(ctx context.Context) {
+	ticker := time.NewTicker(p.keepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			p.close()
+			return
+		case now := <-ticker.C:
+			p.sweep(now)
+		}
+	}
+}
+
+// close closes the idle streams; streams returned later are closed too.
+func (p *streamPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for _, streams := range p.idle {
+		for _, is := range streams {
+			_ = is.stream.Close()
+		}
+	}
+	p.idle = make(map[peer.ID][]idleStream)
+	p.nIdle = 0
+	p.updateIdle()
+}
+
+// setIdle replaces the idle streams of id. Caller must hold p.mu.
+func (p *streamPool) setIdle(id peer.ID, streams []idleStream) {
+	if len(streams) == 0 {
+		delete(p.idle, id)
+	} else {
+		p.idle[id] = streams
+	}
+	p.updateIdle()
+}
+
+// updateIdle publishes the idle stream count. Caller must hold p.mu.
+func (p *streamPool) updateIdle() {
+	if p.metrics != nil {
+		p.metrics.PeerStreamsIdle.Set(float64(p.nIdle))
+	}
+}
+
+// count records a stream checked out. Caller must hold p.mu.
+func (p *streamPool) count(result string) {
+	if p.metrics != nil {
+		p.metrics.PeerStreams.WithLabel(result).Inc()
+	}
+}
+
+// openTransferStream returns a pooled stream to id if one is idle, or opens
+// one with the first of protos the peer supports. reused reports a pooled
+// stream.
+func (n *Node) openTransferStream(ctx context.Context, id peer.ID, protos []protocol.ID) (stream network.Stream, reused bool, err error) {
+	if n.streams != nil {
+		if s := n.streams.get(id, time.Now()); s != nil {
+			return s, true, nil
+		}
+	}
+	s, err := n.host.NewStream(ctx, id, protos...)
+	if err != nil {
+		return nil, false, err
+	}
+	if n.streams != nil {
+		n.streams.opened(s, time.Now().Add(transferSessionIdle))
+	}
+	return s, false, nil
+}
+
+// releaseTransferStream pools a stream whose response was read in full, if
+// it is a session, and closes any other.
+func (n *Node) releaseTransferStream(s network.Stream, reusable bool) {
+	if n.streams == nil {
+		_ = s.Close()
+		return
+	}
+	if reusable && s.Protocol() == ProtocolTransferV2 {
+		n.streams.put(s, time.Now())
+		return
+	}
+	n.streams.drop(s)
+	_ = s.Close()
+}
+
+// setTransferDeadline sets the deadline of a transfer stream, which the
+// pool's leak check follows.
+func (n *Node) setTransferDeadline(s network.Stream, d time.Duration) {
+	deadline := time.Now().Add(d)
+	if err := s.SetDeadline(deadline); err != nil {
+		n.logger.Debug("Failed to set client stream deadline", zap.Error(err))
+	}
+	if n.streams != nil {
+		n.streams.extend(s, deadline)
+	}
+}
@@ -0,0 +1,234 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/metrics"
+)
+
+// pooledConn is the connection of a pooledStream
+type pooledConn struct {
+	network.Conn
+	remote peer.ID
+	closed bool
+}
+
+func (c *pooledConn) RemotePeer() peer.ID { return c.remote }
+func (c *pooledConn) IsClosed() bool      { return c.closed }
+
+// pooledStream records how a pooled stream was let go
+type pooledStream struct {
+	network.Stream
+	conn   *pooledConn
+	closed bool
+	reset  bool
+}
+
+func (s *pooledStream) Conn() network.Conn { return s.conn }
+func (s *pooledStream) Close() error       { s.closed = true; return nil }
+func (s *pooledStream) Reset() error       { s.reset = true; return nil }
+
+func newPooledStream(remote peer.ID) *pooledStream {
+	return &pooledStream{conn: &pooledConn{remote: remote}}
+}
+
+func TestStreamPool_Reuse(t *testing.T) {
+	m := metrics.New()
+	p := newStreamPool(StreamPoolConfig{MaxIdle: 2, KeepAlive: 30 * time.Second}, m, zap.NewNop())
+	now := time.Now()
+
+	if s := p.get("peer-a", now); s != nil {
+		t.Fatal("empty pool returned a stream")
+	}
+
+	s1, s2, s3 := newPooledStream("peer-a"), newPooledStream("peer-a"), newPooledStream("peer-a")
+	for _, s := range []*pooledStream{s1, s2, s3} {
+		p.opened(s, now.Add(time.Minute))
+	}
+	p.put(s1, now)
+	p.put(s2, now)
+	p.put(s3, now) // over MaxIdle
+	if !s3.closed || s1.closed || s2.closed {
+		t.Errorf("closed = %v %v %v, want only the third", s1.closed, s2.closed, s3.closed)
+	}
+	if got := m.PeerStreamsIdle.Value(); got != 2 {
+		t.Errorf("idle gauge = %v, want 2", got)
+	}
+
+	if s := p.get("peer-b", now); s != nil {
+		t.Error("pool returned another peer's stream")
+	}
+	if s := p.get("peer-a", now); s != s2 {
+		t.Errorf("get = %v, want the most recently returned stream", s)
+	}
+	if got := m.PeerStreams.WithLabel("reused").Value(); got != 1 {
+		t.Errorf("reused = %d, want 1", got)
+	}
+	if got := m.PeerStreams.WithLabel("opened").Value(); got != 3 {
+		t.Errorf("opened = %d, want 3", got)
+	}
+
+	// A stream whose connection went away is closed, not handed out
+	s1.conn.closed = true
+	if s := p.get("peer-a", now); s != nil {
+		t.Errorf("get = %v, want none", s)
+	}
+	if !s1.closed {
+		t.Error("stream of a closed connection was not closed")
+	}
+	if got := m.PeerStreamsIdle.Value(); got != 0 {
+		t.Errorf("idle gauge = %v, want 0", got)
+	}
+}
+
+func TestStreamPool_KeepAlive(t *testing.T) {
+	p := newStreamPool(StreamPoolConfig{KeepAlive: 10 * time.Second}, nil, zap.NewNop())
+	now := time.Now()
+
+	old, recent := newPooledStream("peer-a"), newPooledStream("peer-b")
+	p.opened(old, now)
+	p.opened(recent, now)
+	p.put(old, now)
+	p.put(recent, now.Add(8*time.Second))
+
+	p.sweep(now.Add(12 * time.Second))
+	if !old.closed || recent.closed {
+		t.Errorf("closed = %v %v, want only the expired stream", old.closed, recent.closed)
+	}
+	if s := p.get("peer-b", now.Add(12*time.Second)); s != recent {
+		t.Errorf("get = %v, want the stream still alive", s)
+	}
+
+	// An expired stream is not handed out even before a sweep
+	p.put(recent, now)
+	if s := p.get("peer-b", now.Add(20*time.Second)); s != nil {
+		t.Errorf("get = %v, want none", s)
+	}
+}
+
+func TestStreamPool_KeepAliveBounded(t *testing.T) {
+	p := newStreamPool(StreamPoolConfig{KeepAlive: time.Hour}, nil, zap.NewNop())
+	if p.keepAlive != MaxStreamKeepAlive {
+		t.Errorf("keepAlive = %v, want %v", p.keepAlive, MaxStreamKeepAlive)
+	}
+	if p.maxIdle != DefaultStreamMaxIdle {
+		t.Errorf("maxIdle = %d, want %d", p.maxIdle, DefaultStreamMaxIdle)
+	}
+}
+
+func TestStreamPool_Leak(t *testing.T) {
+	m := metrics.New()
+	p := newStreamPool(StreamPoolConfig{}, m, zap.NewNop())
+	now := time.Now()
+
+	leaked, busy, released := newPooledStream("peer-a"), newPooledStream("peer-a"), newPooledStream("peer-a")
+	p.opened(leaked, now)
+	p.opened(busy, now)
+	p.opened(released, now)
+	p.extend(busy, now.Add(time.Hour))
+	p.drop(released)
+
+	p.sweep(now.Add(streamLeakGrace + time.Second))
+	if !leaked.reset || busy.reset || released.reset {
+		t.Errorf("reset = %v %v %v, want only the leaked stream", leaked.reset, busy.reset, released.reset)
+	}
+	if got := m.PeerStreamsLeaked.Value(); got != 1 {
+		t.Errorf("leaked = %d, want 1", got)
+	}
+
+	// Reported once
+	p.sweep(now.Add(2 * streamLeakGrace))
+	if got := m.PeerStreamsLeaked.Value(); got != 1 {
+		t.Errorf("leaked after second sweep = %d, want 1", got)
+	}
+}
+
+func TestStreamPool_Close(t *testing.T) {
+	p := newStreamPool(StreamPoolConfig{}, nil, zap.NewNop())
+	now := time.Now()
+
+	idle, late := newPooledStream("peer-a"), newPooledStream("peer-a")
+	p.opened(idle, now)
+	p.opened(late, now)
+	p.put(idle, now)
+
+	p.close()
+	p.put(late, now)
+	if !idle.closed || !late.closed {
+		t.Errorf("closed = %v %v, want both", idle.closed, late.closed)
+	}
+}
+
+func TestNode_DownloadRange_ReusesStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	logger := newTestLogger()
+
+	node1, err := New(ctx, newTestConfig(t), logger)
+	if err != nil {
+		t.Fatalf("New node1 failed: %v", err)
+	}
+	defer node1.Close()
+
+	m := metrics.New()
+	cfg2 := newTestConfig(t)
+	cfg2.Metrics = m
+	cfg2.StreamPool = &StreamPoolConfig{}
+	node2, err := New(ctx, cfg2, logger)
+	if err != nil {
+		t.Fatalf("New node2 failed: %v", err)
+	}
+	defer node2.Close()
+
+	testContent := []byte("0123456789ABCDEF")
+	testHash := "a1b2c3d4e5f67890123456789012345678901234567890123456789012abcdef"
+	node1.SetContentProvider(ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+		if hash == testHash {
+			return io.NopCloser(bytes.NewReader(testContent)), int64(len(testContent)), nil
+		}
+		return nil, 0, io.EOF
+	}))
+
+	node1Info := peer.AddrInfo{ID: node1.PeerID(), Addrs: node1.Addrs()}
+	if err := node2.host.Connect(ctx, node1Info); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	// Chunks one after another ride the same stream
+	for i, want := range []string{"0123", "4567", "89AB", "CDEF"} {
+		start := int64(i * 4)
+		data, err := node2.DownloadRange(ctx, node1Info, testHash, start, start+4)
+		if err != nil {
+			t.Fatalf("DownloadRange(%d) failed: %v", start, err)
+		}
+		if string(data) != want {
+			t.Errorf("DownloadRange(%d) = %q, want %q", start, data, want)
+		}
+	}
+	if got := m.PeerStreams.WithLabel("opened").Value(); got != 1 {
+		t.Errorf("opened = %d, want 1", got)
+	}
+	if got := m.PeerStreams.WithLabel("reused").Value(); got != 3 {
+		t.Errorf("reused = %d, want 3", got)
+	}
+	if got := m.PeerStreamsIdle.Value(); got != 1 {
+		t.Errorf("idle = %v, want 1", got)
+	}
+
+	// Content the peer lacks ends the session instead of pooling it
+	if _, err := node2.DownloadRange(ctx, node1Info, "ff"+testHash[2:], 0, 0); err == nil {
+		t.Fatal("DownloadRange of missing content succeeded")
+	}
+	if got := m.PeerStreamsIdle.Value(); got != 0 {
+		t.Errorf("idle after failure = %v, want 0", got)
+	}
+}
@@ -0,0 +1,152 @@
+package p2p
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The first transfer protocols answer with the size of the bytes sent and
+// nothing else, so a receiver asking for a range learns neither the file's
+// name nor its total size. ProtocolTransferV2 takes the range frame (see
+// encodeRangeRequest) and answers with the 8-byte size of the range, 0 when
+// the content is not available, then a header describing the content: a
+// 2-byte big-endian length and a JSON ContentInfo. The content follows.
+// Receivers can show progress against the whole file, and check the range
+// and total size before any content arrives. Nodes prefer it when both ends
+// serve it; older peers keep using the version 1 protocols.
+const ProtocolTransferV2 = "/debswarm/transfer/2.0.0"
+
+// maxContentInfoLen bounds the content header a peer may send
+const maxContentInfoLen = 4096
+
+// maxContentNameLen bounds the file name in a content header
+const maxContentNameLen = 255
+
+// ContentInfo describes the content of a ProtocolTransferV2 response.
+type ContentInfo struct {
+	// TotalSize is the size of the whole file
+	TotalSize int64 `json:"total_size"`
+	// Start and End delimit the bytes sent, End exclusive
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	// Filename is the file's name, e.g. "curl_7.88.1-10_amd64.deb", if the
+	// sender knows it
+	Filename string `json:"filename,omitempty"`
+}
+
+// ContentNamer returns the file name of content served to peers, or "" if
+// it is not known.
+type ContentNamer func(sha256Hash string) string
+
+// SetContentNamer sets the function that names content in transfer headers
+func (n *Node) SetContentNamer(namer ContentNamer) {
+	n.contentNamer = namer
+}
+
+// contentName returns the name sent for sha256Hash: a base name of printable
+// characters, or "".
+func (n *Node) contentName(sha256Hash string) string {
+	if n.contentNamer == nil {
+		return ""
+	}
+	name := path.Base(n.contentNamer(sha256Hash))
+	if name == "." || name == "/" || len(name) > maxContentNameLen || !utf8.ValidString(name) ||
+		strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return ""
+	}
+	return name
+}
+
+// writeContentInfo writes the content header of a ProtocolTransferV2
+// response.
+func writeContentInfo(w io.Writer, info ContentInfo) error {
+	body, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if len(body) > maxContentInfoLen {
+		return fmt.Errorf("content header too large: %d bytes", len(body))
+	}
+	frame := make([]byte, 2+len(body))
+	binary.BigEndian.PutUint16(frame, uint16(len(body))) // #nosec G115 -- bounded by maxContentInfoLen
+	copy(frame[2:], body)
+	_, err = w.Write(frame)
+	return err
+}
+
+// readContentInfo reads the content header of a ProtocolTransferV2
+// response.
+func readContentInfo(r io.Reader) (ContentInfo, error) {
+	var info ContentInfo
+	var lenBuf [2]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return info, fmt.Errorf("failed to read content header: %w", err)
+	}
+	n := binary.BigEndian.Uint16(lenBuf[:])
+	if n > maxContentInfoLen {
+		return info, fmt.Errorf("content header too large: %d bytes", n)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return info, fmt.Errorf("failed to read content header: %w", err)
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return info, fmt.Errorf("invalid content header: %w", err)
+	}
+	return info, nil
+}
+
+// validate checks that info answers a request for start to end (end <= 0
+// for the end of the file) with size bytes.
+func (info ContentInfo) validate(start, end, size int64) error {
+	switch {
+	case info.TotalSize <= 0 || info.TotalSize > MaxTransferSize:
+		return fmt.Errorf("content header: invalid total size %d", info.TotalSize)
+	case info.Start != start || info.End-info.Start != size || info.End > info.TotalSize:
+		return fmt.Errorf("content header: range %d-%d of %d does not match the %d bytes asked from %d",
+			info.Start, info.End, info.TotalSize, size, start)
+	case end <= 0 && info.End != info.TotalSize:
+		return fmt.Errorf("content header: range ends at %d, not at the end of the file (%d)", info.End, info.TotalSize)
+	case end > 0 && info.End != min(end, info.TotalSize):
+		return fmt.Errorf("content header: range ends at %d, not at %d", info.End, end)
+	}
+	return nil
+}
+
+// ContentCheck is given the content header of a transfer before its content
+// is read; an error aborts the transfer as a failure of the peer.
+type ContentCheck func(info ContentInfo) error
+
+type contentCheckKey struct{}
+
+// WithContentCheck returns a context whose downloads from peers that send a
+// content header are checked by check, e.g. to reject a file whose total
+// size differs from the one expected, or to show progress.
+func WithContentCheck(ctx context.Context, check ContentCheck) context.Context {
+	return context.WithValue(ctx, contentCheckKey{}, check)
+}
+
+// contentCheckFrom returns the ContentCheck of ctx, or nil.
+func contentCheckFrom(ctx context.Context) ContentCheck {
+	check, _ := ctx.Value(contentCheckKey{}).(ContentCheck)
+	return check
+}
+
+// ExpectTotalSize returns a ContentCheck refusing files whose total size is
+// not size, as when a peer has a different file under the hash. A size of 0
+// (unknown) accepts any.
+func ExpectTotalSize(size int64) ContentCheck {
+	return func(info ContentInfo) error {
+		if size > 0 && info.TotalSize != size {
+			return fmt.Errorf("peer's file is %d bytes, want %d", info.TotalSize, size)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,180 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestContentInfo_RoundTrip(t *testing.T) {
+	want := ContentInfo{TotalSize: 1000, Start: 100, End: 600, Filename: "curl_7.88.1-10_amd64.deb"}
+
+	var buf bytes.Buffer
+	if err := writeContentInfo(&buf, want); err != nil {
+		t.Fatalf("writeContentInfo failed: %v", err)
+	}
+	buf.WriteString("content")
+
+	got, err := readContentInfo(&buf)
+	if err != nil {
+		t.Fatalf("readContentInfo failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("readContentInfo = %+v, want %+v", got, want)
+	}
+	if buf.String() != "content" {
+		t.Errorf("content after header = %q, want %q", buf.String(), "content")
+	}
+}
+
+func TestReadContentInfo_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{"empty", nil},
+		{"truncated", []byte{0, 10, '{'}},
+		{"too large", []byte{0xff, 0xff}},
+		{"not json", append([]byte{0, 3}, "abc"...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readContentInfo(bytes.NewReader(tt.frame)); err == nil {
+				t.Error("readContentInfo should fail")
+			}
+		})
+	}
+}
+
+func TestContentInfo_Validate(t *testing.T) {
+	tests := []struct {
+		name          string
+		info          ContentInfo
+		start, end, n int64
+		wantErr       bool
+	}{
+		{"whole file", ContentInfo{TotalSize: 100, End: 100}, 0, 0, 100, false},
+		{"range", ContentInfo{TotalSize: 100, Start: 10, End: 20}, 10, 20, 10, false},
+		{"range past end", ContentInfo{TotalSize: 100, Start: 90, End: 100}, 90, 200, 10, false},
+		{"open range", ContentInfo{TotalSize: 100, Start: 40, End: 100}, 40, 0, 60, false},
+		{"no total size", ContentInfo{End: 100}, 0, 0, 100, true},
+		{"wrong start", ContentInfo{TotalSize: 100, Start: 5, End: 15}, 10, 20, 10, true},
+		{"wrong length", ContentInfo{TotalSize: 100, Start: 10, End: 20}, 10, 20, 9, true},
+		{"past total size", ContentInfo{TotalSize: 15, Start: 10, End: 20}, 10, 20, 10, true},
+		{"short of end", ContentInfo{TotalSize: 100, Start: 10, End: 15}, 10, 20, 5, true},
+		{"short of file end", ContentInfo{TotalSize: 100, Start: 40, End: 90}, 40, 0, 50, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.info.validate(tt.start, tt.end, tt.n)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNode_ContentName(t *testing.T) {
+	names := map[string]string{
+		"plain":   "curl_7.88.1-10_amd64.deb",
+		"path":    "pool/main/c/curl/curl_7.88.1-10_amd64.deb",
+		"control": "curl\n.deb",
+		"long":    strings.Repeat("a", maxContentNameLen+1),
+	}
+	n := &Node{}
+	if got := n.contentName("plain"); got != "" {
+		t.Errorf("contentName without namer = %q, want empty", got)
+	}
+	n.SetContentNamer(func(hash string) string { return names[hash] })
+
+	tests := map[string]string{
+		"plain":   "curl_7.88.1-10_amd64.deb",
+		"path":    "curl_7.88.1-10_amd64.deb",
+		"control": "",
+		"long":    "",
+		"missing": "",
+	}
+	for hash, want := range tests {
+		if got := n.contentName(hash); got != want {
+			t.Errorf("contentName(%q) = %q, want %q", hash, got, want)
+		}
+	}
+}
+
+func TestExpectTotalSize(t *testing.T) {
+	info := ContentInfo{TotalSize: 100, End: 100}
+	if err := ExpectTotalSize(100)(info); err != nil {
+		t.Errorf("matching size: %v", err)
+	}
+	if err := ExpectTotalSize(0)(info); err != nil {
+		t.Errorf("unknown size: %v", err)
+	}
+	if err := ExpectTotalSize(99)(info); err == nil {
+		t.Error("different size should fail")
+	}
+}
+
+func TestNode_DownloadRange_ContentInfo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	logger := newTestLogger()
+
+	node1, err := New(ctx, newTestConfig(t), logger)
+	if err != nil {
+		t.Fatalf("New node1 failed: %v", err)
+	}
+	defer node1.Close()
+
+	node2, err := New(ctx, newTestConfig(t), logger)
+	if err != nil {
+		t.Fatalf("New node2 failed: %v", err)
+	}
+	defer node2.Close()
+
+	testContent := []byte("0123456789ABCDEF")
+	testHash := "a1b2c3d4e5f67890123456789012345678901234567890123456789012abcdef"
+
+	node1.SetContentProvider(ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+		if hash == testHash {
+			return io.NopCloser(bytes.NewReader(testContent)), int64(len(testContent)), nil
+		}
+		return nil, 0, io.EOF
+	}))
+	node1.SetContentNamer(func(hash string) string { return "pool/main/h/hello/hello_2.10-3_amd64.deb" })
+
+	node1Info := peer.AddrInfo{ID: node1.PeerID(), Addrs: node1.Addrs()}
+	if err := node2.host.Connect(ctx, node1Info); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	var seen ContentInfo
+	check := func(info ContentInfo) error {
+		seen = info
+		return nil
+	}
+	data, err := node2.DownloadRange(WithContentCheck(ctx, check), node1Info, testHash, 4, 12)
+	if err != nil {
+		t.Fatalf("DownloadRange failed: %v", err)
+	}
+	if string(data) != "456789AB" {
+		t.Errorf("DownloadRange = %q, want %q", data, "456789AB")
+	}
+	want := ContentInfo{TotalSize: 16, Start: 4, End: 12, Filename: "hello_2.10-3_amd64.deb"}
+	if seen != want {
+		t.Errorf("content header = %+v, want %+v", seen, want)
+	}
+
+	errMismatch := errors.New("size mismatch")
+	_, err = node2.DownloadRange(WithContentCheck(ctx, func(ContentInfo) error { return errMismatch }),
+		node1Info, testHash, 0, 0)
+	if !errors.Is(err, errMismatch) {
+		t.Errorf("DownloadRange with failing check = %v, want %v", err, errMismatch)
+	}
+}
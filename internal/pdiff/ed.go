@@ -0,0 +1,122 @@
+package pdiff
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// edCommand is one command of an ed script: lines first..last of the input
+// are replaced by text. An append after line n is first = n+1, last = n.
+type edCommand struct {
+	first, last int
+	text        [][]byte
+}
+
+// Apply applies a pdiff patch, the output of diff --ed, to base. The script
+// lists its commands from the end of the file backwards; they are checked to
+// be in that order and within base, then applied in a single pass.
+func Apply(base, patch []byte) ([]byte, error) {
+	cmds, err := parseEd(patch)
+	if err != nil {
+		return nil, err
+	}
+	lines := splitLines(base)
+
+	out := make([]byte, 0, len(base)+len(patch))
+	next := 1 // the first input line not yet copied or replaced
+	for i := len(cmds) - 1; i >= 0; i-- {
+		c := cmds[i]
+		if c.last > len(lines) {
+			return nil, fmt.Errorf("pdiff: line %d is past the end of a %d line file", c.last, len(lines))
+		}
+		for ; next < c.first; next++ {
+			out = append(out, lines[next-1]...)
+		}
+		for _, t := range c.text {
+			out = append(out, t...)
+		}
+		next = c.last + 1
+	}
+	for ; next <= len(lines); next++ {
+		out = append(out, lines[next-1]...)
+	}
+	return out, nil
+}
+
+// parseEd parses the a, c and d commands diff --ed emits, in script order
+func parseEd(patch []byte) ([]edCommand, error) {
+	lines := splitLines(patch)
+	var cmds []edCommand
+	for i := 0; i < len(lines); i++ {
+		line := bytes.TrimRight(lines[i], "\n")
+		if len(line) == 0 {
+			return nil, fmt.Errorf("pdiff: empty command at line %d", i+1)
+		}
+		op := line[len(line)-1]
+		from, to, err := parseRange(line[:len(line)-1])
+		if err != nil {
+			return nil, fmt.Errorf("pdiff: invalid command %q at line %d", line, i+1)
+		}
+
+		var c edCommand
+		switch op {
+		case 'a':
+			if to != from {
+				return nil, fmt.Errorf("pdiff: invalid command %q at line %d", line, i+1)
+			}
+			c = edCommand{first: from + 1, last: from}
+		case 'c', 'd':
+			if from < 1 || to < from {
+				return nil, fmt.Errorf("pdiff: invalid command %q at line %d", line, i+1)
+			}
+			c = edCommand{first: from, last: to}
+		default:
+			return nil, fmt.Errorf("pdiff: unsupported command %q at line %d", line, i+1)
+		}
+
+		if op != 'd' {
+			terminated := false
+			for i++; i < len(lines); i++ {
+				if bytes.Equal(lines[i], []byte(".\n")) {
+					terminated = true
+					break
+				}
+				c.text = append(c.text, lines[i])
+			}
+			if !terminated {
+				return nil, fmt.Errorf("pdiff: unterminated text for %q", line)
+			}
+		}
+
+		if n := len(cmds); n > 0 && c.last >= cmds[n-1].first {
+			return nil, fmt.Errorf("pdiff: command %q is out of order", line)
+		}
+		cmds = append(cmds, c)
+	}
+	return cmds, nil
+}
+
+// parseRange parses "n" or "n,m"
+func parseRange(b []byte) (from, to int, err error) {
+	before, after, found := bytes.Cut(b, []byte(","))
+	if from, err = strconv.Atoi(string(before)); err != nil || from < 0 {
+		return 0, 0, fmt.Errorf("invalid line number %q", before)
+	}
+	if !found {
+		return from, from, nil
+	}
+	if to, err = strconv.Atoi(string(after)); err != nil {
+		return 0, 0, fmt.Errorf("invalid line number %q", after)
+	}
+	return from, to, nil
+}
+
+// splitLines splits data after each newline; a final line without one is kept
+func splitLines(data []byte) [][]byte {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if n := len(lines); n > 0 && len(lines[n-1]) == 0 {
+		lines = lines[:n-1]
+	}
+	return lines
+}
@@ -0,0 +1,194 @@
+// Package pdiff parses and applies APT index diffs ("pdiffs"). A repository
+// that publishes them keeps, next to an index such as
+// main/binary-amd64/Packages, a Packages.diff/ directory whose Index lists the
+// index's current SHA256, the SHA256 of each earlier version, and the ed
+// scripts that turn an earlier version into a later one. APT uses them to
+// update a Packages file it already has by downloading a few KB of patches
+// instead of the whole index; debswarm uses them to rebuild the same file.
+//
+// Parsing and patching are pure: fetching the Index and patches, and checking
+// the result against the signed Release, is left to the caller.
+package pdiff
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxIndexSize bounds the scanner; a real Index lists at most a few hundred
+// patches and stays well under 100 KB.
+const maxIndexSize = 4 * 1024 * 1024
+
+var (
+	// ErrNoSHA256 is returned for an Index without SHA256 fields (only
+	// ancient repositories publish SHA1-only diffs)
+	ErrNoSHA256 = errors.New("pdiff: no SHA256 fields in index")
+	// ErrNoPath is returned by Plan when the base is not a version the Index
+	// has patches for, e.g. it is older than the oldest patch kept
+	ErrNoPath = errors.New("pdiff: no patch path from base to current")
+)
+
+// FileHash is a SHA256 and size as listed in an Index
+type FileHash struct {
+	SHA256 string
+	Size   int64
+}
+
+// Patch is one patch a Plan applies
+type Patch struct {
+	// Name is the patch name, e.g. T-2026-10-01-0213.15-F-2026-09-30-2012.55.
+	// The patch is downloaded from <index>.diff/<DownloadName>.
+	Name string
+	// Result is the hash and size of the uncompressed ed script
+	Result FileHash
+	// DownloadName and Download identify the compressed file on the mirror
+	DownloadName string
+	Download     FileHash
+}
+
+type historyEntry struct {
+	FileHash
+	name string
+}
+
+// Index is a parsed <index>.diff/Index file
+type Index struct {
+	// Current is the hash and size of the index the patches lead to
+	Current FileHash
+	// Merged is set for X-Patch-Precedence: merged, where each patch goes
+	// straight from one earlier version to the current one
+	Merged bool
+
+	history  []historyEntry // oldest first
+	patches  map[string]FileHash
+	download map[string]historyEntry // keyed by patch name
+}
+
+// ParseIndex parses the body of a .diff/Index file
+func ParseIndex(body []byte) (*Index, error) {
+	idx := &Index{
+		patches:  make(map[string]FileHash),
+		download: make(map[string]historyEntry),
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	sc.Buffer(make([]byte, 0, 64*1024), maxIndexSize)
+
+	section := ""
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			f := strings.Fields(line)
+			if len(f) != 3 {
+				continue
+			}
+			size, err := strconv.ParseInt(f[1], 10, 64)
+			if err != nil || size < 0 {
+				return nil, fmt.Errorf("pdiff: invalid size in %q", strings.TrimSpace(line))
+			}
+			entry := historyEntry{FileHash{SHA256: strings.ToLower(f[0]), Size: size}, f[2]}
+			switch section {
+			case "SHA256-History":
+				idx.history = append(idx.history, entry)
+			case "SHA256-Patches":
+				idx.patches[entry.name] = entry.FileHash
+			case "SHA256-Download":
+				idx.download[strings.TrimSuffix(entry.name, ".gz")] = entry
+			}
+			continue
+		}
+
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			section = ""
+			continue
+		}
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		section = key
+		switch key {
+		case "SHA256-Current":
+			f := strings.Fields(val)
+			if len(f) != 2 {
+				return nil, fmt.Errorf("pdiff: invalid SHA256-Current %q", val)
+			}
+			size, err := strconv.ParseInt(f[1], 10, 64)
+			if err != nil || size < 0 {
+				return nil, fmt.Errorf("pdiff: invalid SHA256-Current %q", val)
+			}
+			idx.Current = FileHash{SHA256: strings.ToLower(f[0]), Size: size}
+		case "X-Patch-Precedence":
+			idx.Merged = strings.EqualFold(val, "merged")
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if idx.Current.SHA256 == "" {
+		return nil, ErrNoSHA256
+	}
+	return idx, nil
+}
+
+// Known reports whether sha256 is the current version or one the Index has
+// patches from
+func (idx *Index) Known(sha256 string) bool {
+	if sha256 == idx.Current.SHA256 {
+		return true
+	}
+	for _, h := range idx.history {
+		if h.SHA256 == sha256 {
+			return true
+		}
+	}
+	return false
+}
+
+// Plan returns the patches that turn the version with hash base into the
+// current one, in the order they must be applied. It is empty when base is
+// already current, and fails with ErrNoPath when base is not in the history.
+func (idx *Index) Plan(base string) ([]Patch, error) {
+	base = strings.ToLower(base)
+	if base == idx.Current.SHA256 {
+		return nil, nil
+	}
+	start := -1
+	// The newest matching entry wins should a version ever repeat
+	for i := len(idx.history) - 1; i >= 0; i-- {
+		if idx.history[i].SHA256 == base {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, ErrNoPath
+	}
+
+	names := []string{idx.history[start].name}
+	if !idx.Merged {
+		names = names[:0]
+		for _, h := range idx.history[start:] {
+			names = append(names, h.name)
+		}
+	}
+	plan := make([]Patch, 0, len(names))
+	for _, name := range names {
+		result, ok := idx.patches[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: patch %s is not listed", ErrNoPath, name)
+		}
+		p := Patch{Name: name, Result: result, DownloadName: name + ".gz"}
+		if d, ok := idx.download[name]; ok {
+			p.DownloadName = d.name
+			p.Download = d.FileHash
+		}
+		plan = append(plan, p)
+	}
+	return plan, nil
+}
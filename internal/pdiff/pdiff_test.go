@@ -0,0 +1,157 @@
+package pdiff
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const (
+	v1 = "Package: a\nVersion: 1\n\nPackage: b\nVersion: 1\n\nPackage: c\nVersion: 1\n"
+	v2 = "Package: a\nVersion: 2\n\nPackage: c\nVersion: 1\n\nPackage: d\nVersion: 1\n"
+	v3 = "Package: a0\nVersion: 1\n\nPackage: a\nVersion: 2\n\nPackage: d\nVersion: 1\n"
+
+	// diff --ed v1 v2, diff --ed v2 v3 and diff --ed v1 v3
+	patch12 = "7c\nPackage: d\n.\n4c\nPackage: c\n.\n2c\nVersion: 2\n.\n"
+	patch23 = "3,5d\n0a\nPackage: a0\nVersion: 1\n\n.\n"
+	patch13 = "7c\nPackage: d\n.\n4,5c\nPackage: a\nVersion: 2\n.\n1c\nPackage: a0\n.\n"
+)
+
+func sum(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func TestApply(t *testing.T) {
+	for _, tt := range []struct {
+		name, base, patch, want string
+	}{
+		{"change", v1, patch12, v2},
+		{"delete and prepend", v2, patch23, v3},
+		{"merged", v1, patch13, v3},
+		{"empty patch", v1, "", v1},
+		{"append at end", "a\n", "1a\nb\nc\n.\n", "a\nb\nc\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.base), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply_Rejects(t *testing.T) {
+	for _, patch := range []string{
+		"9d\n",                 // past the end
+		"2c\nx\n",              // unterminated text
+		"1d\n3d\n",             // ascending order
+		"2,1d\n",               // inverted range
+		"0d\n",                 // no line 0
+		"s/.//\n",              // unsupported command
+		"1,2a\nx\n.\n",         // append takes one address
+		"1x\n",                 // unknown command
+		"\n",                   // empty command
+		"1c\nPackage: x\n.\nw", // trailing junk
+	} {
+		if _, err := Apply([]byte(v1), []byte(patch)); err == nil {
+			t.Errorf("Apply(%q) accepted", patch)
+		}
+	}
+}
+
+func testIndex(merged bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "SHA256-Current: %s %d\n", sum(v3), len(v3))
+	b.WriteString("SHA256-History:\n")
+	fmt.Fprintf(&b, " %s %d T-2-F-1\n", sum(v1), len(v1))
+	fmt.Fprintf(&b, " %s %d T-3-F-2\n", sum(v2), len(v2))
+	b.WriteString("SHA256-Patches:\n")
+	first := patch12
+	if merged {
+		first = patch13
+	}
+	fmt.Fprintf(&b, " %s %d T-2-F-1\n", sum(first), len(first))
+	fmt.Fprintf(&b, " %s %d T-3-F-2\n", sum(patch23), len(patch23))
+	b.WriteString("SHA256-Download:\n")
+	fmt.Fprintf(&b, " %s 40 T-2-F-1.gz\n", strings.Repeat("a", 64))
+	fmt.Fprintf(&b, " %s 30 T-3-F-2.gz\n", strings.Repeat("b", 64))
+	if merged {
+		b.WriteString("X-Patch-Precedence: merged\n")
+	}
+	return b.String()
+}
+
+func TestPlan(t *testing.T) {
+	idx, err := ParseIndex([]byte(testIndex(false)))
+	if err != nil {
+		t.Fatalf("ParseIndex: %v", err)
+	}
+	if idx.Current.SHA256 != sum(v3) || idx.Current.Size != int64(len(v3)) || idx.Merged {
+		t.Errorf("index = %+v", idx)
+	}
+
+	plan, err := idx.Plan(sum(v1))
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(plan) != 2 || plan[0].Name != "T-2-F-1" || plan[1].Name != "T-3-F-2" {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if plan[0].DownloadName != "T-2-F-1.gz" || plan[0].Download.Size != 40 || plan[1].Result.SHA256 != sum(patch23) {
+		t.Errorf("patch details = %+v", plan)
+	}
+	data := []byte(v1)
+	for _, p := range plan {
+		patch := map[string]string{"T-2-F-1": patch12, "T-3-F-2": patch23}[p.Name]
+		if data, err = Apply(data, []byte(patch)); err != nil {
+			t.Fatalf("Apply %s: %v", p.Name, err)
+		}
+	}
+	if string(data) != v3 {
+		t.Errorf("rebuilt %q", data)
+	}
+
+	if plan, err := idx.Plan(sum(v3)); err != nil || len(plan) != 0 {
+		t.Errorf("Plan(current) = %v, %v", plan, err)
+	}
+	if _, err := idx.Plan(sum("unknown")); !errors.Is(err, ErrNoPath) {
+		t.Errorf("Plan(unknown) err = %v, want ErrNoPath", err)
+	}
+	if !idx.Known(sum(v2)) || idx.Known(sum("unknown")) {
+		t.Error("Known mismatch")
+	}
+}
+
+func TestPlan_Merged(t *testing.T) {
+	idx, err := ParseIndex([]byte(testIndex(true)))
+	if err != nil {
+		t.Fatalf("ParseIndex: %v", err)
+	}
+	plan, err := idx.Plan(sum(v1))
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if !idx.Merged || len(plan) != 1 || plan[0].Result.SHA256 != sum(patch13) {
+		t.Errorf("merged plan = %+v", plan)
+	}
+}
+
+func TestParseIndex_Invalid(t *testing.T) {
+	for _, body := range []string{
+		"",
+		"SHA1-Current: abc 12\n",
+		"SHA256-Current: abc\n",
+		"SHA256-Current: abc 12\nSHA256-History:\n abc -1 T-1\n",
+	} {
+		if _, err := ParseIndex([]byte(body)); err == nil {
+			t.Errorf("ParseIndex(%q) accepted", body)
+		}
+	}
+}
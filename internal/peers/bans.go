@@ -0,0 +1,218 @@
+package peers
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// permanentBanUntil is the blacklist end of a permanently banned peer, so
+// the blacklist checks need no special case for it
+var permanentBanUntil = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// maxBanDuration is the longest temporary ban; escalation past it is
+// permanent
+const maxBanDuration = 100 * 365 * 24 * time.Hour
+
+// BanPolicy escalates the blacklisting of a peer that keeps serving corrupt
+// data. Each offense is a strike: the first bans the peer for Duration, each
+// further one Multiplier times longer than the last, and the
+// PermanentAfter-th bans it for good. Strikes are forgotten StrikeExpiry
+// after the last one, once its ban is over.
+type BanPolicy struct {
+	Duration       time.Duration
+	Multiplier     float64
+	PermanentAfter int // 0 = never
+	StrikeExpiry   time.Duration
+}
+
+// DefaultBanPolicy returns the default escalation: a day, then four times
+// longer per strike, permanent at the fifth, strikes kept for 30 days.
+func DefaultBanPolicy() BanPolicy {
+	return BanPolicy{
+		Duration:       24 * time.Hour,
+		Multiplier:     4,
+		PermanentAfter: 5,
+		StrikeExpiry:   30 * 24 * time.Hour,
+	}
+}
+
+// banDuration returns how long the strikes-th strike bans a peer, 0 for a
+// permanent ban.
+func (p BanPolicy) banDuration(strikes int) time.Duration {
+	if p.PermanentAfter > 0 && strikes >= p.PermanentAfter {
+		return 0
+	}
+	d := float64(p.Duration) * math.Pow(max(p.Multiplier, 1), float64(strikes-1))
+	if d >= float64(maxBanDuration) {
+		return 0
+	}
+	return time.Duration(d)
+}
+
+// Ban is a peer's record of strikes and the ban the last one earned.
+type Ban struct {
+	PeerID     peer.ID
+	Strikes    int
+	Reason     string    // reason of the last strike
+	LastStrike time.Time // when the last strike was given
+	Until      time.Time // end of the ban; zero if permanent
+	Permanent  bool
+}
+
+// Active reports whether the ban is in force at now.
+func (b Ban) Active(now time.Time) bool {
+	return b.Permanent || now.Before(b.Until)
+}
+
+// expired reports whether the strikes are forgotten at now.
+func (b Ban) expired(now time.Time, expiry time.Duration) bool {
+	return !b.Active(now) && now.Sub(b.LastStrike) >= expiry
+}
+
+// BanRecorder is called with a peer's ban record whenever it changes, so it
+// can be persisted. A record with no strikes was removed.
+type BanRecorder func(Ban)
+
+// SetBanPolicy replaces the ban escalation policy.
+func (s *Scorer) SetBanPolicy(p BanPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.banPolicy = p
+}
+
+// SetBanRecorder sets the function that persists ban records
+func (s *Scorer) SetBanRecorder(r BanRecorder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordBan = r
+}
+
+// Strike records an offense of a peer, such as serving data that failed
+// verification, and bans it for as long as its strikes call for.
+func (s *Scorer) Strike(peerID peer.ID, reason string) Ban {
+	s.mu.Lock()
+	now := time.Now()
+	b, ok := s.bans[peerID]
+	if !ok || b.expired(now, s.banPolicy.StrikeExpiry) {
+		b = &Ban{PeerID: peerID}
+		s.bans[peerID] = b
+	}
+	b.Strikes++
+	b.Reason = reason
+	b.LastStrike = now
+	switch d := s.banPolicy.banDuration(b.Strikes); {
+	case b.Permanent:
+	case d == 0:
+		b.Permanent = true
+		b.Until = time.Time{}
+	case now.Add(d).After(b.Until):
+		// A strike never shortens a ban in force
+		b.Until = now.Add(d)
+	}
+	s.applyBanLocked(s.getOrCreate(peerID), b)
+	ban, record := *b, s.recordBan
+	s.mu.Unlock()
+
+	if record != nil {
+		record(ban)
+	}
+	return ban
+}
+
+// RestoreBan reinstates a ban record persisted by a previous run. It
+// reports false for a record whose strikes are already forgotten, which is
+// ignored.
+func (s *Scorer) RestoreBan(b Ban) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b.Strikes <= 0 || b.expired(time.Now(), s.banPolicy.StrikeExpiry) {
+		return false
+	}
+	s.bans[b.PeerID] = &b
+	if ps, ok := s.peers[b.PeerID]; ok {
+		s.applyBanLocked(ps, &b)
+	}
+	return true
+}
+
+// Pardon lifts a peer's ban and forgets its strikes, along with any other
+// blacklisting. It reports whether the peer had a ban record.
+func (s *Scorer) Pardon(peerID peer.ID) bool {
+	s.mu.Lock()
+	_, banned := s.bans[peerID]
+	delete(s.bans, peerID)
+	if ps, ok := s.peers[peerID]; ok {
+		ps.Blacklisted = false
+		ps.BlacklistReason = ""
+		ps.BlacklistUntil = time.Time{}
+		ps.scoreCachedAt = time.Time{}
+	}
+	record := s.recordBan
+	s.mu.Unlock()
+
+	if banned && record != nil {
+		record(Ban{PeerID: peerID})
+	}
+	return banned
+}
+
+// Bans returns the ban records of all peers with strikes, bans in force
+// first, each group by peer ID.
+func (s *Scorer) Bans() []Ban {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	bans := make([]Ban, 0, len(s.bans))
+	for _, b := range s.bans {
+		if !b.expired(now, s.banPolicy.StrikeExpiry) {
+			bans = append(bans, *b)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		if ai, aj := bans[i].Active(now), bans[j].Active(now); ai != aj {
+			return ai
+		}
+		return bans[i].PeerID < bans[j].PeerID
+	})
+	return bans
+}
+
+// isBannedLocked reports whether the peer has a ban in force. Caller must
+// hold at least RLock.
+func (s *Scorer) isBannedLocked(peerID peer.ID) bool {
+	b, ok := s.bans[peerID]
+	return ok && b.Active(time.Now())
+}
+
+// applyBanLocked blacklists ps for as long as b lasts. Caller must hold
+// s.mu.
+func (s *Scorer) applyBanLocked(ps *PeerScore, b *Ban) {
+	if !b.Active(time.Now()) {
+		return
+	}
+	until := b.Until
+	if b.Permanent {
+		until = permanentBanUntil
+	}
+	ps.Blacklisted = true
+	ps.BlacklistReason = b.Reason
+	ps.BlacklistUntil = until
+	ps.cachedScore = 0
+	ps.scoreCachedAt = time.Now()
+}
+
+// pruneBansLocked drops the records whose strikes are forgotten and returns
+// them. Caller must hold s.mu.
+func (s *Scorer) pruneBansLocked(now time.Time) []peer.ID {
+	var pruned []peer.ID
+	for id, b := range s.bans {
+		if b.expired(now, s.banPolicy.StrikeExpiry) {
+			delete(s.bans, id)
+			pruned = append(pruned, id)
+		}
+	}
+	return pruned
+}
@@ -0,0 +1,169 @@
+package peers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBanPolicy_Duration(t *testing.T) {
+	p := DefaultBanPolicy()
+	tests := map[int]time.Duration{
+		1: 24 * time.Hour,
+		2: 4 * 24 * time.Hour,
+		3: 16 * 24 * time.Hour,
+		4: 64 * 24 * time.Hour,
+		5: 0,
+		9: 0,
+	}
+	for strikes, want := range tests {
+		if got := p.banDuration(strikes); got != want {
+			t.Errorf("banDuration(%d) = %v, want %v", strikes, got, want)
+		}
+	}
+
+	// Without permanent bans escalation stops at a bound rather than
+	// overflowing
+	p.PermanentAfter = 0
+	if got := p.banDuration(40); got != 0 {
+		t.Errorf("banDuration(40) = %v, want permanent", got)
+	}
+}
+
+func TestStrike_Escalates(t *testing.T) {
+	s := NewScorer()
+	s.SetBanPolicy(BanPolicy{Duration: time.Hour, Multiplier: 2, PermanentAfter: 3, StrikeExpiry: 24 * time.Hour})
+	var recorded []Ban
+	s.SetBanRecorder(func(b Ban) { recorded = append(recorded, b) })
+	peerID := testPeerID("peer1")
+
+	b := s.Strike(peerID, "hash mismatch")
+	if b.Strikes != 1 || b.Permanent || time.Until(b.Until) > time.Hour || time.Until(b.Until) < 59*time.Minute {
+		t.Errorf("first strike = %+v, want a 1h ban", b)
+	}
+	if !s.IsBlacklisted(peerID) {
+		t.Error("peer should be blacklisted after a strike")
+	}
+
+	b = s.Strike(peerID, "hash mismatch")
+	if b.Strikes != 2 || time.Until(b.Until) < 119*time.Minute {
+		t.Errorf("second strike = %+v, want a 2h ban", b)
+	}
+
+	b = s.Strike(peerID, "index hash mismatch")
+	if b.Strikes != 3 || !b.Permanent || !b.Until.IsZero() || b.Reason != "index hash mismatch" {
+		t.Errorf("third strike = %+v, want a permanent ban", b)
+	}
+	if stats := s.GetStats(peerID); stats.BlacklistUntil.Before(time.Now().Add(50 * 365 * 24 * time.Hour)) {
+		t.Errorf("permanent ban blacklists until %v", stats.BlacklistUntil)
+	}
+
+	// A permanent ban stays permanent
+	if b = s.Strike(peerID, "hash mismatch"); !b.Permanent || b.Strikes != 4 {
+		t.Errorf("fourth strike = %+v", b)
+	}
+	if len(recorded) != 4 || recorded[3].Strikes != 4 {
+		t.Errorf("recorded = %+v, want every strike", recorded)
+	}
+}
+
+func TestStrike_ForgottenAfterExpiry(t *testing.T) {
+	s := NewScorer()
+	s.SetBanPolicy(BanPolicy{Duration: time.Hour, Multiplier: 2, PermanentAfter: 3, StrikeExpiry: 24 * time.Hour})
+	peerID := testPeerID("peer1")
+
+	s.Strike(peerID, "hash mismatch")
+	s.Strike(peerID, "hash mismatch")
+
+	// The last strike was two days ago and its ban is long over
+	past := time.Now().Add(-48 * time.Hour)
+	s.bans[peerID].LastStrike = past
+	s.bans[peerID].Until = past.Add(2 * time.Hour)
+	if len(s.Bans()) != 0 {
+		t.Errorf("Bans() = %+v, want the forgotten record hidden", s.Bans())
+	}
+	if b := s.Strike(peerID, "hash mismatch"); b.Strikes != 1 || b.Permanent {
+		t.Errorf("strike after expiry = %+v, want the first", b)
+	}
+}
+
+func TestRestoreBan(t *testing.T) {
+	s := NewScorer()
+	banned, over, forgotten := testPeerID("banned"), testPeerID("over"), testPeerID("forgotten")
+	now := time.Now()
+
+	if !s.RestoreBan(Ban{PeerID: banned, Strikes: 5, Reason: "hash mismatch", LastStrike: now.Add(-time.Hour), Permanent: true}) {
+		t.Error("permanent ban not restored")
+	}
+	if !s.RestoreBan(Ban{PeerID: over, Strikes: 1, LastStrike: now.Add(-48 * time.Hour), Until: now.Add(-24 * time.Hour)}) {
+		t.Error("ban with strikes still counted not restored")
+	}
+	if s.RestoreBan(Ban{PeerID: forgotten, Strikes: 1, LastStrike: now.Add(-60 * 24 * time.Hour), Until: now.Add(-59 * 24 * time.Hour)}) {
+		t.Error("ban with forgotten strikes restored")
+	}
+
+	if !s.IsBlacklisted(banned) || s.IsBlacklisted(over) || s.IsBlacklisted(forgotten) {
+		t.Errorf("blacklisted = %v %v %v, want only the permanent ban",
+			s.IsBlacklisted(banned), s.IsBlacklisted(over), s.IsBlacklisted(forgotten))
+	}
+	// Bans in force are listed first; the ban that is over keeps its strikes
+	bans := s.Bans()
+	if len(bans) != 2 || bans[0].PeerID != banned || bans[0].Reason != "hash mismatch" || bans[1].PeerID != over {
+		t.Errorf("Bans() = %+v", bans)
+	}
+	if b := s.Strike(over, "hash mismatch"); b.Strikes != 2 {
+		t.Errorf("strike after restore = %+v, want the second", b)
+	}
+}
+
+func TestPardon(t *testing.T) {
+	s := NewScorer()
+	var recorded []Ban
+	s.SetBanRecorder(func(b Ban) { recorded = append(recorded, b) })
+	peerID := testPeerID("peer1")
+
+	if s.Pardon(peerID) {
+		t.Error("Pardon of a peer without a ban reported true")
+	}
+	for range 5 {
+		s.Strike(peerID, "hash mismatch")
+	}
+	if !s.Pardon(peerID) {
+		t.Fatal("Pardon reported no ban")
+	}
+	if s.IsBlacklisted(peerID) || len(s.Bans()) != 0 {
+		t.Error("pardoned peer still banned")
+	}
+	if last := recorded[len(recorded)-1]; last.PeerID != peerID || last.Strikes != 0 {
+		t.Errorf("last record = %+v, want a deletion", last)
+	}
+	if b := s.Strike(peerID, "hash mismatch"); b.Strikes != 1 || b.Permanent {
+		t.Errorf("strike after pardon = %+v, want the first", b)
+	}
+}
+
+func TestCleanup_PrunesForgottenBans(t *testing.T) {
+	s := NewScorer()
+	s.SetBanPolicy(BanPolicy{Duration: time.Hour, Multiplier: 2, StrikeExpiry: time.Millisecond})
+	var recorded []Ban
+	s.SetBanRecorder(func(b Ban) { recorded = append(recorded, b) })
+	peerID := testPeerID("peer1")
+
+	now := time.Now()
+	s.RestoreBan(Ban{PeerID: peerID, Strikes: 1, LastStrike: now, Until: now.Add(time.Hour)})
+	s.Cleanup()
+	if len(s.Bans()) != 1 {
+		t.Fatal("ban in force was pruned")
+	}
+
+	s.Pardon(peerID)
+	s.RestoreBan(Ban{PeerID: peerID, Strikes: 1, LastStrike: now.Add(-time.Hour), Until: now.Add(time.Millisecond)})
+	time.Sleep(5 * time.Millisecond)
+	recorded = nil
+	s.Cleanup()
+	if len(s.Bans()) != 0 {
+		t.Error("forgotten ban was not pruned")
+	}
+	if len(recorded) != 1 || recorded[0].PeerID != peerID || recorded[0].Strikes != 0 {
+		t.Errorf("recorded = %+v, want the deletion", recorded)
+	}
+}
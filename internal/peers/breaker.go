@@ -0,0 +1,173 @@
+package peers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ErrCircuitOpen is returned for a transfer attempt to a peer whose circuit
+// breaker is open. The attempt is skipped without touching the network.
+var ErrCircuitOpen = errors.New("peer circuit breaker open")
+
+// BreakerState is the state of a peer's circuit breaker.
+//
+// Scores alone decay slowly, so during an outage a dead peer keeps being
+// dialed, each attempt costing a full connect timeout. The breaker cuts it
+// off: after FailureThreshold consecutive failures it opens and every attempt
+// is skipped; once the backoff elapses it goes half-open and lets a single
+// probe through. A successful probe closes it, a failed one reopens it with
+// the backoff doubled (up to MaxBackoff).
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (b BreakerState) String() string {
+	switch b {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerConfig tunes the per-peer circuit breaker.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// breaker. 0 disables the breaker.
+	FailureThreshold int
+	// Backoff is how long the breaker stays open after its first trip.
+	Backoff time.Duration
+	// MaxBackoff caps the doubling backoff after repeated failed probes.
+	MaxBackoff time.Duration
+}
+
+// DefaultBreakerConfig returns the default breaker settings.
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold: 5,
+		Backoff:          30 * time.Second,
+		MaxBackoff:       10 * time.Minute,
+	}
+}
+
+// breakerProbeTimeout bounds how long a half-open probe may stay unreported
+// (e.g. canceled because another source won a race) before another is allowed.
+const breakerProbeTimeout = time.Minute
+
+// SetBreakerConfig replaces the circuit breaker settings.
+func (s *Scorer) SetBreakerConfig(cfg BreakerConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.breaker = cfg
+}
+
+// AllowAttempt reports whether a transfer to the peer may be attempted, and
+// must be called right before one. When an open breaker's backoff has
+// elapsed it moves to half-open and admits the caller as the single probe.
+func (s *Scorer) AllowAttempt(peerID peer.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ps, ok := s.peers[peerID]
+	if !ok || s.breaker.FailureThreshold <= 0 {
+		return true
+	}
+	now := time.Now()
+	switch ps.Breaker {
+	case BreakerOpen:
+		if now.Before(ps.BreakerUntil) {
+			return false
+		}
+		ps.Breaker = BreakerHalfOpen
+		ps.probeStarted = now
+		return true
+	case BreakerHalfOpen:
+		if now.Sub(ps.probeStarted) < breakerProbeTimeout {
+			return false
+		}
+		ps.probeStarted = now
+		return true
+	default:
+		return true
+	}
+}
+
+// isTrippedLocked reports whether an attempt to the peer would be refused
+// right now, without claiming a probe. Caller must hold at least RLock.
+func (s *Scorer) isTrippedLocked(peerID peer.ID) bool {
+	ps, ok := s.peers[peerID]
+	if !ok || s.breaker.FailureThreshold <= 0 {
+		return false
+	}
+	switch ps.Breaker {
+	case BreakerOpen:
+		return time.Now().Before(ps.BreakerUntil)
+	case BreakerHalfOpen:
+		return time.Since(ps.probeStarted) < breakerProbeTimeout
+	default:
+		return false
+	}
+}
+
+// BreakerStatus returns the peer's breaker state and, when open, the time
+// the next probe is allowed.
+func (s *Scorer) BreakerStatus(peerID peer.ID) (BreakerState, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ps, ok := s.peers[peerID]
+	if !ok {
+		return BreakerClosed, time.Time{}
+	}
+	return ps.Breaker, ps.BreakerUntil
+}
+
+// OpenBreakers returns how many peers currently have a tripped breaker.
+func (s *Scorer) OpenBreakers() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for id := range s.peers {
+		if s.isTrippedLocked(id) {
+			n++
+		}
+	}
+	return n
+}
+
+// breakerSuccessLocked closes the breaker. Caller must hold the write lock.
+func (s *Scorer) breakerSuccessLocked(ps *PeerScore) {
+	ps.ConsecutiveFailures = 0
+	ps.Breaker = BreakerClosed
+	ps.BreakerUntil = time.Time{}
+	ps.breakerTrips = 0
+}
+
+// breakerFailureLocked counts a failure and opens the breaker when the
+// threshold is reached or a half-open probe failed. Caller must hold the
+// write lock.
+func (s *Scorer) breakerFailureLocked(ps *PeerScore, now time.Time) {
+	ps.ConsecutiveFailures++
+	if s.breaker.FailureThreshold <= 0 {
+		return
+	}
+	if ps.Breaker != BreakerHalfOpen && ps.ConsecutiveFailures < s.breaker.FailureThreshold {
+		return
+	}
+	backoff := s.breaker.Backoff << ps.breakerTrips
+	if backoff <= 0 || backoff > s.breaker.MaxBackoff {
+		backoff = s.breaker.MaxBackoff
+	}
+	if backoff < s.breaker.MaxBackoff {
+		ps.breakerTrips++
+	}
+	ps.Breaker = BreakerOpen
+	ps.BreakerUntil = now.Add(backoff)
+}
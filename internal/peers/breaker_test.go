@@ -0,0 +1,119 @@
+package peers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	s := NewScorer()
+	s.SetBreakerConfig(BreakerConfig{FailureThreshold: 3, Backoff: time.Hour, MaxBackoff: time.Hour})
+	id := peer.ID("flaky")
+
+	for i := 0; i < 2; i++ {
+		s.RecordFailure(id, "connect failed")
+	}
+	if !s.AllowAttempt(id) {
+		t.Fatal("breaker opened before reaching the threshold")
+	}
+	// A success resets the consecutive count.
+	s.RecordSuccess(id, 100, 10, 1000)
+	for i := 0; i < 2; i++ {
+		s.RecordFailure(id, "connect failed")
+	}
+	if !s.AllowAttempt(id) {
+		t.Fatal("success did not reset consecutive failures")
+	}
+
+	s.RecordFailure(id, "connect failed")
+	if state, until := s.BreakerStatus(id); state != BreakerOpen || until.IsZero() {
+		t.Fatalf("state = %v, want open", state)
+	}
+	if s.AllowAttempt(id) {
+		t.Error("open breaker admitted an attempt")
+	}
+	if got := s.OpenBreakers(); got != 1 {
+		t.Errorf("OpenBreakers() = %d, want 1", got)
+	}
+	if got := s.SelectBest([]peer.AddrInfo{{ID: id}, {ID: "other"}}, 2); len(got) != 1 || got[0].ID != "other" {
+		t.Errorf("SelectBest should skip the tripped peer, got %v", got)
+	}
+}
+
+func TestBreaker_HalfOpenProbe(t *testing.T) {
+	s := NewScorer()
+	s.SetBreakerConfig(BreakerConfig{FailureThreshold: 1, Backoff: time.Minute, MaxBackoff: 4 * time.Minute})
+	id := peer.ID("dead")
+
+	s.RecordFailure(id, "connect failed")
+	// Pretend the backoff has elapsed.
+	s.mu.Lock()
+	s.peers[id].BreakerUntil = time.Now().Add(-time.Second)
+	s.mu.Unlock()
+
+	if !s.AllowAttempt(id) {
+		t.Fatal("elapsed backoff should admit a probe")
+	}
+	if state, _ := s.BreakerStatus(id); state != BreakerHalfOpen {
+		t.Fatalf("state = %v, want half-open", state)
+	}
+	if s.AllowAttempt(id) {
+		t.Error("half-open breaker admitted a second concurrent probe")
+	}
+
+	// Failed probe reopens with the backoff doubled.
+	before := time.Now()
+	s.RecordFailure(id, "connect failed")
+	state, until := s.BreakerStatus(id)
+	if state != BreakerOpen {
+		t.Fatalf("state = %v, want open after failed probe", state)
+	}
+	if d := until.Sub(before); d < 2*time.Minute-time.Second || d > 2*time.Minute+time.Second {
+		t.Errorf("backoff after failed probe = %v, want ~2m", d)
+	}
+
+	// Successful probe closes it.
+	s.mu.Lock()
+	s.peers[id].BreakerUntil = time.Now().Add(-time.Second)
+	s.mu.Unlock()
+	if !s.AllowAttempt(id) {
+		t.Fatal("expected a probe")
+	}
+	s.RecordSuccess(id, 100, 10, 1000)
+	if state, _ := s.BreakerStatus(id); state != BreakerClosed {
+		t.Errorf("state = %v, want closed after successful probe", state)
+	}
+}
+
+func TestBreaker_BackoffCapped(t *testing.T) {
+	s := NewScorer()
+	s.SetBreakerConfig(BreakerConfig{FailureThreshold: 1, Backoff: time.Minute, MaxBackoff: 3 * time.Minute})
+	id := peer.ID("dead")
+
+	for i := 0; i < 6; i++ {
+		s.mu.Lock()
+		if ps, ok := s.peers[id]; ok && ps.Breaker == BreakerOpen {
+			ps.Breaker = BreakerHalfOpen
+		}
+		s.mu.Unlock()
+		s.RecordFailure(id, "connect failed")
+	}
+	_, until := s.BreakerStatus(id)
+	if d := time.Until(until); d > 3*time.Minute {
+		t.Errorf("backoff %v exceeds max", d)
+	}
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	s := NewScorer()
+	s.SetBreakerConfig(BreakerConfig{})
+	id := peer.ID("flaky")
+	for i := 0; i < 20; i++ {
+		s.RecordFailure(id, "connect failed")
+	}
+	if !s.AllowAttempt(id) {
+		t.Error("disabled breaker refused an attempt")
+	}
+}
@@ -0,0 +1,172 @@
+package peers
+
+import (
+	"net"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// SelectionConfig tunes how SelectDiverse picks providers and how much
+// address diversity the caller demands before trusting the result.
+//
+// Address groups stand in for network operators: a subnet is an IPv4 /24 or
+// IPv6 /48, and a netgroup is an IPv4 /16 or IPv6 /32 (the same coarse
+// grouping Bitcoin Core uses as an ASN approximation without a routing
+// database). An attacker filling the DHT with sybil providers usually does so
+// from few netgroups, so capping per-group selection and counting distinct
+// groups bounds how much of the provider set one operator can own.
+type SelectionConfig struct {
+	// MaxPerSubnet caps selected providers sharing a /24 (/48). 0 = no cap.
+	MaxPerSubnet int
+	// MaxPerNetgroup caps selected providers sharing a /16 (/32). 0 = no cap.
+	MaxPerNetgroup int
+	// MinScore is the score below which a peer is never selected.
+	MinScore float64
+	// ExplorationRatio is the share of selected slots given to lower-ranked
+	// peers, so unknown peers get a chance to earn a score.
+	ExplorationRatio float64
+	// MinAddressGroups is the number of distinct netgroups a provider set
+	// must span before the caller may rely on it alone. 0 disables the check.
+	MinAddressGroups int
+	// PreferTags ranks peers labeled with any of these tags ahead of the
+	// rest, whatever their score (see Label).
+	PreferTags []string
+}
+
+// DefaultSelectionConfig returns the selection behavior debswarm has always
+// used: no per-group caps, the blacklist score floor, and 30% exploration.
+func DefaultSelectionConfig() SelectionConfig {
+	return SelectionConfig{
+		MinScore:         ScoreBlacklist,
+		ExplorationRatio: 0.3,
+	}
+}
+
+// SetSelectionConfig replaces the selection settings.
+func (s *Scorer) SetSelectionConfig(cfg SelectionConfig) {
+	if cfg.ExplorationRatio < 0 {
+		cfg.ExplorationRatio = 0
+	}
+	if cfg.ExplorationRatio > 1 {
+		cfg.ExplorationRatio = 1
+	}
+	s.mu.Lock()
+	s.selection = cfg
+	s.mu.Unlock()
+}
+
+// SelectionConfig returns the current selection settings.
+func (s *Scorer) SelectionConfig() SelectionConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.selection
+}
+
+// HasSufficientDiversity reports whether providers span at least
+// MinAddressGroups distinct netgroups. LAN peers (mDNS-discovered, or
+// reachable on a private/loopback address) satisfy the check on their own:
+// a WAN attacker cannot place sybils on the local network.
+func (s *Scorer) HasSufficientDiversity(providers []peer.AddrInfo) bool {
+	s.mu.RLock()
+	min := s.selection.MinAddressGroups
+	s.mu.RUnlock()
+	if min <= 0 {
+		return true
+	}
+	for _, p := range providers {
+		if s.IsLANPeer(p) {
+			return true
+		}
+	}
+	return AddressGroups(providers) >= min
+}
+
+// IsLANPeer reports whether p is on the local network: discovered via mDNS,
+// or reachable on a private, loopback or link-local address.
+func (s *Scorer) IsLANPeer(p peer.AddrInfo) bool {
+	return s.IsMDNSPeer(p.ID) || isLocalPeer(p)
+}
+
+// AddressGroups returns the number of distinct netgroups among providers.
+// Providers with no IP address (e.g. relay-only) share a single group.
+func AddressGroups(providers []peer.AddrInfo) int {
+	groups := make(map[string]struct{}, len(providers))
+	for _, p := range providers {
+		groups[netgroupKey(peerIP(p))] = struct{}{}
+	}
+	return len(groups)
+}
+
+// applyDiversityCaps drops peers (in rank order) that would exceed the
+// per-subnet or per-netgroup caps. Local peers are never capped.
+func applyDiversityCaps(ranked []peer.AddrInfo, cfg SelectionConfig) []peer.AddrInfo {
+	if cfg.MaxPerSubnet <= 0 && cfg.MaxPerNetgroup <= 0 {
+		return ranked
+	}
+	subnets := make(map[string]int)
+	netgroups := make(map[string]int)
+	out := make([]peer.AddrInfo, 0, len(ranked))
+	for _, p := range ranked {
+		if isLocalPeer(p) {
+			out = append(out, p)
+			continue
+		}
+		ip := peerIP(p)
+		sk, nk := subnetKey(ip), netgroupKey(ip)
+		if cfg.MaxPerSubnet > 0 && subnets[sk] >= cfg.MaxPerSubnet {
+			continue
+		}
+		if cfg.MaxPerNetgroup > 0 && netgroups[nk] >= cfg.MaxPerNetgroup {
+			continue
+		}
+		subnets[sk]++
+		netgroups[nk]++
+		out = append(out, p)
+	}
+	return out
+}
+
+// peerIP returns the first IP address in the peer's multiaddrs, or nil.
+func peerIP(p peer.AddrInfo) net.IP {
+	for _, addr := range p.Addrs {
+		var ip net.IP
+		multiaddr.ForEach(addr, func(c multiaddr.Component) bool {
+			switch c.Protocol().Code {
+			case multiaddr.P_IP4, multiaddr.P_IP6:
+				ip = net.ParseIP(c.Value())
+				return false
+			}
+			return true
+		})
+		if ip != nil {
+			return ip
+		}
+	}
+	return nil
+}
+
+// isLocalPeer reports whether the peer is reachable on a private or loopback
+// address.
+func isLocalPeer(p peer.AddrInfo) bool {
+	ip := peerIP(p)
+	return ip != nil && (ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast())
+}
+
+func subnetKey(ip net.IP) string {
+	return maskKey(ip, 24, 48)
+}
+
+func netgroupKey(ip net.IP) string {
+	return maskKey(ip, 16, 32)
+}
+
+func maskKey(ip net.IP, v4Bits, v6Bits int) string {
+	if ip == nil {
+		return "noip"
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(v4Bits, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(v6Bits, 128)).String()
+}
@@ -0,0 +1,153 @@
+package peers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func peerAt(t *testing.T, id, ip string) peer.AddrInfo {
+	t.Helper()
+	ma, err := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/%s/tcp/4001", ip))
+	if err != nil {
+		t.Fatalf("multiaddr: %v", err)
+	}
+	return peer.AddrInfo{ID: testPeerID(id), Addrs: []multiaddr.Multiaddr{ma}}
+}
+
+func TestAddressGroups(t *testing.T) {
+	providers := []peer.AddrInfo{
+		peerAt(t, "a", "203.0.113.1"),
+		peerAt(t, "b", "203.0.113.2"),  // same /16 as a
+		peerAt(t, "c", "203.0.200.9"),  // same /16 as a
+		peerAt(t, "d", "198.51.100.7"), // second group
+		{ID: testPeerID("e")},          // no address: "noip" group
+		{ID: testPeerID("f")},          // shares "noip"
+	}
+	if got := AddressGroups(providers); got != 3 {
+		t.Errorf("AddressGroups = %d, want 3", got)
+	}
+}
+
+func TestSelectDiverse_SubnetCap(t *testing.T) {
+	s := NewScorer()
+	cfg := DefaultSelectionConfig()
+	cfg.MaxPerSubnet = 1
+	s.SetSelectionConfig(cfg)
+
+	// Four sybils in one /24 rank highest; two independent peers rank lower.
+	var candidates []peer.AddrInfo
+	for i := 0; i < 4; i++ {
+		p := peerAt(t, fmt.Sprintf("sybil%d", i), fmt.Sprintf("203.0.113.%d", i+1))
+		for j := 0; j < 5; j++ {
+			s.RecordSuccess(p.ID, 1024, 10, 50*1024*1024)
+		}
+		candidates = append(candidates, p)
+	}
+	candidates = append(candidates, peerAt(t, "x", "198.51.100.1"), peerAt(t, "y", "192.0.2.1"))
+
+	got := s.SelectDiverse(candidates, 4)
+	sybils := 0
+	for _, p := range got {
+		if len(p.ID) > 5 && string(p.ID)[:5] == "sybil" {
+			sybils++
+		}
+	}
+	if sybils != 1 {
+		t.Errorf("selected %d peers from one /24, want 1 (got %d peers)", sybils, len(got))
+	}
+	if len(got) != 3 {
+		t.Errorf("len = %d, want 3 (one per subnet)", len(got))
+	}
+}
+
+func TestSelectDiverse_LocalPeersUncapped(t *testing.T) {
+	s := NewScorer()
+	cfg := DefaultSelectionConfig()
+	cfg.MaxPerSubnet = 1
+	s.SetSelectionConfig(cfg)
+
+	candidates := []peer.AddrInfo{
+		peerAt(t, "lan1", "192.168.1.10"),
+		peerAt(t, "lan2", "192.168.1.11"),
+		peerAt(t, "lan3", "192.168.1.12"),
+	}
+	if got := s.SelectDiverse(candidates, 3); len(got) != 3 {
+		t.Errorf("LAN peers should not be capped, got %d", len(got))
+	}
+}
+
+func TestSelectDiverse_ExplorationRatio(t *testing.T) {
+	s := NewScorer()
+	cfg := DefaultSelectionConfig()
+	cfg.ExplorationRatio = 0
+	s.SetSelectionConfig(cfg)
+
+	var candidates []peer.AddrInfo
+	for i := 0; i < 10; i++ {
+		id := testPeerID(string(rune('a' + i)))
+		for j := 0; j < 5; j++ {
+			s.RecordSuccess(id, 1024, float64(10+i*10), float64((10-i)*1024*1024))
+		}
+		candidates = append(candidates, peer.AddrInfo{ID: id})
+	}
+
+	got := s.SelectDiverse(candidates, 4)
+	for i, p := range got {
+		if p.ID != candidates[i].ID {
+			t.Fatalf("with no exploration, slot %d = %s, want %s", i, p.ID, candidates[i].ID)
+		}
+	}
+}
+
+func TestSelectBest_MinScore(t *testing.T) {
+	s := NewScorer()
+	cfg := DefaultSelectionConfig()
+	cfg.MinScore = 0.6 // above the neutral score of unknown peers
+	s.SetSelectionConfig(cfg)
+
+	if got := s.SelectBest([]peer.AddrInfo{{ID: testPeerID("unknown")}}, 5); len(got) != 0 {
+		t.Errorf("peer below MinScore was selected: %v", got)
+	}
+}
+
+func TestHasSufficientDiversity(t *testing.T) {
+	s := NewScorer()
+	wan := []peer.AddrInfo{peerAt(t, "a", "203.0.113.1"), peerAt(t, "b", "203.0.7.1")}
+
+	if !s.HasSufficientDiversity(wan) {
+		t.Error("check disabled by default should always pass")
+	}
+
+	cfg := DefaultSelectionConfig()
+	cfg.MinAddressGroups = 2
+	s.SetSelectionConfig(cfg)
+	if s.HasSufficientDiversity(wan) {
+		t.Error("two providers in one /16 should not satisfy MinAddressGroups=2")
+	}
+	if !s.HasSufficientDiversity(append(wan, peerAt(t, "c", "198.51.100.1"))) {
+		t.Error("two netgroups should satisfy MinAddressGroups=2")
+	}
+
+	s.MarkAsMDNSPeer(testPeerID("a"))
+	if !s.HasSufficientDiversity(wan) {
+		t.Error("an mDNS provider should satisfy the check")
+	}
+}
+
+func TestIsLANPeer(t *testing.T) {
+	s := NewScorer()
+	if !s.IsLANPeer(peerAt(t, "a", "192.168.1.20")) {
+		t.Error("a peer on a private address should be a LAN peer")
+	}
+	wan := peerAt(t, "b", "203.0.113.1")
+	if s.IsLANPeer(wan) {
+		t.Error("a peer on a public address should not be a LAN peer")
+	}
+	s.MarkAsMDNSPeer(wan.ID)
+	if !s.IsLANPeer(wan) {
+		t.Error("an mDNS peer should be a LAN peer")
+	}
+}
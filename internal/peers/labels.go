@@ -0,0 +1,119 @@
+package peers
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Limits on operator-assigned labels
+const (
+	MaxLabelNameLen = 64
+	MaxLabelTags    = 16
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,31}$`)
+
+// Label is the human-friendly name and tags an operator gave a peer, e.g.
+// "rack3-seedbox" tagged "seedbox". Tags can be preferred in provider
+// selection (see SelectionConfig.PreferTags).
+type Label struct {
+	Name string   `json:"name,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// IsZero reports whether the label has neither name nor tags
+func (l Label) IsZero() bool {
+	return l.Name == "" && len(l.Tags) == 0
+}
+
+// HasTag reports whether the label carries tag
+func (l Label) HasTag(tag string) bool {
+	return slices.Contains(l.Tags, tag)
+}
+
+// NormalizeLabel trims the name, lowercases, sorts and de-duplicates the tags,
+// and checks both against the label limits.
+func NormalizeLabel(l Label) (Label, error) {
+	l.Name = strings.TrimSpace(l.Name)
+	if len(l.Name) > MaxLabelNameLen {
+		return Label{}, fmt.Errorf("name is longer than %d bytes", MaxLabelNameLen)
+	}
+	for _, r := range l.Name {
+		if !unicode.IsPrint(r) {
+			return Label{}, fmt.Errorf("name contains a non-printable character")
+		}
+	}
+
+	tags := make([]string, 0, len(l.Tags))
+	for _, t := range l.Tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !ValidTag(t) {
+			return Label{}, fmt.Errorf("invalid tag %q: use up to 32 of a-z, 0-9, '.', '_' and '-'", t)
+		}
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	tags = slices.Compact(tags)
+	if len(tags) > MaxLabelTags {
+		return Label{}, fmt.Errorf("more than %d tags", MaxLabelTags)
+	}
+	if len(tags) == 0 {
+		tags = nil
+	}
+	l.Tags = tags
+	return l, nil
+}
+
+// ValidTag reports whether t is a well-formed tag
+func ValidTag(t string) bool {
+	return tagPattern.MatchString(t)
+}
+
+// SetLabel assigns a label to a peer; a zero label removes it. The label is
+// kept even when the peer is unknown or cleaned up, so it applies whenever
+// the peer shows up.
+func (s *Scorer) SetLabel(peerID peer.ID, l Label) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l.IsZero() {
+		delete(s.labels, peerID)
+		return
+	}
+	s.labels[peerID] = l
+}
+
+// Label returns the label assigned to a peer, zero if none
+func (s *Scorer) Label(peerID peer.ID) Label {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.labels[peerID]
+}
+
+// Name returns the name assigned to a peer, or "" if it has none
+func (s *Scorer) Name(peerID peer.ID) string {
+	return s.Label(peerID).Name
+}
+
+// isPreferredLocked reports whether the peer carries one of the preferred
+// tags. The caller must hold s.mu.
+func (s *Scorer) isPreferredLocked(peerID peer.ID) bool {
+	if len(s.selection.PreferTags) == 0 {
+		return false
+	}
+	l, ok := s.labels[peerID]
+	if !ok {
+		return false
+	}
+	for _, t := range s.selection.PreferTags {
+		if l.HasTag(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package peers
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestNormalizeLabel(t *testing.T) {
+	l, err := NormalizeLabel(Label{Name: "  rack3-seedbox ", Tags: []string{"SeedBox", "rack3", "seedbox"}})
+	if err != nil {
+		t.Fatalf("NormalizeLabel: %v", err)
+	}
+	if l.Name != "rack3-seedbox" || !slices.Equal(l.Tags, []string{"rack3", "seedbox"}) {
+		t.Errorf("label = %+v", l)
+	}
+
+	for _, bad := range []Label{
+		{Tags: []string{"has space"}},
+		{Tags: []string{"-leading"}},
+		{Tags: []string{""}},
+		{Name: "tab\there"},
+		{Name: string(make([]byte, MaxLabelNameLen+1))},
+	} {
+		if _, err := NormalizeLabel(bad); err == nil {
+			t.Errorf("NormalizeLabel(%q) accepted", bad)
+		}
+	}
+}
+
+func TestSelectBest_PreferTags(t *testing.T) {
+	s := NewScorer()
+	fast, seedbox := testPeerID("fast"), testPeerID("seedbox")
+	for i := 0; i < 5; i++ {
+		s.RecordSuccess(fast, 1024, 5, 100*1024*1024)
+		s.RecordSuccess(seedbox, 1024, 200, 1024*1024)
+	}
+	candidates := []peer.AddrInfo{{ID: fast}, {ID: seedbox}}
+
+	if got := s.SelectBest(candidates, 1); got[0].ID != fast {
+		t.Fatalf("without preference selected %s, want the faster peer", got[0].ID)
+	}
+
+	s.SetLabel(seedbox, Label{Name: "rack3-seedbox", Tags: []string{"seedbox"}})
+	cfg := DefaultSelectionConfig()
+	cfg.PreferTags = []string{"seedbox"}
+	s.SetSelectionConfig(cfg)
+	if got := s.SelectBest(candidates, 1); got[0].ID != seedbox {
+		t.Errorf("selected %s, want the peer tagged seedbox", got[0].ID)
+	}
+	if s.Name(seedbox) != "rack3-seedbox" || s.Name(fast) != "" {
+		t.Errorf("Name = %q, %q", s.Name(seedbox), s.Name(fast))
+	}
+
+	// Clearing the label drops the preference
+	s.SetLabel(seedbox, Label{})
+	if got := s.SelectBest(candidates, 1); got[0].ID != fast {
+		t.Errorf("after clearing the label selected %s", got[0].ID)
+	}
+}
@@ -57,6 +57,11 @@ type PeerScore struct {
 	BytesDownloaded int64
 	BytesUploaded   int64
 
+	// Last bandwidth probe (see RecordProbe); ProbedAt is zero if never probed
+	ProbedLatencyMs  float64
+	ProbedThroughput float64
+	ProbedAt         time.Time
+
 	// Timing
 	FirstSeen   time.Time
 	LastSeen    time.Time
@@ -69,6 +74,13 @@ type PeerScore struct {
 	BlacklistUntil  time.Time
 	IsMDNSPeer      bool // True if discovered via mDNS (local LAN peer)
 
+	// Circuit breaker (see BreakerState)
+	ConsecutiveFailures int
+	Breaker             BreakerState
+	BreakerUntil        time.Time // when an open breaker admits its next probe
+	breakerTrips        int       // consecutive trips, for backoff doubling
+	probeStarted        time.Time // when the current half-open probe was admitted
+
 	// Computed score (cached)
 	cachedScore   float64
 	scoreCachedAt time.Time
@@ -82,6 +94,24 @@ type Scorer struct {
 	// Reference values for normalization
 	refLatencyMs  float64 // Expected good latency
 	refThroughput float64 // Expected good throughput
+
+	// Provider selection knobs (see SelectionConfig)
+	selection SelectionConfig
+
+	// Per-peer circuit breaker settings (see BreakerConfig)
+	breaker BreakerConfig
+
+	// Component weights and decay (see ScoringConfig)
+	scoring ScoringConfig
+
+	// Operator-assigned names and tags (see Label)
+	labels map[peer.ID]Label
+
+	// Strikes and escalating bans (see BanPolicy), kept apart from the
+	// scores so they outlive Cleanup
+	bans      map[peer.ID]*Ban
+	banPolicy BanPolicy
+	recordBan BanRecorder
 }
 
 // NewScorer creates a new peer scorer
@@ -90,6 +120,12 @@ func NewScorer() *Scorer {
 		peers:         make(map[peer.ID]*PeerScore),
 		refLatencyMs:  100,              // 100ms is "good"
 		refThroughput: 1024 * 1024 * 10, // 10 MB/s is "good"
+		selection:     DefaultSelectionConfig(),
+		breaker:       DefaultBreakerConfig(),
+		scoring:       DefaultScoringConfig(),
+		labels:        make(map[peer.ID]Label),
+		bans:          make(map[peer.ID]*Ban),
+		banPolicy:     DefaultBanPolicy(),
 	}
 }
 
@@ -106,6 +142,7 @@ func (s *Scorer) RecordSuccess(peerID peer.ID, bytes int64, latencyMs float64, t
 	ps.BytesDownloaded += bytes
 	ps.LastSeen = now
 	ps.LastSuccess = now
+	s.breakerSuccessLocked(ps)
 
 	// Clear expired blacklist on successful transfer
 	if ps.Blacklisted && now.After(ps.BlacklistUntil) {
@@ -113,8 +150,8 @@ func (s *Scorer) RecordSuccess(peerID peer.ID, bytes int64, latencyMs float64, t
 		ps.BlacklistReason = ""
 	}
 
-	// Update EMAs
-	if ps.TotalRequests == 1 {
+	// Update EMAs; a probe has already seeded them
+	if ps.TotalRequests == 1 && ps.ProbedAt.IsZero() {
 		ps.AvgLatencyMs = latencyMs
 		ps.AvgThroughput = throughput
 	} else {
@@ -124,9 +161,33 @@ func (s *Scorer) RecordSuccess(peerID peer.ID, bytes int64, latencyMs float64, t
 
 	ps.SuccessRate = float64(ps.SuccessCount) / float64(ps.TotalRequests)
 
-	// Update cached score while holding write lock
-	ps.cachedScore = s.computeScore(ps)
-	ps.scoreCachedAt = time.Now()
+	// Update cached score while holding write lock, as of the same instant
+	// as the data, so a breakdown can reproduce it exactly
+	ps.cachedScore = s.scoreAt(ps, now)
+	ps.scoreCachedAt = now
+}
+
+// RecordProbe records the result of a bandwidth probe. A peer that has not
+// served a request yet starts its averages from the probe, so its score and
+// adaptive rate limit reflect its capacity from the first transfer; for a
+// peer with transfers behind it the probe is kept for display only.
+func (s *Scorer) RecordProbe(peerID peer.ID, latencyMs, throughput float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ps := s.getOrCreate(peerID)
+	now := time.Now()
+	ps.ProbedLatencyMs = latencyMs
+	ps.ProbedThroughput = throughput
+	ps.ProbedAt = now
+	ps.LastSeen = now
+	if ps.TotalRequests == 0 {
+		ps.AvgLatencyMs = latencyMs
+		ps.AvgThroughput = throughput
+	}
+
+	ps.cachedScore = s.scoreAt(ps, now)
+	ps.scoreCachedAt = now
 }
 
 // RecordFailure records a failed transfer
@@ -141,12 +202,14 @@ func (s *Scorer) RecordFailure(peerID peer.ID, reason string) {
 	ps.FailureCount++
 	ps.LastSeen = now
 	ps.LastFailure = now
+	s.breakerFailureLocked(ps, now)
 
 	ps.SuccessRate = float64(ps.SuccessCount) / float64(ps.TotalRequests)
 
-	// Update cached score while holding write lock
-	ps.cachedScore = s.computeScore(ps)
-	ps.scoreCachedAt = time.Now()
+	// Update cached score while holding write lock, as of the same instant
+	// as the data, so a breakdown can reproduce it exactly
+	ps.cachedScore = s.scoreAt(ps, now)
+	ps.scoreCachedAt = now
 }
 
 // RecordUpload records bytes uploaded to a peer
@@ -205,6 +268,9 @@ func (s *Scorer) IsBlacklisted(peerID peer.ID) bool {
 // Note: This only checks if the peer is currently blacklisted; expired blacklists
 // are cleared during Cleanup() or write operations
 func (s *Scorer) isBlacklistedLocked(peerID peer.ID) bool {
+	if s.isBannedLocked(peerID) {
+		return true
+	}
 	ps, ok := s.peers[peerID]
 	if !ok {
 		return false
@@ -235,7 +301,8 @@ func (s *Scorer) GetScore(peerID peer.ID) float64 {
 	return s.computeScore(ps)
 }
 
-// SelectBest returns the best n peers from the given list, sorted by score
+// SelectBest returns the best n peers from the given list, sorted by score.
+// Peers carrying a preferred tag come before all others.
 func (s *Scorer) SelectBest(candidates []peer.AddrInfo, n int) []peer.AddrInfo {
 	if len(candidates) == 0 {
 		return nil
@@ -245,13 +312,14 @@ func (s *Scorer) SelectBest(candidates []peer.AddrInfo, n int) []peer.AddrInfo {
 	defer s.mu.RUnlock()
 
 	type scored struct {
-		info  peer.AddrInfo
-		score float64
+		info      peer.AddrInfo
+		score     float64
+		preferred bool
 	}
 
 	scoredPeers := make([]scored, 0, len(candidates))
 	for _, c := range candidates {
-		if s.isBlacklistedLocked(c.ID) {
+		if s.isBlacklistedLocked(c.ID) || s.isTrippedLocked(c.ID) {
 			continue
 		}
 
@@ -263,13 +331,16 @@ func (s *Scorer) SelectBest(candidates []peer.AddrInfo, n int) []peer.AddrInfo {
 			score = 0.5 // Unknown peers get neutral score
 		}
 
-		if score >= ScoreBlacklist {
-			scoredPeers = append(scoredPeers, scored{c, score})
+		if score >= s.selection.MinScore {
+			scoredPeers = append(scoredPeers, scored{c, score, s.isPreferredLocked(c.ID)})
 		}
 	}
 
-	// Sort by score descending
+	// Sort preferred peers first, then by score descending
 	sort.Slice(scoredPeers, func(i, j int) bool {
+		if scoredPeers[i].preferred != scoredPeers[j].preferred {
+			return scoredPeers[i].preferred
+		}
 		return scoredPeers[i].score > scoredPeers[j].score
 	})
 
@@ -286,21 +357,28 @@ func (s *Scorer) SelectBest(candidates []peer.AddrInfo, n int) []peer.AddrInfo {
 	return result
 }
 
-// SelectDiverse returns peers with a mix of scores for exploration
-// Returns top performers plus some random lower-scored peers
+// SelectDiverse returns peers with a mix of scores for exploration.
+// Returns top performers plus some lower-scored peers (ExplorationRatio of the
+// slots), after dropping peers that would exceed the per-subnet and
+// per-netgroup caps.
 func (s *Scorer) SelectDiverse(candidates []peer.AddrInfo, n int) []peer.AddrInfo {
 	if len(candidates) == 0 {
 		return nil
 	}
+	cfg := s.SelectionConfig()
 
-	// Get best peers
-	best := s.SelectBest(candidates, n*2)
+	// Rank everything, then apply the address-group caps before trimming to
+	// the 2n pool, so a capped-out group cannot crowd others out of the pool.
+	best := applyDiversityCaps(s.SelectBest(candidates, len(candidates)), cfg)
+	if len(best) > n*2 {
+		best = best[:n*2]
+	}
 	if len(best) <= n {
 		return best
 	}
 
-	// Take 70% best, 30% exploratory
-	numBest := (n * 7) / 10
+	// Take the best (1 - ExplorationRatio) share, explore the rest
+	numBest := int(math.Floor(float64(n)*(1-cfg.ExplorationRatio) + 1e-9))
 	if numBest < 1 {
 		numBest = 1
 	}
@@ -355,9 +433,19 @@ func (s *Scorer) GetAllStats() []*PeerScore {
 	return result
 }
 
-// Cleanup removes stale peer entries
+// Cleanup removes stale peer entries, and the ban records whose strikes are
+// forgotten
 func (s *Scorer) Cleanup() int {
 	s.mu.Lock()
+	pruned, record := s.pruneBansLocked(time.Now()), s.recordBan
+	defer func() {
+		if record == nil {
+			return
+		}
+		for _, id := range pruned {
+			record(Ban{PeerID: id})
+		}
+	}()
 	defer s.mu.Unlock()
 
 	threshold := time.Now().Add(-MaxPeerAge)
@@ -397,6 +485,9 @@ func (s *Scorer) getOrCreate(peerID peer.ID) *PeerScore {
 			LastSeen:  time.Now(),
 		}
 		s.peers[peerID] = ps
+		if b, banned := s.bans[peerID]; banned {
+			s.applyBanLocked(ps, b)
+		}
 	}
 	return ps
 }
@@ -407,20 +498,185 @@ func (s *Scorer) computeScore(ps *PeerScore) float64 {
 	if !ps.scoreCachedAt.IsZero() && time.Since(ps.scoreCachedAt) < ScoreCacheTTL {
 		return ps.cachedScore
 	}
+	return s.scoreAt(ps, time.Now())
+}
 
+// scoreAt computes a peer's score as of now, bypassing the cache
+func (s *Scorer) scoreAt(ps *PeerScore, now time.Time) float64 {
 	// Not enough data - return neutral score (but boost mDNS peers)
 	if ps.TotalRequests < MinSamples {
+		neutral := 0.5
 		if ps.IsMDNSPeer {
-			return 0.65 // mDNS peers get a slight boost even with no data
+			neutral = 0.65 // mDNS peers get a slight boost even with no data
+		}
+		if !ps.ProbedAt.IsZero() {
+			return s.probedScore(ps, neutral, now)
 		}
-		return 0.5
+		return neutral
 	}
 
 	// Blacklisted peers get zero score
-	if ps.Blacklisted && time.Now().Before(ps.BlacklistUntil) {
+	if ps.Blacklisted && now.Before(ps.BlacklistUntil) {
 		return 0
 	}
 
+	score := s.measuredScore(ps, now)
+
+	// Idle peers drift back toward neutral
+	score = 0.5 + (score-0.5)*s.decayFactor(now.Sub(lastTransfer(ps)))
+
+	// Note: We don't cache here as this may be called from RLock context
+	// Caching is done in write operations (RecordSuccess/RecordFailure)
+
+	return score
+}
+
+// measuredScore is the weighted combination of a peer's components as of
+// now, before decay
+func (s *Scorer) measuredScore(ps *PeerScore, now time.Time) float64 {
+	score := s.components(ps, now).weighted(s.scoring.Weights)
+
+	// Clamp to 0-1
+	if score < 0 {
+		score = 0
+	}
+	if score > 1 {
+		score = 1
+	}
+	return score
+}
+
+// probedScore moves the neutral score of a peer with few samples by how its
+// latency and throughput (seeded by a probe) compare to the references, each
+// weighted as in the measured score.
+func (s *Scorer) probedScore(ps *PeerScore, neutral float64, now time.Time) float64 {
+	c := s.components(ps, now)
+	score := neutral +
+		s.scoring.Weights.Latency*(c.Latency-0.5) +
+		s.scoring.Weights.Throughput*(c.Throughput-0.5)
+	return math.Max(0, math.Min(1, score))
+}
+
+// Score bases, saying which rule produced a peer's score
+const (
+	BasisMeasured    = "measured"    // weighted components
+	BasisFewSamples  = "few_samples" // fewer than MinSamples requests: neutral score
+	BasisProbed      = "probed"      // few samples, but a bandwidth probe: neutral moved by its results
+	BasisBlacklisted = "blacklisted" // blacklisted: zero
+)
+
+// ScoreBreakdown explains a peer's current score. Score is what selection
+// uses; it may be a cached value up to ScoreCacheTTL old, computed at
+// CachedAt. Basis, Components, Measured and Decay are evaluated at the same
+// instant as Score, so they always account for it.
+type ScoreBreakdown struct {
+	PeerID     peer.ID
+	Score      float64
+	CachedAt   time.Time // zero when Score was computed just now
+	Basis      string
+	Samples    int64
+	Components ScoreComponents // what the measured score is (or would be) made of
+	Weights    ScoreWeights    // scaled to sum to 1
+	// Measured is the weighted components before decay; Decay is the share
+	// of its distance from neutral left after LastTransfer (1 = none).
+	Measured     float64
+	Decay        float64
+	LastTransfer time.Time
+}
+
+// Breakdown returns the components behind a peer's score, or nil for an
+// unknown peer.
+func (s *Scorer) Breakdown(peerID peer.ID) *ScoreBreakdown {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ps, ok := s.peers[peerID]
+	if !ok {
+		return nil
+	}
+	return s.breakdown(ps)
+}
+
+// AllBreakdowns returns score breakdowns for all known peers
+func (s *Scorer) AllBreakdowns() []*ScoreBreakdown {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*ScoreBreakdown, 0, len(s.peers))
+	for _, ps := range s.peers {
+		result = append(result, s.breakdown(ps))
+	}
+	return result
+}
+
+func (s *Scorer) breakdown(ps *PeerScore) *ScoreBreakdown {
+	// Explain the score selection uses: the cached one as of when it was
+	// computed, otherwise one computed now
+	at := time.Now()
+	var cachedAt time.Time
+	score := 0.0
+	if !ps.scoreCachedAt.IsZero() && at.Sub(ps.scoreCachedAt) < ScoreCacheTTL {
+		at, cachedAt, score = ps.scoreCachedAt, ps.scoreCachedAt, ps.cachedScore
+	} else {
+		score = s.scoreAt(ps, at)
+	}
+
+	basis := BasisMeasured
+	switch {
+	case ps.TotalRequests < MinSamples && !ps.ProbedAt.IsZero():
+		basis = BasisProbed
+	case ps.TotalRequests < MinSamples:
+		basis = BasisFewSamples
+	case ps.Blacklisted && at.Before(ps.BlacklistUntil):
+		basis = BasisBlacklisted
+	}
+	return &ScoreBreakdown{
+		PeerID:       ps.PeerID,
+		Score:        score,
+		CachedAt:     cachedAt,
+		Basis:        basis,
+		Samples:      ps.TotalRequests,
+		Components:   s.components(ps, at),
+		Weights:      s.scoring.Weights,
+		Measured:     s.measuredScore(ps, at),
+		Decay:        s.decayFactor(at.Sub(lastTransfer(ps))),
+		LastTransfer: lastTransfer(ps),
+	}
+}
+
+// ScoreComponents are the normalized (0-1) inputs to a peer's score
+type ScoreComponents struct {
+	Latency     float64
+	Throughput  float64
+	Reliability float64
+	Freshness   float64
+	Proximity   float64
+	Reciprocity float64
+}
+
+// weighted combines the components with the score weights
+func (c ScoreComponents) weighted(w ScoreWeights) float64 {
+	return w.Latency*c.Latency +
+		w.Throughput*c.Throughput +
+		w.Reliability*c.Reliability +
+		w.Freshness*c.Freshness +
+		w.Proximity*c.Proximity +
+		w.Reciprocity*c.Reciprocity
+}
+
+// reciprocityPrior keeps the reciprocity of peers that have exchanged little
+// near neutral
+const reciprocityPrior = 1024 * 1024
+
+// reciprocity scores what a peer served this node against what it took:
+// 1 for a peer that only gives, 0 for one that only takes, 0.5 when even.
+func reciprocity(ps *PeerScore) float64 {
+	given := float64(ps.BytesDownloaded) + reciprocityPrior
+	taken := float64(ps.BytesUploaded) + reciprocityPrior
+	return given / (given + taken)
+}
+
+func (s *Scorer) components(ps *PeerScore, now time.Time) ScoreComponents {
 	// Latency score (lower is better)
 	// Score of 1.0 at refLatency, decreasing as latency increases
 	latencyScore := s.refLatencyMs / (s.refLatencyMs + ps.AvgLatencyMs)
@@ -436,7 +692,7 @@ func (s *Scorer) computeScore(ps *PeerScore) float64 {
 	reliabilityScore := ps.SuccessRate
 
 	// Freshness score - prefer recently active peers
-	hoursSinceLastSeen := time.Since(ps.LastSeen).Hours()
+	hoursSinceLastSeen := math.Max(0, now.Sub(ps.LastSeen).Hours())
 	freshnessScore := math.Exp(-hoursSinceLastSeen / 24) // Decay over 24 hours
 
 	// Proximity score - mDNS (LAN) peers get maximum, DHT peers get lower
@@ -445,25 +701,14 @@ func (s *Scorer) computeScore(ps *PeerScore) float64 {
 		proximityScore = 1.0 // Maximum for LAN peers
 	}
 
-	// Weighted combination
-	score := WeightLatency*latencyScore +
-		WeightThroughput*throughputScore +
-		WeightReliability*reliabilityScore +
-		WeightFreshness*freshnessScore +
-		WeightProximity*proximityScore
-
-	// Clamp to 0-1
-	if score < 0 {
-		score = 0
-	}
-	if score > 1 {
-		score = 1
+	return ScoreComponents{
+		Latency:     latencyScore,
+		Throughput:  throughputScore,
+		Reliability: reliabilityScore,
+		Freshness:   freshnessScore,
+		Proximity:   proximityScore,
+		Reciprocity: reciprocity(ps),
 	}
-
-	// Note: We don't cache here as this may be called from RLock context
-	// Caching is done in write operations (RecordSuccess/RecordFailure)
-
-	return score
 }
 
 // Exponential moving average
@@ -1,6 +1,7 @@
 package peers
 
 import (
+	"math"
 	"sync"
 	"testing"
 	"time"
@@ -717,3 +718,82 @@ func TestGetAllStatsCopy(t *testing.T) {
 		t.Error("GetAllStats should return copies, not references")
 	}
 }
+
+func TestBreakdown(t *testing.T) {
+	s := NewScorer()
+	if s.Breakdown(testPeerID("unknown")) != nil {
+		t.Error("Breakdown of unknown peer should be nil")
+	}
+
+	measured, fresh, banned := testPeerID("measured"), testPeerID("fresh"), testPeerID("banned")
+	for i := 0; i < MinSamples; i++ {
+		s.RecordSuccess(measured, 1024, 100, 10*1024*1024)
+		s.RecordSuccess(banned, 1024, 100, 10*1024*1024)
+	}
+	s.RecordSuccess(fresh, 1024, 100, 10*1024*1024)
+	s.Blacklist(banned, "hash mismatch", time.Hour)
+
+	b := s.Breakdown(measured)
+	if b.Basis != BasisMeasured || b.Samples != MinSamples {
+		t.Errorf("measured peer: basis %q, samples %d", b.Basis, b.Samples)
+	}
+	// At the reference latency and throughput those components are exactly half
+	if math.Abs(b.Components.Latency-0.5) > 1e-9 || math.Abs(b.Components.Throughput-0.5) > 1e-9 {
+		t.Errorf("components = %+v", b.Components)
+	}
+	if b.Score != s.GetScore(measured) || b.CachedAt.IsZero() {
+		t.Errorf("score %v (cached at %v), GetScore %v", b.Score, b.CachedAt, s.GetScore(measured))
+	}
+
+	if b := s.Breakdown(fresh); b.Basis != BasisFewSamples || b.Score != 0.5 {
+		t.Errorf("fresh peer: %+v", b)
+	}
+	if b := s.Breakdown(banned); b.Basis != BasisBlacklisted || b.Score != 0 {
+		t.Errorf("blacklisted peer: %+v", b)
+	}
+	if n := len(s.AllBreakdowns()); n != 3 {
+		t.Errorf("AllBreakdowns returned %d peers, want 3", n)
+	}
+}
+
+func TestScoreUpdatesOnceMeasured(t *testing.T) {
+	s := NewScorer()
+	id := testPeerID("new")
+	for i := 0; i < MinSamples; i++ {
+		s.RecordFailure(id, "timeout")
+	}
+	// The neutral score cached while there were too few samples must not
+	// outlive them
+	if got := s.GetScore(id); got >= 0.5 {
+		t.Errorf("score after %d failures = %v, want below neutral", MinSamples, got)
+	}
+}
+
+func TestRecordProbe(t *testing.T) {
+	s := NewScorer()
+	fast, slow := testPeerID("fast"), testPeerID("slow")
+	s.RecordProbe(fast, 10, 100*1024*1024)
+	s.RecordProbe(slow, 1000, 100*1024)
+
+	if f, sl := s.GetScore(fast), s.GetScore(slow); f <= 0.5 || sl >= 0.5 {
+		t.Errorf("probed scores: fast %v, slow %v; want either side of neutral", f, sl)
+	}
+	if b := s.Breakdown(fast); b.Basis != BasisProbed || b.Samples != 0 {
+		t.Errorf("probed peer: basis %q, samples %d", b.Basis, b.Samples)
+	}
+
+	// The first transfer blends with the probe instead of replacing it
+	s.RecordSuccess(slow, 1024, 100, 10*1024*1024)
+	ps := s.GetStats(slow)
+	if want := ema(100*1024, 10*1024*1024, EMAAlpha); math.Abs(ps.AvgThroughput-want) > 1e-6 {
+		t.Errorf("throughput after first transfer = %v, want %v", ps.AvgThroughput, want)
+	}
+
+	// A probe does not overwrite what transfers measured
+	measured := testPeerID("measured")
+	s.RecordSuccess(measured, 1024, 100, 10*1024*1024)
+	s.RecordProbe(measured, 5, 1024)
+	if ps := s.GetStats(measured); ps.AvgThroughput != 10*1024*1024 || ps.ProbedThroughput != 1024 {
+		t.Errorf("measured peer after probe: %+v", ps)
+	}
+}
@@ -0,0 +1,105 @@
+package peers
+
+import (
+	"math"
+	"time"
+)
+
+// ScoreWeights sets how much each component counts toward a measured score.
+// Weights are relative: they are scaled to sum to 1.
+type ScoreWeights struct {
+	Latency     float64
+	Throughput  float64
+	Reliability float64
+	Freshness   float64
+	Proximity   float64
+	Reciprocity float64
+}
+
+// DefaultScoreWeights returns the weights debswarm has always used.
+// Reciprocity is off by default.
+func DefaultScoreWeights() ScoreWeights {
+	return ScoreWeights{
+		Latency:     WeightLatency,
+		Throughput:  WeightThroughput,
+		Reliability: WeightReliability,
+		Freshness:   WeightFreshness,
+		Proximity:   WeightProximity,
+	}
+}
+
+// normalized scales the weights to sum to 1. All-zero (or negative) weights
+// fall back to the defaults.
+func (w ScoreWeights) normalized() ScoreWeights {
+	for _, v := range []*float64{&w.Latency, &w.Throughput, &w.Reliability, &w.Freshness, &w.Proximity, &w.Reciprocity} {
+		*v = max(*v, 0)
+	}
+	sum := w.Latency + w.Throughput + w.Reliability + w.Freshness + w.Proximity + w.Reciprocity
+	if sum <= 0 {
+		return DefaultScoreWeights().normalized()
+	}
+	return ScoreWeights{
+		Latency:     w.Latency / sum,
+		Throughput:  w.Throughput / sum,
+		Reliability: w.Reliability / sum,
+		Freshness:   w.Freshness / sum,
+		Proximity:   w.Proximity / sum,
+		Reciprocity: w.Reciprocity / sum,
+	}
+}
+
+// ScoringConfig tunes how measured scores are computed.
+type ScoringConfig struct {
+	Weights ScoreWeights
+	// DecayHalfLife pulls a measured score toward neutral (0.5) the longer
+	// a peer goes without a transfer: halfway after one half-life. A peer
+	// that was bad an hour ago is not necessarily bad now, and one that was
+	// excellent last week should not outrank fresh peers forever. 0 disables
+	// decay.
+	DecayHalfLife time.Duration
+}
+
+// DefaultScoringConfig returns the default scoring settings.
+func DefaultScoringConfig() ScoringConfig {
+	return ScoringConfig{
+		Weights:       DefaultScoreWeights(),
+		DecayHalfLife: 6 * time.Hour,
+	}
+}
+
+// SetScoringConfig replaces the scoring settings. Cached scores are dropped
+// so the new settings apply at once.
+func (s *Scorer) SetScoringConfig(cfg ScoringConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cfg.Weights = cfg.Weights.normalized()
+	s.scoring = cfg
+	for _, ps := range s.peers {
+		ps.scoreCachedAt = time.Time{}
+	}
+}
+
+// ScoringConfig returns the current scoring settings, with weights scaled
+// to sum to 1.
+func (s *Scorer) ScoringConfig() ScoringConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.scoring
+}
+
+// decayFactor returns how much of a measured score's distance from neutral
+// is left after idle without a transfer
+func (s *Scorer) decayFactor(idle time.Duration) float64 {
+	if s.scoring.DecayHalfLife <= 0 || idle <= 0 {
+		return 1
+	}
+	return math.Exp2(-idle.Hours() / s.scoring.DecayHalfLife.Hours())
+}
+
+// lastTransfer returns when the peer last succeeded or failed a transfer
+func lastTransfer(ps *PeerScore) time.Time {
+	if ps.LastFailure.After(ps.LastSuccess) {
+		return ps.LastFailure
+	}
+	return ps.LastSuccess
+}
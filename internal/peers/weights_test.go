@@ -0,0 +1,82 @@
+package peers
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestScoreDecay(t *testing.T) {
+	s := NewScorer()
+	s.SetScoringConfig(ScoringConfig{Weights: DefaultScoreWeights(), DecayHalfLife: time.Hour})
+	id := testPeerID("idle")
+	for i := 0; i < MinSamples; i++ {
+		s.RecordFailure(id, "timeout")
+	}
+	ps := s.peers[id]
+
+	age := func(d time.Duration) {
+		ps.LastFailure = time.Now().Add(-d)
+		ps.LastSeen = ps.LastFailure
+		ps.scoreCachedAt = time.Time{}
+	}
+	age(0)
+	fresh := s.GetScore(id)
+	if fresh >= 0.5 {
+		t.Fatalf("failing peer scores %v, want below neutral", fresh)
+	}
+
+	age(time.Hour)
+	b := s.Breakdown(id)
+	if math.Abs(b.Decay-0.5) > 1e-3 {
+		t.Errorf("decay after one half-life = %v, want 0.5", b.Decay)
+	}
+	want := 0.5 + (b.Measured-0.5)*b.Decay
+	if math.Abs(b.Score-want) > 1e-9 {
+		t.Errorf("score = %v, want %v", b.Score, want)
+	}
+
+	age(48 * time.Hour)
+	if got := s.GetScore(id); math.Abs(got-0.5) > 1e-3 {
+		t.Errorf("score after a long idle = %v, want ~0.5", got)
+	}
+
+	s.SetScoringConfig(ScoringConfig{Weights: DefaultScoreWeights()})
+	if got := s.GetScore(id); got >= fresh+0.1 {
+		t.Errorf("score with decay off = %v, want close to %v", got, fresh)
+	}
+}
+
+func TestScoreWeights(t *testing.T) {
+	w := ScoreWeights{Latency: 2, Reciprocity: 2}.normalized()
+	if w.Latency != 0.5 || w.Reciprocity != 0.5 || w.Throughput != 0 {
+		t.Errorf("normalized = %+v", w)
+	}
+	if got := (ScoreWeights{}).normalized(); got != DefaultScoreWeights().normalized() {
+		t.Errorf("zero weights normalized to %+v, want the defaults", got)
+	}
+
+	s := NewScorer()
+	giver, taker := testPeerID("giver"), testPeerID("taker")
+	for i := 0; i < MinSamples; i++ {
+		s.RecordSuccess(giver, 50*1024*1024, 100, 1024*1024)
+		s.RecordSuccess(taker, 1024, 100, 1024*1024)
+	}
+	s.RecordUpload(taker, 500*1024*1024)
+	if r := s.Breakdown(giver).Components.Reciprocity; r < 0.9 {
+		t.Errorf("giver reciprocity = %v, want near 1", r)
+	}
+	if r := s.Breakdown(taker).Components.Reciprocity; r > 0.1 {
+		t.Errorf("taker reciprocity = %v, want near 0", r)
+	}
+
+	// With reciprocity weighed in, the giver outranks the taker even though
+	// the rest of their measurements are alike
+	s.SetScoringConfig(ScoringConfig{Weights: ScoreWeights{Reliability: 1, Reciprocity: 1}})
+	if s.GetScore(giver) <= s.GetScore(taker) {
+		t.Errorf("giver %v should outrank taker %v", s.GetScore(giver), s.GetScore(taker))
+	}
+	if w := s.ScoringConfig().Weights; w.Reliability != 0.5 || w.Reciprocity != 0.5 {
+		t.Errorf("ScoringConfig().Weights = %+v", w)
+	}
+}
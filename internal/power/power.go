@@ -0,0 +1,131 @@
+// Package power reports whether the host runs on battery, read from
+// Linux's /sys/class/power_supply, and whether its network connection is
+// metered, as NetworkManager decides it. Where either source is missing,
+// Sample says so in the reading rather than failing.
+package power
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nmMetered values of NetworkManager's Metered property (NMMetered)
+const (
+	nmMeteredYes      = 1
+	nmMeteredGuessYes = 3
+)
+
+// queryTimeout bounds the question to NetworkManager
+const queryTimeout = 5 * time.Second
+
+// ErrNoNetworkManager is returned when NetworkManager cannot be asked
+// whether the connection is metered.
+var ErrNoNetworkManager = errors.New("NetworkManager not available")
+
+// Sample is one reading of the host's power source and network.
+type Sample struct {
+	Time      time.Time
+	OnBattery bool  // a system battery powers the host, no AC adapter is online
+	Metered   bool  // NetworkManager considers the primary connection metered
+	PowerErr  error // why the power source could not be read; reads as AC
+	NetErr    error // why the metered state could not be read; reads as unmetered
+}
+
+// Detector reads Samples.
+type Detector struct {
+	sysDir string
+	// metered asks NetworkManager for its Metered property
+	metered func(ctx context.Context) (uint32, error)
+}
+
+// NewDetector returns a Detector reading the host's power supplies and
+// asking NetworkManager over the system bus.
+func NewDetector() *Detector {
+	return &Detector{sysDir: "/sys/class/power_supply", metered: busctlMetered}
+}
+
+// Sample reads the current power source and metered state.
+func (d *Detector) Sample(ctx context.Context) Sample {
+	s := Sample{Time: time.Now()}
+	s.OnBattery, s.PowerErr = d.onBattery()
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	v, err := d.metered(ctx)
+	if err != nil {
+		s.NetErr = err
+	} else {
+		s.Metered = v == nmMeteredYes || v == nmMeteredGuessYes
+	}
+	return s
+}
+
+// onBattery reports whether a system battery powers the host: there is one,
+// and no mains or USB supply is online. Batteries of peripherals (scope
+// "Device") do not count; a host without a battery is on AC.
+func (d *Detector) onBattery() (bool, error) {
+	entries, err := os.ReadDir(d.sysDir)
+	if err != nil {
+		return false, err
+	}
+	battery, ac := false, false
+	for _, e := range entries {
+		dir := filepath.Join(d.sysDir, e.Name())
+		if readAttr(dir, "scope") == "Device" {
+			continue
+		}
+		switch readAttr(dir, "type") {
+		case "Battery":
+			if readAttr(dir, "present") != "0" {
+				battery = true
+			}
+		case "Mains", "USB", "USB_C", "USB_PD":
+			if readAttr(dir, "online") == "1" {
+				ac = true
+			}
+		}
+	}
+	return battery && !ac, nil
+}
+
+// readAttr returns a sysfs attribute without surrounding whitespace, "" if
+// it cannot be read.
+func readAttr(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// busctlMetered reads NetworkManager's Metered property with busctl, which
+// ships with systemd, so no D-Bus library is needed.
+func busctlMetered(ctx context.Context) (uint32, error) {
+	out, err := exec.CommandContext(ctx, "busctl", "--system", "get-property",
+		"org.freedesktop.NetworkManager", "/org/freedesktop/NetworkManager",
+		"org.freedesktop.NetworkManager", "Metered").Output()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrNoNetworkManager, err)
+	}
+	return parseMetered(string(out))
+}
+
+// parseMetered parses busctl's rendering of a uint32 property: "u 4".
+func parseMetered(out string) (uint32, error) {
+	fields := strings.Fields(out)
+	if len(fields) != 2 || fields[0] != "u" {
+		return 0, fmt.Errorf("unexpected Metered property %q", strings.TrimSpace(out))
+	}
+	v, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected Metered property %q", strings.TrimSpace(out))
+	}
+	return uint32(v), nil
+}
@@ -0,0 +1,86 @@
+package power
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSupply creates a power supply directory with the given attributes.
+func writeSupply(t *testing.T, dir, name string, attrs map[string]string) {
+	t.Helper()
+	supply := filepath.Join(dir, name)
+	if err := os.MkdirAll(supply, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for attr, value := range attrs {
+		if err := os.WriteFile(filepath.Join(supply, attr), []byte(value+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOnBattery(t *testing.T) {
+	dir := t.TempDir()
+	d := &Detector{sysDir: dir}
+
+	// No supplies at all: a desktop
+	if on, err := d.onBattery(); err != nil || on {
+		t.Fatalf("no supplies: onBattery = %v, %v", on, err)
+	}
+
+	writeSupply(t, dir, "BAT0", map[string]string{"type": "Battery", "present": "1", "status": "Discharging"})
+	writeSupply(t, dir, "AC", map[string]string{"type": "Mains", "online": "0"})
+	// A wireless mouse charging over USB is not the host's power source
+	writeSupply(t, dir, "hidpp_battery_0", map[string]string{"type": "Battery", "scope": "Device"})
+	writeSupply(t, dir, "ucsi-source-psy-USBC000:001", map[string]string{"type": "USB", "scope": "Device", "online": "1"})
+	if on, err := d.onBattery(); err != nil || !on {
+		t.Fatalf("unplugged laptop: onBattery = %v, %v", on, err)
+	}
+
+	writeSupply(t, dir, "AC", map[string]string{"type": "Mains", "online": "1"})
+	if on, err := d.onBattery(); err != nil || on {
+		t.Fatalf("plugged-in laptop: onBattery = %v, %v", on, err)
+	}
+
+	if _, err := (&Detector{sysDir: filepath.Join(dir, "missing")}).onBattery(); err == nil {
+		t.Error("missing power_supply directory read without error")
+	}
+}
+
+func TestSampleMetered(t *testing.T) {
+	for _, tc := range []struct {
+		value   uint32
+		err     error
+		metered bool
+	}{
+		{value: 1, metered: true},  // yes
+		{value: 3, metered: true},  // guess-yes
+		{value: 2, metered: false}, // no
+		{value: 4, metered: false}, // guess-no
+		{value: 0, metered: false}, // unknown
+		{err: ErrNoNetworkManager, metered: false},
+	} {
+		d := &Detector{
+			sysDir:  t.TempDir(),
+			metered: func(context.Context) (uint32, error) { return tc.value, tc.err },
+		}
+		s := d.Sample(context.Background())
+		if s.Metered != tc.metered || !errors.Is(s.NetErr, tc.err) {
+			t.Errorf("Metered %d (%v): sample = %+v", tc.value, tc.err, s)
+		}
+	}
+}
+
+func TestParseMetered(t *testing.T) {
+	if v, err := parseMetered("u 4\n"); err != nil || v != 4 {
+		t.Errorf(`parseMetered("u 4") = %d, %v`, v, err)
+	}
+	for _, bad := range []string{"", "u", "s \"yes\"", "u -1", "u 4 5"} {
+		if _, err := parseMetered(bad); err == nil {
+			t.Errorf("parseMetered(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Package prefetch defines package groups ("tasks"): named sets of
+// packages, such as everything a new development machine needs, that the
+// daemon keeps cached so machines can be provisioned from the LAN swarm
+// without the mirror. Groups come from the configuration file or are added
+// at runtime and persisted in a Store. A Catalog resolves them against the
+// package index, dependencies included.
+package prefetch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Group is a named set of packages.
+type Group struct {
+	Name string `json:"name"`
+	// Packages are package names, optionally qualified with an
+	// architecture ("libc6:i386"); unqualified names are resolved for every
+	// configured architecture.
+	Packages []string `json:"packages"`
+}
+
+var (
+	validGroupName   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+	validPackageName = regexp.MustCompile(`^[a-z0-9][a-z0-9.+-]+(:[a-z0-9-]+)?$`)
+)
+
+// Validate checks the group name and package names.
+func (g Group) Validate() error {
+	if !validGroupName.MatchString(g.Name) {
+		return fmt.Errorf("invalid group name %q: use letters, digits, '.', '_' and '-', at most 64", g.Name)
+	}
+	if len(g.Packages) == 0 {
+		return errors.New("a group needs at least one package")
+	}
+	for _, p := range g.Packages {
+		if !validPackageName.MatchString(p) {
+			return fmt.Errorf("invalid package name %q", p)
+		}
+	}
+	return nil
+}
+
+// HostArchitecture returns the Debian architecture of the host, the
+// default architecture groups are resolved for.
+func HostArchitecture() string {
+	switch runtime.GOARCH {
+	case "386":
+		return "i386"
+	case "arm":
+		return "armhf"
+	case "ppc64le":
+		return "ppc64el"
+	case "mips64le":
+		return "mips64el"
+	case "mipsle":
+		return "mipsel"
+	default:
+		return runtime.GOARCH
+	}
+}
+
+// splitArch splits "name:arch" into its name and architecture.
+func splitArch(s string) (name, arch string) {
+	name, arch, _ = strings.Cut(s, ":")
+	return name, arch
+}
@@ -0,0 +1,147 @@
+package prefetch
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+func pkg(name, version, arch, depends string) *index.PackageInfo {
+	return &index.PackageInfo{
+		Package:      name,
+		Version:      version,
+		Architecture: arch,
+		Depends:      depends,
+		SHA256:       name + "_" + version + "_" + arch,
+	}
+}
+
+func hashes(pkgs []*index.PackageInfo) []string {
+	var out []string
+	for _, p := range pkgs {
+		out = append(out, p.SHA256)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestCatalogResolve(t *testing.T) {
+	catalog := NewCatalog([]*index.PackageInfo{
+		pkg("build-essential", "12.9", "amd64", "libc6-dev | libc-dev, gcc (>= 4:10), make"),
+		pkg("build-essential", "12.9", "arm64", "libc6-dev | libc-dev, gcc (>= 4:10), make"),
+		pkg("libc6-dev", "2.36-9", "amd64", "libc6 (= 2.36-9)"),
+		pkg("libc6-dev", "2.36-9+deb12u10", "amd64", "libc6 (= 2.36-9+deb12u10)"),
+		pkg("libc6", "2.36-9+deb12u10", "amd64", ""),
+		pkg("gcc", "4:12.2.0-3", "amd64", "cpp (= 4:12.2.0-3), gcc-12"),
+		pkg("cpp", "4:12.2.0-3", "amd64", ""),
+		pkg("make", "4.3-4.1", "amd64", ""),
+		pkg("git", "1:2.39.5-0+deb12u2", "amd64", "perl, git-man (>> 1:2.39.5), libc6 (>= 2.34)"),
+		pkg("git-man", "1:2.39.5-0+deb12u2", "all", ""),
+		pkg("perl", "5.36.0-7", "amd64", "perlapi-5.36.0 | awk"),
+		pkg("vim", "2:9.0.1378-2", "s390x", ""),
+	}, []string{"amd64"})
+
+	res := catalog.Resolve(Group{Name: "base-dev", Packages: []string{"build-essential", "git", "nano"}}, true)
+	want := []string{
+		"build-essential_12.9_amd64", "cpp_4:12.2.0-3_amd64", "gcc_4:12.2.0-3_amd64",
+		"git-man_1:2.39.5-0+deb12u2_all", "git_1:2.39.5-0+deb12u2_amd64",
+		"libc6-dev_2.36-9+deb12u10_amd64", "libc6_2.36-9+deb12u10_amd64",
+		"make_4.3-4.1_amd64", "perl_5.36.0-7_amd64",
+	}
+	if got := hashes(res.Packages); !slices.Equal(got, want) {
+		t.Errorf("packages = %v, want %v", got, want)
+	}
+	if !slices.Equal(res.Missing, []string{"nano:amd64"}) {
+		t.Errorf("missing = %v", res.Missing)
+	}
+	if !slices.Equal(res.Unresolved, []string{"gcc-12", "perlapi-5.36.0 | awk"}) {
+		t.Errorf("unresolved = %v", res.Unresolved)
+	}
+
+	res = catalog.Resolve(Group{Name: "git", Packages: []string{"git"}}, false)
+	if got := hashes(res.Packages); !slices.Equal(got, []string{"git_1:2.39.5-0+deb12u2_amd64"}) {
+		t.Errorf("without dependencies = %v", got)
+	}
+
+	// Qualified names resolve only for their architecture
+	res = catalog.Resolve(Group{Name: "x", Packages: []string{"make:amd64", "make:arm64"}}, false)
+	if len(res.Packages) != 1 || !slices.Equal(res.Missing, []string{"make:arm64"}) {
+		t.Errorf("qualified = %v, missing %v", hashes(res.Packages), res.Missing)
+	}
+}
+
+func TestParseRelations(t *testing.T) {
+	got := ParseRelations("libc6 (>= 2.34), python3:any, foo [amd64] | bar <!nocheck>, ")
+	want := [][]string{{"libc6"}, {"python3"}, {"foo", "bar"}}
+	if len(got) != len(want) {
+		t.Fatalf("ParseRelations = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !slices.Equal(got[i], want[i]) {
+			t.Errorf("relation %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestGroupValidate(t *testing.T) {
+	for _, g := range []Group{
+		{Name: "base-dev", Packages: []string{"build-essential", "libc6:i386", "g++"}},
+	} {
+		if err := g.Validate(); err != nil {
+			t.Errorf("%+v: %v", g, err)
+		}
+	}
+	for _, g := range []Group{
+		{Name: "", Packages: []string{"git"}},
+		{Name: "a/b", Packages: []string{"git"}},
+		{Name: "empty"},
+		{Name: "bad", Packages: []string{"Git"}},
+		{Name: "bad", Packages: []string{"git (>= 1)"}},
+	} {
+		if err := g.Validate(); err == nil {
+			t.Errorf("%+v accepted", g)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prefetch.json")
+	s, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(Group{Name: "base-dev", Packages: []string{"build-essential"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(Group{Name: "base-dev", Packages: []string{"build-essential", "git"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(Group{Name: "bad name"}); err == nil {
+		t.Error("invalid group stored")
+	}
+	if err := s.SetPinned([]string{"bb", "aa"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := reopened.Groups()
+	if len(groups) != 1 || !slices.Equal(groups[0].Packages, []string{"build-essential", "git"}) {
+		t.Errorf("groups after reopening = %+v", groups)
+	}
+	if !slices.Equal(reopened.Pinned(), []string{"aa", "bb"}) {
+		t.Errorf("pinned after reopening = %v", reopened.Pinned())
+	}
+
+	if err := reopened.Remove("base-dev"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Remove("base-dev"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Remove = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,147 @@
+package prefetch
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/debswarm/debswarm/internal/debpkg"
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+// Catalog looks packages up by name and architecture in a snapshot of the
+// index. Where several versions of a package are indexed, e.g. from a
+// suite and its security updates, the newest wins, as APT picks by
+// default.
+type Catalog struct {
+	archs  []string
+	byName map[string]map[string]*index.PackageInfo // name → architecture → newest
+}
+
+// NewCatalog builds a catalog of the packages for archs, and those for
+// every architecture ("all").
+func NewCatalog(pkgs []*index.PackageInfo, archs []string) *Catalog {
+	c := &Catalog{archs: archs, byName: make(map[string]map[string]*index.PackageInfo)}
+	for _, pkg := range pkgs {
+		if pkg.Architecture != "all" && !slices.Contains(archs, pkg.Architecture) {
+			continue
+		}
+		byArch := c.byName[pkg.Package]
+		if byArch == nil {
+			byArch = make(map[string]*index.PackageInfo)
+			c.byName[pkg.Package] = byArch
+		}
+		if cur := byArch[pkg.Architecture]; cur == nil || debpkg.CompareVersions(pkg.Version, cur.Version) > 0 {
+			byArch[pkg.Architecture] = pkg
+		}
+	}
+	return c
+}
+
+// lookup returns the package name installs on arch.
+func (c *Catalog) lookup(name, arch string) *index.PackageInfo {
+	byArch := c.byName[name]
+	if pkg := byArch[arch]; pkg != nil {
+		return pkg
+	}
+	return byArch["all"]
+}
+
+// Resolution is what a group resolves to.
+type Resolution struct {
+	// Packages to cache, one per SHA256
+	Packages []*index.PackageInfo
+	// Missing lists the group's packages ("name:arch") the index does not
+	// have
+	Missing []string
+	// Unresolved lists dependencies no indexed package satisfies, such as
+	// virtual packages; name their providers in the group to include them
+	Unresolved []string
+}
+
+// Resolve resolves the packages of g for each architecture of the
+// catalog, with their dependencies (Pre-Depends and Depends) when
+// dependencies is set. Of alternatives ("a | b") the first indexed one is
+// taken, and version constraints are not checked: the newest version is
+// always used.
+func (c *Catalog) Resolve(g Group, dependencies bool) Resolution {
+	type key struct{ name, arch string }
+	var queue []key
+	for _, p := range g.Packages {
+		name, arch := splitArch(p)
+		if arch != "" {
+			queue = append(queue, key{name, arch})
+			continue
+		}
+		for _, a := range c.archs {
+			queue = append(queue, key{name, a})
+		}
+	}
+	requested := len(queue)
+
+	var res Resolution
+	seen := make(map[key]bool)
+	found := make(map[string]bool)
+	unresolved := make(map[string]bool)
+	for i := 0; i < len(queue); i++ {
+		k := queue[i]
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		pkg := c.lookup(k.name, k.arch)
+		if pkg == nil {
+			if i < requested {
+				res.Missing = append(res.Missing, k.name+":"+k.arch)
+			}
+			continue
+		}
+		if !found[pkg.SHA256] {
+			found[pkg.SHA256] = true
+			res.Packages = append(res.Packages, pkg)
+		}
+		if !dependencies {
+			continue
+		}
+		for _, alternatives := range ParseRelations(pkg.Depends) {
+			satisfied := false
+			for _, alt := range alternatives {
+				if c.lookup(alt, k.arch) != nil {
+					queue = append(queue, key{alt, k.arch})
+					satisfied = true
+					break
+				}
+			}
+			if !satisfied && !unresolved[alternatives[0]] {
+				unresolved[alternatives[0]] = true
+				res.Unresolved = append(res.Unresolved, strings.Join(alternatives, " | "))
+			}
+		}
+	}
+	slices.Sort(res.Unresolved)
+	return res
+}
+
+// ParseRelations parses a Depends field into the package names of each
+// relation's alternatives, dropping version constraints ("(>= 1.0)"),
+// architecture qualifiers (":any") and restrictions ("[amd64]",
+// "<!nocheck>").
+func ParseRelations(field string) [][]string {
+	var relations [][]string
+	for _, rel := range strings.Split(field, ",") {
+		var alternatives []string
+		for _, alt := range strings.Split(rel, "|") {
+			alt = strings.TrimSpace(alt)
+			if i := strings.IndexAny(alt, " ([<"); i >= 0 {
+				alt = alt[:i]
+			}
+			alt, _ = splitArch(alt)
+			if alt != "" {
+				alternatives = append(alternatives, alt)
+			}
+		}
+		if len(alternatives) > 0 {
+			relations = append(relations, alternatives)
+		}
+	}
+	return relations
+}
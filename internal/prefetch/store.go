@@ -0,0 +1,118 @@
+package prefetch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+)
+
+// ErrNotFound is returned when removing a group that does not exist.
+var ErrNotFound = errors.New("no such group")
+
+// state is what a Store persists.
+type state struct {
+	Groups []Group `json:"groups,omitempty"`
+	// Pinned lists the packages pinned in the cache for groups, so they
+	// can be unpinned once no group needs them; packages pinned by other
+	// means are never listed
+	Pinned []string `json:"pinned,omitempty"`
+}
+
+// Store holds the groups added at runtime, and the packages pinned for
+// groups, persisted as JSON so they survive restarts.
+type Store struct {
+	path string
+
+	mu    sync.Mutex
+	state state
+}
+
+// OpenStore opens the store at path. A missing file is an empty store; an
+// empty path keeps the store in memory.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Groups returns the groups added at runtime.
+func (s *Store) Groups() []Group {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.state.Groups)
+}
+
+// Put adds g, replacing a group of the same name, and persists it.
+func (s *Store) Put(g Group) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.state
+	next.Groups = slices.DeleteFunc(slices.Clone(next.Groups), func(o Group) bool { return o.Name == g.Name })
+	next.Groups = append(next.Groups, g)
+	return s.saveLocked(next)
+}
+
+// Remove removes the group named name.
+func (s *Store) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.state
+	next.Groups = slices.DeleteFunc(slices.Clone(next.Groups), func(g Group) bool { return g.Name == name })
+	if len(next.Groups) == len(s.state.Groups) {
+		return ErrNotFound
+	}
+	return s.saveLocked(next)
+}
+
+// Pinned returns the packages pinned for groups.
+func (s *Store) Pinned() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.state.Pinned)
+}
+
+// SetPinned records the packages pinned for groups.
+func (s *Store) SetPinned(hashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.state
+	next.Pinned = slices.Sorted(slices.Values(hashes))
+	return s.saveLocked(next)
+}
+
+// saveLocked persists next and makes it current. Caller holds s.mu.
+func (s *Store) saveLocked(next state) error {
+	if s.path != "" {
+		data, err := json.MarshalIndent(next, "", "  ")
+		if err != nil {
+			return err
+		}
+		tmp := s.path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0o644); err != nil {
+			return fmt.Errorf("failed to save prefetch groups: %w", err)
+		}
+		if err := os.Rename(tmp, s.path); err != nil {
+			return fmt.Errorf("failed to save prefetch groups: %w", err)
+		}
+	}
+	s.state = next
+	return nil
+}
@@ -0,0 +1,107 @@
+// Package priority classifies transfers so that a client waiting on a
+// package is served before background work. A download carries its class in
+// its context: the downloader holds background downloads back while an
+// interactive one runs, and peers serving a background transfer keep upload
+// slots free for interactive requests.
+package priority
+
+import (
+	"context"
+	"sync"
+)
+
+// Class is the priority of a transfer. Lower values come first.
+type Class uint8
+
+// Classes, highest priority first.
+const (
+	// Interactive is a client waiting on the package, e.g. apt install.
+	// Work without a class is interactive.
+	Interactive Class = iota
+	// Replication is fleet replication between seeds.
+	Replication
+	// Prefetch is background work nobody waits on, e.g. retries of
+	// failed downloads.
+	Prefetch
+)
+
+// String returns the class name used in logs and metrics.
+func (c Class) String() string {
+	switch c {
+	case Replication:
+		return "replication"
+	case Prefetch:
+		return "prefetch"
+	default:
+		return "interactive"
+	}
+}
+
+// Background reports whether c yields to interactive transfers.
+func (c Class) Background() bool {
+	return c != Interactive
+}
+
+// Level is the class of a running download. A background download that a
+// client starts waiting on is promoted to interactive while it runs.
+type Level struct {
+	mu       sync.Mutex
+	class    Class
+	promoted chan struct{}
+}
+
+// NewLevel returns a Level of class c.
+func NewLevel(c Class) *Level {
+	l := &Level{class: c, promoted: make(chan struct{})}
+	if c == Interactive {
+		close(l.promoted)
+	}
+	return l
+}
+
+// Class returns the current class; a nil Level is interactive.
+func (l *Level) Class() Class {
+	if l == nil {
+		return Interactive
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.class
+}
+
+// Promote makes the download interactive.
+func (l *Level) Promote() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.class != Interactive {
+		l.class = Interactive
+		close(l.promoted)
+	}
+}
+
+// Promoted returns a channel closed once the download is interactive. A nil
+// Level's is nil, as it never has to wait for promotion.
+func (l *Level) Promoted() <-chan struct{} {
+	if l == nil {
+		return nil
+	}
+	return l.promoted
+}
+
+type levelKey struct{}
+
+// WithLevel returns a context whose transfers have the class of l.
+func WithLevel(ctx context.Context, l *Level) context.Context {
+	return context.WithValue(ctx, levelKey{}, l)
+}
+
+// FromContext returns the Level of ctx, or nil for interactive work.
+func FromContext(ctx context.Context) *Level {
+	l, _ := ctx.Value(levelKey{}).(*Level)
+	return l
+}
+
+// Of returns the current class of ctx's transfers.
+func Of(ctx context.Context) Class {
+	return FromContext(ctx).Class()
+}
@@ -0,0 +1,43 @@
+package priority
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLevel(t *testing.T) {
+	ctx := context.Background()
+	if Of(ctx) != Interactive {
+		t.Errorf("unclassified work = %v, want interactive", Of(ctx))
+	}
+
+	l := NewLevel(Prefetch)
+	ctx = WithLevel(ctx, l)
+	if Of(ctx) != Prefetch || !Of(ctx).Background() {
+		t.Errorf("class = %v, want prefetch", Of(ctx))
+	}
+	select {
+	case <-l.Promoted():
+		t.Fatal("promoted before Promote")
+	default:
+	}
+
+	l.Promote()
+	l.Promote() // idempotent
+	if Of(ctx) != Interactive {
+		t.Errorf("promoted class = %v, want interactive", Of(ctx))
+	}
+	select {
+	case <-l.Promoted():
+	default:
+		t.Error("Promoted not closed after Promote")
+	}
+}
+
+func TestClassString(t *testing.T) {
+	for c, want := range map[Class]string{Interactive: "interactive", Replication: "replication", Prefetch: "prefetch"} {
+		if c.String() != want {
+			t.Errorf("%d.String() = %q, want %q", c, c.String(), want)
+		}
+	}
+}
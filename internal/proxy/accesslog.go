@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/accesslog"
+	"github.com/debswarm/debswarm/internal/downloader"
+	"github.com/debswarm/debswarm/internal/requestid"
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+// accessRecord collects what the access log says about a request as the
+// handlers serve it
+type accessRecord struct {
+	mu     sync.Mutex
+	url    string
+	class  string
+	source string
+	peers  []string
+}
+
+type accessRecordKey struct{}
+
+// accessRecordFrom returns the access log record of a request, or nil when
+// the access log is off
+func accessRecordFrom(ctx context.Context) *accessRecord {
+	rec, _ := ctx.Value(accessRecordKey{}).(*accessRecord)
+	return rec
+}
+
+// noteSource records where a response comes from for the access log. The
+// first source noted wins, so a step serving from the cache what it just
+// got from peers can note the peers first.
+func noteSource(ctx context.Context, source string) {
+	if rec := accessRecordFrom(ctx); rec != nil {
+		rec.mu.Lock()
+		if rec.source == "" {
+			rec.source = source
+		}
+		rec.mu.Unlock()
+	}
+}
+
+// noteClass records the class of a response once the mirror's Content-Type
+// has refined what its URL said
+func noteClass(ctx context.Context, class artifactClass) {
+	if rec := accessRecordFrom(ctx); rec != nil {
+		rec.mu.Lock()
+		rec.class = string(class)
+		rec.mu.Unlock()
+	}
+}
+
+// noteDownloadPeers records the peers that supplied chunks of a download
+func noteDownloadPeers(ctx context.Context, info downloader.DownloadInfo) {
+	rec := accessRecordFrom(ctx)
+	if rec == nil {
+		return
+	}
+	var ids []string
+	for _, c := range info.Chunks {
+		if c.Source == downloader.SourceTypePeer && c.ID != "" && !slices.Contains(ids, c.ID) {
+			ids = append(ids, c.ID)
+		}
+	}
+	rec.mu.Lock()
+	rec.peers = ids
+	rec.mu.Unlock()
+}
+
+// accessWriter captures the status and size of a response
+type accessWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (a *accessWriter) WriteHeader(status int) {
+	if a.status == 0 {
+		a.status = status
+	}
+	a.ResponseWriter.WriteHeader(status)
+}
+
+func (a *accessWriter) Write(p []byte) (int, error) {
+	if a.status == 0 {
+		a.status = http.StatusOK
+	}
+	n, err := a.ResponseWriter.Write(p)
+	a.bytes += int64(n)
+	return n, err
+}
+
+func (a *accessWriter) Flush() {
+	if f, ok := a.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (a *accessWriter) Unwrap() http.ResponseWriter { return a.ResponseWriter }
+
+// startAccessLog prepares a request for the access log, if it is on: it
+// returns the writer the response goes through, the request carrying the
+// record, and a function writing the entry once the request is served.
+func (s *Server) startAccessLog(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request, func()) {
+	if s.accessLog == nil {
+		return w, r, func() {}
+	}
+	start := time.Now()
+	aw := &accessWriter{ResponseWriter: w}
+	rec := &accessRecord{}
+	r = r.WithContext(context.WithValue(r.Context(), accessRecordKey{}, rec))
+	return aw, r, func() { s.logAccess(r, aw, rec, start) }
+}
+
+// logAccess writes the access log entry of a served request
+func (s *Server) logAccess(r *http.Request, aw *accessWriter, rec *accessRecord, start time.Time) {
+	status := aw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if !s.accessLog.Sampled(status) {
+		return
+	}
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+
+	rec.mu.Lock()
+	entry := accesslog.Entry{
+		Time:       start,
+		RequestID:  requestid.FromContext(r.Context()),
+		TraceID:    requestid.TraceIDFromContext(r.Context()),
+		Client:     client,
+		Method:     r.Method,
+		URL:        rec.url,
+		Class:      rec.class,
+		Source:     rec.source,
+		Status:     status,
+		Bytes:      aw.bytes,
+		DurationMS: time.Since(start).Milliseconds(),
+		Peers:      rec.peers,
+	}
+	rec.mu.Unlock()
+	if entry.URL == "" {
+		entry.URL = sanitize.URL(r.URL.String())
+	}
+	if src := aw.Header().Get("X-Debswarm-Source"); src != "" {
+		entry.Source = src
+	}
+	if status >= http.StatusBadRequest {
+		entry.Source = ""
+	}
+	s.accessLog.Log(entry)
+}
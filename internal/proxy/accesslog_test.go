@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/accesslog"
+	"github.com/debswarm/debswarm/internal/downloader"
+	"github.com/debswarm/debswarm/internal/hashutil"
+	"github.com/debswarm/debswarm/internal/security"
+)
+
+func TestAccessLog(t *testing.T) {
+	payload := []byte("access log payload")
+	hash := hashutil.HashBytes(payload)
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	policy, err := security.NewMirrorPolicy(security.PolicyConfig{AllowedCIDRs: []string{"127.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetMirrorPolicy(policy)
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	server.accessLog, err = accesslog.New(accesslog.Config{Path: path, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgPath := "pool/main/a/access/access_1.0_amd64.deb"
+	packages := fmt.Sprintf("Package: access\nFilename: %s\nSize: %d\nSHA256: %s\n\n", pkgPath, len(payload), hash)
+	if err := server.index.LoadFromData([]byte(packages), mockMirror.URL+"/dists/stable/main/binary-amd64/Packages"); err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+	get := func(url string) int {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/"+url, nil)
+		req.RemoteAddr = "192.0.2.7:40000"
+		w := httptest.NewRecorder()
+		server.handleRequest(w, req)
+		return w.Code
+	}
+	get(mockMirror.URL + "/" + pkgPath)
+	get(mockMirror.URL + "/" + pkgPath)
+	if code := get("no-url"); code != http.StatusBadRequest {
+		t.Fatalf("unparsable request: status %d, want 400", code)
+	}
+	if err := server.accessLog.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []accesslog.Entry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e accesslog.Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("entries = %d, want 3", len(entries))
+	}
+
+	first, second, bad := entries[0], entries[1], entries[2]
+	if first.Class != "package" || first.Source != "mirror" || first.Status != http.StatusOK ||
+		first.Bytes != int64(len(payload)) || first.Client != "192.0.2.7" || first.RequestID == "" {
+		t.Errorf("mirror download = %+v", first)
+	}
+	if second.Source != "cache" || second.Bytes != int64(len(payload)) {
+		t.Errorf("cache hit = %+v", second)
+	}
+	if bad.Status != http.StatusBadRequest || bad.Source != "" || bad.Class != "" {
+		t.Errorf("bad request = %+v", bad)
+	}
+}
+
+func TestNoteDownloadPeers(t *testing.T) {
+	rec := &accessRecord{}
+	ctx := context.WithValue(context.Background(), accessRecordKey{}, rec)
+	noteDownloadPeers(ctx, downloader.DownloadInfo{Chunks: []downloader.ChunkProgress{
+		{Source: downloader.SourceTypePeer, ID: "peerA"},
+		{Source: downloader.SourceTypeMirror, ID: "http://deb.debian.org/debian/pool/x.deb"},
+		{Source: downloader.SourceTypePeer, ID: "peerB"},
+		{Source: downloader.SourceTypePeer, ID: "peerA"},
+	}})
+	if !slices.Equal(rec.peers, []string{"peerA", "peerB"}) {
+		t.Errorf("peers = %v, want [peerA peerB]", rec.peers)
+	}
+
+	// Without a record, e.g. with the access log off, nothing happens
+	noteDownloadPeers(context.Background(), downloader.DownloadInfo{})
+	noteSource(context.Background(), "cache")
+}
@@ -3,15 +3,21 @@ package proxy
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"net"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"go.uber.org/zap"
 
 	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/p2p"
+	"github.com/debswarm/debswarm/internal/peers"
 )
 
 // API response types
@@ -58,6 +64,103 @@ type apiPackageList struct {
 	Total    int           `json:"total"`
 }
 
+type apiPeer struct {
+	ID                  string   `json:"id"`
+	Name                string   `json:"name,omitempty"`
+	Tags                []string `json:"tags,omitempty"`
+	Score               float64  `json:"score"`
+	Category            string   `json:"category"`
+	Version             string   `json:"version,omitempty"`
+	MDNS                bool     `json:"mdns"`
+	Blacklisted         bool     `json:"blacklisted"`
+	Breaker             string   `json:"breaker"`
+	ConsecutiveFailures int      `json:"consecutive_failures"`
+	RetryAt             string   `json:"retry_at,omitempty"` // when an open breaker admits its next probe
+	LastSeen            string   `json:"last_seen"`
+}
+
+// apiConnectedPeer is a peer with open connections, for GET
+// /api/peers/connections
+type apiConnectedPeer struct {
+	ID             string          `json:"id"`
+	Name           string          `json:"name,omitempty"`
+	Score          float64         `json:"score"`
+	Category       string          `json:"category"`
+	Version        string          `json:"version,omitempty"`
+	ConnectedSince string          `json:"connected_since"` // oldest open connection
+	Uploads        int             `json:"uploads"`         // transfers in progress to the peer
+	Downloads      int             `json:"downloads"`       // transfers in progress from the peer
+	Connections    []apiConnection `json:"connections"`
+}
+
+type apiConnection struct {
+	Addr      string `json:"addr"`
+	Transport string `json:"transport"` // quic, tcp, webtransport, websocket or relay
+	Direction string `json:"direction"` // inbound or outbound
+	Opened    string `json:"opened"`
+}
+
+// apiPeerExplanation breaks down a peer's score: each component's value,
+// weight and contribution, the decay toward neutral, and the raw stats
+// behind them.
+type apiPeerExplanation struct {
+	ID            string                `json:"id"`
+	Name          string                `json:"name,omitempty"`
+	Score         float64               `json:"score"`
+	Category      string                `json:"category"`
+	Basis         string                `json:"basis"` // measured, few_samples, probed or blacklisted
+	ScoreCachedAt string                `json:"score_cached_at,omitempty"`
+	Samples       int64                 `json:"samples"`
+	Measured      float64               `json:"measured"` // weighted components, before decay
+	Decay         float64               `json:"decay"`    // share of the distance from neutral left
+	DecayHalfLife string                `json:"decay_half_life"`
+	LastTransfer  string                `json:"last_transfer,omitempty"`
+	Components    []apiScoreComponent   `json:"components"`
+	Stats         apiPeerExplainedStats `json:"stats"`
+}
+
+type apiScoreComponent struct {
+	Name         string  `json:"name"`
+	Value        float64 `json:"value"`
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"`
+}
+
+type apiPeerExplainedStats struct {
+	AvgLatencyMs        float64 `json:"avg_latency_ms"`
+	AvgThroughput       float64 `json:"avg_throughput"`
+	SuccessRate         float64 `json:"success_rate"`
+	Successes           int64   `json:"successes"`
+	Failures            int64   `json:"failures"`
+	BytesDownloaded     int64   `json:"bytes_downloaded"`
+	BytesUploaded       int64   `json:"bytes_uploaded"`
+	MDNS                bool    `json:"mdns"`
+	BlacklistReason     string  `json:"blacklist_reason,omitempty"`
+	BlacklistUntil      string  `json:"blacklist_until,omitempty"`
+	Breaker             string  `json:"breaker"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	LastSeen            string  `json:"last_seen"`
+	ProbedLatencyMs     float64 `json:"probed_latency_ms,omitempty"`
+	ProbedThroughput    float64 `json:"probed_throughput,omitempty"`
+	ProbedAt            string  `json:"probed_at,omitempty"`
+}
+
+// apiProbeResult is the outcome of POST /api/peers/{id}/probe
+type apiProbeResult struct {
+	ID         string  `json:"id"`
+	Bytes      int64   `json:"bytes"`
+	LatencyMs  float64 `json:"latency_ms"`
+	DurationMs float64 `json:"duration_ms"`
+	Throughput float64 `json:"throughput"` // bytes per second
+	Score      float64 `json:"score"`      // the peer's score with the probe recorded
+}
+
+type apiP2PState struct {
+	Paused bool   `json:"paused"`
+	Since  string `json:"since,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
 // registerAPIRoutes registers all cache management REST API routes on the given mux.
 // Mutating endpoints are restricted to loopback clients: the metrics server may be
 // bound to a non-local address (for dashboard/metrics access), and these endpoints
@@ -70,6 +173,34 @@ func (s *Server) registerAPIRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /api/cache/packages/{hash}/pin", requireLoopback(s.handleAPIPinPackage))
 	mux.HandleFunc("POST /api/cache/packages/{hash}/unpin", requireLoopback(s.handleAPIUnpinPackage))
 	mux.HandleFunc("DELETE /api/cache/packages/{hash}", requireLoopback(s.handleAPIDeletePackage))
+	mux.HandleFunc("GET /api/peers", s.handleAPIPeers)
+	mux.HandleFunc("GET /api/peers/accounting", s.handleAPIPeerAccounting)
+	mux.HandleFunc("GET /api/stats/packages", s.handleAPIPackageStats)
+	mux.HandleFunc("GET /api/clients", s.handleAPIClients)
+	mux.HandleFunc("GET /api/peers/connections", s.handleAPIPeerConnections)
+	mux.HandleFunc("GET /api/peers/labels", s.handleAPIPeerLabels)
+	mux.HandleFunc("GET /api/peers/bans", s.handleAPIPeerBans)
+	mux.HandleFunc("GET /api/peers/receipts", s.handleAPIReceipts)
+	mux.HandleFunc("GET /api/peers/{id}/explain", s.handleAPIExplainPeer)
+	mux.HandleFunc("PUT /api/peers/{id}/label", requireLoopback(s.handleAPISetPeerLabel))
+	mux.HandleFunc("DELETE /api/peers/{id}/ban", requireLoopback(s.handleAPIPardonPeer))
+	mux.HandleFunc("POST /api/peers/{id}/probe", requireLoopback(s.handleAPIProbePeer))
+	mux.HandleFunc("POST /api/apt/import", requireLoopback(s.handleAPIAPTImport))
+	mux.HandleFunc("GET /api/config", requireLoopback(s.handleAPIConfig))
+	mux.HandleFunc("GET /api/p2p", s.handleAPIP2PState)
+	mux.HandleFunc("GET /api/events", s.handleAPIEvents)
+	mux.HandleFunc("POST /api/p2p/pause", requireLoopback(s.handleAPIP2PPause))
+	mux.HandleFunc("POST /api/p2p/resume", requireLoopback(s.handleAPIP2PResume))
+	mux.HandleFunc("GET /api/scheduler", s.handleAPIScheduler)
+	mux.HandleFunc("POST /api/scheduler/windows", requireLoopback(s.handleAPIAddScheduleWindow))
+	mux.HandleFunc("POST /api/scheduler/exceptions", requireLoopback(s.handleAPIAddScheduleException))
+	mux.HandleFunc("DELETE /api/scheduler/{id}", requireLoopback(s.handleAPIRemoveScheduleEntry))
+	mux.HandleFunc("GET /api/prefetch", s.handleAPIPrefetch)
+	mux.HandleFunc("POST /api/prefetch/groups", requireLoopback(s.handleAPIPutPrefetchGroup))
+	mux.HandleFunc("DELETE /api/prefetch/groups/{name}", requireLoopback(s.handleAPIRemovePrefetchGroup))
+	mux.HandleFunc("POST /api/prefetch/run", requireLoopback(s.handleAPIRunPrefetch))
+	mux.HandleFunc("POST /api/offline/check", requireLoopback(s.handleAPIOfflineCheck))
+	mux.HandleFunc("POST /api/simulate", requireLoopback(s.handleAPISimulate))
 }
 
 // requireLoopback rejects requests from non-loopback clients with 403.
@@ -330,3 +461,378 @@ func (s *Server) handleAPIDeletePackage(w http.ResponseWriter, r *http.Request)
 
 	writeJSON(w, http.StatusOK, apiOK{OK: true, Message: "package deleted"})
 }
+
+// POST /api/apt/import
+//
+// Called by the APT hook (debswarm apt enable) after each dpkg run. The scan
+// of APT's archives directory runs in the background so the hook never holds
+// up apt: packages APT fetched without the proxy are imported and announced.
+// Requests arriving while a scan is already waiting to start are coalesced
+// into it.
+func (s *Server) handleAPIAPTImport(w http.ResponseWriter, r *http.Request) {
+	if s.aptImporter == nil {
+		writeError(w, http.StatusServiceUnavailable, "APT archives import is not available")
+		return
+	}
+
+	if s.aptImportQueued.CompareAndSwap(false, true) {
+		go s.runAPTImport()
+	}
+	writeJSON(w, http.StatusAccepted, apiOK{OK: true, Message: "APT archives import queued"})
+}
+
+func (s *Server) runAPTImport() {
+	s.aptImportMu.Lock()
+	defer s.aptImportMu.Unlock()
+	s.aptImportQueued.Store(false)
+
+	result, err := s.aptImporter.Import(s.announceCtx)
+	if err != nil {
+		s.logger.Warn("Failed to import APT archives", zap.Error(err))
+		return
+	}
+	for _, hash := range result.Hashes {
+		s.announceAsync(hash)
+	}
+}
+
+// GET /api/config
+//
+// Serves the configuration the daemon is running with as TOML, so
+// 'debswarm config diff' can compare it with the file on disk. Loopback only:
+// it reveals paths and network layout.
+func (s *Server) handleAPIConfig(w http.ResponseWriter, r *http.Request) {
+	if s.configSource == nil {
+		writeError(w, http.StatusServiceUnavailable, "active configuration is not available")
+		return
+	}
+	data, err := s.configSource()
+	if err != nil {
+		s.logger.Warn("Failed to render active configuration", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "failed to render configuration")
+		return
+	}
+	w.Header().Set("Content-Type", "application/toml")
+	_, _ = w.Write(data)
+}
+
+// GET /api/peers
+func (s *Server) handleAPIPeers(w http.ResponseWriter, r *http.Request) {
+	if s.scorer == nil {
+		writeJSON(w, http.StatusOK, []*apiPeer{})
+		return
+	}
+	stats := s.scorer.GetAllStats()
+	result := make([]*apiPeer, 0, len(stats))
+	for _, ps := range stats {
+		score := s.scorer.GetScore(ps.PeerID)
+		label := s.scorer.Label(ps.PeerID)
+		p := &apiPeer{
+			ID:                  ps.PeerID.String(),
+			Name:                label.Name,
+			Tags:                label.Tags,
+			Score:               score,
+			Category:            peers.ScoreCategory(score),
+			MDNS:                ps.IsMDNSPeer,
+			Blacklisted:         ps.Blacklisted && time.Now().Before(ps.BlacklistUntil),
+			Breaker:             ps.Breaker.String(),
+			ConsecutiveFailures: ps.ConsecutiveFailures,
+			LastSeen:            ps.LastSeen.UTC().Format(time.RFC3339),
+		}
+		if ps.Breaker == peers.BreakerOpen {
+			p.RetryAt = ps.BreakerUntil.UTC().Format(time.RFC3339)
+		}
+		if s.p2pNode != nil {
+			p.Version = peerVersion(s.p2pNode.PeerCapabilities(ps.PeerID))
+		}
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Score > result[j].Score })
+	writeJSON(w, http.StatusOK, result)
+}
+
+// GET /api/peers/connections
+func (s *Server) handleAPIPeerConnections(w http.ResponseWriter, r *http.Request) {
+	if s.p2pNode == nil {
+		writeJSON(w, http.StatusOK, []*apiConnectedPeer{})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.connectedPeers(s.p2pNode.Connections()))
+}
+
+// connectedPeers describes live connections with each peer's label and score
+func (s *Server) connectedPeers(conns []p2p.PeerConnections) []*apiConnectedPeer {
+	result := make([]*apiConnectedPeer, 0, len(conns))
+	for _, pc := range conns {
+		p := &apiConnectedPeer{
+			ID:             pc.ID.String(),
+			ConnectedSince: pc.Since().UTC().Format(time.RFC3339),
+			Uploads:        pc.Uploads,
+			Downloads:      pc.Downloads,
+			Connections:    make([]apiConnection, 0, len(pc.Connections)),
+		}
+		if s.scorer != nil {
+			p.Name = s.scorer.Name(pc.ID)
+			p.Score = s.scorer.GetScore(pc.ID)
+			p.Category = peers.ScoreCategory(p.Score)
+		}
+		if s.p2pNode != nil {
+			p.Version = peerVersion(s.p2pNode.PeerCapabilities(pc.ID))
+		}
+		for _, c := range pc.Connections {
+			addr := ""
+			if c.Remote != nil {
+				addr = c.Remote.String()
+			}
+			p.Connections = append(p.Connections, apiConnection{
+				Addr:      addr,
+				Transport: c.Transport,
+				Direction: c.Direction,
+				Opened:    c.Opened.UTC().Format(time.RFC3339),
+			})
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// GET /api/peers/{id}/explain
+//
+// Why a peer has its current score.
+func (s *Server) handleAPIExplainPeer(w http.ResponseWriter, r *http.Request) {
+	id, err := peer.Decode(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid peer ID")
+		return
+	}
+	var b *peers.ScoreBreakdown
+	var ps *peers.PeerScore
+	if s.scorer != nil {
+		b, ps = s.scorer.Breakdown(id), s.scorer.GetStats(id)
+	}
+	if b == nil || ps == nil {
+		writeError(w, http.StatusNotFound, "peer not known")
+		return
+	}
+	writeJSON(w, http.StatusOK, explainPeer(b, ps, s.scorer.Label(id), s.scorer.ScoringConfig()))
+}
+
+func explainPeer(b *peers.ScoreBreakdown, ps *peers.PeerScore, label peers.Label, scoring peers.ScoringConfig) *apiPeerExplanation {
+	e := &apiPeerExplanation{
+		ID:            b.PeerID.String(),
+		Name:          label.Name,
+		Score:         b.Score,
+		Category:      peers.ScoreCategory(b.Score),
+		Basis:         b.Basis,
+		Samples:       b.Samples,
+		Measured:      b.Measured,
+		Decay:         b.Decay,
+		DecayHalfLife: scoring.DecayHalfLife.String(),
+		Stats: apiPeerExplainedStats{
+			AvgLatencyMs:        ps.AvgLatencyMs,
+			AvgThroughput:       ps.AvgThroughput,
+			SuccessRate:         ps.SuccessRate,
+			Successes:           ps.SuccessCount,
+			Failures:            ps.FailureCount,
+			BytesDownloaded:     ps.BytesDownloaded,
+			BytesUploaded:       ps.BytesUploaded,
+			MDNS:                ps.IsMDNSPeer,
+			Breaker:             ps.Breaker.String(),
+			ConsecutiveFailures: ps.ConsecutiveFailures,
+			LastSeen:            ps.LastSeen.UTC().Format(time.RFC3339),
+		},
+	}
+	if !b.CachedAt.IsZero() {
+		e.ScoreCachedAt = b.CachedAt.UTC().Format(time.RFC3339)
+	}
+	if !b.LastTransfer.IsZero() {
+		e.LastTransfer = b.LastTransfer.UTC().Format(time.RFC3339)
+	}
+	if !ps.ProbedAt.IsZero() {
+		e.Stats.ProbedLatencyMs = ps.ProbedLatencyMs
+		e.Stats.ProbedThroughput = ps.ProbedThroughput
+		e.Stats.ProbedAt = ps.ProbedAt.UTC().Format(time.RFC3339)
+	}
+	if b.Basis == peers.BasisBlacklisted {
+		e.Stats.BlacklistReason = ps.BlacklistReason
+		e.Stats.BlacklistUntil = ps.BlacklistUntil.UTC().Format(time.RFC3339)
+	}
+	c, wt := b.Components, b.Weights
+	for _, comp := range []struct {
+		name          string
+		value, weight float64
+	}{
+		{"latency", c.Latency, wt.Latency},
+		{"throughput", c.Throughput, wt.Throughput},
+		{"reliability", c.Reliability, wt.Reliability},
+		{"freshness", c.Freshness, wt.Freshness},
+		{"proximity", c.Proximity, wt.Proximity},
+		{"reciprocity", c.Reciprocity, wt.Reciprocity},
+	} {
+		e.Components = append(e.Components, apiScoreComponent{
+			Name:         comp.name,
+			Value:        comp.value,
+			Weight:       comp.weight,
+			Contribution: comp.value * comp.weight,
+		})
+	}
+	return e
+}
+
+// GET /api/peers/accounting?since=YYYY-MM-DD
+//
+// Per-peer byte totals from the transfer ledger, from the given UTC day
+// (default: the last 30 days).
+func (s *Server) handleAPIPeerAccounting(w http.ResponseWriter, r *http.Request) {
+	since := time.Now().AddDate(0, 0, -30)
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "since must be a date (YYYY-MM-DD)")
+			return
+		}
+		since = t
+	}
+	result, err := s.cache.PeerAccounting(since)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// GET /api/peers/labels
+//
+// All stored peer labels, including those of peers not seen this run.
+func (s *Server) handleAPIPeerLabels(w http.ResponseWriter, r *http.Request) {
+	labels, err := s.cache.PeerLabels()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, labels)
+}
+
+// PUT /api/peers/{id}/label with {"name": "...", "tags": [...]}
+//
+// Replaces a peer's label; an empty name and tag list removes it.
+func (s *Server) handleAPISetPeerLabel(w http.ResponseWriter, r *http.Request) {
+	id, err := peer.Decode(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid peer ID")
+		return
+	}
+	var label peers.Label
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&label); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid label: "+err.Error())
+		return
+	}
+	label, err = peers.NormalizeLabel(label)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.cache.SetPeerLabel(cache.PeerLabel{PeerID: id.String(), Name: label.Name, Tags: label.Tags}); err != nil {
+		s.logger.Error("Failed to store peer label", zap.String("peer", id.String()), zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "failed to store peer label")
+		return
+	}
+	if s.scorer != nil {
+		s.scorer.SetLabel(id, label)
+	}
+	s.logger.Info("Peer label updated",
+		zap.String("peer", id.String()),
+		zap.String("name", label.Name),
+		zap.Strings("tags", label.Tags))
+	writeJSON(w, http.StatusOK, cache.PeerLabel{PeerID: id.String(), Name: label.Name, Tags: label.Tags})
+}
+
+// POST /api/peers/{id}/probe
+//
+// Measures the bandwidth to a peer with a short transfer and records it with
+// the scorer. The peer is dialed if not connected, through the first node
+// (of several swarms) that is connected to it.
+func (s *Server) handleAPIProbePeer(w http.ResponseWriter, r *http.Request) {
+	id, err := peer.Decode(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid peer ID")
+		return
+	}
+	if s.p2pNode == nil {
+		writeError(w, http.StatusServiceUnavailable, "P2P node is not running")
+		return
+	}
+	node := s.p2pNode
+	for _, n := range s.allNodes() {
+		if n.Host().Network().Connectedness(id) == network.Connected {
+			node = n
+			break
+		}
+	}
+	res, err := node.Probe(r.Context(), id)
+	switch {
+	case errors.Is(err, p2p.ErrPaused):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, apiProbeResult{
+		ID:         id.String(),
+		Bytes:      res.Bytes,
+		LatencyMs:  float64(res.Latency.Microseconds()) / 1000,
+		DurationMs: float64(res.Duration.Microseconds()) / 1000,
+		Throughput: res.Throughput,
+		Score:      node.Scorer().GetScore(id),
+	})
+}
+
+// GET /api/p2p
+func (s *Server) handleAPIP2PState(w http.ResponseWriter, r *http.Request) {
+	if s.p2pNode == nil {
+		writeError(w, http.StatusServiceUnavailable, "P2P node is not running")
+		return
+	}
+	writeJSON(w, http.StatusOK, p2pStateResponse(s.p2pNode.PauseState()))
+}
+
+// POST /api/p2p/pause?reason=...
+//
+// Kill switch: stops uploads (resetting running ones), announcements and new
+// P2P downloads at once; package requests fall back to the cache and mirror.
+func (s *Server) handleAPIP2PPause(w http.ResponseWriter, r *http.Request) {
+	if s.p2pNode == nil {
+		writeError(w, http.StatusServiceUnavailable, "P2P node is not running")
+		return
+	}
+	reason := strings.TrimSpace(r.URL.Query().Get("reason"))
+	if len(reason) > 200 {
+		reason = reason[:200]
+	}
+	for _, node := range s.allNodes() {
+		node.Pause(reason)
+	}
+	writeJSON(w, http.StatusOK, p2pStateResponse(s.p2pNode.PauseState()))
+}
+
+// POST /api/p2p/resume
+func (s *Server) handleAPIP2PResume(w http.ResponseWriter, r *http.Request) {
+	if s.p2pNode == nil {
+		writeError(w, http.StatusServiceUnavailable, "P2P node is not running")
+		return
+	}
+	for _, node := range s.allNodes() {
+		node.Resume()
+	}
+	writeJSON(w, http.StatusOK, p2pStateResponse(s.p2pNode.PauseState()))
+}
+
+func p2pStateResponse(st p2p.PauseState) apiP2PState {
+	resp := apiP2PState{Paused: st.Paused, Reason: st.Reason}
+	if st.Paused {
+		resp.Since = st.Since.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
@@ -1,14 +1,28 @@
 package proxy
 
 import (
+	"bytes"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/debswarm/debswarm/internal/aptarchives"
+	"github.com/debswarm/debswarm/internal/p2p"
+	"github.com/debswarm/debswarm/internal/peers"
 )
 
 // testPkg is a helper that inserts a package into the test cache and returns its hash.
@@ -426,3 +440,302 @@ func TestIsValidSHA256(t *testing.T) {
 		})
 	}
 }
+
+func TestAPIPeers_BreakerState(t *testing.T) {
+	s := newTestServer(t)
+	s.scorer.SetBreakerConfig(peers.BreakerConfig{FailureThreshold: 2, Backoff: time.Minute, MaxBackoff: time.Minute})
+	dead := peer.ID("dead-peer")
+	good := peer.ID("good-peer")
+	s.scorer.RecordFailure(dead, "connect failed")
+	s.scorer.RecordFailure(dead, "connect failed")
+	s.scorer.RecordSuccess(good, 1024, 10, 1<<20)
+
+	w := httptest.NewRecorder()
+	s.handleAPIPeers(w, httptest.NewRequest("GET", "/api/peers", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var list []apiPeer
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	byID := make(map[string]apiPeer, len(list))
+	for _, p := range list {
+		byID[p.ID] = p
+	}
+	if p := byID[dead.String()]; p.Breaker != "open" || p.ConsecutiveFailures != 2 || p.RetryAt == "" {
+		t.Errorf("dead peer = %+v, want open breaker with retry time", p)
+	}
+	if p := byID[good.String()]; p.Breaker != "closed" || p.RetryAt != "" {
+		t.Errorf("good peer = %+v, want closed breaker", p)
+	}
+}
+
+func TestAPISetPeerLabel(t *testing.T) {
+	s := newTestServer(t)
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.scorer.RecordSuccess(id, 1024, 10, 1<<20)
+
+	put := func(peerID, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("PUT", "/api/peers/"+peerID+"/label", bytes.NewBufferString(body))
+		r.SetPathValue("id", peerID)
+		w := httptest.NewRecorder()
+		s.handleAPISetPeerLabel(w, r)
+		return w
+	}
+	if w := put("not-a-peer", `{"name":"x"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid peer ID: status = %d", w.Code)
+	}
+	if w := put(id.String(), `{"tags":["bad tag"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid tag: status = %d", w.Code)
+	}
+	if w := put(id.String(), `{"name":"rack3-seedbox","tags":["Seedbox"]}`); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	// The label shows in /api/peers and is stored
+	w := httptest.NewRecorder()
+	s.handleAPIPeers(w, httptest.NewRequest("GET", "/api/peers", nil))
+	var list []apiPeer
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "rack3-seedbox" || len(list[0].Tags) != 1 || list[0].Tags[0] != "seedbox" {
+		t.Errorf("peers = %+v", list)
+	}
+	stored, err := s.cache.PeerLabels()
+	if err != nil || len(stored) != 1 || stored[0].PeerID != id.String() {
+		t.Errorf("stored labels = %+v, %v", stored, err)
+	}
+
+	// An empty label removes it
+	if w := put(id.String(), `{}`); w.Code != http.StatusOK {
+		t.Fatalf("clear: status = %d", w.Code)
+	}
+	if s.scorer.Name(id) != "" {
+		t.Error("label not cleared in the scorer")
+	}
+	if stored, _ := s.cache.PeerLabels(); len(stored) != 0 {
+		t.Errorf("label not deleted: %+v", stored)
+	}
+}
+
+func TestAPIAPTImport_Unavailable(t *testing.T) {
+	s := newTestServer(t)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/apt/import", nil)
+	s.handleAPIAPTImport(w, r)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestAPIAPTImport_ImportsArchives(t *testing.T) {
+	s := newTestServer(t)
+
+	archivesDir := t.TempDir()
+	content := "package APT fetched directly"
+	h := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(h[:])
+	if err := os.WriteFile(filepath.Join(archivesDir, "direct_1.0_amd64.deb"), []byte(content), 0644); err != nil {
+		t.Fatalf("write deb: %v", err)
+	}
+	packages := filepath.Join(t.TempDir(), "Packages")
+	entry := "Package: direct\nVersion: 1.0\nArchitecture: amd64\n" +
+		"Filename: pool/main/d/direct/direct_1.0_amd64.deb\nSHA256: " + hash + "\n\n"
+	if err := os.WriteFile(packages, []byte(entry), 0644); err != nil {
+		t.Fatalf("write Packages: %v", err)
+	}
+	if err := s.index.LoadFromFile(packages); err != nil {
+		t.Fatalf("load Packages: %v", err)
+	}
+	s.SetAPTImporter(aptarchives.New(s.cache, s.index, s.logger, &aptarchives.Config{ArchivesPath: archivesDir}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/apt/import", nil)
+	s.handleAPIAPTImport(w, r)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d; body: %s", w.Code, http.StatusAccepted, w.Body.String())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !s.cache.Has(hash) {
+		if time.Now().After(deadline) {
+			t.Fatal("package was not imported into the cache")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAPIConfig(t *testing.T) {
+	s := newTestServer(t)
+
+	w := httptest.NewRecorder()
+	s.handleAPIConfig(w, httptest.NewRequest("GET", "/api/config", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status without source = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	s.SetConfigSource(func() ([]byte, error) { return []byte("[cache]\nmax_size = \"10GB\"\n"), nil })
+	w = httptest.NewRecorder()
+	s.handleAPIConfig(w, httptest.NewRequest("GET", "/api/config", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "max_size") {
+		t.Fatalf("status = %d, body = %q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/toml" {
+		t.Errorf("Content-Type = %q, want application/toml", ct)
+	}
+}
+
+func TestAPIP2P_NoNode(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, h := range []http.HandlerFunc{s.handleAPIP2PState, s.handleAPIP2PPause, s.handleAPIP2PResume} {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("POST", "/api/p2p/pause", nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("status without P2P node = %d, want %d", w.Code, http.StatusServiceUnavailable)
+		}
+	}
+}
+
+func TestAPIProbePeer_NoNode(t *testing.T) {
+	s := newTestServer(t)
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := peer.IDFromPrivateKey(key)
+
+	for peerID, want := range map[string]int{"not-a-peer": http.StatusBadRequest, id.String(): http.StatusServiceUnavailable} {
+		r := httptest.NewRequest("POST", "/api/peers/"+peerID+"/probe", nil)
+		r.SetPathValue("id", peerID)
+		w := httptest.NewRecorder()
+		s.handleAPIProbePeer(w, r)
+		if w.Code != want {
+			t.Errorf("probe %s: status = %d, want %d", peerID, w.Code, want)
+		}
+	}
+}
+
+func TestP2PStateResponse(t *testing.T) {
+	since := time.Date(2026, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	got := p2pStateResponse(p2p.PauseState{Paused: true, Since: since, Reason: "metered link"})
+	if !got.Paused || got.Since != "2026-03-01T11:00:00Z" || got.Reason != "metered link" {
+		t.Errorf("paused state = %+v", got)
+	}
+	if got := p2pStateResponse(p2p.PauseState{}); got.Paused || got.Since != "" {
+		t.Errorf("active state = %+v", got)
+	}
+}
+
+func TestAPIExplainPeer(t *testing.T) {
+	s := newTestServer(t)
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < peers.MinSamples; i++ {
+		s.scorer.RecordSuccess(id, 1024, 100, 10*1024*1024)
+	}
+	s.scorer.SetLabel(id, peers.Label{Name: "rack3-seedbox"})
+
+	get := func(peerID string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/peers/"+peerID+"/explain", nil)
+		r.SetPathValue("id", peerID)
+		w := httptest.NewRecorder()
+		s.handleAPIExplainPeer(w, r)
+		return w
+	}
+	if w := get("not-a-peer"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid peer ID: status = %d", w.Code)
+	}
+	other, _, _ := crypto.GenerateEd25519Key(rand.Reader)
+	otherID, _ := peer.IDFromPrivateKey(other)
+	if w := get(otherID.String()); w.Code != http.StatusNotFound {
+		t.Errorf("unknown peer: status = %d", w.Code)
+	}
+
+	w := get(id.String())
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var e apiPeerExplanation
+	if err := json.NewDecoder(w.Body).Decode(&e); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if e.Name != "rack3-seedbox" || e.Basis != peers.BasisMeasured || e.Stats.Successes != int64(peers.MinSamples) {
+		t.Errorf("explanation = %+v", e)
+	}
+	if len(e.Components) != 6 {
+		t.Fatalf("components = %+v", e.Components)
+	}
+	sum, weights := 0.0, 0.0
+	for _, c := range e.Components {
+		sum += c.Contribution
+		weights += c.Weight
+	}
+	if math.Abs(sum-e.Measured) > 1e-9 || math.Abs(weights-1) > 1e-9 {
+		t.Errorf("contributions sum to %v (measured %v), weights to %v", sum, e.Measured, weights)
+	}
+	if math.Abs(e.Score-(0.5+(e.Measured-0.5)*e.Decay)) > 1e-9 {
+		t.Errorf("score %v does not follow from measured %v and decay %v", e.Score, e.Measured, e.Decay)
+	}
+}
+
+func TestAPIPeerConnections(t *testing.T) {
+	s := newTestServer(t)
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.scorer.RecordSuccess(id, 1024, 10, 1<<20)
+	s.scorer.SetLabel(id, peers.Label{Name: "rack3-seedbox"})
+
+	// Without a P2P node there are no connections
+	w := httptest.NewRecorder()
+	s.handleAPIPeerConnections(w, httptest.NewRequest("GET", "/api/peers/connections", nil))
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("no node: status %d, body %q", w.Code, w.Body.String())
+	}
+
+	opened := time.Now().Add(-time.Hour)
+	list := s.connectedPeers([]p2p.PeerConnections{{
+		ID: id,
+		Connections: []p2p.Connection{{
+			Remote:    multiaddr.StringCast("/ip4/192.168.1.20/udp/4001/quic-v1"),
+			Transport: "quic",
+			Direction: "inbound",
+			Opened:    opened,
+		}},
+		Uploads: 2,
+	}})
+	if len(list) != 1 {
+		t.Fatalf("%d peers, want 1", len(list))
+	}
+	p := list[0]
+	if p.Name != "rack3-seedbox" || p.Score <= 0 || p.Uploads != 2 || p.ConnectedSince != opened.UTC().Format(time.RFC3339) {
+		t.Errorf("peer = %+v", p)
+	}
+	if len(p.Connections) != 1 || p.Connections[0].Addr != "/ip4/192.168.1.20/udp/4001/quic-v1" || p.Connections[0].Direction != "inbound" {
+		t.Errorf("connections = %+v", p.Connections)
+	}
+}
@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/audit"
+	"github.com/debswarm/debswarm/internal/peers"
+)
+
+// strikePeer gives a strike to a peer that served data failing
+// verification. The scorer bans it for longer on each repeat offense, and
+// for good after enough of them.
+func (s *Server) strikePeer(id peer.ID, reason string) peers.Ban {
+	ban := s.scorer.Strike(id, reason)
+	s.metrics.PeersBlacklisted.Inc()
+	s.logger.Warn("Peer banned",
+		zap.String("peer", id.String()),
+		zap.String("reason", reason),
+		zap.Int("strikes", ban.Strikes),
+		zap.Bool("permanent", ban.Permanent),
+		zap.Time("until", ban.Until))
+	return ban
+}
+
+// banReason describes a ban for the audit log
+func banReason(b peers.Ban) string {
+	if b.Permanent {
+		return fmt.Sprintf("%s (strike %d, banned permanently)", b.Reason, b.Strikes)
+	}
+	return fmt.Sprintf("%s (strike %d, banned until %s)", b.Reason, b.Strikes, b.Until.UTC().Format(time.RFC3339))
+}
+
+// apiPeerBan is a peer's strikes and ban in /api/peers/bans
+type apiPeerBan struct {
+	PeerID     string `json:"peer_id"`
+	Name       string `json:"name,omitempty"`
+	Strikes    int    `json:"strikes"`
+	Reason     string `json:"reason,omitempty"`
+	LastStrike string `json:"last_strike"`
+	Until      string `json:"until,omitempty"`
+	Permanent  bool   `json:"permanent"`
+	Active     bool   `json:"active"`
+}
+
+// GET /api/peers/bans
+//
+// Peers with strikes, bans in force first. A peer whose ban is over keeps
+// its strikes until they expire, so a further offense bans it for longer.
+func (s *Server) handleAPIPeerBans(w http.ResponseWriter, r *http.Request) {
+	result := []apiPeerBan{}
+	if s.scorer == nil {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	now := time.Now()
+	for _, b := range s.scorer.Bans() {
+		ban := apiPeerBan{
+			PeerID:     b.PeerID.String(),
+			Name:       s.scorer.Name(b.PeerID),
+			Strikes:    b.Strikes,
+			Reason:     b.Reason,
+			LastStrike: b.LastStrike.UTC().Format(time.RFC3339),
+			Permanent:  b.Permanent,
+			Active:     b.Active(now),
+		}
+		if !b.Permanent {
+			ban.Until = b.Until.UTC().Format(time.RFC3339)
+		}
+		result = append(result, ban)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// DELETE /api/peers/{id}/ban
+//
+// Pardons a peer: lifts its ban, even a permanent one, and forgets its
+// strikes.
+func (s *Server) handleAPIPardonPeer(w http.ResponseWriter, r *http.Request) {
+	id, err := peer.Decode(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid peer ID")
+		return
+	}
+	if s.scorer == nil || !s.scorer.Pardon(id) {
+		writeError(w, http.StatusNotFound, "peer is not banned")
+		return
+	}
+	s.logger.Info("Peer pardoned", zap.String("peer", id.String()))
+	s.audit.Log(audit.NewPeerPardonedEvent(id.String()).WithPeerName(s.scorer.Name(id)))
+	writeJSON(w, http.StatusOK, apiOK{OK: true, Message: "peer pardoned"})
+}
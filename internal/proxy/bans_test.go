@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestAPIPeerBans(t *testing.T) {
+	s := newTestServer(t)
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ban := s.strikePeer(id, "hash mismatch")
+	if ban.Strikes != 1 || !s.scorer.IsBlacklisted(id) {
+		t.Fatalf("strike = %+v", ban)
+	}
+	if reason := banReason(ban); !strings.HasPrefix(reason, "hash mismatch (strike 1, banned until ") {
+		t.Errorf("banReason = %q", reason)
+	}
+
+	list := func() []apiPeerBan {
+		w := httptest.NewRecorder()
+		s.handleAPIPeerBans(w, httptest.NewRequest("GET", "/api/peers/bans", nil))
+		var bans []apiPeerBan
+		if err := json.NewDecoder(w.Body).Decode(&bans); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return bans
+	}
+	if bans := list(); len(bans) != 1 || bans[0].PeerID != id.String() || !bans[0].Active || bans[0].Until == "" {
+		t.Errorf("bans = %+v", bans)
+	}
+
+	pardon := func(peerID string) int {
+		r := httptest.NewRequest("DELETE", "/api/peers/"+peerID+"/ban", nil)
+		r.SetPathValue("id", peerID)
+		w := httptest.NewRecorder()
+		s.handleAPIPardonPeer(w, r)
+		return w.Code
+	}
+	if code := pardon("not-a-peer"); code != http.StatusBadRequest {
+		t.Errorf("invalid peer ID: status = %d", code)
+	}
+	if code := pardon(id.String()); code != http.StatusOK {
+		t.Fatalf("pardon: status = %d", code)
+	}
+	if s.scorer.IsBlacklisted(id) || len(list()) != 0 {
+		t.Error("pardoned peer still banned")
+	}
+	if code := pardon(id.String()); code != http.StatusNotFound {
+		t.Errorf("second pardon: status = %d", code)
+	}
+}
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/requestid"
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+// BuildProfile configures the build listener, a second proxy port for
+// debootstrap and mmdebstrap chroot builds. Requests on it differ from APT
+// requests on the main port in three ways:
+//
+//   - cached metadata younger than MetadataTTL is served without asking the
+//     mirror, and cached metadata is served stale when the mirror fails, so a
+//     build neither waits on revalidations nor sees the index change mid-run;
+//   - every package served is recorded in a build set, named by the request
+//     (see buildPathPrefix) or DefaultKey, which CI can list and keep;
+//   - with Pin, those packages are pinned so eviction keeps them for the
+//     next run of the same build.
+type BuildProfile struct {
+	Addr        string
+	MetadataTTL time.Duration
+	DefaultKey  string
+	Pin         bool
+}
+
+// buildPathPrefix starts a mirror-style build URL naming its build set, e.g.
+// http://127.0.0.1:9979/build/bookworm-minbase/deb.debian.org/debian for
+// debootstrap's MIRROR argument. Requests through the listener as an HTTP
+// proxy (http_proxy, Acquire::http::Proxy) use the default build set.
+const buildPathPrefix = "/build/"
+
+// buildKeyPattern matches build set names
+var buildKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+type buildKey struct{}
+
+// withBuildKey marks ctx as a build listener request for the named build set
+func withBuildKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, buildKey{}, key)
+}
+
+// buildKeyFrom returns the build set of a build listener request
+func buildKeyFrom(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(buildKey{}).(string)
+	return key, ok
+}
+
+// buildHandler marks requests to the build listener with their build set,
+// taking it off the path of mirror-style URLs.
+func (s *Server) buildHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := s.build.DefaultKey
+		if rest, ok := strings.CutPrefix(r.URL.Path, buildPathPrefix); ok && r.URL.Host == "" {
+			name, path, _ := strings.Cut(rest, "/")
+			if !buildKeyPattern.MatchString(name) {
+				http.Error(w, "debswarm: invalid build set name; use up to 64 letters, digits, '.', '_' or '-'", http.StatusBadRequest)
+				return
+			}
+			key = name
+			r = r.Clone(r.Context())
+			r.URL.Path = "/" + path
+			r.URL.RawPath = ""
+		}
+		next.ServeHTTP(w, r.WithContext(withBuildKey(r.Context(), key)))
+	})
+}
+
+// serveBuildMetadata serves a build request for metadata from the cache when
+// the cached copy was fetched or revalidated within the build TTL. It reports
+// whether it served the request.
+func (s *Server) serveBuildMetadata(w http.ResponseWriter, r *http.Request, url string, isIndex bool) bool {
+	if _, ok := buildKeyFrom(r.Context()); !ok || s.build.MetadataTTL <= 0 {
+		return false
+	}
+	entry, rc, err := s.cache.GetMetadata(url)
+	if err != nil {
+		return false
+	}
+	checked := entry.LastValidated
+	if entry.FetchedAt.After(checked) {
+		checked = entry.FetchedAt
+	}
+	if time.Since(checked) > s.build.MetadataTTL {
+		_ = rc.Close()
+		return false
+	}
+	requestid.LoggerFromContext(r.Context(), s.logger).Debug("Serving metadata to build within TTL",
+		zap.String("url", sanitize.URL(url)))
+	s.serveCachedMetadata(w, r, url, isIndex, entry, rc, false)
+	return true
+}
+
+// recordBuildPackage adds a package served to a build listener request to
+// its build set, pinning it when the profile asks to.
+func (s *Server) recordBuildPackage(ctx context.Context, hash, url string, size int64) {
+	key, ok := buildKeyFrom(ctx)
+	if !ok {
+		return
+	}
+	log := requestid.LoggerFromContext(ctx, s.logger)
+	if err := s.cache.RecordBuildPackage(key, hash, url, size); err != nil {
+		log.Debug("Failed to record build package", zap.String("build", key), zap.Error(err))
+		return
+	}
+	if s.metrics != nil {
+		s.metrics.BuildPackages.Inc()
+	}
+	if s.build.Pin && s.cache.Has(hash) && !s.cache.IsPinned(hash) {
+		if err := s.cache.Pin(hash); err != nil {
+			log.Debug("Failed to pin build package", zap.String("build", key), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+func TestBuildHandler(t *testing.T) {
+	s := &Server{build: &BuildProfile{DefaultKey: "default"}}
+	var gotPath, gotKey string
+	h := s.buildHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotKey, _ = buildKeyFrom(r.Context())
+	}))
+
+	for _, tt := range []struct {
+		target, path, key string
+	}{
+		{"/build/bookworm-minbase/deb.debian.org/debian/dists/bookworm/InRelease", "/deb.debian.org/debian/dists/bookworm/InRelease", "bookworm-minbase"},
+		{"/deb.debian.org/debian/dists/bookworm/InRelease", "/deb.debian.org/debian/dists/bookworm/InRelease", "default"},
+		{"http://deb.debian.org/build/x/dists/bookworm/InRelease", "/build/x/dists/bookworm/InRelease", "default"},
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if gotPath != tt.path || gotKey != tt.key {
+			t.Errorf("%s: path=%q key=%q, want %q %q", tt.target, gotPath, gotKey, tt.path, tt.key)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/build/../deb.debian.org/debian/dists/bookworm/InRelease", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid build set name: code = %d, want 400", w.Code)
+	}
+}
+
+func TestBuildMetadataTTL(t *testing.T) {
+	payload := bytes.Repeat([]byte("Package: hello\n\n"), 64)
+	m := &countingMirror{body: payload, etag: `"v1"`}
+	mockMirror := httptest.NewServer(m.handler())
+	defer mockMirror.Close()
+
+	srv := serverWith(t, freshMetaCache(t), index.New(t.TempDir(), newTestLogger()))
+	defer shutdownServer(t, srv)
+	srv.build = &BuildProfile{MetadataTTL: time.Hour, DefaultKey: "default"}
+	url := mockMirror.URL + "/debian/dists/bookworm/main/binary-amd64/Packages"
+
+	get := func(build bool) {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/"+url, nil)
+		if build {
+			r = r.WithContext(withBuildKey(r.Context(), "ci"))
+		}
+		w := httptest.NewRecorder()
+		srv.handleIndexRequest(w, r, url)
+		if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), payload) {
+			t.Fatalf("code=%d bodyLen=%d", w.Code, w.Body.Len())
+		}
+	}
+
+	get(true) // nothing cached yet: fetched from the mirror
+	get(true)
+	get(true)
+	if got := atomic.LoadInt32(&m.requests); got != 1 {
+		t.Errorf("mirror requests for build = %d, want 1", got)
+	}
+	get(false) // the main listener still revalidates
+	if got := atomic.LoadInt32(&m.conditional); got != 1 {
+		t.Errorf("conditional requests = %d, want 1", got)
+	}
+
+	srv.build.MetadataTTL = 0
+	get(true)
+	if got := atomic.LoadInt32(&m.requests); got != 3 {
+		t.Errorf("mirror requests with the TTL off = %d, want 3", got)
+	}
+}
+
+func TestBuildRecordsPackages(t *testing.T) {
+	c := freshMetaCache(t)
+	data := []byte("hello package contents")
+	hash := sha256Hex(data)
+	if err := c.Put(bytes.NewReader(data), hash, "hello_2.10_amd64.deb"); err != nil {
+		t.Fatal(err)
+	}
+	idx := index.New(t.TempDir(), newTestLogger())
+	if err := idx.LoadFromData([]byte("Package: hello\nVersion: 2.10\nArchitecture: amd64\n"+
+		"Filename: pool/main/h/hello/hello_2.10_amd64.deb\nSize: 22\nSHA256: "+hash+"\n"),
+		"http://deb.debian.org/debian/dists/bookworm/main/binary-amd64/Packages"); err != nil {
+		t.Fatal(err)
+	}
+	srv := serverWith(t, c, idx)
+	defer shutdownServer(t, srv)
+	srv.build = &BuildProfile{DefaultKey: "default", Pin: true}
+
+	url := "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10_amd64.deb"
+	r := httptest.NewRequest(http.MethodGet, "/"+url, nil)
+	w := httptest.NewRecorder()
+	srv.handlePackageRequest(w, r.WithContext(withBuildKey(r.Context(), "bookworm")), url)
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d", w.Code)
+	}
+
+	pkgs, err := c.BuildSetPackages("bookworm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || pkgs[0].SHA256 != hash || pkgs[0].URL != url || !pkgs[0].Cached {
+		t.Errorf("build set = %+v", pkgs)
+	}
+	if !c.IsPinned(hash) {
+		t.Error("build package not pinned")
+	}
+	if srv.metrics.BuildPackages.Value() != 1 {
+		t.Errorf("build packages metric = %d", srv.metrics.BuildPackages.Value())
+	}
+
+	// Requests on the main listener are not recorded
+	w = httptest.NewRecorder()
+	srv.handlePackageRequest(w, httptest.NewRequest(http.MethodGet, "/"+url, nil), url)
+	if sets, _ := c.BuildSets(); len(sets) != 1 || sets[0].Packages != 1 {
+		t.Errorf("sets = %+v", sets)
+	}
+}
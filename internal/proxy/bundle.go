@@ -0,0 +1,209 @@
+package proxy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Support bundle: one tarball with everything a bug report usually needs.
+// Secrets never enter it: the configuration comes from the same redacted
+// source as GET /api/config, and the other files hold no key material.
+
+// bundleInfo is info.json in a support bundle
+type bundleInfo struct {
+	GeneratedAt string `json:"generated_at"`
+	GoVersion   string `json:"go_version"`
+	OS          string `json:"os"`
+	Arch        string `json:"arch"`
+	CPUs        int    `json:"cpus"`
+	Goroutines  int    `json:"goroutines"`
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	PeerID      string `json:"peer_id,omitempty"`
+}
+
+type bundleRoutingPeer struct {
+	ID            string `json:"id"`
+	AddedAt       string `json:"added_at"`
+	LastUsefulAt  string `json:"last_useful_at,omitempty"`
+	LastQueriedAt string `json:"last_queried_at,omitempty"`
+}
+
+// SetLogSource adds recent log output, as returned by fn, to support
+// bundles.
+func (s *Server) SetLogSource(fn func() []byte) {
+	s.logSource = fn
+}
+
+// GET /debug/bundle
+//
+// A gzipped tarball of goroutine stacks, redacted configuration, recent
+// logs, timeouts and peer scores, cache and transfer statistics, peers and
+// connections, and the DHT routing table, for attaching to bug reports.
+func (s *Server) handleDebugBundle(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := s.WriteDebugBundle(&buf); err != nil {
+		s.logger.Warn("Failed to build debug bundle", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "failed to build debug bundle")
+		return
+	}
+	setSecurityHeaders(w)
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="debswarm-bundle-%s.tar.gz"`, time.Now().UTC().Format("20060102-150405")))
+	_, _ = buf.WriteTo(w)
+}
+
+// WriteDebugBundle writes the support bundle to w as a gzipped tarball.
+// A section that cannot be produced is replaced by a .error file saying
+// why, so one failure does not cost the rest of the bundle.
+func (s *Server) WriteDebugBundle(w io.Writer) error {
+	now := time.Now()
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: "debswarm-bundle/" + name, Mode: 0o600, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	for _, section := range s.bundleSections() {
+		data, err := section.collect()
+		name := section.name
+		if err != nil {
+			name, data = name+".error", []byte(err.Error()+"\n")
+		}
+		if data == nil {
+			continue
+		}
+		if err := add(name, data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+type bundleSection struct {
+	name    string
+	collect func() ([]byte, error) // nil data leaves the file out
+}
+
+func (s *Server) bundleSections() []bundleSection {
+	return []bundleSection{
+		{"info.json", func() ([]byte, error) { return bundleJSON(s.bundleInfo()) }},
+		{"goroutines.txt", func() ([]byte, error) {
+			var buf bytes.Buffer
+			err := pprof.Lookup("goroutine").WriteTo(&buf, 2)
+			return buf.Bytes(), err
+		}},
+		{"config.toml", func() ([]byte, error) {
+			if s.configSource == nil {
+				return nil, nil
+			}
+			return s.configSource()
+		}},
+		{"logs.txt", func() ([]byte, error) {
+			if s.logSource == nil {
+				return nil, nil
+			}
+			return s.logSource(), nil
+		}},
+		{"state.json", func() ([]byte, error) { return bundleJSON(s.debugState()) }},
+		{"stats.json", func() ([]byte, error) { return captureHandler(s.handleStats) }},
+		{"cache.json", func() ([]byte, error) { return captureHandler(s.handleAPICache) }},
+		{"peers.json", func() ([]byte, error) { return captureHandler(s.handleAPIPeers) }},
+		{"connections.json", func() ([]byte, error) { return captureHandler(s.handleAPIPeerConnections) }},
+		{"routing_table.json", func() ([]byte, error) {
+			if s.p2pNode == nil {
+				return nil, nil
+			}
+			var table []bundleRoutingPeer
+			for _, p := range s.p2pNode.RoutingTable() {
+				table = append(table, bundleRoutingPeer{
+					ID:            p.ID.String(),
+					AddedAt:       p.AddedAt.UTC().Format(time.RFC3339),
+					LastUsefulAt:  bundleTime(p.LastUsefulAt),
+					LastQueriedAt: bundleTime(p.LastQueriedAt),
+				})
+			}
+			return bundleJSON(table)
+		}},
+	}
+}
+
+func (s *Server) bundleInfo() *bundleInfo {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	info := &bundleInfo{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		CPUs:        runtime.NumCPU(),
+		Goroutines:  runtime.NumGoroutine(),
+		HeapAlloc:   mem.HeapAlloc,
+	}
+	if s.p2pNode != nil {
+		info.PeerID = s.p2pNode.PeerID().String()
+	}
+	return info
+}
+
+func bundleJSON(v any) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func bundleTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// captureResponse collects what a handler writes, so bundle files have
+// exactly the format of the API endpoints they mirror.
+type captureResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (c *captureResponse) Header() http.Header         { return c.header }
+func (c *captureResponse) Write(b []byte) (int, error) { return c.body.Write(b) }
+func (c *captureResponse) WriteHeader(status int)      { c.status = status }
+
+func captureHandler(h http.HandlerFunc) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		return nil, err
+	}
+	rec := &captureResponse{header: make(http.Header), status: http.StatusOK}
+	h(rec, req)
+	if rec.status != http.StatusOK {
+		return nil, fmt.Errorf("status %d: %s", rec.status, bytes.TrimSpace(rec.body.Bytes()))
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, rec.body.Bytes(), "", "  "); err != nil {
+		return rec.body.Bytes(), nil
+	}
+	return indented.Bytes(), nil
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readBundle returns the files of a support bundle by name
+func readBundle(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	files := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[strings.TrimPrefix(hdr.Name, "debswarm-bundle/")] = string(body)
+	}
+	return files
+}
+
+func TestDebugBundle(t *testing.T) {
+	s := newTestServer(t)
+	s.SetConfigSource(func() ([]byte, error) { return []byte("[privacy]\npsk = \"fingerprint:ab12\"\n"), nil })
+	s.SetLogSource(func() []byte { return []byte("INFO\tStarting debswarm daemon\n") })
+
+	w := httptest.NewRecorder()
+	s.handleDebugBundle(w, httptest.NewRequest("GET", "/debug/bundle", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	files := readBundle(t, w.Body.Bytes())
+
+	for _, name := range []string{"info.json", "goroutines.txt", "config.toml", "logs.txt", "state.json", "stats.json", "cache.json", "peers.json", "connections.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("bundle lacks %s; has %d files", name, len(files))
+		}
+	}
+	if !strings.Contains(files["goroutines.txt"], "goroutine ") {
+		t.Error("goroutines.txt has no stacks")
+	}
+	if !strings.Contains(files["config.toml"], "fingerprint:ab12") || !strings.Contains(files["logs.txt"], "Starting debswarm daemon") {
+		t.Error("configuration or logs missing")
+	}
+	if !strings.Contains(files["cache.json"], "\"total_packages\"") {
+		t.Errorf("cache.json = %q", files["cache.json"])
+	}
+	if _, ok := files["routing_table.json"]; ok {
+		t.Error("routing table included without a P2P node")
+	}
+}
+
+func TestDebugBundle_SectionError(t *testing.T) {
+	s := newTestServer(t)
+	s.SetConfigSource(func() ([]byte, error) { return nil, io.ErrUnexpectedEOF })
+
+	var buf bytes.Buffer
+	if err := s.WriteDebugBundle(&buf); err != nil {
+		t.Fatal(err)
+	}
+	files := readBundle(t, buf.Bytes())
+	if got := files["config.toml.error"]; !strings.Contains(got, "unexpected EOF") {
+		t.Errorf("config.toml.error = %q", got)
+	}
+	if _, ok := files["state.json"]; !ok {
+		t.Error("a failed section dropped the rest of the bundle")
+	}
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// cacheFullState tracks an episode of package cache writes refused for lack
+// of space. While it lasts, downloaded packages are served to APT but neither
+// cached nor announced, so the node quietly stops contributing to the swarm.
+type cacheFullState struct {
+	mu       sync.Mutex
+	since    time.Time // first refused write of the episode; zero when healthy
+	last     time.Time // most recent refused write
+	refused  int64     // writes refused this episode
+	reported int64     // refusals already covered by a log summary
+}
+
+// CacheFullStatus describes the current cache-full episode
+type CacheFullStatus struct {
+	Degraded bool
+	Since    time.Time
+	Last     time.Time
+	Refused  int64
+}
+
+// isCacheFull reports whether err means the cache has no room for a package
+func isCacheFull(err error) bool {
+	return err != nil && classifyFailure(err) == codeDiskFull
+}
+
+// noteCacheWrite records the outcome of storing a package. A refusal for lack
+// of space starts or extends a cache-full episode; a successful write ends it.
+// Other failures leave the state alone.
+func (s *Server) noteCacheWrite(err error) {
+	if err != nil && !isCacheFull(err) {
+		return
+	}
+	now := time.Now()
+	st := &s.cacheFull
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if err == nil {
+		if st.since.IsZero() {
+			return
+		}
+		s.logger.Info("Cache is accepting packages again",
+			zap.Duration("degradedFor", now.Sub(st.since)),
+			zap.Int64("refusedWrites", st.refused))
+		st.since, st.last, st.refused, st.reported = time.Time{}, time.Time{}, 0, 0
+		s.metrics.CacheDegraded.Set(0)
+		return
+	}
+
+	s.metrics.CacheFullRefusals.Inc()
+	if st.since.IsZero() {
+		st.since = now
+		s.metrics.CacheDegraded.Set(1)
+		s.logger.Warn("Cache is full: packages are served from the mirror without being cached or shared with peers",
+			zap.Bool("strict", s.strictWhenFull),
+			zap.Error(err))
+	}
+	st.last = now
+	st.refused++
+}
+
+// refusesUncached reports whether a package that could not be cached for err
+// must be refused to the client rather than served uncached (strict mode).
+func (s *Server) refusesUncached(err error) bool {
+	return s.strictWhenFull && isCacheFull(err)
+}
+
+// CacheFullStatus returns the current cache-full episode, if any
+func (s *Server) CacheFullStatus() CacheFullStatus {
+	st := &s.cacheFull
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return CacheFullStatus{
+		Degraded: !st.since.IsZero(),
+		Since:    st.since,
+		Last:     st.last,
+		Refused:  st.refused,
+	}
+}
+
+// LogCacheFullSummary logs a warning when packages went uncached since the
+// previous summary. The daemon calls it periodically so a full cache keeps
+// showing up in the logs, not only at the start of the episode.
+func (s *Server) LogCacheFullSummary() {
+	st := &s.cacheFull
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.since.IsZero() || st.refused == st.reported {
+		return
+	}
+	s.logger.Warn("Cache still full: recent packages were not cached or shared",
+		zap.Int64("refusedSinceLastSummary", st.refused-st.reported),
+		zap.Int64("refusedTotal", st.refused),
+		zap.Duration("degradedFor", time.Since(st.since)),
+		zap.Int64("cacheSize", s.cache.Size()),
+		zap.Int64("cacheMaxSize", s.cache.MaxSize()))
+	st.reported = st.refused
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -48,14 +49,14 @@ func TestProcessDownloadSuccess_CacheFullStillServes(t *testing.T) {
 		t.Fatalf("write assembled file: %v", err)
 	}
 
-	res := srv.processDownloadSuccess(context.Background(), &downloader.DownloadResult{
+	res, err := srv.processDownloadSuccess(context.Background(), &downloader.DownloadResult{
 		FilePath: filePath,
 		Size:     int64(len(content)),
 		Source:   downloader.SourceTypeMirror,
 	}, hash, "pkg_1.0_amd64.deb")
 
-	if res == nil {
-		t.Fatal("processDownloadSuccess returned nil")
+	if err != nil || res == nil {
+		t.Fatalf("processDownloadSuccess = %v, %v", res, err)
 	}
 	// Must NOT ask the caller to serve from cache — the cache write failed, so
 	// serveFromCache would 500 on the follow-up cache.Get.
@@ -72,4 +73,90 @@ func TestProcessDownloadSuccess_CacheFullStillServes(t *testing.T) {
 	// If the cache had NOT been full, PutFile would have succeeded and the first
 	// assertion (serveFromCache) would have caught it — so reaching here with the
 	// payload served proves the cache-full path was exercised.
+
+	status := srv.CacheFullStatus()
+	if !status.Degraded || status.Refused != 1 {
+		t.Errorf("CacheFullStatus = %+v, want degraded with one refusal", status)
+	}
+	if got := cfg.Metrics.CacheDegraded.Value(); got != 1 {
+		t.Errorf("CacheDegraded = %v, want 1", got)
+	}
+	if got := cfg.Metrics.CacheFullRefusals.Value(); got != 1 {
+		t.Errorf("CacheFullRefusals = %d, want 1", got)
+	}
+	if !srv.GetDashboardStats().CacheDegraded {
+		t.Error("dashboard stats should report the degraded cache")
+	}
+}
+
+// In strict mode a package the full cache cannot take is refused with a
+// disk-full error (507) instead of being served uncached.
+func TestProcessDownloadSuccess_StrictWhenFull(t *testing.T) {
+	logger := newTestLogger()
+	tinyCache, err := cache.New(t.TempDir(), 10, logger)
+	if err != nil {
+		t.Fatalf("cache.New: %v", err)
+	}
+	t.Cleanup(func() { _ = tinyCache.Close() })
+
+	cfg := &Config{
+		Addr:           "127.0.0.1:0",
+		Metrics:        metrics.New(),
+		Timeouts:       timeouts.NewManager(nil),
+		Scorer:         peers.NewScorer(),
+		StrictWhenFull: true,
+	}
+	srv := NewServer(cfg, tinyCache, index.New(t.TempDir(), logger), nil, mirror.NewFetcher(nil, logger), logger)
+
+	content := bytes.Repeat([]byte("strict;"), 256)
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "assembled")
+	if err := os.WriteFile(filePath, content, 0o600); err != nil {
+		t.Fatalf("write assembled file: %v", err)
+	}
+
+	res, err := srv.processDownloadSuccess(context.Background(), &downloader.DownloadResult{
+		FilePath: filePath,
+		Size:     int64(len(content)),
+		Source:   downloader.SourceTypeMirror,
+	}, hash, "pkg_1.0_amd64.deb")
+	if res != nil || !errors.Is(err, cache.ErrCacheFull) && !errors.Is(err, cache.ErrInsufficientDiskSpace) {
+		t.Fatalf("processDownloadSuccess = %v, %v; want a cache-full error", res, err)
+	}
+	if code := classifyFailure(err); code != codeDiskFull {
+		t.Errorf("classifyFailure = %s, want %s", code, codeDiskFull)
+	}
+	if _, statErr := os.Stat(dir); !os.IsNotExist(statErr) {
+		t.Errorf("assembly directory should be removed (stat err=%v)", statErr)
+	}
+}
+
+func TestNoteCacheWrite(t *testing.T) {
+	srv := newTestServer(t)
+	defer shutdownServer(t, srv)
+
+	srv.noteCacheWrite(errors.New("disk error"))
+	if srv.CacheFullStatus().Degraded {
+		t.Error("a non-space error should not mark the cache degraded")
+	}
+
+	srv.noteCacheWrite(cache.ErrCacheFull)
+	srv.noteCacheWrite(cache.ErrCacheFull)
+	if st := srv.CacheFullStatus(); !st.Degraded || st.Refused != 2 {
+		t.Errorf("status = %+v, want degraded with two refusals", st)
+	}
+	srv.LogCacheFullSummary()
+	if srv.cacheFull.reported != 2 {
+		t.Errorf("reported = %d, want 2 after a summary", srv.cacheFull.reported)
+	}
+
+	srv.noteCacheWrite(nil)
+	if st := srv.CacheFullStatus(); st.Degraded || st.Refused != 0 {
+		t.Errorf("status = %+v, want healthy after a successful write", st)
+	}
+	if got := srv.metrics.CacheDegraded.Value(); got != 0 {
+		t.Errorf("CacheDegraded = %v, want 0", got)
+	}
 }
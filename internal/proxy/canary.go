@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/audit"
+	"github.com/debswarm/debswarm/internal/hashutil"
+	"github.com/debswarm/debswarm/internal/requestid"
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+// Canary mode validates debswarm against the mirror during a rollout: a
+// sample of the packages peers served is fetched from the mirror as well, in
+// the background, and the two are compared. P2P content is always verified
+// against the index hash, so a mismatch means the mirror serves something
+// else under the same URL: a stale or wrong index, or a bug in the
+// verification path. The timings show whether P2P was faster than the mirror
+// would have been. The APT client never waits on a canary fetch.
+
+// canaryTimeout bounds one background mirror fetch
+const canaryTimeout = 5 * time.Minute
+
+// CanaryConfig enables canary checks of peer-served packages.
+type CanaryConfig struct {
+	// SampleRate is the share (0-1) of peer-served packages checked
+	SampleRate float64
+	// MaxConcurrent caps the mirror fetches running at once; packages
+	// sampled while every slot is busy are skipped. Default 1.
+	MaxConcurrent int
+	// MaxSize skips larger packages (0 = no limit)
+	MaxSize int64
+}
+
+type canary struct {
+	CanaryConfig
+	slots chan struct{}
+}
+
+func newCanary(cfg CanaryConfig) *canary {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 1
+	}
+	return &canary{CanaryConfig: cfg, slots: make(chan struct{}, cfg.MaxConcurrent)}
+}
+
+// sampleCanary starts a canary check of a package peers just served, if it
+// is sampled. mirrorURL is where the mirror serves it and p2pDuration how
+// long the P2P download took.
+func (s *Server) sampleCanary(ctx context.Context, mirrorURL, expectedHash, path string, size int64, p2pDuration time.Duration) {
+	c := s.canary
+	if c == nil || !s.mirrorAllowed(sourcePolicyFrom(ctx)) {
+		return
+	}
+	if c.MaxSize > 0 && size > c.MaxSize {
+		return
+	}
+	if rand.Float64() >= c.SampleRate {
+		return
+	}
+	log := requestid.LoggerFromContext(ctx, s.logger)
+	select {
+	case c.slots <- struct{}{}:
+	default:
+		log.Debug("Canary check skipped, all slots busy", zap.String("hash", expectedHash[:16]+"..."))
+		return
+	}
+
+	// The check outlives the request, so it keeps only its ID, trace and
+	// logger; announceCtx is canceled on shutdown.
+	checkCtx := requestid.WithLogger(requestid.WithRequestID(s.announceCtx, requestid.FromContext(ctx)), log)
+	if trace, ok := requestid.TraceFromContext(ctx); ok {
+		checkCtx = requestid.WithTrace(checkCtx, trace)
+	}
+	go func() {
+		defer func() { <-c.slots }()
+		checkCtx, cancel := context.WithTimeout(checkCtx, canaryTimeout)
+		defer cancel()
+		s.runCanary(checkCtx, mirrorURL, expectedHash, path, size, p2pDuration)
+	}()
+}
+
+// runCanary fetches a package from the mirror and compares it with what
+// peers served
+func (s *Server) runCanary(ctx context.Context, mirrorURL, expectedHash, path string, size int64, p2pDuration time.Duration) {
+	log := requestid.LoggerFromContext(ctx, s.logger)
+	start := time.Now()
+	body, _, err := s.fetcher.Stream(ctx, mirrorURL)
+	if err != nil {
+		log.Debug("Canary mirror fetch failed", zap.String("url", sanitize.URL(mirrorURL)), zap.Error(err))
+		s.metrics.CanaryChecks.WithLabel("error").Inc()
+		return
+	}
+	mirrorHash, err := hashutil.HashReader(body)
+	_ = body.Close()
+	mirrorDuration := time.Since(start)
+	if err != nil {
+		log.Debug("Canary mirror read failed", zap.String("url", sanitize.URL(mirrorURL)), zap.Error(err))
+		s.metrics.CanaryChecks.WithLabel("error").Inc()
+		return
+	}
+
+	s.metrics.CanaryP2PDuration.Observe(p2pDuration.Seconds())
+	s.metrics.CanaryMirrorDuration.Observe(mirrorDuration.Seconds())
+	if mirrorHash == expectedHash {
+		s.metrics.CanaryChecks.WithLabel("match").Inc()
+		log.Debug("Canary check matched",
+			zap.String("hash", expectedHash[:16]+"..."),
+			zap.Duration("p2p", p2pDuration),
+			zap.Duration("mirror", mirrorDuration))
+		return
+	}
+
+	s.metrics.CanaryChecks.WithLabel("mismatch").Inc()
+	log.Warn("Canary check mismatch: mirror serves different content than peers",
+		zap.String("url", sanitize.URL(mirrorURL)),
+		zap.String("expected", expectedHash),
+		zap.String("mirrorHash", mirrorHash),
+		zap.Duration("p2p", p2pDuration),
+		zap.Duration("mirror", mirrorDuration))
+	s.audit.Log(audit.NewCanaryMismatchEvent(expectedHash, path, mirrorHash, size,
+		p2pDuration.Milliseconds(), mirrorDuration.Milliseconds()).WithRequest(ctx))
+}
@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/audit"
+	"github.com/debswarm/debswarm/internal/hashutil"
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+type recordingAudit struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (r *recordingAudit) Log(e audit.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recordingAudit) Close() error { return nil }
+
+func TestRunCanary(t *testing.T) {
+	payload := []byte("canary package contents")
+	hash := hashutil.HashBytes(payload)
+	var body atomic.Value
+	body.Store(payload)
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.deb" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(body.Load().([]byte))
+	}))
+	defer mockMirror.Close()
+
+	srv := serverWith(t, newTestCache(t), index.New(t.TempDir(), newTestLogger()))
+	defer shutdownServer(t, srv)
+	rec := &recordingAudit{}
+	srv.audit = rec
+	url := mockMirror.URL + "/pool/main/c/canary/canary_1.0_amd64.deb"
+
+	srv.runCanary(context.Background(), url, hash, "canary_1.0_amd64.deb", int64(len(payload)), time.Second)
+	if got := srv.metrics.CanaryChecks.WithLabel("match").Value(); got != 1 {
+		t.Errorf("match checks = %d, want 1", got)
+	}
+	if len(rec.events) != 0 {
+		t.Errorf("a match logged %d audit events, want none", len(rec.events))
+	}
+
+	body.Store([]byte("something else"))
+	srv.runCanary(context.Background(), url, hash, "canary_1.0_amd64.deb", int64(len(payload)), time.Second)
+	if got := srv.metrics.CanaryChecks.WithLabel("mismatch").Value(); got != 1 {
+		t.Errorf("mismatch checks = %d, want 1", got)
+	}
+	if len(rec.events) != 1 || rec.events[0].EventType != audit.EventCanaryMismatch {
+		t.Fatalf("audit events = %+v, want one canary_mismatch", rec.events)
+	}
+	if rec.events[0].DurationMs != 1000 {
+		t.Errorf("P2P duration = %dms, want 1000", rec.events[0].DurationMs)
+	}
+
+	srv.runCanary(context.Background(), mockMirror.URL+"/missing.deb", hash, "missing.deb", 1, time.Second)
+	if got := srv.metrics.CanaryChecks.WithLabel("error").Value(); got != 1 {
+		t.Errorf("error checks = %d, want 1", got)
+	}
+	if got, _, _ := srv.metrics.CanaryMirrorDuration.Stats(); got != 2 {
+		t.Errorf("mirror durations observed = %d, want 2", got)
+	}
+}
+
+func TestSampleCanary(t *testing.T) {
+	payload := []byte("sampled package")
+	hash := hashutil.HashBytes(payload)
+	var requests int32
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write(payload)
+	}))
+	defer mockMirror.Close()
+
+	srv := serverWith(t, newTestCache(t), index.New(t.TempDir(), newTestLogger()))
+	defer shutdownServer(t, srv)
+	url := mockMirror.URL + "/pool/main/s/sampled/sampled_1.0_amd64.deb"
+	size := int64(len(payload))
+
+	// Disabled
+	srv.sampleCanary(context.Background(), url, hash, "sampled.deb", size, time.Second)
+
+	srv.canary = newCanary(CanaryConfig{SampleRate: 1, MaxSize: 4})
+	// Too large
+	srv.sampleCanary(context.Background(), url, hash, "sampled.deb", size, time.Second)
+	srv.canary.MaxSize = 0
+	// The client refused the mirror
+	srv.sampleCanary(withSourcePolicy(context.Background(), policyP2POnly), url, hash, "sampled.deb", size, time.Second)
+	if got := atomic.LoadInt32(&requests); got != 0 {
+		t.Fatalf("mirror requests for unsampled packages = %d, want 0", got)
+	}
+
+	srv.sampleCanary(context.Background(), url, hash, "sampled.deb", size, time.Second)
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.metrics.CanaryChecks.WithLabel("match").Value() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("sampled canary check did not complete")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/debswarm/debswarm/internal/artifact"
+)
+
+// artifactClass is a kind of repository artifact. The names are used as
+// keys of [proxy.classes] in the config.
+type artifactClass string
+
+const (
+	classPackage     artifactClass = "package"     // .deb, .udeb, .ddeb
+	classSource      artifactClass = "source"      // .dsc, orig/debian tarballs, .diff.gz
+	classIndex       artifactClass = "index"       // Packages, Sources
+	classRelease     artifactClass = "release"     // Release, InRelease, Release.gpg
+	classTranslation artifactClass = "translation" // i18n/Translation-*, i18n/Index
+	classContents    artifactClass = "contents"    // Contents-*
+	classCommands    artifactClass = "commands"    // cnf/Commands-*
+	classDEP11       artifactClass = "dep11"       // AppStream Components, icons, CID-Index
+	classPdiff       artifactClass = "pdiff"       // Packages.diff/Index and its patches
+	classInstaller   artifactClass = "installer"   // installer-<arch>/ images and kernels
+	classGeneric     artifactClass = "generic"     // RPM, Arch, Nix and OCI artifacts (see generic.go)
+	classUnknown     artifactClass = "unknown"     // anything else
+)
+
+// ClassPolicy says how the proxy treats one class of artifact
+type ClassPolicy struct {
+	// Cache keeps a copy: packages in the package cache, everything else in
+	// the metadata cache. Packages that are not cached stream straight from
+	// the mirror without verification.
+	Cache bool
+	// Share fetches the artifact from peers and serves it to them. Only
+	// packages, source artifacts and generic-mode artifacts, which their
+	// index verifies, and indexes, which the signed Release verifies (see
+	// serveSharedIndex), can be shared.
+	Share bool
+}
+
+// classRule maps URLs to a class and the handler that serves it
+type classRule struct {
+	class   artifactClass
+	handler requestType
+	match   func(u artifactURL) bool
+}
+
+// artifactURL is a lowercased URL split for matching
+type artifactURL struct {
+	full string // without query
+	base string // last path segment with compression suffixes removed
+}
+
+func parseArtifactURL(url string) artifactURL {
+	full := strings.ToLower(url)
+	if i := strings.IndexByte(full, '?'); i >= 0 {
+		full = full[:i]
+	}
+	base := path.Base(full)
+	for _, ext := range []string{".gz", ".xz", ".bz2", ".lzma", ".lz4", ".zst"} {
+		base = strings.TrimSuffix(base, ext)
+	}
+	return artifactURL{full: full, base: base}
+}
+
+// classRules are tried in order; the first match wins. Packages come first so
+// a package named like a metadata file (e.g. pool/main/s/sources-list/) is
+// not mistaken for one, and pdiffs precede indexes because they live under
+// Packages.diff/.
+var classRules = []classRule{
+	{classPackage, requestTypePackage, func(u artifactURL) bool {
+		return strings.HasSuffix(u.full, ".deb") || strings.HasSuffix(u.full, ".udeb") || strings.HasSuffix(u.full, ".ddeb")
+	}},
+	// Verified only on the generic route; ahead of source artifacts so an
+	// Arch package under a mirror's pool/ is not taken for a source tarball
+	{classGeneric, requestTypeUnknown, func(u artifactURL) bool {
+		return artifact.IsArtifactURL(u.full)
+	}},
+	{classSource, requestTypePackage, func(u artifactURL) bool {
+		return isSourceArtifactURL(u.full)
+	}},
+	{classPdiff, requestTypeUnknown, func(u artifactURL) bool {
+		return strings.Contains(u.full, ".diff/")
+	}},
+	{classTranslation, requestTypeUnknown, func(u artifactURL) bool {
+		return strings.Contains(u.full, "/i18n/")
+	}},
+	{classCommands, requestTypeUnknown, func(u artifactURL) bool {
+		return strings.Contains(u.full, "/cnf/")
+	}},
+	{classDEP11, requestTypeUnknown, func(u artifactURL) bool {
+		return strings.Contains(u.full, "/dep11/")
+	}},
+	{classContents, requestTypeUnknown, func(u artifactURL) bool {
+		return strings.HasPrefix(u.base, "contents-")
+	}},
+	{classIndex, requestTypeIndex, func(u artifactURL) bool {
+		if u.base == "packages" || u.base == "sources" {
+			return true
+		}
+		// Acquire-By-Hash copies of Packages/Sources: dist-layout binary-*/ and
+		// source/, or a flat-layout repo where by-hash sits under the repo base.
+		return strings.Contains(u.full, "/by-hash/") &&
+			(strings.Contains(u.full, "/binary-") || strings.Contains(u.full, "/source/") || isFlatByHash(u.full))
+	}},
+	{classRelease, requestTypeRelease, func(u artifactURL) bool {
+		return u.base == "release" || u.base == "inrelease" || u.base == "release.gpg"
+	}},
+	{classInstaller, requestTypeUnknown, func(u artifactURL) bool {
+		return strings.Contains(u.full, "/installer-")
+	}},
+}
+
+// classifyURL returns the artifact class of a repository URL and the handler
+// that serves it.
+func classifyURL(url string) (artifactClass, requestType) {
+	u := parseArtifactURL(url)
+	for _, rule := range classRules {
+		if rule.match(u) {
+			return rule.class, rule.handler
+		}
+	}
+	return classUnknown, requestTypeUnknown
+}
+
+// contentTypeClasses classify the responses to URLs no rule matches by the
+// media type the mirror sends, such as a package behind a download link
+var contentTypeClasses = map[string]artifactClass{
+	"application/vnd.debian.binary-package": classPackage,
+	"application/x-debian-package":          classPackage,
+	"application/x-deb":                     classPackage,
+}
+
+// classifyResponse returns the artifact class of a mirror response: the class
+// of its URL, or when no rule matches the URL, the class its Content-Type
+// names.
+func classifyResponse(url, contentType string) artifactClass {
+	class, _ := classifyURL(url)
+	if class != classUnknown || contentType == "" {
+		return class
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return classUnknown
+	}
+	if c, ok := contentTypeClasses[mediaType]; ok {
+		return c
+	}
+	return classUnknown
+}
+
+func (s *Server) classifyRequest(url string) requestType {
+	_, handler := classifyURL(url)
+	return handler
+}
+
+// defaultClassPolicy caches everything and shares what the index verifies
+func defaultClassPolicy(class artifactClass) ClassPolicy {
+	switch class {
+	case classPackage, classSource, classGeneric:
+		return ClassPolicy{Cache: true, Share: true}
+	default:
+		return ClassPolicy{Cache: true}
+	}
+}
+
+// classPolicy returns the configured policy for a class
+func (s *Server) classPolicy(class artifactClass) ClassPolicy {
+	if p, ok := s.classPolicies[class]; ok {
+		return p
+	}
+	return defaultClassPolicy(class)
+}
+
+// policyForURL returns the policy for the artifact at url, which may also be
+// a cached package's pool path. A repository configured not to be shared
+// overrides its class's Share.
+func (s *Server) policyForURL(url string) ClassPolicy {
+	class, _ := classifyURL(url)
+	policy := s.classPolicy(class)
+	if repo := s.repoForURL(url); repo != nil && !repo.Share {
+		policy.Share = false
+	}
+	return policy
+}
+
+// restrictsSharing reports whether any shareable class is configured not to
+// be shared, so announcements must check each package's class
+func (s *Server) restrictsSharing() bool {
+	return !s.classPolicy(classPackage).Share || !s.classPolicy(classSource).Share ||
+		(len(s.generic) > 0 && !s.classPolicy(classGeneric).Share) || s.restrictsRepoSharing()
+}
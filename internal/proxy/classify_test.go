@@ -0,0 +1,224 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+// URLs from real repository layouts: Debian and Ubuntu dist trees, the
+// Debian installer, Ubuntu ddebs, a Launchpad PPA, and flat repositories.
+func TestClassifyURL(t *testing.T) {
+	const debian = "http://deb.debian.org/debian/"
+	const ubuntu = "http://archive.ubuntu.com/ubuntu/"
+	tests := []struct {
+		url  string
+		want artifactClass
+	}{
+		{debian + "pool/main/h/hello/hello_2.10-3_amd64.deb", classPackage},
+		{debian + "pool/main/g/grub2/grub-efi-amd64-bin_2.06-13_amd64.udeb", classPackage},
+		{"http://ddebs.ubuntu.com/pool/main/h/hello/hello-dbgsym_2.10-3_amd64.ddeb", classPackage},
+		{"http://ppa.launchpadcontent.net/deadsnakes/ppa/ubuntu/pool/main/p/python3.12/python3.12_3.12.1-1_amd64.deb", classPackage},
+		{debian + "pool/main/h/hello/hello_2.10-3.dsc", classSource},
+		{debian + "pool/main/h/hello/hello_2.10.orig.tar.gz", classSource},
+		{debian + "pool/main/h/hello/hello_2.10-3.debian.tar.xz", classSource},
+		{debian + "pool/main/d/dpkg/dpkg_1.22.6.tar.xz", classSource},
+		{debian + "pool/main/z/zstd/zstd_1.5.5+dfsg2.orig.tar.zst", classSource},
+		{debian + "pool/main/s/sources-list/sources-list_1.0.dsc", classSource},
+
+		{"https://dl.fedoraproject.org/pub/fedora/linux/releases/40/Everything/x86_64/os/Packages/h/hello-2.12.1-4.fc40.x86_64.rpm", classGeneric},
+		{"https://geo.mirror.pkgbuild.com/pool/packages/hello-2.12.1-1-x86_64.pkg.tar.zst", classGeneric},
+		{"https://cache.nixos.org/nar/0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73.nar.xz", classGeneric},
+
+		{debian + "dists/bookworm/main/binary-amd64/Packages.xz", classIndex},
+		{debian + "dists/bookworm/main/binary-amd64/Packages", classIndex},
+		{debian + "dists/bookworm/main/source/Sources.gz", classIndex},
+		{debian + "dists/bookworm/main/debian-installer/binary-amd64/Packages.gz", classIndex},
+		{debian + "dists/bookworm/main/binary-amd64/by-hash/SHA256/3f0a", classIndex},
+		{debian + "dists/bookworm/main/source/by-hash/SHA256/3f0a", classIndex},
+		{"https://pkgs.k8s.io/core:/stable:/v1.30/deb/Packages", classIndex},
+		{"https://pkgs.k8s.io/core:/stable:/v1.30/deb/by-hash/SHA256/3f0a", classIndex},
+
+		{debian + "dists/bookworm/InRelease", classRelease},
+		{debian + "dists/bookworm/Release", classRelease},
+		{debian + "dists/bookworm/Release.gpg", classRelease},
+		{"https://pkgs.k8s.io/core:/stable:/v1.30/deb/InRelease", classRelease},
+		{debian + "dists/bookworm/main/binary-amd64/Release", classRelease},
+
+		{debian + "dists/bookworm/main/i18n/Translation-en.bz2", classTranslation},
+		{debian + "dists/bookworm/main/i18n/Translation-de.xz", classTranslation},
+		{ubuntu + "dists/noble/main/i18n/by-hash/SHA256/3f0a", classTranslation},
+		{debian + "dists/bookworm/main/Contents-amd64.gz", classContents},
+		{debian + "dists/bookworm/main/Contents-udeb-amd64.gz", classContents},
+		{ubuntu + "dists/noble/Contents-amd64.gz", classContents},
+		{ubuntu + "dists/noble/main/cnf/Commands-amd64.xz", classCommands},
+		{ubuntu + "dists/noble/main/cnf/by-hash/SHA256/3f0a", classCommands},
+		{debian + "dists/bookworm/main/dep11/Components-amd64.yml.gz", classDEP11},
+		{debian + "dists/bookworm/main/dep11/icons-64x64.tar.gz", classDEP11},
+		{debian + "dists/bookworm/main/dep11/CID-Index-amd64.json.gz", classDEP11},
+		{debian + "dists/bookworm/main/dep11/by-hash/SHA256/3f0a", classDEP11},
+		{debian + "dists/bookworm-updates/main/binary-amd64/Packages.diff/Index", classPdiff},
+		{debian + "dists/bookworm-updates/main/binary-amd64/Packages.diff/T-2024-06-01-0204.29-F-2024-05-31-2012.55.gz", classPdiff},
+		{debian + "dists/bookworm/main/i18n/Translation-en.diff/Index", classPdiff},
+		{debian + "dists/bookworm/main/installer-amd64/current/images/netboot/netboot.tar.gz", classInstaller},
+		{debian + "dists/bookworm/main/installer-amd64/current/images/SHA256SUMS", classInstaller},
+
+		{"http://example.com/some/other/file.txt", classUnknown},
+		{debian + "README", classUnknown},
+		{debian + "dists/bookworm/ChangeLog", classUnknown},
+	}
+	for _, tt := range tests {
+		if got, _ := classifyURL(tt.url); got != tt.want {
+			t.Errorf("classifyURL(%q) = %s, want %s", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyURL_Handlers(t *testing.T) {
+	handlers := map[artifactClass]requestType{}
+	for _, rule := range classRules {
+		handlers[rule.class] = rule.handler
+	}
+	want := map[artifactClass]requestType{
+		classPackage: requestTypePackage,
+		classSource:  requestTypePackage,
+		classIndex:   requestTypeIndex,
+		classRelease: requestTypeRelease,
+		classPdiff:   requestTypeUnknown,
+		classDEP11:   requestTypeUnknown,
+	}
+	for class, h := range want {
+		if handlers[class] != h {
+			t.Errorf("%s handled by %d, want %d", class, handlers[class], h)
+		}
+	}
+}
+
+func TestClassPolicy_Defaults(t *testing.T) {
+	s := &Server{}
+	if p := s.classPolicy(classPackage); !p.Cache || !p.Share {
+		t.Errorf("package policy = %+v, want cached and shared", p)
+	}
+	if p := s.classPolicy(classDEP11); !p.Cache || p.Share {
+		t.Errorf("dep11 policy = %+v, want cached, not shared", p)
+	}
+	if s.restrictsSharing() {
+		t.Error("default policies should not restrict sharing")
+	}
+
+	s.classPolicies = map[artifactClass]ClassPolicy{classSource: {Cache: true}}
+	if p := s.policyForURL("pool/main/h/hello/hello_2.10-3.dsc"); p.Share {
+		t.Errorf("source policy = %+v, want not shared", p)
+	}
+	if !s.restrictsSharing() {
+		t.Error("unshared source class should restrict sharing")
+	}
+}
+
+// A package class configured not to be cached streams from the mirror and
+// leaves nothing in the cache.
+func TestClassPolicy_UncachedPackages(t *testing.T) {
+	payload := []byte("udeb payload")
+	sum := sha256.Sum256(payload)
+	hash := hex.EncodeToString(sum[:])
+
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	server.classPolicies = map[artifactClass]ClassPolicy{classPackage: {}}
+
+	pkgPath := "pool/main/g/grub2/grub_1.0_amd64.udeb"
+	packages := fmt.Sprintf("Package: grub\nVersion: 1.0\nArchitecture: amd64\nFilename: %s\nSize: %d\nSHA256: %s\n\n",
+		pkgPath, len(payload), hash)
+	if err := server.index.LoadFromData([]byte(packages), mockMirror.URL+"/dists/stable/main/debian-installer/binary-amd64/Packages"); err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+
+	pkgURL := mockMirror.URL + "/" + pkgPath
+	w := httptest.NewRecorder()
+	server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	if w.Code != http.StatusOK || w.Body.String() != string(payload) {
+		t.Fatalf("status %d, body %q", w.Code, w.Body.String())
+	}
+	if server.cache.Has(hash) {
+		t.Error("package of an uncached class was cached")
+	}
+}
+
+func TestClassifyResponse(t *testing.T) {
+	const debian = "http://deb.debian.org/debian/"
+	tests := []struct {
+		url         string
+		contentType string
+		want        artifactClass
+	}{
+		{"https://vendor.example/download/agent?arch=amd64", "application/vnd.debian.binary-package", classPackage},
+		{"https://vendor.example/download/agent", "application/x-debian-package; charset=binary", classPackage},
+		{"https://vendor.example/download/agent", "application/x-deb", classPackage},
+		{"https://vendor.example/download/agent", "text/html; charset=utf-8", classUnknown},
+		{"https://vendor.example/download/agent", "not a media type;;", classUnknown},
+		{"https://vendor.example/download/agent", "", classUnknown},
+		// The URL rules win over what the mirror says
+		{debian + "dists/bookworm/main/i18n/Translation-en.xz", "application/vnd.debian.binary-package", classTranslation},
+		{debian + "pool/main/h/hello/hello_2.10-3_amd64.deb", "application/octet-stream", classPackage},
+	}
+	for _, tt := range tests {
+		if got := classifyResponse(tt.url, tt.contentType); got != tt.want {
+			t.Errorf("classifyResponse(%q, %q) = %s, want %s", tt.url, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+// A URL no rule matches follows the policy of the class its Content-Type
+// names.
+func TestClassPolicy_ContentType(t *testing.T) {
+	payload := []byte("package behind a download link")
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.debian.binary-package")
+		_, _ = w.Write(payload)
+	}))
+	defer mockMirror.Close()
+
+	srv := serverWith(t, freshMetaCache(t), index.New(t.TempDir(), newTestLogger()))
+	defer shutdownServer(t, srv)
+
+	get := func(url string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		srv.handlePassthrough(w, httptest.NewRequest(http.MethodGet, "/"+url, nil), url)
+		if w.Code != http.StatusOK || w.Body.String() != string(payload) {
+			t.Fatalf("status %d, body %q", w.Code, w.Body.String())
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/vnd.debian.binary-package" {
+			t.Errorf("Content-Type = %q, want the mirror's", ct)
+		}
+	}
+
+	cached := mockMirror.URL + "/download/agent"
+	get(cached)
+	entry, rc, err := srv.cache.GetMetadata(cached)
+	if err != nil {
+		t.Fatalf("package-typed response was not cached: %v", err)
+	}
+	_ = rc.Close()
+	if entry.ContentType != "application/vnd.debian.binary-package" {
+		t.Errorf("cached Content-Type = %q", entry.ContentType)
+	}
+
+	srv.classPolicies = map[artifactClass]ClassPolicy{classPackage: {}}
+	uncached := mockMirror.URL + "/download/tool"
+	get(uncached)
+	if _, rc, err := srv.cache.GetMetadata(uncached); err == nil {
+		_ = rc.Close()
+		t.Error("response of an uncached class was cached")
+	}
+}
@@ -0,0 +1,285 @@
+package proxy
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/dashboard"
+	"github.com/debswarm/debswarm/internal/ratelimit"
+)
+
+// ClientProfile sets the rate limit and source policy of the proxy clients
+// in its networks, e.g. the machines of one lab behind a LAN listener. A
+// client belongs to the first profile with a network containing its
+// address.
+type ClientProfile struct {
+	Name     string
+	Networks []*net.IPNet
+
+	// MaxRate limits the responses to each client, in bytes per second
+	// (0 = unlimited). Each client has its own limit; they are not shared.
+	MaxRate int64
+
+	// Policy, unless empty, is the source policy of every request from
+	// the clients ("auto", "mirror-only", "p2p-only" or "cache-only"),
+	// whatever policy the request asks for.
+	Policy string
+}
+
+const (
+	// maxTrackedClients bounds the per-client statistics; the client seen
+	// least recently is dropped to make room for a new one
+	maxTrackedClients = 1024
+	// dashboardTopClients is how many of the busiest clients the
+	// dashboard lists
+	dashboardTopClients = 10
+)
+
+// ClientStats is one proxy client's traffic since the daemon started.
+type ClientStats struct {
+	Address     string    `json:"address"`
+	Profile     string    `json:"profile,omitempty"`
+	Requests    int64     `json:"requests"`
+	BytesServed int64     `json:"bytes_served"`
+	MaxRate     int64     `json:"max_rate,omitempty"` // bytes per second, 0 when unlimited
+	Policy      string    `json:"policy,omitempty"`   // forced by the profile
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// trackedClient counts one client's requests and response bytes.
+type trackedClient struct {
+	addr     string
+	requests atomic.Int64
+	bytes    atomic.Int64
+	lastSeen atomic.Int64 // unix nanoseconds
+
+	// Set from the client's profile; guarded by clientTracker.mu
+	profile *ClientProfile
+	policy  sourcePolicy
+	forced  bool // policy overrides the request's
+	limiter *ratelimit.Limiter
+}
+
+// clientTracker keeps per-client statistics and applies client profiles.
+// Clients are identified by IP address: the proxy sees the TCP peer, not a
+// forwarded address.
+type clientTracker struct {
+	mu       sync.Mutex
+	profiles []ClientProfile
+	clients  map[string]*trackedClient
+	now      func() time.Time
+}
+
+func newClientTracker(profiles []ClientProfile) *clientTracker {
+	return &clientTracker{
+		profiles: profiles,
+		clients:  make(map[string]*trackedClient),
+		now:      time.Now,
+	}
+}
+
+// setProfiles replaces the client profiles, applying them to the clients
+// already tracked. Their statistics are kept.
+func (t *clientTracker) setProfiles(profiles []ClientProfile) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.profiles = profiles
+	for _, c := range t.clients {
+		t.applyProfile(c)
+	}
+}
+
+// observe counts a request from r's client and returns the client.
+func (t *clientTracker) observe(r *http.Request) *trackedClient {
+	addr, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		addr = r.RemoteAddr
+	}
+	now := t.now()
+
+	t.mu.Lock()
+	c, ok := t.clients[addr]
+	if !ok {
+		if len(t.clients) >= maxTrackedClients {
+			t.evictOldest()
+		}
+		c = &trackedClient{addr: addr}
+		t.applyProfile(c)
+		t.clients[addr] = c
+	}
+	t.mu.Unlock()
+
+	c.requests.Add(1)
+	c.lastSeen.Store(now.UnixNano())
+	return c
+}
+
+// evictOldest drops the client seen least recently. Callers hold t.mu.
+func (t *clientTracker) evictOldest() {
+	var oldest *trackedClient
+	for _, c := range t.clients {
+		if oldest == nil || c.lastSeen.Load() < oldest.lastSeen.Load() {
+			oldest = c
+		}
+	}
+	if oldest != nil {
+		delete(t.clients, oldest.addr)
+	}
+}
+
+// applyProfile sets c's profile, policy and limiter from the first
+// profile containing its address. Callers hold t.mu.
+func (t *clientTracker) applyProfile(c *trackedClient) {
+	c.profile, c.policy, c.forced, c.limiter = nil, policyAuto, false, nil
+	ip := net.ParseIP(c.addr)
+	if ip == nil {
+		return
+	}
+	for i := range t.profiles {
+		p := &t.profiles[i]
+		if !slices.ContainsFunc(p.Networks, func(n *net.IPNet) bool { return n != nil && n.Contains(ip) }) {
+			continue
+		}
+		c.profile = p
+		if p.Policy != "" {
+			// Profiles are validated with the configuration; an unknown
+			// policy name forces nothing
+			if policy, err := parsePolicyName(p.Policy); err == nil {
+				c.policy, c.forced = policy, true
+			}
+		}
+		if p.MaxRate > 0 {
+			c.limiter = ratelimit.New(p.MaxRate)
+		}
+		return
+	}
+}
+
+// settings returns what c's profile says about a request: the forced
+// policy, if any, and the rate limiter for the response (nil = unlimited).
+func (t *clientTracker) settings(c *trackedClient) (sourcePolicy, bool, *ratelimit.Limiter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return c.policy, c.forced, c.limiter
+}
+
+// stats returns the tracked clients, the most bytes served first.
+func (t *clientTracker) stats() []ClientStats {
+	t.mu.Lock()
+	out := make([]ClientStats, 0, len(t.clients))
+	for _, c := range t.clients {
+		st := ClientStats{
+			Address:     c.addr,
+			Requests:    c.requests.Load(),
+			BytesServed: c.bytes.Load(),
+			MaxRate:     c.limiter.Rate(),
+			LastSeen:    time.Unix(0, c.lastSeen.Load()),
+		}
+		if c.profile != nil {
+			st.Profile = c.profile.Name
+		}
+		if c.forced {
+			st.Policy = c.policy.String()
+		}
+		out = append(out, st)
+	}
+	t.mu.Unlock()
+
+	slices.SortFunc(out, func(a, b ClientStats) int {
+		return cmp.Or(cmp.Compare(b.BytesServed, a.BytesServed), cmp.Compare(a.Address, b.Address))
+	})
+	return out
+}
+
+// clientWriter counts the response bytes sent to a client and holds them
+// to the client's rate limit.
+type clientWriter struct {
+	http.ResponseWriter
+	client *trackedClient
+	body   io.Writer // the ResponseWriter, rate limited if the client is
+}
+
+func (c *clientWriter) Write(p []byte) (int, error) {
+	n, err := c.body.Write(p)
+	c.client.bytes.Add(int64(n))
+	return n, err
+}
+
+func (c *clientWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *clientWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
+
+// trackClient counts a request in its client's statistics and applies the
+// client's profile: it returns the writer the response goes through and
+// the policy the profile forces, if any.
+func (s *Server) trackClient(ctx context.Context, w http.ResponseWriter, r *http.Request) (http.ResponseWriter, sourcePolicy, bool) {
+	c := s.clients.observe(r)
+	policy, forced, limiter := s.clients.settings(c)
+	body := io.Writer(w)
+	if limiter != nil {
+		body = limiter.WriterContext(ctx, w)
+	}
+	return &clientWriter{ResponseWriter: w, client: c, body: body}, policy, forced
+}
+
+// SetClientProfiles replaces the client profiles while the proxy runs.
+func (s *Server) SetClientProfiles(profiles []ClientProfile) {
+	s.clients.setProfiles(profiles)
+}
+
+// ClientStats returns the traffic of each client seen since the daemon
+// started, the most bytes served first.
+func (s *Server) ClientStats() []ClientStats {
+	return s.clients.stats()
+}
+
+// topClients returns the busiest clients for the dashboard.
+func (s *Server) topClients(limit int) []dashboard.ClientStat {
+	stats := s.clients.stats()
+	out := make([]dashboard.ClientStat, 0, min(limit, len(stats)))
+	for _, st := range stats[:min(limit, len(stats))] {
+		rate := "unlimited"
+		if st.MaxRate > 0 {
+			rate = formatBytes(st.MaxRate) + "/s"
+		}
+		out = append(out, dashboard.ClientStat{
+			Address:   st.Address,
+			Profile:   st.Profile,
+			Requests:  st.Requests,
+			Served:    formatBytes(st.BytesServed),
+			RateLimit: rate,
+			Policy:    st.Policy,
+			LastSeen:  st.LastSeen.Format("2006-01-02 15:04"),
+		})
+	}
+	return out
+}
+
+// GET /api/clients?top=N
+//
+// The proxy clients seen since the daemon started (all of them, or the N
+// that were sent the most bytes), with their profile and limits.
+func (s *Server) handleAPIClients(w http.ResponseWriter, r *http.Request) {
+	stats := s.clients.stats()
+	if v := r.URL.Query().Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "top must be a positive number")
+			return
+		}
+		stats = stats[:min(n, len(stats))]
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
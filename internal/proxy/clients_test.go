@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/hashutil"
+	"github.com/debswarm/debswarm/internal/security"
+)
+
+func TestClientProfiles(t *testing.T) {
+	payload := []byte("client payload")
+	hash := hashutil.HashBytes(payload)
+
+	var mirrorHits atomic.Int32
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorHits.Add(1)
+		_, _ = w.Write(payload)
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	policy, err := security.NewMirrorPolicy(security.PolicyConfig{AllowedCIDRs: []string{"127.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetMirrorPolicy(policy)
+
+	_, lab, _ := net.ParseCIDR("10.1.0.0/16")
+	server.SetClientProfiles([]ClientProfile{{Name: "lab", Networks: []*net.IPNet{lab}, MaxRate: 1 << 20, Policy: "cache-only"}})
+
+	pkgPath := "pool/main/c/client/client_1.0_amd64.deb"
+	packages := fmt.Sprintf("Package: client\nFilename: %s\nSize: %d\nSHA256: %s\n\n", pkgPath, len(payload), hash)
+	if err := server.index.LoadFromData([]byte(packages), mockMirror.URL+"/dists/stable/main/binary-amd64/Packages"); err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+	get := func(addr, policy string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/"+mockMirror.URL+"/"+pkgPath, nil)
+		req.RemoteAddr = addr + ":40000"
+		if policy != "" {
+			req.Header.Set(policyHeader, policy)
+		}
+		w := httptest.NewRecorder()
+		server.handleRequest(w, req)
+		return w
+	}
+
+	// The lab profile forces cache-only, whatever the client asks for
+	if w := get("10.1.2.3", "mirror-only"); w.Code != http.StatusGatewayTimeout || mirrorHits.Load() != 0 {
+		t.Fatalf("lab client on a cold cache: status %d, mirror hits %d", w.Code, mirrorHits.Load())
+	}
+	if w := get("192.0.2.7", ""); w.Code != http.StatusOK || w.Body.String() != string(payload) {
+		t.Fatalf("other client: status %d, body %q", w.Code, w.Body.String())
+	}
+
+	stats := make(map[string]ClientStats)
+	for _, st := range server.ClientStats() {
+		stats[st.Address] = st
+	}
+	if len(stats) != 2 {
+		t.Fatalf("stats = %+v, want two clients", stats)
+	}
+	if st := stats["192.0.2.7"]; st.Requests != 1 || st.BytesServed != int64(len(payload)) || st.Profile != "" || st.MaxRate != 0 {
+		t.Errorf("other client stats = %+v", st)
+	}
+	if st := stats["10.1.2.3"]; st.Requests != 1 || st.Profile != "lab" || st.Policy != "cache-only" || st.MaxRate != 1<<20 {
+		t.Errorf("lab client stats = %+v", st)
+	}
+
+	// Without the profile the lab client chooses its own policy again
+	server.SetClientProfiles(nil)
+	if w := get("10.1.2.3", "mirror-only"); w.Code != http.StatusOK || mirrorHits.Load() != 2 {
+		t.Errorf("lab client without profile: status %d, mirror hits %d", w.Code, mirrorHits.Load())
+	}
+
+	w := httptest.NewRecorder()
+	server.handleAPIClients(w, httptest.NewRequest("GET", "/api/clients?top=1", nil))
+	var top []ClientStats
+	if err := json.NewDecoder(w.Body).Decode(&top); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(top) != 1 || top[0].Address != "10.1.2.3" || top[0].Requests != 2 || top[0].Profile != "" {
+		t.Errorf("top clients = %+v", top)
+	}
+	if dash := server.GetDashboardStats().TopClients; len(dash) != 2 || dash[0].Address != "10.1.2.3" {
+		t.Errorf("dashboard clients = %+v", dash)
+	}
+}
+
+func TestClientTrackerEvictsLeastRecent(t *testing.T) {
+	tracker := newClientTracker(nil)
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+
+	for i := range maxTrackedClients {
+		now = now.Add(time.Second)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = fmt.Sprintf("10.0.%d.%d:1234", i/256, i%256)
+		tracker.observe(req)
+	}
+	// Seeing the first client again makes the second the oldest
+	now = now.Add(time.Second)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.0:1234"
+	tracker.observe(req)
+
+	req.RemoteAddr = "192.0.2.1:1234"
+	tracker.observe(req)
+	if len(tracker.clients) != maxTrackedClients {
+		t.Fatalf("%d clients tracked, want %d", len(tracker.clients), maxTrackedClients)
+	}
+	if _, ok := tracker.clients["10.0.0.1"]; ok {
+		t.Error("least recently seen client was kept")
+	}
+	if _, ok := tracker.clients["10.0.0.0"]; !ok {
+		t.Error("recently seen client was dropped")
+	}
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"io"
+
+	"github.com/debswarm/debswarm/internal/audit"
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/p2p"
+)
+
+// Content served to peers comes from a chain of stores, consulted in order
+// until one has the hash: the package cache (its memory tier, then disk),
+// cached indexes when the index class is shared, then the stores added with
+// AddContentProvider, such as a cold tier or an existing mirror tree read in
+// place, so a seeder need not import those packages into the cache.
+
+// AddContentProvider adds a store packages are served to peers from, after
+// the cache. Hashes that belong to another swarm or are revoked are never
+// asked of it.
+func (s *Server) AddContentProvider(p p2p.ContentProvider) {
+	s.contentMu.Lock()
+	defer s.contentMu.Unlock()
+	s.contentProviders = append(s.contentProviders, p)
+}
+
+// peerContent returns the store node serves its peers from. A revoked hash
+// reads as "not found" from every store, so the peer is told we do not
+// have it.
+func (s *Server) peerContent(node *p2p.Node) p2p.ContentProvider {
+	chain := p2p.ContentChain{
+		p2p.ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+			return s.packageForPeer(node, hash)
+		}),
+		p2p.ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+			if !s.classPolicy(classIndex).Share {
+				return nil, 0, cache.ErrNotFound
+			}
+			return s.indexForPeer(node, hash)
+		}),
+		p2p.ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+			if !s.servesHash(node, hash) {
+				return nil, 0, cache.ErrNotFound
+			}
+			s.contentMu.RLock()
+			extra := s.contentProviders
+			s.contentMu.RUnlock()
+			return extra.Open(hash)
+		}),
+	}
+	return p2p.ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+		if reason, revoked := s.revocations.IsRevoked(hash); revoked {
+			s.metrics.RevokedBlocked.WithLabel("upload").Inc()
+			s.audit.Log(audit.NewRevokedContentBlockedEvent(hash, "upload", reason))
+			return nil, 0, cache.ErrNotFound
+		}
+		return chain.Open(hash)
+	})
+}
@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/hashutil"
+	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/p2p"
+)
+
+func TestPeerContent(t *testing.T) {
+	srv := serverWith(t, newTestCache(t), index.New(t.TempDir(), newTestLogger()))
+	defer shutdownServer(t, srv)
+
+	cached := []byte("a package in the cache")
+	cachedHash := hashutil.HashBytes(cached)
+	if err := srv.cache.Put(bytes.NewReader(cached), cachedHash, "pool/main/h/hello/hello_1.0_amd64.deb"); err != nil {
+		t.Fatal(err)
+	}
+	mirrored := []byte("a package in a mirror tree")
+	mirroredHash := hashutil.HashBytes(mirrored)
+	srv.AddContentProvider(p2p.ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+		if hash != mirroredHash {
+			return nil, 0, cache.ErrNotFound
+		}
+		return io.NopCloser(bytes.NewReader(mirrored)), int64(len(mirrored)), nil
+	}))
+
+	content := srv.peerContent(nil)
+	read := func(hash string) ([]byte, error) {
+		rc, _, err := content.Open(hash)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	if got, err := read(cachedHash); err != nil || !bytes.Equal(got, cached) {
+		t.Errorf("cached package = %q, %v", got, err)
+	}
+	if got, err := read(mirroredHash); err != nil || !bytes.Equal(got, mirrored) {
+		t.Errorf("package from the added store = %q, %v", got, err)
+	}
+	if _, err := read(hashutil.HashBytes([]byte("nowhere"))); err == nil {
+		t.Error("a package no store has was served")
+	}
+
+	// Revoked content is served from no store
+	srv.revocations = newRevocationManager(t, mirroredHash)
+	if _, err := read(mirroredHash); err == nil {
+		t.Error("revoked package was served")
+	}
+	if got := srv.metrics.RevokedBlocked.WithLabel("upload").Value(); got != 1 {
+		t.Errorf("revoked uploads blocked = %d, want 1", got)
+	}
+}
@@ -0,0 +1,500 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	grpcpeer "google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/p2p"
+	"github.com/debswarm/debswarm/internal/peers"
+	controlv1 "github.com/debswarm/debswarm/pkg/control/v1"
+)
+
+// The control API is the gRPC counterpart of the REST API on the metrics
+// port, for tools that embed debswarm control (pkg/control). It is served
+// only on a Unix socket: file permissions decide who may connect, and the
+// caller's user, read from the socket's peer credentials, decides whether
+// it may call the methods that change state.
+
+// ControlConfig configures the control socket.
+type ControlConfig struct {
+	// Socket is the path of the Unix socket
+	Socket string
+
+	// Group owns the socket; its members may connect and call read-only
+	// methods. Empty keeps the daemon's group.
+	Group string
+
+	// AdminGroups are groups whose members may call every method. Root and
+	// the daemon's own user always may.
+	AdminGroups []string
+
+	// Reload reloads the configuration, as on SIGHUP; nil if not supported
+	Reload func() error
+}
+
+// adminMethods are the control methods that change state or reveal the
+// configuration.
+var adminMethods = map[string]bool{
+	controlv1.Control_PinPackage_FullMethodName:    true,
+	controlv1.Control_UnpinPackage_FullMethodName:  true,
+	controlv1.Control_DeletePackage_FullMethodName: true,
+	controlv1.Control_SetPeerLabel_FullMethodName:  true,
+	controlv1.Control_PauseP2P_FullMethodName:      true,
+	controlv1.Control_ResumeP2P_FullMethodName:     true,
+	controlv1.Control_GetConfig_FullMethodName:     true,
+	controlv1.Control_ReloadConfig_FullMethodName:  true,
+}
+
+// ServeControl serves the control API on cfg.Socket until ctx is done. A
+// stale socket left by an earlier run is replaced.
+func (s *Server) ServeControl(ctx context.Context, cfg ControlConfig) error {
+	adminGIDs := make(map[uint32]bool)
+	for _, name := range cfg.AdminGroups {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return fmt.Errorf("control admin group: %w", err)
+		}
+		gid, err := strconv.ParseUint(g.Gid, 10, 32)
+		if err != nil {
+			return fmt.Errorf("control admin group %s: %w", name, err)
+		}
+		adminGIDs[uint32(gid)] = true
+	}
+
+	ln, err := listenControl(cfg.Socket, cfg.Group)
+	if err != nil {
+		return err
+	}
+
+	svc := &controlService{s: s, reload: cfg.Reload}
+	auth := &controlAuthorizer{daemonUID: uint32(os.Getuid()), adminGIDs: adminGIDs, logger: s.logger} // #nosec G115 -- uids fit in 32 bits
+	srv := grpc.NewServer(grpc.Creds(peerCredentials{}), grpc.UnaryInterceptor(auth.intercept))
+	controlv1.RegisterControlServer(srv, svc)
+
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+	s.logger.Info("Control API listening", zap.String("socket", cfg.Socket))
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// listenControl listens on the Unix socket at path, readable and writable
+// by its owner and group only.
+func listenControl(path, group string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("control socket %s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err == nil {
+			var gid int
+			gid, err = strconv.Atoi(g.Gid)
+			if err == nil {
+				err = os.Chown(path, -1, gid)
+			}
+		}
+		if err != nil {
+			_ = ln.Close()
+			return nil, fmt.Errorf("control socket group %s: %w", group, err)
+		}
+	}
+	return ln, nil
+}
+
+// controlCaller identifies the process at the other end of the control
+// socket.
+type controlCaller struct {
+	credentials.CommonAuthInfo
+	uid, gid uint32
+	known    bool // false where peer credentials are unavailable
+}
+
+func (controlCaller) AuthType() string { return "peercred" }
+
+// peerCredentials reads the caller's user from each control connection.
+// The connection itself stays unencrypted: it never leaves the host.
+type peerCredentials struct{}
+
+func (peerCredentials) ClientHandshake(_ context.Context, _ string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return conn, controlCaller{}, nil
+}
+
+func (peerCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	caller := controlCaller{CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity}}
+	if uc, ok := conn.(*net.UnixConn); ok {
+		if uid, gid, err := peerCred(uc); err == nil {
+			caller.uid, caller.gid, caller.known = uid, gid, true
+		}
+	}
+	return conn, caller, nil
+}
+
+func (peerCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "peercred"}
+}
+
+func (c peerCredentials) Clone() credentials.TransportCredentials { return c }
+
+func (peerCredentials) OverrideServerName(string) error { return nil }
+
+// controlAuthorizer admits calls to admin methods from admin callers only.
+type controlAuthorizer struct {
+	daemonUID uint32
+	adminGIDs map[uint32]bool
+	logger    *zap.Logger
+}
+
+func (a *controlAuthorizer) intercept(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if adminMethods[info.FullMethod] {
+		p, _ := grpcpeer.FromContext(ctx)
+		var caller controlCaller
+		if p != nil {
+			caller, _ = p.AuthInfo.(controlCaller)
+		}
+		if !a.isAdmin(caller) {
+			a.logger.Warn("Refused control call from non-admin user",
+				zap.String("method", info.FullMethod),
+				zap.Uint32("uid", caller.uid),
+				zap.Bool("credentials", caller.known))
+			return nil, status.Errorf(codes.PermissionDenied, "%s needs an admin user", info.FullMethod)
+		}
+	}
+	return handler(ctx, req)
+}
+
+// isAdmin reports whether caller is root, the daemon's user, or a member of
+// an admin group.
+func (a *controlAuthorizer) isAdmin(caller controlCaller) bool {
+	if !caller.known {
+		return false
+	}
+	if caller.uid == 0 || caller.uid == a.daemonUID || a.adminGIDs[caller.gid] {
+		return true
+	}
+	if len(a.adminGIDs) == 0 {
+		return false
+	}
+	u, err := user.LookupId(strconv.FormatUint(uint64(caller.uid), 10))
+	if err != nil {
+		return false
+	}
+	gids, err := u.GroupIds()
+	if err != nil {
+		return false
+	}
+	for _, g := range gids {
+		if gid, err := strconv.ParseUint(g, 10, 32); err == nil && a.adminGIDs[uint32(gid)] {
+			return true
+		}
+	}
+	return false
+}
+
+// controlService implements the control API on the proxy server.
+type controlService struct {
+	controlv1.UnimplementedControlServer
+	s      *Server
+	reload func() error
+}
+
+func timestampOrNil(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() || t.Unix() <= 0 {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func (c *controlService) GetCacheStats(context.Context, *controlv1.GetCacheStatsRequest) (*controlv1.GetCacheStatsResponse, error) {
+	stats, err := c.s.cache.Stats()
+	if err != nil {
+		c.s.logger.Error("Failed to get cache stats", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to retrieve cache stats")
+	}
+	resp := &controlv1.GetCacheStatsResponse{
+		TotalPackages:  int64(stats.TotalPackages),
+		TotalSize:      stats.TotalSize,
+		MaxSize:        stats.MaxSize,
+		BandwidthSaved: stats.BandwidthSaved,
+		PinnedCount:    int64(c.s.cache.PinnedCount()),
+	}
+	if stats.TotalPackages > 0 {
+		resp.OldestAccess = timestampOrNil(stats.OldestAccess)
+		resp.NewestAccess = timestampOrNil(stats.NewestAccess)
+	}
+	return resp, nil
+}
+
+func (c *controlService) ListPackages(_ context.Context, req *controlv1.ListPackagesRequest) (*controlv1.ListPackagesResponse, error) {
+	var (
+		packages []*cache.Package
+		err      error
+	)
+	switch name := strings.TrimSpace(req.GetName()); {
+	case req.GetPinned():
+		packages, err = c.s.cache.ListPinned()
+	case name != "":
+		packages, err = c.s.cache.ListByPackageName(name)
+	default:
+		packages, err = c.s.cache.List()
+	}
+	if err != nil {
+		c.s.logger.Error("Failed to list packages", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list packages")
+	}
+
+	limit := int(req.GetLimit())
+	if limit <= 0 {
+		limit = 100
+	}
+	limit = min(limit, 1000)
+	resp := &controlv1.ListPackagesResponse{Total: int64(len(packages))}
+	for _, pkg := range packages[:min(limit, len(packages))] {
+		resp.Packages = append(resp.Packages, &controlv1.Package{
+			Sha256:       pkg.SHA256,
+			Size:         pkg.Size,
+			Filename:     pkg.Filename,
+			PackageName:  pkg.PackageName,
+			Version:      pkg.PackageVersion,
+			Architecture: pkg.Architecture,
+			AddedAt:      timestampOrNil(pkg.AddedAt),
+			LastAccessed: timestampOrNil(pkg.LastAccessed),
+			AccessCount:  pkg.AccessCount,
+			Pinned:       pkg.Pinned,
+			Announced:    !pkg.Announced.IsZero() && pkg.Announced.Unix() > 0,
+		})
+	}
+	return resp, nil
+}
+
+// packageError maps a cache error for hash to a gRPC status.
+func (c *controlService) packageError(op, hash string, err error) error {
+	switch {
+	case errors.Is(err, cache.ErrNotFound):
+		return status.Error(codes.NotFound, "package not found")
+	case errors.Is(err, cache.ErrFileInUse):
+		return status.Error(codes.FailedPrecondition, "package is currently being read")
+	}
+	c.s.logger.Error("Failed to "+op+" package", zap.String("hash", hash[:16]+"..."), zap.Error(err))
+	return status.Errorf(codes.Internal, "failed to %s package", op)
+}
+
+func validHash(hash string) error {
+	if !isValidSHA256(hash) {
+		return status.Error(codes.InvalidArgument, "invalid SHA256 hash format")
+	}
+	return nil
+}
+
+func (c *controlService) PinPackage(_ context.Context, req *controlv1.PinPackageRequest) (*controlv1.PinPackageResponse, error) {
+	if err := validHash(req.GetSha256()); err != nil {
+		return nil, err
+	}
+	if err := c.s.cache.Pin(req.GetSha256()); err != nil {
+		return nil, c.packageError("pin", req.GetSha256(), err)
+	}
+	return &controlv1.PinPackageResponse{}, nil
+}
+
+func (c *controlService) UnpinPackage(_ context.Context, req *controlv1.UnpinPackageRequest) (*controlv1.UnpinPackageResponse, error) {
+	if err := validHash(req.GetSha256()); err != nil {
+		return nil, err
+	}
+	if err := c.s.cache.Unpin(req.GetSha256()); err != nil {
+		return nil, c.packageError("unpin", req.GetSha256(), err)
+	}
+	return &controlv1.UnpinPackageResponse{}, nil
+}
+
+func (c *controlService) DeletePackage(_ context.Context, req *controlv1.DeletePackageRequest) (*controlv1.DeletePackageResponse, error) {
+	hash := req.GetSha256()
+	if err := validHash(hash); err != nil {
+		return nil, err
+	}
+	if !c.s.cache.Has(hash) {
+		return nil, status.Error(codes.NotFound, "package not found")
+	}
+	if err := c.s.cache.Delete(hash); err != nil {
+		return nil, c.packageError("delete", hash, err)
+	}
+	return &controlv1.DeletePackageResponse{}, nil
+}
+
+func (c *controlService) ListPeers(context.Context, *controlv1.ListPeersRequest) (*controlv1.ListPeersResponse, error) {
+	resp := &controlv1.ListPeersResponse{}
+	if c.s.scorer == nil {
+		return resp, nil
+	}
+	for _, ps := range c.s.scorer.GetAllStats() {
+		score := c.s.scorer.GetScore(ps.PeerID)
+		label := c.s.scorer.Label(ps.PeerID)
+		p := &controlv1.Peer{
+			Id:                  ps.PeerID.String(),
+			Name:                label.Name,
+			Tags:                label.Tags,
+			Score:               score,
+			Category:            peers.ScoreCategory(score),
+			Mdns:                ps.IsMDNSPeer,
+			Blacklisted:         ps.Blacklisted && time.Now().Before(ps.BlacklistUntil),
+			Breaker:             ps.Breaker.String(),
+			ConsecutiveFailures: int32(ps.ConsecutiveFailures), // #nosec G115 -- small counter
+			LastSeen:            timestampOrNil(ps.LastSeen),
+		}
+		if c.s.p2pNode != nil {
+			p.Version = peerVersion(c.s.p2pNode.PeerCapabilities(ps.PeerID))
+		}
+		resp.Peers = append(resp.Peers, p)
+	}
+	return resp, nil
+}
+
+func (c *controlService) SetPeerLabel(_ context.Context, req *controlv1.SetPeerLabelRequest) (*controlv1.SetPeerLabelResponse, error) {
+	id, err := peer.Decode(req.GetPeerId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid peer ID")
+	}
+	label, err := peers.NormalizeLabel(peers.Label{Name: req.GetName(), Tags: req.GetTags()})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := c.s.cache.SetPeerLabel(cache.PeerLabel{PeerID: id.String(), Name: label.Name, Tags: label.Tags}); err != nil {
+		c.s.logger.Error("Failed to store peer label", zap.String("peer", id.String()), zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to store peer label")
+	}
+	if c.s.scorer != nil {
+		c.s.scorer.SetLabel(id, label)
+	}
+	c.s.logger.Info("Peer label updated",
+		zap.String("peer", id.String()),
+		zap.String("name", label.Name),
+		zap.Strings("tags", label.Tags))
+	return &controlv1.SetPeerLabelResponse{Name: label.Name, Tags: label.Tags}, nil
+}
+
+func p2pStateProto(st p2p.PauseState) *controlv1.P2PState {
+	resp := &controlv1.P2PState{Paused: st.Paused, Reason: st.Reason}
+	if st.Paused {
+		resp.Since = timestamppb.New(st.Since)
+	}
+	return resp
+}
+
+func (c *controlService) p2pNode() (*p2p.Node, error) {
+	if c.s.p2pNode == nil {
+		return nil, status.Error(codes.Unavailable, "P2P node is not running")
+	}
+	return c.s.p2pNode, nil
+}
+
+func (c *controlService) GetP2PState(context.Context, *controlv1.GetP2PStateRequest) (*controlv1.GetP2PStateResponse, error) {
+	node, err := c.p2pNode()
+	if err != nil {
+		return nil, err
+	}
+	return &controlv1.GetP2PStateResponse{State: p2pStateProto(node.PauseState())}, nil
+}
+
+func (c *controlService) PauseP2P(_ context.Context, req *controlv1.PauseP2PRequest) (*controlv1.PauseP2PResponse, error) {
+	node, err := c.p2pNode()
+	if err != nil {
+		return nil, err
+	}
+	reason := strings.TrimSpace(req.GetReason())
+	if len(reason) > 200 {
+		reason = reason[:200]
+	}
+	for _, n := range c.s.allNodes() {
+		n.Pause(reason)
+	}
+	return &controlv1.PauseP2PResponse{State: p2pStateProto(node.PauseState())}, nil
+}
+
+func (c *controlService) ResumeP2P(context.Context, *controlv1.ResumeP2PRequest) (*controlv1.ResumeP2PResponse, error) {
+	node, err := c.p2pNode()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range c.s.allNodes() {
+		n.Resume()
+	}
+	return &controlv1.ResumeP2PResponse{State: p2pStateProto(node.PauseState())}, nil
+}
+
+func (c *controlService) ListDownloads(context.Context, *controlv1.ListDownloadsRequest) (*controlv1.ListDownloadsResponse, error) {
+	resp := &controlv1.ListDownloadsResponse{}
+	for _, info := range c.s.downloads.Downloads() {
+		resp.Downloads = append(resp.Downloads, &controlv1.Download{
+			Id:       info.ID,
+			Sha256:   info.Hash,
+			Name:     info.Name,
+			Size:     info.Size,
+			Received: info.Received,
+			Started:  timestampOrNil(info.Started),
+			Finished: timestampOrNil(info.Finished),
+			Source:   info.Source,
+			Error:    info.Error,
+			Retries:  int32(info.Retries),     // #nosec G115 -- small counter
+			Chunks:   int32(len(info.Chunks)), // #nosec G115 -- small counter
+		})
+	}
+	return resp, nil
+}
+
+func (c *controlService) GetConfig(context.Context, *controlv1.GetConfigRequest) (*controlv1.GetConfigResponse, error) {
+	if c.s.configSource == nil {
+		return nil, status.Error(codes.Unavailable, "active configuration is not available")
+	}
+	data, err := c.s.configSource()
+	if err != nil {
+		c.s.logger.Warn("Failed to render active configuration", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to render configuration")
+	}
+	return &controlv1.GetConfigResponse{Toml: string(data)}, nil
+}
+
+func (c *controlService) ReloadConfig(context.Context, *controlv1.ReloadConfigRequest) (*controlv1.ReloadConfigResponse, error) {
+	if c.reload == nil {
+		return nil, status.Error(codes.Unimplemented, "configuration reload is not available")
+	}
+	if err := c.reload(); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &controlv1.ReloadConfigResponse{}, nil
+}
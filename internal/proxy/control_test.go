@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/debswarm/debswarm/internal/hashutil"
+	"github.com/debswarm/debswarm/pkg/control"
+	controlv1 "github.com/debswarm/debswarm/pkg/control/v1"
+)
+
+func TestServeControl(t *testing.T) {
+	server := newTestServer(t)
+	payload := "control api package"
+	hash := testPkg(t, server, payload, "control_1.0_amd64.deb")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	reloaded := make(chan struct{}, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- server.ServeControl(ctx, ControlConfig{Socket: socket, Reload: func() error {
+			reloaded <- struct{}{}
+			return errors.New("config is invalid")
+		}})
+	}()
+	defer func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("ServeControl: %v", err)
+		}
+	}()
+
+	client, err := control.Dial(socket)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer client.Close()
+
+	callCtx, callCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer callCancel()
+	var stats *controlv1.GetCacheStatsResponse
+	for {
+		// The socket appears once ServeControl is listening
+		if stats, err = client.GetCacheStats(callCtx, &controlv1.GetCacheStatsRequest{}); status.Code(err) != codes.Unavailable {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("GetCacheStats: %v", err)
+	}
+	if stats.GetTotalPackages() != 1 || stats.GetTotalSize() != int64(len(payload)) {
+		t.Errorf("GetCacheStats = %v", stats)
+	}
+
+	// The test runs as the daemon's user, so admin methods are allowed
+	if _, err := client.PinPackage(callCtx, &controlv1.PinPackageRequest{Sha256: hash}); err != nil {
+		t.Fatalf("PinPackage: %v", err)
+	}
+	list, err := client.ListPackages(callCtx, &controlv1.ListPackagesRequest{Pinned: true})
+	if err != nil {
+		t.Fatalf("ListPackages: %v", err)
+	}
+	if list.GetTotal() != 1 || list.GetPackages()[0].GetSha256() != hash || !list.GetPackages()[0].GetPinned() {
+		t.Errorf("ListPackages(pinned) = %v", list)
+	}
+
+	if _, err := client.PinPackage(callCtx, &controlv1.PinPackageRequest{Sha256: "nothex"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("PinPackage(invalid) error = %v, want InvalidArgument", err)
+	}
+	if _, err := client.DeletePackage(callCtx, &controlv1.DeletePackageRequest{Sha256: hashutil.HashBytes([]byte("other"))}); status.Code(err) != codes.NotFound {
+		t.Errorf("DeletePackage(missing) error = %v, want NotFound", err)
+	}
+	if _, err := client.GetP2PState(callCtx, &controlv1.GetP2PStateRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("GetP2PState without a node: error = %v, want Unavailable", err)
+	}
+	if _, err := client.ReloadConfig(callCtx, &controlv1.ReloadConfigRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ReloadConfig error = %v, want FailedPrecondition", err)
+	}
+	<-reloaded
+}
+
+func TestControlAuthorizer(t *testing.T) {
+	auth := &controlAuthorizer{daemonUID: 1500, adminGIDs: map[uint32]bool{1600: true}, logger: zap.NewNop()}
+
+	tests := []struct {
+		name   string
+		caller controlCaller
+		want   bool
+	}{
+		{"root", controlCaller{uid: 0, known: true}, true},
+		{"daemon user", controlCaller{uid: 1500, gid: 1500, known: true}, true},
+		{"admin group", controlCaller{uid: 1700, gid: 1600, known: true}, true},
+		{"other user", controlCaller{uid: 1700, gid: 1700, known: true}, false},
+		{"no credentials", controlCaller{}, false},
+	}
+	for _, tt := range tests {
+		if got := auth.isAdmin(tt.caller); got != tt.want {
+			t.Errorf("%s: isAdmin = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	called := false
+	handler := func(context.Context, any) (any, error) { called = true; return nil, nil }
+	for method, wantAllowed := range map[string]bool{
+		controlv1.Control_ListPeers_FullMethodName:    true,
+		controlv1.Control_PauseP2P_FullMethodName:     false,
+		controlv1.Control_GetConfig_FullMethodName:    false,
+		controlv1.Control_ListPackages_FullMethodName: true,
+	} {
+		called = false
+		_, err := auth.intercept(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		if called != wantAllowed || (err == nil) != wantAllowed {
+			t.Errorf("%s without credentials: called %v, error %v", method, called, err)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/downloader"
+	"github.com/debswarm/debswarm/internal/peers"
+	"github.com/debswarm/debswarm/internal/ratelimit"
+)
+
+// Debug state response types. Durations are in milliseconds and rates in
+// bytes per second.
+
+type debugState struct {
+	GeneratedAt   string             `json:"generated_at"`
+	Timeouts      []debugTimeout     `json:"timeouts"`
+	PeerTimeouts  []debugPeerTimeout `json:"peer_timeouts"`
+	PeerScores    []debugPeerScore   `json:"peer_scores"`
+	RateLimits    *debugRateLimits   `json:"rate_limits,omitempty"`
+	AnnounceQueue debugAnnounceQueue `json:"announce_queue"`
+}
+
+type debugTimeout struct {
+	Operation   string `json:"operation"`
+	BaseMs      int64  `json:"base_ms"`
+	CurrentMs   int64  `json:"current_ms"`
+	AverageMs   int64  `json:"average_ms"`
+	Successes   int64  `json:"successes"`
+	Failures    int64  `json:"failures"`
+	Timeouts    int64  `json:"timeouts"`
+	LastUpdated string `json:"last_updated"`
+}
+
+type debugPeerTimeout struct {
+	ID             string  `json:"id"`
+	Profile        string  `json:"profile"`
+	Throughput     float64 `json:"throughput"`
+	ChunkTimeoutMs int64   `json:"chunk_timeout_ms"` // deadline for a default-size chunk
+	Successes      int64   `json:"successes"`
+	Timeouts       int64   `json:"timeouts"`
+	LastSeen       string  `json:"last_seen"`
+}
+
+type debugPeerScore struct {
+	ID                  string               `json:"id"`
+	Score               float64              `json:"score"`
+	ScoreCachedAt       string               `json:"score_cached_at,omitempty"` // set when score is a cached value
+	Category            string               `json:"category"`
+	Basis               string               `json:"basis"`
+	Samples             int64                `json:"samples"`
+	Components          debugScoreComponents `json:"components"`
+	AvgLatencyMs        float64              `json:"avg_latency_ms"`
+	AvgThroughput       float64              `json:"avg_throughput"`
+	SuccessRate         float64              `json:"success_rate"`
+	MDNS                bool                 `json:"mdns"`
+	Breaker             string               `json:"breaker"`
+	ConsecutiveFailures int                  `json:"consecutive_failures"`
+}
+
+type debugScoreComponents struct {
+	Latency     float64 `json:"latency"`
+	Throughput  float64 `json:"throughput"`
+	Reliability float64 `json:"reliability"`
+	Freshness   float64 `json:"freshness"`
+	Proximity   float64 `json:"proximity"`
+	Reciprocity float64 `json:"reciprocity"`
+}
+
+type debugRateLimits struct {
+	Upload       debugLimiter       `json:"upload"`
+	Download     debugLimiter       `json:"download"`
+	WANUpload    debugLimiter       `json:"wan_upload"`
+	WANDownload  debugLimiter       `json:"wan_download"`
+	PeerUpload   []debugPeerLimiter `json:"peer_upload,omitempty"`
+	PeerDownload []debugPeerLimiter `json:"peer_download,omitempty"`
+}
+
+type debugLimiter struct {
+	Enabled bool    `json:"enabled"`
+	Rate    int64   `json:"rate"`
+	Burst   int     `json:"burst"`
+	Tokens  float64 `json:"tokens"`
+}
+
+type debugPeerLimiter struct {
+	ID         string  `json:"id"`
+	Limit      int64   `json:"limit"`
+	BaseLimit  int64   `json:"base_limit"`
+	Tokens     float64 `json:"tokens"`
+	LastAccess string  `json:"last_access"`
+}
+
+type debugAnnounceQueue struct {
+	Depth    int `json:"depth"`
+	Capacity int `json:"capacity"`
+}
+
+// GET /stats/debug
+//
+// Internal state for diagnosing slow downloads: adaptive timeouts, per-peer
+// transfer profiles, score breakdowns, rate limiter buckets and the announce
+// queue. The format is for people and may change between releases.
+func (s *Server) handleDebugState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.debugState())
+}
+
+func (s *Server) debugState() *debugState {
+	state := &debugState{
+		GeneratedAt:   time.Now().UTC().Format(time.RFC3339),
+		Timeouts:      []debugTimeout{},
+		PeerTimeouts:  []debugPeerTimeout{},
+		PeerScores:    []debugPeerScore{},
+		AnnounceQueue: debugAnnounceQueue{Depth: len(s.announceChan), Capacity: cap(s.announceChan)},
+	}
+
+	for _, t := range s.timeouts.GetAllStats() {
+		state.Timeouts = append(state.Timeouts, debugTimeout{
+			Operation:   string(t.Operation),
+			BaseMs:      t.BaseTimeout.Milliseconds(),
+			CurrentMs:   t.CurrentTimeout.Milliseconds(),
+			AverageMs:   t.AvgDuration.Milliseconds(),
+			Successes:   t.SuccessCount,
+			Failures:    t.FailureCount,
+			Timeouts:    t.TimeoutCount,
+			LastUpdated: t.LastUpdated.UTC().Format(time.RFC3339),
+		})
+	}
+	sort.Slice(state.Timeouts, func(i, j int) bool { return state.Timeouts[i].Operation < state.Timeouts[j].Operation })
+
+	for _, p := range s.timeouts.GetAllPeerStats() {
+		state.PeerTimeouts = append(state.PeerTimeouts, debugPeerTimeout{
+			ID:             p.PeerID,
+			Profile:        string(p.Profile),
+			Throughput:     p.Throughput,
+			ChunkTimeoutMs: s.timeouts.PeerTransferTimeout(p.PeerID, downloader.DefaultChunkSize).Milliseconds(),
+			Successes:      p.SuccessCount,
+			Timeouts:       p.TimeoutCount,
+			LastSeen:       p.LastSeen.UTC().Format(time.RFC3339),
+		})
+	}
+	sort.Slice(state.PeerTimeouts, func(i, j int) bool { return state.PeerTimeouts[i].ID < state.PeerTimeouts[j].ID })
+
+	if s.scorer != nil {
+		for _, b := range s.scorer.AllBreakdowns() {
+			score := debugPeerScore{
+				ID:       b.PeerID.String(),
+				Score:    b.Score,
+				Category: peers.ScoreCategory(b.Score),
+				Basis:    b.Basis,
+				Samples:  b.Samples,
+				Components: debugScoreComponents{
+					Latency:     b.Components.Latency,
+					Throughput:  b.Components.Throughput,
+					Reliability: b.Components.Reliability,
+					Freshness:   b.Components.Freshness,
+					Proximity:   b.Components.Proximity,
+					Reciprocity: b.Components.Reciprocity,
+				},
+			}
+			if !b.CachedAt.IsZero() {
+				score.ScoreCachedAt = b.CachedAt.UTC().Format(time.RFC3339)
+			}
+			if ps := s.scorer.GetStats(b.PeerID); ps != nil {
+				score.AvgLatencyMs = ps.AvgLatencyMs
+				score.AvgThroughput = ps.AvgThroughput
+				score.SuccessRate = ps.SuccessRate
+				score.MDNS = ps.IsMDNSPeer
+				score.Breaker = ps.Breaker.String()
+				score.ConsecutiveFailures = ps.ConsecutiveFailures
+			}
+			state.PeerScores = append(state.PeerScores, score)
+		}
+		sort.Slice(state.PeerScores, func(i, j int) bool { return state.PeerScores[i].Score > state.PeerScores[j].Score })
+	}
+
+	if s.p2pNode != nil {
+		rl := s.p2pNode.RateLimitState()
+		state.RateLimits = &debugRateLimits{
+			Upload:       debugLimiterState(rl.Upload),
+			Download:     debugLimiterState(rl.Download),
+			WANUpload:    debugLimiterState(rl.WANUpload),
+			WANDownload:  debugLimiterState(rl.WANDownload),
+			PeerUpload:   debugPeerLimiterStates(rl.PeerUpload),
+			PeerDownload: debugPeerLimiterStates(rl.PeerDownload),
+		}
+	}
+	return state
+}
+
+func debugLimiterState(st ratelimit.State) debugLimiter {
+	return debugLimiter{Enabled: st.Enabled, Rate: st.Rate, Burst: st.Burst, Tokens: st.Tokens}
+}
+
+func debugPeerLimiterStates(states []ratelimit.PeerState) []debugPeerLimiter {
+	if len(states) == 0 {
+		return nil
+	}
+	result := make([]debugPeerLimiter, 0, len(states))
+	for _, st := range states {
+		result = append(result, debugPeerLimiter{
+			ID:         st.PeerID.String(),
+			Limit:      st.CurrentLimit,
+			BaseLimit:  st.BaseLimit,
+			Tokens:     st.Tokens,
+			LastAccess: st.LastAccess.UTC().Format(time.RFC3339),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
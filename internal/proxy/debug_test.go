@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/debswarm/debswarm/internal/peers"
+	"github.com/debswarm/debswarm/internal/timeouts"
+)
+
+func TestDebugState(t *testing.T) {
+	s := newTestServer(t)
+
+	fast, fresh := peer.ID("fast-peer"), peer.ID("fresh-peer")
+	for i := 0; i < peers.MinSamples; i++ {
+		s.scorer.RecordSuccess(fast, 1<<20, 20, 20<<20)
+	}
+	s.scorer.RecordSuccess(fresh, 1<<20, 20, 20<<20)
+	s.timeouts.SetPeerProfile(string(fast), timeouts.ProfileLAN)
+	s.timeouts.RecordTimeout(timeouts.OpDHTLookup)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/stats/debug", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	requireLoopback(s.handleDebugState)(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	var state debugState
+	if err := json.NewDecoder(w.Body).Decode(&state); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	var dht *debugTimeout
+	for i := range state.Timeouts {
+		if state.Timeouts[i].Operation == string(timeouts.OpDHTLookup) {
+			dht = &state.Timeouts[i]
+		}
+	}
+	if dht == nil || dht.Timeouts != 1 || dht.CurrentMs <= dht.BaseMs {
+		t.Errorf("dht_lookup timeout = %+v", dht)
+	}
+
+	if len(state.PeerTimeouts) != 1 || state.PeerTimeouts[0].Profile != "lan" || state.PeerTimeouts[0].ChunkTimeoutMs == 0 {
+		t.Errorf("peer timeouts = %+v", state.PeerTimeouts)
+	}
+
+	if len(state.PeerScores) != 2 {
+		t.Fatalf("peer scores = %+v", state.PeerScores)
+	}
+	bases := map[string]string{}
+	for _, ps := range state.PeerScores {
+		bases[ps.ID] = ps.Basis
+		if ps.ID == fast.String() && ps.Components.Reliability != 1 {
+			t.Errorf("fast peer components = %+v", ps.Components)
+		}
+	}
+	if bases[fast.String()] != peers.BasisMeasured || bases[fresh.String()] != peers.BasisFewSamples {
+		t.Errorf("score bases = %v", bases)
+	}
+
+	if state.AnnounceQueue.Capacity != cap(s.announceChan) {
+		t.Errorf("announce queue = %+v", state.AnnounceQueue)
+	}
+	if state.RateLimits != nil {
+		t.Errorf("rate limits without a P2P node = %+v", state.RateLimits)
+	}
+
+	// Remote clients are refused
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/stats/debug", nil)
+	r.RemoteAddr = "192.0.2.10:1234"
+	requireLoopback(s.handleDebugState)(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("remote status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
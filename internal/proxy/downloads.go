@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/debswarm/debswarm/internal/dashboard"
+	"github.com/debswarm/debswarm/internal/downloader"
+)
+
+// recentDownloads is how many finished downloads the downloads page keeps
+const recentDownloads = 50
+
+// finishDownload ends the tracking of a package download, adds it to the
+// dashboard's recent downloads and notes its peers for the access log.
+func (s *Server) finishDownload(ctx context.Context, progress *downloader.Progress, result *packageDownloadResult, err error) {
+	if err != nil || result == nil {
+		progress.Finish("", err)
+		return
+	}
+	progress.Finish(result.source, nil)
+	noteDownloadPeers(ctx, progress.Info())
+	if s.dashboard != nil {
+		info := progress.Info()
+		s.dashboard.RecordDownload(filepath.Base(info.Name), info.Size, result.source, info.Finished.Sub(info.Started))
+	}
+}
+
+// GetDashboardDownloads returns the active and recent package downloads,
+// with their chunk maps, for the dashboard's downloads page.
+func (s *Server) GetDashboardDownloads() []dashboard.Download {
+	infos := s.downloads.Downloads()
+	out := make([]dashboard.Download, 0, len(infos))
+	for _, info := range infos {
+		end := info.Finished
+		if end.IsZero() {
+			end = time.Now()
+		}
+		d := dashboard.Download{
+			ID:         info.ID,
+			Filename:   filepath.Base(info.Name),
+			Path:       info.Name,
+			Size:       formatBytes(info.Size),
+			Started:    info.Started.Format("15:04:05"),
+			Duration:   formatDuration(end.Sub(info.Started)),
+			Active:     info.Finished.IsZero(),
+			Source:     info.Source,
+			Error:      info.Error,
+			Retries:    info.Retries,
+			Chunks:     make([]dashboard.DownloadChunk, len(info.Chunks)),
+			Throughput: info.Throughput,
+			Notes:      info.Notes,
+		}
+		if info.Size > 0 {
+			d.Percent = min(100, float64(info.Received)*100/float64(info.Size))
+		}
+		for i, c := range info.Chunks {
+			d.Chunks[i] = dashboard.DownloadChunk{Source: c.Source, From: s.sourceName(c), Attempts: c.Attempts}
+			if c.Duration > 0 {
+				d.Chunks[i].Duration = formatDuration(c.Duration)
+			}
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// sourceName names where a chunk came from: a peer by its label or short
+// ID, a mirror by its host.
+func (s *Server) sourceName(c downloader.ChunkProgress) string {
+	switch c.Source {
+	case downloader.SourceTypePeer:
+		id, err := peer.Decode(c.ID)
+		if err != nil {
+			return ""
+		}
+		if name := s.scorer.Name(id); name != "" {
+			return name
+		}
+		if short := id.String(); len(short) > 12 {
+			return short[:6] + "..." + short[len(short)-6:]
+		}
+		return id.String()
+	case downloader.SourceTypeMirror:
+		if u, err := url.Parse(c.ID); err == nil {
+			return u.Host
+		}
+	}
+	return ""
+}
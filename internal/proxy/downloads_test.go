@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/dashboard"
+	"github.com/debswarm/debswarm/internal/downloader"
+)
+
+func TestDashboardDownloads(t *testing.T) {
+	payload := make([]byte, downloader.DefaultChunkSize+1000)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(payload)))
+		_, _ = w.Write(payload)
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	dash := dashboard.New(&dashboard.Config{}, server.GetDashboardStats, nil)
+	server.SetDashboard(dash)
+
+	pkgURL := indexPackage(t, server, mockMirror.URL, "pool/main/s/streampkg/streampkg_1.0_amd64.deb", payload)
+	w := httptest.NewRecorder()
+	server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+
+	downloads := server.GetDashboardDownloads()
+	if len(downloads) != 1 {
+		t.Fatalf("downloads = %+v", downloads)
+	}
+	d := downloads[0]
+	if d.Filename != "streampkg_1.0_amd64.deb" || d.Active || d.Source != "mirror" || d.Percent != 100 {
+		t.Errorf("download = %+v", d)
+	}
+	if len(d.Chunks) != 2 {
+		t.Fatalf("chunk map = %+v, want 2 chunks", d.Chunks)
+	}
+	for i, c := range d.Chunks {
+		if c.Source != "mirror" || c.From == "" {
+			t.Errorf("chunk %d = %+v", i, c)
+		}
+	}
+
+	// The dashboard's recent downloads list it too
+	rec := httptest.NewRecorder()
+	dash.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "streampkg_1.0_amd64.deb") {
+		t.Error("download missing from the dashboard's recent downloads")
+	}
+}
@@ -344,13 +344,13 @@ func TestE2E_TwoNodeP2PTransfer(t *testing.T) {
 	defer node1.Close()
 
 	// Set content getter for node1 so it can serve packages
-	node1.SetContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+	node1.SetContentProvider(p2p.ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
 		reader, pkg, err := cache1.Get(hash)
 		if err != nil {
 			return nil, 0, err
 		}
 		return reader, pkg.Size, nil
-	})
+	}))
 
 	// Wait for node1 to be ready
 	node1.WaitForBootstrap()
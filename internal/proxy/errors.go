@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/downloader"
+	"github.com/debswarm/debswarm/internal/mirror"
+	"github.com/debswarm/debswarm/internal/p2p"
+)
+
+// errorHeader carries a machine-readable failure code on error responses,
+// so client tooling can tell a file missing upstream from a broken swarm.
+const errorHeader = "X-Debswarm-Error"
+
+// Failure codes sent in errorHeader and counted in
+// debswarm_request_errors_total. Each has one HTTP status.
+const (
+	codeMirrorNotFound     = "mirror-not-found"    // 404: the mirror does not have the file
+	codeMirrorError        = "mirror-error"        // 502: the mirror answered with another error
+	codeMirrorUnreachable  = "mirror-unreachable"  // 502: no connection to the mirror
+	codeUpstreamTimeout    = "upstream-timeout"    // 504: the mirror or peers were too slow
+	codeDHTTimeout         = "dht-timeout"         // 504: provider lookup timed out
+	codeDHTFailed          = "dht-failed"          // 504: provider lookup failed
+	codeNoProviders        = "no-providers"        // 504: no peer has the package
+	codePeersFailed        = "peers-failed"        // 504: every peer tried failed
+	codeP2PPaused          = "p2p-paused"          // 504: P2P is paused and no other source was allowed
+	codePolicyRefused      = "policy-refused"      // 504: the source policy excluded every source that could serve it
+	codeVerificationFailed = "verification-failed" // 502: content did not match the signed index
+	codeIndexUnverified    = "index-unverified"    // 502: an index failed upstream signature verification
+	codeDiskFull           = "disk-full"           // 507: no space to assemble or store the package
+	codeCacheError         = "cache-error"         // 500: the cached copy could not be read
+	codeOffline            = "offline"             // 503: not cached, and no network or offline mode
+	codeRevoked            = "revoked"             // 410: the hash is on the revocation list
+	codeQuarantined        = "quarantined"         // 403: the malware scanner flagged it
+	codeHookRejected       = "hook-rejected"       // 403: a pipeline hook refused it
+	codeHashUnknown        = "hash-unknown"        // 403: no index gives its hash and hash_required is set
+	codeUpstreamFailed     = "upstream-failed"     // 502: any other download failure
+)
+
+// codeStatus maps each failure code to the status APT receives.
+var codeStatus = map[string]int{
+	codeMirrorNotFound:     http.StatusNotFound,
+	codeMirrorError:        http.StatusBadGateway,
+	codeMirrorUnreachable:  http.StatusBadGateway,
+	codeUpstreamTimeout:    http.StatusGatewayTimeout,
+	codeDHTTimeout:         http.StatusGatewayTimeout,
+	codeDHTFailed:          http.StatusGatewayTimeout,
+	codeNoProviders:        http.StatusGatewayTimeout,
+	codePeersFailed:        http.StatusGatewayTimeout,
+	codeP2PPaused:          http.StatusGatewayTimeout,
+	codePolicyRefused:      http.StatusGatewayTimeout,
+	codeVerificationFailed: http.StatusBadGateway,
+	codeIndexUnverified:    http.StatusBadGateway,
+	codeDiskFull:           http.StatusInsufficientStorage,
+	codeCacheError:         http.StatusInternalServerError,
+	codeOffline:            http.StatusServiceUnavailable,
+	codeRevoked:            http.StatusGone,
+	codeQuarantined:        http.StatusForbidden,
+	codeHookRejected:       http.StatusForbidden,
+	codeHashUnknown:        http.StatusForbidden,
+	codeUpstreamFailed:     http.StatusBadGateway,
+}
+
+// P2P failure causes, joined to errPolicyRefused when a request that could
+// only use peers failed.
+var (
+	errDHTLookup   = errors.New("DHT provider lookup failed")
+	errNoProviders = errors.New("no peer provides the package")
+	errPeersFailed = errors.New("every peer tried failed")
+)
+
+// classifyFailure maps a download or fetch error to a failure code.
+func classifyFailure(err error) string {
+	var statusErr *mirror.StatusError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, cache.ErrQuarantined):
+		return codeQuarantined
+	case errors.Is(err, cache.ErrHashMismatch), errors.Is(err, downloader.ErrHashMismatch):
+		return codeVerificationFailed
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, cache.ErrInsufficientDiskSpace), errors.Is(err, cache.ErrCacheFull):
+		return codeDiskFull
+	case errors.Is(err, errPolicyRefused):
+		return classifyP2PFailure(err)
+	case errors.As(err, &statusErr):
+		if statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusGone {
+			return codeMirrorNotFound
+		}
+		return codeMirrorError
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, downloader.ErrTimeout):
+		return codeUpstreamTimeout
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return codeUpstreamTimeout
+		}
+		return codeMirrorUnreachable
+	}
+	return codeUpstreamFailed
+}
+
+// classifyP2PFailure names why peers could not serve a request that was
+// limited to them.
+func classifyP2PFailure(err error) string {
+	switch {
+	case errors.Is(err, p2p.ErrPaused):
+		return codeP2PPaused
+	case errors.Is(err, errDHTLookup) && errors.Is(err, context.DeadlineExceeded):
+		return codeDHTTimeout
+	case errors.Is(err, errDHTLookup):
+		return codeDHTFailed
+	case errors.Is(err, errNoProviders):
+		return codeNoProviders
+	case errors.Is(err, errPeersFailed):
+		return codePeersFailed
+	}
+	return codePolicyRefused
+}
+
+// writeFailure answers a request with the status for code, the code in
+// errorHeader, and msg as the body.
+func (s *Server) writeFailure(w http.ResponseWriter, code, msg string) {
+	status, ok := codeStatus[code]
+	if !ok {
+		code, status = codeUpstreamFailed, http.StatusBadGateway
+	}
+	s.metrics.RequestErrors.WithLabel(code).Inc()
+	w.Header().Set(errorHeader, code)
+	http.Error(w, msg, status)
+}
+
+// writeFetchFailure answers a request whose download or fetch failed with
+// err. msg is the body's prefix; the code says what went wrong.
+func (s *Server) writeFetchFailure(w http.ResponseWriter, msg string, err error) {
+	code := classifyFailure(err)
+	s.writeFailure(w, code, "debswarm: "+msg+" ("+code+")")
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/downloader"
+	"github.com/debswarm/debswarm/internal/mirror"
+	"github.com/debswarm/debswarm/internal/p2p"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"mirror 404", fmt.Errorf("mirror fetch failed: %w", &mirror.StatusError{StatusCode: 404, Status: "404 Not Found"}), codeMirrorNotFound},
+		{"mirror 503", fmt.Errorf("failed after 3 attempts: %w", &mirror.StatusError{StatusCode: 503, Status: "503 Service Unavailable"}), codeMirrorError},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, codeMirrorUnreachable},
+		{"deadline", fmt.Errorf("mirror fetch failed: %w", context.DeadlineExceeded), codeUpstreamTimeout},
+		{"hash mismatch", fmt.Errorf("mirror data failed hash verification: %w", cache.ErrHashMismatch), codeVerificationFailed},
+		{"chunk mismatch", downloader.ErrHashMismatch, codeVerificationFailed},
+		{"disk full", &os.PathError{Op: "write", Path: "/var/cache/debswarm/x", Err: syscall.ENOSPC}, codeDiskFull},
+		{"cache full", cache.ErrCacheFull, codeDiskFull},
+		{"quarantined", fmt.Errorf("%w: Eicar", cache.ErrQuarantined), codeQuarantined},
+		{"dht timeout", fmt.Errorf("%w: %w", errPolicyRefused, fmt.Errorf("%w: %w", errDHTLookup, context.DeadlineExceeded)), codeDHTTimeout},
+		{"dht budget", fmt.Errorf("%w: %w", errPolicyRefused, fmt.Errorf("%w: %w", errDHTLookup, p2p.ErrBudgetExhausted)), codeDHTFailed},
+		{"no providers", fmt.Errorf("%w: %w", errPolicyRefused, errNoProviders), codeNoProviders},
+		{"peers failed", fmt.Errorf("%w: %w", errPolicyRefused, errPeersFailed), codePeersFailed},
+		{"paused", fmt.Errorf("%w: %w", errPolicyRefused, p2p.ErrPaused), codeP2PPaused},
+		{"other", errors.New("boom"), codeUpstreamFailed},
+	}
+	for _, tt := range tests {
+		if got := classifyFailure(tt.err); got != tt.want {
+			t.Errorf("%s: classifyFailure = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	for code := range codeStatus {
+		if codeStatus[code] < 400 {
+			t.Errorf("%s maps to non-error status %d", code, codeStatus[code])
+		}
+	}
+}
+
+func TestPackageRequestErrorCodes(t *testing.T) {
+	payload := []byte("missing upstream")
+	sum := sha256.Sum256(payload)
+	hash := hex.EncodeToString(sum[:])
+
+	mockMirror := httptest.NewServer(http.NotFoundHandler())
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+
+	pkgPath := "pool/main/m/missing/missing_1.0_amd64.deb"
+	packages := fmt.Sprintf("Package: missing\nVersion: 1.0\nArchitecture: amd64\nFilename: %s\nSize: %d\nSHA256: %s\n\n",
+		pkgPath, len(payload), hash)
+	if err := server.index.LoadFromData([]byte(packages), mockMirror.URL+"/dists/stable/main/binary-amd64/Packages"); err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+	pkgURL := mockMirror.URL + "/" + pkgPath
+
+	get := func(policy sourcePolicy) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/"+pkgURL, nil)
+		req = req.WithContext(withSourcePolicy(req.Context(), policy))
+		w := httptest.NewRecorder()
+		server.handlePackageRequest(w, req, pkgURL)
+		return w
+	}
+
+	w := get(policyAuto)
+	if w.Code != http.StatusNotFound || w.Header().Get(errorHeader) != codeMirrorNotFound {
+		t.Errorf("mirror 404: status %d, %s %q", w.Code, errorHeader, w.Header().Get(errorHeader))
+	}
+	w = get(policyP2POnly)
+	if w.Code != http.StatusGatewayTimeout || w.Header().Get(errorHeader) != codeNoProviders {
+		t.Errorf("p2p-only without peers: status %d, %s %q", w.Code, errorHeader, w.Header().Get(errorHeader))
+	}
+	w = get(policyCacheOnly)
+	if w.Header().Get(errorHeader) != codePolicyRefused {
+		t.Errorf("cache-only miss: %s %q", errorHeader, w.Header().Get(errorHeader))
+	}
+
+	for _, code := range []string{codeMirrorNotFound, codeNoProviders, codePolicyRefused} {
+		if got := server.metrics.RequestErrors.WithLabel(code).Value(); got != 1 {
+			t.Errorf("request errors{%s} = %d, want 1", code, got)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/audit"
+	"github.com/debswarm/debswarm/internal/dashboard"
+)
+
+// Event severities shown on the timeline
+const (
+	severityInfo    = "info"
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+// handleAPIEvents lists the events on the timeline, newest first. The query
+// may select a category, a type, a period back from now ("since=24h"), text
+// in the event ("q") and a limit (default 100).
+func (s *Server) handleAPIEvents(w http.ResponseWriter, r *http.Request) {
+	if s.timeline == nil {
+		writeError(w, http.StatusNotFound, "event timeline not enabled")
+		return
+	}
+	q := r.URL.Query()
+	filter := audit.TimelineFilter{
+		Category: q.Get("category"),
+		Type:     audit.EventType(q.Get("type")),
+		Query:    q.Get("q"),
+		Limit:    100,
+	}
+	if v := q.Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, "since must be a positive duration, e.g. 24h")
+			return
+		}
+		filter.Since = time.Now().Add(-d)
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		filter.Limit = n
+	}
+	writeJSON(w, http.StatusOK, s.timeline.Events(filter))
+}
+
+// GetDashboardEvents returns the timeline events matching f for the
+// dashboard's events page, newest first
+func (s *Server) GetDashboardEvents(f dashboard.EventFilter) []dashboard.Event {
+	if s.timeline == nil {
+		return nil
+	}
+	events := s.timeline.Events(audit.TimelineFilter{
+		Category: f.Category,
+		Since:    f.Since,
+		Query:    f.Query,
+		Limit:    f.Limit,
+	})
+	out := make([]dashboard.Event, 0, len(events))
+	for _, e := range events {
+		summary, severity := describeEvent(e)
+		out = append(out, dashboard.Event{
+			Time:     e.Timestamp.Local().Format("2006-01-02 15:04:05"),
+			Type:     string(e.EventType),
+			Category: audit.Category(e.EventType),
+			Severity: severity,
+			Summary:  summary,
+		})
+	}
+	return out
+}
+
+// describeEvent returns a one-line description of a timeline event and
+// how serious it is
+func describeEvent(e audit.Event) (string, string) {
+	// Events carry the start of the peer ID already
+	peer := e.PeerName
+	if peer == "" {
+		peer = e.PeerID
+	}
+	switch e.EventType {
+	case audit.EventBootstrap:
+		if e.Error != "" {
+			return fmt.Sprintf("Bootstrap (%s) failed after %s: %s", e.Reason, msDuration(e.DurationMs), e.Error), severityError
+		}
+		return fmt.Sprintf("Bootstrap (%s) done in %s: %d peers, %d routing table entries",
+			e.Reason, msDuration(e.DurationMs), e.Peers, e.RoutingTableSize), severityInfo
+	case audit.EventModeChange:
+		text := fmt.Sprintf("%s is now %s", e.Mode, e.State)
+		if e.Reason != "" {
+			text += " (" + e.Reason + ")"
+		}
+		return text, severityInfo
+	case audit.EventP2PPaused:
+		return fmt.Sprintf("P2P paused: %s (%d uploads reset)", orNone(e.Reason), e.UploadsReset), severityWarning
+	case audit.EventP2PResumed:
+		return fmt.Sprintf("P2P resumed after %s", msDuration(e.DurationMs)), severityInfo
+	case audit.EventVerificationFailed:
+		return fmt.Sprintf("Hash mismatch for %s from peer %s", e.PackageName, peer), severityError
+	case audit.EventPeerBlacklisted:
+		return fmt.Sprintf("Peer %s blacklisted: %s", peer, orNone(e.Reason)), severityWarning
+	case audit.EventPeerPardoned:
+		return fmt.Sprintf("Peer %s pardoned", peer), severityInfo
+	case audit.EventRevokedContentBlocked:
+		return fmt.Sprintf("Revoked package %s refused from %s: %s", e.PackageHash, e.Source, orNone(e.Reason)), severityWarning
+	case audit.EventRevokedContentPurged:
+		return fmt.Sprintf("Revoked package %s purged from the cache: %s", e.PackageHash, orNone(e.Reason)), severityWarning
+	case audit.EventHookRejected:
+		return fmt.Sprintf("Hook %s rejected %s at %s: %s", e.Hook, e.PackageName, e.Stage, orNone(e.Reason)), severityWarning
+	case audit.EventPackageQuarantined:
+		return fmt.Sprintf("%s quarantined: %s", e.PackageName, e.Reason), severityError
+	case audit.EventScanFailed:
+		return fmt.Sprintf("Could not scan %s: %s", e.PackageName, e.Error), severityWarning
+	case audit.EventCanaryMismatch:
+		return fmt.Sprintf("Mirror served %s for %s, peers %s", e.MirrorHash, e.PackageName, e.PackageHash), severityError
+	case audit.EventCacheEviction:
+		return fmt.Sprintf("Evicted %d packages (%s) to make room, cache now %s",
+			e.PackagesEvicted, formatBytes(e.BytesFreed), formatBytes(e.CacheSize)), severityInfo
+	case audit.EventCacheDiskPressure:
+		return fmt.Sprintf("Low disk space: evicted %d packages (%s), cache now %s",
+			e.PackagesEvicted, formatBytes(e.BytesFreed), formatBytes(e.CacheSize)), severityWarning
+	case audit.EventConfigReload:
+		if e.Error != "" {
+			return "Configuration reload failed: " + e.Error, severityError
+		}
+		if len(e.Changes) == 0 {
+			return "Configuration reloaded, nothing changed", severityInfo
+		}
+		return fmt.Sprintf("Configuration reloaded: %s", strings.Join(e.Changes, ", ")), severityInfo
+	}
+	return string(e.EventType), severityInfo
+}
+
+func msDuration(ms int64) string {
+	return formatDuration(time.Duration(ms) * time.Millisecond)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "no reason given"
+	}
+	return s
+}
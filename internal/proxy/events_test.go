@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/audit"
+	"github.com/debswarm/debswarm/internal/dashboard"
+)
+
+func TestAPIEvents(t *testing.T) {
+	server := newTestServer(t)
+	defer shutdownServer(t, server)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.handleAPIEvents(w, httptest.NewRequest("GET", "/api/events"+query, nil))
+		return w
+	}
+	if w := get(""); w.Code != http.StatusNotFound {
+		t.Errorf("without a timeline: status %d, want 404", w.Code)
+	}
+
+	server.timeline = audit.NewTimeline(0)
+	server.timeline.Log(audit.NewBootstrapEvent("startup", 4, 12, 2500, ""))
+	server.timeline.Log(audit.NewVerificationFailedEvent(strings.Repeat("ab", 32), "curl_8.5_amd64.deb", "12D3KooWExamplePeerIdentifier"))
+	server.timeline.Log(audit.NewConfigReloadEvent(nil, "invalid configuration: bad rate"))
+
+	w := get("?category=security&since=1h")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var events []audit.Event
+	if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].EventType != audit.EventVerificationFailed {
+		t.Errorf("security events = %+v", events)
+	}
+	for _, bad := range []string{"?since=yesterday", "?limit=0"} {
+		if w := get(bad); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", bad, w.Code)
+		}
+	}
+
+	list := server.GetDashboardEvents(dashboard.EventFilter{})
+	if len(list) != 3 {
+		t.Fatalf("dashboard events = %+v", list)
+	}
+	for i, want := range []struct{ severity, summary string }{
+		{"error", "Configuration reload failed: invalid configuration: bad rate"},
+		{"error", "Hash mismatch for curl_8.5_amd64.deb from peer 12D3KooWExampleP"},
+		{"info", "Bootstrap (startup) done in 2.5s: 4 peers, 12 routing table entries"},
+	} {
+		if list[i].Severity != want.severity || list[i].Summary != want.summary {
+			t.Errorf("event %d = %+v, want %s %q", i, list[i], want.severity, want.summary)
+		}
+	}
+	if list[1].Category != audit.CategorySecurity {
+		t.Errorf("category = %q", list[1].Category)
+	}
+}
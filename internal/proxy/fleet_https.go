@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/p2p"
+)
+
+// fleetHTTPSPeers returns the fleet peers of node that a failed P2P
+// transfer may be retried from over HTTPS, or nil when the fallback is off.
+// The fleet is the main swarm's LAN peers.
+func (s *Server) fleetHTTPSPeers(node *p2p.Node) map[peer.ID]bool {
+	if s.fleetHTTPS == nil || node != s.p2pNode {
+		return nil
+	}
+	peers := make(map[peer.ID]bool)
+	for _, p := range node.GetMDNSPeers() {
+		peers[p.ID] = true
+	}
+	return peers
+}
+
+// fleetHTTPSRange fetches bytes [start, end) of a package from a fleet peer
+// over HTTPS after the P2P transfer failed with p2pErr, which it returns if
+// the fallback is off or fails too. The caller verifies the data as it
+// would the peer's.
+func (s *Server) fleetHTTPSRange(ctx context.Context, info peer.AddrInfo, hash string, start, end int64, p2pErr error) ([]byte, error) {
+	// A pause or a canceled download is not a transport failure
+	if s.fleetHTTPS == nil || ctx.Err() != nil || errors.Is(p2pErr, p2p.ErrPaused) {
+		return nil, p2pErr
+	}
+	addrs := slices.Concat(info.Addrs, s.p2pNode.Host().Peerstore().Addrs(info.ID))
+	data, err := s.fleetHTTPS.FetchRange(ctx, addrs, hash, start, end)
+	if err != nil {
+		s.metrics.FleetHTTPSFallbacks.WithLabel("failure").Inc()
+		return nil, fmt.Errorf("%w (HTTPS fallback: %w)", p2pErr, err)
+	}
+	s.metrics.FleetHTTPSFallbacks.WithLabel("success").Inc()
+	s.logger.Debug("Fetched from fleet peer over HTTPS after P2P transfer failed",
+		zap.String("hash", hash[:16]+"..."),
+		zap.String("peer", info.ID.String()),
+		zap.Int("size", len(data)),
+		zap.NamedError("p2pError", p2pErr))
+	return data, nil
+}
+
+// PeerContent returns what the main P2P node serves to its peers, for
+// other transports serving the same content.
+func (s *Server) PeerContent() p2p.ContentProvider {
+	return s.peerContent(s.p2pNode)
+}
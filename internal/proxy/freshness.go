@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/config"
+	"github.com/debswarm/debswarm/internal/mirror"
+	"github.com/debswarm/debswarm/internal/requestid"
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+// Passthrough objects (Translation, Contents, DEP-11, installer files and
+// anything unclassified) can be served from the metadata cache for a while
+// without asking the mirror. How long comes from the mirror's Cache-Control
+// or Expires, capped by PassthroughMaxTTL, or from the class TTL or
+// PassthroughTTL when the mirror gives none. Release files, indexes and
+// pdiffs always revalidate: they change together when the archive is
+// updated, and a fresh Release expires every passthrough object under its
+// suite so none is served against the wrong Release.
+
+// passthroughTTLClass reports whether cached copies of a class may be served
+// without revalidation
+func passthroughTTLClass(class artifactClass) bool {
+	return config.PassthroughClass(string(class))
+}
+
+// passthroughTTL returns the lifetime of a class's cached copies when the
+// mirror sends no freshness headers
+func (s *Server) passthroughTTL(class artifactClass) time.Duration {
+	if ttl, ok := s.classTTLs[class]; ok {
+		return ttl
+	}
+	return s.passthroughDefaultTTL
+}
+
+// freshUntil returns until when a passthrough object fetched or revalidated
+// now may be served without asking the mirror, or zero if it must be
+// revalidated on every use.
+func (s *Server) freshUntil(url string, cond *mirror.ConditionalResult, now time.Time) time.Time {
+	class := classifyResponse(url, cond.ContentType)
+	if !passthroughTTLClass(class) {
+		return time.Time{}
+	}
+	lifetime := s.passthroughTTL(class)
+	if s.passthroughMaxTTL > 0 {
+		if upstream, ok := freshnessLifetime(cond, now); ok {
+			lifetime = min(upstream, s.passthroughMaxTTL)
+		}
+	}
+	if lifetime <= 0 {
+		return time.Time{}
+	}
+	return now.Add(lifetime)
+}
+
+// freshnessLifetime returns how long the mirror says a response stays fresh,
+// and whether it said anything. no-store, no-cache and private responses are
+// kept (as before) but revalidated on every use.
+func freshnessLifetime(cond *mirror.ConditionalResult, now time.Time) (time.Duration, bool) {
+	if cond.CacheControl != "" {
+		maxAge, sMaxAge := -1, -1
+		for _, directive := range strings.Split(cond.CacheControl, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			switch strings.ToLower(name) {
+			case "no-store", "no-cache", "private":
+				return 0, true
+			case "max-age":
+				if n, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && n >= 0 {
+					maxAge = n
+				}
+			case "s-maxage":
+				if n, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && n >= 0 {
+					sMaxAge = n
+				}
+			}
+		}
+		// A shared cache prefers s-maxage
+		if sMaxAge >= 0 {
+			return time.Duration(sMaxAge) * time.Second, true
+		}
+		if maxAge >= 0 {
+			return time.Duration(maxAge) * time.Second, true
+		}
+	}
+	if cond.Expires != "" {
+		expires, err := http.ParseTime(cond.Expires)
+		if err != nil {
+			// An invalid Expires, such as "0", means already expired
+			return 0, true
+		}
+		date := now
+		if d, err := http.ParseTime(cond.Date); err == nil {
+			date = d
+		}
+		return max(expires.Sub(date), 0), true
+	}
+	return 0, false
+}
+
+// serveFreshMetadata serves a cached passthrough object that is still fresh
+// without asking the mirror. It reports whether it served the request.
+func (s *Server) serveFreshMetadata(w http.ResponseWriter, r *http.Request, url string, isIndex bool) bool {
+	if class, _ := classifyURL(url); !passthroughTTLClass(class) {
+		return false
+	}
+	entry, rc, err := s.cache.GetMetadata(url)
+	if err != nil {
+		return false
+	}
+	if !entry.IsFresh() {
+		_ = rc.Close()
+		return false
+	}
+	requestid.LoggerFromContext(r.Context(), s.logger).Debug("Serving fresh passthrough object from cache",
+		zap.String("url", sanitize.URL(url)), zap.Time("freshUntil", entry.FreshUntil))
+	if s.metrics != nil {
+		s.metrics.PassthroughFreshHits.Inc()
+	}
+	s.serveCachedMetadata(w, r, url, isIndex, entry, rc, false)
+	return true
+}
+
+// expireSuite makes the passthrough objects under a Release file's suite
+// due for revalidation once the mirror serves a new Release
+func (s *Server) expireSuite(url string) {
+	if class, _ := classifyURL(url); class != classRelease || s.cache == nil {
+		return
+	}
+	dir, _ := path.Split(url)
+	if n := s.cache.ExpireMetadataUnder(dir); n > 0 {
+		s.logger.Debug("Expired cached passthrough objects for new Release",
+			zap.String("suite", sanitize.URL(dir)), zap.Int("count", n))
+	}
+}
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/mirror"
+)
+
+func TestFreshnessLifetime(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name string
+		cond mirror.ConditionalResult
+		want time.Duration
+		ok   bool
+	}{
+		{"no headers", mirror.ConditionalResult{}, 0, false},
+		{"max-age", mirror.ConditionalResult{CacheControl: "public, max-age=300"}, 5 * time.Minute, true},
+		{"s-maxage wins", mirror.ConditionalResult{CacheControl: "max-age=300, s-maxage=60"}, time.Minute, true},
+		{"no-cache", mirror.ConditionalResult{CacheControl: "no-cache, max-age=300"}, 0, true},
+		{"no-store", mirror.ConditionalResult{CacheControl: "no-store"}, 0, true},
+		{"private", mirror.ConditionalResult{CacheControl: "private, max-age=300"}, 0, true},
+		{"bad max-age", mirror.ConditionalResult{CacheControl: "max-age=soon"}, 0, false},
+		{"expires with date", mirror.ConditionalResult{
+			Expires: "Fri, 02 Jan 2026 04:04:05 GMT",
+			Date:    "Fri, 02 Jan 2026 03:34:05 GMT",
+		}, 30 * time.Minute, true},
+		{"expires without date", mirror.ConditionalResult{Expires: "Fri, 02 Jan 2026 03:14:05 GMT"}, 10 * time.Minute, true},
+		{"expires in the past", mirror.ConditionalResult{Expires: "Thu, 01 Jan 2026 00:00:00 GMT"}, 0, true},
+		{"invalid expires", mirror.ConditionalResult{Expires: "0"}, 0, true},
+		{"cache-control beats expires", mirror.ConditionalResult{
+			CacheControl: "max-age=60",
+			Expires:      "Fri, 02 Jan 2026 04:04:05 GMT",
+		}, time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := freshnessLifetime(&tt.cond, now)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("freshnessLifetime() = %v, %v; want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestFreshUntil(t *testing.T) {
+	now := time.Now()
+	srv := &Server{
+		passthroughDefaultTTL: 10 * time.Minute,
+		passthroughMaxTTL:     time.Hour,
+		classTTLs:             map[artifactClass]time.Duration{classDEP11: 0},
+	}
+	base := "http://deb.debian.org/debian/dists/bookworm/"
+	tests := []struct {
+		name string
+		url  string
+		cond mirror.ConditionalResult
+		want time.Duration
+	}{
+		{"default ttl", base + "main/i18n/Translation-en.xz", mirror.ConditionalResult{}, 10 * time.Minute},
+		{"upstream max-age", base + "main/Contents-amd64.gz", mirror.ConditionalResult{CacheControl: "max-age=120"}, 2 * time.Minute},
+		{"capped", base + "main/Contents-amd64.gz", mirror.ConditionalResult{CacheControl: "max-age=86400"}, time.Hour},
+		{"class override", base + "main/dep11/Components-amd64.yml.gz", mirror.ConditionalResult{}, 0},
+		{"release revalidates", base + "InRelease", mirror.ConditionalResult{CacheControl: "max-age=300"}, 0},
+		{"index revalidates", base + "main/binary-amd64/Packages.xz", mirror.ConditionalResult{CacheControl: "max-age=300"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := srv.freshUntil(tt.url, &tt.cond, now)
+			want := time.Time{}
+			if tt.want > 0 {
+				want = now.Add(tt.want)
+			}
+			if !got.Equal(want) {
+				t.Errorf("freshUntil() = %v, want %v", got, want)
+			}
+		})
+	}
+
+	// With the cap at zero the mirror's headers are ignored
+	srv.passthroughMaxTTL = 0
+	cond := mirror.ConditionalResult{CacheControl: "no-cache"}
+	if got := srv.freshUntil(base+"main/i18n/Translation-en.xz", &cond, now); !got.Equal(now.Add(10 * time.Minute)) {
+		t.Errorf("freshUntil() ignoring headers = %v, want the default TTL", got)
+	}
+}
+
+func TestPassthroughTTL(t *testing.T) {
+	payload := bytes.Repeat([]byte("hello\tgreeting\n"), 64)
+	m := &countingMirror{body: payload, etag: `"t1"`}
+	handler := m.handler()
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=600")
+		handler(w, r)
+	}))
+	defer mockMirror.Close()
+
+	srv := serverWith(t, freshMetaCache(t), index.New(t.TempDir(), newTestLogger()))
+	defer shutdownServer(t, srv)
+	srv.passthroughMaxTTL = time.Hour
+	suite := mockMirror.URL + "/debian/dists/bookworm/"
+	url := suite + "main/i18n/Translation-en"
+
+	get := func(url string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		srv.handlePassthrough(w, httptest.NewRequest(http.MethodGet, "/"+url, nil), url)
+		if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), payload) {
+			t.Fatalf("code=%d bodyLen=%d", w.Code, w.Body.Len())
+		}
+	}
+
+	get(url)
+	get(url)
+	get(url)
+	if got := atomic.LoadInt32(&m.requests); got != 1 {
+		t.Errorf("mirror requests within TTL = %d, want 1", got)
+	}
+	if got := srv.metrics.PassthroughFreshHits.Value(); got != 2 {
+		t.Errorf("fresh hits = %d, want 2", got)
+	}
+
+	// A new Release expires the suite: the next request revalidates
+	get(suite + "InRelease")
+	get(url)
+	if got := atomic.LoadInt32(&m.conditional); got != 1 {
+		t.Errorf("conditional requests after a new Release = %d, want 1", got)
+	}
+	// ...and the 304 makes it fresh again
+	get(url)
+	if got := atomic.LoadInt32(&m.requests); got != 3 {
+		t.Errorf("mirror requests = %d, want 3", got)
+	}
+}
@@ -0,0 +1,370 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/artifact"
+	"github.com/debswarm/debswarm/internal/downloader"
+	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/mirror"
+	"github.com/debswarm/debswarm/internal/requestid"
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+// Generic mode caches and shares artifacts of packaging ecosystems other than
+// APT. A client points its repository at a mirror-style URL naming an
+// adapter, e.g.
+//
+//	http://127.0.0.1:9977/generic/rpm/dl.fedoraproject.org/pub/fedora/linux
+//
+// The adapter picks the SHA256 of each artifact out of the repository
+// metadata fetched through the same route (see package artifact); artifacts
+// it can resolve are then served like .debs — from the cache, peers or the
+// mirror, verified against that hash. Everything else passes through, or for
+// a registry that answers by request headers (OCI, see artifact.Relayer) is
+// relayed to it uncached. The APT path never consults the adapters.
+
+// genericPathPrefix starts a generic-mode URL: /generic/<adapter>/<host>/<path>.
+// The upstream is fetched over HTTPS unless the host is preceded by http:/.
+const genericPathPrefix = "/generic/"
+
+// genericAdapter is an enabled adapter and its one-shot warm from cached
+// metadata
+type genericAdapter struct {
+	artifact.Adapter
+	warmOnce sync.Once
+}
+
+// isGenericRequest reports whether r is for the generic route
+func (s *Server) isGenericRequest(r *http.Request) bool {
+	return len(s.generic) > 0 && r.URL.Host == "" && strings.HasPrefix(r.URL.Path, genericPathPrefix)
+}
+
+// parseGenericPath splits a generic-mode request into its adapter name and
+// upstream URL. It returns "" for the URL when the path names no host.
+func parseGenericPath(r *http.Request) (name, targetURL string) {
+	rest := strings.TrimPrefix(r.URL.Path, genericPathPrefix)
+	name, rest, _ = strings.Cut(rest, "/")
+	scheme := "https://"
+	// The mux cleans http://host to http:/host, so accept both
+	for _, prefix := range []string{"https:", "http:"} {
+		if after, ok := strings.CutPrefix(rest, prefix); ok {
+			scheme, rest = prefix+"//", strings.TrimLeft(after, "/")
+			break
+		}
+	}
+	if host, _, _ := strings.Cut(rest, "/"); host == "" {
+		return name, ""
+	}
+	targetURL = scheme + rest
+	if r.URL.RawQuery != "" {
+		targetURL += "?" + r.URL.RawQuery
+	}
+	return name, targetURL
+}
+
+// handleGeneric serves a request on the generic route.
+func (s *Server) handleGeneric(w http.ResponseWriter, r *http.Request) {
+	log := requestid.LoggerFromContext(r.Context(), s.logger)
+
+	name, targetURL := parseGenericPath(r)
+	ad, ok := s.generic[name]
+	if !ok {
+		http.Error(w, "debswarm: unknown or disabled generic adapter "+strconv.Quote(name), http.StatusNotFound)
+		return
+	}
+	if targetURL == "" {
+		http.Error(w, "debswarm: could not parse a repository URL from the request", http.StatusBadRequest)
+		return
+	}
+	if decision := s.mirrorPolicy.Load().CheckHostURL(targetURL); !decision.Allowed {
+		s.writeBlockedURLError(w, r, targetURL, decision)
+		return
+	}
+
+	kind := ad.Classify(targetURL)
+	log.Debug("Generic request",
+		zap.String("adapter", name),
+		zap.String("method", r.Method),
+		zap.String("url", sanitize.URL(targetURL)),
+		zap.Int("kind", int(kind)))
+
+	relayer, relays := ad.Adapter.(artifact.Relayer)
+	if relays {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "debswarm: the generic route only serves pulls", http.StatusMethodNotAllowed)
+			return
+		}
+		reqHeaders, _ := relayer.RelayHeaders()
+		r = r.WithContext(withRelayHeaders(r.Context(), r, reqHeaders))
+	}
+
+	switch {
+	case kind == artifact.KindArtifact:
+		s.serveGenericArtifact(w, r, ad, targetURL)
+	case relays:
+		s.relayGeneric(w, r, ad, relayer, targetURL, kind)
+	case kind == artifact.KindMetadata:
+		s.serveGenericMetadata(w, r, ad, targetURL)
+	default:
+		s.handlePassthrough(w, r, targetURL)
+	}
+}
+
+// maxRelayedMetadata bounds the metadata a relayed response is captured for
+// loading; the OCI distribution spec lets registries refuse manifests over
+// 4MiB
+const maxRelayedMetadata = 4 << 20
+
+// withRelayHeaders returns ctx carrying the named headers of r, which
+// mirror fetches made with it send upstream
+func withRelayHeaders(ctx context.Context, r *http.Request, names []string) context.Context {
+	header := make(http.Header)
+	for _, name := range names {
+		if values := r.Header.Values(name); len(values) > 0 {
+			header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	if len(header) == 0 {
+		return ctx
+	}
+	return mirror.WithHeader(ctx, header)
+}
+
+// relayGeneric relays a request for a Relayer adapter to the upstream as the
+// client sent it, uncached, with the upstream's status and the adapter's
+// response headers, and loads metadata it returns into the adapter.
+func (s *Server) relayGeneric(w http.ResponseWriter, r *http.Request, ad *genericAdapter, relayer artifact.Relayer, url string, kind artifact.Kind) {
+	ctx := r.Context()
+	log := requestid.LoggerFromContext(ctx, s.logger)
+
+	resp, err := s.fetcher.Do(ctx, r.Method, s.upstreamFetchURL(url))
+	if err != nil {
+		logFetchFailure(ctx, log, "Failed to relay request", err)
+		s.writeFetchFailure(w, "failed to fetch", err)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	_, respHeaders := relayer.RelayHeaders()
+	for _, name := range respHeaders {
+		if values := resp.Header.Values(name); len(values) > 0 {
+			w.Header()[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	cw := &captureWriter{ResponseWriter: w, limit: maxRelayedMetadata}
+	cw.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(cw, resp.Body); err != nil {
+		log.Debug("Relayed response interrupted", zap.Error(err))
+		return
+	}
+	if kind != artifact.KindMetadata || r.Method != http.MethodGet || cw.status != http.StatusOK || !cw.complete() {
+		return
+	}
+	if err := ad.Load(url, cw.buf.Bytes()); err != nil {
+		log.Debug("Failed to load relayed metadata",
+			zap.String("adapter", ad.Name()),
+			zap.String("url", sanitize.URL(url)),
+			zap.Error(err))
+	}
+}
+
+// serveGenericMetadata serves repository metadata like any passthrough file,
+// keeping a copy of the body to load into the adapter. When the client's
+// copy was current and no body was sent, the adapter loads the cached copy.
+func (s *Server) serveGenericMetadata(w http.ResponseWriter, r *http.Request, ad *genericAdapter, url string) {
+	log := requestid.LoggerFromContext(r.Context(), s.logger)
+
+	cw := &captureWriter{ResponseWriter: w, limit: s.fetcher.MaxResponseSize()}
+	s.serveMetadata(cw, r, url, false)
+
+	var data []byte
+	switch {
+	case cw.status == http.StatusOK && cw.complete():
+		data = cw.buf.Bytes()
+	case cw.status == http.StatusNotModified && s.cache != nil && s.cache.MetadataEnabled():
+		entry, rc, err := s.cache.GetMetadata(url)
+		if err != nil {
+			return
+		}
+		data, err = io.ReadAll(io.LimitReader(rc, entry.Size))
+		_ = rc.Close()
+		if err != nil {
+			return
+		}
+	default:
+		return
+	}
+	if err := ad.Load(url, data); err != nil {
+		log.Warn("Failed to load repository metadata",
+			zap.String("adapter", ad.Name()),
+			zap.String("url", sanitize.URL(url)),
+			zap.Error(err))
+	}
+}
+
+// serveGenericArtifact serves an artifact verified against the hash its
+// metadata gives, or streams it from the mirror when no loaded metadata
+// lists it, as handlePackageRequest does for a .deb.
+func (s *Server) serveGenericArtifact(w http.ResponseWriter, r *http.Request, ad *genericAdapter, url string) {
+	ctx := r.Context()
+	log := requestid.LoggerFromContext(ctx, s.logger)
+	policy := sourcePolicyFrom(ctx)
+	w = &contentTypeWriter{ResponseWriter: w, contentType: ad.ContentType()}
+
+	a, ok := ad.Resolve(url)
+	if !ok {
+		s.warmGenericFromCache(ad)
+		a, ok = ad.Resolve(url)
+	}
+	if ok && s.policyForURL(url).Cache {
+		s.serveVerifiedPackage(w, r, url, a.Filename, a.SHA256, a.Size)
+		return
+	}
+
+	path := index.ExtractPathFromURL(url)
+	if !ok && s.refuseUnknownHash(log, w, url) {
+		return
+	}
+	if !s.mirrorAllowed(policy) {
+		s.refuseMirror(ctx, w, policy, "", path, codePolicyRefused, "artifact is not verified and cached, so only the mirror can serve it")
+		return
+	}
+	if policy != policyAuto {
+		s.notePolicy(ctx, policy, "", path, downloader.SourceTypeMirror)
+	}
+	s.metrics.CacheMisses.Inc()
+	if !ok {
+		s.metrics.PackagesServedUncached.Inc()
+		s.noteUncachedServe(log, url)
+	}
+	s.streamUncachedPackage(w, r, url, path)
+}
+
+// warmGenericFromCache loads an adapter once from the metadata cache, so
+// artifacts resolve after a restart before clients refetch their metadata.
+func (s *Server) warmGenericFromCache(ad *genericAdapter) {
+	ad.warmOnce.Do(func() {
+		if s.cache == nil || !s.cache.MetadataEnabled() {
+			return
+		}
+		urls, err := s.cache.ListMetadataURLs()
+		if err != nil {
+			s.logger.Debug("Generic warm: failed to list cached metadata", zap.Error(err))
+			return
+		}
+		loaded := 0
+		for _, u := range urls {
+			if ad.Classify(u) != artifact.KindMetadata {
+				continue
+			}
+			entry, rc, err := s.cache.GetMetadata(u)
+			if err != nil {
+				continue
+			}
+			data, err := io.ReadAll(io.LimitReader(rc, entry.Size))
+			_ = rc.Close()
+			if err == nil && ad.Load(u, data) == nil {
+				loaded++
+			}
+		}
+		if loaded > 0 {
+			s.logger.Info("Warmed generic adapter from cached metadata",
+				zap.String("adapter", ad.Name()), zap.Int("files", loaded))
+		}
+	})
+}
+
+// captureWriter keeps a copy of a response body up to limit bytes
+type captureWriter struct {
+	http.ResponseWriter
+	status   int
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func (c *captureWriter) WriteHeader(code int) {
+	if c.status == 0 {
+		c.status = code
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	n, err := c.ResponseWriter.Write(p)
+	if !c.overflow {
+		if c.limit > 0 && int64(c.buf.Len()+n) > c.limit {
+			c.overflow = true
+			c.buf = bytes.Buffer{}
+		} else {
+			c.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+// complete reports whether the whole body was captured: it did not
+// overflow, and matches the Content-Length sent, if any
+func (c *captureWriter) complete() bool {
+	if c.overflow {
+		return false
+	}
+	if cl := c.Header().Get("Content-Length"); cl != "" {
+		n, err := strconv.Atoi(cl)
+		return err == nil && n == c.buf.Len()
+	}
+	return true
+}
+
+func (c *captureWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *captureWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
+
+// contentTypeWriter replaces the .deb content type the package paths send
+// with the adapter's
+type contentTypeWriter struct {
+	http.ResponseWriter
+	contentType string
+	wroteHeader bool
+}
+
+func (c *contentTypeWriter) WriteHeader(code int) {
+	if !c.wroteHeader {
+		c.wroteHeader = true
+		if c.Header().Get("Content-Type") == "application/vnd.debian.binary-package" {
+			c.Header().Set("Content-Type", c.contentType)
+		}
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *contentTypeWriter) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+func (c *contentTypeWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *contentTypeWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/artifact"
+	"github.com/debswarm/debswarm/internal/hashutil"
+	"github.com/debswarm/debswarm/internal/security"
+)
+
+func TestGenericRPM(t *testing.T) {
+	payload := []byte("rpm payload")
+	hash := hashutil.HashBytes(payload)
+	primary := fmt.Sprintf(`<metadata><package type="rpm"><checksum type="sha256">%s</checksum>`+
+		`<size package="%d"/><location href="Packages/h/hello-1.0.rpm"/></package></metadata>`, hash, len(payload))
+	mirrorHits := 0
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fedora/repodata/abc-primary.xml":
+			_, _ = w.Write([]byte(primary))
+		case "/fedora/Packages/h/hello-1.0.rpm", "/fedora/Packages/o/other-1.0.rpm":
+			mirrorHits++
+			_, _ = w.Write(payload)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	policy, err := security.NewMirrorPolicy(security.PolicyConfig{AllowedCIDRs: []string{"127.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetMirrorPolicy(policy)
+	server.generic = map[string]*genericAdapter{"rpm": {Adapter: artifact.NewRPM()}}
+
+	base := "/generic/rpm/http:/" + strings.TrimPrefix(mockMirror.URL, "http://") + "/fedora/"
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.handleRequest(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get(base + "repodata/abc-primary.xml"); rec.Code != http.StatusOK || rec.Body.String() != primary {
+		t.Fatalf("metadata: status %d, body %q", rec.Code, rec.Body.String())
+	}
+
+	for i, wantSource := range []string{"mirror", "cache"} {
+		rec := get(base + "Packages/h/hello-1.0.rpm")
+		if rec.Code != http.StatusOK || rec.Body.String() != string(payload) {
+			t.Fatalf("request %d: status %d, body %q", i, rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/x-rpm" {
+			t.Errorf("request %d: Content-Type = %q", i, ct)
+		}
+		if src := rec.Header().Get("X-Debswarm-Source"); src != wantSource {
+			t.Errorf("request %d: source = %q, want %q", i, src, wantSource)
+		}
+	}
+	if !server.cache.Has(hash) {
+		t.Error("verified artifact was not cached")
+	}
+	if mirrorHits != 1 {
+		t.Errorf("mirror hits = %d, want 1", mirrorHits)
+	}
+
+	// Not in the metadata: streamed uncached
+	if rec := get(base + "Packages/o/other-1.0.rpm"); rec.Code != http.StatusOK || rec.Header().Get("X-Debswarm-Source") != "mirror" {
+		t.Errorf("unlisted artifact: status %d, source %q", rec.Code, rec.Header().Get("X-Debswarm-Source"))
+	}
+
+	if rec := get("/generic/nix/cache.nixos.org/nix-cache-info"); rec.Code != http.StatusNotFound {
+		t.Errorf("disabled adapter: status %d, want 404", rec.Code)
+	}
+}
+
+func TestGenericOCI(t *testing.T) {
+	layer := []byte("layer payload")
+	digest := "sha256:" + hashutil.HashBytes(layer)
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"layers":[{"digest":%q,"size":%d}]}`, digest, len(layer))
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="https://auth.example/token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/library/alpine/manifests/3.20":
+			if r.Header.Get("Accept") == "" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Header().Set("Docker-Content-Digest", "sha256:"+hashutil.HashBytes([]byte(manifest)))
+			_, _ = w.Write([]byte(manifest))
+		case "/v2/library/alpine/blobs/" + digest:
+			_, _ = w.Write(layer)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	policy, err := security.NewMirrorPolicy(security.PolicyConfig{AllowedCIDRs: []string{"127.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetMirrorPolicy(policy)
+	oci := artifact.NewOCI()
+	server.generic = map[string]*genericAdapter{"oci": {Adapter: oci}}
+
+	base := "/generic/oci/http:/" + strings.TrimPrefix(mockMirror.URL, "http://") + "/v2/library/alpine/"
+	get := func(path, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", "application/vnd.oci.image.manifest.v1+json")
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		server.handleRequest(rec, req)
+		return rec
+	}
+
+	// The token challenge reaches the client unchanged
+	rec := get(base+"manifests/3.20", "")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("unauthenticated manifest: status %d, WWW-Authenticate %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+
+	rec = get(base+"manifests/3.20", "Bearer token")
+	if rec.Code != http.StatusOK || rec.Body.String() != manifest || rec.Header().Get("Docker-Content-Digest") == "" {
+		t.Fatalf("manifest: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if a, ok := oci.Resolve(mockMirror.URL + "/v2/library/alpine/blobs/" + digest); !ok || a.Size != int64(len(layer)) {
+		t.Errorf("manifest not loaded: %+v, %v", a, ok)
+	}
+
+	rec = get(base+"blobs/"+digest, "Bearer token")
+	if rec.Code != http.StatusOK || rec.Body.String() != string(layer) {
+		t.Fatalf("blob: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/octet-stream" {
+		t.Errorf("blob Content-Type = %q", ct)
+	}
+	if !server.cache.Has(hashutil.HashBytes(layer)) {
+		t.Error("blob was not cached")
+	}
+
+	req := httptest.NewRequest(http.MethodPut, base+"blobs/uploads/", nil)
+	rec = httptest.NewRecorder()
+	server.handleRequest(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("push: status %d, want 405", rec.Code)
+	}
+}
+
+func TestParseGenericPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"/generic/rpm/dl.example.org/fedora/repodata/repomd.xml": "https://dl.example.org/fedora/repodata/repomd.xml",
+		"/generic/rpm/http:/127.0.0.1:8080/fedora/x.rpm":         "http://127.0.0.1:8080/fedora/x.rpm",
+		"/generic/nix/https://cache.example.org/abc.narinfo?x=1": "https://cache.example.org/abc.narinfo?x=1",
+		"/generic/arch/": "",
+	} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if _, got := parseGenericPath(r); got != want {
+			t.Errorf("parseGenericPath(%s) = %q, want %q", path, got, want)
+		}
+	}
+}
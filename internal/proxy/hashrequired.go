@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+// In hash-required mode a package is only served when a loaded index gives
+// its SHA256, so every byte APT receives has been checked against that hash.
+// By default a package with no index entry (a repository whose indexes never
+// went through the proxy, or a misconfigured third-party repo) is streamed
+// from the mirror unverified; hash-required refuses it instead, except for
+// the repositories the operator exempts.
+
+// normalizeRepoPrefix turns an exemption entry into the form matched by
+// hashExempt: no scheme, a lower-case host and no trailing slash.
+func normalizeRepoPrefix(prefix string) string {
+	prefix = strings.TrimSpace(prefix)
+	if i := strings.Index(prefix, "://"); i >= 0 {
+		prefix = prefix[i+3:]
+	}
+	prefix = strings.TrimRight(prefix, "/")
+	host, path, _ := strings.Cut(prefix, "/")
+	if path == "" {
+		return strings.ToLower(host)
+	}
+	return strings.ToLower(host) + "/" + path
+}
+
+// hashExempt reports whether rawURL belongs to a repository exempt from
+// hash-required mode. A prefix matches whole path segments, so "host/ppa"
+// covers host/ppa/pool/... but not host/ppa2/pool/....
+func (s *Server) hashExempt(rawURL string) bool {
+	if len(s.hashRequiredExempt) == 0 {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	target := strings.ToLower(u.Host) + u.Path
+	for _, prefix := range s.hashRequiredExempt {
+		if target == prefix || strings.HasPrefix(target, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// refuseUnknownHash answers a package request with no index entry when
+// hash-required mode forbids passing it through, and reports whether it did.
+func (s *Server) refuseUnknownHash(log *zap.Logger, w http.ResponseWriter, rawURL string) bool {
+	if !s.hashRequired || s.hashExempt(rawURL) {
+		return false
+	}
+	log.Warn("Refusing package with no index entry (hash_required)",
+		zap.String("url", sanitize.URL(rawURL)))
+	s.writeFailure(w, codeHashUnknown, "debswarm: package has no SHA256 in any loaded index, "+
+		"and hash_required forbids serving it unverified")
+	return true
+}
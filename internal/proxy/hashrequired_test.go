@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/hashutil"
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+func TestHashRequired(t *testing.T) {
+	payload := []byte("package payload")
+	hash := hashutil.HashBytes(payload)
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	server.hashRequired = true
+	host := strings.TrimPrefix(mockMirror.URL, "http://")
+	server.hashRequiredExempt = []string{normalizeRepoPrefix("HTTP://" + strings.ToUpper(host) + "/thirdparty/")}
+
+	indexed := "debian/pool/main/h/hello/hello_2.10_amd64.deb"
+	packages := fmt.Sprintf("Package: hello\nFilename: pool/main/h/hello/hello_2.10_amd64.deb\nSize: %d\nSHA256: %s\n\n", len(payload), hash)
+	if err := server.index.LoadFromData([]byte(packages), mockMirror.URL+"/debian/dists/stable/main/binary-amd64/Packages"); err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{indexed, http.StatusOK},
+		{"debian/pool/main/u/unknown/unknown_1.0_amd64.deb", http.StatusForbidden},
+		{"thirdparty/pool/main/t/tool/tool_1.0_amd64.deb", http.StatusOK},
+		{"thirdparty2/pool/main/t/tool/tool_1.0_amd64.deb", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		pkgURL := mockMirror.URL + "/" + tt.path
+		w := httptest.NewRecorder()
+		server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.path, w.Code, tt.want)
+		}
+		if tt.want == http.StatusForbidden && w.Header().Get(errorHeader) != codeHashUnknown {
+			t.Errorf("%s: %s = %q, want %q", tt.path, errorHeader, w.Header().Get(errorHeader), codeHashUnknown)
+		}
+	}
+}
+
+// A package class that is not cached is still checked against the index:
+// a body that does not match the SHA256 never reaches APT.
+func TestHashRequired_UncachedClass(t *testing.T) {
+	payload := []byte("package payload")
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("tampered payload"))
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	server.hashRequired = true
+	server.classPolicies = map[artifactClass]ClassPolicy{classPackage: {}}
+
+	packages := fmt.Sprintf("Package: hello\nFilename: pool/main/h/hello/hello_2.10_amd64.deb\nSize: %d\nSHA256: %s\n\n", len(payload), hashutil.HashBytes(payload))
+	if err := server.index.LoadFromData([]byte(packages), mockMirror.URL+"/debian/dists/stable/main/binary-amd64/Packages"); err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+
+	pkgURL := mockMirror.URL + "/debian/pool/main/h/hello/hello_2.10_amd64.deb"
+	w := httptest.NewRecorder()
+	server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	if w.Code == http.StatusOK {
+		t.Fatalf("mismatched package served: body %q", w.Body.String())
+	}
+	if got := w.Header().Get(errorHeader); got != codeVerificationFailed {
+		t.Errorf("%s = %q, want %q", errorHeader, got, codeVerificationFailed)
+	}
+	if strings.Contains(w.Body.String(), "tampered") {
+		t.Error("mismatched body was relayed")
+	}
+}
+
+// A response classified as a package by its Content-Type has no index hash,
+// so hash_required refuses it unless its repository is exempt.
+func TestHashRequired_ContentTypePackage(t *testing.T) {
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.debian.binary-package")
+		_, _ = w.Write([]byte("package behind a download link"))
+	}))
+	defer mockMirror.Close()
+
+	srv := serverWith(t, freshMetaCache(t), index.New(t.TempDir(), newTestLogger()))
+	defer shutdownServer(t, srv)
+	srv.hashRequired = true
+	srv.hashRequiredExempt = []string{normalizeRepoPrefix(mockMirror.URL + "/vendor/")}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"download/agent", http.StatusForbidden},
+		{"vendor/download/agent", http.StatusOK},
+	}
+	for _, tt := range tests {
+		url := mockMirror.URL + "/" + tt.path
+		w := httptest.NewRecorder()
+		srv.handlePassthrough(w, httptest.NewRequest(http.MethodGet, "/"+url, nil), url)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.path, w.Code, tt.want)
+		}
+		if tt.want == http.StatusForbidden && w.Header().Get(errorHeader) != codeHashUnknown {
+			t.Errorf("%s: %s = %q, want %q", tt.path, errorHeader, w.Header().Get(errorHeader), codeHashUnknown)
+		}
+	}
+}
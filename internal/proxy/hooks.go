@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/audit"
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/hooks"
+	"github.com/debswarm/debswarm/internal/requestid"
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+// hookPackage builds the hooks' view of a verified package. data holds the
+// content of a package served from memory; nil reads it from the cache.
+func (s *Server) hookPackage(hash, path string, size int64, source string, data []byte) *hooks.Package {
+	pkg := &hooks.Package{SHA256: hash, Filename: path, Size: size, Source: source}
+	if data != nil {
+		pkg.Open = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+	} else {
+		pkg.Open = func() (io.ReadCloser, error) {
+			rc, _, err := s.cache.Get(hash)
+			return rc, err
+		}
+	}
+	return pkg
+}
+
+// contentVerified runs the content-verified hooks for a package that was just
+// downloaded and passed hash verification.
+func (s *Server) contentVerified(hash, path string, size int64, source string, data []byte) {
+	if s.hooks.Empty() {
+		return
+	}
+	s.hooks.ContentVerified(s.announceCtx, s.hookPackage(hash, path, size, source, data))
+}
+
+// allowAnnounce runs the pre-announce hooks for a cached package, after
+// making sure it has been scanned when scanning is on. pkg may be nil, in
+// which case it is looked up.
+func (s *Server) allowAnnounce(ctx context.Context, hash string, pkg *cache.Package) bool {
+	if !s.scannedForAnnounce(hash) {
+		return false
+	}
+	if !s.hooks.HasPreAnnounce() && !s.restrictsSharing() {
+		return true
+	}
+	if pkg == nil {
+		var err error
+		if pkg, err = s.cache.Info(hash); err != nil {
+			return false
+		}
+	}
+	return s.allowShare(ctx, s.hookPackage(hash, pkg.Filename, pkg.Size, "cache", nil))
+}
+
+// allowShare applies the sharing policy and the pre-announce hooks to a
+// package about to be announced.
+func (s *Server) allowShare(ctx context.Context, hp *hooks.Package) bool {
+	if !s.policyForURL(hp.Filename).Share || !s.repoShares(hp.SHA256) {
+		return false
+	}
+	if !s.hooks.HasPreAnnounce() {
+		return true
+	}
+	if err := s.hooks.PreAnnounce(ctx, hp); err != nil {
+		s.noteHookRejection(ctx, err, hp, "")
+		return false
+	}
+	return true
+}
+
+// allowServe runs the pre-serve hooks before pkg is sent to a proxy client.
+// On refusal it answers 403 and returns false.
+func (s *Server) allowServe(w http.ResponseWriter, r *http.Request, pkg *hooks.Package) bool {
+	if !s.hooks.HasPreServe() {
+		return true
+	}
+	ctx := r.Context()
+	err := s.hooks.PreServe(ctx, pkg, hooks.Target{ID: r.RemoteAddr})
+	if err == nil {
+		return true
+	}
+	s.noteHookRejection(ctx, err, pkg, "")
+	s.writeFailure(w, codeHookRejected, "debswarm: package refused by "+hookName(err)+" hook")
+	return false
+}
+
+// allowUpload runs the pre-serve hooks before a cached package is uploaded
+// to a peer.
+func (s *Server) allowUpload(hash string, peerID peer.ID) error {
+	pkg, err := s.cache.Info(hash)
+	if err != nil {
+		return err
+	}
+	hp := s.hookPackage(hash, pkg.Filename, pkg.Size, "cache", nil)
+	if err := s.hooks.PreServe(s.announceCtx, hp, hooks.Target{Peer: true, ID: peerID.String()}); err != nil {
+		s.noteHookRejection(s.announceCtx, err, hp, peerID.String())
+		return err
+	}
+	return nil
+}
+
+// noteHookRejection logs, counts and audits a package a hook refused.
+func (s *Server) noteHookRejection(ctx context.Context, err error, pkg *hooks.Package, peerID string) {
+	stage, reason := "", err.Error()
+	var rej *hooks.RejectedError
+	if errors.As(err, &rej) {
+		stage, reason = rej.Stage, rej.Err.Error()
+	}
+	requestid.LoggerFromContext(ctx, s.logger).Warn("Package refused by hook",
+		zap.String("hook", hookName(err)),
+		zap.String("stage", stage),
+		zap.String("path", sanitize.Path(pkg.Filename)),
+		zap.String("peer", peerID),
+		zap.Error(err))
+	s.metrics.HookRejections.WithLabel(stage).Inc()
+	event := audit.NewHookRejectedEvent(hookName(err), stage, pkg.SHA256, pkg.Filename, peerID, reason).
+		WithRequest(ctx)
+	if id, decodeErr := peer.Decode(peerID); decodeErr == nil && s.scorer != nil {
+		event = event.WithPeerName(s.scorer.Name(id))
+	}
+	s.audit.Log(event)
+}
+
+func hookName(err error) string {
+	var rej *hooks.RejectedError
+	if errors.As(err, &rej) {
+		return rej.Hook
+	}
+	return "unknown"
+}
+
+// resultHookPackage is hookPackage for a finished download.
+func (s *Server) resultHookPackage(r *packageDownloadResult, path string) *hooks.Package {
+	if r.serveFromCache {
+		return s.hookPackage(r.hash, path, r.size, r.source, nil)
+	}
+	return s.hookPackage(r.hash, path, int64(len(r.data)), r.source, r.data)
+}
@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/hooks"
+)
+
+// scanHook records verified packages and refuses to serve those in deny.
+type scanHook struct {
+	mu       sync.Mutex
+	verified map[string][]byte
+	deny     map[string]bool
+}
+
+func (h *scanHook) Name() string { return "scan" }
+
+func (h *scanHook) ContentVerified(_ context.Context, pkg *hooks.Package) {
+	rc, err := pkg.Open()
+	if err != nil {
+		return
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(rc)
+	h.mu.Lock()
+	h.verified[pkg.SHA256] = data
+	h.mu.Unlock()
+}
+
+func (h *scanHook) PreServe(_ context.Context, pkg *hooks.Package, _ hooks.Target) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.deny[pkg.SHA256] {
+		return errors.New("infected")
+	}
+	return nil
+}
+
+func TestPipelineHooks(t *testing.T) {
+	payload := []byte("hooked payload")
+	sum := sha256.Sum256(payload)
+	hash := hex.EncodeToString(sum[:])
+
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	hook := &scanHook{verified: map[string][]byte{}, deny: map[string]bool{}}
+	server.hooks = hooks.NewChain([]hooks.Hook{hook}, server.logger)
+
+	pkgPath := "pool/main/h/hooked/hooked_1.0_amd64.deb"
+	packages := fmt.Sprintf("Package: hooked\nVersion: 1.0\nArchitecture: amd64\nFilename: %s\nSize: %d\nSHA256: %s\n\n",
+		pkgPath, len(payload), hash)
+	if err := server.index.LoadFromData([]byte(packages), mockMirror.URL+"/dists/stable/main/binary-amd64/Packages"); err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+	pkgURL := mockMirror.URL + "/" + pkgPath
+
+	get := func() *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+		return w
+	}
+
+	if w := get(); w.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", w.Code)
+	}
+	hook.mu.Lock()
+	got := hook.verified[hash]
+	hook.mu.Unlock()
+	if string(got) != string(payload) {
+		t.Errorf("content-verified hook saw %q, want %q", got, payload)
+	}
+
+	hook.mu.Lock()
+	hook.deny[hash] = true
+	hook.mu.Unlock()
+	w := get()
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("refused cache hit: status %d, want 403", w.Code)
+	}
+	if got := server.metrics.HookRejections.WithLabel(hooks.StagePreServe).Value(); got != 1 {
+		t.Errorf("pre_serve rejections = %d, want 1", got)
+	}
+}
@@ -0,0 +1,311 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/downloader"
+	"github.com/debswarm/debswarm/internal/requestid"
+)
+
+// inflightDownload is a package download that later requests for the same
+// hash can attach to. Plain singleflight made every coalesced APT client wait
+// for the whole file before receiving its first byte; with this, a download
+// that streams from the mirror is also spooled to disk as it arrives, and
+// followers stream from the spool right behind the leader, so they finish at
+// nearly the same time instead of one after another.
+//
+// The final byte is held back from followers until the leader's download has
+// been verified against the index hash. A download that fails verification
+// therefore always leaves followers with a short (Content-Length mismatched)
+// response that APT rejects, never a complete but unverified one.
+//
+// Downloads that do not stream (P2P and fleet transfers, which are verified
+// as a whole) never start a spool; followers wait for the result as before.
+type inflightDownload struct {
+	hash string
+	size int64 // expected size from the index (0 = unknown, cannot stream)
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	spool   *os.File // bytes received so far; nil until a mirror stream starts
+	written int64
+	broken  bool // spool write failed; followers give up rather than stall
+	refs    int  // spool users: the writer plus each streaming follower
+	done    bool
+	result  *packageDownloadResult
+	err     error
+}
+
+// inflightRegistry tracks the package downloads followers may attach to.
+type inflightRegistry struct {
+	mu sync.Mutex
+	m  map[string]*inflightDownload
+}
+
+// join returns the in-flight download for hash, registering a new one if
+// there is none. leader is true for the caller that registered it; that
+// caller must run the download and call finish.
+func (r *inflightRegistry) join(hash string, size int64) (fl *inflightDownload, leader bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if fl := r.m[hash]; fl != nil {
+		return fl, false
+	}
+	if r.m == nil {
+		r.m = make(map[string]*inflightDownload)
+	}
+	fl = &inflightDownload{hash: hash, size: size}
+	fl.cond = sync.NewCond(&fl.mu)
+	r.m[hash] = fl
+	return fl, true
+}
+
+// get returns the in-flight download for hash, or nil.
+func (r *inflightRegistry) get(hash string) *inflightDownload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.m[hash]
+}
+
+// finish unregisters fl and publishes the leader's result to its followers.
+// Requests arriving afterwards start afresh (and normally hit the cache).
+func (r *inflightRegistry) finish(fl *inflightDownload, result *packageDownloadResult, err error) {
+	r.mu.Lock()
+	if r.m[fl.hash] == fl {
+		delete(r.m, fl.hash)
+	}
+	r.mu.Unlock()
+
+	fl.mu.Lock()
+	fl.done = true
+	fl.result = result
+	fl.err = err
+	spooling := fl.spool != nil
+	fl.cond.Broadcast()
+	fl.mu.Unlock()
+	if spooling {
+		fl.release() // the writer's reference
+	}
+}
+
+// startSpool creates the spool file in dir so followers can stream the
+// download. It reports false when there is nothing to gain (unknown size) or
+// the spool cannot be created; the download proceeds unshared either way.
+func (fl *inflightDownload) startSpool(dir string) bool {
+	if fl.size <= 0 {
+		return false
+	}
+	f, err := os.CreateTemp(dir, "inflight-"+fl.hash+".*")
+	if err != nil {
+		return false
+	}
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	if fl.spool != nil || fl.done {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return false
+	}
+	fl.spool = f
+	fl.refs = 1
+	fl.cond.Broadcast()
+	return true
+}
+
+// Write appends downloaded bytes to the spool and wakes followers. It never
+// fails: a spool error must not abort the leader's own download, so it only
+// marks the spool broken.
+func (fl *inflightDownload) Write(p []byte) (int, error) {
+	fl.mu.Lock()
+	broken := fl.broken
+	fl.mu.Unlock()
+	if broken {
+		return len(p), nil
+	}
+
+	n, err := fl.spool.Write(p)
+
+	fl.mu.Lock()
+	fl.written += int64(n)
+	if err != nil {
+		fl.broken = true
+	}
+	fl.cond.Broadcast()
+	fl.mu.Unlock()
+	return len(p), nil
+}
+
+// release drops a spool reference, removing the spool with the last one. A
+// follower arriving after that is served the finished result instead.
+func (fl *inflightDownload) release() {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	fl.refs--
+	if fl.refs == 0 && fl.spool != nil {
+		name := fl.spool.Name()
+		_ = fl.spool.Close()
+		_ = os.Remove(name)
+		fl.spool = nil
+	}
+}
+
+// wake rouses waiting followers so they can notice a cancelled request.
+func (fl *inflightDownload) wake() {
+	fl.mu.Lock()
+	fl.cond.Broadcast()
+	fl.mu.Unlock()
+}
+
+// spoolDir is where in-flight spools are written: the cache's pending
+// directory, so they share the cache's filesystem and disk budget.
+func (s *Server) spoolDir() string {
+	return filepath.Join(s.cache.BasePath(), "packages", "pending")
+}
+
+// serveInflight serves a request that arrived while the same package was
+// already being downloaded. It reports whether the whole package was sent.
+func (s *Server) serveInflight(w http.ResponseWriter, r *http.Request, fl *inflightDownload, log *zap.Logger) bool {
+	ctx := r.Context()
+	stop := context.AfterFunc(ctx, fl.wake)
+	defer stop()
+
+	fl.mu.Lock()
+	for fl.spool == nil && !fl.done && ctx.Err() == nil {
+		fl.cond.Wait()
+	}
+	if fl.spool == nil || fl.broken {
+		for !fl.done && ctx.Err() == nil {
+			fl.cond.Wait()
+		}
+		done, result, err := fl.done, fl.result, fl.err
+		fl.mu.Unlock()
+		if !done {
+			return false // client went away
+		}
+		if err != nil {
+			log.Error("Download failed", zap.Error(err))
+			s.writeFetchFailure(w, "failed to fetch package", err)
+			return false
+		}
+		s.servePackageResult(w, result)
+		return true
+	}
+	fl.refs++
+	spool := fl.spool
+	fl.mu.Unlock()
+	defer fl.release()
+
+	s.metrics.InflightStreams.Inc()
+	log.Debug("Streaming package from in-flight download",
+		zap.String("hash", fl.hash[:16]+"..."))
+
+	w.Header().Set("Content-Type", "application/vnd.debian.binary-package")
+	w.Header().Set("Content-Length", strconv.FormatInt(fl.size, 10))
+	w.Header().Set("X-Debswarm-Source", downloader.SourceTypeMirror)
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	buf := make([]byte, 32*1024)
+	var off int64
+	for off < fl.size {
+		fl.mu.Lock()
+		limit := fl.available()
+		for limit <= off && !fl.done && !fl.broken && ctx.Err() == nil {
+			fl.cond.Wait()
+			limit = fl.available()
+		}
+		failed := fl.broken || (fl.done && fl.err != nil)
+		fl.mu.Unlock()
+
+		if failed || ctx.Err() != nil || limit <= off {
+			// Verification failed, the spool broke, or the client left: end
+			// the response short so APT discards it.
+			return false
+		}
+
+		n := min(limit-off, int64(len(buf)))
+		read, err := spool.ReadAt(buf[:n], off)
+		if read > 0 {
+			if _, werr := w.Write(buf[:read]); werr != nil {
+				return false
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			off += int64(read)
+		}
+		if err != nil && read == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// serveStreamed runs the download registered as fl in the background and
+// serves the leading request from its spool like a follower's. A package
+// that comes from the mirror then reaches APT as it arrives, hashed on the
+// way into the cache, instead of after it has been written and verified in
+// full. Should verification fail, the response ends one byte short of its
+// Content-Length, which closes the connection; APT discards the file and
+// retries. Downloads that do not stream are served when they complete, as
+// before.
+func (s *Server) serveStreamed(w http.ResponseWriter, r *http.Request, fl *inflightDownload, url, expectedHash string, expectedSize int64, path string) {
+	ctx := r.Context()
+	log := requestid.LoggerFromContext(ctx, s.logger)
+
+	go func() {
+		dctx, cancel := s.detachDownload(ctx)
+		defer cancel()
+		led := false
+		result, err, shared := s.downloadGroup.Do(expectedHash, func() (interface{}, error) {
+			led = true
+			res, err := s.downloadPackage(dctx, url, expectedHash, expectedSize, path)
+			if err == nil {
+				s.recordOrigin(url, expectedHash)
+			}
+			return res, err
+		})
+		if shared && !led {
+			s.metrics.CoalescedRequests.WithLabel("package").Inc()
+		}
+		var downloadResult *packageDownloadResult
+		if err == nil {
+			downloadResult = result.(*packageDownloadResult)
+		}
+		s.inflight.finish(fl, downloadResult, err)
+	}()
+
+	if s.serveInflight(w, r, fl, log) {
+		s.packageServed(ctx, expectedHash, url, path, expectedSize)
+	}
+}
+
+// detachDownload returns ctx without its cancellation, for a download that
+// requests other than the one that started it are attached to: the leader
+// leaving, or its spool breaking, must not cut the followers off. The
+// download still stops when the server shuts down.
+func (s *Server) detachDownload(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(s.announceCtx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// available returns how many spooled bytes a follower may send. Until the
+// download is verified the last byte of the package is withheld. Callers
+// hold fl.mu.
+func (fl *inflightDownload) available() int64 {
+	if fl.done && fl.err == nil {
+		return fl.written
+	}
+	return min(fl.written, fl.size-1)
+}
@@ -0,0 +1,278 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// signalRecorder is a ResponseRecorder that is safe to inspect while the
+// handler is still writing, and signals once the body reaches a threshold.
+type signalRecorder struct {
+	mu        sync.Mutex
+	header    http.Header
+	code      int
+	body      bytes.Buffer
+	threshold int
+	reached   chan struct{}
+}
+
+func newSignalRecorder(threshold int) *signalRecorder {
+	return &signalRecorder{header: make(http.Header), threshold: threshold, reached: make(chan struct{})}
+}
+
+func (r *signalRecorder) Header() http.Header { return r.header }
+
+func (r *signalRecorder) WriteHeader(code int) {
+	r.mu.Lock()
+	r.code = code
+	r.mu.Unlock()
+}
+
+func (r *signalRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	before := r.body.Len()
+	r.body.Write(p)
+	if before < r.threshold && r.body.Len() >= r.threshold {
+		close(r.reached)
+	}
+	return len(p), nil
+}
+
+func (r *signalRecorder) bytes() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]byte(nil), r.body.Bytes()...)
+}
+
+// stallingMirror serves the first half of payload, then blocks until release
+// is closed before sending the rest.
+func stallingMirror(payload []byte, release <-chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(payload)))
+		w.WriteHeader(http.StatusOK)
+		half := len(payload) / 2
+		_, _ = w.Write(payload[:half])
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = w.Write(payload[half:])
+	}))
+}
+
+// waitForSpool waits until the in-flight download for hash has spooled at
+// least n bytes.
+func waitForSpool(t *testing.T, server *Server, hash string, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if fl := server.inflight.get(hash); fl != nil {
+			fl.mu.Lock()
+			written := fl.written
+			fl.mu.Unlock()
+			if written >= n {
+				return
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("in-flight download never spooled %d bytes", n)
+}
+
+// TestInflight_FollowerStreamsBeforeDownloadCompletes verifies that a second
+// request for a package being downloaded receives bytes while the leader's
+// mirror stream is still in progress, and ends with the full package.
+func TestInflight_FollowerStreamsBeforeDownloadCompletes(t *testing.T) {
+	payload := make([]byte, 256*1024)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	hash := sha256Hex(payload)
+	release := make(chan struct{})
+	mockMirror := stallingMirror(payload, release)
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	pkgURL := indexPackage(t, server, mockMirror.URL, "pool/main/s/streampkg/streampkg_1.0_amd64.deb", payload)
+
+	leaderW := httptest.NewRecorder()
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		server.handlePackageRequest(leaderW, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	}()
+	waitForSpool(t, server, hash, int64(len(payload)/2))
+
+	follower := newSignalRecorder(len(payload) / 4)
+	followerDone := make(chan struct{})
+	go func() {
+		defer close(followerDone)
+		server.handlePackageRequest(follower, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	}()
+
+	select {
+	case <-follower.reached:
+	case <-time.After(5 * time.Second):
+		t.Fatal("follower received no bytes while the download was in progress")
+	}
+	close(release)
+	<-leaderDone
+	<-followerDone
+
+	if leaderW.Code != http.StatusOK || !bytes.Equal(leaderW.Body.Bytes(), payload) {
+		t.Fatalf("leader: status %d, len %d", leaderW.Code, leaderW.Body.Len())
+	}
+	if got := follower.bytes(); !bytes.Equal(got, payload) {
+		t.Fatalf("follower body mismatch: len %d, want %d", len(got), len(payload))
+	}
+	if got := server.metrics.InflightStreams.Value(); got != 1 {
+		t.Errorf("InflightStreams = %d, want 1", got)
+	}
+	if server.inflight.get(hash) != nil {
+		t.Error("in-flight entry not removed after completion")
+	}
+}
+
+// TestInflight_VerificationFailureTruncatesFollower verifies that when the
+// download fails hash verification, a follower never receives a complete body:
+// the final byte is withheld until verification succeeds.
+func TestInflight_VerificationFailureTruncatesFollower(t *testing.T) {
+	good := bytes.Repeat([]byte("g"), 64*1024)
+	evil := bytes.Repeat([]byte("e"), len(good))
+	hash := sha256Hex(good)
+	release := make(chan struct{})
+	mockMirror := stallingMirror(evil, release)
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	pkgURL := indexPackage(t, server, mockMirror.URL, "pool/main/s/streampkg/streampkg_1.0_amd64.deb", good)
+
+	leaderW := httptest.NewRecorder()
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		server.handlePackageRequest(leaderW, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	}()
+	waitForSpool(t, server, hash, int64(len(evil)/2))
+
+	follower := newSignalRecorder(1)
+	followerDone := make(chan struct{})
+	go func() {
+		defer close(followerDone)
+		server.handlePackageRequest(follower, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	}()
+	<-follower.reached
+	close(release)
+	<-leaderDone
+	<-followerDone
+
+	if leaderW.Code != http.StatusBadGateway {
+		t.Fatalf("leader status = %d, want 502", leaderW.Code)
+	}
+	if got := len(follower.bytes()); got >= len(evil) {
+		t.Fatalf("follower received %d bytes of an unverified %d-byte package", got, len(evil))
+	}
+	if server.cache.Count() != 0 {
+		t.Error("mismatched content was cached")
+	}
+}
+
+// TestInflight_FollowerWaitsForNonStreamingResult verifies that a follower of
+// a download with no spool (unknown size) is served the completed result.
+func TestInflight_FollowerWaitsForNonStreamingResult(t *testing.T) {
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+
+	payload := []byte("in-memory result")
+	hash := sha256Hex(payload)
+	fl, leader := server.inflight.join(hash, 0)
+	if !leader {
+		t.Fatal("first join must lead")
+	}
+	if fl.startSpool(server.spoolDir()) {
+		t.Fatal("spool must not start for an unknown size")
+	}
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.serveInflight(w, httptest.NewRequest("GET", "/", nil), fl, server.logger)
+	}()
+	server.inflight.finish(fl, &packageDownloadResult{data: payload, hash: hash, source: "peer"}, nil)
+	<-done
+
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), payload) {
+		t.Fatalf("status %d, body %q", w.Code, w.Body.String())
+	}
+}
+
+// TestInflight_LeaderLeavingKeepsDownload verifies that the client that
+// started a streamed download can go away without cancelling the download
+// its followers are attached to, and that only the request served in full
+// counts as a download in the package statistics.
+func TestInflight_LeaderLeavingKeepsDownload(t *testing.T) {
+	payload := make([]byte, 256*1024)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	hash := sha256Hex(payload)
+	release := make(chan struct{})
+	mockMirror := stallingMirror(payload, release)
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	server.streamMirror = true
+	pkgURL := indexPackage(t, server, mockMirror.URL, "pool/main/s/streampkg/streampkg_1.0_amd64.deb", payload)
+
+	leaderCtx, leave := context.WithCancel(context.Background())
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		req := httptest.NewRequest("GET", "/"+pkgURL, nil).WithContext(leaderCtx)
+		server.handlePackageRequest(newSignalRecorder(len(payload)), req, pkgURL)
+	}()
+	waitForSpool(t, server, hash, int64(len(payload)/2))
+
+	follower := newSignalRecorder(len(payload) / 4)
+	followerDone := make(chan struct{})
+	go func() {
+		defer close(followerDone)
+		server.handlePackageRequest(follower, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	}()
+	select {
+	case <-follower.reached:
+	case <-time.After(5 * time.Second):
+		t.Fatal("follower received no bytes while the download was in progress")
+	}
+
+	leave()
+	<-leaderDone
+	close(release)
+	<-followerDone
+
+	if got := follower.bytes(); !bytes.Equal(got, payload) {
+		t.Fatalf("follower body mismatch: len %d, want %d", len(got), len(payload))
+	}
+	if !server.cache.Has(hash) {
+		t.Error("download was not cached after its leader left")
+	}
+	stats, err := server.cache.TopPackages(10)
+	if err != nil {
+		t.Fatalf("TopPackages: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Downloads != 1 {
+		t.Errorf("package stats = %+v, want one download", stats)
+	}
+}
@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/hooks"
+	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/localmirror"
+	"github.com/debswarm/debswarm/internal/p2p"
+)
+
+// localMirrorRescan is how often a local mirror's indexes are checked for
+// changes, so packages a mirror sync adds are announced the same hour
+const localMirrorRescan = time.Hour
+
+// ServeLocalMirror serves the packages of an existing mirror tree to peers,
+// reading them in place, and keeps them announced. With a malware scanner
+// configured, each package is scanned before it is first announced or
+// served, like a package entering the cache. It runs until ctx is canceled.
+func (s *Server) ServeLocalMirror(ctx context.Context, m *localmirror.Mirror) {
+	screen := &mirrorScreen{clean: make(map[string]bool)}
+	s.AddContentProvider(p2p.ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+		pkg := m.Lookup(hash)
+		if pkg == nil || !s.policyForURL(pkg.Filename).Share || !s.screenMirrorPackage(m, pkg, screen) {
+			return nil, 0, cache.ErrNotFound
+		}
+		return m.Open(hash)
+	}))
+
+	// announced holds when each package's provider record expires
+	announced := make(map[string]time.Time)
+	ticker := time.NewTicker(localMirrorRescan)
+	defer ticker.Stop()
+	for {
+		if _, err := m.Load(); err != nil {
+			s.logger.Warn("Failed to index local mirror",
+				zap.String("root", m.Root()), zap.Error(err))
+		}
+		s.announceLocalMirror(ctx, m, announced, screen)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// mirrorScreen holds the scanner's verdicts on local mirror packages, so
+// each is scanned once: true when clean, false when quarantined. A failed
+// scan records nothing and is retried.
+type mirrorScreen struct {
+	mu    sync.Mutex
+	clean map[string]bool
+}
+
+// screenMirrorPackage reports whether a local mirror package may be
+// announced and served, scanning it the first time when a scanner is
+// configured.
+func (s *Server) screenMirrorPackage(m *localmirror.Mirror, pkg *index.PackageInfo, screen *mirrorScreen) bool {
+	if s.scanner == nil {
+		return true
+	}
+	screen.mu.Lock()
+	clean, known := screen.clean[pkg.SHA256]
+	screen.mu.Unlock()
+	if known {
+		return clean
+	}
+
+	rc, size, err := m.Open(pkg.SHA256)
+	if err != nil {
+		return false
+	}
+	defer rc.Close()
+	err = s.scanContent(rc, size, pkg.SHA256, pkg.Filename)
+	if err != nil && !errors.Is(err, cache.ErrQuarantined) {
+		return false
+	}
+	screen.mu.Lock()
+	screen.clean[pkg.SHA256] = err == nil
+	screen.mu.Unlock()
+	return err == nil
+}
+
+// announceLocalMirror announces the mirror's packages that were never
+// announced or whose provider records expire within reannounceMargin, and
+// forgets packages the mirror no longer lists.
+func (s *Server) announceLocalMirror(ctx context.Context, m *localmirror.Mirror, announced map[string]time.Time, screen *mirrorScreen) {
+	if s.p2pNode == nil || s.p2pNode.Paused() {
+		return
+	}
+	// Like reannouncement, this is background work that yields to
+	// announcements of freshly downloaded packages.
+	ctx = p2p.WithPriority(ctx, p2p.PriorityLow)
+	due := time.Now().Add(reannounceMargin(s.providerTTL))
+
+	pkgs := m.Packages()
+	listed := make(map[string]bool, len(pkgs))
+	const maxConcurrent = 4
+	sem := make(chan struct{}, maxConcurrent)
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, pkg := range pkgs {
+		listed[pkg.SHA256] = true
+		mu.Lock()
+		expires, ok := announced[pkg.SHA256]
+		mu.Unlock()
+		if ok && expires.After(due) {
+			continue
+		}
+		if _, revoked := s.revocations.IsRevoked(pkg.SHA256); revoked {
+			continue
+		}
+		if !s.screenMirrorPackage(m, pkg, screen) || !s.allowShare(ctx, mirrorHookPackage(m, pkg)) {
+			s.metrics.Announcements.WithLabel("refused").Inc()
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(hash string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			node := s.nodeForHash(hash)
+			if node == nil {
+				s.metrics.Announcements.WithLabel("refused").Inc()
+				return
+			}
+			if err := node.Provide(ctx, hash); err != nil {
+				s.metrics.Announcements.WithLabel("failed").Inc()
+				s.logger.Debug("Failed to announce local mirror package",
+					zap.String("hash", hash[:min(16, len(hash))]+"..."), zap.Error(err))
+				return
+			}
+			s.metrics.Announcements.WithLabel("ok").Inc()
+			mu.Lock()
+			announced[hash] = time.Now().Add(s.providerTTL)
+			mu.Unlock()
+		}(pkg.SHA256)
+	}
+	wg.Wait()
+
+	for hash := range announced {
+		if !listed[hash] {
+			delete(announced, hash)
+		}
+	}
+	screen.mu.Lock()
+	for hash := range screen.clean {
+		if !listed[hash] {
+			delete(screen.clean, hash)
+		}
+	}
+	screen.mu.Unlock()
+}
+
+// mirrorHookPackage builds the hooks' view of a local mirror package.
+func mirrorHookPackage(m *localmirror.Mirror, pkg *index.PackageInfo) *hooks.Package {
+	hash := pkg.SHA256
+	return &hooks.Package{
+		SHA256:   hash,
+		Filename: pkg.Filename,
+		Size:     pkg.Size,
+		Source:   "local-mirror",
+		Open: func() (io.ReadCloser, error) {
+			rc, _, err := m.Open(hash)
+			return rc, err
+		},
+	}
+}
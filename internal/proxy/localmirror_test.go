@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/hashutil"
+	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/localmirror"
+)
+
+func TestServeLocalMirror(t *testing.T) {
+	srv := serverWith(t, newTestCache(t), index.New(t.TempDir(), newTestLogger()))
+	defer shutdownServer(t, srv)
+	srv.scanner = fakeScanner{}
+
+	root := t.TempDir()
+	deb := []byte("a package in the mirror tree")
+	hash := hashutil.HashBytes(deb)
+	debPath := filepath.Join(root, "pool", "main", "h", "hello", "hello_1.0_amd64.deb")
+	packagesPath := filepath.Join(root, "dists", "bookworm", "main", "binary-amd64", "Packages")
+	for _, dir := range []string{filepath.Dir(debPath), filepath.Dir(packagesPath)} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(debPath, deb, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	infected := []byte("EICAR in the mirror tree")
+	infectedHash := hashutil.HashBytes(infected)
+	if err := os.WriteFile(filepath.Join(filepath.Dir(debPath), "evil_1.0_amd64.deb"), infected, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stanza := fmt.Sprintf("Package: hello\nFilename: pool/main/h/hello/hello_1.0_amd64.deb\nSize: %d\nSHA256: %s\n\n", len(deb), hash) +
+		fmt.Sprintf("Package: evil\nFilename: pool/main/h/hello/evil_1.0_amd64.deb\nSize: %d\nSHA256: %s\n", len(infected), infectedHash)
+	if err := os.WriteFile(packagesPath, []byte(stanza), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.ServeLocalMirror(ctx, localmirror.New(root, newTestLogger()))
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	content := srv.peerContent(nil)
+	var (
+		got []byte
+		err error
+	)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		var rc io.ReadCloser
+		if rc, _, err = content.Open(hash); err == nil {
+			got, err = io.ReadAll(rc)
+			rc.Close()
+			break
+		}
+	}
+	if err != nil || !bytes.Equal(got, deb) {
+		t.Fatalf("mirror package = %q, %v", got, err)
+	}
+
+	// The package was served in place, not imported
+	if srv.cache.Has(hash) {
+		t.Error("mirror package was copied into the cache")
+	}
+
+	// Mirror packages pass the malware scanner like cached ones
+	if rc, _, err := content.Open(infectedHash); err == nil {
+		rc.Close()
+		t.Error("mirror package flagged by the scanner was served")
+	}
+}
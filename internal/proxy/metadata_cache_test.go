@@ -2,8 +2,6 @@ package proxy
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -48,11 +46,6 @@ func (m *countingMirror) handler() http.HandlerFunc {
 	}
 }
 
-func sha256Hex(b []byte) string {
-	s := sha256.Sum256(b)
-	return hex.EncodeToString(s[:])
-}
-
 func serverWith(t *testing.T, c *cache.Cache, idx *index.Index) *Server {
 	t.Helper()
 	cfg := &Config{
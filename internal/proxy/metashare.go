@@ -0,0 +1,221 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/downloader"
+	"github.com/debswarm/debswarm/internal/p2p"
+	"github.com/debswarm/debswarm/internal/release"
+	"github.com/debswarm/debswarm/internal/requestid"
+	"github.com/debswarm/debswarm/internal/sanitize"
+	"github.com/debswarm/debswarm/internal/timeouts"
+)
+
+const (
+	// maxMetadataProviders is how many peers are tried for one index file
+	// before the mirror is asked instead
+	maxMetadataProviders = 3
+	// metadataAnnounceTimeout bounds advertising one index file in the DHT
+	metadataAnnounceTimeout = 30 * time.Second
+)
+
+// Results of sharing an index, the labels of debswarm_metadata_p2p_total
+const (
+	metaShareCurrent     = "current"      // cached copy still listed by the Release
+	metaShareDownloaded  = "downloaded"   // fetched from a peer
+	metaShareNoProviders = "no_providers" // no peer advertised it
+	metaShareFailed      = "failed"       // providers found but none delivered
+	metaShareUploaded    = "uploaded"     // served to a peer
+)
+
+var errMetadataMismatch = errors.New("index does not match its Release hash")
+
+// listedIndexHash returns the SHA256 and size the signature-verified Release
+// lists for the index at rawURL. ok is false when no verified Release is
+// available or it does not list the file, in which case the index is only
+// ever fetched from the mirror.
+func (s *Server) listedIndexHash(rawURL string) (hash string, size int64, ok bool) {
+	return s.listedIndexHashIn(rawURL, s.obtainRelease)
+}
+
+// listedIndexHashIn is listedIndexHash with the verified Release for a base
+// URL found by obtain.
+func (s *Server) listedIndexHashIn(rawURL string, obtain func(base string) *release.Release) (hash string, size int64, ok bool) {
+	if s.keyringFor(rawURL).Empty() || !isVerifiableIndexURL(rawURL) {
+		return "", 0, false
+	}
+	base := verificationBaseURL(rawURL)
+	if base == "" {
+		return "", 0, false
+	}
+	rel := obtain(base)
+	if rel == nil {
+		return "", 0, false
+	}
+	if digest, byHash := byHashSHA256(rel, rawURL); byHash {
+		size, ok := rel.SizeOf(digest)
+		return digest, size, ok
+	}
+	fh, ok := rel.SHA256[strings.TrimPrefix(rawURL, base)]
+	return fh.SHA256, fh.Size, ok
+}
+
+// serveSharedIndex serves an index file by the hash its signed Release lists:
+// from the cache when the cached copy is still the listed one, otherwise
+// from a peer that has it, so a fleet running apt update asks the mirror
+// for each index about once. The Release itself always comes from the
+// mirror. It reports whether it served the request; on false the caller
+// goes to the mirror as before.
+func (s *Server) serveSharedIndex(w http.ResponseWriter, r *http.Request, url string) bool {
+	if !s.policyForURL(url).Share {
+		return false
+	}
+	hash, size, ok := s.listedIndexHash(url)
+	if !ok {
+		return false
+	}
+	ctx := r.Context()
+	log := requestid.LoggerFromContext(ctx, s.logger)
+
+	if entry, rc, err := s.cache.GetMetadata(url); err == nil {
+		if entry.SHA256 == hash {
+			s.metrics.MetadataP2P.WithLabel(metaShareCurrent).Inc()
+			s.serveCachedMetadata(w, r, url, true, entry, rc, false)
+			return true
+		}
+		_ = rc.Close()
+	}
+
+	node := s.nodeForURL(url)
+	if node == nil || s.p2pPaused() || !sourcePolicyFrom(ctx).allowsPeers() {
+		return false
+	}
+	led := false
+	_, err, shared := s.metadataGroup.Do(hash, func() (interface{}, error) {
+		led = true
+		data, err := s.fetchIndexFromPeers(ctx, node, hash, size)
+		if err != nil {
+			return nil, err
+		}
+		s.storeMetadata(url, data, "", "", "application/octet-stream", log)
+		s.announceIndex(url, hash)
+		return nil, nil
+	})
+	if shared && !led {
+		s.metrics.CoalescedRequests.WithLabel("metadata").Inc()
+	}
+	if err != nil {
+		log.Debug("Index not available from peers, using mirror",
+			zap.String("url", sanitize.URL(url)), zap.Error(err))
+		return false
+	}
+	entry, rc, err := s.cache.GetMetadata(url)
+	if err != nil || entry.SHA256 != hash {
+		// Evicted or replaced in the meantime
+		if rc != nil {
+			_ = rc.Close()
+		}
+		return false
+	}
+	noteSource(ctx, downloader.SourceTypePeer)
+	s.serveCachedMetadata(w, r, url, true, entry, rc, false)
+	return true
+}
+
+// fetchIndexFromPeers downloads the index with the given Release hash and
+// size from the best few peers advertising it. A peer serving other bytes
+// is blacklisted, as for packages.
+func (s *Server) fetchIndexFromPeers(ctx context.Context, node *p2p.Node, hash string, size int64) ([]byte, error) {
+	dhtCtx, cancel := context.WithTimeout(p2p.WithPriority(ctx, p2p.PriorityHigh), s.timeouts.Get(timeouts.OpDHTLookup))
+	providers, err := node.FindProvidersRanked(dhtCtx, hash, maxMetadataProviders)
+	cancel()
+	if err != nil || len(providers) == 0 {
+		s.metrics.MetadataP2P.WithLabel(metaShareNoProviders).Inc()
+		if err == nil {
+			err = errNoProviders
+		}
+		return nil, err
+	}
+
+	lastErr := errPeersFailed
+	for _, p := range providers[:min(maxMetadataProviders, len(providers))] {
+		peerCtx, peerCancel := context.WithTimeout(ctx, s.p2pTimeout)
+		data, err := node.Download(peerCtx, p, hash)
+		peerCancel()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if int64(len(data)) != size || sha256Hex(data) != hash {
+			s.strikePeer(p.ID, "index hash mismatch")
+			lastErr = errMetadataMismatch
+			continue
+		}
+		s.metrics.MetadataP2P.WithLabel(metaShareDownloaded).Inc()
+		return data, nil
+	}
+	s.metrics.MetadataP2P.WithLabel(metaShareFailed).Inc()
+	return nil, fmt.Errorf("index from peers: %w", lastErr)
+}
+
+// shareFetchedIndex advertises an index just fetched from the mirror when
+// it is the copy the signed Release lists, so other nodes can fetch it from
+// this one.
+func (s *Server) shareFetchedIndex(url string, data []byte) {
+	if !s.policyForURL(url).Share {
+		return
+	}
+	hash, _, ok := s.listedIndexHash(url)
+	if !ok || sha256Hex(data) != hash {
+		return
+	}
+	s.announceIndex(url, hash)
+}
+
+// announceIndex advertises a cached index file in the DHT in the
+// background. Index files are not in the package table, so they are not
+// reannounced: each node that fetches a newer index advertises it again.
+func (s *Server) announceIndex(url, hash string) {
+	node := s.nodeForURL(url)
+	if node == nil || node.Paused() {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(s.announceCtx, metadataAnnounceTimeout)
+		defer cancel()
+		if err := node.Provide(ctx, hash); err != nil {
+			if s.announceCtx.Err() == nil {
+				s.metrics.Announcements.WithLabel("failed").Inc()
+				s.logger.Debug("Failed to announce index", zap.String("url", sanitize.URL(url)), zap.Error(err))
+			}
+			return
+		}
+		s.metrics.Announcements.WithLabel("ok").Inc()
+	}()
+}
+
+// indexForPeer returns a cached index file for a peer asking by its hash.
+// Only Packages and Sources indexes of shared classes are served, and only
+// to the swarm their repository belongs to.
+func (s *Server) indexForPeer(node *p2p.Node, hash string) (io.ReadCloser, int64, error) {
+	entry, rc, err := s.cache.GetMetadataByHash(hash)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !isVerifiableIndexURL(entry.URL) || !s.policyForURL(entry.URL).Share ||
+		(len(s.swarms) > 0 && s.nodeForURL(entry.URL) != node) {
+		_ = rc.Close()
+		return nil, 0, cache.ErrNotFound
+	}
+	s.metrics.MetadataP2P.WithLabel(metaShareUploaded).Inc()
+	return rc, entry.Size, nil
+}
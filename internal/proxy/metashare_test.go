@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+func sha512Hex(b []byte) string {
+	sum := sha512.Sum512(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// shareSetup caches a signed InRelease listing pkgBody as the mock mirror's
+// Packages file and returns a server sharing indexes, with no P2P node.
+func shareSetup(t *testing.T, pkgBody []byte) (srv *Server, m *countingMirror, pkgURL string) {
+	t.Helper()
+	m = &countingMirror{body: pkgBody, etag: `"v1"`}
+	mock := httptest.NewServer(m.handler())
+	t.Cleanup(mock.Close)
+
+	dist := mock.URL + "/debian/dists/bookworm/"
+	pkgURL = dist + verPkgRel
+	e, kr := genKeyAndKeyring(t)
+	c := freshMetaCache(t)
+	putMetadata(t, c, dist+"InRelease", clearsignBody(t, e, fmt.Sprintf(
+		"Origin: Debian\nSuite: bookworm\nSHA256:\n %s %d %s\nSHA512:\n %s %d %s\n",
+		sha256Hex(pkgBody), len(pkgBody), verPkgRel, sha512Hex(pkgBody), len(pkgBody), verPkgRel)))
+
+	srv = serverWith(t, c, index.New(t.TempDir(), newTestLogger()))
+	t.Cleanup(func() { shutdownServer(t, srv) })
+	srv.keyring = kr
+	srv.verifyMode = verifyAuto
+	srv.classPolicies = map[artifactClass]ClassPolicy{classIndex: {Cache: true, Share: true}}
+	return srv, m, pkgURL
+}
+
+func TestListedIndexHash(t *testing.T) {
+	pkgBody := []byte("Package: hello\nVersion: 2.10\nArchitecture: amd64\n\n")
+	srv, _, pkgURL := shareSetup(t, pkgBody)
+	want := sha256Hex(pkgBody)
+
+	if hash, size, ok := srv.listedIndexHash(pkgURL); !ok || hash != want || size != int64(len(pkgBody)) {
+		t.Errorf("plain path = %s %d %v, want %s %d", hash, size, ok, want, len(pkgBody))
+	}
+	byHash := pkgURL[:len(pkgURL)-len("Packages")] + "by-hash/SHA256/" + want
+	if hash, size, ok := srv.listedIndexHash(byHash); !ok || hash != want || size != int64(len(pkgBody)) {
+		t.Errorf("by-hash = %s %d %v, want %s %d", hash, size, ok, want, len(pkgBody))
+	}
+	if _, _, ok := srv.listedIndexHash(pkgURL + ".xz"); ok {
+		t.Error("index the Release does not list has a hash")
+	}
+
+	// Asked for by another digest, the index is shared under its SHA256
+	bySHA512 := pkgURL[:len(pkgURL)-len("Packages")] + "by-hash/SHA512/" + sha512Hex(pkgBody)
+	if hash, size, ok := srv.listedIndexHash(bySHA512); !ok || hash != want || size != int64(len(pkgBody)) {
+		t.Errorf("by-hash SHA512 = %s %d %v, want %s %d", hash, size, ok, want, len(pkgBody))
+	}
+	if ok, reason := srv.verifyIndex(bySHA512, pkgBody); !ok {
+		t.Errorf("by-hash SHA512 should verify, got reason=%q", reason)
+	}
+	if ok, reason := srv.verifyIndex(bySHA512, append(pkgBody, 'X')); ok || reason != verifyReasonHashMismatch {
+		t.Errorf("tampered by-hash SHA512: ok=%v reason=%q, want false/hash-mismatch", ok, reason)
+	}
+	unknown := pkgURL[:len(pkgURL)-len("Packages")] + "by-hash/MD5Sum/d41d8cd98f00b204e9800998ecf8427e"
+	if _, _, ok := srv.listedIndexHash(unknown); ok {
+		t.Error("digest the Release does not list has a hash")
+	}
+
+	srv.keyring = nil
+	if _, _, ok := srv.listedIndexHash(pkgURL); ok {
+		t.Error("hash trusted without a keyring")
+	}
+}
+
+func TestServeSharedIndex_ListedCopySkipsMirror(t *testing.T) {
+	pkgBody := []byte("Package: hello\nVersion: 2.10\nArchitecture: amd64\n\n")
+	srv, m, pkgURL := shareSetup(t, pkgBody)
+
+	// The first request goes to the mirror: nothing is cached and there are
+	// no peers to ask
+	w := httptest.NewRecorder()
+	srv.handleIndexRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), pkgBody) {
+		t.Fatalf("first request: %d, %d bytes", w.Code, w.Body.Len())
+	}
+
+	// The cached copy is the one the Release lists, so the next request is
+	// answered without revalidating
+	w = httptest.NewRecorder()
+	srv.handleIndexRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), pkgBody) {
+		t.Fatalf("second request: %d, %d bytes", w.Code, w.Body.Len())
+	}
+	if got := atomic.LoadInt32(&m.requests); got != 1 {
+		t.Errorf("mirror requests = %d, want 1", got)
+	}
+	if got := srv.metrics.MetadataP2P.WithLabel(metaShareCurrent).Value(); got != 1 {
+		t.Errorf("current = %d, want 1", got)
+	}
+
+	// A copy the Release no longer lists is revalidated as before
+	putMetadata(t, srv.cache, pkgURL, []byte("Package: old\n\n"))
+	w = httptest.NewRecorder()
+	srv.handleIndexRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	if !bytes.Equal(w.Body.Bytes(), pkgBody) {
+		t.Errorf("outdated copy served: %q", w.Body.String())
+	}
+	if got := atomic.LoadInt32(&m.requests); got != 2 {
+		t.Errorf("mirror requests = %d, want 2", got)
+	}
+}
+
+func TestIndexForPeer(t *testing.T) {
+	pkgBody := []byte("Package: hello\nVersion: 2.10\nArchitecture: amd64\n\n")
+	srv, _, pkgURL := shareSetup(t, pkgBody)
+	putMetadata(t, srv.cache, pkgURL, pkgBody)
+
+	rc, size, err := srv.indexForPeer(nil, sha256Hex(pkgBody))
+	if err != nil {
+		t.Fatalf("indexForPeer: %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if size != int64(len(pkgBody)) || !bytes.Equal(got, pkgBody) {
+		t.Errorf("indexForPeer = %d bytes, want the cached index", size)
+	}
+
+	// The Release is not an index: peers must get it from the mirror
+	entry, rc, err := srv.cache.GetMetadata(pkgURL[:len(pkgURL)-len(verPkgRel)] + "InRelease")
+	if err != nil {
+		t.Fatalf("GetMetadata(InRelease): %v", err)
+	}
+	_ = rc.Close()
+	if _, _, err := srv.indexForPeer(nil, entry.SHA256); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("InRelease served to a peer: %v", err)
+	}
+}
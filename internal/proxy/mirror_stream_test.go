@@ -7,7 +7,11 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/cache"
 )
 
 // indexPackage registers a package payload in the server's index so the
@@ -76,6 +80,38 @@ func TestMirrorFallback_LargePackageStreamedToCacheAndServed(t *testing.T) {
 	}
 }
 
+func TestMirrorFallback_RecordsOrigin(t *testing.T) {
+	payload := []byte("origin test package")
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	pkgURL := indexPackage(t, server, mockMirror.URL, "pool/main/s/streampkg/streampkg_1.0_amd64.deb", payload)
+
+	w := httptest.NewRecorder()
+	server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+
+	pkg, err := server.cache.Info(sha256Hex(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := cache.Origin{
+		Repo:      strings.TrimPrefix(mockMirror.URL, "http://"),
+		Suite:     "stable",
+		Component: "main",
+		Mirror:    pkgURL,
+	}
+	if pkg.Origin != want {
+		t.Errorf("origin = %+v, want %+v", pkg.Origin, want)
+	}
+}
+
 // TestMirrorFallback_HashMismatchRejectedAndNotCached verifies that a mirror
 // serving content that does not match the signed index hash results in a 502
 // and nothing cached — the verification gate must hold on the streaming path.
@@ -112,3 +148,76 @@ func TestMirrorFallback_HashMismatchRejectedAndNotCached(t *testing.T) {
 		t.Errorf("VerificationFailures = %d, want >= 1", got)
 	}
 }
+
+// TestMirrorFallback_StreamedBeforeVerification verifies that with
+// StreamMirror the requesting client receives a mirror download while it is
+// still arriving, and the complete package once it has been verified.
+func TestMirrorFallback_StreamedBeforeVerification(t *testing.T) {
+	payload := make([]byte, 256*1024)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	release := make(chan struct{})
+	mockMirror := stallingMirror(payload, release)
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	server.streamMirror = true
+	pkgURL := indexPackage(t, server, mockMirror.URL, "pool/main/s/streampkg/streampkg_1.0_amd64.deb", payload)
+
+	w := newSignalRecorder(len(payload) / 4)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	}()
+	select {
+	case <-w.reached:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client received no bytes before the mirror finished")
+	}
+	close(release)
+	<-done
+
+	if got := w.bytes(); !bytes.Equal(got, payload) {
+		t.Fatalf("body mismatch: len %d, want %d", len(got), len(payload))
+	}
+	if got := w.Header().Get("Content-Length"); got != fmt.Sprint(len(payload)) {
+		t.Errorf("Content-Length = %q", got)
+	}
+	if server.cache.Count() != 1 {
+		t.Error("streamed package was not cached")
+	}
+}
+
+// TestMirrorFallback_StreamedMismatchEndsShort verifies that a streamed
+// download failing verification never reaches the client complete, so the
+// connection is dropped and APT retries.
+func TestMirrorFallback_StreamedMismatchEndsShort(t *testing.T) {
+	good := bytes.Repeat([]byte("g"), 64*1024)
+	evil := bytes.Repeat([]byte("e"), len(good))
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(evil)))
+		_, _ = w.Write(evil)
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	server.streamMirror = true
+	pkgURL := indexPackage(t, server, mockMirror.URL, "pool/main/s/streampkg/streampkg_1.0_amd64.deb", good)
+
+	w := httptest.NewRecorder()
+	server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+
+	if w.Body.Len() >= len(evil) {
+		t.Fatalf("client received %d bytes of an unverified %d-byte package", w.Body.Len(), len(evil))
+	}
+	if server.cache.Count() != 0 {
+		t.Error("mismatched content was cached")
+	}
+	if got := server.metrics.VerificationFailures.Value(); got < 1 {
+		t.Errorf("VerificationFailures = %d, want >= 1", got)
+	}
+}
@@ -0,0 +1,219 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/prefetch"
+	"github.com/debswarm/debswarm/internal/requestid"
+	"github.com/debswarm/debswarm/internal/sanitize"
+	"github.com/debswarm/debswarm/internal/timeouts"
+)
+
+// Offline mode (connectivity_mode = "offline") guarantees that no mirror
+// is contacted: the fetcher refuses every request, and the proxy serves
+// packages from the cache and peers only and metadata from the metadata
+// cache. Anything else fails at once with the "offline" failure code,
+// instead of after a doomed mirror request.
+
+// offline reports whether mirror fetches are disabled.
+func (s *Server) offline() bool {
+	return s.fetcher != nil && s.fetcher.Offline()
+}
+
+// mirrorAllowed reports whether a request under policy may use the mirror.
+func (s *Server) mirrorAllowed(policy sourcePolicy) bool {
+	return policy.allowsMirror() && !s.offline()
+}
+
+// refuseMirror answers a request that only the mirror could have served. In
+// offline mode that is an offline failure; otherwise the policy excluded the
+// mirror, and code and reason are as for refuseByPolicy.
+func (s *Server) refuseMirror(ctx context.Context, w http.ResponseWriter, policy sourcePolicy, hash, path, code, reason string) {
+	if !s.offline() {
+		s.refuseByPolicy(ctx, w, policy, hash, path, code, reason)
+		return
+	}
+	log := requestid.LoggerFromContext(ctx, s.logger)
+	log.Info("Package request refused in offline mode",
+		zap.String("path", sanitize.Path(path)),
+		zap.String("reason", reason))
+	s.writeFailure(w, codeOffline, fmt.Sprintf("debswarm: offline mode: %s, and mirror fetches are disabled", reason))
+}
+
+// serveOfflineMetadata serves the cached copy of a metadata file, however
+// old, in offline mode. Without one the request fails.
+func (s *Server) serveOfflineMetadata(w http.ResponseWriter, r *http.Request, url string, isIndex, caching bool) {
+	if caching {
+		if entry, rc, err := s.cache.GetMetadata(url); err == nil {
+			s.serveCachedMetadata(w, r, url, isIndex, entry, rc, true)
+			return
+		}
+	}
+	requestid.LoggerFromContext(r.Context(), s.logger).Debug("Metadata not cached in offline mode",
+		zap.String("url", sanitize.URL(url)))
+	s.writeFailure(w, codeOffline, "debswarm: offline mode: metadata not cached, and mirror fetches are disabled")
+}
+
+// offlineCheckWorkers bounds the provider lookups of one offline check.
+const offlineCheckWorkers = 8
+
+// OfflineCheckRequest is the body of POST /api/offline/check.
+type OfflineCheckRequest struct {
+	Packages []string `json:"packages"`
+	// Architectures to resolve for (default: the host's)
+	Architectures []string `json:"architectures,omitempty"`
+	// NoDependencies checks the named packages only
+	NoDependencies bool `json:"no_dependencies,omitempty"`
+}
+
+// OfflineCheck is the response of POST /api/offline/check.
+type OfflineCheck struct {
+	// Offline reports whether the node is in offline mode
+	Offline  bool                  `json:"offline"`
+	Packages []OfflinePackageCheck `json:"packages"`
+}
+
+// OfflinePackageCheck tells whether one package could be installed without
+// the mirror: every package it resolves to must be cached or provided by a
+// peer.
+type OfflinePackageCheck struct {
+	Name        string `json:"name"`
+	Installable bool   `json:"installable"`
+	// Total packages it resolves to, dependencies included; how many are
+	// cached here, and how many only peers have
+	Total  int `json:"total"`
+	Cached int `json:"cached"`
+	Peers  int `json:"peers"`
+	// Unavailable lists the files neither cached nor provided by a peer
+	Unavailable []string `json:"unavailable,omitempty"`
+	// Missing lists packages the index does not have; Unresolved,
+	// dependencies no indexed package satisfies (e.g. virtual packages),
+	// which may be satisfied on the target machine and are not counted
+	// against it
+	Missing    []string `json:"missing,omitempty"`
+	Unresolved []string `json:"unresolved,omitempty"`
+}
+
+// Where a package is available from
+const (
+	availableNowhere = iota
+	availableCache
+	availablePeers
+)
+
+// POST /api/offline/check
+func (s *Server) handleAPIOfflineCheck(w http.ResponseWriter, r *http.Request) {
+	var req OfflineCheckRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if err := (prefetch.Group{Name: "check", Packages: req.Packages}).Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	archs := req.Architectures
+	if len(archs) == 0 {
+		archs = []string{prefetch.HostArchitecture()}
+	}
+	writeJSON(w, http.StatusOK, s.checkOffline(r.Context(), req.Packages, archs, !req.NoDependencies))
+}
+
+// checkOffline resolves each of names against the index and reports whether
+// everything it needs is cached or on peers.
+func (s *Server) checkOffline(ctx context.Context, names, archs []string, dependencies bool) *OfflineCheck {
+	catalog := prefetch.NewCatalog(s.index.Packages(), archs)
+	resolutions := make([]prefetch.Resolution, len(names))
+	var pkgs []*index.PackageInfo
+	seen := make(map[string]bool)
+	for i, name := range names {
+		resolutions[i] = catalog.Resolve(prefetch.Group{Name: name, Packages: []string{name}}, dependencies)
+		for _, pkg := range resolutions[i].Packages {
+			if !seen[pkg.SHA256] {
+				seen[pkg.SHA256] = true
+				pkgs = append(pkgs, pkg)
+			}
+		}
+	}
+	availability := s.packageAvailability(ctx, pkgs)
+
+	check := &OfflineCheck{Offline: s.offline(), Packages: make([]OfflinePackageCheck, 0, len(names))}
+	for i, name := range names {
+		res := resolutions[i]
+		pc := OfflinePackageCheck{
+			Name:       name,
+			Total:      len(res.Packages),
+			Missing:    res.Missing,
+			Unresolved: res.Unresolved,
+		}
+		for _, pkg := range res.Packages {
+			switch availability[pkg.SHA256] {
+			case availableCache:
+				pc.Cached++
+			case availablePeers:
+				pc.Peers++
+			default:
+				pc.Unavailable = append(pc.Unavailable, path.Base(pkg.Filename))
+			}
+		}
+		slices.Sort(pc.Unavailable)
+		pc.Installable = len(pc.Missing) == 0 && len(pc.Unavailable) == 0
+		check.Packages = append(check.Packages, pc)
+	}
+	return check
+}
+
+// packageAvailability finds where each of pkgs could be fetched from
+// without the mirror: the cache, or a peer providing it.
+func (s *Server) packageAvailability(ctx context.Context, pkgs []*index.PackageInfo) map[string]int {
+	out := make(map[string]int, len(pkgs))
+	var lookup []*index.PackageInfo
+	for _, pkg := range pkgs {
+		if s.cache.Has(pkg.SHA256) {
+			out[pkg.SHA256] = availableCache
+		} else {
+			lookup = append(lookup, pkg)
+		}
+	}
+	if s.p2pNode == nil || s.p2pPaused() {
+		return out
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, offlineCheckWorkers)
+	for _, pkg := range lookup {
+		url := "http://" + pkg.Repo + "/" + pkg.Filename
+		if !s.policyForURL(url).Share {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			dhtCtx, cancel := context.WithTimeout(ctx, s.timeouts.Get(timeouts.OpDHTLookup))
+			defer cancel()
+			providers, err := s.nodeForRepo(pkg.Repo).FindProviders(dhtCtx, pkg.SHA256, 1)
+			if err == nil && len(providers) > 0 {
+				mu.Lock()
+				out[pkg.SHA256] = availablePeers
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return out
+}
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOfflineMode_NeverContactsMirror(t *testing.T) {
+	var requests atomic.Int32
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte("from the mirror"))
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	server.cache.SetMetadataMaxSize(1 * 1024 * 1024)
+
+	// Prime the metadata cache while online
+	release := mockMirror.URL + "/dists/stable/InRelease"
+	w := httptest.NewRecorder()
+	server.handlePassthrough(w, httptest.NewRequest("GET", "/"+release, nil), release)
+	if w.Code != http.StatusOK {
+		t.Fatalf("prime: status %d", w.Code)
+	}
+	server.fetcher.SetOffline(true)
+	primed := requests.Load()
+
+	w = httptest.NewRecorder()
+	server.handlePassthrough(w, httptest.NewRequest("GET", "/"+release, nil), release)
+	if w.Code != http.StatusOK || w.Body.String() != "from the mirror" {
+		t.Errorf("cached metadata: status %d, body %q", w.Code, w.Body.String())
+	}
+
+	uncached := mockMirror.URL + "/dists/stable/main/binary-amd64/Packages.xz"
+	w = httptest.NewRecorder()
+	server.handlePassthrough(w, httptest.NewRequest("GET", "/"+uncached, nil), uncached)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get(errorHeader) != codeOffline {
+		t.Errorf("uncached metadata: status %d, code %q", w.Code, w.Header().Get(errorHeader))
+	}
+
+	// An indexed package no peer has, and one the index does not know
+	pkgURL := indexPackage(t, server, mockMirror.URL, "pool/main/s/streampkg/streampkg_1.0_amd64.deb", []byte("package"))
+	for _, url := range []string{pkgURL, mockMirror.URL + "/pool/main/u/unknown/unknown_1.0_amd64.deb"} {
+		w = httptest.NewRecorder()
+		server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+url, nil), url)
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get(errorHeader) != codeOffline {
+			t.Errorf("%s: status %d, code %q", url, w.Code, w.Header().Get(errorHeader))
+		}
+	}
+
+	w = httptest.NewRecorder()
+	server.handleConnect(w, httptest.NewRequest("CONNECT", "deb.debian.org:443", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("CONNECT: status %d", w.Code)
+	}
+
+	if requests.Load() != primed {
+		t.Errorf("offline mode made %d mirror requests", requests.Load()-primed)
+	}
+}
+
+func TestOfflineCheckAPI(t *testing.T) {
+	server := newTestServer(t)
+	defer shutdownServer(t, server)
+	server.fetcher.SetOffline(true)
+
+	payloads := map[string][]byte{
+		"pool/main/g/git/git_2.39_amd64.deb":       []byte("git package"),
+		"pool/main/g/git-man/git-man_2.39_all.deb": []byte("git-man package"),
+		"pool/main/m/make/make_4.3_amd64.deb":      []byte("make package"),
+	}
+	var packages strings.Builder
+	for path, payload := range payloads {
+		parts := strings.Split(strings.TrimSuffix(path[strings.LastIndex(path, "/")+1:], ".deb"), "_")
+		packages.WriteString("Package: " + parts[0] + "\nVersion: " + parts[1] + "\nArchitecture: " + parts[2] + "\n")
+		if parts[0] == "git" {
+			packages.WriteString("Depends: git-man, perl | perl-base\n")
+		}
+		packages.WriteString("Filename: " + path + "\nSHA256: " + sha256Hex(payload) + "\n\n")
+	}
+	if err := server.index.LoadFromData([]byte(packages.String()), "http://deb.debian.org/debian/dists/stable/main/binary-amd64/Packages"); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"pool/main/g/git/git_2.39_amd64.deb", "pool/main/m/make/make_4.3_amd64.deb"} {
+		if err := server.cache.Put(strings.NewReader(string(payloads[path])), sha256Hex(payloads[path]), path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body := `{"packages": ["make", "git", "nano"], "architectures": ["amd64"]}`
+	w := httptest.NewRecorder()
+	server.handleAPIOfflineCheck(w, httptest.NewRequest("POST", "/api/offline/check", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var check OfflineCheck
+	if err := json.Unmarshal(w.Body.Bytes(), &check); err != nil {
+		t.Fatal(err)
+	}
+	if !check.Offline || len(check.Packages) != 3 {
+		t.Fatalf("check = %+v", check)
+	}
+	if mk := check.Packages[0]; !mk.Installable || mk.Total != 1 || mk.Cached != 1 {
+		t.Errorf("make = %+v", mk)
+	}
+	// git is cached, git-man is not
+	if git := check.Packages[1]; git.Installable || git.Total != 2 || git.Cached != 1 ||
+		len(git.Unavailable) != 1 || git.Unavailable[0] != "git-man_2.39_all.deb" || git.Unresolved[0] != "perl | perl-base" {
+		t.Errorf("git = %+v", git)
+	}
+	if nano := check.Packages[2]; nano.Installable || nano.Missing[0] != "nano:amd64" {
+		t.Errorf("nano = %+v", nano)
+	}
+
+	w = httptest.NewRecorder()
+	server.handleAPIOfflineCheck(w, httptest.NewRequest("POST", "/api/offline/check", strings.NewReader(`{"packages": ["Bad Name"]}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid name: status %d", w.Code)
+	}
+}
@@ -65,9 +65,9 @@ func TestPassthrough_StreamsLargeBody(t *testing.T) {
 // upstream error body leaks through — the status is decided before any bytes
 // are written. The mirror byte counter must not advance on a failed fetch.
 func TestPassthrough_UpstreamErrorReturns502BeforeBytes(t *testing.T) {
-	const upstreamBody = "upstream 404 error page that must not reach the client"
+	const upstreamBody = "upstream 503 error page that must not reach the client"
 	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNotFound)
+		w.WriteHeader(http.StatusServiceUnavailable)
 		_, _ = w.Write([]byte(upstreamBody))
 	}))
 	defer mockMirror.Close()
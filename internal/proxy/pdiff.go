@@ -0,0 +1,285 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/pdiff"
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+const (
+	// pdiffIndexSuffix ends the URL of an index's diff Index, e.g.
+	// .../binary-amd64/Packages.diff/Index
+	pdiffIndexSuffix = ".diff/Index"
+	// pdiffRebuildTimeout bounds one rebuild, including patch downloads
+	pdiffRebuildTimeout = 2 * time.Minute
+	// maxPdiffPatches caps how many patches a rebuild applies. Debian keeps
+	// about two weeks of them; an older base is better refetched in full.
+	maxPdiffPatches = 128
+)
+
+// Results of a rebuild, the labels of debswarm_pdiff_reconstructions_total
+const (
+	pdiffRebuilt = "rebuilt" // patched up to the current version
+	pdiffCurrent = "current" // a cached copy was already current
+	pdiffNoBase  = "no_base" // nothing cached the patches apply to
+	pdiffFailed  = "failed"
+)
+
+// pdiffTarget returns the index a .diff/Index URL describes, or "" when it is
+// not the diff Index of a Packages or Sources file. Translation and Contents
+// diffs are cached like any metadata but not rebuilt: debswarm does not use
+// those files itself.
+func pdiffTarget(rawURL string) string {
+	if !strings.HasSuffix(rawURL, pdiffIndexSuffix) {
+		return ""
+	}
+	target := strings.TrimSuffix(rawURL, pdiffIndexSuffix)
+	if !isVerifiableIndexURL(target) || strings.Contains(target, "/by-hash/") {
+		return ""
+	}
+	return target
+}
+
+// schedulePdiffRebuild rebuilds, in the background, the index a .diff/Index
+// just served describes. A client that updates through pdiffs never fetches
+// the full Packages file, so without this the in-memory index would keep the
+// package hashes of the last full download and new packages could neither be
+// verified nor shared. Concurrent requests for one index share a rebuild.
+func (s *Server) schedulePdiffRebuild(indexURL string) {
+	if !s.reconstructPdiffs || s.cache == nil || !s.cache.MetadataEnabled() || pdiffTarget(indexURL) == "" {
+		return
+	}
+	go func() {
+		led := false
+		_, _, shared := s.pdiffGroup.Do(indexURL, func() (interface{}, error) {
+			led = true
+			ctx, cancel := context.WithTimeout(s.announceCtx, pdiffRebuildTimeout)
+			defer cancel()
+			return s.reconstructFromPdiff(ctx, indexURL), nil
+		})
+		if shared && !led {
+			s.metrics.CoalescedRequests.WithLabel("pdiff").Inc()
+		}
+	}()
+}
+
+// reconstructFromPdiff rebuilds the current version of the index described by
+// the cached .diff/Index at indexURL from the newest cached copy the Index has
+// patches for. The patches come from the metadata cache or the mirror, and are
+// cached for LAN clients doing the same update. The result must match the
+// SHA256 in the Index and pass the same signed-Release check as a downloaded
+// index; it is then loaded into the in-memory index and cached under the
+// index's plain URL and its by-hash URL. It returns the result label.
+func (s *Server) reconstructFromPdiff(ctx context.Context, indexURL string) string {
+	target := pdiffTarget(indexURL)
+	log := s.logger.With(zap.String("index", sanitize.URL(target)))
+	result := s.rebuildIndex(ctx, indexURL, target, log)
+	if s.metrics != nil {
+		s.metrics.PdiffReconstructions.WithLabel(result).Inc()
+	}
+	return result
+}
+
+func (s *Server) rebuildIndex(ctx context.Context, indexURL, target string, log *zap.Logger) string {
+	body := s.readCachedMetadataBody(indexURL)
+	if body == nil {
+		return pdiffNoBase
+	}
+	diffs, err := pdiff.ParseIndex(body)
+	if err != nil {
+		log.Debug("Failed to parse pdiff index", zap.Error(err))
+		return pdiffFailed
+	}
+	current := diffs.Current.SHA256
+	if built, ok := s.pdiffBuilt.Load(target); ok && built == current && s.index.HasIndexFile(target) {
+		return pdiffCurrent
+	}
+
+	base, plan := s.pdiffBase(target, diffs)
+	if base == nil {
+		log.Debug("No cached copy of the index the pdiffs apply to")
+		return pdiffNoBase
+	}
+	if len(plan) > maxPdiffPatches {
+		log.Debug("Cached index is too old to patch", zap.Int("patches", len(plan)))
+		return pdiffNoBase
+	}
+
+	data := base
+	var fetched int64
+	for _, p := range plan {
+		patch, n, err := s.pdiffPatch(ctx, target, p)
+		if err != nil {
+			log.Debug("Failed to obtain pdiff patch", zap.String("patch", p.Name), zap.Error(err))
+			return pdiffFailed
+		}
+		fetched += n
+		if data, err = pdiff.Apply(data, patch); err != nil {
+			log.Debug("Failed to apply pdiff patch", zap.String("patch", p.Name), zap.Error(err))
+			return pdiffFailed
+		}
+	}
+	if int64(len(data)) != diffs.Current.Size || sha256Hex(data) != current {
+		log.Warn("Index rebuilt from pdiffs does not match the pdiff index; discarding")
+		return pdiffFailed
+	}
+
+	// Same gate as a downloaded index: it must be the one the signed Release lists
+	if !s.checkIndexVerification(nil, target, data, log) {
+		return pdiffFailed
+	}
+	if err := s.loadIndexInto(target, data); err != nil {
+		log.Debug("Failed to parse rebuilt index", zap.Error(err))
+		return pdiffFailed
+	}
+	s.storeMetadata(target, data, "", "", "application/octet-stream", log)
+	s.storeMetadata(byHashIndexURL(target, current), data, "", "", "application/octet-stream", log)
+	s.pdiffBuilt.Store(target, current)
+
+	if len(plan) == 0 {
+		return pdiffCurrent
+	}
+	log.Info("Rebuilt index from pdiffs",
+		zap.Int("patches", len(plan)),
+		zap.Int64("patchBytesFetched", fetched),
+		zap.Int("size", len(data)))
+	return pdiffRebuilt
+}
+
+// pdiffBase finds the cached copy of target with the shortest patch path to
+// the current version: the plain, compressed or by-hash copies APT fetched,
+// or a previous rebuild. It returns the uncompressed copy and its patches.
+func (s *Server) pdiffBase(target string, diffs *pdiff.Index) ([]byte, []pdiff.Patch) {
+	candidates := []string{target}
+	for _, ext := range []string{".xz", ".gz", ".zst", ".lz4"} {
+		candidates = append(candidates, target+ext)
+	}
+	byHashPrefix := target[:strings.LastIndex(target, "/")+1] + "by-hash/SHA256/"
+	if urls, err := s.cache.ListMetadataURLs(); err == nil {
+		for _, u := range urls {
+			if strings.HasPrefix(u, byHashPrefix) {
+				candidates = append(candidates, u)
+			}
+		}
+	}
+
+	var best []byte
+	var bestPlan []pdiff.Patch
+	for _, u := range candidates {
+		body := s.readCachedMetadataBody(u)
+		if body == nil {
+			continue
+		}
+		data, err := decompressIndex(body)
+		if err != nil {
+			continue
+		}
+		h := sha256Hex(data)
+		if !diffs.Known(h) {
+			continue
+		}
+		plan, err := diffs.Plan(h)
+		if err != nil {
+			continue
+		}
+		if best == nil || len(plan) < len(bestPlan) {
+			best, bestPlan = data, plan
+		}
+		if len(plan) == 0 {
+			break
+		}
+	}
+	return best, bestPlan
+}
+
+// pdiffPatch returns one uncompressed patch for target and how many bytes
+// were fetched from the mirror for it. A cached copy is used when there is
+// one; a downloaded one is cached so LAN clients updating the same index get
+// it locally.
+func (s *Server) pdiffPatch(ctx context.Context, target string, p pdiff.Patch) ([]byte, int64, error) {
+	url := target + ".diff/" + p.DownloadName
+	var fetched int64
+	body := s.readCachedMetadataBody(url)
+	if body == nil {
+		if body = s.fetchMetadataBytes(ctx, url); body == nil {
+			return nil, 0, errors.New("not available from the mirror")
+		}
+		fetched = int64(len(body))
+	}
+	if p.Download.SHA256 != "" && sha256Hex(body) != p.Download.SHA256 {
+		return nil, fetched, fmt.Errorf("download hash mismatch")
+	}
+
+	r, err := index.DecompressByMagic(body)
+	if err != nil {
+		return nil, fetched, err
+	}
+	patch, err := io.ReadAll(io.LimitReader(r, p.Result.Size+1))
+	if err != nil {
+		return nil, fetched, err
+	}
+	if int64(len(patch)) != p.Result.Size || sha256Hex(patch) != p.Result.SHA256 {
+		return nil, fetched, fmt.Errorf("patch hash mismatch")
+	}
+	if fetched > 0 {
+		s.storeMetadata(url, body, "", "", "", s.logger)
+	}
+	return patch, fetched, nil
+}
+
+// serveRebuiltIndex serves the uncompressed index at url from a pdiff rebuild
+// while the signed Release still lists it, without asking the mirror (which
+// often does not publish uncompressed indexes at all). It reports whether it
+// served the request.
+func (s *Server) serveRebuiltIndex(w http.ResponseWriter, r *http.Request, url string) bool {
+	if _, ok := s.pdiffBuilt.Load(url); !ok {
+		return false
+	}
+	entry, rc, err := s.cache.GetMetadata(url)
+	if err != nil {
+		return false
+	}
+	data, err := io.ReadAll(io.LimitReader(rc, entry.Size))
+	_ = rc.Close()
+	if err != nil {
+		return false
+	}
+	if ok, _ := s.verifyIndex(url, data); !ok {
+		// A newer Release has been seen since the rebuild
+		return false
+	}
+	s.serveCachedMetadata(w, r, url, true, entry, io.NopCloser(bytes.NewReader(data)), false)
+	return true
+}
+
+// byHashIndexURL returns the Acquire-By-Hash URL of an index version
+func byHashIndexURL(target, sha256 string) string {
+	return target[:strings.LastIndex(target, "/")+1] + "by-hash/SHA256/" + sha256
+}
+
+// decompressIndex returns the uncompressed bytes of a cached index body
+func decompressIndex(body []byte) ([]byte, error) {
+	r, err := index.DecompressByMagic(body)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+const (
+	pdiffOld = "Package: hello\nVersion: 2.10\nArchitecture: amd64\n" +
+		"Filename: pool/main/h/hello/hello_2.10_amd64.deb\nSize: 100\n" +
+		"SHA256: 1111111111111111111111111111111111111111111111111111111111111111\n"
+	pdiffAdded = "\nPackage: world\nVersion: 1.0\nArchitecture: amd64\n" +
+		"Filename: pool/main/w/world/world_1.0_amd64.deb\nSize: 200\n" +
+		"SHA256: 2222222222222222222222222222222222222222222222222222222222222222\n"
+	pdiffNew = pdiffOld + pdiffAdded
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func putMetadata(t *testing.T, c *cache.Cache, url string, body []byte) {
+	t.Helper()
+	mw, err := c.NewMetadataWriter(url, "", "", "")
+	if err != nil {
+		t.Fatalf("NewMetadataWriter: %v", err)
+	}
+	if _, err := mw.Write(body); err != nil {
+		t.Fatalf("metadata write: %v", err)
+	}
+	if err := mw.Commit(); err != nil {
+		t.Fatalf("metadata commit: %v", err)
+	}
+}
+
+// pdiffSetup caches a signed InRelease listing pdiffNew, the old Packages.gz
+// and a Packages.diff/Index whose one patch turns pdiffOld into pdiffNew. The
+// patch itself is only on the mock mirror, which counts its requests.
+func pdiffSetup(t *testing.T) (srv *Server, target string, mirrorRequests *int32) {
+	t.Helper()
+	patch := fmt.Sprintf("%da\n%s.\n", strings.Count(pdiffOld, "\n"), pdiffAdded)
+	patchGz := gzipBytes(t, []byte(patch))
+
+	var requests int32
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if strings.HasSuffix(r.URL.Path, "/Packages.diff/T-2-F-1.gz") {
+			_, _ = w.Write(patchGz)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(mock.Close)
+
+	dist := mock.URL + "/debian/dists/bookworm/"
+	target = dist + "main/binary-amd64/Packages"
+	e, kr := genKeyAndKeyring(t)
+	c := freshMetaCache(t)
+	putMetadata(t, c, dist+"InRelease", clearsignBody(t, e, fmt.Sprintf(
+		"Origin: Debian\nSuite: bookworm\nSHA256:\n %s %d main/binary-amd64/Packages\n",
+		sha256Hex([]byte(pdiffNew)), len(pdiffNew))))
+	putMetadata(t, c, target+".gz", gzipBytes(t, []byte(pdiffOld)))
+	putMetadata(t, c, target+".diff/Index", []byte(fmt.Sprintf(
+		"SHA256-Current: %s %d\nSHA256-History:\n %s %d T-2-F-1\nSHA256-Patches:\n %s %d T-2-F-1\nSHA256-Download:\n %s %d T-2-F-1.gz\n",
+		sha256Hex([]byte(pdiffNew)), len(pdiffNew),
+		sha256Hex([]byte(pdiffOld)), len(pdiffOld),
+		sha256Hex([]byte(patch)), len(patch),
+		sha256Hex(patchGz), len(patchGz))))
+
+	srv = serverWith(t, c, index.New(t.TempDir(), newTestLogger()))
+	t.Cleanup(func() { shutdownServer(t, srv) })
+	srv.keyring = kr
+	srv.verifyMode = verifyAuto
+	srv.reconstructPdiffs = true
+	return srv, target, &requests
+}
+
+func TestReconstructFromPdiff(t *testing.T) {
+	srv, target, requests := pdiffSetup(t)
+	indexURL := target + ".diff/Index"
+
+	if got := srv.reconstructFromPdiff(context.Background(), indexURL); got != pdiffRebuilt {
+		t.Fatalf("reconstructFromPdiff = %s, want %s", got, pdiffRebuilt)
+	}
+	if srv.index.GetBySHA256(strings.Repeat("2", 64)) == nil {
+		t.Error("package added by the patch is not in the index")
+	}
+	if atomic.LoadInt32(requests) != 1 {
+		t.Errorf("mirror requests = %d, want 1 (the patch)", atomic.LoadInt32(requests))
+	}
+	if !srv.cache.HasMetadata(target+".diff/T-2-F-1.gz") || !srv.cache.HasMetadata(byHashIndexURL(target, sha256Hex([]byte(pdiffNew)))) {
+		t.Error("patch or rebuilt by-hash index not cached")
+	}
+
+	// Nothing to do the second time
+	if got := srv.reconstructFromPdiff(context.Background(), indexURL); got != pdiffCurrent {
+		t.Errorf("second reconstructFromPdiff = %s, want %s", got, pdiffCurrent)
+	}
+
+	// A LAN client asking for the uncompressed index gets the rebuilt copy
+	// without the mirror, which does not publish it
+	w := httptest.NewRecorder()
+	srv.handleIndexRequest(w, httptest.NewRequest(http.MethodGet, "/"+target, nil), target)
+	if w.Code != http.StatusOK || w.Body.String() != pdiffNew {
+		t.Errorf("serving rebuilt index: code=%d body=%q", w.Code, w.Body.String())
+	}
+	if atomic.LoadInt32(requests) != 1 {
+		t.Errorf("mirror requests = %d after serving the rebuilt index, want 1", atomic.LoadInt32(requests))
+	}
+	if got := srv.metrics.PdiffReconstructions.WithLabel(pdiffRebuilt).Value(); got != 1 {
+		t.Errorf("rebuilt metric = %d, want 1", got)
+	}
+}
+
+func TestReconstructFromPdiff_RefusesUnsigned(t *testing.T) {
+	srv, target, _ := pdiffSetup(t)
+	// Without a trusted key the rebuilt index cannot be checked against the
+	// Release, so enforce must not load it
+	srv.keyring = nil
+	srv.verifyMode = verifyEnforce
+	if got := srv.reconstructFromPdiff(context.Background(), target+".diff/Index"); got != pdiffFailed {
+		t.Errorf("reconstructFromPdiff = %s, want %s", got, pdiffFailed)
+	}
+	if srv.index.GetBySHA256(strings.Repeat("2", 64)) != nil {
+		t.Error("unverified rebuild loaded into the index")
+	}
+}
+
+func TestReconstructFromPdiff_NoBase(t *testing.T) {
+	srv, target, _ := pdiffSetup(t)
+	putMetadata(t, srv.cache, target+".gz", gzipBytes(t, []byte("Package: unrelated\n")))
+	if got := srv.reconstructFromPdiff(context.Background(), target+".diff/Index"); got != pdiffNoBase {
+		t.Errorf("reconstructFromPdiff = %s, want %s", got, pdiffNoBase)
+	}
+}
+
+func TestPdiffTarget(t *testing.T) {
+	const dist = "http://deb.debian.org/debian/dists/trixie/"
+	for url, want := range map[string]string{
+		dist + "main/binary-amd64/Packages.diff/Index": dist + "main/binary-amd64/Packages",
+		dist + "main/source/Sources.diff/Index":        dist + "main/source/Sources",
+		dist + "main/i18n/Translation-en.diff/Index":   "",
+		dist + "main/Contents-amd64.diff/Index":        "",
+		dist + "main/binary-amd64/Packages.xz":         "",
+	} {
+		if got := pdiffTarget(url); got != want {
+			t.Errorf("pdiffTarget(%s) = %q, want %q", url, got, want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/dashboard"
+)
+
+const (
+	// dashboardTopPackages is how many of the most served packages the
+	// dashboard lists
+	dashboardTopPackages = 10
+	// maxTopPackages caps GET /api/stats/packages?top=
+	maxTopPackages = 1000
+)
+
+// packageServed records a package served to a local client: in the
+// per-package statistics and, for a build listener request, in the build.
+func (s *Server) packageServed(ctx context.Context, hash, url, path string, size int64) {
+	s.cache.RecordPackageDownload(hash, path)
+	s.recordBuildPackage(ctx, hash, url, size)
+}
+
+// topPackages returns the most served packages for the dashboard.
+func (s *Server) topPackages(limit int) []dashboard.PackageStat {
+	stats, err := s.cache.TopPackages(limit)
+	if err != nil {
+		s.logger.Debug("Failed to list most served packages", zap.Error(err))
+		return nil
+	}
+	out := make([]dashboard.PackageStat, 0, len(stats))
+	for _, st := range stats {
+		name := filepath.Base(st.Filename)
+		if st.Filename == "" {
+			name = st.SHA256[:min(16, len(st.SHA256))] + "..."
+		}
+		out = append(out, dashboard.PackageStat{
+			Filename:   name,
+			Downloads:  st.Downloads,
+			Uploads:    st.Uploads,
+			Uploaded:   formatBytes(st.BytesUploaded),
+			LastServed: st.LastServed.Format("2006-01-02 15:04"),
+		})
+	}
+	return out
+}
+
+// GET /api/stats/packages?top=N
+//
+// The N most served packages (default 50), by downloads to local clients
+// plus uploads to peers, from the persistent per-package statistics.
+func (s *Server) handleAPIPackageStats(w http.ResponseWriter, r *http.Request) {
+	top := 50
+	if v := r.URL.Query().Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "top must be a positive number")
+			return
+		}
+		top = min(n, maxTopPackages)
+	}
+	result, err := s.cache.TopPackages(top)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/hashutil"
+)
+
+func TestPackageStats(t *testing.T) {
+	payload := []byte("popular package payload")
+	hash := hashutil.HashBytes(payload)
+
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+
+	pkgPath := "pool/main/p/popular/popular_1.0_amd64.deb"
+	packages := fmt.Sprintf("Package: popular\nFilename: %s\nSize: %d\nSHA256: %s\n\n", pkgPath, len(payload), hash)
+	if err := server.index.LoadFromData([]byte(packages), mockMirror.URL+"/dists/stable/main/binary-amd64/Packages"); err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+
+	// A download from the mirror and a cache hit both count
+	pkgURL := mockMirror.URL + "/" + pkgPath
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, w.Code)
+		}
+	}
+	server.cache.RecordPackageUpload(hash, int64(len(payload)), true)
+
+	w := httptest.NewRecorder()
+	server.handleAPIPackageStats(w, httptest.NewRequest("GET", "/api/stats/packages?top=5", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var stats []cache.PackageStat
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(stats) != 1 || stats[0].SHA256 != hash || stats[0].Filename != pkgPath ||
+		stats[0].Downloads != 2 || stats[0].Uploads != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	top := server.GetDashboardStats().TopPackages
+	if len(top) != 1 || top[0].Filename != "popular_1.0_amd64.deb" || top[0].Downloads != 2 {
+		t.Errorf("dashboard top packages = %+v", top)
+	}
+
+	w = httptest.NewRecorder()
+	server.handleAPIPackageStats(w, httptest.NewRequest("GET", "/api/stats/packages?top=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("top=0: status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseSourcePolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		header    string
+		proxyAuth string
+		want      sourcePolicy
+		wantQuery string
+		wantErr   bool
+	}{
+		{name: "none", url: "http://deb.debian.org/debian/pool/a.deb", want: policyAuto},
+		{name: "header", url: "http://deb.debian.org/debian/pool/a.deb", header: "Mirror-Only", want: policyMirrorOnly},
+		{name: "auto", url: "http://deb.debian.org/debian/pool/a.deb", header: "auto", want: policyAuto},
+		{name: "param stripped", url: "http://deb.debian.org/debian/pool/a.deb?debswarm-policy=cache-only&x=1", want: policyCacheOnly, wantQuery: "x=1"},
+		{name: "header wins", url: "http://deb.debian.org/debian/pool/a.deb?debswarm-policy=cache-only", header: "p2p-only", want: policyP2POnly},
+		{name: "proxy credentials", url: "http://deb.debian.org/debian/pool/a.deb", proxyAuth: "policy:p2p-only", want: policyP2POnly},
+		{name: "other credentials", url: "http://deb.debian.org/debian/pool/a.deb", proxyAuth: "alice:secret", want: policyAuto},
+		{name: "unknown", url: "http://deb.debian.org/debian/pool/a.deb", header: "peers-please", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+			if tt.header != "" {
+				r.Header.Set(policyHeader, tt.header)
+			}
+			if tt.proxyAuth != "" {
+				r.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(tt.proxyAuth)))
+			}
+			got, err := parseSourcePolicy(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("policy = %q, want %q", got, tt.want)
+			}
+			if r.URL.RawQuery != tt.wantQuery {
+				t.Errorf("query = %q, want %q", r.URL.RawQuery, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestSourcePolicy_PackageRequests(t *testing.T) {
+	payload := []byte("policy payload")
+	sum := sha256.Sum256(payload)
+	hash := hex.EncodeToString(sum[:])
+
+	var mirrorHits atomic.Int32
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorHits.Add(1)
+		_, _ = w.Write(payload)
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+
+	pkgPath := "pool/main/p/policy/policy_1.0_amd64.deb"
+	packages := fmt.Sprintf("Package: policy\nVersion: 1.0\nArchitecture: amd64\nFilename: %s\nSize: %d\nSHA256: %s\n\n",
+		pkgPath, len(payload), hash)
+	if err := server.index.LoadFromData([]byte(packages), mockMirror.URL+"/dists/stable/main/binary-amd64/Packages"); err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+	pkgURL := mockMirror.URL + "/" + pkgPath
+
+	get := func(policy sourcePolicy) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/"+pkgURL, nil)
+		req = req.WithContext(withSourcePolicy(req.Context(), policy))
+		w := httptest.NewRecorder()
+		server.handlePackageRequest(w, req, pkgURL)
+		return w
+	}
+
+	// Nothing cached yet: cache-only refuses without touching the mirror, and
+	// p2p-only has no peers to ask.
+	for _, p := range []sourcePolicy{policyCacheOnly, policyP2POnly} {
+		if w := get(p); w.Code != http.StatusGatewayTimeout {
+			t.Errorf("%s on a cold cache: status %d, want 504", p, w.Code)
+		}
+	}
+	if n := mirrorHits.Load(); n != 0 {
+		t.Fatalf("mirror fetched %d times for refused requests", n)
+	}
+	if got := server.metrics.SourcePolicyRefused.WithLabel("p2p-only").Value(); got != 1 {
+		t.Errorf("p2p-only refusals = %d, want 1", got)
+	}
+
+	// The default policy downloads and caches it.
+	if w := get(policyAuto); w.Code != http.StatusOK || mirrorHits.Load() != 1 {
+		t.Fatalf("default request: status %d, mirror hits %d", w.Code, mirrorHits.Load())
+	}
+
+	// Now cached: cache-only and p2p-only are served from the cache...
+	for _, p := range []sourcePolicy{policyCacheOnly, policyP2POnly} {
+		w := get(p)
+		if w.Code != http.StatusOK || w.Header().Get("X-Debswarm-Source") != "cache" {
+			t.Errorf("%s on a warm cache: status %d, source %q", p, w.Code, w.Header().Get("X-Debswarm-Source"))
+		}
+	}
+	// ...while mirror-only goes back to the mirror.
+	w := get(policyMirrorOnly)
+	if w.Code != http.StatusOK || w.Body.String() != string(payload) {
+		t.Fatalf("mirror-only: status %d, body %q", w.Code, w.Body.String())
+	}
+	if n := mirrorHits.Load(); n != 2 {
+		t.Errorf("mirror hits = %d, want 2 (mirror-only must skip the cache)", n)
+	}
+	if got := server.metrics.SourcePolicyRequests.WithLabel("mirror-only").Value(); got != 1 {
+		t.Errorf("mirror-only requests = %d, want 1", got)
+	}
+}
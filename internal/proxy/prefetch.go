@@ -0,0 +1,409 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/prefetch"
+	"github.com/debswarm/debswarm/internal/priority"
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+// Prefetch keeps package groups cached (see package prefetch): on each
+// pass, every group is resolved against the package index, and the
+// packages not cached yet are fetched at prefetch priority, through the
+// swarm where peers have them, and announced like any download. Packages
+// of groups are pinned, and unpinned once no group needs them.
+
+// PrefetchConfig configures the package groups kept cached.
+type PrefetchConfig struct {
+	// Groups from the configuration file, which the API cannot change
+	Groups []prefetch.Group
+	// Store holds the groups added at runtime
+	Store *prefetch.Store
+	// Architectures groups are resolved for
+	Architectures []string
+	// Dependencies resolves each package's dependencies too
+	Dependencies bool
+	// Pin protects group packages from eviction
+	Pin bool
+	// Concurrency is how many packages are fetched at once (default 2)
+	Concurrency int
+	// Interval between passes (0 = only when a group changes or a pass
+	// is requested)
+	Interval time.Duration
+}
+
+// Group sources
+const (
+	prefetchSourceConfig = "config"
+	prefetchSourceCLI    = "cli"
+)
+
+// PrefetchGroupStatus is the state of a group after the last pass.
+type PrefetchGroupStatus struct {
+	Name     string   `json:"name"`
+	Source   string   `json:"source"` // "config" or "cli"
+	Packages []string `json:"packages"`
+	// Resolved is how many packages the group resolved to, dependencies
+	// included; Cached how many of them are cached, totaling Bytes
+	Resolved int   `json:"resolved"`
+	Cached   int   `json:"cached"`
+	Bytes    int64 `json:"bytes"`
+	// Missing lists packages the index does not have, Unresolved
+	// dependencies no indexed package satisfies
+	Missing    []string `json:"missing,omitempty"`
+	Unresolved []string `json:"unresolved,omitempty"`
+}
+
+// PrefetchStatus is the response of GET /api/prefetch.
+type PrefetchStatus struct {
+	Architectures []string              `json:"architectures"`
+	Interval      string                `json:"interval"`
+	Running       bool                  `json:"running"`
+	LastRun       time.Time             `json:"last_run,omitzero"`
+	Groups        []PrefetchGroupStatus `json:"groups"`
+}
+
+type prefetcher struct {
+	cfg     PrefetchConfig
+	trigger chan struct{}
+
+	mu      sync.Mutex
+	running bool
+	lastRun time.Time
+	status  map[string]PrefetchGroupStatus // by group name, from the last pass
+}
+
+// EnablePrefetch enables package groups and the /api/prefetch endpoints.
+// Prefetch runs the passes.
+func (s *Server) EnablePrefetch(cfg PrefetchConfig) {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 2
+	}
+	if cfg.Store == nil {
+		cfg.Store, _ = prefetch.OpenStore("")
+	}
+	s.prefetch = &prefetcher{cfg: cfg, trigger: make(chan struct{}, 1)}
+}
+
+// Prefetch runs a pass over the package groups at startup, every interval,
+// and whenever a group is added or a pass is requested, until ctx is
+// canceled.
+func (s *Server) Prefetch(ctx context.Context) {
+	p := s.prefetch
+	if p == nil {
+		return
+	}
+	var tick <-chan time.Time
+	if p.cfg.Interval > 0 {
+		ticker := time.NewTicker(p.cfg.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		s.runPrefetch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-p.trigger:
+		}
+	}
+}
+
+// requestRun asks for a pass as soon as the current one, if any, ends.
+func (p *prefetcher) requestRun() {
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// groups returns the configured groups followed by those added at runtime,
+// with their sources.
+func (p *prefetcher) groups() ([]prefetch.Group, []string) {
+	groups := slices.Clone(p.cfg.Groups)
+	sources := make([]string, len(groups))
+	for i := range sources {
+		sources[i] = prefetchSourceConfig
+	}
+	for _, g := range p.cfg.Store.Groups() {
+		if !p.configured(g.Name) {
+			groups = append(groups, g)
+			sources = append(sources, prefetchSourceCLI)
+		}
+	}
+	return groups, sources
+}
+
+// configured reports whether name is a group from the configuration file.
+func (p *prefetcher) configured(name string) bool {
+	return slices.ContainsFunc(p.cfg.Groups, func(g prefetch.Group) bool { return g.Name == name })
+}
+
+// runPrefetch resolves every group, fetches what is not cached, and
+// updates the pins.
+func (s *Server) runPrefetch(ctx context.Context) {
+	p := s.prefetch
+	if s.index.Count() == 0 {
+		// Nothing resolves before APT has fetched the indices; keep the
+		// pins until it has
+		s.logger.Debug("Skipping prefetch pass: no package index yet")
+		return
+	}
+	groups, sources := p.groups()
+	if len(groups) == 0 && len(p.cfg.Store.Pinned()) == 0 {
+		return
+	}
+	p.mu.Lock()
+	p.running = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.running = false
+		p.mu.Unlock()
+	}()
+
+	catalog := prefetch.NewCatalog(s.index.Packages(), p.cfg.Architectures)
+	resolutions := make([]prefetch.Resolution, len(groups))
+	wanted := make(map[string]*index.PackageInfo)
+	for i, g := range groups {
+		resolutions[i] = catalog.Resolve(g, p.cfg.Dependencies)
+		for _, pkg := range resolutions[i].Packages {
+			wanted[pkg.SHA256] = pkg
+		}
+	}
+
+	var fetch []*index.PackageInfo
+	for hash, pkg := range wanted {
+		if !s.cache.Has(hash) {
+			fetch = append(fetch, pkg)
+		}
+	}
+	s.fetchPrefetched(ctx, fetch)
+	if ctx.Err() != nil {
+		return
+	}
+	s.updatePrefetchPins(wanted)
+
+	status := make(map[string]PrefetchGroupStatus, len(groups))
+	for i, g := range groups {
+		res := resolutions[i]
+		st := PrefetchGroupStatus{
+			Name:       g.Name,
+			Source:     sources[i],
+			Packages:   g.Packages,
+			Resolved:   len(res.Packages),
+			Missing:    res.Missing,
+			Unresolved: res.Unresolved,
+		}
+		for _, pkg := range res.Packages {
+			if s.cache.Has(pkg.SHA256) {
+				st.Cached++
+				st.Bytes += pkg.Size
+			}
+		}
+		status[g.Name] = st
+	}
+	p.mu.Lock()
+	p.status = status
+	p.lastRun = time.Now()
+	p.mu.Unlock()
+	s.logger.Info("Prefetch pass complete",
+		zap.Int("groups", len(groups)),
+		zap.Int("packages", len(wanted)),
+		zap.Int("uncached", len(fetch)))
+}
+
+// fetchPrefetched downloads pkgs, cfg.Concurrency at a time.
+func (s *Server) fetchPrefetched(ctx context.Context, pkgs []*index.PackageInfo) {
+	sem := make(chan struct{}, s.prefetch.cfg.Concurrency)
+	var wg sync.WaitGroup
+	for _, pkg := range pkgs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := s.prefetchPackage(ctx, pkg); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				s.metrics.PrefetchPackages.WithLabel("failed").Inc()
+				s.logger.Warn("Failed to prefetch package",
+					zap.String("filename", sanitize.Filename(pkg.Filename)),
+					zap.Error(err))
+				return
+			}
+			s.metrics.PrefetchPackages.WithLabel("fetched").Inc()
+		}()
+	}
+	wg.Wait()
+}
+
+// prefetchPackage downloads one indexed package as a client request for
+// it would, at prefetch priority, which caches and announces it.
+func (s *Server) prefetchPackage(ctx context.Context, pkg *index.PackageInfo) error {
+	hash := pkg.SHA256
+	if _, revoked := s.revocations.IsRevoked(hash); revoked {
+		return errors.New("package is revoked")
+	}
+	// The index only holds repositories whose indices came through the
+	// proxy, which checked them against the mirror policy
+	url := "http://" + pkg.Repo + "/" + pkg.Filename
+	ctx, done := s.background.start(ctx, hash, priority.Prefetch)
+	defer done()
+	_, err, _ := s.downloadGroup.Do(hash, func() (interface{}, error) {
+		return s.downloadPackage(ctx, url, hash, pkg.Size, pkg.Filename)
+	})
+	return err
+}
+
+// updatePrefetchPins pins the cached packages in wanted, and unpins those
+// pinned for groups earlier that no group needs now. Packages pinned by
+// other means are left alone.
+func (s *Server) updatePrefetchPins(wanted map[string]*index.PackageInfo) {
+	p := s.prefetch
+	var owned []string
+	for _, hash := range p.cfg.Store.Pinned() {
+		if _, ok := wanted[hash]; ok && p.cfg.Pin {
+			owned = append(owned, hash)
+			continue
+		}
+		if err := s.cache.Unpin(hash); err != nil && !errors.Is(err, cache.ErrNotFound) {
+			s.logger.Warn("Failed to unpin prefetched package", zap.Error(err))
+			owned = append(owned, hash)
+		}
+	}
+	if p.cfg.Pin {
+		for hash := range wanted {
+			if slices.Contains(owned, hash) || !s.cache.Has(hash) || s.cache.IsPinned(hash) {
+				continue
+			}
+			if err := s.cache.Pin(hash); err != nil {
+				s.logger.Warn("Failed to pin prefetched package", zap.Error(err))
+				continue
+			}
+			owned = append(owned, hash)
+		}
+	}
+	if err := p.cfg.Store.SetPinned(owned); err != nil {
+		s.logger.Warn("Failed to record prefetch pins", zap.Error(err))
+	}
+}
+
+// Status returns the groups and the results of the last pass.
+func (p *prefetcher) Status() PrefetchStatus {
+	groups, sources := p.groups()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := PrefetchStatus{
+		Architectures: p.cfg.Architectures,
+		Interval:      p.cfg.Interval.String(),
+		Running:       p.running,
+		LastRun:       p.lastRun,
+		Groups:        make([]PrefetchGroupStatus, 0, len(groups)),
+	}
+	for i, g := range groups {
+		gs, ok := p.status[g.Name]
+		if !ok || !slices.Equal(gs.Packages, g.Packages) {
+			// Not resolved since it was added or changed
+			gs = PrefetchGroupStatus{Name: g.Name, Source: sources[i], Packages: g.Packages}
+		}
+		st.Groups = append(st.Groups, gs)
+	}
+	return st
+}
+
+// GET /api/prefetch
+func (s *Server) handleAPIPrefetch(w http.ResponseWriter, r *http.Request) {
+	if s.prefetch == nil {
+		writeError(w, http.StatusNotFound, "prefetch is not enabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.prefetch.Status())
+}
+
+// POST /api/prefetch/groups with {"name": "base-dev", "packages": [...]}
+func (s *Server) handleAPIPutPrefetchGroup(w http.ResponseWriter, r *http.Request) {
+	p := s.prefetch
+	if p == nil {
+		writeError(w, http.StatusNotFound, "prefetch is not enabled")
+		return
+	}
+	var g prefetch.Group
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&g); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid group: "+err.Error())
+		return
+	}
+	if p.configured(g.Name) {
+		writeError(w, http.StatusConflict, "group "+g.Name+" is defined in the configuration file")
+		return
+	}
+	if err := g.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := p.cfg.Store.Put(g); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.logger.Info("Prefetch group saved", zap.String("group", g.Name), zap.Int("packages", len(g.Packages)))
+	p.requestRun()
+	writeJSON(w, http.StatusOK, p.Status())
+}
+
+// DELETE /api/prefetch/groups/{name}
+func (s *Server) handleAPIRemovePrefetchGroup(w http.ResponseWriter, r *http.Request) {
+	p := s.prefetch
+	if p == nil {
+		writeError(w, http.StatusNotFound, "prefetch is not enabled")
+		return
+	}
+	name := r.PathValue("name")
+	if p.configured(name) {
+		writeError(w, http.StatusConflict, "group "+name+" is defined in the configuration file")
+		return
+	}
+	if err := p.cfg.Store.Remove(name); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, prefetch.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	s.logger.Info("Prefetch group removed", zap.String("group", name))
+	// The next pass unpins what only this group needed
+	p.requestRun()
+	writeJSON(w, http.StatusOK, p.Status())
+}
+
+// POST /api/prefetch/run
+func (s *Server) handleAPIRunPrefetch(w http.ResponseWriter, r *http.Request) {
+	p := s.prefetch
+	if p == nil {
+		writeError(w, http.StatusNotFound, "prefetch is not enabled")
+		return
+	}
+	p.requestRun()
+	writeJSON(w, http.StatusAccepted, p.Status())
+}
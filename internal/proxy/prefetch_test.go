@@ -0,0 +1,183 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/prefetch"
+)
+
+func TestPrefetch_FetchesAndPinsGroups(t *testing.T) {
+	payloads := map[string][]byte{
+		"pool/main/g/git/git_2.39_amd64.deb":            []byte("git package"),
+		"pool/main/g/git-man/git-man_2.39_all.deb":      []byte("git-man package"),
+		"pool/main/m/make/make_4.3_amd64.deb":           []byte("make package"),
+		"pool/main/u/unrelated/unrelated_1.0_amd64.deb": []byte("unrelated package"),
+	}
+	var requests atomic.Int32
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, ok := payloads[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		requests.Add(1)
+		_, _ = w.Write(payload)
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+
+	var packages strings.Builder
+	hashes := make(map[string]string)
+	for path, payload := range payloads {
+		sum := sha256.Sum256(payload)
+		hash := hex.EncodeToString(sum[:])
+		name := strings.Split(filepath.Base(path), "_")[0]
+		arch := strings.TrimSuffix(strings.Split(filepath.Base(path), "_")[2], ".deb")
+		depends := ""
+		if name == "git" {
+			depends = "Depends: git-man (= 2.39), perl | perl-base\n"
+		}
+		fmt.Fprintf(&packages, "Package: %s\nVersion: 2.39\nArchitecture: %s\n%sFilename: %s\nSize: %d\nSHA256: %s\n\n",
+			name, arch, depends, path, len(payload), hash)
+		hashes[name] = hash
+	}
+	if err := server.index.LoadFromData([]byte(packages.String()), mockMirror.URL+"/dists/stable/main/binary-amd64/Packages"); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := prefetch.OpenStore(filepath.Join(t.TempDir(), "prefetch.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(prefetch.Group{Name: "tools", Packages: []string{"make"}}); err != nil {
+		t.Fatal(err)
+	}
+	server.EnablePrefetch(PrefetchConfig{
+		Groups:        []prefetch.Group{{Name: "vcs", Packages: []string{"git", "subversion"}}},
+		Store:         store,
+		Architectures: []string{"amd64"},
+		Dependencies:  true,
+		Pin:           true,
+	})
+	server.runPrefetch(context.Background())
+
+	for _, name := range []string{"git", "git-man", "make"} {
+		if !server.cache.Has(hashes[name]) || !server.cache.IsPinned(hashes[name]) {
+			t.Errorf("%s not cached and pinned", name)
+		}
+	}
+	if server.cache.Has(hashes["unrelated"]) {
+		t.Error("package outside the groups fetched")
+	}
+	st := server.prefetch.Status()
+	if len(st.Groups) != 2 || st.Groups[0].Name != "vcs" || st.Groups[0].Source != "config" || st.Groups[1].Source != "cli" {
+		t.Fatalf("groups = %+v", st.Groups)
+	}
+	vcs := st.Groups[0]
+	if vcs.Resolved != 2 || vcs.Cached != 2 || vcs.Missing[0] != "subversion:amd64" || vcs.Unresolved[0] != "perl | perl-base" {
+		t.Errorf("vcs status = %+v", vcs)
+	}
+
+	// A second pass fetches nothing; removing a group unpins what only it needed
+	before := requests.Load()
+	if err := store.Remove("tools"); err != nil {
+		t.Fatal(err)
+	}
+	server.runPrefetch(context.Background())
+	if requests.Load() != before {
+		t.Errorf("second pass made %d mirror requests", requests.Load()-before)
+	}
+	if server.cache.IsPinned(hashes["make"]) || !server.cache.IsPinned(hashes["git"]) {
+		t.Error("pins not updated after removing a group")
+	}
+	if server.prefetch.Status().Groups[0].Cached != 2 {
+		t.Error("status not updated")
+	}
+}
+
+func TestPrefetch_KeepsOtherPins(t *testing.T) {
+	payload := []byte("make package")
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer mockMirror.Close()
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	pkgURL := indexPackage(t, server, mockMirror.URL, "pool/main/s/streampkg/streampkg_1.0_amd64.deb", payload)
+	hash := sha256Hex(payload)
+
+	server.EnablePrefetch(PrefetchConfig{
+		Groups:        []prefetch.Group{{Name: "stream", Packages: []string{"streampkg"}}},
+		Architectures: []string{"amd64"},
+		Pin:           true,
+	})
+	w := httptest.NewRecorder()
+	server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	if err := server.cache.Pin(hash); err != nil {
+		t.Fatal(err)
+	}
+	server.runPrefetch(context.Background())
+
+	// A package pinned by hand stays pinned when the group goes
+	server.prefetch.cfg.Groups = nil
+	server.runPrefetch(context.Background())
+	if !server.cache.IsPinned(hash) {
+		t.Error("prefetch unpinned a package it did not pin")
+	}
+}
+
+func TestPrefetchAPI(t *testing.T) {
+	server := newTestServer(t)
+	defer shutdownServer(t, server)
+
+	w := httptest.NewRecorder()
+	server.handleAPIPrefetch(w, httptest.NewRequest("GET", "/api/prefetch", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("disabled: status %d", w.Code)
+	}
+
+	server.EnablePrefetch(PrefetchConfig{
+		Groups:        []prefetch.Group{{Name: "base", Packages: []string{"bash"}}},
+		Architectures: []string{"amd64"},
+	})
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{`{"name": "base-dev", "packages": ["build-essential", "git"]}`, http.StatusOK},
+		{`{"name": "base", "packages": ["zsh"]}`, http.StatusConflict},
+		{`{"name": "bad name", "packages": ["git"]}`, http.StatusBadRequest},
+		{`{"name": "empty"}`, http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		server.handleAPIPutPrefetchGroup(w, httptest.NewRequest("POST", "/api/prefetch/groups", strings.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("POST %s: status %d, want %d", tt.body, w.Code, tt.want)
+		}
+	}
+	st := server.prefetch.Status()
+	if len(st.Groups) != 2 || st.Groups[1].Name != "base-dev" || st.Groups[1].Source != "cli" {
+		t.Errorf("groups = %+v", st.Groups)
+	}
+
+	for name, want := range map[string]int{"base": http.StatusConflict, "base-dev": http.StatusOK, "missing": http.StatusNotFound} {
+		r := httptest.NewRequest("DELETE", "/api/prefetch/groups/"+name, nil)
+		r.SetPathValue("name", name)
+		w := httptest.NewRecorder()
+		server.handleAPIRemovePrefetchGroup(w, r)
+		if w.Code != want {
+			t.Errorf("DELETE %s: status %d, want %d", name, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/debswarm/debswarm/internal/priority"
+)
+
+// backgroundDownloads holds the priority levels of background downloads
+// (retries and replication) by hash. The downloader holds them back while
+// clients' downloads run; a client asking for a package one of them is
+// fetching promotes it, so the client does not wait behind it.
+type backgroundDownloads struct {
+	mu     sync.Mutex
+	levels map[string][]*priority.Level
+}
+
+// start returns ctx classified as class for a background download of hash,
+// and a function to call when the download ends.
+func (b *backgroundDownloads) start(ctx context.Context, hash string, class priority.Class) (context.Context, func()) {
+	level := priority.NewLevel(class)
+	b.mu.Lock()
+	if b.levels == nil {
+		b.levels = make(map[string][]*priority.Level)
+	}
+	b.levels[hash] = append(b.levels[hash], level)
+	b.mu.Unlock()
+	return priority.WithLevel(ctx, level), func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if rest := slices.DeleteFunc(b.levels[hash], func(l *priority.Level) bool { return l == level }); len(rest) > 0 {
+			b.levels[hash] = rest
+		} else {
+			delete(b.levels, hash)
+		}
+	}
+}
+
+// promote makes the background downloads of hash interactive.
+func (b *backgroundDownloads) promote(hash string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, l := range b.levels[hash] {
+		l.Promote()
+	}
+}
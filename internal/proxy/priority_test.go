@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/priority"
+)
+
+func TestBackgroundDownloads(t *testing.T) {
+	var b backgroundDownloads
+	retry, endRetry := b.start(context.Background(), "aa", priority.Prefetch)
+	replica, endReplica := b.start(context.Background(), "aa", priority.Replication)
+	other, endOther := b.start(context.Background(), "bb", priority.Prefetch)
+	defer endOther()
+
+	b.promote("aa")
+	if priority.Of(retry) != priority.Interactive || priority.Of(replica) != priority.Interactive {
+		t.Error("background downloads of a requested package not promoted")
+	}
+	if priority.Of(other) != priority.Prefetch {
+		t.Error("background download of another package promoted")
+	}
+
+	endRetry()
+	endReplica()
+	if _, ok := b.levels["aa"]; ok {
+		t.Error("finished downloads still tracked")
+	}
+}
@@ -86,13 +86,13 @@ func TestProcessDownloadSuccess_CleansAssemblyDir(t *testing.T) {
 		t.Fatalf("write chunk: %v", err)
 	}
 
-	res := s.processDownloadSuccess(context.Background(), &downloader.DownloadResult{
+	res, err := s.processDownloadSuccess(context.Background(), &downloader.DownloadResult{
 		FilePath: assembly,
 		Size:     int64(len(content)),
 		Source:   downloader.SourceTypeMixed,
 	}, hash, "pkg_1.0_amd64.deb")
 
-	if res == nil || !res.serveFromCache {
+	if err != nil || res == nil || !res.serveFromCache {
 		t.Fatalf("expected a serve-from-cache result, got %+v", res)
 	}
 	if _, err := os.Stat(partialDir); !os.IsNotExist(err) {
@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/audit"
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/downloader"
+	"github.com/debswarm/debswarm/internal/requestid"
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+// Read-through: a chunked download that was interrupted (daemon restart,
+// peers gone, client cancelled) leaves its completed chunks in the partial
+// directory. Previously the next request for the package waited while the
+// downloader resumed it in full before sending APT a byte. Now the leading
+// request streams the completed prefix at once through the in-flight spool,
+// and the remainder follows from the mirror with a range request. As with
+// any spooled download, the last byte is withheld until the whole package
+// has been verified against the index hash.
+
+// resumablePrefix returns how many bytes of the package are already on disk
+// from an interrupted download, or 0 when read-through does not apply.
+func (s *Server) resumablePrefix(hash string, size int64) int64 {
+	if !s.readThrough || s.downloader == nil || size <= 0 {
+		return 0
+	}
+	_, n := s.downloader.ResumablePrefix(hash, size)
+	return n
+}
+
+// serveReadThrough completes the interrupted download registered as fl in the
+// background and serves the request by streaming it, the leader attaching to
+// its own download like any follower.
+func (s *Server) serveReadThrough(w http.ResponseWriter, r *http.Request, fl *inflightDownload, url, expectedHash string, expectedSize int64, path string) {
+	ctx := r.Context()
+	log := requestid.LoggerFromContext(ctx, s.logger)
+	log.Debug("Serving interrupted download from its completed prefix",
+		zap.String("url", sanitize.URL(url)))
+
+	go func() {
+		dctx, cancel := s.detachDownload(ctx)
+		defer cancel()
+		result, err, _ := s.downloadGroup.Do(expectedHash, func() (interface{}, error) {
+			return s.downloadReadThrough(dctx, url, expectedHash, expectedSize, path)
+		})
+		var downloadResult *packageDownloadResult
+		if err == nil {
+			downloadResult = result.(*packageDownloadResult)
+		}
+		s.inflight.finish(fl, downloadResult, err)
+	}()
+
+	if s.serveInflight(w, r, fl, log) {
+		s.packageServed(ctx, expectedHash, url, path, expectedSize)
+	}
+}
+
+// downloadReadThrough caches the package from the prefix of its interrupted
+// download followed by the rest from the mirror, spooling both to requests
+// attached to the download. It falls back to a normal download when the
+// prefix has gone or the mirror does not honour the range.
+func (s *Server) downloadReadThrough(ctx context.Context, url, expectedHash string, expectedSize int64, path string) (*packageDownloadResult, error) {
+	log := requestid.LoggerFromContext(ctx, s.logger)
+
+	assembled, prefix := s.downloader.ResumablePrefix(expectedHash, expectedSize)
+	if prefix == 0 {
+		return s.downloadPackage(ctx, url, expectedHash, expectedSize, path)
+	}
+	f, err := os.Open(assembled)
+	if err != nil {
+		return s.downloadPackage(ctx, url, expectedHash, expectedSize, path)
+	}
+	defer f.Close()
+
+	counted := &countingReader{r: http.NoBody}
+	if prefix < expectedSize {
+		mirrorURL := s.upstreamFetchURL(url)
+		body, err := s.fetcher.StreamRange(ctx, mirrorURL, prefix)
+		if err != nil {
+			log.Debug("Mirror cannot resume the download, fetching it in full",
+				zap.String("url", sanitize.URL(mirrorURL)), zap.Error(err))
+			return s.downloadPackage(ctx, url, expectedHash, expectedSize, path)
+		}
+		defer func() {
+			if closeErr := body.Close(); closeErr != nil {
+				log.Debug("Failed to close mirror response body", zap.Error(closeErr))
+			}
+		}()
+		counted.r = body
+		atomic.AddInt64(&s.requestsMirror, 1)
+	}
+
+	var src io.Reader = io.MultiReader(io.NewSectionReader(f, 0, prefix), counted)
+	if fl := s.inflight.get(expectedHash); fl != nil && fl.startSpool(s.spoolDir()) {
+		src = io.TeeReader(src, fl)
+	}
+	putErr := s.cache.Put(src, expectedHash, path)
+	s.noteCacheWrite(putErr)
+	if putErr != nil {
+		if errors.Is(putErr, cache.ErrHashMismatch) {
+			// The prefix or the mirror's remainder is wrong; either way the
+			// partial download is worthless now
+			log.Warn("Resumed download failed hash verification, discarding it",
+				zap.String("expected", expectedHash),
+				zap.Error(putErr))
+			s.downloader.DiscardPartial(expectedHash)
+			s.metrics.VerificationFailures.Inc()
+			s.audit.Log(audit.NewVerificationFailedEvent(expectedHash, path, "mirror").WithRequest(ctx))
+		}
+		return nil, fmt.Errorf("failed to complete interrupted download: %w", putErr)
+	}
+	s.downloader.DiscardPartial(expectedHash)
+
+	fetched := counted.n
+	s.metrics.ReadThroughDownloads.Inc()
+	atomic.AddInt64(&s.bytesFromMirror, fetched)
+	s.metrics.DownloadsTotal.WithLabel(downloader.SourceTypeMirror).Inc()
+	s.metrics.BytesDownloaded.WithLabel(downloader.SourceTypeMirror).Add(fetched)
+
+	s.contentVerified(expectedHash, path, expectedSize, downloader.SourceTypeMirror, nil)
+	s.recordOrigin(url, expectedHash)
+	s.announceAsync(expectedHash)
+	if s.verifier != nil {
+		s.verifier.VerifyAsync(expectedHash, path)
+	}
+	s.audit.Log(audit.NewDownloadCompleteEvent(
+		expectedHash,
+		path,
+		expectedSize,
+		downloader.SourceTypeMirror,
+		0,
+		0,
+		fetched,
+	).WithRequest(ctx))
+
+	log.Debug("Completed interrupted download",
+		zap.String("hash", expectedHash[:16]+"..."),
+		zap.Int64("resumedBytes", prefix),
+		zap.Int64("mirrorBytes", fetched))
+
+	return &packageDownloadResult{
+		hash:           expectedHash,
+		size:           expectedSize,
+		source:         downloader.SourceTypeMirror,
+		contentType:    "application/vnd.debian.binary-package",
+		serveFromCache: true,
+	}, nil
+}
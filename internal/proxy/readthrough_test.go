@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/downloader"
+)
+
+// rangeMirror serves payload honouring Range requests and records the Range
+// header of each request.
+type rangeMirror struct {
+	payload []byte
+	mu      sync.Mutex
+	ranges  []string
+}
+
+func (m *rangeMirror) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		m.ranges = append(m.ranges, r.Header.Get("Range"))
+		m.mu.Unlock()
+		http.ServeContent(w, r, "pkg.deb", time.Time{}, bytes.NewReader(m.payload))
+	})
+}
+
+// interruptDownload leaves the first chunks of payload on disk as an
+// interrupted chunked download would.
+func interruptDownload(t *testing.T, server *Server, payload []byte, chunks int) {
+	t.Helper()
+	hash := sha256Hex(payload)
+	if err := server.cache.EnsurePartialDir(hash); err != nil {
+		t.Fatal(err)
+	}
+	prefix := int64(chunks) * downloader.DefaultChunkSize
+	assembled := make([]byte, len(payload))
+	copy(assembled[:prefix], payload[:prefix])
+	if err := os.WriteFile(filepath.Join(server.cache.PartialDir(hash), "assembled"), assembled, 0600); err != nil {
+		t.Fatal(err)
+	}
+	sm := server.downloader.GetStateManager()
+	if err := sm.CreateDownload(hash, "", int64(len(payload)), downloader.DefaultChunkSize); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < chunks; i++ {
+		if err := sm.UpdateChunk(hash, i, "completed"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadThrough_ResumesFromPrefix(t *testing.T) {
+	payload := make([]byte, downloader.DefaultChunkSize+300*1024)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	m := &rangeMirror{payload: payload}
+	mockMirror := httptest.NewServer(m.handler())
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	server.readThrough = true
+	pkgURL := indexPackage(t, server, mockMirror.URL, "pool/main/s/streampkg/streampkg_1.0_amd64.deb", payload)
+	interruptDownload(t, server, payload, 1)
+
+	w := httptest.NewRecorder()
+	server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), payload) {
+		t.Fatalf("status %d, %d bytes, want the full package", w.Code, w.Body.Len())
+	}
+
+	m.mu.Lock()
+	ranges := m.ranges
+	m.mu.Unlock()
+	if len(ranges) != 1 || ranges[0] != "bytes=4194304-" {
+		t.Errorf("mirror requests = %q, want one for the remainder", ranges)
+	}
+	if got := server.metrics.ReadThroughDownloads.Value(); got != 1 {
+		t.Errorf("ReadThroughDownloads = %d, want 1", got)
+	}
+	if got := server.metrics.BytesDownloaded.WithLabel(downloader.SourceTypeMirror).Value(); got != 300*1024 {
+		t.Errorf("mirror bytes = %d, want %d", got, 300*1024)
+	}
+	hash := sha256Hex(payload)
+	if pkg, err := server.cache.Info(hash); err != nil {
+		t.Error("package not cached")
+	} else if pkg.Origin.Suite != "stable" || pkg.Origin.Mirror != pkgURL {
+		t.Errorf("origin = %+v, want suite stable from %s", pkg.Origin, pkgURL)
+	}
+	if _, n := server.downloader.ResumablePrefix(hash, int64(len(payload))); n != 0 {
+		t.Error("partial download not discarded after completion")
+	}
+}
+
+func TestReadThrough_CorruptPrefixDiscarded(t *testing.T) {
+	payload := make([]byte, downloader.DefaultChunkSize+1024)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	m := &rangeMirror{payload: payload}
+	mockMirror := httptest.NewServer(m.handler())
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	server.readThrough = true
+	pkgURL := indexPackage(t, server, mockMirror.URL, "pool/main/s/streampkg/streampkg_1.0_amd64.deb", payload)
+	corrupt := append([]byte(nil), payload...)
+	corrupt[0] ^= 0xff
+	interruptDownload(t, server, payload, 1)
+	hash := sha256Hex(payload)
+	if err := os.WriteFile(filepath.Join(server.cache.PartialDir(hash), "assembled"), corrupt, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	if w.Body.Len() >= len(payload) {
+		t.Fatalf("received %d bytes of an unverified %d-byte package", w.Body.Len(), len(payload))
+	}
+	if server.cache.Has(hash) {
+		t.Error("corrupt package cached")
+	}
+	if _, n := server.downloader.ResumablePrefix(hash, int64(len(payload))); n != 0 {
+		t.Error("corrupt partial download kept")
+	}
+
+	// The next request downloads the package afresh
+	w = httptest.NewRecorder()
+	server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), payload) {
+		t.Fatalf("retry: status %d, %d bytes", w.Code, w.Body.Len())
+	}
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/p2p"
+)
+
+// Receipt states, from checking a receipt's bytes against the package
+const (
+	receiptGood    = "good"    // the bytes are those of the verified package
+	receiptCorrupt = "corrupt" // the uploader sent other bytes
+)
+
+// ReceiptInfo is a stored receipt as the API reports it.
+type ReceiptInfo struct {
+	*p2p.Receipt
+	UploaderName string    `json:"uploader_name,omitempty"`
+	ReceivedAt   time.Time `json:"received_at"`
+	// Valid is whether the signature verifies against the uploader's ID
+	Valid bool `json:"valid"`
+	// Content is "good" or "corrupt" once the package is in the cache,
+	// so its bytes are known; empty while they are not
+	Content string `json:"content,omitempty"`
+}
+
+// storeReceipt keeps the receipt of a download from a peer.
+func (s *Server) storeReceipt(r *p2p.Receipt) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	err = s.cache.StoreReceipt(cache.PeerReceipt{
+		SHA256:     r.SHA256,
+		Uploader:   r.Uploader,
+		Start:      r.Start,
+		End:        r.End,
+		ReceivedAt: time.Now(),
+		Receipt:    data,
+	})
+	if err != nil {
+		s.logger.Warn("Failed to store transfer receipt", zap.Error(err))
+	}
+}
+
+// receiptContent checks the digest of a receipt against the same bytes of
+// the cached package, which was verified against its hash when it was
+// stored. It returns "" when the package is not cached.
+func (s *Server) receiptContent(r *p2p.Receipt) string {
+	reader, _, err := s.cache.Get(r.SHA256)
+	if err != nil {
+		return ""
+	}
+	defer reader.Close()
+	if seeker, ok := reader.(io.Seeker); ok {
+		if _, err := seeker.Seek(r.Start, io.SeekStart); err != nil {
+			return ""
+		}
+	} else if _, err := io.CopyN(io.Discard, reader, r.Start); err != nil {
+		return ""
+	}
+	h := sha256.New()
+	if n, err := io.CopyN(h, reader, r.Size()); err != nil || n != r.Size() {
+		// The package is shorter than the receipt says: not its bytes
+		return receiptCorrupt
+	}
+	if hex.EncodeToString(h.Sum(nil)) != r.Digest {
+		return receiptCorrupt
+	}
+	return receiptGood
+}
+
+// GET /api/peers/receipts?hash=H&peer=ID&limit=N
+//
+// Receipts of downloads from peers, most recent first, optionally for one
+// package or one uploader. Each is checked against the cached package, so
+// a receipt for bytes other than the package's shows which peer sent them.
+func (s *Server) handleAPIReceipts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := cache.ReceiptFilter{SHA256: q.Get("hash"), Uploader: q.Get("peer"), Limit: 100}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		filter.Limit = n
+	}
+
+	stored, err := s.cache.Receipts(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	result := make([]ReceiptInfo, 0, len(stored))
+	for _, st := range stored {
+		var rc p2p.Receipt
+		if err := json.Unmarshal(st.Receipt, &rc); err != nil {
+			continue
+		}
+		info := ReceiptInfo{Receipt: &rc, ReceivedAt: st.ReceivedAt, Valid: rc.Verify() == nil}
+		if s.scorer != nil {
+			if id, err := rc.UploaderID(); err == nil {
+				info.UploaderName = s.scorer.Name(id)
+			}
+		}
+		if info.Valid {
+			info.Content = s.receiptContent(&rc)
+		}
+		result = append(result, info)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
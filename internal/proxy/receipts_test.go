@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/debswarm/debswarm/internal/hashutil"
+	"github.com/debswarm/debswarm/internal/p2p"
+)
+
+func TestAPIReceipts(t *testing.T) {
+	server := newTestServer(t)
+	defer shutdownServer(t, server)
+
+	payload := []byte("0123456789ABCDEF")
+	hash := hashutil.HashBytes(payload)
+	if err := server.cache.Put(bytes.NewReader(payload), hash, "receipt_1.0_all.deb"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	receipt := func(start, end int64, sent []byte) *p2p.Receipt {
+		t.Helper()
+		key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := peer.IDFromPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &p2p.Receipt{
+			SHA256: hash, Start: start, End: end, Digest: hashutil.HashBytes(sent),
+			Uploader: id.String(), Downloader: "self", Time: time.Now().Truncate(time.Second),
+		}
+		if err := r.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	good := receipt(0, 8, payload[:8])
+	corrupt := receipt(8, 16, []byte("XXXXXXXX"))
+	tampered := receipt(0, 16, payload)
+	tampered.End = 12
+	for _, r := range []*p2p.Receipt{good, corrupt, tampered} {
+		server.storeReceipt(r)
+	}
+
+	get := func(query string) []ReceiptInfo {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.handleAPIReceipts(w, httptest.NewRequest("GET", "/api/peers/receipts"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body.String())
+		}
+		var out []ReceiptInfo
+		if err := json.NewDecoder(w.Body).Decode(&out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return out
+	}
+
+	byUploader := make(map[string]ReceiptInfo)
+	for _, info := range get("?hash=" + hash) {
+		byUploader[info.Uploader] = info
+	}
+	if len(byUploader) != 3 {
+		t.Fatalf("got %d receipts, want 3", len(byUploader))
+	}
+	if info := byUploader[good.Uploader]; !info.Valid || info.Content != receiptGood {
+		t.Errorf("good receipt: valid %v, content %q", info.Valid, info.Content)
+	}
+	if info := byUploader[corrupt.Uploader]; !info.Valid || info.Content != receiptCorrupt {
+		t.Errorf("corrupt receipt: valid %v, content %q", info.Valid, info.Content)
+	}
+	if info := byUploader[tampered.Uploader]; info.Valid || info.Content != "" {
+		t.Errorf("tampered receipt: valid %v, content %q", info.Valid, info.Content)
+	}
+
+	if out := get("?peer=" + corrupt.Uploader); len(out) != 1 || out[0].Start != 8 {
+		t.Errorf("receipts of one peer = %+v", out)
+	}
+
+	w := httptest.NewRecorder()
+	server.handleAPIReceipts(w, httptest.NewRequest("GET", "/api/peers/receipts?limit=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("limit=0: status %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,324 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/downloader"
+	"github.com/debswarm/debswarm/internal/p2p"
+	"github.com/debswarm/debswarm/internal/priority"
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+// Replication keeps seeds' caches in step for high availability: each seed
+// subscribes to its partners' new-content events (see p2p.ProtocolReplicate)
+// and fetches what they cache, over the normal transfer protocol, so losing
+// one seed loses no content. Every package is verified against its hash as
+// it is stored, as any download is. Fetched packages are published to this
+// node's own partners in turn, so a chain of seeds converges; a package a
+// seed already holds is never fetched twice.
+
+const (
+	// replicationQueue bounds the fetches waiting. A partner whose events
+	// arrive faster than they are fetched is held up, falls behind and
+	// catches up from its backlog.
+	replicationQueue = 1024
+
+	replicationRetryMin = 5 * time.Second
+	replicationRetryMax = 5 * time.Minute
+)
+
+// ReplicationConfig selects what is replicated from partners.
+type ReplicationConfig struct {
+	// Repos limits replication to packages from these repositories
+	// ("deb.debian.org/debian"); empty replicates all
+	Repos []string
+	// Architectures limits replication to these architectures; empty
+	// replicates all
+	Architectures []string
+	// MaxSize skips larger packages (0 = no limit)
+	MaxSize int64
+	// Concurrency is how many packages are fetched at once (default 2)
+	Concurrency int
+	// CatchUp is how far back a partner's backlog is requested on
+	// startup, to pick up what was cached while this node was down
+	// (0 = live events only)
+	CatchUp time.Duration
+}
+
+// matches reports whether ev passes the filters.
+func (c *ReplicationConfig) matches(ev *p2p.ReplicationEvent) bool {
+	if c.MaxSize > 0 && ev.Size > c.MaxSize {
+		return false
+	}
+	if len(c.Architectures) > 0 && !slices.Contains(c.Architectures, ev.Architecture) {
+		return false
+	}
+	if len(c.Repos) == 0 {
+		return true
+	}
+	for _, r := range c.Repos {
+		r = strings.TrimSuffix(r, "/")
+		if ev.Repo == r || strings.HasPrefix(ev.Repo, r+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+type replicationJob struct {
+	partner peer.AddrInfo
+	event   p2p.ReplicationEvent
+}
+
+// errReplicaCached reports a replication event for a package already cached
+var errReplicaCached = errors.New("already cached")
+
+// Replicate subscribes to the P2P node's replication partners and fetches
+// the packages they cache that pass cfg's filters. It also serves this
+// node's backlog to partners that subscribe to it. It runs until ctx is
+// canceled.
+func (s *Server) Replicate(ctx context.Context, cfg ReplicationConfig) {
+	node := s.p2pNode
+	if node == nil {
+		return
+	}
+	partners := node.ReplicationPartners()
+	if len(partners) == 0 {
+		return
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 2
+	}
+	node.SetReplicationBacklog(s.replicationBacklog)
+
+	queue := make(chan replicationJob, replicationQueue)
+	for range cfg.Concurrency {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-queue:
+					s.metrics.ReplicationQueueDepth.Set(float64(len(queue)))
+					s.runReplicationJob(ctx, job)
+				}
+			}
+		}()
+	}
+
+	since := time.Time{}
+	if cfg.CatchUp > 0 {
+		since = time.Now().Add(-cfg.CatchUp)
+	}
+	for _, partner := range partners {
+		go s.followPartner(ctx, node, partner, since, &cfg, queue)
+	}
+	<-ctx.Done()
+}
+
+// followPartner keeps a subscription to one partner open, resubscribing
+// with backoff, from where the last one left off.
+func (s *Server) followPartner(ctx context.Context, node *p2p.Node, partner peer.AddrInfo, since time.Time, cfg *ReplicationConfig, queue chan<- replicationJob) {
+	log := s.logger.With(zap.String("partner", partner.ID.String()))
+	backoff := replicationRetryMin
+	for {
+		subscribed := false
+		err := node.SubscribeReplication(ctx, partner, since, func() {
+			subscribed = true
+			backoff = replicationRetryMin
+			s.metrics.ReplicationPartners.Inc()
+			log.Info("Subscribed to replication partner", zap.Time("since", since))
+		}, func(ev p2p.ReplicationEvent) {
+			if ev.CachedAt.After(since) {
+				since = ev.CachedAt
+			}
+			if !isValidSHA256(ev.SHA256) || ev.Size <= 0 {
+				return
+			}
+			if !cfg.matches(&ev) {
+				s.metrics.Replications.WithLabel("filtered").Inc()
+				return
+			}
+			if s.cache.Has(ev.SHA256) {
+				s.metrics.Replications.WithLabel("cached").Inc()
+				return
+			}
+			// Block rather than drop: the partner disconnects us once we fall
+			// too far behind, and the resubscription catches up from since
+			select {
+			case queue <- replicationJob{partner: partner, event: ev}:
+				s.metrics.ReplicationQueueDepth.Set(float64(len(queue)))
+			case <-ctx.Done():
+			}
+		})
+		if subscribed {
+			s.metrics.ReplicationPartners.Dec()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, p2p.ErrReplicationRefused) {
+			log.Warn("Replication partner refused the subscription; it is paused, or does not list this node in replication.partners")
+		} else {
+			log.Debug("Replication subscription ended", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, replicationRetryMax)
+	}
+}
+
+// runReplicationJob fetches one package and records the outcome.
+func (s *Server) runReplicationJob(ctx context.Context, job replicationJob) {
+	ev := &job.event
+	err := s.replicatePackage(ctx, job.partner, ev)
+	switch {
+	case err == nil:
+		s.metrics.Replications.WithLabel("replicated").Inc()
+		s.metrics.ReplicationLag.Observe(max(time.Since(ev.CachedAt), 0).Seconds())
+		s.logger.Debug("Replicated package from partner",
+			zap.String("filename", sanitize.Filename(ev.Filename)),
+			zap.String("partner", job.partner.ID.String()))
+	case errors.Is(err, errReplicaCached):
+		s.metrics.Replications.WithLabel("cached").Inc()
+	default:
+		if ctx.Err() != nil {
+			return
+		}
+		s.metrics.Replications.WithLabel("failed").Inc()
+		s.logger.Warn("Failed to replicate package from partner",
+			zap.String("hash", ev.SHA256[:16]+"..."),
+			zap.String("filename", sanitize.Filename(ev.Filename)),
+			zap.String("partner", job.partner.ID.String()),
+			zap.Error(err))
+	}
+}
+
+// replicatePackage downloads a package from a partner, stores it with the
+// partner's metadata, and announces it.
+func (s *Server) replicatePackage(ctx context.Context, partner peer.AddrInfo, ev *p2p.ReplicationEvent) error {
+	hash := ev.SHA256
+	if s.cache.Has(hash) {
+		return errReplicaCached
+	}
+	if _, revoked := s.revocations.IsRevoked(hash); revoked {
+		return fmt.Errorf("package is revoked")
+	}
+	filename := ev.Filename
+	if filename == "" {
+		filename = hash
+	}
+
+	ctx, done := s.background.start(ctx, hash, priority.Replication)
+	defer done()
+	node := s.p2pNode
+	src := &downloader.PeerSource{
+		Info: partner,
+		Downloader: func(ctx context.Context, info peer.AddrInfo, hash string, start, end int64) ([]byte, error) {
+			return node.DownloadRange(p2p.WithContentCheck(ctx, p2p.ExpectTotalSize(ev.Size)), info, hash, start, end)
+		},
+	}
+	result, err := s.downloader.Download(ctx, hash, ev.Size, []downloader.Source{src}, nil)
+	if err != nil {
+		return err
+	}
+	if result.FilePath != "" {
+		err = s.cache.PutFile(result.FilePath, hash, filename, result.Size)
+		_ = os.RemoveAll(filepath.Dir(result.FilePath))
+	} else {
+		err = s.cache.Put(bytes.NewReader(result.Data), hash, filename)
+	}
+	s.noteCacheWrite(err)
+	if err != nil {
+		return err
+	}
+
+	if ev.Package != "" {
+		if err := s.cache.SetPackageMetadata(hash, ev.Package, ev.Version, ev.Architecture); err != nil {
+			s.logger.Debug("Failed to record replicated package metadata", zap.Error(err))
+		}
+	}
+	if ev.Repo != "" {
+		origin := cache.Origin{Repo: ev.Repo, Suite: ev.Suite, Component: ev.Component}
+		if err := s.cache.SetOrigin(hash, origin); err != nil {
+			s.logger.Debug("Failed to record replicated package origin", zap.Error(err))
+		}
+	}
+	s.contentVerified(hash, filename, result.Size, downloader.SourceTypePeer, nil)
+	s.announceAsync(hash)
+	s.publishReplication(hash)
+	return nil
+}
+
+// publishReplication tells subscribed replication partners about a package
+// just cached, if it may be shared.
+func (s *Server) publishReplication(hash string) {
+	node := s.p2pNode
+	if node == nil || node.Paused() || !node.HasReplicationSubscribers() {
+		return
+	}
+	go func() {
+		pkg, err := s.cache.Info(hash)
+		if err != nil {
+			return
+		}
+		if _, revoked := s.revocations.IsRevoked(hash); revoked || !s.allowAnnounce(s.announceCtx, hash, pkg) {
+			return
+		}
+		node.PublishReplication(replicationEvent(pkg))
+	}()
+}
+
+// replicationBacklog lists the shareable packages cached at or after since,
+// oldest first, for a partner catching up.
+func (s *Server) replicationBacklog(since time.Time) []p2p.ReplicationEvent {
+	pkgs, err := s.cache.List()
+	if err != nil {
+		s.logger.Warn("Failed to list cache for replication backlog", zap.Error(err))
+		return nil
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].AddedAt.Before(pkgs[j].AddedAt) })
+	var events []p2p.ReplicationEvent
+	for _, pkg := range pkgs {
+		if pkg.AddedAt.Before(since) {
+			continue
+		}
+		if _, revoked := s.revocations.IsRevoked(pkg.SHA256); revoked || !s.allowAnnounce(s.announceCtx, pkg.SHA256, pkg) {
+			continue
+		}
+		events = append(events, replicationEvent(pkg))
+	}
+	return events
+}
+
+func replicationEvent(pkg *cache.Package) p2p.ReplicationEvent {
+	return p2p.ReplicationEvent{
+		SHA256:       pkg.SHA256,
+		Size:         pkg.Size,
+		Filename:     pkg.Filename,
+		Package:      pkg.PackageName,
+		Version:      pkg.PackageVersion,
+		Architecture: pkg.Architecture,
+		Repo:         pkg.Origin.Repo,
+		Suite:        pkg.Origin.Suite,
+		Component:    pkg.Origin.Component,
+		CachedAt:     pkg.AddedAt,
+	}
+}
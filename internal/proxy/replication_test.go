@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/p2p"
+)
+
+func TestReplicationConfigMatches(t *testing.T) {
+	cfg := ReplicationConfig{
+		Repos:         []string{"deb.debian.org/debian/"},
+		Architectures: []string{"amd64", "all"},
+		MaxSize:       1000,
+	}
+	for _, tc := range []struct {
+		ev   p2p.ReplicationEvent
+		want bool
+	}{
+		{p2p.ReplicationEvent{Repo: "deb.debian.org/debian", Architecture: "amd64", Size: 10}, true},
+		{p2p.ReplicationEvent{Repo: "deb.debian.org/debian", Architecture: "all", Size: 1000}, true},
+		{p2p.ReplicationEvent{Repo: "deb.debian.org/debian", Architecture: "arm64", Size: 10}, false},
+		{p2p.ReplicationEvent{Repo: "deb.debian.org/debian", Architecture: "amd64", Size: 1001}, false},
+		{p2p.ReplicationEvent{Repo: "deb.debian.org/debian-security", Architecture: "amd64", Size: 10}, false},
+		{p2p.ReplicationEvent{Architecture: "amd64", Size: 10}, false},
+	} {
+		if got := cfg.matches(&tc.ev); got != tc.want {
+			t.Errorf("matches(%+v) = %v, want %v", tc.ev, got, tc.want)
+		}
+	}
+	if all := (ReplicationConfig{}); !all.matches(&p2p.ReplicationEvent{Size: 1 << 40}) {
+		t.Error("empty filters rejected a package")
+	}
+}
+
+func TestReplicationBacklog(t *testing.T) {
+	s := newTestServer(t)
+	data := []byte("replicated package")
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if err := s.cache.Put(bytes.NewReader(data), hash, "hello_1.0_amd64.deb"); err != nil {
+		t.Fatal(err)
+	}
+	origin := cache.Origin{Repo: "deb.debian.org/debian", Suite: "bookworm", Component: "main"}
+	if err := s.cache.SetOrigin(hash, origin); err != nil {
+		t.Fatal(err)
+	}
+
+	events := s.replicationBacklog(time.Now().Add(-time.Hour))
+	if len(events) != 1 {
+		t.Fatalf("backlog = %+v", events)
+	}
+	ev := events[0]
+	if ev.SHA256 != hash || ev.Size != int64(len(data)) || ev.Architecture != "amd64" ||
+		ev.Repo != origin.Repo || ev.Suite != origin.Suite || ev.CachedAt.IsZero() {
+		t.Errorf("event = %+v", ev)
+	}
+
+	if events := s.replicationBacklog(time.Now().Add(time.Hour)); len(events) != 0 {
+		t.Errorf("backlog from the future = %+v", events)
+	}
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/debswarm/debswarm/internal/gpg"
+)
+
+// Repo is a third-party repository with its own policy. Its hosts must also
+// be in Config.AllowedHosts for the proxy to fetch from them.
+type Repo struct {
+	Name string
+
+	// Hosts are the repository's hostnames; subdomains match too
+	Hosts []string
+
+	// Share fetches its packages and indexes from peers and serves them to
+	// peers
+	Share bool
+
+	// Keyring, if not nil, is the only keyring its Release files are
+	// verified against
+	Keyring *gpg.Keyring
+
+	// UploadPriority serves its packages to peers ahead of others, as for
+	// security updates
+	UploadPriority bool
+}
+
+// repoForHost returns the configured repository serving host, or nil.
+func (s *Server) repoForHost(host string) *Repo {
+	host = strings.ToLower(host)
+	for i := range s.repos {
+		for _, h := range s.repos[i].Hosts {
+			if h = strings.ToLower(h); host == h || strings.HasSuffix(host, "."+h) {
+				return &s.repos[i]
+			}
+		}
+	}
+	return nil
+}
+
+// repoForURL returns the configured repository rawURL belongs to, or nil.
+// Relative paths belong to none.
+func (s *Server) repoForURL(rawURL string) *Repo {
+	if len(s.repos) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	return s.repoForHost(u.Hostname())
+}
+
+// originForHash returns the repository a package comes from, going by the
+// package index or, for a package the index does not list (lists not loaded
+// yet, or a newer version superseded it), the origin recorded when it was
+// cached. It is "" when neither knows the package.
+func (s *Server) originForHash(hash string) string {
+	if pkg := s.index.GetBySHA256(hash); pkg != nil {
+		return pkg.Repo
+	}
+	if pkg, err := s.cache.Info(hash); err == nil {
+		return pkg.Origin.Repo
+	}
+	return ""
+}
+
+// repoForHash returns the configured repository a package comes from (see
+// originForHash).
+func (s *Server) repoForHash(hash string) *Repo {
+	if len(s.repos) == 0 {
+		return nil
+	}
+	origin := s.originForHash(hash)
+	if origin == "" {
+		return nil
+	}
+	host, _, _ := strings.Cut(origin, "/")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return s.repoForHost(host)
+}
+
+// repoShares reports whether a package may be shared over P2P as far as its
+// repository's configuration goes.
+func (s *Server) repoShares(hash string) bool {
+	repo := s.repoForHash(hash)
+	return repo == nil || repo.Share
+}
+
+// restrictsRepoSharing reports whether any repository is configured not to
+// be shared.
+func (s *Server) restrictsRepoSharing() bool {
+	for _, r := range s.repos {
+		if !r.Share {
+			return true
+		}
+	}
+	return false
+}
+
+// keyringFor returns the keyring the Release files at rawURL are verified
+// against: the repository's own, if configured, else the trusted keyring.
+func (s *Server) keyringFor(rawURL string) *gpg.Keyring {
+	if repo := s.repoForURL(rawURL); repo != nil && repo.Keyring != nil {
+		return repo.Keyring
+	}
+	return s.keyring
+}
+
+// uploadPriority reports whether an upload of a cached package goes ahead
+// of others: security updates and packages from priority repositories.
+func (s *Server) uploadPriority(hash string) bool {
+	if repo := s.repoForHash(hash); repo != nil && repo.UploadPriority {
+		return true
+	}
+	return s.isSecurityPackage(hash)
+}
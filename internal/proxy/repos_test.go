@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/gpg"
+	"github.com/debswarm/debswarm/internal/hashutil"
+)
+
+func TestRepoPolicy(t *testing.T) {
+	payload := []byte("vendor package payload")
+	hash := hashutil.HashBytes(payload)
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	vendorKeys := &gpg.Keyring{}
+	server.repos = []Repo{{Name: "vendor", Hosts: []string{"127.0.0.1"}, Share: false, Keyring: vendorKeys, UploadPriority: true}}
+
+	pkgPath := "pool/main/v/vendor/vendor_1.0_amd64.deb"
+	packages := fmt.Sprintf("Package: vendor\nFilename: %s\nSize: %d\nSHA256: %s\n\n", pkgPath, len(payload), hash)
+	if err := server.index.LoadFromData([]byte(packages), mockMirror.URL+"/apt/dists/stable/main/binary-amd64/Packages"); err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+	pkgURL := mockMirror.URL + "/apt/" + pkgPath
+	w := httptest.NewRecorder()
+	server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+pkgURL, nil), pkgURL)
+	if w.Code != http.StatusOK || !server.cache.Has(hash) {
+		t.Fatalf("status = %d, cached = %v", w.Code, server.cache.Has(hash))
+	}
+
+	if server.policyForURL(pkgURL).Share {
+		t.Error("package of an unshared repository may be fetched from peers")
+	}
+	if _, _, err := server.packageForPeer(nil, hash); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("packageForPeer error = %v, want ErrNotFound", err)
+	}
+	if server.allowAnnounce(t.Context(), hash, nil) {
+		t.Error("package of an unshared repository would be announced")
+	}
+	if !server.uploadPriority(hash) {
+		t.Error("package of a priority repository is not uploaded with priority")
+	}
+	if server.keyringFor(mockMirror.URL+"/apt/dists/stable/InRelease") != vendorKeys {
+		t.Error("repository Release not verified against its own keyring")
+	}
+	if server.keyringFor("http://deb.debian.org/debian/dists/stable/InRelease") != server.keyring {
+		t.Error("other repositories not verified against the trusted keyring")
+	}
+
+	server.repos[0].Share = true
+	reader, _, err := server.packageForPeer(nil, hash)
+	if err != nil {
+		t.Fatalf("packageForPeer of a shared repository: %v", err)
+	}
+	_ = reader.Close()
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/audit"
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/revocation"
+)
+
+// refuseRevoked answers 410 Gone for a revoked hash and reports whether it
+// did. Gone (not 404) tells the operator the package was pulled on purpose,
+// and APT surfaces the status text in its error.
+func (s *Server) refuseRevoked(ctx context.Context, w http.ResponseWriter, hash string) bool {
+	reason, revoked := s.revocations.IsRevoked(hash)
+	if !revoked {
+		return false
+	}
+	s.logger.Warn("Refusing revoked package",
+		zap.String("hash", hash[:16]+"..."),
+		zap.String("reason", reason))
+	s.metrics.RevokedBlocked.WithLabel("download").Inc()
+	s.audit.Log(audit.NewRevokedContentBlockedEvent(hash, "download", reason).WithRequest(ctx))
+
+	msg := "package has been revoked"
+	if reason != "" {
+		msg += ": " + reason
+	}
+	s.writeFailure(w, codeRevoked, msg)
+	return true
+}
+
+// PurgeRevoked deletes every cached package on list and returns how many were
+// removed. Packages still being read are skipped (and stay unservable, since
+// requests and uploads check the list); the next purge retries them. Pinned
+// packages are purged too — a revocation overrides an operator's pin.
+func (s *Server) PurgeRevoked(list *revocation.List) int {
+	purged := 0
+	for _, hash := range list.Hashes() {
+		if !s.cache.Has(hash) {
+			continue
+		}
+		reason, _ := list.Lookup(hash)
+		err := s.cache.Delete(hash)
+		if err != nil {
+			if errors.Is(err, cache.ErrFileInUse) {
+				s.logger.Info("Revoked package in use, will retry purge",
+					zap.String("hash", hash[:16]+"..."))
+			} else {
+				s.logger.Warn("Failed to purge revoked package",
+					zap.String("hash", hash[:16]+"..."), zap.Error(err))
+			}
+			continue
+		}
+		purged++
+		s.metrics.RevokedPurged.Inc()
+		s.audit.Log(audit.NewRevokedContentPurgedEvent(hash, reason))
+	}
+	if purged > 0 {
+		s.logger.Info("Purged revoked packages from cache", zap.Int("count", purged))
+	}
+	return purged
+}
@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/gpg"
+	"github.com/debswarm/debswarm/internal/revocation"
+)
+
+// newRevocationManager returns a manager with a signed list revoking hashes
+// already in force.
+func newRevocationManager(t *testing.T, hashes ...string) *revocation.Manager {
+	t.Helper()
+	e, err := openpgp.NewEntity("debswarm proxy test", "test", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("NewEntity: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "revocation.gpg")
+	f, err := os.Create(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Serialize(f); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	kr, err := gpg.Load(zap.NewNop(), keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := "Serial: 1\n\n"
+	for _, h := range hashes {
+		body += h + " pulled upstream\n"
+	}
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, e.PrivateKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte(body))
+	_ = w.Close()
+
+	m := revocation.NewManager(kr, "", nil)
+	if _, err := m.Apply(buf.Bytes()); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	return m
+}
+
+func TestHandlePackageRequest_RevokedRefused(t *testing.T) {
+	server := newTestServer(t)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}()
+
+	testData := "cached package data"
+	testHash := "ed4fadeed15018a95148883178b673dcbf15d03a5a77c92d2d82827fac612b51"
+	if err := server.cache.Put(strings.NewReader(testData), testHash, "hello.deb"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	packagesContent := "Package: hello\nVersion: 2.10\nFilename: pool/main/h/hello/hello.deb\nSize: 19\nSHA256: " + testHash + "\n\n"
+	if err := server.index.LoadFromData([]byte(packagesContent), "http://archive.ubuntu.com/ubuntu"); err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+
+	server.revocations = newRevocationManager(t, testHash)
+
+	url := "http://archive.ubuntu.com/ubuntu/pool/main/h/hello/hello.deb"
+	w := httptest.NewRecorder()
+	server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+url, nil), url)
+
+	if w.Code != http.StatusGone {
+		t.Fatalf("Status = %d, want 410", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "pulled upstream") {
+		t.Errorf("body %q should carry the revocation reason", w.Body.String())
+	}
+	if got := server.metrics.RevokedBlocked.WithLabel("download").Value(); got != 1 {
+		t.Errorf("revoked download blocks = %d, want 1", got)
+	}
+}
+
+func TestPurgeRevoked(t *testing.T) {
+	server := newTestServer(t)
+
+	keep := "keep me"
+	keepHash := sha256Hex([]byte(keep))
+	drop := "revoked content"
+	dropHash := sha256Hex([]byte(drop))
+	for data, hash := range map[string]string{keep: keepHash, drop: dropHash} {
+		if err := server.cache.Put(strings.NewReader(data), hash, "x.deb"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	// A pin does not protect a revoked package.
+	if err := server.cache.Pin(dropHash); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+
+	m := newRevocationManager(t, dropHash, strings.Repeat("0", 64))
+	if n := server.PurgeRevoked(m.Current()); n != 1 {
+		t.Fatalf("PurgeRevoked = %d, want 1", n)
+	}
+	if server.cache.Has(dropHash) {
+		t.Error("revoked package still cached")
+	}
+	if !server.cache.Has(keepHash) {
+		t.Error("unrelated package was purged")
+	}
+	if got := server.metrics.RevokedPurged.Value(); got != 1 {
+		t.Errorf("RevokedPurged = %d, want 1", got)
+	}
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/audit"
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+// screenPackage is the cache screen: it scans a verified package file with
+// the configured scanner before the cache commits it.
+func (s *Server) screenPackage(path, hash, filename string) error {
+	f, err := os.Open(path) // #nosec G304 -- path is a cache-managed file
+	if err != nil {
+		return fmt.Errorf("open package for scanning: %w", err)
+	}
+	defer f.Close()
+	var size int64
+	if fi, err := f.Stat(); err == nil {
+		size = fi.Size()
+	}
+	return s.scanContent(f, size, hash, filename)
+}
+
+// scanContent scans a package's content with the configured scanner,
+// logging and auditing the verdict. It returns an error wrapping
+// cache.ErrQuarantined when the scanner flags the package.
+func (s *Server) scanContent(r io.Reader, size int64, hash, filename string) error {
+	start := time.Now()
+	res, err := s.scanner.Scan(s.announceCtx, r)
+	log := s.logger.With(
+		zap.String("scanner", s.scanner.Name()),
+		zap.String("hash", hash[:min(16, len(hash))]+"..."),
+		zap.String("path", sanitize.Path(filename)),
+		zap.Duration("duration", time.Since(start)))
+	if err != nil {
+		log.Warn("Package scan failed; not caching or announcing it", zap.Error(err))
+		s.metrics.PackageScans.WithLabel("error").Inc()
+		s.audit.Log(audit.NewScanFailedEvent(hash, filename, err.Error()))
+		return fmt.Errorf("scan failed: %w", err)
+	}
+	if res.Infected {
+		log.Warn("Scanner flagged package, quarantining it", zap.String("signature", res.Signature))
+		s.metrics.PackageScans.WithLabel("infected").Inc()
+		s.audit.Log(audit.NewPackageQuarantinedEvent(hash, filename, size, res.Signature))
+		return fmt.Errorf("%w: %s", cache.ErrQuarantined, res.Signature)
+	}
+	log.Debug("Package scanned clean")
+	s.metrics.PackageScans.WithLabel("clean").Inc()
+	return nil
+}
+
+// refuseQuarantined answers 403 for a package the scanner has flagged.
+func (s *Server) refuseQuarantined(w http.ResponseWriter, hash string) bool {
+	if s.scanner == nil || !s.cache.Quarantined(hash) {
+		return false
+	}
+	s.writeFailure(w, codeQuarantined, "debswarm: package quarantined by malware scanner")
+	return true
+}
+
+// scannedForAnnounce reports whether a cached package may be announced with
+// scanning enabled, scanning it now if it was cached before scanning was.
+func (s *Server) scannedForAnnounce(hash string) bool {
+	if s.scanner == nil || s.cache.Scanned(hash) {
+		return true
+	}
+	if err := s.cache.Rescreen(hash); err != nil {
+		s.logger.Debug("Not announcing unscanned package",
+			zap.String("hash", hash[:min(16, len(hash))]+"..."), zap.Error(err))
+		return false
+	}
+	return true
+}
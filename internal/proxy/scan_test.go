@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/scanner"
+)
+
+// fakeScanner flags content containing "EICAR".
+type fakeScanner struct{}
+
+func (fakeScanner) Name() string { return "fake" }
+
+func (fakeScanner) Scan(_ context.Context, r io.Reader) (*scanner.Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Contains(data, []byte("EICAR")) {
+		return &scanner.Result{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+	}
+	return &scanner.Result{}, nil
+}
+
+func TestPackageScanning(t *testing.T) {
+	files := map[string][]byte{
+		"pool/main/c/clean/clean_1.0_amd64.deb": []byte("clean payload"),
+		"pool/main/b/bad/bad_1.0_amd64.deb":     []byte("EICAR payload"),
+	}
+	var mirrorHits atomic.Int32
+	mockMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorHits.Add(1)
+		_, _ = w.Write(files[r.URL.Path[1:]])
+	}))
+	defer mockMirror.Close()
+
+	server := newTestServerWithMirror(t)
+	defer shutdownServer(t, server)
+	server.scanner = fakeScanner{}
+	server.cache.SetScreen(server.screenPackage, t.TempDir())
+
+	var packages bytes.Buffer
+	hashes := map[string]string{}
+	for path, data := range files {
+		sum := sha256.Sum256(data)
+		hashes[path] = hex.EncodeToString(sum[:])
+		fmt.Fprintf(&packages, "Package: p\nVersion: 1.0\nArchitecture: amd64\nFilename: %s\nSize: %d\nSHA256: %s\n\n",
+			path, len(data), hashes[path])
+	}
+	if err := server.index.LoadFromData(packages.Bytes(), mockMirror.URL+"/dists/stable/main/binary-amd64/Packages"); err != nil {
+		t.Fatalf("LoadFromData: %v", err)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		t.Helper()
+		url := mockMirror.URL + "/" + path
+		w := httptest.NewRecorder()
+		server.handlePackageRequest(w, httptest.NewRequest("GET", "/"+url, nil), url)
+		return w
+	}
+
+	cleanPath, badPath := "pool/main/c/clean/clean_1.0_amd64.deb", "pool/main/b/bad/bad_1.0_amd64.deb"
+	if w := get(cleanPath); w.Code != http.StatusOK {
+		t.Fatalf("clean package: status %d", w.Code)
+	}
+	if !server.cache.Scanned(hashes[cleanPath]) {
+		t.Error("clean package cached without being marked scanned")
+	}
+
+	if w := get(badPath); w.Code != http.StatusForbidden {
+		t.Fatalf("infected package: status %d, want 403", w.Code)
+	}
+	if server.cache.Has(hashes[badPath]) {
+		t.Error("infected package was cached")
+	}
+	// Quarantined packages are refused without fetching them again.
+	hits := mirrorHits.Load()
+	if w := get(badPath); w.Code != http.StatusForbidden || mirrorHits.Load() != hits {
+		t.Errorf("repeat request: status %d, mirror hits %d -> %d", w.Code, hits, mirrorHits.Load())
+	}
+
+	for result, want := range map[string]int64{"clean": 1, "infected": 1} {
+		if got := server.metrics.PackageScans.WithLabel(result).Value(); got != want {
+			t.Errorf("%s scans = %d, want %d", result, got, want)
+		}
+	}
+}
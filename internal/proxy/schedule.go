@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/scheduler"
+)
+
+// apiWindowRequest is the body of POST /api/scheduler/windows. Times are
+// RFC 3339 or "YYYY-MM-DD HH:MM" in the scheduler's timezone.
+type apiWindowRequest struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Rate   *int64 `json:"rate,omitempty"` // bytes/sec, 0 = unlimited; omitted = inside-window rate
+	Reason string `json:"reason,omitempty"`
+}
+
+// GET /api/scheduler
+//
+// Recurring windows, exceptions, pending one-off windows and the current
+// state of the scheduler.
+func (s *Server) handleAPIScheduler(w http.ResponseWriter, r *http.Request) {
+	if s.scheduler == nil {
+		writeError(w, http.StatusNotFound, "scheduler is not enabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.scheduler.Schedule())
+}
+
+// POST /api/scheduler/windows
+func (s *Server) handleAPIAddScheduleWindow(w http.ResponseWriter, r *http.Request) {
+	if s.scheduler == nil {
+		writeError(w, http.StatusNotFound, "scheduler is not enabled")
+		return
+	}
+	var req apiWindowRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid window: "+err.Error())
+		return
+	}
+	from, err := s.scheduler.ParseWindowTime(req.From)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, err := s.scheduler.ParseWindowTime(req.To)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	added, err := s.scheduler.AddWindow(scheduler.OneOffWindow{From: from, To: to, Rate: req.Rate, Reason: req.Reason})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.logger.Info("One-off sync window added",
+		zap.String("id", added.ID),
+		zap.Time("from", added.From),
+		zap.Time("to", added.To),
+		zap.String("reason", added.Reason))
+	writeJSON(w, http.StatusOK, added)
+}
+
+// POST /api/scheduler/exceptions with {"name": "...", "from": "2026-12-24", "to": "2027-01-01"}
+func (s *Server) handleAPIAddScheduleException(w http.ResponseWriter, r *http.Request) {
+	if s.scheduler == nil {
+		writeError(w, http.StatusNotFound, "scheduler is not enabled")
+		return
+	}
+	var e scheduler.Exception
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&e); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid exception: "+err.Error())
+		return
+	}
+	added, err := s.scheduler.AddException(e)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.logger.Info("Schedule exception added",
+		zap.String("id", added.ID),
+		zap.String("name", added.Name),
+		zap.String("from", added.From),
+		zap.String("to", added.To))
+	writeJSON(w, http.StatusOK, added)
+}
+
+// DELETE /api/scheduler/{id}
+//
+// Removes an exception or one-off window added at runtime. Those from the
+// configuration file cannot be removed here.
+func (s *Server) handleAPIRemoveScheduleEntry(w http.ResponseWriter, r *http.Request) {
+	if s.scheduler == nil {
+		writeError(w, http.StatusNotFound, "scheduler is not enabled")
+		return
+	}
+	id := r.PathValue("id")
+	if err := s.scheduler.Remove(id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, scheduler.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	s.logger.Info("Schedule entry removed", zap.String("id", id))
+	writeJSON(w, http.StatusOK, s.scheduler.Schedule())
+}
+
+// isSecurityDownload reports whether url fetches a security update, which
+// the scheduler lets through at full speed. The index knows the suite a
+// pool URL belongs to; without an entry the URL itself has to tell.
+func (s *Server) isSecurityDownload(url string) bool {
+	if pkg := s.index.GetByURLPath(url); pkg != nil && scheduler.IsSecurityOrigin(pkg.Repo, pkg.Suite) {
+		return true
+	}
+	return scheduler.IsSecurityUpdate(url)
+}
+
+// isSecurityPackage reports whether a cached package came from a security
+// pocket, going by the origin recorded when it was cached. Peers get such
+// packages ahead of other uploads.
+func (s *Server) isSecurityPackage(hash string) bool {
+	pkg, err := s.cache.Info(hash)
+	if err != nil {
+		return false
+	}
+	return scheduler.IsSecurityOrigin(pkg.Origin.Repo, pkg.Origin.Suite)
+}
@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/scheduler"
+)
+
+func TestAPIScheduler(t *testing.T) {
+	s := newTestServer(t)
+
+	w := httptest.NewRecorder()
+	s.handleAPIScheduler(w, httptest.NewRequest("GET", "/api/scheduler", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("without scheduler: status %d, want 404", w.Code)
+	}
+
+	sched, err := scheduler.New(&scheduler.Config{
+		Enabled:    true,
+		Windows:    []scheduler.Window{{Days: []string{"all"}, StartTime: "01:00", EndTime: "05:00"}},
+		Exceptions: []scheduler.Exception{{Name: "holiday", From: "2099-12-25"}},
+		StatePath:  filepath.Join(t.TempDir(), "scheduler.json"),
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.scheduler = sched
+
+	from := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	to := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+	w = httptest.NewRecorder()
+	s.handleAPIAddScheduleWindow(w, httptest.NewRequest("POST", "/api/scheduler/windows",
+		strings.NewReader(`{"from":"`+from+`","to":"`+to+`","rate":0,"reason":"rebuild"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("add window: status %d: %s", w.Code, w.Body.String())
+	}
+	var added scheduler.OneOffWindow
+	if err := json.Unmarshal(w.Body.Bytes(), &added); err != nil {
+		t.Fatal(err)
+	}
+	if added.ID == "" || added.Rate == nil || *added.Rate != 0 || added.Reason != "rebuild" {
+		t.Errorf("added = %+v", added)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleAPIAddScheduleWindow(w, httptest.NewRequest("POST", "/api/scheduler/windows",
+		strings.NewReader(`{"from":"`+to+`","to":"`+from+`"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("reversed window: status %d, want 400", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleAPIAddScheduleException(w, httptest.NewRequest("POST", "/api/scheduler/exceptions",
+		strings.NewReader(`{"name":"freeze","from":"2099-12-20","to":"2099-12-31"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("add exception: status %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	s.handleAPIScheduler(w, httptest.NewRequest("GET", "/api/scheduler", nil))
+	var sch scheduler.Schedule
+	if err := json.Unmarshal(w.Body.Bytes(), &sch); err != nil {
+		t.Fatal(err)
+	}
+	if len(sch.Windows) != 1 || len(sch.Exceptions) != 2 || len(sch.OneOffWindows) != 1 {
+		t.Errorf("schedule = %+v", sch)
+	}
+
+	req := httptest.NewRequest("DELETE", "/api/scheduler/"+added.ID, nil)
+	req.SetPathValue("id", added.ID)
+	w = httptest.NewRecorder()
+	s.handleAPIRemoveScheduleEntry(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("remove: status %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	s.handleAPIRemoveScheduleEntry(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("second remove: status %d, want 404", w.Code)
+	}
+}
+
+func TestSecurityPackages(t *testing.T) {
+	s := newTestServer(t)
+
+	// Ubuntu serves security updates from the shared pool: only the index
+	// knows which suite a package belongs to
+	const packages = "Package: bash\nVersion: 5.2-1ubuntu1.1\nArchitecture: amd64\n" +
+		"Filename: pool/main/b/bash/bash_5.2-1ubuntu1.1_amd64.deb\nSize: 4\n" +
+		"SHA256: " + "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa" + "\n\n"
+	if err := s.index.LoadFromData([]byte(packages), "http://archive.ubuntu.com/ubuntu/dists/noble-security/main/binary-amd64/Packages"); err != nil {
+		t.Fatal(err)
+	}
+	if !s.isSecurityDownload("http://archive.ubuntu.com/ubuntu/pool/main/b/bash/bash_5.2-1ubuntu1.1_amd64.deb") {
+		t.Error("package indexed under noble-security not treated as a security update")
+	}
+	if s.isSecurityDownload("http://archive.ubuntu.com/ubuntu/pool/main/c/curl/curl_8.5.0-2_amd64.deb") {
+		t.Error("unindexed package treated as a security update")
+	}
+	if !s.isSecurityDownload("http://security.debian.org/debian-security/pool/updates/main/b/bash/bash_5.2.deb") {
+		t.Error("security URL not treated as a security update")
+	}
+
+	const content = "data"
+	hash := sha256Hex([]byte(content))
+	if err := s.cache.Put(strings.NewReader(content), hash, "bash.deb"); err != nil {
+		t.Fatal(err)
+	}
+	if s.isSecurityPackage(hash) {
+		t.Error("package without an origin prioritized")
+	}
+	if err := s.cache.SetOrigin(hash, cache.Origin{Repo: "deb.debian.org/debian-security", Suite: "bookworm-security"}); err != nil {
+		t.Fatal(err)
+	}
+	if !s.isSecurityPackage(hash) {
+		t.Error("package from bookworm-security not prioritized")
+	}
+	if s.isSecurityPackage(sha256Hex([]byte("missing"))) {
+		t.Error("missing package prioritized")
+	}
+}
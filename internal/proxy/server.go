@@ -26,23 +26,32 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/sync/singleflight"
 
+	"github.com/debswarm/debswarm/internal/accesslog"
+	"github.com/debswarm/debswarm/internal/aptarchives"
+	"github.com/debswarm/debswarm/internal/artifact"
 	"github.com/debswarm/debswarm/internal/audit"
 	"github.com/debswarm/debswarm/internal/cache"
 	"github.com/debswarm/debswarm/internal/connectivity"
 	"github.com/debswarm/debswarm/internal/dashboard"
 	"github.com/debswarm/debswarm/internal/downloader"
 	"github.com/debswarm/debswarm/internal/fleet"
+	"github.com/debswarm/debswarm/internal/fleethttp"
 	"github.com/debswarm/debswarm/internal/gpg"
+	"github.com/debswarm/debswarm/internal/hooks"
 	"github.com/debswarm/debswarm/internal/index"
 	"github.com/debswarm/debswarm/internal/metrics"
 	"github.com/debswarm/debswarm/internal/mirror"
 	"github.com/debswarm/debswarm/internal/p2p"
 	"github.com/debswarm/debswarm/internal/peers"
+	"github.com/debswarm/debswarm/internal/priority"
 	"github.com/debswarm/debswarm/internal/requestid"
+	"github.com/debswarm/debswarm/internal/revocation"
 	"github.com/debswarm/debswarm/internal/sanitize"
+	"github.com/debswarm/debswarm/internal/scanner"
 	"github.com/debswarm/debswarm/internal/scheduler"
 	"github.com/debswarm/debswarm/internal/security"
 	"github.com/debswarm/debswarm/internal/timeouts"
+	"github.com/debswarm/debswarm/internal/updatecheck"
 	"github.com/debswarm/debswarm/internal/verify"
 )
 
@@ -52,8 +61,10 @@ type Server struct {
 	cache        *cache.Cache
 	index        *index.Index
 	p2pNode      *p2p.Node
+	swarms       []Swarm // additional swarms, routed by package origin
 	fetcher      *mirror.Fetcher
 	downloader   *downloader.Downloader
+	downloads    *downloader.Tracker // for the dashboard's downloads page
 	stateManager *downloader.StateManager
 	logger       *zap.Logger
 	server       *http.Server
@@ -61,10 +72,15 @@ type Server struct {
 	timeouts     *timeouts.Manager
 	scorer       *peers.Scorer
 	audit        audit.Logger
+	accessLog    *accesslog.Logger // nil when the access log is off
 	connectivity *connectivity.Monitor
 	scheduler    *scheduler.Scheduler
 	fleet        *fleet.Coordinator
+	fleetHTTPS   *fleethttp.Client // fetches from fleet peers when P2P transfers fail
 	verifier     *verify.Verifier
+	revocations  *revocation.Manager
+	hooks        *hooks.Chain
+	scanner      scanner.Scanner
 
 	// Statistics (atomic)
 	requestsTotal   int64
@@ -100,11 +116,47 @@ type Server struct {
 	announceCancel context.CancelFunc
 
 	// Dashboard
-	dashboard    *dashboard.Dashboard
-	cacheMaxSize int64
+	dashboard *dashboard.Dashboard
+	updates   *updatecheck.Checker // nil unless update checks are enabled
+
+	// Cache-full episode; strictWhenFull refuses packages that cannot be
+	// cached instead of serving them uncached.
+	cacheFull      cacheFullState
+	strictWhenFull bool
+
+	// Serve the completed prefix of an interrupted download while the rest
+	// comes from the mirror (see readthrough.go)
+	readThrough bool
+
+	// Stream mirror downloads to the requesting client while they are
+	// hashed, rather than after verification (see serveStreamed)
+	streamMirror bool
+
+	// Imports packages APT fetched directly, on notification from the APT
+	// hook. At most one import runs and one more waits (aptImportQueued).
+	aptImporter     *aptarchives.Importer
+	aptImportMu     sync.Mutex
+	aptImportQueued atomic.Bool
+
+	// Stores packages are served to peers from besides the cache (see
+	// content.go)
+	contentMu        sync.RWMutex
+	contentProviders p2p.ContentChain
+
+	// Renders the daemon's active configuration for GET /api/config
+	configSource func() ([]byte, error)
+	logSource    func() []byte // recent log output, for support bundles
 
 	// Request coalescing - prevents duplicate downloads for same package
 	downloadGroup singleflight.Group
+	// In-flight package downloads that later requests stream from as bytes
+	// arrive, rather than waiting for the coalesced result.
+	inflight inflightRegistry
+	// Background downloads, promoted when a client asks for their package
+	// (see priority.go)
+	background backgroundDownloads
+	// Package groups kept cached (see prefetch.go); nil unless enabled
+	prefetch *prefetcher
 
 	// Retry configuration
 	retryMaxAttempts int
@@ -115,11 +167,32 @@ type Server struct {
 	retryDone        chan struct{}
 
 	// Security configuration
-	allowedHosts       []string     // Additional allowed repository hosts
+	mirrorPolicy atomic.Pointer[security.MirrorPolicy] // upstream URLs and CONNECT targets allowed (SSRF)
+
 	httpsUpstreamHosts []string     // Hosts to fetch over HTTPS even when APT requests HTTP
 	metadataServeStale bool         // serve cached metadata when the mirror is unreachable
+	reconstructPdiffs  bool         // rebuild indexes from pdiffs (see pdiff.go)
 	allowedClientNets  []*net.IPNet // inbound client allowlist for LAN server mode (empty = loopback only)
 
+	// classPolicies overrides the default cache/share policy per artifact class
+	classPolicies map[artifactClass]ClassPolicy
+
+	// How long cached passthrough objects are served without revalidation
+	// (see freshness.go)
+	passthroughDefaultTTL time.Duration
+	passthroughMaxTTL     time.Duration
+	classTTLs             map[artifactClass]time.Duration
+
+	// canary re-fetches a sample of peer-served packages from the mirror
+	// (see canary.go); nil when disabled
+	canary *canary
+
+	// splitHorizon gives LAN peers the first attempt at packages (see
+	// splithorizon.go); nil when disabled
+	splitHorizon *SplitHorizonConfig
+
+	providerTTL time.Duration
+
 	// Upstream GPG verification: verify a Packages index against the GPG-signed
 	// Release before trusting its hashes. verifyMode is "off" (disabled), "warn"
 	// (verify + observe, serve unchanged), "auto" (default; refuse only a decisive
@@ -140,6 +213,14 @@ type Server struct {
 	releaseFetch       singleflight.Group
 	releaseFetchFailed sync.Map // base(string) -> time.Time of last failed fetch
 
+	// hashRequired refuses packages without an index entry, except from
+	// the normalized repository prefixes in hashRequiredExempt.
+	hashRequired       bool
+	hashRequiredExempt []string
+
+	// repos are third-party repositories with their own policy
+	repos []Repo
+
 	// uncachedHostsSeen tracks repository hosts for which we have already logged
 	// an INFO-level "served uncached" notice, so the log is emitted once per host
 	// (the packages_served_uncached_total metric carries the full count).
@@ -154,6 +235,34 @@ type Server struct {
 	// even when no apt-get update has run this session (the case that otherwise
 	// breaks offline installs of already-cached packages).
 	indexWarmOnce sync.Once
+
+	// pdiffGroup collapses concurrent rebuilds of one index from its pdiffs;
+	// pdiffBuilt maps an index URL to the SHA256 last rebuilt for it.
+	pdiffGroup singleflight.Group
+	pdiffBuilt sync.Map
+	// metadataGroup collapses concurrent peer fetches of one index file
+	metadataGroup singleflight.Group
+
+	// generic holds the enabled generic-mode adapters by name (see
+	// generic.go); nil when generic mode is off
+	generic map[string]*genericAdapter
+
+	// clients keeps per-client statistics and applies client profiles (see
+	// clients.go)
+	clients *clientTracker
+
+	// receipts is set when signed receipts are kept for downloads from
+	// peers (see receipts.go)
+	receipts bool
+
+	// timeline keeps the significant events shown on the dashboard's
+	// events page (see events.go); nil when not set
+	timeline *audit.Timeline
+
+	// build is the build listener's profile and buildServer the listener,
+	// both nil when it is disabled (see build.go)
+	build       *BuildProfile
+	buildServer *http.Server
 }
 
 // Config holds proxy server configuration
@@ -163,16 +272,21 @@ type Config struct {
 	DHTLookupLimit             int
 	MetricsPort                int
 	MetricsBind                string // Bind address for metrics server (default: 127.0.0.1)
-	CacheMaxSize               int64
-	MaxConcurrentPeerDownloads int // Maximum concurrent peer downloads (0 = default)
+	MaxConcurrentPeerDownloads int    // Maximum concurrent peer downloads (0 = default)
 	Metrics                    *metrics.Metrics
 	Timeouts                   *timeouts.Manager
 	Scorer                     *peers.Scorer
 	Audit                      audit.Logger          // Audit logger for structured event logging
+	AccessLog                  *accesslog.Logger     // Per-request access log (nil = disabled)
 	Connectivity               *connectivity.Monitor // Connectivity monitor for offline-first mode
 	Scheduler                  *scheduler.Scheduler  // Scheduler for time-based rate limiting
 	Fleet                      *fleet.Coordinator    // Fleet coordinator for LAN download coordination
+	FleetHTTPS                 *fleethttp.Client     // HTTPS fallback to fleet peers (nil = disabled)
 	Verifier                   *verify.Verifier      // Multi-source verifier for download validation
+	Revocations                *revocation.Manager   // Signed list of revoked content hashes (nil = disabled)
+	Hooks                      *hooks.Chain          // Pipeline hooks (nil = none)
+	Scanner                    scanner.Scanner       // Malware scanner run before caching (nil = none)
+	QuarantineDir              string                // Where flagged packages go (default <cache>/quarantine)
 	// Retry settings
 	RetryMaxAttempts int           // Max retry attempts per download (0 = disabled)
 	RetryInterval    time.Duration // How often to check for failed downloads
@@ -181,6 +295,11 @@ type Config struct {
 	// Security settings
 	AllowedHosts []string // Additional allowed repository hosts (beyond built-in Debian/Ubuntu/Mint)
 
+	// MirrorPolicy decides which upstream URLs and CONNECT targets the proxy
+	// may reach. If nil, one allowing AllowedHosts is used. Replace it while
+	// running with SetMirrorPolicy.
+	MirrorPolicy *security.MirrorPolicy
+
 	// AllowedClientCIDRs restricts which inbound clients may use the proxy when it
 	// is bound to a non-loopback address (LAN server mode). Loopback clients are
 	// always allowed. Empty means loopback-only (the default). Parsed from
@@ -198,6 +317,10 @@ type Config struct {
 	// signature and Valid-Until of whatever is served.
 	MetadataServeStale bool
 
+	// ReconstructPdiffs rebuilds Packages/Sources indexes from their pdiffs
+	// when a client updates through them (see reconstructFromPdiff).
+	ReconstructPdiffs bool
+
 	// VerifyMode controls daemon-side upstream signature verification: "" or "off"
 	// (disabled, unchanged behavior), "warn" (verify + observe, serve unchanged),
 	// or "enforce" (refuse an unverified/mismatched index). Keyring holds the
@@ -206,6 +329,83 @@ type Config struct {
 	VerifyMode        string
 	Keyring           *gpg.Keyring
 	VerifyExemptHosts []string
+
+	// HashRequired refuses packages no loaded index gives a SHA256 for,
+	// rather than streaming them from the mirror unverified. Repositories
+	// in HashRequiredExempt (a host, or a host and path prefix) still are.
+	HashRequired       bool
+	HashRequiredExempt []string
+
+	// Repos are third-party repositories with their own sharing, keyring
+	// and upload priority settings.
+	Repos []Repo
+
+	// Clients set the rate limit and source policy of the proxy clients in
+	// their networks. Replace them while running with SetClientProfiles.
+	Clients []ClientProfile
+
+	// Receipts asks each peer a package is downloaded from for a signed
+	// receipt of the bytes it sent, and keeps the receipts in the cache's
+	// database as evidence of who supplied a corrupt package.
+	Receipts bool
+
+	// Timeline, when set, keeps the significant events served by
+	// /api/events and the dashboard's events page. It should also receive
+	// the events logged to Audit.
+	Timeline *audit.Timeline
+
+	// ClassPolicies overrides how artifact classes ("package", "dep11", ...)
+	// are cached and shared. Classes not listed keep their defaults.
+	ClassPolicies map[string]ClassPolicy
+
+	// PassthroughTTL is how long cached passthrough objects are served
+	// without asking the mirror when it sends no Cache-Control or Expires;
+	// ClassTTLs overrides it per class. PassthroughMaxTTL caps the lifetime
+	// the mirror gives (0 = ignore the mirror's headers).
+	PassthroughTTL    time.Duration
+	PassthroughMaxTTL time.Duration
+	ClassTTLs         map[string]time.Duration
+
+	// StrictWhenFull answers 507 Insufficient Storage for a package the cache
+	// has no room for, instead of serving it from the mirror uncached, so a
+	// full cache is noticed before the swarm starves.
+	StrictWhenFull bool
+
+	// Build enables the build listener for debootstrap/mmdebstrap chroot
+	// builds (nil = disabled)
+	Build *BuildProfile
+
+	// Canary compares a sample of peer-served packages with the mirror
+	// (nil = disabled)
+	Canary *CanaryConfig
+
+	// SplitHorizon tries LAN peers before WAN peers and the mirror
+	// (nil = disabled)
+	SplitHorizon *SplitHorizonConfig
+
+	// HedgePercentile, RetryBudget and MaxChunksPerPeer tune chunked
+	// downloads (see downloader.Config)
+	HedgePercentile  float64
+	RetryBudget      float64
+	MaxChunksPerPeer int
+
+	// ReadThrough serves a package whose download was interrupted from the
+	// part already on disk while the remainder is fetched from the mirror
+	ReadThrough bool
+
+	// StreamMirror sends a package fetched from the mirror to APT while it
+	// is being verified, holding back the last byte until it has been
+	// verified
+	StreamMirror bool
+
+	// GenericAdapters enables generic mode for other packaging ecosystems,
+	// served under /generic/<adapter>/ (empty = disabled)
+	GenericAdapters []artifact.Adapter
+
+	// ProviderTTL is how long a provider record lives in the DHT after an
+	// announcement; packages are reannounced shortly before it runs out
+	// (0 = 24h)
+	ProviderTTL time.Duration
 }
 
 // DefaultConfig returns default configuration
@@ -269,27 +469,41 @@ func NewServer(
 		timeouts:           tm,
 		scorer:             scorer,
 		audit:              auditLogger,
+		accessLog:          cfg.AccessLog,
 		connectivity:       cfg.Connectivity,
 		scheduler:          cfg.Scheduler,
 		fleet:              cfg.Fleet,
+		fleetHTTPS:         cfg.FleetHTTPS,
 		verifier:           cfg.Verifier,
+		revocations:        cfg.Revocations,
+		hooks:              cfg.Hooks,
+		scanner:            cfg.Scanner,
 		p2pTimeout:         cfg.P2PTimeout,
 		dhtLookupLimit:     cfg.DHTLookupLimit,
 		metricsPort:        cfg.MetricsPort,
 		metricsBind:        metricsBind,
-		cacheMaxSize:       cfg.CacheMaxSize,
+		strictWhenFull:     cfg.StrictWhenFull,
+		readThrough:        cfg.ReadThrough,
+		streamMirror:       cfg.StreamMirror,
 		announceChan:       make(chan string, 100), // Bounded buffer
 		announceDone:       make(chan struct{}),
 		retryMaxAttempts:   cfg.RetryMaxAttempts,
 		retryInterval:      cfg.RetryInterval,
 		retryMaxAge:        cfg.RetryMaxAge,
 		retryDone:          make(chan struct{}),
-		allowedHosts:       cfg.AllowedHosts,
 		httpsUpstreamHosts: cfg.HTTPSUpstreamHosts,
 		metadataServeStale: cfg.MetadataServeStale,
+		reconstructPdiffs:  cfg.ReconstructPdiffs,
 		allowedClientNets:  cfg.AllowedClientCIDRs,
 	}
 
+	mirrorPolicy := cfg.MirrorPolicy
+	if mirrorPolicy == nil {
+		// Host entries cannot fail to parse
+		mirrorPolicy, _ = security.NewMirrorPolicy(security.PolicyConfig{AllowedHosts: cfg.AllowedHosts})
+	}
+	s.mirrorPolicy.Store(mirrorPolicy)
+
 	// Upstream GPG verification setup (default off preserves existing behavior).
 	s.verifyMode = cfg.VerifyMode
 	if s.verifyMode == "" {
@@ -306,9 +520,58 @@ func NewServer(
 		}
 	}
 
+	s.repos = cfg.Repos
+	s.clients = newClientTracker(cfg.Clients)
+	s.receipts = cfg.Receipts
+	s.timeline = cfg.Timeline
+	s.hashRequired = cfg.HashRequired
+	for _, prefix := range cfg.HashRequiredExempt {
+		if prefix = normalizeRepoPrefix(prefix); prefix != "" {
+			s.hashRequiredExempt = append(s.hashRequiredExempt, prefix)
+		}
+	}
+
+	if len(cfg.ClassPolicies) > 0 {
+		s.classPolicies = make(map[artifactClass]ClassPolicy, len(cfg.ClassPolicies))
+		for name, p := range cfg.ClassPolicies {
+			s.classPolicies[artifactClass(name)] = p
+		}
+	}
+	s.passthroughDefaultTTL = cfg.PassthroughTTL
+	s.passthroughMaxTTL = cfg.PassthroughMaxTTL
+	if len(cfg.ClassTTLs) > 0 {
+		s.classTTLs = make(map[artifactClass]time.Duration, len(cfg.ClassTTLs))
+		for name, ttl := range cfg.ClassTTLs {
+			s.classTTLs[artifactClass(name)] = ttl
+		}
+	}
+
+	if cfg.Canary != nil && cfg.Canary.SampleRate > 0 {
+		s.canary = newCanary(*cfg.Canary)
+	}
+	s.splitHorizon = cfg.SplitHorizon
+
+	if len(cfg.GenericAdapters) > 0 {
+		s.generic = make(map[string]*genericAdapter, len(cfg.GenericAdapters))
+		for _, a := range cfg.GenericAdapters {
+			s.generic[a.Name()] = &genericAdapter{Adapter: a}
+		}
+	}
+
+	s.providerTTL = cfg.ProviderTTL
+	if s.providerTTL <= 0 {
+		s.providerTTL = defaultProviderTTL
+	}
+
 	// Create context for announcement worker that will be canceled on shutdown
 	s.announceCtx, s.announceCancel = context.WithCancel(context.Background())
 
+	// Every package entering the cache is scanned first, whichever path
+	// stores it (downloads, fleet, APT archive import).
+	if s.scanner != nil {
+		pkgCache.SetScreen(s.screenPackage, cfg.QuarantineDir)
+	}
+
 	// Start announcement worker (bounded goroutines)
 	go s.announcementWorker()
 
@@ -316,14 +579,26 @@ func NewServer(
 	s.retryCtx, s.retryCancel = context.WithCancel(context.Background())
 
 	// Create state manager for download resume support
-	stateManager := downloader.NewStateManager(pkgCache.GetDB())
+	var stateManager *downloader.StateManager
+	if err := pkgCache.Migrate(downloader.StateSchema); err != nil {
+		logger.Error("Failed to migrate download state tables, download resume is disabled", zap.Error(err))
+	} else {
+		stateManager = downloader.NewStateManager(pkgCache.GetDB())
+	}
 	s.stateManager = stateManager
 
 	// Expose the cache's capacity and eviction pressure to operators
 	if m != nil {
 		m.CacheMaxSize.Set(float64(pkgCache.MaxSize()))
-		pkgCache.SetOnEvict(func() { m.CacheEvictions.Inc() })
+		pkgCache.SetOnEvict(func(reason string) {
+			m.CacheEvictions.Inc()
+			m.CacheEvictionsByReason.WithLabel(reason).Inc()
+		})
+		pkgCache.SetOnMetadataEvict(func() { m.CacheEvictionsByReason.WithLabel("metadata").Inc() })
 	}
+	pkgCache.SetOnEvictionSweep(func(evicted int, freed, sizeAfter int64) {
+		auditLogger.Log(audit.NewCacheEvictionEvent(evicted, freed, sizeAfter, cache.EvictCapacity))
+	})
 
 	// Determine max concurrent downloads (use config or default)
 	maxConcurrentDownloads := cfg.MaxConcurrentPeerDownloads
@@ -339,7 +614,13 @@ func NewServer(
 		Metrics:       m,
 		StateManager:  stateManager,
 		Cache:         pkgCache,
+		Timeouts:      tm,
+
+		HedgePercentile:  cfg.HedgePercentile,
+		RetryBudget:      cfg.RetryBudget,
+		MaxChunksPerPeer: cfg.MaxChunksPerPeer,
 	})
+	s.downloads = downloader.NewTracker(recentDownloads)
 
 	// Warn when the proxy is exposed beyond loopback. The daemon's fail-closed
 	// validation guarantees a client allowlist is present in that case, but a
@@ -370,6 +651,18 @@ func NewServer(
 		MaxHeaderBytes:    1 << 20, // 1MB
 	}
 
+	if cfg.Build != nil && cfg.Build.Addr != "" {
+		s.build = cfg.Build
+		s.buildServer = &http.Server{
+			Addr:              cfg.Build.Addr,
+			Handler:           s.gateClient(s.buildHandler(mux)),
+			ReadHeaderTimeout: s.server.ReadHeaderTimeout,
+			WriteTimeout:      s.server.WriteTimeout,
+			IdleTimeout:       s.server.IdleTimeout,
+			MaxHeaderBytes:    s.server.MaxHeaderBytes,
+		}
+	}
+
 	return s
 }
 
@@ -385,6 +678,15 @@ func (s *Server) Start() error {
 		go s.retryWorker()
 	}
 
+	if s.buildServer != nil {
+		go func() {
+			s.logger.Info("Starting build listener", zap.String("addr", s.buildServer.Addr))
+			if err := s.buildServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				s.logger.Error("Build listener failed", zap.Error(err))
+			}
+		}()
+	}
+
 	s.logger.Info("Starting HTTP proxy", zap.String("addr", s.addr))
 	return s.server.ListenAndServe()
 }
@@ -394,6 +696,8 @@ func (s *Server) startMetricsServer() {
 	mux.Handle("/metrics", s.metrics.Handler())
 	mux.HandleFunc("/health", s.handleHealth)
 	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("GET /stats/debug", requireLoopback(s.handleDebugState))
+	mux.HandleFunc("GET /debug/bundle", requireLoopback(s.handleDebugBundle))
 	s.registerAPIRoutes(mux)
 
 	// Add dashboard routes if dashboard is set
@@ -564,6 +868,9 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	if s.connectivity != nil {
 		health.ConnectivityMode = s.connectivity.GetMode().String()
 	}
+	if s.offline() {
+		health.Checks["mirror"] = "disabled"
+	}
 
 	// Set overall status
 	if !allHealthy {
@@ -602,25 +909,43 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 		fleetStatus = &status
 	}
 
+	// Get upload admission state if enabled
+	var admission *p2p.AdmissionState
+	if s.p2pNode != nil {
+		if state, ok := s.p2pNode.AdmissionState(); ok {
+			admission = &state
+		}
+	}
+
+	// Get power policy state if enabled
+	var powerState *p2p.PowerState
+	if s.p2pNode != nil {
+		if state, ok := s.p2pNode.PowerState(); ok {
+			powerState = &state
+		}
+	}
+
 	response := struct {
-		RequestsTotal       int64             `json:"requests_total"`
-		RequestsP2P         int64             `json:"requests_p2p"`
-		RequestsMirror      int64             `json:"requests_mirror"`
-		BytesFromP2P        int64             `json:"bytes_from_p2p"`
-		BytesFromMirror     int64             `json:"bytes_from_mirror"`
-		CacheHits           int64             `json:"cache_hits"`
-		ActiveConnections   int64             `json:"active_connections"`
-		P2PRatioPercent     float64           `json:"p2p_ratio_percent"`
-		CacheSizeBytes      int64             `json:"cache_size_bytes"`
-		CacheCount          int               `json:"cache_count"`
-		PackagesUncached    int64             `json:"packages_served_uncached"`
-		MetadataCacheHits   int64             `json:"metadata_cache_hits"`
-		MetadataCacheMiss   int64             `json:"metadata_cache_misses"`
-		MetadataBytesSaved  int64             `json:"metadata_cache_bytes_saved"`
-		MetadataCacheSize   int64             `json:"metadata_cache_size_bytes"`
-		MetadataStaleServed int64             `json:"metadata_cache_stale_served"`
-		Scheduler           *scheduler.Status `json:"scheduler,omitempty"`
-		Fleet               *fleet.Status     `json:"fleet,omitempty"`
+		RequestsTotal       int64               `json:"requests_total"`
+		RequestsP2P         int64               `json:"requests_p2p"`
+		RequestsMirror      int64               `json:"requests_mirror"`
+		BytesFromP2P        int64               `json:"bytes_from_p2p"`
+		BytesFromMirror     int64               `json:"bytes_from_mirror"`
+		CacheHits           int64               `json:"cache_hits"`
+		ActiveConnections   int64               `json:"active_connections"`
+		P2PRatioPercent     float64             `json:"p2p_ratio_percent"`
+		CacheSizeBytes      int64               `json:"cache_size_bytes"`
+		CacheCount          int                 `json:"cache_count"`
+		PackagesUncached    int64               `json:"packages_served_uncached"`
+		MetadataCacheHits   int64               `json:"metadata_cache_hits"`
+		MetadataCacheMiss   int64               `json:"metadata_cache_misses"`
+		MetadataBytesSaved  int64               `json:"metadata_cache_bytes_saved"`
+		MetadataCacheSize   int64               `json:"metadata_cache_size_bytes"`
+		MetadataStaleServed int64               `json:"metadata_cache_stale_served"`
+		Scheduler           *scheduler.Status   `json:"scheduler,omitempty"`
+		Fleet               *fleet.Status       `json:"fleet,omitempty"`
+		UploadAdmission     *p2p.AdmissionState `json:"upload_admission,omitempty"`
+		Power               *p2p.PowerState     `json:"power,omitempty"`
 	}{
 		RequestsTotal:       stats.RequestsTotal,
 		RequestsP2P:         stats.RequestsP2P,
@@ -640,6 +965,8 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 		MetadataStaleServed: s.metrics.MetadataCacheStaleServed.Value(),
 		Scheduler:           schedStatus,
 		Fleet:               fleetStatus,
+		UploadAdmission:     admission,
+		Power:               powerState,
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -679,6 +1006,9 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	// Note: verifier.Close() is called by daemon.go's defer, not here
 	// to avoid double-close and maintain consistent cleanup ordering
 
+	if s.buildServer != nil {
+		_ = s.buildServer.Shutdown(ctx)
+	}
 	return s.server.Shutdown(ctx)
 }
 
@@ -712,11 +1042,50 @@ func (s *Server) GetStats() Stats {
 	}
 }
 
+// dashboardRecentPackages is how many recently used packages the dashboard
+// lists
+const dashboardRecentPackages = 10
+
+// recentPackages returns the most recently used cached packages and their
+// origins for the dashboard.
+func (s *Server) recentPackages(limit int) []dashboard.CachedPackage {
+	pkgs, err := s.cache.RecentPackages(limit)
+	if err != nil {
+		s.logger.Debug("Failed to list recent packages", zap.Error(err))
+		return nil
+	}
+	out := make([]dashboard.CachedPackage, 0, len(pkgs))
+	for _, p := range pkgs {
+		out = append(out, dashboard.CachedPackage{
+			Filename:   filepath.Base(p.Filename),
+			Size:       formatBytes(p.Size),
+			LastAccess: p.LastAccessed.Format("2006-01-02 15:04"),
+			Repo:       p.Origin.Repo,
+			Suite:      p.Origin.Suite,
+			Component:  p.Origin.Component,
+			Mirror:     p.Origin.Mirror,
+		})
+	}
+	return out
+}
+
 // SetDashboard sets the dashboard for the server
 func (s *Server) SetDashboard(d *dashboard.Dashboard) {
 	s.dashboard = d
 }
 
+// SetConfigSource enables GET /api/config, which serves the TOML that fn
+// returns. fn must leave secrets out.
+func (s *Server) SetConfigSource(fn func() ([]byte, error)) {
+	s.configSource = fn
+}
+
+// SetAPTImporter enables POST /api/apt/import, which the APT hook calls to
+// import and announce packages APT downloaded without the proxy.
+func (s *Server) SetAPTImporter(i *aptarchives.Importer) {
+	s.aptImporter = i
+}
+
 // GetDashboardStats returns stats in dashboard format
 func (s *Server) GetDashboardStats() *dashboard.Stats {
 	stats := s.GetStats()
@@ -728,9 +1097,17 @@ func (s *Server) GetDashboardStats() *dashboard.Stats {
 	}
 
 	// Calculate cache usage
+	cacheMaxSize := s.cache.MaxSize()
 	cacheUsage := float64(0)
-	if s.cacheMaxSize > 0 {
-		cacheUsage = float64(s.cache.Size()) / float64(s.cacheMaxSize) * 100
+	if cacheMaxSize > 0 {
+		cacheUsage = float64(s.cache.Size()) / float64(cacheMaxSize) * 100
+	}
+
+	full := s.CacheFullStatus()
+	update := s.updates.Status()
+	degradedSince := ""
+	if full.Degraded {
+		degradedSince = full.Since.Format("2006-01-02 15:04:05")
 	}
 
 	// Get P2P stats
@@ -751,13 +1128,23 @@ func (s *Server) GetDashboardStats() *dashboard.Stats {
 		P2PRatioPercent:      p2pRatio,
 		CacheSizeBytes:       s.cache.Size(),
 		CacheCount:           s.cache.Count(),
-		CacheMaxSize:         formatBytes(s.cacheMaxSize),
+		CacheMaxSize:         formatBytes(cacheMaxSize),
 		CacheUsagePercent:    cacheUsage,
 		ConnectedPeers:       connectedPeers,
 		RoutingTableSize:     routingTableSize,
 		ActiveDownloads:      int(s.metrics.ActiveDownloads.Value()),
 		ActiveUploads:        int(s.metrics.ActiveUploads.Value()),
 		VerificationFailures: s.metrics.VerificationFailures.Value(),
+		CacheDegraded:        full.Degraded,
+		CacheDegradedSince:   degradedSince,
+		CacheFullRefusals:    full.Refused,
+		CacheStrictWhenFull:  s.strictWhenFull,
+		RecentPackages:       s.recentPackages(dashboardRecentPackages),
+		TopPackages:          s.topPackages(dashboardTopPackages),
+		TopClients:           s.topClients(dashboardTopClients),
+		LatestVersion:        update.Latest,
+		UpdateAvailable:      update.UpdateAvailable,
+		PeerVersions:         s.peerVersionShares(),
 	}
 }
 
@@ -793,9 +1180,12 @@ func (s *Server) GetPeerInfo() []dashboard.PeerInfo {
 			category = "Poor"
 		}
 
+		label := s.scorer.Label(ps.PeerID)
 		result = append(result, dashboard.PeerInfo{
 			ID:          ps.PeerID.String(),
 			ShortID:     shortID,
+			Name:        label.Name,
+			Tags:        label.Tags,
 			Score:       score,
 			Category:    category,
 			Latency:     formatDuration(time.Duration(ps.AvgLatencyMs) * time.Millisecond),
@@ -804,12 +1194,21 @@ func (s *Server) GetPeerInfo() []dashboard.PeerInfo {
 			Uploaded:    formatBytes(ps.BytesUploaded),
 			LastSeen:    formatDuration(time.Since(ps.LastSeen)) + " ago",
 			Blacklisted: ps.Blacklisted,
+			Version:     peerVersion(s.p2pNode.PeerCapabilities(ps.PeerID)),
 		})
 	}
 
 	return result
 }
 
+// peerVersion returns the debswarm version a peer reported, or "".
+func peerVersion(pc *p2p.PeerCapabilities) string {
+	if pc == nil {
+		return ""
+	}
+	return pc.Version
+}
+
 func formatBytes(b int64) string {
 	const unit = 1024
 	if b < unit {
@@ -849,6 +1248,13 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 		ctx = requestid.NewContext(ctx, s.logger)
 	}
 
+	// Continue the caller's W3C trace, if it sent one: its trace ID goes in
+	// the logs and audit events, and on to the mirror
+	if trace, ok := requestid.ParseTrace(r.Header.Get(requestid.TraceParentHeader), r.Header.Get(requestid.TraceStateHeader)); ok {
+		ctx = requestid.WithTrace(ctx, trace)
+		ctx = requestid.WithLogger(ctx, requestid.LoggerFromContext(ctx, s.logger).With(zap.String("traceID", trace.TraceID)))
+	}
+
 	// Get request-scoped logger and request ID
 	log := requestid.LoggerFromContext(ctx, s.logger)
 	reqID := requestid.FromContext(ctx)
@@ -859,11 +1265,41 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 	// Update request with new context
 	r = r.WithContext(ctx)
 
-	// Handle CONNECT method for HTTPS tunneling
+	// Count the request for its client; the response goes through the
+	// client's rate limit, if it has one
+	cw, clientPolicy, forced := s.trackClient(ctx, w, r)
+
+	// Handle CONNECT method for HTTPS tunneling. The tunnel needs the
+	// connection itself, so it bypasses the client writer.
 	if r.Method == http.MethodConnect {
 		s.handleConnect(w, r)
 		return
 	}
+	w = cw
+	w, r, logAccess := s.startAccessLog(w, r)
+	defer logAccess()
+
+	// A client may restrict where its packages come from, unless its
+	// profile sets the policy. The query parameter form is stripped here,
+	// before the URL is classified or fetched.
+	policy, err := parseSourcePolicy(r)
+	if forced {
+		policy = clientPolicy
+	} else if err != nil {
+		http.Error(w, "debswarm: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if policy != policyAuto {
+		r = r.WithContext(withSourcePolicy(r.Context(), policy))
+	}
+
+	if s.isGenericRequest(r) {
+		if rec := accessRecordFrom(r.Context()); rec != nil {
+			rec.class = "generic"
+		}
+		s.handleGeneric(w, r)
+		return
+	}
 
 	targetURL, allowed := s.extractTargetURL(r)
 	if targetURL == "" {
@@ -871,17 +1307,22 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !allowed {
-		s.writeBlockedURLError(w, r, targetURL)
+		s.writeBlockedURLError(w, r, targetURL, s.mirrorPolicy.Load().CheckURL(targetURL))
 		return
 	}
 
+	class, handler := classifyURL(targetURL)
+	if rec := accessRecordFrom(r.Context()); rec != nil {
+		rec.url = sanitize.URL(targetURL)
+		rec.class = string(class)
+	}
 	log.Debug("Proxy request",
 		zap.String("method", r.Method),
-		zap.String("url", sanitize.URL(targetURL)))
-
-	reqType := s.classifyRequest(targetURL)
+		zap.String("url", sanitize.URL(targetURL)),
+		zap.String("class", string(class)),
+		zap.Stringer("policy", policy))
 
-	switch reqType {
+	switch handler {
 	case requestTypePackage:
 		s.handlePackageRequest(w, r, targetURL)
 	case requestTypeIndex:
@@ -902,46 +1343,6 @@ const (
 	requestTypeRelease
 )
 
-func (s *Server) classifyRequest(url string) requestType {
-	lower := strings.ToLower(url)
-
-	if strings.HasSuffix(lower, ".deb") ||
-		strings.HasSuffix(lower, ".udeb") ||
-		strings.HasSuffix(lower, ".ddeb") {
-		return requestTypePackage
-	}
-
-	// Source-package artifacts (.dsc/.orig.tar.*/.debian.tar.*/.diff.gz/native
-	// tarball) are content-addressed and verified like binary packages once their
-	// Sources index is parsed. Classified before the index arm below so a source
-	// package whose name contains "sources" (e.g. pool/main/s/sources-list/….dsc)
-	// is not mistaken for a Sources index.
-	if isSourceArtifactURL(lower) {
-		return requestTypePackage
-	}
-
-	if strings.Contains(lower, "/packages") ||
-		strings.Contains(lower, "/sources") {
-		return requestTypeIndex
-	}
-
-	// Detect by-hash URLs for Packages/Sources files: dist-layout binary-*/source/,
-	// or a flat-layout repo where by-hash sits directly under the repo base.
-	// Exclude i18n/ (translations), cnf/ (commands) and dep11/ (appstream).
-	if strings.Contains(lower, "/by-hash/") {
-		if strings.Contains(lower, "/binary-") || strings.Contains(lower, "/source/") || isFlatByHash(lower) {
-			return requestTypeIndex
-		}
-	}
-
-	if strings.Contains(lower, "/release") ||
-		strings.Contains(lower, "/inrelease") {
-		return requestTypeRelease
-	}
-
-	return requestTypeUnknown
-}
-
 // extractTargetURL parses the target repository URL from the request and reports
 // whether it is allowed. It returns ("", false) when no URL can be parsed, and
 // (url, false) when a URL was parsed but is not permitted (blocked internal host,
@@ -968,34 +1369,49 @@ func (s *Server) extractTargetURL(r *http.Request) (targetURL string, allowed bo
 }
 
 // writeBlockedURLError responds with a clear, actionable error when a request is
-// refused by the allow list, distinguishing an internal/SSRF-blocked target from
-// a host that simply hasn't been allow-listed.
-func (s *Server) writeBlockedURLError(w http.ResponseWriter, r *http.Request, targetURL string) {
+// refused by the mirror policy, distinguishing an internal/SSRF-blocked target
+// from a host that simply hasn't been allow-listed, and records the refusal in
+// the audit log. decision is the policy's refusal.
+func (s *Server) writeBlockedURLError(w http.ResponseWriter, r *http.Request, targetURL string, decision security.Decision) {
 	log := requestid.LoggerFromContext(r.Context(), s.logger)
 	log.Warn("Blocked request to non-allowed URL",
 		zap.String("url", sanitize.URL(targetURL)),
+		zap.String("reason", decision.Reason),
 		zap.String("remoteAddr", r.RemoteAddr))
 
-	if security.IsBlockedHost(targetURL) {
-		http.Error(w, "debswarm: refused request to an internal or private address (SSRF protection)", http.StatusForbidden)
-		return
-	}
-
 	host := targetURL
 	if parsed, err := url.Parse(targetURL); err == nil && parsed.Hostname() != "" {
 		host = parsed.Hostname()
 	}
-	http.Error(w, fmt.Sprintf(
-		"debswarm: repository host %q is not in the allowed list. If this is a legitimate repository, "+
-			"add its host to proxy.allowed_hosts in your debswarm config (common third-party repos are trusted "+
-			"by default unless trust_known_repos is disabled).", host),
-		http.StatusForbidden)
+	s.audit.Log(audit.NewMirrorBlockedEvent(sanitize.URL(targetURL), host, decision.Reason).
+		WithRequest(r.Context()))
+
+	switch decision.Reason {
+	case security.ReasonBlockedAddress:
+		http.Error(w, "debswarm: refused request to an internal or private address (SSRF protection). "+
+			"To reach an internal mirror, add its address range to proxy.allowed_cidrs.", http.StatusForbidden)
+	case security.ReasonNotRepository:
+		http.Error(w, "debswarm: refused request for a file that is not part of an APT repository", http.StatusForbidden)
+	default:
+		http.Error(w, fmt.Sprintf(
+			"debswarm: repository host %q is not in the allowed list. If this is a legitimate repository, "+
+				"add its host to proxy.allowed_hosts in your debswarm config (common third-party repos are trusted "+
+				"by default unless trust_known_repos is disabled).", host),
+			http.StatusForbidden)
+	}
 }
 
 // isAllowedMirrorURL validates that a URL is a legitimate Debian/Ubuntu mirror
 // This prevents SSRF attacks by blocking requests to internal services
 func (s *Server) isAllowedMirrorURL(url string) bool {
-	return security.IsAllowedMirrorURLWithHosts(url, s.allowedHosts)
+	return s.mirrorPolicy.Load().CheckURL(url).Allowed
+}
+
+// SetMirrorPolicy replaces the policy deciding which upstream URLs and
+// CONNECT targets the proxy may reach, as on a config reload. Requests
+// already being served keep the policy they were checked against.
+func (s *Server) SetMirrorPolicy(policy *security.MirrorPolicy) {
+	s.mirrorPolicy.Store(policy)
 }
 
 // upstreamFetchURL upgrades a plain-HTTP mirror URL to HTTPS when its host is
@@ -1044,11 +1460,31 @@ func (s *Server) isHTTPSUpstreamHost(host string) bool {
 func (s *Server) handlePackageRequest(w http.ResponseWriter, r *http.Request, url string) {
 	ctx := r.Context()
 	log := requestid.LoggerFromContext(ctx, s.logger)
-	reqID := requestid.FromContext(ctx)
+	policy := sourcePolicyFrom(ctx)
 
 	// Extract path for caching
 	path := index.ExtractPathFromURL(url)
 
+	// Classes configured not to be cached stream straight from the mirror
+	if !s.policyForURL(url).Cache {
+		if !s.mirrorAllowed(policy) {
+			s.refuseMirror(ctx, w, policy, "", path, codePolicyRefused, "packages of this class are not cached, so only the mirror can serve them")
+			return
+		}
+		s.metrics.CacheMisses.Inc()
+		// Not cached does not mean not verified: a package the index knows
+		// is checked against its hash before APT receives a byte of it
+		if pkg := s.index.GetByURLPath(url); pkg != nil && isValidSHA256(pkg.SHA256) {
+			s.serveVerifiedUncachedPackage(w, r, url, pkg.Filename, pkg.SHA256, pkg.Size)
+			return
+		}
+		if s.refuseUnknownHash(log, w, url) {
+			return
+		}
+		s.streamUncachedPackage(w, r, url, path)
+		return
+	}
+
 	// Find expected hash from index using repo-aware lookup
 	var expectedHash string
 	var expectedSize int64
@@ -1099,6 +1535,16 @@ func (s *Server) handlePackageRequest(w http.ResponseWriter, r *http.Request, ur
 	// buffering the whole file in memory (it can be hundreds of MB). This path
 	// skips singleflight — a stream cannot be shared between coalesced waiters.
 	if expectedHash == "" {
+		if s.refuseUnknownHash(log, w, url) {
+			return
+		}
+		if !s.mirrorAllowed(policy) {
+			s.refuseMirror(ctx, w, policy, "", path, codePolicyRefused, "package has no signed index entry, so only the mirror can serve it")
+			return
+		}
+		if policy != policyAuto {
+			s.notePolicy(ctx, policy, "", path, downloader.SourceTypeMirror)
+		}
 		s.metrics.CacheMisses.Inc()
 		s.metrics.PackagesServedUncached.Inc()
 		s.noteUncachedServe(log, url)
@@ -1106,16 +1552,43 @@ func (s *Server) handlePackageRequest(w http.ResponseWriter, r *http.Request, ur
 		return
 	}
 
+	s.serveVerifiedPackage(w, r, url, path, expectedHash, expectedSize)
+}
+
+// serveVerifiedPackage serves a package whose SHA256 is known, from the
+// cache, an in-flight download, peers or the mirror at url, verifying what it
+// downloads. path is the name the package is cached and reported under.
+func (s *Server) serveVerifiedPackage(w http.ResponseWriter, r *http.Request, url, path, expectedHash string, expectedSize int64) {
+	ctx := r.Context()
+	log := requestid.LoggerFromContext(ctx, s.logger)
+	policy := sourcePolicyFrom(ctx)
+
+	// A revoked hash is never served, even from cache: the purge may not have
+	// run yet (or the file was in use when it did).
+	if s.refuseRevoked(ctx, w, expectedHash) {
+		return
+	}
+	if s.refuseQuarantined(w, expectedHash) {
+		return
+	}
+
 	// Check local cache first
-	if s.cache.Has(expectedHash) {
+	if policy.allowsCache() && s.cache.Has(expectedHash) {
+		if !s.allowServe(w, r, s.hookPackage(expectedHash, path, expectedSize, "cache", nil)) {
+			return
+		}
 		err := s.serveFromCache(w, expectedHash)
 		if err == nil {
 			log.Debug("Cache hit", zap.String("hash", expectedHash[:16]+"..."))
 			atomic.AddInt64(&s.cacheHits, 1)
 			s.metrics.CacheHits.Inc()
+			s.packageServed(ctx, expectedHash, url, path, expectedSize)
 
 			// Audit log cache hit
-			s.audit.Log(audit.NewCacheHitEvent(expectedHash, path, expectedSize).WithRequestID(reqID))
+			s.audit.Log(audit.NewCacheHitEvent(expectedHash, path, expectedSize).WithRequest(ctx))
+			if policy != policyAuto {
+				s.notePolicy(ctx, policy, expectedHash, path, "cache")
+			}
 			return
 		}
 		// Has() saw the file but Get() failed — the classic aftermath of
@@ -1130,6 +1603,12 @@ func (s *Server) handlePackageRequest(w http.ResponseWriter, r *http.Request, ur
 	}
 
 	s.metrics.CacheMisses.Inc()
+	s.background.promote(expectedHash)
+
+	if policy == policyCacheOnly {
+		s.refuseByPolicy(ctx, w, policy, expectedHash, path, codePolicyRefused, "package is not cached")
+		return
+	}
 
 	// Offline fast-fail: the package is not cached and there is genuinely nothing
 	// to reach — ModeOffline means no internet AND no mDNS peers. Skip the doomed
@@ -1137,33 +1616,131 @@ func (s *Server) handlePackageRequest(w http.ResponseWriter, r *http.Request, ur
 	// making it wait out the download timeouts.
 	if s.connectivity != nil && s.connectivity.GetMode() == connectivity.ModeOffline {
 		log.Debug("Package not cached and node is offline", zap.String("url", sanitize.URL(url)))
-		http.Error(w, "package not cached and node is offline", http.StatusServiceUnavailable)
+		s.writeFailure(w, codeOffline, "package not cached and node is offline")
 		return
 	}
 
+	// A request for a package that is already downloading attaches to that
+	// download and streams it as it arrives. A policy-restricted request
+	// cannot: the download may be using a source the policy excludes. Nor can
+	// any request while pre-serve hooks are registered or scanning is on, as
+	// the package must be verified and checked before a byte of it is sent.
+	// Offline, a failed download must be reported rather than cut short.
+	var fl *inflightDownload
+	if policy == policyAuto && !s.hooks.HasPreServe() && s.scanner == nil && !s.offline() {
+		var leader bool
+		fl, leader = s.inflight.join(expectedHash, expectedSize)
+		if !leader {
+			log.Debug("Request joined in-flight download", zap.String("url", sanitize.URL(url)))
+			if s.serveInflight(w, r, fl, log) {
+				s.packageServed(ctx, expectedHash, url, path, expectedSize)
+			}
+			return
+		}
+		if s.resumablePrefix(expectedHash, expectedSize) > 0 {
+			s.serveReadThrough(w, r, fl, url, expectedHash, expectedSize, path)
+			return
+		}
+		if s.streamMirror {
+			s.serveStreamed(w, r, fl, url, expectedHash, expectedSize, path)
+			return
+		}
+	}
+
 	// Use singleflight to coalesce concurrent requests for the same package
-	// This prevents duplicate downloads when multiple clients request the same package
+	// This prevents duplicate downloads when multiple clients request the same package.
+	// Requests only coalesce with others under the same policy.
 	coalescingKey := expectedHash
+	if policy != policyAuto {
+		coalescingKey += "|" + string(policy)
+	}
 
+	led := false
 	result, err, shared := s.downloadGroup.Do(coalescingKey, func() (interface{}, error) {
-		return s.downloadPackage(ctx, url, expectedHash, expectedSize, path)
+		led = true
+		res, err := s.downloadPackage(ctx, url, expectedHash, expectedSize, path)
+		if err == nil {
+			s.recordOrigin(url, expectedHash)
+		}
+		return res, err
 	})
+	var downloadResult *packageDownloadResult
+	if err == nil {
+		downloadResult = result.(*packageDownloadResult)
+	}
+	if fl != nil {
+		s.inflight.finish(fl, downloadResult, err)
+	}
 
+	if shared && !led {
+		s.metrics.CoalescedRequests.WithLabel("package").Inc()
+	}
 	if shared {
 		log.Debug("Request coalesced with another download",
 			zap.String("url", sanitize.URL(url)),
 			zap.String("key", coalescingKey[:min(16, len(coalescingKey))]+"..."))
 	}
 
+	if errors.Is(err, errPolicyRefused) {
+		reason := "no peer could provide the package"
+		if s.p2pPaused() {
+			reason = "P2P is paused"
+		}
+		s.refuseMirror(ctx, w, policy, expectedHash, path, classifyP2PFailure(err), reason)
+		return
+	}
+	if errors.Is(err, cache.ErrQuarantined) {
+		s.writeFailure(w, codeQuarantined, "debswarm: package quarantined by malware scanner")
+		return
+	}
 	if err != nil {
-		log.Error("Download failed", zap.Error(err))
-		http.Error(w, "Failed to fetch package", http.StatusBadGateway)
+		logFetchFailure(ctx, log, "Download failed", err)
+		s.writeFetchFailure(w, "failed to fetch package", err)
+		return
+	}
+	if policy != policyAuto {
+		s.notePolicy(ctx, policy, expectedHash, path, downloadResult.source)
+	}
+
+	if !s.allowServe(w, r, s.resultHookPackage(downloadResult, path)) {
 		return
 	}
 
 	// Serve the result
-	downloadResult := result.(*packageDownloadResult)
 	s.servePackageResult(w, downloadResult)
+	s.packageServed(ctx, expectedHash, url, path, expectedSize)
+}
+
+// recordOrigin records the repository, suite and component of a newly cached
+// package, from the index entry url resolves to, and the mirror URL it was
+// requested from. A package served without being cached has no row to
+// update, which is fine.
+func (s *Server) recordOrigin(rawURL, hash string) {
+	origin := cache.Origin{Mirror: originMirror(s.upstreamFetchURL(rawURL))}
+	if pkg := s.index.GetByURLPath(rawURL); pkg != nil && pkg.SHA256 == hash {
+		origin.Repo, origin.Suite, origin.Component = pkg.Repo, pkg.Suite, pkg.Component
+	} else {
+		origin.Repo = index.ExtractRepoFromURL(rawURL)
+	}
+	if err := s.cache.SetOrigin(hash, origin); err != nil {
+		if !errors.Is(err, cache.ErrNotFound) {
+			s.logger.Debug("Failed to record package origin", zap.String("hash", hash[:min(16, len(hash))]), zap.Error(err))
+		}
+		return
+	}
+	// With its origin recorded, the package can be offered to replication
+	// partners, whose filters may select by repository
+	s.publishReplication(hash)
+}
+
+// originMirror returns rawURL without credentials, for recording.
+func originMirror(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	u.User = nil
+	return u.String()
 }
 
 // warmIndexFromCacheOnce loads every cached Packages index into the in-memory
@@ -1227,18 +1804,34 @@ type packageDownloadResult struct {
 // downloadPackage performs the actual download (called via singleflight)
 func (s *Server) downloadPackage(ctx context.Context, url, expectedHash string, expectedSize int64, path string) (result *packageDownloadResult, retErr error) {
 	log := requestid.LoggerFromContext(ctx, s.logger)
-	reqID := requestid.FromContext(ctx)
+	policy := sourcePolicyFrom(ctx)
+	// While P2P is paused every download behaves as if peers were excluded.
+	// So does a class that is not shared.
+	peersAllowed := policy.allowsPeers() && !s.p2pPaused() && s.policyForURL(url).Share
+	// Why peers could not serve the package, reported if they were the only
+	// allowed source.
+	p2pErr := errNoProviders
+	if s.p2pPaused() {
+		p2pErr = p2p.ErrPaused
+	}
 
 	// Check if this is a security update (for scheduler rate bypassing)
-	isSecurityUpdate := scheduler.IsSecurityUpdate(url)
+	isSecurityUpdate := s.isSecurityDownload(url)
 	if isSecurityUpdate && s.scheduler != nil {
 		log.Debug("Security update detected, using full speed",
 			zap.String("url", sanitize.URL(url)))
 		s.metrics.SchedulerUrgentDownloads.Inc()
 	}
+	// The scheduler's window rate applies to this download, from any source
+	ctx = scheduler.WithPackageDownload(ctx, isSecurityUpdate)
+
+	// Follow the download chunk by chunk for the dashboard
+	progress := s.downloads.Start(expectedHash, path, expectedSize)
+	ctx = downloader.WithProgress(ctx, progress)
+	defer func() { s.finishDownload(ctx, progress, result, retErr) }()
 
 	// Consult fleet coordinator before downloading
-	if expectedHash != "" && s.fleet != nil {
+	if expectedHash != "" && s.fleet != nil && peersAllowed {
 		fleetResult, fleetErr := s.fleet.WantPackage(ctx, expectedHash, expectedSize)
 		if fleetErr == nil {
 			switch fleetResult.Action {
@@ -1264,7 +1857,11 @@ func (s *Server) downloadPackage(ctx context.Context, url, expectedHash string,
 						contentType: "application/vnd.debian.binary-package",
 					}, nil
 				}
+				if errors.Is(dlErr, cache.ErrQuarantined) {
+					return nil, dlErr
+				}
 				log.Debug("Fleet LAN download failed, falling back to normal download", zap.Error(dlErr))
+				progress.Note("LAN peer download failed: " + dlErr.Error())
 
 			case fleet.ActionWaitPeer:
 				// Another peer is fetching this package — wait for them, then grab via LAN
@@ -1292,6 +1889,9 @@ func (s *Server) downloadPackage(ctx context.Context, url, expectedHash string,
 								contentType: "application/vnd.debian.binary-package",
 							}, nil
 						}
+						if errors.Is(dlErr, cache.ErrQuarantined) {
+							return nil, dlErr
+						}
 						log.Debug("Fleet peer download after wait failed, falling back", zap.Error(dlErr))
 					}
 				case <-waitCtx.Done():
@@ -1306,7 +1906,7 @@ func (s *Server) downloadPackage(ctx context.Context, url, expectedHash string,
 	}
 
 	// Notify fleet that we're fetching from WAN (so other nodes can wait for us)
-	if expectedHash != "" && s.fleet != nil {
+	if expectedHash != "" && s.fleet != nil && s.mirrorAllowed(policy) && !s.p2pPaused() {
 		s.fleet.NotifyFetching(expectedHash, expectedSize)
 		defer func() {
 			if retErr != nil {
@@ -1322,21 +1922,49 @@ func (s *Server) downloadPackage(ctx context.Context, url, expectedHash string,
 	var mirrorSource downloader.Source
 
 	// Find P2P providers if we have a hash
-	if expectedHash != "" && s.p2pNode != nil {
-		dhtCtx, dhtCancel := context.WithTimeout(ctx, s.timeouts.Get(timeouts.OpDHTLookup))
-		providers, err := s.p2pNode.FindProvidersRanked(dhtCtx, expectedHash, s.dhtLookupLimit)
+	if expectedHash != "" && s.p2pNode != nil && peersAllowed {
+		node := s.nodeForURL(url)
+
+		// An APT client is waiting on this lookup, so it goes ahead of
+		// background DHT work when the lookup budget is tight.
+		dhtCtx, dhtCancel := context.WithTimeout(p2p.WithPriority(ctx, p2p.PriorityHigh), s.timeouts.Get(timeouts.OpDHTLookup))
+		providers, err := node.FindProvidersRanked(dhtCtx, expectedHash, s.dhtLookupLimit)
 		dhtCancel()
+		if err != nil {
+			p2pErr = fmt.Errorf("%w: %w", errDHTLookup, err)
+		}
+
+		// Anti-eclipse: a provider set concentrated in too few address groups
+		// may be one operator's sybils withholding or stalling the package.
+		// Fetch from the mirror instead — unless the mirror is unreachable, in
+		// which case the peers are the only option and hash verification still
+		// guards integrity.
+		if err == nil && len(providers) > 0 && !s.scorer.HasSufficientDiversity(providers) &&
+			(s.connectivity == nil || s.connectivity.GetMode() == connectivity.ModeOnline) {
+			log.Info("Provider set lacks address diversity, using mirror",
+				zap.String("hash", expectedHash[:16]+"..."),
+				zap.Int("providers", len(providers)),
+				zap.Int("addressGroups", peers.AddressGroups(providers)))
+			s.metrics.LowDiversityProviders.Inc()
+			progress.Note("provider set lacks address diversity, using the mirror")
+			providers = nil
+		}
 
 		if err == nil && len(providers) > 0 {
 			log.Debug("Found P2P providers",
 				zap.String("hash", expectedHash[:16]+"..."),
 				zap.Int("count", len(providers)))
 
+			fleetPeers := s.fleetHTTPSPeers(node)
 			for _, p := range providers {
 				peerSources = append(peerSources, &downloader.PeerSource{
 					Info: p,
 					Downloader: func(ctx context.Context, info peer.AddrInfo, hash string, start, end int64) ([]byte, error) {
-						return s.p2pNode.DownloadRange(ctx, info, hash, start, end)
+						data, err := node.DownloadRange(p2p.WithContentCheck(ctx, p2p.ExpectTotalSize(expectedSize)), info, hash, start, end)
+						if err != nil && fleetPeers[info.ID] {
+							return s.fleetHTTPSRange(ctx, info, hash, start, end, err)
+						}
+						return data, err
 					},
 				})
 			}
@@ -1359,20 +1987,51 @@ func (s *Server) downloadPackage(ctx context.Context, url, expectedHash string,
 		},
 	}
 
+	// p2p-only or offline: the downloader must not fall back to (or race)
+	// the mirror.
+	if !s.mirrorAllowed(policy) {
+		mirrorSource = nil
+	}
+
+	// Split horizon: LAN peers first, on their own
+	if expectedHash != "" && len(peerSources) > 0 {
+		result, err := s.downloadLANFirst(ctx, expectedHash, expectedSize, peerSources, mirrorSource != nil)
+		if result != nil {
+			if result.PeerBytes > 0 {
+				s.sampleCanary(ctx, mirrorURL, expectedHash, path, result.Size, result.Duration)
+			}
+			return s.processDownloadSuccess(ctx, result, expectedHash, path)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Debug("LAN peers could not serve the package in budget, adding WAN sources", zap.Error(err))
+			progress.Note("LAN peers could not serve it in budget, using WAN peers and the mirror")
+		}
+	}
+
 	// Use parallel downloader for large files with available peers
 	if expectedHash != "" && expectedSize > 0 && len(peerSources) > 0 {
 		result, err := s.downloader.Download(ctx, expectedHash, expectedSize, peerSources, mirrorSource)
 		if err == nil {
-			return s.processDownloadSuccess(ctx, result, expectedHash, path), nil
+			if result.PeerBytes > 0 {
+				s.sampleCanary(ctx, mirrorURL, expectedHash, path, result.Size, result.Duration)
+			}
+			return s.processDownloadSuccess(ctx, result, expectedHash, path)
 		}
 		log.Debug("Parallel download failed, falling back to mirror", zap.Error(err))
+		progress.Note("parallel download failed: " + err.Error())
 	}
 
 	// Fallback: try simple P2P then mirror
 	if expectedHash != "" && len(peerSources) > 0 {
+		p2pErr = errPeersFailed
 		for _, src := range peerSources[:min(3, len(peerSources))] {
 			peerCtx, peerCancel := context.WithTimeout(ctx, s.p2pTimeout)
+			started := time.Now()
 			data, err := src.DownloadFull(peerCtx, expectedHash)
+			p2pDuration := time.Since(started)
 			peerCancel()
 
 			if err != nil {
@@ -1380,15 +2039,20 @@ func (s *Server) downloadPackage(ctx context.Context, url, expectedHash string,
 			}
 
 			// Verify and cache in a single hashing pass (inside cache.Put)
-			if verifyErr := s.verifyAndCache(data, expectedHash, path); verifyErr != nil {
+			if verifyErr := s.verifyAndCache(data, expectedHash, path, downloader.SourceTypePeer); verifyErr != nil {
+				// The peer served the right bytes; the package itself is bad,
+				// or there is no room to cache it.
+				if errors.Is(verifyErr, cache.ErrQuarantined) || s.refusesUncached(verifyErr) {
+					return nil, verifyErr
+				}
 				log.Warn("P2P hash mismatch, blacklisting peer")
 				s.metrics.VerificationFailures.Inc()
 				if ps, ok := src.(*downloader.PeerSource); ok {
-					s.scorer.Blacklist(ps.Info.ID, "hash mismatch", 24*time.Hour)
-					s.metrics.PeersBlacklisted.Inc()
+					ban := s.strikePeer(ps.Info.ID, "hash mismatch")
 					// Audit log verification failure and the resulting blacklist
-					s.audit.Log(audit.NewVerificationFailedEvent(expectedHash, path, ps.Info.ID.String()).WithRequestID(reqID))
-					s.audit.Log(audit.NewPeerBlacklistedEvent(ps.Info.ID.String(), "hash mismatch").WithRequestID(reqID))
+					name := s.scorer.Name(ps.Info.ID)
+					s.audit.Log(audit.NewVerificationFailedEvent(expectedHash, path, ps.Info.ID.String()).WithRequest(ctx).WithPeerName(name))
+					s.audit.Log(audit.NewPeerBlacklistedEvent(ps.Info.ID.String(), banReason(ban)).WithRequest(ctx).WithPeerName(name))
 				}
 				continue
 			}
@@ -1411,7 +2075,8 @@ func (s *Server) downloadPackage(ctx context.Context, url, expectedHash string,
 				0, // duration not tracked for simple downloads
 				int64(len(data)),
 				0,
-			).WithRequestID(reqID))
+			).WithRequest(ctx))
+			s.sampleCanary(ctx, mirrorURL, expectedHash, path, int64(len(data)), p2pDuration)
 
 			return &packageDownloadResult{
 				data:        data,
@@ -1422,6 +2087,10 @@ func (s *Server) downloadPackage(ctx context.Context, url, expectedHash string,
 		}
 	}
 
+	if !s.mirrorAllowed(policy) {
+		return nil, fmt.Errorf("%w: %w", errPolicyRefused, p2pErr)
+	}
+
 	// Final fallback: mirror. Stream the body straight into the cache — Put
 	// hashes and verifies while writing to disk — then serve from the cached
 	// file, so the package is never buffered in memory (it can be hundreds of
@@ -1435,25 +2104,39 @@ func (s *Server) downloadPackage(ctx context.Context, url, expectedHash string,
 	if err != nil {
 		logFetchFailure(ctx, log, "Mirror fetch failed", err)
 		// Audit log download failure
-		s.audit.Log(audit.NewDownloadFailedEvent(expectedHash, path, err.Error()).WithRequestID(reqID))
+		s.audit.Log(audit.NewDownloadFailedEvent(expectedHash, path, err.Error()).WithRequest(ctx))
 		return nil, fmt.Errorf("mirror fetch failed: %w", err)
 	}
 
-	counted := &countingReader{r: body}
-	putErr := s.cache.Put(counted, expectedHash, path)
+	counted := &countingReader{r: progress.Reader(body, downloader.SourceTypeMirror, mirrorURL)}
+	var src io.Reader = counted
+	// Spool the stream for any requests attached to this download, so they
+	// receive the package as it arrives instead of after verification.
+	// A policy-restricted download never registered one (see handlePackageRequest).
+	if fl := s.inflight.get(expectedHash); policy == policyAuto && fl != nil && fl.startSpool(s.spoolDir()) {
+		src = io.TeeReader(counted, fl)
+	}
+	putErr := s.cache.Put(src, expectedHash, path)
+	s.noteCacheWrite(putErr)
 	if closeErr := body.Close(); closeErr != nil {
 		log.Debug("Failed to close mirror response body", zap.Error(closeErr))
 	}
 
 	if putErr != nil {
+		if errors.Is(putErr, cache.ErrQuarantined) {
+			return nil, putErr
+		}
 		if errors.Is(putErr, cache.ErrHashMismatch) {
 			log.Warn("Mirror hash mismatch",
 				zap.String("expected", expectedHash),
 				zap.Error(putErr))
 			s.metrics.VerificationFailures.Inc()
-			s.audit.Log(audit.NewVerificationFailedEvent(expectedHash, path, "mirror").WithRequestID(reqID))
+			s.audit.Log(audit.NewVerificationFailedEvent(expectedHash, path, "mirror").WithRequest(ctx))
 			return nil, fmt.Errorf("mirror data failed hash verification: %w", putErr)
 		}
+		if s.refusesUncached(putErr) {
+			return nil, fmt.Errorf("failed to cache package: %w", putErr)
+		}
 
 		// The cache could not store the package (cache full, disk error). The
 		// stream is already partially consumed, so re-fetch buffered — the old
@@ -1461,21 +2144,22 @@ func (s *Server) downloadPackage(ctx context.Context, url, expectedHash string,
 		log.Warn("Failed to cache streamed mirror download, refetching into memory", zap.Error(putErr))
 		data, fetchErr := s.fetcher.Fetch(ctx, mirrorURL)
 		if fetchErr != nil {
-			s.audit.Log(audit.NewDownloadFailedEvent(expectedHash, path, fetchErr.Error()).WithRequestID(reqID))
+			s.audit.Log(audit.NewDownloadFailedEvent(expectedHash, path, fetchErr.Error()).WithRequest(ctx))
 			return nil, fmt.Errorf("mirror fetch failed: %w", fetchErr)
 		}
 		actualHash := sha256.Sum256(data)
 		if hex.EncodeToString(actualHash[:]) != expectedHash {
 			s.metrics.VerificationFailures.Inc()
-			s.audit.Log(audit.NewVerificationFailedEvent(expectedHash, path, "mirror").WithRequestID(reqID))
-			return nil, fmt.Errorf("mirror data failed hash verification: expected %s", expectedHash)
+			s.audit.Log(audit.NewVerificationFailedEvent(expectedHash, path, "mirror").WithRequest(ctx))
+			return nil, fmt.Errorf("mirror data failed hash verification: %w: expected %s", cache.ErrHashMismatch, expectedHash)
 		}
 		atomic.AddInt64(&s.bytesFromMirror, int64(len(data)))
 		s.metrics.DownloadsTotal.WithLabel(downloader.SourceTypeMirror).Inc()
 		s.metrics.BytesDownloaded.WithLabel(downloader.SourceTypeMirror).Add(int64(len(data)))
 		s.audit.Log(audit.NewDownloadCompleteEvent(
 			expectedHash, path, int64(len(data)), downloader.SourceTypeMirror,
-			0, 0, int64(len(data))).WithRequestID(reqID))
+			0, 0, int64(len(data))).WithRequest(ctx))
+		s.contentVerified(expectedHash, path, int64(len(data)), downloader.SourceTypeMirror, data)
 		return &packageDownloadResult{
 			data:        data,
 			hash:        expectedHash,
@@ -1489,6 +2173,7 @@ func (s *Server) downloadPackage(ctx context.Context, url, expectedHash string,
 	s.metrics.DownloadsTotal.WithLabel(downloader.SourceTypeMirror).Inc()
 	s.metrics.BytesDownloaded.WithLabel(downloader.SourceTypeMirror).Add(size)
 
+	s.contentVerified(expectedHash, path, size, downloader.SourceTypeMirror, nil)
 	s.announceAsync(expectedHash)
 	if s.verifier != nil {
 		s.verifier.VerifyAsync(expectedHash, path)
@@ -1503,7 +2188,7 @@ func (s *Server) downloadPackage(ctx context.Context, url, expectedHash string,
 		0, // duration not tracked for mirror fallback
 		0,
 		size,
-	).WithRequestID(reqID))
+	).WithRequest(ctx))
 
 	return &packageDownloadResult{
 		hash:           expectedHash,
@@ -1538,24 +2223,34 @@ func (s *Server) downloadFromFleetPeer(ctx context.Context, providerID peer.ID,
 	peerCtx, cancel := context.WithTimeout(ctx, s.p2pTimeout)
 	defer cancel()
 
-	data, err := s.p2pNode.Download(peerCtx, peer.AddrInfo{ID: providerID, Addrs: addrs}, expectedHash)
+	info := peer.AddrInfo{ID: providerID, Addrs: addrs}
+	data, err := s.p2pNode.Download(peerCtx, info, expectedHash)
+	if err != nil && ctx.Err() == nil {
+		// The fallback gets its own time: the P2P attempt may have used it up
+		fallbackCtx, fallbackCancel := context.WithTimeout(ctx, s.p2pTimeout)
+		data, err = s.fleetHTTPSRange(fallbackCtx, info, expectedHash, 0, -1, err)
+		fallbackCancel()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("fleet peer download: %w", err)
 	}
 
-	if err := s.verifyAndCache(data, expectedHash, path); err != nil {
-		s.scorer.Blacklist(providerID, "fleet hash mismatch", 24*time.Hour)
-		s.metrics.PeersBlacklisted.Inc()
-		s.audit.Log(audit.NewPeerBlacklistedEvent(providerID.String(), "fleet hash mismatch"))
+	if err := s.verifyAndCache(data, expectedHash, path, "fleet"); err != nil {
+		if errors.Is(err, cache.ErrQuarantined) || s.refusesUncached(err) {
+			return nil, err
+		}
+		ban := s.strikePeer(providerID, "fleet hash mismatch")
+		s.audit.Log(audit.NewPeerBlacklistedEvent(providerID.String(), banReason(ban)).WithPeerName(s.scorer.Name(providerID)))
 		return nil, fmt.Errorf("fleet peer hash mismatch")
 	}
 	return data, nil
 }
 
-// processDownloadSuccess processes a successful parallel download result
-func (s *Server) processDownloadSuccess(ctx context.Context, result *downloader.DownloadResult, expectedHash, path string) *packageDownloadResult {
+// processDownloadSuccess processes a successful parallel download result. It
+// fails only when the malware scanner quarantined the package, or when strict
+// mode refuses a package the full cache has no room for.
+func (s *Server) processDownloadSuccess(ctx context.Context, result *downloader.DownloadResult, expectedHash, path string) (*packageDownloadResult, error) {
 	log := requestid.LoggerFromContext(ctx, s.logger)
-	reqID := requestid.FromContext(ctx)
 
 	// Update stats
 	atomic.AddInt64(&s.bytesFromP2P, result.PeerBytes)
@@ -1586,7 +2281,7 @@ func (s *Server) processDownloadSuccess(ctx context.Context, result *downloader.
 		result.Duration.Milliseconds(),
 		result.PeerBytes,
 		result.MirrorBytes,
-	).WithRequestID(reqID))
+	).WithRequest(ctx))
 
 	// Handle file-based result (chunked download - streaming)
 	if result.FilePath != "" {
@@ -1597,7 +2292,13 @@ func (s *Server) processDownloadSuccess(ctx context.Context, result *downloader.
 		assemblyDir := filepath.Dir(result.FilePath)
 
 		// Move verified file directly to cache (no memory copy)
-		if err := s.cache.PutFile(result.FilePath, expectedHash, path, result.Size); err != nil {
+		err := s.cache.PutFile(result.FilePath, expectedHash, path, result.Size)
+		s.noteCacheWrite(err)
+		if errors.Is(err, cache.ErrQuarantined) || s.refusesUncached(err) {
+			_ = os.RemoveAll(assemblyDir)
+			return nil, err
+		}
+		if err != nil {
 			// Caching failed (e.g. cache full). The package is fully downloaded and
 			// verified, so serve it anyway instead of returning 500 to APT. Read it
 			// into memory — consistent with the racing/mirror-fallback paths — and
@@ -1607,18 +2308,20 @@ func (s *Server) processDownloadSuccess(ctx context.Context, result *downloader.
 			data, readErr := os.ReadFile(result.FilePath) // #nosec G304 -- path is our own assembled download file
 			_ = os.RemoveAll(assemblyDir)
 			if readErr == nil {
+				s.contentVerified(expectedHash, path, result.Size, result.Source, data)
 				return &packageDownloadResult{
 					data:        data,
 					hash:        expectedHash,
 					size:        result.Size,
 					source:      result.Source,
 					contentType: "application/vnd.debian.binary-package",
-				}
+				}, nil
 			}
 			// Could not re-read the file (e.g. PutFile failed after its rename);
 			// fall through to the cache-serve path, which reports the error to APT.
 			log.Error("Failed to read downloaded file after cache failure", zap.Error(readErr))
 		} else {
+			s.contentVerified(expectedHash, path, result.Size, result.Source, nil)
 			s.announceAsync(expectedHash)
 			_ = os.RemoveAll(assemblyDir)
 		}
@@ -1629,18 +2332,20 @@ func (s *Server) processDownloadSuccess(ctx context.Context, result *downloader.
 			source:         result.Source,
 			contentType:    "application/vnd.debian.binary-package",
 			serveFromCache: true,
-		}
+		}, nil
 	}
 
 	// Handle in-memory result (racing download - small files)
-	s.cacheAndAnnounce(result.Data, expectedHash, path)
+	if err := s.cacheAndAnnounce(result.Data, expectedHash, path, result.Source); errors.Is(err, cache.ErrQuarantined) || s.refusesUncached(err) {
+		return nil, err
+	}
 
 	return &packageDownloadResult{
 		data:        result.Data,
 		hash:        expectedHash,
 		source:      result.Source,
 		contentType: "application/vnd.debian.binary-package",
-	}
+	}, nil
 }
 
 // servePackageResult writes a download result to the HTTP response
@@ -1650,7 +2355,7 @@ func (s *Server) servePackageResult(w http.ResponseWriter, result *packageDownlo
 		reader, _, err := s.cache.Get(result.hash)
 		if err != nil {
 			s.logger.Error("Failed to read from cache for serving", zap.Error(err))
-			http.Error(w, "Cache error", http.StatusInternalServerError)
+			s.writeFailure(w, codeCacheError, "Cache error")
 			return
 		}
 		defer reader.Close()
@@ -1675,17 +2380,24 @@ func (s *Server) servePackageResult(w http.ResponseWriter, result *packageDownlo
 	_, _ = w.Write(result.data)
 }
 
-func (s *Server) cacheAndAnnounce(data []byte, hash, path string) {
-	if err := s.cache.Put(bytes.NewReader(data), hash, path); err != nil {
+// cacheAndAnnounce caches verified data and announces it. A failure to cache
+// is logged and returned; the data may still be served unless it was
+// quarantined or strict mode refuses packages the full cache cannot take.
+func (s *Server) cacheAndAnnounce(data []byte, hash, path, source string) error {
+	err := s.cache.Put(bytes.NewReader(data), hash, path)
+	s.noteCacheWrite(err)
+	if err != nil {
 		s.logger.Warn("Failed to cache", zap.Error(err))
-		return
+		return err
 	}
+	s.contentVerified(hash, path, int64(len(data)), source, nil)
 	s.announceAsync(hash)
 
 	// Asynchronously verify via multi-source query
 	if s.verifier != nil {
 		s.verifier.VerifyAsync(hash, path)
 	}
+	return nil
 }
 
 // verifyAndCache verifies data against hash and stores it in the cache,
@@ -1693,17 +2405,21 @@ func (s *Server) cacheAndAnnounce(data []byte, hash, path string) {
 // not pre-hash, that was a redundant full pass over every download). If the
 // cache cannot store it for storage reasons, the data is verified directly so
 // the caller may still serve it uncached. A cache.ErrHashMismatch return means
-// the data is corrupt and must not be served.
-func (s *Server) verifyAndCache(data []byte, hash, path string) error {
+// the data is corrupt and must not be served; cache.ErrQuarantined means the
+// malware scanner flagged it. In strict mode a full cache's error is returned
+// too, and the data must not be served.
+func (s *Server) verifyAndCache(data []byte, hash, path, source string) error {
 	err := s.cache.Put(bytes.NewReader(data), hash, path)
+	s.noteCacheWrite(err)
 	if err == nil {
+		s.contentVerified(hash, path, int64(len(data)), source, nil)
 		s.announceAsync(hash)
 		if s.verifier != nil {
 			s.verifier.VerifyAsync(hash, path)
 		}
 		return nil
 	}
-	if errors.Is(err, cache.ErrHashMismatch) {
+	if errors.Is(err, cache.ErrHashMismatch) || errors.Is(err, cache.ErrQuarantined) {
 		return err
 	}
 	// Storage failure (cache full, disk error): verify manually so unverified
@@ -1712,19 +2428,30 @@ func (s *Server) verifyAndCache(data []byte, hash, path string) error {
 	if hex.EncodeToString(actual[:]) != hash {
 		return fmt.Errorf("%w: expected %s", cache.ErrHashMismatch, hash)
 	}
+	if s.refusesUncached(err) {
+		return err
+	}
 	s.logger.Warn("Failed to cache verified package", zap.Error(err))
+	s.contentVerified(hash, path, int64(len(data)), source, data)
 	return nil
 }
 
 func (s *Server) announceAsync(hash string) {
-	if s.p2pNode == nil {
+	// While paused, the package stays unannounced and the next reannounce
+	// after Resume picks it up.
+	if s.p2pNode == nil || s.p2pNode.Paused() {
+		return
+	}
+	if _, revoked := s.revocations.IsRevoked(hash); revoked {
 		return
 	}
 	// Non-blocking send to bounded channel
 	select {
 	case s.announceChan <- hash:
+		s.metrics.AnnounceQueueDepth.Set(float64(len(s.announceChan)))
 	default:
 		// Channel full, skip this announcement (will be reannounced later)
+		s.metrics.AnnounceQueueDropped.Inc()
 		s.logger.Debug("Announcement queue full, skipping", zap.String("hash", hash[:16]+"..."))
 	}
 }
@@ -1749,6 +2476,7 @@ func (s *Server) announcementWorker() {
 			close(s.announceDone)
 			return
 		case hash := <-s.announceChan:
+			s.metrics.AnnounceQueueDepth.Set(float64(len(s.announceChan)))
 			sem <- struct{}{} // Acquire semaphore
 			wg.Add(1)
 			go func(h string) {
@@ -1759,11 +2487,26 @@ func (s *Server) announcementWorker() {
 				// Use server's announce context as parent so announcements stop on shutdown
 				ctx, cancel := context.WithTimeout(s.announceCtx, announceTimeout)
 				defer cancel()
-				if err := s.p2pNode.Provide(ctx, h); err != nil {
+				if !s.allowAnnounce(ctx, h, nil) {
+					s.metrics.Announcements.WithLabel("refused").Inc()
+					return
+				}
+				node := s.nodeForHash(h)
+				if node == nil {
+					s.metrics.Announcements.WithLabel("refused").Inc()
+					return
+				}
+				if err := node.Provide(ctx, h); err != nil {
 					// Don't log context canceled errors during shutdown
 					if s.announceCtx.Err() == nil {
+						s.metrics.Announcements.WithLabel("failed").Inc()
 						s.logger.Debug("Failed to announce", zap.Error(err))
 					}
+					return
+				}
+				s.metrics.Announcements.WithLabel("ok").Inc()
+				if err := s.cache.MarkAnnounced(h, s.providerTTL); err != nil {
+					s.logger.Warn("Failed to mark as announced", zap.Error(err))
 				}
 			}(hash)
 		}
@@ -1851,8 +2594,13 @@ func (s *Server) checkAndRetryFailedDownloads() {
 
 // retryDownload performs a retry download for a failed package
 func (s *Server) retryDownload(expectedHash, url string, expectedSize int64, path string) {
+	if _, revoked := s.revocations.IsRevoked(expectedHash); revoked {
+		return
+	}
 	ctx, cancel := context.WithTimeout(s.retryCtx, 5*time.Minute)
 	defer cancel()
+	ctx, done := s.background.start(ctx, expectedHash, priority.Prefetch)
+	defer done()
 
 	// Coalesce with any concurrent requests for the same package
 	coalescingKey := expectedHash
@@ -1860,10 +2608,15 @@ func (s *Server) retryDownload(expectedHash, url string, expectedSize int64, pat
 		coalescingKey = url
 	}
 
+	led := false
 	result, err, shared := s.downloadGroup.Do(coalescingKey, func() (interface{}, error) {
+		led = true
 		return s.downloadPackage(ctx, url, expectedHash, expectedSize, path)
 	})
 
+	if shared && !led {
+		s.metrics.CoalescedRequests.WithLabel("retry").Inc()
+	}
 	if shared {
 		s.logger.Debug("Retry coalesced with another download",
 			zap.String("hash", expectedHash[:min(16, len(expectedHash))]+"..."))
@@ -1938,8 +2691,11 @@ func (s *Server) serveMetadata(w http.ResponseWriter, r *http.Request, url strin
 	ctx := r.Context()
 	log := requestid.LoggerFromContext(ctx, s.logger)
 
-	caching := s.cache != nil && s.cache.MetadataEnabled()
-	staleOK := caching && s.metadataServeStale
+	caching := s.cache != nil && s.cache.MetadataEnabled() && s.policyForURL(url).Cache
+	_, isBuild := buildKeyFrom(ctx)
+	// Builds always fall back to a stale copy: a mirror hiccup should not
+	// fail a build halfway through
+	staleOK := caching && (s.metadataServeStale || isBuild)
 
 	// Immutable by-hash URLs never change; if cached, serve with no upstream call.
 	if caching && cache.IsImmutableMetadataURL(url) {
@@ -1950,6 +2706,27 @@ func (s *Server) serveMetadata(w http.ResponseWriter, r *http.Request, url strin
 		}
 	}
 
+	// A Packages/Sources file rebuilt from pdiffs is served without asking the
+	// mirror while the signed Release still lists it.
+	if caching && isIndex && s.serveRebuiltIndex(w, r, url) {
+		return
+	}
+	if caching && isIndex && s.serveSharedIndex(w, r, url) {
+		return
+	}
+	if caching && isBuild && s.serveBuildMetadata(w, r, url, isIndex) {
+		return
+	}
+	if caching && s.serveFreshMetadata(w, r, url, isIndex) {
+		return
+	}
+
+	// Offline mode: the cached copy, however old, is all there is
+	if s.offline() {
+		s.serveOfflineMetadata(w, r, url, isIndex, caching)
+		return
+	}
+
 	// Offline fast-path: when connectivity is known-offline, skip the doomed
 	// upstream request and serve the cached copy (stale) directly.
 	if staleOK && s.connectivity != nil && s.connectivity.GetMode() == connectivity.ModeOffline {
@@ -1989,7 +2766,7 @@ func (s *Server) serveMetadata(w http.ResponseWriter, r *http.Request, url strin
 			}
 		}
 		logFetchFailure(ctx, log, "Failed to fetch metadata", err)
-		http.Error(w, "Failed to fetch", http.StatusBadGateway)
+		s.writeFetchFailure(w, "failed to fetch", err)
 		return
 	}
 
@@ -1998,6 +2775,12 @@ func (s *Server) serveMetadata(w http.ResponseWriter, r *http.Request, url strin
 			// Our cached copy is current: refresh its validators and serve it.
 			s.cache.RevalidateMetadata(url, cond.ETag, cond.LastModified)
 			if entry, rc, gerr := s.cache.GetMetadata(url); gerr == nil {
+				// A 304 rarely repeats the Content-Type the copy was
+				// classified by
+				if cond.ContentType == "" {
+					cond.ContentType = entry.ContentType
+				}
+				s.cache.SetMetadataFreshUntil(url, s.freshUntil(url, cond, time.Now()))
 				s.serveCachedMetadata(w, r, url, isIndex, entry, rc, false)
 				return
 			}
@@ -2007,6 +2790,7 @@ func (s *Server) serveMetadata(w http.ResponseWriter, r *http.Request, url strin
 			return
 		}
 		// Uncached: the client's own copy is current — relay the 304.
+		noteSource(ctx, downloader.SourceTypeMirror)
 		log.Debug("Metadata not modified upstream", zap.String("url", sanitize.URL(url)))
 		relayValidators(w, cond)
 		w.WriteHeader(http.StatusNotModified)
@@ -2023,6 +2807,7 @@ func (s *Server) serveFreshBody(w http.ResponseWriter, r *http.Request, url stri
 	log := requestid.LoggerFromContext(ctx, s.logger)
 	defer func() { _ = cond.Body.Close() }()
 
+	noteSource(ctx, downloader.SourceTypeMirror)
 	atomic.AddInt64(&s.metadataMisses, 1)
 	if s.metrics != nil {
 		s.metrics.MetadataCacheMisses.Inc()
@@ -2033,12 +2818,12 @@ func (s *Server) serveFreshBody(w http.ResponseWriter, r *http.Request, url stri
 		data, err := io.ReadAll(io.LimitReader(cond.Body, s.fetcher.MaxResponseSize()+1))
 		if err != nil {
 			logFetchFailure(ctx, log, "Failed to fetch index", err)
-			http.Error(w, "Failed to fetch index", http.StatusBadGateway)
+			s.writeFetchFailure(w, "failed to fetch index", err)
 			return
 		}
 		if int64(len(data)) > s.fetcher.MaxResponseSize() {
 			log.Error("Index response exceeds maximum allowed size")
-			http.Error(w, "Index too large", http.StatusBadGateway)
+			s.writeFailure(w, codeMirrorError, "Index too large")
 			return
 		}
 		// Verify the index against the signed Release before trusting/serving it.
@@ -2046,7 +2831,7 @@ func (s *Server) serveFreshBody(w http.ResponseWriter, r *http.Request, url stri
 		// mode it is served with an X-Debswarm-Unverified header (APT still checks
 		// GPG). Must run before any header/body is written.
 		if !s.checkIndexVerification(w, url, data, log) {
-			http.Error(w, "index failed upstream signature verification", http.StatusBadGateway)
+			s.writeFailure(w, codeIndexUnverified, "index failed upstream signature verification")
 			return
 		}
 		if isVerifiableIndexURL(url) {
@@ -2060,6 +2845,7 @@ func (s *Server) serveFreshBody(w http.ResponseWriter, r *http.Request, url stri
 		}
 		if caching {
 			s.storeMetadata(url, data, cond.ETag, cond.LastModified, "application/octet-stream", log)
+			s.shareFetchedIndex(url, data)
 		}
 		w.Header().Set("Content-Type", "application/octet-stream")
 		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
@@ -2069,9 +2855,23 @@ func (s *Server) serveFreshBody(w http.ResponseWriter, r *http.Request, url stri
 		return
 	}
 
+	// A URL no rule matches may be classified by the mirror's Content-Type,
+	// and then follows that class's cache policy
+	if class := classifyResponse(url, cond.ContentType); class != classUnknown {
+		noteClass(ctx, class)
+		caching = caching && s.classPolicy(class).Cache
+		// No index gives such a package's hash, so hash_required refuses it
+		if class == classPackage && s.refuseUnknownHash(log, w, url) {
+			return
+		}
+	}
+
 	// Non-index metadata streams straight through, tee'd into the cache so a
 	// large Contents/Translation file is never buffered in memory.
 	relayValidators(w, cond)
+	if cond.ContentType != "" {
+		w.Header().Set("Content-Type", cond.ContentType)
+	}
 	if cond.Size >= 0 {
 		w.Header().Set("Content-Length", fmt.Sprintf("%d", cond.Size))
 	}
@@ -2080,7 +2880,8 @@ func (s *Server) serveFreshBody(w http.ResponseWriter, r *http.Request, url stri
 	var dst io.Writer = w
 	var mw *cache.MetadataWriter
 	if caching {
-		if writer, werr := s.cache.NewMetadataWriter(url, cond.ETag, cond.LastModified, ""); werr == nil {
+		if writer, werr := s.cache.NewMetadataWriter(url, cond.ETag, cond.LastModified, cond.ContentType); werr == nil {
+			writer.SetFreshUntil(s.freshUntil(url, cond, time.Now()))
 			mw = writer
 			dst = io.MultiWriter(w, mw)
 		} else {
@@ -2099,10 +2900,13 @@ func (s *Server) serveFreshBody(w http.ResponseWriter, r *http.Request, url stri
 		log.Warn("Passthrough stream interrupted", zap.Int64("written", n), zap.Error(err))
 		return
 	}
+	s.expireSuite(url)
 	if mw != nil {
 		if cerr := mw.Commit(); cerr != nil {
 			log.Debug("Failed to cache metadata", zap.String("url", sanitize.URL(url)), zap.Error(cerr))
+			return
 		}
+		s.schedulePdiffRebuild(url)
 	}
 }
 
@@ -2135,6 +2939,7 @@ func (s *Server) serveCachedMetadata(w http.ResponseWriter, r *http.Request, url
 	log := requestid.LoggerFromContext(ctx, s.logger)
 	defer func() { _ = rc.Close() }()
 
+	noteSource(ctx, "cache")
 	atomic.AddInt64(&s.metadataHits, 1)
 	atomic.AddInt64(&s.metadataBytesSaved, entry.Size)
 	if s.metrics != nil {
@@ -2148,6 +2953,8 @@ func (s *Server) serveCachedMetadata(w http.ResponseWriter, r *http.Request, url
 			s.metrics.MetadataCacheStaleServed.Inc()
 		}
 		s.noteStaleServe(log, url)
+	} else {
+		s.schedulePdiffRebuild(url)
 	}
 
 	warmIndex := isIndex && isVerifiableIndexURL(url) && !s.index.HasIndexFile(url)
@@ -2166,7 +2973,7 @@ func (s *Server) serveCachedMetadata(w http.ResponseWriter, r *http.Request, url
 		data, err := io.ReadAll(rc)
 		if err != nil {
 			logFetchFailure(ctx, log, "Failed to read cached index", err)
-			http.Error(w, "Failed to read cached index", http.StatusBadGateway)
+			s.writeFailure(w, codeCacheError, "Failed to read cached index")
 			return
 		}
 		if warmIndex {
@@ -2192,7 +2999,11 @@ func (s *Server) serveCachedMetadata(w http.ResponseWriter, r *http.Request, url
 		return
 	}
 
-	// Non-index: stream the cached body.
+	// Non-index: stream the cached body. A copy cached before hash_required
+	// was turned on is refused like a fresh one
+	if classifyResponse(url, entry.ContentType) == classPackage && s.refuseUnknownHash(log, w, url) {
+		return
+	}
 	if entry.ContentType != "" {
 		w.Header().Set("Content-Type", entry.ContentType)
 	}
@@ -2212,7 +3023,7 @@ func (s *Server) fetchFreshMetadata(w http.ResponseWriter, r *http.Request, url
 	cond, err := s.fetcher.StreamConditional(ctx, s.upstreamFetchURL(url), "", "")
 	if err != nil {
 		logFetchFailure(ctx, log, "Failed to fetch metadata", err)
-		http.Error(w, "Failed to fetch", http.StatusBadGateway)
+		s.writeFetchFailure(w, "failed to fetch", err)
 		return
 	}
 	if cond.NotModified {
@@ -2372,13 +3183,12 @@ func (s *Server) noteStaleServe(log *zap.Logger, rawURL string) {
 func (s *Server) streamUncachedPackage(w http.ResponseWriter, r *http.Request, url, path string) {
 	ctx := r.Context()
 	log := requestid.LoggerFromContext(ctx, s.logger)
-	reqID := requestid.FromContext(ctx)
 
 	body, size, err := s.fetcher.Stream(ctx, s.upstreamFetchURL(url))
 	if err != nil {
 		logFetchFailure(ctx, log, "Mirror fetch failed", err)
-		s.audit.Log(audit.NewDownloadFailedEvent("", path, err.Error()).WithRequestID(reqID))
-		http.Error(w, "Failed to fetch package", http.StatusBadGateway)
+		s.audit.Log(audit.NewDownloadFailedEvent("", path, err.Error()).WithRequest(ctx))
+		s.writeFetchFailure(w, "failed to fetch package", err)
 		return
 	}
 	defer func() { _ = body.Close() }()
@@ -2401,7 +3211,77 @@ func (s *Server) streamUncachedPackage(w http.ResponseWriter, r *http.Request, u
 		log.Warn("Uncached package stream interrupted", zap.Int64("written", n), zap.Error(copyErr))
 		return
 	}
-	s.audit.Log(audit.NewDownloadCompleteEvent("", path, n, downloader.SourceTypeMirror, 0, 0, n).WithRequestID(reqID))
+	s.audit.Log(audit.NewDownloadCompleteEvent("", path, n, downloader.SourceTypeMirror, 0, 0, n).WithRequest(ctx))
+}
+
+// serveVerifiedUncachedPackage serves a package of a class that is not cached
+// but whose SHA256 the index gives. It is spooled to a temporary file and
+// hashed on the way, and only served once it matches, so nothing unverified
+// reaches APT; the spool is removed afterwards.
+func (s *Server) serveVerifiedUncachedPackage(w http.ResponseWriter, r *http.Request, url, path, expectedHash string, expectedSize int64) {
+	ctx := r.Context()
+	log := requestid.LoggerFromContext(ctx, s.logger)
+
+	if s.refuseRevoked(ctx, w, expectedHash) {
+		return
+	}
+
+	body, _, err := s.fetcher.Stream(ctx, s.upstreamFetchURL(url))
+	if err != nil {
+		logFetchFailure(ctx, log, "Mirror fetch failed", err)
+		s.audit.Log(audit.NewDownloadFailedEvent(expectedHash, path, err.Error()).WithRequest(ctx))
+		s.writeFetchFailure(w, "failed to fetch package", err)
+		return
+	}
+	defer func() { _ = body.Close() }()
+
+	atomic.AddInt64(&s.requestsMirror, 1)
+	s.metrics.DownloadsTotal.WithLabel(downloader.SourceTypeMirror).Inc()
+
+	spool, err := os.CreateTemp(s.spoolDir(), "uncached-*")
+	if err != nil {
+		log.Error("Failed to create verification spool", zap.Error(err))
+		s.writeFailure(w, codeDiskFull, "no space to verify the package")
+		return
+	}
+	defer func() {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+	}()
+
+	limit := s.fetcher.MaxResponseSize()
+	if expectedSize > 0 {
+		limit = expectedSize
+	}
+	hasher := sha256.New()
+	n, copyErr := io.Copy(io.MultiWriter(spool, hasher), io.LimitReader(body, limit+1))
+	atomic.AddInt64(&s.bytesFromMirror, n)
+	s.metrics.BytesDownloaded.WithLabel(downloader.SourceTypeMirror).Add(n)
+	if copyErr != nil {
+		logFetchFailure(ctx, log, "Mirror fetch failed", copyErr)
+		s.writeFetchFailure(w, "failed to fetch package", copyErr)
+		return
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != expectedHash || (expectedSize > 0 && n != expectedSize) {
+		log.Warn("Mirror data failed hash verification",
+			zap.String("url", sanitize.URL(url)),
+			zap.String("expected", expectedHash[:16]+"..."))
+		s.metrics.VerificationFailures.Inc()
+		s.audit.Log(audit.NewVerificationFailedEvent(expectedHash, path, "mirror").WithRequest(ctx))
+		s.writeFailure(w, codeVerificationFailed, "package does not match the SHA256 in the index")
+		return
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		s.writeFailure(w, codeCacheError, "verified package could not be read back")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.debian.binary-package")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", n))
+	w.Header().Set("X-Debswarm-Source", downloader.SourceTypeMirror)
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, spool)
+	s.audit.Log(audit.NewDownloadCompleteEvent(expectedHash, path, n, downloader.SourceTypeMirror, 0, 0, n).WithRequest(ctx))
 }
 
 // noteUncachedServe logs, at most once per repository host, that packages from
@@ -2445,20 +3325,61 @@ func (s *Server) serveFromCache(w http.ResponseWriter, hash string) error {
 	return nil
 }
 
+// p2pPaused reports whether the kill switch has paused P2P participation.
+func (s *Server) p2pPaused() bool {
+	return s.p2pNode != nil && s.p2pNode.Paused()
+}
+
 // SetP2PNode sets the P2P node
 func (s *Server) SetP2PNode(node *p2p.Node) {
 	s.p2pNode = node
 	s.scorer = node.Scorer()
 	s.timeouts = node.Timeouts()
+	s.attachNode(node)
+}
 
-	// Set up content getter for serving to peers
-	node.SetContentGetter(func(sha256Hash string) (io.ReadCloser, int64, error) {
-		reader, pkg, err := s.cache.Get(sha256Hash)
-		if err != nil {
-			return nil, 0, err
-		}
-		return reader, pkg.Size, nil
+// attachNode lets a P2P node serve cached packages to its peers
+func (s *Server) attachNode(node *p2p.Node) {
+	node.SetContentProvider(s.peerContent(node))
+	node.SetContentNamer(s.contentName)
+	node.SetTransferRecorder(func(peerID peer.ID, uploaded, downloaded int64) {
+		s.cache.RecordPeerTransfer(peerID.String(), uploaded, downloaded)
 	})
+	node.SetUploadRecorder(s.cache.RecordPackageUpload)
+	node.SetUploadPriority(s.uploadPriority)
+	if s.receipts {
+		node.SetReceiptRecorder(s.storeReceipt)
+	}
+	if s.hooks.HasPreServe() {
+		node.SetUploadGate(s.allowUpload)
+	}
+}
+
+// contentName names a cached package in the headers of uploads to peers.
+func (s *Server) contentName(sha256Hash string) string {
+	pkg, err := s.cache.Info(sha256Hash)
+	if err != nil {
+		return ""
+	}
+	return pkg.Filename
+}
+
+// packageForPeer returns a cached package for a peer. A hash that belongs to
+// another swarm, or of a class that is not shared, reads as "not found", so
+// the peer is told we do not have it.
+func (s *Server) packageForPeer(node *p2p.Node, sha256Hash string) (io.ReadCloser, int64, error) {
+	if !s.servesHash(node, sha256Hash) {
+		return nil, 0, cache.ErrNotFound
+	}
+	reader, pkg, err := s.cache.Get(sha256Hash)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !s.policyForURL(pkg.Filename).Share || !s.repoShares(sha256Hash) {
+		_ = reader.Close()
+		return nil, 0, cache.ErrNotFound
+	}
+	return reader, pkg.Size, nil
 }
 
 // LoadIndex loads a package index from URL
@@ -2466,19 +3387,46 @@ func (s *Server) LoadIndex(url string) error {
 	return s.index.LoadFromURL(url)
 }
 
-// ReannouncePackages announces all cached packages to the DHT
+const (
+	defaultProviderTTL = 24 * time.Hour
+	// minReannounceWait keeps packages that stay due, because their
+	// announcement keeps failing or is refused, from rerunning the
+	// reannounce pass back to back
+	minReannounceWait = 15 * time.Minute
+)
+
+// reannounceMargin is how long before a provider record expires its package
+// is reannounced: early enough that a slow pass finishes in time, late
+// enough that records are not refreshed much more often than they expire
+func reannounceMargin(ttl time.Duration) time.Duration {
+	return min(time.Hour, ttl/4)
+}
+
+// ReannouncePackages announces the cached packages whose provider records
+// have expired or expire within reannounceMargin
 func (s *Server) ReannouncePackages(ctx context.Context) error {
 	if s.p2pNode == nil {
 		return nil
 	}
+	if s.p2pNode.Paused() {
+		s.logger.Debug("Skipping reannounce while P2P is paused")
+		return nil
+	}
 
-	packages, err := s.cache.GetUnannounced()
+	packages, err := s.cache.GetAnnounceDue(time.Now().Add(reannounceMargin(s.providerTTL)))
 	if err != nil {
 		return err
 	}
+	if len(packages) == 0 {
+		return nil
+	}
 
 	s.logger.Info("Reannouncing packages", zap.Int("count", len(packages)))
 
+	// Refreshing existing records is background work: under a provide
+	// budget, announcements of freshly downloaded packages go first.
+	ctx = p2p.WithPriority(ctx, p2p.PriorityLow)
+
 	// Each Provide is a multi-second DHT walk; done one at a time, a cache of
 	// thousands of packages takes hours per reannounce cycle and undermines
 	// the announce interval. Announce with bounded concurrency instead — the
@@ -2488,6 +3436,9 @@ func (s *Server) ReannouncePackages(ctx context.Context) error {
 	var wg sync.WaitGroup
 
 	for _, pkg := range packages {
+		if _, revoked := s.revocations.IsRevoked(pkg.SHA256); revoked {
+			continue
+		}
 		select {
 		case <-ctx.Done():
 			wg.Wait()
@@ -2496,26 +3447,54 @@ func (s *Server) ReannouncePackages(ctx context.Context) error {
 		}
 
 		wg.Add(1)
-		go func(hash string) {
+		go func(pkg *cache.Package) {
 			defer wg.Done()
 			defer func() { <-sem }()
 
-			if err := s.p2pNode.Provide(ctx, hash); err != nil {
+			hash := pkg.SHA256
+			if !s.allowAnnounce(ctx, hash, pkg) {
+				s.metrics.Announcements.WithLabel("refused").Inc()
+				return
+			}
+			node := s.nodeForHash(hash)
+			if node == nil {
+				s.metrics.Announcements.WithLabel("refused").Inc()
+				return
+			}
+			if err := node.Provide(ctx, hash); err != nil {
+				s.metrics.Announcements.WithLabel("failed").Inc()
 				s.logger.Debug("Failed to announce package",
 					zap.String("hash", hash[:16]+"..."),
 					zap.Error(err))
 				return
 			}
-			if err := s.cache.MarkAnnounced(hash); err != nil {
+			s.metrics.Announcements.WithLabel("ok").Inc()
+			if err := s.cache.MarkAnnounced(hash, s.providerTTL); err != nil {
 				s.logger.Warn("Failed to mark as announced", zap.Error(err))
 			}
-		}(pkg.SHA256)
+		}(pkg)
 	}
 
 	wg.Wait()
 	return nil
 }
 
+// NextReannounce returns how long to wait before the next ReannouncePackages
+// run: until the earliest provider record is within reannounceMargin of
+// expiring, at least minReannounceWait and at most maxWait
+func (s *Server) NextReannounce(maxWait time.Duration) time.Duration {
+	due, ok, err := s.cache.NextAnnounceDue()
+	if err != nil {
+		s.logger.Debug("Failed to read next announce due time", zap.Error(err))
+		return maxWait
+	}
+	if !ok {
+		return maxWait
+	}
+	wait := time.Until(due.Add(-reannounceMargin(s.providerTTL)))
+	return min(max(wait, minReannounceWait), maxWait)
+}
+
 // CleanupDownloadState purges failed and abandoned download state rows and
 // orphaned partial-download directories. Failed downloads stop being retried
 // after the retry window, but their state rows and multi-MB partial assembly
@@ -2540,6 +3519,7 @@ func (s *Server) CleanupDownloadState(maxAge time.Duration) {
 	if err != nil {
 		s.logger.Warn("Failed to sweep stale partial downloads", zap.Error(err))
 	} else if n > 0 {
+		s.metrics.PartialDirsRemoved.WithLabel("stale").Add(int64(n))
 		s.logger.Info("Swept stale partial download directories", zap.Int("removed", n))
 	}
 }
@@ -2554,6 +3534,9 @@ func (s *Server) UpdateMetrics() {
 	s.metrics.CacheCount.Set(float64(s.cache.Count()))
 	s.metrics.MetadataCacheSize.Set(float64(s.cache.MetadataSize()))
 
+	if s.scorer != nil {
+		s.metrics.PeersCircuitOpen.Set(float64(s.scorer.OpenBreakers()))
+	}
 	if s.p2pNode != nil {
 		s.metrics.ConnectedPeers.Set(float64(s.p2pNode.ConnectedPeers()))
 		s.metrics.RoutingTableSize.Set(float64(s.p2pNode.RoutingTableSize()))
@@ -2582,7 +3565,6 @@ func (s *Server) UpdateMetrics() {
 func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := requestid.LoggerFromContext(ctx, s.logger)
-	reqID := requestid.FromContext(ctx)
 
 	atomic.AddInt64(&s.connectTotal, 1)
 	s.metrics.ConnectRequestsTotal.Inc()
@@ -2605,14 +3587,30 @@ func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
 		zap.String("target", targetHost),
 		zap.String("remoteAddr", r.RemoteAddr))
 
+	// A tunnel reaches the mirror directly, which offline mode rules out
+	if s.offline() {
+		atomic.AddInt64(&s.connectFailed, 1)
+		s.metrics.ConnectRequestsFailed.Inc()
+		s.writeFailure(w, codeOffline, fmt.Sprintf("debswarm: offline mode: HTTPS tunnels to %s are disabled; use http:// sources so packages can come from the cache and peers", targetHost))
+		return
+	}
+
 	// Security: Validate target against allowed patterns
-	if !security.IsAllowedConnectTargetWithHosts(targetHost, s.allowedHosts) {
+	policy := s.mirrorPolicy.Load()
+	if decision := policy.CheckConnect(targetHost); !decision.Allowed {
 		log.Warn("Blocked CONNECT to non-allowed target",
 			zap.String("target", targetHost),
+			zap.String("reason", decision.Reason),
 			zap.String("remoteAddr", r.RemoteAddr))
 		atomic.AddInt64(&s.connectFailed, 1)
 		s.metrics.ConnectRequestsFailed.Inc()
-		s.audit.Log(audit.NewConnectTunnelBlockedEvent(host, port, "not_allowed").WithRequestID(reqID))
+		s.audit.Log(audit.NewConnectTunnelBlockedEvent(host, port, decision.Reason).WithRequest(ctx))
+		if decision.Reason == security.ReasonPortNotAllowed {
+			http.Error(w, fmt.Sprintf(
+				"debswarm: CONNECT to port %s is not allowed; add it to proxy.allowed_ports in your debswarm config.", port),
+				http.StatusForbidden)
+			return
+		}
 		http.Error(w, fmt.Sprintf(
 			"debswarm: HTTPS repository host %q is not in the allowed list. If this is a legitimate repository, "+
 				"add its host to proxy.allowed_hosts in your debswarm config.", host),
@@ -2632,7 +3630,7 @@ func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
 			zap.Error(err))
 		atomic.AddInt64(&s.connectFailed, 1)
 		s.metrics.ConnectRequestsFailed.Inc()
-		s.audit.Log(audit.NewConnectTunnelBlockedEvent(host, port, err.Error()).WithRequestID(reqID))
+		s.audit.Log(audit.NewConnectTunnelBlockedEvent(host, port, err.Error()).WithRequest(ctx))
 		http.Error(w, "Failed to connect to target", http.StatusBadGateway)
 		return
 	}
@@ -2641,14 +3639,14 @@ func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
 	// The hostname passed validation above, but DNS could resolve to a private IP
 	// between our check and the actual connection.
 	if tcpAddr, ok := targetConn.RemoteAddr().(*net.TCPAddr); ok {
-		if security.IsBlockedIP(tcpAddr.IP) {
+		if policy.BlocksIP(tcpAddr.IP) {
 			_ = targetConn.Close()
 			log.Warn("Blocked CONNECT tunnel - target resolved to private IP (DNS rebinding)",
 				zap.String("target", targetHost),
 				zap.String("resolvedIP", tcpAddr.IP.String()))
 			atomic.AddInt64(&s.connectFailed, 1)
 			s.metrics.ConnectRequestsFailed.Inc()
-			s.audit.Log(audit.NewConnectTunnelBlockedEvent(host, port, "dns_rebinding_blocked").WithRequestID(reqID))
+			s.audit.Log(audit.NewConnectTunnelBlockedEvent(host, port, "dns_rebinding_blocked").WithRequest(ctx))
 			http.Error(w, "CONNECT target resolved to blocked address", http.StatusForbidden)
 			return
 		}
@@ -2687,7 +3685,7 @@ func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Audit log tunnel start
-	s.audit.Log(audit.NewConnectTunnelStartEvent(host, port).WithRequestID(reqID))
+	s.audit.Log(audit.NewConnectTunnelStartEvent(host, port).WithRequest(ctx))
 
 	// Track active tunnels
 	atomic.AddInt64(&s.activeTunnels, 1)
@@ -2708,7 +3706,7 @@ func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
 	s.metrics.TunnelDuration.Observe(duration.Seconds())
 
 	// Audit log tunnel end
-	s.audit.Log(audit.NewConnectTunnelEndEvent(host, port, bytesIn+bytesOut, duration.Milliseconds()).WithRequestID(reqID))
+	s.audit.Log(audit.NewConnectTunnelEndEvent(host, port, bytesIn+bytesOut, duration.Milliseconds()).WithRequest(ctx))
 
 	log.Debug("CONNECT tunnel closed",
 		zap.String("target", targetHost),
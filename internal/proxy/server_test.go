@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
 	"net/http"
 	"net/http/httptest"
@@ -10,11 +11,14 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/debswarm/debswarm/internal/audit"
 	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/hashutil"
 	"github.com/debswarm/debswarm/internal/index"
 	"github.com/debswarm/debswarm/internal/metrics"
 	"github.com/debswarm/debswarm/internal/mirror"
 	"github.com/debswarm/debswarm/internal/peers"
+	"github.com/debswarm/debswarm/internal/security"
 	"github.com/debswarm/debswarm/internal/timeouts"
 )
 
@@ -351,6 +355,56 @@ func TestExtractTargetURL_BlockedURLs(t *testing.T) {
 	}
 }
 
+func TestHandleRequest_TraceContext(t *testing.T) {
+	server := newTestServer(t)
+	rec := &recordingAudit{}
+	server.audit = rec
+
+	req := httptest.NewRequest("GET", "/http://10.20.1.5/debian/dists/stable/Release", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	server.handleRequest(httptest.NewRecorder(), req)
+	if len(rec.events) != 1 || rec.events[0].TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("audit events = %+v, want the trace ID", rec.events)
+	}
+
+	rec.events = nil
+	req = httptest.NewRequest("GET", "/http://10.20.1.5/debian/dists/stable/Release", nil)
+	req.Header.Set("traceparent", "not a trace")
+	server.handleRequest(httptest.NewRecorder(), req)
+	if len(rec.events) != 1 || rec.events[0].TraceID != "" {
+		t.Errorf("audit events = %+v, want no trace ID", rec.events)
+	}
+}
+
+func TestSetMirrorPolicy(t *testing.T) {
+	server := newTestServer(t)
+	rec := &recordingAudit{}
+	server.audit = rec
+	target := "http://10.20.1.5:8080/debian/dists/stable/Release"
+
+	w := httptest.NewRecorder()
+	server.handleRequest(w, httptest.NewRequest("GET", "/"+target, nil))
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "proxy.allowed_cidrs") {
+		t.Errorf("internal mirror: status %d, body %q", w.Code, w.Body.String())
+	}
+	if len(rec.events) != 1 || rec.events[0].EventType != audit.EventMirrorBlocked ||
+		rec.events[0].Reason != security.ReasonBlockedAddress || rec.events[0].TargetHost != "10.20.1.5" {
+		t.Errorf("audit events = %+v, want one mirror_blocked for 10.20.1.5", rec.events)
+	}
+
+	policy, err := security.NewMirrorPolicy(security.PolicyConfig{AllowedCIDRs: []string{"10.20.0.0/16"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetMirrorPolicy(policy)
+	if !server.isAllowedMirrorURL(target) {
+		t.Errorf("isAllowedMirrorURL(%q) = false after allowing 10.20.0.0/16", target)
+	}
+	if server.isAllowedMirrorURL("http://10.30.1.5/debian/dists/stable/Release") {
+		t.Error("isAllowedMirrorURL(10.30.1.5) = true, want false outside the allowed range")
+	}
+}
+
 func TestHandleRequest_InvalidRequest(t *testing.T) {
 	server := newTestServer(t)
 
@@ -483,6 +537,37 @@ func TestReannouncePackages_NoNode(t *testing.T) {
 	}
 }
 
+func TestNextReannounce(t *testing.T) {
+	pkgCache := newTestCache(t)
+	srv := serverWith(t, pkgCache, index.New(t.TempDir(), newTestLogger()))
+	defer shutdownServer(t, srv)
+	maxWait := 12 * time.Hour
+
+	if got := srv.NextReannounce(maxWait); got != maxWait {
+		t.Errorf("empty cache: NextReannounce = %v, want %v", got, maxWait)
+	}
+
+	data := []byte("announced package")
+	hash := hashutil.HashBytes(data)
+	if err := pkgCache.Put(bytes.NewReader(data), hash, "pkg.deb"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if got := srv.NextReannounce(maxWait); got != minReannounceWait {
+		t.Errorf("never announced: NextReannounce = %v, want %v", got, minReannounceWait)
+	}
+
+	if err := pkgCache.MarkAnnounced(hash, 4*time.Hour); err != nil {
+		t.Fatalf("MarkAnnounced failed: %v", err)
+	}
+	// 4h record, reannounced an hour before it expires
+	if got := srv.NextReannounce(maxWait); got < 2*time.Hour+58*time.Minute || got > 3*time.Hour {
+		t.Errorf("NextReannounce = %v, want about 3h", got)
+	}
+	if got := srv.NextReannounce(time.Hour); got != time.Hour {
+		t.Errorf("capped: NextReannounce = %v, want 1h", got)
+	}
+}
+
 func TestAnnounceAsync_NoNode(t *testing.T) {
 	server := newTestServer(t)
 
@@ -623,8 +708,11 @@ func TestHandleIndexRequest_Error(t *testing.T) {
 
 	server.handleIndexRequest(w, req, mockMirror.URL+"/Packages")
 
-	if w.Code != http.StatusBadGateway {
-		t.Errorf("Status = %d, want %d", w.Code, http.StatusBadGateway)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if got := w.Header().Get(errorHeader); got != codeMirrorNotFound {
+		t.Errorf("%s = %q, want %q", errorHeader, got, codeMirrorNotFound)
 	}
 }
 
@@ -884,8 +972,8 @@ func TestHandlePackageRequest_MirrorError(t *testing.T) {
 
 	server.handlePackageRequest(w, req, mockMirror.URL+"/nonexistent.deb")
 
-	if w.Code != http.StatusBadGateway {
-		t.Errorf("Status = %d, want %d", w.Code, http.StatusBadGateway)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusNotFound)
 	}
 }
 
@@ -983,7 +1071,7 @@ func TestCacheAndAnnounce(t *testing.T) {
 	testHash := "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72"
 
 	// Should not panic without p2p node
-	server.cacheAndAnnounce(testData, testHash, "test.deb")
+	server.cacheAndAnnounce(testData, testHash, "test.deb", "peer")
 
 	// Verify data was cached
 	if !server.cache.Has(testHash) {
@@ -997,7 +1085,6 @@ func TestGetDashboardStats_WithCacheMaxSize(t *testing.T) {
 		P2PTimeout:     5 * time.Second,
 		DHTLookupLimit: 10,
 		MetricsPort:    0,
-		CacheMaxSize:   1024 * 1024 * 100, // 100MB
 		Metrics:        metrics.New(),
 		Timeouts:       timeouts.NewManager(nil),
 		Scorer:         peers.NewScorer(),
@@ -0,0 +1,296 @@
+package proxy
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/connectivity"
+	"github.com/debswarm/debswarm/internal/sanitize"
+)
+
+// A simulation answers "what would debswarm do" for a list of request URLs,
+// such as the packages of an APT log, from the node's current state: its
+// configuration, cache, metadata cache and package indices. Nothing is
+// fetched, looked up on the DHT or stored, so the same state always gives
+// the same answer. Requests are simulated in order, and a package or file
+// an earlier request would have fetched counts as cached for later ones.
+
+// maxSimulateURLs bounds the requests of one simulation.
+const maxSimulateURLs = 100000
+
+// Simulated outcomes, in the order the totals list them
+const (
+	// OutcomeCache is served from the cache without asking anyone
+	OutcomeCache = "cache"
+	// OutcomeRevalidate is served from the cache after a conditional
+	// request to the mirror, or fetched again if the file changed
+	OutcomeRevalidate = "revalidate"
+	// OutcomeP2P is looked up on the DHT and fetched from peers, falling
+	// back to the mirror (unless offline) if none has it
+	OutcomeP2P = "p2p"
+	// OutcomeMirror is fetched from the mirror
+	OutcomeMirror = "mirror"
+	// OutcomeRefused is refused
+	OutcomeRefused = "refused"
+)
+
+var simulationOutcomes = []string{OutcomeCache, OutcomeRevalidate, OutcomeP2P, OutcomeMirror, OutcomeRefused}
+
+// SimulateRequest is the body of POST /api/simulate.
+type SimulateRequest struct {
+	URLs []string `json:"urls"`
+}
+
+// Simulation is the response of POST /api/simulate.
+type Simulation struct {
+	// Offline reports whether mirror fetches are disabled; P2P, whether
+	// peers would be asked at all
+	Offline bool `json:"offline"`
+	P2P     bool `json:"p2p"`
+	// Totals has one entry per outcome, in a fixed order
+	Totals   []SimulationTotal  `json:"totals"`
+	Requests []SimulatedRequest `json:"requests"`
+}
+
+// SimulationTotal counts the requests with one outcome. Bytes covers the
+// requests whose size is known: packages from the index, and cached files.
+type SimulationTotal struct {
+	Outcome  string `json:"outcome"`
+	Requests int    `json:"requests"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// SimulatedRequest is what the proxy would do with one request.
+type SimulatedRequest struct {
+	URL     string `json:"url"`
+	Class   string `json:"class,omitempty"`
+	Outcome string `json:"outcome"`
+	Reason  string `json:"reason"`
+	// Size in bytes, 0 if unknown
+	Size int64 `json:"size,omitempty"`
+}
+
+// POST /api/simulate
+func (s *Server) handleAPISimulate(w http.ResponseWriter, r *http.Request) {
+	var req SimulateRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if len(req.URLs) == 0 {
+		writeError(w, http.StatusBadRequest, "no URLs to simulate")
+		return
+	}
+	if len(req.URLs) > maxSimulateURLs {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("too many URLs (%d, at most %d)", len(req.URLs), maxSimulateURLs))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.simulate(req.URLs))
+}
+
+// simulation tracks what the requests simulated so far would have cached.
+type simulation struct {
+	packages map[string]bool // by hash
+	metadata map[string]bool // by URL
+}
+
+// simulate reports what the proxy would do with each of urls, in order.
+func (s *Server) simulate(urls []string) *Simulation {
+	sim := &simulation{packages: make(map[string]bool), metadata: make(map[string]bool)}
+	out := &Simulation{
+		Offline:  s.offline(),
+		P2P:      s.p2pNode != nil && !s.p2pPaused(),
+		Requests: make([]SimulatedRequest, 0, len(urls)),
+	}
+	totals := make(map[string]*SimulationTotal, len(simulationOutcomes))
+	for _, outcome := range simulationOutcomes {
+		out.Totals = append(out.Totals, SimulationTotal{Outcome: outcome})
+	}
+	for i := range out.Totals {
+		totals[out.Totals[i].Outcome] = &out.Totals[i]
+	}
+	for _, raw := range urls {
+		req := s.simulateURL(sim, raw)
+		t := totals[req.Outcome]
+		t.Requests++
+		t.Bytes += req.Size
+		out.Requests = append(out.Requests, req)
+	}
+	return out
+}
+
+// simulateURL follows the decisions of handleRequest for one URL.
+func (s *Server) simulateURL(sim *simulation, rawURL string) SimulatedRequest {
+	req := SimulatedRequest{URL: sanitize.URL(rawURL)}
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		req.Outcome, req.Reason = OutcomeRefused, "not an http:// or https:// URL"
+		return req
+	}
+	if decision := s.mirrorPolicy.Load().CheckURL(rawURL); !decision.Allowed {
+		req.Outcome, req.Reason = OutcomeRefused, "refused by the mirror policy: "+decision.Reason
+		return req
+	}
+	class, handler := classifyURL(rawURL)
+	req.Class = string(class)
+	if handler == requestTypePackage {
+		s.simulatePackage(sim, &req, rawURL)
+	} else {
+		s.simulateMetadata(sim, &req, rawURL, handler == requestTypeIndex)
+	}
+	return req
+}
+
+// simulatePackage follows handlePackageRequest and serveVerifiedPackage.
+func (s *Server) simulatePackage(sim *simulation, req *SimulatedRequest, rawURL string) {
+	pkg := s.index.GetByURLPath(rawURL)
+	if pkg == nil {
+		s.warmIndexFromCacheOnce()
+		pkg = s.index.GetByURLPath(rawURL)
+	}
+	if pkg != nil {
+		if _, err := hex.DecodeString(pkg.SHA256); err != nil || len(pkg.SHA256) != 64 {
+			pkg = nil
+		}
+	}
+	if pkg != nil {
+		req.Size = pkg.Size
+	}
+
+	if !s.policyForURL(rawURL).Cache {
+		switch {
+		case s.offline():
+			req.Outcome, req.Reason = OutcomeRefused, "packages of this class are not cached, and mirror fetches are disabled"
+		case pkg == nil && s.hashRequired && !s.hashExempt(rawURL):
+			req.Outcome, req.Reason = OutcomeRefused, "no SHA256 in any loaded index, and hash_required forbids serving it unverified"
+		default:
+			req.Outcome, req.Reason = OutcomeMirror, "packages of this class are not cached: streamed from the mirror"
+		}
+		return
+	}
+	if pkg == nil {
+		switch {
+		case s.hashRequired && !s.hashExempt(rawURL):
+			req.Outcome, req.Reason = OutcomeRefused, "no SHA256 in any loaded index, and hash_required forbids serving it unverified"
+		case s.offline():
+			req.Outcome, req.Reason = OutcomeRefused, "no SHA256 in any loaded index, and mirror fetches are disabled"
+		default:
+			req.Outcome, req.Reason = OutcomeMirror, "no SHA256 in any loaded index: streamed from the mirror unverified, not cached or shared"
+		}
+		return
+	}
+
+	if reason, revoked := s.revocations.IsRevoked(pkg.SHA256); revoked {
+		req.Outcome, req.Reason = OutcomeRefused, "revoked"
+		if reason != "" {
+			req.Reason += ": " + reason
+		}
+		return
+	}
+	if s.scanner != nil && s.cache.Quarantined(pkg.SHA256) {
+		req.Outcome, req.Reason = OutcomeRefused, "quarantined by the malware scanner"
+		return
+	}
+	if s.cache.Has(pkg.SHA256) {
+		req.Outcome, req.Reason = OutcomeCache, "cached"
+		return
+	}
+	if sim.packages[pkg.SHA256] {
+		req.Outcome, req.Reason = OutcomeCache, "cached by an earlier request"
+		return
+	}
+	if s.connectivity != nil && s.connectivity.GetMode() == connectivity.ModeOffline {
+		req.Outcome, req.Reason = OutcomeRefused, "not cached, and the node has neither internet access nor LAN peers"
+		return
+	}
+
+	share := s.policyForURL(rawURL).Share
+	peers := share && s.nodeForRepo(pkg.Repo) != nil && !s.p2pPaused()
+	switch {
+	case peers && s.offline():
+		req.Outcome, req.Reason = OutcomeP2P, "not cached: fetched from peers, with no mirror fallback (offline mode)"
+	case peers:
+		req.Outcome, req.Reason = OutcomeP2P, "not cached: fetched from peers, or the mirror if none has it"
+	case s.offline():
+		req.Outcome, req.Reason = OutcomeRefused, "not cached, peers cannot be asked, and mirror fetches are disabled"
+		return
+	case !share:
+		req.Outcome, req.Reason = OutcomeMirror, "not cached, and packages of this repository or class are not shared"
+	case s.p2pPaused():
+		req.Outcome, req.Reason = OutcomeMirror, "not cached, and P2P is paused"
+	default:
+		req.Outcome, req.Reason = OutcomeMirror, "not cached, and P2P is not running"
+	}
+	sim.packages[pkg.SHA256] = true
+}
+
+// simulateMetadata follows serveMetadata.
+func (s *Server) simulateMetadata(sim *simulation, req *SimulatedRequest, rawURL string, isIndex bool) {
+	caching := s.cache != nil && s.cache.MetadataEnabled() && s.policyForURL(rawURL).Cache
+	var entry *cache.MetadataEntry
+	if caching {
+		if e, rc, err := s.cache.GetMetadata(rawURL); err == nil {
+			_ = rc.Close()
+			entry = e
+			req.Size = e.Size
+		}
+	}
+	simulated := caching && sim.metadata[rawURL]
+
+	if entry != nil && cache.IsImmutableMetadataURL(rawURL) {
+		req.Outcome, req.Reason = OutcomeCache, "cached, and by-hash files never change"
+		return
+	}
+	if caching && isIndex && s.policyForURL(rawURL).Share {
+		// Only a Release verified already counts: finding one may take a
+		// mirror request, which a simulation does not make
+		if hash, size, ok := s.listedIndexHashIn(rawURL, s.releaseStore.get); ok {
+			req.Size = size
+			switch {
+			case entry != nil && entry.SHA256 == hash:
+				req.Outcome, req.Reason = OutcomeCache, "cached, and still the copy the signed Release lists"
+				return
+			case simulated:
+				req.Outcome, req.Reason = OutcomeCache, "cached by an earlier request"
+				return
+			case s.nodeForURL(rawURL) != nil && !s.p2pPaused() && !s.offline():
+				req.Outcome, req.Reason = OutcomeP2P, "fetched from peers by the hash the signed Release lists, or the mirror if none has it"
+				sim.metadata[rawURL] = true
+				return
+			}
+		}
+	}
+	if entry != nil && passthroughTTLClass(artifactClass(req.Class)) && entry.IsFresh() {
+		req.Outcome, req.Reason = OutcomeCache, "cached, and fresh until "+entry.FreshUntil.UTC().Format("2006-01-02 15:04:05Z")
+		return
+	}
+	if s.offline() {
+		if entry != nil || simulated {
+			req.Outcome, req.Reason = OutcomeCache, "cached copy served however old (offline mode)"
+		} else {
+			req.Outcome, req.Reason = OutcomeRefused, "not cached, and mirror fetches are disabled"
+		}
+		return
+	}
+	if s.connectivity != nil && s.connectivity.GetMode() == connectivity.ModeOffline {
+		if (entry != nil || simulated) && s.metadataServeStale {
+			req.Outcome, req.Reason = OutcomeCache, "cached copy served stale: the node has no internet access"
+		} else {
+			req.Outcome, req.Reason = OutcomeRefused, "not cached, and the node has no internet access"
+		}
+		return
+	}
+	switch {
+	case entry != nil || simulated:
+		req.Outcome, req.Reason = OutcomeRevalidate, "cached: revalidated with a conditional request to the mirror"
+	case caching:
+		req.Outcome, req.Reason = OutcomeMirror, "not cached: fetched from the mirror and cached"
+		sim.metadata[rawURL] = true
+	default:
+		req.Outcome, req.Reason = OutcomeMirror, "metadata of this class is not cached: passed through from the mirror"
+	}
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	server := newTestServer(t)
+	defer shutdownServer(t, server)
+	server.cache.SetMetadataMaxSize(1 * 1024 * 1024)
+
+	cached, uncached := []byte("cached package"), []byte("uncached package")
+	packages := "Package: cached\nVersion: 1.0\nArchitecture: amd64\nFilename: pool/main/c/cached/cached_1.0_amd64.deb\n" +
+		"Size: 14\nSHA256: " + sha256Hex(cached) + "\n\n" +
+		"Package: uncached\nVersion: 1.0\nArchitecture: amd64\nFilename: pool/main/u/uncached/uncached_1.0_amd64.deb\n" +
+		"Size: 16\nSHA256: " + sha256Hex(uncached) + "\n\n"
+	if err := server.index.LoadFromData([]byte(packages), "http://deb.debian.org/debian/dists/stable/main/binary-amd64/Packages"); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.cache.Put(strings.NewReader(string(cached)), sha256Hex(cached), "pool/main/c/cached/cached_1.0_amd64.deb"); err != nil {
+		t.Fatal(err)
+	}
+
+	const mirror = "http://deb.debian.org/debian/"
+	urls := []string{
+		mirror + "dists/stable/InRelease",
+		mirror + "pool/main/c/cached/cached_1.0_amd64.deb",
+		mirror + "pool/main/u/uncached/uncached_1.0_amd64.deb",
+		mirror + "pool/main/u/uncached/uncached_1.0_amd64.deb",
+		mirror + "pool/main/n/nope/nope_1.0_amd64.deb",
+		mirror + "dists/stable/InRelease",
+		"http://127.0.0.1:8080/debian/pool/main/x/x/x_1.0_amd64.deb",
+		"not a url",
+	}
+	want := []struct{ outcome, reason string }{
+		{OutcomeMirror, "not cached"},
+		{OutcomeCache, "cached"},
+		{OutcomeMirror, "P2P is not running"},
+		{OutcomeCache, "earlier request"},
+		{OutcomeMirror, "no SHA256"},
+		{OutcomeRevalidate, "conditional request"},
+		{OutcomeRefused, "mirror policy"},
+		{OutcomeRefused, "not an http"},
+	}
+
+	body, _ := json.Marshal(SimulateRequest{URLs: urls})
+	w := httptest.NewRecorder()
+	server.handleAPISimulate(w, httptest.NewRequest("POST", "/api/simulate", strings.NewReader(string(body))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var sim Simulation
+	if err := json.Unmarshal(w.Body.Bytes(), &sim); err != nil {
+		t.Fatal(err)
+	}
+	if len(sim.Requests) != len(urls) {
+		t.Fatalf("%d results for %d URLs", len(sim.Requests), len(urls))
+	}
+	for i, req := range sim.Requests {
+		if req.Outcome != want[i].outcome || !strings.Contains(req.Reason, want[i].reason) {
+			t.Errorf("%s: %s (%s), want %s (%s)", urls[i], req.Outcome, req.Reason, want[i].outcome, want[i].reason)
+		}
+	}
+	wantTotals := map[string][2]int64{
+		OutcomeCache:      {2, 14 + 16},
+		OutcomeRevalidate: {1, 0},
+		OutcomeP2P:        {0, 0},
+		OutcomeMirror:     {3, 16},
+		OutcomeRefused:    {2, 0},
+	}
+	for i, total := range sim.Totals {
+		if total.Outcome != simulationOutcomes[i] {
+			t.Errorf("totals[%d] is %s, want %s", i, total.Outcome, simulationOutcomes[i])
+		}
+		if w := wantTotals[total.Outcome]; int64(total.Requests) != w[0] || total.Bytes != w[1] {
+			t.Errorf("%s total = %+v, want %d requests, %d bytes", total.Outcome, total, w[0], w[1])
+		}
+	}
+
+	// Offline, what is not cached cannot be served
+	server.fetcher.SetOffline(true)
+	result := server.simulate(urls[2:3])
+	if !result.Offline || result.Requests[0].Outcome != OutcomeRefused {
+		t.Errorf("offline: %+v", result)
+	}
+}
+
+func TestSimulateAPI_Validates(t *testing.T) {
+	server := newTestServer(t)
+	defer shutdownServer(t, server)
+
+	for _, body := range []string{`{"urls": []}`, `not json`} {
+		w := httptest.NewRecorder()
+		server.handleAPISimulate(w, httptest.NewRequest("POST", "/api/simulate", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d", body, w.Code)
+		}
+	}
+}
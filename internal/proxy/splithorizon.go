@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/debswarm/debswarm/internal/downloader"
+)
+
+// Split-horizon operation is how most offices want a fleet to behave: a
+// package some machine on the LAN already has should come from it, not
+// cross the WAN link again. With it enabled, LAN providers get the first
+// attempt at a package on their own; WAN peers and the mirror join only when
+// the LAN cannot deliver it within the budget. Chunks the LAN did deliver
+// are kept (see downloader resume), so the fallback only fetches the rest.
+
+// SplitHorizonConfig enables LAN-first downloads.
+type SplitHorizonConfig struct {
+	// Deadline is how long LAN peers get on their own
+	Deadline time.Duration
+	// MinLANRate extends the deadline for large packages to the time they
+	// take at this rate, in bytes per second (0 = deadline only)
+	MinLANRate int64
+}
+
+// budget returns how long LAN peers get for a package of size bytes.
+func (c *SplitHorizonConfig) budget(size int64) time.Duration {
+	d := c.Deadline
+	if c.MinLANRate > 0 {
+		d = max(d, time.Duration(float64(size)/float64(c.MinLANRate)*float64(time.Second)))
+	}
+	return d
+}
+
+// lanSources returns the sources that are LAN peers.
+func (s *Server) lanSources(sources []downloader.Source) []downloader.Source {
+	var lan []downloader.Source
+	for _, src := range sources {
+		if ps, ok := src.(*downloader.PeerSource); ok && s.scorer.IsLANPeer(ps.Info) {
+			lan = append(lan, src)
+		}
+	}
+	return lan
+}
+
+// downloadLANFirst gives the LAN peers among peerSources the first attempt
+// at a package, within the split-horizon budget. It returns nil when split
+// horizon is off, when no LAN peer has the package, or when there is nothing
+// to hold back (every source is a LAN peer). An error means the LAN could
+// not deliver in time and the caller should carry on with every source.
+func (s *Server) downloadLANFirst(ctx context.Context, expectedHash string, expectedSize int64, peerSources []downloader.Source, withMirror bool) (*downloader.DownloadResult, error) {
+	if s.splitHorizon == nil || expectedSize <= 0 {
+		return nil, nil
+	}
+	lan := s.lanSources(peerSources)
+	if len(lan) == 0 || (len(lan) == len(peerSources) && !withMirror) {
+		return nil, nil
+	}
+
+	lanCtx, cancel := context.WithTimeout(ctx, s.splitHorizon.budget(expectedSize))
+	defer cancel()
+	result, err := s.downloader.Download(lanCtx, expectedHash, expectedSize, lan, nil)
+	if err != nil {
+		s.metrics.SplitHorizonDownloads.WithLabel("fallback").Inc()
+		return nil, err
+	}
+	s.metrics.SplitHorizonDownloads.WithLabel("lan").Inc()
+	return result, nil
+}
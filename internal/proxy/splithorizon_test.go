@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/debswarm/debswarm/internal/downloader"
+	"github.com/debswarm/debswarm/internal/hashutil"
+	"github.com/debswarm/debswarm/internal/index"
+)
+
+// peerSourceAt is a peer source at ip that serves data, or stalls until
+// its context ends when data is nil.
+func peerSourceAt(t *testing.T, id, ip string, data []byte) *downloader.PeerSource {
+	t.Helper()
+	ma, err := multiaddr.NewMultiaddr("/ip4/" + ip + "/tcp/4001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &downloader.PeerSource{
+		Info: peer.AddrInfo{ID: peer.ID(id), Addrs: []multiaddr.Multiaddr{ma}},
+		Downloader: func(ctx context.Context, _ peer.AddrInfo, _ string, start, end int64) ([]byte, error) {
+			if data == nil {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			if end <= 0 || end > int64(len(data)) {
+				end = int64(len(data))
+			}
+			return data[start:end], nil
+		},
+	}
+}
+
+func TestSplitHorizonBudget(t *testing.T) {
+	c := &SplitHorizonConfig{Deadline: 5 * time.Second}
+	if got := c.budget(1 << 30); got != 5*time.Second {
+		t.Errorf("deadline-only budget = %v", got)
+	}
+	c.MinLANRate = 10 << 20
+	if got := c.budget(1 << 20); got != 5*time.Second {
+		t.Errorf("small package budget = %v, want the deadline", got)
+	}
+	if got := c.budget(100 << 20); got != 10*time.Second {
+		t.Errorf("100MB budget = %v, want 10s at 10MB/s", got)
+	}
+}
+
+func TestDownloadLANFirst(t *testing.T) {
+	payload := []byte("a package some machine on the LAN already has")
+	hash := hashutil.HashBytes(payload)
+	size := int64(len(payload))
+
+	srv := serverWith(t, newTestCache(t), index.New(t.TempDir(), newTestLogger()))
+	defer shutdownServer(t, srv)
+	ctx := context.Background()
+
+	lan := peerSourceAt(t, "lan", "192.168.1.20", payload)
+	wan := peerSourceAt(t, "wan", "203.0.113.7", payload)
+	stalled := peerSourceAt(t, "stalled", "10.0.0.9", nil)
+
+	// Disabled
+	if result, err := srv.downloadLANFirst(ctx, hash, size, []downloader.Source{lan, wan}, true); result != nil || err != nil {
+		t.Fatalf("disabled = %v, %v", result, err)
+	}
+
+	srv.splitHorizon = &SplitHorizonConfig{Deadline: 200 * time.Millisecond}
+
+	// Nothing to hold back: no LAN peer, or only LAN peers and no mirror
+	if result, err := srv.downloadLANFirst(ctx, hash, size, []downloader.Source{wan}, true); result != nil || err != nil {
+		t.Errorf("WAN only = %v, %v", result, err)
+	}
+	if result, err := srv.downloadLANFirst(ctx, hash, size, []downloader.Source{lan}, false); result != nil || err != nil {
+		t.Errorf("LAN only, no mirror = %v, %v", result, err)
+	}
+
+	result, err := srv.downloadLANFirst(ctx, hash, size, []downloader.Source{wan, lan}, true)
+	if err != nil || result == nil || result.PeerBytes != size {
+		t.Fatalf("LAN download = %+v, %v", result, err)
+	}
+	if got := srv.metrics.SplitHorizonDownloads.WithLabel("lan").Value(); got != 1 {
+		t.Errorf("lan downloads = %d, want 1", got)
+	}
+
+	// A LAN peer that cannot deliver in budget gives way
+	started := time.Now()
+	if _, err := srv.downloadLANFirst(ctx, hash, size, []downloader.Source{wan, stalled}, true); err == nil {
+		t.Fatal("stalled LAN peer should fail the LAN attempt")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("LAN attempt took %v, budget is 200ms", elapsed)
+	}
+	if got := srv.metrics.SplitHorizonDownloads.WithLabel("fallback").Value(); got != 1 {
+		t.Errorf("fallbacks = %d, want 1", got)
+	}
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"strings"
+
+	"github.com/debswarm/debswarm/internal/index"
+	"github.com/debswarm/debswarm/internal/p2p"
+)
+
+// Swarm is an additional swarm the daemon is a member of through a second
+// P2P node. Packages from its origins are looked up, announced and served
+// there; all other packages stay in the main swarm.
+type Swarm struct {
+	Name string
+	Node *p2p.Node
+
+	// Origins are repository prefixes, "host" or "host/path" without a
+	// scheme, matched against the repository recorded in the package index.
+	Origins []string
+}
+
+// AddSwarm joins an additional swarm. Call before Start, after SetP2PNode.
+func (s *Server) AddSwarm(sw Swarm) {
+	s.swarms = append(s.swarms, sw)
+	s.attachNode(sw.Node)
+}
+
+// nodeForRepo returns the P2P node for packages from a repository
+func (s *Server) nodeForRepo(repo string) *p2p.Node {
+	for _, sw := range s.swarms {
+		if originMatches(repo, sw.Origins) {
+			return sw.Node
+		}
+	}
+	return s.p2pNode
+}
+
+// nodeForURL returns the P2P node for the package at url
+func (s *Server) nodeForURL(url string) *p2p.Node {
+	if len(s.swarms) == 0 {
+		return s.p2pNode
+	}
+	return s.nodeForRepo(index.ExtractRepoFromURL(url))
+}
+
+// nodeForHash returns the P2P node for a cached package, going by its
+// origin (see originForHash). With additional swarms, a package whose
+// origin is unknown could come from a private repository, so it gets no
+// node: it is neither announced nor served in any swarm.
+func (s *Server) nodeForHash(hash string) *p2p.Node {
+	if len(s.swarms) == 0 {
+		return s.p2pNode
+	}
+	origin := s.originForHash(hash)
+	if origin == "" {
+		return nil
+	}
+	return s.nodeForRepo(origin)
+}
+
+// servesHash reports whether node may upload a package, so a package from
+// one swarm's origins is never served to another swarm's peers.
+func (s *Server) servesHash(node *p2p.Node, hash string) bool {
+	if len(s.swarms) == 0 {
+		return true
+	}
+	return node != nil && s.nodeForHash(hash) == node
+}
+
+// allNodes returns the main P2P node followed by every additional swarm's
+func (s *Server) allNodes() []*p2p.Node {
+	nodes := make([]*p2p.Node, 0, 1+len(s.swarms))
+	if s.p2pNode != nil {
+		nodes = append(nodes, s.p2pNode)
+	}
+	for _, sw := range s.swarms {
+		nodes = append(nodes, sw.Node)
+	}
+	return nodes
+}
+
+// originMatches reports whether repo equals one of origins or lies under it
+func originMatches(repo string, origins []string) bool {
+	repo = strings.ToLower(strings.TrimSuffix(repo, "/"))
+	for _, origin := range origins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		if origin != "" && (repo == origin || strings.HasPrefix(repo, origin+"/")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/cache"
+	"github.com/debswarm/debswarm/internal/p2p"
+)
+
+func TestOriginMatches(t *testing.T) {
+	origins := []string{"apt.corp.example", "mirror.example/internal/"}
+	tests := []struct {
+		repo string
+		want bool
+	}{
+		{"apt.corp.example", true},
+		{"apt.corp.example/debian", true},
+		{"APT.corp.example/debian", true},
+		{"apt.corp.example.evil", false},
+		{"mirror.example/internal", true},
+		{"mirror.example/internal/debian", true},
+		{"mirror.example/internal-other", false},
+		{"mirror.example/debian", false},
+		{"deb.debian.org/debian", false},
+	}
+	for _, tt := range tests {
+		if got := originMatches(tt.repo, origins); got != tt.want {
+			t.Errorf("originMatches(%q) = %v, want %v", tt.repo, got, tt.want)
+		}
+	}
+}
+
+func TestSwarmRouting(t *testing.T) {
+	server := newTestServer(t)
+	defer shutdownServer(t, server)
+
+	main, corp := &p2p.Node{}, &p2p.Node{}
+	server.p2pNode = main
+	server.swarms = []Swarm{{Name: "corp", Node: corp, Origins: []string{"apt.corp.example"}}}
+
+	corpHash := "aa" + fmt.Sprintf("%062d", 1)
+	debianHash := "bb" + fmt.Sprintf("%062d", 2)
+	for repo, hash := range map[string]string{"apt.corp.example": corpHash, "deb.debian.org/debian": debianHash} {
+		packages := fmt.Sprintf("Package: p\nVersion: 1\nArchitecture: amd64\nFilename: pool/main/p/p_1_amd64.deb\nSize: 1\nSHA256: %s\n\n", hash)
+		if err := server.index.LoadFromData([]byte(packages), "http://"+repo+"/dists/stable/main/binary-amd64/Packages"); err != nil {
+			t.Fatalf("LoadFromData: %v", err)
+		}
+	}
+
+	if got := server.nodeForURL("http://apt.corp.example/pool/main/p/p_1_amd64.deb"); got != corp {
+		t.Error("corp package URL not routed to the corp swarm")
+	}
+	if got := server.nodeForURL("http://deb.debian.org/debian/pool/main/p/p_1_amd64.deb"); got != main {
+		t.Error("Debian package URL not routed to the main swarm")
+	}
+	if server.nodeForHash(corpHash) != corp || server.nodeForHash(debianHash) != main {
+		t.Error("cached packages not routed by their index origin")
+	}
+
+	// A package the index does not know is routed by the origin recorded in
+	// the cache, and one of unknown origin is kept out of every swarm
+	const content = "corp package"
+	cachedHash := sha256Hex([]byte(content))
+	if err := server.cache.Put(strings.NewReader(content), cachedHash, "corp.deb"); err != nil {
+		t.Fatal(err)
+	}
+	if server.nodeForHash(cachedHash) != nil || server.servesHash(main, cachedHash) || server.servesHash(corp, cachedHash) {
+		t.Error("package of unknown origin must be neither announced nor served")
+	}
+	if err := server.cache.SetOrigin(cachedHash, cache.Origin{Repo: "apt.corp.example/debian"}); err != nil {
+		t.Fatal(err)
+	}
+	if server.nodeForHash(cachedHash) != corp || !server.servesHash(corp, cachedHash) || server.servesHash(main, cachedHash) {
+		t.Error("package not in the index not routed by its cached origin")
+	}
+
+	if !server.servesHash(corp, corpHash) || server.servesHash(main, corpHash) {
+		t.Error("corp package must be served only to the corp swarm")
+	}
+	if !server.servesHash(main, debianHash) || server.servesHash(corp, debianHash) {
+		t.Error("Debian package must be served only to the main swarm")
+	}
+	if nodes := server.allNodes(); len(nodes) != 2 || nodes[0] != main || nodes[1] != corp {
+		t.Errorf("allNodes = %v", nodes)
+	}
+}
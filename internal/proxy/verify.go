@@ -134,6 +134,24 @@ func byHashDigest(rawURL string) string {
 	return strings.ToLower(rawURL[i+len(marker):])
 }
 
+// byHashSHA256 returns the SHA256 of the file an Acquire-By-Hash URL names,
+// whichever digest it is named by: the URL's own for .../by-hash/SHA256/<hex>,
+// and for SHA512, SHA1 or MD5Sum the SHA256 rel lists for the same file, ""
+// if rel lists none. Mapping every digest to the SHA256 keeps peers sharing
+// one copy however clients ask for it. byHash is false for other URLs.
+func byHashSHA256(rel *release.Release, rawURL string) (digest string, byHash bool) {
+	i := strings.Index(rawURL, "/by-hash/")
+	if i < 0 {
+		return "", false
+	}
+	algorithm, digest, ok := strings.Cut(rawURL[i+len("/by-hash/"):], "/")
+	if !ok || digest == "" || strings.Contains(digest, "/") {
+		return "", false
+	}
+	canonical, _ := rel.CanonicalSHA256(algorithm, digest)
+	return canonical, true
+}
+
 // verificationEnabled reports whether any verification work runs (mode != off).
 func (s *Server) verificationEnabled() bool {
 	return s.verifyMode == verifyWarn || s.verifyMode == verifyAuto || s.verifyMode == verifyEnforce
@@ -184,7 +202,7 @@ func (s *Server) isExemptHost(rawURL string) bool {
 // dist. It returns (true, "") when the index's SHA256 is vouched for by a
 // signature-verified Release, else (false, reason).
 func (s *Server) verifyIndex(rawURL string, data []byte) (bool, string) {
-	if s.keyring == nil || s.keyring.Empty() {
+	if s.keyringFor(rawURL).Empty() {
 		return false, verifyReasonNoKey
 	}
 	// dist-layout repos anchor on "/dists/<suite>/"; flat-layout repos (no dists/
@@ -207,6 +225,15 @@ func (s *Server) verifyIndex(rawURL string, data []byte) (bool, string) {
 		}
 		return false, verifyReasonHashMismatch
 	}
+	// By another digest: the bytes must have the SHA256 the Release lists for
+	// the file of that digest, as nothing else checks them
+	if digest, byHash := byHashSHA256(rel, rawURL); byHash {
+		sum := sha256.Sum256(data)
+		if digest == "" || hex.EncodeToString(sum[:]) != digest {
+			return false, verifyReasonHashMismatch
+		}
+		return true, ""
+	}
 
 	// Plain path: the Release must list this file, and its bytes must hash to the
 	// listed value.
@@ -241,11 +268,11 @@ func (s *Server) obtainRelease(base string) *release.Release {
 		return r
 	}
 	if s.cache != nil && s.cache.MetadataEnabled() {
-		if rel := s.verifiedInRelease(s.readCachedMetadataBody(base + "InRelease")); rel != nil {
+		if rel := s.verifiedInRelease(base, s.readCachedMetadataBody(base+"InRelease")); rel != nil {
 			s.releaseStore.put(base, rel)
 			return rel
 		}
-		if rel := s.verifiedDetachedRelease(s.readCachedMetadataBody(base+"Release"), s.readCachedMetadataBody(base+"Release.gpg")); rel != nil {
+		if rel := s.verifiedDetachedRelease(base, s.readCachedMetadataBody(base+"Release"), s.readCachedMetadataBody(base+"Release.gpg")); rel != nil {
 			s.releaseStore.put(base, rel)
 			return rel
 		}
@@ -256,13 +283,13 @@ func (s *Server) obtainRelease(base string) *release.Release {
 	return nil
 }
 
-// verifiedInRelease verifies a clearsigned InRelease body against the keyring and
-// returns the parsed Release, or nil on any failure (including a nil body).
-func (s *Server) verifiedInRelease(body []byte) *release.Release {
+// verifiedInRelease verifies a clearsigned InRelease body against the keyring for
+// base and returns the parsed Release, or nil on any failure (including a nil body).
+func (s *Server) verifiedInRelease(base string, body []byte) *release.Release {
 	if body == nil {
 		return nil
 	}
-	verified, err := s.keyring.VerifyClearsigned(body)
+	verified, err := s.keyringFor(base).VerifyClearsigned(body)
 	if err != nil {
 		return nil
 	}
@@ -273,13 +300,13 @@ func (s *Server) verifiedInRelease(body []byte) *release.Release {
 	return rel
 }
 
-// verifiedDetachedRelease verifies a Release body against its detached Release.gpg
-// signature and returns the parsed Release, or nil on any failure.
-func (s *Server) verifiedDetachedRelease(body, sig []byte) *release.Release {
+// verifiedDetachedRelease verifies a Release body for base against its detached
+// Release.gpg signature and returns the parsed Release, or nil on any failure.
+func (s *Server) verifiedDetachedRelease(base string, body, sig []byte) *release.Release {
 	if body == nil || sig == nil {
 		return nil
 	}
-	if err := s.keyring.VerifyDetached(body, sig); err != nil {
+	if err := s.keyringFor(base).VerifyDetached(body, sig); err != nil {
 		return nil
 	}
 	rel, err := release.Parse(body)
@@ -303,7 +330,9 @@ func (s *Server) fetchReleaseOnDemand(base string) *release.Release {
 			return nil
 		}
 	}
-	v, _, _ := s.releaseFetch.Do(base, func() (interface{}, error) {
+	led := false
+	v, _, shared := s.releaseFetch.Do(base, func() (interface{}, error) {
+		led = true
 		// A concurrent caller may have populated the store while we waited.
 		if r := s.releaseStore.get(base); r != nil {
 			return r, nil
@@ -311,14 +340,14 @@ func (s *Server) fetchReleaseOnDemand(base string) *release.Release {
 		ctx, cancel := context.WithTimeout(context.Background(), releaseFetchTimeout)
 		defer cancel()
 
-		if rel := s.verifiedInRelease(s.fetchMetadataBytes(ctx, base+"InRelease")); rel != nil {
+		if rel := s.verifiedInRelease(base, s.fetchMetadataBytes(ctx, base+"InRelease")); rel != nil {
 			s.releaseStore.put(base, rel)
 			s.releaseFetchFailed.Delete(base)
 			return rel, nil
 		}
 		body := s.fetchMetadataBytes(ctx, base+"Release")
 		sig := s.fetchMetadataBytes(ctx, base+"Release.gpg")
-		if rel := s.verifiedDetachedRelease(body, sig); rel != nil {
+		if rel := s.verifiedDetachedRelease(base, body, sig); rel != nil {
 			s.releaseStore.put(base, rel)
 			s.releaseFetchFailed.Delete(base)
 			return rel, nil
@@ -326,6 +355,9 @@ func (s *Server) fetchReleaseOnDemand(base string) *release.Release {
 		s.releaseFetchFailed.Store(base, time.Now())
 		return (*release.Release)(nil), nil
 	})
+	if shared && !led {
+		s.metrics.CoalescedRequests.WithLabel("release").Inc()
+	}
 	rel, _ := v.(*release.Release)
 	return rel
 }
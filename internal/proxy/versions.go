@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"sort"
+
+	"github.com/debswarm/debswarm/internal/dashboard"
+	"github.com/debswarm/debswarm/internal/updatecheck"
+)
+
+// SetUpdateChecker shows the latest release found by c on the dashboard.
+func (s *Server) SetUpdateChecker(c *updatecheck.Checker) {
+	s.updates = c
+}
+
+// peerVersionShares returns the debswarm versions connected peers run, most
+// common first.
+func (s *Server) peerVersionShares() []dashboard.VersionShare {
+	if s.p2pNode == nil {
+		return nil
+	}
+	return versionShares(s.p2pNode.PeerVersions())
+}
+
+// versionShares turns peer counts by version into shares, most common first
+// and newest first among equals.
+func versionShares(counts map[string]int) []dashboard.VersionShare {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	if total == 0 {
+		return nil
+	}
+	shares := make([]dashboard.VersionShare, 0, len(counts))
+	for v, n := range counts {
+		shares = append(shares, dashboard.VersionShare{
+			Version: v,
+			Peers:   n,
+			Percent: float64(n) * 100 / float64(total),
+		})
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Peers != shares[j].Peers {
+			return shares[i].Peers > shares[j].Peers
+		}
+		if updatecheck.Newer(shares[i].Version, shares[j].Version) {
+			return true
+		}
+		if updatecheck.Newer(shares[j].Version, shares[i].Version) {
+			return false
+		}
+		return shares[i].Version < shares[j].Version
+	})
+	return shares
+}
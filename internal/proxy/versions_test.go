@@ -0,0 +1,23 @@
+package proxy
+
+import "testing"
+
+func TestVersionShares(t *testing.T) {
+	if got := versionShares(map[string]int{}); got != nil {
+		t.Errorf("no peers = %+v, want nil", got)
+	}
+
+	got := versionShares(map[string]int{"1.9.0": 1, "1.10.0": 1, "1.11.0": 8, "unknown": 2})
+	want := []string{"1.11.0", "unknown", "1.10.0", "1.9.0"}
+	if len(got) != len(want) {
+		t.Fatalf("shares = %+v", got)
+	}
+	for i, v := range want {
+		if got[i].Version != v {
+			t.Errorf("share %d = %s, want %s", i, got[i].Version, v)
+		}
+	}
+	if got[0].Peers != 8 || got[0].Percent < 66.6 || got[0].Percent > 66.7 {
+		t.Errorf("top share = %+v", got[0])
+	}
+}
@@ -4,35 +4,48 @@ package ratelimit
 import (
 	"context"
 	"io"
+	"time"
 
 	"golang.org/x/time/rate"
 )
 
+// Options tune a limiter's token bucket.
+type Options struct {
+	// Burst is the bucket size in bytes: how much can be sent at full speed
+	// after an idle spell before the rate applies. 0 = one second's worth of
+	// the rate, between 64KB and 4MB.
+	Burst int64
+
+	// SmallObjectSize lets transfers of at most this many bytes through
+	// without waiting (0 = none). They still draw tokens, so they count
+	// toward the rate and delay the bulk transfers that follow, but a small
+	// package is never held up behind a large one.
+	SmallObjectSize int64
+}
+
 // Limiter provides rate-limited readers and writers
 type Limiter struct {
 	limiter *rate.Limiter
 	enabled bool
+	opts    Options
 }
 
-// New creates a new rate limiter.
+// New creates a new rate limiter with the default burst.
 // bytesPerSecond of 0 or negative means unlimited.
 func New(bytesPerSecond int64) *Limiter {
-	if bytesPerSecond <= 0 {
-		return &Limiter{enabled: false}
-	}
+	return NewWithOptions(bytesPerSecond, Options{})
+}
 
-	// Use burst size of 64KB or 1 second worth, whichever is larger
-	burst := bytesPerSecond
-	if burst < 64*1024 {
-		burst = 64 * 1024
-	}
-	if burst > 4*1024*1024 {
-		burst = 4 * 1024 * 1024 // Cap at 4MB burst
+// NewWithOptions creates a new rate limiter with the given bucket options.
+// bytesPerSecond of 0 or negative means unlimited.
+func NewWithOptions(bytesPerSecond int64, opts Options) *Limiter {
+	if bytesPerSecond <= 0 {
+		return &Limiter{enabled: false, opts: opts}
 	}
-
 	return &Limiter{
-		limiter: rate.NewLimiter(rate.Limit(bytesPerSecond), int(burst)),
+		limiter: rate.NewLimiter(rate.Limit(bytesPerSecond), int(burstFor(bytesPerSecond, opts.Burst))),
 		enabled: true,
+		opts:    opts,
 	}
 }
 
@@ -41,6 +54,35 @@ func (l *Limiter) Enabled() bool {
 	return l != nil && l.enabled
 }
 
+// Rate returns the configured rate in bytes per second, or 0 when unlimited.
+func (l *Limiter) Rate() int64 {
+	if !l.Enabled() {
+		return 0
+	}
+	return int64(l.limiter.Limit())
+}
+
+// State is a snapshot of a limiter's settings and bucket
+type State struct {
+	Enabled bool
+	Rate    int64   // bytes per second, 0 when unlimited
+	Burst   int     // bucket size in bytes
+	Tokens  float64 // bytes available now; negative while callers wait
+}
+
+// State returns the limiter's current settings and available tokens.
+func (l *Limiter) State() State {
+	if !l.Enabled() {
+		return State{}
+	}
+	return State{
+		Enabled: true,
+		Rate:    int64(l.limiter.Limit()),
+		Burst:   l.limiter.Burst(),
+		Tokens:  l.limiter.Tokens(),
+	}
+}
+
 // UpdateRate changes the rate limit dynamically.
 // bytesPerSecond of 0 or negative disables rate limiting.
 func (l *Limiter) UpdateRate(bytesPerSecond int64) {
@@ -52,14 +94,7 @@ func (l *Limiter) UpdateRate(bytesPerSecond int64) {
 		return
 	}
 
-	burst := bytesPerSecond
-	if burst < 64*1024 {
-		burst = 64 * 1024
-	}
-	if burst > 4*1024*1024 {
-		burst = 4 * 1024 * 1024
-	}
-
+	burst := burstFor(bytesPerSecond, l.opts.Burst)
 	if l.limiter == nil {
 		l.limiter = rate.NewLimiter(rate.Limit(bytesPerSecond), int(burst))
 	} else {
@@ -69,6 +104,12 @@ func (l *Limiter) UpdateRate(bytesPerSecond int64) {
 	l.enabled = true
 }
 
+// exempt reports whether a transfer of size bytes is small enough to skip
+// waiting. A negative size means unknown.
+func (l *Limiter) exempt(size int64) bool {
+	return size >= 0 && size <= l.opts.SmallObjectSize
+}
+
 // Reader returns a rate-limited reader
 func (l *Limiter) Reader(r io.Reader) io.Reader {
 	if !l.Enabled() {
@@ -83,6 +124,12 @@ func (l *Limiter) Reader(r io.Reader) io.Reader {
 
 // ReaderContext returns a rate-limited reader with context
 func (l *Limiter) ReaderContext(ctx context.Context, r io.Reader) io.Reader {
+	return l.ReaderContextSize(ctx, r, -1)
+}
+
+// ReaderContextSize returns a rate-limited reader for a transfer of size
+// bytes (-1 if unknown). A small object is read without waiting.
+func (l *Limiter) ReaderContextSize(ctx context.Context, r io.Reader, size int64) io.Reader {
 	if !l.Enabled() {
 		return r
 	}
@@ -90,6 +137,7 @@ func (l *Limiter) ReaderContext(ctx context.Context, r io.Reader) io.Reader {
 		r:       r,
 		limiter: l.limiter,
 		ctx:     ctx,
+		noWait:  l.exempt(size),
 	}
 }
 
@@ -107,6 +155,12 @@ func (l *Limiter) Writer(w io.Writer) io.Writer {
 
 // WriterContext returns a rate-limited writer with context
 func (l *Limiter) WriterContext(ctx context.Context, w io.Writer) io.Writer {
+	return l.WriterContextSize(ctx, w, -1)
+}
+
+// WriterContextSize returns a rate-limited writer for a transfer of size
+// bytes (-1 if unknown). A small object is written without waiting.
+func (l *Limiter) WriterContextSize(ctx context.Context, w io.Writer, size int64) io.Writer {
 	if !l.Enabled() {
 		return w
 	}
@@ -114,6 +168,7 @@ func (l *Limiter) WriterContext(ctx context.Context, w io.Writer) io.Writer {
 		w:       w,
 		limiter: l.limiter,
 		ctx:     ctx,
+		noWait:  l.exempt(size),
 	}
 }
 
@@ -122,13 +177,16 @@ type LimitedReader struct {
 	r       io.Reader
 	limiter *rate.Limiter
 	ctx     context.Context
+	noWait  bool // small object: draw tokens without waiting
 }
 
 // Read implements io.Reader with rate limiting.
 // Splits large reads into burst-sized waits to avoid panicking when n > burst.
 func (lr *LimitedReader) Read(p []byte) (n int, err error) {
 	n, err = lr.r.Read(p)
-	if n > 0 {
+	if n > 0 && lr.noWait {
+		drawN(n, lr.limiter)
+	} else if n > 0 {
 		// Split into burst-sized waits to avoid WaitN panic when n > burst
 		burst := lr.limiter.Burst()
 		remaining := n
@@ -151,11 +209,16 @@ type LimitedWriter struct {
 	w       io.Writer
 	limiter *rate.Limiter
 	ctx     context.Context
+	noWait  bool // small object: draw tokens without waiting
 }
 
 // Write implements io.Writer with rate limiting.
 // Splits large writes into burst-sized chunks to avoid WaitN panic when len(p) > burst.
 func (lw *LimitedWriter) Write(p []byte) (n int, err error) {
+	if lw.noWait {
+		drawN(len(p), lw.limiter)
+		return lw.w.Write(p)
+	}
 	burst := lw.limiter.Burst()
 	for n < len(p) {
 		// Determine chunk size (at most burst)
@@ -177,3 +240,37 @@ func (lw *LimitedWriter) Write(p []byte) (n int, err error) {
 	}
 	return n, nil
 }
+
+// burstFor returns the bucket size for a rate: the configured burst, or one
+// second's worth of the rate between 64KB and 4MB
+func burstFor(bytesPerSecond, configured int64) int64 {
+	if configured > 0 {
+		return configured
+	}
+	burst := bytesPerSecond
+	if burst < 64*1024 {
+		burst = 64 * 1024
+	}
+	if burst > 4*1024*1024 {
+		burst = 4 * 1024 * 1024 // Cap at 4MB burst
+	}
+	return burst
+}
+
+// drawN takes n tokens from each non-nil limiter without waiting, leaving
+// the bucket in debt if it runs short. Draws are split into burst-sized
+// reservations, since a reservation larger than the burst is refused.
+func drawN(n int, limiters ...*rate.Limiter) {
+	now := time.Now()
+	for _, l := range limiters {
+		if l == nil {
+			continue
+		}
+		burst := l.Burst()
+		for remaining := n; remaining > 0; {
+			take := min(remaining, burst)
+			l.ReserveN(now, take)
+			remaining -= take
+		}
+	}
+}
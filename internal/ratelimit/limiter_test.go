@@ -333,3 +333,81 @@ func TestNew_BurstCalculation(t *testing.T) {
 		})
 	}
 }
+
+func TestLimiter_Rate(t *testing.T) {
+	if got := New(0).Rate(); got != 0 {
+		t.Errorf("unlimited Rate() = %d, want 0", got)
+	}
+	l := New(1000)
+	if got := l.Rate(); got != 1000 {
+		t.Errorf("Rate() = %d, want 1000", got)
+	}
+	l.UpdateRate(5000)
+	if got := l.Rate(); got != 5000 {
+		t.Errorf("Rate() after update = %d, want 5000", got)
+	}
+	l.UpdateRate(0)
+	if got := l.Rate(); got != 0 {
+		t.Errorf("Rate() after disable = %d, want 0", got)
+	}
+}
+
+func TestLimiter_State(t *testing.T) {
+	if st := New(0).State(); st.Enabled || st.Rate != 0 {
+		t.Errorf("unlimited state = %+v", st)
+	}
+	var nilLimiter *Limiter
+	if st := nilLimiter.State(); st.Enabled {
+		t.Errorf("nil limiter state = %+v", st)
+	}
+
+	st := New(1024 * 1024).State()
+	if !st.Enabled || st.Rate != 1024*1024 || st.Burst != 1024*1024 {
+		t.Errorf("limited state = %+v", st)
+	}
+	if st.Tokens != float64(st.Burst) {
+		t.Errorf("fresh bucket has %v tokens, want %d", st.Tokens, st.Burst)
+	}
+}
+
+func TestLimiter_Burst(t *testing.T) {
+	l := NewWithOptions(1000, Options{Burst: 1 << 20})
+	if st := l.State(); st.Burst != 1<<20 {
+		t.Errorf("configured burst = %d, want %d", st.Burst, 1<<20)
+	}
+	// A rate change keeps the configured burst
+	l.UpdateRate(5000)
+	if st := l.State(); st.Burst != 1<<20 || st.Rate != 5000 {
+		t.Errorf("state after update = %+v", st)
+	}
+	if got := New(1000).State().Burst; got != 64*1024 {
+		t.Errorf("default burst = %d, want 64KB", got)
+	}
+}
+
+func TestLimiter_SmallObject(t *testing.T) {
+	const size = 128 * 1024 // twice the bucket: ~64s of waiting at 1KB/s
+	l := NewWithOptions(1024, Options{SmallObjectSize: size})
+	data := bytes.Repeat([]byte("x"), size)
+
+	start := time.Now()
+	var buf bytes.Buffer
+	if _, err := l.WriterContextSize(context.Background(), &buf, size).Write(data); err != nil {
+		t.Fatalf("small object write: %v", err)
+	}
+	if _, err := io.ReadAll(l.ReaderContextSize(context.Background(), bytes.NewReader(data), size)); err != nil {
+		t.Fatalf("small object read: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("small objects took %v, want no waiting", elapsed)
+	}
+	// ...but they were charged, so bulk transfers now wait
+	if tokens := l.State().Tokens; tokens >= 0 {
+		t.Errorf("tokens after small objects = %v, want debt", tokens)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.WriterContextSize(ctx, &buf, size+1).Write(data[:1024]); err == nil {
+		t.Error("bulk write after small objects should wait")
+	}
+}
@@ -56,6 +56,14 @@ type PeerLimiterConfig struct {
 	// AdaptiveRecalcInterval is how often to recalculate adaptive rates
 	AdaptiveRecalcInterval time.Duration
 
+	// Burst is the per-peer bucket size in bytes, 0 = one second's worth
+	// of the peer's rate between 64KB and 4MB
+	Burst int64
+
+	// SmallObjectSize lets transfers of at most this many bytes through both
+	// limiters without waiting (0 = none); see Options.SmallObjectSize
+	SmallObjectSize int64
+
 	// Logger for debug output
 	Logger *zap.Logger
 }
@@ -97,6 +105,8 @@ type PeerLimiterManager struct {
 	latencyThresh  float64
 	idleTimeout    time.Duration
 	recalcInterval time.Duration
+	burst          int64
+	smallObject    int64
 
 	// Dependencies
 	globalLimiter   *Limiter
@@ -150,6 +160,8 @@ func NewPeerLimiterManager(cfg PeerLimiterConfig, globalLimiter *Limiter, scorer
 		latencyThresh:   cfg.LatencyThresholdMs,
 		idleTimeout:     idleTimeout,
 		recalcInterval:  recalcInterval,
+		burst:           cfg.Burst,
+		smallObject:     cfg.SmallObjectSize,
 		globalLimiter:   globalLimiter,
 		scorer:          scorer,
 		adaptiveEnabled: cfg.AdaptiveEnabled && scorer != nil,
@@ -209,6 +221,9 @@ func (m *PeerLimiterManager) GetLimiter(peerID peer.ID) *rate.Limiter {
 
 	// Create limiter with appropriate burst
 	burst := calculateBurst(limit)
+	if m.burst > 0 {
+		burst = m.burst
+	}
 	limiter := rate.NewLimiter(rate.Limit(limit), int(burst))
 
 	pl = &PeerLimiter{
@@ -229,6 +244,12 @@ func (m *PeerLimiterManager) GetLimiter(peerID peer.ID) *rate.Limiter {
 
 // ReaderContext returns a rate-limited reader that applies both global and per-peer limits
 func (m *PeerLimiterManager) ReaderContext(ctx context.Context, peerID peer.ID, r io.Reader) io.Reader {
+	return m.ReaderContextSize(ctx, peerID, r, -1)
+}
+
+// ReaderContextSize is ReaderContext for a transfer of size bytes (-1 if
+// unknown). A small object is read without waiting.
+func (m *PeerLimiterManager) ReaderContextSize(ctx context.Context, peerID peer.ID, r io.Reader, size int64) io.Reader {
 	peerLimiter := m.GetLimiter(peerID)
 
 	// Get global limiter if available
@@ -247,11 +268,18 @@ func (m *PeerLimiterManager) ReaderContext(ctx context.Context, peerID peer.ID,
 		globalLim: globalLim,
 		peerLim:   peerLimiter,
 		ctx:       ctx,
+		noWait:    m.exempt(size),
 	}
 }
 
 // WriterContext returns a rate-limited writer that applies both global and per-peer limits
 func (m *PeerLimiterManager) WriterContext(ctx context.Context, peerID peer.ID, w io.Writer) io.Writer {
+	return m.WriterContextSize(ctx, peerID, w, -1)
+}
+
+// WriterContextSize is WriterContext for a transfer of size bytes (-1 if
+// unknown). A small object is written without waiting.
+func (m *PeerLimiterManager) WriterContextSize(ctx context.Context, peerID peer.ID, w io.Writer, size int64) io.Writer {
 	peerLimiter := m.GetLimiter(peerID)
 
 	// Get global limiter if available
@@ -270,9 +298,16 @@ func (m *PeerLimiterManager) WriterContext(ctx context.Context, peerID peer.ID,
 		globalLim: globalLim,
 		peerLim:   peerLimiter,
 		ctx:       ctx,
+		noWait:    m.exempt(size),
 	}
 }
 
+// exempt reports whether a transfer of size bytes is small enough to skip
+// waiting. A negative size means unknown.
+func (m *PeerLimiterManager) exempt(size int64) bool {
+	return size >= 0 && size <= m.smallObject
+}
+
 // calculatePeerLimit calculates the rate limit for a specific peer
 func (m *PeerLimiterManager) calculatePeerLimit(peerID peer.ID) int64 {
 	baseLimit := m.perPeerLimit
@@ -394,6 +429,35 @@ func (m *PeerLimiterManager) GetPeerStats(peerID peer.ID) (currentLimit int64, b
 	return pl.currentLimit, pl.baseLimit, true
 }
 
+// PeerState is a snapshot of one peer's limiter
+type PeerState struct {
+	PeerID       peer.ID
+	CurrentLimit int64
+	BaseLimit    int64
+	Tokens       float64
+	LastAccess   time.Time
+}
+
+// Snapshot returns the state of every active peer limiter
+func (m *PeerLimiterManager) Snapshot() []PeerState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	states := make([]PeerState, 0, len(m.peerLimiters))
+	for id, pl := range m.peerLimiters {
+		pl.mu.Lock()
+		states = append(states, PeerState{
+			PeerID:       id,
+			CurrentLimit: pl.currentLimit,
+			BaseLimit:    pl.baseLimit,
+			Tokens:       pl.limiter.Tokens(),
+			LastAccess:   pl.lastAccess,
+		})
+		pl.mu.Unlock()
+	}
+	return states
+}
+
 // PeerCount returns the number of active peer limiters
 func (m *PeerLimiterManager) PeerCount() int {
 	m.mu.RLock()
@@ -412,12 +476,15 @@ type ComposedLimitedReader struct {
 	globalLim *rate.Limiter
 	peerLim   *rate.Limiter
 	ctx       context.Context
+	noWait    bool // small object: draw tokens without waiting
 }
 
 // Read implements io.Reader with composed rate limiting
 func (cr *ComposedLimitedReader) Read(p []byte) (n int, err error) {
 	n, err = cr.r.Read(p)
-	if n > 0 {
+	if n > 0 && cr.noWait {
+		drawN(n, cr.globalLim, cr.peerLim)
+	} else if n > 0 {
 		// Wait for BOTH limiters (the stricter one dominates), splitting into
 		// burst-sized waits so neither limiter is asked for more than its burst
 		// (rate.WaitN errors when n exceeds a finite limiter's burst).
@@ -434,6 +501,7 @@ type ComposedLimitedWriter struct {
 	globalLim *rate.Limiter
 	peerLim   *rate.Limiter
 	ctx       context.Context
+	noWait    bool // small object: draw tokens without waiting
 }
 
 // Write implements io.Writer with composed rate limiting.
@@ -441,6 +509,10 @@ type ComposedLimitedWriter struct {
 // neither limiter is asked for more than its burst — rate.WaitN errors when
 // len(p) exceeds a finite limiter's burst.
 func (cw *ComposedLimitedWriter) Write(p []byte) (n int, err error) {
+	if cw.noWait {
+		drawN(len(p), cw.globalLim, cw.peerLim)
+		return cw.w.Write(p)
+	}
 	burst := composedBurst(cw.globalLim, cw.peerLim)
 	for n < len(p) {
 		end := len(p)
@@ -529,3 +529,64 @@ func TestDefaultPeerLimiterConfig(t *testing.T) {
 		t.Error("AdaptiveEnabled should be true by default")
 	}
 }
+
+func TestPeerLimiterManager_Snapshot(t *testing.T) {
+	cfg := PeerLimiterConfig{PerPeerLimit: 1024 * 1024}
+	mgr := NewPeerLimiterManager(cfg, nil, nil)
+	defer mgr.Close()
+
+	if got := mgr.Snapshot(); len(got) != 0 {
+		t.Errorf("empty manager snapshot = %+v", got)
+	}
+	mgr.GetLimiter(mockPeerID("peer-a"))
+	mgr.GetLimiter(mockPeerID("peer-b"))
+
+	states := mgr.Snapshot()
+	if len(states) != 2 {
+		t.Fatalf("snapshot has %d peers, want 2", len(states))
+	}
+	for _, st := range states {
+		if st.CurrentLimit != 1024*1024 || st.BaseLimit != 1024*1024 || st.LastAccess.IsZero() {
+			t.Errorf("peer state = %+v", st)
+		}
+	}
+}
+
+func TestPeerLimiterManager_BurstAndSmallObject(t *testing.T) {
+	global := NewWithOptions(1024, Options{SmallObjectSize: 32 * 1024})
+	mgr := NewPeerLimiterManager(PeerLimiterConfig{
+		PerPeerLimit:    1024,
+		Burst:           8 * 1024,
+		SmallObjectSize: 32 * 1024,
+	}, global, nil)
+	defer mgr.Close()
+	peerID := mockPeerID("small-object-peer")
+
+	if got := mgr.GetLimiter(peerID).Burst(); got != 8*1024 {
+		t.Errorf("per-peer burst = %d, want 8KB", got)
+	}
+
+	// 32KB is four per-peer buckets: ~24s of waiting at 1KB/s
+	data := bytes.Repeat([]byte("x"), 32*1024)
+	start := time.Now()
+	var buf bytes.Buffer
+	if _, err := mgr.WriterContextSize(context.Background(), peerID, &buf, int64(len(data))).Write(data); err != nil {
+		t.Fatalf("small object write: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("small object took %v, want no waiting", elapsed)
+	}
+	if tokens := mgr.GetLimiter(peerID).Tokens(); tokens >= 0 {
+		t.Errorf("per-peer tokens = %v, want debt", tokens)
+	}
+	// The global bucket holds 64KB, so the draw leaves it half full
+	if tokens := global.State().Tokens; tokens > 33*1024 {
+		t.Errorf("global tokens = %v, want the small object charged", tokens)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := io.ReadAll(mgr.ReaderContext(ctx, peerID, bytes.NewReader(data[:1024]))); err == nil {
+		t.Error("read of unknown size after a small object should wait")
+	}
+}
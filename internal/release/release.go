@@ -39,19 +39,53 @@ type Release struct {
 	// SHA256 maps a dist-relative index path (e.g. "main/binary-amd64/Packages.gz")
 	// to its listed hash and size.
 	SHA256 map[string]FileHash
-	// hashSet holds every SHA256 value listed, for O(1) by-hash membership checks
-	// (an Acquire-By-Hash URL carries the file's hash directly).
-	hashSet map[string]struct{}
+	// hashSizes maps every SHA256 value listed to its size, for O(1) by-hash
+	// lookups (an Acquire-By-Hash URL carries the file's hash directly).
+	hashSizes map[string]int64
+	// equivalents maps "<section>:<digest>" of the MD5Sum, SHA1 and SHA512
+	// sections to the SHA256 listed for the same file
+	equivalents map[string]string
+}
+
+// alternateSections are the hash sections listed besides SHA256, whose
+// digests also name files in Acquire-By-Hash URLs
+var alternateSections = []string{"MD5Sum", "SHA1", "SHA512"}
+
+// CanonicalSHA256 returns the SHA256 the Release lists for the file it lists
+// with the given digest of algorithm, an Acquire-By-Hash directory name
+// ("SHA256", "SHA512", "SHA1" or "MD5Sum", any case). A file fetched by one
+// digest is the same content as by another, so peers share it under its
+// SHA256 whichever digest a client asked with. ok is false when the Release
+// does not list the digest, or lists no SHA256 for its file.
+func (r *Release) CanonicalSHA256(algorithm, digest string) (string, bool) {
+	digest = strings.ToLower(digest)
+	if strings.EqualFold(algorithm, "SHA256") {
+		return digest, r.HasHash(digest)
+	}
+	for _, section := range alternateSections {
+		if strings.EqualFold(algorithm, section) {
+			h, ok := r.equivalents[section+":"+digest]
+			return h, ok
+		}
+	}
+	return "", false
 }
 
 // HasHash reports whether the given hex SHA256 is listed anywhere in the Release.
 // A by-hash index URL (/by-hash/SHA256/<hex>) is verified iff its digest is a
 // hash the signed Release vouches for.
 func (r *Release) HasHash(hexSHA256 string) bool {
-	_, ok := r.hashSet[strings.ToLower(hexSHA256)]
+	_, ok := r.hashSizes[strings.ToLower(hexSHA256)]
 	return ok
 }
 
+// SizeOf returns the size the Release lists for a file with the given hex
+// SHA256, and whether it lists one at all.
+func (r *Release) SizeOf(hexSHA256 string) (int64, bool) {
+	size, ok := r.hashSizes[strings.ToLower(hexSHA256)]
+	return size, ok
+}
+
 // releaseTimeLayouts are the formats seen in Release "Valid-Until"/"Date" fields.
 var releaseTimeLayouts = []string{
 	"Mon, 02 Jan 2006 15:04:05 MST",
@@ -66,41 +100,54 @@ var ErrNoSHA256 = errors.New("release: no SHA256 section found")
 // Parse parses a Release body (or the verified plaintext of an InRelease). It
 // returns ErrNoSHA256 if the body carries no SHA256 section.
 func Parse(body []byte) (*Release, error) {
-	r := &Release{SHA256: make(map[string]FileHash), hashSet: make(map[string]struct{})}
+	r := &Release{SHA256: make(map[string]FileHash), hashSizes: make(map[string]int64), equivalents: make(map[string]string)}
+	// alternates collects the digests of the other sections by path, to be
+	// matched with the SHA256 entries once all sections are read
+	alternates := make(map[string]map[string]string)
 
 	sc := bufio.NewScanner(bytes.NewReader(body))
 	sc.Buffer(make([]byte, 0, 64*1024), maxReleaseSize)
 
-	inSHA256 := false
+	section := ""
 	for sc.Scan() {
 		line := sc.Text()
 		if line == "" {
 			continue
 		}
 
-		// Indented lines are entries of the current hash section. Only the SHA256
-		// section is collected; MD5Sum/SHA1/SHA512 entries fall through ignored.
+		// Indented lines are entries of the current hash section. The SHA256
+		// section is what files are verified against; the MD5Sum, SHA1 and
+		// SHA512 digests only name the same files.
 		if line[0] == ' ' || line[0] == '\t' {
-			if inSHA256 {
-				if f := strings.Fields(line); len(f) >= 3 {
-					size, _ := strconv.ParseInt(f[1], 10, 64)
-					h := strings.ToLower(f[0])
-					r.SHA256[f[2]] = FileHash{SHA256: h, Size: size}
-					r.hashSet[h] = struct{}{}
+			f := strings.Fields(line)
+			if len(f) < 3 {
+				continue
+			}
+			switch section {
+			case "":
+			case "SHA256":
+				size, _ := strconv.ParseInt(f[1], 10, 64)
+				h := strings.ToLower(f[0])
+				r.SHA256[f[2]] = FileHash{SHA256: h, Size: size}
+				r.hashSizes[h] = size
+			default:
+				if alternates[section] == nil {
+					alternates[section] = make(map[string]string)
 				}
+				alternates[section][f[2]] = strings.ToLower(f[0])
 			}
 			continue
 		}
 
 		// A top-level line ends any hash section and sets a "Key: value" field.
-		inSHA256 = false
+		section = ""
 		key, val, ok := splitField(line)
 		if !ok {
 			continue
 		}
 		switch key {
-		case "SHA256":
-			inSHA256 = true // the following indented lines are SHA256 entries
+		case "SHA256", "MD5Sum", "SHA1", "SHA512":
+			section = key // the following indented lines are its entries
 		case "Origin":
 			r.Origin = val
 		case "Suite":
@@ -121,6 +168,13 @@ func Parse(body []byte) (*Release, error) {
 	if len(r.SHA256) == 0 {
 		return nil, ErrNoSHA256
 	}
+	for section, digests := range alternates {
+		for path, digest := range digests {
+			if fh, ok := r.SHA256[path]; ok {
+				r.equivalents[section+":"+digest] = fh.SHA256
+			}
+		}
+	}
 	return r, nil
 }
 
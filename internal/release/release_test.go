@@ -49,6 +49,12 @@ func TestParse_FieldsAndHashes(t *testing.T) {
 	if gz := r.SHA256["main/binary-amd64/Packages.gz"]; gz.Size != 3000 {
 		t.Fatalf("Packages.gz size = %d, want 3000", gz.Size)
 	}
+	if size, ok := r.SizeOf(strings.Repeat("A", 64)); !ok || size != 8000 {
+		t.Fatalf("SizeOf(Packages hash) = %d, %v; want 8000, true", size, ok)
+	}
+	if _, ok := r.SizeOf(strings.Repeat("f", 64)); ok {
+		t.Fatal("SizeOf found a hash the Release does not list")
+	}
 	// MD5Sum entries must NOT leak into the SHA256 map.
 	for path, fh := range r.SHA256 {
 		if len(fh.SHA256) != 64 {
@@ -98,3 +104,35 @@ func TestParse_MalformedHashLineSkipped(t *testing.T) {
 		t.Fatalf("SHA256 entries = %d, want 1 (malformed line skipped)", len(r.SHA256))
 	}
 }
+
+func TestCanonicalSHA256(t *testing.T) {
+	body := sampleRelease + `SHA512:
+ ` + strings.Repeat("e", 128) + ` 8000 main/binary-amd64/Packages
+ ` + strings.Repeat("f", 128) + ` 100 main/i18n/Translation-en
+`
+	r, err := Parse([]byte(body))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	packages := strings.Repeat("a", 64)
+	tests := []struct {
+		algorithm, digest string
+		want              string
+		ok                bool
+	}{
+		{"SHA256", strings.Repeat("A", 64), packages, true},
+		{"MD5Sum", "d41d8cd98f00b204e9800998ecf8427e", packages, true},
+		{"md5sum", "D41D8CD98F00B204E9800998ECF8427E", packages, true},
+		{"SHA512", strings.Repeat("e", 128), packages, true},
+		// Listed without a SHA256 entry for the same file
+		{"SHA512", strings.Repeat("f", 128), "", false},
+		{"SHA1", strings.Repeat("1", 40), "", false},
+		{"SHA384", strings.Repeat("e", 96), "", false},
+	}
+	for _, tt := range tests {
+		got, ok := r.CanonicalSHA256(tt.algorithm, tt.digest)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("CanonicalSHA256(%s, %s) = %q, %v; want %q, %v", tt.algorithm, tt.digest, got, ok, tt.want, tt.ok)
+		}
+	}
+}
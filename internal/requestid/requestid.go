@@ -18,6 +18,7 @@ type contextKey int
 const (
 	requestIDKey contextKey = iota
 	loggerKey
+	traceKey
 )
 
 // validIDRegex validates request ID format: 24 hex characters
@@ -0,0 +1,112 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// W3C Trace Context (https://www.w3.org/TR/trace-context/) headers. A client
+// such as a CI job that sets them has its trace continued through the
+// proxy's logs, audit events and mirror requests.
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+// maxTraceStateLen bounds the tracestate passed on: 32 list members of at
+// most 256 characters each, plus separators.
+const maxTraceStateLen = 32*257 - 1
+
+// Trace is the trace context a request belongs to.
+type Trace struct {
+	// TraceID identifies the whole trace: 32 lowercase hex characters
+	TraceID string
+	// SpanID is the caller's span the request is part of (the traceparent
+	// parent-id): 16 lowercase hex characters
+	SpanID string
+	// Flags are the trace flags; bit 0 means the caller samples the trace
+	Flags string
+	// State is the vendor-specific tracestate, passed on unchanged
+	State string
+}
+
+// ParseTrace parses traceparent and tracestate header values. It reports
+// false if traceparent is missing or malformed, in which case both are
+// ignored, as the specification requires.
+func ParseTrace(traceparent, tracestate string) (Trace, bool) {
+	tp := strings.TrimSpace(traceparent)
+	// version "-" trace-id "-" parent-id "-" flags; later versions may
+	// append fields after another "-"
+	if len(tp) < 55 || (len(tp) > 55 && tp[55] != '-') {
+		return Trace{}, false
+	}
+	version, traceID, spanID, flags := tp[0:2], tp[3:35], tp[36:52], tp[53:55]
+	if tp[2] != '-' || tp[35] != '-' || tp[52] != '-' {
+		return Trace{}, false
+	}
+	if !isLowerHex(version) || version == "ff" || (version == "00" && len(tp) != 55) {
+		return Trace{}, false
+	}
+	if !isLowerHex(traceID) || !isLowerHex(spanID) || !isLowerHex(flags) ||
+		strings.Trim(traceID, "0") == "" || strings.Trim(spanID, "0") == "" {
+		return Trace{}, false
+	}
+	state := strings.TrimSpace(tracestate)
+	if len(state) > maxTraceStateLen {
+		state = ""
+	}
+	return Trace{TraceID: traceID, SpanID: spanID, Flags: flags, State: state}, true
+}
+
+// Child returns the trace of a request made on behalf of t, such as a mirror
+// fetch: the same trace, with a new span ID of its own.
+func (t Trace) Child() Trace {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	t.SpanID = hex.EncodeToString(id)
+	return t
+}
+
+// TraceParent returns the traceparent header value for t.
+func (t Trace) TraceParent() string {
+	return "00-" + t.TraceID + "-" + t.SpanID + "-" + t.Flags
+}
+
+// SetHeader sets the traceparent and tracestate headers of h for t.
+func (t Trace) SetHeader(h http.Header) {
+	h.Set(TraceParentHeader, t.TraceParent())
+	if t.State != "" {
+		h.Set(TraceStateHeader, t.State)
+	}
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// WithTrace adds a trace context to the context.
+func WithTrace(ctx context.Context, t Trace) context.Context {
+	return context.WithValue(ctx, traceKey, t)
+}
+
+// TraceFromContext retrieves the trace context from context.
+func TraceFromContext(ctx context.Context) (Trace, bool) {
+	t, ok := ctx.Value(traceKey).(Trace)
+	return t, ok
+}
+
+// TraceIDFromContext returns the trace ID of the context's trace, or an
+// empty string if it has none.
+func TraceIDFromContext(ctx context.Context) string {
+	t, _ := TraceFromContext(ctx)
+	return t.TraceID
+}
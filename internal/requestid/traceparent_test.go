@@ -0,0 +1,67 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestParseTrace(t *testing.T) {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	trace, ok := ParseTrace(tp, "vendor=abc")
+	if !ok {
+		t.Fatal("valid traceparent rejected")
+	}
+	if trace.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || trace.SpanID != "00f067aa0ba902b7" ||
+		trace.Flags != "01" || trace.State != "vendor=abc" {
+		t.Errorf("trace = %+v", trace)
+	}
+	if trace.TraceParent() != tp {
+		t.Errorf("TraceParent() = %q", trace.TraceParent())
+	}
+
+	// A later version may append fields
+	if _, ok := ParseTrace("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", ""); !ok {
+		t.Error("future version rejected")
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01",
+	} {
+		if _, ok := ParseTrace(bad, ""); ok {
+			t.Errorf("ParseTrace(%q) accepted", bad)
+		}
+	}
+}
+
+func TestTraceChild(t *testing.T) {
+	trace, _ := ParseTrace("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "vendor=abc")
+	child := trace.Child()
+	if child.TraceID != trace.TraceID || child.SpanID == trace.SpanID || len(child.SpanID) != 16 {
+		t.Errorf("child = %+v", child)
+	}
+
+	h := http.Header{}
+	child.SetHeader(h)
+	parsed, ok := ParseTrace(h.Get(TraceParentHeader), h.Get(TraceStateHeader))
+	if !ok || parsed != child {
+		t.Errorf("header round trip = %+v, want %+v", parsed, child)
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	if TraceIDFromContext(context.Background()) != "" {
+		t.Error("trace ID without a trace")
+	}
+	trace, _ := ParseTrace("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "")
+	ctx := WithTrace(context.Background(), trace)
+	if TraceIDFromContext(ctx) != trace.TraceID {
+		t.Errorf("TraceIDFromContext = %q", TraceIDFromContext(ctx))
+	}
+}
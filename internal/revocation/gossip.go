@@ -0,0 +1,106 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"go.uber.org/zap"
+)
+
+// ProtocolID is the libp2p protocol used to push the signed list to fleet
+// peers. The wire format is the raw clearsigned list, terminated by the
+// sender closing its write side.
+const ProtocolID = "/debswarm/revocation/1.0.0"
+
+const gossipTimeout = 30 * time.Second
+
+// Gossip distributes the list in force to fleet peers and accepts lists pushed
+// by them. Receivers verify every list against their own keyring, so a peer
+// can relay a list but never forge one.
+type Gossip struct {
+	host    host.Host
+	manager *Manager
+	logger  *zap.Logger
+}
+
+// NewGossip registers the revocation stream handler on h.
+func NewGossip(h host.Host, m *Manager, logger *zap.Logger) *Gossip {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	g := &Gossip{host: h, manager: m, logger: logger}
+	h.SetStreamHandler(protocol.ID(ProtocolID), g.handleStream)
+	return g
+}
+
+// Close removes the stream handler.
+func (g *Gossip) Close() {
+	g.host.RemoveStreamHandler(protocol.ID(ProtocolID))
+}
+
+func (g *Gossip) handleStream(s network.Stream) {
+	defer func() { _ = s.Close() }()
+	from := s.Conn().RemotePeer()
+	_ = s.SetReadDeadline(time.Now().Add(gossipTimeout))
+
+	data, err := io.ReadAll(io.LimitReader(s, MaxListSize+1))
+	if err != nil {
+		g.logger.Debug("Failed to read revocation list from peer",
+			zap.String("peer", from.String()), zap.Error(err))
+		return
+	}
+	accepted, err := g.manager.Apply(data)
+	switch {
+	case accepted:
+		g.logger.Info("Accepted revocation list from fleet peer", zap.String("peer", from.String()))
+	case errors.Is(err, ErrStaleSerial):
+		// The common case: the peer is behind or equal to us.
+	default:
+		g.logger.Warn("Rejected revocation list from peer",
+			zap.String("peer", from.String()), zap.Error(err))
+	}
+}
+
+// Push sends the list in force to each peer and returns how many accepted the
+// stream. It is a no-op when no list has been accepted yet.
+func (g *Gossip) Push(ctx context.Context, peers []peer.ID) int {
+	signed := g.manager.Signed()
+	if len(signed) == 0 {
+		return 0
+	}
+	sent := 0
+	for _, p := range peers {
+		if p == g.host.ID() {
+			continue
+		}
+		if err := g.pushTo(ctx, p, signed); err != nil {
+			g.logger.Debug("Failed to push revocation list",
+				zap.String("peer", p.String()), zap.Error(err))
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+func (g *Gossip) pushTo(ctx context.Context, p peer.ID, signed []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, gossipTimeout)
+	defer cancel()
+	s, err := g.host.NewStream(ctx, p, protocol.ID(ProtocolID))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = s.Close() }()
+	_ = s.SetWriteDeadline(time.Now().Add(gossipTimeout))
+	if _, err := s.Write(signed); err != nil {
+		_ = s.Reset()
+		return err
+	}
+	return s.CloseWrite()
+}
@@ -0,0 +1,62 @@
+package revocation
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestGossip_PushPropagatesVerifiedList(t *testing.T) {
+	mn := mocknet.New()
+	defer func() { _ = mn.Close() }()
+
+	hostA, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("GenPeer A: %v", err)
+	}
+	hostB, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("GenPeer B: %v", err)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("LinkAll: %v", err)
+	}
+	if err := mn.ConnectAllButSelf(); err != nil {
+		t.Fatalf("ConnectAllButSelf: %v", err)
+	}
+
+	signer, kr := testSigner(t)
+	h := strings.Repeat("e", 64)
+
+	mA := NewManager(kr, "", nil)
+	mB := NewManager(kr, "", nil)
+	gA := NewGossip(hostA, mA, nil)
+	defer gA.Close()
+	gB := NewGossip(hostB, mB, nil)
+	defer gB.Close()
+
+	// Nothing to push before a list is in force.
+	if n := gA.Push(context.Background(), []peer.ID{hostB.ID()}); n != 0 {
+		t.Fatalf("Push with no list sent %d", n)
+	}
+
+	if _, err := mA.Apply(signList(t, signer, 1, h)); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if n := gA.Push(context.Background(), []peer.ID{hostA.ID(), hostB.ID()}); n != 1 {
+		t.Fatalf("Push sent %d, want 1 (self skipped)", n)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := mB.IsRevoked(h); ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("peer B never accepted the gossiped list")
+}
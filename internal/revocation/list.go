@@ -0,0 +1,145 @@
+// Package revocation maintains a signed list of known-bad package content
+// hashes. When a repository pulls a package (a security revocation), the list
+// lets a fleet purge it from every cache and stop sharing it swarm-wide: a
+// revoked hash is deleted from the local cache, refused to peers that ask for
+// it, and refused to APT clients that request it.
+//
+// The list is an OpenPGP clearsigned text document, fetched from a configured
+// URL or received from fleet peers over a small gossip protocol. Only a list
+// that verifies against the operator-configured keyring and carries a serial
+// newer than the current one is accepted, so any peer may relay it without
+// being trusted.
+package revocation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoSerial is returned when a list body has no Serial header.
+var ErrNoSerial = errors.New("revocation: list has no Serial header")
+
+// List is an immutable, parsed revocation list.
+//
+// The signed body looks like:
+//
+//	Serial: 7
+//	Issued: 2026-10-17T00:00:00Z
+//
+//	# comment lines and blank lines are ignored
+//	<sha256> <free-form reason>
+//
+// Headers run until the first blank line. Serial is required and must grow
+// with every published list; Issued is informational.
+type List struct {
+	Serial  uint64
+	Issued  time.Time
+	entries map[string]string // sha256 -> reason
+}
+
+// Parse parses a verified list body. It never sees unsigned input in the
+// daemon: callers pass the plaintext returned by signature verification.
+func Parse(body []byte) (*List, error) {
+	l := &List{entries: make(map[string]string)}
+	haveSerial := false
+	inHeader := true
+
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+
+		if inHeader {
+			if line == "" {
+				inHeader = false
+				continue
+			}
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				return nil, fmt.Errorf("revocation: line %d: malformed header %q", lineNo, line)
+			}
+			value = strings.TrimSpace(value)
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "serial":
+				n, err := strconv.ParseUint(value, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("revocation: line %d: invalid serial %q", lineNo, value)
+				}
+				l.Serial = n
+				haveSerial = true
+			case "issued":
+				t, err := time.Parse(time.RFC3339, value)
+				if err != nil {
+					return nil, fmt.Errorf("revocation: line %d: invalid issued time %q", lineNo, value)
+				}
+				l.Issued = t
+			}
+			// Unknown headers are ignored so the format can grow.
+			continue
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, reason, _ := strings.Cut(line, " ")
+		hash = strings.ToLower(hash)
+		if !isSHA256(hash) {
+			return nil, fmt.Errorf("revocation: line %d: invalid sha256 %q", lineNo, hash)
+		}
+		l.entries[hash] = strings.TrimSpace(reason)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("revocation: read list: %w", err)
+	}
+	if !haveSerial {
+		return nil, ErrNoSerial
+	}
+	return l, nil
+}
+
+// Lookup reports whether hash is revoked and, if so, the published reason.
+func (l *List) Lookup(hash string) (string, bool) {
+	if l == nil {
+		return "", false
+	}
+	reason, ok := l.entries[strings.ToLower(hash)]
+	return reason, ok
+}
+
+// Len returns the number of revoked hashes.
+func (l *List) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.entries)
+}
+
+// Hashes returns the revoked hashes in sorted order.
+func (l *List) Hashes() []string {
+	if l == nil {
+		return nil
+	}
+	out := make([]string, 0, len(l.entries))
+	for h := range l.entries {
+		out = append(out, h)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func isSHA256(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,77 @@
+package revocation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse_Valid(t *testing.T) {
+	a := strings.Repeat("a", 64)
+	b := strings.Repeat("B", 64)
+	body := "Serial: 12\nIssued: 2026-10-17T00:00:00Z\nX-Future: ignored\n\n" +
+		"# pulled by the security team\n" +
+		a + " CVE-2026-0001 backdoored build\n\n" +
+		b + "\n"
+
+	l, err := Parse([]byte(body))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if l.Serial != 12 {
+		t.Errorf("Serial = %d, want 12", l.Serial)
+	}
+	if l.Issued.IsZero() {
+		t.Error("Issued not parsed")
+	}
+	if l.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", l.Len())
+	}
+	if reason, ok := l.Lookup(a); !ok || reason != "CVE-2026-0001 backdoored build" {
+		t.Errorf("Lookup(a) = %q, %v", reason, ok)
+	}
+	// Hashes are normalized to lower case on both sides.
+	if _, ok := l.Lookup(strings.ToUpper(b)); !ok {
+		t.Error("Lookup should be case-insensitive")
+	}
+	if _, ok := l.Lookup(strings.Repeat("c", 64)); ok {
+		t.Error("unlisted hash reported revoked")
+	}
+	if got := l.Hashes(); len(got) != 2 || got[0] != a {
+		t.Errorf("Hashes = %v", got)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no serial", "Issued: 2026-10-17T00:00:00Z\n\n" + strings.Repeat("a", 64) + "\n"},
+		{"bad serial", "Serial: seven\n\n"},
+		{"bad issued", "Serial: 1\nIssued: yesterday\n\n"},
+		{"malformed header", "Serial 1\n\n"},
+		{"bad hash", "Serial: 1\n\nnot-a-hash reason\n"},
+		{"short hash", "Serial: 1\n\n" + strings.Repeat("a", 63) + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.body)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+	if _, err := Parse([]byte("Issued: 2026-10-17T00:00:00Z\n")); !errors.Is(err, ErrNoSerial) {
+		t.Errorf("want ErrNoSerial, got %v", err)
+	}
+}
+
+func TestList_NilSafe(t *testing.T) {
+	var l *List
+	if _, ok := l.Lookup(strings.Repeat("a", 64)); ok {
+		t.Error("nil list revoked a hash")
+	}
+	if l.Len() != 0 || l.Hashes() != nil {
+		t.Error("nil list should be empty")
+	}
+}
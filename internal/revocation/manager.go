@@ -0,0 +1,221 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/gpg"
+)
+
+// MaxListSize bounds a signed list read from the network or disk. A list of
+// tens of thousands of hashes is well under this.
+const MaxListSize = 4 << 20
+
+var (
+	// ErrStaleSerial is returned when a verified list is not newer than the
+	// one already in force. It is expected during gossip and not an error to
+	// report loudly.
+	ErrStaleSerial = errors.New("revocation: list serial is not newer than the current list")
+	// ErrTooLarge is returned when a list exceeds MaxListSize.
+	ErrTooLarge = errors.New("revocation: list exceeds maximum size")
+)
+
+// Manager holds the revocation list currently in force. It is safe for
+// concurrent use; lookups take only a read lock.
+type Manager struct {
+	keyring   *gpg.Keyring
+	statePath string // where the last accepted signed list is persisted ("" = memory only)
+	logger    *zap.Logger
+
+	// applyMu serializes Apply, so lists are persisted and passed to
+	// onUpdate in serial order, and a restart never restores an older list
+	// than the one in force
+	applyMu sync.Mutex
+
+	mu       sync.RWMutex
+	list     *List
+	signed   []byte // the clearsigned bytes of list, for gossip and persistence
+	onUpdate func(*List)
+}
+
+// NewManager creates a manager that accepts lists signed by a key in keyring.
+// statePath, when set, persists the last accepted list across restarts so
+// enforcement does not lapse while the list URL is unreachable.
+func NewManager(keyring *gpg.Keyring, statePath string, logger *zap.Logger) *Manager {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Manager{
+		keyring:   keyring,
+		statePath: statePath,
+		logger:    logger,
+	}
+}
+
+// SetOnUpdate registers a callback run each time a newer list is accepted,
+// one at a time and in serial order. Lookups are not blocked while it runs,
+// but it must not call Apply. The daemon uses it to purge newly revoked
+// hashes from the cache. Must be set before the manager is in use.
+func (m *Manager) SetOnUpdate(fn func(*List)) {
+	m.onUpdate = fn
+}
+
+// Load restores the persisted list, if any. A missing file is not an error.
+func (m *Manager) Load() error {
+	if m.statePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(m.statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("revocation: read persisted list: %w", err)
+	}
+	list, err := m.verify(data)
+	if err != nil {
+		return fmt.Errorf("revocation: persisted list: %w", err)
+	}
+	m.mu.Lock()
+	m.list = list
+	m.signed = data
+	m.mu.Unlock()
+	m.logger.Info("Loaded revocation list",
+		zap.Uint64("serial", list.Serial),
+		zap.Int("hashes", list.Len()))
+	return nil
+}
+
+// Apply verifies a clearsigned list and, if it is newer than the current one,
+// makes it the list in force. It reports whether the list was accepted.
+// ErrStaleSerial is returned for a valid list that is not newer.
+func (m *Manager) Apply(signed []byte) (bool, error) {
+	if len(signed) > MaxListSize {
+		return false, ErrTooLarge
+	}
+	list, err := m.verify(signed)
+	if err != nil {
+		return false, err
+	}
+
+	m.applyMu.Lock()
+	m.mu.Lock()
+	if m.list != nil && list.Serial <= m.list.Serial {
+		m.mu.Unlock()
+		m.applyMu.Unlock()
+		return false, ErrStaleSerial
+	}
+	m.list = list
+	m.signed = append([]byte(nil), signed...)
+	m.mu.Unlock()
+
+	// Lookups carry on with the new list while it is written out and the
+	// callback runs; applyMu keeps both in serial order
+	defer m.applyMu.Unlock()
+	if err := m.persist(signed); err != nil {
+		m.logger.Warn("Failed to persist revocation list", zap.Error(err))
+	}
+	m.logger.Info("Accepted revocation list",
+		zap.Uint64("serial", list.Serial),
+		zap.Int("hashes", list.Len()))
+
+	if m.onUpdate != nil {
+		m.onUpdate(list)
+	}
+	return true, nil
+}
+
+// Fetch downloads the signed list from url and applies it.
+func (m *Manager) Fetch(ctx context.Context, client *http.Client, url string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("revocation: fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("revocation: fetch %s: HTTP %d", url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxListSize+1))
+	if err != nil {
+		return false, fmt.Errorf("revocation: read %s: %w", url, err)
+	}
+	return m.Apply(data)
+}
+
+// IsRevoked reports whether hash is on the list in force, with its reason.
+// A nil manager revokes nothing, so callers need not guard the disabled case.
+func (m *Manager) IsRevoked(hash string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.list.Lookup(hash)
+}
+
+// Current returns the list in force, or nil if none has been accepted.
+func (m *Manager) Current() *List {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.list
+}
+
+// Signed returns the clearsigned bytes of the list in force (nil if none).
+func (m *Manager) Signed() []byte {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.signed
+}
+
+func (m *Manager) verify(signed []byte) (*List, error) {
+	body, err := m.keyring.VerifyClearsigned(signed)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(body)
+}
+
+// persist writes the accepted list atomically (temp file + rename).
+func (m *Manager) persist(signed []byte) error {
+	if m.statePath == "" {
+		return nil
+	}
+	dir := filepath.Dir(m.statePath)
+	tmp, err := os.CreateTemp(dir, ".revocations-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(signed); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, m.statePath); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
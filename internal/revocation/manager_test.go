@@ -0,0 +1,229 @@
+package revocation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/gpg"
+)
+
+// testSigner generates a signing key and returns it with a keyring that
+// trusts it (loaded from disk, as the daemon does).
+func testSigner(t *testing.T) (*openpgp.Entity, *gpg.Keyring) {
+	t.Helper()
+	e, err := openpgp.NewEntity("debswarm revocation test", "test", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("NewEntity: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "revocation.gpg")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := e.Serialize(f); err != nil {
+		t.Fatalf("serialize: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	kr, err := gpg.Load(zap.NewNop(), path)
+	if err != nil || kr.Empty() {
+		t.Fatalf("gpg.Load: %v (empty=%v)", err, kr.Empty())
+	}
+	return e, kr
+}
+
+func signList(t *testing.T, e *openpgp.Entity, serial uint64, hashes ...string) []byte {
+	t.Helper()
+	body := fmt.Sprintf("Serial: %d\n\n", serial)
+	for _, h := range hashes {
+		body += h + " test revocation\n"
+	}
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, e.PrivateKey, nil)
+	if err != nil {
+		t.Fatalf("clearsign.Encode: %v", err)
+	}
+	if _, err := w.Write([]byte(body)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestManager_ApplyAndSerialOrdering(t *testing.T) {
+	signer, kr := testSigner(t)
+	m := NewManager(kr, "", nil)
+	h1 := strings.Repeat("1", 64)
+	h2 := strings.Repeat("2", 64)
+
+	var updates int
+	m.SetOnUpdate(func(*List) { updates++ })
+
+	if _, ok := m.IsRevoked(h1); ok {
+		t.Fatal("empty manager revoked a hash")
+	}
+	if ok, err := m.Apply(signList(t, signer, 2, h1)); !ok || err != nil {
+		t.Fatalf("Apply serial 2: %v %v", ok, err)
+	}
+	if _, ok := m.IsRevoked(h1); !ok {
+		t.Error("h1 should be revoked")
+	}
+
+	// Same or older serial is rejected, and does not replace the list.
+	if ok, err := m.Apply(signList(t, signer, 2, h2)); ok || !errors.Is(err, ErrStaleSerial) {
+		t.Errorf("equal serial: ok=%v err=%v, want ErrStaleSerial", ok, err)
+	}
+	if ok, err := m.Apply(signList(t, signer, 1, h2)); ok || !errors.Is(err, ErrStaleSerial) {
+		t.Errorf("older serial: ok=%v err=%v, want ErrStaleSerial", ok, err)
+	}
+	if _, ok := m.IsRevoked(h2); ok {
+		t.Error("stale list must not take effect")
+	}
+
+	if ok, err := m.Apply(signList(t, signer, 3, h2)); !ok || err != nil {
+		t.Fatalf("Apply serial 3: %v %v", ok, err)
+	}
+	if _, ok := m.IsRevoked(h1); ok {
+		t.Error("h1 dropped from the newer list should no longer be revoked")
+	}
+	if updates != 2 {
+		t.Errorf("onUpdate called %d times, want 2", updates)
+	}
+}
+
+func TestManager_RejectsUntrustedAndTampered(t *testing.T) {
+	_, kr := testSigner(t)
+	other, _ := testSigner(t)
+	m := NewManager(kr, "", nil)
+
+	if ok, err := m.Apply(signList(t, other, 5, strings.Repeat("a", 64))); ok || err == nil {
+		t.Error("list signed by an untrusted key was accepted")
+	}
+	if ok, err := m.Apply([]byte("Serial: 9\n\n" + strings.Repeat("a", 64) + "\n")); ok || err == nil {
+		t.Error("unsigned list was accepted")
+	}
+	if m.Current() != nil {
+		t.Error("no list should be in force")
+	}
+}
+
+func TestManager_PersistAndLoad(t *testing.T) {
+	signer, kr := testSigner(t)
+	state := filepath.Join(t.TempDir(), "revocations.asc")
+	h := strings.Repeat("f", 64)
+
+	m := NewManager(kr, state, nil)
+	if _, err := m.Apply(signList(t, signer, 4, h)); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	restored := NewManager(kr, state, nil)
+	if err := restored.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if restored.Current() == nil || restored.Current().Serial != 4 {
+		t.Fatalf("restored list = %+v", restored.Current())
+	}
+	if _, ok := restored.IsRevoked(h); !ok {
+		t.Error("restored list lost an entry")
+	}
+
+	// A missing state file is not an error.
+	if err := NewManager(kr, filepath.Join(t.TempDir(), "absent"), nil).Load(); err != nil {
+		t.Errorf("Load of missing file: %v", err)
+	}
+}
+
+func TestManager_ConcurrentApplyPersistsNewest(t *testing.T) {
+	signer, kr := testSigner(t)
+	state := filepath.Join(t.TempDir(), "revocations.asc")
+	const lists = 20
+	signed := make([][]byte, lists)
+	for i := range signed {
+		signed[i] = signList(t, signer, uint64(i+1), strings.Repeat("a", 64))
+	}
+
+	m := NewManager(kr, state, nil)
+	var (
+		mu      sync.Mutex
+		updates []uint64
+	)
+	m.SetOnUpdate(func(list *List) {
+		mu.Lock()
+		updates = append(updates, list.Serial)
+		mu.Unlock()
+	})
+	var wg sync.WaitGroup
+	for _, data := range signed {
+		wg.Add(1)
+		go func(data []byte) {
+			defer wg.Done()
+			_, _ = m.Apply(data)
+		}(data)
+	}
+	wg.Wait()
+
+	restored := NewManager(kr, state, nil)
+	if err := restored.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got, want := restored.Current().Serial, m.Current().Serial; got != want {
+		t.Errorf("persisted serial = %d, in force = %d", got, want)
+	}
+	// The purge callback sees the accepted lists in serial order
+	for i := 1; i < len(updates); i++ {
+		if updates[i] <= updates[i-1] {
+			t.Fatalf("onUpdate serials out of order: %v", updates)
+		}
+	}
+	if n := len(updates); n == 0 || updates[n-1] != m.Current().Serial {
+		t.Errorf("last onUpdate serials %v, in force = %d", updates, m.Current().Serial)
+	}
+}
+
+func TestManager_Fetch(t *testing.T) {
+	signer, kr := testSigner(t)
+	signed := signList(t, signer, 1, strings.Repeat("d", 64))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/revocations.asc" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(signed)
+	}))
+	defer srv.Close()
+
+	m := NewManager(kr, "", nil)
+	if ok, err := m.Fetch(context.Background(), srv.Client(), srv.URL+"/revocations.asc"); !ok || err != nil {
+		t.Fatalf("Fetch: %v %v", ok, err)
+	}
+	if _, err := m.Fetch(context.Background(), srv.Client(), srv.URL+"/missing"); err == nil {
+		t.Error("Fetch of a 404 should fail")
+	}
+}
+
+func TestManager_NilIsInert(t *testing.T) {
+	var m *Manager
+	if _, ok := m.IsRevoked(strings.Repeat("a", 64)); ok {
+		t.Error("nil manager revoked a hash")
+	}
+	if m.Current() != nil || m.Signed() != nil {
+		t.Error("nil manager should have no list")
+	}
+}
@@ -0,0 +1,129 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is the INSTREAM chunk size. clamd rejects a stream larger
+// than its StreamMaxLength, not large chunks, so this only bounds memory.
+const clamdChunkSize = 64 * 1024
+
+// Clamd scans through a clamd daemon with the INSTREAM command.
+type Clamd struct {
+	network string // "unix" or "tcp"
+	address string
+	timeout time.Duration
+}
+
+// NewClamd returns a scanner for clamd at addr: a Unix socket path, or
+// host:port for TCP.
+func NewClamd(addr string, timeout time.Duration) *Clamd {
+	network := "tcp"
+	if strings.HasPrefix(addr, "/") || strings.HasPrefix(addr, "@") {
+		network = "unix"
+	}
+	return &Clamd{network: network, address: addr, timeout: timeout}
+}
+
+// Name implements Scanner.
+func (c *Clamd) Name() string { return "clamd" }
+
+// Scan implements Scanner.
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return nil, fmt.Errorf("connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	// Unblock reads and writes if ctx is cancelled without a deadline.
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if err := writeInstream(conn, r); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// clamd closes the connection when the stream exceeds its limit;
+		// its reply, if any, explains why.
+		if reply, rerr := readReply(conn); rerr == nil && reply != "" {
+			return nil, fmt.Errorf("clamd: %s", reply)
+		}
+		return nil, fmt.Errorf("send to clamd: %w", err)
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("read clamd reply: %w", err)
+	}
+	return parseClamdReply(reply)
+}
+
+// writeInstream sends r as a zINSTREAM command: length-prefixed chunks
+// terminated by a zero-length chunk.
+func writeInstream(w io.Writer, r io.Reader) error {
+	if _, err := io.WriteString(w, "zINSTREAM\x00"); err != nil {
+		return err
+	}
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n)) // #nosec G115 -- n <= clamdChunkSize
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read package: %w", err)
+		}
+	}
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+func readReply(r io.Reader) (string, error) {
+	reply, err := bufio.NewReader(r).ReadBytes(0)
+	if err != nil && !(errors.Is(err, io.EOF) && len(reply) > 0) {
+		return "", err
+	}
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
+
+// parseClamdReply interprets "stream: OK", "stream: <sig> FOUND" and
+// "<message> ERROR".
+func parseClamdReply(reply string) (*Result, error) {
+	msg := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case msg == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(msg, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(msg, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", reply)
+	}
+}
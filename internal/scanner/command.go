@@ -0,0 +1,76 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Command scans by running a program with the package on its stdin, e.g.
+// "clamdscan --no-summary -". It follows the ClamAV exit status convention:
+// 0 clean, 1 infected, anything else an error.
+type Command struct {
+	argv    []string
+	timeout time.Duration
+}
+
+// NewCommand returns a scanner that runs argv for each package.
+func NewCommand(argv []string, timeout time.Duration) *Command {
+	return &Command{argv: append([]string(nil), argv...), timeout: timeout}
+}
+
+// Name implements Scanner.
+func (c *Command) Name() string { return "command" }
+
+// Scan implements Scanner.
+func (c *Command) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.argv[0], c.argv[1:]...) // #nosec G204 -- operator-configured scanner
+	cmd.Stdin = r
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	if err == nil {
+		return &Result{}, nil
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("scanner %s: %w", c.argv[0], ctx.Err())
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return &Result{Infected: true, Signature: signature(out.String())}, nil
+	}
+	if msg := firstLine(out.String()); msg != "" {
+		return nil, fmt.Errorf("scanner %s: %w: %s", c.argv[0], err, msg)
+	}
+	return nil, fmt.Errorf("scanner %s: %w", c.argv[0], err)
+}
+
+// signature extracts the finding from scanner output such as
+// "stdin: Eicar-Test-Signature FOUND".
+func signature(out string) string {
+	line := firstLine(out)
+	if _, after, ok := strings.Cut(line, ": "); ok {
+		line = after
+	}
+	if line = strings.TrimSuffix(line, " FOUND"); line == "" {
+		return "unknown"
+	}
+	return line
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(line)
+}
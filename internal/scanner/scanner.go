@@ -0,0 +1,37 @@
+// Package scanner checks package content with an external malware scanner,
+// either a clamd daemon or a command, before the package is cached and
+// announced to the swarm.
+package scanner
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Result is the verdict for one package.
+type Result struct {
+	Infected  bool
+	Signature string // what the scanner found, e.g. "Eicar-Test-Signature"
+}
+
+// Scanner scans a stream of package content. An error means no verdict was
+// reached (scanner unreachable, timeout, oversized input), not that the
+// content is bad.
+type Scanner interface {
+	// Name identifies the scanner in logs and metrics.
+	Name() string
+	Scan(ctx context.Context, r io.Reader) (*Result, error)
+}
+
+// New returns a scanner for a clamd address or a command line. Exactly one
+// should be set; clamd takes precedence. It returns nil when both are empty.
+func New(clamd string, command []string, timeout time.Duration) Scanner {
+	switch {
+	case clamd != "":
+		return NewClamd(clamd, timeout)
+	case len(command) > 0:
+		return NewCommand(command, timeout)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd answers INSTREAM requests, flagging streams containing "EICAR".
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				cmd, err := br.ReadString(0)
+				if err != nil || cmd != "zINSTREAM\x00" {
+					_, _ = io.WriteString(conn, "UNKNOWN COMMAND\x00")
+					return
+				}
+				var data bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(br, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if _, err := io.CopyN(&data, br, int64(size)); err != nil {
+						return
+					}
+				}
+				if bytes.Contains(data.Bytes(), []byte("EICAR")) {
+					_, _ = io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+					return
+				}
+				_, _ = io.WriteString(conn, "stream: OK\x00")
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestClamd(t *testing.T) {
+	s := New(fakeClamd(t), nil, 5*time.Second)
+	if s.Name() != "clamd" {
+		t.Fatalf("Name() = %q", s.Name())
+	}
+	ctx := context.Background()
+
+	// Larger than one chunk, to exercise chunking.
+	clean := bytes.Repeat([]byte("debian "), 20000)
+	res, err := s.Scan(ctx, bytes.NewReader(clean))
+	if err != nil || res.Infected {
+		t.Errorf("clean scan = %+v, %v", res, err)
+	}
+
+	res, err = s.Scan(ctx, strings.NewReader("X5O!P%@AP EICAR test"))
+	if err != nil || !res.Infected || res.Signature != "Eicar-Test-Signature" {
+		t.Errorf("infected scan = %+v, %v", res, err)
+	}
+}
+
+func TestClamd_Unreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	if _, err := NewClamd(addr, time.Second).Scan(context.Background(), strings.NewReader("x")); err == nil {
+		t.Error("expected an error from an unreachable clamd")
+	}
+}
+
+func TestParseClamdReply(t *testing.T) {
+	tests := []struct {
+		reply    string
+		infected bool
+		sig      string
+		wantErr  bool
+	}{
+		{"stream: OK", false, "", false},
+		{"stream: Win.Test.EICAR_HDB-1 FOUND", true, "Win.Test.EICAR_HDB-1", false},
+		{"INSTREAM size limit exceeded. ERROR", false, "", true},
+		{"", false, "", true},
+	}
+	for _, tt := range tests {
+		res, err := parseClamdReply(tt.reply)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseClamdReply(%q) error = %v", tt.reply, err)
+			continue
+		}
+		if err == nil && (res.Infected != tt.infected || res.Signature != tt.sig) {
+			t.Errorf("parseClamdReply(%q) = %+v", tt.reply, res)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	// Exit 1 with a clamscan-style finding when stdin mentions EICAR,
+	// 2 when it mentions BROKEN, 0 otherwise.
+	script := `in=$(cat); case "$in" in *EICAR*) echo "stdin: Eicar-Test-Signature FOUND"; exit 1;; *BROKEN*) echo "cannot scan" >&2; exit 2;; esac`
+	s := New("", []string{"sh", "-c", script}, 5*time.Second)
+	ctx := context.Background()
+
+	if res, err := s.Scan(ctx, strings.NewReader("clean")); err != nil || res.Infected {
+		t.Errorf("clean scan = %+v, %v", res, err)
+	}
+	res, err := s.Scan(ctx, strings.NewReader("EICAR"))
+	if err != nil || !res.Infected || res.Signature != "Eicar-Test-Signature" {
+		t.Errorf("infected scan = %+v, %v", res, err)
+	}
+	if _, err := s.Scan(ctx, strings.NewReader("BROKEN")); err == nil || !strings.Contains(err.Error(), "cannot scan") {
+		t.Errorf("failed scan error = %v", err)
+	}
+}
+
+func TestNew_None(t *testing.T) {
+	if New("", nil, time.Second) != nil {
+		t.Error("New with nothing configured should return nil")
+	}
+}
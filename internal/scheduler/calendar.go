@@ -0,0 +1,273 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// dateLayout is the format of exception dates
+const dateLayout = "2006-01-02"
+
+// Exception suspends the recurring windows on a range of dates, such as a
+// holiday or a change freeze: the outside-window rate applies all day.
+// One-off windows still open during an exception.
+type Exception struct {
+	ID   string `json:"id,omitempty"` // set for exceptions added at runtime
+	Name string `json:"name,omitempty"`
+	From string `json:"from"`         // first date, "2026-12-24"
+	To   string `json:"to,omitempty"` // last date, inclusive ("" = From only)
+}
+
+// Validate checks the dates of an exception.
+func (e Exception) Validate() error {
+	from, err := time.Parse(dateLayout, e.From)
+	if err != nil {
+		return fmt.Errorf("invalid from date %q: expected YYYY-MM-DD", e.From)
+	}
+	if e.To == "" {
+		return nil
+	}
+	to, err := time.Parse(dateLayout, e.To)
+	if err != nil {
+		return fmt.Errorf("invalid to date %q: expected YYYY-MM-DD", e.To)
+	}
+	if to.Before(from) {
+		return fmt.Errorf("to date %s is before from date %s", e.To, e.From)
+	}
+	return nil
+}
+
+// covers reports whether the exception applies on t's date. Dates in
+// YYYY-MM-DD form order as strings.
+func (e Exception) covers(t time.Time) bool {
+	day := t.Format(dateLayout)
+	last := e.To
+	if last == "" {
+		last = e.From
+	}
+	return day >= e.From && day <= last
+}
+
+// ended reports whether the exception is over by t
+func (e Exception) ended(t time.Time) bool {
+	last := e.To
+	if last == "" {
+		last = e.From
+	}
+	return t.Format(dateLayout) > last
+}
+
+// OneOffWindow is a sync window that opens once, such as for a maintenance
+// run. It opens regardless of the recurring windows and exceptions.
+type OneOffWindow struct {
+	ID     string    `json:"id"`
+	Reason string    `json:"reason,omitempty"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	// Rate is the rate while the window is open in bytes/sec, 0 for
+	// unlimited; nil uses the inside-window rate.
+	Rate *int64 `json:"rate,omitempty"`
+}
+
+// Validate checks the times of a one-off window.
+func (w OneOffWindow) Validate() error {
+	if w.From.IsZero() || w.To.IsZero() {
+		return errors.New("window needs a start and an end")
+	}
+	if !w.To.After(w.From) {
+		return fmt.Errorf("window ends (%s) before it starts (%s)", w.To.Format(time.RFC3339), w.From.Format(time.RFC3339))
+	}
+	if w.Rate != nil && *w.Rate < 0 {
+		return fmt.Errorf("invalid rate %d", *w.Rate)
+	}
+	return nil
+}
+
+func (w OneOffWindow) contains(t time.Time) bool {
+	return !t.Before(w.From) && t.Before(w.To)
+}
+
+// ParseWindowTime parses the start or end of a one-off window: RFC 3339, or
+// "2006-01-02 15:04" in the scheduler's timezone.
+func (s *Scheduler) ParseWindowTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	s.mu.RLock()
+	loc := s.timezone
+	s.mu.RUnlock()
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, v, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: expected \"YYYY-MM-DD HH:MM\" or RFC 3339", v)
+}
+
+// calendar holds the exceptions and one-off windows added at runtime. It is
+// persisted so they survive restarts.
+type calendar struct {
+	LastID     int            `json:"last_id"`
+	Exceptions []Exception    `json:"exceptions,omitempty"`
+	Windows    []OneOffWindow `json:"windows,omitempty"`
+}
+
+func (c *calendar) nextID() string {
+	c.LastID++
+	return strconv.Itoa(c.LastID)
+}
+
+// prune drops entries that are over by now
+func (c *calendar) prune(now time.Time) {
+	c.Exceptions = slices.DeleteFunc(c.Exceptions, func(e Exception) bool { return e.ended(now) })
+	c.Windows = slices.DeleteFunc(c.Windows, func(w OneOffWindow) bool { return !now.Before(w.To) })
+}
+
+// loadCalendar reads a persisted calendar. A missing file is an empty
+// calendar.
+func loadCalendar(path string) (calendar, error) {
+	var c calendar
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return calendar{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return c, nil
+}
+
+func saveCalendar(path string, c calendar) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ErrNotFound is returned when removing an exception or window that does not
+// exist.
+var ErrNotFound = errors.New("no such exception or window")
+
+// AddException adds a date exception and persists it. It returns the
+// exception with its assigned ID.
+func (s *Scheduler) AddException(e Exception) (Exception, error) {
+	if err := e.Validate(); err != nil {
+		return Exception{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.calendar
+	next.Exceptions = slices.Clone(next.Exceptions)
+	e.ID = next.nextID()
+	next.Exceptions = append(next.Exceptions, e)
+	if err := s.saveLocked(next); err != nil {
+		return Exception{}, err
+	}
+	return e, nil
+}
+
+// AddWindow adds a one-off window and persists it. It returns the window
+// with its assigned ID.
+func (s *Scheduler) AddWindow(w OneOffWindow) (OneOffWindow, error) {
+	if err := w.Validate(); err != nil {
+		return OneOffWindow{}, err
+	}
+	if !time.Now().Before(w.To) {
+		return OneOffWindow{}, errors.New("window is already over")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.calendar
+	next.Windows = slices.Clone(next.Windows)
+	w.ID = next.nextID()
+	next.Windows = append(next.Windows, w)
+	if err := s.saveLocked(next); err != nil {
+		return OneOffWindow{}, err
+	}
+	return w, nil
+}
+
+// Remove removes the exception or one-off window with the given ID.
+func (s *Scheduler) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.calendar
+	next.Exceptions = slices.DeleteFunc(slices.Clone(next.Exceptions), func(e Exception) bool { return e.ID == id })
+	next.Windows = slices.DeleteFunc(slices.Clone(next.Windows), func(w OneOffWindow) bool { return w.ID == id })
+	if len(next.Exceptions)+len(next.Windows) == len(s.calendar.Exceptions)+len(s.calendar.Windows) {
+		return ErrNotFound
+	}
+	return s.saveLocked(next)
+}
+
+// saveLocked prunes finished entries from next, persists it and makes it
+// current. Caller holds s.mu.
+func (s *Scheduler) saveLocked(next calendar) error {
+	next.prune(time.Now().In(s.timezone))
+	if err := saveCalendar(s.statePath, next); err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+	s.calendar = next
+	return nil
+}
+
+// Schedule is everything that decides the scheduler's rate, for display
+type Schedule struct {
+	Status        Status         `json:"status"`
+	Windows       []Window       `json:"windows"`
+	Exceptions    []Exception    `json:"exceptions"`
+	OneOffWindows []OneOffWindow `json:"one_off_windows"`
+	OutsideRate   int64          `json:"outside_rate"`
+	InsideRate    int64          `json:"inside_rate"`
+	UrgentBypass  bool           `json:"urgent_full_speed"`
+}
+
+// Schedule returns the recurring windows, the configured and added
+// exceptions that are not over, the pending one-off windows and the current
+// status.
+func (s *Scheduler) Schedule() Schedule {
+	status := s.Status()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now().In(s.timezone)
+
+	sch := Schedule{
+		Status:        status,
+		Windows:       slices.Clone(s.windowConfigs),
+		OneOffWindows: []OneOffWindow{},
+		Exceptions:    []Exception{},
+		OutsideRate:   s.outsideRate,
+		InsideRate:    s.insideRate,
+		UrgentBypass:  s.urgentFullSpeed,
+	}
+	for _, e := range append(slices.Clone(s.exceptions), s.calendar.Exceptions...) {
+		if !e.ended(now) {
+			sch.Exceptions = append(sch.Exceptions, e)
+		}
+	}
+	for _, w := range s.calendar.Windows {
+		if now.Before(w.To) {
+			sch.OneOffWindows = append(sch.OneOffWindows, w)
+		}
+	}
+	return sch
+}
@@ -0,0 +1,238 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// newCalendarScheduler returns a scheduler with a weekday 09:00-17:00
+// window in UTC, 100 bytes/sec outside and 1000 inside, security updates
+// unlimited
+func newCalendarScheduler(t *testing.T, statePath string, exceptions ...Exception) *Scheduler {
+	t.Helper()
+	s, err := New(&Config{
+		Enabled:           true,
+		Windows:           []Window{{Days: []string{"weekday"}, StartTime: "09:00", EndTime: "17:00"}},
+		Timezone:          "UTC",
+		OutsideWindowRate: 100,
+		InsideWindowRate:  1000,
+		UrgentFullSpeed:   true,
+		Exceptions:        exceptions,
+		StatePath:         statePath,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestExceptionValidate(t *testing.T) {
+	valid := []Exception{
+		{From: "2026-12-25"},
+		{From: "2026-12-24", To: "2026-12-26"},
+		{From: "2026-12-25", To: "2026-12-25"},
+	}
+	for _, e := range valid {
+		if err := e.Validate(); err != nil {
+			t.Errorf("%+v: %v", e, err)
+		}
+	}
+	invalid := []Exception{
+		{},
+		{From: "25.12.2026"},
+		{From: "2026-12-25", To: "tomorrow"},
+		{From: "2026-12-26", To: "2026-12-25"},
+	}
+	for _, e := range invalid {
+		if err := e.Validate(); err == nil {
+			t.Errorf("%+v accepted", e)
+		}
+	}
+}
+
+func TestSchedulerPeriod(t *testing.T) {
+	s := newCalendarScheduler(t, "", Exception{Name: "Christmas", From: "2026-12-24", To: "2026-12-26"})
+
+	// Friday 2026-12-18 and Thursday 2026-12-24, both at noon
+	friday := time.Date(2026, 12, 18, 12, 0, 0, 0, time.UTC)
+	holiday := time.Date(2026, 12, 24, 12, 0, 0, 0, time.UTC)
+
+	if p := s.periodLocked(friday); !p.inWindow || p.rate != 1000 {
+		t.Errorf("weekday noon = %+v, want recurring window", p)
+	}
+	if p := s.periodLocked(holiday); p.inWindow || p.rate != 100 || p.exception != "Christmas" {
+		t.Errorf("holiday noon = %+v, want exception", p)
+	}
+
+	// A one-off window opens even on the holiday
+	unlimited := int64(0)
+	s.calendar.Windows = []OneOffWindow{{
+		ID:   "7",
+		From: holiday.Add(-time.Hour),
+		To:   holiday.Add(time.Hour),
+		Rate: &unlimited,
+	}}
+	if p := s.periodLocked(holiday); !p.inWindow || p.rate != 0 || p.oneOff != "7" {
+		t.Errorf("one-off window = %+v", p)
+	}
+	if p := s.periodLocked(holiday.Add(time.Hour)); p.inWindow {
+		t.Errorf("one-off window still open at its end: %+v", p)
+	}
+
+	// Without a rate the inside rate applies
+	s.calendar.Windows[0].Rate = nil
+	if p := s.periodLocked(holiday); p.rate != 1000 {
+		t.Errorf("one-off rate = %d, want inside rate", p.rate)
+	}
+}
+
+func TestSchedulerNextWindowSkipsExceptions(t *testing.T) {
+	now := time.Now().UTC()
+	// Exceptions covering the next ten days: the next recurring window
+	// cannot open before they end
+	e := Exception{From: now.Format(dateLayout), To: now.AddDate(0, 0, 9).Format(dateLayout)}
+	s := newCalendarScheduler(t, "", e)
+
+	next := s.NextWindowStart()
+	if next.IsZero() {
+		t.Fatal("no next window")
+	}
+	if next.Format(dateLayout) <= e.To {
+		t.Errorf("next window %v falls in the exception", next)
+	}
+
+	// A one-off window within the exception opens first
+	w, err := s.AddWindow(OneOffWindow{From: now.Add(time.Hour), To: now.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.NextWindowStart(); !got.Equal(w.From) {
+		t.Errorf("next window = %v, want one-off at %v", got, w.From)
+	}
+}
+
+func TestSchedulerCalendarPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.json")
+	s := newCalendarScheduler(t, path)
+
+	now := time.Now()
+	w, err := s.AddWindow(OneOffWindow{From: now.Add(time.Hour), To: now.Add(3 * time.Hour), Reason: "rebuild"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := s.AddException(Exception{Name: "freeze", From: now.AddDate(0, 0, 1).Format(dateLayout)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.ID == e.ID || w.ID == "" || e.ID == "" {
+		t.Errorf("IDs %q and %q", w.ID, e.ID)
+	}
+	if _, err := s.AddWindow(OneOffWindow{From: now.Add(-2 * time.Hour), To: now.Add(-time.Hour)}); err == nil {
+		t.Error("window in the past accepted")
+	}
+	if _, err := s.AddException(Exception{From: "soon"}); err == nil {
+		t.Error("invalid exception accepted")
+	}
+
+	// A restarted daemon has both
+	s = newCalendarScheduler(t, path)
+	sch := s.Schedule()
+	if len(sch.OneOffWindows) != 1 || sch.OneOffWindows[0].Reason != "rebuild" ||
+		len(sch.Exceptions) != 1 || sch.Exceptions[0].Name != "freeze" {
+		t.Fatalf("schedule after restart = %+v", sch)
+	}
+
+	if err := s.Remove(w.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove(w.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second remove = %v, want ErrNotFound", err)
+	}
+	s = newCalendarScheduler(t, path)
+	if sch := s.Schedule(); len(sch.OneOffWindows) != 0 || len(sch.Exceptions) != 1 {
+		t.Errorf("schedule after remove = %+v", sch)
+	}
+
+	// IDs are not reused
+	w2, err := s.AddWindow(OneOffWindow{From: now.Add(time.Hour), To: now.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w2.ID == w.ID || w2.ID == e.ID {
+		t.Errorf("ID %q reused", w2.ID)
+	}
+}
+
+func TestSchedulerCalendarPruned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.json")
+	past := calendar{
+		LastID:     2,
+		Exceptions: []Exception{{ID: "1", From: "2020-01-01"}},
+		Windows:    []OneOffWindow{{ID: "2", From: time.Now().Add(-2 * time.Hour), To: time.Now().Add(-time.Hour)}},
+	}
+	if err := saveCalendar(path, past); err != nil {
+		t.Fatal(err)
+	}
+	s := newCalendarScheduler(t, path)
+	if len(s.calendar.Exceptions) != 0 || len(s.calendar.Windows) != 0 {
+		t.Errorf("finished entries kept: %+v", s.calendar)
+	}
+}
+
+func TestParseWindowTime(t *testing.T) {
+	s, err := New(&Config{Enabled: true, Timezone: "America/New_York"}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2026, 11, 7, 22, 0, 0, 0, s.timezone)
+	for _, v := range []string{"2026-11-07 22:00", "2026-11-07T22:00", "2026-11-07T22:00:00-05:00"} {
+		got, err := s.ParseWindowTime(v)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseWindowTime(%q) = %v, %v; want %v", v, got, err, want)
+		}
+	}
+	if _, err := s.ParseWindowTime("tonight"); err == nil {
+		t.Error("invalid time accepted")
+	}
+}
+
+func TestSchedulerReaderContext(t *testing.T) {
+	s := newCalendarScheduler(t, "")
+	// Shut every window so the 100 bytes/sec outside rate applies
+	today := time.Now().UTC().Format(dateLayout)
+	if _, err := s.AddException(Exception{From: today, To: time.Now().UTC().AddDate(0, 0, 1).Format(dateLayout)}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := bytes.Repeat([]byte("x"), 64)
+	src := bytes.NewReader(data)
+	if r := s.ReaderContext(context.Background(), src); r != io.Reader(src) {
+		t.Error("traffic that is not a package download was limited")
+	}
+	if r := s.ReaderContext(WithPackageDownload(context.Background(), true), src); r != io.Reader(src) {
+		t.Error("security update was limited")
+	}
+	if r := s.ReaderContext(WithPackageDownload(context.Background(), false), src); r == io.Reader(src) {
+		t.Error("package download was not limited")
+	}
+
+	// Downloads share one limiter until the rate changes
+	if s.limiterFor(100) != s.limiterFor(100) {
+		t.Error("limiter not shared")
+	}
+	if s.limiterFor(100) == s.limiterFor(200) {
+		t.Error("limiter kept after rate change")
+	}
+
+	var nilSched *Scheduler
+	if r := nilSched.ReaderContext(WithPackageDownload(context.Background(), false), src); r != io.Reader(src) {
+		t.Error("nil scheduler limited")
+	}
+}
@@ -2,22 +2,40 @@ package scheduler
 
 import (
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/ratelimit"
 )
 
 // Scheduler controls download rates based on configured time windows.
 // During sync windows, downloads run at full speed (or configured inside rate).
 // Outside windows, downloads are rate-limited to the outside rate.
 // Security updates can optionally bypass rate limits entirely.
+//
+// Date exceptions keep the recurring windows shut on holidays and freeze
+// periods, and one-off windows open outside the weekly pattern. Those added
+// at runtime are persisted in Config.StatePath.
 type Scheduler struct {
+	mu              sync.RWMutex
 	windows         []*ParsedWindow
+	windowConfigs   []Window
+	exceptions      []Exception // from the configuration
+	calendar        calendar    // added at runtime
+	statePath       string
 	timezone        *time.Location
 	outsideRate     int64 // bytes/sec outside window (0 = unlimited)
 	insideRate      int64 // bytes/sec inside window (0 = unlimited)
 	urgentFullSpeed bool
 	logger          *zap.Logger
+
+	// limiter enforces the current rate on package downloads; replaced
+	// when the rate changes
+	limiterMu   sync.Mutex
+	limiter     *ratelimit.Limiter
+	limiterRate int64
 }
 
 // Config holds scheduler configuration.
@@ -28,6 +46,11 @@ type Config struct {
 	OutsideWindowRate int64  // bytes/sec, 0 = unlimited
 	InsideWindowRate  int64  // bytes/sec, 0 = unlimited
 	UrgentFullSpeed   bool   // security updates always get full speed
+	Exceptions        []Exception
+
+	// StatePath is the file exceptions and one-off windows added at runtime
+	// are kept in ("" = not persisted). Ignored by Update.
+	StatePath string
 }
 
 // New creates a new Scheduler from configuration.
@@ -36,13 +59,33 @@ func New(cfg *Config, logger *zap.Logger) (*Scheduler, error) {
 	if cfg == nil || !cfg.Enabled {
 		return nil, nil
 	}
+	cal, err := loadCalendar(cfg.StatePath)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scheduler{logger: logger, calendar: cal, statePath: cfg.StatePath}
+	s.apply(cfg)
+	s.calendar.prune(time.Now().In(s.timezone))
+	return s, nil
+}
+
+// Update replaces the windows, timezone and rates of a running scheduler, as
+// when a fleet leader distributes new sync windows. Enabled is ignored: a
+// scheduler that exists stays active.
+func (s *Scheduler) Update(cfg *Config) {
+	if s == nil || cfg == nil {
+		return
+	}
+	s.apply(cfg)
+}
 
+func (s *Scheduler) apply(cfg *Config) {
 	// Parse timezone
 	tz := time.UTC
 	if cfg.Timezone != "" {
 		loc, err := time.LoadLocation(cfg.Timezone)
 		if err != nil {
-			logger.Warn("Invalid timezone, using UTC",
+			s.logger.Warn("Invalid timezone, using UTC",
 				zap.String("timezone", cfg.Timezone),
 				zap.Error(err))
 		} else {
@@ -52,38 +95,111 @@ func New(cfg *Config, logger *zap.Logger) (*Scheduler, error) {
 
 	// Parse windows
 	windows := make([]*ParsedWindow, 0, len(cfg.Windows))
+	windowConfigs := make([]Window, 0, len(cfg.Windows))
 	for i, w := range cfg.Windows {
 		pw, err := ParseWindow(w)
 		if err != nil {
-			logger.Warn("Invalid sync window, skipping",
+			s.logger.Warn("Invalid sync window, skipping",
 				zap.Int("index", i),
 				zap.Error(err))
 			continue
 		}
 		windows = append(windows, pw)
+		windowConfigs = append(windowConfigs, w)
+	}
+
+	exceptions := make([]Exception, 0, len(cfg.Exceptions))
+	for i, e := range cfg.Exceptions {
+		if err := e.Validate(); err != nil {
+			s.logger.Warn("Invalid schedule exception, skipping",
+				zap.Int("index", i),
+				zap.Error(err))
+			continue
+		}
+		e.ID = ""
+		exceptions = append(exceptions, e)
 	}
 
 	if len(windows) == 0 {
-		logger.Warn("No valid sync windows configured, scheduler will not rate limit")
+		s.logger.Warn("No valid sync windows configured, scheduler will not rate limit")
 	}
 
-	return &Scheduler{
-		windows:         windows,
-		timezone:        tz,
-		outsideRate:     cfg.OutsideWindowRate,
-		insideRate:      cfg.InsideWindowRate,
-		urgentFullSpeed: cfg.UrgentFullSpeed,
-		logger:          logger,
-	}, nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.windows = windows
+	s.windowConfigs = windowConfigs
+	s.exceptions = exceptions
+	s.timezone = tz
+	s.outsideRate = cfg.OutsideWindowRate
+	s.insideRate = cfg.InsideWindowRate
+	s.urgentFullSpeed = cfg.UrgentFullSpeed
 }
 
 // IsInWindow returns true if the current time is within any configured sync window.
 func (s *Scheduler) IsInWindow() bool {
-	if s == nil || len(s.windows) == 0 {
-		return true // No windows = always in window (no restrictions)
+	if s == nil {
+		return true
 	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.inWindowLocked()
+}
 
-	now := time.Now().In(s.timezone)
+// inWindowLocked reports whether now is inside a window. Caller holds s.mu.
+func (s *Scheduler) inWindowLocked() bool {
+	return s.periodLocked(time.Now().In(s.timezone)).inWindow
+}
+
+// period is the schedule in effect at one moment
+type period struct {
+	inWindow  bool
+	rate      int64
+	oneOff    string // ID of the open one-off window
+	exception string // name (or first date) of the exception in effect
+}
+
+// periodLocked works out the schedule at now: an open one-off window wins,
+// then a date exception shuts the recurring windows, then the recurring
+// windows apply. Caller holds s.mu.
+func (s *Scheduler) periodLocked(now time.Time) period {
+	for _, w := range s.calendar.Windows {
+		if w.contains(now) {
+			p := period{inWindow: true, rate: s.insideRate, oneOff: w.ID}
+			if w.Rate != nil {
+				p.rate = *w.Rate
+			}
+			return p
+		}
+	}
+	if e, ok := s.exceptionLocked(now); ok {
+		name := e.Name
+		if name == "" {
+			name = e.From
+		}
+		return period{rate: s.outsideRate, exception: name}
+	}
+	if s.inRecurringWindowLocked(now) {
+		return period{inWindow: true, rate: s.insideRate}
+	}
+	return period{rate: s.outsideRate}
+}
+
+// exceptionLocked returns the exception covering now's date, if any
+func (s *Scheduler) exceptionLocked(now time.Time) (Exception, bool) {
+	for _, list := range [][]Exception{s.exceptions, s.calendar.Exceptions} {
+		for _, e := range list {
+			if e.covers(now) {
+				return e, true
+			}
+		}
+	}
+	return Exception{}, false
+}
+
+func (s *Scheduler) inRecurringWindowLocked(now time.Time) bool {
+	if len(s.windows) == 0 {
+		return true // No windows = always in window (no restrictions)
+	}
 	for _, w := range s.windows {
 		if w.Contains(now) {
 			return true
@@ -99,43 +215,93 @@ func (s *Scheduler) GetCurrentRate(isUrgent bool) int64 {
 	if s == nil {
 		return 0 // No scheduler = unlimited
 	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
 	// Security updates bypass rate limits if configured
 	if isUrgent && s.urgentFullSpeed {
 		return 0
 	}
-
-	if s.IsInWindow() {
-		return s.insideRate
-	}
-	return s.outsideRate
+	return s.periodLocked(time.Now().In(s.timezone)).rate
 }
 
 // NextWindowStart returns when the next sync window opens.
 // Returns zero time if already in a window or no windows configured.
 func (s *Scheduler) NextWindowStart() time.Time {
-	if s == nil || len(s.windows) == 0 {
+	if s == nil {
 		return time.Time{}
 	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.nextWindowStartLocked()
+}
 
+func (s *Scheduler) nextWindowStartLocked() time.Time {
 	now := time.Now().In(s.timezone)
 
 	// Check if already in a window
-	if s.IsInWindow() {
+	if s.periodLocked(now).inWindow {
 		return time.Time{}
 	}
 
-	// Find the earliest next window start
+	// Find the earliest next window start, skipping recurring windows that
+	// fall on an exception date
 	var earliest time.Time
+	consider := func(t time.Time) {
+		if !t.IsZero() && (earliest.IsZero() || t.Before(earliest)) {
+			earliest = t
+		}
+	}
+	for _, w := range s.calendar.Windows {
+		if w.From.After(now) {
+			consider(w.From.In(s.timezone))
+		}
+	}
+	if len(s.windows) == 0 {
+		// Only an exception keeps the window shut: it opens when that ends
+		if e, ok := s.exceptionLocked(now); ok {
+			consider(s.dayAfterExceptionsLocked(e))
+		}
+		return earliest
+	}
 	for _, w := range s.windows {
-		next := w.NextStart(now)
-		if !next.IsZero() && (earliest.IsZero() || next.Before(earliest)) {
-			earliest = next
+		from := now
+		// Bounded: exceptions cover finitely many days
+		for range 400 {
+			next := w.NextStart(from)
+			if next.IsZero() {
+				break
+			}
+			if _, ok := s.exceptionLocked(next); !ok {
+				consider(next)
+				break
+			}
+			from = next
 		}
 	}
 	return earliest
 }
 
+// dayAfterExceptionsLocked returns the start of the first day after e not
+// covered by an exception
+func (s *Scheduler) dayAfterExceptionsLocked(e Exception) time.Time {
+	last := e.To
+	if last == "" {
+		last = e.From
+	}
+	day, err := time.ParseInLocation(dateLayout, last, s.timezone)
+	if err != nil {
+		return time.Time{}
+	}
+	for range 400 {
+		day = day.AddDate(0, 0, 1)
+		if _, ok := s.exceptionLocked(day); !ok {
+			return day
+		}
+	}
+	return time.Time{}
+}
+
 // IsSecurityUpdate checks if a URL appears to be a security update.
 func IsSecurityUpdate(url string) bool {
 	lowerURL := strings.ToLower(url)
@@ -145,6 +311,20 @@ func IsSecurityUpdate(url string) bool {
 		strings.Contains(lowerURL, "/updates/")
 }
 
+// IsSecurityOrigin reports whether a package indexed under repo (e.g.
+// "security.debian.org/debian-security") and suite comes from a security
+// pocket: a "-security" suite, the "<codename>/updates" suites of older
+// Debian releases, or a security archive. Pool URLs do not name the suite,
+// so this catches security updates IsSecurityUpdate cannot see.
+func IsSecurityOrigin(repo, suite string) bool {
+	suite = strings.ToLower(suite)
+	if strings.HasSuffix(suite, "-security") || strings.HasSuffix(suite, "/updates") {
+		return true
+	}
+	host, path, _ := strings.Cut(strings.ToLower(repo), "/")
+	return strings.HasPrefix(host, "security.") || strings.HasPrefix(path, "debian-security")
+}
+
 // Status returns the current scheduler status for monitoring.
 type Status struct {
 	InWindow       bool
@@ -152,6 +332,8 @@ type Status struct {
 	NextWindowOpen time.Time // zero if in window or no windows
 	Timezone       string
 	WindowCount    int
+	OneOffWindow   string `json:",omitempty"` // ID of the open one-off window
+	Exception      string `json:",omitempty"` // exception keeping windows shut
 }
 
 // Status returns the current scheduler status.
@@ -163,19 +345,17 @@ func (s *Scheduler) Status() Status {
 		}
 	}
 
-	inWindow := s.IsInWindow()
-	var currentRate int64
-	if inWindow {
-		currentRate = s.insideRate
-	} else {
-		currentRate = s.outsideRate
-	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
+	p := s.periodLocked(time.Now().In(s.timezone))
 	return Status{
-		InWindow:       inWindow,
-		CurrentRate:    currentRate,
-		NextWindowOpen: s.NextWindowStart(),
+		InWindow:       p.inWindow,
+		CurrentRate:    p.rate,
+		NextWindowOpen: s.nextWindowStartLocked(),
 		Timezone:       s.timezone.String(),
 		WindowCount:    len(s.windows),
+		OneOffWindow:   p.oneOff,
+		Exception:      p.exception,
 	}
 }
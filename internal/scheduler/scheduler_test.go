@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -305,6 +306,35 @@ func TestSchedulerGetCurrentRate(t *testing.T) {
 	}
 }
 
+func TestSchedulerUpdate(t *testing.T) {
+	s, err := New(&Config{Enabled: true, OutsideWindowRate: 100 * 1024}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !s.IsInWindow() {
+		t.Fatal("scheduler with no windows should be in window")
+	}
+
+	// A window that never contains "now": the day before today, one minute long.
+	yesterday := strings.ToLower(time.Now().UTC().AddDate(0, 0, -1).Weekday().String())
+	s.Update(&Config{
+		Windows:           []Window{{Days: []string{yesterday}, StartTime: "00:00", EndTime: "00:01"}},
+		Timezone:          "UTC",
+		OutsideWindowRate: 50 * 1024,
+	})
+
+	status := s.Status()
+	if status.WindowCount != 1 || status.Timezone != "UTC" {
+		t.Fatalf("Status after Update = %+v", status)
+	}
+	if s.IsInWindow() {
+		t.Fatal("updated window should not contain now")
+	}
+	if rate := s.GetCurrentRate(false); rate != 50*1024 {
+		t.Errorf("GetCurrentRate after Update = %d, want %d", rate, 50*1024)
+	}
+}
+
 func TestIsSecurityUpdate(t *testing.T) {
 	tests := []struct {
 		url  string
@@ -341,6 +371,29 @@ func TestIsSecurityUpdate(t *testing.T) {
 	}
 }
 
+func TestIsSecurityOrigin(t *testing.T) {
+	tests := []struct {
+		repo, suite string
+		want        bool
+	}{
+		{"deb.debian.org/debian-security", "bookworm-security", true},
+		{"security.debian.org/debian-security", "", true},
+		{"security.debian.org", "buster/updates", true},
+		{"security.ubuntu.com/ubuntu", "noble", true},
+		{"archive.ubuntu.com/ubuntu", "noble-security", true},
+		{"archive.ubuntu.com/ubuntu", "noble-updates", false},
+		{"deb.debian.org/debian", "bookworm-updates", false},
+		{"deb.debian.org/debian", "bookworm", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsSecurityOrigin(tt.repo, tt.suite); got != tt.want {
+			t.Errorf("IsSecurityOrigin(%q, %q) = %v, want %v", tt.repo, tt.suite, got, tt.want)
+		}
+	}
+}
+
 func TestSchedulerStatus(t *testing.T) {
 	logger := zap.NewNop()
 	cfg := &Config{
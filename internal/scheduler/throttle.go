@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"context"
+	"io"
+
+	"github.com/debswarm/debswarm/internal/ratelimit"
+)
+
+type downloadKey struct{}
+
+// WithPackageDownload marks ctx as a package download, which the
+// scheduler's rate applies to; urgent is set for security updates. Other
+// traffic, such as index files, is not limited.
+func WithPackageDownload(ctx context.Context, urgent bool) context.Context {
+	return context.WithValue(ctx, downloadKey{}, urgent)
+}
+
+// ReaderContext returns r limited to the current rate when ctx is a package
+// download, for mirror and peer transfers alike. All downloads share one
+// budget. A transfer keeps the rate that applied when it started.
+func (s *Scheduler) ReaderContext(ctx context.Context, r io.Reader) io.Reader {
+	if s == nil {
+		return r
+	}
+	urgent, ok := ctx.Value(downloadKey{}).(bool)
+	if !ok {
+		return r
+	}
+	rate := s.GetCurrentRate(urgent)
+	if rate <= 0 {
+		return r
+	}
+	return s.limiterFor(rate).ReaderContext(ctx, r)
+}
+
+// limiterFor returns the shared limiter, replaced if the rate changed
+func (s *Scheduler) limiterFor(rate int64) *ratelimit.Limiter {
+	s.limiterMu.Lock()
+	defer s.limiterMu.Unlock()
+	if s.limiter == nil || s.limiterRate != rate {
+		s.limiter = ratelimit.New(rate)
+		s.limiterRate = rate
+	}
+	return s.limiter
+}
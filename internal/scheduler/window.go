@@ -10,9 +10,9 @@ import (
 
 // Window represents a configured time window for sync operations.
 type Window struct {
-	Days      []string `toml:"days"`       // "monday", "tuesday", etc. or "weekday", "weekend"
-	StartTime string   `toml:"start_time"` // "09:00" (24h format)
-	EndTime   string   `toml:"end_time"`   // "17:00"
+	Days      []string `toml:"days" json:"days"`             // "monday", "tuesday", etc. or "weekday", "weekend"
+	StartTime string   `toml:"start_time" json:"start_time"` // "09:00" (24h format)
+	EndTime   string   `toml:"end_time" json:"end_time"`     // "17:00"
 }
 
 // ParsedWindow is a pre-parsed time window for efficient evaluation.
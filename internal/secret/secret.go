@@ -0,0 +1,187 @@
+// Package secret expands references to secrets kept outside the config
+// file, such as a mirror's access token, so the file itself can be shared
+// and shown without them. A reference is written ${source:name}:
+//
+//	${env:NAME}      the environment variable NAME
+//	${file:/path}    the contents of a file, e.g. a systemd credential
+//	${keyring:NAME}  the Secret Service item with service "debswarm" and
+//	                 account NAME, read with secret-tool
+//
+// Trailing newlines of files and keyring items are dropped.
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Sources of secrets
+const (
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceKeyring = "keyring"
+)
+
+// KeyringService is the service attribute of keyring items
+const KeyringService = "debswarm"
+
+// lookupTimeout bounds a keyring lookup
+const lookupTimeout = 10 * time.Second
+
+// ErrNotFound is returned when a referenced secret does not exist.
+var ErrNotFound = errors.New("secret not found")
+
+// Ref is a reference to a secret.
+type Ref struct {
+	Source string // SourceEnv, SourceFile or SourceKeyring
+	Name   string
+}
+
+func (r Ref) String() string {
+	return "${" + r.Source + ":" + r.Name + "}"
+}
+
+// segment is a part of a template: literal text or a reference.
+type segment struct {
+	text string
+	ref  *Ref
+}
+
+// parse splits template into literal text and references.
+func parse(template string) ([]segment, error) {
+	var segs []segment
+	rest := template
+	for {
+		i := strings.Index(rest, "${")
+		if i < 0 {
+			if rest != "" {
+				segs = append(segs, segment{text: rest})
+			}
+			return segs, nil
+		}
+		if i > 0 {
+			segs = append(segs, segment{text: rest[:i]})
+		}
+		end := strings.IndexByte(rest[i:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated reference in %q", template)
+		}
+		body := rest[i+2 : i+end]
+		source, name, ok := strings.Cut(body, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid reference ${%s} (use ${env:NAME}, ${file:PATH} or ${keyring:NAME})", body)
+		}
+		switch source {
+		case SourceEnv, SourceFile, SourceKeyring:
+		default:
+			return nil, fmt.Errorf("unknown secret source %q in ${%s} (use env, file or keyring)", source, body)
+		}
+		segs = append(segs, segment{ref: &Ref{Source: source, Name: name}})
+		rest = rest[i+end+1:]
+	}
+}
+
+// Check reports whether template is well formed, without reading the
+// secrets it refers to.
+func Check(template string) error {
+	_, err := parse(template)
+	return err
+}
+
+// Refs returns the references in template.
+func Refs(template string) ([]Ref, error) {
+	segs, err := parse(template)
+	if err != nil {
+		return nil, err
+	}
+	var refs []Ref
+	for _, s := range segs {
+		if s.ref != nil {
+			refs = append(refs, *s.ref)
+		}
+	}
+	return refs, nil
+}
+
+// Resolver reads secrets.
+type Resolver struct {
+	getenv   func(string) (string, bool)
+	readFile func(string) ([]byte, error)
+	keyring  func(ctx context.Context, name string) (string, error)
+}
+
+// NewResolver returns a Resolver reading the process environment, the file
+// system and the user's Secret Service keyring.
+func NewResolver() *Resolver {
+	return &Resolver{getenv: os.LookupEnv, readFile: os.ReadFile, keyring: secretTool}
+}
+
+// Expand returns template with each reference replaced by the secret.
+func (r *Resolver) Expand(ctx context.Context, template string) (string, error) {
+	segs, err := parse(template)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, s := range segs {
+		if s.ref == nil {
+			b.WriteString(s.text)
+			continue
+		}
+		v, err := r.lookup(ctx, *s.ref)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", s.ref, err)
+		}
+		b.WriteString(v)
+	}
+	return b.String(), nil
+}
+
+func (r *Resolver) lookup(ctx context.Context, ref Ref) (string, error) {
+	switch ref.Source {
+	case SourceEnv:
+		v, ok := r.getenv(ref.Name)
+		if !ok {
+			return "", ErrNotFound
+		}
+		return v, nil
+	case SourceFile:
+		data, err := r.readFile(ref.Name)
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrNotFound
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+		defer cancel()
+		v, err := r.keyring(ctx, ref.Name)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(v, "\r\n"), nil
+	}
+}
+
+// secretTool looks the item up with secret-tool, which ships with
+// libsecret, so no D-Bus library is needed. secret-tool exits 1 without
+// output when there is no such item.
+func secretTool(ctx context.Context, name string) (string, error) {
+	out, err := exec.CommandContext(ctx, "secret-tool", "lookup",
+		"service", KeyringService, "account", name).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && len(out) == 0 {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("secret-tool: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,71 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r := &Resolver{
+		getenv: func(name string) (string, bool) {
+			if name == "MIRROR_TOKEN" {
+				return "env-secret", true
+			}
+			return "", false
+		},
+		readFile: os.ReadFile,
+		keyring: func(_ context.Context, name string) (string, error) {
+			if name == "corp-mirror" {
+				return "keyring-secret\n", nil
+			}
+			return "", ErrNotFound
+		},
+	}
+
+	tests := []struct {
+		template string
+		want     string
+		err      error
+	}{
+		{"plain", "plain", nil},
+		{"", "", nil},
+		{"Bearer ${env:MIRROR_TOKEN}", "Bearer env-secret", nil},
+		{"${file:" + tokenFile + "}", "file-secret", nil},
+		{"${keyring:corp-mirror}:${env:MIRROR_TOKEN}", "keyring-secret:env-secret", nil},
+		{"${env:MISSING}", "", ErrNotFound},
+		{"${file:" + filepath.Join(dir, "missing") + "}", "", ErrNotFound},
+		{"${keyring:other}", "", ErrNotFound},
+	}
+	for _, tt := range tests {
+		got, err := r.Expand(context.Background(), tt.template)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("Expand(%q) = %q, %v; want %q, %v", tt.template, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, template := range []string{"plain", "Bearer ${env:TOKEN}", "${file:/run/credentials/debswarm/token}"} {
+		if err := Check(template); err != nil {
+			t.Errorf("Check(%q) = %v", template, err)
+		}
+	}
+	for _, template := range []string{"${env:TOKEN", "${TOKEN}", "${env:}", "${vault:token}"} {
+		if err := Check(template); err == nil {
+			t.Errorf("Check(%q) accepted", template)
+		}
+	}
+
+	refs, err := Refs("${env:A} and ${keyring:b}")
+	if err != nil || len(refs) != 2 || refs[0] != (Ref{SourceEnv, "A"}) || refs[1].String() != "${keyring:b}" {
+		t.Errorf("Refs = %v, %v", refs, err)
+	}
+}
@@ -0,0 +1,210 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Reasons a MirrorPolicy refuses a target, as recorded in Decision.Reason
+// and the audit log.
+const (
+	ReasonBlockedAddress = "blocked_address"  // internal, private or metadata address (SSRF)
+	ReasonNotRepository  = "not_repository"   // URL does not look like an APT repository file
+	ReasonHostNotAllowed = "host_not_allowed" // host is neither a known mirror nor allowed
+	ReasonPortNotAllowed = "port_not_allowed" // CONNECT to a port that is not allowed
+	ReasonInvalidTarget  = "invalid_target"   // CONNECT target has no host
+)
+
+// PolicyConfig configures a MirrorPolicy.
+type PolicyConfig struct {
+	// AllowedHosts are repository hosts allowed beyond the built-in Debian,
+	// Ubuntu and Mint mirrors; subdomains match too. A host entry never lifts
+	// the block on internal addresses.
+	AllowedHosts []string
+
+	// AllowedCIDRs are address ranges allowed even when private, loopback or
+	// link-local. An IP address in one needs no AllowedHosts entry, and a
+	// hostname that resolves into one passes the CONNECT rebinding check.
+	AllowedCIDRs []string
+
+	// AllowedPorts are ports CONNECT tunnels may use besides 80 and 443.
+	// Plain HTTP mirror URLs may use any port.
+	AllowedPorts []int
+
+	// AllowPrivate lifts the block on private and loopback addresses (and
+	// "localhost"). Link-local addresses and metadata hostnames, where cloud
+	// metadata services live, stay blocked unless in AllowedCIDRs.
+	AllowPrivate bool
+}
+
+// MirrorPolicy decides which upstream URLs and CONNECT targets the proxy
+// may reach. It is immutable; build a new one to change it.
+type MirrorPolicy struct {
+	hosts        []string
+	cidrs        []*net.IPNet
+	ports        map[string]bool
+	allowPrivate bool
+}
+
+// Decision is the outcome of a policy check. Reason is empty when Allowed.
+type Decision struct {
+	Allowed bool
+	Reason  string
+}
+
+var allowed = Decision{Allowed: true}
+
+func refused(reason string) Decision {
+	return Decision{Reason: reason}
+}
+
+// DefaultMirrorPolicy is the policy with nothing configured: the built-in
+// mirrors only, on any public address.
+var DefaultMirrorPolicy = &MirrorPolicy{ports: map[string]bool{"80": true, "443": true}}
+
+// NewMirrorPolicy builds a policy from cfg, failing on a malformed CIDR or
+// port.
+func NewMirrorPolicy(cfg PolicyConfig) (*MirrorPolicy, error) {
+	p := &MirrorPolicy{
+		ports:        map[string]bool{"80": true, "443": true},
+		allowPrivate: cfg.AllowPrivate,
+	}
+	for _, h := range cfg.AllowedHosts {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			p.hosts = append(p.hosts, h)
+		}
+	}
+	for _, c := range cfg.AllowedCIDRs {
+		_, ipnet, err := net.ParseCIDR(strings.TrimSpace(c))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", c, err)
+		}
+		p.cidrs = append(p.cidrs, ipnet)
+	}
+	for _, port := range cfg.AllowedPorts {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d", port)
+		}
+		p.ports[strconv.Itoa(port)] = true
+	}
+	return p, nil
+}
+
+// CheckURL decides whether the proxy may fetch rawURL from upstream: the
+// host must not be an internal address, the path must look like an APT
+// repository file, and the host must be a known mirror, an allowed host or
+// an address in an allowed range.
+func (p *MirrorPolicy) CheckURL(rawURL string) Decision {
+	host := extractHost(rawURL)
+	if host != "" && p.BlocksHost(host) {
+		return refused(ReasonBlockedAddress)
+	}
+	if !IsDebianRepoURL(rawURL) {
+		return refused(ReasonNotRepository)
+	}
+	if host == "" || !p.allowsHost(host) {
+		return refused(ReasonHostNotAllowed)
+	}
+	return allowed
+}
+
+// CheckHostURL is CheckURL without the repository path check, for URLs in
+// the proxy's generic mode, whose adapters decide what a path is. The host
+// must still be a known mirror, an allowed host or in an allowed range.
+func (p *MirrorPolicy) CheckHostURL(rawURL string) Decision {
+	host := extractHost(rawURL)
+	switch {
+	case host == "":
+		return refused(ReasonInvalidTarget)
+	case p.BlocksHost(host):
+		return refused(ReasonBlockedAddress)
+	case !p.allowsHost(host):
+		return refused(ReasonHostNotAllowed)
+	}
+	return allowed
+}
+
+// CheckConnect decides whether the proxy may open a CONNECT tunnel to
+// hostPort. A target without a port is taken as port 443.
+func (p *MirrorPolicy) CheckConnect(hostPort string) Decision {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host, port = hostPort, "443"
+	}
+	if host == "" {
+		return refused(ReasonInvalidTarget)
+	}
+	if !p.ports[port] {
+		return refused(ReasonPortNotAllowed)
+	}
+	if p.BlocksHost(host) {
+		return refused(ReasonBlockedAddress)
+	}
+	if !p.allowsHost(host) {
+		return refused(ReasonHostNotAllowed)
+	}
+	return allowed
+}
+
+// BlocksHost reports whether host (without port) is an internal address or
+// hostname the policy refuses, including hex, octal and decimal encodings.
+func (p *MirrorPolicy) BlocksHost(host string) bool {
+	lower := strings.ToLower(host)
+	if ip := parseIPPermissive(lower); ip != nil {
+		return p.BlocksIP(ip)
+	}
+	for _, pattern := range blockedHostnamePatterns {
+		if strings.HasSuffix(pattern, ".") {
+			if strings.HasPrefix(lower, pattern) {
+				return true
+			}
+		} else if !p.allowPrivate && (lower == pattern || strings.HasSuffix(lower, "."+pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlocksIP reports whether ip is an address the policy refuses, as for a
+// hostname's resolved address.
+func (p *MirrorPolicy) BlocksIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if p.inAllowedCIDR(ip) {
+		return false
+	}
+	if p.allowPrivate {
+		return ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()
+	}
+	return IsBlockedIP(ip)
+}
+
+// allowsHost reports whether host is a known mirror, an allowed host, or an
+// address in an allowed range.
+func (p *MirrorPolicy) allowsHost(host string) bool {
+	lower := strings.ToLower(host)
+	if isKnownDebianMirror(lower) {
+		return true
+	}
+	for _, h := range p.hosts {
+		if matchesHost(lower, h) {
+			return true
+		}
+	}
+	if ip := parseIPPermissive(lower); ip != nil {
+		return p.inAllowedCIDR(ip)
+	}
+	return false
+}
+
+func (p *MirrorPolicy) inAllowedCIDR(ip net.IP) bool {
+	for _, c := range p.cidrs {
+		if c.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
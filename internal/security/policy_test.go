@@ -0,0 +1,119 @@
+package security
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMirrorPolicy(t *testing.T) {
+	policy, err := NewMirrorPolicy(PolicyConfig{
+		AllowedHosts: []string{"Mirror.Corp.Example"},
+		AllowedCIDRs: []string{"10.20.0.0/16", "fd12::/16"},
+		AllowedPorts: []int{8443},
+	})
+	if err != nil {
+		t.Fatalf("NewMirrorPolicy: %v", err)
+	}
+
+	urls := []struct {
+		url  string
+		want string // refusal reason, "" if allowed
+	}{
+		{"http://deb.debian.org/debian/dists/bookworm/Release", ""},
+		{"http://apt.mirror.corp.example:8080/debian/pool/main/h/hello/hello.deb", ""},
+		{"http://10.20.1.5:3142/debian/dists/stable/InRelease", ""},
+		{"http://[fd12::5]/debian/dists/stable/InRelease", ""},
+		{"http://10.30.1.5/debian/dists/stable/InRelease", ReasonBlockedAddress},
+		{"http://169.254.169.254/debian/dists/stable/Release", ReasonBlockedAddress},
+		{"http://localhost/debian/dists/stable/Release", ReasonBlockedAddress},
+		{"http://mirror.corp.example/secrets.txt", ReasonNotRepository},
+		{"http://other.example/debian/dists/stable/Release", ReasonHostNotAllowed},
+		{"http://203.0.113.9/debian/dists/stable/Release", ReasonHostNotAllowed},
+	}
+	for _, tt := range urls {
+		if got := policy.CheckURL(tt.url); got.Allowed != (tt.want == "") || got.Reason != tt.want {
+			t.Errorf("CheckURL(%q) = %+v, want reason %q", tt.url, got, tt.want)
+		}
+	}
+
+	hostURLs := []struct {
+		url  string
+		want string
+	}{
+		{"https://mirror.corp.example/fedora/Packages/h/hello.rpm", ""},
+		{"https://10.20.1.5/nix/nar/abc.nar.xz", ""},
+		{"https://10.30.1.5/nix/nar/abc.nar.xz", ReasonBlockedAddress},
+		{"https://other.example/fedora/Packages/h/hello.rpm", ReasonHostNotAllowed},
+		{"not a url", ReasonInvalidTarget},
+	}
+	for _, tt := range hostURLs {
+		if got := policy.CheckHostURL(tt.url); got.Allowed != (tt.want == "") || got.Reason != tt.want {
+			t.Errorf("CheckHostURL(%q) = %+v, want reason %q", tt.url, got, tt.want)
+		}
+	}
+
+	targets := []struct {
+		hostPort string
+		want     string
+	}{
+		{"deb.debian.org:443", ""},
+		{"mirror.corp.example:8443", ""},
+		{"mirror.corp.example", ""},
+		{"10.20.1.5:443", ""},
+		{"mirror.corp.example:22", ReasonPortNotAllowed},
+		{"192.168.1.1:443", ReasonBlockedAddress},
+		{"other.example:443", ReasonHostNotAllowed},
+		{":443", ReasonInvalidTarget},
+	}
+	for _, tt := range targets {
+		if got := policy.CheckConnect(tt.hostPort); got.Allowed != (tt.want == "") || got.Reason != tt.want {
+			t.Errorf("CheckConnect(%q) = %+v, want reason %q", tt.hostPort, got, tt.want)
+		}
+	}
+
+	if policy.BlocksIP(net.ParseIP("10.20.3.4")) {
+		t.Error("BlocksIP(10.20.3.4) = true, want false (allowed CIDR)")
+	}
+	if !policy.BlocksIP(net.ParseIP("10.21.3.4")) {
+		t.Error("BlocksIP(10.21.3.4) = false, want true")
+	}
+}
+
+func TestMirrorPolicy_AllowPrivate(t *testing.T) {
+	policy, err := NewMirrorPolicy(PolicyConfig{AllowPrivate: true, AllowedHosts: []string{"mirror.lan"}})
+	if err != nil {
+		t.Fatalf("NewMirrorPolicy: %v", err)
+	}
+
+	tests := []struct {
+		host    string
+		blocked bool
+	}{
+		{"192.168.1.10", false},
+		{"127.0.0.1", false},
+		{"localhost", false},
+		{"169.254.169.254", true},
+		{"0xa9fea9fe", true},
+		{"metadata.google.internal", true},
+		{"fe80::1", true},
+	}
+	for _, tt := range tests {
+		if got := policy.BlocksHost(tt.host); got != tt.blocked {
+			t.Errorf("BlocksHost(%q) = %v, want %v", tt.host, got, tt.blocked)
+		}
+	}
+
+	// Private addresses are reachable but still need to be allowed
+	if got := policy.CheckURL("http://192.168.1.10/debian/dists/stable/Release"); got.Reason != ReasonHostNotAllowed {
+		t.Errorf("CheckURL(private IP) = %+v, want %s", got, ReasonHostNotAllowed)
+	}
+}
+
+func TestNewMirrorPolicy_Invalid(t *testing.T) {
+	if _, err := NewMirrorPolicy(PolicyConfig{AllowedCIDRs: []string{"10.0.0.0"}}); err == nil {
+		t.Error("expected error for CIDR without prefix length")
+	}
+	if _, err := NewMirrorPolicy(PolicyConfig{AllowedPorts: []int{70000}}); err == nil {
+		t.Error("expected error for out-of-range port")
+	}
+}
@@ -9,7 +9,7 @@ import (
 )
 
 // blockedHostnamePatterns contains non-IP hostname patterns that should never be accessed.
-// IP-based blocking is handled separately by isBlockedIP using net.IP methods,
+// IP-based blocking is handled separately by MirrorPolicy.BlocksIP using net.IP methods,
 // which correctly handles hex/octal/decimal encoded addresses.
 var blockedHostnamePatterns = []string{
 	"localhost", // Loopback hostname
@@ -25,7 +25,7 @@ func IsBlockedHost(rawURL string) bool {
 	if host == "" {
 		return false
 	}
-	return isBlockedHostOrIP(host)
+	return DefaultMirrorPolicy.BlocksHost(host)
 }
 
 // IsBlockedIP checks if an IP address is in a blocked range
@@ -39,33 +39,6 @@ func IsBlockedIP(ip net.IP) bool {
 		ip.IsLinkLocalMulticast() || ip.IsUnspecified()
 }
 
-// isBlockedHostOrIP checks if a host string (no port) is a blocked target.
-func isBlockedHostOrIP(host string) bool {
-	lower := strings.ToLower(host)
-
-	// Try to parse as IP (handles standard and alternate encodings like hex/octal/decimal)
-	if ip := parseIPPermissive(lower); ip != nil {
-		return IsBlockedIP(ip)
-	}
-
-	// Hostname-based checks
-	for _, pattern := range blockedHostnamePatterns {
-		if strings.HasSuffix(pattern, ".") {
-			// Prefix pattern (e.g., "metadata.") — matches hosts starting with this
-			if strings.HasPrefix(lower, pattern) {
-				return true
-			}
-		} else {
-			// Exact or subdomain pattern (e.g., "localhost" matches "localhost" and "sub.localhost")
-			if lower == pattern || strings.HasSuffix(lower, "."+pattern) {
-				return true
-			}
-		}
-	}
-
-	return false
-}
-
 // parseIPPermissive parses an IP address string, including alternate encodings
 // that standard net.ParseIP doesn't handle:
 //   - Hex: 0x7f000001 → 127.0.0.1
@@ -242,38 +215,8 @@ func IsAllowedMirrorURL(url string) bool {
 // allowing additional configured hosts beyond the built-in list.
 // The URL must not target internal services and must look like a Debian repository.
 func IsAllowedMirrorURLWithHosts(url string, allowedHosts []string) bool {
-	if IsBlockedHost(url) {
-		return false
-	}
-	// Must have Debian-style URL patterns
-	if !IsDebianRepoURL(url) {
-		return false
-	}
-	// Check if host is in the allowed list (built-in or configured)
-	return isAllowedHost(url, allowedHosts)
-}
-
-// isAllowedHost checks if a URL's host is in the allowed list
-func isAllowedHost(rawURL string, additionalHosts []string) bool {
-	host := extractHost(rawURL)
-	if host == "" {
-		return false
-	}
-
-	// Check built-in mirror patterns
-	if isKnownDebianMirror(host) {
-		return true
-	}
-
-	// Check additional configured hosts (exact or subdomain match)
-	for _, allowed := range additionalHosts {
-		allowedLower := strings.ToLower(allowed)
-		if matchesHost(host, allowedLower) {
-			return true
-		}
-	}
-
-	return false
+	policy, _ := NewMirrorPolicy(PolicyConfig{AllowedHosts: allowedHosts})
+	return policy.CheckURL(url).Allowed
 }
 
 // knownMirrorDomains contains domain names for known Debian/Ubuntu/Mint mirrors.
@@ -321,49 +264,14 @@ func IsAllowedConnectTarget(hostPort string) bool {
 // checking both built-in mirrors and additional configured hosts.
 // Returns true only for allowed hosts on ports 443 or 80.
 func IsAllowedConnectTargetWithHosts(hostPort string, allowedHosts []string) bool {
-	host, port, err := net.SplitHostPort(hostPort)
-	if err != nil {
-		// If no port specified, assume it's just a host (unusual for CONNECT)
-		host = hostPort
-		port = "443"
-	}
-
-	// Only allow standard HTTPS/HTTP ports
-	if port != "443" && port != "80" {
-		return false
-	}
-
-	// Block private/internal hosts
-	if isBlockedConnectHost(host) {
-		return false
-	}
-
-	// Allow known mirrors or configured hosts
-	return isKnownMirrorOrAllowed(host, allowedHosts)
-}
-
-// isKnownMirrorOrAllowed checks if a host is a known mirror or in the allowed list
-func isKnownMirrorOrAllowed(host string, allowedHosts []string) bool {
-	if isKnownDebianMirror(host) {
-		return true
-	}
-
-	// Check additional configured hosts
-	lower := strings.ToLower(host)
-	for _, allowed := range allowedHosts {
-		allowedLower := strings.ToLower(allowed)
-		if lower == allowedLower || strings.HasSuffix(lower, "."+allowedLower) {
-			return true
-		}
-	}
-
-	return false
+	policy, _ := NewMirrorPolicy(PolicyConfig{AllowedHosts: allowedHosts})
+	return policy.CheckConnect(hostPort).Allowed
 }
 
 // isBlockedConnectHost checks if a host is a private/internal address.
 // Uses the same IP-aware and hostname-aware checks as IsBlockedHost.
 func isBlockedConnectHost(host string) bool {
-	return isBlockedHostOrIP(host)
+	return DefaultMirrorPolicy.BlocksHost(host)
 }
 
 // isKnownDebianMirror checks if a host matches known Debian/Ubuntu mirror patterns.
@@ -0,0 +1,193 @@
+package timeouts
+
+import (
+	"time"
+)
+
+// Profile classifies a peer by where it sits on the network, which sets the
+// transfer deadline it gets before any transfers with it have been observed.
+type Profile string
+
+const (
+	ProfileLAN Profile = "lan" // reached over a private address
+	ProfileWAN Profile = "wan" // reached over the internet or a relay
+)
+
+// Per-profile starting points for peers without observed transfers
+const (
+	LANFirstByte      = 2 * time.Second
+	WANFirstByte      = DefaultPeerFirstByte
+	LANBytesPerSecond = 4 * 1024 * 1024 // 4 MB/s: slow Wi-Fi
+	WANBytesPerSecond = 256 * 1024      // 256 KB/s: slow residential uplink
+
+	// PeerTransferMargin multiplies the expected transfer time
+	PeerTransferMargin = 2.0
+
+	// MaxPeerProfiles bounds the number of peers tracked; the least recently
+	// seen is dropped first
+	MaxPeerProfiles = 1024
+)
+
+// peerProfile tracks transfer performance for one peer
+type peerProfile struct {
+	profile      Profile
+	throughput   float64 // EMA of observed bytes per second, 0 until observed
+	successCount int64
+	timeoutCount int64
+	lastSeen     time.Time
+}
+
+// PeerStats reports what the manager has learned about a peer
+type PeerStats struct {
+	PeerID       string
+	Profile      Profile
+	Throughput   float64
+	SuccessCount int64
+	TimeoutCount int64
+	LastSeen     time.Time
+}
+
+// SetPeerProfile records whether a peer is on the LAN or across the WAN.
+// Learned throughput is kept when the profile changes.
+func (m *Manager) SetPeerProfile(peerID string, p Profile) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.peerLocked(peerID).profile = p
+}
+
+// RecordPeerTransfer records a completed transfer of size bytes from a peer
+func (m *Manager) RecordPeerTransfer(peerID string, size int64, duration time.Duration) {
+	if !m.config.AdaptiveEnabled || size <= 0 || duration <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.peerLocked(peerID)
+	rate := float64(size) / duration.Seconds()
+	if p.throughput == 0 {
+		p.throughput = rate
+	} else {
+		p.throughput = AdaptationAlpha*rate + (1-AdaptationAlpha)*p.throughput
+	}
+	p.successCount++
+}
+
+// RecordPeerTimeout records that a transfer from a peer missed its deadline.
+// The peer's expected throughput is halved, doubling the transfer part of
+// its next deadline.
+func (m *Manager) RecordPeerTimeout(peerID string) {
+	if !m.config.AdaptiveEnabled {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.peerLocked(peerID)
+	if p.throughput == 0 {
+		p.throughput = p.profile.bytesPerSecond()
+	}
+	p.throughput /= TimeoutMultiplier
+	p.timeoutCount++
+}
+
+// PeerTransferTimeout returns the deadline for transferring size bytes from
+// a peer: the profile's first-byte allowance plus the expected transfer time
+// at the peer's observed throughput (or the profile's default), with margin.
+func (m *Manager) PeerTransferTimeout(peerID string, sizeBytes int64) time.Duration {
+	m.mu.RLock()
+	p, ok := m.peers[peerID]
+	var profile Profile
+	var throughput float64
+	if ok {
+		profile, throughput = p.profile, p.throughput
+	}
+	m.mu.RUnlock()
+
+	if throughput <= 0 {
+		throughput = profile.bytesPerSecond()
+	}
+	timeout := profile.firstByte()
+	if sizeBytes > 0 {
+		timeout += time.Duration(float64(sizeBytes) / throughput * PeerTransferMargin * float64(time.Second))
+	}
+	return clampTimeout(timeout)
+}
+
+// GetPeerStats returns what has been learned about a peer, or nil
+func (m *Manager) GetPeerStats(peerID string) *PeerStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p, ok := m.peers[peerID]
+	if !ok {
+		return nil
+	}
+	return p.stats(peerID)
+}
+
+// GetAllPeerStats returns what has been learned about every tracked peer
+func (m *Manager) GetAllPeerStats() []*PeerStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make([]*PeerStats, 0, len(m.peers))
+	for id, p := range m.peers {
+		stats = append(stats, p.stats(id))
+	}
+	return stats
+}
+
+func (p *peerProfile) stats(peerID string) *PeerStats {
+	return &PeerStats{
+		PeerID:       peerID,
+		Profile:      p.profile,
+		Throughput:   p.throughput,
+		SuccessCount: p.successCount,
+		TimeoutCount: p.timeoutCount,
+		LastSeen:     p.lastSeen,
+	}
+}
+
+// peerLocked returns the profile for a peer, creating it and evicting the
+// least recently seen peer if needed. Caller must hold m.mu.
+func (m *Manager) peerLocked(peerID string) *peerProfile {
+	p, ok := m.peers[peerID]
+	if !ok {
+		if len(m.peers) >= MaxPeerProfiles {
+			m.evictOldestPeerLocked()
+		}
+		p = &peerProfile{profile: ProfileWAN}
+		m.peers[peerID] = p
+	}
+	p.lastSeen = time.Now()
+	return p
+}
+
+func (m *Manager) evictOldestPeerLocked() {
+	var oldestID string
+	var oldest time.Time
+	for id, p := range m.peers {
+		if oldestID == "" || p.lastSeen.Before(oldest) {
+			oldestID, oldest = id, p.lastSeen
+		}
+	}
+	delete(m.peers, oldestID)
+}
+
+func (p Profile) firstByte() time.Duration {
+	if p == ProfileLAN {
+		return LANFirstByte
+	}
+	return WANFirstByte
+}
+
+func (p Profile) bytesPerSecond() float64 {
+	if p == ProfileLAN {
+		return LANBytesPerSecond
+	}
+	return WANBytesPerSecond
+}
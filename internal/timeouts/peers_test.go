@@ -0,0 +1,103 @@
+package timeouts
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPeerTransferTimeout_Profiles(t *testing.T) {
+	m := NewManager(nil)
+	const size = 4 * 1024 * 1024
+
+	unknown := m.PeerTransferTimeout("unknown", size)
+	m.SetPeerProfile("wan", ProfileWAN)
+	if got := m.PeerTransferTimeout("wan", size); got != unknown {
+		t.Errorf("WAN peer = %v, want the unknown-peer default %v", got, unknown)
+	}
+
+	m.SetPeerProfile("lan", ProfileLAN)
+	lan := m.PeerTransferTimeout("lan", size)
+	if lan >= unknown {
+		t.Errorf("LAN peer timeout %v should be shorter than WAN %v", lan, unknown)
+	}
+	if want := LANFirstByte + 2*time.Second; lan != want {
+		t.Errorf("LAN peer = %v, want %v", lan, want)
+	}
+	if got := m.PeerTransferTimeout("lan", 0); got != LANFirstByte {
+		t.Errorf("LAN peer without size = %v, want %v", got, LANFirstByte)
+	}
+}
+
+func TestPeerTransferTimeout_Learns(t *testing.T) {
+	m := NewManager(nil)
+	const size = 4 * 1024 * 1024
+
+	m.SetPeerProfile("p", ProfileLAN)
+	before := m.PeerTransferTimeout("p", size)
+
+	// A fast peer earns a tighter deadline
+	m.RecordPeerTransfer("p", size, 100*time.Millisecond)
+	fast := m.PeerTransferTimeout("p", size)
+	if fast >= before {
+		t.Errorf("after fast transfer: %v, want < %v", fast, before)
+	}
+
+	// A missed deadline loosens it again
+	m.RecordPeerTimeout("p")
+	if got := m.PeerTransferTimeout("p", size); got <= fast {
+		t.Errorf("after timeout: %v, want > %v", got, fast)
+	}
+
+	stats := m.GetPeerStats("p")
+	if stats == nil || stats.SuccessCount != 1 || stats.TimeoutCount != 1 || stats.Profile != ProfileLAN {
+		t.Errorf("GetPeerStats = %+v", stats)
+	}
+	if m.GetPeerStats("missing") != nil {
+		t.Error("GetPeerStats for an unseen peer should be nil")
+	}
+}
+
+func TestPeerTransferTimeout_Clamped(t *testing.T) {
+	m := NewManager(nil)
+	if got := m.PeerTransferTimeout("slow", 1<<40); got != MaxTimeout {
+		t.Errorf("huge transfer = %v, want %v", got, MaxTimeout)
+	}
+}
+
+func TestPeerProfiles_AdaptiveDisabled(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AdaptiveEnabled = false
+	m := NewManager(cfg)
+
+	before := m.PeerTransferTimeout("p", 1<<20)
+	m.RecordPeerTransfer("p", 1<<20, time.Millisecond)
+	m.RecordPeerTimeout("p")
+	if got := m.PeerTransferTimeout("p", 1<<20); got != before {
+		t.Errorf("timeout changed with adaptation disabled: %v -> %v", before, got)
+	}
+}
+
+func TestPeerProfiles_Bounded(t *testing.T) {
+	m := NewManager(nil)
+	m.SetPeerProfile("first", ProfileLAN)
+	time.Sleep(time.Millisecond)
+	for i := 0; i < MaxPeerProfiles; i++ {
+		m.SetPeerProfile(fmt.Sprintf("peer-%d", i), ProfileWAN)
+	}
+	if len(m.peers) != MaxPeerProfiles {
+		t.Errorf("tracked %d peers, want %d", len(m.peers), MaxPeerProfiles)
+	}
+	if m.GetPeerStats("first") != nil {
+		t.Error("least recently seen peer was not evicted")
+	}
+}
+
+func TestReset_ForgetsPeers(t *testing.T) {
+	m := NewManager(nil)
+	m.SetPeerProfile("p", ProfileLAN)
+	m.Reset()
+	if m.GetPeerStats("p") != nil {
+		t.Error("Reset kept peer profiles")
+	}
+}
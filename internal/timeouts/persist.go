@@ -0,0 +1,123 @@
+package timeouts
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MaxSnapshotAge is how old a saved snapshot may be and still be loaded.
+// Older learned values likely describe a different network.
+const MaxSnapshotAge = 7 * 24 * time.Hour
+
+// snapshot is the on-disk form of a Manager's learned state
+type snapshot struct {
+	SavedAt    time.Time                       `json:"saved_at"`
+	Operations map[Operation]operationSnapshot `json:"operations"`
+	Peers      map[string]peerSnapshot         `json:"peers,omitempty"`
+}
+
+type operationSnapshot struct {
+	Current time.Duration `json:"current"`
+	Average time.Duration `json:"average"`
+}
+
+type peerSnapshot struct {
+	Profile    Profile   `json:"profile"`
+	Throughput float64   `json:"throughput"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// Save writes the learned timeouts and peer profiles to path atomically
+// (temp file + rename), so a restart starts from them instead of defaults.
+func (m *Manager) Save(path string) error {
+	m.mu.RLock()
+	snap := snapshot{
+		SavedAt:    time.Now(),
+		Operations: make(map[Operation]operationSnapshot, len(m.timeouts)),
+		Peers:      make(map[string]peerSnapshot, len(m.peers)),
+	}
+	for op, t := range m.timeouts {
+		snap.Operations[op] = operationSnapshot{Current: t.currentTimeout, Average: t.avgDuration}
+	}
+	for id, p := range m.peers {
+		snap.Peers[id] = peerSnapshot{Profile: p.profile, Throughput: p.throughput, LastSeen: p.lastSeen}
+	}
+	m.mu.RUnlock()
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".timeouts-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// Load restores state written by Save. A missing file is not an error, and
+// a snapshot older than MaxSnapshotAge is ignored. Restored timeouts never
+// drop below the configured base and are clamped to the timeout bounds.
+// Returns whether anything was restored.
+func (m *Manager) Load(path string) (bool, error) {
+	if !m.config.AdaptiveEnabled {
+		return false, nil
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path is in the daemon's data directory
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return false, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if time.Since(snap.SavedAt) > MaxSnapshotAge {
+		return false, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for op, saved := range snap.Operations {
+		t, ok := m.timeouts[op]
+		if !ok || saved.Current <= 0 {
+			continue
+		}
+		t.currentTimeout = clampTimeout(max(saved.Current, t.baseTimeout))
+		t.avgDuration = max(saved.Average, 0)
+	}
+	for id, saved := range snap.Peers {
+		if (saved.Profile != ProfileLAN && saved.Profile != ProfileWAN) || saved.Throughput < 0 {
+			continue
+		}
+		if len(m.peers) >= MaxPeerProfiles {
+			break
+		}
+		m.peers[id] = &peerProfile{
+			profile:    saved.Profile,
+			throughput: saved.Throughput,
+			lastSeen:   saved.LastSeen,
+		}
+	}
+	return true, nil
+}
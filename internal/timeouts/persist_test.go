@@ -0,0 +1,138 @@
+package timeouts
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timeouts.json")
+
+	m := NewManager(nil)
+	for i := 0; i < 3; i++ {
+		m.RecordTimeout(OpDHTLookup)
+	}
+	m.SetPeerProfile("lan-peer", ProfileLAN)
+	m.RecordPeerTransfer("lan-peer", 8<<20, time.Second)
+	if err := m.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	restored := NewManager(nil)
+	ok, err := restored.Load(path)
+	if err != nil || !ok {
+		t.Fatalf("Load = %v, %v", ok, err)
+	}
+	if got, want := restored.Get(OpDHTLookup), m.Get(OpDHTLookup); got != want {
+		t.Errorf("DHT lookup timeout = %v, want %v", got, want)
+	}
+	if got, want := restored.PeerTransferTimeout("lan-peer", 4<<20), m.PeerTransferTimeout("lan-peer", 4<<20); got != want {
+		t.Errorf("peer timeout = %v, want %v", got, want)
+	}
+	if stats := restored.GetPeerStats("lan-peer"); stats == nil || stats.Profile != ProfileLAN {
+		t.Errorf("peer profile not restored: %+v", stats)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".timeouts-*")); len(matches) != 0 {
+		t.Errorf("temp files left behind: %v", matches)
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	m := NewManager(nil)
+	ok, err := m.Load(filepath.Join(t.TempDir(), "timeouts.json"))
+	if err != nil || ok {
+		t.Errorf("Load missing = %v, %v; want false, nil", ok, err)
+	}
+}
+
+func TestLoad_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timeouts.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(nil)
+	if _, err := m.Load(path); err == nil {
+		t.Error("Load of corrupt file should fail")
+	}
+	if got := m.Get(OpDHTLookup); got != DefaultDHTLookup {
+		t.Errorf("corrupt load changed timeout to %v", got)
+	}
+}
+
+func TestLoad_StaleOrOutOfBounds(t *testing.T) {
+	dir := t.TempDir()
+	write := func(snap snapshot) string {
+		t.Helper()
+		data, err := json.Marshal(snap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "timeouts.json")
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// Too old: ignored
+	m := NewManager(nil)
+	path := write(snapshot{
+		SavedAt:    time.Now().Add(-MaxSnapshotAge - time.Hour),
+		Operations: map[Operation]operationSnapshot{OpDHTLookup: {Current: 5 * time.Second}},
+	})
+	if ok, err := m.Load(path); err != nil || ok {
+		t.Errorf("stale Load = %v, %v", ok, err)
+	}
+	if got := m.Get(OpDHTLookup); got != DefaultDHTLookup {
+		t.Errorf("stale snapshot applied: %v", got)
+	}
+
+	// Below base and above max: clamped
+	path = write(snapshot{
+		SavedAt: time.Now(),
+		Operations: map[Operation]operationSnapshot{
+			OpPeerConnect:   {Current: time.Millisecond},
+			OpTunnelIdle:    {Current: time.Hour},
+			"unknown_op":    {Current: time.Second},
+			OpDHTLookupFull: {Current: -time.Second},
+		},
+		Peers: map[string]peerSnapshot{"bogus": {Profile: "moon", Throughput: 1}},
+	})
+	if _, err := m.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Get(OpPeerConnect); got != DefaultPeerConnect {
+		t.Errorf("peer connect = %v, want base %v", got, DefaultPeerConnect)
+	}
+	if got := m.Get(OpTunnelIdle); got != MaxTimeout {
+		t.Errorf("tunnel idle = %v, want %v", got, MaxTimeout)
+	}
+	if got := m.Get(OpDHTLookupFull); got != DefaultDHTLookupFull {
+		t.Errorf("negative snapshot applied: %v", got)
+	}
+	if m.GetPeerStats("bogus") != nil {
+		t.Error("peer with unknown profile restored")
+	}
+}
+
+func TestLoad_AdaptiveDisabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timeouts.json")
+	m := NewManager(nil)
+	m.RecordTimeout(OpDHTLookup)
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.AdaptiveEnabled = false
+	fixed := NewManager(cfg)
+	if ok, err := fixed.Load(path); err != nil || ok {
+		t.Errorf("Load with adaptation disabled = %v, %v", ok, err)
+	}
+	if got := fixed.Get(OpDHTLookup); got != DefaultDHTLookup {
+		t.Errorf("fixed timeouts changed to %v", got)
+	}
+}
@@ -29,6 +29,11 @@ const (
 
 	// Size-based timeout calculation
 	BytesPerSecondBase = 1024 * 1024 // 1 MB/s baseline
+
+	// Chunk download times kept for latency percentiles, and how many must
+	// be recorded before a percentile is trusted
+	ChunkLatencySamples    = 200
+	MinChunkLatencySamples = 20
 )
 
 // Operation types for timeout tracking
@@ -50,7 +55,11 @@ const (
 type Manager struct {
 	mu       sync.RWMutex
 	timeouts map[Operation]*adaptiveTimeout
+	peers    map[string]*peerProfile
 	config   *Config
+
+	// chunks holds recent chunk download times, for hedging
+	chunks *DurationTracker
 }
 
 // Config holds timeout configuration
@@ -102,7 +111,9 @@ func NewManager(cfg *Config) *Manager {
 
 	m := &Manager{
 		timeouts: make(map[Operation]*adaptiveTimeout),
+		peers:    make(map[string]*peerProfile),
 		config:   cfg,
+		chunks:   NewDurationTracker(ChunkLatencySamples),
 	}
 
 	// Initialize with defaults
@@ -297,7 +308,7 @@ func (m *Manager) GetAllStats() []*Stats {
 	return stats
 }
 
-// Reset resets all timeouts to their base values
+// Reset resets all timeouts to their base values and forgets peer profiles
 func (m *Manager) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -310,6 +321,7 @@ func (m *Manager) Reset() {
 		t.timeoutCount = 0
 		t.lastUpdated = time.Now()
 	}
+	m.peers = make(map[string]*peerProfile)
 }
 
 // ResetDecay gradually resets timeouts toward base values
@@ -365,6 +377,20 @@ func CalculateTransferTimeout(sizeBytes int64, bytesPerSecond int64, margin floa
 	return clampTimeout(timeout)
 }
 
+// RecordChunkLatency records how long a successful chunk download took.
+func (m *Manager) RecordChunkLatency(d time.Duration) {
+	m.chunks.Record(d)
+}
+
+// ChunkLatencyPercentile returns the pth percentile of recent chunk download
+// times, or 0 until MinChunkLatencySamples have been recorded.
+func (m *Manager) ChunkLatencyPercentile(p float64) time.Duration {
+	if m.chunks.Len() < MinChunkLatencySamples {
+		return 0
+	}
+	return m.chunks.Percentile(p)
+}
+
 // PercentileTimeout calculates a timeout based on percentile of observed durations.
 // Uses a ring buffer to bound memory usage (reslicing would leak backing array).
 type DurationTracker struct {
@@ -400,6 +426,13 @@ func (dt *DurationTracker) Record(d time.Duration) {
 	dt.writeIdx = (dt.writeIdx + 1) % dt.maxSamples
 }
 
+// Len returns how many durations are held
+func (dt *DurationTracker) Len() int {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+	return len(dt.durations)
+}
+
 // Percentile returns the nth percentile of recorded durations
 func (dt *DurationTracker) Percentile(p float64) time.Duration {
 	dt.mu.Lock()
@@ -810,3 +810,17 @@ func TestConstants(t *testing.T) {
 		t.Errorf("AdaptationAlpha %v should be between 0 and 1", AdaptationAlpha)
 	}
 }
+
+func TestChunkLatencyPercentile(t *testing.T) {
+	m := NewManager(nil)
+	for i := 1; i < MinChunkLatencySamples; i++ {
+		m.RecordChunkLatency(time.Duration(i) * time.Second)
+	}
+	if got := m.ChunkLatencyPercentile(95); got != 0 {
+		t.Errorf("percentile with %d samples = %v, want 0", MinChunkLatencySamples-1, got)
+	}
+	m.RecordChunkLatency(time.Duration(MinChunkLatencySamples) * time.Second)
+	if got := m.ChunkLatencyPercentile(50); got != 10*time.Second {
+		t.Errorf("p50 = %v, want 10s", got)
+	}
+}
@@ -0,0 +1,72 @@
+package units
+
+import (
+	"strings"
+	"time"
+)
+
+// The flag types below implement pflag.Value, so command-line flags take
+// the same notation as the config file.
+
+// SizeFlag is a size flag, in bytes.
+type SizeFlag int64
+
+func (f *SizeFlag) Set(s string) error {
+	n, err := ParseSize(s)
+	if err != nil {
+		return err
+	}
+	*f = SizeFlag(n)
+	return nil
+}
+
+func (f *SizeFlag) String() string { return FormatSize(int64(*f)) }
+
+func (f *SizeFlag) Type() string { return "size" }
+
+// DurationFlag is a duration flag that also accepts days and weeks.
+type DurationFlag time.Duration
+
+func (f *DurationFlag) Set(s string) error {
+	d, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*f = DurationFlag(d)
+	return nil
+}
+
+func (f *DurationFlag) String() string { return time.Duration(*f).String() }
+
+func (f *DurationFlag) Type() string { return "duration" }
+
+// RateFlag is a rate flag. Unset, it is the zero (unlimited) Rate.
+type RateFlag struct {
+	Rate
+	set  bool
+	text string
+}
+
+func (f *RateFlag) Set(s string) error {
+	r, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	f.Rate, f.set, f.text = r, true, strings.TrimSpace(s)
+	return nil
+}
+
+// String returns the rate as given, so it can be written to a config file
+// unchanged, or "unlimited" when unset.
+func (f *RateFlag) String() string {
+	if f.set {
+		return f.text
+	}
+	return f.Rate.String()
+}
+
+// IsSet reports whether the flag was given, so that an explicit "0" can
+// override a configured limit.
+func (f *RateFlag) IsSet() bool { return f.set }
+
+func (f *RateFlag) Type() string { return "rate" }
@@ -0,0 +1,292 @@
+// Package units parses the sizes, rates and durations used in configuration
+// files and command-line flags.
+//
+// Sizes are a number with an optional unit, case-insensitive. The IEC units
+// KiB, MiB, GiB and TiB, and the shorthands K, M, G and T, are powers of
+// 1024; the SI units kB, MB, GB and TB are powers of 1000, so values copied
+// from disk vendors or other tools mean what they say. The number may have
+// a fractional part ("1.5GiB").
+//
+// Capacities are sizes or a percentage of the filesystem ("20%").
+//
+// Rates are sizes per second, with an optional "/s" suffix, or a percentage
+// of the measured link capacity ("30%link").
+//
+// Durations are Go durations ("90s", "36h") that may also use days and weeks
+// ("2d", "1w", "1d12h").
+package units
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Binary size multipliers
+const (
+	KiB int64 = 1 << (10 * (iota + 1))
+	MiB
+	GiB
+	TiB
+)
+
+// Decimal size multipliers
+const (
+	KB int64 = 1000
+	MB       = 1000 * KB
+	GB       = 1000 * MB
+	TB       = 1000 * GB
+)
+
+var sizeUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"K":   KiB,
+	"KB":  KB,
+	"KIB": KiB,
+	"M":   MiB,
+	"MB":  MB,
+	"MIB": MiB,
+	"G":   GiB,
+	"GB":  GB,
+	"GIB": GiB,
+	"T":   TiB,
+	"TB":  TB,
+	"TIB": TiB,
+}
+
+// ParseSize parses a size like "10GiB", "500MB" or "1.5G" into bytes.
+// An empty string is 0.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	num, unit := splitNumber(s)
+	if num == "" {
+		return 0, fmt.Errorf("invalid size: no numeric value in %q", s)
+	}
+	mult, ok := sizeUnits[strings.ToUpper(strings.TrimSpace(unit))]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit %q in %q", strings.TrimSpace(unit), s)
+	}
+
+	if !strings.Contains(num, ".") {
+		n, err := strconv.ParseInt(num, 10, 64)
+		if err != nil || (n > 0 && n > math.MaxInt64/mult) {
+			return 0, fmt.Errorf("size value overflows int64 in %q", s)
+		}
+		return n * mult, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	bytes := f * float64(mult)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("size value overflows int64 in %q", s)
+	}
+	return int64(bytes), nil
+}
+
+// splitNumber splits s into its leading unsigned decimal number and the rest.
+func splitNumber(s string) (num, rest string) {
+	end, dot := 0, false
+	for i, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			end = i + 1
+		case c == '.' && !dot:
+			dot = true
+		default:
+			return s[:end], s[i:]
+		}
+	}
+	return s[:end], s[len(s):]
+}
+
+// Rate is a transfer rate limit: a fixed number of bytes per second, or a
+// percentage of the link's measured capacity. The zero Rate is unlimited.
+type Rate struct {
+	BytesPerSec int64
+	LinkPercent float64 // > 0 for a rate relative to the link
+}
+
+// linkSuffix marks a percentage-of-link rate
+const linkSuffix = "%link"
+
+// ParseRate parses a rate like "10MB/s", "100KB" or "30%link". An empty
+// string, "0" and "unlimited" are unlimited.
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" || strings.EqualFold(s, "unlimited") {
+		return Rate{}, nil
+	}
+	if pct, ok := strings.CutSuffix(strings.ToLower(s), linkSuffix); ok {
+		p, err := strconv.ParseFloat(strings.TrimSpace(pct), 64)
+		if err != nil || p <= 0 || p > 100 {
+			return Rate{}, fmt.Errorf("invalid rate %q: link percentage must be above 0 and at most 100", s)
+		}
+		return Rate{LinkPercent: p}, nil
+	}
+	n, err := ParseSize(strings.TrimSuffix(s, "/s"))
+	if err != nil {
+		return Rate{}, err
+	}
+	return Rate{BytesPerSec: n}, nil
+}
+
+// LinkRelative reports whether the rate is a percentage of the link.
+func (r Rate) LinkRelative() bool {
+	return r.LinkPercent > 0
+}
+
+// Resolve returns the rate in bytes per second given the measured link
+// capacity in bytes per second. A link-relative rate is unlimited (0) until
+// the link has been measured.
+func (r Rate) Resolve(link int64) int64 {
+	if !r.LinkRelative() {
+		return r.BytesPerSec
+	}
+	if link <= 0 {
+		return 0
+	}
+	return max(1, int64(float64(link)*r.LinkPercent/100))
+}
+
+// String formats the rate as it would be written in a config file.
+func (r Rate) String() string {
+	switch {
+	case r.LinkRelative():
+		return strconv.FormatFloat(r.LinkPercent, 'f', -1, 64) + linkSuffix
+	case r.BytesPerSec == 0:
+		return "unlimited"
+	default:
+		return FormatSize(r.BytesPerSec) + "/s"
+	}
+}
+
+// Capacity is a storage limit: a fixed number of bytes, or a percentage of
+// the capacity of the filesystem that holds the data.
+type Capacity struct {
+	Bytes       int64
+	DiskPercent float64 // > 0 for a limit relative to the filesystem
+}
+
+// ParseCapacity parses a capacity like "10GB" or "20%".
+func ParseCapacity(s string) (Capacity, error) {
+	s = strings.TrimSpace(s)
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		p, err := strconv.ParseFloat(strings.TrimSpace(pct), 64)
+		if err != nil || p <= 0 || p > 100 {
+			return Capacity{}, fmt.Errorf("invalid size %q: percentage must be above 0 and at most 100", s)
+		}
+		return Capacity{DiskPercent: p}, nil
+	}
+	n, err := ParseSize(s)
+	if err != nil {
+		return Capacity{}, err
+	}
+	return Capacity{Bytes: n}, nil
+}
+
+// DiskRelative reports whether the capacity is a percentage of the
+// filesystem.
+func (c Capacity) DiskRelative() bool {
+	return c.DiskPercent > 0
+}
+
+// Resolve returns the capacity in bytes given the size of the filesystem in
+// bytes.
+func (c Capacity) Resolve(disk int64) int64 {
+	if !c.DiskRelative() {
+		return c.Bytes
+	}
+	return int64(float64(disk) * c.DiskPercent / 100)
+}
+
+// String formats the capacity as it would be written in a config file.
+func (c Capacity) String() string {
+	if c.DiskRelative() {
+		return strconv.FormatFloat(c.DiskPercent, 'f', -1, 64) + "%"
+	}
+	return FormatSize(c.Bytes)
+}
+
+// FormatSize formats bytes with the largest binary unit that keeps the
+// number at or above 1, to one decimal place, e.g. "1.5GiB".
+func FormatSize(b int64) string {
+	for _, u := range []struct {
+		name string
+		mult int64
+	}{{"TiB", TiB}, {"GiB", GiB}, {"MiB", MiB}, {"KiB", KiB}} {
+		if b >= u.mult {
+			return strings.TrimSuffix(strconv.FormatFloat(float64(b)/float64(u.mult), 'f', 1, 64), ".0") + u.name
+		}
+	}
+	return strconv.FormatInt(b, 10) + "B"
+}
+
+var errDuration = errors.New("want e.g. 90s, 36h, 2d or 1w")
+
+// ParseDuration parses a duration like time.ParseDuration, also accepting
+// days ("d", 24h) and weeks ("w", 7d), alone or combined with the other
+// units ("1d12h").
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if !strings.ContainsAny(s, "dw") {
+		return time.ParseDuration(s)
+	}
+	neg := strings.HasPrefix(s, "-")
+	rest := strings.TrimLeft(s, "+-")
+	if rest == "" {
+		return 0, fmt.Errorf("invalid duration %q: %w", s, errDuration)
+	}
+
+	var total time.Duration
+	for rest != "" {
+		num, after := splitNumber(rest)
+		if num == "" || after == "" {
+			return 0, fmt.Errorf("invalid duration %q: %w", s, errDuration)
+		}
+		unitEnd := strings.IndexFunc(after, func(c rune) bool { return c == '.' || (c >= '0' && c <= '9') })
+		if unitEnd < 0 {
+			unitEnd = len(after)
+		}
+		unit := after[:unitEnd]
+		rest = after[unitEnd:]
+
+		var d time.Duration
+		switch unit {
+		case "d", "w":
+			f, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q: %w", s, errDuration)
+			}
+			day := 24 * float64(time.Hour)
+			if unit == "w" {
+				day *= 7
+			}
+			if f*day >= math.MaxInt64 {
+				return 0, fmt.Errorf("invalid duration %q: overflow", s)
+			}
+			d = time.Duration(f * day)
+		default:
+			var err error
+			if d, err = time.ParseDuration(num + unit); err != nil {
+				return 0, fmt.Errorf("invalid duration %q: %w", s, errDuration)
+			}
+		}
+		if total > math.MaxInt64-d {
+			return 0, fmt.Errorf("invalid duration %q: overflow", s)
+		}
+		total += d
+	}
+	if neg {
+		total = -total
+	}
+	return total, nil
+}
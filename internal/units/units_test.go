@@ -0,0 +1,197 @@
+package units
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"", 0},
+		{"0", 0},
+		{"12345", 12345},
+		{"100B", 100},
+		{"1K", KiB},
+		{"1KB", KB},
+		{"1kB", KB},
+		{"1KiB", KiB},
+		{"10kb", 10 * KB},
+		{"512MiB", 512 * MiB},
+		{"500MB", 500 * MB},
+		{"1.5GiB", 3 * GiB / 2},
+		{"1.5GB", 3 * GB / 2},
+		{"0.5M", MiB / 2},
+		{"2 GiB", 2 * GiB},
+		{"1TB", TB},
+		{"1TiB", TiB},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"GB", "-5MB", "10XB", "1.2.3MB", "9999999999999TB", "."} {
+		if _, err := ParseSize(bad); err == nil {
+			t.Errorf("ParseSize(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Rate
+	}{
+		{"", Rate{}},
+		{"0", Rate{}},
+		{"unlimited", Rate{}},
+		{"10MB/s", Rate{BytesPerSec: 10 * MB}},
+		{"10MiB/s", Rate{BytesPerSec: 10 * MiB}},
+		{"100KiB", Rate{BytesPerSec: 100 * KiB}},
+		{"30%link", Rate{LinkPercent: 30}},
+		{"12.5%LINK", Rate{LinkPercent: 12.5}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRate(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"0%link", "150%link", "%link", "30%", "fast"} {
+		if _, err := ParseRate(bad); err == nil {
+			t.Errorf("ParseRate(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestRateResolve(t *testing.T) {
+	fixed := Rate{BytesPerSec: 1000}
+	if got := fixed.Resolve(0); got != 1000 {
+		t.Errorf("fixed rate = %d, want 1000", got)
+	}
+	link := Rate{LinkPercent: 30}
+	if got := link.Resolve(0); got != 0 {
+		t.Errorf("unmeasured link = %d, want unlimited", got)
+	}
+	if got := link.Resolve(10 * MiB); got != 3*MiB {
+		t.Errorf("30%% of 10MB/s = %d, want %d", got, 3*MiB)
+	}
+	if got := link.String(); got != "30%link" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := fixed.String(); got != "1000B/s" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestParseCapacity(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Capacity
+	}{
+		{"", Capacity{}},
+		{"10GB", Capacity{Bytes: 10 * GB}},
+		{"10GiB", Capacity{Bytes: 10 * GiB}},
+		{"20%", Capacity{DiskPercent: 20}},
+		{" 12.5 %", Capacity{DiskPercent: 12.5}},
+	}
+	for _, tt := range tests {
+		got, err := ParseCapacity(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseCapacity(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"0%", "101%", "%", "20%link", "big"} {
+		if _, err := ParseCapacity(bad); err == nil {
+			t.Errorf("ParseCapacity(%q) succeeded", bad)
+		}
+	}
+
+	if got := (Capacity{DiskPercent: 20}).Resolve(100 * GiB); got != 20*GiB {
+		t.Errorf("20%% of 100GB = %d, want %d", got, 20*GiB)
+	}
+	if got := (Capacity{Bytes: 1000}).Resolve(100 * GiB); got != 1000 {
+		t.Errorf("fixed capacity = %d, want 1000", got)
+	}
+	if got := (Capacity{DiskPercent: 20}).String(); got != "20%" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"90s", 90 * time.Second},
+		{"36h", 36 * time.Hour},
+		{"2d", 48 * time.Hour},
+		{"1w", 7 * 24 * time.Hour},
+		{"1d12h", 36 * time.Hour},
+		{"0.5d", 12 * time.Hour},
+		{"1d1h30m", 25*time.Hour + 30*time.Minute},
+		{"-1d", -24 * time.Hour},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "d", "2days", "1x2d", "3"} {
+		if _, err := ParseDuration(bad); err == nil {
+			t.Errorf("ParseDuration(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		512:         "512B",
+		KiB:         "1KiB",
+		3 * MiB / 2: "1.5MiB",
+		10 * GiB:    "10GiB",
+		10 * GB:     "9.3GiB",
+	}
+	for in, want := range tests {
+		if got := FormatSize(in); got != want {
+			t.Errorf("FormatSize(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFlags(t *testing.T) {
+	var size SizeFlag
+	if err := size.Set("1.5GiB"); err != nil || int64(size) != 3*GiB/2 {
+		t.Errorf("SizeFlag = %d, %v", size, err)
+	}
+	var d DurationFlag
+	if err := d.Set("2d"); err != nil || time.Duration(d) != 48*time.Hour {
+		t.Errorf("DurationFlag = %v, %v", time.Duration(d), err)
+	}
+	if d.String() != "48h0m0s" {
+		t.Errorf("DurationFlag.String() = %q", d.String())
+	}
+	var r RateFlag
+	if r.IsSet() {
+		t.Error("unset rate flag reports set")
+	}
+	if r.String() != "unlimited" {
+		t.Errorf("unset RateFlag.String() = %q", r.String())
+	}
+	if err := r.Set("0"); err != nil || !r.IsSet() || r.Rate != (Rate{}) {
+		t.Errorf("RateFlag = %+v, %v", r, err)
+	}
+	// The rate is kept as given, so a decimal one is not rewritten in
+	// binary units when saved
+	if err := r.Set(" 2MB/s"); err != nil || r.BytesPerSec != 2*MB || r.String() != "2MB/s" {
+		t.Errorf("RateFlag = %+v %q, %v", r, r.String(), err)
+	}
+	if err := r.Set("bogus"); err == nil {
+		t.Error("invalid rate accepted")
+	}
+}
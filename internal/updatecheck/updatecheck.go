@@ -0,0 +1,218 @@
+// Package updatecheck compares the running debswarm version to the latest
+// release published at a configurable URL. It only reports; it never
+// downloads or installs anything.
+package updatecheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultURL is the GitHub releases API endpoint of the latest release.
+const DefaultURL = "https://api.github.com/repos/clintcan/debswarm/releases/latest"
+
+// maxResponseSize bounds the release document read from the network. The
+// GitHub API response with release notes is well under this.
+const maxResponseSize = 1 << 20
+
+// Status is the outcome of the most recent check.
+type Status struct {
+	Current         string
+	Latest          string // empty until a check succeeds
+	UpdateAvailable bool
+	CheckedAt       time.Time // zero until the first check
+	Error           string    // of the most recent check, if it failed
+}
+
+// Checker periodically fetches the latest release version.
+type Checker struct {
+	url     string
+	current string
+	client  *http.Client
+	logger  *zap.Logger
+
+	mu     sync.RWMutex
+	status Status
+}
+
+// New creates a Checker comparing current against the release published at
+// url (DefaultURL if empty).
+func New(url, current string, client *http.Client, logger *zap.Logger) *Checker {
+	if url == "" {
+		url = DefaultURL
+	}
+	return &Checker{
+		url:     url,
+		current: current,
+		client:  client,
+		logger:  logger,
+		status:  Status{Current: current},
+	}
+}
+
+// Status returns the outcome of the most recent check. A nil Checker, as
+// when update checks are disabled, reports nothing.
+func (c *Checker) Status() Status {
+	if c == nil {
+		return Status{}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+// Check fetches the latest release and records the outcome.
+func (c *Checker) Check(ctx context.Context) (Status, error) {
+	latest, err := c.fetch(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status.CheckedAt = time.Now()
+	if err != nil {
+		c.status.Error = err.Error()
+		return c.status, err
+	}
+	c.status.Error = ""
+	c.status.Latest = latest
+	c.status.UpdateAvailable = Newer(latest, c.current)
+	return c.status, nil
+}
+
+// Run checks now and then every interval until ctx is done, logging when a
+// newer release appears.
+func (c *Checker) Run(ctx context.Context, interval time.Duration) {
+	var announced string
+	check := func() {
+		status, err := c.Check(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				c.logger.Debug("Update check failed", zap.String("url", c.url), zap.Error(err))
+			}
+			return
+		}
+		if status.UpdateAvailable && status.Latest != announced {
+			announced = status.Latest
+			c.logger.Info("A newer debswarm release is available",
+				zap.String("current", status.Current),
+				zap.String("latest", status.Latest))
+		}
+	}
+
+	check()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			check()
+		}
+	}
+}
+
+// fetch returns the latest version published at the URL.
+func (c *Checker) fetch(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "debswarm/"+c.current)
+	req.Header.Set("Accept", "application/json, text/plain")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("updatecheck: fetch %s: %w", c.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("updatecheck: fetch %s: HTTP %d", c.url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return "", fmt.Errorf("updatecheck: read %s: %w", c.url, err)
+	}
+	return ParseRelease(data)
+}
+
+// ParseRelease extracts the version from a release document: a GitHub
+// release ({"tag_name": "v1.2.3"}), an object with a "version" field, or
+// the bare version as plain text.
+func ParseRelease(data []byte) (string, error) {
+	text := strings.TrimSpace(string(data))
+	if strings.HasPrefix(text, "{") {
+		var doc struct {
+			TagName string `json:"tag_name"`
+			Version string `json:"version"`
+		}
+		if err := json.Unmarshal([]byte(text), &doc); err != nil {
+			return "", fmt.Errorf("updatecheck: invalid release document: %w", err)
+		}
+		text = doc.TagName
+		if text == "" {
+			text = doc.Version
+		}
+	}
+	if _, ok := parse(text); !ok {
+		return "", fmt.Errorf("updatecheck: no release version in response")
+	}
+	return strings.TrimPrefix(text, "v"), nil
+}
+
+// Newer reports whether version a is a later release than b. Versions that
+// do not parse, such as "dev" builds, are never newer nor older.
+func Newer(a, b string) bool {
+	va, ok := parse(a)
+	if !ok {
+		return false
+	}
+	vb, ok := parse(b)
+	if !ok {
+		return false
+	}
+	for i := range va.nums {
+		if va.nums[i] != vb.nums[i] {
+			return va.nums[i] > vb.nums[i]
+		}
+	}
+	// A release is newer than its pre-releases (1.2.0 > 1.2.0-rc1)
+	if va.pre == "" || vb.pre == "" {
+		return va.pre == "" && vb.pre != ""
+	}
+	return va.pre > vb.pre
+}
+
+// version is a parsed MAJOR.MINOR.PATCH[-PRE] version
+type version struct {
+	nums [3]int
+	pre  string
+}
+
+// parse parses "v1.2.3", "1.2.3-rc1" or "1.2"; build metadata after "+"
+// is ignored.
+func parse(s string) (version, bool) {
+	var v version
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, v.pre, _ = strings.Cut(s, "-")
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.nums[i] = n
+	}
+	return v, true
+}
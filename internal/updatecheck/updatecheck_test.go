@@ -0,0 +1,103 @@
+package updatecheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1.2.1", "1.2.0", true},
+		{"v1.10.0", "1.9.3", true},
+		{"1.2.0", "1.2.0", false},
+		{"1.2.0", "1.3.0", false},
+		{"1.2.0", "1.2.0-rc1", true},
+		{"1.2.0-rc2", "1.2.0-rc1", true},
+		{"1.2.0-rc1", "1.2.0", false},
+		{"1.3", "1.2.9", true},
+		{"1.2.0+build5", "1.1.0", true},
+		{"1.2.0", "dev", false},
+		{"dev", "1.2.0", false},
+		{"", "1.2.0", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.a, tt.b); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseRelease(t *testing.T) {
+	tests := []struct {
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{`{"tag_name": "v1.40.0", "name": "debswarm 1.40.0"}`, "1.40.0", false},
+		{`{"version": "1.40.1"}`, "1.40.1", false},
+		{"v1.41.0\n", "1.41.0", false},
+		{`{"tag_name": "nightly"}`, "", true},
+		{`{"tag_name": `, "", true},
+		{"<html>Not Found</html>", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRelease([]byte(tt.body))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRelease(%q) = %q, %v", tt.body, got, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	latest := `{"tag_name": "v1.5.0"}`
+	var userAgent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+		if latest == "" {
+			http.Error(w, "rate limited", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(latest))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "1.4.2", srv.Client(), zap.NewNop())
+	status, err := c.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if status.Latest != "1.5.0" || !status.UpdateAvailable || status.CheckedAt.IsZero() {
+		t.Errorf("status = %+v", status)
+	}
+	if userAgent != "debswarm/1.4.2" {
+		t.Errorf("User-Agent = %q", userAgent)
+	}
+
+	// A failed check keeps the last known release
+	latest = ""
+	if _, err := c.Check(context.Background()); err == nil {
+		t.Fatal("expected an error for HTTP 403")
+	}
+	if status := c.Status(); status.Latest != "1.5.0" || status.Error == "" {
+		t.Errorf("status after failure = %+v", status)
+	}
+
+	// Development builds never claim an update
+	dev := New(srv.URL, "dev", srv.Client(), zap.NewNop())
+	latest = "1.5.0"
+	if status, err := dev.Check(context.Background()); err != nil || status.UpdateAvailable {
+		t.Errorf("dev build status = %+v, %v", status, err)
+	}
+
+	var disabled *Checker
+	if disabled.Status() != (Status{}) {
+		t.Error("nil checker reported a status")
+	}
+}
@@ -0,0 +1,426 @@
+// Package uplink shares a bandwidth budget among the debswarm daemons on one
+// uplink, such as several VMs on a host, so that together they stay within
+// the link even though each one's own limits are correct.
+//
+// The daemons coordinate through a directory they all mount. Every interval
+// each one writes a report of its recent throughput there and reads the
+// others'. From the same reports every member computes the same max-min
+// fair split of the budget: a member that used less than its share keeps
+// what it used, plus some headroom, and the members that wanted more split
+// the rest. Each then limits itself to its own share. A member whose report
+// stops changing is left out after a few intervals, so a crashed daemon's
+// share returns to the others.
+package uplink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/metrics"
+	"github.com/debswarm/debswarm/internal/ratelimit"
+)
+
+// staleIntervals is how many intervals a member's report may go unchanged
+// before the member is left out.
+const staleIntervals = 3
+
+// limitedShare is the fraction of its share a member must use to count as
+// wanting more.
+const limitedShare = 0.9
+
+// headroom is how much more than it used an unlimited member is allotted,
+// so that it can speed up until the next interval.
+const headroom = 1.25
+
+// Config configures a Budget.
+type Config struct {
+	// Dir is the directory the daemons share
+	Dir string
+	// Member names this daemon; it must be unique among the members
+	Member string
+	// Upload and Download are the budgets of all members together, in
+	// bytes/sec (0 = no budget in that direction)
+	Upload   int64
+	Download int64
+	// Interval is how often reports are written and shares recomputed
+	Interval time.Duration
+	Metrics  *metrics.Metrics
+}
+
+// Budget limits this daemon to its share of the budgets it shares with the
+// other members.
+type Budget struct {
+	cfg    Config
+	logger *zap.Logger
+
+	upload   *direction
+	download *direction
+
+	// Reports of other members: when their Updated last changed, by this
+	// host's clock, so that skewed clocks across VMs do not matter
+	mu      sync.Mutex
+	seen    map[string]seenReport
+	members int
+	failing bool // last write or read failed; logged once
+}
+
+type seenReport struct {
+	report  report
+	changed time.Time
+	live    bool // has been updated recently at some point
+}
+
+// direction is one of the budgets: the limiter enforcing this member's
+// share, and the bytes that went through it since the last report
+type direction struct {
+	budget  int64
+	limiter *ratelimit.Limiter
+	bytes   atomic.Int64
+	share   atomic.Int64
+	last    time.Time
+}
+
+// report is what a member writes to the shared directory.
+type report struct {
+	Member   string    `json:"member"`
+	Updated  time.Time `json:"updated"`
+	Upload   usage     `json:"upload"`
+	Download usage     `json:"download"`
+}
+
+// usage is a member's throughput in one direction over the last interval.
+type usage struct {
+	Rate    int64 `json:"rate"`    // bytes/sec
+	Limited bool  `json:"limited"` // used (nearly) all of its share
+}
+
+// New returns a Budget; Start begins coordinating with the other members.
+// Until then, this daemon may use the whole budget.
+func New(cfg Config, logger *zap.Logger) (*Budget, error) {
+	if cfg.Dir == "" || cfg.Member == "" {
+		return nil, fmt.Errorf("uplink: directory and member name are required")
+	}
+	if strings.ContainsAny(cfg.Member, `/\`) || strings.HasPrefix(cfg.Member, ".") {
+		return nil, fmt.Errorf("uplink: invalid member name %q", cfg.Member)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil { // #nosec G301 -- shared with the other daemons
+		return nil, fmt.Errorf("uplink: %w", err)
+	}
+	now := time.Now()
+	b := &Budget{
+		cfg:      cfg,
+		logger:   logger,
+		upload:   newDirection(cfg.Upload, now),
+		download: newDirection(cfg.Download, now),
+		seen:     make(map[string]seenReport),
+	}
+	return b, nil
+}
+
+func newDirection(budget int64, now time.Time) *direction {
+	d := &direction{budget: budget, limiter: ratelimit.New(budget), last: now}
+	d.share.Store(budget)
+	return d
+}
+
+// Start recomputes the shares every interval until ctx is done, then
+// withdraws this member's report.
+func (b *Budget) Start(ctx context.Context) {
+	b.update(time.Now())
+	go func() {
+		ticker := time.NewTicker(b.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				_ = os.Remove(b.reportPath(b.cfg.Member))
+				return
+			case now := <-ticker.C:
+				b.update(now)
+			}
+		}
+	}()
+}
+
+// ReaderContext returns r limited to this member's download share. Its
+// signature fits mirror.Fetcher.SetThrottle and p2p.DownloadThrottle.
+func (b *Budget) ReaderContext(ctx context.Context, r io.Reader) io.Reader {
+	if b == nil || b.download.budget <= 0 {
+		return r
+	}
+	return &countingReader{r: b.download.limiter.ReaderContext(ctx, r), n: &b.download.bytes}
+}
+
+// WriterContextSize returns w limited to this member's upload share. Its
+// signature fits p2p.UploadThrottle.
+func (b *Budget) WriterContextSize(ctx context.Context, w io.Writer, size int64) io.Writer {
+	if b == nil || b.upload.budget <= 0 {
+		return w
+	}
+	return &countingWriter{w: b.upload.limiter.WriterContextSize(ctx, w, size), n: &b.upload.bytes}
+}
+
+// State is a snapshot of the shared budgets.
+type State struct {
+	Members       int   // daemons sharing the uplink, this one included
+	UploadShare   int64 // this daemon's share, bytes/sec (0 = no budget)
+	DownloadShare int64
+}
+
+// State returns the current members and shares.
+func (b *Budget) State() State {
+	b.mu.Lock()
+	members := b.members
+	b.mu.Unlock()
+	return State{
+		Members:       max(members, 1),
+		UploadShare:   b.upload.share.Load(),
+		DownloadShare: b.download.share.Load(),
+	}
+}
+
+// update reports this member's usage since the last update, reads the
+// others' reports, and applies this member's new shares.
+func (b *Budget) update(now time.Time) {
+	own := report{
+		Member:   b.cfg.Member,
+		Updated:  now.UTC(),
+		Upload:   b.upload.usage(now),
+		Download: b.download.usage(now),
+	}
+	writeErr := b.writeReport(own)
+	others, readErr := b.readReports(now)
+
+	b.mu.Lock()
+	b.members = len(others) + 1
+	members := b.members
+	if err := firstErr(writeErr, readErr); err != nil {
+		if !b.failing {
+			b.logger.Warn("Shared uplink directory unavailable; keeping current shares",
+				zap.String("dir", b.cfg.Dir), zap.Error(err))
+			b.failing = true
+		}
+	} else if b.failing {
+		b.logger.Info("Shared uplink directory available again", zap.String("dir", b.cfg.Dir))
+		b.failing = false
+	}
+	b.mu.Unlock()
+	if readErr != nil {
+		return
+	}
+
+	b.upload.apply(own.Upload, others, func(r report) usage { return r.Upload })
+	b.download.apply(own.Download, others, func(r report) usage { return r.Download })
+
+	if m := b.cfg.Metrics; m != nil {
+		m.UplinkMembers.Set(float64(members))
+		if b.upload.budget > 0 {
+			m.UplinkShare.WithLabel("upload").Set(float64(b.upload.share.Load()))
+		}
+		if b.download.budget > 0 {
+			m.UplinkShare.WithLabel("download").Set(float64(b.download.share.Load()))
+		}
+	}
+}
+
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// usage returns the direction's throughput since the last call.
+func (d *direction) usage(now time.Time) usage {
+	n := d.bytes.Swap(0)
+	elapsed := now.Sub(d.last).Seconds()
+	d.last = now
+	if d.budget <= 0 || elapsed <= 0 {
+		return usage{}
+	}
+	rate := int64(float64(n) / elapsed)
+	return usage{Rate: rate, Limited: float64(rate) >= limitedShare*float64(d.share.Load())}
+}
+
+// apply sets this member's share from its own usage and the others'.
+func (d *direction) apply(own usage, others []report, get func(report) usage) {
+	if d.budget <= 0 {
+		return
+	}
+	usages := make([]usage, 0, len(others)+1)
+	usages = append(usages, own)
+	for _, r := range others {
+		usages = append(usages, get(r))
+	}
+	share := shares(d.budget, usages)[0]
+	if share != d.share.Load() {
+		d.share.Store(share)
+		d.limiter.UpdateRate(share)
+	}
+}
+
+// shares splits budget among members max-min fairly by their usage: a
+// member that was not limited is allotted what it used plus headroom, and
+// at least a small floor so that it can start transferring; members that
+// were limited split what remains equally. Whatever no member needs is
+// split equally among all. The shares never add up to more than budget.
+func shares(budget int64, usages []usage) []int64 {
+	n := len(usages)
+	floor := max(budget/int64(4*n), 1)
+	demands := make([]int64, n) // -1 = as much as it can get
+	for i, u := range usages {
+		if u.Limited {
+			demands[i] = -1
+			continue
+		}
+		demands[i] = max(int64(float64(u.Rate)*headroom), floor)
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		da, db := demands[a], demands[b]
+		switch {
+		case da == db:
+			return 0
+		case da < 0:
+			return 1
+		case db < 0:
+			return -1
+		case da < db:
+			return -1
+		default:
+			return 1
+		}
+	})
+
+	result := make([]int64, n)
+	remaining := budget
+	for k, i := range order {
+		fair := remaining / int64(n-k)
+		give := fair
+		if demands[i] >= 0 && demands[i] < fair {
+			give = demands[i]
+		}
+		result[i] = give
+		remaining -= give
+	}
+	for i := range result {
+		result[i] = max(result[i]+remaining/int64(n), 1)
+	}
+	return result
+}
+
+func (b *Budget) reportPath(member string) string {
+	return filepath.Join(b.cfg.Dir, member+".json")
+}
+
+// writeReport replaces this member's report atomically, so that readers
+// never see a partial one.
+func (b *Budget) writeReport(r report) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	path := b.reportPath(r.Member)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil { // #nosec G306 -- read by the other daemons
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// readReports returns the current reports of the other members: those
+// whose report changed within the last few intervals.
+func (b *Budget) readReports(now time.Time) ([]report, error) {
+	entries, err := os.ReadDir(b.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	stale := staleIntervals * b.cfg.Interval
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var reports []report
+	present := make(map[string]bool)
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() || name == b.cfg.Member {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(b.cfg.Dir, e.Name()))
+		if err != nil {
+			continue // removed meanwhile
+		}
+		var r report
+		if err := json.Unmarshal(data, &r); err != nil || r.Member != name {
+			continue
+		}
+		present[name] = true
+		prev, ok := b.seen[name]
+		switch {
+		case !ok:
+			// A report seen for the first time may be the leftover of a
+			// daemon that is gone. It counts at once if its own clock says
+			// it is recent, and otherwise once it changes.
+			age := now.Sub(r.Updated)
+			prev = seenReport{report: r, changed: now, live: age > -stale && age <= stale}
+		case !prev.report.Updated.Equal(r.Updated):
+			prev = seenReport{report: r, changed: now, live: true}
+		}
+		b.seen[name] = prev
+		if prev.live && now.Sub(prev.changed) <= stale {
+			reports = append(reports, r)
+		}
+	}
+	for name := range b.seen {
+		if !present[name] {
+			delete(b.seen, name)
+		}
+	}
+	return reports, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n *atomic.Int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,188 @@
+package uplink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/metrics"
+)
+
+func TestShares(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget int64
+		usages []usage
+		want   []int64
+	}{
+		{
+			name:   "alone",
+			budget: 1000,
+			usages: []usage{{Rate: 0}},
+			want:   []int64{1000},
+		},
+		{
+			name:   "all busy split evenly",
+			budget: 900,
+			usages: []usage{{Rate: 300, Limited: true}, {Rate: 300, Limited: true}, {Rate: 300, Limited: true}},
+			want:   []int64{300, 300, 300},
+		},
+		{
+			// The idle member keeps its floor, 1000/(4*2) = 125
+			name:   "busy and idle",
+			budget: 1000,
+			usages: []usage{{Rate: 500, Limited: true}, {Rate: 0}},
+			want:   []int64{875, 125},
+		},
+		{
+			// The light member keeps its usage plus headroom, 200*1.25
+			name:   "busy and light",
+			budget: 1000,
+			usages: []usage{{Rate: 200}, {Rate: 800, Limited: true}},
+			want:   []int64{250, 750},
+		},
+		{
+			// Nobody is limited: the 500 left over is split evenly
+			name:   "nobody busy",
+			budget: 1000,
+			usages: []usage{{Rate: 200}, {Rate: 200}},
+			want:   []int64{500, 500},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := shares(tt.budget, tt.usages)
+			var total int64
+			for i := range got {
+				total += got[i]
+				if got[i] != tt.want[i] {
+					t.Errorf("shares = %v, want %v", got, tt.want)
+					break
+				}
+			}
+			if total > tt.budget {
+				t.Errorf("shares add up to %d, more than the budget %d", total, tt.budget)
+			}
+		})
+	}
+}
+
+func newTestBudget(t *testing.T, dir, member string) *Budget {
+	t.Helper()
+	b, err := New(Config{Dir: dir, Member: member, Upload: 1000, Interval: time.Second, Metrics: metrics.New()}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBudget_SharesWithOtherMembers(t *testing.T) {
+	dir := t.TempDir()
+	a := newTestBudget(t, dir, "a")
+	b := newTestBudget(t, dir, "b")
+
+	now := time.Now()
+	a.update(now)
+	b.update(now)
+	a.update(now.Add(100 * time.Millisecond))
+	if st := a.State(); st.Members != 2 {
+		t.Fatalf("a sees %d members, want 2", st.Members)
+	}
+
+	// b used all of its share in the last second; a was idle
+	b.upload.bytes.Store(b.upload.share.Load())
+	now = now.Add(time.Second)
+	b.update(now)
+	if got := b.State().UploadShare; got != 875 {
+		t.Errorf("busy member's share = %d, want 875", got)
+	}
+	a.update(now)
+	if got := a.State().UploadShare; got != 125 {
+		t.Errorf("idle member's share = %d, want the floor 125", got)
+	}
+	if got := a.cfg.Metrics.UplinkMembers.Value(); got != 2 {
+		t.Errorf("members gauge = %v, want 2", got)
+	}
+}
+
+func TestBudget_LeavesOutStaleMembers(t *testing.T) {
+	dir := t.TempDir()
+	a := newTestBudget(t, dir, "a")
+
+	// A report left behind by a daemon that is long gone
+	old, _ := json.Marshal(report{Member: "gone", Updated: time.Now().Add(-time.Hour)})
+	if err := os.WriteFile(filepath.Join(dir, "gone.json"), old, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b := newTestBudget(t, dir, "b")
+
+	now := time.Now()
+	b.update(now)
+	a.update(now)
+	if st := a.State(); st.Members != 2 || st.UploadShare != 500 {
+		t.Errorf("state = %+v, want 2 members sharing 1000 evenly", st)
+	}
+
+	// b stops reporting: after a few intervals a has the budget to itself
+	for i := 1; i <= staleIntervals+1; i++ {
+		a.update(now.Add(time.Duration(i) * time.Second))
+	}
+	if st := a.State(); st.Members != 1 || st.UploadShare != 1000 {
+		t.Errorf("state = %+v, want 1 member with the whole budget", st)
+	}
+}
+
+func TestBudget_Throttles(t *testing.T) {
+	dir := t.TempDir()
+	b, err := New(Config{Dir: dir, Member: "a", Upload: 1000}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.Start(ctx)
+	if _, err := os.Stat(filepath.Join(dir, "a.json")); err != nil {
+		t.Fatalf("no report written: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w := b.WriterContextSize(ctx, &buf, 10)
+	if _, err := w.Write([]byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.upload.bytes.Load(); got != 10 {
+		t.Errorf("counted %d bytes, want 10", got)
+	}
+
+	// No download budget: readers pass through
+	r := bytes.NewReader(nil)
+	if got := b.ReaderContext(ctx, r); got != io.Reader(r) {
+		t.Error("reader wrapped without a download budget")
+	}
+
+	cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "a.json")); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("report not withdrawn on shutdown")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNew_InvalidMember(t *testing.T) {
+	for _, member := range []string{"", "../x", ".hidden"} {
+		if _, err := New(Config{Dir: t.TempDir(), Member: member, Upload: 1}, zap.NewNop()); err == nil {
+			t.Errorf("member %q accepted", member)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Package control is a client for the gRPC control API a debswarm daemon
+// serves on its Unix control socket, for tools that manage debswarm without
+// running the CLI. The service and its messages are in package controlv1.
+//
+//	c, err := control.Dial(control.DefaultSocket)
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//	stats, err := c.GetCacheStats(ctx, &controlv1.GetCacheStatsRequest{})
+package control
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	controlv1 "github.com/debswarm/debswarm/pkg/control/v1"
+)
+
+// DefaultSocket is where the packaged daemon serves the control API.
+const DefaultSocket = "/run/debswarm/control.sock"
+
+// Client is a connection to a daemon's control socket.
+type Client struct {
+	controlv1.ControlClient
+	conn *grpc.ClientConn
+}
+
+// Dial returns a client for the control socket at path. The connection is
+// made on first use; the daemon authorizes each call by the caller's user.
+func Dial(path string, opts ...grpc.DialOption) (*Client, error) {
+	// The socket is local and access to it is controlled by file permissions
+	// and peer credentials, so the connection needs no TLS.
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.NewClient("unix:"+path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{ControlClient: controlv1.NewControlClient(conn), conn: conn}, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,1857 @@
+// Control API for a running debswarm daemon, served over its Unix control
+// socket. Regenerate the Go code with 'make proto'.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: pkg/control/v1/control.proto
+
+package controlv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetCacheStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCacheStatsRequest) Reset() {
+	*x = GetCacheStatsRequest{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCacheStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCacheStatsRequest) ProtoMessage() {}
+
+func (x *GetCacheStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCacheStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetCacheStatsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{0}
+}
+
+type GetCacheStatsResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	TotalPackages  int64                  `protobuf:"varint,1,opt,name=total_packages,json=totalPackages,proto3" json:"total_packages,omitempty"`
+	TotalSize      int64                  `protobuf:"varint,2,opt,name=total_size,json=totalSize,proto3" json:"total_size,omitempty"`
+	MaxSize        int64                  `protobuf:"varint,3,opt,name=max_size,json=maxSize,proto3" json:"max_size,omitempty"`
+	BandwidthSaved int64                  `protobuf:"varint,4,opt,name=bandwidth_saved,json=bandwidthSaved,proto3" json:"bandwidth_saved,omitempty"`
+	PinnedCount    int64                  `protobuf:"varint,5,opt,name=pinned_count,json=pinnedCount,proto3" json:"pinned_count,omitempty"`
+	// Unset when the cache is empty
+	OldestAccess  *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=oldest_access,json=oldestAccess,proto3" json:"oldest_access,omitempty"`
+	NewestAccess  *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=newest_access,json=newestAccess,proto3" json:"newest_access,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCacheStatsResponse) Reset() {
+	*x = GetCacheStatsResponse{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCacheStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCacheStatsResponse) ProtoMessage() {}
+
+func (x *GetCacheStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCacheStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetCacheStatsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetCacheStatsResponse) GetTotalPackages() int64 {
+	if x != nil {
+		return x.TotalPackages
+	}
+	return 0
+}
+
+func (x *GetCacheStatsResponse) GetTotalSize() int64 {
+	if x != nil {
+		return x.TotalSize
+	}
+	return 0
+}
+
+func (x *GetCacheStatsResponse) GetMaxSize() int64 {
+	if x != nil {
+		return x.MaxSize
+	}
+	return 0
+}
+
+func (x *GetCacheStatsResponse) GetBandwidthSaved() int64 {
+	if x != nil {
+		return x.BandwidthSaved
+	}
+	return 0
+}
+
+func (x *GetCacheStatsResponse) GetPinnedCount() int64 {
+	if x != nil {
+		return x.PinnedCount
+	}
+	return 0
+}
+
+func (x *GetCacheStatsResponse) GetOldestAccess() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OldestAccess
+	}
+	return nil
+}
+
+func (x *GetCacheStatsResponse) GetNewestAccess() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NewestAccess
+	}
+	return nil
+}
+
+type Package struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sha256        string                 `protobuf:"bytes,1,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	Filename      string                 `protobuf:"bytes,3,opt,name=filename,proto3" json:"filename,omitempty"`
+	PackageName   string                 `protobuf:"bytes,4,opt,name=package_name,json=packageName,proto3" json:"package_name,omitempty"`
+	Version       string                 `protobuf:"bytes,5,opt,name=version,proto3" json:"version,omitempty"`
+	Architecture  string                 `protobuf:"bytes,6,opt,name=architecture,proto3" json:"architecture,omitempty"`
+	AddedAt       *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=added_at,json=addedAt,proto3" json:"added_at,omitempty"`
+	LastAccessed  *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=last_accessed,json=lastAccessed,proto3" json:"last_accessed,omitempty"`
+	AccessCount   int64                  `protobuf:"varint,9,opt,name=access_count,json=accessCount,proto3" json:"access_count,omitempty"`
+	Pinned        bool                   `protobuf:"varint,10,opt,name=pinned,proto3" json:"pinned,omitempty"`
+	Announced     bool                   `protobuf:"varint,11,opt,name=announced,proto3" json:"announced,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Package) Reset() {
+	*x = Package{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Package) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Package) ProtoMessage() {}
+
+func (x *Package) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Package.ProtoReflect.Descriptor instead.
+func (*Package) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Package) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *Package) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Package) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *Package) GetPackageName() string {
+	if x != nil {
+		return x.PackageName
+	}
+	return ""
+}
+
+func (x *Package) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Package) GetArchitecture() string {
+	if x != nil {
+		return x.Architecture
+	}
+	return ""
+}
+
+func (x *Package) GetAddedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.AddedAt
+	}
+	return nil
+}
+
+func (x *Package) GetLastAccessed() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastAccessed
+	}
+	return nil
+}
+
+func (x *Package) GetAccessCount() int64 {
+	if x != nil {
+		return x.AccessCount
+	}
+	return 0
+}
+
+func (x *Package) GetPinned() bool {
+	if x != nil {
+		return x.Pinned
+	}
+	return false
+}
+
+func (x *Package) GetAnnounced() bool {
+	if x != nil {
+		return x.Announced
+	}
+	return false
+}
+
+type ListPackagesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only pinned packages
+	Pinned bool `protobuf:"varint,1,opt,name=pinned,proto3" json:"pinned,omitempty"`
+	// Only packages with this name
+	Name string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// At most this many, default 100, up to 1000
+	Limit         int32 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPackagesRequest) Reset() {
+	*x = ListPackagesRequest{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPackagesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPackagesRequest) ProtoMessage() {}
+
+func (x *ListPackagesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPackagesRequest.ProtoReflect.Descriptor instead.
+func (*ListPackagesRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListPackagesRequest) GetPinned() bool {
+	if x != nil {
+		return x.Pinned
+	}
+	return false
+}
+
+func (x *ListPackagesRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ListPackagesRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type ListPackagesResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Packages []*Package             `protobuf:"bytes,1,rep,name=packages,proto3" json:"packages,omitempty"`
+	// Matching packages, before the limit
+	Total         int64 `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPackagesResponse) Reset() {
+	*x = ListPackagesResponse{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPackagesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPackagesResponse) ProtoMessage() {}
+
+func (x *ListPackagesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPackagesResponse.ProtoReflect.Descriptor instead.
+func (*ListPackagesResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListPackagesResponse) GetPackages() []*Package {
+	if x != nil {
+		return x.Packages
+	}
+	return nil
+}
+
+func (x *ListPackagesResponse) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+type PinPackageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sha256        string                 `protobuf:"bytes,1,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PinPackageRequest) Reset() {
+	*x = PinPackageRequest{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PinPackageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PinPackageRequest) ProtoMessage() {}
+
+func (x *PinPackageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PinPackageRequest.ProtoReflect.Descriptor instead.
+func (*PinPackageRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PinPackageRequest) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+type PinPackageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PinPackageResponse) Reset() {
+	*x = PinPackageResponse{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PinPackageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PinPackageResponse) ProtoMessage() {}
+
+func (x *PinPackageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PinPackageResponse.ProtoReflect.Descriptor instead.
+func (*PinPackageResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{6}
+}
+
+type UnpinPackageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sha256        string                 `protobuf:"bytes,1,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnpinPackageRequest) Reset() {
+	*x = UnpinPackageRequest{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnpinPackageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnpinPackageRequest) ProtoMessage() {}
+
+func (x *UnpinPackageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnpinPackageRequest.ProtoReflect.Descriptor instead.
+func (*UnpinPackageRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *UnpinPackageRequest) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+type UnpinPackageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnpinPackageResponse) Reset() {
+	*x = UnpinPackageResponse{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnpinPackageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnpinPackageResponse) ProtoMessage() {}
+
+func (x *UnpinPackageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnpinPackageResponse.ProtoReflect.Descriptor instead.
+func (*UnpinPackageResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{8}
+}
+
+type DeletePackageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sha256        string                 `protobuf:"bytes,1,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletePackageRequest) Reset() {
+	*x = DeletePackageRequest{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletePackageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePackageRequest) ProtoMessage() {}
+
+func (x *DeletePackageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePackageRequest.ProtoReflect.Descriptor instead.
+func (*DeletePackageRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DeletePackageRequest) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+type DeletePackageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletePackageResponse) Reset() {
+	*x = DeletePackageResponse{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletePackageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePackageResponse) ProtoMessage() {}
+
+func (x *DeletePackageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePackageResponse.ProtoReflect.Descriptor instead.
+func (*DeletePackageResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{10}
+}
+
+type Peer struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Id          string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name        string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Tags        []string               `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty"`
+	Score       float64                `protobuf:"fixed64,4,opt,name=score,proto3" json:"score,omitempty"`
+	Category    string                 `protobuf:"bytes,5,opt,name=category,proto3" json:"category,omitempty"`
+	Version     string                 `protobuf:"bytes,6,opt,name=version,proto3" json:"version,omitempty"`
+	Mdns        bool                   `protobuf:"varint,7,opt,name=mdns,proto3" json:"mdns,omitempty"`
+	Blacklisted bool                   `protobuf:"varint,8,opt,name=blacklisted,proto3" json:"blacklisted,omitempty"`
+	// closed, open or half-open
+	Breaker             string                 `protobuf:"bytes,9,opt,name=breaker,proto3" json:"breaker,omitempty"`
+	ConsecutiveFailures int32                  `protobuf:"varint,10,opt,name=consecutive_failures,json=consecutiveFailures,proto3" json:"consecutive_failures,omitempty"`
+	LastSeen            *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *Peer) Reset() {
+	*x = Peer{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Peer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Peer) ProtoMessage() {}
+
+func (x *Peer) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Peer.ProtoReflect.Descriptor instead.
+func (*Peer) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Peer) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Peer) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Peer) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Peer) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Peer) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *Peer) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Peer) GetMdns() bool {
+	if x != nil {
+		return x.Mdns
+	}
+	return false
+}
+
+func (x *Peer) GetBlacklisted() bool {
+	if x != nil {
+		return x.Blacklisted
+	}
+	return false
+}
+
+func (x *Peer) GetBreaker() string {
+	if x != nil {
+		return x.Breaker
+	}
+	return ""
+}
+
+func (x *Peer) GetConsecutiveFailures() int32 {
+	if x != nil {
+		return x.ConsecutiveFailures
+	}
+	return 0
+}
+
+func (x *Peer) GetLastSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastSeen
+	}
+	return nil
+}
+
+type ListPeersRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPeersRequest) Reset() {
+	*x = ListPeersRequest{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPeersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPeersRequest) ProtoMessage() {}
+
+func (x *ListPeersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPeersRequest.ProtoReflect.Descriptor instead.
+func (*ListPeersRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{12}
+}
+
+type ListPeersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Peers         []*Peer                `protobuf:"bytes,1,rep,name=peers,proto3" json:"peers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPeersResponse) Reset() {
+	*x = ListPeersResponse{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPeersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPeersResponse) ProtoMessage() {}
+
+func (x *ListPeersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPeersResponse.ProtoReflect.Descriptor instead.
+func (*ListPeersResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ListPeersResponse) GetPeers() []*Peer {
+	if x != nil {
+		return x.Peers
+	}
+	return nil
+}
+
+type SetPeerLabelRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PeerId        string                 `protobuf:"bytes,1,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Tags          []string               `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetPeerLabelRequest) Reset() {
+	*x = SetPeerLabelRequest{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetPeerLabelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetPeerLabelRequest) ProtoMessage() {}
+
+func (x *SetPeerLabelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetPeerLabelRequest.ProtoReflect.Descriptor instead.
+func (*SetPeerLabelRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *SetPeerLabelRequest) GetPeerId() string {
+	if x != nil {
+		return x.PeerId
+	}
+	return ""
+}
+
+func (x *SetPeerLabelRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SetPeerLabelRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type SetPeerLabelResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The label as stored, normalized
+	Name          string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Tags          []string `protobuf:"bytes,2,rep,name=tags,proto3" json:"tags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetPeerLabelResponse) Reset() {
+	*x = SetPeerLabelResponse{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetPeerLabelResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetPeerLabelResponse) ProtoMessage() {}
+
+func (x *SetPeerLabelResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetPeerLabelResponse.ProtoReflect.Descriptor instead.
+func (*SetPeerLabelResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *SetPeerLabelResponse) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SetPeerLabelResponse) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type P2PState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Paused        bool                   `protobuf:"varint,1,opt,name=paused,proto3" json:"paused,omitempty"`
+	Since         *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=since,proto3" json:"since,omitempty"`
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *P2PState) Reset() {
+	*x = P2PState{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *P2PState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*P2PState) ProtoMessage() {}
+
+func (x *P2PState) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use P2PState.ProtoReflect.Descriptor instead.
+func (*P2PState) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *P2PState) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+func (x *P2PState) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *P2PState) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type GetP2PStateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetP2PStateRequest) Reset() {
+	*x = GetP2PStateRequest{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetP2PStateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetP2PStateRequest) ProtoMessage() {}
+
+func (x *GetP2PStateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetP2PStateRequest.ProtoReflect.Descriptor instead.
+func (*GetP2PStateRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{17}
+}
+
+type GetP2PStateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	State         *P2PState              `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetP2PStateResponse) Reset() {
+	*x = GetP2PStateResponse{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetP2PStateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetP2PStateResponse) ProtoMessage() {}
+
+func (x *GetP2PStateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetP2PStateResponse.ProtoReflect.Descriptor instead.
+func (*GetP2PStateResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *GetP2PStateResponse) GetState() *P2PState {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+type PauseP2PRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reason        string                 `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PauseP2PRequest) Reset() {
+	*x = PauseP2PRequest{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PauseP2PRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PauseP2PRequest) ProtoMessage() {}
+
+func (x *PauseP2PRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PauseP2PRequest.ProtoReflect.Descriptor instead.
+func (*PauseP2PRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *PauseP2PRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type PauseP2PResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	State         *P2PState              `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PauseP2PResponse) Reset() {
+	*x = PauseP2PResponse{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PauseP2PResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PauseP2PResponse) ProtoMessage() {}
+
+func (x *PauseP2PResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PauseP2PResponse.ProtoReflect.Descriptor instead.
+func (*PauseP2PResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *PauseP2PResponse) GetState() *P2PState {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+type ResumeP2PRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeP2PRequest) Reset() {
+	*x = ResumeP2PRequest{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeP2PRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeP2PRequest) ProtoMessage() {}
+
+func (x *ResumeP2PRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeP2PRequest.ProtoReflect.Descriptor instead.
+func (*ResumeP2PRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{21}
+}
+
+type ResumeP2PResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	State         *P2PState              `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeP2PResponse) Reset() {
+	*x = ResumeP2PResponse{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeP2PResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeP2PResponse) ProtoMessage() {}
+
+func (x *ResumeP2PResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeP2PResponse.ProtoReflect.Descriptor instead.
+func (*ResumeP2PResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ResumeP2PResponse) GetState() *P2PState {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+type Download struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Id       int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Sha256   string                 `protobuf:"bytes,2,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	Name     string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Size     int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	Received int64                  `protobuf:"varint,5,opt,name=received,proto3" json:"received,omitempty"`
+	Started  *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=started,proto3" json:"started,omitempty"`
+	// Unset while active
+	Finished *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=finished,proto3" json:"finished,omitempty"`
+	// peer, mirror or mixed
+	Source        string `protobuf:"bytes,8,opt,name=source,proto3" json:"source,omitempty"`
+	Error         string `protobuf:"bytes,9,opt,name=error,proto3" json:"error,omitempty"`
+	Retries       int32  `protobuf:"varint,10,opt,name=retries,proto3" json:"retries,omitempty"`
+	Chunks        int32  `protobuf:"varint,11,opt,name=chunks,proto3" json:"chunks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Download) Reset() {
+	*x = Download{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Download) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Download) ProtoMessage() {}
+
+func (x *Download) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Download.ProtoReflect.Descriptor instead.
+func (*Download) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *Download) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Download) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *Download) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Download) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Download) GetReceived() int64 {
+	if x != nil {
+		return x.Received
+	}
+	return 0
+}
+
+func (x *Download) GetStarted() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Started
+	}
+	return nil
+}
+
+func (x *Download) GetFinished() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Finished
+	}
+	return nil
+}
+
+func (x *Download) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Download) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Download) GetRetries() int32 {
+	if x != nil {
+		return x.Retries
+	}
+	return 0
+}
+
+func (x *Download) GetChunks() int32 {
+	if x != nil {
+		return x.Chunks
+	}
+	return 0
+}
+
+type ListDownloadsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDownloadsRequest) Reset() {
+	*x = ListDownloadsRequest{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDownloadsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDownloadsRequest) ProtoMessage() {}
+
+func (x *ListDownloadsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDownloadsRequest.ProtoReflect.Descriptor instead.
+func (*ListDownloadsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{24}
+}
+
+type ListDownloadsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Active downloads, oldest first, then finished ones, newest first
+	Downloads     []*Download `protobuf:"bytes,1,rep,name=downloads,proto3" json:"downloads,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDownloadsResponse) Reset() {
+	*x = ListDownloadsResponse{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDownloadsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDownloadsResponse) ProtoMessage() {}
+
+func (x *ListDownloadsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDownloadsResponse.ProtoReflect.Descriptor instead.
+func (*ListDownloadsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *ListDownloadsResponse) GetDownloads() []*Download {
+	if x != nil {
+		return x.Downloads
+	}
+	return nil
+}
+
+type GetConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetConfigRequest) Reset() {
+	*x = GetConfigRequest{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetConfigRequest) ProtoMessage() {}
+
+func (x *GetConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetConfigRequest.ProtoReflect.Descriptor instead.
+func (*GetConfigRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{26}
+}
+
+type GetConfigResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Toml          string                 `protobuf:"bytes,1,opt,name=toml,proto3" json:"toml,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetConfigResponse) Reset() {
+	*x = GetConfigResponse{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetConfigResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetConfigResponse) ProtoMessage() {}
+
+func (x *GetConfigResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetConfigResponse.ProtoReflect.Descriptor instead.
+func (*GetConfigResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *GetConfigResponse) GetToml() string {
+	if x != nil {
+		return x.Toml
+	}
+	return ""
+}
+
+type ReloadConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReloadConfigRequest) Reset() {
+	*x = ReloadConfigRequest{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReloadConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReloadConfigRequest) ProtoMessage() {}
+
+func (x *ReloadConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReloadConfigRequest.ProtoReflect.Descriptor instead.
+func (*ReloadConfigRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{28}
+}
+
+type ReloadConfigResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReloadConfigResponse) Reset() {
+	*x = ReloadConfigResponse{}
+	mi := &file_pkg_control_v1_control_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReloadConfigResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReloadConfigResponse) ProtoMessage() {}
+
+func (x *ReloadConfigResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_control_v1_control_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReloadConfigResponse.ProtoReflect.Descriptor instead.
+func (*ReloadConfigResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_control_v1_control_proto_rawDescGZIP(), []int{29}
+}
+
+var File_pkg_control_v1_control_proto protoreflect.FileDescriptor
+
+const file_pkg_control_v1_control_proto_rawDesc = "" +
+	"\n" +
+	"\x1cpkg/control/v1/control.proto\x12\x13debswarm.control.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x16\n" +
+	"\x14GetCacheStatsRequest\"\xc6\x02\n" +
+	"\x15GetCacheStatsResponse\x12%\n" +
+	"\x0etotal_packages\x18\x01 \x01(\x03R\rtotalPackages\x12\x1d\n" +
+	"\n" +
+	"total_size\x18\x02 \x01(\x03R\ttotalSize\x12\x19\n" +
+	"\bmax_size\x18\x03 \x01(\x03R\amaxSize\x12'\n" +
+	"\x0fbandwidth_saved\x18\x04 \x01(\x03R\x0ebandwidthSaved\x12!\n" +
+	"\fpinned_count\x18\x05 \x01(\x03R\vpinnedCount\x12?\n" +
+	"\roldest_access\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\foldestAccess\x12?\n" +
+	"\rnewest_access\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\fnewestAccess\"\x83\x03\n" +
+	"\aPackage\x12\x16\n" +
+	"\x06sha256\x18\x01 \x01(\tR\x06sha256\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\x12\x1a\n" +
+	"\bfilename\x18\x03 \x01(\tR\bfilename\x12!\n" +
+	"\fpackage_name\x18\x04 \x01(\tR\vpackageName\x12\x18\n" +
+	"\aversion\x18\x05 \x01(\tR\aversion\x12\"\n" +
+	"\farchitecture\x18\x06 \x01(\tR\farchitecture\x125\n" +
+	"\badded_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\aaddedAt\x12?\n" +
+	"\rlast_accessed\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\flastAccessed\x12!\n" +
+	"\faccess_count\x18\t \x01(\x03R\vaccessCount\x12\x16\n" +
+	"\x06pinned\x18\n" +
+	" \x01(\bR\x06pinned\x12\x1c\n" +
+	"\tannounced\x18\v \x01(\bR\tannounced\"W\n" +
+	"\x13ListPackagesRequest\x12\x16\n" +
+	"\x06pinned\x18\x01 \x01(\bR\x06pinned\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limit\"f\n" +
+	"\x14ListPackagesResponse\x128\n" +
+	"\bpackages\x18\x01 \x03(\v2\x1c.debswarm.control.v1.PackageR\bpackages\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x03R\x05total\"+\n" +
+	"\x11PinPackageRequest\x12\x16\n" +
+	"\x06sha256\x18\x01 \x01(\tR\x06sha256\"\x14\n" +
+	"\x12PinPackageResponse\"-\n" +
+	"\x13UnpinPackageRequest\x12\x16\n" +
+	"\x06sha256\x18\x01 \x01(\tR\x06sha256\"\x16\n" +
+	"\x14UnpinPackageResponse\".\n" +
+	"\x14DeletePackageRequest\x12\x16\n" +
+	"\x06sha256\x18\x01 \x01(\tR\x06sha256\"\x17\n" +
+	"\x15DeletePackageResponse\"\xc6\x02\n" +
+	"\x04Peer\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04tags\x18\x03 \x03(\tR\x04tags\x12\x14\n" +
+	"\x05score\x18\x04 \x01(\x01R\x05score\x12\x1a\n" +
+	"\bcategory\x18\x05 \x01(\tR\bcategory\x12\x18\n" +
+	"\aversion\x18\x06 \x01(\tR\aversion\x12\x12\n" +
+	"\x04mdns\x18\a \x01(\bR\x04mdns\x12 \n" +
+	"\vblacklisted\x18\b \x01(\bR\vblacklisted\x12\x18\n" +
+	"\abreaker\x18\t \x01(\tR\abreaker\x121\n" +
+	"\x14consecutive_failures\x18\n" +
+	" \x01(\x05R\x13consecutiveFailures\x127\n" +
+	"\tlast_seen\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\blastSeen\"\x12\n" +
+	"\x10ListPeersRequest\"D\n" +
+	"\x11ListPeersResponse\x12/\n" +
+	"\x05peers\x18\x01 \x03(\v2\x19.debswarm.control.v1.PeerR\x05peers\"V\n" +
+	"\x13SetPeerLabelRequest\x12\x17\n" +
+	"\apeer_id\x18\x01 \x01(\tR\x06peerId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04tags\x18\x03 \x03(\tR\x04tags\">\n" +
+	"\x14SetPeerLabelResponse\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04tags\x18\x02 \x03(\tR\x04tags\"l\n" +
+	"\bP2PState\x12\x16\n" +
+	"\x06paused\x18\x01 \x01(\bR\x06paused\x120\n" +
+	"\x05since\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\"\x14\n" +
+	"\x12GetP2PStateRequest\"J\n" +
+	"\x13GetP2PStateResponse\x123\n" +
+	"\x05state\x18\x01 \x01(\v2\x1d.debswarm.control.v1.P2PStateR\x05state\")\n" +
+	"\x0fPauseP2PRequest\x12\x16\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason\"G\n" +
+	"\x10PauseP2PResponse\x123\n" +
+	"\x05state\x18\x01 \x01(\v2\x1d.debswarm.control.v1.P2PStateR\x05state\"\x12\n" +
+	"\x10ResumeP2PRequest\"H\n" +
+	"\x11ResumeP2PResponse\x123\n" +
+	"\x05state\x18\x01 \x01(\v2\x1d.debswarm.control.v1.P2PStateR\x05state\"\xc4\x02\n" +
+	"\bDownload\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x16\n" +
+	"\x06sha256\x18\x02 \x01(\tR\x06sha256\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\x12\x1a\n" +
+	"\breceived\x18\x05 \x01(\x03R\breceived\x124\n" +
+	"\astarted\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\astarted\x126\n" +
+	"\bfinished\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\bfinished\x12\x16\n" +
+	"\x06source\x18\b \x01(\tR\x06source\x12\x14\n" +
+	"\x05error\x18\t \x01(\tR\x05error\x12\x18\n" +
+	"\aretries\x18\n" +
+	" \x01(\x05R\aretries\x12\x16\n" +
+	"\x06chunks\x18\v \x01(\x05R\x06chunks\"\x16\n" +
+	"\x14ListDownloadsRequest\"T\n" +
+	"\x15ListDownloadsResponse\x12;\n" +
+	"\tdownloads\x18\x01 \x03(\v2\x1d.debswarm.control.v1.DownloadR\tdownloads\"\x12\n" +
+	"\x10GetConfigRequest\"'\n" +
+	"\x11GetConfigResponse\x12\x12\n" +
+	"\x04toml\x18\x01 \x01(\tR\x04toml\"\x15\n" +
+	"\x13ReloadConfigRequest\"\x16\n" +
+	"\x14ReloadConfigResponse2\x83\n" +
+	"\n" +
+	"\aControl\x12f\n" +
+	"\rGetCacheStats\x12).debswarm.control.v1.GetCacheStatsRequest\x1a*.debswarm.control.v1.GetCacheStatsResponse\x12c\n" +
+	"\fListPackages\x12(.debswarm.control.v1.ListPackagesRequest\x1a).debswarm.control.v1.ListPackagesResponse\x12]\n" +
+	"\n" +
+	"PinPackage\x12&.debswarm.control.v1.PinPackageRequest\x1a'.debswarm.control.v1.PinPackageResponse\x12c\n" +
+	"\fUnpinPackage\x12(.debswarm.control.v1.UnpinPackageRequest\x1a).debswarm.control.v1.UnpinPackageResponse\x12f\n" +
+	"\rDeletePackage\x12).debswarm.control.v1.DeletePackageRequest\x1a*.debswarm.control.v1.DeletePackageResponse\x12Z\n" +
+	"\tListPeers\x12%.debswarm.control.v1.ListPeersRequest\x1a&.debswarm.control.v1.ListPeersResponse\x12c\n" +
+	"\fSetPeerLabel\x12(.debswarm.control.v1.SetPeerLabelRequest\x1a).debswarm.control.v1.SetPeerLabelResponse\x12`\n" +
+	"\vGetP2PState\x12'.debswarm.control.v1.GetP2PStateRequest\x1a(.debswarm.control.v1.GetP2PStateResponse\x12W\n" +
+	"\bPauseP2P\x12$.debswarm.control.v1.PauseP2PRequest\x1a%.debswarm.control.v1.PauseP2PResponse\x12Z\n" +
+	"\tResumeP2P\x12%.debswarm.control.v1.ResumeP2PRequest\x1a&.debswarm.control.v1.ResumeP2PResponse\x12f\n" +
+	"\rListDownloads\x12).debswarm.control.v1.ListDownloadsRequest\x1a*.debswarm.control.v1.ListDownloadsResponse\x12Z\n" +
+	"\tGetConfig\x12%.debswarm.control.v1.GetConfigRequest\x1a&.debswarm.control.v1.GetConfigResponse\x12c\n" +
+	"\fReloadConfig\x12(.debswarm.control.v1.ReloadConfigRequest\x1a).debswarm.control.v1.ReloadConfigResponseB7Z5github.com/debswarm/debswarm/pkg/control/v1;controlv1b\x06proto3"
+
+var (
+	file_pkg_control_v1_control_proto_rawDescOnce sync.Once
+	file_pkg_control_v1_control_proto_rawDescData []byte
+)
+
+func file_pkg_control_v1_control_proto_rawDescGZIP() []byte {
+	file_pkg_control_v1_control_proto_rawDescOnce.Do(func() {
+		file_pkg_control_v1_control_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pkg_control_v1_control_proto_rawDesc), len(file_pkg_control_v1_control_proto_rawDesc)))
+	})
+	return file_pkg_control_v1_control_proto_rawDescData
+}
+
+var file_pkg_control_v1_control_proto_msgTypes = make([]protoimpl.MessageInfo, 30)
+var file_pkg_control_v1_control_proto_goTypes = []any{
+	(*GetCacheStatsRequest)(nil),  // 0: debswarm.control.v1.GetCacheStatsRequest
+	(*GetCacheStatsResponse)(nil), // 1: debswarm.control.v1.GetCacheStatsResponse
+	(*Package)(nil),               // 2: debswarm.control.v1.Package
+	(*ListPackagesRequest)(nil),   // 3: debswarm.control.v1.ListPackagesRequest
+	(*ListPackagesResponse)(nil),  // 4: debswarm.control.v1.ListPackagesResponse
+	(*PinPackageRequest)(nil),     // 5: debswarm.control.v1.PinPackageRequest
+	(*PinPackageResponse)(nil),    // 6: debswarm.control.v1.PinPackageResponse
+	(*UnpinPackageRequest)(nil),   // 7: debswarm.control.v1.UnpinPackageRequest
+	(*UnpinPackageResponse)(nil),  // 8: debswarm.control.v1.UnpinPackageResponse
+	(*DeletePackageRequest)(nil),  // 9: debswarm.control.v1.DeletePackageRequest
+	(*DeletePackageResponse)(nil), // 10: debswarm.control.v1.DeletePackageResponse
+	(*Peer)(nil),                  // 11: debswarm.control.v1.Peer
+	(*ListPeersRequest)(nil),      // 12: debswarm.control.v1.ListPeersRequest
+	(*ListPeersResponse)(nil),     // 13: debswarm.control.v1.ListPeersResponse
+	(*SetPeerLabelRequest)(nil),   // 14: debswarm.control.v1.SetPeerLabelRequest
+	(*SetPeerLabelResponse)(nil),  // 15: debswarm.control.v1.SetPeerLabelResponse
+	(*P2PState)(nil),              // 16: debswarm.control.v1.P2PState
+	(*GetP2PStateRequest)(nil),    // 17: debswarm.control.v1.GetP2PStateRequest
+	(*GetP2PStateResponse)(nil),   // 18: debswarm.control.v1.GetP2PStateResponse
+	(*PauseP2PRequest)(nil),       // 19: debswarm.control.v1.PauseP2PRequest
+	(*PauseP2PResponse)(nil),      // 20: debswarm.control.v1.PauseP2PResponse
+	(*ResumeP2PRequest)(nil),      // 21: debswarm.control.v1.ResumeP2PRequest
+	(*ResumeP2PResponse)(nil),     // 22: debswarm.control.v1.ResumeP2PResponse
+	(*Download)(nil),              // 23: debswarm.control.v1.Download
+	(*ListDownloadsRequest)(nil),  // 24: debswarm.control.v1.ListDownloadsRequest
+	(*ListDownloadsResponse)(nil), // 25: debswarm.control.v1.ListDownloadsResponse
+	(*GetConfigRequest)(nil),      // 26: debswarm.control.v1.GetConfigRequest
+	(*GetConfigResponse)(nil),     // 27: debswarm.control.v1.GetConfigResponse
+	(*ReloadConfigRequest)(nil),   // 28: debswarm.control.v1.ReloadConfigRequest
+	(*ReloadConfigResponse)(nil),  // 29: debswarm.control.v1.ReloadConfigResponse
+	(*timestamppb.Timestamp)(nil), // 30: google.protobuf.Timestamp
+}
+var file_pkg_control_v1_control_proto_depIdxs = []int32{
+	30, // 0: debswarm.control.v1.GetCacheStatsResponse.oldest_access:type_name -> google.protobuf.Timestamp
+	30, // 1: debswarm.control.v1.GetCacheStatsResponse.newest_access:type_name -> google.protobuf.Timestamp
+	30, // 2: debswarm.control.v1.Package.added_at:type_name -> google.protobuf.Timestamp
+	30, // 3: debswarm.control.v1.Package.last_accessed:type_name -> google.protobuf.Timestamp
+	2,  // 4: debswarm.control.v1.ListPackagesResponse.packages:type_name -> debswarm.control.v1.Package
+	30, // 5: debswarm.control.v1.Peer.last_seen:type_name -> google.protobuf.Timestamp
+	11, // 6: debswarm.control.v1.ListPeersResponse.peers:type_name -> debswarm.control.v1.Peer
+	30, // 7: debswarm.control.v1.P2PState.since:type_name -> google.protobuf.Timestamp
+	16, // 8: debswarm.control.v1.GetP2PStateResponse.state:type_name -> debswarm.control.v1.P2PState
+	16, // 9: debswarm.control.v1.PauseP2PResponse.state:type_name -> debswarm.control.v1.P2PState
+	16, // 10: debswarm.control.v1.ResumeP2PResponse.state:type_name -> debswarm.control.v1.P2PState
+	30, // 11: debswarm.control.v1.Download.started:type_name -> google.protobuf.Timestamp
+	30, // 12: debswarm.control.v1.Download.finished:type_name -> google.protobuf.Timestamp
+	23, // 13: debswarm.control.v1.ListDownloadsResponse.downloads:type_name -> debswarm.control.v1.Download
+	0,  // 14: debswarm.control.v1.Control.GetCacheStats:input_type -> debswarm.control.v1.GetCacheStatsRequest
+	3,  // 15: debswarm.control.v1.Control.ListPackages:input_type -> debswarm.control.v1.ListPackagesRequest
+	5,  // 16: debswarm.control.v1.Control.PinPackage:input_type -> debswarm.control.v1.PinPackageRequest
+	7,  // 17: debswarm.control.v1.Control.UnpinPackage:input_type -> debswarm.control.v1.UnpinPackageRequest
+	9,  // 18: debswarm.control.v1.Control.DeletePackage:input_type -> debswarm.control.v1.DeletePackageRequest
+	12, // 19: debswarm.control.v1.Control.ListPeers:input_type -> debswarm.control.v1.ListPeersRequest
+	14, // 20: debswarm.control.v1.Control.SetPeerLabel:input_type -> debswarm.control.v1.SetPeerLabelRequest
+	17, // 21: debswarm.control.v1.Control.GetP2PState:input_type -> debswarm.control.v1.GetP2PStateRequest
+	19, // 22: debswarm.control.v1.Control.PauseP2P:input_type -> debswarm.control.v1.PauseP2PRequest
+	21, // 23: debswarm.control.v1.Control.ResumeP2P:input_type -> debswarm.control.v1.ResumeP2PRequest
+	24, // 24: debswarm.control.v1.Control.ListDownloads:input_type -> debswarm.control.v1.ListDownloadsRequest
+	26, // 25: debswarm.control.v1.Control.GetConfig:input_type -> debswarm.control.v1.GetConfigRequest
+	28, // 26: debswarm.control.v1.Control.ReloadConfig:input_type -> debswarm.control.v1.ReloadConfigRequest
+	1,  // 27: debswarm.control.v1.Control.GetCacheStats:output_type -> debswarm.control.v1.GetCacheStatsResponse
+	4,  // 28: debswarm.control.v1.Control.ListPackages:output_type -> debswarm.control.v1.ListPackagesResponse
+	6,  // 29: debswarm.control.v1.Control.PinPackage:output_type -> debswarm.control.v1.PinPackageResponse
+	8,  // 30: debswarm.control.v1.Control.UnpinPackage:output_type -> debswarm.control.v1.UnpinPackageResponse
+	10, // 31: debswarm.control.v1.Control.DeletePackage:output_type -> debswarm.control.v1.DeletePackageResponse
+	13, // 32: debswarm.control.v1.Control.ListPeers:output_type -> debswarm.control.v1.ListPeersResponse
+	15, // 33: debswarm.control.v1.Control.SetPeerLabel:output_type -> debswarm.control.v1.SetPeerLabelResponse
+	18, // 34: debswarm.control.v1.Control.GetP2PState:output_type -> debswarm.control.v1.GetP2PStateResponse
+	20, // 35: debswarm.control.v1.Control.PauseP2P:output_type -> debswarm.control.v1.PauseP2PResponse
+	22, // 36: debswarm.control.v1.Control.ResumeP2P:output_type -> debswarm.control.v1.ResumeP2PResponse
+	25, // 37: debswarm.control.v1.Control.ListDownloads:output_type -> debswarm.control.v1.ListDownloadsResponse
+	27, // 38: debswarm.control.v1.Control.GetConfig:output_type -> debswarm.control.v1.GetConfigResponse
+	29, // 39: debswarm.control.v1.Control.ReloadConfig:output_type -> debswarm.control.v1.ReloadConfigResponse
+	27, // [27:40] is the sub-list for method output_type
+	14, // [14:27] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
+}
+
+func init() { file_pkg_control_v1_control_proto_init() }
+func file_pkg_control_v1_control_proto_init() {
+	if File_pkg_control_v1_control_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_control_v1_control_proto_rawDesc), len(file_pkg_control_v1_control_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   30,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pkg_control_v1_control_proto_goTypes,
+		DependencyIndexes: file_pkg_control_v1_control_proto_depIdxs,
+		MessageInfos:      file_pkg_control_v1_control_proto_msgTypes,
+	}.Build()
+	File_pkg_control_v1_control_proto = out.File
+	file_pkg_control_v1_control_proto_goTypes = nil
+	file_pkg_control_v1_control_proto_depIdxs = nil
+}
@@ -0,0 +1,616 @@
+// Control API for a running debswarm daemon, served over its Unix control
+// socket. Regenerate the Go code with 'make proto'.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: pkg/control/v1/control.proto
+
+package controlv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Control_GetCacheStats_FullMethodName = "/debswarm.control.v1.Control/GetCacheStats"
+	Control_ListPackages_FullMethodName  = "/debswarm.control.v1.Control/ListPackages"
+	Control_PinPackage_FullMethodName    = "/debswarm.control.v1.Control/PinPackage"
+	Control_UnpinPackage_FullMethodName  = "/debswarm.control.v1.Control/UnpinPackage"
+	Control_DeletePackage_FullMethodName = "/debswarm.control.v1.Control/DeletePackage"
+	Control_ListPeers_FullMethodName     = "/debswarm.control.v1.Control/ListPeers"
+	Control_SetPeerLabel_FullMethodName  = "/debswarm.control.v1.Control/SetPeerLabel"
+	Control_GetP2PState_FullMethodName   = "/debswarm.control.v1.Control/GetP2PState"
+	Control_PauseP2P_FullMethodName      = "/debswarm.control.v1.Control/PauseP2P"
+	Control_ResumeP2P_FullMethodName     = "/debswarm.control.v1.Control/ResumeP2P"
+	Control_ListDownloads_FullMethodName = "/debswarm.control.v1.Control/ListDownloads"
+	Control_GetConfig_FullMethodName     = "/debswarm.control.v1.Control/GetConfig"
+	Control_ReloadConfig_FullMethodName  = "/debswarm.control.v1.Control/ReloadConfig"
+)
+
+// ControlClient is the client API for Control service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Control manages the daemon's cache, peers, downloads and configuration.
+// Methods that change state need an admin caller (see [control] in the
+// configuration); the others are open to anyone who can reach the socket.
+type ControlClient interface {
+	// GetCacheStats summarizes the package cache.
+	GetCacheStats(ctx context.Context, in *GetCacheStatsRequest, opts ...grpc.CallOption) (*GetCacheStatsResponse, error)
+	// ListPackages lists cached packages.
+	ListPackages(ctx context.Context, in *ListPackagesRequest, opts ...grpc.CallOption) (*ListPackagesResponse, error)
+	// PinPackage keeps a package from eviction. Admin only.
+	PinPackage(ctx context.Context, in *PinPackageRequest, opts ...grpc.CallOption) (*PinPackageResponse, error)
+	// UnpinPackage makes a pinned package evictable again. Admin only.
+	UnpinPackage(ctx context.Context, in *UnpinPackageRequest, opts ...grpc.CallOption) (*UnpinPackageResponse, error)
+	// DeletePackage removes a package from the cache. Admin only.
+	DeletePackage(ctx context.Context, in *DeletePackageRequest, opts ...grpc.CallOption) (*DeletePackageResponse, error)
+	// ListPeers lists the peers the daemon has scored.
+	ListPeers(ctx context.Context, in *ListPeersRequest, opts ...grpc.CallOption) (*ListPeersResponse, error)
+	// SetPeerLabel names and tags a peer; an empty label removes it. Admin only.
+	SetPeerLabel(ctx context.Context, in *SetPeerLabelRequest, opts ...grpc.CallOption) (*SetPeerLabelResponse, error)
+	// GetP2PState reports whether P2P participation is paused.
+	GetP2PState(ctx context.Context, in *GetP2PStateRequest, opts ...grpc.CallOption) (*GetP2PStateResponse, error)
+	// PauseP2P stops uploads, announcements and P2P downloads. Admin only.
+	PauseP2P(ctx context.Context, in *PauseP2PRequest, opts ...grpc.CallOption) (*PauseP2PResponse, error)
+	// ResumeP2P undoes PauseP2P. Admin only.
+	ResumeP2P(ctx context.Context, in *ResumeP2PRequest, opts ...grpc.CallOption) (*ResumeP2PResponse, error)
+	// ListDownloads lists package downloads in progress and recently finished.
+	ListDownloads(ctx context.Context, in *ListDownloadsRequest, opts ...grpc.CallOption) (*ListDownloadsResponse, error)
+	// GetConfig returns the configuration the daemon is running with, as TOML.
+	// Admin only: it reveals paths and network layout.
+	GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*GetConfigResponse, error)
+	// ReloadConfig reloads the configuration file, as on SIGHUP. Admin only.
+	ReloadConfig(ctx context.Context, in *ReloadConfigRequest, opts ...grpc.CallOption) (*ReloadConfigResponse, error)
+}
+
+type controlClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewControlClient(cc grpc.ClientConnInterface) ControlClient {
+	return &controlClient{cc}
+}
+
+func (c *controlClient) GetCacheStats(ctx context.Context, in *GetCacheStatsRequest, opts ...grpc.CallOption) (*GetCacheStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCacheStatsResponse)
+	err := c.cc.Invoke(ctx, Control_GetCacheStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) ListPackages(ctx context.Context, in *ListPackagesRequest, opts ...grpc.CallOption) (*ListPackagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListPackagesResponse)
+	err := c.cc.Invoke(ctx, Control_ListPackages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) PinPackage(ctx context.Context, in *PinPackageRequest, opts ...grpc.CallOption) (*PinPackageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PinPackageResponse)
+	err := c.cc.Invoke(ctx, Control_PinPackage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) UnpinPackage(ctx context.Context, in *UnpinPackageRequest, opts ...grpc.CallOption) (*UnpinPackageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UnpinPackageResponse)
+	err := c.cc.Invoke(ctx, Control_UnpinPackage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) DeletePackage(ctx context.Context, in *DeletePackageRequest, opts ...grpc.CallOption) (*DeletePackageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeletePackageResponse)
+	err := c.cc.Invoke(ctx, Control_DeletePackage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) ListPeers(ctx context.Context, in *ListPeersRequest, opts ...grpc.CallOption) (*ListPeersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListPeersResponse)
+	err := c.cc.Invoke(ctx, Control_ListPeers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) SetPeerLabel(ctx context.Context, in *SetPeerLabelRequest, opts ...grpc.CallOption) (*SetPeerLabelResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetPeerLabelResponse)
+	err := c.cc.Invoke(ctx, Control_SetPeerLabel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) GetP2PState(ctx context.Context, in *GetP2PStateRequest, opts ...grpc.CallOption) (*GetP2PStateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetP2PStateResponse)
+	err := c.cc.Invoke(ctx, Control_GetP2PState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) PauseP2P(ctx context.Context, in *PauseP2PRequest, opts ...grpc.CallOption) (*PauseP2PResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PauseP2PResponse)
+	err := c.cc.Invoke(ctx, Control_PauseP2P_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) ResumeP2P(ctx context.Context, in *ResumeP2PRequest, opts ...grpc.CallOption) (*ResumeP2PResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResumeP2PResponse)
+	err := c.cc.Invoke(ctx, Control_ResumeP2P_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) ListDownloads(ctx context.Context, in *ListDownloadsRequest, opts ...grpc.CallOption) (*ListDownloadsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDownloadsResponse)
+	err := c.cc.Invoke(ctx, Control_ListDownloads_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*GetConfigResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetConfigResponse)
+	err := c.cc.Invoke(ctx, Control_GetConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) ReloadConfig(ctx context.Context, in *ReloadConfigRequest, opts ...grpc.CallOption) (*ReloadConfigResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReloadConfigResponse)
+	err := c.cc.Invoke(ctx, Control_ReloadConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ControlServer is the server API for Control service.
+// All implementations must embed UnimplementedControlServer
+// for forward compatibility.
+//
+// Control manages the daemon's cache, peers, downloads and configuration.
+// Methods that change state need an admin caller (see [control] in the
+// configuration); the others are open to anyone who can reach the socket.
+type ControlServer interface {
+	// GetCacheStats summarizes the package cache.
+	GetCacheStats(context.Context, *GetCacheStatsRequest) (*GetCacheStatsResponse, error)
+	// ListPackages lists cached packages.
+	ListPackages(context.Context, *ListPackagesRequest) (*ListPackagesResponse, error)
+	// PinPackage keeps a package from eviction. Admin only.
+	PinPackage(context.Context, *PinPackageRequest) (*PinPackageResponse, error)
+	// UnpinPackage makes a pinned package evictable again. Admin only.
+	UnpinPackage(context.Context, *UnpinPackageRequest) (*UnpinPackageResponse, error)
+	// DeletePackage removes a package from the cache. Admin only.
+	DeletePackage(context.Context, *DeletePackageRequest) (*DeletePackageResponse, error)
+	// ListPeers lists the peers the daemon has scored.
+	ListPeers(context.Context, *ListPeersRequest) (*ListPeersResponse, error)
+	// SetPeerLabel names and tags a peer; an empty label removes it. Admin only.
+	SetPeerLabel(context.Context, *SetPeerLabelRequest) (*SetPeerLabelResponse, error)
+	// GetP2PState reports whether P2P participation is paused.
+	GetP2PState(context.Context, *GetP2PStateRequest) (*GetP2PStateResponse, error)
+	// PauseP2P stops uploads, announcements and P2P downloads. Admin only.
+	PauseP2P(context.Context, *PauseP2PRequest) (*PauseP2PResponse, error)
+	// ResumeP2P undoes PauseP2P. Admin only.
+	ResumeP2P(context.Context, *ResumeP2PRequest) (*ResumeP2PResponse, error)
+	// ListDownloads lists package downloads in progress and recently finished.
+	ListDownloads(context.Context, *ListDownloadsRequest) (*ListDownloadsResponse, error)
+	// GetConfig returns the configuration the daemon is running with, as TOML.
+	// Admin only: it reveals paths and network layout.
+	GetConfig(context.Context, *GetConfigRequest) (*GetConfigResponse, error)
+	// ReloadConfig reloads the configuration file, as on SIGHUP. Admin only.
+	ReloadConfig(context.Context, *ReloadConfigRequest) (*ReloadConfigResponse, error)
+	mustEmbedUnimplementedControlServer()
+}
+
+// UnimplementedControlServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedControlServer struct{}
+
+func (UnimplementedControlServer) GetCacheStats(context.Context, *GetCacheStatsRequest) (*GetCacheStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCacheStats not implemented")
+}
+func (UnimplementedControlServer) ListPackages(context.Context, *ListPackagesRequest) (*ListPackagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPackages not implemented")
+}
+func (UnimplementedControlServer) PinPackage(context.Context, *PinPackageRequest) (*PinPackageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PinPackage not implemented")
+}
+func (UnimplementedControlServer) UnpinPackage(context.Context, *UnpinPackageRequest) (*UnpinPackageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnpinPackage not implemented")
+}
+func (UnimplementedControlServer) DeletePackage(context.Context, *DeletePackageRequest) (*DeletePackageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePackage not implemented")
+}
+func (UnimplementedControlServer) ListPeers(context.Context, *ListPeersRequest) (*ListPeersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPeers not implemented")
+}
+func (UnimplementedControlServer) SetPeerLabel(context.Context, *SetPeerLabelRequest) (*SetPeerLabelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetPeerLabel not implemented")
+}
+func (UnimplementedControlServer) GetP2PState(context.Context, *GetP2PStateRequest) (*GetP2PStateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetP2PState not implemented")
+}
+func (UnimplementedControlServer) PauseP2P(context.Context, *PauseP2PRequest) (*PauseP2PResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PauseP2P not implemented")
+}
+func (UnimplementedControlServer) ResumeP2P(context.Context, *ResumeP2PRequest) (*ResumeP2PResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResumeP2P not implemented")
+}
+func (UnimplementedControlServer) ListDownloads(context.Context, *ListDownloadsRequest) (*ListDownloadsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDownloads not implemented")
+}
+func (UnimplementedControlServer) GetConfig(context.Context, *GetConfigRequest) (*GetConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConfig not implemented")
+}
+func (UnimplementedControlServer) ReloadConfig(context.Context, *ReloadConfigRequest) (*ReloadConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReloadConfig not implemented")
+}
+func (UnimplementedControlServer) mustEmbedUnimplementedControlServer() {}
+func (UnimplementedControlServer) testEmbeddedByValue()                 {}
+
+// UnsafeControlServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ControlServer will
+// result in compilation errors.
+type UnsafeControlServer interface {
+	mustEmbedUnimplementedControlServer()
+}
+
+func RegisterControlServer(s grpc.ServiceRegistrar, srv ControlServer) {
+	// If the following call pancis, it indicates UnimplementedControlServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Control_ServiceDesc, srv)
+}
+
+func _Control_GetCacheStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCacheStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).GetCacheStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_GetCacheStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).GetCacheStats(ctx, req.(*GetCacheStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_ListPackages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPackagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).ListPackages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_ListPackages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).ListPackages(ctx, req.(*ListPackagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_PinPackage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PinPackageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).PinPackage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_PinPackage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).PinPackage(ctx, req.(*PinPackageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_UnpinPackage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnpinPackageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).UnpinPackage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_UnpinPackage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).UnpinPackage(ctx, req.(*UnpinPackageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_DeletePackage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePackageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).DeletePackage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_DeletePackage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).DeletePackage(ctx, req.(*DeletePackageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_ListPeers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPeersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).ListPeers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_ListPeers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).ListPeers(ctx, req.(*ListPeersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_SetPeerLabel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetPeerLabelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).SetPeerLabel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_SetPeerLabel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).SetPeerLabel(ctx, req.(*SetPeerLabelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_GetP2PState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetP2PStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).GetP2PState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_GetP2PState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).GetP2PState(ctx, req.(*GetP2PStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_PauseP2P_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PauseP2PRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).PauseP2P(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_PauseP2P_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).PauseP2P(ctx, req.(*PauseP2PRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_ResumeP2P_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResumeP2PRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).ResumeP2P(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_ResumeP2P_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).ResumeP2P(ctx, req.(*ResumeP2PRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_ListDownloads_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDownloadsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).ListDownloads(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_ListDownloads_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).ListDownloads(ctx, req.(*ListDownloadsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_GetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).GetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_GetConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).GetConfig(ctx, req.(*GetConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_ReloadConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).ReloadConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_ReloadConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).ReloadConfig(ctx, req.(*ReloadConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Control_ServiceDesc is the grpc.ServiceDesc for Control service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Control_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "debswarm.control.v1.Control",
+	HandlerType: (*ControlServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCacheStats",
+			Handler:    _Control_GetCacheStats_Handler,
+		},
+		{
+			MethodName: "ListPackages",
+			Handler:    _Control_ListPackages_Handler,
+		},
+		{
+			MethodName: "PinPackage",
+			Handler:    _Control_PinPackage_Handler,
+		},
+		{
+			MethodName: "UnpinPackage",
+			Handler:    _Control_UnpinPackage_Handler,
+		},
+		{
+			MethodName: "DeletePackage",
+			Handler:    _Control_DeletePackage_Handler,
+		},
+		{
+			MethodName: "ListPeers",
+			Handler:    _Control_ListPeers_Handler,
+		},
+		{
+			MethodName: "SetPeerLabel",
+			Handler:    _Control_SetPeerLabel_Handler,
+		},
+		{
+			MethodName: "GetP2PState",
+			Handler:    _Control_GetP2PState_Handler,
+		},
+		{
+			MethodName: "PauseP2P",
+			Handler:    _Control_PauseP2P_Handler,
+		},
+		{
+			MethodName: "ResumeP2P",
+			Handler:    _Control_ResumeP2P_Handler,
+		},
+		{
+			MethodName: "ListDownloads",
+			Handler:    _Control_ListDownloads_Handler,
+		},
+		{
+			MethodName: "GetConfig",
+			Handler:    _Control_GetConfig_Handler,
+		},
+		{
+			MethodName: "ReloadConfig",
+			Handler:    _Control_ReloadConfig_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "pkg/control/v1/control.proto",
+}
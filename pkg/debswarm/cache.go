@@ -0,0 +1,206 @@
+// Package debswarm embeds debswarm's package fetching in other programs,
+// such as image builders and provisioners, without running the daemon.
+//
+// A Cache is a content-addressed store of packages keyed by SHA256. A Node
+// joins the swarm and, given a cache, serves it to peers. A Downloader
+// fetches a package by hash from the swarm's peers, falling back to a mirror
+// URL, verifies it and stores it in the cache:
+//
+//	c, err := debswarm.OpenCache("/var/cache/builder", 0, nil)
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//	node, err := debswarm.NewNode(ctx, debswarm.NodeOptions{Cache: c})
+//	if err != nil {
+//		return err
+//	}
+//	defer node.Close()
+//	d := debswarm.NewDownloader(debswarm.DownloaderOptions{Cache: c, Node: node})
+//	res, err := d.Fetch(ctx, debswarm.Request{SHA256: sum, Size: size, URL: url})
+//
+// Only this package is a supported API; the daemon's internal packages
+// change without notice.
+package debswarm
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/cache"
+)
+
+// DefaultMaxCacheSize is the cache size limit when none is given (10 GiB).
+const DefaultMaxCacheSize = 10 << 30
+
+var (
+	// ErrNotFound is returned for a package that is not in the cache.
+	ErrNotFound = cache.ErrNotFound
+	// ErrHashMismatch is returned when content does not match its SHA256.
+	ErrHashMismatch = cache.ErrHashMismatch
+	// ErrInvalidHash is returned for a SHA256 that is not 64 hex digits.
+	ErrInvalidHash = errors.New("invalid SHA256 hash")
+)
+
+// Package describes a cached package.
+type Package struct {
+	SHA256       string
+	Size         int64
+	Filename     string
+	Name         string // Debian package name, when known
+	Version      string
+	Architecture string
+	AddedAt      time.Time
+	LastAccessed time.Time
+	AccessCount  int64
+	Pinned       bool
+}
+
+// Stats summarizes a cache.
+type Stats struct {
+	Packages int
+	Size     int64
+	MaxSize  int64
+}
+
+// Cache is a package cache on disk. It may be shared with a daemon's
+// cache directory only while that daemon is stopped.
+type Cache struct {
+	c *cache.Cache
+}
+
+// OpenCache opens the cache in dir, creating it if needed. Least recently
+// used packages are evicted to keep it under maxSize bytes (0 means
+// DefaultMaxCacheSize). logger may be nil.
+func OpenCache(dir string, maxSize int64, logger *zap.Logger) (*Cache, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxCacheSize
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	c, err := cache.New(dir, maxSize, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{c: c}, nil
+}
+
+// Has reports whether the package with the given SHA256 is cached.
+func (c *Cache) Has(sha256 string) bool {
+	return validHash(sha256) && c.c.Has(sha256)
+}
+
+// Open returns the content of a cached package, or ErrNotFound. The caller
+// closes the reader; the package cannot be evicted while it is open.
+func (c *Cache) Open(sha256 string) (io.ReadCloser, *Package, error) {
+	if !validHash(sha256) {
+		return nil, nil, ErrInvalidHash
+	}
+	r, pkg, err := c.c.Get(sha256)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, packageFrom(pkg), nil
+}
+
+// Info describes a cached package without opening it, or returns
+// ErrNotFound.
+func (c *Cache) Info(sha256 string) (*Package, error) {
+	if !validHash(sha256) {
+		return nil, ErrInvalidHash
+	}
+	pkg, err := c.c.Info(sha256)
+	if err != nil {
+		return nil, err
+	}
+	return packageFrom(pkg), nil
+}
+
+// Put stores the package read from r, failing with ErrHashMismatch unless
+// its SHA256 is sha256. filename is recorded for listings.
+func (c *Cache) Put(r io.Reader, sha256, filename string) error {
+	if !validHash(sha256) {
+		return ErrInvalidHash
+	}
+	return c.c.Put(r, sha256, filename)
+}
+
+// Delete removes a package from the cache.
+func (c *Cache) Delete(sha256 string) error {
+	if !validHash(sha256) {
+		return ErrInvalidHash
+	}
+	return c.c.Delete(sha256)
+}
+
+// Pin keeps a package from being evicted.
+func (c *Cache) Pin(sha256 string) error {
+	if !validHash(sha256) {
+		return ErrInvalidHash
+	}
+	return c.c.Pin(sha256)
+}
+
+// Unpin makes a pinned package evictable again.
+func (c *Cache) Unpin(sha256 string) error {
+	if !validHash(sha256) {
+		return ErrInvalidHash
+	}
+	return c.c.Unpin(sha256)
+}
+
+// List returns every cached package.
+func (c *Cache) List() ([]*Package, error) {
+	pkgs, err := c.c.List()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Package, len(pkgs))
+	for i, p := range pkgs {
+		out[i] = packageFrom(p)
+	}
+	return out, nil
+}
+
+// Stats returns the cache's package count and size.
+func (c *Cache) Stats() Stats {
+	return Stats{Packages: c.c.Count(), Size: c.c.Size(), MaxSize: c.c.MaxSize()}
+}
+
+// Close flushes and closes the cache.
+func (c *Cache) Close() error {
+	return c.c.Close()
+}
+
+func packageFrom(p *cache.Package) *Package {
+	return &Package{
+		SHA256:       p.SHA256,
+		Size:         p.Size,
+		Filename:     p.Filename,
+		Name:         p.PackageName,
+		Version:      p.PackageVersion,
+		Architecture: p.Architecture,
+		AddedAt:      p.AddedAt,
+		LastAccessed: p.LastAccessed,
+		AccessCount:  p.AccessCount,
+		Pinned:       p.Pinned,
+	}
+}
+
+// validHash reports whether s is a lowercase hex SHA256, the form the cache
+// keys packages by.
+func validHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
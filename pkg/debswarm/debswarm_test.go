@@ -0,0 +1,110 @@
+package debswarm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/debswarm/debswarm/internal/hashutil"
+)
+
+func TestCache(t *testing.T) {
+	c, err := OpenCache(t.TempDir(), 0, nil)
+	if err != nil {
+		t.Fatalf("OpenCache: %v", err)
+	}
+	defer c.Close()
+
+	content := "sdk cache package"
+	hash := hashutil.HashBytes([]byte(content))
+	if err := c.Put(strings.NewReader("something else"), hash, "x.deb"); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("Put with the wrong content: error = %v, want ErrHashMismatch", err)
+	}
+	if err := c.Put(strings.NewReader(content), hash, "x_1.0_amd64.deb"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if !c.Has(hash) || c.Has("../../etc/passwd") {
+		t.Error("Has gave the wrong answer")
+	}
+
+	r, pkg, err := c.Open(hash)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, _ := io.ReadAll(r)
+	_ = r.Close()
+	if string(got) != content || pkg.Size != int64(len(content)) || pkg.Filename != "x_1.0_amd64.deb" {
+		t.Errorf("Open = %q, %+v", got, pkg)
+	}
+	if _, _, err := c.Open(strings.ToUpper(hash)); !errors.Is(err, ErrInvalidHash) {
+		t.Errorf("Open(uppercase) error = %v, want ErrInvalidHash", err)
+	}
+
+	if err := c.Pin(hash); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	if list, err := c.List(); err != nil || len(list) != 1 || !list[0].Pinned {
+		t.Errorf("List = %v, %v", list, err)
+	}
+	if st := c.Stats(); st.Packages != 1 || st.Size != int64(len(content)) || st.MaxSize != DefaultMaxCacheSize {
+		t.Errorf("Stats = %+v", st)
+	}
+	if err := c.Delete(hash); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := c.Info(hash); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Info after Delete: error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDownloaderFetchFromMirror(t *testing.T) {
+	content := "package from the mirror"
+	hash := hashutil.HashBytes([]byte(content))
+	var requests atomic.Int32
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = io.WriteString(w, content)
+	}))
+	defer mirror.Close()
+
+	c, err := OpenCache(t.TempDir(), 0, nil)
+	if err != nil {
+		t.Fatalf("OpenCache: %v", err)
+	}
+	defer c.Close()
+	d := NewDownloader(DownloaderOptions{Cache: c})
+	ctx := context.Background()
+	url := mirror.URL + "/debian/pool/main/h/hello/hello_2.10_amd64.deb"
+
+	res, err := d.Fetch(ctx, Request{SHA256: hash, URL: url})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if res.Source != SourceMirror || res.Size != int64(len(content)) || res.MirrorBytes != int64(len(content)) {
+		t.Errorf("Fetch = %+v", res)
+	}
+	if pkg, err := c.Info(hash); err != nil || pkg.Filename != "hello_2.10_amd64.deb" {
+		t.Errorf("cached package = %+v, %v", pkg, err)
+	}
+
+	// A second fetch is served from the cache
+	if res, err := d.Fetch(ctx, Request{SHA256: hash, URL: url}); err != nil || res.Source != SourceCache {
+		t.Errorf("second Fetch = %+v, %v", res, err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("mirror requests = %d, want 1", n)
+	}
+
+	other := hashutil.HashBytes([]byte("not what the mirror serves"))
+	if _, err := d.Fetch(ctx, Request{SHA256: other, URL: url}); err == nil || c.Has(other) {
+		t.Errorf("Fetch with the wrong hash: error = %v, cached = %v", err, c.Has(other))
+	}
+	if _, err := d.Fetch(ctx, Request{SHA256: other}); !errors.Is(err, ErrNoSource) {
+		t.Errorf("Fetch without a source: error = %v, want ErrNoSource", err)
+	}
+}
@@ -0,0 +1,196 @@
+package debswarm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/downloader"
+	"github.com/debswarm/debswarm/internal/mirror"
+	"github.com/debswarm/debswarm/internal/security"
+)
+
+// Where a fetched package came from, as reported in Result.Source
+const (
+	SourceCache  = "cache"
+	SourcePeer   = downloader.SourceTypePeer
+	SourceMirror = downloader.SourceTypeMirror
+	SourceMixed  = downloader.SourceTypeMixed
+)
+
+// ErrNoSource is returned by Fetch when no peer has the package and the
+// request has no mirror URL to fall back to.
+var ErrNoSource = errors.New("no peer provides the package and there is no mirror URL")
+
+// DownloaderOptions configures a Downloader.
+type DownloaderOptions struct {
+	// Cache is where fetched packages are stored. Required.
+	Cache *Cache
+	// Node, when set, fetches from peers in the swarm; without it every
+	// package comes from its mirror URL.
+	Node *Node
+	// Announce tells the swarm about each package fetched, so peers can
+	// download it from Node. Node must be serving Cache.
+	Announce bool
+	// MaxPeers is how many providers a package is downloaded from at most
+	// (0 = 10).
+	MaxPeers int
+	// AllowPrivateMirrors lets mirrors redirect to private and loopback
+	// addresses, for a mirror on the local network. Redirects to them are
+	// refused by default.
+	AllowPrivateMirrors bool
+	// Logger receives the downloader's logs; nil discards them.
+	Logger *zap.Logger
+}
+
+// Request names a package to fetch.
+type Request struct {
+	// SHA256 of the package, from a signed Packages index. Required: the
+	// content is verified against it before it is stored.
+	SHA256 string
+	// Size in bytes, if known. Large packages of known size are downloaded
+	// in chunks from several peers at once.
+	Size int64
+	// URL of the package on a mirror, used when no peer has it or peers
+	// are slow. Empty fetches from peers only.
+	URL string
+	// Filename recorded in the cache; defaults to the URL's base name.
+	Filename string
+}
+
+// Result describes a fetched package.
+type Result struct {
+	SHA256      string
+	Size        int64
+	Source      string // SourceCache, SourcePeer, SourceMirror or SourceMixed
+	PeerBytes   int64
+	MirrorBytes int64
+	Duration    time.Duration
+}
+
+// Downloader fetches packages into a cache. It is safe for concurrent use.
+type Downloader struct {
+	cache    *Cache
+	node     *Node
+	announce bool
+	maxPeers int
+	d        *downloader.Downloader
+	fetcher  *mirror.Fetcher
+	logger   *zap.Logger
+}
+
+// NewDownloader returns a downloader for opts.
+func NewDownloader(opts DownloaderOptions) *Downloader {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	maxPeers := opts.MaxPeers
+	if maxPeers <= 0 {
+		maxPeers = 10
+	}
+	fetcher := mirror.NewFetcher(nil, logger)
+	if opts.AllowPrivateMirrors {
+		policy, _ := security.NewMirrorPolicy(security.PolicyConfig{AllowPrivate: true})
+		fetcher.SetMirrorPolicy(policy)
+	}
+	cfg := &downloader.Config{}
+	if opts.Node != nil {
+		cfg.Scorer = opts.Node.n.Scorer()
+		cfg.Timeouts = opts.Node.n.Timeouts()
+	}
+	return &Downloader{
+		cache:    opts.Cache,
+		node:     opts.Node,
+		announce: opts.Announce && opts.Node != nil,
+		maxPeers: maxPeers,
+		d:        downloader.New(cfg),
+		fetcher:  fetcher,
+		logger:   logger,
+	}
+}
+
+// Fetch makes sure the requested package is in the cache, downloading it
+// from peers and the mirror if it is not. Read it with Cache.Open.
+func (d *Downloader) Fetch(ctx context.Context, req Request) (*Result, error) {
+	if !validHash(req.SHA256) {
+		return nil, ErrInvalidHash
+	}
+	if pkg, err := d.cache.Info(req.SHA256); err == nil {
+		return &Result{SHA256: req.SHA256, Size: pkg.Size, Source: SourceCache}, nil
+	}
+
+	var peerSources []downloader.Source
+	if d.node != nil {
+		providers, err := d.node.n.FindProvidersRanked(ctx, req.SHA256, d.maxPeers)
+		if err != nil {
+			d.logger.Debug("Provider lookup failed", zap.String("hash", req.SHA256), zap.Error(err))
+		}
+		for _, p := range providers {
+			peerSources = append(peerSources, &downloader.PeerSource{
+				Info: p,
+				Downloader: func(ctx context.Context, info peer.AddrInfo, hash string, start, end int64) ([]byte, error) {
+					return d.node.n.DownloadRange(ctx, info, hash, start, end)
+				},
+			})
+		}
+	}
+	var mirrorSource downloader.Source
+	if req.URL != "" {
+		mirrorSource = &downloader.MirrorSource{
+			URL: req.URL,
+			Fetcher: func(ctx context.Context, url string, start, end int64) ([]byte, error) {
+				// The downloader's end is exclusive, HTTP ranges are inclusive
+				if end > 0 {
+					end--
+				}
+				return d.fetcher.FetchRange(ctx, url, start, end)
+			},
+		}
+	}
+	if len(peerSources) == 0 && mirrorSource == nil {
+		return nil, ErrNoSource
+	}
+
+	res, err := d.d.Download(ctx, req.SHA256, req.Size, peerSources, mirrorSource)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", req.SHA256, err)
+	}
+	if err := d.store(req, res); err != nil {
+		return nil, err
+	}
+	if d.announce {
+		if err := d.node.Announce(ctx, req.SHA256); err != nil {
+			d.logger.Debug("Failed to announce package", zap.String("hash", req.SHA256), zap.Error(err))
+		}
+	}
+	return &Result{
+		SHA256:      req.SHA256,
+		Size:        res.Size,
+		Source:      res.Source,
+		PeerBytes:   res.PeerBytes,
+		MirrorBytes: res.MirrorBytes,
+		Duration:    res.Duration,
+	}, nil
+}
+
+// store moves a verified download into the cache.
+func (d *Downloader) store(req Request, res *downloader.DownloadResult) error {
+	filename := req.Filename
+	if filename == "" && req.URL != "" {
+		filename = path.Base(req.URL)
+	}
+	if res.FilePath == "" {
+		return d.cache.c.Put(bytes.NewReader(res.Data), req.SHA256, filename)
+	}
+	defer func() { _ = os.RemoveAll(filepath.Dir(res.FilePath)) }()
+	return d.cache.c.PutFile(res.FilePath, req.SHA256, filename, res.Size)
+}
@@ -0,0 +1,153 @@
+package debswarm
+
+import (
+	"context"
+	"io"
+
+	"go.uber.org/zap"
+
+	"github.com/debswarm/debswarm/internal/config"
+	"github.com/debswarm/debswarm/internal/p2p"
+	"github.com/debswarm/debswarm/internal/peers"
+)
+
+// DefaultBootstrapPeers returns the bootstrap peers the daemon uses by
+// default: the public libp2p bootstrap nodes.
+func DefaultBootstrapPeers() []string {
+	return config.DefaultConfig().Network.BootstrapAddrs()
+}
+
+// LoadPSK reads a private swarm's pre-shared key from a swarm.key file, as
+// written by 'debswarm psk generate'.
+func LoadPSK(path string) ([]byte, error) {
+	return p2p.LoadPSK(path)
+}
+
+// ParsePSK decodes a pre-shared key given as 64 hex digits.
+func ParsePSK(hexKey string) ([]byte, error) {
+	return p2p.ParsePSKFromHex(hexKey)
+}
+
+// NodeOptions configures a Node. The zero value joins the public swarm on a
+// random port with a throwaway identity and serves nothing.
+type NodeOptions struct {
+	// ListenPort is the TCP and QUIC port to listen on; 0 picks a free one.
+	ListenPort int
+	// DataDir keeps the node's identity key across runs. Empty generates a
+	// new identity each time.
+	DataDir string
+	// BootstrapPeers are multiaddrs to join the DHT through; nil means
+	// DefaultBootstrapPeers. A private swarm lists its own nodes here.
+	BootstrapPeers []string
+	// PSK is the pre-shared key of a private swarm, from LoadPSK or
+	// ParsePSK; nil joins the public swarm.
+	PSK []byte
+	// EnableMDNS finds peers on the local network without the DHT.
+	EnableMDNS bool
+	// PeerAllowlist, when set, limits the node to these peer IDs, CIDRs
+	// and IP addresses; PeerBlocklist excludes them.
+	PeerAllowlist []string
+	PeerBlocklist []string
+	// MaxUploadRate and MaxDownloadRate cap transfers in bytes per second
+	// (0 = unlimited).
+	MaxUploadRate   int64
+	MaxDownloadRate int64
+	// Cache, when set, is served to peers that ask for its packages.
+	Cache *Cache
+	// Version is reported to peers in the handshake; empty means "sdk".
+	Version string
+	// Logger receives the node's logs; nil discards them.
+	Logger *zap.Logger
+}
+
+// Node is a member of the swarm.
+type Node struct {
+	n *p2p.Node
+}
+
+// NewNode starts a node. It bootstraps in the background; use
+// WaitForBootstrap before the first lookup for complete results. The node
+// runs until Close or until ctx is done.
+func NewNode(ctx context.Context, opts NodeOptions) (*Node, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	bootstrap := opts.BootstrapPeers
+	if bootstrap == nil {
+		bootstrap = DefaultBootstrapPeers()
+	}
+	version := opts.Version
+	if version == "" {
+		version = "sdk"
+	}
+	n, err := p2p.New(ctx, &p2p.Config{
+		ListenPort:         opts.ListenPort,
+		DataDir:            opts.DataDir,
+		BootstrapPeers:     bootstrap,
+		EnableMDNS:         opts.EnableMDNS,
+		PreferQUIC:         true,
+		PSK:                opts.PSK,
+		PeerAllowlist:      opts.PeerAllowlist,
+		PeerBlocklist:      opts.PeerBlocklist,
+		MaxUploadRate:      opts.MaxUploadRate,
+		MaxDownloadRate:    opts.MaxDownloadRate,
+		Scorer:             peers.NewScorer(),
+		Version:            version,
+		EnableRelay:        true,
+		EnableHolePunching: true,
+	}, logger)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Cache != nil {
+		c := opts.Cache.c
+		n.SetContentProvider(p2p.ContentGetter(func(hash string) (io.ReadCloser, int64, error) {
+			r, pkg, err := c.Get(hash)
+			if err != nil {
+				return nil, 0, err
+			}
+			return r, pkg.Size, nil
+		}))
+	}
+	return &Node{n: n}, nil
+}
+
+// ID returns the node's peer ID.
+func (n *Node) ID() string {
+	return n.n.PeerID().String()
+}
+
+// Addrs returns the multiaddrs the node listens on.
+func (n *Node) Addrs() []string {
+	addrs := n.n.Addrs()
+	out := make([]string, len(addrs))
+	for i, a := range addrs {
+		out[i] = a.String()
+	}
+	return out
+}
+
+// ConnectedPeers returns the number of peers the node is connected to.
+func (n *Node) ConnectedPeers() int {
+	return n.n.ConnectedPeers()
+}
+
+// WaitForBootstrap blocks until the node has joined the DHT.
+func (n *Node) WaitForBootstrap() {
+	n.n.WaitForBootstrap()
+}
+
+// Announce tells the swarm this node has the package with the given SHA256,
+// so peers can download it from the node's cache.
+func (n *Node) Announce(ctx context.Context, sha256 string) error {
+	if !validHash(sha256) {
+		return ErrInvalidHash
+	}
+	return n.n.Provide(ctx, sha256)
+}
+
+// Close leaves the swarm.
+func (n *Node) Close() error {
+	return n.n.Close()
+}